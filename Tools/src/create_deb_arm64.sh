@@ -28,6 +28,7 @@ cp ${BGO_SPACE}/seelog_unix.xml ${BGO_SPACE}/bin/debian_arm64/debian/etc/amazon/
 cp ${BGO_SPACE}/amazon-ssm-agent.json.template ${BGO_SPACE}/bin/debian_arm64/debian/etc/amazon/ssm/
 cp ${BGO_SPACE}/packaging/ubuntu/amazon-ssm-agent.conf ${BGO_SPACE}/bin/debian_arm64/debian/etc/init/
 cp ${BGO_SPACE}/packaging/ubuntu/amazon-ssm-agent.service ${BGO_SPACE}/bin/debian_arm64/debian/lib/systemd/system/
+cp ${BGO_SPACE}/packaging/ubuntu/amazon-ssm-agent.socket ${BGO_SPACE}/bin/debian_arm64/debian/lib/systemd/system/
 
 echo "Copying debian package config files"
 
@@ -0,0 +1,45 @@
+#!/usr/bin/env bash
+echo "****************************************"
+echo "Creating tar file for FreeBSD amd64     "
+echo "****************************************"
+
+ROOTFS=${BGO_SPACE}/bin/freebsd_amd64/freebsd
+TAR_NAME=ssm-agent-freebsd.tar.gz
+DESTINATION=${BGO_SPACE}/bin/amazon-ssm-agent-freebsd-`cat ${BGO_SPACE}/VERSION`.tar.gz
+rm -rf ${ROOTFS}
+
+echo "Creating freebsd folders"
+
+mkdir -p ${ROOTFS}/usr/bin
+mkdir -p ${ROOTFS}/etc/amazon/ssm
+mkdir -p ${ROOTFS}/usr/local/etc/rc.d
+mkdir -p ${ROOTFS}/var/lib/amazon/ssm
+
+echo "Copying application files"
+
+cp ${BGO_SPACE}/bin/freebsd_amd64/amazon-ssm-agent ${ROOTFS}/usr/bin/
+cp ${BGO_SPACE}/bin/freebsd_amd64/ssm-agent-worker ${ROOTFS}/usr/bin/
+cp ${BGO_SPACE}/bin/freebsd_amd64/ssm-document-worker ${ROOTFS}/usr/bin/
+cp ${BGO_SPACE}/bin/freebsd_amd64/ssm-session-worker ${ROOTFS}/usr/bin/
+cp ${BGO_SPACE}/bin/freebsd_amd64/ssm-session-logger ${ROOTFS}/usr/bin/
+cp ${BGO_SPACE}/bin/freebsd_amd64/ssm-cli ${ROOTFS}/usr/bin/
+
+cp ${BGO_SPACE}/seelog_unix.xml ${ROOTFS}/etc/amazon/ssm/seelog.xml.template
+cp ${BGO_SPACE}/amazon-ssm-agent.json.template ${ROOTFS}/etc/amazon/ssm/
+cp ${BGO_SPACE}/RELEASENOTES.md ${ROOTFS}/etc/amazon/ssm/
+cp ${BGO_SPACE}/packaging/freebsd/amazon-ssm-agent ${ROOTFS}/usr/local/etc/rc.d/
+
+echo "Setting permissions as required by rc.d"
+
+chmod 555 ${ROOTFS}/usr/local/etc/rc.d/amazon-ssm-agent
+
+echo "Creating tar"
+(
+cd ${ROOTFS}
+tar czf $TAR_NAME * --owner=0 --group=0
+)
+
+echo "Moving tar"
+cp ${ROOTFS}/${TAR_NAME} ${DESTINATION}
+
+echo "Archive created at ${ROOTFS}/${TAR_NAME} and a versioned copy is at ${DESTINATION}"
@@ -34,6 +34,7 @@ cp ${BGO_SPACE}/README.md ${BGO_SPACE}/bin/linux_386/linux/etc/amazon/ssm/README
 cp ${BGO_SPACE}/NOTICE.md ${BGO_SPACE}/bin/linux_386/linux/etc/amazon/ssm/NOTICE.md
 cp ${BGO_SPACE}/packaging/linux/amazon-ssm-agent.conf ${BGO_SPACE}/bin/linux_386/linux/etc/init/
 cp ${BGO_SPACE}/packaging/linux/amazon-ssm-agent.service ${BGO_SPACE}/bin/linux_386/linux/etc/systemd/system/
+cp ${BGO_SPACE}/packaging/linux/amazon-ssm-agent.socket ${BGO_SPACE}/bin/linux_386/linux/etc/systemd/system/
 cd ${BGO_SPACE}/bin/linux_386/linux/usr/bin/; strip --strip-unneeded amazon-ssm-agent; strip --strip-unneeded ssm-agent-worker; strip --strip-unneeded ssm-cli; strip --strip-unneeded ssm-document-worker; strip --strip-unneeded ssm-session-worker; strip --strip-unneeded ssm-session-logger; cd ~-
 
 echo "Creating the rpm package"
@@ -24,17 +24,21 @@ import (
 	"github.com/aws/amazon-ssm-agent/agent/agent"
 	"github.com/aws/amazon-ssm-agent/agent/agentlogstocloudwatch/cloudwatchlogspublisher"
 	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/bootstrapdocument"
 	"github.com/aws/amazon-ssm-agent/agent/context"
 	"github.com/aws/amazon-ssm-agent/agent/framework/coremanager"
 	"github.com/aws/amazon-ssm-agent/agent/framework/coremodules"
 	"github.com/aws/amazon-ssm-agent/agent/health"
 	"github.com/aws/amazon-ssm-agent/agent/hibernation"
+	"github.com/aws/amazon-ssm-agent/agent/ipc/localapi"
 	"github.com/aws/amazon-ssm-agent/agent/ipc/messagebus"
 	logger "github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/performance"
 	"github.com/aws/amazon-ssm-agent/agent/rebooter"
 	"github.com/aws/amazon-ssm-agent/agent/session/utility"
 	"github.com/aws/amazon-ssm-agent/agent/ssm"
 	"github.com/aws/amazon-ssm-agent/agent/startup"
+	"github.com/aws/amazon-ssm-agent/agent/systemd"
 )
 
 const (
@@ -65,12 +69,29 @@ func start(log logger.T, instanceIDPtr *string, regionPtr *string, shouldCheckHi
 	}
 	context := context.Default(log, config)
 	context = context.With("[ssm-agent-worker]")
+	performance.Apply(log, config.Performance)
+
+	// documents run by the worker must not send notifications to systemd on behalf of the agent
+	systemd.UnsetEnvironment()
+
+	// pick up command and session worker limit and log sink changes without restarting the worker
+	appconfig.StartConfigWatcher()
+
+	// serve the agent status to tools on the instance
+	localapi.Start(context)
+
+	// run the bootstrap document, such as a proxy or certificate setup, before the worker contacts the service
+	bootstrapdocument.Run(context)
 
 	//Reset password for default RunAs user if already exists
 	sessionUtil := &utility.SessionUtil{}
 	if err := sessionUtil.ResetPasswordIfDefaultUserExists(context); err != nil {
 		log.Warnf("Reset password failed, %v", err)
 	}
+	if !config.Agent.ContainerMode {
+		// disable the session user once no session started as it for the configured number of days
+		go sessionUtil.MonitorSessionUserInactivity(log)
+	}
 
 	//Initializing the health module to send empty health pings to the service.
 	healthModule := health.NewHealthCheck(context, ssm.NewService())
@@ -98,6 +119,9 @@ func start(log logger.T, instanceIDPtr *string, regionPtr *string, shouldCheckHi
 	if status, hibernationErr := healthModule.GetAgentState(); shouldCheckHibernation && status == health.Passive && !context.AppConfig().Agent.ContainerMode {
 		//Starting hibernate mode
 		context.Log().Info("Entering SSM Agent hibernate - ", hibernationErr)
+		localapi.SetAgentState(localapi.StateHibernating)
+		// the agent started even though it cannot reach the service, systemd would otherwise restart it
+		notifyReady(log, "Hibernating, the service is not reachable")
 		go func() {
 			hibernateState.ExecuteHibernation()
 			err = startAgent(ssmAgent, context, log, instanceIDPtr, regionPtr)
@@ -119,11 +143,21 @@ func startAgent(ssmAgent agent.ISSMAgent, context context.T, log logger.T, insta
 		return
 	}
 	ssmAgent.SetCoreManager(cpm)
+	localapi.SetAgentState(localapi.StateActive)
 
 	ssmAgent.Start()
+	notifyReady(log, "Active")
 	return
 }
 
+// notifyReady tells systemd that the agent is ready, once the configuration is loaded and the first health
+// check with the service completed. Notifications of ssm-agent-worker are accepted because of NotifyAccess=all
+func notifyReady(log logger.T, status string) {
+	if _, err := systemd.Notify(systemd.Ready, systemd.Status(status)); err != nil {
+		log.Warnf("failed to notify systemd, %v", err)
+	}
+}
+
 func blockUntilSignaled(ssmAgent agent.ISSMAgent, log logger.T) {
 	// Below channel will handle all machine initiated shutdown/reboot requests.
 
@@ -16,11 +16,16 @@ package agent
 
 import (
 	"runtime"
+	"time"
 
 	"github.com/aws/amazon-ssm-agent/agent/context"
 	"github.com/aws/amazon-ssm-agent/agent/framework/coremanager"
 	"github.com/aws/amazon-ssm-agent/agent/health"
 	"github.com/aws/amazon-ssm-agent/agent/hibernation"
+	"github.com/aws/amazon-ssm-agent/agent/ipc/localapi"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/outbox"
+	"github.com/aws/amazon-ssm-agent/agent/shutdown"
 	"github.com/aws/amazon-ssm-agent/agent/version"
 	_ "go.nanomsg.org/mangos/v3/transport/ipc"
 )
@@ -96,12 +101,31 @@ func (agent *SSMAgent) Stop() {
 	log := agent.context.Log()
 	log.Info("Stopping ssm agent worker")
 	log.Flush()
+	localapi.Stop()
 
 	if agent.coreManager == nil {
 		return
 	}
 
-	agent.coreManager.Stop()
+	// the core modules drain their documents and sessions, results the service cannot receive stay queued for the restart
+	shutdown.Register(shutdown.PhaseDrain, "core modules", agent.stopCoreModules)
+	shutdown.Register(shutdown.PhaseFlush, "outbox", flushOutbox)
+	deadline := time.Duration(agent.context.AppConfig().Shutdown.DeadlineSeconds) * time.Second
+	if !shutdown.Run(log, deadline) {
+		log.Warnf("Shutdown did not complete within %v, the unfinished documents resume after the restart", deadline)
+	}
 	log.Info("Bye.")
 	log.Flush()
 }
+
+// stopCoreModules stops the core manager, which waits for the running documents of the core modules
+func (agent *SSMAgent) stopCoreModules(log log.T) {
+	agent.coreManager.Stop()
+}
+
+// flushOutbox sends the results queued while the service was unreachable
+func flushOutbox(log log.T) {
+	if outbox.Enabled() {
+		outbox.Flush(log)
+	}
+}
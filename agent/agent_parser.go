@@ -174,6 +174,17 @@ func registerManagedInstance() (managedInstanceID string, err error) {
 		return managedInstanceID, fmt.Errorf("error persisting the instance registration information. %v", err)
 	}
 
+	// keep the activation to register again if the instance is deregistered
+	if config, configErr := appconfig.Config(false); configErr == nil && config.Registration.AutoReregister {
+		if err = registration.StoreActivation(registration.ActivationInfo{
+			ActivationCode: activationCode,
+			ActivationId:   activationID,
+			Region:         region,
+		}); err != nil {
+			return managedInstanceID, fmt.Errorf("error persisting the activation for reregistration. %v", err)
+		}
+	}
+
 	// saving registration information to the registration file
 	reg := map[string]string{
 		"ManagedInstanceID": managedInstanceID,
@@ -31,7 +31,6 @@ import (
 	"github.com/aws/aws-sdk-go/aws/client"
 	"github.com/aws/aws-sdk-go/aws/credentials"
 	"github.com/aws/aws-sdk-go/aws/request"
-	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
 )
 
@@ -79,8 +78,7 @@ func createCloudWatchClientWithConfig(log log.T, config *aws.Config) cloudwatchl
 		NumMaxRetries: maxRetries,
 	})
 
-	appConfig, errConfig := appconfig.Config(false)
-	if errConfig != nil {
+	if _, errConfig := appconfig.Config(false); errConfig != nil {
 		log.Error("failed to read appconfig.")
 	} else {
 		if region, err := platform.Region(); err == nil {
@@ -92,8 +90,7 @@ func createCloudWatchClientWithConfig(log log.T, config *aws.Config) cloudwatchl
 		}
 	}
 
-	sess := session.New(config)
-	sess.Handlers.Build.PushBack(request.MakeAddToUserAgentHandler(appConfig.Agent.Name, appConfig.Agent.Version))
+	sess := sdkutil.NewSession(config, "")
 	return cloudwatchlogs.New(sess)
 }
 
@@ -37,28 +37,37 @@ var (
 // otherwise it returns a previous loaded version, if any.
 func Config(reload bool) (SsmagentConfig, error) {
 	if reload || !isLoaded() {
-		var agentConfig SsmagentConfig
-		agentConfig = DefaultConfig()
-		path, pathErr := retrieveAppConfigPath()
-		if pathErr != nil {
-			return agentConfig, nil
-		}
-		agentConfig.Os.Name = runtime.GOOS
-		agentConfig.Agent.Version = version.Version
-
-		// Process config override
-		fmt.Printf("Applying config override from %s.\n", path)
-
-		if err := jsonutil.UnmarshalFile(path, &agentConfig); err != nil {
-			fmt.Println("Failed to unmarshal config override. Fall back to default.")
+		agentConfig, loaded, err := load()
+		if !loaded {
 			return agentConfig, err
 		}
-		parser(&agentConfig)
 		cache(agentConfig)
 	}
 	return getCached(), nil
 }
 
+// load reads the app configuration from the config file.
+// loaded is false if the default config was returned because the file is missing or could not be parsed.
+func load() (agentConfig SsmagentConfig, loaded bool, err error) {
+	agentConfig = DefaultConfig()
+	path, pathErr := retrieveAppConfigPath()
+	if pathErr != nil {
+		return agentConfig, false, nil
+	}
+	agentConfig.Os.Name = runtime.GOOS
+	agentConfig.Agent.Version = version.Version
+
+	// Process config override
+	fmt.Printf("Applying config override from %s.\n", path)
+
+	if err = jsonutil.UnmarshalFile(path, &agentConfig); err != nil {
+		fmt.Println("Failed to unmarshal config override. Fall back to default.")
+		return agentConfig, false, err
+	}
+	parser(&agentConfig)
+	return agentConfig, true, nil
+}
+
 func isLoaded() bool {
 	lock.RLock()
 	defer lock.RUnlock()
@@ -88,17 +97,44 @@ func getAppConfigPath() (path string, err error) {
 	return AppConfigPath, err
 }
 
+// autoCommandWorkersLimit sizes the command worker pool from the number of CPUs
+func autoCommandWorkersLimit() int {
+	limit := CommandWorkersPerCPU * runtime.NumCPU()
+	if limit < DefaultCommandWorkersLimitMin {
+		return DefaultCommandWorkersLimitMin
+	}
+	if limit > DefaultCommandWorkersLimitMax {
+		return DefaultCommandWorkersLimitMax
+	}
+	return limit
+}
+
 // DefaultConfig returns default ssm agent configuration
 func DefaultConfig() SsmagentConfig {
 
 	var credsProfile = CredentialProfile{
-		ShareCreds: true,
+		ShareCreds:                      true,
+		CredentialProcessTimeoutSeconds: DefaultCredentialProcessTimeoutSeconds,
+	}
+	var s3 = S3Cfg{
+		UploadPartSizeMB: DefaultS3UploadPartSizeMB,
+		ObjectACL:        DefaultS3ObjectACL,
 	}
-	var s3 S3Cfg
 	var mds = MdsCfg{
-		CommandWorkersLimit: DefaultCommandWorkersLimit,
-		StopTimeoutMillis:   DefaultStopTimeoutMillis,
-		CommandRetryLimit:   DefaultCommandRetryLimit,
+		CommandWorkersLimit:  autoCommandWorkersLimit(),
+		CancelWorkersLimit:   DefaultCancelWorkersLimit,
+		QueuedJobsPerWorker:  DefaultQueuedJobsPerWorker,
+		CpuBoundWorkersLimit: runtime.NumCPU(),
+		IoBoundWorkersLimit:  IoBoundWorkersPerCPU * runtime.NumCPU(),
+		StopTimeoutMillis:    DefaultStopTimeoutMillis,
+		CommandRetryLimit:    DefaultCommandRetryLimit,
+		CommandDelivery:      CommandDeliveryPoll,
+		Polling: MdsPollingCfg{
+			MaxIntervalSeconds: DefaultPollMaxIntervalSeconds,
+		},
+		Validation: MdsValidationCfg{
+			MaxClockSkewSeconds: DefaultMessageMaxClockSkewSeconds,
+		},
 	}
 	var mgs = MgsConfig{
 		SessionWorkersLimit: DefaultSessionWorkersLimit,
@@ -123,6 +159,9 @@ func DefaultConfig() SsmagentConfig {
 		TelemetryMetricsNamespace:               DefaultTelemetryNamespace,
 		AuditExpirationDay:                      DefaultAuditExpirationDay,
 		LongRunningWorkerMonitorIntervalSeconds: defaultLongRunningWorkerMonitorIntervalSeconds,
+		UseDualStackEndpoint:                    false,
+		UseFipsEndpoint:                         false,
+		Ec2MetadataEndpointMode:                 Ec2MetadataEndpointModeIPv4,
 	}
 	var os = OsInfo{
 		Lang:    "en-US",
@@ -130,17 +169,100 @@ func DefaultConfig() SsmagentConfig {
 	}
 	var birdwatcher BirdwatcherCfg
 	var kms KmsConfig
+	var logCfg = LogCfg{
+		Sinks:            []string{LogSinkFile},
+		SyslogIdentifier: DefaultAgentName,
+	}
+	var proxy ProxyCfg
+	var tlsCfg = TlsCfg{
+		MinVersion: TlsVersion12,
+	}
+	var registrationCfg = RegistrationCfg{
+		PrivateKeyStorage:         PrivateKeyStorageFile,
+		MaxReregistrationAttempts: DefaultMaxReregistrationAttempts,
+	}
+	var fingerprintCfg FingerprintCfg
+	var failoverCfg = FailoverCfg{
+		UnreachableSeconds: DefaultFailoverUnreachableSeconds,
+		FailbackSeconds:    DefaultFailoverFailbackSeconds,
+	}
+	var privilegeCfg = PrivilegeCfg{
+		User: DefaultUnprivilegedUser,
+	}
+	var executionContextCfg ExecutionContextCfg
+	var outputCfg = OutputCfg{
+		WebhookTimeoutSeconds: DefaultOutputWebhookTimeoutSeconds,
+		TruncationStrategy:    OutputTruncationHead,
+	}
+	var artifactCacheCfg = ArtifactCacheCfg{
+		Enabled:   true,
+		MaxSizeMB: DefaultArtifactCacheMaxSizeMB,
+	}
+	var offlineCfg = OfflineCfg{
+		MaxQueueSizeMB:       DefaultOfflineMaxQueueSizeMB,
+		MaxEntryAgeHours:     DefaultOfflineMaxEntryAgeHours,
+		FlushIntervalSeconds: DefaultOfflineFlushIntervalSeconds,
+	}
+	var lowMemoryCfg = LowMemoryCfg{
+		MemoryLimitMB:         DefaultLowMemoryLimitMB,
+		SessionBufferCapacity: DefaultLowMemorySessionBufferCapacity,
+		Gatherers:             []string{"AWS:AWSComponent", "AWS:InstanceDetailedInformation", "AWS:Network"},
+	}
+	var sessionUserCfg = SessionUserCfg{
+		Name:          DefaultRunAsUserName,
+		Administrator: true,
+		SudoRule:      DefaultSessionUserSudoRule,
+	}
 
 	var ssmagentCfg = SsmagentConfig{
-		Profile:     credsProfile,
-		Mds:         mds,
-		Ssm:         ssm,
-		Mgs:         mgs,
-		Agent:       agent,
-		Os:          os,
-		S3:          s3,
-		Birdwatcher: birdwatcher,
-		Kms:         kms,
+		Profile:          credsProfile,
+		Mds:              mds,
+		Ssm:              ssm,
+		Mgs:              mgs,
+		Agent:            agent,
+		Os:               os,
+		S3:               s3,
+		Birdwatcher:      birdwatcher,
+		Kms:              kms,
+		Log:              logCfg,
+		Proxy:            proxy,
+		Tls:              tlsCfg,
+		Registration:     registrationCfg,
+		Fingerprint:      fingerprintCfg,
+		Failover:         failoverCfg,
+		Privilege:        privilegeCfg,
+		ExecutionContext: executionContextCfg,
+		Output:           outputCfg,
+		ArtifactCache:    artifactCacheCfg,
+		SessionUser:      sessionUserCfg,
+		Offline:          offlineCfg,
+		StateEncryption:  StateEncryptionCfg{Mode: StateEncryptionNone},
+		Attestation:      AttestationCfg{Sinks: []string{AttestationSinkFile}},
+		LowMemory:        lowMemoryCfg,
+		Host:             HostCfg{HostAccess: HostAccessNone, HostRoot: DefaultHostRoot, StateHostPath: DefaultDataStorePath},
+		Shutdown:         ShutdownCfg{DeadlineSeconds: DefaultShutdownDeadlineSeconds},
+		ClockSkew:        ClockSkewCfg{ThresholdSeconds: DefaultClockSkewThresholdSeconds},
+		Network: NetworkCfg{
+			ConnectionAttemptDelayMilliseconds: DefaultNetworkConnectionAttemptDelayMilliseconds,
+			DnsCacheSeconds:                    DefaultNetworkDnsCacheSeconds,
+			MaxIdleConnectionsPerHost:          DefaultNetworkMaxIdleConnectionsPerHost,
+		},
+		StateStore:       StateStoreCfg{MaxSizeMB: DefaultStateStoreMaxSizeMB},
+		Inventory:        InventoryCfg{GathererTimeoutSeconds: DefaultInventoryGathererTimeoutSeconds},
+		WorkerPool: WorkerPoolCfg{
+			Enabled:                    false,
+			Size:                       DefaultWorkerPoolSize,
+			MaxDocumentsPerWorker:      DefaultWorkerPoolMaxDocumentsPerWorker,
+			HealthCheckIntervalSeconds: DefaultWorkerPoolHealthCheckIntervalSeconds,
+		},
+		Performance: PerformanceCfg{Profile: PerformanceProfileDefault},
+		Compression: CompressionCfg{Enabled: false, ThresholdBytes: DefaultCompressionThresholdBytes},
+		Reboot:      RebootCfg{CoalesceSeconds: DefaultRebootCoalesceSeconds},
+		Export:      ExportCfg{Format: ExportFormatJson, MaxFileSizeMB: DefaultExportMaxFileSizeMB},
+
+		ServiceDocuments: ServiceDocumentsCfg{CacheMaxEntries: DefaultDocumentCacheMaxEntries},
+		InstanceTags:     InstanceTagsCfg{RefreshIntervalSeconds: DefaultInstanceTagsRefreshIntervalSeconds},
+		Watchdog:         WatchdogCfg{Enabled: true, StallTimeoutSeconds: DefaultWatchdogStallTimeoutSeconds},
 	}
 
 	return ssmagentCfg
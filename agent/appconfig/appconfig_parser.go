@@ -16,13 +16,40 @@
 package appconfig
 
 import (
+	"fmt"
 	"log"
+	"net"
+	"net/url"
+	"path"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/aws/amazon-ssm-agent/agent/fips"
 )
 
+var accountIdPattern = regexp.MustCompile(`^[0-9]{12}$`)
+
+// sha256Pattern matches the lower case hex digests of sha256 hashes
+var sha256Pattern = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// accountNamePattern matches local account names that are valid on both Linux and Windows
+var accountNamePattern = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_.-]{0,19}$`)
+
 //func parser(config *T) {
 func parser(config *SsmagentConfig) {
 	log.Printf("processing appconfig overrides")
 
+	// Profile config
+	config.Profile.CredentialProcessTimeoutSeconds = getNumericValue(
+		config.Profile.CredentialProcessTimeoutSeconds,
+		DefaultCredentialProcessTimeoutSecondsMin,
+		DefaultCredentialProcessTimeoutSecondsMax,
+		DefaultCredentialProcessTimeoutSeconds)
+
 	// Agent config
 	config.Agent.Name = getStringValue(config.Agent.Name, DefaultAgentName)
 	config.Agent.OrchestrationRootDir = getStringValue(config.Agent.OrchestrationRootDir, defaultOrchestrationRootDirName)
@@ -43,13 +70,34 @@ func parser(config *SsmagentConfig) {
 		DefaultAuditExpirationDayMin,
 		DefaultAuditExpirationDayMax,
 		DefaultAuditExpirationDay)
+	config.Agent.Ec2MetadataEndpointMode = getEc2MetadataEndpointMode(config.Agent.Ec2MetadataEndpointMode)
+	config.Agent.BootstrapDocumentPath = strings.TrimSpace(config.Agent.BootstrapDocumentPath)
+	// an agent built for FIPS validated cryptography always uses the FIPS endpoints
+	config.Agent.UseFipsEndpoint = config.Agent.UseFipsEndpoint || fips.Enabled
 
 	// MDS config
-	config.Mds.CommandWorkersLimit = getNumericValue(
+	config.Mds.CommandWorkersLimit = getNumericValueAboveMin(
 		config.Mds.CommandWorkersLimit,
-		DefaultCommandWorkersLimitMin,
-		config.Mds.CommandWorkersLimit, // we do not restrict max number of worker limit here
-		DefaultCommandWorkersLimit)
+		1, // we do not restrict max number of worker limit here
+		autoCommandWorkersLimit())
+	config.Mds.CancelWorkersLimit = getNumericValue(
+		config.Mds.CancelWorkersLimit,
+		DefaultCancelWorkersLimitMin,
+		DefaultCancelWorkersLimitMax,
+		DefaultCancelWorkersLimit)
+	config.Mds.QueuedJobsPerWorker = getNumericValue(
+		config.Mds.QueuedJobsPerWorker,
+		DefaultQueuedJobsPerWorkerMin,
+		DefaultQueuedJobsPerWorkerMax,
+		DefaultQueuedJobsPerWorker)
+	config.Mds.CpuBoundWorkersLimit = getNumericValueAboveMin(
+		config.Mds.CpuBoundWorkersLimit,
+		1,
+		runtime.NumCPU())
+	config.Mds.IoBoundWorkersLimit = getNumericValueAboveMin(
+		config.Mds.IoBoundWorkersLimit,
+		1,
+		IoBoundWorkersPerCPU*runtime.NumCPU())
 	config.Mds.CommandRetryLimit = getNumericValue(
 		config.Mds.CommandRetryLimit,
 		DefaultCommandRetryLimitMin,
@@ -61,6 +109,18 @@ func parser(config *SsmagentConfig) {
 		DefaultStopTimeoutMillisMax,
 		DefaultStopTimeoutMillis)
 	config.Mds.Endpoint = getStringValue(config.Mds.Endpoint, "")
+	config.Mds.CommandDelivery = getCommandDelivery(config.Mds.CommandDelivery)
+	config.Mds.Polling.MaxIntervalSeconds = getNumericValue(
+		config.Mds.Polling.MaxIntervalSeconds,
+		DefaultPollMaxIntervalSecondsMin,
+		DefaultPollMaxIntervalSecondsMax,
+		DefaultPollMaxIntervalSeconds)
+	config.Mds.Polling.Windows = getPollWindows(config.Mds.Polling.Windows)
+	config.Mds.Validation.MaxClockSkewSeconds = getNumericValue(
+		config.Mds.Validation.MaxClockSkewSeconds,
+		DefaultMessageMaxClockSkewSecondsMin,
+		DefaultMessageMaxClockSkewSecondsMax,
+		DefaultMessageMaxClockSkewSeconds)
 
 	// SSM config
 	config.Ssm.Endpoint = getStringValue(config.Ssm.Endpoint, "")
@@ -82,6 +142,863 @@ func parser(config *SsmagentConfig) {
 		config.Ssm.RunCommandLogsRetentionDurationHours,
 		DefaultStateOrchestrationLogsRetentionDurationHoursMin,
 		DefaultRunCommandLogsRetentionDurationHours)
+
+	// S3 config, a zero bandwidth leaves output uploads unthrottled
+	config.S3.UploadPartSizeMB = getNumericValue(
+		config.S3.UploadPartSizeMB,
+		DefaultS3UploadPartSizeMBMin,
+		DefaultS3UploadPartSizeMBMax,
+		DefaultS3UploadPartSizeMB)
+	config.S3.UploadBandwidthKBps = getNumericValueAboveMin(config.S3.UploadBandwidthKBps, 0, 0)
+	config.S3.BucketEndpoints = getBucketEndpoints(config.S3.BucketEndpoints)
+	config.S3.ServerSideEncryption, config.S3.SSEKMSKeyId = getS3ServerSideEncryption(config.S3.ServerSideEncryption, config.S3.SSEKMSKeyId)
+	config.S3.ExpectedBucketOwner = getExpectedBucketOwner(config.S3.ExpectedBucketOwner)
+	config.S3.ObjectACL = getS3ObjectACL(config.S3.ObjectACL)
+
+	// Log config
+	config.Log.Sinks = getLogSinks(config.Log.Sinks)
+	config.Log.SyslogIdentifier = getSyslogIdentifier(config.Log.SyslogIdentifier)
+	if config.Log.MaxDirectorySizeMB != 0 {
+		// a size budget below the minimum would evict every rotated file on each check
+		config.Log.MaxDirectorySizeMB = getNumericValueAboveMin(
+			config.Log.MaxDirectorySizeMB,
+			DefaultLogMaxDirectorySizeMBMin,
+			DefaultLogMaxDirectorySizeMBMin)
+	}
+
+	// Proxy config
+	config.Proxy.PacFile = strings.TrimSpace(config.Proxy.PacFile)
+	config.Proxy.Services = getProxyServiceRules(config.Proxy.Services)
+
+	// TLS config
+	config.Tls.CaBundlePath = strings.TrimSpace(config.Tls.CaBundlePath)
+	config.Tls.MinVersion = getTlsMinVersion(config.Tls.MinVersion)
+	var cipherSuites []string
+	for _, cipherSuite := range config.Tls.CipherSuites {
+		if cipherSuite = strings.TrimSpace(cipherSuite); cipherSuite != "" {
+			cipherSuites = append(cipherSuites, cipherSuite)
+		}
+	}
+	config.Tls.CipherSuites = cipherSuites
+
+	// Registration config
+	config.Registration.PrivateKeyStorage = getPrivateKeyStorage(config.Registration.PrivateKeyStorage)
+	config.Registration.ActivationHook = strings.TrimSpace(config.Registration.ActivationHook)
+	config.Registration.MaxReregistrationAttempts = getNumericValue(
+		config.Registration.MaxReregistrationAttempts,
+		DefaultMaxReregistrationAttemptsMin,
+		DefaultMaxReregistrationAttemptsMax,
+		DefaultMaxReregistrationAttempts)
+
+	// Fingerprint config, a zero similarity threshold keeps the threshold saved with the fingerprint
+	if config.Fingerprint.SimilarityThreshold != 0 {
+		config.Fingerprint.SimilarityThreshold = getNumericValue(
+			config.Fingerprint.SimilarityThreshold,
+			DefaultFingerprintSimilarityThresholdMin,
+			DefaultFingerprintSimilarityThresholdMax,
+			DefaultFingerprintSimilarityThreshold)
+	}
+	config.Fingerprint.IdentityHook = strings.TrimSpace(config.Fingerprint.IdentityHook)
+
+	// Failover config
+	config.Failover.Secondaries = getFailoverSecondaries(config.Failover.Secondaries)
+	config.Failover.UnreachableSeconds = getNumericValue(
+		config.Failover.UnreachableSeconds,
+		DefaultFailoverUnreachableSecondsMin,
+		DefaultFailoverUnreachableSecondsMax,
+		DefaultFailoverUnreachableSeconds)
+	config.Failover.FailbackSeconds = getNumericValue(
+		config.Failover.FailbackSeconds,
+		DefaultFailoverFailbackSecondsMin,
+		DefaultFailoverFailbackSecondsMax,
+		DefaultFailoverFailbackSeconds)
+
+	// Privilege config
+	config.Privilege.User = getStringValue(config.Privilege.User, DefaultUnprivilegedUser)
+	config.Privilege.PluginPrivileges = getPluginPrivileges(config.Privilege.PluginPrivileges)
+
+	// Execution context config
+	config.ExecutionContext.SELinuxDomain = strings.TrimSpace(config.ExecutionContext.SELinuxDomain)
+	config.ExecutionContext.AppArmorProfile = strings.TrimSpace(config.ExecutionContext.AppArmorProfile)
+
+	// Output config, output is only posted to webhooks over https since it can contain secrets
+	config.Output.WebhookUrl = getWebhookUrl(config.Output.WebhookUrl)
+	config.Output.WebhookTimeoutSeconds = getNumericValue(
+		config.Output.WebhookTimeoutSeconds,
+		DefaultOutputWebhookTimeoutSecondsMin,
+		DefaultOutputWebhookTimeoutSecondsMax,
+		DefaultOutputWebhookTimeoutSeconds)
+	config.Output.TruncationStrategy = getTruncationStrategy(config.Output.TruncationStrategy)
+
+	// Artifact cache config
+	config.ArtifactCache.MaxSizeMB = getNumericValue(
+		config.ArtifactCache.MaxSizeMB,
+		DefaultArtifactCacheMaxSizeMBMin,
+		DefaultArtifactCacheMaxSizeMBMax,
+		DefaultArtifactCacheMaxSizeMB)
+
+	// Session user config
+	config.SessionUser.Name = getSessionUserName(config.SessionUser.Name)
+	config.SessionUser.Uid = getNumericValueAboveMin(config.SessionUser.Uid, 0, 0)
+	config.SessionUser.Gid = getNumericValueAboveMin(config.SessionUser.Gid, 0, 0)
+	config.SessionUser.SudoRule = getSudoRule(config.SessionUser.SudoRule)
+	config.SessionUser.HomeSkeleton = strings.TrimSpace(config.SessionUser.HomeSkeleton)
+	config.SessionUser.InactiveDays = getNumericValue(config.SessionUser.InactiveDays, 0, DefaultSessionUserInactiveDaysMax, 0)
+
+	// Local api config
+	config.LocalApi.AllowedGroup = strings.TrimSpace(config.LocalApi.AllowedGroup)
+
+	// Offline queue config
+	config.Offline.MaxQueueSizeMB = getNumericValue(
+		config.Offline.MaxQueueSizeMB,
+		DefaultOfflineMaxQueueSizeMBMin,
+		DefaultOfflineMaxQueueSizeMBMax,
+		DefaultOfflineMaxQueueSizeMB)
+	config.Offline.MaxEntryAgeHours = getNumericValue(
+		config.Offline.MaxEntryAgeHours,
+		DefaultOfflineMaxEntryAgeHoursMin,
+		DefaultOfflineMaxEntryAgeHoursMax,
+		DefaultOfflineMaxEntryAgeHours)
+	config.Offline.FlushIntervalSeconds = getNumericValue(
+		config.Offline.FlushIntervalSeconds,
+		DefaultOfflineFlushIntervalSecondsMin,
+		DefaultOfflineFlushIntervalSecondsMax,
+		DefaultOfflineFlushIntervalSeconds)
+
+	// State encryption config
+	config.StateEncryption.Mode, config.StateEncryption.KmsKeyId = getStateEncryption(config.StateEncryption.Mode, config.StateEncryption.KmsKeyId)
+	config.Attestation.Sinks = getAttestationSinks(config.Attestation.Sinks, config.Attestation.S3BucketName)
+	config.ScriptSandbox.SeccompProfile = strings.TrimSpace(config.ScriptSandbox.SeccompProfile)
+	config.ScriptSandbox.LandlockReadOnlyPaths = getAbsolutePaths(config.ScriptSandbox.LandlockReadOnlyPaths)
+	config.ScriptSandbox.LandlockReadWritePaths = getAbsolutePaths(config.ScriptSandbox.LandlockReadWritePaths)
+	config.Isolation.Chroot = getAbsolutePath(config.Isolation.Chroot)
+	config.ScriptInspection.Rules = getScriptInspectionRules(config.ScriptInspection.Rules)
+	config.ScriptInspection.YaraPath = strings.TrimSpace(config.ScriptInspection.YaraPath)
+	config.ScriptInspection.YaraRules = getAbsolutePaths(config.ScriptInspection.YaraRules)
+	config.ScriptInspection.YaraSeverity = getScriptInspectionSeverity(config.ScriptInspection.YaraSeverity)
+	config.ExecutionPolicy.AllowedPlugins = getMatchPatterns(config.ExecutionPolicy.AllowedPlugins)
+	config.ExecutionPolicy.DeniedPlugins = getMatchPatterns(config.ExecutionPolicy.DeniedPlugins)
+	config.ExecutionPolicy.AllowedDocuments = getMatchPatterns(config.ExecutionPolicy.AllowedDocuments)
+	config.ExecutionPolicy.DeniedDocuments = getMatchPatterns(config.ExecutionPolicy.DeniedDocuments)
+	config.ExecutionPolicy.AllowedSourceAccounts = getAccountIds(config.ExecutionPolicy.AllowedSourceAccounts, false)
+	config.ExecutionPolicy.DeniedSourceAccounts = getAccountIds(config.ExecutionPolicy.DeniedSourceAccounts, true)
+	config.ExecutionPolicy.ApprovalRequiredPlugins = getMatchPatterns(config.ExecutionPolicy.ApprovalRequiredPlugins)
+	config.ExecutionPolicy.ApproverPublicKeys = getAbsolutePaths(config.ExecutionPolicy.ApproverPublicKeys)
+
+	// Low memory config
+	config.LowMemory.MemoryLimitMB = getNumericValue(
+		config.LowMemory.MemoryLimitMB,
+		DefaultLowMemoryLimitMBMin,
+		DefaultLowMemoryLimitMBMax,
+		DefaultLowMemoryLimitMB)
+	config.LowMemory.SessionBufferCapacity = getNumericValue(
+		config.LowMemory.SessionBufferCapacity,
+		DefaultLowMemorySessionBufferCapacityMin,
+		DefaultLowMemorySessionBufferCapacityMax,
+		DefaultLowMemorySessionBufferCapacity)
+	config.LowMemory.Gatherers = getMatchPatterns(config.LowMemory.Gatherers)
+	if config.LowMemory.Enabled && config.Mds.CommandWorkersLimit > DefaultLowMemoryCommandWorkersLimit {
+		config.Mds.CommandWorkersLimit = DefaultLowMemoryCommandWorkersLimit
+	}
+
+	// Host config
+	config.Host.HostAccess = getHostAccess(config.Host.HostAccess)
+	config.Host.HostRoot = getStringValue(strings.TrimSpace(config.Host.HostRoot), DefaultHostRoot)
+	config.Host.StateHostPath = getStringValue(strings.TrimSpace(config.Host.StateHostPath), DefaultDataStorePath)
+	config.Host.ActivationId = strings.TrimSpace(config.Host.ActivationId)
+	config.Host.ActivationCodeFile = strings.TrimSpace(config.Host.ActivationCodeFile)
+	config.Host.ActivationRegion = strings.TrimSpace(config.Host.ActivationRegion)
+
+	// Shutdown config
+	config.Shutdown.DeadlineSeconds = getNumericValue(
+		config.Shutdown.DeadlineSeconds,
+		DefaultShutdownDeadlineSecondsMin,
+		DefaultShutdownDeadlineSecondsMax,
+		DefaultShutdownDeadlineSeconds)
+
+	// Clock skew config
+	config.ClockSkew.ThresholdSeconds = getNumericValue(
+		config.ClockSkew.ThresholdSeconds,
+		DefaultClockSkewThresholdSecondsMin,
+		DefaultClockSkewThresholdSecondsMax,
+		DefaultClockSkewThresholdSeconds)
+
+	// Instance metadata config
+	config.Imds.IdentityCertificatePath = strings.TrimSpace(config.Imds.IdentityCertificatePath)
+
+	// Network config
+	config.Network.SourceAddress = strings.TrimSpace(config.Network.SourceAddress)
+	if config.Network.SourceAddress != "" && net.ParseIP(config.Network.SourceAddress) == nil {
+		log.Printf("ignoring network source address %v, it is not an IP address", config.Network.SourceAddress)
+		config.Network.SourceAddress = ""
+	}
+	config.Network.SourceInterface = strings.TrimSpace(config.Network.SourceInterface)
+	config.Network.ConnectionAttemptDelayMilliseconds = getNumericValue(
+		config.Network.ConnectionAttemptDelayMilliseconds,
+		DefaultNetworkConnectionAttemptDelayMillisecondsMin,
+		DefaultNetworkConnectionAttemptDelayMillisecondsMax,
+		DefaultNetworkConnectionAttemptDelayMilliseconds)
+	config.Network.DnsCacheSeconds = getNumericValue(
+		config.Network.DnsCacheSeconds,
+		DefaultNetworkDnsCacheSecondsMin,
+		DefaultNetworkDnsCacheSecondsMax,
+		DefaultNetworkDnsCacheSeconds)
+	config.Network.MaxIdleConnectionsPerHost = getNumericValue(
+		config.Network.MaxIdleConnectionsPerHost,
+		DefaultNetworkMaxIdleConnectionsPerHostMin,
+		DefaultNetworkMaxIdleConnectionsPerHostMax,
+		DefaultNetworkMaxIdleConnectionsPerHost)
+
+	// State store config
+	config.StateStore.MaxSizeMB = getNumericValue(
+		config.StateStore.MaxSizeMB,
+		DefaultStateStoreMaxSizeMBMin,
+		DefaultStateStoreMaxSizeMBMax,
+		DefaultStateStoreMaxSizeMB)
+
+	// Inventory config
+	config.Inventory.GathererTimeoutSeconds = getNumericValue(
+		config.Inventory.GathererTimeoutSeconds,
+		DefaultInventoryGathererTimeoutSecondsMin,
+		DefaultInventoryGathererTimeoutSecondsMax,
+		DefaultInventoryGathererTimeoutSeconds)
+
+	// Worker pool config
+	config.WorkerPool.Size = getNumericValue(
+		config.WorkerPool.Size,
+		DefaultWorkerPoolSizeMin,
+		DefaultWorkerPoolSizeMax,
+		DefaultWorkerPoolSize)
+	config.WorkerPool.MaxDocumentsPerWorker = getNumericValue(
+		config.WorkerPool.MaxDocumentsPerWorker,
+		DefaultWorkerPoolMaxDocumentsPerWorkerMin,
+		DefaultWorkerPoolMaxDocumentsPerWorkerMax,
+		DefaultWorkerPoolMaxDocumentsPerWorker)
+	config.WorkerPool.HealthCheckIntervalSeconds = getNumericValue(
+		config.WorkerPool.HealthCheckIntervalSeconds,
+		DefaultWorkerPoolHealthCheckIntervalSecondsMin,
+		DefaultWorkerPoolHealthCheckIntervalSecondsMax,
+		DefaultWorkerPoolHealthCheckIntervalSeconds)
+
+	// Performance config
+	config.Performance.Profile = getPerformanceProfile(config.Performance.Profile)
+
+	// Compression config
+	config.Compression.ThresholdBytes = getNumericValue(
+		config.Compression.ThresholdBytes,
+		DefaultCompressionThresholdBytesMin,
+		DefaultCompressionThresholdBytesMax,
+		DefaultCompressionThresholdBytes)
+
+	// Reboot config
+	config.Reboot.CoalesceSeconds = getNumericValue(
+		config.Reboot.CoalesceSeconds,
+		DefaultRebootCoalesceSecondsMin,
+		DefaultRebootCoalesceSecondsMax,
+		DefaultRebootCoalesceSeconds)
+	config.Reboot.MaintenanceWindows = getPollWindows(config.Reboot.MaintenanceWindows)
+	config.Reboot.BlackoutWindows = getPollWindows(config.Reboot.BlackoutWindows)
+
+	// Export config
+	config.Export.Format = getExportFormat(config.Export.Format)
+	config.Export.LocalFile = strings.TrimSpace(config.Export.LocalFile)
+	config.Export.Socket = strings.TrimSpace(config.Export.Socket)
+	config.Export.MaxFileSizeMB = getNumericValue(
+		config.Export.MaxFileSizeMB,
+		DefaultExportMaxFileSizeMBMin,
+		DefaultExportMaxFileSizeMBMax,
+		DefaultExportMaxFileSizeMB)
+	config.Export.AccountId = strings.TrimSpace(config.Export.AccountId)
+	if config.Export.AccountId != "" && !accountIdPattern.MatchString(config.Export.AccountId) {
+		log.Printf("ignoring export account %q, it is not an account id", config.Export.AccountId)
+		config.Export.AccountId = ""
+	}
+
+	// Events config
+	config.Events.Subscribers = getEventSubscribers(config.Events.Subscribers)
+
+	// Service documents config
+	config.ServiceDocuments.CacheMaxEntries = getNumericValue(
+		config.ServiceDocuments.CacheMaxEntries,
+		DefaultDocumentCacheMaxEntriesMin,
+		DefaultDocumentCacheMaxEntriesMax,
+		DefaultDocumentCacheMaxEntries)
+	config.ServiceDocuments.Pinned = getPinnedDocuments(config.ServiceDocuments.Pinned)
+
+	// Instance tags config
+	config.InstanceTags.RefreshIntervalSeconds = getNumericValue(
+		config.InstanceTags.RefreshIntervalSeconds,
+		DefaultInstanceTagsRefreshIntervalSecondsMin,
+		DefaultInstanceTagsRefreshIntervalSecondsMax,
+		DefaultInstanceTagsRefreshIntervalSeconds)
+
+	// Watchdog config
+	config.Watchdog.StallTimeoutSeconds = getNumericValue(
+		config.Watchdog.StallTimeoutSeconds,
+		DefaultWatchdogStallTimeoutSecondsMin,
+		DefaultWatchdogStallTimeoutSecondsMax,
+		DefaultWatchdogStallTimeoutSeconds)
+
+	// External plugin config
+	for i := range config.ExternalPlugins {
+		config.ExternalPlugins[i].Name = strings.TrimSpace(config.ExternalPlugins[i].Name)
+		config.ExternalPlugins[i].Path = strings.TrimSpace(config.ExternalPlugins[i].Path)
+		config.ExternalPlugins[i].RunAsUser = strings.TrimSpace(config.ExternalPlugins[i].RunAsUser)
+		config.ExternalPlugins[i].TimeoutSeconds = getNumericValue(
+			config.ExternalPlugins[i].TimeoutSeconds,
+			DefaultExternalPluginTimeoutSecondsMin,
+			DefaultExternalPluginTimeoutSecondsMax,
+			DefaultExternalPluginTimeoutSeconds)
+	}
+}
+
+// getSessionUserName returns the default session user if the configured name is not a valid account name
+func getSessionUserName(name string) string {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return DefaultRunAsUserName
+	}
+	if !accountNamePattern.MatchString(name) {
+		log.Printf("ignoring session user name %q, it is not a valid account name", name)
+		return DefaultRunAsUserName
+	}
+	return name
+}
+
+// getSudoRule returns the default sudoers rule if the configured rule is empty or spans several lines
+func getSudoRule(rule string) string {
+	rule = strings.TrimSpace(rule)
+	if rule == "" || strings.ContainsAny(rule, "\r\n") {
+		return DefaultSessionUserSudoRule
+	}
+	return rule
+}
+
+// getWebhookUrl drops an output webhook url that does not use https
+func getWebhookUrl(webhookUrl string) string {
+	webhookUrl = strings.TrimSpace(webhookUrl)
+	if webhookUrl == "" {
+		return ""
+	}
+	if parsedUrl, err := url.Parse(webhookUrl); err != nil || parsedUrl.Scheme != "https" || parsedUrl.Host == "" {
+		log.Printf("ignoring output webhook url %v, only https urls are supported", webhookUrl)
+		return ""
+	}
+	return webhookUrl
+}
+
+// getTruncationStrategy returns the output truncation strategy, keeping the head for unknown strategies
+func getTruncationStrategy(strategy string) string {
+	strategy = strings.ToLower(strings.TrimSpace(strategy))
+	switch strategy {
+	case OutputTruncationHead, OutputTruncationTail, OutputTruncationHeadTail:
+		return strategy
+	case "":
+		return OutputTruncationHead
+	default:
+		log.Printf("ignoring unknown output truncation strategy %v, keeping the head of the output", strategy)
+		return OutputTruncationHead
+	}
+}
+
+// getPluginPrivileges drops plugin privilege overrides that are neither root nor user
+func getPluginPrivileges(privileges map[string]string) map[string]string {
+	result := make(map[string]string)
+	for plugin, privilege := range privileges {
+		privilege = strings.ToLower(strings.TrimSpace(privilege))
+		if privilege != PrivilegeRoot && privilege != PrivilegeUser {
+			log.Printf("ignoring unknown privilege %q for plugin %v", privilege, plugin)
+			continue
+		}
+		result[strings.TrimSpace(plugin)] = privilege
+	}
+	return result
+}
+
+// getBucketEndpoints drops bucket endpoint overrides without a bucket name or endpoint
+func getBucketEndpoints(bucketEndpoints map[string]string) map[string]string {
+	result := make(map[string]string)
+	for bucket, endpoint := range bucketEndpoints {
+		bucket, endpoint = strings.TrimSpace(bucket), strings.TrimSpace(endpoint)
+		if bucket == "" || endpoint == "" {
+			log.Printf("ignoring S3 endpoint %q for bucket %q", endpoint, bucket)
+			continue
+		}
+		result[bucket] = endpoint
+	}
+	return result
+}
+
+// getS3ServerSideEncryption drops unknown encryption algorithms, a KMS key without an algorithm implies aws:kms
+// and a KMS key is only kept for aws:kms
+func getS3ServerSideEncryption(algorithm string, kmsKeyId string) (string, string) {
+	algorithm, kmsKeyId = strings.TrimSpace(algorithm), strings.TrimSpace(kmsKeyId)
+	if algorithm == "" && kmsKeyId != "" {
+		algorithm = S3ServerSideEncryptionKms
+	}
+	switch algorithm {
+	case "", S3ServerSideEncryptionAes256:
+		return algorithm, ""
+	case S3ServerSideEncryptionKms:
+		return algorithm, kmsKeyId
+	}
+	log.Printf("ignoring unknown S3 server side encryption %v", algorithm)
+	return "", ""
+}
+
+// getStateEncryption returns the lower case state encryption mode, defaulting to none. A KMS key without a mode
+// implies kms, and kms without a key falls back to local so that the state is still encrypted.
+func getStateEncryption(mode string, kmsKeyId string) (string, string) {
+	mode, kmsKeyId = strings.ToLower(strings.TrimSpace(mode)), strings.TrimSpace(kmsKeyId)
+	if mode == "" && kmsKeyId != "" {
+		mode = StateEncryptionKms
+	}
+	switch mode {
+	case "", StateEncryptionNone:
+		return StateEncryptionNone, ""
+	case StateEncryptionLocal:
+		return mode, ""
+	case StateEncryptionKms:
+		if kmsKeyId == "" {
+			log.Printf("state encryption kms requires a KmsKeyId, using local")
+			return StateEncryptionLocal, ""
+		}
+		return mode, kmsKeyId
+	}
+	log.Printf("ignoring unknown state encryption mode %q", mode)
+	return StateEncryptionNone, ""
+}
+
+// getExpectedBucketOwner drops an expected bucket owner that is not an account id
+func getExpectedBucketOwner(owner string) string {
+	owner = strings.TrimSpace(owner)
+	if owner != "" && !accountIdPattern.MatchString(owner) {
+		log.Printf("ignoring expected bucket owner %v, it is not an account id", owner)
+		return ""
+	}
+	return owner
+}
+
+// getS3ObjectACL returns the canned acl for uploaded objects, falling back to bucket-owner-full-control
+func getS3ObjectACL(acl string) string {
+	acl = strings.TrimSpace(acl)
+	for _, cannedACL := range S3CannedACLs {
+		if acl == cannedACL {
+			return acl
+		}
+	}
+	if acl != "" {
+		log.Printf("ignoring unknown S3 object acl %v", acl)
+	}
+	return DefaultS3ObjectACL
+}
+
+// getCommandDelivery returns poll unless push delivery over the control channel is configured
+func getCommandDelivery(configValue string) string {
+	switch strings.ToLower(strings.TrimSpace(configValue)) {
+	case CommandDeliveryPush:
+		return CommandDeliveryPush
+	case "", CommandDeliveryPoll:
+		return CommandDeliveryPoll
+	}
+	log.Printf("ignoring unknown command delivery %v", configValue)
+	return CommandDeliveryPoll
+}
+
+// getPollWindows drops poll windows with an invalid start, end or day of the week
+func getPollWindows(windows []PollWindowCfg) []PollWindowCfg {
+	var result []PollWindowCfg
+	for _, window := range windows {
+		window.Start, window.End = strings.TrimSpace(window.Start), strings.TrimSpace(window.End)
+		if _, err := time.Parse(PollWindowTimeFormat, window.Start); err != nil {
+			log.Printf("ignoring poll window with invalid start %q", window.Start)
+			continue
+		}
+		if _, err := time.Parse(PollWindowTimeFormat, window.End); err != nil {
+			log.Printf("ignoring poll window with invalid end %q", window.End)
+			continue
+		}
+		var days []string
+		valid := true
+		for _, day := range window.Days {
+			if _, ok := PollWindowDays[strings.ToLower(strings.TrimSpace(day))]; !ok {
+				log.Printf("ignoring poll window with invalid day %q", day)
+				valid = false
+				break
+			}
+			days = append(days, strings.ToLower(strings.TrimSpace(day)))
+		}
+		if !valid {
+			continue
+		}
+		window.Days = days
+		result = append(result, window)
+	}
+	return result
+}
+
+// getFailoverSecondaries drops secondaries without a region and normalizes the service names of endpoint overrides
+func getFailoverSecondaries(secondaries []FailoverTargetCfg) []FailoverTargetCfg {
+	var result []FailoverTargetCfg
+	for _, secondary := range secondaries {
+		secondary.Region = strings.TrimSpace(secondary.Region)
+		if secondary.Region == "" {
+			log.Printf("ignoring failover secondary without region")
+			continue
+		}
+		endpoints := make(map[string]string)
+		for service, endpoint := range secondary.Endpoints {
+			if endpoint = strings.TrimSpace(endpoint); endpoint != "" {
+				endpoints[strings.ToLower(strings.TrimSpace(service))] = endpoint
+			}
+		}
+		secondary.Endpoints = endpoints
+		result = append(result, secondary)
+	}
+	return result
+}
+
+// getPrivateKeyStorage returns the lower case storage backend, defaulting to file
+func getPrivateKeyStorage(configValue string) string {
+	storage := strings.ToLower(strings.TrimSpace(configValue))
+	switch storage {
+	case "":
+		return PrivateKeyStorageFile
+	case PrivateKeyStorageFile, PrivateKeyStorageTpm, PrivateKeyStorageOs, PrivateKeyStorageAuto:
+		return storage
+	}
+	log.Printf("ignoring unknown private key storage %q", configValue)
+	return PrivateKeyStorageFile
+}
+
+// getTlsMinVersion accepts versions written as 1.2, TLS1.2 or TLSv1.2 and defaults to TLS 1.2
+func getTlsMinVersion(configValue string) string {
+	version := strings.TrimPrefix(strings.ToLower(strings.TrimSpace(configValue)), "tls")
+	switch strings.TrimPrefix(version, "v") {
+	case "", "1.2":
+		return TlsVersion12
+	case "1.3":
+		return TlsVersion13
+	}
+	log.Printf("ignoring unsupported minimum TLS version %q", configValue)
+	return TlsVersion12
+}
+
+// getProxyServiceRules normalizes service names and drops rules with an invalid proxy address
+func getProxyServiceRules(configValue map[string]ProxyRuleCfg) map[string]ProxyRuleCfg {
+	rules := make(map[string]ProxyRuleCfg)
+	for service, rule := range configValue {
+		service = strings.ToLower(strings.TrimSpace(service))
+		rule.Proxy = strings.TrimSpace(rule.Proxy)
+		if strings.EqualFold(rule.Proxy, ProxyDirect) {
+			rule.Proxy = ProxyDirect
+		} else if rule.Proxy != "" {
+			proxy := rule.Proxy
+			if !strings.Contains(proxy, "://") {
+				proxy = "http://" + proxy
+			}
+			if proxyURL, err := url.Parse(proxy); err != nil || proxyURL.Host == "" {
+				log.Printf("ignoring proxy rule for %v with invalid proxy %q", service, rule.Proxy)
+				continue
+			}
+		}
+		var noProxy []string
+		for _, host := range rule.NoProxy {
+			if host = strings.ToLower(strings.TrimSpace(host)); host != "" {
+				noProxy = append(noProxy, host)
+			}
+		}
+		rule.NoProxy = noProxy
+		rules[service] = rule
+	}
+	return rules
+}
+
+// getLogSinks drops unknown and duplicate sinks and falls back to file logging if none remain
+func getLogSinks(configValue []string) []string {
+	var sinks []string
+	seen := make(map[string]bool)
+	for _, sink := range configValue {
+		sink = strings.ToLower(strings.TrimSpace(sink))
+		switch sink {
+		case LogSinkFile, LogSinkSyslog, LogSinkJournald:
+			if !seen[sink] {
+				seen[sink] = true
+				sinks = append(sinks, sink)
+			}
+		default:
+			log.Printf("ignoring unknown log sink %q", sink)
+		}
+	}
+	if len(sinks) == 0 {
+		return []string{LogSinkFile}
+	}
+	return sinks
+}
+
+// getSyslogIdentifier returns the trimmed identifier, or the agent name if it is empty or holds characters
+// that are not allowed in the seelog configuration
+func getSyslogIdentifier(configValue string) string {
+	identifier := strings.TrimSpace(configValue)
+	if identifier == "" {
+		return DefaultAgentName
+	}
+	if strings.ContainsAny(identifier, "\"'<>&") || strings.IndexFunc(identifier, unicode.IsControl) >= 0 {
+		log.Printf("ignoring syslog identifier %q with invalid characters", configValue)
+		return DefaultAgentName
+	}
+	return identifier
+}
+
+// getAttestationSinks drops unknown and duplicate attestation sinks, and the s3 sink when no bucket is configured.
+// It falls back to the file sink if none remain.
+func getAttestationSinks(configValue []string, s3BucketName string) []string {
+	var sinks []string
+	seen := make(map[string]bool)
+	for _, sink := range configValue {
+		sink = strings.ToLower(strings.TrimSpace(sink))
+		switch sink {
+		case AttestationSinkS3:
+			if strings.TrimSpace(s3BucketName) == "" {
+				log.Printf("ignoring attestation sink %q without a bucket", sink)
+				continue
+			}
+			fallthrough
+		case AttestationSinkFile:
+			if !seen[sink] {
+				seen[sink] = true
+				sinks = append(sinks, sink)
+			}
+		default:
+			log.Printf("ignoring unknown attestation sink %q", sink)
+		}
+	}
+	if len(sinks) == 0 {
+		return []string{AttestationSinkFile}
+	}
+	return sinks
+}
+
+// getAbsolutePaths drops the empty and relative paths and cleans the others
+func getAbsolutePaths(configValue []string) []string {
+	var paths []string
+	for _, path := range configValue {
+		path = strings.TrimSpace(path)
+		if path == "" {
+			continue
+		}
+		if !filepath.IsAbs(path) {
+			log.Printf("ignoring relative path %q", path)
+			continue
+		}
+		paths = append(paths, filepath.Clean(path))
+	}
+	return paths
+}
+
+// getAbsolutePath returns the cleaned path, relative paths are ignored
+func getAbsolutePath(configValue string) string {
+	if paths := getAbsolutePaths([]string{configValue}); len(paths) > 0 {
+		return paths[0]
+	}
+	return ""
+}
+
+// getScriptInspectionSeverity returns the severity of a script inspection rule, rules without one warn
+func getScriptInspectionSeverity(configValue string) string {
+	switch severity := strings.ToLower(strings.TrimSpace(configValue)); severity {
+	case ScriptInspectionSeverityBlock:
+		return severity
+	case "", ScriptInspectionSeverityWarn:
+		return ScriptInspectionSeverityWarn
+	default:
+		log.Printf("unknown script inspection severity %q, blocking", configValue)
+		return ScriptInspectionSeverityBlock
+	}
+}
+
+// getScriptInspectionRules drops the rules whose pattern is not a valid regular expression, rules are named after
+// their pattern when they have no name
+func getScriptInspectionRules(configValue []ScriptInspectionRuleCfg) []ScriptInspectionRuleCfg {
+	var rules []ScriptInspectionRuleCfg
+	for _, rule := range configValue {
+		if _, err := regexp.Compile(rule.Pattern); err != nil || rule.Pattern == "" {
+			log.Printf("ignoring script inspection rule %q with invalid pattern %q", rule.Name, rule.Pattern)
+			continue
+		}
+		rule.Name = strings.TrimSpace(rule.Name)
+		if rule.Name == "" {
+			rule.Name = rule.Pattern
+		}
+		rule.Severity = getScriptInspectionSeverity(rule.Severity)
+		rules = append(rules, rule)
+	}
+	return rules
+}
+
+// getHostAccess returns the host access mode in lower case, script plugins of unknown modes run in the container
+func getHostAccess(configValue string) string {
+	switch mode := strings.ToLower(strings.TrimSpace(configValue)); mode {
+	case HostAccessAuto, HostAccessNsenter, HostAccessChroot:
+		return mode
+	case "", HostAccessNone:
+		return HostAccessNone
+	default:
+		log.Printf("unknown host access %q, running scripts in the container", configValue)
+		return HostAccessNone
+	}
+}
+
+// getPerformanceProfile returns the performance profile in lower case, unknown profiles keep the runtime defaults
+func getPerformanceProfile(configValue string) string {
+	switch profile := strings.ToLower(strings.TrimSpace(configValue)); profile {
+	case PerformanceProfileLatency, PerformanceProfileFootprint:
+		return profile
+	case "", PerformanceProfileDefault:
+		return PerformanceProfileDefault
+	default:
+		log.Printf("unknown performance profile %q, keeping the runtime defaults", configValue)
+		return PerformanceProfileDefault
+	}
+}
+
+// getExportFormat returns the export format in lower case, unknown formats fall back to json
+func getExportFormat(configValue string) string {
+	switch format := strings.ToLower(strings.TrimSpace(configValue)); format {
+	case ExportFormatAsff:
+		return format
+	case "", ExportFormatJson:
+		return ExportFormatJson
+	default:
+		log.Printf("unknown export format %q, using %v", configValue, ExportFormatJson)
+		return ExportFormatJson
+	}
+}
+
+// getEventSubscribers drops the event subscribers without exactly one of a https webhook url, an absolute command,
+// a topic arn or an event bus, and the malformed event type patterns
+func getEventSubscribers(configValue []EventSubscriberCfg) []EventSubscriberCfg {
+	var subscribers []EventSubscriberCfg
+	for i, subscriber := range configValue {
+		subscriber.Name = strings.TrimSpace(subscriber.Name)
+		if subscriber.Name == "" {
+			subscriber.Name = fmt.Sprintf("subscriber%v", i)
+		}
+		subscriber.WebhookUrl = strings.TrimSpace(subscriber.WebhookUrl)
+		if subscriber.WebhookUrl != "" {
+			if parsedUrl, err := url.Parse(subscriber.WebhookUrl); err != nil || parsedUrl.Scheme != "https" || parsedUrl.Host == "" {
+				log.Printf("ignoring event subscriber %v, only https webhook urls are supported", subscriber.Name)
+				continue
+			}
+		}
+		if len(subscriber.Command) > 0 && !filepath.IsAbs(subscriber.Command[0]) {
+			log.Printf("ignoring event subscriber %v, the command must be an absolute path", subscriber.Name)
+			continue
+		}
+		subscriber.SnsTopicArn = strings.TrimSpace(subscriber.SnsTopicArn)
+		if subscriber.SnsTopicArn != "" && !strings.HasPrefix(subscriber.SnsTopicArn, "arn:") {
+			log.Printf("ignoring event subscriber %v, %v is not a topic arn", subscriber.Name, subscriber.SnsTopicArn)
+			continue
+		}
+		subscriber.EventBusName = strings.TrimSpace(subscriber.EventBusName)
+		destinations := 0
+		for _, destination := range []string{subscriber.WebhookUrl, strings.Join(subscriber.Command, " "), subscriber.SnsTopicArn, subscriber.EventBusName} {
+			if destination != "" {
+				destinations++
+			}
+		}
+		if destinations != 1 {
+			log.Printf("ignoring event subscriber %v, it needs one of a webhook url, a command, a topic arn or an event bus", subscriber.Name)
+			continue
+		}
+		subscriber.Types = getMatchPatterns(subscriber.Types)
+		subscriber.TimeoutSeconds = getNumericValue(
+			subscriber.TimeoutSeconds,
+			DefaultEventTimeoutSecondsMin,
+			DefaultEventTimeoutSecondsMax,
+			DefaultEventTimeoutSeconds)
+		subscriber.MaxAttempts = getNumericValue(
+			subscriber.MaxAttempts,
+			DefaultEventMaxAttemptsMin,
+			DefaultEventMaxAttemptsMax,
+			DefaultEventMaxAttempts)
+		subscribers = append(subscribers, subscriber)
+	}
+	return subscribers
+}
+
+// getPinnedDocuments drops the malformed hashes and the pins without a name pattern, or without a hash or a public key
+func getPinnedDocuments(configValue []PinnedDocumentCfg) []PinnedDocumentCfg {
+	var pins []PinnedDocumentCfg
+	for _, pin := range configValue {
+		pin.Name = strings.TrimSpace(pin.Name)
+		if patterns := getMatchPatterns([]string{pin.Name}); len(patterns) == 0 {
+			log.Printf("ignoring document pin without a valid name pattern")
+			continue
+		}
+		var hashes []string
+		for _, hash := range pin.Sha256 {
+			hash = strings.ToLower(strings.TrimSpace(hash))
+			if !sha256Pattern.MatchString(hash) {
+				log.Printf("ignoring hash %q of document pin %v, it is not a sha256 hex digest", hash, pin.Name)
+				continue
+			}
+			hashes = append(hashes, hash)
+		}
+		pin.Sha256 = hashes
+		pin.PublicKeys = getAbsolutePaths(pin.PublicKeys)
+		if len(pin.Sha256) == 0 && len(pin.PublicKeys) == 0 {
+			log.Printf("ignoring document pin %v, it needs a sha256 hash or a public key", pin.Name)
+			continue
+		}
+		pins = append(pins, pin)
+	}
+	return pins
+}
+
+// getMatchPatterns drops the empty and malformed path.Match patterns
+func getMatchPatterns(configValue []string) []string {
+	var patterns []string
+	for _, pattern := range configValue {
+		pattern = strings.TrimSpace(pattern)
+		if pattern == "" {
+			continue
+		}
+		if _, err := path.Match(pattern, ""); err != nil {
+			log.Printf("ignoring malformed pattern %q", pattern)
+			continue
+		}
+		patterns = append(patterns, pattern)
+	}
+	return patterns
+}
+
+// getAccountIds drops the values that are not account ids, and the wildcard unless allowWildcard is set
+func getAccountIds(configValue []string, allowWildcard bool) []string {
+	var accounts []string
+	for _, account := range configValue {
+		account = strings.TrimSpace(account)
+		if !accountIdPattern.MatchString(account) && !(allowWildcard && account == "*") {
+			log.Printf("ignoring account %q, it is not an account id", account)
+			continue
+		}
+		accounts = append(accounts, account)
+	}
+	return accounts
+}
+
+// getEc2MetadataEndpointMode returns the metadata endpoint mode in its canonical case, defaulting to IPv4
+func getEc2MetadataEndpointMode(configValue string) string {
+	switch strings.ToLower(strings.TrimSpace(configValue)) {
+	case "":
+		return Ec2MetadataEndpointModeIPv4
+	case strings.ToLower(Ec2MetadataEndpointModeIPv4):
+		return Ec2MetadataEndpointModeIPv4
+	case strings.ToLower(Ec2MetadataEndpointModeIPv6):
+		return Ec2MetadataEndpointModeIPv6
+	}
+	log.Printf("ignoring unknown metadata endpoint mode %q", configValue)
+	return Ec2MetadataEndpointModeIPv4
 }
 
 // getStringValue returns the default value if config is empty, else the config value
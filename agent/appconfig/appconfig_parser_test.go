@@ -16,6 +16,8 @@ package appconfig
 import (
 	"os"
 	"path/filepath"
+	"runtime"
+	"strings"
 	"testing"
 
 	"io/ioutil"
@@ -122,3 +124,584 @@ func TestGetNumeric64Value(t *testing.T) {
 		assert.Equal(t, test.Output, output)
 	}
 }
+
+// getLogSinks Tests
+
+type GetLogSinksTest struct {
+	Input  []string
+	Output []string
+}
+
+var (
+	getLogSinksTests = []GetLogSinksTest{
+		{nil, []string{LogSinkFile}},                                                      // empty
+		{[]string{"unknown"}, []string{LogSinkFile}},                                      // only unknown sinks
+		{[]string{"Journald"}, []string{LogSinkJournald}},                                 // case insensitive
+		{[]string{"file", "syslog", "file"}, []string{LogSinkFile, LogSinkSyslog}},        // duplicates removed
+		{[]string{"journald", "bogus", " file "}, []string{LogSinkJournald, LogSinkFile}}, // unknown dropped
+	}
+)
+
+func TestGetLogSinks(t *testing.T) {
+	for _, test := range getLogSinksTests {
+		output := getLogSinks(test.Input)
+		assert.Equal(t, test.Output, output)
+	}
+}
+
+func TestGetSyslogIdentifier(t *testing.T) {
+	assert.Equal(t, DefaultAgentName, getSyslogIdentifier(""))
+	assert.Equal(t, "ssm-agent", getSyslogIdentifier(" ssm-agent "))
+	for _, identifier := range []string{`ssm"/><custom name="x`, "ssm<agent", "ssm&amp;", "ssm'agent", "ssm\nagent"} {
+		assert.Equal(t, DefaultAgentName, getSyslogIdentifier(identifier), identifier)
+	}
+}
+
+func TestGetAttestationSinks(t *testing.T) {
+	assert.Equal(t, []string{AttestationSinkFile}, getAttestationSinks(nil, ""))
+	assert.Equal(t, []string{AttestationSinkFile}, getAttestationSinks([]string{"s3"}, ""))
+	assert.Equal(t, []string{AttestationSinkS3}, getAttestationSinks([]string{" S3 ", "s3"}, "bucket"))
+	assert.Equal(t, []string{AttestationSinkFile, AttestationSinkS3}, getAttestationSinks([]string{"file", "bogus", "s3"}, "bucket"))
+}
+
+func TestGetScriptInspectionSeverity(t *testing.T) {
+	assert.Equal(t, ScriptInspectionSeverityWarn, getScriptInspectionSeverity(""))
+	assert.Equal(t, ScriptInspectionSeverityWarn, getScriptInspectionSeverity(" Warn "))
+	assert.Equal(t, ScriptInspectionSeverityBlock, getScriptInspectionSeverity("BLOCK"))
+	assert.Equal(t, ScriptInspectionSeverityBlock, getScriptInspectionSeverity("critical"))
+}
+
+func TestGetScriptInspectionRules(t *testing.T) {
+	assert.Nil(t, getScriptInspectionRules(nil))
+	assert.Equal(t, []ScriptInspectionRuleCfg{
+		{Name: "curl-pipe-shell", Pattern: `curl[^|]*\|\s*(ba)?sh`, Severity: ScriptInspectionSeverityBlock},
+		{Name: "AKIA[0-9A-Z]{16}", Pattern: "AKIA[0-9A-Z]{16}", Severity: ScriptInspectionSeverityWarn},
+	}, getScriptInspectionRules([]ScriptInspectionRuleCfg{
+		{Name: " curl-pipe-shell ", Pattern: `curl[^|]*\|\s*(ba)?sh`, Severity: "block"},
+		{Name: "invalid", Pattern: "(", Severity: "block"},
+		{Name: "empty"},
+		{Pattern: "AKIA[0-9A-Z]{16}"},
+	}))
+}
+
+func TestGetAbsolutePaths(t *testing.T) {
+	root := filepath.Clean(os.TempDir())
+	assert.Nil(t, getAbsolutePaths(nil))
+	assert.Nil(t, getAbsolutePaths([]string{"", " relative "}))
+	assert.Equal(t, []string{root}, getAbsolutePaths([]string{" " + root + string(filepath.Separator) + " ", "relative"}))
+}
+
+func TestGetHostAccess(t *testing.T) {
+	assert.Equal(t, HostAccessNone, getHostAccess(""))
+	assert.Equal(t, HostAccessNsenter, getHostAccess(" NSEnter "))
+	assert.Equal(t, HostAccessChroot, getHostAccess("chroot"))
+	assert.Equal(t, HostAccessAuto, getHostAccess("auto"))
+	assert.Equal(t, HostAccessNone, getHostAccess("ssh"))
+}
+
+func TestGetMatchPatterns(t *testing.T) {
+	assert.Nil(t, getMatchPatterns(nil))
+	assert.Equal(t, []string{"aws:run*", "Custom-?"}, getMatchPatterns([]string{" aws:run* ", "", "[", "Custom-?"}))
+}
+
+func TestGetAccountIds(t *testing.T) {
+	assert.Equal(t, []string{"123456789012"}, getAccountIds([]string{" 123456789012 ", "1234", "*"}, false))
+	assert.Equal(t, []string{"*", "123456789012"}, getAccountIds([]string{"*", "123456789012", "account"}, true))
+}
+
+func TestGetProxyServiceRules(t *testing.T) {
+	rules := getProxyServiceRules(map[string]ProxyRuleCfg{
+		" S3 ":        {Proxy: "http://s3proxy:3128", NoProxy: []string{" .Internal ", ""}},
+		"ssmmessages": {Proxy: "direct"},
+		"ssm":         {Proxy: "http://"},
+		"ec2messages": {NoProxy: []string{"*"}},
+		"kms":         {Proxy: "kmsproxy:8080"},
+	})
+
+	assert.Equal(t, map[string]ProxyRuleCfg{
+		"s3":          {Proxy: "http://s3proxy:3128", NoProxy: []string{".internal"}},
+		"ssmmessages": {Proxy: ProxyDirect},
+		"ec2messages": {NoProxy: []string{"*"}},
+		"kms":         {Proxy: "kmsproxy:8080"},
+	}, rules)
+}
+
+func TestGetEc2MetadataEndpointMode(t *testing.T) {
+	assert.Equal(t, Ec2MetadataEndpointModeIPv4, getEc2MetadataEndpointMode(""))
+	assert.Equal(t, Ec2MetadataEndpointModeIPv4, getEc2MetadataEndpointMode("ipv4"))
+	assert.Equal(t, Ec2MetadataEndpointModeIPv6, getEc2MetadataEndpointMode(" ipv6 "))
+	assert.Equal(t, Ec2MetadataEndpointModeIPv4, getEc2MetadataEndpointMode("dualstack"))
+}
+
+func TestGetTlsMinVersion(t *testing.T) {
+	assert.Equal(t, TlsVersion12, getTlsMinVersion(""))
+	assert.Equal(t, TlsVersion12, getTlsMinVersion("1.2"))
+	assert.Equal(t, TlsVersion13, getTlsMinVersion("TLSv1.3"))
+	assert.Equal(t, TlsVersion13, getTlsMinVersion(" tls1.3 "))
+	assert.Equal(t, TlsVersion12, getTlsMinVersion("1.0"))
+}
+
+func TestGetPrivateKeyStorage(t *testing.T) {
+	assert.Equal(t, PrivateKeyStorageFile, getPrivateKeyStorage(""))
+	assert.Equal(t, PrivateKeyStorageTpm, getPrivateKeyStorage(" TPM "))
+	assert.Equal(t, PrivateKeyStorageOs, getPrivateKeyStorage("os"))
+	assert.Equal(t, PrivateKeyStorageAuto, getPrivateKeyStorage("Auto"))
+	assert.Equal(t, PrivateKeyStorageFile, getPrivateKeyStorage("hsm"))
+}
+
+func TestGetFailoverSecondaries(t *testing.T) {
+	secondaries := getFailoverSecondaries([]FailoverTargetCfg{
+		{Region: " us-west-2 ", Endpoints: map[string]string{"SSM": " ssm.example.com ", "s3": ""}},
+		{Region: ""},
+	})
+	assert.Equal(t, []FailoverTargetCfg{
+		{Region: "us-west-2", Endpoints: map[string]string{"ssm": "ssm.example.com"}},
+	}, secondaries)
+}
+
+func TestGetPluginPrivileges(t *testing.T) {
+	privileges := getPluginPrivileges(map[string]string{
+		"aws:runShellScript": " User ",
+		"Port":               "root",
+		"aws:psModule":       "admin",
+	})
+	assert.Equal(t, map[string]string{"aws:runShellScript": PrivilegeUser, "Port": PrivilegeRoot}, privileges)
+}
+
+func TestGetBucketEndpoints(t *testing.T) {
+	bucketEndpoints := getBucketEndpoints(map[string]string{
+		"output-bucket": " https://bucket.vpce-0123456789abcdef0.s3.us-west-2.vpce.amazonaws.com ",
+		"other-bucket":  "",
+		" ":             "s3.us-east-1.amazonaws.com",
+	})
+	assert.Equal(t, map[string]string{
+		"output-bucket": "https://bucket.vpce-0123456789abcdef0.s3.us-west-2.vpce.amazonaws.com",
+	}, bucketEndpoints)
+}
+
+func TestGetWebhookUrl(t *testing.T) {
+	assert.Equal(t, "https://hooks.example.com/ssm/output", getWebhookUrl(" https://hooks.example.com/ssm/output "))
+	assert.Equal(t, "", getWebhookUrl("http://hooks.example.com/ssm/output"))
+	assert.Equal(t, "", getWebhookUrl("https://"))
+	assert.Equal(t, "", getWebhookUrl(""))
+}
+
+func TestGetTruncationStrategy(t *testing.T) {
+	assert.Equal(t, OutputTruncationTail, getTruncationStrategy(" Tail "))
+	assert.Equal(t, OutputTruncationHeadTail, getTruncationStrategy("head+tail"))
+	assert.Equal(t, OutputTruncationHead, getTruncationStrategy(""))
+	assert.Equal(t, OutputTruncationHead, getTruncationStrategy("middle"))
+}
+
+func TestParserWorkerLimits(t *testing.T) {
+	config := DefaultConfig()
+	config.Mds.CommandWorkersLimit = 0
+	config.Mds.CancelWorkersLimit = 0
+	config.Mds.QueuedJobsPerWorker = 5000
+	config.Mds.CpuBoundWorkersLimit = -1
+	config.Mds.IoBoundWorkersLimit = 0
+
+	parser(&config)
+
+	assert.Equal(t, autoCommandWorkersLimit(), config.Mds.CommandWorkersLimit)
+	assert.True(t, config.Mds.CommandWorkersLimit >= DefaultCommandWorkersLimitMin && config.Mds.CommandWorkersLimit <= DefaultCommandWorkersLimitMax)
+	assert.Equal(t, DefaultCancelWorkersLimit, config.Mds.CancelWorkersLimit)
+	assert.Equal(t, DefaultQueuedJobsPerWorker, config.Mds.QueuedJobsPerWorker)
+	assert.Equal(t, runtime.NumCPU(), config.Mds.CpuBoundWorkersLimit)
+	assert.Equal(t, IoBoundWorkersPerCPU*runtime.NumCPU(), config.Mds.IoBoundWorkersLimit)
+
+	config.Mds.CommandWorkersLimit = 100
+	parser(&config)
+	assert.Equal(t, 100, config.Mds.CommandWorkersLimit)
+}
+
+func TestParserOfflineLimits(t *testing.T) {
+	config := DefaultConfig()
+	config.Offline.MaxQueueSizeMB = 0
+	config.Offline.MaxEntryAgeHours = 1000
+	config.Offline.FlushIntervalSeconds = 30
+
+	parser(&config)
+
+	assert.Equal(t, DefaultOfflineMaxQueueSizeMB, config.Offline.MaxQueueSizeMB)
+	assert.Equal(t, DefaultOfflineMaxEntryAgeHours, config.Offline.MaxEntryAgeHours)
+	assert.Equal(t, 30, config.Offline.FlushIntervalSeconds)
+}
+
+func TestParserLowMemory(t *testing.T) {
+	config := DefaultConfig()
+	config.Mds.CommandWorkersLimit = 16
+	config.LowMemory.MemoryLimitMB = 8
+	config.LowMemory.SessionBufferCapacity = 500
+	config.LowMemory.Gatherers = []string{" AWS:Network ", "", "AWS:["}
+
+	parser(&config)
+
+	assert.Equal(t, 16, config.Mds.CommandWorkersLimit)
+	assert.Equal(t, DefaultLowMemoryLimitMB, config.LowMemory.MemoryLimitMB)
+	assert.Equal(t, 500, config.LowMemory.SessionBufferCapacity)
+	assert.Equal(t, []string{"AWS:Network"}, config.LowMemory.Gatherers)
+
+	config.LowMemory.Enabled = true
+	parser(&config)
+	assert.Equal(t, DefaultLowMemoryCommandWorkersLimit, config.Mds.CommandWorkersLimit)
+}
+
+func TestParserShutdown(t *testing.T) {
+	config := DefaultConfig()
+	assert.Equal(t, DefaultShutdownDeadlineSeconds, config.Shutdown.DeadlineSeconds)
+
+	config.Shutdown.DeadlineSeconds = 1
+	parser(&config)
+	assert.Equal(t, DefaultShutdownDeadlineSeconds, config.Shutdown.DeadlineSeconds)
+
+	config.Shutdown.DeadlineSeconds = 120
+	parser(&config)
+	assert.Equal(t, 120, config.Shutdown.DeadlineSeconds)
+}
+
+func TestParserClockSkew(t *testing.T) {
+	config := DefaultConfig()
+	assert.Equal(t, DefaultClockSkewThresholdSeconds, config.ClockSkew.ThresholdSeconds)
+
+	config.ClockSkew.ThresholdSeconds = 7200
+	parser(&config)
+	assert.Equal(t, DefaultClockSkewThresholdSeconds, config.ClockSkew.ThresholdSeconds)
+
+	config.ClockSkew.ThresholdSeconds = 30
+	parser(&config)
+	assert.Equal(t, 30, config.ClockSkew.ThresholdSeconds)
+}
+
+func TestParserImds(t *testing.T) {
+	config := DefaultConfig()
+	config.Imds.IdentityCertificatePath = " /etc/amazon/ssm/ec2-identity.pem "
+
+	parser(&config)
+
+	assert.Equal(t, "/etc/amazon/ssm/ec2-identity.pem", config.Imds.IdentityCertificatePath)
+}
+
+func TestParserNetwork(t *testing.T) {
+	config := DefaultConfig()
+	config.Network.SourceAddress = " 10.0.1.15 "
+	config.Network.SourceInterface = " eth1 "
+	config.Network.ConnectionAttemptDelayMilliseconds = 5000
+	config.Network.DnsCacheSeconds = 0
+	config.Network.MaxIdleConnectionsPerHost = 0
+
+	parser(&config)
+
+	assert.Equal(t, "10.0.1.15", config.Network.SourceAddress)
+	assert.Equal(t, "eth1", config.Network.SourceInterface)
+	assert.Equal(t, DefaultNetworkConnectionAttemptDelayMilliseconds, config.Network.ConnectionAttemptDelayMilliseconds)
+	assert.Equal(t, 0, config.Network.DnsCacheSeconds)
+	assert.Equal(t, DefaultNetworkMaxIdleConnectionsPerHost, config.Network.MaxIdleConnectionsPerHost)
+
+	config.Network.SourceAddress = "mgmt0"
+	parser(&config)
+	assert.Equal(t, "", config.Network.SourceAddress)
+}
+
+func TestParserStateStore(t *testing.T) {
+	config := DefaultConfig()
+	assert.Equal(t, DefaultStateStoreMaxSizeMB, config.StateStore.MaxSizeMB)
+
+	config.StateStore.MaxSizeMB = 0
+	parser(&config)
+	assert.Equal(t, DefaultStateStoreMaxSizeMB, config.StateStore.MaxSizeMB)
+
+	config.StateStore.MaxSizeMB = 256
+	parser(&config)
+	assert.Equal(t, 256, config.StateStore.MaxSizeMB)
+}
+
+func TestParserInventory(t *testing.T) {
+	config := DefaultConfig()
+	assert.Equal(t, DefaultInventoryGathererTimeoutSeconds, config.Inventory.GathererTimeoutSeconds)
+
+	config.Inventory.GathererTimeoutSeconds = 5
+	parser(&config)
+	assert.Equal(t, DefaultInventoryGathererTimeoutSeconds, config.Inventory.GathererTimeoutSeconds)
+
+	config.Inventory.GathererTimeoutSeconds = 600
+	parser(&config)
+	assert.Equal(t, 600, config.Inventory.GathererTimeoutSeconds)
+}
+
+func TestParserWorkerPool(t *testing.T) {
+	config := DefaultConfig()
+	assert.False(t, config.WorkerPool.Enabled)
+	assert.Equal(t, DefaultWorkerPoolSize, config.WorkerPool.Size)
+
+	config.WorkerPool.Size = 0
+	config.WorkerPool.MaxDocumentsPerWorker = 5000
+	config.WorkerPool.HealthCheckIntervalSeconds = 1
+	parser(&config)
+	assert.Equal(t, DefaultWorkerPoolSize, config.WorkerPool.Size)
+	assert.Equal(t, DefaultWorkerPoolMaxDocumentsPerWorker, config.WorkerPool.MaxDocumentsPerWorker)
+	assert.Equal(t, DefaultWorkerPoolHealthCheckIntervalSeconds, config.WorkerPool.HealthCheckIntervalSeconds)
+
+	config.WorkerPool.Size = 4
+	config.WorkerPool.MaxDocumentsPerWorker = 10
+	config.WorkerPool.HealthCheckIntervalSeconds = 30
+	parser(&config)
+	assert.Equal(t, 4, config.WorkerPool.Size)
+	assert.Equal(t, 10, config.WorkerPool.MaxDocumentsPerWorker)
+	assert.Equal(t, 30, config.WorkerPool.HealthCheckIntervalSeconds)
+}
+
+func TestParserPerformance(t *testing.T) {
+	config := DefaultConfig()
+	assert.Equal(t, PerformanceProfileDefault, config.Performance.Profile)
+
+	config.Performance.Profile = " Latency "
+	parser(&config)
+	assert.Equal(t, PerformanceProfileLatency, config.Performance.Profile)
+
+	config.Performance.Profile = "throughput"
+	parser(&config)
+	assert.Equal(t, PerformanceProfileDefault, config.Performance.Profile)
+}
+
+func TestParserCompression(t *testing.T) {
+	config := DefaultConfig()
+	assert.False(t, config.Compression.Enabled)
+	assert.Equal(t, DefaultCompressionThresholdBytes, config.Compression.ThresholdBytes)
+
+	config.Compression.ThresholdBytes = 10
+	parser(&config)
+	assert.Equal(t, DefaultCompressionThresholdBytes, config.Compression.ThresholdBytes)
+
+	config.Compression.ThresholdBytes = 65536
+	parser(&config)
+	assert.Equal(t, 65536, config.Compression.ThresholdBytes)
+}
+
+func TestParserReboot(t *testing.T) {
+	config := DefaultConfig()
+	assert.Equal(t, DefaultRebootCoalesceSeconds, config.Reboot.CoalesceSeconds)
+
+	config.Reboot.CoalesceSeconds = 0
+	config.Reboot.MaintenanceWindows = []PollWindowCfg{{Days: []string{" Sat "}, Start: "02:00", End: "04:00"}, {Start: "2am", End: "04:00"}}
+	config.Reboot.BlackoutWindows = []PollWindowCfg{{Days: []string{"someday"}, Start: "00:00", End: "23:59"}}
+	parser(&config)
+	assert.Equal(t, 0, config.Reboot.CoalesceSeconds, "reboot requests are not coalesced")
+	assert.Equal(t, []PollWindowCfg{{Days: []string{"sat"}, Start: "02:00", End: "04:00"}}, config.Reboot.MaintenanceWindows)
+	assert.Empty(t, config.Reboot.BlackoutWindows)
+
+	config.Reboot.CoalesceSeconds = 7200
+	parser(&config)
+	assert.Equal(t, DefaultRebootCoalesceSeconds, config.Reboot.CoalesceSeconds)
+}
+
+func TestParserExport(t *testing.T) {
+	config := DefaultConfig()
+	assert.False(t, config.Export.Enabled)
+	assert.Equal(t, ExportFormatJson, config.Export.Format)
+	assert.Equal(t, DefaultExportMaxFileSizeMB, config.Export.MaxFileSizeMB)
+
+	config.Export.Format = " ASFF "
+	config.Export.Socket = " /run/siem.sock "
+	config.Export.MaxFileSizeMB = 0
+	config.Export.AccountId = " 123456789012 "
+	parser(&config)
+	assert.Equal(t, ExportFormatAsff, config.Export.Format)
+	assert.Equal(t, "/run/siem.sock", config.Export.Socket)
+	assert.Equal(t, DefaultExportMaxFileSizeMB, config.Export.MaxFileSizeMB)
+	assert.Equal(t, "123456789012", config.Export.AccountId)
+
+	config.Export.Format = "oval"
+	config.Export.AccountId = "my-account"
+	parser(&config)
+	assert.Equal(t, ExportFormatJson, config.Export.Format)
+	assert.Empty(t, config.Export.AccountId)
+}
+
+func TestParserIsolation(t *testing.T) {
+	config := DefaultConfig()
+	assert.False(t, config.Isolation.PrivateTmp)
+	assert.Empty(t, config.Isolation.Chroot)
+
+	config.Isolation.Chroot = " /srv/jail/ "
+	parser(&config)
+	assert.Equal(t, "/srv/jail", config.Isolation.Chroot)
+
+	config.Isolation.Chroot = "jail"
+	parser(&config)
+	assert.Empty(t, config.Isolation.Chroot)
+}
+
+func TestParserServiceDocuments(t *testing.T) {
+	config := DefaultConfig()
+	assert.False(t, config.ServiceDocuments.CacheEnabled)
+	assert.Equal(t, DefaultDocumentCacheMaxEntries, config.ServiceDocuments.CacheMaxEntries)
+
+	hash := strings.Repeat("ab", 32)
+	config.ServiceDocuments.CacheMaxEntries = DefaultDocumentCacheMaxEntriesMax + 1
+	config.ServiceDocuments.Pinned = []PinnedDocumentCfg{
+		{Name: " arn:aws:ssm:*:123456789012:document/Shared* ", Sha256: []string{" " + strings.ToUpper(hash) + " ", "md5"}},
+		{Name: "Signed", PublicKeys: []string{"/etc/amazon/ssm/signer.pem", "signer.pem"}},
+		{Name: "", Sha256: []string{hash}},
+		{Name: "[", Sha256: []string{hash}},
+		{Name: "Unpinned", Sha256: []string{"md5"}},
+	}
+	parser(&config)
+	assert.Equal(t, DefaultDocumentCacheMaxEntries, config.ServiceDocuments.CacheMaxEntries)
+	assert.Equal(t, []PinnedDocumentCfg{
+		{Name: "arn:aws:ssm:*:123456789012:document/Shared*", Sha256: []string{hash}},
+		{Name: "Signed", PublicKeys: []string{"/etc/amazon/ssm/signer.pem"}},
+	}, config.ServiceDocuments.Pinned)
+}
+
+func TestParserInstanceTags(t *testing.T) {
+	config := DefaultConfig()
+	assert.Equal(t, DefaultInstanceTagsRefreshIntervalSeconds, config.InstanceTags.RefreshIntervalSeconds)
+
+	config.InstanceTags.RefreshIntervalSeconds = 60
+	parser(&config)
+	assert.Equal(t, 60, config.InstanceTags.RefreshIntervalSeconds)
+
+	config.InstanceTags.RefreshIntervalSeconds = DefaultInstanceTagsRefreshIntervalSecondsMin - 1
+	parser(&config)
+	assert.Equal(t, DefaultInstanceTagsRefreshIntervalSeconds, config.InstanceTags.RefreshIntervalSeconds)
+}
+
+func TestParserWatchdog(t *testing.T) {
+	config := DefaultConfig()
+	assert.True(t, config.Watchdog.Enabled)
+	assert.Equal(t, DefaultWatchdogStallTimeoutSeconds, config.Watchdog.StallTimeoutSeconds)
+
+	config.Watchdog.StallTimeoutSeconds = 300
+	parser(&config)
+	assert.Equal(t, 300, config.Watchdog.StallTimeoutSeconds)
+
+	config.Watchdog.StallTimeoutSeconds = DefaultWatchdogStallTimeoutSecondsMax + 1
+	parser(&config)
+	assert.Equal(t, DefaultWatchdogStallTimeoutSeconds, config.Watchdog.StallTimeoutSeconds)
+}
+
+func TestParserEvents(t *testing.T) {
+	config := DefaultConfig()
+	config.Events.Subscribers = []EventSubscriberCfg{
+		{Name: " pager ", Types: []string{" session.* ", "[", ""}, WebhookUrl: " https://example.com/events ", TimeoutSeconds: 600},
+		{Command: []string{"/usr/local/bin/on-event", "--verbose"}, MaxAttempts: 20},
+		{Name: "insecure", WebhookUrl: "http://example.com/events"},
+		{Name: "relative", Command: []string{"on-event"}},
+		{Name: "both", WebhookUrl: "https://example.com/events", Command: []string{"/usr/local/bin/on-event"}},
+		{Name: "none"},
+		{Name: "topic", SnsTopicArn: "my-topic"},
+		{Name: "bus", EventBusName: " ops ", Types: []string{"step.*"}},
+		{Name: "topic and bus", SnsTopicArn: "arn:aws:sns:us-east-1:123456789012:ops", EventBusName: "ops"},
+	}
+	parser(&config)
+	assert.Equal(t, []EventSubscriberCfg{
+		{
+			Name:           "pager",
+			Types:          []string{"session.*"},
+			WebhookUrl:     "https://example.com/events",
+			TimeoutSeconds: DefaultEventTimeoutSeconds,
+			MaxAttempts:    DefaultEventMaxAttempts,
+		},
+		{
+			Name:           "subscriber1",
+			Command:        []string{"/usr/local/bin/on-event", "--verbose"},
+			TimeoutSeconds: DefaultEventTimeoutSeconds,
+			MaxAttempts:    DefaultEventMaxAttempts,
+		},
+		{
+			Name:           "bus",
+			Types:          []string{"step.*"},
+			EventBusName:   "ops",
+			TimeoutSeconds: DefaultEventTimeoutSeconds,
+			MaxAttempts:    DefaultEventMaxAttempts,
+		},
+	}, config.Events.Subscribers)
+}
+
+func TestParserExternalPlugins(t *testing.T) {
+	config := DefaultConfig()
+	config.ExternalPlugins = []ExternalPluginCfg{
+		{Name: " custom:runTerraform ", Path: " /opt/plugins/terraform ", TimeoutSeconds: 0},
+		{Name: "custom:lint", Path: "/opt/plugins/lint", TimeoutSeconds: 60},
+	}
+
+	parser(&config)
+
+	assert.Equal(t, "custom:runTerraform", config.ExternalPlugins[0].Name)
+	assert.Equal(t, "/opt/plugins/terraform", config.ExternalPlugins[0].Path)
+	assert.Equal(t, DefaultExternalPluginTimeoutSeconds, config.ExternalPlugins[0].TimeoutSeconds)
+	assert.Equal(t, 60, config.ExternalPlugins[1].TimeoutSeconds)
+}
+
+func TestGetS3ServerSideEncryption(t *testing.T) {
+	algorithm, kmsKeyId := getS3ServerSideEncryption("", " alias/output ")
+	assert.Equal(t, S3ServerSideEncryptionKms, algorithm)
+	assert.Equal(t, "alias/output", kmsKeyId)
+
+	algorithm, kmsKeyId = getS3ServerSideEncryption(S3ServerSideEncryptionAes256, "alias/output")
+	assert.Equal(t, S3ServerSideEncryptionAes256, algorithm)
+	assert.Equal(t, "", kmsKeyId)
+
+	algorithm, kmsKeyId = getS3ServerSideEncryption("des", "")
+	assert.Equal(t, "", algorithm)
+	assert.Equal(t, "", kmsKeyId)
+}
+
+func TestGetStateEncryption(t *testing.T) {
+	mode, kmsKeyId := getStateEncryption("", "")
+	assert.Equal(t, StateEncryptionNone, mode)
+	assert.Equal(t, "", kmsKeyId)
+
+	mode, kmsKeyId = getStateEncryption("", " alias/state ")
+	assert.Equal(t, StateEncryptionKms, mode)
+	assert.Equal(t, "alias/state", kmsKeyId)
+
+	mode, kmsKeyId = getStateEncryption("KMS", "")
+	assert.Equal(t, StateEncryptionLocal, mode)
+	assert.Equal(t, "", kmsKeyId)
+
+	mode, kmsKeyId = getStateEncryption(" Local ", "alias/state")
+	assert.Equal(t, StateEncryptionLocal, mode)
+	assert.Equal(t, "", kmsKeyId)
+
+	mode, kmsKeyId = getStateEncryption("tpm", "")
+	assert.Equal(t, StateEncryptionNone, mode)
+	assert.Equal(t, "", kmsKeyId)
+}
+
+func TestGetS3ObjectSettings(t *testing.T) {
+	assert.Equal(t, "123456789012", getExpectedBucketOwner(" 123456789012 "))
+	assert.Equal(t, "", getExpectedBucketOwner("owner"))
+	assert.Equal(t, "private", getS3ObjectACL("private"))
+	assert.Equal(t, DefaultS3ObjectACL, getS3ObjectACL("everyone"))
+	assert.Equal(t, DefaultS3ObjectACL, getS3ObjectACL(""))
+}
+
+func TestGetSessionUserSettings(t *testing.T) {
+	assert.Equal(t, "session-admin", getSessionUserName(" session-admin "))
+	assert.Equal(t, DefaultRunAsUserName, getSessionUserName(""))
+	assert.Equal(t, DefaultRunAsUserName, getSessionUserName("bad user;rm"))
+	assert.Equal(t, "ALL=(ALL) /usr/bin/systemctl", getSudoRule(" ALL=(ALL) /usr/bin/systemctl "))
+	assert.Equal(t, DefaultSessionUserSudoRule, getSudoRule(""))
+	assert.Equal(t, DefaultSessionUserSudoRule, getSudoRule("ALL=(ALL) ALL\nroot ALL=(ALL) ALL"))
+}
+
+func TestGetCommandDelivery(t *testing.T) {
+	assert.Equal(t, CommandDeliveryPush, getCommandDelivery(" Push "))
+	assert.Equal(t, CommandDeliveryPoll, getCommandDelivery(""))
+	assert.Equal(t, CommandDeliveryPoll, getCommandDelivery("websocket"))
+}
+
+func TestGetPollWindows(t *testing.T) {
+	windows := getPollWindows([]PollWindowCfg{
+		{Days: []string{" Sat ", "sunday"}, Start: "22:00", End: " 04:00 "},
+		{Start: "9:00", End: "17:00"},
+		{Start: "25:00", End: "17:00"},
+		{Days: []string{"someday"}, Start: "09:00", End: "17:00"},
+	})
+	assert.Equal(t, []PollWindowCfg{
+		{Days: []string{"sat", "sunday"}, Start: "22:00", End: "04:00"},
+		{Start: "9:00", End: "17:00"},
+	}, windows)
+}
@@ -0,0 +1,111 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package appconfig manages the configuration of the agent.
+package appconfig
+
+import (
+	"log"
+	"reflect"
+	"sort"
+	"sync"
+)
+
+// ConfigChangeHandler is called with the previous and the new config after a reload changed the config
+type ConfigChangeHandler func(oldConfig SsmagentConfig, newConfig SsmagentConfig)
+
+var (
+	reloadLock     sync.Mutex
+	handlersLock   sync.RWMutex
+	changeHandlers = make(map[string]ConfigChangeHandler)
+)
+
+// RegisterConfigChangeHandler registers a handler that is notified when a reload changes the config.
+// Registering a handler under an existing name replaces the previous handler.
+func RegisterConfigChangeHandler(name string, handler ConfigChangeHandler) {
+	handlersLock.Lock()
+	defer handlersLock.Unlock()
+	changeHandlers[name] = handler
+}
+
+// UnregisterConfigChangeHandler removes the handler registered under name
+func UnregisterConfigChangeHandler(name string) {
+	handlersLock.Lock()
+	defer handlersLock.Unlock()
+	delete(changeHandlers, name)
+}
+
+// Reload re-reads the config file and applies the settings that are safe to change while the agent runs:
+// worker limits and logging. Other settings keep their current value until the agent restarts,
+// the service endpoints among them since the clients of the services are created once at start.
+func Reload() (SsmagentConfig, error) {
+	reloadLock.Lock()
+	defer reloadLock.Unlock()
+
+	currentConfig, _ := Config(false)
+	fileConfig, _, err := load()
+	if err != nil {
+		log.Printf("Config reload failed, keeping current config: %v", err)
+		return currentConfig, err
+	}
+
+	newConfig := applyReloadableSettings(currentConfig, fileConfig)
+	if !reflect.DeepEqual(newConfig, fileConfig) {
+		log.Printf("Some of the changed config settings only take effect after the agent is restarted")
+	}
+	if reflect.DeepEqual(currentConfig, newConfig) {
+		log.Printf("Config reloaded, no reloadable setting changed")
+		return currentConfig, nil
+	}
+
+	cache(newConfig)
+	notifyConfigChange(currentConfig, newConfig)
+	return newConfig, nil
+}
+
+// applyReloadableSettings returns the current config updated with the reloadable settings of the new config
+func applyReloadableSettings(currentConfig SsmagentConfig, newConfig SsmagentConfig) SsmagentConfig {
+	config := currentConfig
+
+	config.Mds.CommandWorkersLimit = newConfig.Mds.CommandWorkersLimit
+	config.Mgs.SessionWorkersLimit = newConfig.Mgs.SessionWorkersLimit
+	config.Log = newConfig.Log
+
+	return config
+}
+
+// notifyConfigChange calls the registered handlers in name order so notifications are deterministic
+func notifyConfigChange(oldConfig SsmagentConfig, newConfig SsmagentConfig) {
+	handlersLock.RLock()
+	names := make([]string, 0, len(changeHandlers))
+	for name := range changeHandlers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	handlers := make([]ConfigChangeHandler, 0, len(names))
+	for _, name := range names {
+		handlers = append(handlers, changeHandlers[name])
+	}
+	handlersLock.RUnlock()
+
+	for i, handler := range handlers {
+		func() {
+			defer func() {
+				if msg := recover(); msg != nil {
+					log.Printf("Config change handler %v panicked: %v", names[i], msg)
+				}
+			}()
+			handler(oldConfig, newConfig)
+		}()
+	}
+}
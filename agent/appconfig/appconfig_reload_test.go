@@ -0,0 +1,102 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package appconfig
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApplyReloadableSettings(t *testing.T) {
+	currentConfig := DefaultConfig()
+	newConfig := DefaultConfig()
+	newConfig.Mds.Endpoint = "mds.example.com"
+	newConfig.Mds.CommandWorkersLimit = 10
+	newConfig.Mgs.SessionWorkersLimit = 20
+	newConfig.Log.Sinks = []string{LogSinkJournald}
+	newConfig.Agent.OrchestrationRootDir = "other"
+
+	config := applyReloadableSettings(currentConfig, newConfig)
+
+	assert.Equal(t, currentConfig.Mds.Endpoint, config.Mds.Endpoint, "endpoints need a restart")
+	assert.Equal(t, 10, config.Mds.CommandWorkersLimit)
+	assert.Equal(t, 20, config.Mgs.SessionWorkersLimit)
+	assert.Equal(t, []string{LogSinkJournald}, config.Log.Sinks)
+	assert.Equal(t, currentConfig.Agent.OrchestrationRootDir, config.Agent.OrchestrationRootDir)
+}
+
+func TestReload_NotifiesHandlers(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "appconfig")
+	defer os.RemoveAll(dir)
+	configPath := filepath.Join(dir, AppConfigFileName)
+
+	originalFunc := retrieveAppConfigPath
+	retrieveAppConfigPath = func() (string, error) {
+		return configPath, nil
+	}
+	defer func() { retrieveAppConfigPath = originalFunc }()
+
+	assert.NoError(t, ioutil.WriteFile(configPath, []byte(`{"Mds": {"CommandWorkersLimit": 5}}`), ReadWriteAccess))
+	_, err := Config(true)
+	assert.NoError(t, err)
+
+	var notifiedOld, notifiedNew SsmagentConfig
+	calls := 0
+	RegisterConfigChangeHandler("test", func(oldConfig, newConfig SsmagentConfig) {
+		notifiedOld, notifiedNew = oldConfig, newConfig
+		calls++
+	})
+	defer UnregisterConfigChangeHandler("test")
+
+	assert.NoError(t, ioutil.WriteFile(configPath, []byte(`{"Mds": {"CommandWorkersLimit": 8}, "Agent": {"OrchestrationRootDir": "other"}}`), ReadWriteAccess))
+	config, err := Reload()
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1, calls)
+	assert.Equal(t, 5, notifiedOld.Mds.CommandWorkersLimit)
+	assert.Equal(t, 8, notifiedNew.Mds.CommandWorkersLimit)
+	assert.Equal(t, 8, config.Mds.CommandWorkersLimit)
+	assert.Equal(t, defaultOrchestrationRootDirName, config.Agent.OrchestrationRootDir)
+
+	// reloading an unchanged file does not notify
+	_, err = Reload()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, calls)
+}
+
+func TestReload_InvalidFileKeepsConfig(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "appconfig")
+	defer os.RemoveAll(dir)
+	configPath := filepath.Join(dir, AppConfigFileName)
+
+	originalFunc := retrieveAppConfigPath
+	retrieveAppConfigPath = func() (string, error) {
+		return configPath, nil
+	}
+	defer func() { retrieveAppConfigPath = originalFunc }()
+
+	assert.NoError(t, ioutil.WriteFile(configPath, []byte(`{"Mds": {"CommandWorkersLimit": 7}}`), ReadWriteAccess))
+	_, err := Config(true)
+	assert.NoError(t, err)
+
+	assert.NoError(t, ioutil.WriteFile(configPath, []byte(`{"Mds": `), ReadWriteAccess))
+	config, err := Reload()
+
+	assert.Error(t, err)
+	assert.Equal(t, 7, config.Mds.CommandWorkersLimit)
+}
@@ -0,0 +1,105 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package appconfig manages the configuration of the agent.
+package appconfig
+
+import (
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// configReloadDelay coalesces the burst of file events editors produce when saving the config
+const configReloadDelay = 2 * time.Second
+
+var watcherOnce sync.Once
+
+// StartConfigWatcher reloads the config whenever the config file changes or the agent receives the reload signal (SIGHUP on unix).
+// The watcher is started once per process, later calls do nothing. The core agent and the worker each run one and
+// reload their own config, the handlers registered in a process only see the reloads of that process.
+func StartConfigWatcher() {
+	watcherOnce.Do(func() {
+		reloadRequests := make(chan struct{}, 1)
+		requestReload := func() {
+			select {
+			case reloadRequests <- struct{}{}:
+			default:
+			}
+		}
+
+		watchConfigFile(requestReload)
+		watchReloadSignal(requestReload)
+
+		go func() {
+			for range reloadRequests {
+				time.Sleep(configReloadDelay)
+				Reload()
+			}
+		}()
+	})
+}
+
+// watchConfigFile watches the config folder since the config file may not exist yet, or may be replaced by a rename
+func watchConfigFile(requestReload func()) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		log.Printf("Failed to create config file watcher, config changes require a restart: %v", err)
+		return
+	}
+	if err = watcher.Add(filepath.Dir(AppConfigPath)); err != nil {
+		log.Printf("Failed to watch config folder, config changes require a restart: %v", err)
+		watcher.Close()
+		return
+	}
+
+	go func() {
+		for {
+			select {
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if filepath.Clean(event.Name) == filepath.Clean(AppConfigPath) &&
+					event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Rename|fsnotify.Remove) != 0 {
+					requestReload()
+				}
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				log.Printf("Config file watcher error: %v", err)
+			}
+		}
+	}()
+}
+
+// watchReloadSignal requests a reload when one of the platform reload signals is received
+func watchReloadSignal(requestReload func()) {
+	if len(reloadSignals) == 0 {
+		return
+	}
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, reloadSignals...)
+	go func() {
+		for range signals {
+			log.Printf("Received reload signal")
+			requestReload()
+		}
+	}()
+}
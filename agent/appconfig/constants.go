@@ -14,7 +14,10 @@
 // Package appconfig manages the configuration of the agent.
 package appconfig
 
-import "os"
+import (
+	"os"
+	"time"
+)
 
 const (
 	// Agent defaults
@@ -23,13 +26,98 @@ const (
 
 	DefaultTelemetryNamespace = "amazon-ssm-agent-telemetry"
 
-	DefaultCommandWorkersLimit    = 5
-	DefaultCommandWorkersLimitMin = 1
+	// a CommandWorkersLimit of 0 sizes the pool from the number of CPUs
+	CommandWorkersPerCPU          = 2
+	DefaultCommandWorkersLimitMin = 2
+	DefaultCommandWorkersLimitMax = 64
+
+	DefaultCancelWorkersLimit    = 3
+	DefaultCancelWorkersLimitMin = 1
+	DefaultCancelWorkersLimitMax = 100
+
+	DefaultQueuedJobsPerWorker    = 10
+	DefaultQueuedJobsPerWorkerMin = 1
+	DefaultQueuedJobsPerWorkerMax = 1000
+
+	// a CpuBoundWorkersLimit of 0 runs as many cpu bound documents as there are CPUs,
+	// an IoBoundWorkersLimit of 0 runs IoBoundWorkersPerCPU io bound documents per CPU
+	IoBoundWorkersPerCPU = 4
 
 	DefaultCommandRetryLimit    = 15
 	DefaultCommandRetryLimitMin = 1
 	DefaultCommandRetryLimitMax = 100
 
+	DefaultMaxReregistrationAttempts    = 5
+	DefaultMaxReregistrationAttemptsMin = 1
+	DefaultMaxReregistrationAttemptsMax = 50
+
+	DefaultFingerprintSimilarityThreshold    = 40
+	DefaultFingerprintSimilarityThresholdMin = 1
+	DefaultFingerprintSimilarityThresholdMax = 100
+
+	DefaultS3UploadPartSizeMB    = 8
+	DefaultS3UploadPartSizeMBMin = 5
+	DefaultS3UploadPartSizeMBMax = 512
+
+	// DefaultS3ObjectACL gives the bucket owner full control of the objects the agent writes
+	DefaultS3ObjectACL = "bucket-owner-full-control"
+
+	DefaultOutputWebhookTimeoutSeconds    = 30
+	DefaultOutputWebhookTimeoutSecondsMin = 1
+	DefaultOutputWebhookTimeoutSecondsMax = 300
+
+	// Strategies to truncate output that does not fit in the reply
+	OutputTruncationHead     = "head"
+	OutputTruncationTail     = "tail"
+	OutputTruncationHeadTail = "head+tail"
+
+	DefaultArtifactCacheMaxSizeMB    = 2048
+	DefaultArtifactCacheMaxSizeMBMin = 1
+	DefaultArtifactCacheMaxSizeMBMax = 1048576
+
+	DefaultSessionUserInactiveDaysMax = 3650
+
+	DefaultOfflineMaxQueueSizeMB          = 100
+	DefaultOfflineMaxQueueSizeMBMin       = 1
+	DefaultOfflineMaxQueueSizeMBMax       = 10240
+	DefaultOfflineMaxEntryAgeHours        = 72
+	DefaultOfflineMaxEntryAgeHoursMin     = 1
+	DefaultOfflineMaxEntryAgeHoursMax     = 720
+	DefaultOfflineFlushIntervalSeconds    = 60
+	DefaultOfflineFlushIntervalSecondsMin = 10
+	DefaultOfflineFlushIntervalSecondsMax = 3600
+
+	DefaultExternalPluginTimeoutSeconds    = 3600
+	DefaultExternalPluginTimeoutSecondsMin = 1
+	DefaultExternalPluginTimeoutSecondsMax = 172800
+
+	// DefaultDocumentMaxReboots is how many times a document can reboot the instance when it sets no maxReboots
+	DefaultDocumentMaxReboots = 10
+
+	// BootstrapDocumentFolderName is the folder of the data store with the result and output of the bootstrap document
+	BootstrapDocumentFolderName = "bootstrap"
+
+	// the MDS poll interval is capped below the 15 minutes at which the poll job restarts anyway
+	DefaultPollMaxIntervalSeconds    = 300
+	DefaultPollMaxIntervalSecondsMin = 5
+	DefaultPollMaxIntervalSecondsMax = 900
+
+	DefaultMessageMaxClockSkewSeconds    = 300
+	DefaultMessageMaxClockSkewSecondsMin = 30
+	DefaultMessageMaxClockSkewSecondsMax = 3600
+
+	DefaultFailoverUnreachableSeconds    = 300
+	DefaultFailoverUnreachableSecondsMin = 30
+	DefaultFailoverUnreachableSecondsMax = 3600
+
+	DefaultFailoverFailbackSeconds    = 900
+	DefaultFailoverFailbackSecondsMin = 60
+	DefaultFailoverFailbackSecondsMax = 86400
+
+	DefaultCredentialProcessTimeoutSeconds    = 60
+	DefaultCredentialProcessTimeoutSecondsMin = 1
+	DefaultCredentialProcessTimeoutSecondsMax = 600
+
 	DefaultStopTimeoutMillis    = 20000
 	DefaultStopTimeoutMillisMin = 10000
 	DefaultStopTimeoutMillisMax = 1000000
@@ -82,6 +170,9 @@ const (
 	//aws-ssm-agent bookkeeping constants for failed sent replies
 	RepliesRootDirName = "replies"
 
+	// CrashReportsDirName is the folder in the log folder the crash reports of plugins are written to
+	CrashReportsDirName = "crashreports"
+
 	//aws-ssm-agent bookkeeping constants for compliance
 	ComplianceRootDirName         = "compliance"
 	ComplianceContentHashFileName = "contentHash"
@@ -126,9 +217,30 @@ const (
 	// PluginNameAwsConfigureDaemon is the name for configure daemon plugin
 	PluginNameAwsConfigureDaemon = "aws:configureDaemon"
 
+	// PluginNameAwsConfigureCloudWatchAgent is the name for configure unified cloudwatch agent plugin
+	PluginNameAwsConfigureCloudWatchAgent = "aws:configureCloudWatchAgent"
+
+	// PluginNameAwsPutComplianceItems is the name for put compliance items plugin
+	PluginNameAwsPutComplianceItems = "aws:putComplianceItems"
+
+	// PluginNameAwsScanUpdates is the name for scan updates plugin
+	PluginNameAwsScanUpdates = "aws:scanUpdates"
+
 	// PluginNameAwsConfigurePackage is the name for configure package plugin
 	PluginNameAwsConfigurePackage = "aws:configurePackage"
 
+	// PluginNameAwsConfigureWindowsFeatures is the name for configure windows features plugin
+	PluginNameAwsConfigureWindowsFeatures = "aws:configureWindowsFeatures"
+
+	// PluginNameAwsManagePackages is the name for manage packages plugin
+	PluginNameAwsManagePackages = "aws:managePackages"
+
+	// PluginNameAwsManageUsers is the name for manage users plugin
+	PluginNameAwsManageUsers = "aws:manageUsers"
+
+	// PluginNameAwsManageRegistry is the name for manage registry plugin
+	PluginNameAwsManageRegistry = "aws:manageRegistry"
+
 	// PluginNameAwsRunShellScript is the name for run shell script plugin
 	PluginNameAwsRunShellScript = "aws:runShellScript"
 
@@ -156,6 +268,9 @@ const (
 	// PluginNameCloudWatch is the name of cloud watch plugin
 	PluginNameCloudWatch = "aws:cloudWatch"
 
+	// PluginNameCloudWatchAgent is the name of the long running plugin of the unified cloudwatch agent
+	PluginNameCloudWatchAgent = "aws:cloudWatchAgent"
+
 	// PluginNameRunDockerAction is the name of the docker container plugin
 	PluginNameDockerContainer = "aws:runDockerAction"
 
@@ -182,6 +297,97 @@ const (
 	DefaultSessionWorkersLimit    = 1000
 	DefaultSessionWorkersLimitMin = 1
 
+	// Low memory mode defaults
+	DefaultLowMemoryCommandWorkersLimit      = 2
+	DefaultLowMemoryLimitMB                  = 128
+	DefaultLowMemoryLimitMBMin               = 16
+	DefaultLowMemoryLimitMBMax               = 4096
+	DefaultLowMemorySessionBufferCapacity    = 1000
+	DefaultLowMemorySessionBufferCapacityMin = 100
+	DefaultLowMemorySessionBufferCapacityMax = 100000
+
+	// Graceful shutdown defaults
+	DefaultShutdownDeadlineSeconds    = 30
+	DefaultShutdownDeadlineSecondsMin = 5
+	DefaultShutdownDeadlineSecondsMax = 600
+
+	// Clock skew defaults
+	DefaultClockSkewThresholdSeconds    = 60
+	DefaultClockSkewThresholdSecondsMin = 1
+	DefaultClockSkewThresholdSecondsMax = 3600
+
+	// Connection attempt delay defaults, from RFC 8305
+	DefaultNetworkConnectionAttemptDelayMilliseconds    = 250
+	DefaultNetworkConnectionAttemptDelayMillisecondsMin = 10
+	DefaultNetworkConnectionAttemptDelayMillisecondsMax = 2000
+
+	// Shared connection pool defaults
+	DefaultNetworkDnsCacheSeconds              = 30
+	DefaultNetworkDnsCacheSecondsMin           = 0
+	DefaultNetworkDnsCacheSecondsMax           = 3600
+	DefaultNetworkMaxIdleConnectionsPerHost    = 8
+	DefaultNetworkMaxIdleConnectionsPerHostMin = 1
+	DefaultNetworkMaxIdleConnectionsPerHostMax = 100
+
+	// Document state store size defaults
+	DefaultStateStoreMaxSizeMB    = 64
+	DefaultStateStoreMaxSizeMBMin = 1
+	DefaultStateStoreMaxSizeMBMax = 1024
+
+	// Inventory gatherer timeout defaults
+	DefaultInventoryGathererTimeoutSeconds    = 300
+	DefaultInventoryGathererTimeoutSecondsMin = 10
+	DefaultInventoryGathererTimeoutSecondsMax = 3600
+
+	// Document worker pool defaults
+	DefaultWorkerPoolSize                          = 2
+	DefaultWorkerPoolSizeMin                       = 1
+	DefaultWorkerPoolSizeMax                       = 16
+	DefaultWorkerPoolMaxDocumentsPerWorker         = 50
+	DefaultWorkerPoolMaxDocumentsPerWorkerMin      = 1
+	DefaultWorkerPoolMaxDocumentsPerWorkerMax      = 1000
+	DefaultWorkerPoolHealthCheckIntervalSeconds    = 60
+	DefaultWorkerPoolHealthCheckIntervalSecondsMin = 10
+	DefaultWorkerPoolHealthCheckIntervalSecondsMax = 300
+
+	// Request compression threshold defaults
+	DefaultCompressionThresholdBytes    = 8192
+	DefaultCompressionThresholdBytesMin = 1024
+	DefaultCompressionThresholdBytesMax = 1048576
+
+	// Reboot request coalescing defaults
+	DefaultRebootCoalesceSeconds    = 30
+	DefaultRebootCoalesceSecondsMin = 0
+	DefaultRebootCoalesceSecondsMax = 3600
+
+	// Export file rotation defaults
+	DefaultExportMaxFileSizeMB    = 100
+	DefaultExportMaxFileSizeMBMin = 1
+	DefaultExportMaxFileSizeMBMax = 10240
+
+	// Event subscriber delivery defaults
+	DefaultEventTimeoutSeconds    = 10
+	DefaultEventTimeoutSecondsMin = 1
+	DefaultEventTimeoutSecondsMax = 300
+	DefaultEventMaxAttempts       = 3
+	DefaultEventMaxAttemptsMin    = 1
+	DefaultEventMaxAttemptsMax    = 10
+
+	// Service document cache defaults
+	DefaultDocumentCacheMaxEntries    = 200
+	DefaultDocumentCacheMaxEntriesMin = 1
+	DefaultDocumentCacheMaxEntriesMax = 10000
+
+	// Instance tags refresh defaults
+	DefaultInstanceTagsRefreshIntervalSeconds    = 300
+	DefaultInstanceTagsRefreshIntervalSecondsMin = 30
+	DefaultInstanceTagsRefreshIntervalSecondsMax = 86400
+
+	// Watchdog stall timeout defaults
+	DefaultWatchdogStallTimeoutSeconds    = 900
+	DefaultWatchdogStallTimeoutSecondsMin = 60
+	DefaultWatchdogStallTimeoutSecondsMax = 86400
+
 	// PluginNameStandardStream is the name for session manager standard stream plugin aka shell.
 	PluginNameStandardStream = "Standard_Stream"
 
@@ -191,14 +397,122 @@ const (
 	// PluginNamePort is the name for session manager port plugin.
 	PluginNamePort = "Port"
 
+	// Log sinks supported by the agent logger
+	LogSinkFile     = "file"
+	LogSinkSyslog   = "syslog"
+	LogSinkJournald = "journald"
+
+	// Minimum size budget for the log directory, the agent and error logs alone can grow to 40MB
+	DefaultLogMaxDirectorySizeMBMin = 50
+
+	// Instance metadata endpoint modes, IPv6 uses the metadata service address reachable from IPv6-only subnets
+	Ec2MetadataEndpointModeIPv4 = "IPv4"
+	Ec2MetadataEndpointModeIPv6 = "IPv6"
+
+	// Minimum TLS versions supported for connections to service endpoints
+	TlsVersion12 = "TLS1.2"
+	TlsVersion13 = "TLS1.3"
+
+	// Storage backends for the managed instance private key. PrivateKeyStorageOs uses DPAPI on Windows
+	// and the system keychain on macOS, PrivateKeyStorageAuto picks the most secure backend available.
+	PrivateKeyStorageFile = "file"
+	PrivateKeyStorageTpm  = "tpm"
+	PrivateKeyStorageOs   = "os"
+	PrivateKeyStorageAuto = "auto"
+
+	// Privileges a plugin can be declared with, PrivilegeRoot plugins run in a worker started by the privilege broker
+	PrivilegeRoot = "root"
+	PrivilegeUser = "user"
+
+	// DefaultUnprivilegedUser is the account the agent worker runs as when Privilege.Unprivileged is set
+	DefaultUnprivilegedUser = "ssm-agent"
+
+	// Command delivery modes, CommandDeliveryPush receives run command messages over the MGS control channel
+	// when the service supports it and polls MDS otherwise
+	CommandDeliveryPoll = "poll"
+	CommandDeliveryPush = "push"
+
+	// ProxyDirect is the proxy rule value that sends requests to a service without a proxy
+	ProxyDirect = "DIRECT"
+
 	// Session default RunAs user name
 	DefaultRunAsUserName = "ssm-user"
+
+	// DefaultSessionUserSudoRule is the sudoers rule of the session user when it is an administrator
+	DefaultSessionUserSudoRule = "ALL=(ALL) NOPASSWD:ALL"
+
+	// Server side encryption algorithms of objects written to S3
+	S3ServerSideEncryptionAes256 = "AES256"
+	S3ServerSideEncryptionKms    = "aws:kms"
+
+	// Encryption modes of the document state and replies persisted by the agent
+	StateEncryptionNone  = "none"
+	StateEncryptionLocal = "local"
+	StateEncryptionKms   = "kms"
+
+	// Sinks of the provenance attestations of executed documents
+	AttestationSinkFile = "file"
+	AttestationSinkS3   = "s3"
+
+	// Formats of the exported inventory and compliance data
+	ExportFormatJson = "json"
+	ExportFormatAsff = "asff"
+
+	// Severities of the script inspection rules
+	ScriptInspectionSeverityWarn  = "warn"
+	ScriptInspectionSeverityBlock = "block"
+
+	// Ways script plugins of an agent in a container reach the host
+	HostAccessNone    = "none"
+	HostAccessAuto    = "auto"
+	HostAccessNsenter = "nsenter"
+	HostAccessChroot  = "chroot"
+
+	// Runtime tuning profiles of the agent processes
+	PerformanceProfileDefault   = "default"
+	PerformanceProfileLatency   = "latency"
+	PerformanceProfileFootprint = "footprint"
+
+	// DefaultPerformanceFootprintSessionBufferCapacity caps the session buffers in the footprint profile
+	DefaultPerformanceFootprintSessionBufferCapacity = 1000
+
+	// DefaultHostRoot is where the host filesystem is mounted in the container of the agent
+	DefaultHostRoot = "/host"
+
+	// ApprovalTokenParameter is the document parameter holding the approval of steps running plugins that require one
+	ApprovalTokenParameter = "ApprovalToken"
 )
 
+// PollWindowTimeFormat is the format of the start and end of poll windows
+const PollWindowTimeFormat = "15:04"
+
+// PollWindowDays maps the lower case day names poll windows accept to the days of the week
+var PollWindowDays = map[string]time.Weekday{
+	"sun": time.Sunday, "sunday": time.Sunday,
+	"mon": time.Monday, "monday": time.Monday,
+	"tue": time.Tuesday, "tuesday": time.Tuesday,
+	"wed": time.Wednesday, "wednesday": time.Wednesday,
+	"thu": time.Thursday, "thursday": time.Thursday,
+	"fri": time.Friday, "friday": time.Friday,
+	"sat": time.Saturday, "saturday": time.Saturday,
+}
+
+// S3CannedACLs are the acls objects written to S3 can be uploaded with
+var S3CannedACLs = []string{
+	"private",
+	"public-read",
+	"public-read-write",
+	"authenticated-read",
+	"aws-exec-read",
+	"bucket-owner-read",
+	"bucket-owner-full-control",
+}
+
 // Document versions that are supported by this Agent version.
 // Note that 1.1 and 2.1 are deprecated schemas and hence are not added here.
 // Version 2.0.1, 2.0.2, and 2.0.3 are added to support install documents for configurePackage
-// that require capabilities that did not exist before the build where support for these versions was added
+// that require capabilities that did not exist before the build where support for these versions was added.
+// Version 2.3 adds the include of document fragments to 2.2
 var SupportedDocumentVersions = map[string]struct{}{
 	"1.0":   {},
 	"1.2":   {},
@@ -207,6 +521,7 @@ var SupportedDocumentVersions = map[string]struct{}{
 	"2.0.2": {},
 	"2.0.3": {},
 	"2.2":   {},
+	"2.3":   {},
 }
 
 // Session Manager Document versions that are supported by this Agent version.
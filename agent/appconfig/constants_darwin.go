@@ -99,3 +99,6 @@ const (
 	// RunCommandScriptName is the script name where all downloaded or provided commands will be stored
 	RunCommandScriptName = "_script.sh"
 )
+
+// RebootRequiredExitCodes are the exit codes of a step that completed and requires a reboot
+var RebootRequiredExitCodes = []int{RebootExitCode}
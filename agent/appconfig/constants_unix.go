@@ -110,6 +110,9 @@ var DefaultSessionLogger = "/usr/bin/ssm-session-logger"
 // AppConfigPath is the path of the AppConfig
 var AppConfigPath = DefaultProgramFolder + AppConfigFileName
 
+// RebootRequiredExitCodes are the exit codes of a step that completed and requires a reboot
+var RebootRequiredExitCodes = []int{RebootExitCode}
+
 func init() {
 	/*
 	   Powershell command used to be poweshell in alpha versions, now it's pwsh in prod versions
@@ -41,6 +41,9 @@ const (
 	// Exit Code that would trigger a Soft Reboot
 	RebootExitCode = 3010
 
+	// RebootInitiatedExitCode is returned by installers that completed and started a restart of the instance
+	RebootInitiatedExitCode = 1641
+
 	// PackagePlatform is the platform name to use when looking for packages
 	PackagePlatform = "windows"
 
@@ -65,6 +68,9 @@ const (
 //PowerShellPluginCommandName is the path of the powershell.exe to be used by the runPowerShellScript plugin
 var PowerShellPluginCommandName = filepath.Join(os.Getenv("SystemRoot"), "System32", "WindowsPowerShell", "v1.0", "powershell.exe")
 
+// RebootRequiredExitCodes are the exit codes of a step that completed and requires a reboot
+var RebootRequiredExitCodes = []int{RebootExitCode, RebootInitiatedExitCode}
+
 // Program Folder
 var DefaultProgramFolder string
 
@@ -173,6 +179,13 @@ func init() {
 	EnvWinDir = os.Getenv("WINDIR")
 	temp := os.Getenv("TEMP")
 
+	// Nano Server does not ship Windows PowerShell, fall back to PowerShell 7 when it is installed
+	if _, err := os.Stat(PowerShellPluginCommandName); err != nil {
+		if _, err = os.Stat(filepath.Join(EnvProgramFiles, "PowerShell", "7", "pwsh.exe")); err == nil {
+			PowerShellPluginCommandName = filepath.Join(EnvProgramFiles, "PowerShell", "7", "pwsh.exe")
+		}
+	}
+
 	DefaultProgramFolder = filepath.Join(EnvProgramFiles, SSMFolder)
 	DefaultPluginPath = filepath.Join(EnvProgramFiles, SSMPluginFolder)
 	DefaultSSMAgentWorker = filepath.Join(DefaultProgramFolder, "ssm-agent-worker.exe")
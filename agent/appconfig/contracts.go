@@ -18,14 +18,56 @@ package appconfig
 type CredentialProfile struct {
 	ShareCreds   bool
 	ShareProfile string
+	// WebIdentityTokenFile and WebIdentityRoleArn configure credentials from AssumeRoleWithWebIdentity
+	WebIdentityTokenFile       string
+	WebIdentityRoleArn         string
+	WebIdentityRoleSessionName string
+	// CredentialProcess is an external command that prints credentials in the credential_process json format
+	CredentialProcess               string
+	CredentialProcessTimeoutSeconds int
 }
 
-// MdsCfg represents configuration for Message delivery service (MDS)
+// MdsCfg represents configuration for Message delivery service (MDS),
+// CommandDelivery selects whether run command messages are only polled from MDS or also received over the MGS control channel
+// CommandWorkersLimit and CancelWorkersLimit size the document worker pools, QueuedJobsPerWorker limits the documents
+// waiting for a worker, and CpuBoundWorkersLimit and IoBoundWorkersLimit limit the running documents of a scheduling class
 type MdsCfg struct {
-	Endpoint            string
-	CommandWorkersLimit int
-	StopTimeoutMillis   int64
-	CommandRetryLimit   int
+	Endpoint             string
+	CommandWorkersLimit  int
+	CancelWorkersLimit   int
+	QueuedJobsPerWorker  int
+	CpuBoundWorkersLimit int
+	IoBoundWorkersLimit  int
+	StopTimeoutMillis    int64
+	CommandRetryLimit    int
+	CommandDelivery      string
+	Polling              MdsPollingCfg
+	Validation           MdsValidationCfg
+}
+
+// MdsValidationCfg represents the strict validation of run command messages. When Strict is set messages
+// created more than MaxClockSkewSeconds away from the clock of the instance, and messages whose id was
+// received before, are rejected and recorded in the audit log.
+type MdsValidationCfg struct {
+	Strict              bool
+	MaxClockSkewSeconds int
+}
+
+// MdsPollingCfg represents adaptive polling of MDS. When Adaptive is set the wait between polls doubles
+// while no messages arrive, up to MaxIntervalSeconds, and resets after a message. Inside one of the Windows
+// the agent always polls without waiting.
+type MdsPollingCfg struct {
+	Adaptive           bool
+	MaxIntervalSeconds int
+	Windows            []PollWindowCfg
+}
+
+// PollWindowCfg represents a daily window in local time, Start and End use the 24 hour HH:MM format and
+// a window ending before it starts spans midnight. Days limits the window to some days of the week, like Mon or Sat.
+type PollWindowCfg struct {
+	Days  []string
+	Start string
+	End   string
 }
 
 // SsmCfg represents configuration for Simple system manager (SSM)
@@ -41,6 +83,8 @@ type SsmCfg struct {
 	AssociationLogsRetentionDurationHours int
 	RunCommandLogsRetentionDurationHours  int
 	SessionLogsRetentionDurationHours     int
+	// HealthInventoryEnabled writes the operational gauges of every health ping as a custom inventory item
+	HealthInventoryEnabled bool
 }
 
 // AgentInfo represents metadata for amazon-ssm-agent
@@ -58,6 +102,12 @@ type AgentInfo struct {
 	TelemetryMetricsNamespace               string
 	LongRunningWorkerMonitorIntervalSeconds int
 	AuditExpirationDay                      int
+	UseDualStackEndpoint                    bool
+	UseFipsEndpoint                         bool
+	Ec2MetadataEndpointMode                 string
+	// BootstrapDocumentPath is a local command document the worker executes once, on its first start,
+	// before it contacts the service
+	BootstrapDocumentPath string
 }
 
 // MgsConfig represents configuration for Message Gateway service
@@ -82,28 +132,500 @@ type OsInfo struct {
 
 // S3Cfg represents configurations related to S3 bucket and key for SSM
 type S3Cfg struct {
-	Endpoint  string
-	Region    string
-	LogBucket string
-	LogKey    string
+	Endpoint            string
+	Region              string
+	LogBucket           string
+	LogKey              string
+	UploadPartSizeMB    int
+	UploadBandwidthKBps int
+	// BucketEndpoints overrides the endpoint of individual buckets, such as buckets reached through S3 interface endpoints
+	BucketEndpoints map[string]string
+	// ServerSideEncryption, SSEKMSKeyId, ExpectedBucketOwner and ObjectACL are applied to every object the agent writes
+	ServerSideEncryption string
+	SSEKMSKeyId          string
+	ExpectedBucketOwner  string
+	ObjectACL            string
 }
 
 // BirdwatcherCfg represents configuration related to ConfigurePackage Birdwatcher integration
 type BirdwatcherCfg struct {
 	ForceEnable bool
+	// MirrorUrl is a file:// or https:// package mirror that packages are resolved from instead of the package service
+	MirrorUrl string
+	// MirrorPublicKeys are the paths of the PEM encoded public keys that must sign the manifests of the package mirror
+	MirrorPublicKeys []string
+}
+
+// LogCfg represents configuration for the agent logger
+type LogCfg struct {
+	Sinks               []string
+	SyslogIdentifier    string
+	CompressRotatedLogs bool
+	MaxDirectorySizeMB  int
+}
+
+// ProxyRuleCfg represents the proxy settings used for requests to a single service
+type ProxyRuleCfg struct {
+	Proxy   string
+	NoProxy []string
+}
+
+// ProxyCfg represents configuration for choosing the proxy used to reach service endpoints
+type ProxyCfg struct {
+	PacFile  string
+	Services map[string]ProxyRuleCfg
+}
+
+// TlsCfg represents configuration for the TLS connections the agent makes to service endpoints
+type TlsCfg struct {
+	CaBundlePath string
+	MinVersion   string
+	CipherSuites []string
+}
+
+// RegistrationCfg represents configuration for the managed instance registration
+type RegistrationCfg struct {
+	PrivateKeyStorage         string
+	AutoReregister            bool
+	ActivationHook            string
+	MaxReregistrationAttempts int
+}
+
+// FingerprintCfg represents configuration for the managed instance fingerprint
+type FingerprintCfg struct {
+	SimilarityThreshold int
+	IdentityHook        string
+}
+
+// FailoverTargetCfg represents a secondary region used when the primary control plane is unreachable,
+// Endpoints optionally overrides the endpoint of a service in that region
+type FailoverTargetCfg struct {
+	Region    string
+	Endpoints map[string]string
+}
+
+// FailoverCfg represents configuration for switching to secondary regions during a regional event
+type FailoverCfg struct {
+	Secondaries        []FailoverTargetCfg
+	UnreachableSeconds int
+	FailbackSeconds    int
+}
+
+// PrivilegeCfg represents configuration for running the agent worker as an unprivileged user on Linux,
+// PluginPrivileges overrides whether a plugin needs a worker started as root by the privilege broker
+type PrivilegeCfg struct {
+	Unprivileged     bool
+	User             string
+	PluginPrivileges map[string]string
+}
+
+// ExecutionContextCfg represents the SELinux domain or AppArmor profile document and session workers are confined to on Linux,
+// documents can override them unless DenyDocumentOverride is set
+type ExecutionContextCfg struct {
+	SELinuxDomain        string
+	AppArmorProfile      string
+	DenyDocumentOverride bool
+}
+
+// OutputCfg represents the webhook command output is posted to instead of S3.
+// The gzipped output is signed with an HMAC SHA256 of the signing key when one is configured.
+// TruncationStrategy chooses whether the head, the tail or both ends of output too large for the reply are kept.
+type OutputCfg struct {
+	WebhookUrl            string
+	WebhookSigningKey     string
+	WebhookTimeoutSeconds int
+	TruncationStrategy    string
+}
+
+// ArtifactCacheCfg represents the cache of downloaded artifacts shared by aws:downloadContent and aws:configurePackage.
+// Artifacts are stored by their SHA256 checksum and the least recently used are evicted above MaxSizeMB.
+type ArtifactCacheCfg struct {
+	Enabled   bool
+	MaxSizeMB int
+}
+
+// OfflineCfg represents the queue of results the agent could not send because the service was unreachable.
+// When Enabled, command replies, association status, inventory and health pings are stored and sent in order
+// every FlushIntervalSeconds, entries older than MaxEntryAgeHours or above MaxQueueSizeMB are dropped.
+type OfflineCfg struct {
+	Enabled              bool
+	MaxQueueSizeMB       int
+	MaxEntryAgeHours     int
+	FlushIntervalSeconds int
+}
+
+// LocalApiCfg represents the local api tools on the instance use to query the agent status and trigger actions.
+// Root, the user of the agent and members of AllowedGroup can connect to its unix socket or named pipe.
+// LoopbackSessions lets them start shell and port sessions without the service. Root chooses the session document and
+// its RunAs user, the sessions of the other users run the default document as their own account.
+type LocalApiCfg struct {
+	Enabled          bool
+	AllowedGroup     string
+	LoopbackSessions bool
+}
+
+// StateEncryptionCfg represents the at-rest encryption of the document state and the replies the agent persists,
+// which contain the resolved parameters of the documents. Mode local keeps the data key in the agent vault,
+// mode kms keeps it wrapped by KmsKeyId. Existing files are rewritten in the configured form when the agent starts.
+type StateEncryptionCfg struct {
+	Mode     string
+	KmsKeyId string
+}
+
+// AttestationCfg represents the provenance attestations the agent emits when a document finishes. They list the
+// document hash, the plugin versions, the digests of the downloaded artifacts and the parameters with their values
+// redacted. Sinks selects where they are written: file writes them to LocalDirectory, s3 uploads them to S3BucketName.
+type AttestationCfg struct {
+	Enabled        bool
+	Sinks          []string
+	LocalDirectory string
+	S3BucketName   string
+	S3KeyPrefix    string
+}
+
+// ScriptSandboxCfg represents the kernel sandbox aws:runShellScript children run in on Linux. SeccompProfile is the path
+// of a seccomp profile in the OCI format, the children can only access LandlockReadOnlyPaths and LandlockReadWritePaths
+// when either is set. Kernels without seccomp filters or Landlock run the children without them.
+// Steps can override the settings with their sandbox property unless DenyDocumentOverride is set.
+type ScriptSandboxCfg struct {
+	Enabled                bool
+	SeccompProfile         string
+	LandlockReadOnlyPaths  []string
+	LandlockReadWritePaths []string
+	DenyDocumentOverride   bool
+}
+
+// DocumentIsolationCfg represents the filesystem isolation of the aws:runShellScript and aws:runPowerShellScript children.
+// Each document has a private temporary directory the children find in TMPDIR, TMP and TEMP, it is removed when the
+// document completes or, after a crash, when the agent starts. PrivateTmp mounts it over /tmp and /var/tmp in a private
+// mount namespace, and Chroot is the root directory of the children. Both only apply to aws:runShellScript on Linux.
+type DocumentIsolationCfg struct {
+	PrivateTmp bool
+	Chroot     string
+}
+
+// PowerShellCfg represents the visibility of aws:runPowerShellScript steps to AMSI and EDR products. Scripts are passed
+// to PowerShell as files, never as encoded commands, so that AMSI scans them and script block logging records them.
+// RequireScriptBlockLogging fails the steps on Windows instances where the script block logging policy is not enabled
+// or no AMSI provider is registered, instead of logging a warning.
+type PowerShellCfg struct {
+	RequireScriptBlockLogging bool
+}
+
+// ScriptInspectionRuleCfg represents a regular expression resolved scripts are inspected against, Severity is warn or block.
+type ScriptInspectionRuleCfg struct {
+	Name     string
+	Pattern  string
+	Severity string
+}
+
+// ScriptInspectionCfg represents the inspection of the parameter resolved scripts of aws:runShellScript and
+// aws:runPowerShellScript steps before they run. Scripts matching a block rule fail the step, matches of warn rules
+// are logged and reported in the step output. When YaraPath is set, the yara executable also scans the scripts with
+// the YaraRules files and its matches have the YaraSeverity.
+type ScriptInspectionCfg struct {
+	Rules        []ScriptInspectionRuleCfg
+	YaraPath     string
+	YaraRules    []string
+	YaraSeverity string
+}
+
+// ExecutionPolicyCfg represents the plugins and documents the agent runs. A document is rejected with the RejectedByPolicy
+// status when a step runs a plugin matching DeniedPlugins or none of AllowedPlugins, when its name matches DeniedDocuments
+// or none of AllowedDocuments, or when it is shared from an account of DeniedSourceAccounts or outside AllowedSourceAccounts.
+// Empty allowlists allow everything and denylists take precedence. Plugin and document patterns use the path.Match syntax,
+// "*" in DeniedSourceAccounts rejects every document shared from another account.
+// Steps running a plugin matching ApprovalRequiredPlugins additionally need the ApprovalToken document parameter to hold
+// the base64 signature of the command ID, the association ID for associations, by one of the ApproverPublicKeys.
+// ApproverPublicKeys are paths to PEM encoded Ed25519, ECDSA or RSA public keys or certificates, ECDSA and RSA signatures
+// are taken over the sha256.
+type ExecutionPolicyCfg struct {
+	AllowedPlugins          []string
+	DeniedPlugins           []string
+	AllowedDocuments        []string
+	DeniedDocuments         []string
+	AllowedSourceAccounts   []string
+	DeniedSourceAccounts    []string
+	ApprovalRequiredPlugins []string
+	ApproverPublicKeys      []string
+}
+
+// LowMemoryCfg represents the reduced footprint mode for devices with little memory. When Enabled run command and
+// association documents run inside the worker process instead of a document worker each, at most
+// DefaultLowMemoryCommandWorkersLimit at a time, and no further document is started while the worker uses more than
+// MemoryLimitMB. Session data channels buffer SessionBufferCapacity messages and inventory only collects the gatherers
+// matching Gatherers, using the path.Match syntax.
+type LowMemoryCfg struct {
+	Enabled               bool
+	MemoryLimitMB         int
+	SessionBufferCapacity int
+	Gatherers             []string
+}
+
+// HostCfg represents running the agent in a container that manages its node, like a pod of a Kubernetes DaemonSet.
+// HostAccess selects where aws:runShellScript runs its commands: HostAccessNone runs them in the container of the agent,
+// HostAccessNsenter enters the namespaces of the host init process, which needs the host PID namespace and a privileged
+// container, and HostAccessChroot changes root to HostRoot, the host filesystem mounted in the container.
+// HostAccessAuto uses nsenter when the agent detects it runs in a container. StateHostPath is the host directory mounted
+// at the agent data store, scripts written to the data store run from there on the host.
+// An agent in a container that is not registered yet registers the node with the hybrid activation ActivationId,
+// the activation code read from ActivationCodeFile and ActivationRegion.
+type HostCfg struct {
+	HostAccess         string
+	HostRoot           string
+	StateHostPath      string
+	ActivationId       string
+	ActivationCodeFile string
+	ActivationRegion   string
+}
+
+// ShutdownCfg represents the graceful shutdown of the agent worker. On a termination signal the worker stops accepting
+// new documents and sessions, tells the session clients their sessions end, waits for the running documents and flushes
+// their replies and logs, at most DeadlineSeconds before it exits. Documents still running then resume after the restart.
+type ShutdownCfg struct {
+	DeadlineSeconds int
+}
+
+// ClockSkewCfg represents the detection of the difference between the local clock and the clock of the services, measured
+// from the Date header of their responses. Requests to MDS, MGS, SSM and S3 are signed with the service time unless
+// DisableCorrection is set, so that a drifted clock does not make them fail with signature errors. A skew above
+// ThresholdSeconds is reported as a health warning, and written to the audit log when AuditEvent is set.
+type ClockSkewCfg struct {
+	DisableCorrection bool
+	ThresholdSeconds  int
+	AuditEvent        bool
+}
+
+// ImdsCfg represents how the agent reads the EC2 instance metadata. With RequireV2 every request needs an IMDSv2 session
+// token and fails instead of falling back to IMDSv1, agents in containers need a hop limit of at least 2 in the metadata
+// options of the instance to receive the token. With VerifyIdentityDocument the instance id, region, availability zone and
+// instance type are read from the instance identity document, which is only used when its signature verifies with the
+// AWS public certificate of the region stored as PEM in IdentityCertificatePath.
+type ImdsCfg struct {
+	RequireV2               bool
+	VerifyIdentityDocument  bool
+	IdentityCertificatePath string
+}
+
+// NetworkCfg represents the local address the outbound connections of the agent to MDS, MGS, SSM and S3, and the
+// connections of port forwarding sessions, are made from. SourceAddress is an IP address of the instance, SourceInterface
+// the name of a network interface whose first IPv4 address, or else first global IPv6 address, is used. SourceAddress
+// takes precedence. Connections to loopback destinations, like a proxy on the instance, are not bound.
+// Host names with several addresses are dialed as in RFC 8305, alternating IPv6 and IPv4 addresses and starting the
+// next attempt every ConnectionAttemptDelayMilliseconds without waiting for the previous one to time out.
+// With PrewarmConnections the agent connects to MGS as soon as a session start is received, so the data channel of
+// the session does not wait for the name resolution and the TCP handshake.
+// The addresses of host names are cached for DnsCacheSeconds, zero disables the cache. The AWS service clients of
+// the agent share one connection pool, which keeps MaxIdleConnectionsPerHost idle connections to each endpoint and
+// negotiates HTTP/2 with the endpoints that support it, unless DisableHttp2 is set.
+type NetworkCfg struct {
+	SourceAddress                      string
+	SourceInterface                    string
+	ConnectionAttemptDelayMilliseconds int
+	PrewarmConnections                 bool
+	DnsCacheSeconds                    int
+	MaxIdleConnectionsPerHost          int
+	DisableHttp2                       bool
+}
+
+// StateStoreCfg represents the file the agent keeps the state of its pending and running documents in.
+// The file never grows above MaxSizeMB, the states of corrupt documents are dropped first when it is full.
+type StateStoreCfg struct {
+	MaxSizeMB int
+}
+
+// InventoryCfg represents the inventory collection, the gatherers run concurrently and each is given
+// GathererTimeoutSeconds to complete. The items of the gatherers that completed are uploaded without the others.
+type InventoryCfg struct {
+	GathererTimeoutSeconds int
+}
+
+// WorkerPoolCfg represents the document workers started ahead of the commands, so a command does not wait for its
+// worker process to start. A pooled worker runs one command at a time and restores its process state after each:
+// the environment, working directory, file mode creation mask, resource limits and plugin registry. Goroutines and
+// file descriptors a command leaks and the managed instance credentials the worker cached are kept, a worker is
+// replaced after MaxDocumentsPerWorker commands, when it misses a health check or fails to restore its state.
+// Documents run under an execution context or needing root run in a new worker.
+type WorkerPoolCfg struct {
+	Enabled                    bool
+	Size                       int
+	MaxDocumentsPerWorker      int
+	HealthCheckIntervalSeconds int
+}
+
+// PerformanceCfg represents the tuning of the Go runtime of the agent processes. PerformanceProfileDefault keeps the
+// runtime defaults. PerformanceProfileLatency collects garbage less often, so collections take less CPU from co-located
+// workloads, and PerformanceProfileFootprint collects more often and caps the session buffers. Both profiles limit
+// GOMAXPROCS to the CPU quota and set the soft memory limit of the runtime below the memory limit of the cgroup of
+// the agent. GOGC, GOMEMLIMIT and GOMAXPROCS set in the environment of the agent take precedence.
+type PerformanceCfg struct {
+	Profile string
+}
+
+// CompressionCfg represents the compression of the large payloads the agent sends, command replies to ec2messages and
+// inventory to ssm. When Enabled request bodies of at least ThresholdBytes are sent gzip compressed. A service that
+// rejects a compressed request gets it again uncompressed and is no longer sent compressed requests.
+type CompressionCfg struct {
+	Enabled        bool
+	ThresholdBytes int
+}
+
+// RebootCfg represents the coordination of the reboots documents request. Requests that arrive within CoalesceSeconds
+// of the first one are satisfied by a single reboot. The reboot waits for one of the MaintenanceWindows when any are set
+// and never happens in one of the BlackoutWindows, the closed periods of the change calendar of the instance.
+// Both use the daily windows in local time of the poll windows.
+type RebootCfg struct {
+	CoalesceSeconds    int
+	MaintenanceWindows []PollWindowCfg
+	BlackoutWindows    []PollWindowCfg
+}
+
+// ExportCfg represents the export of the inventory and compliance data the agent submits to Systems Manager.
+// Every submission is mirrored in Format, json records or ASFF findings, one per line. The lines are appended to
+// LocalFile, rotated once it reaches MaxFileSizeMB, and written to the unix socket or named pipe Socket when set.
+// The export file of the instance is used when neither is set. AccountId is the account of the ASFF findings,
+// the account of the instance identity document by default.
+type ExportCfg struct {
+	Enabled       bool
+	Format        string
+	LocalFile     string
+	Socket        string
+	MaxFileSizeMB int
+	AccountId     string
+}
+
+// EventsCfg represents the subscribers of the lifecycle events of the documents the agent runs and the sessions it opens.
+type EventsCfg struct {
+	Subscribers []EventSubscriberCfg
+}
+
+// EventSubscriberCfg represents a subscriber the events matching one of the Types patterns are delivered to, every event
+// when Types is empty. Events are posted as JSON to the https WebhookUrl or written to the standard input of Command,
+// signed with an HMAC SHA256 of the SigningKey when one is configured. Events published to the SnsTopicArn or put on
+// the EventBridge bus EventBusName are sent in batches with the credentials of the instance. A delivery is abandoned
+// after TimeoutSeconds and failed deliveries are attempted up to MaxAttempts times with exponential backoff.
+type EventSubscriberCfg struct {
+	Name           string
+	Types          []string
+	WebhookUrl     string
+	Command        []string
+	SnsTopicArn    string
+	EventBusName   string
+	SigningKey     string
+	TimeoutSeconds int
+	MaxAttempts    int
+}
+
+// ServiceDocumentsCfg represents the documents the agent fetches with GetDocument, for associations, aws:runDocument steps
+// and SSMDocument sources of aws:downloadContent. When CacheEnabled their content is kept in a local cache of at most
+// CacheMaxEntries versions, documents requested by version number are served from it without calling the service and
+// the others once DescribeDocument returns the hash of the cached version. Documents matching a pin must pass it.
+type ServiceDocumentsCfg struct {
+	CacheEnabled    bool
+	CacheMaxEntries int
+	Pinned          []PinnedDocumentCfg
+}
+
+// PinnedDocumentCfg pins the content of the documents whose name or ARN matches Name, in the path.Match syntax.
+// The content GetDocument returns must have one of the Sha256 hex digests, or the version name of the document must be
+// the unpadded base64url signature of the content by one of the PublicKeys, paths to PEM encoded Ed25519, ECDSA or RSA
+// public keys or certificates. ECDSA and RSA signatures are taken over the sha256 of the content.
+type PinnedDocumentCfg struct {
+	Name       string
+	Sha256     []string
+	PublicKeys []string
+}
+
+// InstanceTagsCfg represents the instance tags documents reference as {{tag:Name}} in their steps and preconditions.
+// The tags are fetched from the instance metadata, or DescribeTags and ListTagsForResource when the instance does not
+// expose them there, and kept for RefreshIntervalSeconds.
+type InstanceTagsCfg struct {
+	RefreshIntervalSeconds int
+}
+
+// WatchdogCfg represents the watchdog of the core modules. A worker that makes no progress for StallTimeoutSeconds
+// beyond its own interval is considered stalled: the goroutines of the agent are dumped to the log folder and the worker
+// is restarted.
+type WatchdogCfg struct {
+	Enabled             bool
+	StallTimeoutSeconds int
+}
+
+// ExternalPluginCfg represents a plugin shipped as a separate executable, documents run it as a step named Name.
+// The agent exchanges JSON messages with it over its standard input and output for every step it runs.
+// RunAsUser, Environment and TimeoutSeconds confine the plugin, it only inherits the agent environment with InheritEnvironment.
+type ExternalPluginCfg struct {
+	Name               string
+	Path               string
+	Args               []string
+	RunAsUser          string
+	InheritEnvironment bool
+	Environment        map[string]string
+	TimeoutSeconds     int
+}
+
+// SessionUserCfg represents the account sessions are started as when RunAs is not enabled.
+// Uid, Gid and HomeSkeleton only apply to Linux, InactiveDays disables the account when no session used it for that many days.
+type SessionUserCfg struct {
+	Disabled      bool
+	Name          string
+	Uid           int
+	Gid           int
+	Administrator bool
+	SudoRule      string
+	HomeSkeleton  string
+	InactiveDays  int
 }
 
 // SsmagentConfig stores agent configuration values.
 type SsmagentConfig struct {
-	Profile     CredentialProfile
-	Mds         MdsCfg
-	Ssm         SsmCfg
-	Mgs         MgsConfig
-	Agent       AgentInfo
-	Os          OsInfo
-	S3          S3Cfg
-	Birdwatcher BirdwatcherCfg
-	Kms         KmsConfig
+	Profile          CredentialProfile
+	Mds              MdsCfg
+	Ssm              SsmCfg
+	Mgs              MgsConfig
+	Agent            AgentInfo
+	Os               OsInfo
+	S3               S3Cfg
+	Birdwatcher      BirdwatcherCfg
+	Kms              KmsConfig
+	Log              LogCfg
+	Proxy            ProxyCfg
+	Tls              TlsCfg
+	Registration     RegistrationCfg
+	Fingerprint      FingerprintCfg
+	Failover         FailoverCfg
+	Privilege        PrivilegeCfg
+	ExecutionContext ExecutionContextCfg
+	Output           OutputCfg
+	ArtifactCache    ArtifactCacheCfg
+	SessionUser      SessionUserCfg
+	Offline          OfflineCfg
+	LocalApi         LocalApiCfg
+	StateEncryption  StateEncryptionCfg
+	Attestation      AttestationCfg
+	ScriptSandbox    ScriptSandboxCfg
+	Isolation        DocumentIsolationCfg
+	PowerShell       PowerShellCfg
+	ScriptInspection ScriptInspectionCfg
+	ExecutionPolicy  ExecutionPolicyCfg
+	LowMemory        LowMemoryCfg
+	Host             HostCfg
+	Shutdown         ShutdownCfg
+	ClockSkew        ClockSkewCfg
+	Imds             ImdsCfg
+	Network          NetworkCfg
+	StateStore       StateStoreCfg
+	Inventory        InventoryCfg
+	WorkerPool       WorkerPoolCfg
+	Performance      PerformanceCfg
+	Compression      CompressionCfg
+	Reboot           RebootCfg
+	Export           ExportCfg
+	Events           EventsCfg
+	ServiceDocuments ServiceDocumentsCfg
+	InstanceTags     InstanceTagsCfg
+	Watchdog         WatchdogCfg
+	ExternalPlugins  []ExternalPluginCfg
 }
 
 // AppConstants represents some run time constant variable for various module.
@@ -0,0 +1,59 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package appconfig
+
+import (
+	"strings"
+	"time"
+)
+
+// Contains returns true if the local time is inside the window, a window spanning midnight belongs to the day it starts on
+func (window PollWindowCfg) Contains(now time.Time) bool {
+	start, err := time.Parse(PollWindowTimeFormat, window.Start)
+	if err != nil {
+		return false
+	}
+	end, err := time.Parse(PollWindowTimeFormat, window.End)
+	if err != nil {
+		return false
+	}
+	startMinute := start.Hour()*60 + start.Minute()
+	endMinute := end.Hour()*60 + end.Minute()
+	minute := now.Hour()*60 + now.Minute()
+
+	day := now.Weekday()
+	var inside bool
+	if startMinute <= endMinute {
+		inside = minute >= startMinute && minute < endMinute
+	} else if minute >= startMinute {
+		inside = true
+	} else if minute < endMinute {
+		inside = true
+		day = (day + 6) % 7
+	}
+	return inside && window.includesDay(day)
+}
+
+// includesDay returns true if the window applies to the day, windows without days apply to every day
+func (window PollWindowCfg) includesDay(day time.Weekday) bool {
+	if len(window.Days) == 0 {
+		return true
+	}
+	for _, name := range window.Days {
+		if weekday, ok := PollWindowDays[strings.ToLower(name)]; ok && weekday == day {
+			return true
+		}
+	}
+	return false
+}
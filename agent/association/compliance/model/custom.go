@@ -0,0 +1,147 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package model
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/ssm"
+)
+
+const (
+	// CustomComplianceTypePrefix starts the compliance types that documents report
+	CustomComplianceTypePrefix = "Custom:"
+	// MaxCustomComplianceItems is the most items PutComplianceItems accepts for a compliance type
+	MaxCustomComplianceItems = 10000
+	// ExecutionTypeCommand links the items to the command that reported them
+	ExecutionTypeCommand = "Command"
+	// ExecutionTypeAssociation links the items to the association that reported them
+	ExecutionTypeAssociation = "Association"
+)
+
+var customComplianceTypePattern = regexp.MustCompile(`^Custom:[A-Za-z0-9_\-]\w{0,91}$`)
+
+var complianceSeverities = []string{
+	ssm.ComplianceSeverityCritical,
+	ssm.ComplianceSeverityHigh,
+	ssm.ComplianceSeverityMedium,
+	ssm.ComplianceSeverityLow,
+	ssm.ComplianceSeverityInformational,
+	ssm.ComplianceSeverityUnspecified,
+}
+
+// CustomComplianceItem is one rule of a custom compliance type, like the result of a CIS benchmark rule
+type CustomComplianceItem struct {
+	Id       string
+	Title    string `json:",omitempty"`
+	Severity string
+	Status   string
+	Details  map[string]string `json:",omitempty"`
+}
+
+// CustomComplianceReport replaces the items of a custom compliance type of the instance.
+// ExecutionId and ExecutionType link the items to the command or association that reported them.
+type CustomComplianceReport struct {
+	ComplianceType string
+	ExecutionId    string    `json:",omitempty"`
+	ExecutionType  string    `json:",omitempty"`
+	ExecutionTime  time.Time `json:",omitempty"`
+	Items          []CustomComplianceItem
+}
+
+// SeveritySummary counts the items of every severity
+type SeveritySummary struct {
+	CriticalCount      int
+	HighCount          int
+	MediumCount        int
+	LowCount           int
+	InformationalCount int
+	UnspecifiedCount   int
+}
+
+// CustomComplianceSummary counts the compliant and non compliant items of a report by severity
+type CustomComplianceSummary struct {
+	ComplianceType      string
+	CompliantCount      int
+	NonCompliantCount   int
+	CompliantSummary    SeveritySummary
+	NonCompliantSummary SeveritySummary
+}
+
+// ValidateCustomComplianceReport checks the report against the constraints of PutComplianceItems
+func ValidateCustomComplianceReport(report CustomComplianceReport) error {
+	if !customComplianceTypePattern.MatchString(report.ComplianceType) {
+		return fmt.Errorf("compliance type %q must start with %v followed by letters, digits, '_' or '-'", report.ComplianceType, CustomComplianceTypePrefix)
+	}
+	if len(report.Items) > MaxCustomComplianceItems {
+		return fmt.Errorf("%v reports %v items, at most %v are allowed", report.ComplianceType, len(report.Items), MaxCustomComplianceItems)
+	}
+	ids := map[string]struct{}{}
+	for _, item := range report.Items {
+		if item.Id == "" {
+			return fmt.Errorf("an item of %v has no Id", report.ComplianceType)
+		}
+		if _, duplicate := ids[item.Id]; duplicate {
+			return fmt.Errorf("item %v of %v is reported twice", item.Id, report.ComplianceType)
+		}
+		ids[item.Id] = struct{}{}
+		if item.Status != COMPLIANT && item.Status != NON_COMPLIANT {
+			return fmt.Errorf("item %v has status %q, the status must be %v or %v", item.Id, item.Status, COMPLIANT, NON_COMPLIANT)
+		}
+		if !isComplianceSeverity(item.Severity) {
+			return fmt.Errorf("item %v has severity %q, the severity must be one of %v", item.Id, item.Severity, complianceSeverities)
+		}
+	}
+	return nil
+}
+
+// SummarizeCustomCompliance counts the items of the report by status and severity
+func SummarizeCustomCompliance(report CustomComplianceReport) CustomComplianceSummary {
+	summary := CustomComplianceSummary{ComplianceType: report.ComplianceType}
+	for _, item := range report.Items {
+		severities := &summary.CompliantSummary
+		if item.Status == NON_COMPLIANT {
+			summary.NonCompliantCount++
+			severities = &summary.NonCompliantSummary
+		} else {
+			summary.CompliantCount++
+		}
+		switch item.Severity {
+		case ssm.ComplianceSeverityCritical:
+			severities.CriticalCount++
+		case ssm.ComplianceSeverityHigh:
+			severities.HighCount++
+		case ssm.ComplianceSeverityMedium:
+			severities.MediumCount++
+		case ssm.ComplianceSeverityLow:
+			severities.LowCount++
+		case ssm.ComplianceSeverityInformational:
+			severities.InformationalCount++
+		default:
+			severities.UnspecifiedCount++
+		}
+	}
+	return summary
+}
+
+func isComplianceSeverity(severity string) bool {
+	for _, known := range complianceSeverities {
+		if severity == known {
+			return true
+		}
+	}
+	return false
+}
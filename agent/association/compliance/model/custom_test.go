@@ -0,0 +1,75 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package model
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func cisReport() CustomComplianceReport {
+	return CustomComplianceReport{
+		ComplianceType: "Custom:CIS",
+		Items: []CustomComplianceItem{
+			{Id: "1.1.1", Title: "Disable cramfs", Severity: "HIGH", Status: COMPLIANT},
+			{Id: "1.1.2", Title: "Separate /tmp", Severity: "MEDIUM", Status: NON_COMPLIANT},
+			{Id: "5.2.8", Title: "Disable SSH root login", Severity: "CRITICAL", Status: NON_COMPLIANT, Details: map[string]string{"PermitRootLogin": "yes"}},
+			{Id: "6.1.1", Title: "Audit permissions", Severity: UNSPECIFIED, Status: COMPLIANT},
+		},
+	}
+}
+
+func TestValidateCustomComplianceReport(t *testing.T) {
+	assert.NoError(t, ValidateCustomComplianceReport(cisReport()))
+	assert.NoError(t, ValidateCustomComplianceReport(CustomComplianceReport{ComplianceType: "Custom:CIS"}), "an empty report clears the type")
+
+	for _, complianceType := range []string{"", "CIS", "Association", "Custom:", "Custom:CIS benchmark"} {
+		report := cisReport()
+		report.ComplianceType = complianceType
+		assert.Error(t, ValidateCustomComplianceReport(report), complianceType)
+	}
+
+	report := cisReport()
+	report.Items[1].Id = ""
+	assert.Error(t, ValidateCustomComplianceReport(report))
+
+	report = cisReport()
+	report.Items[1].Id = report.Items[0].Id
+	assert.Error(t, ValidateCustomComplianceReport(report))
+
+	report = cisReport()
+	report.Items[2].Status = "FAILED"
+	assert.Error(t, ValidateCustomComplianceReport(report))
+
+	report = cisReport()
+	report.Items[3].Severity = "SEVERE"
+	assert.Error(t, ValidateCustomComplianceReport(report))
+
+	report = CustomComplianceReport{ComplianceType: "Custom:CIS"}
+	for i := 0; i <= MaxCustomComplianceItems; i++ {
+		report.Items = append(report.Items, CustomComplianceItem{Id: strconv.Itoa(i), Severity: UNSPECIFIED, Status: COMPLIANT})
+	}
+	assert.Error(t, ValidateCustomComplianceReport(report))
+}
+
+func TestSummarizeCustomCompliance(t *testing.T) {
+	summary := SummarizeCustomCompliance(cisReport())
+	assert.Equal(t, "Custom:CIS", summary.ComplianceType)
+	assert.Equal(t, 2, summary.CompliantCount)
+	assert.Equal(t, 2, summary.NonCompliantCount)
+	assert.Equal(t, SeveritySummary{HighCount: 1, UnspecifiedCount: 1}, summary.CompliantSummary)
+	assert.Equal(t, SeveritySummary{CriticalCount: 1, MediumCount: 1}, summary.NonCompliantSummary)
+}
@@ -0,0 +1,215 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package reporter batches the custom compliance items documents report and submits them with PutComplianceItems.
+package reporter
+
+import (
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/association/compliance/model"
+	"github.com/aws/amazon-ssm-agent/agent/export"
+	"github.com/aws/amazon-ssm-agent/agent/fileutil"
+	"github.com/aws/amazon-ssm-agent/agent/jsonutil"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/platform"
+	ssmSvc "github.com/aws/amazon-ssm-agent/agent/ssm"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ssm"
+)
+
+const (
+	// batchDelay coalesces the reports of concurrent documents into a single submission
+	batchDelay = 30 * time.Second
+	// retryDelay is the wait before the reports are submitted again after a failed submission
+	retryDelay = 5 * time.Minute
+)
+
+var (
+	// pendingPath keeps the reports that were not submitted yet across restarts of the agent
+	pendingPath = filepath.Join(appconfig.DefaultDataStorePath, appconfig.ComplianceRootDirName, "custom", "pending.json")
+
+	timeNow    = time.Now
+	afterFunc  = time.AfterFunc
+	instanceID = platform.InstanceID
+	newService = ssmSvc.NewService
+
+	reporterOnce     sync.Once
+	reporterInstance *reporter
+)
+
+// reporter keeps the last report of every custom compliance type until it is submitted
+type reporter struct {
+	lock      sync.Mutex
+	service   ssmSvc.Service
+	pending   map[string]model.CustomComplianceReport
+	scheduled bool
+}
+
+// Report validates the report and queues it for the next submission, the report replaces a pending report of the
+// same compliance type like PutComplianceItems replaces the items of the type. Report returns the summary of the items.
+func Report(log log.T, report model.CustomComplianceReport) (summary model.CustomComplianceSummary, err error) {
+	if err = model.ValidateCustomComplianceReport(report); err != nil {
+		return
+	}
+	reporterOnce.Do(func() {
+		reporterInstance = &reporter{service: newService(), pending: readPending(log)}
+		if len(reporterInstance.pending) > 0 {
+			reporterInstance.schedule(log, batchDelay)
+		}
+	})
+	reporterInstance.queue(log, report)
+	return model.SummarizeCustomCompliance(report), nil
+}
+
+func (r *reporter) queue(log log.T, report model.CustomComplianceReport) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if report.ExecutionTime.IsZero() {
+		report.ExecutionTime = timeNow()
+	}
+	if _, replaced := r.pending[report.ComplianceType]; replaced {
+		log.Infof("%v replaces the pending items of %v", report.ExecutionId, report.ComplianceType)
+	}
+	r.pending[report.ComplianceType] = report
+	r.writePending(log)
+	r.schedule(log, batchDelay)
+}
+
+// schedule submits the pending reports after the delay unless a submission is scheduled already, the caller holds the lock
+func (r *reporter) schedule(log log.T, delay time.Duration) {
+	if r.scheduled {
+		return
+	}
+	r.scheduled = true
+	afterFunc(delay, func() { r.submit(log) })
+}
+
+// submit puts the items of every pending report, the reports that failed are submitted again after retryDelay
+func (r *reporter) submit(log log.T) {
+	r.lock.Lock()
+	r.scheduled = false
+	reports := make([]model.CustomComplianceReport, 0, len(r.pending))
+	for _, report := range r.pending {
+		reports = append(reports, report)
+	}
+	r.lock.Unlock()
+
+	sort.Slice(reports, func(i, j int) bool { return reports[i].ComplianceType < reports[j].ComplianceType })
+	failed := false
+	id, err := instanceID()
+	if err != nil {
+		log.Errorf("failed to submit custom compliance, unable to get the instance id: %v", err)
+		failed = true
+		reports = nil
+	}
+	for _, report := range reports {
+		err := r.put(log, id, report)
+		r.lock.Lock()
+		if err != nil {
+			log.Error(err)
+			failed = true
+		} else if pending := r.pending[report.ComplianceType]; pending.ExecutionTime.Equal(report.ExecutionTime) {
+			// a report queued while this one was submitted stays pending
+			delete(r.pending, report.ComplianceType)
+		}
+		r.lock.Unlock()
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.writePending(log)
+	if failed {
+		log.Warnf("custom compliance is submitted again in %v", retryDelay)
+		r.schedule(log, retryDelay)
+	} else if len(r.pending) > 0 {
+		r.schedule(log, batchDelay)
+	}
+}
+
+func (r *reporter) put(log log.T, instanceID string, report model.CustomComplianceReport) error {
+	items := make([]*ssm.ComplianceItemEntry, len(report.Items))
+	for i, item := range report.Items {
+		items[i] = &ssm.ComplianceItemEntry{
+			Id:       aws.String(item.Id),
+			Title:    aws.String(item.Title),
+			Severity: aws.String(item.Severity),
+			Status:   aws.String(item.Status),
+			Details:  aws.StringMap(item.Details),
+		}
+	}
+	content, err := json.Marshal(report.Items)
+	if err != nil {
+		return err
+	}
+	sum := md5.Sum(content)
+
+	summary := model.SummarizeCustomCompliance(report)
+	log.Infof("submitting %v items of %v reported by %v %v, %v non compliant",
+		len(items), report.ComplianceType, report.ExecutionType, report.ExecutionId, summary.NonCompliantCount)
+	executionTime := report.ExecutionTime
+	if _, err = r.service.PutComplianceItems(
+		log,
+		&executionTime,
+		report.ExecutionType,
+		report.ExecutionId,
+		instanceID,
+		report.ComplianceType,
+		base64.StdEncoding.EncodeToString(sum[:]),
+		items); err != nil {
+		return fmt.Errorf("failed to submit the items of %v: %v", report.ComplianceType, err)
+	}
+	export.Compliance(log, instanceID, export.ComplianceSubmission{
+		ComplianceType: report.ComplianceType,
+		ExecutionId:    report.ExecutionId,
+		ExecutionType:  report.ExecutionType,
+		ExecutionTime:  executionTime,
+		Items:          items,
+	})
+	return nil
+}
+
+// writePending persists the pending reports, the caller holds the lock
+func (r *reporter) writePending(log log.T) {
+	content, err := jsonutil.Marshal(r.pending)
+	if err == nil {
+		if err = fileutil.MakeDirs(filepath.Dir(pendingPath)); err == nil {
+			err = fileutil.WriteAllText(pendingPath, content)
+		}
+	}
+	if err != nil {
+		log.Warnf("failed to persist the pending custom compliance: %v", err)
+	}
+}
+
+// readPending loads the reports a previous run of the agent did not submit
+func readPending(log log.T) map[string]model.CustomComplianceReport {
+	pending := map[string]model.CustomComplianceReport{}
+	if !fileutil.Exists(pendingPath) {
+		return pending
+	}
+	if err := jsonutil.UnmarshalFile(pendingPath, &pending); err != nil {
+		log.Warnf("failed to load the pending custom compliance: %v", err)
+		return map[string]model.CustomComplianceReport{}
+	}
+	return pending
+}
@@ -0,0 +1,118 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package reporter
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/association/compliance/model"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/platform"
+	ssmSvc "github.com/aws/amazon-ssm-agent/agent/ssm"
+	"github.com/aws/aws-sdk-go/service/ssm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// fakeScheduler replaces the clock and timers of the reporter
+type fakeScheduler struct {
+	now    time.Time
+	delays []time.Duration
+	fire   func()
+}
+
+func setupReporter(t *testing.T) (r *reporter, service *ssmSvc.Mock, scheduler *fakeScheduler, cleanup func()) {
+	dir, err := ioutil.TempDir("", "reporter")
+	assert.NoError(t, err)
+	scheduler = &fakeScheduler{now: time.Date(2020, time.June, 6, 10, 0, 0, 0, time.UTC)}
+	defaultPendingPath := pendingPath
+	pendingPath = filepath.Join(dir, "pending.json")
+	timeNow = func() time.Time { return scheduler.now }
+	afterFunc = func(d time.Duration, f func()) *time.Timer {
+		scheduler.delays = append(scheduler.delays, d)
+		scheduler.fire = f
+		return nil
+	}
+	instanceID = func() (string, error) { return "i-1234567890", nil }
+	service = ssmSvc.NewMockDefault()
+	return &reporter{service: service, pending: map[string]model.CustomComplianceReport{}}, service, scheduler, func() {
+		pendingPath = defaultPendingPath
+		timeNow = time.Now
+		afterFunc = time.AfterFunc
+		instanceID = platform.InstanceID
+		os.RemoveAll(dir)
+	}
+}
+
+func newReport(complianceType, executionID string, ids ...string) model.CustomComplianceReport {
+	report := model.CustomComplianceReport{ComplianceType: complianceType, ExecutionType: model.ExecutionTypeAssociation, ExecutionId: executionID}
+	for _, id := range ids {
+		report.Items = append(report.Items, model.CustomComplianceItem{Id: id, Severity: "HIGH", Status: model.NON_COMPLIANT})
+	}
+	return report
+}
+
+func TestReporterBatchesReports(t *testing.T) {
+	r, service, scheduler, cleanup := setupReporter(t)
+	defer cleanup()
+	logger := log.NewMockLog()
+
+	r.queue(logger, newReport("Custom:CIS", "association-1", "1.1.1"))
+	r.queue(logger, newReport("Custom:Hardening", "association-2", "ssh"))
+	r.queue(logger, newReport("Custom:CIS", "association-1", "1.1.1", "1.1.2"))
+	assert.Equal(t, []time.Duration{batchDelay}, scheduler.delays, "the reports share a single submission")
+	assert.Len(t, readPending(logger), 2, "the pending reports survive a restart")
+
+	itemCount := func(count int) interface{} {
+		return mock.MatchedBy(func(items []*ssm.ComplianceItemEntry) bool { return len(items) == count })
+	}
+	service.On("PutComplianceItems", logger, mock.Anything, model.ExecutionTypeAssociation, "association-1", "i-1234567890", "Custom:CIS", mock.Anything, itemCount(2)).
+		Return(&ssm.PutComplianceItemsOutput{}, nil).Once()
+	service.On("PutComplianceItems", logger, mock.Anything, model.ExecutionTypeAssociation, "association-2", "i-1234567890", "Custom:Hardening", mock.Anything, itemCount(1)).
+		Return(&ssm.PutComplianceItemsOutput{}, nil).Once()
+	scheduler.fire()
+	service.AssertExpectations(t)
+	assert.Empty(t, r.pending)
+	assert.Empty(t, readPending(logger))
+	assert.Len(t, scheduler.delays, 1)
+}
+
+func TestReporterRetriesFailedSubmissions(t *testing.T) {
+	r, service, scheduler, cleanup := setupReporter(t)
+	defer cleanup()
+	logger := log.NewMockLog()
+
+	r.queue(logger, newReport("Custom:CIS", "association-1", "1.1.1"))
+	service.On("PutComplianceItems", logger, mock.Anything, mock.Anything, mock.Anything, mock.Anything, "Custom:CIS", mock.Anything, mock.Anything).
+		Return(&ssm.PutComplianceItemsOutput{}, errors.New("AccessDeniedException")).Once()
+	scheduler.fire()
+	assert.Equal(t, []time.Duration{batchDelay, retryDelay}, scheduler.delays)
+	assert.Len(t, r.pending, 1)
+
+	service.On("PutComplianceItems", logger, mock.Anything, mock.Anything, mock.Anything, mock.Anything, "Custom:CIS", mock.Anything, mock.Anything).
+		Return(&ssm.PutComplianceItemsOutput{}, nil).Once()
+	scheduler.fire()
+	assert.Empty(t, r.pending)
+	service.AssertExpectations(t)
+}
+
+func TestReportRejectsInvalidReports(t *testing.T) {
+	_, err := Report(log.NewMockLog(), newReport("CIS", "association-1", "1.1.1"))
+	assert.Error(t, err)
+}
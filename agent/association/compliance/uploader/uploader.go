@@ -26,6 +26,7 @@ import (
 	"github.com/aws/amazon-ssm-agent/agent/association/compliance/model"
 	"github.com/aws/amazon-ssm-agent/agent/context"
 	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/export"
 	"github.com/aws/amazon-ssm-agent/agent/log"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/inventory/datauploader"
 	"github.com/aws/amazon-ssm-agent/agent/sdkutil"
@@ -145,6 +146,19 @@ func (u *ComplianceUploader) UpdateAssociationCompliance(associationID string, i
 		u.optimizer.UpdateContentHash(AssociationComplianceItemName, itemContentHash)
 	}
 
+	if export.Enabled() {
+		// the items are only sent when they changed, the export always carries them
+		exportItems := newComplianceItems
+		if itemContentHash == oldHash {
+			exportItems, _, _ = u.ConvertToSsmAssociationComplianceItems(log, associationComplianceEntries, "")
+		}
+		export.Compliance(log, instanceID, export.ComplianceSubmission{
+			ComplianceType: associationComplianceType,
+			ExecutionTime:  executionTime,
+			Items:          exportItems,
+		})
+	}
+
 	log.Debugf("Put compliance item %v return response %v", newComplianceItems, response)
 	return nil
 }
@@ -98,11 +98,17 @@ func InitializeDocumentState(context context.T,
 	}
 
 	docContent := &docparser.DocContent{
-		SchemaVersion: payload.DocumentContent.SchemaVersion,
-		Description:   payload.DocumentContent.Description,
-		RuntimeConfig: payload.DocumentContent.RuntimeConfig,
-		MainSteps:     payload.DocumentContent.MainSteps,
-		Parameters:    payload.DocumentContent.Parameters,
+		SchemaVersion:    payload.DocumentContent.SchemaVersion,
+		Description:      payload.DocumentContent.Description,
+		RuntimeConfig:    payload.DocumentContent.RuntimeConfig,
+		MainSteps:        payload.DocumentContent.MainSteps,
+		Parameters:       payload.DocumentContent.Parameters,
+		Variables:        payload.DocumentContent.Variables,
+		ExecutionContext: payload.DocumentContent.ExecutionContext,
+		OutputS3Settings: payload.DocumentContent.OutputS3Settings,
+		Priority:         payload.DocumentContent.Priority,
+		SchedulingClass:  payload.DocumentContent.SchedulingClass,
+		MaxReboots:       payload.DocumentContent.MaxReboots,
 	}
 	return docparser.InitializeDocState(context.Log(), contracts.Association, docContent, documentInfo, parserInfo, payload.Parameters)
 }
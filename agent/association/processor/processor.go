@@ -23,6 +23,8 @@ import (
 	"time"
 
 	"github.com/aws/amazon-ssm-agent/agent/association/cache"
+	complianceModel "github.com/aws/amazon-ssm-agent/agent/association/compliance/model"
+	complianceReporter "github.com/aws/amazon-ssm-agent/agent/association/compliance/reporter"
 	complianceUploader "github.com/aws/amazon-ssm-agent/agent/association/compliance/uploader"
 	"github.com/aws/amazon-ssm-agent/agent/association/frequentcollector"
 	"github.com/aws/amazon-ssm-agent/agent/association/model"
@@ -33,11 +35,13 @@ import (
 	"github.com/aws/amazon-ssm-agent/agent/context"
 	"github.com/aws/amazon-ssm-agent/agent/contracts"
 	"github.com/aws/amazon-ssm-agent/agent/framework/processor"
+	"github.com/aws/amazon-ssm-agent/agent/ipc/localapi"
 	"github.com/aws/amazon-ssm-agent/agent/jsonutil"
 	"github.com/aws/amazon-ssm-agent/agent/log"
 	"github.com/aws/amazon-ssm-agent/agent/platform"
+	"github.com/aws/amazon-ssm-agent/agent/schedule"
 	"github.com/aws/amazon-ssm-agent/agent/times"
-	"github.com/carlescere/scheduler"
+	"github.com/aws/amazon-ssm-agent/agent/watchdog"
 )
 
 const (
@@ -51,7 +55,7 @@ const (
 
 // Processor contains the logic for processing association
 type Processor struct {
-	pollJob            *scheduler.Job
+	pollJob            *schedule.Job
 	assocSvc           service.T
 	complianceUploader complianceUploader.T
 	context            context.T
@@ -63,6 +67,9 @@ type Processor struct {
 
 var lock sync.RWMutex
 
+// reportCompliance queues custom compliance items for PutComplianceItems
+var reportCompliance = complianceReporter.Report
+
 // NewAssociationProcessor returns a new Processor with the given context.
 func NewAssociationProcessor(context context.T) *Processor {
 	assocContext := context.With("[" + name + "]")
@@ -99,7 +106,7 @@ func (p *Processor) ModuleExecute(context context.T) {
 	associationFrequenceMinutes := context.AppConfig().Ssm.AssociationFrequencyMinutes
 	log.Info("Starting association polling")
 	log.Debugf("Association polling frequency is %v", associationFrequenceMinutes)
-	var job *scheduler.Job
+	var job *schedule.Job
 	var err error
 	if job, err = assocScheduler.CreateScheduler(
 		log,
@@ -109,8 +116,54 @@ func (p *Processor) ModuleExecute(context context.T) {
 	}
 	p.InitializeAssociationProcessor()
 	p.SetPollJob(job)
+	watchdog.Register(name, time.Duration(associationFrequenceMinutes)*time.Minute, p.restartPolling)
+	localapi.RegisterAction(localapi.VerbRefreshAssociation, p.refreshFromLocalApi)
+	localapi.RegisterRequestAction(localapi.VerbPutComplianceItems, p.putComplianceFromLocalApi)
 }
+
+// refreshFromLocalApi lists the associations and runs those that are due when a local api client asks for it
+func (p *Processor) refreshFromLocalApi(log log.T) error {
+	go p.ProcessAssociation()
+	return nil
+}
+
+// putComplianceFromLocalApi queues the custom compliance items a local api client reports,
+// items linked to an association are only accepted for an association of the instance
+func (p *Processor) putComplianceFromLocalApi(log log.T, req localapi.Request) localapi.Response {
+	if req.Compliance == nil {
+		return localapi.Response{Error: "compliance is missing from the request"}
+	}
+	report := *req.Compliance
+	if report.ExecutionType == complianceModel.ExecutionTypeAssociation && !schedulemanager.AssociationExists(report.ExecutionId) {
+		return localapi.Response{Error: fmt.Sprintf("association %v is not associated with this instance", report.ExecutionId)}
+	}
+	summary, err := reportCompliance(log, report)
+	if err != nil {
+		return localapi.Response{Error: err.Error()}
+	}
+	return localapi.Response{
+		Message:           fmt.Sprintf("%v items of %v queued", len(report.Items), report.ComplianceType),
+		ComplianceSummary: &summary,
+	}
+}
+
+// restartPolling is the restart of the watchdog when the association polling stalls,
+// the new poll job runs next to the stalled run
+func (p *Processor) restartPolling(log log.T) {
+	log.Warn("Restarting association polling")
+	assocScheduler.Stop(p.pollJob)
+	job, err := assocScheduler.CreateScheduler(log, p.ProcessAssociation, p.context.AppConfig().Ssm.AssociationFrequencyMinutes)
+	if err != nil {
+		log.Errorf("unable to schedule association processor. %v", err)
+		return
+	}
+	p.SetPollJob(job)
+}
+
 func (p *Processor) ModuleRequestStop(stopType contracts.StopType) (err error) {
+	watchdog.Unregister(name)
+	localapi.RegisterAction(localapi.VerbRefreshAssociation, nil)
+	localapi.RegisterRequestAction(localapi.VerbPutComplianceItems, nil)
 	assocScheduler.Stop(p.pollJob)
 	signal.Stop()
 	p.proc.Stop(stopType)
@@ -141,7 +194,7 @@ func (p *Processor) InitializeAssociationProcessor() {
 }
 
 // SetPollJob represents setter for PollJob
-func (p *Processor) SetPollJob(job *scheduler.Job) {
+func (p *Processor) SetPollJob(job *schedule.Job) {
 	p.pollJob = job
 }
 
@@ -151,6 +204,8 @@ func (p *Processor) ProcessAssociation() {
 	associations := []*model.InstanceAssociation{}
 
 	log.Debug("running ProcessAssociation")
+	// a run that returns is progress for the watchdog
+	defer watchdog.Beat(name)
 
 	instanceID, err := sys.InstanceID()
 	if err != nil {
@@ -19,6 +19,7 @@ import (
 	"time"
 
 	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/schedule"
 )
 
 const (
@@ -38,7 +39,7 @@ var instance = AssociationExecutionSignal{
 	stopSignal:    make(chan bool, 1),
 }
 
-var schedulerHealthTimer = time.NewTicker(defaultScheduleHealthTimerDurationSeconds * time.Second)
+var schedulerHealthJob *schedule.Job
 var waitTimerForNextScheduledAssociation *time.Timer
 var nextScheduledDate time.Time
 
@@ -67,14 +68,11 @@ func InitializeAssociationSignalService(log log.T, task func(log log.T)) {
 		}
 	}()
 
-	go func() {
-		for {
-			select {
-			case <-schedulerHealthTimer.C:
-				ExecuteAssociation(log)
-			}
-		}
-	}()
+	if schedulerHealthJob == nil {
+		schedulerHealthJob = schedule.Every(defaultScheduleHealthTimerDurationSeconds*time.Second, func() {
+			ExecuteAssociation(log)
+		})
+	}
 }
 
 // ResetWaitTimerForNextScheduledAssociation stops old wait timer and creates new one with updated target date
@@ -99,15 +97,12 @@ func ResetWaitTimerForNextScheduledAssociation(log log.T, targetDate time.Time)
 		waitTimerForNextScheduledAssociation.Stop()
 	}
 
-	waitTimerForNextScheduledAssociation = time.NewTimer(duration)
+	waitTimerForNextScheduledAssociation = time.AfterFunc(duration, func() {
+		ExecuteAssociation(log)
+	})
 	nextScheduledDate = targetDate
 
 	log.Infof(scheduleForNextAssociationMessage, targetDate, duration)
-	go func() {
-		<-waitTimerForNextScheduledAssociation.C
-		ExecuteAssociation(log)
-	}()
-
 }
 
 // StopWaitTimerForNextScheduledAssociation stops the timer so it will not get triggered and send signal for the next scheduled association
@@ -138,7 +133,10 @@ func Stop() {
 	if waitTimerForNextScheduledAssociation != nil {
 		waitTimerForNextScheduledAssociation.Stop()
 	}
-	schedulerHealthTimer.Stop()
+	if schedulerHealthJob != nil {
+		schedulerHealthJob.Stop()
+		schedulerHealthJob = nil
+	}
 }
 
 // StopExecutionSignal stops the signal channel, which stops the association execution
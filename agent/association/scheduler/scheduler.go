@@ -19,7 +19,7 @@ import (
 	"time"
 
 	"github.com/aws/amazon-ssm-agent/agent/log"
-	"github.com/carlescere/scheduler"
+	"github.com/aws/amazon-ssm-agent/agent/schedule"
 )
 
 const (
@@ -27,24 +27,26 @@ const (
 )
 
 // CreateScheduler runs a given poll job every pollFrequencyMinutes
-func CreateScheduler(log log.T, task func(), frequencyInMinutes int) (job *scheduler.Job, err error) {
-	if job, err = scheduler.Every(frequencyInMinutes).Minutes().Run(func() {
-		loop(task, log, job)
-	}); err != nil {
-		return nil, log.Errorf("unable to create scheduler, %v", err)
+func CreateScheduler(log log.T, task func(), frequencyInMinutes int) (job *schedule.Job, err error) {
+	if frequencyInMinutes <= 0 {
+		return nil, log.Errorf("unable to create scheduler, invalid frequency %v", frequencyInMinutes)
 	}
+	job = schedule.Every(time.Duration(frequencyInMinutes)*time.Minute, func() {
+		loop(task, log)
+	})
+	job.RunNow()
 	return job, nil
 }
 
 // Stop stops the scheduler.
-func Stop(job *scheduler.Job) {
+func Stop(job *schedule.Job) {
 	if job != nil {
-		job.Quit <- true
+		job.Stop()
 	}
 }
 
 // loop executes the task provided when creates scheduler
-func loop(task func(), log log.T, job *scheduler.Job) {
+func loop(task func(), log log.T) {
 	// time lock to only have one loop active anytime.
 	// this is extra insurance to prevent any race condition
 	taskStartTime := time.Now()
@@ -55,8 +57,8 @@ func loop(task func(), log log.T, job *scheduler.Job) {
 }
 
 // ScheduleNextRun skips waiting and schedule next run immediately
-func ScheduleNextRun(j *scheduler.Job) {
-	j.SkipWait <- true
+func ScheduleNextRun(j *schedule.Job) {
+	j.RunNow()
 }
 
 var sleepMilli = func(pollStartTime time.Time, sleepDurationInMilliseconds int) {
@@ -16,6 +16,7 @@ package service
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"sync"
 	"time"
@@ -26,6 +27,7 @@ import (
 	"github.com/aws/amazon-ssm-agent/agent/association/schedulemanager"
 	"github.com/aws/amazon-ssm-agent/agent/jsonutil"
 	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/outbox"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/pluginutil"
 	"github.com/aws/amazon-ssm-agent/agent/sdkutil"
 	ssmsvc "github.com/aws/amazon-ssm-agent/agent/ssm"
@@ -95,10 +97,28 @@ func NewAssociationService(name string) *AssociationService {
 		stopPolicy: policy,
 		name:       name,
 	}
+	outbox.RegisterSender(outbox.KindAssociationStatus, svc.sendQueuedStatus)
 
 	return &svc
 }
 
+// queuedAssociationStatus is an association execution result queued while SSM was unreachable
+type queuedAssociationStatus struct {
+	AssociationID   string
+	InstanceID      string
+	ExecutionResult ssm.InstanceAssociationExecutionResult
+}
+
+// sendQueuedStatus sends an association execution result queued while SSM was unreachable
+func (s *AssociationService) sendQueuedStatus(log log.T, payload []byte) error {
+	var status queuedAssociationStatus
+	if err := json.Unmarshal(payload, &status); err != nil {
+		return err
+	}
+	_, err := s.ssmSvc.UpdateInstanceAssociationStatus(log, status.AssociationID, status.InstanceID, &status.ExecutionResult)
+	return err
+}
+
 // CreateNewServiceIfUnHealthy checks service healthy and create new service if original is unhealthy
 func (s *AssociationService) CreateNewServiceIfUnHealthy(log log.T) {
 	if s.stopPolicy == nil {
@@ -249,7 +269,12 @@ func (s *AssociationService) UpdateInstanceAssociationStatus(
 		log.Info("Updating association status ", jsonutil.Indent(executionResultContent))
 
 		var response *ssm.UpdateInstanceAssociationStatusOutput
-		if response, err = s.ssmSvc.UpdateInstanceAssociationStatus(log, associationID, instanceID, &executionResult); err != nil {
+		var queued bool
+		queuedStatus := queuedAssociationStatus{AssociationID: associationID, InstanceID: instanceID, ExecutionResult: executionResult}
+		if queued, err = outbox.Send(log, outbox.KindAssociationStatus, queuedStatus, func() (err error) {
+			response, err = s.ssmSvc.UpdateInstanceAssociationStatus(log, associationID, instanceID, &executionResult)
+			return err
+		}); err != nil {
 			log.Errorf("unable to update association status, %v", err)
 
 			// After machine reboot system turn back to use UpdateInstanceAssociationStatus for legacy association
@@ -264,6 +289,10 @@ func (s *AssociationService) UpdateInstanceAssociationStatus(
 
 			return
 		}
+		if queued {
+			log.Infof("Queued association %v status until the service can be reached", associationID)
+			return
+		}
 
 		var responseContent string
 		if responseContent, err = jsonutil.Marshal(response); err != nil {
@@ -0,0 +1,362 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package attestation emits a provenance attestation of every document the agent ran, an in-toto statement with a
+// SLSA provenance predicate listing the document hash, the plugin versions, the digests of the artifacts the
+// plugins downloaded and the parameters with their values redacted. The statements are written to the sinks of
+// the Attestation configuration.
+package attestation
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/context"
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/version"
+)
+
+const (
+	// StatementType is the in-toto statement type of the attestations
+	StatementType = "https://in-toto.io/Statement/v0.1"
+
+	// PredicateType is the SLSA provenance predicate type of the attestations
+	PredicateType = "https://slsa.dev/provenance/v0.2"
+
+	// BuilderID identifies the agent as the builder of the provenance
+	BuilderID = "https://github.com/aws/amazon-ssm-agent"
+
+	// BuildType identifies the execution of a document as the build of the provenance
+	BuildType = "https://github.com/aws/amazon-ssm-agent/DocumentExecution@v1"
+
+	// artifactsFileName is the file of the orchestration directory the plugins record their artifacts in,
+	// the plugins run in their own process so the artifacts are passed on through the file system
+	artifactsFileName = "attestationArtifacts.jsonl"
+
+	// attestationsDirName is the directory of the file sink when LocalDirectory is not configured
+	attestationsDirName = "attestations"
+
+	// digestAlgorithm is the algorithm of every digest of the attestations
+	digestAlgorithm = "sha256"
+)
+
+// Statement is an in-toto statement about the document that ran
+type Statement struct {
+	Type          string     `json:"_type"`
+	PredicateType string     `json:"predicateType"`
+	Subject       []Subject  `json:"subject"`
+	Predicate     Provenance `json:"predicate"`
+}
+
+// Subject is the document the statement is about
+type Subject struct {
+	Name   string            `json:"name"`
+	Digest map[string]string `json:"digest"`
+}
+
+// Provenance is the SLSA provenance of the execution of the document
+type Provenance struct {
+	Builder    Builder    `json:"builder"`
+	BuildType  string     `json:"buildType"`
+	Invocation Invocation `json:"invocation"`
+	Metadata   Metadata   `json:"metadata"`
+	Plugins    []Plugin   `json:"plugins"`
+	Materials  []Material `json:"materials,omitempty"`
+}
+
+// Builder identifies the agent that ran the document
+type Builder struct {
+	ID      string `json:"id"`
+	Version string `json:"version"`
+}
+
+// Invocation describes how the document was run, the parameter values are redacted
+type Invocation struct {
+	Parameters  map[string]string `json:"parameters,omitempty"`
+	Environment Environment       `json:"environment"`
+}
+
+// Environment identifies the instance and the request the document ran for
+type Environment struct {
+	InstanceID      string `json:"instanceId"`
+	DocumentID      string `json:"documentId"`
+	DocumentVersion string `json:"documentVersion,omitempty"`
+	CommandID       string `json:"commandId,omitempty"`
+	AssociationID   string `json:"associationId,omitempty"`
+}
+
+// Metadata is the outcome of the execution
+type Metadata struct {
+	BuildStartedOn  *time.Time             `json:"buildStartedOn,omitempty"`
+	BuildFinishedOn *time.Time             `json:"buildFinishedOn,omitempty"`
+	Status          contracts.ResultStatus `json:"status"`
+}
+
+// Plugin is a step of the document and the version of the plugin that ran it. Digest is the digest of the
+// executable of external plugins, the built-in plugins have the version of the agent.
+type Plugin struct {
+	StepName string                 `json:"stepName"`
+	Name     string                 `json:"name"`
+	Version  string                 `json:"version"`
+	Digest   map[string]string      `json:"digest,omitempty"`
+	Status   contracts.ResultStatus `json:"status,omitempty"`
+}
+
+// Material is an artifact a step downloaded
+type Material struct {
+	URI    string            `json:"uri"`
+	Digest map[string]string `json:"digest"`
+}
+
+// Artifact is a file a step downloaded, Source is where it was downloaded from
+type Artifact struct {
+	StepName string
+	Source   string
+	Path     string
+	SHA256   string
+}
+
+// Sink writes the attestation of a document, name is unique per document and instance
+type Sink func(context context.T, config appconfig.AttestationCfg, instanceID string, name string, statement []byte) error
+
+var (
+	sinksLock sync.RWMutex
+	sinks     = map[string]Sink{
+		appconfig.AttestationSinkFile: writeFile,
+		appconfig.AttestationSinkS3:   uploadToS3,
+	}
+
+	// artifactsLock serializes the steps of a document recording artifacts in the same process
+	artifactsLock sync.Mutex
+
+	getAppConfig = appconfig.Config
+)
+
+// RegisterSink sets the sink of the name, a nil sink removes it
+func RegisterSink(name string, sink Sink) {
+	sinksLock.Lock()
+	defer sinksLock.Unlock()
+	if sink == nil {
+		delete(sinks, name)
+		return
+	}
+	sinks[name] = sink
+}
+
+// Enabled returns whether attestations are emitted
+func Enabled() bool {
+	config, err := getAppConfig(false)
+	return err == nil && config.Attestation.Enabled
+}
+
+// RecordArtifacts records the digests of the files a step downloaded from source, directories are walked and
+// the path of their files is appended to the source as a fragment.
+// They are written to the orchestration directory of the document and listed as materials of its attestation.
+func RecordArtifacts(log log.T, orchestrationDir string, stepName string, source string, paths []string) {
+	if !Enabled() || orchestrationDir == "" {
+		return
+	}
+	var artifacts []Artifact
+	for _, root := range paths {
+		err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+			if err != nil || info.IsDir() {
+				return err
+			}
+			digest, err := fileDigest(path)
+			if err != nil {
+				return err
+			}
+			artifactSource := source
+			if rel, relErr := filepath.Rel(root, path); relErr == nil && rel != "." {
+				artifactSource += "#" + filepath.ToSlash(rel)
+			}
+			artifacts = append(artifacts, Artifact{StepName: stepName, Source: artifactSource, Path: path, SHA256: digest})
+			return nil
+		})
+		if err != nil {
+			log.Warnf("failed to compute the digest of artifact %v: %v", root, err)
+		}
+	}
+	if len(artifacts) == 0 {
+		return
+	}
+
+	artifactsLock.Lock()
+	defer artifactsLock.Unlock()
+	file, err := os.OpenFile(filepath.Join(orchestrationDir, artifactsFileName), os.O_APPEND|os.O_CREATE|os.O_WRONLY, appconfig.ReadWriteAccess)
+	if err != nil {
+		log.Warnf("failed to record the artifacts of step %v: %v", stepName, err)
+		return
+	}
+	defer file.Close()
+	encoder := json.NewEncoder(file)
+	for _, artifact := range artifacts {
+		if err = encoder.Encode(artifact); err != nil {
+			log.Warnf("failed to record the artifacts of step %v: %v", stepName, err)
+			return
+		}
+	}
+}
+
+// Emit writes the attestation of the document to the configured sinks, result is the final result of the document
+func Emit(context context.T, docState *contracts.DocumentState, result contracts.DocumentResult) {
+	log := context.Log()
+	config := context.AppConfig()
+	if !config.Attestation.Enabled {
+		return
+	}
+
+	statement := NewStatement(log, config, docState, result)
+	content, err := json.MarshalIndent(statement, "", "  ")
+	if err != nil {
+		log.Errorf("failed to encode the attestation of document %v: %v", docState.DocumentInformation.DocumentID, err)
+		return
+	}
+	name := docState.DocumentInformation.DocumentID + ".intoto.json"
+	for _, sinkName := range config.Attestation.Sinks {
+		sinksLock.RLock()
+		sink := sinks[sinkName]
+		sinksLock.RUnlock()
+		if sink == nil {
+			log.Warnf("no attestation sink named %v", sinkName)
+			continue
+		}
+		if err = sink(context, config.Attestation, docState.DocumentInformation.InstanceID, name, content); err != nil {
+			log.Errorf("failed to write the attestation of document %v to %v: %v", docState.DocumentInformation.DocumentID, sinkName, err)
+		}
+	}
+}
+
+// NewStatement returns the attestation of the document
+func NewStatement(log log.T, config appconfig.SsmagentConfig, docState *contracts.DocumentState, result contracts.DocumentResult) Statement {
+	docInfo := docState.DocumentInformation
+	statement := Statement{
+		Type:          StatementType,
+		PredicateType: PredicateType,
+		Subject: []Subject{{
+			Name:   docInfo.DocumentName,
+			Digest: map[string]string{digestAlgorithm: docInfo.DocumentHash},
+		}},
+		Predicate: Provenance{
+			Builder:   Builder{ID: BuilderID, Version: version.Version},
+			BuildType: BuildType,
+			Invocation: Invocation{
+				Parameters: docInfo.RedactedParameters,
+				Environment: Environment{
+					InstanceID:      docInfo.InstanceID,
+					DocumentID:      docInfo.DocumentID,
+					DocumentVersion: docInfo.DocumentVersion,
+					CommandID:       docInfo.CommandID,
+					AssociationID:   docInfo.AssociationID,
+				},
+			},
+			Metadata: Metadata{Status: result.Status},
+		},
+	}
+
+	externalPlugins := make(map[string]appconfig.ExternalPluginCfg)
+	for _, plugin := range config.ExternalPlugins {
+		externalPlugins[plugin.Name] = plugin
+	}
+	for _, pluginState := range docState.InstancePluginsInformation {
+		plugin := Plugin{StepName: pluginState.Id, Name: pluginState.Name, Version: version.Version}
+		if external, ok := externalPlugins[pluginState.Name]; ok {
+			plugin.Version = ""
+			if digest, err := fileDigest(external.Path); err != nil {
+				log.Warnf("failed to compute the digest of plugin %v: %v", external.Path, err)
+			} else {
+				plugin.Digest = map[string]string{digestAlgorithm: digest}
+			}
+		}
+		if pluginResult, ok := result.PluginResults[pluginState.Id]; ok && pluginResult != nil {
+			plugin.Status = pluginResult.Status
+			statement.Predicate.Metadata.extend(pluginResult.StartDateTime, pluginResult.EndDateTime)
+		}
+		statement.Predicate.Plugins = append(statement.Predicate.Plugins, plugin)
+	}
+
+	for _, artifact := range readArtifacts(log, docState.IOConfig.OrchestrationDirectory) {
+		statement.Predicate.Materials = append(statement.Predicate.Materials, Material{
+			URI:    artifact.Source,
+			Digest: map[string]string{digestAlgorithm: artifact.SHA256},
+		})
+	}
+	return statement
+}
+
+// extend widens the start and end of the execution to include a step
+func (m *Metadata) extend(start time.Time, end time.Time) {
+	if !start.IsZero() && (m.BuildStartedOn == nil || start.Before(*m.BuildStartedOn)) {
+		m.BuildStartedOn = &start
+	}
+	if !end.IsZero() && (m.BuildFinishedOn == nil || end.After(*m.BuildFinishedOn)) {
+		m.BuildFinishedOn = &end
+	}
+}
+
+// readArtifacts returns the artifacts recorded in the orchestration directory, sorted by source and path
+func readArtifacts(log log.T, orchestrationDir string) (artifacts []Artifact) {
+	if orchestrationDir == "" {
+		return nil
+	}
+	file, err := os.Open(filepath.Join(orchestrationDir, artifactsFileName))
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Warnf("failed to read the recorded artifacts: %v", err)
+		}
+		return nil
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var artifact Artifact
+		if err = json.Unmarshal(scanner.Bytes(), &artifact); err != nil {
+			log.Warnf("ignoring malformed recorded artifact: %v", err)
+			continue
+		}
+		artifacts = append(artifacts, artifact)
+	}
+	sort.SliceStable(artifacts, func(i, j int) bool {
+		if artifacts[i].Source != artifacts[j].Source {
+			return artifacts[i].Source < artifacts[j].Source
+		}
+		return artifacts[i].Path < artifacts[j].Path
+	})
+	return artifacts
+}
+
+// fileDigest returns the hex encoded sha256 of the file
+func fileDigest(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	hash := sha256.New()
+	if _, err = io.Copy(hash, file); err != nil {
+		return "", fmt.Errorf("failed to read %v: %v", path, err)
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
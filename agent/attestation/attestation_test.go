@@ -0,0 +1,162 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package attestation
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/context"
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/version"
+	"github.com/stretchr/testify/assert"
+)
+
+var logMock = log.NewMockLog()
+
+// enableAttestation configures the attestation and returns a context with that configuration
+func enableAttestation(t *testing.T, attestationCfg appconfig.AttestationCfg) (context.T, func()) {
+	config := appconfig.DefaultConfig()
+	config.Attestation = attestationCfg
+	pluginPath := writeTempFile(t, "plugin")
+	config.ExternalPlugins = []appconfig.ExternalPluginCfg{{Name: "custom:plugin", Path: pluginPath}}
+
+	originalConfig := getAppConfig
+	getAppConfig = func(bool) (appconfig.SsmagentConfig, error) { return config, nil }
+
+	ctx := new(context.Mock)
+	ctx.On("Log").Return(logMock)
+	ctx.On("AppConfig").Return(config)
+	return ctx, func() {
+		getAppConfig = originalConfig
+		os.Remove(pluginPath)
+	}
+}
+
+func writeTempFile(t *testing.T, content string) string {
+	file, err := ioutil.TempFile("", "attestation_test")
+	assert.NoError(t, err)
+	defer file.Close()
+	_, err = file.WriteString(content)
+	assert.NoError(t, err)
+	return file.Name()
+}
+
+func testDocState(orchestrationDir string) *contracts.DocumentState {
+	return &contracts.DocumentState{
+		DocumentInformation: contracts.DocumentInfo{
+			DocumentID:         "command-1",
+			CommandID:          "command-1",
+			InstanceID:         "i-1234",
+			DocumentName:       "AWS-RunShellScript",
+			DocumentHash:       "abcd",
+			RedactedParameters: map[string]string{"commands": "****"},
+		},
+		IOConfig: contracts.IOConfiguration{OrchestrationDirectory: orchestrationDir},
+		InstancePluginsInformation: []contracts.PluginState{
+			{Id: "download", Name: "aws:downloadContent"},
+			{Id: "run", Name: "custom:plugin"},
+		},
+	}
+}
+
+func TestNewStatement(t *testing.T) {
+	orchestrationDir, _ := ioutil.TempDir("", "attestation_test")
+	defer os.RemoveAll(orchestrationDir)
+	ctx, restore := enableAttestation(t, appconfig.AttestationCfg{Enabled: true})
+	defer restore()
+
+	downloadDir := filepath.Join(orchestrationDir, "downloads")
+	assert.NoError(t, os.MkdirAll(filepath.Join(downloadDir, "sub"), 0700))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(downloadDir, "sub", "script.sh"), []byte("echo"), 0600))
+	RecordArtifacts(logMock, orchestrationDir, "download", "S3:bucket/scripts", []string{downloadDir})
+
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	result := contracts.DocumentResult{
+		Status: contracts.ResultStatusSuccess,
+		PluginResults: map[string]*contracts.PluginResult{
+			"download": {Status: contracts.ResultStatusSuccess, StartDateTime: start, EndDateTime: start.Add(time.Second)},
+			"run":      {Status: contracts.ResultStatusSuccess, StartDateTime: start.Add(time.Second), EndDateTime: start.Add(time.Minute)},
+		},
+	}
+	statement := NewStatement(logMock, ctx.AppConfig(), testDocState(orchestrationDir), result)
+
+	assert.Equal(t, StatementType, statement.Type)
+	assert.Equal(t, []Subject{{Name: "AWS-RunShellScript", Digest: map[string]string{"sha256": "abcd"}}}, statement.Subject)
+	assert.Equal(t, map[string]string{"commands": "****"}, statement.Predicate.Invocation.Parameters)
+	assert.Equal(t, start, *statement.Predicate.Metadata.BuildStartedOn)
+	assert.Equal(t, start.Add(time.Minute), *statement.Predicate.Metadata.BuildFinishedOn)
+
+	assert.Equal(t, 2, len(statement.Predicate.Plugins))
+	assert.Equal(t, version.Version, statement.Predicate.Plugins[0].Version)
+	assert.Equal(t, "", statement.Predicate.Plugins[1].Version)
+	// sha256 of "plugin"
+	assert.Equal(t, map[string]string{"sha256": "5e689e2b01672bf33996e75d5e372ff60c536ce1599a1458e867cd8f4bef5160"}, statement.Predicate.Plugins[1].Digest)
+
+	// sha256 of "echo"
+	assert.Equal(t, []Material{{
+		URI:    "S3:bucket/scripts#sub/script.sh",
+		Digest: map[string]string{"sha256": "092c79e8f80e559e404bcf660c48f3522b67aba9ff1484b0367e1a4ddef7431d"},
+	}}, statement.Predicate.Materials)
+}
+
+func TestRecordArtifacts_Disabled(t *testing.T) {
+	orchestrationDir, _ := ioutil.TempDir("", "attestation_test")
+	defer os.RemoveAll(orchestrationDir)
+	_, restore := enableAttestation(t, appconfig.AttestationCfg{})
+	defer restore()
+
+	file := writeTempFile(t, "content")
+	defer os.Remove(file)
+	RecordArtifacts(logMock, orchestrationDir, "download", "source", []string{file})
+
+	assert.Empty(t, readArtifacts(logMock, orchestrationDir))
+}
+
+func TestEmit(t *testing.T) {
+	localDir, _ := ioutil.TempDir("", "attestation_test")
+	defer os.RemoveAll(localDir)
+	ctx, restore := enableAttestation(t, appconfig.AttestationCfg{
+		Enabled:        true,
+		Sinks:          []string{appconfig.AttestationSinkFile, "capture"},
+		LocalDirectory: localDir,
+	})
+	defer restore()
+
+	var captured []byte
+	RegisterSink("capture", func(context context.T, config appconfig.AttestationCfg, instanceID string, name string, statement []byte) error {
+		assert.Equal(t, "i-1234", instanceID)
+		assert.Equal(t, "command-1.intoto.json", name)
+		captured = statement
+		return nil
+	})
+	defer RegisterSink("capture", nil)
+
+	Emit(ctx, testDocState(""), contracts.DocumentResult{Status: contracts.ResultStatusFailed})
+
+	written, err := ioutil.ReadFile(filepath.Join(localDir, "command-1.intoto.json"))
+	assert.NoError(t, err)
+	assert.Equal(t, written, captured)
+
+	var statement Statement
+	assert.NoError(t, json.Unmarshal(written, &statement))
+	assert.Equal(t, PredicateType, statement.PredicateType)
+	assert.Equal(t, contracts.ResultStatusFailed, statement.Predicate.Metadata.Status)
+}
@@ -0,0 +1,72 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package attestation
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/context"
+	"github.com/aws/amazon-ssm-agent/agent/fileutil"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/s3util"
+)
+
+// s3Uploader uploads a file to S3
+type s3Uploader interface {
+	S3Upload(log log.T, bucketName string, objectKey string, filePath string) error
+}
+
+var newS3Uploader = func(context context.T, bucketName string) s3Uploader {
+	return s3util.NewAmazonS3Util(context.Log(), bucketName)
+}
+
+// fileDirectory returns the directory of the file sink, the attestations directory of the instance by default
+func fileDirectory(config appconfig.AttestationCfg, instanceID string) string {
+	if config.LocalDirectory != "" {
+		return config.LocalDirectory
+	}
+	return filepath.Join(appconfig.DefaultDataStorePath, instanceID, attestationsDirName)
+}
+
+// writeFile writes the attestation to the local directory of the configuration
+func writeFile(context context.T, config appconfig.AttestationCfg, instanceID string, name string, statement []byte) error {
+	dir := fileDirectory(config, instanceID)
+	if err := fileutil.MakeDirs(dir); err != nil {
+		return fmt.Errorf("failed to create directory %v: %v", dir, err)
+	}
+	return ioutil.WriteFile(filepath.Join(dir, name), statement, appconfig.ReadWriteAccess)
+}
+
+// uploadToS3 uploads the attestation to the bucket of the configuration, under the key prefix and the instance id
+func uploadToS3(context context.T, config appconfig.AttestationCfg, instanceID string, name string, statement []byte) error {
+	file, err := ioutil.TempFile("", "attestation")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+	_, err = file.Write(statement)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	objectKey := path.Join(config.S3KeyPrefix, instanceID, name)
+	return newS3Uploader(context, config.S3BucketName).S3Upload(context.Log(), config.S3BucketName, objectKey, file.Name())
+}
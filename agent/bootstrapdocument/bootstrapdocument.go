@@ -0,0 +1,226 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package bootstrapdocument executes the local document configured as bootstrap document once, on the first
+// start of the agent worker and before the worker contacts the service
+package bootstrapdocument
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/context"
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/framework/docparser"
+	"github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/basicexecuter"
+	"github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/plugin"
+	"github.com/aws/amazon-ssm-agent/agent/framework/runpluginutil"
+	"github.com/aws/amazon-ssm-agent/agent/health"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/task"
+	"github.com/twinj/uuid"
+)
+
+const (
+	// resultFileName is the file recording the result of the bootstrap document, the document never runs again once it exists
+	resultFileName = "result.json"
+	// outputFolderName is the orchestration folder where the plugins of the bootstrap document write their output
+	outputFolderName = "output"
+	// localInstanceID replaces the instance id in the message id, the instance id is not known before contacting the service
+	localInstanceID = "local"
+)
+
+var (
+	// folder is the folder of the data store with the result and output of the bootstrap document
+	folder = filepath.Join(appconfig.DefaultDataStorePath, appconfig.BootstrapDocumentFolderName)
+
+	// runDocument is the dependency used to execute the parsed plugins of the document
+	runDocument = runDocumentPlugins
+)
+
+// Run executes the bootstrap document when one is configured and it never ran on this machine, and records its result.
+// The result is reported to the health module, including the result recorded by an earlier start.
+func Run(context context.T) {
+	log := context.Log()
+	path := context.AppConfig().Agent.BootstrapDocumentPath
+	if path == "" {
+		return
+	}
+
+	resultPath := filepath.Join(folder, resultFileName)
+	if result, found := loadResult(log, resultPath); found {
+		if result.Status == contracts.ResultStatusInProgress {
+			// the worker stopped while the document was running, a document that ran partially is not run again
+			result.Status = contracts.ResultStatusFailed
+			result.EndTime = time.Now()
+			result.Error = "the agent stopped before the bootstrap document completed"
+			saveResult(log, resultPath, result)
+		}
+		log.Debugf("Bootstrap document already ran on this machine: %s", result)
+		health.ReportBootstrapDocument(result)
+		return
+	}
+
+	log.Infof("Running bootstrap document %v", path)
+	result := health.BootstrapDocumentHealth{Path: path, Status: contracts.ResultStatusInProgress, StartTime: time.Now()}
+	// the document runs only once its start is recorded, so it cannot run a second time
+	if err := saveResult(log, resultPath, result); err != nil {
+		result.Status = contracts.ResultStatusFailed
+		result.EndTime = time.Now()
+		result.Error = fmt.Sprintf("failed to record the start of the bootstrap document: %v", err)
+		log.Errorf("Bootstrap document %v was not run: %v", path, result.Error)
+		health.ReportBootstrapDocument(result)
+		return
+	}
+
+	result.Status, result.Error = execute(context, path)
+	result.EndTime = time.Now()
+	saveResult(log, resultPath, result)
+	if result.Status == contracts.ResultStatusSuccess {
+		log.Infof("Bootstrap document %v completed", path)
+	} else {
+		log.Errorf("Bootstrap document %v did not succeed: %s", path, result)
+	}
+	health.ReportBootstrapDocument(result)
+}
+
+// execute parses and runs the document and returns its status, with the errors of the steps that did not succeed
+func execute(context context.T, path string) (contracts.ResultStatus, string) {
+	log := context.Log()
+	docContent, err := docparser.LoadDocument(path)
+	if err != nil {
+		return contracts.ResultStatusFailed, err.Error()
+	}
+
+	orchestrationDir := filepath.Join(folder, outputFolderName)
+	if err = os.MkdirAll(orchestrationDir, appconfig.ReadWriteExecuteAccess); err != nil {
+		return contracts.ResultStatusFailed, fmt.Sprintf("failed to create output directory %v: %v", orchestrationDir, err)
+	}
+	documentID := uuid.NewV4().String()
+	// the plugins expect the run command message id format aws.ssm.CommandId.InstanceId
+	messageID := fmt.Sprintf("aws.ssm.%v.%v", documentID, localInstanceID)
+	docInfo := contracts.DocumentInfo{
+		DocumentID:   documentID,
+		CommandID:    documentID,
+		MessageID:    messageID,
+		DocumentName: filepath.Base(path),
+	}
+	parserInfo := docparser.DocumentParserInfo{
+		OrchestrationDir:  orchestrationDir,
+		MessageId:         messageID,
+		DocumentId:        documentID,
+		DefaultWorkingDir: filepath.Dir(path),
+	}
+	pluginsInfo, err := docContent.ParseDocument(log, docInfo, parserInfo, map[string]interface{}{})
+	if err != nil {
+		return contracts.ResultStatusFailed, err.Error()
+	}
+
+	docState := contracts.DocumentState{
+		DocumentInformation:        docInfo,
+		DocumentType:               contracts.SendCommand,
+		SchemaVersion:              docContent.SchemaVersion,
+		InstancePluginsInformation: pluginsInfo,
+		IOConfig:                   contracts.IOConfiguration{OrchestrationDirectory: orchestrationDir},
+	}
+	result := runDocument(context, docState)
+	return result.Status, stepErrors(pluginsInfo, result)
+}
+
+// runDocumentPlugins executes the plugins of a document with the worker plugin registry and returns the final result
+func runDocumentPlugins(context context.T, docState contracts.DocumentState) (result contracts.DocumentResult) {
+	runpluginutil.SSMPluginRegistry = plugin.RegisteredWorkerPlugins(context)
+
+	store := &memoryDocumentStore{state: docState}
+	for res := range basicexecuter.NewBasicExecuter(context).Run(task.NewChanneledCancelFlag(), store) {
+		if res.LastPlugin == "" {
+			result = res
+		}
+	}
+	return result
+}
+
+// stepErrors lists the steps that did not succeed, in document order
+func stepErrors(pluginsInfo []contracts.PluginState, result contracts.DocumentResult) string {
+	var errs []string
+	for _, pluginInfo := range pluginsInfo {
+		res, exists := result.PluginResults[pluginInfo.Id]
+		if !exists || res.Status == contracts.ResultStatusSuccess || res.Status == contracts.ResultStatusSkipped {
+			continue
+		}
+		message := fmt.Sprintf("step %v %v with exit code %v", pluginInfo.Id, res.Status, res.Code)
+		if res.Error != "" {
+			message += ": " + strings.TrimSpace(res.Error)
+		}
+		errs = append(errs, message)
+	}
+	return strings.Join(errs, "; ")
+}
+
+// loadResult reads the recorded result, a result that cannot be read still counts as found so the document is not run again
+func loadResult(log log.T, resultPath string) (result health.BootstrapDocumentHealth, found bool) {
+	content, err := ioutil.ReadFile(resultPath)
+	if os.IsNotExist(err) {
+		return result, false
+	}
+	if err == nil {
+		err = json.Unmarshal(content, &result)
+	}
+	if err != nil {
+		log.Warnf("Failed to read the result of the bootstrap document %v: %v", resultPath, err)
+		result.Status = contracts.ResultStatusFailed
+		result.Error = fmt.Sprintf("the recorded result cannot be read: %v", err)
+	}
+	return result, true
+}
+
+// saveResult records the result of the bootstrap document
+func saveResult(log log.T, resultPath string, result health.BootstrapDocumentHealth) error {
+	content, err := json.Marshal(result)
+	if err == nil {
+		if err = os.MkdirAll(filepath.Dir(resultPath), appconfig.ReadWriteExecuteAccess); err == nil {
+			err = ioutil.WriteFile(resultPath, content, appconfig.ReadWriteAccess)
+		}
+	}
+	if err != nil {
+		log.Errorf("Failed to record the result of the bootstrap document in %v: %v", resultPath, err)
+	}
+	return err
+}
+
+// memoryDocumentStore keeps the document state in memory, the bootstrap document is never resumed
+type memoryDocumentStore struct {
+	mu    sync.Mutex
+	state contracts.DocumentState
+}
+
+// Save stores the document state
+func (s *memoryDocumentStore) Save(state contracts.DocumentState) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.state = state
+}
+
+// Load returns the stored document state
+func (s *memoryDocumentStore) Load() contracts.DocumentState {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.state
+}
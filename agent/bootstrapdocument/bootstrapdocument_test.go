@@ -0,0 +1,145 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package bootstrapdocument
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/context"
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/health"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+const testDocument = `{
+  "schemaVersion": "2.2",
+  "description": "bootstrap",
+  "mainSteps": [
+    {"action": "aws:runShellScript", "name": "installCA", "inputs": {"runCommand": ["echo ca"]}},
+    {"action": "aws:runShellScript", "name": "setProxy", "inputs": {"runCommand": ["echo proxy"]}}
+  ]
+}`
+
+// setup creates a bootstrap document in a temporary folder and replaces the result folder and the executer
+func setup(t *testing.T, status contracts.ResultStatus) (ctx *context.Mock, runs *int, cleanup func()) {
+	dir, err := ioutil.TempDir("", "bootstrapdocument")
+	assert.NoError(t, err)
+	documentPath := filepath.Join(dir, "bootstrap.json")
+	assert.NoError(t, ioutil.WriteFile(documentPath, []byte(testDocument), 0600))
+
+	origFolder, origRunDocument := folder, runDocument
+	folder = filepath.Join(dir, appconfig.BootstrapDocumentFolderName)
+	runs = new(int)
+	runDocument = func(context context.T, docState contracts.DocumentState) contracts.DocumentResult {
+		*runs++
+		result := contracts.DocumentResult{Status: status, PluginResults: map[string]*contracts.PluginResult{}}
+		for _, pluginInfo := range docState.InstancePluginsInformation {
+			result.PluginResults[pluginInfo.Id] = &contracts.PluginResult{Status: contracts.ResultStatusSuccess}
+		}
+		if status == contracts.ResultStatusFailed {
+			result.PluginResults["setProxy"] = &contracts.PluginResult{Status: status, Code: 1, Error: "proxy unreachable\n"}
+		}
+		return result
+	}
+
+	config := appconfig.SsmagentConfig{}
+	config.Agent.BootstrapDocumentPath = documentPath
+	ctx = new(context.Mock)
+	ctx.On("Log").Return(log.NewMockLog())
+	ctx.On("AppConfig").Return(config)
+	ctx.On("With", mock.AnythingOfType("string")).Return(ctx)
+	return ctx, runs, func() {
+		folder, runDocument = origFolder, origRunDocument
+		os.RemoveAll(dir)
+	}
+}
+
+func TestRunExecutesTheDocumentOnce(t *testing.T) {
+	ctx, runs, cleanup := setup(t, contracts.ResultStatusSuccess)
+	defer cleanup()
+
+	Run(ctx)
+	Run(ctx)
+
+	assert.Equal(t, 1, *runs)
+	result, found := health.BootstrapDocument()
+	assert.True(t, found)
+	assert.Equal(t, contracts.ResultStatusSuccess, result.Status)
+	assert.Empty(t, result.Error)
+	assert.True(t, !result.EndTime.Before(result.StartTime))
+}
+
+func TestRunRecordsFailedSteps(t *testing.T) {
+	ctx, _, cleanup := setup(t, contracts.ResultStatusFailed)
+	defer cleanup()
+
+	Run(ctx)
+
+	content, err := ioutil.ReadFile(filepath.Join(folder, resultFileName))
+	assert.NoError(t, err)
+	var result health.BootstrapDocumentHealth
+	assert.NoError(t, json.Unmarshal(content, &result))
+	assert.Equal(t, contracts.ResultStatusFailed, result.Status)
+	assert.Equal(t, "step setProxy Failed with exit code 1: proxy unreachable", result.Error)
+}
+
+func TestRunDoesNotRepeatAnInterruptedDocument(t *testing.T) {
+	ctx, runs, cleanup := setup(t, contracts.ResultStatusSuccess)
+	defer cleanup()
+	resultPath := filepath.Join(folder, resultFileName)
+	assert.NoError(t, saveResult(log.NewMockLog(), resultPath, health.BootstrapDocumentHealth{Status: contracts.ResultStatusInProgress}))
+
+	Run(ctx)
+
+	assert.Equal(t, 0, *runs)
+	result, found := loadResult(log.NewMockLog(), resultPath)
+	assert.True(t, found)
+	assert.Equal(t, contracts.ResultStatusFailed, result.Status)
+	assert.Contains(t, result.Error, "stopped before the bootstrap document completed")
+}
+
+func TestRunReportsInvalidDocument(t *testing.T) {
+	ctx, runs, cleanup := setup(t, contracts.ResultStatusSuccess)
+	defer cleanup()
+	assert.NoError(t, ioutil.WriteFile(ctx.AppConfig().Agent.BootstrapDocumentPath, []byte(`{"schemaVersion": "2.2"}`), 0600))
+
+	Run(ctx)
+
+	assert.Equal(t, 0, *runs)
+	result, _ := health.BootstrapDocument()
+	assert.Equal(t, contracts.ResultStatusFailed, result.Status)
+	assert.Contains(t, result.Error, "mainSteps")
+}
+
+func TestRunWithoutBootstrapDocument(t *testing.T) {
+	ctx := context.NewMockDefault()
+	runs := 0
+	origRunDocument := runDocument
+	defer func() { runDocument = origRunDocument }()
+	runDocument = func(context.T, contracts.DocumentState) contracts.DocumentResult {
+		runs++
+		return contracts.DocumentResult{}
+	}
+
+	Run(ctx)
+
+	assert.Equal(t, 0, runs)
+}
@@ -47,7 +47,11 @@ func RunCommand(args []string, out io.Writer) (exitCode int) {
 			fmt.Fprint(out, cmd.Help())
 		} else {
 			cmdErr, result := cmd.Execute(subcommands, parameters)
-			if cmdErr != nil {
+			if failed, ok := cmdErr.(*cliutil.CommandFailedError); ok {
+				fmt.Fprintln(out, failed.Output)
+				// Exit 255 if command ran but failed
+				return cliutil.CLI_COMMAND_FAIL_EXITCODE
+			} else if cmdErr != nil {
 				displayUsage(out)
 				fmt.Fprintln(out, cmdErr.Error())
 				// Exit 255 if command failed
@@ -68,3 +68,17 @@ func TestCliCmdExecSuccess(t *testing.T) {
 	assert.Equal(t, cliutil.CLI_SUCCESS_EXITCODE, exitCode, "command execution success return exit code 0")
 	cliCmdMock.AssertExpectations(t)
 }
+
+func TestCliCmdFailedWithOutput(t *testing.T) {
+	var buffer bytes.Buffer
+	cliCmdMock := &CliCommandMock.CliCommand{}
+	cliCmdMock.On("Name").Return("cli-command-failed-mock").Once()
+	cliCmdMock.On("Execute", mock.AnythingOfType("[]string"), mock.AnythingOfType("map[string][]string")).Return(&cliutil.CommandFailedError{Output: "step failed"}, "").Once()
+	cliutil.Register(cliCmdMock)
+
+	args := []string{"ssm-cli", "cli-command-failed-mock"}
+	exitCode := RunCommand(args, &buffer)
+	assert.Equal(t, cliutil.CLI_COMMAND_FAIL_EXITCODE, exitCode, "failed command return exit code 255")
+	assert.Equal(t, "step failed\n", buffer.String(), "failed command output is displayed without usage")
+	cliCmdMock.AssertExpectations(t)
+}
@@ -0,0 +1,199 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package clicommand contains the implementation of all commands for the ssm agent cli
+// Package clicommand contains the implementation of all commands for the ssm agent cli
+package clicommand
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"text/template"
+
+	"github.com/aws/amazon-ssm-agent/agent/cli/cliutil"
+	"github.com/aws/amazon-ssm-agent/agent/instancestate"
+	"github.com/aws/amazon-ssm-agent/agent/log/ssmlog"
+)
+
+const (
+	clearInstanceStateCommand           = "clear-instance-state"
+	clearInstanceStateRestore           = "restore"
+	clearInstanceStateSnapshotDirectory = "snapshot-directory"
+	clearInstanceStateKeepLogs          = "keep-logs"
+)
+
+const clearInstanceStateCommandHelp = `NAME:
+    {{.ClearInstanceStateCommandName}}
+
+DESCRIPTION
+    Removes the state of the agent that belongs to this instance before an image is created from it:
+    the registration, the fingerprint, the documents and caches of the instance, and the logs.
+    Instances launched from the image register or identify themselves again like new instances,
+    and the agent removes the state the image builder left for other instances when it first starts.
+
+    The state is moved aside before it is removed and moved back when any of it cannot be moved.
+    With {{.SnapshotDirectoryFlag}}, the state is saved first and {{.RestoreFlag}} puts it back.
+    Stop the agent before clearing or restoring the state, for example right before sysprep.
+
+SYNOPSIS
+    {{.ClearInstanceStateCommandName}}
+    [{{.RestoreFlag}}]
+    [{{.SnapshotDirectoryFlag}}]
+    [{{.KeepLogsFlag}}]
+
+PARAMETERS
+    {{.SnapshotDirectoryFlag}} (string) Empty directory outside the agent folders where the state is saved,
+    or the directory to restore the state from with {{.RestoreFlag}}.
+
+    {{.RestoreFlag}} Restores the state saved in {{.SnapshotDirectoryFlag}} instead of clearing it.
+
+    {{.KeepLogsFlag}} Keeps the agent logs.
+
+EXAMPLES
+    This example clears the state before an image is created and keeps a copy on a separate volume.
+
+    Command:
+
+      {{.SsmCliName}} {{.ClearInstanceStateCommandName}} {{.SnapshotDirectoryFlag}} /mnt/backup/ssm-state
+
+    Output:
+
+      Cleared the instance state:
+        /var/lib/amazon/ssm/Vault
+        /var/lib/amazon/ssm/i-0123456789abcdef0
+
+    This example restores the state when the image was not created.
+
+    Command:
+
+      {{.SsmCliName}} {{.ClearInstanceStateCommandName}} {{.RestoreFlag}} {{.SnapshotDirectoryFlag}} /mnt/backup/ssm-state
+
+OUTPUT
+    The cleared or restored paths
+`
+
+type clearInstanceStateHelpParams struct {
+	SsmCliName                    string
+	ClearInstanceStateCommandName string
+	RestoreFlag                   string
+	SnapshotDirectoryFlag         string
+	KeepLogsFlag                  string
+}
+
+// clearInstanceState and restoreInstanceState are the dependencies used to clear and restore the state
+var clearInstanceState = instancestate.Clear
+var restoreInstanceState = instancestate.Restore
+
+func init() {
+	cliutil.Register(&ClearInstanceStateCommand{})
+}
+
+type ClearInstanceStateCommand struct {
+	helpText string
+}
+
+// Execute validates and executes the clear-instance-state cli command
+func (c *ClearInstanceStateCommand) Execute(subcommands []string, parameters map[string][]string) (error, string) {
+	validation := c.validateClearInstanceStateCommandInput(subcommands, parameters)
+	// return validation errors if any were found
+	if len(validation) > 0 {
+		return errors.New(strings.Join(validation, "\n")), ""
+	}
+
+	snapshotDir := ""
+	if values, exists := parameters[clearInstanceStateSnapshotDirectory]; exists {
+		snapshotDir = values[0]
+	}
+	logger := ssmlog.SSMLogger(false)
+	defer logger.Flush()
+
+	if _, restore := parameters[clearInstanceStateRestore]; restore {
+		restored, err := restoreInstanceState(logger, snapshotDir)
+		if err != nil {
+			return err, ""
+		}
+		return nil, formatStatePaths("Restored the instance state", restored)
+	}
+
+	_, keepLogs := parameters[clearInstanceStateKeepLogs]
+	cleared, err := clearInstanceState(logger, snapshotDir, !keepLogs)
+	if err != nil {
+		return err, ""
+	}
+	return nil, formatStatePaths("Cleared the instance state", cleared)
+}
+
+// Help prints help for the clear-instance-state cli command
+func (c *ClearInstanceStateCommand) Help() string {
+	if len(c.helpText) == 0 {
+		t, _ := template.New("ClearInstanceStateCommandHelp").Parse(clearInstanceStateCommandHelp)
+		params := clearInstanceStateHelpParams{
+			cliutil.SsmCliName,
+			clearInstanceStateCommand,
+			cliutil.FormatFlag(clearInstanceStateRestore),
+			cliutil.FormatFlag(clearInstanceStateSnapshotDirectory),
+			cliutil.FormatFlag(clearInstanceStateKeepLogs),
+		}
+		buf := new(bytes.Buffer)
+		t.Execute(buf, params)
+		c.helpText = buf.String()
+	}
+	return c.helpText
+}
+
+// Name is the command name used in the cli
+func (ClearInstanceStateCommand) Name() string {
+	return clearInstanceStateCommand
+}
+
+// validateClearInstanceStateCommandInput checks the subcommands and parameters for required values and unsupported values
+func (ClearInstanceStateCommand) validateClearInstanceStateCommandInput(subcommands []string, parameters map[string][]string) []string {
+	validation := make([]string, 0)
+	if len(subcommands) > 0 {
+		validation = append(validation, fmt.Sprintf("%v does not support subcommand %v", clearInstanceStateCommand, subcommands), "")
+		return validation
+	}
+
+	_, restore := parameters[clearInstanceStateRestore]
+	if _, exists := parameters[clearInstanceStateSnapshotDirectory]; restore && !exists {
+		validation = append(validation, fmt.Sprintf("%v is required with %v", cliutil.FormatFlag(clearInstanceStateSnapshotDirectory), cliutil.FormatFlag(clearInstanceStateRestore)))
+	}
+
+	for key, values := range parameters {
+		switch key {
+		case clearInstanceStateSnapshotDirectory:
+			if len(values) != 1 {
+				validation = append(validation, fmt.Sprintf("expected 1 value for parameter %v", cliutil.FormatFlag(key)))
+			}
+		case clearInstanceStateRestore, clearInstanceStateKeepLogs:
+			if restore && key == clearInstanceStateKeepLogs {
+				validation = append(validation, fmt.Sprintf("%v is not supported with %v", cliutil.FormatFlag(key), cliutil.FormatFlag(clearInstanceStateRestore)))
+			} else if len(values) != 0 {
+				validation = append(validation, fmt.Sprintf("%v does not take a value", cliutil.FormatFlag(key)))
+			}
+		default:
+			validation = append(validation, fmt.Sprintf("unknown parameter %v", cliutil.FormatFlag(key)))
+		}
+	}
+	return validation
+}
+
+// formatStatePaths lists the cleared or restored paths under a title
+func formatStatePaths(title string, paths []string) string {
+	if len(paths) == 0 {
+		return title + ": nothing to do"
+	}
+	return title + ":\n  " + strings.Join(paths, "\n  ")
+}
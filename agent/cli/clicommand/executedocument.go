@@ -0,0 +1,331 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package clicommand contains the implementation of all commands for the ssm agent cli
+package clicommand
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"text/template"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/cli/cliutil"
+	"github.com/aws/amazon-ssm-agent/agent/context"
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/framework/docparser"
+	"github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/basicexecuter"
+	"github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/plugin"
+	"github.com/aws/amazon-ssm-agent/agent/framework/runpluginutil"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/log/ssmlog"
+	"github.com/aws/amazon-ssm-agent/agent/task"
+	"github.com/twinj/uuid"
+)
+
+const (
+	executeDocumentCommand         = "execute-document"
+	executeDocumentPath            = "path"
+	executeDocumentParameters      = "parameters"
+	executeDocumentOutputDirectory = "output-directory"
+	fileUrlPrefix                  = "file://"
+	localInstanceID                = "local"
+)
+
+const executeDocumentCommandHelp = `NAME:
+    {{.ExecuteDocumentCommandName}}
+
+DESCRIPTION
+    Runs an SSM document from a local file on this machine, without contacting the Systems Manager service.
+    The document is parsed and executed by the same plugins the agent uses for Run Command,
+    which makes it possible to test a document before uploading it.
+
+SYNOPSIS
+    {{.ExecuteDocumentCommandName}}
+    {{.PathFlag}}
+    [{{.ParametersFlag}}]
+    [{{.OutputDirectoryFlag}}]
+
+PARAMETERS
+    {{.PathFlag}} (string) Path to a JSON or YAML command document.
+
+    {{.ParametersFlag}} (string) JSON object with the document parameters, or file:// followed by the path to one.
+
+    {{.OutputDirectoryFlag}} (string) Directory where the plugins write their output.
+    A temporary directory is created when not provided.
+
+EXAMPLES
+    This example runs a shell script document with parameters read from a file.
+
+    Command:
+
+      {{.SsmCliName}} {{.ExecuteDocumentCommandName}} {{.PathFlag}} ./doc.yaml {{.ParametersFlag}} file://params.json
+
+    Output:
+
+      Step runShellScript (aws:runShellScript): Success, exit code 0
+        hello world
+
+      Document status: Success
+      Output directory: /tmp/ssm-cli-execute-document123456789
+
+OUTPUT
+    Status, exit code and output of every step followed by the status of the document
+`
+
+type executeDocumentHelpParams struct {
+	SsmCliName                 string
+	ExecuteDocumentCommandName string
+	PathFlag                   string
+	ParametersFlag             string
+	OutputDirectoryFlag        string
+}
+
+// runDocument is the dependency used to execute the parsed plugins of a document
+var runDocument = runDocumentPlugins
+
+func init() {
+	cliutil.Register(&ExecuteDocumentCommand{})
+}
+
+type ExecuteDocumentCommand struct {
+	helpText string
+}
+
+// Execute validates and executes the execute-document cli command
+func (c *ExecuteDocumentCommand) Execute(subcommands []string, parameters map[string][]string) (error, string) {
+	validation := c.validateExecuteDocumentCommandInput(subcommands, parameters)
+	// return validation errors if any were found
+	if len(validation) > 0 {
+		return errors.New(strings.Join(validation, "\n")), ""
+	}
+
+	docContent, err := docparser.LoadDocument(parameters[executeDocumentPath][0])
+	if err != nil {
+		return err, ""
+	}
+	documentParameters := make(map[string]interface{})
+	if values, exists := parameters[executeDocumentParameters]; exists {
+		if documentParameters, err = c.loadParameters(values[0]); err != nil {
+			return err, ""
+		}
+	}
+	orchestrationDir, err := c.outputDirectory(parameters)
+	if err != nil {
+		return err, ""
+	}
+	workingDir, err := os.Getwd()
+	if err != nil {
+		return err, ""
+	}
+
+	documentID := uuid.NewV4().String()
+	// the plugins expect the run command message id format aws.ssm.CommandId.InstanceId
+	messageID := fmt.Sprintf("aws.ssm.%v.%v", documentID, localInstanceID)
+	docInfo := contracts.DocumentInfo{
+		DocumentID:   documentID,
+		CommandID:    documentID,
+		MessageID:    messageID,
+		DocumentName: filepath.Base(parameters[executeDocumentPath][0]),
+	}
+	parserInfo := docparser.DocumentParserInfo{
+		OrchestrationDir:  orchestrationDir,
+		MessageId:         messageID,
+		DocumentId:        documentID,
+		DefaultWorkingDir: workingDir,
+	}
+	logger := ssmlog.SSMLogger(false)
+	defer logger.Flush()
+	pluginsInfo, err := docContent.ParseDocument(logger, docInfo, parserInfo, documentParameters)
+	if err != nil {
+		return err, ""
+	}
+
+	docState := contracts.DocumentState{
+		DocumentInformation:        docInfo,
+		DocumentType:               contracts.SendCommand,
+		SchemaVersion:              docContent.SchemaVersion,
+		InstancePluginsInformation: pluginsInfo,
+		IOConfig:                   contracts.IOConfiguration{OrchestrationDirectory: orchestrationDir},
+	}
+	result, err := runDocument(logger, docState)
+	if err != nil {
+		return err, ""
+	}
+
+	output := formatDocumentResult(pluginsInfo, result, orchestrationDir)
+	if result.Status != contracts.ResultStatusSuccess {
+		return &cliutil.CommandFailedError{Output: output}, ""
+	}
+	return nil, output
+}
+
+// Help prints help for the execute-document cli command
+func (c *ExecuteDocumentCommand) Help() string {
+	if len(c.helpText) == 0 {
+		t, _ := template.New("ExecuteDocumentCommandHelp").Parse(executeDocumentCommandHelp)
+		params := executeDocumentHelpParams{
+			cliutil.SsmCliName,
+			executeDocumentCommand,
+			cliutil.FormatFlag(executeDocumentPath),
+			cliutil.FormatFlag(executeDocumentParameters),
+			cliutil.FormatFlag(executeDocumentOutputDirectory),
+		}
+		buf := new(bytes.Buffer)
+		t.Execute(buf, params)
+		c.helpText = buf.String()
+	}
+	return c.helpText
+}
+
+// Name is the command name used in the cli
+func (ExecuteDocumentCommand) Name() string {
+	return executeDocumentCommand
+}
+
+// validateExecuteDocumentCommandInput checks the subcommands and parameters for required values and unsupported values
+func (ExecuteDocumentCommand) validateExecuteDocumentCommandInput(subcommands []string, parameters map[string][]string) []string {
+	validation := make([]string, 0)
+	if len(subcommands) > 0 {
+		validation = append(validation, fmt.Sprintf("%v does not support subcommand %v", executeDocumentCommand, subcommands), "")
+		return validation
+	}
+
+	// look for required parameters
+	if _, exists := parameters[executeDocumentPath]; !exists {
+		validation = append(validation, fmt.Sprintf("%v is required", cliutil.FormatFlag(executeDocumentPath)))
+	}
+
+	for key, values := range parameters {
+		switch key {
+		case executeDocumentPath, executeDocumentOutputDirectory:
+			if len(values) != 1 {
+				validation = append(validation, fmt.Sprintf("expected 1 value for parameter %v", cliutil.FormatFlag(key)))
+			}
+		case executeDocumentParameters:
+			if len(values) != 1 {
+				validation = append(validation, fmt.Sprintf("expected 1 value for parameter %v", cliutil.FormatFlag(key)))
+			} else if !cliutil.ValidJson(values[0]) && !strings.HasPrefix(strings.ToLower(values[0]), fileUrlPrefix) {
+				validation = append(validation, fmt.Sprintf("%v value must be a json object or a file:// path", cliutil.FormatFlag(key)))
+			}
+		default:
+			validation = append(validation, fmt.Sprintf("unknown parameter %v", cliutil.FormatFlag(key)))
+		}
+	}
+	return validation
+}
+
+// loadParameters reads the document parameters from inline JSON or a file:// path
+func (ExecuteDocumentCommand) loadParameters(value string) (map[string]interface{}, error) {
+	raw := []byte(value)
+	if strings.HasPrefix(strings.ToLower(value), fileUrlPrefix) {
+		path := value[len(fileUrlPrefix):]
+		var err error
+		if raw, err = ioutil.ReadFile(path); err != nil {
+			return nil, fmt.Errorf("failed to read parameters %v: %v", path, err)
+		}
+	}
+	documentParameters := make(map[string]interface{})
+	if err := json.Unmarshal(raw, &documentParameters); err != nil {
+		return nil, fmt.Errorf("parameters must be a json object: %v", err)
+	}
+	return documentParameters, nil
+}
+
+// outputDirectory returns the requested output directory, or a new temporary one
+func (ExecuteDocumentCommand) outputDirectory(parameters map[string][]string) (string, error) {
+	if values, exists := parameters[executeDocumentOutputDirectory]; exists {
+		dir, err := filepath.Abs(values[0])
+		if err != nil {
+			return "", err
+		}
+		return dir, os.MkdirAll(dir, appconfig.ReadWriteExecuteAccess)
+	}
+	return ioutil.TempDir("", "ssm-cli-"+executeDocumentCommand)
+}
+
+// runDocumentPlugins executes the plugins of a document with the worker plugin registry and returns the final result
+func runDocumentPlugins(logger log.T, docState contracts.DocumentState) (result contracts.DocumentResult, err error) {
+	config, err := appconfig.Config(false)
+	if err != nil {
+		return result, fmt.Errorf("failed to load agent configuration: %v", err)
+	}
+	ctx := context.Default(logger, config).With("[" + executeDocumentCommand + "]")
+	runpluginutil.SSMPluginRegistry = plugin.RegisteredWorkerPlugins(ctx)
+
+	store := &memoryDocumentStore{state: docState}
+	for res := range basicexecuter.NewBasicExecuter(ctx).Run(task.NewChanneledCancelFlag(), store) {
+		if res.LastPlugin == "" {
+			result = res
+		}
+	}
+	return result, nil
+}
+
+// formatDocumentResult lists the result of every step in document order followed by the document status
+func formatDocumentResult(pluginsInfo []contracts.PluginState, result contracts.DocumentResult, orchestrationDir string) string {
+	var buf bytes.Buffer
+	for _, pluginInfo := range pluginsInfo {
+		res, exists := result.PluginResults[pluginInfo.Id]
+		if !exists {
+			fmt.Fprintf(&buf, "Step %v (%v): not run\n\n", pluginInfo.Id, pluginInfo.Name)
+			continue
+		}
+		fmt.Fprintf(&buf, "Step %v (%v): %v, exit code %v\n", pluginInfo.Id, pluginInfo.Name, res.Status, res.Code)
+		writeIndented(&buf, res.StandardOutput)
+		writeIndented(&buf, res.StandardError)
+		if res.StandardOutput == "" && res.StandardError == "" {
+			writeIndented(&buf, res.Error)
+		}
+		buf.WriteString("\n")
+	}
+	fmt.Fprintf(&buf, "Document status: %v\n", result.Status)
+	fmt.Fprintf(&buf, "Output directory: %v", orchestrationDir)
+	return buf.String()
+}
+
+// writeIndented writes every non empty line of text indented under its step
+func writeIndented(buf *bytes.Buffer, text string) {
+	for _, line := range strings.Split(strings.TrimRight(text, "\n"), "\n") {
+		if line != "" {
+			fmt.Fprintf(buf, "  %v\n", line)
+		}
+	}
+}
+
+// memoryDocumentStore keeps the document state in memory, a local run is never resumed
+type memoryDocumentStore struct {
+	mu    sync.Mutex
+	state contracts.DocumentState
+}
+
+// Save stores the document state
+func (s *memoryDocumentStore) Save(state contracts.DocumentState) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.state = state
+}
+
+// Load returns the stored document state
+func (s *memoryDocumentStore) Load() contracts.DocumentState {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.state
+}
@@ -25,6 +25,7 @@ import (
 	"github.com/aws/amazon-ssm-agent/agent/appconfig"
 	"github.com/aws/amazon-ssm-agent/agent/cli/cliutil"
 	"github.com/aws/amazon-ssm-agent/agent/fileutil"
+	"github.com/aws/amazon-ssm-agent/agent/framework/docmanager"
 )
 
 const (
@@ -174,13 +175,7 @@ func (GetOfflineCommand) isCommandInState(stateFolder string, commandID string)
 	dirs, _ := fileutil.GetDirectoryNames(appconfig.DefaultDataStorePath)
 
 	for _, dir := range dirs {
-		potentialFolder := path.Join(appconfig.DefaultDataStorePath,
-			dir,
-			appconfig.DefaultDocumentRootDirName,
-			appconfig.DefaultLocationOfState,
-			stateFolder,
-			commandID)
-		if fileutil.Exists(potentialFolder) {
+		if docmanager.HasDocumentState(dir, stateFolder, commandID) {
 			return true
 		}
 	}
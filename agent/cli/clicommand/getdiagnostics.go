@@ -0,0 +1,584 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package clicommand contains the implementation of all commands for the ssm agent cli
+package clicommand
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/cli/cliutil"
+	"github.com/aws/amazon-ssm-agent/agent/fileutil"
+	"github.com/aws/amazon-ssm-agent/agent/ipc/localapi"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/managedInstances/registration"
+	"github.com/aws/amazon-ssm-agent/agent/platform"
+	"github.com/aws/amazon-ssm-agent/agent/proxyconfig"
+	"github.com/aws/amazon-ssm-agent/agent/rip"
+	"github.com/aws/amazon-ssm-agent/agent/sdkutil"
+	"github.com/aws/amazon-ssm-agent/agent/version"
+)
+
+const (
+	getDiagnosticsCommand      = "get-diagnostics"
+	getDiagnosticsOutputBundle = "output-bundle"
+
+	diagnosticsPassed  = "Passed"
+	diagnosticsWarning = "Warning"
+	diagnosticsFailed  = "Failed"
+	diagnosticsSkipped = "Skipped"
+
+	// diagnosticsProbeTimeout limits every network probe made by the checks
+	diagnosticsProbeTimeout = 10 * time.Second
+	// maxClockSkew is the skew after which the service rejects signed requests
+	maxClockSkew = 5 * time.Minute
+	// minFreeDiskSpace is the space the agent needs to keep documents, logs and updates
+	minFreeDiskSpace int64 = 100 * 1024 * 1024
+	// maxBundleFileBytes limits how much of the end of every log file goes into the bundle
+	maxBundleFileBytes int64 = 10 * 1024 * 1024
+	redactedValue            = "REDACTED"
+)
+
+const getDiagnosticsCommandHelp = `NAME:
+    {{.GetDiagnosticsCommandName}}
+
+DESCRIPTION
+    Checks the prerequisites of the agent on this machine: instance metadata and credentials,
+    reachability of the service endpoints, proxy configuration, clock skew, disk space,
+    permissions of the agent directories and versions of the agent and its workers.
+
+    The report is printed as JSON, the command fails when one of the checks failed.
+    A support bundle with the report, the agent configuration and the agent logs can be written
+    to a zip file, secrets such as access keys, passwords and tokens are redacted from it.
+
+SYNOPSIS
+    {{.GetDiagnosticsCommandName}}
+    [{{.OutputBundleFlag}}]
+
+PARAMETERS
+    {{.OutputBundleFlag}} (string) Path of the zip file to write the support bundle to.
+
+EXAMPLES
+    Command:
+
+      {{.SsmCliName}} {{.GetDiagnosticsCommandName}} {{.OutputBundleFlag}} /tmp/ssm-diagnostics.zip
+
+    Output:
+      {
+        "CliVersion": "3.0.0.0",
+        "Region": "us-east-1",
+        "Checks": [
+          {
+            "Name": "Endpoint ssm",
+            "Status": "Passed",
+            "Note": "ssm.us-east-1.amazonaws.com reachable directly"
+          },
+          ...
+        ],
+        "Bundle": "/tmp/ssm-diagnostics.zip"
+      }
+
+OUTPUT
+    JSON report with the status of every check
+`
+
+type getDiagnosticsHelpParams struct {
+	SsmCliName                string
+	GetDiagnosticsCommandName string
+	OutputBundleFlag          string
+}
+
+// diagnosticsReport is the result of all checks of the get-diagnostics command
+type diagnosticsReport struct {
+	GeneratedAt time.Time
+	CliVersion  string
+	Platform    string
+	InstanceID  string `json:",omitempty"`
+	Region      string `json:",omitempty"`
+	Checks      []diagnosticsCheck
+	Bundle      string `json:",omitempty"`
+}
+
+// diagnosticsCheck is the result of one check
+type diagnosticsCheck struct {
+	Name   string
+	Status string
+	Note   string
+}
+
+// diagnosticsServices are the endpoints the agent has to reach, with their proxy rule names
+var diagnosticsServices = []string{
+	proxyconfig.ServiceSsm,
+	proxyconfig.ServiceEc2Messages,
+	proxyconfig.ServiceSsmMessages,
+	proxyconfig.ServiceS3,
+}
+
+// secretPatterns match secrets in configuration and log files, the groups around the secret are kept
+var secretPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`()\b(?:AKIA|ASIA)[0-9A-Z]{16}\b()`),
+	regexp.MustCompile(`(?i)("[^"]*(?:secret|password|passphrase|token|credential)[^"]*"\s*:\s*")[^"]*()`),
+	regexp.MustCompile(`(?i)((?:secret|password|passphrase|token)[a-z_]*\s*[=:]\s*)[^\s",]+()`),
+	regexp.MustCompile(`(://[^/\s:@]+:)[^@\s/]+(@)`),
+}
+
+// dependencies of the checks
+var (
+	getConfig             = appconfig.Config
+	getRegion             = platform.Region
+	getInstanceID         = platform.InstanceID
+	getManagedInstanceID  = registration.InstanceID
+	callLocalApi          = localapi.Call
+	diagnosticsHttpClient = func(service string) *http.Client {
+		return &http.Client{Transport: proxyconfig.NewTransport(service), Timeout: diagnosticsProbeTimeout}
+	}
+)
+
+// files of the support bundle
+var (
+	bundleConfigFiles = []string{appconfig.AppConfigPath, filepath.Join(appconfig.DefaultProgramFolder, appconfig.SeelogConfigFileName)}
+	bundleLogDir      = log.DefaultLogDir
+)
+
+func init() {
+	cliutil.Register(&GetDiagnosticsCommand{})
+}
+
+type GetDiagnosticsCommand struct {
+	helpText string
+}
+
+// Execute validates and executes the get-diagnostics cli command
+func (c *GetDiagnosticsCommand) Execute(subcommands []string, parameters map[string][]string) (error, string) {
+	validation := c.validateGetDiagnosticsCommandInput(subcommands, parameters)
+	// return validation errors if any were found
+	if len(validation) > 0 {
+		return errors.New(strings.Join(validation, "\n")), ""
+	}
+
+	report := c.runChecks()
+	if values, exists := parameters[getDiagnosticsOutputBundle]; exists {
+		bundle, err := filepath.Abs(values[0])
+		if err != nil {
+			return err, ""
+		}
+		report.Bundle = bundle
+		if err = c.writeBundle(bundle, report); err != nil {
+			return fmt.Errorf("failed to write support bundle: %v", err), ""
+		}
+	}
+
+	output, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err, ""
+	}
+	for _, check := range report.Checks {
+		if check.Status == diagnosticsFailed {
+			return &cliutil.CommandFailedError{Output: string(output)}, ""
+		}
+	}
+	return nil, string(output)
+}
+
+// Help prints help for the get-diagnostics cli command
+func (c *GetDiagnosticsCommand) Help() string {
+	if len(c.helpText) == 0 {
+		t, _ := template.New("GetDiagnosticsCommandHelp").Parse(getDiagnosticsCommandHelp)
+		params := getDiagnosticsHelpParams{cliutil.SsmCliName, getDiagnosticsCommand, cliutil.FormatFlag(getDiagnosticsOutputBundle)}
+		buf := new(bytes.Buffer)
+		t.Execute(buf, params)
+		c.helpText = buf.String()
+	}
+	return c.helpText
+}
+
+// Name is the command name used in the cli
+func (GetDiagnosticsCommand) Name() string {
+	return getDiagnosticsCommand
+}
+
+// validateGetDiagnosticsCommandInput checks the subcommands and parameters for unsupported values
+func (GetDiagnosticsCommand) validateGetDiagnosticsCommandInput(subcommands []string, parameters map[string][]string) []string {
+	validation := make([]string, 0)
+	if len(subcommands) > 0 {
+		validation = append(validation, fmt.Sprintf("%v does not support subcommand %v", getDiagnosticsCommand, subcommands), "")
+		return validation
+	}
+
+	for key, values := range parameters {
+		if key != getDiagnosticsOutputBundle {
+			validation = append(validation, fmt.Sprintf("unknown parameter %v", cliutil.FormatFlag(key)))
+		} else if len(values) != 1 {
+			validation = append(validation, fmt.Sprintf("expected 1 value for parameter %v", cliutil.FormatFlag(key)))
+		}
+	}
+	return validation
+}
+
+// runChecks runs every check and returns the report
+func (c GetDiagnosticsCommand) runChecks() diagnosticsReport {
+	report := diagnosticsReport{
+		GeneratedAt: time.Now().UTC(),
+		CliVersion:  version.Version,
+		Platform:    runtime.GOOS + "/" + runtime.GOARCH,
+	}
+	config, err := getConfig(false)
+	if err != nil {
+		config = appconfig.DefaultConfig()
+	}
+
+	report.Checks = append(report.Checks, c.checkMetadataService())
+	report.InstanceID, _ = getInstanceID()
+	report.Region, _ = getRegion()
+	report.Checks = append(report.Checks, c.checkCredentials())
+
+	var serviceTime time.Time
+	for _, service := range diagnosticsServices {
+		check, date := c.checkEndpoint(config, report.Region, service)
+		report.Checks = append(report.Checks, check)
+		if serviceTime.IsZero() {
+			serviceTime = date
+		}
+	}
+	report.Checks = append(report.Checks,
+		c.checkProxy(config),
+		c.checkClockSkew(serviceTime),
+		c.checkDiskSpace(),
+		c.checkDirectoryPermissions(),
+		c.checkVersions(),
+	)
+	return report
+}
+
+// checkMetadataService requests a metadata token, managed instances do not use the metadata service
+func (GetDiagnosticsCommand) checkMetadataService() diagnosticsCheck {
+	check := diagnosticsCheck{Name: "Instance metadata"}
+	if managedInstanceID := getManagedInstanceID(); managedInstanceID != "" {
+		check.Status = diagnosticsSkipped
+		check.Note = fmt.Sprintf("registered as managed instance %v", managedInstanceID)
+		return check
+	}
+
+	endpoint := platform.EC2MetadataServiceEndpoint()
+	request, err := http.NewRequest(http.MethodPut, endpoint+platform.EC2MetadataTokenURL, nil)
+	if err != nil {
+		check.Status, check.Note = diagnosticsFailed, err.Error()
+		return check
+	}
+	request.Header.Set(platform.EC2MetadataTokenExpireHeader, "60")
+	client := http.Client{Timeout: diagnosticsProbeTimeout}
+	response, err := client.Do(request)
+	if err != nil {
+		check.Status, check.Note = diagnosticsFailed, fmt.Sprintf("%v is not reachable: %v", endpoint, err)
+		return check
+	}
+	response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		check.Status, check.Note = diagnosticsFailed, fmt.Sprintf("%v returned %v for a token request", endpoint, response.Status)
+		return check
+	}
+	check.Status, check.Note = diagnosticsPassed, fmt.Sprintf("%v returned a token", endpoint)
+	return check
+}
+
+// checkCredentials retrieves the credentials the agent would use, without showing them
+func (GetDiagnosticsCommand) checkCredentials() diagnosticsCheck {
+	check := diagnosticsCheck{Name: "Credentials"}
+	awsConfig := sdkutil.AwsConfig()
+	if awsConfig.Credentials == nil {
+		check.Status, check.Note = diagnosticsFailed, "no credential provider is available"
+		return check
+	}
+	value, err := awsConfig.Credentials.Get()
+	if err != nil {
+		check.Status, check.Note = diagnosticsFailed, fmt.Sprintf("failed to retrieve credentials: %v", err)
+		return check
+	}
+	check.Status, check.Note = diagnosticsPassed, fmt.Sprintf("credentials retrieved from %v", value.ProviderName)
+	return check
+}
+
+// checkEndpoint sends a request to the endpoint of a service through its proxy,
+// any http response means the endpoint is reachable. The Date header of the response is returned.
+func (GetDiagnosticsCommand) checkEndpoint(config appconfig.SsmagentConfig, region string, service string) (diagnosticsCheck, time.Time) {
+	check := diagnosticsCheck{Name: "Endpoint " + service}
+	endpoint := configuredEndpoint(config, service)
+	if endpoint == "" {
+		if region == "" {
+			check.Status, check.Note = diagnosticsSkipped, "region is unknown"
+			return check, time.Time{}
+		}
+		endpoint = rip.GetDefaultServiceEndpoint(region, service)
+	}
+	endpointUrl := endpoint
+	if !strings.Contains(endpointUrl, "://") {
+		endpointUrl = "https://" + endpointUrl
+	}
+
+	request, err := http.NewRequest(http.MethodGet, endpointUrl, nil)
+	if err != nil {
+		check.Status, check.Note = diagnosticsFailed, err.Error()
+		return check, time.Time{}
+	}
+	route := "directly"
+	if proxy, err := proxyconfig.ProxyFunc(service)(request); err == nil && proxy != nil {
+		route = "through proxy " + redactSecrets(proxy.String())
+	}
+	response, err := diagnosticsHttpClient(service).Do(request)
+	if err != nil {
+		check.Status, check.Note = diagnosticsFailed, fmt.Sprintf("%v is not reachable %v: %v", endpoint, route, redactSecrets(err.Error()))
+		return check, time.Time{}
+	}
+	response.Body.Close()
+	check.Status, check.Note = diagnosticsPassed, fmt.Sprintf("%v reachable %v", endpoint, route)
+	date, _ := http.ParseTime(response.Header.Get("Date"))
+	return check, date
+}
+
+// configuredEndpoint returns the endpoint override of a service from the agent configuration
+func configuredEndpoint(config appconfig.SsmagentConfig, service string) string {
+	switch service {
+	case proxyconfig.ServiceSsm:
+		return config.Ssm.Endpoint
+	case proxyconfig.ServiceEc2Messages:
+		return config.Mds.Endpoint
+	case proxyconfig.ServiceSsmMessages:
+		return config.Mgs.Endpoint
+	case proxyconfig.ServiceS3:
+		return config.S3.Endpoint
+	}
+	return ""
+}
+
+// checkProxy describes the proxy configuration of the agent, with credentials redacted
+func (GetDiagnosticsCommand) checkProxy(config appconfig.SsmagentConfig) diagnosticsCheck {
+	check := diagnosticsCheck{Name: "Proxy", Status: diagnosticsPassed}
+	var settings []string
+	for _, name := range []string{"https_proxy", "http_proxy", "no_proxy"} {
+		value := os.Getenv(name)
+		if value == "" {
+			value = os.Getenv(strings.ToUpper(name))
+		}
+		if value != "" {
+			settings = append(settings, fmt.Sprintf("%v=%v", name, redactSecrets(value)))
+		}
+	}
+	if config.Proxy.PacFile != "" {
+		settings = append(settings, "pac file "+config.Proxy.PacFile)
+		if !fileutil.Exists(config.Proxy.PacFile) && !strings.Contains(config.Proxy.PacFile, "://") {
+			check.Status = diagnosticsFailed
+			settings = append(settings, "pac file does not exist")
+		}
+	}
+	for service, rule := range config.Proxy.Services {
+		settings = append(settings, fmt.Sprintf("%v rule proxy=%v no_proxy=%v", service, redactSecrets(rule.Proxy), strings.Join(rule.NoProxy, ",")))
+	}
+	if len(settings) == 0 {
+		check.Note = "no proxy is configured"
+	} else {
+		check.Note = strings.Join(settings, "; ")
+	}
+	return check
+}
+
+// checkClockSkew compares the local clock with the date returned by the service
+func (GetDiagnosticsCommand) checkClockSkew(serviceTime time.Time) diagnosticsCheck {
+	check := diagnosticsCheck{Name: "Clock skew"}
+	if serviceTime.IsZero() {
+		check.Status, check.Note = diagnosticsSkipped, "no service endpoint returned its time"
+		return check
+	}
+	skew := time.Since(serviceTime).Round(time.Second)
+	if skew < 0 {
+		skew = -skew
+	}
+	check.Note = fmt.Sprintf("local clock differs from the service by %v", skew)
+	switch {
+	case skew >= maxClockSkew:
+		check.Status = diagnosticsFailed
+	case skew >= time.Minute:
+		check.Status = diagnosticsWarning
+	default:
+		check.Status = diagnosticsPassed
+	}
+	return check
+}
+
+// checkDiskSpace checks the space left on the disk holding the agent data
+func (GetDiagnosticsCommand) checkDiskSpace() diagnosticsCheck {
+	check := diagnosticsCheck{Name: "Disk space"}
+	info, err := fileutil.GetDiskSpaceInfoForPath(existingParent(appconfig.DefaultDataStorePath))
+	if err != nil {
+		check.Status, check.Note = diagnosticsFailed, fmt.Sprintf("failed to get disk space: %v", err)
+		return check
+	}
+	check.Note = fmt.Sprintf("%v MB available of %v MB", info.AvailBytes/(1024*1024), info.TotalBytes/(1024*1024))
+	if info.AvailBytes < minFreeDiskSpace {
+		check.Status = diagnosticsFailed
+	} else {
+		check.Status = diagnosticsPassed
+	}
+	return check
+}
+
+// checkDirectoryPermissions checks the agent directories exist and are not writable by everyone
+func (GetDiagnosticsCommand) checkDirectoryPermissions() diagnosticsCheck {
+	check := diagnosticsCheck{Name: "Directory permissions", Status: diagnosticsPassed}
+	var notes []string
+	for _, dir := range []string{appconfig.DefaultDataStorePath, appconfig.DefaultProgramFolder, log.DefaultLogDir} {
+		info, err := os.Stat(dir)
+		if err != nil {
+			check.Status = diagnosticsFailed
+			notes = append(notes, fmt.Sprintf("%v: %v", dir, err))
+			continue
+		}
+		notes = append(notes, fmt.Sprintf("%v %v", dir, info.Mode().Perm()))
+		if runtime.GOOS != "windows" && info.Mode().Perm()&0002 != 0 {
+			if check.Status == diagnosticsPassed {
+				check.Status = diagnosticsWarning
+			}
+			notes = append(notes, fmt.Sprintf("%v is writable by everyone", dir))
+		}
+	}
+	check.Note = strings.Join(notes, "; ")
+	return check
+}
+
+// checkVersions compares the version of the running agent with this cli and checks the workers are installed
+func (GetDiagnosticsCommand) checkVersions() diagnosticsCheck {
+	check := diagnosticsCheck{Name: "Versions", Status: diagnosticsPassed}
+	notes := []string{"ssm-cli " + version.Version}
+	if resp, err := callLocalApi(localapi.VerbStatus); err != nil {
+		notes = append(notes, fmt.Sprintf("running agent unknown, local api is not available: %v", err))
+	} else if resp.Status != nil {
+		notes = append(notes, fmt.Sprintf("running agent %v (%v)", resp.Status.Version, resp.Status.State))
+		if resp.Status.Version != version.Version {
+			check.Status = diagnosticsWarning
+			notes = append(notes, "the running agent was not restarted after an update")
+		}
+	}
+	for _, worker := range []string{appconfig.DefaultSSMAgentWorker, appconfig.DefaultDocumentWorker, appconfig.DefaultSessionWorker} {
+		if !fileutil.Exists(worker) {
+			check.Status = diagnosticsFailed
+			notes = append(notes, fmt.Sprintf("worker %v is missing", worker))
+		}
+	}
+	check.Note = strings.Join(notes, "; ")
+	return check
+}
+
+// existingParent returns the path, or its closest parent that exists
+func existingParent(path string) string {
+	for !fileutil.Exists(path) {
+		parent := filepath.Dir(filepath.Clean(path))
+		if parent == path {
+			break
+		}
+		path = parent
+	}
+	return path
+}
+
+// writeBundle writes the report, the agent configuration, the end of the agent logs and the plugin crash reports to a zip file,
+// every file except the report is redacted
+func (GetDiagnosticsCommand) writeBundle(path string, report diagnosticsReport) (err error) {
+	bundle, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, appconfig.ReadWriteAccess)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if closeErr := bundle.Close(); err == nil {
+			err = closeErr
+		}
+	}()
+
+	archive := zip.NewWriter(bundle)
+	reportContent, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err = addBundleFile(archive, "report.json", reportContent); err != nil {
+		return err
+	}
+
+	files := append([]string{}, bundleConfigFiles...)
+	if logs, err := filepath.Glob(filepath.Join(bundleLogDir, "*.log")); err == nil {
+		files = append(files, logs...)
+	}
+	if reports, err := filepath.Glob(filepath.Join(bundleLogDir, appconfig.CrashReportsDirName, "*.json")); err == nil {
+		files = append(files, reports...)
+	}
+	for _, file := range files {
+		content, err := readFileTail(file, maxBundleFileBytes)
+		if err != nil {
+			continue
+		}
+		if err = addBundleFile(archive, filepath.Base(file), []byte(redactSecrets(string(content)))); err != nil {
+			return err
+		}
+	}
+	return archive.Close()
+}
+
+// addBundleFile adds one file to the support bundle
+func addBundleFile(archive *zip.Writer, name string, content []byte) error {
+	writer, err := archive.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: time.Now()})
+	if err != nil {
+		return err
+	}
+	_, err = writer.Write(content)
+	return err
+}
+
+// readFileTail returns at most the last limit bytes of a file
+func readFileTail(path string, limit int64) ([]byte, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if info.Size() > limit {
+		if _, err = file.Seek(-limit, io.SeekEnd); err != nil {
+			return nil, err
+		}
+	}
+	return ioutil.ReadAll(file)
+}
+
+// redactSecrets replaces access keys, passwords, tokens and url credentials with a placeholder
+func redactSecrets(content string) string {
+	for _, pattern := range secretPatterns {
+		content = pattern.ReplaceAllString(content, "${1}"+redactedValue+"${2}")
+	}
+	return content
+}
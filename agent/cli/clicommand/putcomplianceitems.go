@@ -0,0 +1,202 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package clicommand contains the implementation of all commands for the ssm agent cli
+package clicommand
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"text/template"
+
+	"github.com/aws/amazon-ssm-agent/agent/association/compliance/model"
+	"github.com/aws/amazon-ssm-agent/agent/cli/cliutil"
+	"github.com/aws/amazon-ssm-agent/agent/ipc/localapi"
+)
+
+const (
+	putComplianceItemsCommand       = "put-compliance-items"
+	putComplianceItemsType          = "compliance-type"
+	putComplianceItemsItems         = "items"
+	putComplianceItemsAssociationID = "association-id"
+	putComplianceItemsCommandID     = "command-id"
+)
+
+const putComplianceItemsCommandHelp = `NAME:
+    {{.PutComplianceItemsCommandName}}
+
+DESCRIPTION
+    Reports the items of a custom compliance type of this instance, like the results of CIS benchmark rules.
+    The agent batches the items with those other documents report and submits them with PutComplianceItems,
+    the items replace the items the instance reported before for the compliance type.
+
+    The items can be linked to the association or command that checked them.
+
+SYNOPSIS
+    {{.PutComplianceItemsCommandName}}
+    {{.ComplianceTypeFlag}} <value>
+    {{.ItemsFlag}} <value>
+    [{{.AssociationIdFlag}} <value> | {{.CommandIdFlag}} <value>]
+
+PARAMETERS
+    {{.ComplianceTypeFlag}} (string) Compliance type starting with Custom:, like Custom:CIS.
+    {{.ItemsFlag}} (string) JSON array of items with Id, Title, Severity, Status and Details, or file:// followed by the path to one.
+    {{.AssociationIdFlag}} (string) Association of the instance that checked the items.
+    {{.CommandIdFlag}} (string) Command that checked the items.
+
+EXAMPLES
+    Command:
+
+      {{.SsmCliName}} {{.PutComplianceItemsCommandName}} {{.ComplianceTypeFlag}} Custom:CIS {{.ItemsFlag}} file://cis.json
+
+    Output:
+      {
+        "ComplianceType": "Custom:CIS",
+        "CompliantCount": 41,
+        "NonCompliantCount": 2,
+        ...
+      }
+
+OUTPUT
+    JSON summary of the items by status and severity
+`
+
+type putComplianceItemsHelpParams struct {
+	SsmCliName                    string
+	PutComplianceItemsCommandName string
+	ComplianceTypeFlag            string
+	ItemsFlag                     string
+	AssociationIdFlag             string
+	CommandIdFlag                 string
+}
+
+// sendLocalApi is the dependency used to send the items to the agent
+var sendLocalApi = localapi.Send
+
+func init() {
+	cliutil.Register(&PutComplianceItemsCommand{})
+}
+
+type PutComplianceItemsCommand struct {
+	helpText string
+}
+
+// Execute validates and executes the put-compliance-items cli command
+func (c *PutComplianceItemsCommand) Execute(subcommands []string, parameters map[string][]string) (error, string) {
+	validation := c.validatePutComplianceItemsCommandInput(subcommands, parameters)
+	// return validation errors if any were found
+	if len(validation) > 0 {
+		return errors.New(strings.Join(validation, "\n")), ""
+	}
+
+	report := model.CustomComplianceReport{ComplianceType: parameters[putComplianceItemsType][0]}
+	if err := c.loadItems(parameters[putComplianceItemsItems][0], &report.Items); err != nil {
+		return err, ""
+	}
+	if values, exists := parameters[putComplianceItemsAssociationID]; exists {
+		report.ExecutionType, report.ExecutionId = model.ExecutionTypeAssociation, values[0]
+	} else if values, exists := parameters[putComplianceItemsCommandID]; exists {
+		report.ExecutionType, report.ExecutionId = model.ExecutionTypeCommand, values[0]
+	}
+	if err := model.ValidateCustomComplianceReport(report); err != nil {
+		return err, ""
+	}
+
+	resp, err := sendLocalApi(localapi.Request{Verb: localapi.VerbPutComplianceItems, Compliance: &report})
+	if err != nil {
+		return fmt.Errorf("failed to send the items to the agent: %v", err), ""
+	}
+	output, err := json.MarshalIndent(resp.ComplianceSummary, "", "  ")
+	if err != nil {
+		return err, ""
+	}
+	return nil, string(output)
+}
+
+// Help prints help for the put-compliance-items cli command
+func (c *PutComplianceItemsCommand) Help() string {
+	if len(c.helpText) == 0 {
+		t, _ := template.New("PutComplianceItemsCommandHelp").Parse(putComplianceItemsCommandHelp)
+		params := putComplianceItemsHelpParams{
+			cliutil.SsmCliName,
+			putComplianceItemsCommand,
+			cliutil.FormatFlag(putComplianceItemsType),
+			cliutil.FormatFlag(putComplianceItemsItems),
+			cliutil.FormatFlag(putComplianceItemsAssociationID),
+			cliutil.FormatFlag(putComplianceItemsCommandID),
+		}
+		buf := new(bytes.Buffer)
+		t.Execute(buf, params)
+		c.helpText = buf.String()
+	}
+	return c.helpText
+}
+
+// Name is the command name used in the cli
+func (PutComplianceItemsCommand) Name() string {
+	return putComplianceItemsCommand
+}
+
+// validatePutComplianceItemsCommandInput checks the subcommands and parameters for required values and unsupported values
+func (PutComplianceItemsCommand) validatePutComplianceItemsCommandInput(subcommands []string, parameters map[string][]string) []string {
+	validation := make([]string, 0)
+	if len(subcommands) > 0 {
+		validation = append(validation, fmt.Sprintf("%v does not support subcommand %v", putComplianceItemsCommand, subcommands), "")
+		return validation
+	}
+
+	// look for required parameters
+	for _, required := range []string{putComplianceItemsType, putComplianceItemsItems} {
+		if _, exists := parameters[required]; !exists {
+			validation = append(validation, fmt.Sprintf("%v is required", cliutil.FormatFlag(required)))
+		}
+	}
+	_, hasAssociation := parameters[putComplianceItemsAssociationID]
+	_, hasCommand := parameters[putComplianceItemsCommandID]
+	if hasAssociation && hasCommand {
+		validation = append(validation, fmt.Sprintf("%v and %v cannot be used together",
+			cliutil.FormatFlag(putComplianceItemsAssociationID), cliutil.FormatFlag(putComplianceItemsCommandID)))
+	}
+
+	for key, values := range parameters {
+		switch key {
+		case putComplianceItemsType, putComplianceItemsItems, putComplianceItemsAssociationID, putComplianceItemsCommandID:
+			if len(values) != 1 {
+				validation = append(validation, fmt.Sprintf("expected 1 value for parameter %v", cliutil.FormatFlag(key)))
+			}
+		default:
+			validation = append(validation, fmt.Sprintf("unknown parameter %v", cliutil.FormatFlag(key)))
+		}
+	}
+	return validation
+}
+
+// loadItems reads the items from an inline JSON array or a file:// path
+func (PutComplianceItemsCommand) loadItems(value string, items *[]model.CustomComplianceItem) error {
+	raw := []byte(value)
+	if strings.HasPrefix(strings.ToLower(value), fileUrlPrefix) {
+		path := value[len(fileUrlPrefix):]
+		var err error
+		if raw, err = ioutil.ReadFile(path); err != nil {
+			return fmt.Errorf("failed to read items %v: %v", path, err)
+		}
+	}
+	if err := json.Unmarshal(raw, items); err != nil {
+		return fmt.Errorf("items must be a json array: %v", err)
+	}
+	return nil
+}
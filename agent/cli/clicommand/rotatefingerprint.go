@@ -0,0 +1,117 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package clicommand contains the implementation of all commands for the ssm agent cli
+package clicommand
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"text/template"
+
+	"github.com/aws/amazon-ssm-agent/agent/cli/cliutil"
+	"github.com/aws/amazon-ssm-agent/agent/managedInstances/fingerprint"
+)
+
+const (
+	rotateFingerprintCommand = "rotate-fingerprint"
+)
+
+const rotateFingerprintCommandHelp = `NAME:
+    {{.RotateFingerprintCommandName}}
+
+DESCRIPTION
+    Replaces the fingerprint of this managed instance with a new one generated for the current hardware.
+    Run it on a cloned machine so it stops sharing the fingerprint of the machine it was cloned from.
+
+    The managed instance registration is bound to the previous fingerprint, register the instance
+    again with a new activation and restart the agent after rotating the fingerprint.
+
+SYNOPSIS
+    {{.RotateFingerprintCommandName}}
+
+EXAMPLES
+    Command:
+
+      {{.SsmCliName}} {{.RotateFingerprintCommandName}}
+
+    Output:
+      Fingerprint rotated, register the instance again and restart the agent.
+
+OUTPUT
+    Confirmation that the fingerprint was rotated
+`
+
+type rotateFingerprintHelpParams struct {
+	SsmCliName                   string
+	RotateFingerprintCommandName string
+}
+
+// rotateFingerprint is the dependency used to replace the saved fingerprint
+var rotateFingerprint = fingerprint.RotateFingerprint
+
+func init() {
+	cliutil.Register(&RotateFingerprintCommand{})
+}
+
+type RotateFingerprintCommand struct {
+	helpText string
+}
+
+// Execute validates and executes the rotate-fingerprint cli command
+func (c *RotateFingerprintCommand) Execute(subcommands []string, parameters map[string][]string) (error, string) {
+	validation := c.validateRotateFingerprintCommandInput(subcommands, parameters)
+	// return validation errors if any were found
+	if len(validation) > 0 {
+		return errors.New(strings.Join(validation, "\n")), ""
+	}
+
+	if _, err := rotateFingerprint(); err != nil {
+		return err, ""
+	}
+	return nil, "Fingerprint rotated, register the instance again and restart the agent."
+}
+
+// Help prints help for the rotate-fingerprint cli command
+func (c *RotateFingerprintCommand) Help() string {
+	if len(c.helpText) == 0 {
+		t, _ := template.New("RotateFingerprintCommandHelp").Parse(rotateFingerprintCommandHelp)
+		params := rotateFingerprintHelpParams{cliutil.SsmCliName, rotateFingerprintCommand}
+		buf := new(bytes.Buffer)
+		t.Execute(buf, params)
+		c.helpText = buf.String()
+	}
+	return c.helpText
+}
+
+// Name is the command name used in the cli
+func (RotateFingerprintCommand) Name() string {
+	return rotateFingerprintCommand
+}
+
+// validateRotateFingerprintCommandInput checks the subcommands and parameters for unsupported values
+func (RotateFingerprintCommand) validateRotateFingerprintCommandInput(subcommands []string, parameters map[string][]string) []string {
+	validation := make([]string, 0)
+	if len(subcommands) > 0 {
+		validation = append(validation, fmt.Sprintf("%v does not support subcommand %v", rotateFingerprintCommand, subcommands), "")
+		return validation
+	}
+
+	// look for unsupported parameters
+	for key := range parameters {
+		validation = append(validation, fmt.Sprintf("unknown parameter %v", cliutil.FormatFlag(key)))
+	}
+	return validation
+}
@@ -179,7 +179,7 @@ func (SendOfflineCommand) validateContent(content contracts.DocumentContent) err
 		if len(content.RuntimeConfig) == 0 {
 			return fmt.Errorf("runtimeConfig cannot be empty")
 		}
-	case "2.0", "2.0.1", "2.0.2", "2.0.3", "2.2":
+	case "2.0", "2.0.1", "2.0.2", "2.0.3", "2.2", "2.3":
 		if len(content.MainSteps) == 0 {
 			return fmt.Errorf("mainSteps cannot be empty")
 		}
@@ -0,0 +1,283 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package clicommand contains the implementation of all commands for the ssm agent cli
+package clicommand
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"strings"
+	"text/template"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/cli/cliutil"
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/ipc/localapi"
+	mgsContracts "github.com/aws/amazon-ssm-agent/agent/session/contracts"
+	"github.com/go-yaml/yaml"
+)
+
+const (
+	startSessionCommand     = "start-session"
+	startSessionType        = "session-type"
+	startSessionDocument    = "document"
+	startSessionParameters  = "parameters"
+	startSessionRunAsUser   = "run-as-user"
+	endOfTransmissionSymbol = 0x04
+)
+
+const startSessionCommandHelp = `NAME:
+    {{.StartSessionCommandName}}
+
+DESCRIPTION
+    Starts a loopback session through the local api of the agent running on this machine, without the
+    Session Manager service. The session runs in a session worker with the same plugins as a real session,
+    which validates RunAs, shell profiles and port sessions before creating real sessions.
+
+    The agent must have LocalApi.Enabled and LocalApi.LoopbackSessions set in its configuration.
+    Input is read from stdin line by line and the output of the session is written to stdout.
+    Shell sessions end when stdin is closed or the shell exits, port sessions end when the port closes the connection.
+
+SYNOPSIS
+    {{.StartSessionCommandName}}
+    [{{.SessionTypeFlag}}]
+    [{{.DocumentFlag}}]
+    [{{.ParametersFlag}}]
+    [{{.RunAsUserFlag}}]
+
+PARAMETERS
+    {{.SessionTypeFlag}} (string) Standard_Stream, InteractiveCommands or Port, Standard_Stream by default.
+    Ignored when a document is provided.
+
+    {{.DocumentFlag}} (string) file:// followed by the path to a JSON or YAML session document,
+    such as the session preferences with runAsEnabled, runAsDefaultUser and shellProfile inputs.
+
+    {{.ParametersFlag}} (string) JSON object with the session parameters, or file:// followed by the path to one.
+    Port sessions without a document take portNumber.
+
+    {{.RunAsUserFlag}} (string) RunAs user of the session, like the SSMSessionRunAs tag of an IAM identity.
+
+    The document and the RunAs user are only used when the command runs as root, the sessions of other users
+    run the default document of the session type as the user that started them.
+
+EXAMPLES
+    This example runs a command in a shell session started with the session preferences of a file.
+
+    Command:
+
+      echo whoami | {{.SsmCliName}} {{.StartSessionCommandName}} {{.DocumentFlag}} file://preferences.json
+
+    Output:
+
+      Starting session with SessionId: loopback-01234567-890a-bcde-f012-34567890abcd
+      $ whoami
+      ssm-user
+      $
+      Exiting session with sessionId: loopback-01234567-890a-bcde-f012-34567890abcd.
+
+    This example sends an http request through a port session.
+
+    Command:
+
+      printf 'GET / HTTP/1.0\r\n\r\n' | {{.SsmCliName}} {{.StartSessionCommandName}} {{.SessionTypeFlag}} Port {{.ParametersFlag}} '{"portNumber":"80"}'
+
+OUTPUT
+    Output of the session
+`
+
+type startSessionHelpParams struct {
+	SsmCliName              string
+	StartSessionCommandName string
+	SessionTypeFlag         string
+	DocumentFlag            string
+	ParametersFlag          string
+	RunAsUserFlag           string
+}
+
+// openSessionStream is the dependency used to start the session through the local api
+var openSessionStream = localapi.OpenStream
+
+func init() {
+	cliutil.Register(&StartSessionCommand{})
+}
+
+type StartSessionCommand struct {
+	helpText string
+}
+
+// Execute validates and executes the start-session cli command
+func (c *StartSessionCommand) Execute(subcommands []string, parameters map[string][]string) (error, string) {
+	validation := c.validateStartSessionCommandInput(subcommands, parameters)
+	// return validation errors if any were found
+	if len(validation) > 0 {
+		return errors.New(strings.Join(validation, "\n")), ""
+	}
+
+	sessionRequest := localapi.SessionRequest{SessionType: appconfig.PluginNameStandardStream}
+	if values, exists := parameters[startSessionType]; exists {
+		sessionRequest.SessionType = values[0]
+	}
+	if values, exists := parameters[startSessionDocument]; exists {
+		docContent, err := c.loadSessionDocument(values[0])
+		if err != nil {
+			return err, ""
+		}
+		sessionRequest.DocumentContent = &docContent
+		sessionRequest.SessionType = docContent.SessionType
+	}
+	if values, exists := parameters[startSessionParameters]; exists {
+		var err error
+		if sessionRequest.Parameters, err = (ExecuteDocumentCommand{}).loadParameters(values[0]); err != nil {
+			return err, ""
+		}
+	}
+	if values, exists := parameters[startSessionRunAsUser]; exists {
+		sessionRequest.RunAsUser = values[0]
+	}
+
+	conn, resp, err := openSessionStream(localapi.Request{Verb: localapi.VerbStartSession, Session: &sessionRequest})
+	if err != nil {
+		return fmt.Errorf("failed to start the session through the local api of the agent: %v", err), ""
+	}
+	defer conn.Close()
+
+	sessionId := resp.Message
+	fmt.Fprintf(os.Stderr, "Starting session with SessionId: %v\n", sessionId)
+	go sendSessionInput(conn, os.Stdin, sessionRequest.SessionType)
+	if err = receiveSessionOutput(conn, os.Stdout, os.Stderr); err != nil {
+		return err, ""
+	}
+	return nil, fmt.Sprintf("\nExiting session with sessionId: %v.", sessionId)
+}
+
+// Help prints help for the start-session cli command
+func (c *StartSessionCommand) Help() string {
+	if len(c.helpText) == 0 {
+		t, _ := template.New("StartSessionCommandHelp").Parse(startSessionCommandHelp)
+		params := startSessionHelpParams{
+			cliutil.SsmCliName,
+			startSessionCommand,
+			cliutil.FormatFlag(startSessionType),
+			cliutil.FormatFlag(startSessionDocument),
+			cliutil.FormatFlag(startSessionParameters),
+			cliutil.FormatFlag(startSessionRunAsUser),
+		}
+		buf := new(bytes.Buffer)
+		t.Execute(buf, params)
+		c.helpText = buf.String()
+	}
+	return c.helpText
+}
+
+// Name is the command name used in the cli
+func (StartSessionCommand) Name() string {
+	return startSessionCommand
+}
+
+// validateStartSessionCommandInput checks the subcommands and parameters for unsupported values
+func (StartSessionCommand) validateStartSessionCommandInput(subcommands []string, parameters map[string][]string) []string {
+	validation := make([]string, 0)
+	if len(subcommands) > 0 {
+		validation = append(validation, fmt.Sprintf("%v does not support subcommand %v", startSessionCommand, subcommands), "")
+		return validation
+	}
+
+	for key, values := range parameters {
+		switch key {
+		case startSessionType, startSessionRunAsUser:
+			if len(values) != 1 {
+				validation = append(validation, fmt.Sprintf("expected 1 value for parameter %v", cliutil.FormatFlag(key)))
+			}
+		case startSessionDocument:
+			if len(values) != 1 {
+				validation = append(validation, fmt.Sprintf("expected 1 value for parameter %v", cliutil.FormatFlag(key)))
+			} else if !strings.HasPrefix(strings.ToLower(values[0]), fileUrlPrefix) {
+				validation = append(validation, fmt.Sprintf("%v value must be a file:// path", cliutil.FormatFlag(key)))
+			}
+		case startSessionParameters:
+			if len(values) != 1 {
+				validation = append(validation, fmt.Sprintf("expected 1 value for parameter %v", cliutil.FormatFlag(key)))
+			} else if !cliutil.ValidJson(values[0]) && !strings.HasPrefix(strings.ToLower(values[0]), fileUrlPrefix) {
+				validation = append(validation, fmt.Sprintf("%v value must be a json object or a file:// path", cliutil.FormatFlag(key)))
+			}
+		default:
+			validation = append(validation, fmt.Sprintf("unknown parameter %v", cliutil.FormatFlag(key)))
+		}
+	}
+	return validation
+}
+
+// loadSessionDocument reads a JSON or YAML session document from a file:// path
+func (StartSessionCommand) loadSessionDocument(value string) (docContent contracts.SessionDocumentContent, err error) {
+	path := value[len(fileUrlPrefix):]
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return docContent, fmt.Errorf("failed to read session document %v: %v", path, err)
+	}
+	if err = json.Unmarshal(raw, &docContent); err != nil {
+		if err = yaml.Unmarshal(raw, &docContent); err != nil {
+			return docContent, fmt.Errorf("session document %v is not valid JSON or YAML: %v", path, err)
+		}
+	}
+	return docContent, nil
+}
+
+// sendSessionInput sends the input to the session until it is closed, then shells receive an end of transmission.
+// Port sessions end when the port closes its connection.
+func sendSessionInput(conn net.Conn, input io.Reader, sessionType string) {
+	encoder := json.NewEncoder(conn)
+	buf := make([]byte, 4096)
+	for {
+		n, err := input.Read(buf)
+		if n > 0 {
+			frame := localapi.SessionFrame{PayloadType: uint32(mgsContracts.Output), Payload: append([]byte(nil), buf[:n]...)}
+			if encoder.Encode(frame) != nil {
+				return
+			}
+		}
+		if err != nil {
+			break
+		}
+	}
+
+	if sessionType != appconfig.PluginNamePort {
+		encoder.Encode(localapi.SessionFrame{PayloadType: uint32(mgsContracts.Output), Payload: []byte{endOfTransmissionSymbol}})
+	}
+}
+
+// receiveSessionOutput writes the output of the session until the agent closes the connection
+func receiveSessionOutput(conn net.Conn, stdout io.Writer, stderr io.Writer) error {
+	decoder := json.NewDecoder(conn)
+	for {
+		var frame localapi.SessionFrame
+		if err := decoder.Decode(&frame); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return fmt.Errorf("session ended unexpectedly: %v", err)
+		}
+		switch mgsContracts.PayloadType(frame.PayloadType) {
+		case mgsContracts.Output:
+			stdout.Write(frame.Payload)
+		case mgsContracts.Error:
+			stderr.Write(frame.Payload)
+		}
+	}
+}
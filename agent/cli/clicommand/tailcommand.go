@@ -0,0 +1,326 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package clicommand contains the implementation of all commands for the ssm agent cli
+package clicommand
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/cli/cliutil"
+	"github.com/aws/amazon-ssm-agent/agent/fileutil"
+	"github.com/aws/amazon-ssm-agent/agent/framework/docmanager"
+	"github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/iohandler"
+)
+
+const (
+	tailCommand          = "tail-command"
+	tailCommandCommandID = "command-id"
+	tailCommandFollow    = "follow"
+	tailCommandStep      = "step"
+
+	// tailPollInterval is how often the output files are read again in follow mode
+	tailPollInterval = 500 * time.Millisecond
+)
+
+const tailCommandHelp = `NAME:
+    {{.TailCommandName}}
+
+DESCRIPTION
+    Writes the output files of a command run by the local amazon-ssm-agent service as they are written.
+    The output of every step is preceded by a header with the step name and the stream.
+
+SYNOPSIS
+    {{.TailCommandName}}
+    <command-id> | {{.CommandIdFlag}} <command-id>
+    [{{.FollowFlag}}]
+    [{{.StepFlag}} <step> ...]
+
+PARAMETERS
+    {{.CommandIdFlag}} (string) Command ID of a command sent to the instance or from {{.SendCommandName}}.
+
+    {{.FollowFlag}} (boolean) Keeps writing the output as it is written until the command completes.
+
+    {{.StepFlag}} (list) Names of the steps to write the output of, all steps by default.
+
+EXAMPLES
+    This example follows the output of the step runShellScript of a command.
+
+    Command:
+
+      {{.SsmCliName}} {{.TailCommandName}} 01234567-890a-bcde-f012-34567890abcd {{.FollowFlag}} {{.StepFlag}} runShellScript
+
+    Output:
+
+      ==> runShellScript stdout <==
+      Installing packages
+      Done
+
+OUTPUT
+    Output of the steps of the command
+`
+
+type tailCommandHelpParams struct {
+	SsmCliName      string
+	TailCommandName string
+	SendCommandName string
+	CommandIdFlag   string
+	FollowFlag      string
+	StepFlag        string
+}
+
+func init() {
+	cliutil.Register(&TailCommand{})
+}
+
+type TailCommand struct {
+	helpText string
+}
+
+// Execute validates and executes the tail-command cli command
+func (c *TailCommand) Execute(subcommands []string, parameters map[string][]string) (error, string) {
+	validation, commandID, follow, steps := c.validateTailCommandInput(subcommands, parameters)
+	// return validation errors if any were found
+	if len(validation) > 0 {
+		return errors.New(strings.Join(validation, "\n")), ""
+	}
+
+	instanceID, orchestrationDir := c.findCommand(commandID)
+	if instanceID == "" {
+		return &cliutil.CommandFailedError{Output: fmt.Sprintf("No output found for command ID %v", commandID)}, ""
+	}
+
+	tail := newOutputTail(orchestrationDir, steps, os.Stdout)
+	isRunning := func() bool { return follow && c.isCommandRunning(instanceID, commandID) }
+	if err := tail.follow(isRunning, tailPollInterval); err != nil {
+		return &cliutil.CommandFailedError{Output: err.Error()}, ""
+	}
+	return nil, ""
+}
+
+// Help prints help for the tail-command cli command
+func (c *TailCommand) Help() string {
+	if len(c.helpText) == 0 {
+		t, _ := template.New("TailCommandHelp").Parse(tailCommandHelp)
+		params := tailCommandHelpParams{
+			cliutil.SsmCliName,
+			tailCommand,
+			sendCommand,
+			cliutil.FormatFlag(tailCommandCommandID),
+			cliutil.FormatFlag(tailCommandFollow),
+			cliutil.FormatFlag(tailCommandStep),
+		}
+		buf := new(bytes.Buffer)
+		t.Execute(buf, params)
+		c.helpText = buf.String()
+	}
+	return c.helpText
+}
+
+// Name is the command name used in the cli
+func (TailCommand) Name() string {
+	return tailCommand
+}
+
+// validateTailCommandInput checks the subcommands and parameters for required values, format, and unsupported values.
+// The command id can be the only subcommand, the parser then also records it as a parameter without a name.
+func (TailCommand) validateTailCommandInput(subcommands []string, parameters map[string][]string) (validation []string, commandID string, follow bool, steps []string) {
+	validation = make([]string, 0)
+
+	var commandIDs []string
+	if len(subcommands) > 1 {
+		validation = append(validation, fmt.Sprintf("%v does not support subcommand %v", tailCommand, subcommands), "")
+		return validation, "", false, nil
+	} else if len(subcommands) == 1 {
+		commandIDs = subcommands
+	}
+
+	for key, values := range parameters {
+		switch key {
+		case "":
+			// the value of the command id subcommand
+		case tailCommandCommandID:
+			commandIDs = append(commandIDs, values...)
+		case tailCommandFollow:
+			follow = true
+			if len(values) > 0 {
+				validation = append(validation, fmt.Sprintf("flag %v should not have any values", cliutil.FormatFlag(key)))
+			}
+		case tailCommandStep:
+			if len(values) == 0 {
+				validation = append(validation, fmt.Sprintf("expected at least 1 value for parameter %v", cliutil.FormatFlag(key)))
+			}
+			steps = values
+		default:
+			validation = append(validation, fmt.Sprintf("unknown parameter %v", cliutil.FormatFlag(key)))
+		}
+	}
+
+	if len(commandIDs) == 0 {
+		validation = append(validation, fmt.Sprintf("%v is required", cliutil.FormatFlag(tailCommandCommandID)))
+	} else if len(commandIDs) != 1 {
+		validation = append(validation, fmt.Sprintf("expected 1 value for parameter %v", cliutil.FormatFlag(tailCommandCommandID)))
+	} else {
+		// must be a 36 character UUID
+		commandID = strings.ToLower(commandIDs[0])
+		if commandIdLen := len(commandID); commandIdLen != 36 {
+			validation = append(validation,
+				fmt.Sprintf("Invalid length for parameter %v.  Length was %v should be 36",
+					cliutil.FormatFlag(tailCommandCommandID), commandIdLen))
+		}
+	}
+	return validation, commandID, follow, steps
+}
+
+// findCommand returns the instance that has the state or the output of the command, and the orchestration directory
+// of the command, which may not exist yet for pending commands
+func (c TailCommand) findCommand(commandID string) (instanceID string, orchestrationDir string) {
+	// the default config is returned when the config file cannot be read
+	config, _ := appconfig.Config(false)
+
+	// TODO:MF: Find a way to get the current instanceID instead of trying all possible folders
+	dirs, _ := fileutil.GetDirectoryNames(appconfig.DefaultDataStorePath)
+	for _, dir := range dirs {
+		orchestrationDir = filepath.Join(appconfig.DefaultDataStorePath, dir, appconfig.DefaultDocumentRootDirName, config.Agent.OrchestrationRootDir, commandID)
+		if fileutil.Exists(orchestrationDir) || c.isCommandRunning(dir, commandID) {
+			return dir, orchestrationDir
+		}
+	}
+	return "", ""
+}
+
+// isCommandRunning returns whether the command is pending or in progress
+func (TailCommand) isCommandRunning(instanceID, commandID string) bool {
+	return docmanager.HasDocumentState(instanceID, appconfig.DefaultLocationOfPending, commandID) ||
+		docmanager.HasDocumentState(instanceID, appconfig.DefaultLocationOfCurrent, commandID)
+}
+
+// outputTail writes the output files of the steps of a command from where it stopped reading them
+type outputTail struct {
+	orchestrationDir string
+	steps            map[string]bool
+	out              io.Writer
+	offsets          map[string]int64
+	lastFile         string
+}
+
+// newOutputTail returns a tail of the output of the steps in the orchestration directory, all steps when steps is empty
+func newOutputTail(orchestrationDir string, steps []string, out io.Writer) *outputTail {
+	tail := &outputTail{orchestrationDir: orchestrationDir, out: out, offsets: make(map[string]int64)}
+	if len(steps) > 0 {
+		tail.steps = make(map[string]bool)
+		for _, step := range steps {
+			// the step directories are named like the steps without colons
+			tail.steps[filepath.Base(fileutil.BuildPath("", step))] = true
+		}
+	}
+	return tail
+}
+
+// follow reads the output files every interval while the command is running, and once more after it completed
+func (t *outputTail) follow(isRunning func() bool, interval time.Duration) error {
+	for {
+		// the state is read before the files, the output written before the command completes is not missed
+		running := isRunning()
+		if err := t.read(); err != nil {
+			return err
+		}
+		if !running {
+			return nil
+		}
+		time.Sleep(interval)
+	}
+}
+
+// read writes what was appended to the output files since the last read, in the order of their paths
+func (t *outputTail) read() error {
+	for _, file := range t.outputFiles() {
+		if err := t.readFile(file); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// outputFiles returns the stdout and stderr files of the steps of the command
+func (t *outputTail) outputFiles() []string {
+	config := iohandler.DefaultOutputConfig()
+	var files []string
+	filepath.Walk(t.orchestrationDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return nil
+		}
+		if name := info.Name(); name != config.StdoutFileName && name != config.StderrFileName {
+			return nil
+		}
+		if t.steps != nil && !t.steps[filepath.Base(filepath.Dir(path))] {
+			return nil
+		}
+		files = append(files, path)
+		return nil
+	})
+	sort.Strings(files)
+	return files
+}
+
+// readFile writes the content of the file after its offset, preceded by a header when another file was written last
+func (t *outputTail) readFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to open output file %v: %v", path, err)
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to read output file %v: %v", path, err)
+	}
+	offset := t.offsets[path]
+	if info.Size() < offset {
+		// the file was truncated, it is read again from the start
+		offset = 0
+	}
+	if info.Size() == offset {
+		return nil
+	}
+	if _, err = f.Seek(offset, io.SeekStart); err != nil {
+		return fmt.Errorf("failed to read output file %v: %v", path, err)
+	}
+
+	if t.lastFile != path {
+		if t.lastFile != "" {
+			fmt.Fprintln(t.out)
+		}
+		fmt.Fprintf(t.out, "==> %v %v <==\n", filepath.Base(filepath.Dir(path)), filepath.Base(path))
+		t.lastFile = path
+	}
+	n, err := io.CopyN(t.out, f, info.Size()-offset)
+	t.offsets[path] = offset + n
+	if err != nil && err != io.EOF {
+		return fmt.Errorf("failed to read output file %v: %v", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,151 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package clicommand
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/cli/cliutil"
+	"github.com/stretchr/testify/assert"
+)
+
+const tailTestCommandID = "01234567-890a-bcde-f012-34567890abcd"
+
+// writeStepOutput writes or appends to an output file of a step in the orchestration directory
+func writeStepOutput(t *testing.T, orchestrationDir string, step string, stream string, content string, appendContent bool) string {
+	path := filepath.Join(orchestrationDir, step, stream)
+	assert.NoError(t, os.MkdirAll(filepath.Dir(path), appconfig.ReadWriteExecuteAccess))
+	flags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	if appendContent {
+		flags = os.O_CREATE | os.O_WRONLY | os.O_APPEND
+	}
+	file, err := os.OpenFile(path, flags, appconfig.ReadWriteAccess)
+	assert.NoError(t, err)
+	file.WriteString(content)
+	file.Close()
+	return path
+}
+
+func TestOutputTailReadsFromTheOffset(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "tail")
+	defer os.RemoveAll(dir)
+	var out bytes.Buffer
+	tail := newOutputTail(dir, nil, &out)
+
+	stdout := writeStepOutput(t, dir, "runShellScript", "stdout", "first\n", false)
+	assert.NoError(t, tail.read())
+	assert.Equal(t, "==> runShellScript stdout <==\nfirst\n", out.String())
+
+	// nothing new is written when the files did not change
+	out.Reset()
+	assert.NoError(t, tail.read())
+	assert.Empty(t, out.String())
+
+	// appended output is written without a header while the same file is read
+	writeStepOutput(t, dir, "runShellScript", "stdout", "second\n", true)
+	assert.NoError(t, tail.read())
+	assert.Equal(t, "second\n", out.String())
+	assert.Equal(t, int64(len("first\nsecond\n")), tail.offsets[stdout])
+
+	// output of another file is preceded by a header again
+	out.Reset()
+	writeStepOutput(t, dir, "runShellScript", "stderr", "warning\n", false)
+	assert.NoError(t, tail.read())
+	assert.Equal(t, "\n==> runShellScript stderr <==\nwarning\n", out.String())
+
+	// a truncated file is read again from the start
+	out.Reset()
+	writeStepOutput(t, dir, "runShellScript", "stdout", "new\n", false)
+	assert.NoError(t, tail.read())
+	assert.Equal(t, "\n==> runShellScript stdout <==\nnew\n", out.String())
+}
+
+func TestOutputTailFiltersSteps(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "tail")
+	defer os.RemoveAll(dir)
+	var out bytes.Buffer
+	tail := newOutputTail(dir, []string{"aws:runShellScript"}, &out)
+
+	writeStepOutput(t, dir, "awsrunShellScript", "stdout", "kept\n", false)
+	writeStepOutput(t, dir, "other", "stdout", "skipped\n", false)
+	writeStepOutput(t, dir, "awsrunShellScript", "trace", "not an output file\n", false)
+	assert.NoError(t, tail.read())
+	assert.Equal(t, "==> awsrunShellScript stdout <==\nkept\n", out.String())
+}
+
+func TestOutputTailWithoutOutput(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "tail")
+	defer os.RemoveAll(dir)
+	var out bytes.Buffer
+
+	// a pending command has no orchestration directory yet
+	tail := newOutputTail(filepath.Join(dir, tailTestCommandID), nil, &out)
+	assert.NoError(t, tail.read())
+	assert.NoError(t, tail.follow(func() bool { return false }, 0))
+	assert.Empty(t, out.String())
+}
+
+func TestOutputTailFollowsUntilTheCommandCompletes(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "tail")
+	defer os.RemoveAll(dir)
+	var out bytes.Buffer
+	tail := newOutputTail(dir, nil, &out)
+
+	// the command writes more output after every check of its state and completes on the third check
+	outputs := []string{"first\n", "second\n", "last\n"}
+	checks := 0
+	isRunning := func() bool {
+		writeStepOutput(t, dir, "runShellScript", "stdout", outputs[checks], true)
+		checks++
+		return checks < len(outputs)
+	}
+	assert.NoError(t, tail.follow(isRunning, 0))
+	assert.Equal(t, 3, checks)
+	assert.Equal(t, "==> runShellScript stdout <==\nfirst\nsecond\nlast\n", out.String())
+}
+
+func TestTailCommandWithoutOutput(t *testing.T) {
+	err, output := (&TailCommand{}).Execute([]string{tailTestCommandID}, map[string][]string{"": {tailTestCommandID}})
+	assert.IsType(t, &cliutil.CommandFailedError{}, err)
+	assert.Contains(t, err.Error(), "No output found for command ID "+tailTestCommandID)
+	assert.Empty(t, output)
+}
+
+func TestValidateTailCommandInput(t *testing.T) {
+	command := TailCommand{}
+
+	validation, commandID, follow, steps := command.validateTailCommandInput(nil,
+		map[string][]string{tailCommandCommandID: {tailTestCommandID}, tailCommandFollow: {}, tailCommandStep: {"runShellScript"}})
+	assert.Empty(t, validation)
+	assert.Equal(t, tailTestCommandID, commandID)
+	assert.True(t, follow)
+	assert.Equal(t, []string{"runShellScript"}, steps)
+
+	validation, commandID, follow, _ = command.validateTailCommandInput([]string{tailTestCommandID}, map[string][]string{"": {tailTestCommandID}})
+	assert.Empty(t, validation)
+	assert.Equal(t, tailTestCommandID, commandID)
+	assert.False(t, follow)
+
+	validation, _, _, _ = command.validateTailCommandInput(nil, map[string][]string{tailCommandFollow: {"true"}, tailCommandCommandID: {tailTestCommandID}})
+	assert.NotEmpty(t, validation)
+	validation, _, _, _ = command.validateTailCommandInput(nil, map[string][]string{tailCommandCommandID: {"short"}})
+	assert.NotEmpty(t, validation)
+	validation, _, _, _ = command.validateTailCommandInput(nil, map[string][]string{})
+	assert.NotEmpty(t, validation)
+}
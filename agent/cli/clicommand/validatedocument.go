@@ -0,0 +1,151 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package clicommand contains the implementation of all commands for the ssm agent cli
+// Package clicommand contains the implementation of all commands for the ssm agent cli
+package clicommand
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/aws/amazon-ssm-agent/agent/cli/cliutil"
+	"github.com/aws/amazon-ssm-agent/agent/framework/docparser"
+)
+
+const (
+	validateDocumentCommand = "validate-document"
+	validateDocumentPath    = "path"
+)
+
+const validateDocumentCommandHelp = `NAME:
+    {{.ValidateDocumentCommandName}}
+
+DESCRIPTION
+    Validates an SSM command document from a local file against the document schema used by the agent
+    before it runs a document, and reports the path and the reason of every violation.
+    Fragments included by schema version 2.3 documents are resolved first.
+
+SYNOPSIS
+    {{.ValidateDocumentCommandName}}
+    {{.PathFlag}}
+
+PARAMETERS
+    {{.PathFlag}} (string) Path to a JSON or YAML command document.
+
+EXAMPLES
+    This example validates a document with a step without action.
+
+    Command:
+
+      {{.SsmCliName}} {{.ValidateDocumentCommandName}} {{.PathFlag}} ./doc.yaml
+
+    Output:
+
+      Document is not valid:
+        mainSteps[1].action: is required
+
+OUTPUT
+    The violations of the document, or a confirmation that the document is valid
+`
+
+type validateDocumentHelpParams struct {
+	SsmCliName                  string
+	ValidateDocumentCommandName string
+	PathFlag                    string
+}
+
+func init() {
+	cliutil.Register(&ValidateDocumentCommand{})
+}
+
+type ValidateDocumentCommand struct {
+	helpText string
+}
+
+// Execute validates the input and the document of the validate-document cli command
+func (c *ValidateDocumentCommand) Execute(subcommands []string, parameters map[string][]string) (error, string) {
+	validation := c.validateValidateDocumentCommandInput(subcommands, parameters)
+	// return validation errors if any were found
+	if len(validation) > 0 {
+		return errors.New(strings.Join(validation, "\n")), ""
+	}
+
+	path := parameters[validateDocumentPath][0]
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read document %v: %v", path, err), ""
+	}
+	if raw, err = docparser.ResolveDocument(raw, filepath.Dir(path)); err != nil {
+		return fmt.Errorf("failed to resolve document %v: %v", path, err), ""
+	}
+	if err = docparser.ValidateDocument(raw); err != nil {
+		if _, ok := err.(docparser.ValidationErrors); ok {
+			return &cliutil.CommandFailedError{Output: err.Error()}, ""
+		}
+		return fmt.Errorf("document %v is not valid JSON or YAML: %v", path, err), ""
+	}
+	return nil, fmt.Sprintf("Document %v is valid", path)
+}
+
+// Help prints help for the validate-document cli command
+func (c *ValidateDocumentCommand) Help() string {
+	if len(c.helpText) == 0 {
+		t, _ := template.New("ValidateDocumentCommandHelp").Parse(validateDocumentCommandHelp)
+		params := validateDocumentHelpParams{
+			cliutil.SsmCliName,
+			validateDocumentCommand,
+			cliutil.FormatFlag(validateDocumentPath),
+		}
+		buf := new(bytes.Buffer)
+		t.Execute(buf, params)
+		c.helpText = buf.String()
+	}
+	return c.helpText
+}
+
+// Name is the command name used in the cli
+func (ValidateDocumentCommand) Name() string {
+	return validateDocumentCommand
+}
+
+// validateValidateDocumentCommandInput checks the subcommands and parameters for required values and unsupported values
+func (ValidateDocumentCommand) validateValidateDocumentCommandInput(subcommands []string, parameters map[string][]string) []string {
+	validation := make([]string, 0)
+	if len(subcommands) > 0 {
+		validation = append(validation, fmt.Sprintf("%v does not support subcommand %v", validateDocumentCommand, subcommands), "")
+		return validation
+	}
+
+	// look for required parameters
+	if _, exists := parameters[validateDocumentPath]; !exists {
+		validation = append(validation, fmt.Sprintf("%v is required", cliutil.FormatFlag(validateDocumentPath)))
+	}
+
+	for key, values := range parameters {
+		switch key {
+		case validateDocumentPath:
+			if len(values) != 1 {
+				validation = append(validation, fmt.Sprintf("expected 1 value for parameter %v", cliutil.FormatFlag(key)))
+			}
+		default:
+			validation = append(validation, fmt.Sprintf("unknown parameter %v", cliutil.FormatFlag(key)))
+		}
+	}
+	return validation
+}
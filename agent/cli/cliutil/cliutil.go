@@ -100,3 +100,13 @@ func ValidUrl(s string) bool {
 	}
 	return false
 }
+
+// CommandFailedError is returned by a command that ran but did not succeed,
+// its output is displayed without the usage text
+type CommandFailedError struct {
+	Output string
+}
+
+func (e *CommandFailedError) Error() string {
+	return e.Output
+}
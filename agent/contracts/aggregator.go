@@ -42,6 +42,7 @@ func prepareRuntimeStatus(log log.T, pluginResult PluginResult) PluginRuntimeSta
 		EndDateTime:    times.ToIso8601UTC(pluginResult.EndDateTime),
 		StandardOutput: pluginResult.StandardOutput,
 		StandardError:  pluginResult.StandardError,
+		OutputManifest: pluginResult.OutputManifest,
 	}
 
 	if pluginResult.OutputS3BucketName != "" {
@@ -52,6 +53,11 @@ func prepareRuntimeStatus(log log.T, pluginResult PluginResult) PluginRuntimeSta
 		runtimeStatus.StepName = pluginResult.StepName
 	}
 
+	// the service does not know the interrupted and rejected statuses, they are reported as failures of the step
+	if runtimeStatus.Status == ResultStatusInterruptedByRestart || runtimeStatus.Status == ResultStatusRejectedByPolicy {
+		runtimeStatus.Status = ResultStatusFailed
+	}
+
 	if runtimeStatus.Status == ResultStatusFailed && runtimeStatus.Code == 0 {
 		runtimeStatus.Code = 1
 	}
@@ -92,7 +98,8 @@ func DocumentResultAggregator(log log.T,
 		// Skipped is a form of success
 		successCounts := runtimeStatusCounts[string(ResultStatusSuccess)] + runtimeStatusCounts[string(ResultStatusSkipped)]
 
-		if runtimeStatusCounts[string(ResultStatusSuccessAndReboot)] > 0 {
+		// a step that passed and requires a reboot reboots the instance before the next steps run, like a step rerun after a reboot
+		if runtimeStatusCounts[string(ResultStatusSuccessAndReboot)] > 0 || runtimeStatusCounts[string(ResultStatusPassedAndReboot)] > 0 {
 			documentStatus = ResultStatusSuccessAndReboot
 		} else if runtimeStatusCounts[string(ResultStatusFailed)] > 0 {
 			documentStatus = ResultStatusFailed
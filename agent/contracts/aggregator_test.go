@@ -55,6 +55,19 @@ func TestPrepareRuntimeStatus(t *testing.T) {
 	pluginResult := PluginResult{Error: fmt.Sprintf("Plugin failed with error code 1")}
 	runtimeStatus := prepareRuntimeStatus(logger, pluginResult)
 	assert.Equal(t, pluginResult.Error, runtimeStatus.Output)
+
+	// a step interrupted by a restart is reported as failed
+	pluginResult = PluginResult{Status: ResultStatusInterruptedByRestart, Error: "interrupted"}
+	runtimeStatus = prepareRuntimeStatus(logger, pluginResult)
+	assert.Equal(t, ResultStatusFailed, runtimeStatus.Status)
+	assert.Equal(t, 1, runtimeStatus.Code)
+	assert.Equal(t, "interrupted", runtimeStatus.Output)
+
+	// a step rejected by the execution policy is reported as failed
+	pluginResult = PluginResult{Status: ResultStatusRejectedByPolicy, Error: "rejected"}
+	runtimeStatus = prepareRuntimeStatus(logger, pluginResult)
+	assert.Equal(t, ResultStatusFailed, runtimeStatus.Status)
+	assert.Equal(t, "rejected", runtimeStatus.Output)
 	return
 }
 
@@ -95,6 +108,16 @@ func TestDocumentStatus(t *testing.T) {
 			},
 			Output: ResultStatusSuccessAndReboot,
 		},
+		{
+			Input: map[string]*PluginResult{
+				"installUpdate": &PluginResult{
+					PluginName: "aws:runPowerShellScript",
+					Code:       3010,
+					Status:     "PassedAndReboot",
+				},
+			},
+			Output: ResultStatusSuccessAndReboot,
+		},
 		{
 			Input: map[string]*PluginResult{
 				"aws:runScript": &PluginResult{
@@ -75,6 +75,12 @@ type DocumentInfo struct {
 	ProcInfo        OSProcInfo
 	ClientId        string
 	RunAsUser       string
+	// DocumentHash is the sha256 of the document content before its parameters were resolved
+	DocumentHash string `json:",omitempty"`
+	// RedactedParameters are the parameters of the document with every value but parameter store references masked
+	RedactedParameters map[string]string `json:",omitempty"`
+	// ApprovalToken is the signature of the command ID passed in the ApprovalToken parameter of the document
+	ApprovalToken string `json:",omitempty"`
 }
 
 //CloudWatchConfiguration represents information relevant to command output in cloudWatch
@@ -89,7 +95,10 @@ type IOConfiguration struct {
 	OrchestrationDirectory string
 	OutputS3BucketName     string
 	OutputS3KeyPrefix      string
+	OutputS3Settings       S3ObjectSettings
 	CloudWatchConfig       CloudWatchConfiguration
+	// ExpectedStdoutSha256 is set for each step from its configuration, standard output with this hash is not uploaded
+	ExpectedStdoutSha256 string
 }
 
 // DocumentState represents information relevant to a command that gets executed by agent
@@ -100,6 +109,9 @@ type DocumentState struct {
 	InstancePluginsInformation []PluginState
 	CancelInformation          CancelCommandInfo
 	IOConfig                   IOConfiguration
+	ExecutionContext           ExecutionContext
+	Urgent                     bool
+	SchedulingClass            string
 }
 
 // IsRebootRequired returns if reboot is needed
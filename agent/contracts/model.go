@@ -40,6 +40,10 @@ const (
 	ResultStatusSkipped ResultStatus = "Skipped"
 	// ResultStatusTestFailure represents test failure
 	ResultStatusTestFailure ResultStatus = "TestFailure"
+	// ResultStatusInterruptedByRestart represents a step that was running when the agent or host restarted and could not be resumed
+	ResultStatusInterruptedByRestart ResultStatus = "InterruptedByRestart"
+	// ResultStatusRejectedByPolicy represents a step that was not run because the execution policy of the agent rejected its document
+	ResultStatusRejectedByPolicy ResultStatus = "RejectedByPolicy"
 )
 
 // IsSuccess checks whether the result is success or not
@@ -72,6 +76,8 @@ func MergeResultStatus(current ResultStatus, new ResultStatus) (merged ResultSta
 		ResultStatusNotStarted,
 		ResultStatusInProgress,
 		ResultStatusFailed,
+		ResultStatusInterruptedByRestart,
+		ResultStatusRejectedByPolicy,
 		ResultStatusCancelled,
 		ResultStatusTimedOut,
 	}
@@ -183,6 +189,21 @@ type InstancePluginConfig struct {
 	Settings      interface{}         `json:"settings" yaml:"settings"`
 	Timeout       int                 `json:"timeoutSeconds" yaml:"timeoutSeconds"`
 	Preconditions map[string][]string `json:"precondition" yaml:"precondition"`
+	// RebootIfRequired completes the step when it requires a reboot, then reboots and resumes with the next step
+	RebootIfRequired bool `json:"rebootIfRequired,omitempty" yaml:"rebootIfRequired,omitempty"`
+	// RebootHooks are run before the reboot the step requires and after it, before the document continues
+	RebootHooks *RebootHooks `json:"rebootHooks,omitempty" yaml:"rebootHooks,omitempty"`
+	// ExpectedOutputSha256 is the hex SHA256 of the usual standard output of the step, output with this hash is
+	// reported as its hash and a sample instead of being uploaded
+	ExpectedOutputSha256 string `json:"expectedOutputSha256,omitempty" yaml:"expectedOutputSha256,omitempty"`
+}
+
+// RebootHooks are the commands a step runs around the reboot it requires. PreReboot checkpoints the data the next steps
+// need in the scratch directory of the document, PostReboot validates the instance after the reboot. A failing PreReboot
+// hook fails the step instead of rebooting, a failing PostReboot hook fails the step and skips the rest of the document.
+type RebootHooks struct {
+	PreReboot  []string `json:"preReboot,omitempty" yaml:"preReboot,omitempty"`
+	PostReboot []string `json:"postReboot,omitempty" yaml:"postReboot,omitempty"`
 }
 
 // DocumentContent object which represents ssm document content.
@@ -192,6 +213,43 @@ type DocumentContent struct {
 	RuntimeConfig map[string]*PluginConfig `json:"runtimeConfig" yaml:"runtimeConfig"`
 	MainSteps     []*InstancePluginConfig  `json:"mainSteps" yaml:"mainSteps"`
 	Parameters    map[string]*Parameter    `json:"parameters" yaml:"parameters"`
+	// Variables are computed from the parameters with expressions like {{ upper name }} and used like parameters
+	Variables map[string]string `json:"variables,omitempty" yaml:"variables,omitempty"`
+	// ExecutionContext overrides the SELinux domain or AppArmor profile of the document worker
+	ExecutionContext *ExecutionContext `json:"executionContext,omitempty" yaml:"executionContext,omitempty"`
+	// OutputS3Settings overrides the encryption, bucket owner and acl of the output uploaded to S3
+	OutputS3Settings *S3ObjectSettings `json:"outputS3Settings,omitempty" yaml:"outputS3Settings,omitempty"`
+	// Priority set to urgent starts a small document ahead of other queued documents
+	Priority string `json:"priority,omitempty" yaml:"priority,omitempty"`
+	// SchedulingClass tells whether the document mostly uses the CPU or waits for the disk and the network
+	SchedulingClass string `json:"schedulingClass,omitempty" yaml:"schedulingClass,omitempty"`
+	// MaxReboots limits how many times the steps of the document can reboot the instance
+	MaxReboots int `json:"maxReboots,omitempty" yaml:"maxReboots,omitempty"`
+}
+
+// DocumentPriorityUrgent is the document priority that starts a small document ahead of other queued documents
+const DocumentPriorityUrgent = "urgent"
+
+const (
+	// SchedulingClassCPU is the scheduling class of documents that mostly use the CPU
+	SchedulingClassCPU = "cpu"
+	// SchedulingClassIO is the scheduling class of documents that mostly wait for the disk or the network
+	SchedulingClassIO = "io"
+)
+
+// ExecutionContext is the Linux security context a document or session worker is confined to
+type ExecutionContext struct {
+	SELinuxDomain   string `json:"seLinuxDomain" yaml:"seLinuxDomain"`
+	AppArmorProfile string `json:"appArmorProfile" yaml:"appArmorProfile"`
+}
+
+// S3ObjectSettings are the server side encryption, expected bucket owner and acl of objects written to S3,
+// empty fields use the S3 settings of the agent configuration
+type S3ObjectSettings struct {
+	ServerSideEncryption string `json:"serverSideEncryption" yaml:"serverSideEncryption"`
+	SSEKMSKeyId          string `json:"sseKmsKeyId" yaml:"sseKmsKeyId"`
+	ExpectedBucketOwner  string `json:"expectedBucketOwner" yaml:"expectedBucketOwner"`
+	ObjectACL            string `json:"objectAcl" yaml:"objectAcl"`
 }
 
 // SessionInputs stores session configuration
@@ -202,6 +260,8 @@ type SessionInputs struct {
 	CloudWatchLogGroupName      string             `json:"cloudWatchLogGroupName" yaml:"cloudWatchLogGroupName"`
 	CloudWatchEncryptionEnabled bool               `json:"cloudWatchEncryptionEnabled" yaml:"cloudWatchEncryptionEnabled"`
 	KmsKeyId                    string             `json:"kmsKeyId" yaml:"kmsKeyId"`
+	KmsEncryptionContext        map[string]string  `json:"kmsEncryptionContext" yaml:"kmsEncryptionContext"`
+	KmsGrantTokens              []string           `json:"kmsGrantTokens" yaml:"kmsGrantTokens"`
 	RunAsEnabled                bool               `json:"runAsEnabled" yaml:"runAsEnabled"`
 	RunAsDefaultUser            string             `json:"runAsDefaultUser" yaml:"runAsDefaultUser"`
 	ShellProfile                ShellProfileConfig `json:"shellProfile" yaml:"shellProfile"`
@@ -221,6 +281,8 @@ type SessionDocumentContent struct {
 	Inputs        SessionInputs         `json:"inputs" yaml:"inputs"`
 	Parameters    map[string]*Parameter `json:"parameters" yaml:"parameters"`
 	Properties    interface{}           `json:"properties" yaml:"properties"`
+	// ExecutionContext overrides the SELinux domain or AppArmor profile of the session worker
+	ExecutionContext *ExecutionContext `json:"executionContext,omitempty" yaml:"executionContext,omitempty"`
 }
 
 // AdditionalInfo section in agent response
@@ -242,17 +304,18 @@ type AgentInfo struct {
 
 // PluginRuntimeStatus represents plugin runtime status section in agent response
 type PluginRuntimeStatus struct {
-	Status             ResultStatus `json:"status"`
-	Code               int          `json:"code"`
-	Name               string       `json:"name"`
-	Output             string       `json:"output"`
-	StartDateTime      string       `json:"startDateTime"`
-	EndDateTime        string       `json:"endDateTime"`
-	OutputS3BucketName string       `json:"outputS3BucketName"`
-	OutputS3KeyPrefix  string       `json:"outputS3KeyPrefix"`
-	StepName           string       `json:"stepName"`
-	StandardOutput     string       `json:"standardOutput"`
-	StandardError      string       `json:"standardError"`
+	Status             ResultStatus    `json:"status"`
+	Code               int             `json:"code"`
+	Name               string          `json:"name"`
+	Output             string          `json:"output"`
+	StartDateTime      string          `json:"startDateTime"`
+	EndDateTime        string          `json:"endDateTime"`
+	OutputS3BucketName string          `json:"outputS3BucketName"`
+	OutputS3KeyPrefix  string          `json:"outputS3KeyPrefix"`
+	StepName           string          `json:"stepName"`
+	StandardOutput     string          `json:"standardOutput"`
+	StandardError      string          `json:"standardError"`
+	OutputManifest     *OutputManifest `json:"outputManifest,omitempty"`
 }
 
 // AgentConfiguration is a struct that stores information about the agent and instance
@@ -28,19 +28,42 @@ const (
 
 // PluginResult represents a plugin execution result.
 type PluginResult struct {
-	PluginID           string       `json:"pluginID"`
-	PluginName         string       `json:"pluginName"`
-	Status             ResultStatus `json:"status"`
-	Code               int          `json:"code"`
-	Output             interface{}  `json:"output"`
-	StartDateTime      time.Time    `json:"startDateTime"`
-	EndDateTime        time.Time    `json:"endDateTime"`
-	OutputS3BucketName string       `json:"outputS3BucketName"`
-	OutputS3KeyPrefix  string       `json:"outputS3KeyPrefix"`
-	StepName           string       `json:"stepName"`
-	Error              string       `json:"error"`
-	StandardOutput     string       `json:"standardOutput"`
-	StandardError      string       `json:"standardError"`
+	PluginID           string          `json:"pluginID"`
+	PluginName         string          `json:"pluginName"`
+	Status             ResultStatus    `json:"status"`
+	Code               int             `json:"code"`
+	Output             interface{}     `json:"output"`
+	StartDateTime      time.Time       `json:"startDateTime"`
+	EndDateTime        time.Time       `json:"endDateTime"`
+	OutputS3BucketName string          `json:"outputS3BucketName"`
+	OutputS3KeyPrefix  string          `json:"outputS3KeyPrefix"`
+	StepName           string          `json:"stepName"`
+	Error              string          `json:"error"`
+	StandardOutput     string          `json:"standardOutput"`
+	StandardError      string          `json:"standardError"`
+	OutputManifest     *OutputManifest `json:"outputManifest,omitempty"`
+	// RebootCount is how many times the step rebooted the instance
+	RebootCount int `json:"rebootCount,omitempty"`
+}
+
+// OutputManifest describes the standard output and error of a step that were truncated to fit in the reply,
+// and where their full content was sent.
+type OutputManifest struct {
+	TruncationStrategy string                `json:"truncationStrategy"`
+	StandardOutput     *OutputStreamManifest `json:"standardOutput,omitempty"`
+	StandardError      *OutputStreamManifest `json:"standardError,omitempty"`
+}
+
+// OutputStreamManifest describes one truncated output stream, the hash is the hex SHA256 of the full stream.
+type OutputStreamManifest struct {
+	TotalBytes          int    `json:"totalBytes"`
+	ReturnedBytes       int    `json:"returnedBytes"`
+	Sha256              string `json:"sha256"`
+	LocalPath           string `json:"localPath"`
+	S3Uri               string `json:"s3Uri,omitempty"`
+	WebhookKey          string `json:"webhookKey,omitempty"`
+	CloudWatchLogGroup  string `json:"cloudWatchLogGroup,omitempty"`
+	CloudWatchLogStream string `json:"cloudWatchLogStream,omitempty"`
 }
 
 // IPlugin is interface for authoring a functionality of work.
@@ -93,9 +116,21 @@ type Configuration struct {
 	SessionId                   string
 	ClientId                    string
 	KmsKeyId                    string
+	KmsEncryptionContext        map[string]string
+	KmsGrantTokens              []string
 	RunAsEnabled                bool
 	RunAsUser                   string
 	ShellProfile                ShellProfileConfig
+	// LoopbackToken is set for sessions started through the local api, their data channel attaches to the agent
+	LoopbackToken string
+	// RebootIfRequired completes the step when it requires a reboot, then reboots and resumes with the next step
+	RebootIfRequired bool
+	// MaxReboots limits how many times the steps of the document can reboot the instance
+	MaxReboots int
+	// RebootHooks are run before the reboot the step requires and after it
+	RebootHooks *RebootHooks
+	// ExpectedOutputSha256 is the hash of the standard output that is reported without being uploaded
+	ExpectedOutputSha256 string
 }
 
 // Plugin wraps the plugin configuration and plugin result.
@@ -0,0 +1,184 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/sdkutil"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/arn"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/eventbridge"
+	"github.com/aws/aws-sdk-go/service/eventbridge/eventbridgeiface"
+	"github.com/aws/aws-sdk-go/service/sns"
+	"github.com/aws/aws-sdk-go/service/sns/snsiface"
+)
+
+const (
+	// maxCloudBatchSize is the number of events of an SNS message and of a PutEvents request
+	maxCloudBatchSize = 10
+
+	// snsEventTypesAttribute is the message attribute listing the event types of the message, for filter policies
+	snsEventTypesAttribute = "EventTypes"
+
+	// eventBridgeSource is the source of the events put on EventBridge buses
+	eventBridgeSource = "amazon-ssm-agent"
+)
+
+// cloudAwsConfig returns the aws config of the clients of a subscriber, in the region of the resource when it is an arn
+func cloudAwsConfig(config appconfig.EventSubscriberCfg, resource string) *aws.Config {
+	awsConfig := sdkutil.AwsConfig()
+	if parsed, err := arn.Parse(resource); err == nil && parsed.Region != "" {
+		awsConfig.Region = aws.String(parsed.Region)
+	}
+	awsConfig.HTTPClient = &http.Client{
+		Transport: awsConfig.HTTPClient.Transport,
+		Timeout:   time.Duration(config.TimeoutSeconds) * time.Second,
+	}
+	return awsConfig
+}
+
+// retryableEvents returns the events when the error can be retried
+func retryableEvents(events []Event, err error) []Event {
+	if request.IsErrorRetryable(err) || request.IsErrorThrottle(err) {
+		return events
+	}
+	return nil
+}
+
+// snsSubscriber publishes every batch of events as a message to a topic, the message is the JSON array of the events
+type snsSubscriber struct {
+	name     string
+	topicArn string
+	client   snsiface.SNSAPI
+}
+
+func newSnsSubscriber(config appconfig.EventSubscriberCfg) *snsSubscriber {
+	return &snsSubscriber{
+		name:     config.Name,
+		topicArn: config.SnsTopicArn,
+		client:   sns.New(sdkutil.NewSession(cloudAwsConfig(config, config.SnsTopicArn), "")),
+	}
+}
+
+// Name returns the name of the subscriber
+func (s *snsSubscriber) Name() string {
+	return s.name
+}
+
+// MaxBatchSize returns the number of events of a message
+func (s *snsSubscriber) MaxBatchSize() int {
+	return maxCloudBatchSize
+}
+
+// DeliverBatch publishes the events as a single message
+func (s *snsSubscriber) DeliverBatch(log log.T, events []Event) (retryable []Event, err error) {
+	message, err := json.Marshal(events)
+	if err != nil {
+		return nil, err
+	}
+	typeSet := make(map[string]bool)
+	var types []string
+	for _, event := range events {
+		if !typeSet[event.Type] {
+			typeSet[event.Type] = true
+			types = append(types, event.Type)
+		}
+	}
+	sort.Strings(types)
+	typesValue, _ := json.Marshal(types)
+
+	if _, err = s.client.Publish(&sns.PublishInput{
+		TopicArn: aws.String(s.topicArn),
+		Message:  aws.String(string(message)),
+		MessageAttributes: map[string]*sns.MessageAttributeValue{
+			snsEventTypesAttribute: {
+				DataType:    aws.String("String.Array"),
+				StringValue: aws.String(string(typesValue)),
+			},
+		},
+	}); err != nil {
+		return retryableEvents(events, err), err
+	}
+	return nil, nil
+}
+
+// eventBridgeSubscriber puts the events on an EventBridge bus, the type of an event is its detail type
+type eventBridgeSubscriber struct {
+	name    string
+	busName string
+	client  eventbridgeiface.EventBridgeAPI
+}
+
+func newEventBridgeSubscriber(config appconfig.EventSubscriberCfg) *eventBridgeSubscriber {
+	return &eventBridgeSubscriber{
+		name:    config.Name,
+		busName: config.EventBusName,
+		client:  eventbridge.New(sdkutil.NewSession(cloudAwsConfig(config, config.EventBusName), "")),
+	}
+}
+
+// Name returns the name of the subscriber
+func (e *eventBridgeSubscriber) Name() string {
+	return e.name
+}
+
+// MaxBatchSize returns the number of entries of a PutEvents request
+func (e *eventBridgeSubscriber) MaxBatchSize() int {
+	return maxCloudBatchSize
+}
+
+// DeliverBatch puts the events on the bus, the entries the bus failed to put can be retried
+func (e *eventBridgeSubscriber) DeliverBatch(log log.T, events []Event) (retryable []Event, err error) {
+	entries := make([]*eventbridge.PutEventsRequestEntry, len(events))
+	for i, event := range events {
+		detail, err := json.Marshal(event)
+		if err != nil {
+			return nil, err
+		}
+		entry := &eventbridge.PutEventsRequestEntry{
+			EventBusName: aws.String(e.busName),
+			Source:       aws.String(eventBridgeSource),
+			DetailType:   aws.String(event.Type),
+			Detail:       aws.String(string(detail)),
+		}
+		if eventTime, err := time.Parse(time.RFC3339, event.Time); err == nil {
+			entry.Time = aws.Time(eventTime)
+		}
+		entries[i] = entry
+	}
+
+	output, err := e.client.PutEvents(&eventbridge.PutEventsInput{Entries: entries})
+	if err != nil {
+		return retryableEvents(events, err), err
+	}
+	if aws.Int64Value(output.FailedEntryCount) == 0 {
+		return nil, nil
+	}
+	var errorCode string
+	for i, result := range output.Entries {
+		if i < len(events) && result.ErrorCode != nil {
+			retryable = append(retryable, events[i])
+			errorCode = aws.StringValue(result.ErrorCode)
+		}
+	}
+	return retryable, fmt.Errorf("the bus failed to put %v of %v events: %v", len(retryable), len(events), errorCode)
+}
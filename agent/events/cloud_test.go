@@ -0,0 +1,119 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package events
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/eventbridge"
+	"github.com/aws/aws-sdk-go/service/eventbridge/eventbridgeiface"
+	"github.com/aws/aws-sdk-go/service/sns"
+	"github.com/aws/aws-sdk-go/service/sns/snsiface"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeSns struct {
+	snsiface.SNSAPI
+	inputs []*sns.PublishInput
+	err    error
+}
+
+func (f *fakeSns) Publish(input *sns.PublishInput) (*sns.PublishOutput, error) {
+	f.inputs = append(f.inputs, input)
+	return &sns.PublishOutput{}, f.err
+}
+
+type fakeEventBridge struct {
+	eventbridgeiface.EventBridgeAPI
+	inputs []*eventbridge.PutEventsInput
+	output *eventbridge.PutEventsOutput
+	err    error
+}
+
+func (f *fakeEventBridge) PutEvents(input *eventbridge.PutEventsInput) (*eventbridge.PutEventsOutput, error) {
+	f.inputs = append(f.inputs, input)
+	return f.output, f.err
+}
+
+var testEvents = []Event{
+	{Id: "1", Type: StepFinished, Time: "2020-06-01T12:00:00Z", Detail: map[string]string{"StepName": "install"}},
+	{Id: "2", Type: CommandFinished, Time: "2020-06-01T12:00:01Z"},
+	{Id: "3", Type: StepFinished, Time: "2020-06-01T12:00:02Z"},
+}
+
+func TestSnsSubscriber(t *testing.T) {
+	client := &fakeSns{}
+	subscriber := &snsSubscriber{name: "topic", topicArn: "arn:aws:sns:us-east-1:123456789012:ops", client: client}
+
+	retryable, err := subscriber.DeliverBatch(log.NewMockLog(), testEvents)
+	assert.NoError(t, err)
+	assert.Empty(t, retryable)
+	assert.Len(t, client.inputs, 1)
+	assert.Equal(t, "arn:aws:sns:us-east-1:123456789012:ops", *client.inputs[0].TopicArn)
+	var published []Event
+	assert.NoError(t, json.Unmarshal([]byte(*client.inputs[0].Message), &published))
+	assert.Equal(t, testEvents, published)
+	attribute := client.inputs[0].MessageAttributes[snsEventTypesAttribute]
+	assert.Equal(t, "String.Array", *attribute.DataType)
+	assert.Equal(t, `["command.finished","step.finished"]`, *attribute.StringValue)
+
+	client.err = awserr.New("Throttling", "rate exceeded", nil)
+	retryable, err = subscriber.DeliverBatch(log.NewMockLog(), testEvents)
+	assert.Error(t, err)
+	assert.Equal(t, testEvents, retryable)
+
+	client.err = awserr.New("AuthorizationError", "not authorized", nil)
+	retryable, err = subscriber.DeliverBatch(log.NewMockLog(), testEvents)
+	assert.Error(t, err)
+	assert.Empty(t, retryable)
+}
+
+func TestEventBridgeSubscriber(t *testing.T) {
+	client := &fakeEventBridge{output: &eventbridge.PutEventsOutput{FailedEntryCount: aws.Int64(0)}}
+	subscriber := &eventBridgeSubscriber{name: "bus", busName: "ops", client: client}
+
+	retryable, err := subscriber.DeliverBatch(log.NewMockLog(), testEvents)
+	assert.NoError(t, err)
+	assert.Empty(t, retryable)
+	entries := client.inputs[0].Entries
+	assert.Len(t, entries, 3)
+	assert.Equal(t, "ops", *entries[0].EventBusName)
+	assert.Equal(t, eventBridgeSource, *entries[0].Source)
+	assert.Equal(t, StepFinished, *entries[0].DetailType)
+	assert.Equal(t, testTime, entries[0].Time.UTC())
+	var detail Event
+	assert.NoError(t, json.Unmarshal([]byte(*entries[0].Detail), &detail))
+	assert.Equal(t, testEvents[0], detail)
+
+	client.output = &eventbridge.PutEventsOutput{
+		FailedEntryCount: aws.Int64(1),
+		Entries: []*eventbridge.PutEventsResultEntry{
+			{EventId: aws.String("a")},
+			{ErrorCode: aws.String("InternalFailure")},
+			{EventId: aws.String("c")},
+		},
+	}
+	retryable, err = subscriber.DeliverBatch(log.NewMockLog(), testEvents)
+	assert.Error(t, err)
+	assert.Equal(t, []Event{testEvents[1]}, retryable)
+
+	client.err = awserr.New("AccessDeniedException", "not authorized", nil)
+	retryable, err = subscriber.DeliverBatch(log.NewMockLog(), testEvents)
+	assert.Error(t, err)
+	assert.Empty(t, retryable)
+}
@@ -0,0 +1,297 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package events is the bus of the lifecycle events of the agent. The documents the agent runs, their steps and the
+// sessions it opens publish events, the bus delivers them to the subscribers of the Events configuration and to the
+// subscribers of the agent itself. Every subscriber has its own queue so that a slow subscriber does not hold up the
+// others, and a circuit breaker that stops the deliveries for a while once they keep failing.
+package events
+
+import (
+	"encoding/json"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/platform"
+	"github.com/aws/amazon-ssm-agent/agent/sdkutil"
+	"github.com/twinj/uuid"
+)
+
+// Types of the lifecycle events
+const (
+	CommandStarted      = "command.started"
+	CommandFinished     = "command.finished"
+	StepFinished        = "step.finished"
+	AssociationStarted  = "association.started"
+	AssociationFinished = "association.finished"
+	SessionOpened       = "session.opened"
+	SessionClosed       = "session.closed"
+)
+
+const (
+	// queueSize is the number of events waiting for delivery to a subscriber, newer events are dropped once it is full
+	queueSize = 100
+
+	// maxRetryDelay caps the exponential backoff between the delivery attempts
+	maxRetryDelay = time.Minute
+
+	// circuitBreakerThreshold is the number of consecutive batches a subscriber failed to receive after which the
+	// circuit breaker opens, the events are then dropped without delivery until circuitBreakerCooldown passed
+	circuitBreakerThreshold = 5
+	circuitBreakerCooldown  = 5 * time.Minute
+)
+
+// Event is a lifecycle event, Detail describes the document or the session of the event
+type Event struct {
+	Id         string
+	Type       string
+	Time       string
+	InstanceId string
+	Detail     map[string]string `json:",omitempty"`
+}
+
+// Subscriber receives the events it subscribed to, Deliver returns whether a failed delivery can be attempted again
+type Subscriber interface {
+	Name() string
+	Deliver(log log.T, event Event, payload []byte) (retry bool, err error)
+}
+
+// BatchSubscriber receives the events it subscribed to in batches of up to MaxBatchSize events. DeliverBatch returns
+// the events of the batch whose delivery failed and can be attempted again.
+type BatchSubscriber interface {
+	Name() string
+	MaxBatchSize() int
+	DeliverBatch(log log.T, events []Event) (retryable []Event, err error)
+}
+
+// singleSubscriber delivers the events of a Subscriber one at a time
+type singleSubscriber struct {
+	Subscriber
+}
+
+func (s singleSubscriber) MaxBatchSize() int {
+	return 1
+}
+
+func (s singleSubscriber) DeliverBatch(log log.T, events []Event) (retryable []Event, err error) {
+	payload, err := json.Marshal(events[0])
+	if err != nil {
+		return nil, err
+	}
+	if retry, err := s.Deliver(log, events[0], payload); err != nil {
+		if retry {
+			return events, err
+		}
+		return nil, err
+	}
+	return nil, nil
+}
+
+// subscription queues the events of the types of a subscriber
+type subscription struct {
+	types       []string
+	subscriber  BatchSubscriber
+	maxAttempts int
+	queue       chan Event
+	breaker     *sdkutil.StopPolicy
+	openUntil   time.Time
+}
+
+// bus holds the subscriptions, the configured subscribers are subscribed when the first event is published
+type bus struct {
+	lock          sync.RWMutex
+	subscriptions []*subscription
+}
+
+var (
+	getAppConfig  = appconfig.Config
+	getInstanceID = platform.InstanceID
+	timeNow       = time.Now
+	sleep         = time.Sleep
+
+	// batchWindow is how long the first event of a batch waits for the events that follow it
+	batchWindow = time.Second
+
+	busOnce     sync.Once
+	busInstance = &bus{}
+)
+
+// Publish queues the event for the subscribers of its type, publishing never blocks the caller
+func Publish(log log.T, eventType string, detail map[string]string) {
+	busOnce.Do(func() { subscribeConfigured(log) })
+
+	busInstance.lock.RLock()
+	defer busInstance.lock.RUnlock()
+	if len(busInstance.subscriptions) == 0 {
+		return
+	}
+	event := Event{
+		Id:     uuid.NewV4().String(),
+		Type:   eventType,
+		Time:   timeNow().UTC().Format(time.RFC3339),
+		Detail: detail,
+	}
+	event.InstanceId, _ = getInstanceID()
+	for _, s := range busInstance.subscriptions {
+		if !s.matches(eventType) {
+			continue
+		}
+		select {
+		case s.queue <- event:
+		default:
+			log.Warnf("dropping %v event %v, the queue of subscriber %v is full", eventType, event.Id, s.subscriber.Name())
+		}
+	}
+}
+
+// Subscribe delivers the events matching one of the type patterns to the subscriber, every event when there are none
+func Subscribe(log log.T, types []string, subscriber Subscriber, maxAttempts int) {
+	SubscribeBatch(log, types, singleSubscriber{subscriber}, maxAttempts)
+}
+
+// SubscribeBatch delivers the events matching one of the type patterns to the subscriber in batches
+func SubscribeBatch(log log.T, types []string, subscriber BatchSubscriber, maxAttempts int) {
+	s := &subscription{
+		types:       types,
+		subscriber:  subscriber,
+		maxAttempts: maxAttempts,
+		queue:       make(chan Event, queueSize),
+		breaker:     sdkutil.NewStopPolicy(subscriber.Name(), circuitBreakerThreshold),
+	}
+	busInstance.lock.Lock()
+	busInstance.subscriptions = append(busInstance.subscriptions, s)
+	busInstance.lock.Unlock()
+	go s.deliver(log)
+}
+
+// subscribeConfigured subscribes the webhooks, the commands, the topics and the buses of the Events configuration
+func subscribeConfigured(log log.T) {
+	config, err := getAppConfig(false)
+	if err != nil {
+		log.Warnf("event subscribers are not loaded: %v", err)
+		return
+	}
+	for _, subscriberConfig := range config.Events.Subscribers {
+		var subscriber BatchSubscriber
+		switch {
+		case subscriberConfig.WebhookUrl != "":
+			subscriber = singleSubscriber{newWebhookSubscriber(subscriberConfig)}
+		case subscriberConfig.SnsTopicArn != "":
+			subscriber = newSnsSubscriber(subscriberConfig)
+		case subscriberConfig.EventBusName != "":
+			subscriber = newEventBridgeSubscriber(subscriberConfig)
+		default:
+			subscriber = singleSubscriber{newCommandSubscriber(subscriberConfig)}
+		}
+		log.Infof("delivering events %v to subscriber %v", subscriberConfig.Types, subscriberConfig.Name)
+		SubscribeBatch(log, subscriberConfig.Types, subscriber, subscriberConfig.MaxAttempts)
+	}
+}
+
+func (s *subscription) matches(eventType string) bool {
+	if len(s.types) == 0 {
+		return true
+	}
+	for _, pattern := range s.types {
+		if matched, _ := path.Match(pattern, eventType); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// deliver delivers the queued events in batches, in the order they were published
+func (s *subscription) deliver(log log.T) {
+	for event := range s.queue {
+		batch := s.collect(event)
+		if !s.closed(log) {
+			log.Debugf("dropping %v events, the circuit breaker of subscriber %v is open", len(batch), s.subscriber.Name())
+			continue
+		}
+		if err := s.deliverBatch(log, batch); err != nil {
+			log.Errorf("dropping %v events for subscriber %v: %v", len(batch), s.subscriber.Name(), err)
+			s.breaker.ProcessException(err)
+			if !s.breaker.IsHealthy() {
+				s.openUntil = timeNow().Add(circuitBreakerCooldown)
+				log.Errorf("opening the circuit breaker of subscriber %v for %v", s.subscriber.Name(), circuitBreakerCooldown)
+			}
+			continue
+		}
+		s.breaker.ResetErrorCount()
+	}
+}
+
+// collect returns the batch of the event and of the events queued within the batch window that follow it
+func (s *subscription) collect(event Event) []Event {
+	batch := []Event{event}
+	if s.subscriber.MaxBatchSize() <= 1 {
+		return batch
+	}
+	timer := time.NewTimer(batchWindow)
+	defer timer.Stop()
+	for len(batch) < s.subscriber.MaxBatchSize() {
+		select {
+		case next, ok := <-s.queue:
+			if !ok {
+				return batch
+			}
+			batch = append(batch, next)
+		case <-timer.C:
+			return batch
+		}
+	}
+	return batch
+}
+
+// closed returns true when the circuit breaker lets the deliveries through. Once its cooldown passed, the breaker is
+// half open and a single failed batch opens it again.
+func (s *subscription) closed(log log.T) bool {
+	if s.breaker.IsHealthy() {
+		return true
+	}
+	if timeNow().Before(s.openUntil) {
+		return false
+	}
+	log.Infof("trying subscriber %v again after the cooldown of its circuit breaker", s.subscriber.Name())
+	s.breaker.ResetErrorCount()
+	s.breaker.AddErrorCount(circuitBreakerThreshold - 1)
+	return true
+}
+
+// deliverBatch delivers the batch, attempting the events that can be retried again with exponential backoff
+func (s *subscription) deliverBatch(log log.T, batch []Event) error {
+	for attempt := 1; ; attempt++ {
+		retryable, err := s.subscriber.DeliverBatch(log, batch)
+		if err == nil {
+			log.Debugf("delivered %v events to subscriber %v", len(batch), s.subscriber.Name())
+			return nil
+		}
+		log.Warnf("attempt %v: failed to deliver %v events to subscriber %v: %v", attempt, len(batch), s.subscriber.Name(), err)
+		if len(retryable) == 0 || attempt >= s.maxAttempts {
+			return err
+		}
+		batch = retryable
+		sleep(retryDelay(attempt))
+	}
+}
+
+// retryDelay returns the exponential backoff after the failed attempt
+func retryDelay(attempt int) time.Duration {
+	if delay := time.Duration(1<<uint(attempt)) * time.Second; delay < maxRetryDelay {
+		return delay
+	}
+	return maxRetryDelay
+}
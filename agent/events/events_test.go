@@ -0,0 +1,290 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package events
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/platform"
+	"github.com/aws/amazon-ssm-agent/agent/sdkutil"
+	"github.com/stretchr/testify/assert"
+)
+
+var testTime = time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+
+// fakeSubscriber fails the first failures deliveries and records the delivered events
+type fakeSubscriber struct {
+	lock      sync.Mutex
+	failures  int
+	retry     bool
+	attempts  int
+	delivered chan Event
+}
+
+func (f *fakeSubscriber) Name() string { return "fake" }
+
+func (f *fakeSubscriber) Deliver(log log.T, event Event, payload []byte) (bool, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.attempts++
+	if f.attempts <= f.failures {
+		return f.retry, errors.New("unavailable")
+	}
+	f.delivered <- event
+	return false, nil
+}
+
+func useTestBus(t *testing.T) {
+	busOnce.Do(func() {})
+	busInstance = &bus{}
+	getInstanceID = func() (string, error) { return "i-1234567890abcdef0", nil }
+	timeNow = func() time.Time { return testTime }
+	sleep = func(time.Duration) {}
+	batchWindow = 100 * time.Millisecond
+	t.Cleanup(func() {
+		batchWindow = time.Second
+		busInstance = &bus{}
+		getInstanceID = platform.InstanceID
+		timeNow = time.Now
+		sleep = time.Sleep
+	})
+}
+
+func receive(t *testing.T, events chan Event) (event Event) {
+	select {
+	case event = <-events:
+	case <-time.After(5 * time.Second):
+		assert.Fail(t, "no event was delivered")
+	}
+	return
+}
+
+func TestPublishDeliversMatchingEvents(t *testing.T) {
+	useTestBus(t)
+	sessions := &fakeSubscriber{delivered: make(chan Event, 10)}
+	all := &fakeSubscriber{delivered: make(chan Event, 10)}
+	Subscribe(log.NewMockLog(), []string{"session.*"}, sessions, 1)
+	Subscribe(log.NewMockLog(), nil, all, 1)
+
+	Publish(log.NewMockLog(), CommandStarted, map[string]string{"CommandId": "command-id"})
+	Publish(log.NewMockLog(), SessionOpened, map[string]string{"SessionId": "session-id"})
+
+	event := receive(t, sessions.delivered)
+	assert.Equal(t, SessionOpened, event.Type)
+	assert.Equal(t, "i-1234567890abcdef0", event.InstanceId)
+	assert.Equal(t, "2020-06-01T12:00:00Z", event.Time)
+	assert.Equal(t, "session-id", event.Detail["SessionId"])
+	assert.NotEmpty(t, event.Id)
+	assert.Equal(t, CommandStarted, receive(t, all.delivered).Type)
+	assert.Equal(t, SessionOpened, receive(t, all.delivered).Type)
+	assert.Len(t, sessions.delivered, 0)
+}
+
+func TestDeliverRetries(t *testing.T) {
+	useTestBus(t)
+	subscriber := &fakeSubscriber{failures: 2, retry: true, delivered: make(chan Event, 10)}
+	Subscribe(log.NewMockLog(), nil, subscriber, 3)
+
+	Publish(log.NewMockLog(), CommandFinished, nil)
+	assert.Equal(t, CommandFinished, receive(t, subscriber.delivered).Type)
+	assert.Equal(t, 3, subscriber.attempts)
+}
+
+func TestDeliverDropsEventsThatCannotBeRetried(t *testing.T) {
+	useTestBus(t)
+	subscriber := &fakeSubscriber{failures: 1, retry: false, delivered: make(chan Event, 10)}
+	Subscribe(log.NewMockLog(), nil, subscriber, 3)
+
+	Publish(log.NewMockLog(), CommandStarted, nil)
+	Publish(log.NewMockLog(), CommandFinished, nil)
+	assert.Equal(t, CommandFinished, receive(t, subscriber.delivered).Type)
+	assert.Equal(t, 2, subscriber.attempts)
+}
+
+// fakeBatchSubscriber fails the batches while failing is set and records the delivered batches
+type fakeBatchSubscriber struct {
+	lock      sync.Mutex
+	batchSize int
+	failing   bool
+	attempts  int
+	delivered chan []Event
+}
+
+func (f *fakeBatchSubscriber) Name() string { return "batch" }
+
+func (f *fakeBatchSubscriber) MaxBatchSize() int { return f.batchSize }
+
+func (f *fakeBatchSubscriber) DeliverBatch(log log.T, events []Event) ([]Event, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.attempts++
+	if f.failing {
+		return events[1:], errors.New("unavailable")
+	}
+	f.delivered <- events
+	return nil, nil
+}
+
+func TestDeliverBatches(t *testing.T) {
+	useTestBus(t)
+	subscriber := &fakeBatchSubscriber{batchSize: 3, delivered: make(chan []Event, 10)}
+	SubscribeBatch(log.NewMockLog(), []string{"step.*"}, subscriber, 1)
+
+	for i := 0; i < 4; i++ {
+		Publish(log.NewMockLog(), StepFinished, nil)
+	}
+	var batches []int
+	for _, batch := range [][]Event{receiveBatch(t, subscriber.delivered), receiveBatch(t, subscriber.delivered)} {
+		batches = append(batches, len(batch))
+	}
+	assert.Equal(t, []int{3, 1}, batches)
+}
+
+func TestDeliverBatchRetriesTheRetryableEvents(t *testing.T) {
+	useTestBus(t)
+	s := &subscription{subscriber: &fakeBatchSubscriber{batchSize: 4, failing: true}, maxAttempts: 3}
+	err := s.deliverBatch(log.NewMockLog(), []Event{{Id: "1"}, {Id: "2"}, {Id: "3"}, {Id: "4"}})
+	assert.Error(t, err)
+	assert.Equal(t, 3, s.subscriber.(*fakeBatchSubscriber).attempts)
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	useTestBus(t)
+	now := testTime
+	timeNow = func() time.Time { return now }
+	subscriber := &fakeBatchSubscriber{batchSize: 1, failing: true, delivered: make(chan []Event, 10)}
+	s := &subscription{
+		subscriber:  subscriber,
+		maxAttempts: 1,
+		queue:       make(chan Event, queueSize),
+		breaker:     sdkutil.NewStopPolicy("batch", circuitBreakerThreshold),
+	}
+	deliver := func(events int) {
+		for i := 0; i < events; i++ {
+			s.queue <- Event{Type: StepFinished}
+		}
+		close(s.queue)
+		s.deliver(log.NewMockLog())
+		s.queue = make(chan Event, queueSize)
+	}
+
+	deliver(circuitBreakerThreshold + 2)
+	assert.Equal(t, circuitBreakerThreshold, subscriber.attempts, "the open breaker drops the events")
+	assert.False(t, s.breaker.IsHealthy())
+
+	now = now.Add(circuitBreakerCooldown)
+	deliver(2)
+	assert.Equal(t, circuitBreakerThreshold+1, subscriber.attempts, "a half open breaker opens again on failure")
+
+	now = now.Add(circuitBreakerCooldown)
+	subscriber.failing = false
+	deliver(1)
+	assert.Equal(t, circuitBreakerThreshold+2, subscriber.attempts)
+	assert.True(t, s.breaker.IsHealthy())
+	assert.False(t, s.breaker.HasError())
+}
+
+func receiveBatch(t *testing.T, batches chan []Event) (batch []Event) {
+	select {
+	case batch = <-batches:
+	case <-time.After(5 * time.Second):
+		assert.Fail(t, "no batch was delivered")
+	}
+	return
+}
+
+func TestRetryDelay(t *testing.T) {
+	assert.Equal(t, 2*time.Second, retryDelay(1))
+	assert.Equal(t, 8*time.Second, retryDelay(3))
+	assert.Equal(t, maxRetryDelay, retryDelay(10))
+}
+
+func TestWebhookSubscriber(t *testing.T) {
+	timeNow = func() time.Time { return testTime }
+	defer func() { timeNow = time.Now }()
+	status := http.StatusInternalServerError
+	var header http.Header
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header
+		body, _ = ioutil.ReadAll(r.Body)
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	subscriber := newWebhookSubscriber(appconfig.EventSubscriberCfg{
+		Name: "pager", WebhookUrl: server.URL, SigningKey: "secret", TimeoutSeconds: 5})
+	event := Event{Id: "event-id", Type: SessionOpened}
+	payload, _ := json.Marshal(event)
+
+	retry, err := subscriber.Deliver(log.NewMockLog(), event, payload)
+	assert.Error(t, err)
+	assert.True(t, retry)
+
+	status = http.StatusBadRequest
+	retry, err = subscriber.Deliver(log.NewMockLog(), event, payload)
+	assert.Error(t, err)
+	assert.False(t, retry)
+
+	status = http.StatusOK
+	retry, err = subscriber.Deliver(log.NewMockLog(), event, payload)
+	assert.NoError(t, err)
+	assert.Equal(t, payload, body)
+	assert.Equal(t, SessionOpened, header.Get(webhookEventTypeHeader))
+	assert.Equal(t, "event-id", header.Get(webhookEventIdHeader))
+	assert.Equal(t, "1591012800", header.Get(webhookTimestampHeader))
+	assert.Equal(t, "sha256="+signEvent("secret", "1591012800", payload), header.Get(webhookSignatureHeader))
+}
+
+func TestCommandSubscriber(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the command is a shell script")
+	}
+	timeNow = func() time.Time { return testTime }
+	defer func() { timeNow = time.Now }()
+	dir, _ := ioutil.TempDir("", "events")
+	defer os.RemoveAll(dir)
+	output := filepath.Join(dir, "event")
+	subscriber := newCommandSubscriber(appconfig.EventSubscriberCfg{
+		Name:           "script",
+		Command:        []string{"/bin/sh", "-c", `cat > "$0" && echo "$SSM_EVENT_TYPE $SSM_EVENT_SIGNATURE" >> "$0"`, output},
+		SigningKey:     "secret",
+		TimeoutSeconds: 5,
+	})
+	event := Event{Id: "event-id", Type: CommandFinished}
+	payload, _ := json.Marshal(event)
+
+	retry, err := subscriber.Deliver(log.NewMockLog(), event, payload)
+	assert.NoError(t, err)
+	assert.False(t, retry)
+	data, _ := ioutil.ReadFile(output)
+	assert.Equal(t, string(payload)+CommandFinished+" sha256="+signEvent("secret", "1591012800", payload)+"\n", string(data))
+
+	subscriber.command = []string{"/bin/sh", "-c", "exit 1"}
+	retry, err = subscriber.Deliver(log.NewMockLog(), event, payload)
+	assert.Error(t, err)
+	assert.True(t, retry)
+}
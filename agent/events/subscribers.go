@@ -0,0 +1,160 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package events
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/exec"
+	"strconv"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/proxyconfig"
+)
+
+// Headers sent with the events posted to webhooks.
+// The signature is the hex HMAC SHA256 of the timestamp and the body separated by a new line.
+const (
+	webhookEventTypeHeader = "X-Ssm-Event-Type"
+	webhookEventIdHeader   = "X-Ssm-Event-Id"
+	webhookTimestampHeader = "X-Ssm-Timestamp"
+	webhookSignatureHeader = "X-Ssm-Signature"
+)
+
+// Environment variables of the commands, the event itself is written to their standard input
+const (
+	commandEventTypeEnv = "SSM_EVENT_TYPE"
+	commandEventIdEnv   = "SSM_EVENT_ID"
+	commandTimestampEnv = "SSM_EVENT_TIMESTAMP"
+	commandSignatureEnv = "SSM_EVENT_SIGNATURE"
+)
+
+// webhookSubscriber posts the events to a customer provided https webhook
+type webhookSubscriber struct {
+	name       string
+	url        string
+	signingKey string
+	client     *http.Client
+}
+
+func newWebhookSubscriber(config appconfig.EventSubscriberCfg) *webhookSubscriber {
+	return &webhookSubscriber{
+		name:       config.Name,
+		url:        config.WebhookUrl,
+		signingKey: config.SigningKey,
+		client: &http.Client{
+			Transport: proxyconfig.NewTransport(""),
+			Timeout:   time.Duration(config.TimeoutSeconds) * time.Second,
+		},
+	}
+}
+
+// Name returns the name of the subscriber
+func (w *webhookSubscriber) Name() string {
+	return w.name
+}
+
+// Deliver posts the event, server errors and failed requests can be retried
+func (w *webhookSubscriber) Deliver(log log.T, event Event, payload []byte) (retry bool, err error) {
+	request, err := http.NewRequest(http.MethodPost, w.url, bytes.NewReader(payload))
+	if err != nil {
+		return false, err
+	}
+	timestamp := strconv.FormatInt(timeNow().Unix(), 10)
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set(webhookEventTypeHeader, event.Type)
+	request.Header.Set(webhookEventIdHeader, event.Id)
+	request.Header.Set(webhookTimestampHeader, timestamp)
+	if w.signingKey != "" {
+		request.Header.Set(webhookSignatureHeader, "sha256="+signEvent(w.signingKey, timestamp, payload))
+	}
+
+	response, err := w.client.Do(request)
+	if err != nil {
+		return true, err
+	}
+	defer response.Body.Close()
+	io.Copy(ioutil.Discard, response.Body)
+
+	if response.StatusCode >= 200 && response.StatusCode < 300 {
+		return false, nil
+	}
+	return response.StatusCode >= 500 || response.StatusCode == http.StatusTooManyRequests,
+		fmt.Errorf("webhook returned status %v", response.Status)
+}
+
+// commandSubscriber runs a customer provided command for every event
+type commandSubscriber struct {
+	name       string
+	command    []string
+	signingKey string
+	timeout    time.Duration
+}
+
+func newCommandSubscriber(config appconfig.EventSubscriberCfg) *commandSubscriber {
+	return &commandSubscriber{
+		name:       config.Name,
+		command:    config.Command,
+		signingKey: config.SigningKey,
+		timeout:    time.Duration(config.TimeoutSeconds) * time.Second,
+	}
+}
+
+// Name returns the name of the subscriber
+func (c *commandSubscriber) Name() string {
+	return c.name
+}
+
+// Deliver runs the command with the event on its standard input, a command that failed or timed out can be run again
+func (c *commandSubscriber) Deliver(log log.T, event Event, payload []byte) (retry bool, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+
+	timestamp := strconv.FormatInt(timeNow().Unix(), 10)
+	cmd := exec.CommandContext(ctx, c.command[0], c.command[1:]...)
+	cmd.Stdin = bytes.NewReader(payload)
+	cmd.Env = append(os.Environ(),
+		commandEventTypeEnv+"="+event.Type,
+		commandEventIdEnv+"="+event.Id,
+		commandTimestampEnv+"="+timestamp)
+	if c.signingKey != "" {
+		cmd.Env = append(cmd.Env, commandSignatureEnv+"=sha256="+signEvent(c.signingKey, timestamp, payload))
+	}
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		if ctx.Err() != nil {
+			err = ctx.Err()
+		}
+		return true, fmt.Errorf("command %v failed: %v %s", c.command[0], err, bytes.TrimSpace(output))
+	}
+	return false, nil
+}
+
+// signEvent returns the hex HMAC SHA256 the subscribers use to verify the event was sent by the agent
+func signEvent(signingKey string, timestamp string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(signingKey))
+	mac.Write([]byte(timestamp + "\n"))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,257 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package export
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ssm"
+)
+
+const (
+	// AsffSchemaVersion is the version of the AWS Security Finding Format of the findings
+	AsffSchemaVersion = "2018-10-08"
+
+	// generatorPrefix prefixes the generator of the findings, the generator identifies the data type
+	generatorPrefix = "amazon-ssm-agent"
+
+	// Types of the findings of inventory and compliance submissions
+	inventoryFindingType  = "Software and Configuration Checks/AWS Systems Manager/Inventory"
+	complianceFindingType = "Software and Configuration Checks/AWS Systems Manager/Compliance"
+
+	// Labels of the finding severities
+	severityInformational = "INFORMATIONAL"
+	severityLow           = "LOW"
+	severityMedium        = "MEDIUM"
+	severityHigh          = "HIGH"
+	severityCritical      = "CRITICAL"
+
+	// Statuses of the compliance of the findings
+	compliancePassed       = "PASSED"
+	complianceFailed       = "FAILED"
+	complianceNotAvailable = "NOT_AVAILABLE"
+)
+
+// Finding is a finding of the AWS Security Finding Format, a compliance item or an inventory type of the instance
+type Finding struct {
+	SchemaVersion string
+	Id            string
+	ProductArn    string
+	GeneratorId   string
+	AwsAccountId  string
+	Types         []string
+	CreatedAt     string
+	UpdatedAt     string
+	Severity      Severity
+	Title         string
+	Description   string
+	Resources     []Resource
+	Compliance    *FindingCompliance `json:",omitempty"`
+	ProductFields map[string]string  `json:",omitempty"`
+	RecordState   string
+}
+
+// Severity is the severity of a finding, Original is the severity of the compliance item
+type Severity struct {
+	Label    string
+	Original string `json:",omitempty"`
+}
+
+// Resource is the instance a finding is about
+type Resource struct {
+	Type      string
+	Id        string
+	Partition string
+	Region    string
+}
+
+// FindingCompliance is the compliance status of a finding
+type FindingCompliance struct {
+	Status string
+}
+
+// findingContext holds what the findings of the instance share
+type findingContext struct {
+	instanceID string
+	accountID  string
+	region     string
+	partition  string
+}
+
+// newFindingContext returns the context of the findings of the instance, the account of the configuration overrides
+// the account of the identity document
+func newFindingContext(log log.T, config appconfig.ExportCfg, instanceID string) findingContext {
+	context := findingContext{instanceID: instanceID, accountID: config.AccountId}
+	var err error
+	if context.accountID == "" {
+		if context.accountID, err = accountID(); err != nil {
+			log.Debugf("exporting findings without an account: %v", err)
+		}
+	}
+	if context.region, err = region(); err != nil {
+		log.Debugf("exporting findings without a region: %v", err)
+	}
+	context.partition = partition(context.region)
+	return context
+}
+
+// partition returns the partition of the region
+func partition(region string) string {
+	switch {
+	case strings.HasPrefix(region, "cn-"):
+		return "aws-cn"
+	case strings.HasPrefix(region, "us-gov-"):
+		return "aws-us-gov"
+	default:
+		return "aws"
+	}
+}
+
+// resource returns the instance resource, managed instances are not EC2 instances
+func (c findingContext) resource() Resource {
+	resource := Resource{
+		Type:      "AwsEc2Instance",
+		Id:        fmt.Sprintf("arn:%v:ec2:%v:%v:instance/%v", c.partition, c.region, c.accountID, c.instanceID),
+		Partition: c.partition,
+		Region:    c.region,
+	}
+	if !strings.HasPrefix(c.instanceID, "i-") {
+		resource.Type = "Other"
+		resource.Id = fmt.Sprintf("arn:%v:ssm:%v:%v:managed-instance/%v", c.partition, c.region, c.accountID, c.instanceID)
+	}
+	return resource
+}
+
+// finding returns a finding of the instance with the fields every finding has
+func (c findingContext) finding(id string, generator string, findingType string, updatedAt time.Time) Finding {
+	timestamp := updatedAt.UTC().Format(time.RFC3339)
+	return Finding{
+		SchemaVersion: AsffSchemaVersion,
+		Id:            fmt.Sprintf("%v/%v", c.instanceID, id),
+		ProductArn:    fmt.Sprintf("arn:%v:securityhub:%v:%v:product/%v/default", c.partition, c.region, c.accountID, c.accountID),
+		GeneratorId:   fmt.Sprintf("%v/%v", generatorPrefix, generator),
+		AwsAccountId:  c.accountID,
+		Types:         []string{findingType},
+		CreatedAt:     timestamp,
+		UpdatedAt:     timestamp,
+		Resources:     []Resource{c.resource()},
+		RecordState:   "ACTIVE",
+	}
+}
+
+// complianceFindings returns a finding for every compliance item of the submission
+func (c findingContext) complianceFindings(submission ComplianceSubmission) (findings []Finding) {
+	executionTime := submission.ExecutionTime
+	if executionTime.IsZero() {
+		executionTime = timeNow()
+	}
+	for i, item := range submission.Items {
+		itemID := aws.StringValue(item.Id)
+		if itemID == "" {
+			itemID = strconv.Itoa(i)
+		}
+		status := aws.StringValue(item.Status)
+		finding := c.finding(
+			fmt.Sprintf("compliance/%v/%v", submission.ComplianceType, itemID),
+			"compliance/"+submission.ComplianceType,
+			complianceFindingType,
+			executionTime)
+		finding.Title = aws.StringValue(item.Title)
+		if finding.Title == "" {
+			finding.Title = itemID
+		}
+		finding.Description = fmt.Sprintf("%v item %v of %v is %v", submission.ComplianceType, itemID, c.instanceID, status)
+		finding.Severity = Severity{Label: severityLabel(aws.StringValue(item.Severity)), Original: aws.StringValue(item.Severity)}
+		finding.Compliance = &FindingCompliance{Status: complianceStatus(status)}
+		finding.ProductFields = map[string]string{
+			"aws/ssm/ComplianceType": submission.ComplianceType,
+			"aws/ssm/Status":         status,
+		}
+		if submission.ExecutionId != "" {
+			finding.ProductFields["aws/ssm/ExecutionId"] = submission.ExecutionId
+			finding.ProductFields["aws/ssm/ExecutionType"] = submission.ExecutionType
+		}
+		for key, value := range item.Details {
+			finding.ProductFields[key] = aws.StringValue(value)
+		}
+		findings = append(findings, finding)
+	}
+	return
+}
+
+// inventoryFindings returns an informational finding for every inventory type, the findings summarize the content
+func (c findingContext) inventoryFindings(items []*ssm.InventoryItem) (findings []Finding) {
+	for _, item := range items {
+		typeName := aws.StringValue(item.TypeName)
+		captureTime, err := time.Parse(time.RFC3339, aws.StringValue(item.CaptureTime))
+		if err != nil {
+			captureTime = timeNow()
+		}
+		finding := c.finding("inventory/"+typeName, "inventory/"+typeName, inventoryFindingType, captureTime)
+		finding.Title = fmt.Sprintf("%v inventory of %v", typeName, c.instanceID)
+		finding.Description = fmt.Sprintf("%v captured %v %v entries", c.instanceID, len(item.Content), typeName)
+		finding.Severity = Severity{Label: severityInformational}
+		finding.ProductFields = map[string]string{
+			"aws/ssm/TypeName":      typeName,
+			"aws/ssm/SchemaVersion": aws.StringValue(item.SchemaVersion),
+			"aws/ssm/ContentHash":   aws.StringValue(item.ContentHash),
+			"aws/ssm/EntryCount":    strconv.Itoa(len(item.Content)),
+		}
+		findings = append(findings, finding)
+	}
+	return
+}
+
+// severityLabel maps the severity of a compliance item to the severity label of its finding
+func severityLabel(severity string) string {
+	switch strings.ToUpper(severity) {
+	case ssm.ComplianceSeverityCritical:
+		return severityCritical
+	case ssm.ComplianceSeverityHigh:
+		return severityHigh
+	case ssm.ComplianceSeverityMedium:
+		return severityMedium
+	case ssm.ComplianceSeverityLow:
+		return severityLow
+	default:
+		return severityInformational
+	}
+}
+
+// complianceStatus maps the status of a compliance item to the compliance status of its finding
+func complianceStatus(status string) string {
+	switch strings.ToUpper(status) {
+	case ssm.ComplianceStatusCompliant:
+		return compliancePassed
+	case ssm.ComplianceStatusNonCompliant:
+		return complianceFailed
+	default:
+		return complianceNotAvailable
+	}
+}
+
+// findingValues returns the findings as the values to export
+func findingValues(findings []Finding) []interface{} {
+	values := make([]interface{}, len(findings))
+	for i := range findings {
+		values[i] = findings[i]
+	}
+	return values
+}
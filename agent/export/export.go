@@ -0,0 +1,145 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package export mirrors the inventory and compliance data the agent submits to Systems Manager to a local file or
+// socket, as json records or as findings of the AWS Security Finding Format, for the integrations running on the
+// instance. The Export configuration selects the format and the destinations.
+package export
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/platform"
+	"github.com/aws/aws-sdk-go/service/ssm"
+)
+
+const (
+	// SchemaVersion is the schema version of the json records
+	SchemaVersion = "1.0"
+
+	// RecordTypeInventory is the type of the records of inventory submissions
+	RecordTypeInventory = "Inventory"
+
+	// RecordTypeCompliance is the type of the records of compliance submissions
+	RecordTypeCompliance = "Compliance"
+)
+
+// ComplianceSubmission is a submission of the compliance items of a compliance type
+type ComplianceSubmission struct {
+	ComplianceType string
+	ExecutionId    string `json:",omitempty"`
+	ExecutionType  string `json:",omitempty"`
+	ExecutionTime  time.Time
+	Items          []*ssm.ComplianceItemEntry
+}
+
+// Record is a submission exported in the json format
+type Record struct {
+	SchemaVersion string
+	RecordType    string
+	InstanceId    string
+	ExportTime    string
+	Inventory     []*ssm.InventoryItem  `json:",omitempty"`
+	Compliance    *ComplianceSubmission `json:",omitempty"`
+}
+
+var (
+	getAppConfig = appconfig.Config
+	accountID    = platform.AccountID
+	region       = platform.Region
+	timeNow      = time.Now
+
+	// writeLock serializes the writes of concurrent submissions
+	writeLock sync.Mutex
+)
+
+// Enabled returns true when the submissions are exported
+func Enabled() bool {
+	config, err := getAppConfig(false)
+	return err == nil && config.Export.Enabled
+}
+
+// Inventory exports the inventory items submitted for the instance, the items carry their content
+func Inventory(log log.T, instanceID string, items []*ssm.InventoryItem) {
+	config, err := getAppConfig(false)
+	if err != nil || !config.Export.Enabled || len(items) == 0 {
+		return
+	}
+	if config.Export.Format == appconfig.ExportFormatAsff {
+		findings := newFindingContext(log, config.Export, instanceID).inventoryFindings(items)
+		export(log, config.Export, instanceID, findingValues(findings))
+		return
+	}
+	export(log, config.Export, instanceID, []interface{}{Record{
+		SchemaVersion: SchemaVersion,
+		RecordType:    RecordTypeInventory,
+		InstanceId:    instanceID,
+		ExportTime:    timeNow().UTC().Format(time.RFC3339),
+		Inventory:     items,
+	}})
+}
+
+// Compliance exports the compliance items submitted for the instance
+func Compliance(log log.T, instanceID string, submission ComplianceSubmission) {
+	config, err := getAppConfig(false)
+	if err != nil || !config.Export.Enabled {
+		return
+	}
+	if config.Export.Format == appconfig.ExportFormatAsff {
+		findings := newFindingContext(log, config.Export, instanceID).complianceFindings(submission)
+		export(log, config.Export, instanceID, findingValues(findings))
+		return
+	}
+	export(log, config.Export, instanceID, []interface{}{Record{
+		SchemaVersion: SchemaVersion,
+		RecordType:    RecordTypeCompliance,
+		InstanceId:    instanceID,
+		ExportTime:    timeNow().UTC().Format(time.RFC3339),
+		Compliance:    &submission,
+	}})
+}
+
+// export writes the values as json lines to the destinations of the configuration, failures are only logged
+// since the export never holds up the submissions to Systems Manager
+func export(log log.T, config appconfig.ExportCfg, instanceID string, values []interface{}) {
+	if len(values) == 0 {
+		return
+	}
+	var data []byte
+	for _, value := range values {
+		line, err := json.Marshal(value)
+		if err != nil {
+			log.Warnf("failed to export %v data: %v", config.Format, err)
+			return
+		}
+		data = append(append(data, line...), '\n')
+	}
+
+	writeLock.Lock()
+	defer writeLock.Unlock()
+	if config.Socket != "" {
+		if err := writeSocket(config.Socket, data); err != nil {
+			log.Warnf("failed to export %v lines to %v: %v", len(values), config.Socket, err)
+		}
+	}
+	if config.LocalFile != "" || config.Socket == "" {
+		path := exportFile(config, instanceID)
+		if err := appendFile(path, data, int64(config.MaxFileSizeMB)*1024*1024); err != nil {
+			log.Warnf("failed to export %v lines to %v: %v", len(values), path, err)
+		}
+	}
+}
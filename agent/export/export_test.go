@@ -0,0 +1,249 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package export
+
+import (
+	"bufio"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/platform"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ssm"
+	"github.com/stretchr/testify/assert"
+)
+
+const testInstanceID = "i-1234567890abcdef0"
+
+var testTime = time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+
+func useExportConfig(t *testing.T, export appconfig.ExportCfg) {
+	getAppConfig = func(bool) (appconfig.SsmagentConfig, error) {
+		config := appconfig.DefaultConfig()
+		config.Export = export
+		return config, nil
+	}
+	accountID = func() (string, error) { return "123456789012", nil }
+	region = func() (string, error) { return "us-east-1", nil }
+	timeNow = func() time.Time { return testTime }
+	t.Cleanup(func() {
+		getAppConfig = appconfig.Config
+		accountID = platform.AccountID
+		region = platform.Region
+		timeNow = time.Now
+	})
+}
+
+func readLines(t *testing.T, path string) (lines []map[string]interface{}) {
+	file, err := os.Open(path)
+	assert.NoError(t, err)
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var line map[string]interface{}
+		assert.NoError(t, json.Unmarshal(scanner.Bytes(), &line))
+		lines = append(lines, line)
+	}
+	return
+}
+
+func testSubmission() ComplianceSubmission {
+	return ComplianceSubmission{
+		ComplianceType: "Custom:PackageUpdates",
+		ExecutionId:    "command-id",
+		ExecutionType:  "Command",
+		ExecutionTime:  testTime,
+		Items: []*ssm.ComplianceItemEntry{
+			{
+				Id:       aws.String("openssl"),
+				Title:    aws.String("openssl 1.1.1k"),
+				Severity: aws.String(ssm.ComplianceSeverityHigh),
+				Status:   aws.String(ssm.ComplianceStatusNonCompliant),
+				Details:  map[string]*string{"Advisory": aws.String("ALAS-2021-1")},
+			},
+			{
+				Severity: aws.String(ssm.ComplianceSeverityUnspecified),
+				Status:   aws.String(ssm.ComplianceStatusCompliant),
+			},
+		},
+	}
+}
+
+func TestExportDisabled(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "export")
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "export.jsonl")
+	useExportConfig(t, appconfig.ExportCfg{Format: appconfig.ExportFormatJson, LocalFile: path, MaxFileSizeMB: 1})
+
+	assert.False(t, Enabled())
+	Compliance(log.NewMockLog(), testInstanceID, testSubmission())
+	_, err := os.Stat(path)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestExportJson(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "export")
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "siem", "export.jsonl")
+	useExportConfig(t, appconfig.ExportCfg{Enabled: true, Format: appconfig.ExportFormatJson, LocalFile: path, MaxFileSizeMB: 1})
+
+	assert.True(t, Enabled())
+	Inventory(log.NewMockLog(), testInstanceID, []*ssm.InventoryItem{{
+		TypeName:      aws.String("AWS:Application"),
+		SchemaVersion: aws.String("1.1"),
+		CaptureTime:   aws.String("2020-06-01T12:00:00Z"),
+		Content:       []map[string]*string{{"Name": aws.String("openssl")}},
+	}})
+	Compliance(log.NewMockLog(), testInstanceID, testSubmission())
+
+	lines := readLines(t, path)
+	assert.Len(t, lines, 2)
+	assert.Equal(t, RecordTypeInventory, lines[0]["RecordType"])
+	assert.Equal(t, testInstanceID, lines[0]["InstanceId"])
+	assert.Equal(t, "2020-06-01T12:00:00Z", lines[0]["ExportTime"])
+	assert.Equal(t, "openssl", lines[0]["Inventory"].([]interface{})[0].(map[string]interface{})["Content"].([]interface{})[0].(map[string]interface{})["Name"])
+	assert.Nil(t, lines[0]["Compliance"])
+	assert.Equal(t, RecordTypeCompliance, lines[1]["RecordType"])
+	compliance := lines[1]["Compliance"].(map[string]interface{})
+	assert.Equal(t, "Custom:PackageUpdates", compliance["ComplianceType"])
+	assert.Len(t, compliance["Items"], 2)
+}
+
+func TestExportAsff(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "export")
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "export.jsonl")
+	useExportConfig(t, appconfig.ExportCfg{Enabled: true, Format: appconfig.ExportFormatAsff, LocalFile: path, MaxFileSizeMB: 1})
+
+	Compliance(log.NewMockLog(), testInstanceID, testSubmission())
+
+	var findings []Finding
+	for _, line := range readLines(t, path) {
+		data, _ := json.Marshal(line)
+		var finding Finding
+		assert.NoError(t, json.Unmarshal(data, &finding))
+		findings = append(findings, finding)
+	}
+	assert.Len(t, findings, 2)
+	finding := findings[0]
+	assert.Equal(t, AsffSchemaVersion, finding.SchemaVersion)
+	assert.Equal(t, testInstanceID+"/compliance/Custom:PackageUpdates/openssl", finding.Id)
+	assert.Equal(t, "arn:aws:securityhub:us-east-1:123456789012:product/123456789012/default", finding.ProductArn)
+	assert.Equal(t, "amazon-ssm-agent/compliance/Custom:PackageUpdates", finding.GeneratorId)
+	assert.Equal(t, "123456789012", finding.AwsAccountId)
+	assert.Equal(t, "2020-06-01T12:00:00Z", finding.UpdatedAt)
+	assert.Equal(t, Severity{Label: "HIGH", Original: "HIGH"}, finding.Severity)
+	assert.Equal(t, "openssl 1.1.1k", finding.Title)
+	assert.Equal(t, &FindingCompliance{Status: "FAILED"}, finding.Compliance)
+	assert.Equal(t, []Resource{{
+		Type:      "AwsEc2Instance",
+		Id:        "arn:aws:ec2:us-east-1:123456789012:instance/" + testInstanceID,
+		Partition: "aws",
+		Region:    "us-east-1",
+	}}, finding.Resources)
+	assert.Equal(t, "ALAS-2021-1", finding.ProductFields["Advisory"])
+	assert.Equal(t, "command-id", finding.ProductFields["aws/ssm/ExecutionId"])
+
+	assert.Equal(t, testInstanceID+"/compliance/Custom:PackageUpdates/1", findings[1].Id)
+	assert.Equal(t, "INFORMATIONAL", findings[1].Severity.Label)
+	assert.Equal(t, "PASSED", findings[1].Compliance.Status)
+}
+
+func TestInventoryFindings(t *testing.T) {
+	useExportConfig(t, appconfig.ExportCfg{Enabled: true, Format: appconfig.ExportFormatAsff, AccountId: "210987654321"})
+	region = func() (string, error) { return "cn-north-1", nil }
+
+	findings := newFindingContext(log.NewMockLog(), appconfig.ExportCfg{AccountId: "210987654321"}, "mi-1234567890abcdef0").inventoryFindings(
+		[]*ssm.InventoryItem{{
+			TypeName:      aws.String("AWS:Application"),
+			SchemaVersion: aws.String("1.1"),
+			CaptureTime:   aws.String("2020-05-01T08:00:00Z"),
+			ContentHash:   aws.String("hash"),
+			Content:       []map[string]*string{{"Name": aws.String("openssl")}, {"Name": aws.String("curl")}},
+		}})
+
+	assert.Len(t, findings, 1)
+	assert.Equal(t, "mi-1234567890abcdef0/inventory/AWS:Application", findings[0].Id)
+	assert.Equal(t, "210987654321", findings[0].AwsAccountId)
+	assert.Equal(t, "2020-05-01T08:00:00Z", findings[0].CreatedAt)
+	assert.Equal(t, "INFORMATIONAL", findings[0].Severity.Label)
+	assert.Nil(t, findings[0].Compliance)
+	assert.Equal(t, "2", findings[0].ProductFields["aws/ssm/EntryCount"])
+	assert.Equal(t, []Resource{{
+		Type:      "Other",
+		Id:        "arn:aws-cn:ssm:cn-north-1:210987654321:managed-instance/mi-1234567890abcdef0",
+		Partition: "aws-cn",
+		Region:    "cn-north-1",
+	}}, findings[0].Resources)
+}
+
+func TestAppendFileRotates(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "export")
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "export.jsonl")
+
+	assert.NoError(t, appendFile(path, []byte("first\n"), 10))
+	assert.NoError(t, appendFile(path, []byte("second\n"), 10))
+	assert.NoError(t, appendFile(path, []byte("third\n"), 20))
+
+	data, _ := ioutil.ReadFile(path)
+	assert.Equal(t, "second\nthird\n", string(data))
+	data, _ = ioutil.ReadFile(path + rotatedFileSuffix)
+	assert.Equal(t, "first\n", string(data))
+}
+
+func TestExportSocket(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("named pipes are not covered")
+	}
+	dir, _ := ioutil.TempDir("", "export")
+	defer os.RemoveAll(dir)
+	address := filepath.Join(dir, "siem.sock")
+	listener, err := net.Listen("unix", address)
+	assert.NoError(t, err)
+	defer listener.Close()
+	received := make(chan string, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			received <- ""
+			return
+		}
+		defer conn.Close()
+		data, _ := ioutil.ReadAll(conn)
+		received <- string(data)
+	}()
+	useExportConfig(t, appconfig.ExportCfg{Enabled: true, Format: appconfig.ExportFormatJson, Socket: address, MaxFileSizeMB: 1})
+
+	Compliance(log.NewMockLog(), testInstanceID, testSubmission())
+
+	select {
+	case data := <-received:
+		assert.True(t, strings.HasSuffix(data, "\n"))
+		assert.Contains(t, data, `"RecordType":"Compliance"`)
+	case <-time.After(5 * time.Second):
+		assert.Fail(t, "nothing was written to the socket")
+	}
+	_, err = os.Stat(exportFile(appconfig.ExportCfg{}, testInstanceID))
+	assert.True(t, os.IsNotExist(err), "the export file is not written when only the socket is configured")
+}
@@ -0,0 +1,81 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package export
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/fileutil"
+)
+
+const (
+	// exportDirName is the directory of the export file of the instance
+	exportDirName = "export"
+
+	// exportFileName is the export file of the instance when LocalFile is not configured
+	exportFileName = "export.jsonl"
+
+	// rotatedFileSuffix is appended to the export file once it reached its maximum size
+	rotatedFileSuffix = ".1"
+
+	// socketTimeout bounds the connection to the socket and the write of the lines
+	socketTimeout = 5 * time.Second
+)
+
+// exportFile returns the export file of the configuration, the export file of the instance by default
+func exportFile(config appconfig.ExportCfg, instanceID string) string {
+	if config.LocalFile != "" {
+		return config.LocalFile
+	}
+	return filepath.Join(appconfig.DefaultDataStorePath, instanceID, exportDirName, exportFileName)
+}
+
+// appendFile appends the data to the file, the file replaces its previous rotation first when the data would
+// take it past maxSize
+func appendFile(path string, data []byte, maxSize int64) error {
+	if err := fileutil.MakeDirs(filepath.Dir(path)); err != nil {
+		return err
+	}
+	if info, err := os.Stat(path); err == nil && info.Size() > 0 && info.Size()+int64(len(data)) > maxSize {
+		if err = os.Rename(path, path+rotatedFileSuffix); err != nil {
+			return err
+		}
+	}
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, appconfig.ReadWriteAccess)
+	if err != nil {
+		return err
+	}
+	_, err = file.Write(data)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// writeSocket writes the data to a new connection to the socket
+func writeSocket(address string, data []byte) error {
+	conn, err := dialSocket(address)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if err = conn.SetWriteDeadline(time.Now().Add(socketTimeout)); err != nil {
+		return err
+	}
+	_, err = conn.Write(data)
+	return err
+}
@@ -34,10 +34,9 @@ import (
 	"github.com/aws/amazon-ssm-agent/agent/log"
 	"github.com/aws/amazon-ssm-agent/agent/platform"
 	"github.com/aws/amazon-ssm-agent/agent/s3util"
+	"github.com/aws/amazon-ssm-agent/agent/proxyconfig"
 	"github.com/aws/amazon-ssm-agent/agent/sdkutil"
 	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/request"
-	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/cenkalti/backoff"
 )
@@ -171,9 +170,7 @@ func CanGetS3Object(log log.T, amazonS3URL s3util.AmazonS3URL) bool {
 		Key:    aws.String(objectKey),
 	}
 
-	appConfig, _ := appconfig.Config(false)
-	sess := session.New(config)
-	sess.Handlers.Build.PushBack(request.MakeAddToUserAgentHandler(appConfig.Agent.Name, appConfig.Agent.Version))
+	sess := sdkutil.NewSession(config, proxyconfig.ServiceS3)
 
 	s3client := s3.New(sess)
 	var res *s3.HeadObjectOutput
@@ -199,9 +196,7 @@ func ListS3Folders(log log.T, amazonS3URL s3util.AmazonS3URL) (folderNames []str
 		Prefix:    &prefix,
 		Delimiter: aws.String("/"),
 	}
-	appConfig, _ := appconfig.Config(false)
-	sess := session.New(config)
-	sess.Handlers.Build.PushBack(request.MakeAddToUserAgentHandler(appConfig.Agent.Name, appConfig.Agent.Version))
+	sess := sdkutil.NewSession(config, proxyconfig.ServiceS3)
 
 	s3client := s3.New(sess)
 	req, resp := s3client.ListObjectsRequest(params)
@@ -242,9 +237,7 @@ func ListS3Directory(log log.T, amazonS3URL s3util.AmazonS3URL) (folderNames []s
 	}
 	log.Debugf("ListS3Object Bucket: %v, Prefix: %v", params.Bucket, params.Prefix)
 
-	appConfig, _ := appconfig.Config(false)
-	sess := session.New(config)
-	sess.Handlers.Build.PushBack(request.MakeAddToUserAgentHandler(appConfig.Agent.Name, appConfig.Agent.Version))
+	sess := sdkutil.NewSession(config, proxyconfig.ServiceS3)
 
 	s3client := s3.New(sess)
 	obj, err := s3client.ListObjects(params)
@@ -281,9 +274,7 @@ func s3Download(log log.T, amazonS3URL s3util.AmazonS3URL, destFile string) (out
 		}
 		params.IfNoneMatch = aws.String(existingETag)
 	}
-	appConfig, _ := appconfig.Config(false)
-	sess := session.New(config)
-	sess.Handlers.Build.PushBack(request.MakeAddToUserAgentHandler(appConfig.Agent.Name, appConfig.Agent.Version))
+	sess := sdkutil.NewSession(config, proxyconfig.ServiceS3)
 
 	s3client := s3.New(sess)
 
@@ -0,0 +1,197 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package artifactcache stores downloaded artifacts by their SHA256 checksum, so documents that
+// download an artifact another document already downloaded reuse the cached copy.
+package artifactcache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/fileutil"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+)
+
+// Cache reuses downloaded artifacts by their SHA256 checksum
+type Cache interface {
+	// Get copies the cached artifact with the checksum to the destination, it returns false when
+	// the artifact is not cached or the cached copy no longer matches its checksum
+	Get(log log.T, checksum string, destination string) bool
+
+	// Put adds a downloaded file to the cache, evicting the least recently used artifacts above the size limit
+	Put(log log.T, checksum string, source string) error
+}
+
+// Disabled is the cache used when the cache is turned off or a document opts out of it
+var Disabled Cache = disabledCache{}
+
+var getAppConfig = appconfig.Config
+
+// cacheDir is shared by all documents, artifacts are files named by their checksum
+var cacheDir = filepath.Join(appconfig.DefaultDataStorePath, "artifactcache")
+
+var checksumPattern = regexp.MustCompile("^[a-f0-9]{64}$")
+
+// New returns the artifact cache configured for the agent
+func New() Cache {
+	config, err := getAppConfig(false)
+	if err != nil || !config.ArtifactCache.Enabled {
+		return Disabled
+	}
+	return &fileCache{
+		dir:     cacheDir,
+		maxSize: int64(config.ArtifactCache.MaxSizeMB) * 1024 * 1024,
+	}
+}
+
+type disabledCache struct{}
+
+func (disabledCache) Get(log log.T, checksum string, destination string) bool { return false }
+
+func (disabledCache) Put(log log.T, checksum string, source string) error { return nil }
+
+// fileCache keeps the artifacts in a directory. Artifacts are written to a temporary file and renamed,
+// so other processes never see partial artifacts, and are verified again when they are reused.
+type fileCache struct {
+	dir     string
+	maxSize int64
+}
+
+func (cache *fileCache) Get(log log.T, checksum string, destination string) bool {
+	checksum = strings.ToLower(checksum)
+	if !checksumPattern.MatchString(checksum) {
+		return false
+	}
+	cachedFile := filepath.Join(cache.dir, checksum)
+	if !fileutil.Exists(cachedFile) {
+		return false
+	}
+
+	if err := fileutil.MakeDirs(filepath.Dir(destination)); err != nil {
+		log.Warnf("Failed to create the directory of %v: %v", destination, err)
+		return false
+	}
+	actual, err := copyFile(cachedFile, destination)
+	if err != nil {
+		log.Warnf("Failed to copy cached artifact %v to %v: %v", checksum, destination, err)
+		os.Remove(destination)
+		return false
+	}
+	if actual != checksum {
+		log.Warnf("Cached artifact %v is corrupted, removing it from the cache", checksum)
+		os.Remove(destination)
+		os.Remove(cachedFile)
+		return false
+	}
+
+	// the modification time orders artifacts for eviction
+	now := time.Now()
+	os.Chtimes(cachedFile, now, now)
+	log.Infof("Reused cached artifact %v for %v", checksum, destination)
+	return true
+}
+
+func (cache *fileCache) Put(log log.T, checksum string, source string) error {
+	checksum = strings.ToLower(checksum)
+	if !checksumPattern.MatchString(checksum) {
+		return fmt.Errorf("invalid SHA256 checksum %v", checksum)
+	}
+	if err := fileutil.MakeDirs(cache.dir); err != nil {
+		return err
+	}
+
+	tempFile, err := ioutil.TempFile(cache.dir, ".tmp")
+	if err != nil {
+		return err
+	}
+	tempFile.Close()
+	defer os.Remove(tempFile.Name())
+
+	actual, err := copyFile(source, tempFile.Name())
+	if err != nil {
+		return err
+	}
+	if actual != checksum {
+		return fmt.Errorf("checksum %v of %v does not match %v", actual, source, checksum)
+	}
+	if err = os.Rename(tempFile.Name(), filepath.Join(cache.dir, checksum)); err != nil {
+		return err
+	}
+
+	cache.evict(log)
+	return nil
+}
+
+// evict removes the least recently used artifacts until the cache fits its size limit
+func (cache *fileCache) evict(log log.T) {
+	files, err := ioutil.ReadDir(cache.dir)
+	if err != nil {
+		return
+	}
+
+	var artifacts []os.FileInfo
+	var size int64
+	for _, file := range files {
+		if file.Mode().IsRegular() && checksumPattern.MatchString(file.Name()) {
+			artifacts = append(artifacts, file)
+			size += file.Size()
+		}
+	}
+	sort.Slice(artifacts, func(i, j int) bool {
+		return artifacts[i].ModTime().Before(artifacts[j].ModTime())
+	})
+
+	for _, artifact := range artifacts {
+		if size <= cache.maxSize {
+			return
+		}
+		if err = os.Remove(filepath.Join(cache.dir, artifact.Name())); err != nil {
+			log.Warnf("Failed to evict cached artifact %v: %v", artifact.Name(), err)
+			continue
+		}
+		log.Debugf("Evicted cached artifact %v", artifact.Name())
+		size -= artifact.Size()
+	}
+}
+
+// copyFile copies the source file to the destination and returns the SHA256 checksum of the copied content
+func copyFile(source string, destination string) (checksum string, err error) {
+	in, err := os.Open(source)
+	if err != nil {
+		return "", err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(destination, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, appconfig.ReadWriteAccess)
+	if err != nil {
+		return "", err
+	}
+	defer out.Close()
+
+	hash := sha256.New()
+	if _, err = io.Copy(io.MultiWriter(out, hash), in); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), out.Close()
+}
@@ -0,0 +1,112 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package artifactcache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/fileutil"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/stretchr/testify/assert"
+)
+
+var logMock = log.NewMockLog()
+
+func writeArtifact(t *testing.T, dir string, content string) (path string, checksum string) {
+	hash := sha256.Sum256([]byte(content))
+	path = filepath.Join(dir, content)
+	assert.NoError(t, ioutil.WriteFile(path, []byte(content), appconfig.ReadWriteAccess))
+	return path, hex.EncodeToString(hash[:])
+}
+
+func TestPutAndGet(t *testing.T) {
+	cache := &fileCache{dir: t.TempDir(), maxSize: 1024}
+	source, checksum := writeArtifact(t, t.TempDir(), "installer")
+
+	destination := filepath.Join(t.TempDir(), "downloads", "installer.msi")
+	assert.False(t, cache.Get(logMock, checksum, destination))
+
+	assert.NoError(t, cache.Put(logMock, checksum, source))
+	assert.True(t, cache.Get(logMock, checksum, destination))
+	content, err := ioutil.ReadFile(destination)
+	assert.NoError(t, err)
+	assert.Equal(t, "installer", string(content))
+}
+
+func TestPutRejectsChecksumMismatch(t *testing.T) {
+	cache := &fileCache{dir: t.TempDir(), maxSize: 1024}
+	source, _ := writeArtifact(t, t.TempDir(), "installer")
+	_, otherChecksum := writeArtifact(t, t.TempDir(), "other")
+
+	assert.Error(t, cache.Put(logMock, otherChecksum, source))
+	assert.Error(t, cache.Put(logMock, "not-a-checksum", source))
+
+	files, _ := ioutil.ReadDir(cache.dir)
+	assert.Empty(t, files)
+}
+
+func TestGetRemovesCorruptedArtifact(t *testing.T) {
+	cache := &fileCache{dir: t.TempDir(), maxSize: 1024}
+	source, checksum := writeArtifact(t, t.TempDir(), "installer")
+	assert.NoError(t, cache.Put(logMock, checksum, source))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(cache.dir, checksum), []byte("tampered"), appconfig.ReadWriteAccess))
+
+	destination := filepath.Join(t.TempDir(), "installer")
+	assert.False(t, cache.Get(logMock, checksum, destination))
+	_, err := os.Stat(destination)
+	assert.True(t, os.IsNotExist(err))
+	_, err = os.Stat(filepath.Join(cache.dir, checksum))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestPutEvictsLeastRecentlyUsed(t *testing.T) {
+	cache := &fileCache{dir: t.TempDir(), maxSize: 11}
+	sourceDir := t.TempDir()
+	first, firstChecksum := writeArtifact(t, sourceDir, "first")
+	second, secondChecksum := writeArtifact(t, sourceDir, "second")
+	third, thirdChecksum := writeArtifact(t, sourceDir, "third")
+
+	assert.NoError(t, cache.Put(logMock, firstChecksum, first))
+	assert.NoError(t, cache.Put(logMock, secondChecksum, second))
+	past := time.Now().Add(-time.Hour)
+	os.Chtimes(filepath.Join(cache.dir, firstChecksum), past, past)
+	os.Chtimes(filepath.Join(cache.dir, secondChecksum), past.Add(-time.Hour), past.Add(-time.Hour))
+	assert.NoError(t, cache.Put(logMock, thirdChecksum, third))
+
+	assert.True(t, fileutil.Exists(filepath.Join(cache.dir, firstChecksum)))
+	assert.False(t, fileutil.Exists(filepath.Join(cache.dir, secondChecksum)))
+	assert.True(t, fileutil.Exists(filepath.Join(cache.dir, thirdChecksum)))
+}
+
+func TestNew(t *testing.T) {
+	defer func() { getAppConfig = appconfig.Config }()
+
+	config := appconfig.DefaultConfig()
+	getAppConfig = func(bool) (appconfig.SsmagentConfig, error) { return config, nil }
+	assert.Equal(t, &fileCache{dir: cacheDir, maxSize: int64(appconfig.DefaultArtifactCacheMaxSizeMB) * 1024 * 1024}, New())
+
+	config.ArtifactCache.Enabled = false
+	assert.Equal(t, Disabled, New())
+
+	getAppConfig = func(bool) (appconfig.SsmagentConfig, error) { return config, errors.New("no config") }
+	assert.Equal(t, Disabled, New())
+}
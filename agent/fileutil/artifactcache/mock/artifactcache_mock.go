@@ -0,0 +1,37 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package mock implements a mock of the artifact cache
+package mock
+
+import (
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/stretchr/testify/mock"
+)
+
+// CacheMock mocks artifactcache.Cache
+type CacheMock struct {
+	mock.Mock
+}
+
+// Get mocks artifactcache.Cache.Get
+func (m *CacheMock) Get(log log.T, checksum string, destination string) bool {
+	args := m.Called(log, checksum, destination)
+	return args.Bool(0)
+}
+
+// Put mocks artifactcache.Cache.Put
+func (m *CacheMock) Put(log log.T, checksum string, source string) error {
+	args := m.Called(log, checksum, source)
+	return args.Error(0)
+}
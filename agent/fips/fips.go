@@ -0,0 +1,19 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package fips reports whether the agent is built to use FIPS validated cryptography only.
+//
+// Building with the fips tag restricts TLS to the FIPS approved settings of the validated module,
+// which requires a Go toolchain with BoringCrypto, such as GOEXPERIMENT=boringcrypto go build -tags fips.
+// A build with the fips tag and without BoringCrypto fails, so it cannot silently use other crypto.
+package fips
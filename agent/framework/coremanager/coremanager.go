@@ -25,11 +25,15 @@ import (
 	"github.com/aws/amazon-ssm-agent/agent/contracts"
 	"github.com/aws/amazon-ssm-agent/agent/fileutil"
 	"github.com/aws/amazon-ssm-agent/agent/framework/coremodules"
+	"github.com/aws/amazon-ssm-agent/agent/framework/docmanager"
+	"github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/outofproc"
 	"github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/plugin"
 	"github.com/aws/amazon-ssm-agent/agent/framework/runpluginutil"
 	logger "github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/outbox"
 	"github.com/aws/amazon-ssm-agent/agent/platform"
 	"github.com/aws/amazon-ssm-agent/agent/rebooter"
+	"github.com/aws/amazon-ssm-agent/agent/statecrypto"
 )
 
 const (
@@ -114,33 +118,21 @@ func initializeBookkeepingLocations(log logger.T, instanceID string) bool {
 
 	//TODO: initializations for all state tracking folders of core modules should be moved inside the corresponding core modules.
 
-	//Create folders pending, current, completed, corrupt under the location DefaultLogDirPath/<instanceId>
+	//The document states are kept in the state store, the completed folder is a legacy folder
 	log.Info("Initializing bookkeeping folders")
 	initStatus := true
-	folders := []string{
-		appconfig.DefaultLocationOfPending,
-		appconfig.DefaultLocationOfCurrent,
-		appconfig.DefaultLocationOfCompleted,
-		appconfig.DefaultLocationOfCorrupt}
-
-	for _, folder := range folders {
-
-		directoryName := filepath.Join(appconfig.DefaultDataStorePath,
-			instanceID,
-			appconfig.DefaultDocumentRootDirName,
-			appconfig.DefaultLocationOfState,
-			folder)
-		//legacy dir, unused
-		if folder == appconfig.DefaultLocationOfCompleted {
-			log.Info("removing the completed state files")
-			fileutil.DeleteDirectory(directoryName)
-		}
-		err := fileutil.MakeDirs(directoryName)
-		if err != nil {
-			log.Errorf("Encountered error while creating folders for internal state management. %v", err)
-			initStatus = false
-			break
-		}
+	log.Info("removing the completed state files")
+	fileutil.DeleteDirectory(filepath.Join(appconfig.DefaultDataStorePath,
+		instanceID,
+		appconfig.DefaultDocumentRootDirName,
+		appconfig.DefaultLocationOfState,
+		appconfig.DefaultLocationOfCompleted))
+
+	//Open the document state store, moving the state files of the previous folders into it
+	stateStore, err := docmanager.StateStore(log, instanceID)
+	if err != nil {
+		log.Errorf("Encountered error while opening the document state store. %v", err)
+		initStatus = false
 	}
 
 	//Create folders for long running plugins
@@ -220,6 +212,16 @@ func initializeBookkeepingLocations(log logger.T, instanceID string) bool {
 		initStatus = false
 	}
 
+	//Rewrite the persisted document state and replies in the configured encryption form
+	log.Infof("Migrating the encryption of the document state and replies")
+	var stores []statecrypto.Store
+	if stateStore != nil {
+		stores = append(stores, stateStore)
+	}
+	if err := statecrypto.MigrateStores(log, stores, replies, outbox.Dir()); err != nil {
+		log.Warnf("encountered error while migrating the encryption of the document state, the remaining states are migrated on the next start. %v", err)
+	}
+
 	return initStatus
 }
 
@@ -232,7 +234,10 @@ func (c *CoreManager) Start() {
 // Stop requests the core modules to stop executing
 // Stop would be called by the agent and should be treated as hard stop
 func (c *CoreManager) Stop() {
-	c.stopCoreModules(contracts.StopTypeHardStop)
+	// drain the in-flight documents, they get the configured stop timeout to complete
+	c.stopCoreModules(contracts.StopTypeSoftStop)
+	// the pooled document workers are idle once the documents drained
+	outofproc.StopWorkerPool()
 }
 
 // executeCoreModules launches all the core modules
@@ -260,11 +265,9 @@ func (c *CoreManager) stopCoreModules(stopType contracts.StopType) {
 	var wg sync.WaitGroup
 	l := len(c.coreModules)
 	for i := 0; i < l; i++ {
+		wg.Add(1)
 		go func(wgc *sync.WaitGroup, i int) {
-			if stopType == contracts.StopTypeSoftStop {
-				wgc.Add(1)
-				defer wgc.Done()
-			}
+			defer wgc.Done()
 
 			module := c.coreModules[i]
 			if err := module.ModuleRequestStop(stopType); err != nil {
@@ -276,10 +279,21 @@ func (c *CoreManager) stopCoreModules(stopType contracts.StopType) {
 		}(&wg, i)
 	}
 
-	// use waitgroups in case of softstop to wait for the core modules to finish their work
+	// use waitgroups in case of softstop to wait for the core modules to finish their work,
+	// bounded so that a stuck module does not keep the agent from stopping
 	// use timeout for hardstop and return control
 	if stopType == contracts.StopTypeSoftStop {
-		wg.Wait()
+		done := make(chan struct{})
+		go func() {
+			wg.Wait()
+			close(done)
+		}()
+		softStopTimeout := hardStopTimeout + time.Duration(c.context.AppConfig().Mds.StopTimeoutMillis)*time.Millisecond
+		select {
+		case <-done:
+		case <-time.After(softStopTimeout):
+			log.Warnf("core modules did not stop within %v", softStopTimeout)
+		}
 	} else {
 		time.Sleep(hardStopTimeout)
 	}
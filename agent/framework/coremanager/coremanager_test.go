@@ -128,8 +128,9 @@ func (suite *CoreManagerTestSuite) TestCoreManager_Start_Reboot() {
 // Unit testing function for Stop() method
 func (suite *CoreManagerTestSuite) TestCoreManager_Stop() {
 	suite.coreManager.Stop()
-	suite.moduleMock.AssertCalled(suite.T(), "ModuleRequestStop", contracts.StopTypeHardStop)
-	suite.moduleMock.AssertNotCalled(suite.T(), "ModuleRequestStop", contracts.StopTypeSoftStop)
+	// in-flight documents are drained
+	suite.moduleMock.AssertCalled(suite.T(), "ModuleRequestStop", contracts.StopTypeSoftStop)
+	suite.moduleMock.AssertNotCalled(suite.T(), "ModuleRequestStop", contracts.StopTypeHardStop)
 	suite.moduleMock.AssertNotCalled(suite.T(), "ModuleName")
 }
 
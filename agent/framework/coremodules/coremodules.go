@@ -22,6 +22,7 @@ import (
 	"github.com/aws/amazon-ssm-agent/agent/runcommand"
 	"github.com/aws/amazon-ssm-agent/agent/session"
 	"github.com/aws/amazon-ssm-agent/agent/ssm"
+	"github.com/aws/amazon-ssm-agent/agent/watchdog"
 )
 
 // ModuleRegistry stores a set of core modules.
@@ -63,4 +64,5 @@ func loadCoreModules(context context.T) {
 			context.Log().Errorf("Something went wrong during initialization of long running plugin manager")
 		}
 	}
+	registeredCoreModules = append(registeredCoreModules, watchdog.NewWatchdog(context))
 }
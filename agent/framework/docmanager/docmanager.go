@@ -15,7 +15,6 @@
 package docmanager
 
 import (
-	"os"
 	"path"
 	"path/filepath"
 	"regexp"
@@ -24,8 +23,10 @@ import (
 	"github.com/aws/amazon-ssm-agent/agent/appconfig"
 	"github.com/aws/amazon-ssm-agent/agent/contracts"
 	"github.com/aws/amazon-ssm-agent/agent/fileutil"
+	"github.com/aws/amazon-ssm-agent/agent/framework/docmanager/statestore"
 	"github.com/aws/amazon-ssm-agent/agent/jsonutil"
 	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/statecrypto"
 )
 
 const (
@@ -40,11 +41,14 @@ type DocumentMgr interface {
 	PersistDocumentState(log log.T, fileName, instanceID, locationFolder string, state contracts.DocumentState)
 	GetDocumentState(log log.T, fileName, instanceID, locationFolder string) contracts.DocumentState
 	RemoveDocumentState(log log.T, fileName, instanceID, locationFolder string)
+	ListDocumentStates(log log.T, instanceID, locationFolder string) []string
+	IsDocumentProcessed(log log.T, instanceID, messageID string) bool
+	MarkDocumentProcessed(log log.T, instanceID, messageID string)
 }
 
 //TODO use class lock instead of global lock?
 //TODO decouple the DocState model to better fit the service-processor-executer architecture
-//DocumentFileMgr encapsulate the file access and perform bookkeeping operations at the specified file location
+//DocumentFileMgr encapsulate the state store access and perform bookkeeping operations at the specified location
 type DocumentFileMgr struct {
 	dataStorePath string
 	rootDirName   string
@@ -60,106 +64,126 @@ func NewDocumentFileMgr(dataStorePath, rootDirName, stateLocation string) *Docum
 }
 
 func (d *DocumentFileMgr) MoveDocumentState(log log.T, fileName, instanceID, srcLocationFolder, dstLocationFolder string) {
+	store, err := d.stateStore(log, instanceID)
+	if err != nil {
+		log.Debugf("moving state %v from %v to %v failed with error %v", fileName, srcLocationFolder, dstLocationFolder, err)
+		return
+	}
 
-	absoluteSource := path.Join(d.dataStorePath,
-		instanceID,
-		d.rootDirName,
-		d.stateLocation,
-		srcLocationFolder)
-
-	absoluteDestination := path.Join(d.dataStorePath,
-		instanceID,
-		d.rootDirName,
-		d.stateLocation,
-		dstLocationFolder)
-
-	if s, err := fileutil.MoveFile(fileName, absoluteSource, absoluteDestination); s && err == nil {
-		log.Debugf("moved file %v from %v to %v successfully", fileName, srcLocationFolder, dstLocationFolder)
+	content, found := store.Get(stateKey(srcLocationFolder, fileName))
+	if !found {
+		log.Debugf("moving state %v from %v to %v failed, the state does not exist", fileName, srcLocationFolder, dstLocationFolder)
+		return
+	}
+	if err = putState(log, store, stateKey(dstLocationFolder, fileName), content); err == nil {
+		err = store.Delete(stateKey(srcLocationFolder, fileName))
+	}
+	if err == nil {
+		log.Debugf("moved state %v from %v to %v successfully", fileName, srcLocationFolder, dstLocationFolder)
 	} else {
-		log.Debugf("moving file %v from %v to %v failed with error %v", fileName, srcLocationFolder, dstLocationFolder, err)
+		log.Debugf("moving state %v from %v to %v failed with error %v", fileName, srcLocationFolder, dstLocationFolder, err)
 	}
-
 }
 
 func (d *DocumentFileMgr) PersistDocumentState(log log.T, fileName, instanceID, locationFolder string, state contracts.DocumentState) {
-
-	absoluteFileName := path.Join(path.Join(d.dataStorePath,
-		instanceID,
-		d.rootDirName,
-		d.stateLocation,
-		locationFolder), fileName)
+	key := stateKey(locationFolder, fileName)
 
 	content, err := jsonutil.Marshal(state)
 	if err != nil {
 		log.Errorf("encountered error with message %v while marshalling %v to string", err, state)
+		return
+	}
+	store, err := d.stateStore(log, instanceID)
+	if err != nil {
+		log.Errorf("persisting interim state %v failed with error %v", key, err)
+		return
+	}
+	if _, found := store.Get(key); found {
+		log.Debugf("overwriting state %v", key)
+	}
+	log.Tracef("persisting interim state %v in %v", jsonutil.Indent(content), key)
+	sealed, err := statecrypto.Seal(log, []byte(jsonutil.Indent(content)))
+	if err != nil {
+		log.Errorf("encountered error %v while encrypting interim state for %v", err, key)
+		return
+	}
+	if err = putState(log, store, key, sealed); err == nil {
+		log.Debugf("successfully persisted interim state in %v", locationFolder)
 	} else {
-		if fileutil.Exists(absoluteFileName) {
-			log.Debugf("overwriting contents of %v", absoluteFileName)
-		}
-		log.Tracef("persisting interim state %v in file %v", jsonutil.Indent(content), absoluteFileName)
-		if s, err := fileutil.WriteIntoFileWithPermissions(absoluteFileName, jsonutil.Indent(content), os.FileMode(int(appconfig.ReadWriteAccess))); s && err == nil {
-			log.Debugf("successfully persisted interim state in %v", locationFolder)
-		} else {
-			log.Debugf("persisting interim state in %v failed with error %v", locationFolder, err)
-		}
+		log.Errorf("persisting interim state in %v failed with error %v", locationFolder, err)
 	}
 }
 
 func (d *DocumentFileMgr) GetDocumentState(log log.T, fileName, instanceID, locationFolder string) contracts.DocumentState {
-	filepath := path.Join(d.dataStorePath,
-		instanceID,
-		d.rootDirName,
-		d.stateLocation,
-		locationFolder)
-
-	absoluteFileName := path.Join(filepath, fileName)
-
 	var commandState contracts.DocumentState
-	err := jsonutil.UnmarshalFile(absoluteFileName, &commandState)
+	store, err := d.stateStore(log, instanceID)
 	if err != nil {
-		log.Errorf("encountered error with message %v while reading Interim state of command from file - %v", err, fileName)
-		if fileExists, _ := fileutil.LocalFileExist(absoluteFileName); fileExists {
-			if documentContents, err := fileutil.ReadAllText(absoluteFileName); err == nil {
-				log.Infof("Document contents: %v", documentContents)
-			}
-
-			d.MoveDocumentState(log, fileName, instanceID, locationFolder, appconfig.DefaultLocationOfCorrupt)
-		}
+		log.Errorf("encountered error with message %v while reading Interim state of command - %v", err, fileName)
+		return commandState
+	}
+	content, found := store.Get(stateKey(locationFolder, fileName))
+	if !found {
+		log.Errorf("Interim state of command %v does not exist in %v", fileName, locationFolder)
+		return commandState
+	}
 
+	if err = unmarshalState(log, content, &commandState); err != nil {
+		log.Errorf("encountered error with message %v while reading Interim state of command - %v", err, fileName)
+		log.Infof("Document contents: %v", string(content))
+		d.MoveDocumentState(log, fileName, instanceID, locationFolder, appconfig.DefaultLocationOfCorrupt)
 	} else {
-		//logging interim state as read from the file
+		//logging interim state as read from the store
 		jsonString, err := jsonutil.Marshal(commandState)
 		if err != nil {
 			log.Errorf("encountered error with message %v while marshalling %v to string", err, commandState)
 		} else {
-			log.Tracef("interim CommandState read from file-system - %v", jsonutil.Indent(jsonString))
+			log.Tracef("interim CommandState read from the state store - %v", jsonutil.Indent(jsonString))
 		}
 	}
 
 	return commandState
 }
 
-// RemoveData deletes the fileName from locationFolder under defaultLogDir/instanceID
+// RemoveDocumentState deletes the state of the command from locationFolder
 func (d *DocumentFileMgr) RemoveDocumentState(log log.T, commandID, instanceID, locationFolder string) {
-
-	absoluteFileName := docStateFileName(commandID, instanceID, locationFolder)
-
-	err := fileutil.DeleteFile(absoluteFileName)
+	key := stateKey(locationFolder, commandID)
+	store, err := d.stateStore(log, instanceID)
+	if err == nil {
+		err = store.Delete(key)
+	}
 	if err != nil {
-		log.Errorf("encountered error %v while deleting file %v", err, absoluteFileName)
+		log.Errorf("encountered error %v while deleting state %v", err, key)
 	} else {
-		log.Debugf("successfully deleted file %v", absoluteFileName)
+		log.Debugf("successfully deleted state %v", key)
 	}
 }
 
+// ListDocumentStates returns the names of the states in locationFolder, from the least recently written
+func (d *DocumentFileMgr) ListDocumentStates(log log.T, instanceID, locationFolder string) []string {
+	store, err := d.stateStore(log, instanceID)
+	if err != nil {
+		log.Errorf("encountered error %v while listing the states in %v", err, locationFolder)
+		return nil
+	}
+	return stateNames(store, locationFolder)
+}
+
+// stateStore returns the state store of the instance
+func (d *DocumentFileMgr) stateStore(log log.T, instanceID string) (*statestore.Store, error) {
+	return openStateStore(log, path.Join(d.dataStorePath,
+		instanceID,
+		d.rootDirName,
+		d.stateLocation,
+		stateStoreFileName))
+}
+
 //TODO rework this part
-// DocumentStateDir returns absolute filename where command states are persisted
-func DocumentStateDir(instanceID, locationFolder string) string {
+// DocumentStateDir returns the absolute path of the directory the state store of the documents is in
+func DocumentStateDir(instanceID string) string {
 	return filepath.Join(appconfig.DefaultDataStorePath,
 		instanceID,
 		appconfig.DefaultDocumentRootDirName,
-		appconfig.DefaultLocationOfState,
-		locationFolder)
+		appconfig.DefaultLocationOfState)
 }
 
 // orchestrationDir returns the absolute path of the orchestration directory
@@ -359,8 +383,3 @@ func isOlderThan(log log.T, fileFullPath string, retentionDurationHours int) boo
 	// Check whether the current time is after modification time plus the retention duration
 	return modificationTime.Add(time.Hour * time.Duration(retentionDurationHours)).Before(time.Now())
 }
-
-// docStateFileName returns absolute filename where command states are persisted
-func docStateFileName(fileName, instanceID, locationFolder string) string {
-	return path.Join(DocumentStateDir(instanceID, locationFolder), fileName)
-}
@@ -0,0 +1,134 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package docmanager
+
+import (
+	"encoding/json"
+	"strings"
+	"sync"
+
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+)
+
+// MemoryDocumentMgr keeps the document states in memory. The sub-documents run by plugins use it: they run in the
+// document worker, which must not open the state store the agent holds, and their states are not needed once they finish.
+type MemoryDocumentMgr struct {
+	lock      sync.Mutex
+	states    map[string][]byte
+	keys      []string
+	processed map[string]bool
+}
+
+// NewMemoryDocumentMgr returns an empty MemoryDocumentMgr
+func NewMemoryDocumentMgr() *MemoryDocumentMgr {
+	return &MemoryDocumentMgr{
+		states:    make(map[string][]byte),
+		processed: make(map[string]bool),
+	}
+}
+
+func (d *MemoryDocumentMgr) MoveDocumentState(log log.T, fileName, instanceID, srcLocationFolder, dstLocationFolder string) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	content, found := d.states[stateKey(srcLocationFolder, fileName)]
+	if !found {
+		log.Debugf("moving state %v from %v to %v failed, the state does not exist", fileName, srcLocationFolder, dstLocationFolder)
+		return
+	}
+	d.delete(stateKey(srcLocationFolder, fileName))
+	d.put(stateKey(dstLocationFolder, fileName), content)
+}
+
+func (d *MemoryDocumentMgr) PersistDocumentState(log log.T, fileName, instanceID, locationFolder string, state contracts.DocumentState) {
+	// the state is kept marshalled so later changes of the caller's copy do not leak into it
+	content, err := json.Marshal(state)
+	if err != nil {
+		log.Errorf("encountered error with message %v while marshalling %v to string", err, state)
+		return
+	}
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	d.put(stateKey(locationFolder, fileName), content)
+}
+
+func (d *MemoryDocumentMgr) GetDocumentState(log log.T, fileName, instanceID, locationFolder string) contracts.DocumentState {
+	var state contracts.DocumentState
+	d.lock.Lock()
+	content, found := d.states[stateKey(locationFolder, fileName)]
+	d.lock.Unlock()
+	if !found {
+		log.Errorf("Interim state of command %v does not exist in %v", fileName, locationFolder)
+		return state
+	}
+	if err := json.Unmarshal(content, &state); err != nil {
+		log.Errorf("encountered error with message %v while reading Interim state of command - %v", err, fileName)
+	}
+	return state
+}
+
+func (d *MemoryDocumentMgr) RemoveDocumentState(log log.T, fileName, instanceID, locationFolder string) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	d.delete(stateKey(locationFolder, fileName))
+}
+
+// ListDocumentStates returns the names of the states in locationFolder, from the least recently written
+func (d *MemoryDocumentMgr) ListDocumentStates(log log.T, instanceID, locationFolder string) []string {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	prefix := stateKey(locationFolder, "")
+	var names []string
+	for _, key := range d.keys {
+		if strings.HasPrefix(key, prefix) {
+			names = append(names, strings.TrimPrefix(key, prefix))
+		}
+	}
+	return names
+}
+
+func (d *MemoryDocumentMgr) IsDocumentProcessed(log log.T, instanceID, messageID string) bool {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	return d.processed[messageID]
+}
+
+func (d *MemoryDocumentMgr) MarkDocumentProcessed(log log.T, instanceID, messageID string) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	d.processed[messageID] = true
+}
+
+// put writes the state as the most recently written one, the caller holds the lock
+func (d *MemoryDocumentMgr) put(key string, content []byte) {
+	d.delete(key)
+	d.states[key] = content
+	d.keys = append(d.keys, key)
+}
+
+// delete removes the state, the caller holds the lock
+func (d *MemoryDocumentMgr) delete(key string) {
+	if _, found := d.states[key]; !found {
+		return
+	}
+	delete(d.states, key)
+	for i, existing := range d.keys {
+		if existing == key {
+			d.keys = append(d.keys[:i], d.keys[i+1:]...)
+			break
+		}
+	}
+}
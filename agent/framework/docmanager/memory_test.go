@@ -0,0 +1,44 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package docmanager
+
+import (
+	"testing"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryDocumentMgr(t *testing.T) {
+	docMgr := NewMemoryDocumentMgr()
+	logger := log.NewMockLog()
+
+	docMgr.PersistDocumentState(logger, "document-1", "instanceID", appconfig.DefaultLocationOfCurrent, testDocumentState("document-1"))
+	docMgr.PersistDocumentState(logger, "document-2", "instanceID", appconfig.DefaultLocationOfCurrent, testDocumentState("document-2"))
+	docMgr.PersistDocumentState(logger, "document-1", "instanceID", appconfig.DefaultLocationOfCurrent, testDocumentState("document-1"))
+	assert.Equal(t, []string{"document-2", "document-1"}, docMgr.ListDocumentStates(logger, "instanceID", appconfig.DefaultLocationOfCurrent))
+
+	docMgr.MoveDocumentState(logger, "document-2", "instanceID", appconfig.DefaultLocationOfCurrent, appconfig.DefaultLocationOfCompleted)
+	assert.Equal(t, []string{"document-1"}, docMgr.ListDocumentStates(logger, "instanceID", appconfig.DefaultLocationOfCurrent))
+	state := docMgr.GetDocumentState(logger, "document-2", "instanceID", appconfig.DefaultLocationOfCompleted)
+	assert.Equal(t, "document-2", state.DocumentInformation.DocumentID)
+
+	docMgr.RemoveDocumentState(logger, "document-1", "instanceID", appconfig.DefaultLocationOfCurrent)
+	assert.Empty(t, docMgr.ListDocumentStates(logger, "instanceID", appconfig.DefaultLocationOfCurrent))
+
+	assert.False(t, docMgr.IsDocumentProcessed(logger, "instanceID", "message-1"))
+	docMgr.MarkDocumentProcessed(logger, "instanceID", "message-1")
+	assert.True(t, docMgr.IsDocumentProcessed(logger, "instanceID", "message-1"))
+}
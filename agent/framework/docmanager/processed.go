@@ -0,0 +1,120 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package docmanager
+
+import (
+	"os"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/fileutil"
+	"github.com/aws/amazon-ssm-agent/agent/jsonutil"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+)
+
+const (
+	// processedDocumentsFileName is the file under the document root directory that records started documents
+	processedDocumentsFileName = "processed_documents.json"
+
+	// processedDocumentRetention is how long a started document is remembered, messages are not redelivered after they expire
+	processedDocumentRetention = time.Hour * 48
+
+	// maxProcessedDocuments bounds the number of remembered documents, the oldest are dropped first
+	maxProcessedDocuments = 10000
+)
+
+// processedLock serializes access to the processed documents file of all document managers
+var processedLock sync.Mutex
+
+var timeNow = time.Now
+
+// IsDocumentProcessed returns true if a document with the given message id was already started within the retention window
+func (d *DocumentFileMgr) IsDocumentProcessed(log log.T, instanceID, messageID string) bool {
+	processedLock.Lock()
+	defer processedLock.Unlock()
+
+	_, found := d.loadProcessedDocuments(log, instanceID)[messageID]
+	return found
+}
+
+// MarkDocumentProcessed records that the document with the given message id was started, so a redelivery of the
+// message after an agent restart does not run it again
+func (d *DocumentFileMgr) MarkDocumentProcessed(log log.T, instanceID, messageID string) {
+	processedLock.Lock()
+	defer processedLock.Unlock()
+
+	processed := d.loadProcessedDocuments(log, instanceID)
+	processed[messageID] = timeNow().UTC()
+	pruneProcessedDocuments(processed)
+
+	content, err := jsonutil.Marshal(processed)
+	if err != nil {
+		log.Errorf("encountered error with message %v while marshalling processed documents", err)
+		return
+	}
+	fileName := d.processedDocumentsFile(instanceID)
+	if err = fileutil.MakeDirs(path.Dir(fileName)); err != nil {
+		log.Errorf("failed to create directory for processed documents %v: %v", fileName, err)
+		return
+	}
+	if s, err := fileutil.WriteIntoFileWithPermissions(fileName, content, os.FileMode(int(appconfig.ReadWriteAccess))); s && err == nil {
+		log.Debugf("recorded document %v as processed", messageID)
+	} else {
+		log.Errorf("recording document %v as processed in %v failed with error %v", messageID, fileName, err)
+	}
+}
+
+// loadProcessedDocuments reads the processed documents file, dropping the entries outside the retention window
+func (d *DocumentFileMgr) loadProcessedDocuments(log log.T, instanceID string) map[string]time.Time {
+	processed := make(map[string]time.Time)
+	fileName := d.processedDocumentsFile(instanceID)
+	if !fileutil.Exists(fileName) {
+		return processed
+	}
+	if err := jsonutil.UnmarshalFile(fileName, &processed); err != nil {
+		log.Warnf("ignoring unreadable processed documents file %v: %v", fileName, err)
+		return make(map[string]time.Time)
+	}
+	pruneProcessedDocuments(processed)
+	return processed
+}
+
+// pruneProcessedDocuments removes the expired entries and then the oldest ones above maxProcessedDocuments
+func pruneProcessedDocuments(processed map[string]time.Time) {
+	expiry := timeNow().UTC().Add(-processedDocumentRetention)
+	for messageID, startTime := range processed {
+		if startTime.Before(expiry) {
+			delete(processed, messageID)
+		}
+	}
+	for len(processed) > maxProcessedDocuments {
+		var oldestID string
+		var oldestTime time.Time
+		for messageID, startTime := range processed {
+			if oldestID == "" || startTime.Before(oldestTime) {
+				oldestID, oldestTime = messageID, startTime
+			}
+		}
+		delete(processed, oldestID)
+	}
+}
+
+func (d *DocumentFileMgr) processedDocumentsFile(instanceID string) string {
+	return path.Join(d.dataStorePath,
+		instanceID,
+		d.rootDirName,
+		processedDocumentsFileName)
+}
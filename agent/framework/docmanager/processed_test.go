@@ -0,0 +1,96 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package docmanager
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func mockTimeNow(now time.Time) func() {
+	original := timeNow
+	timeNow = func() time.Time { return now }
+	return func() { timeNow = original }
+}
+
+func TestMarkDocumentProcessedPersistsAcrossManagers(t *testing.T) {
+	dataStorePath, _ := ioutil.TempDir("", "docmanager")
+	defer os.RemoveAll(dataStorePath)
+	logger := log.NewMockLog()
+
+	docMgr := NewDocumentFileMgr(dataStorePath, "document", "state")
+	assert.False(t, docMgr.IsDocumentProcessed(logger, "instanceID", "messageID"))
+	docMgr.MarkDocumentProcessed(logger, "instanceID", "messageID")
+
+	// a new manager reads the record written before the restart
+	restarted := NewDocumentFileMgr(dataStorePath, "document", "state")
+	assert.True(t, restarted.IsDocumentProcessed(logger, "instanceID", "messageID"))
+	assert.False(t, restarted.IsDocumentProcessed(logger, "instanceID", "otherMessageID"))
+	assert.False(t, restarted.IsDocumentProcessed(logger, "otherInstanceID", "messageID"))
+}
+
+func TestIsDocumentProcessedExpiresAfterRetention(t *testing.T) {
+	dataStorePath, _ := ioutil.TempDir("", "docmanager")
+	defer os.RemoveAll(dataStorePath)
+	logger := log.NewMockLog()
+	now := time.Now()
+
+	docMgr := NewDocumentFileMgr(dataStorePath, "document", "state")
+	restore := mockTimeNow(now)
+	docMgr.MarkDocumentProcessed(logger, "instanceID", "messageID")
+	restore()
+
+	defer mockTimeNow(now.Add(processedDocumentRetention + time.Minute))()
+	assert.False(t, docMgr.IsDocumentProcessed(logger, "instanceID", "messageID"))
+}
+
+func TestIsDocumentProcessedIgnoresCorruptFile(t *testing.T) {
+	dataStorePath, _ := ioutil.TempDir("", "docmanager")
+	defer os.RemoveAll(dataStorePath)
+	logger := log.NewMockLog()
+
+	docMgr := NewDocumentFileMgr(dataStorePath, "document", "state")
+	os.MkdirAll(dataStorePath+"/instanceID/document", 0700)
+	ioutil.WriteFile(docMgr.processedDocumentsFile("instanceID"), []byte("not json"), 0600)
+
+	assert.False(t, docMgr.IsDocumentProcessed(logger, "instanceID", "messageID"))
+	docMgr.MarkDocumentProcessed(logger, "instanceID", "messageID")
+	assert.True(t, docMgr.IsDocumentProcessed(logger, "instanceID", "messageID"))
+}
+
+func TestPruneProcessedDocumentsDropsOldestAboveLimit(t *testing.T) {
+	now := time.Now().UTC()
+	defer mockTimeNow(now)()
+
+	processed := make(map[string]time.Time)
+	for i := 0; i <= maxProcessedDocuments; i++ {
+		processed[fmt.Sprintf("message%d", i)] = now.Add(time.Duration(i) * time.Millisecond)
+	}
+	processed["oldest"] = now.Add(-time.Hour)
+	processed["expired"] = now.Add(-processedDocumentRetention - time.Hour)
+
+	pruneProcessedDocuments(processed)
+
+	assert.Equal(t, maxProcessedDocuments, len(processed))
+	_, found := processed["oldest"]
+	assert.False(t, found)
+	_, found = processed["expired"]
+	assert.False(t, found)
+}
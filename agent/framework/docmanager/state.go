@@ -0,0 +1,212 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package docmanager
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/framework/docmanager/statestore"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/statecrypto"
+)
+
+// stateStoreFileName is the file under the state directory of the documents that keeps their states
+const stateStoreFileName = "documents.db"
+
+// stateLocations are the locations of the states, before the state store each was a directory with a file per state
+var stateLocations = []string{
+	appconfig.DefaultLocationOfPending,
+	appconfig.DefaultLocationOfCurrent,
+	appconfig.DefaultLocationOfCorrupt,
+}
+
+var (
+	getAppConfig = appconfig.Config
+
+	// stateStoresLock guards stateStores, a state store is opened once per process
+	stateStoresLock sync.Mutex
+	stateStores     = make(map[string]*statestore.Store)
+)
+
+// StateStore returns the document state store of the instance
+func StateStore(log log.T, instanceID string) (*statestore.Store, error) {
+	return openStateStore(log, filepath.Join(DocumentStateDir(instanceID), stateStoreFileName))
+}
+
+// DocumentStates returns the states in locationFolder of the instance, the states that cannot be read are skipped
+func DocumentStates(log log.T, instanceID, locationFolder string) []contracts.DocumentState {
+	store, err := StateStore(log, instanceID)
+	if err != nil {
+		log.Debugf("failed to open the document state store: %v", err)
+		return nil
+	}
+	var states []contracts.DocumentState
+	for _, name := range stateNames(store, locationFolder) {
+		content, found := store.Get(stateKey(locationFolder, name))
+		if !found {
+			continue
+		}
+		var state contracts.DocumentState
+		if err = unmarshalState(log, content, &state); err != nil {
+			log.Debugf("skipping document state %v: %v", name, err)
+			continue
+		}
+		states = append(states, state)
+	}
+	return states
+}
+
+// HasDocumentState returns true when the state store of the instance has a state named name in locationFolder.
+// The store is read as it is, for the processes that do not run the documents.
+func HasDocumentState(instanceID, locationFolder, name string) bool {
+	_, err := readStateContent(instanceID, locationFolder, name)
+	return err == nil
+}
+
+// ReadDocumentState reads the state named name in locationFolder into dest, the store is read as it is for the
+// processes that do not own it
+func ReadDocumentState(log log.T, instanceID, locationFolder, name string, dest interface{}) error {
+	content, err := readStateContent(instanceID, locationFolder, name)
+	if err != nil {
+		return err
+	}
+	return unmarshalState(log, content, dest)
+}
+
+// readStateContent returns the content of the state from the state store file of the instance
+func readStateContent(instanceID, locationFolder, name string) ([]byte, error) {
+	return readStateFile(filepath.Join(DocumentStateDir(instanceID), stateStoreFileName), locationFolder, name)
+}
+
+// readStateFile returns the content of the state from the state store file at storePath
+func readStateFile(storePath, locationFolder, name string) ([]byte, error) {
+	store, err := statestore.OpenReadOnly(storePath)
+	if err != nil {
+		return nil, err
+	}
+	content, found := store.Get(stateKey(locationFolder, name))
+	if !found {
+		return nil, fmt.Errorf("document state %v is not in %v", name, locationFolder)
+	}
+	return content, nil
+}
+
+// openStateStore returns the state store at storePath, opening it on its first use.
+// The state files of the previous layout are moved into the store when it is opened.
+func openStateStore(log log.T, storePath string) (*statestore.Store, error) {
+	stateStoresLock.Lock()
+	defer stateStoresLock.Unlock()
+	if store, found := stateStores[storePath]; found {
+		return store, nil
+	}
+
+	maxSizeMB := appconfig.DefaultStateStoreMaxSizeMB
+	if config, err := getAppConfig(false); err == nil {
+		maxSizeMB = config.StateStore.MaxSizeMB
+	}
+	store, err := statestore.Open(log, storePath, int64(maxSizeMB)*1024*1024)
+	if err != nil {
+		return nil, err
+	}
+	importStateFiles(log, store, filepath.Dir(storePath))
+	stateStores[storePath] = store
+	return store, nil
+}
+
+// importStateFiles moves the state files of the state location directories into the store,
+// and removes the directories once they are empty
+func importStateFiles(log log.T, store *statestore.Store, stateDir string) {
+	imported := 0
+	for _, location := range stateLocations {
+		locationDir := filepath.Join(stateDir, location)
+		files, err := ioutil.ReadDir(locationDir)
+		if err != nil {
+			continue
+		}
+		for _, file := range files {
+			if !file.Mode().IsRegular() {
+				continue
+			}
+			filePath := filepath.Join(locationDir, file.Name())
+			content, err := ioutil.ReadFile(filePath)
+			if err == nil {
+				err = putState(log, store, stateKey(location, file.Name()), content)
+			}
+			if err != nil {
+				log.Warnf("Failed to move document state %v into the state store, it is moved on the next start: %v", filePath, err)
+				continue
+			}
+			os.Remove(filePath)
+			imported++
+		}
+		os.Remove(locationDir)
+	}
+	if imported > 0 {
+		log.Infof("Moved %v document state files into the state store", imported)
+	}
+}
+
+// putState writes the state, when the store is full the states of corrupt documents are dropped from the oldest
+// until the state fits
+func putState(log log.T, store *statestore.Store, key string, content []byte) error {
+	err := store.Put(key, content)
+	if err != statestore.ErrFull {
+		return err
+	}
+	for _, corruptKey := range store.Keys(stateKey(appconfig.DefaultLocationOfCorrupt, "")) {
+		if corruptKey == key {
+			continue
+		}
+		log.Warnf("The document state store is full, dropping the state %v", corruptKey)
+		if err = store.Delete(corruptKey); err != nil {
+			return err
+		}
+		if err = store.Put(key, content); err != statestore.ErrFull {
+			return err
+		}
+	}
+	return err
+}
+
+// unmarshalState reads a state written in plaintext or by statecrypto.Seal into dest
+func unmarshalState(log log.T, content []byte, dest interface{}) error {
+	plaintext, err := statecrypto.Open(log, content)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(plaintext, dest)
+}
+
+// stateKey returns the key of the state named name in locationFolder
+func stateKey(locationFolder, name string) string {
+	return locationFolder + "/" + name
+}
+
+// stateNames returns the names of the states in locationFolder, from the least recently written
+func stateNames(store *statestore.Store, locationFolder string) []string {
+	prefix := stateKey(locationFolder, "")
+	var names []string
+	for _, key := range store.Keys(prefix) {
+		names = append(names, strings.TrimPrefix(key, prefix))
+	}
+	return names
+}
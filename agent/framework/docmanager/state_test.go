@@ -0,0 +1,162 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package docmanager
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/stretchr/testify/assert"
+)
+
+// newStateTestMgr returns a document manager on a temporary data store, its state stores are closed on cleanup
+func newStateTestMgr(t *testing.T) (*DocumentFileMgr, string) {
+	dataStorePath := t.TempDir()
+	t.Cleanup(func() {
+		stateStoresLock.Lock()
+		defer stateStoresLock.Unlock()
+		for storePath, store := range stateStores {
+			if strings.HasPrefix(storePath, dataStorePath) {
+				store.Close()
+				delete(stateStores, storePath)
+			}
+		}
+	})
+	return NewDocumentFileMgr(dataStorePath, "document", "state"), dataStorePath
+}
+
+func testDocumentState(documentID string) contracts.DocumentState {
+	return contracts.DocumentState{
+		DocumentType: contracts.SendCommand,
+		DocumentInformation: contracts.DocumentInfo{
+			DocumentID:     documentID,
+			DocumentStatus: contracts.ResultStatusInProgress,
+		},
+	}
+}
+
+func TestPersistGetAndRemoveDocumentState(t *testing.T) {
+	docMgr, _ := newStateTestMgr(t)
+	logger := log.NewMockLog()
+
+	docMgr.PersistDocumentState(logger, "command-1", "instanceID", appconfig.DefaultLocationOfCurrent, testDocumentState("command-1"))
+	docMgr.PersistDocumentState(logger, "command-2", "instanceID", appconfig.DefaultLocationOfCurrent, testDocumentState("command-2"))
+	docMgr.PersistDocumentState(logger, "command-3", "instanceID", appconfig.DefaultLocationOfPending, testDocumentState("command-3"))
+
+	state := docMgr.GetDocumentState(logger, "command-1", "instanceID", appconfig.DefaultLocationOfCurrent)
+	assert.Equal(t, "command-1", state.DocumentInformation.DocumentID)
+	assert.Equal(t, []string{"command-1", "command-2"}, docMgr.ListDocumentStates(logger, "instanceID", appconfig.DefaultLocationOfCurrent))
+	assert.Equal(t, []string{"command-3"}, docMgr.ListDocumentStates(logger, "instanceID", appconfig.DefaultLocationOfPending))
+
+	docMgr.RemoveDocumentState(logger, "command-1", "instanceID", appconfig.DefaultLocationOfCurrent)
+	assert.Equal(t, []string{"command-2"}, docMgr.ListDocumentStates(logger, "instanceID", appconfig.DefaultLocationOfCurrent))
+	assert.Empty(t, docMgr.ListDocumentStates(logger, "otherInstanceID", appconfig.DefaultLocationOfCurrent))
+}
+
+func TestMoveDocumentState(t *testing.T) {
+	docMgr, _ := newStateTestMgr(t)
+	logger := log.NewMockLog()
+
+	docMgr.PersistDocumentState(logger, "command-1", "instanceID", appconfig.DefaultLocationOfCurrent, testDocumentState("command-1"))
+	docMgr.MoveDocumentState(logger, "command-1", "instanceID", appconfig.DefaultLocationOfCurrent, appconfig.DefaultLocationOfCorrupt)
+
+	assert.Empty(t, docMgr.ListDocumentStates(logger, "instanceID", appconfig.DefaultLocationOfCurrent))
+	state := docMgr.GetDocumentState(logger, "command-1", "instanceID", appconfig.DefaultLocationOfCorrupt)
+	assert.Equal(t, "command-1", state.DocumentInformation.DocumentID)
+}
+
+func TestUnreadableDocumentStateIsMovedToCorrupt(t *testing.T) {
+	docMgr, dataStorePath := newStateTestMgr(t)
+	logger := log.NewMockLog()
+
+	store, err := openStateStore(logger, filepath.Join(dataStorePath, "instanceID", "document", "state", stateStoreFileName))
+	assert.NoError(t, err)
+	assert.NoError(t, store.Put(stateKey(appconfig.DefaultLocationOfCurrent, "command-1"), []byte("{not json")))
+
+	state := docMgr.GetDocumentState(logger, "command-1", "instanceID", appconfig.DefaultLocationOfCurrent)
+	assert.Empty(t, state.DocumentInformation.DocumentID)
+	assert.Empty(t, docMgr.ListDocumentStates(logger, "instanceID", appconfig.DefaultLocationOfCurrent))
+	assert.Equal(t, []string{"command-1"}, docMgr.ListDocumentStates(logger, "instanceID", appconfig.DefaultLocationOfCorrupt))
+}
+
+func TestStateFilesAreImportedIntoTheStore(t *testing.T) {
+	docMgr, dataStorePath := newStateTestMgr(t)
+	logger := log.NewMockLog()
+
+	stateDir := filepath.Join(dataStorePath, "instanceID", "document", "state")
+	for _, location := range []string{appconfig.DefaultLocationOfPending, appconfig.DefaultLocationOfCurrent} {
+		assert.NoError(t, os.MkdirAll(filepath.Join(stateDir, location), appconfig.ReadWriteExecuteAccess))
+		content := []byte(`{"DocumentInformation":{"DocumentID":"` + location + `-command"}}`)
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(stateDir, location, location+"-command"), content, appconfig.ReadWriteAccess))
+	}
+
+	assert.Equal(t, []string{"pending-command"}, docMgr.ListDocumentStates(logger, "instanceID", appconfig.DefaultLocationOfPending))
+	state := docMgr.GetDocumentState(logger, "current-command", "instanceID", appconfig.DefaultLocationOfCurrent)
+	assert.Equal(t, "current-command", state.DocumentInformation.DocumentID)
+
+	// the imported folders are removed
+	for _, location := range []string{appconfig.DefaultLocationOfPending, appconfig.DefaultLocationOfCurrent} {
+		_, err := os.Stat(filepath.Join(stateDir, location))
+		assert.True(t, os.IsNotExist(err))
+	}
+}
+
+func TestCorruptStatesAreDroppedWhenTheStoreIsFull(t *testing.T) {
+	original := getAppConfig
+	getAppConfig = func(bool) (appconfig.SsmagentConfig, error) {
+		config := appconfig.DefaultConfig()
+		config.StateStore.MaxSizeMB = 1
+		return config, nil
+	}
+	defer func() { getAppConfig = original }()
+
+	_, dataStorePath := newStateTestMgr(t)
+	logger := log.NewMockLog()
+	store, err := openStateStore(logger, filepath.Join(dataStorePath, "instanceID", "document", "state", stateStoreFileName))
+	assert.NoError(t, err)
+
+	content := bytes.Repeat([]byte("a"), 300*1024)
+	for _, name := range []string{"command-1", "command-2", "command-3"} {
+		assert.NoError(t, putState(logger, store, stateKey(appconfig.DefaultLocationOfCorrupt, name), content))
+	}
+	assert.NoError(t, putState(logger, store, stateKey(appconfig.DefaultLocationOfCurrent, "command-4"), content))
+
+	// the oldest corrupt state made room for the new state
+	assert.Equal(t, []string{"command-2", "command-3"}, stateNames(store, appconfig.DefaultLocationOfCorrupt))
+	assert.Equal(t, []string{"command-4"}, stateNames(store, appconfig.DefaultLocationOfCurrent))
+}
+
+func TestReadDocumentStateReadsTheStoreFile(t *testing.T) {
+	docMgr, dataStorePath := newStateTestMgr(t)
+	logger := log.NewMockLog()
+	docMgr.PersistDocumentState(logger, "command-1", "instanceID", appconfig.DefaultLocationOfCurrent, testDocumentState("command-1"))
+
+	// ReadDocumentState reads the default data store path, the store file is read as another process would
+	storePath := filepath.Join(dataStorePath, "instanceID", "document", "state", stateStoreFileName)
+	content, err := readStateFile(storePath, appconfig.DefaultLocationOfCurrent, "command-1")
+	assert.NoError(t, err)
+	var state contracts.DocumentState
+	assert.NoError(t, unmarshalState(logger, content, &state))
+	assert.Equal(t, "command-1", state.DocumentInformation.DocumentID)
+
+	_, err = readStateFile(storePath, appconfig.DefaultLocationOfPending, "command-1")
+	assert.Error(t, err)
+}
@@ -0,0 +1,53 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// +build windows
+
+package statestore
+
+import (
+	"os"
+	"reflect"
+	"syscall"
+	"unsafe"
+)
+
+// mapFile maps the first size bytes of the file for reading, the writes to the file are visible in the mapping
+func mapFile(file *os.File, size int) ([]byte, error) {
+	handle, err := syscall.CreateFileMapping(syscall.Handle(file.Fd()), nil, syscall.PAGE_READONLY, uint32(uint64(size)>>32), uint32(size), nil)
+	if err != nil {
+		return nil, os.NewSyscallError("CreateFileMapping", err)
+	}
+	// the view keeps the mapping alive once its handle is closed
+	address, err := syscall.MapViewOfFile(handle, syscall.FILE_MAP_READ, 0, 0, uintptr(size))
+	syscall.CloseHandle(handle)
+	if address == 0 {
+		return nil, os.NewSyscallError("MapViewOfFile", err)
+	}
+
+	var data []byte
+	header := (*reflect.SliceHeader)(unsafe.Pointer(&data))
+	header.Data = address
+	header.Len = size
+	header.Cap = size
+	return data, nil
+}
+
+// unmapFile releases a mapping created by mapFile
+func unmapFile(data []byte) error {
+	if len(data) == 0 {
+		return nil
+	}
+	address := (*reflect.SliceHeader)(unsafe.Pointer(&data)).Data
+	return os.NewSyscallError("UnmapViewOfFile", syscall.UnmapViewOfFile(address))
+}
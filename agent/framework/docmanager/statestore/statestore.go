@@ -0,0 +1,551 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package statestore keeps small records, like the states of the documents of an instance, in a single file
+// instead of a file per record. Records are appended to the file and read through a memory mapping of it, a record
+// that is replaced or deleted takes space in the file until the store is compacted. Every record carries a checksum,
+// when the store is opened the records following a damaged one are dropped and the damaged file is kept aside.
+package statestore
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+)
+
+const (
+	// the file starts with fileMagic, the version of the format and 4 reserved bytes
+	fileMagic      = "SSMSTATE"
+	fileVersion    = 1
+	fileHeaderSize = 16
+
+	// a record is the checksum of the rest of the record, the key length, the value length, the key and the value.
+	// A record with a zero key length ends the records, the file is extended with zeros.
+	recordHeaderSize = 12
+	tombstone        = math.MaxUint32
+	maxKeyLength     = 1024
+
+	// growStep is the size the file is extended by
+	growStep = 64 * 1024
+
+	// a compaction runs once the replaced and deleted records take more than compactionMinDeadBytes
+	// and more than the live records
+	compactionMinDeadBytes = 1024 * 1024
+
+	compactSuffix = ".compact"
+	corruptSuffix = ".corrupt"
+)
+
+var (
+	// ErrFull is returned when a record does not fit in the maximum size of the store, even after a compaction
+	ErrFull = errors.New("state store is full")
+
+	// ErrReadOnly is returned when a store opened with OpenReadOnly is modified
+	ErrReadOnly = errors.New("state store is read only")
+)
+
+// entry locates a live record in the file
+type entry struct {
+	offset int64
+	size   int64
+	keyLen int64
+}
+
+// Store is a set of records kept in one file, it is safe for concurrent use within a process.
+// Only one process may open the file with Open, other processes read it with OpenReadOnly: the agent owns the document
+// state store of the instance, the sub-documents run in the document worker keep their states in memory.
+type Store struct {
+	lock     sync.Mutex
+	log      log.T
+	path     string
+	file     *os.File
+	data     []byte
+	mapped   bool
+	size     int64
+	maxSize  int64
+	index    map[string]entry
+	live     int64
+	readOnly bool
+}
+
+// Open opens the store file at path, creating it if needed, the file never grows above maxSize bytes.
+// Damaged records are dropped, a copy of the damaged file is kept next to it.
+func Open(log log.T, path string, maxSize int64) (*Store, error) {
+	if minSize := int64(fileHeaderSize + growStep); maxSize < minSize {
+		maxSize = minSize
+	}
+	if err := os.MkdirAll(filepath.Dir(path), appconfig.ReadWriteExecuteAccess); err != nil {
+		return nil, err
+	}
+	s := &Store{log: log, path: path, maxSize: maxSize}
+	if err := s.open(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// OpenReadOnly reads the store file at path as it is, the records being written by another process are skipped
+func OpenReadOnly(path string) (*Store, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	s := &Store{path: path, data: content, readOnly: true}
+	if err = s.checkHeader(); err != nil {
+		return nil, err
+	}
+	s.scan()
+	return s, nil
+}
+
+// Get returns a copy of the value of the key
+func (s *Store) Get(key string) ([]byte, bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	e, found := s.index[key]
+	if !found {
+		return nil, false
+	}
+	value := make([]byte, e.size-recordHeaderSize-e.keyLen)
+	copy(value, s.data[e.offset+recordHeaderSize+e.keyLen:e.offset+e.size])
+	return value, true
+}
+
+// Keys returns the keys starting with prefix, from the least recently written
+func (s *Store) Keys(prefix string) []string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.keys(prefix)
+}
+
+// Put sets the value of the key
+func (s *Store) Put(key string, value []byte) error {
+	if key == "" || len(key) > maxKeyLength {
+		return fmt.Errorf("invalid state store key %q", key)
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.readOnly {
+		return ErrReadOnly
+	}
+
+	record := encodeRecord(key, value, false)
+	offset, err := s.append(record)
+	if err != nil {
+		return err
+	}
+	if previous, found := s.index[key]; found {
+		s.live -= previous.size
+	}
+	s.index[key] = entry{offset: offset, size: int64(len(record)), keyLen: int64(len(key))}
+	s.live += int64(len(record))
+	return s.compactIfWasteful()
+}
+
+// Delete removes the key, it does nothing when the key is not in the store
+func (s *Store) Delete(key string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.readOnly {
+		return ErrReadOnly
+	}
+	previous, found := s.index[key]
+	if !found {
+		return nil
+	}
+
+	_, err := s.append(encodeRecord(key, nil, true))
+	if err != nil && err != ErrFull {
+		return err
+	}
+	delete(s.index, key)
+	s.live -= previous.size
+	if err == ErrFull {
+		// there is no room for the deletion record, the compaction drops the deleted record instead
+		return s.compact()
+	}
+	return s.compactIfWasteful()
+}
+
+// Rewrite replaces every value by the result of rewrite, the values rewrite fails for are kept as they are
+func (s *Store) Rewrite(rewrite func(value []byte) ([]byte, error)) error {
+	if s.readOnly {
+		return ErrReadOnly
+	}
+	var failed int
+	for _, key := range s.Keys("") {
+		value, found := s.Get(key)
+		if !found {
+			continue
+		}
+		rewritten, err := rewrite(value)
+		if err != nil {
+			s.log.Warnf("Failed to rewrite state %v: %v", key, err)
+			failed++
+			continue
+		}
+		if bytes.Equal(rewritten, value) {
+			continue
+		}
+		if err = s.Put(key, rewritten); err != nil {
+			return err
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("failed to rewrite %v states", failed)
+	}
+	return nil
+}
+
+// Size returns the size of the live records and the size of the records in the file
+func (s *Store) Size() (live int64, total int64) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.live, s.size - fileHeaderSize
+}
+
+// Close releases the file of the store
+func (s *Store) Close() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.close()
+}
+
+// open opens and maps the file, and loads the index from its records. The caller holds the lock or owns the store.
+func (s *Store) open() (err error) {
+	if s.file, err = os.OpenFile(s.path, os.O_RDWR|os.O_CREATE, appconfig.ReadWriteAccess); err != nil {
+		return err
+	}
+	info, err := s.file.Stat()
+	if err != nil {
+		s.close()
+		return err
+	}
+	if info.Size() == 0 {
+		err = s.initialize()
+	} else {
+		err = s.remap(info.Size())
+	}
+	if err != nil {
+		s.close()
+		return err
+	}
+
+	if err = s.checkHeader(); err != nil {
+		// the file is not a state store, it is kept aside and a new store is started
+		s.log.Errorf("State store %v is damaged, starting a new one: %v", s.path, err)
+		s.close()
+		if err = os.Rename(s.path, s.path+corruptSuffix); err != nil {
+			return err
+		}
+		return s.open()
+	}
+
+	if damaged := s.scan(); damaged {
+		s.log.Errorf("State store %v has damaged records, the records after offset %v are dropped", s.path, s.size)
+		if err = copyFile(s.path, s.path+corruptSuffix); err != nil {
+			s.log.Warnf("Failed to keep a copy of the damaged state store: %v", err)
+		}
+		return s.compact()
+	}
+	return s.compactIfWasteful()
+}
+
+// initialize writes the header of a new file
+func (s *Store) initialize() error {
+	header := make([]byte, fileHeaderSize)
+	copy(header, fileMagic)
+	binary.LittleEndian.PutUint32(header[len(fileMagic):], fileVersion)
+	if _, err := s.file.WriteAt(header, 0); err != nil {
+		return err
+	}
+	return s.remap(s.capacityFor(fileHeaderSize))
+}
+
+// checkHeader verifies the file starts with the header of a state store of a known version
+func (s *Store) checkHeader() error {
+	if len(s.data) < fileHeaderSize || string(s.data[:len(fileMagic)]) != fileMagic {
+		return errors.New("not a state store")
+	}
+	if version := binary.LittleEndian.Uint32(s.data[len(fileMagic):]); version != fileVersion {
+		return fmt.Errorf("unsupported state store version %v", version)
+	}
+	return nil
+}
+
+// scan loads the index from the records of the file, it stops at the end of the records or at the first damaged
+// record and returns true in the later case
+func (s *Store) scan() (damaged bool) {
+	s.index = make(map[string]entry)
+	s.live = 0
+	offset := int64(fileHeaderSize)
+	for {
+		key, size, deleted, end, valid := s.readRecord(offset)
+		if end {
+			break
+		}
+		if !valid {
+			damaged = true
+			break
+		}
+		if previous, found := s.index[key]; found {
+			s.live -= previous.size
+			delete(s.index, key)
+		}
+		if !deleted {
+			s.index[key] = entry{offset: offset, size: size, keyLen: int64(len(key))}
+			s.live += size
+		}
+		offset += size
+	}
+	s.size = offset
+	return damaged
+}
+
+// readRecord decodes the record at offset
+func (s *Store) readRecord(offset int64) (key string, size int64, deleted bool, end bool, valid bool) {
+	available := int64(len(s.data)) - offset
+	if available < recordHeaderSize {
+		return "", 0, false, true, true
+	}
+	header := s.data[offset : offset+recordHeaderSize]
+	keyLen := int64(binary.LittleEndian.Uint32(header[4:]))
+	valueLen := binary.LittleEndian.Uint32(header[8:])
+	if keyLen == 0 {
+		return "", 0, false, true, true
+	}
+
+	deleted = valueLen == tombstone
+	size = recordHeaderSize + keyLen
+	if !deleted {
+		size += int64(valueLen)
+	}
+	if keyLen > maxKeyLength || size > available {
+		return "", 0, false, false, false
+	}
+	record := s.data[offset : offset+size]
+	if crc32.ChecksumIEEE(record[4:]) != binary.LittleEndian.Uint32(record) {
+		return "", 0, false, false, false
+	}
+	return string(record[recordHeaderSize : recordHeaderSize+keyLen]), size, deleted, false, true
+}
+
+// append writes the record after the last one, growing the file or compacting the store when it does not fit
+func (s *Store) append(record []byte) (offset int64, err error) {
+	if needed := s.size + int64(len(record)); needed > int64(len(s.data)) {
+		if err = s.grow(int64(len(record))); err != nil {
+			return 0, err
+		}
+	}
+	offset = s.size
+	if _, err = s.file.WriteAt(record, offset); err != nil {
+		return 0, err
+	}
+	s.size += int64(len(record))
+	return offset, nil
+}
+
+// grow makes room for length bytes after the last record
+func (s *Store) grow(length int64) error {
+	if s.size+length > s.maxSize {
+		// the replaced and deleted records may leave enough room
+		if err := s.compact(); err != nil {
+			return err
+		}
+		if s.size+length > s.maxSize {
+			return ErrFull
+		}
+		if s.size+length <= int64(len(s.data)) {
+			return nil
+		}
+	}
+	capacity := 2 * int64(len(s.data))
+	if minimum := s.capacityFor(s.size + length); capacity < minimum {
+		capacity = minimum
+	}
+	if capacity > s.maxSize {
+		capacity = s.maxSize
+	}
+	return s.remap(capacity)
+}
+
+// capacityFor returns the file size for size bytes of records, rounded up to growStep
+func (s *Store) capacityFor(size int64) int64 {
+	capacity := (size/growStep + 1) * growStep
+	if capacity > s.maxSize {
+		capacity = s.maxSize
+	}
+	return capacity
+}
+
+// remap extends the file to capacity and maps it again
+func (s *Store) remap(capacity int64) (err error) {
+	if s.mapped {
+		if err = unmapFile(s.data); err != nil {
+			return err
+		}
+		s.data, s.mapped = nil, false
+	}
+	if info, err := s.file.Stat(); err == nil && info.Size() < capacity {
+		if err = s.file.Truncate(capacity); err != nil {
+			return err
+		}
+	}
+	if s.data, err = mapFile(s.file, int(capacity)); err != nil {
+		return err
+	}
+	s.mapped = true
+	return nil
+}
+
+// compactIfWasteful compacts the store when the replaced and deleted records take more room than the live ones
+func (s *Store) compactIfWasteful() error {
+	dead := s.size - fileHeaderSize - s.live
+	if dead < compactionMinDeadBytes || dead < s.live {
+		return nil
+	}
+	return s.compact()
+}
+
+// compact writes the live records to a new file, which replaces the file of the store
+func (s *Store) compact() error {
+	compactPath := s.path + compactSuffix
+	written, err := s.writeLiveRecords(compactPath)
+	if err != nil {
+		os.Remove(compactPath)
+		return err
+	}
+	s.log.Debugf("Compacted state store %v from %v to %v bytes", s.path, s.size, written)
+
+	// the file is unmapped and closed before it is replaced, which windows requires
+	if err = s.close(); err != nil {
+		return err
+	}
+	if err = os.Rename(compactPath, s.path); err != nil {
+		os.Remove(compactPath)
+	}
+	if openErr := s.open(); openErr != nil {
+		return openErr
+	}
+	return err
+}
+
+// writeLiveRecords writes the header and the live records, from the least recently written, to a new file
+func (s *Store) writeLiveRecords(path string) (written int64, err error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, appconfig.ReadWriteAccess)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	writer := bufio.NewWriter(file)
+	if _, err = writer.Write(s.data[:fileHeaderSize]); err != nil {
+		return 0, err
+	}
+	written = fileHeaderSize
+	for _, key := range s.keys("") {
+		e := s.index[key]
+		if _, err = writer.Write(s.data[e.offset : e.offset+e.size]); err != nil {
+			return 0, err
+		}
+		written += e.size
+	}
+	if err = writer.Flush(); err != nil {
+		return 0, err
+	}
+	capacity := s.capacityFor(written)
+	if capacity < written {
+		capacity = written
+	}
+	if err = file.Truncate(capacity); err != nil {
+		return 0, err
+	}
+	return written, file.Sync()
+}
+
+// keys returns the keys starting with prefix by offset, the caller holds the lock
+func (s *Store) keys(prefix string) []string {
+	var keys []string
+	for key := range s.index {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return s.index[keys[i]].offset < s.index[keys[j]].offset
+	})
+	return keys
+}
+
+// close unmaps and closes the file
+func (s *Store) close() (err error) {
+	if s.mapped {
+		err = unmapFile(s.data)
+		s.mapped = false
+	}
+	s.data = nil
+	if s.file != nil {
+		if closeErr := s.file.Close(); err == nil {
+			err = closeErr
+		}
+		s.file = nil
+	}
+	return err
+}
+
+// encodeRecord returns the record of the key, a deleted record has no value
+func encodeRecord(key string, value []byte, deleted bool) []byte {
+	valueLen := uint32(len(value))
+	if deleted {
+		valueLen, value = tombstone, nil
+	}
+	record := make([]byte, recordHeaderSize+len(key)+len(value))
+	binary.LittleEndian.PutUint32(record[4:], uint32(len(key)))
+	binary.LittleEndian.PutUint32(record[8:], valueLen)
+	copy(record[recordHeaderSize:], key)
+	copy(record[recordHeaderSize+len(key):], value)
+	binary.LittleEndian.PutUint32(record, crc32.ChecksumIEEE(record[4:]))
+	return record
+}
+
+// copyFile copies the file at source to destination
+func copyFile(source string, destination string) error {
+	in, err := os.Open(source)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(destination, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, appconfig.ReadWriteAccess)
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
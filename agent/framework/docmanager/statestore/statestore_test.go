@@ -0,0 +1,185 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package statestore
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/stretchr/testify/assert"
+)
+
+var logMock = log.NewMockLog()
+
+func openTestStore(t *testing.T, maxSize int64) (*Store, string) {
+	path := filepath.Join(t.TempDir(), "documents.db")
+	store, err := Open(logMock, path, maxSize)
+	assert.NoError(t, err)
+	return store, path
+}
+
+func TestPutGetDelete(t *testing.T) {
+	store, path := openTestStore(t, 1024*1024)
+
+	assert.NoError(t, store.Put("pending/doc-1", []byte("one")))
+	assert.NoError(t, store.Put("current/doc-2", []byte("two")))
+	assert.NoError(t, store.Put("pending/doc-3", []byte("three")))
+	assert.NoError(t, store.Put("pending/doc-1", []byte("one again")))
+	assert.NoError(t, store.Delete("pending/doc-3"))
+	assert.NoError(t, store.Delete("pending/unknown"))
+
+	value, found := store.Get("pending/doc-1")
+	assert.True(t, found)
+	assert.Equal(t, "one again", string(value))
+	_, found = store.Get("pending/doc-3")
+	assert.False(t, found)
+	assert.Equal(t, []string{"current/doc-2", "pending/doc-1"}, store.Keys(""))
+	assert.Equal(t, []string{"pending/doc-1"}, store.Keys("pending/"))
+	assert.NoError(t, store.Close())
+
+	// the records are read back from the file
+	store, err := Open(logMock, path, 1024*1024)
+	assert.NoError(t, err)
+	defer store.Close()
+	value, found = store.Get("current/doc-2")
+	assert.True(t, found)
+	assert.Equal(t, "two", string(value))
+	assert.Equal(t, []string{"current/doc-2", "pending/doc-1"}, store.Keys(""))
+}
+
+func TestReadOnly(t *testing.T) {
+	store, path := openTestStore(t, 1024*1024)
+	defer store.Close()
+	assert.NoError(t, store.Put("pending/doc-1", []byte("one")))
+
+	reader, err := OpenReadOnly(path)
+	assert.NoError(t, err)
+	value, found := reader.Get("pending/doc-1")
+	assert.True(t, found)
+	assert.Equal(t, "one", string(value))
+	assert.Equal(t, ErrReadOnly, reader.Put("pending/doc-2", []byte("two")))
+
+	_, err = OpenReadOnly(filepath.Join(filepath.Dir(path), "missing.db"))
+	assert.Error(t, err)
+}
+
+func TestCompaction(t *testing.T) {
+	store, path := openTestStore(t, 16*1024*1024)
+	defer store.Close()
+
+	value := bytes.Repeat([]byte("x"), 64*1024)
+	for i := 0; i < 40; i++ {
+		assert.NoError(t, store.Put("current/doc", value))
+	}
+	assert.NoError(t, store.Put("pending/doc", []byte("kept")))
+
+	live, total := store.Size()
+	assert.True(t, total < 2*compactionMinDeadBytes, "replaced records are compacted, %v bytes in the file", total)
+	assert.Equal(t, int64(2*recordHeaderSize+len("current/doc")+len(value)+len("pending/doc")+len("kept")), live)
+	info, err := os.Stat(path)
+	assert.NoError(t, err)
+	assert.True(t, info.Size() < 4*1024*1024)
+
+	stored, found := store.Get("current/doc")
+	assert.True(t, found)
+	assert.Equal(t, value, stored)
+	assert.Equal(t, []string{"current/doc", "pending/doc"}, store.Keys(""))
+}
+
+func TestFull(t *testing.T) {
+	maxSize := int64(fileHeaderSize + growStep)
+	store, path := openTestStore(t, maxSize)
+	defer store.Close()
+
+	value := bytes.Repeat([]byte("x"), 10*1024)
+	var err error
+	var stored int
+	for ; stored < 10 && err == nil; stored++ {
+		err = store.Put(fmt.Sprintf("corrupt/doc-%v", stored), value)
+	}
+	assert.Equal(t, ErrFull, err)
+
+	// a deletion fits even without room for its record
+	assert.NoError(t, store.Delete("corrupt/doc-0"))
+	_, found := store.Get("corrupt/doc-0")
+	assert.False(t, found)
+	assert.NoError(t, store.Put("corrupt/doc-1", value))
+	assert.NoError(t, store.Put("pending/doc", value))
+	info, err := os.Stat(path)
+	assert.NoError(t, err)
+	assert.True(t, info.Size() <= maxSize)
+}
+
+func TestDamagedRecordsAreDropped(t *testing.T) {
+	store, path := openTestStore(t, 1024*1024)
+	assert.NoError(t, store.Put("pending/doc-1", []byte("one")))
+	assert.NoError(t, store.Put("pending/doc-2", []byte("two")))
+	assert.NoError(t, store.Close())
+
+	// flip a byte of the value of the second record
+	content, err := ioutil.ReadFile(path)
+	assert.NoError(t, err)
+	content[bytes.Index(content, []byte("two"))] = 'T'
+	assert.NoError(t, ioutil.WriteFile(path, content, 0600))
+
+	store, err = Open(logMock, path, 1024*1024)
+	assert.NoError(t, err)
+	defer store.Close()
+	assert.Equal(t, []string{"pending/doc-1"}, store.Keys(""))
+	_, err = os.Stat(path + corruptSuffix)
+	assert.NoError(t, err, "the damaged file is kept")
+
+	// the store is usable after the recovery
+	assert.NoError(t, store.Put("pending/doc-3", []byte("three")))
+	value, found := store.Get("pending/doc-3")
+	assert.True(t, found)
+	assert.Equal(t, "three", string(value))
+}
+
+func TestDamagedHeaderStartsANewStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "documents.db")
+	assert.NoError(t, ioutil.WriteFile(path, []byte(`{"not":"a store"}`), 0600))
+
+	store, err := Open(logMock, path, 1024*1024)
+	assert.NoError(t, err)
+	defer store.Close()
+	assert.Empty(t, store.Keys(""))
+	kept, err := ioutil.ReadFile(path + corruptSuffix)
+	assert.NoError(t, err)
+	assert.Equal(t, `{"not":"a store"}`, string(kept))
+}
+
+func TestRewrite(t *testing.T) {
+	store, _ := openTestStore(t, 1024*1024)
+	defer store.Close()
+	assert.NoError(t, store.Put("pending/doc-1", []byte("one")))
+	assert.NoError(t, store.Put("pending/doc-2", []byte("bad")))
+
+	err := store.Rewrite(func(value []byte) ([]byte, error) {
+		if string(value) == "bad" {
+			return nil, fmt.Errorf("cannot rewrite")
+		}
+		return bytes.ToUpper(value), nil
+	})
+	assert.Error(t, err)
+	value, _ := store.Get("pending/doc-1")
+	assert.Equal(t, "ONE", string(value))
+	value, _ = store.Get("pending/doc-2")
+	assert.Equal(t, "bad", string(value))
+}
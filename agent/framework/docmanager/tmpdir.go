@@ -0,0 +1,78 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package docmanager
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/fileutil"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+)
+
+// tmpRootDirName is the directory under the document root directory that holds the temporary directories of the documents
+const tmpRootDirName = "tmp"
+
+// tmpRootDirectory returns the directory that holds the temporary directories of the documents of the instance
+var tmpRootDirectory = func(instanceID string) string {
+	return filepath.Join(appconfig.DefaultDataStorePath, instanceID, appconfig.DefaultDocumentRootDirName, tmpRootDirName)
+}
+
+// TmpDirectory returns the private temporary directory of a document, documentID is the name of its state
+func TmpDirectory(instanceID, documentID string) string {
+	if instanceID == "" || documentID == "" {
+		return ""
+	}
+	return filepath.Join(tmpRootDirectory(instanceID), documentID)
+}
+
+// DeleteTmpDirectory deletes the temporary directory of a document once it completes
+func DeleteTmpDirectory(log log.T, instanceID, documentID string) {
+	if dir := TmpDirectory(instanceID, documentID); dir != "" {
+		if err := os.RemoveAll(dir); err != nil {
+			log.Warnf("failed to delete the temporary directory %v of document %v: %v", dir, documentID, err)
+		}
+	}
+}
+
+// DeleteStaleTmpDirectories deletes the temporary directories the documents that are neither pending nor in progress
+// left behind when the agent stopped before they completed. The directories are listed before the states, a
+// directory created after its document state was written is never deleted while the document runs.
+func DeleteStaleTmpDirectories(log log.T, instanceID string, docMgr DocumentMgr) {
+	tmpRootDir := tmpRootDirectory(instanceID)
+	if !fileutil.Exists(tmpRootDir) {
+		return
+	}
+	dirNames, err := fileutil.GetDirectoryNames(tmpRootDir)
+	if err != nil || len(dirNames) == 0 {
+		return
+	}
+
+	active := make(map[string]bool)
+	for _, location := range []string{appconfig.DefaultLocationOfPending, appconfig.DefaultLocationOfCurrent} {
+		for _, name := range docMgr.ListDocumentStates(log, instanceID, location) {
+			active[name] = true
+		}
+	}
+	for _, dirName := range dirNames {
+		if active[dirName] {
+			continue
+		}
+		log.Debugf("Deleting temporary directory of document %v", dirName)
+		if err := os.RemoveAll(filepath.Join(tmpRootDir, dirName)); err != nil {
+			log.Warnf("failed to delete the temporary directory of document %v: %v", dirName, err)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package docmanager
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/fileutil"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeleteStaleTmpDirectories(t *testing.T) {
+	docMgr, dataStorePath := newStateTestMgr(t)
+	logger := log.NewMockLog()
+	original := tmpRootDirectory
+	tmpRootDirectory = func(instanceID string) string { return filepath.Join(dataStorePath, instanceID, "tmp") }
+	defer func() { tmpRootDirectory = original }()
+
+	docMgr.PersistDocumentState(logger, "command-1", "instanceID", appconfig.DefaultLocationOfCurrent, testDocumentState("command-1"))
+	docMgr.PersistDocumentState(logger, "command-2", "instanceID", appconfig.DefaultLocationOfPending, testDocumentState("command-2"))
+	for _, documentID := range []string{"command-1", "command-2", "command-3"} {
+		assert.NoError(t, os.MkdirAll(filepath.Join(TmpDirectory("instanceID", documentID), "leftover"), 0700))
+	}
+
+	DeleteStaleTmpDirectories(logger, "instanceID", docMgr)
+	assert.True(t, fileutil.Exists(TmpDirectory("instanceID", "command-1")))
+	assert.True(t, fileutil.Exists(TmpDirectory("instanceID", "command-2")))
+	assert.False(t, fileutil.Exists(TmpDirectory("instanceID", "command-3")))
+
+	DeleteTmpDirectory(logger, "instanceID", "command-1")
+	assert.False(t, fileutil.Exists(TmpDirectory("instanceID", "command-1")))
+	assert.Empty(t, TmpDirectory("instanceID", ""))
+}
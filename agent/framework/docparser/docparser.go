@@ -16,15 +16,21 @@
 package docparser
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"path/filepath"
+	"regexp"
 	"strings"
 
 	"github.com/aws/amazon-ssm-agent/agent/appconfig"
 	"github.com/aws/amazon-ssm-agent/agent/contracts"
 	"github.com/aws/amazon-ssm-agent/agent/fileutil"
+	"github.com/aws/amazon-ssm-agent/agent/framework/docparser/instancemetadata"
 	"github.com/aws/amazon-ssm-agent/agent/framework/docparser/parameters"
 	"github.com/aws/amazon-ssm-agent/agent/framework/docparser/parameterstore"
+	"github.com/aws/amazon-ssm-agent/agent/instancetags"
 	"github.com/aws/amazon-ssm-agent/agent/jsonutil"
 	"github.com/aws/amazon-ssm-agent/agent/log"
 	"github.com/aws/amazon-ssm-agent/agent/updateutil"
@@ -32,8 +38,21 @@ import (
 
 const (
 	preconditionSchemaVersion string = "2.2"
+
+	// redactedParameterValue replaces the parameter values recorded in the document information
+	redactedParameterValue = "****"
+)
+
+var (
+	resolveInstanceTags         = instancetags.Resolve
+	resolveInstanceTagsText     = instancetags.ResolveText
+	resolveInstanceMetadata     = instancemetadata.Resolve
+	resolveInstanceMetadataText = instancemetadata.ResolveText
 )
 
+// parameterStoreReference matches parameter values that only reference a parameter store parameter
+var parameterStoreReference = regexp.MustCompile(`^\{\{\s*ssm(-secure)?:[^{}]+\}\}$`)
+
 // DocumentParserInfo represents the parsed information from the request
 type DocumentParserInfo struct {
 	OrchestrationDir  string
@@ -58,6 +77,15 @@ func InitializeDocState(log log.T,
 	docState.DocumentType = documentType
 	docState.DocumentInformation = docInfo
 	docState.IOConfig = docContent.GetIOConfiguration(parserInfo)
+	docState.ExecutionContext = docContent.GetExecutionContext()
+	docState.Urgent = docContent.IsUrgent()
+	docState.SchedulingClass = docContent.GetSchedulingClass()
+	// ParseDocument resolves the parameters in place, the hash is taken from the content as it was received
+	docState.DocumentInformation.DocumentHash = documentHash(docContent)
+	docState.DocumentInformation.RedactedParameters = redactParameters(params)
+	if token, ok := params[appconfig.ApprovalTokenParameter].(string); ok {
+		docState.DocumentInformation.ApprovalToken = token
+	}
 
 	pluginInfo, err := docContent.ParseDocument(log, docInfo, parserInfo, params)
 	if err != nil {
@@ -67,9 +95,38 @@ func InitializeDocState(log log.T,
 	return docState, nil
 }
 
+// documentHash returns the hex encoded sha256 of the JSON encoding of the document content
+func documentHash(docContent IDocumentContent) string {
+	content, err := json.Marshal(docContent)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// redactParameters masks the parameter values, parameter store references are kept since they name the value without revealing it
+func redactParameters(params map[string]interface{}) map[string]string {
+	if len(params) == 0 {
+		return nil
+	}
+	redacted := make(map[string]string, len(params))
+	for name, value := range params {
+		if reference, ok := value.(string); ok && parameterStoreReference.MatchString(strings.TrimSpace(reference)) {
+			redacted[name] = strings.TrimSpace(reference)
+			continue
+		}
+		redacted[name] = redactedParameterValue
+	}
+	return redacted
+}
+
 type IDocumentContent interface {
 	GetSchemaVersion() string
 	GetIOConfiguration(parserInfo DocumentParserInfo) contracts.IOConfiguration
+	GetExecutionContext() contracts.ExecutionContext
+	IsUrgent() bool
+	GetSchedulingClass() string
 	ParseDocument(log log.T, docInfo contracts.DocumentInfo, parserInfo DocumentParserInfo, params map[string]interface{}) (pluginsInfo []contracts.PluginState, err error)
 }
 
@@ -84,12 +141,38 @@ func (docContent *DocContent) GetSchemaVersion() string {
 
 // GetIOConfiguration is a method used to get IO config from the document
 func (docContent *DocContent) GetIOConfiguration(parserInfo DocumentParserInfo) contracts.IOConfiguration {
-	return contracts.IOConfiguration{
+	ioConfig := contracts.IOConfiguration{
 		OrchestrationDirectory: parserInfo.OrchestrationDir,
 		OutputS3BucketName:     parserInfo.S3Bucket,
 		OutputS3KeyPrefix:      parserInfo.S3Prefix,
 		CloudWatchConfig:       parserInfo.CloudWatchConfig,
 	}
+	if docContent.OutputS3Settings != nil {
+		ioConfig.OutputS3Settings = *docContent.OutputS3Settings
+	}
+	return ioConfig
+}
+
+// GetExecutionContext is a method used to get the execution context the document overrides
+func (docContent *DocContent) GetExecutionContext() contracts.ExecutionContext {
+	if docContent.ExecutionContext == nil {
+		return contracts.ExecutionContext{}
+	}
+	return *docContent.ExecutionContext
+}
+
+// IsUrgent is a method used to check if the document asks to be started ahead of other queued documents
+func (docContent *DocContent) IsUrgent() bool {
+	return docContent.Priority == contracts.DocumentPriorityUrgent
+}
+
+// GetSchedulingClass is a method used to get the scheduling class of the document, unknown classes are ignored
+func (docContent *DocContent) GetSchedulingClass() string {
+	switch docContent.SchedulingClass {
+	case contracts.SchedulingClassCPU, contracts.SchedulingClassIO:
+		return docContent.SchedulingClass
+	}
+	return ""
 }
 
 // ParseDocument is a method used to parse documents that are not received by any service (MDS or State manager)
@@ -101,6 +184,9 @@ func (docContent *DocContent) ParseDocument(log log.T,
 	if err = validateSchema(docContent.SchemaVersion); err != nil {
 		return
 	}
+	if err = validateDocumentContent(*docContent); err != nil {
+		return
+	}
 	if err = getValidatedParameters(log, params, docContent); err != nil {
 		return
 	}
@@ -122,6 +208,25 @@ func (sessionDocContent *SessionDocContent) GetIOConfiguration(parserInfo Docume
 	}
 }
 
+// GetExecutionContext is a method used to get the execution context the session document overrides
+func (sessionDocContent *SessionDocContent) GetExecutionContext() contracts.ExecutionContext {
+	if sessionDocContent.ExecutionContext == nil {
+		return contracts.ExecutionContext{}
+	}
+	return *sessionDocContent.ExecutionContext
+}
+
+// IsUrgent is a method used to check if the document asks to be started ahead of other queued documents,
+// sessions are always started ahead of documents by the processor
+func (sessionDocContent *SessionDocContent) IsUrgent() bool {
+	return false
+}
+
+// GetSchedulingClass is a method used to get the scheduling class of the session document, sessions have none
+func (sessionDocContent *SessionDocContent) GetSchedulingClass() string {
+	return ""
+}
+
 // ParseDocument is a method used to parse documents that are not received by any service (MDS or State manager)
 func (sessionDocContent *SessionDocContent) ParseDocument(log log.T,
 	docInfo contracts.DocumentInfo,
@@ -239,7 +344,7 @@ func parseDocumentContent(docContent DocContent, parserInfo DocumentParserInfo,
 	case "1.0", "1.2":
 		return parsePluginStateForV10Schema(docContent, parserInfo.OrchestrationDir, parserInfo.S3Bucket, parserInfo.S3Prefix, parserInfo.MessageId, parserInfo.DocumentId, parserInfo.DefaultWorkingDir)
 
-	case "2.0", "2.0.1", "2.0.2", "2.0.3", "2.2":
+	case "2.0", "2.0.1", "2.0.2", "2.0.3", "2.2", "2.3":
 
 		return parsePluginStateForV20Schema(docContent, parserInfo.OrchestrationDir, parserInfo.S3Bucket, parserInfo.S3Prefix, parserInfo.MessageId, parserInfo.DocumentId, parserInfo.DefaultWorkingDir, log, params)
 
@@ -272,6 +377,7 @@ func parsePluginStateForV10Schema(
 			PluginName:              pluginName,
 			PluginID:                pluginName,
 			DefaultWorkingDirectory: defaultWorkingDir,
+			MaxReboots:              docContent.MaxReboots,
 		}
 		pluginConfigurations = append(pluginConfigurations, &config)
 	}
@@ -316,6 +422,10 @@ func parsePluginStateForV20Schema(
 			Preconditions:           parsePluginParametersInPreconditions(&docContent, instancePluginConfig.Preconditions, params, log),
 			IsPreconditionEnabled:   isPreconditionEnabled,
 			DefaultWorkingDirectory: defaultWorkingDir,
+			RebootIfRequired:        instancePluginConfig.RebootIfRequired,
+			MaxReboots:              docContent.MaxReboots,
+			RebootHooks:             instancePluginConfig.RebootHooks,
+			ExpectedOutputSha256:    strings.ToLower(strings.TrimSpace(instancePluginConfig.ExpectedOutputSha256)),
 		}
 
 		var plugin contracts.PluginState
@@ -357,9 +467,22 @@ func validateAndReplaceParametersInPreconditionArguments(docContent *DocContent,
 	// replace document parameters in each of the arguments
 	parsedArguments := make([]contracts.PreconditionArgument, len(args))
 	for i, arg := range args {
+		resolved := parameters.ReplaceParameters(arg, validParameters, log).(string)
+		// an argument referencing a tag or instance information that cannot be fetched stays unresolved,
+		// which fails the precondition
+		if withTags, err := resolveInstanceTagsText(log, resolved); err != nil {
+			log.Warnf("failed to resolve the instance tags of precondition argument %v: %v", arg, err)
+		} else {
+			resolved = withTags
+		}
+		if withMetadata, err := resolveInstanceMetadataText(log, resolved); err != nil {
+			log.Warnf("failed to resolve the instance information of precondition argument %v: %v", arg, err)
+		} else {
+			resolved = withMetadata
+		}
 		parsedArguments[i] = contracts.PreconditionArgument{
 			InitialArgumentValue:  arg,
-			ResolvedArgumentValue: parameters.ReplaceParameters(arg, validParameters, log).(string),
+			ResolvedArgumentValue: resolved,
 		}
 	}
 	return parsedArguments
@@ -396,6 +519,8 @@ func (sessionDocContent *SessionDocContent) parsePluginStateForStartSession(
 		CloudWatchLogGroup:          sessionDocContent.Inputs.CloudWatchLogGroupName,
 		CloudWatchEncryptionEnabled: sessionDocContent.Inputs.CloudWatchEncryptionEnabled,
 		KmsKeyId:                    sessionDocContent.Inputs.KmsKeyId,
+		KmsEncryptionContext:        sessionDocContent.Inputs.KmsEncryptionContext,
+		KmsGrantTokens:              sessionDocContent.Inputs.KmsGrantTokens,
 		Properties:                  sessionDocContent.Properties,
 		RunAsEnabled:                sessionDocContent.Inputs.RunAsEnabled,
 		RunAsUser:                   runAsUser,
@@ -454,6 +579,17 @@ func getValidatedParameters(log log.T, params map[string]interface{}, docContent
 		return err
 	}
 
+	// computes the document variables, steps use them like parameters
+	if len(docContent.Variables) > 0 {
+		variables, err := parameters.EvaluateVariables(docContent.Variables, validParameters)
+		if err != nil {
+			return err
+		}
+		for name, value := range variables {
+			validParameters[name] = value
+		}
+	}
+
 	err := replaceValidatedPluginParameters(docContent, validParameters, log)
 	return err
 }
@@ -485,6 +621,14 @@ func replaceValidatedPluginParameters(
 			if updatedRuntimeConfig[pluginName].Properties, err = parameterstore.Resolve(logger, updatedRuntimeConfig[pluginName].Properties); err != nil {
 				return err
 			}
+
+			// Resolves instance tags and instance information
+			if updatedRuntimeConfig[pluginName].Settings, err = resolveInstanceReferences(logger, updatedRuntimeConfig[pluginName].Settings); err != nil {
+				return err
+			}
+			if updatedRuntimeConfig[pluginName].Properties, err = resolveInstanceReferences(logger, updatedRuntimeConfig[pluginName].Properties); err != nil {
+				return err
+			}
 		}
 		docContent.RuntimeConfig = updatedRuntimeConfig
 		return nil
@@ -508,6 +652,14 @@ func replaceValidatedPluginParameters(
 			if updatedMainSteps[index].Inputs, err = parameterstore.Resolve(logger, updatedMainSteps[index].Inputs); err != nil {
 				return err
 			}
+
+			// Resolves instance tags and instance information
+			if updatedMainSteps[index].Settings, err = resolveInstanceReferences(logger, updatedMainSteps[index].Settings); err != nil {
+				return err
+			}
+			if updatedMainSteps[index].Inputs, err = resolveInstanceReferences(logger, updatedMainSteps[index].Inputs); err != nil {
+				return err
+			}
 		}
 		docContent.MainSteps = updatedMainSteps
 		return nil
@@ -515,6 +667,15 @@ func replaceValidatedPluginParameters(
 	return nil
 }
 
+// resolveInstanceReferences replaces the {{tag:Name}} and {{instance:key}} references of the input
+func resolveInstanceReferences(logger log.T, input interface{}) (interface{}, error) {
+	input, err := resolveInstanceTags(logger, input)
+	if err != nil {
+		return input, err
+	}
+	return resolveInstanceMetadata(logger, input)
+}
+
 // isPreConditionEnabled checks if precondition support is enabled by checking document schema version
 func isPreconditionEnabled(schemaVersion string) (response bool) {
 	response = false
@@ -15,15 +15,20 @@
 package docparser
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/aws/amazon-ssm-agent/agent/appconfig"
 	"github.com/aws/amazon-ssm-agent/agent/contracts"
 	"github.com/aws/amazon-ssm-agent/agent/fileutil"
+	"github.com/aws/amazon-ssm-agent/agent/framework/docparser/instancemetadata"
+	"github.com/aws/amazon-ssm-agent/agent/instancetags"
 	"github.com/aws/amazon-ssm-agent/agent/jsonutil"
 	"github.com/aws/amazon-ssm-agent/agent/log"
 	"github.com/stretchr/testify/assert"
@@ -358,7 +363,7 @@ func TestParseDocument_EmptyDocContent(t *testing.T) {
 
 	assert.NotNil(t, err)
 	assert.Error(t, err)
-	assert.Contains(t, err.Error(), "Unsupported schema format")
+	assert.Contains(t, err.Error(), "runtimeConfig: is required for schema version 1.2")
 }
 
 func TestParseDocument_Invalid(t *testing.T) {
@@ -380,7 +385,7 @@ func TestParseDocument_Invalid(t *testing.T) {
 
 	assert.NotNil(t, err)
 	assert.Error(t, err)
-	assert.Contains(t, err.Error(), "Unsupported schema format")
+	assert.Contains(t, err.Error(), "runtimeConfig: is required for schema version 1.2")
 }
 
 func TestParseDocument_InvalidSchema(t *testing.T) {
@@ -483,6 +488,98 @@ func TestParseDocument_ReplaceDefaultParameters(t *testing.T) {
 	assert.NotEqual(t, parsedMessage, originalMessage)
 }
 
+func TestParseDocument_ReplaceVariables(t *testing.T) {
+	mockLog := log.NewMockLog()
+
+	testParserInfo := DocumentParserInfo{
+		OrchestrationDir: testOrchDir,
+		MessageId:        testMessageID,
+		DocumentId:       testDocumentID,
+	}
+
+	var testDocContent DocContent
+	variablesDoc := loadFile(t, "testdata/sampleVariables.json")
+	err := json.Unmarshal([]byte(variablesDoc), &testDocContent)
+	assert.NoError(t, err, "Error occurred when trying to unmarshal test document")
+
+	params := map[string]interface{}{"environment": "staging"}
+	pluginsInfo, err := testDocContent.ParseDocument(mockLog, contracts.DocumentInfo{}, testParserInfo, params)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(pluginsInfo))
+	inputs := pluginsInfo[0].Configuration.Properties.(map[string]interface{})
+	assert.Equal(t, []interface{}{"echo STAGING", "echo web db in staging.example.com"}, inputs["runCommand"])
+}
+
+func TestParseDocument_InvalidVariables(t *testing.T) {
+	var testDocContent DocContent
+	variablesDoc := loadFile(t, "testdata/sampleVariables.json")
+	err := json.Unmarshal([]byte(variablesDoc), &testDocContent)
+	assert.NoError(t, err)
+	testDocContent.Variables["stage"] = "{{ upper fqdns }}"
+	testDocContent.Variables["fqdns"] = "{{ lower stage }}"
+
+	_, err = testDocContent.ParseDocument(log.NewMockLog(), contracts.DocumentInfo{}, DocumentParserInfo{}, nil)
+
+	assert.Error(t, err)
+}
+
+func TestParseDocument_ReplaceInstanceReferences(t *testing.T) {
+	defer func() {
+		resolveInstanceTags = instancetags.Resolve
+		resolveInstanceTagsText = instancetags.ResolveText
+		resolveInstanceMetadata = instancemetadata.Resolve
+		resolveInstanceMetadataText = instancemetadata.ResolveText
+	}()
+	replacer := strings.NewReplacer("{{tag:Environment}}", "prod", "{{ tag:Team }}", "web")
+	metadataReplacer := strings.NewReplacer("{{instance:az}}", "us-east-1a")
+	resolveInstanceTags = func(log log.T, input interface{}) (interface{}, error) {
+		if inputs, ok := input.(map[string]interface{}); ok {
+			if commands, ok := inputs["runCommand"].([]interface{}); ok {
+				inputs["runCommand"] = []interface{}{replacer.Replace(commands[0].(string))}
+			}
+		}
+		return input, nil
+	}
+	resolveInstanceTagsText = func(log log.T, text string) (string, error) {
+		return replacer.Replace(text), nil
+	}
+	resolveInstanceMetadata = func(log log.T, input interface{}) (interface{}, error) {
+		if inputs, ok := input.(map[string]interface{}); ok {
+			if commands, ok := inputs["runCommand"].([]interface{}); ok {
+				inputs["runCommand"] = []interface{}{metadataReplacer.Replace(commands[0].(string))}
+			}
+		}
+		return input, nil
+	}
+	resolveInstanceMetadataText = func(log log.T, text string) (string, error) {
+		return metadataReplacer.Replace(text), nil
+	}
+
+	var testDocContent DocContent
+	err := json.Unmarshal([]byte(`{
+		"schemaVersion": "2.2",
+		"mainSteps": [{
+			"action": "aws:runShellScript",
+			"name": "deploy",
+			"precondition": {"StringEquals": ["{{tag:Environment}}", "prod"]},
+			"inputs": {"runCommand": ["echo {{ tag:Team }} {{instance:az}}"]}
+		}]
+	}`), &testDocContent)
+	assert.NoError(t, err)
+
+	pluginsInfo, err := testDocContent.ParseDocument(log.NewMockLog(), contracts.DocumentInfo{}, DocumentParserInfo{}, nil)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(pluginsInfo))
+	inputs := pluginsInfo[0].Configuration.Properties.(map[string]interface{})
+	assert.Equal(t, []interface{}{"echo web us-east-1a"}, inputs["runCommand"])
+	assert.Equal(t, []contracts.PreconditionArgument{
+		{InitialArgumentValue: "{{tag:Environment}}", ResolvedArgumentValue: "prod"},
+		{InitialArgumentValue: "prod", ResolvedArgumentValue: "prod"},
+	}, pluginsInfo[0].Configuration.Preconditions["StringEquals"])
+}
+
 func TestIsCrossPlatformEnabledForSchema20(t *testing.T) {
 	var schemaVersion = "2.0"
 	isCrossPlatformEnabled := isPreconditionEnabled(schemaVersion)
@@ -653,3 +750,80 @@ func loadPreconditionsFromFile(t *testing.T) (preconditions map[string]map[strin
 	}
 	return preconditions
 }
+
+func TestGetIOConfigurationWithOutputS3Settings(t *testing.T) {
+	docContent := &DocContent{
+		SchemaVersion: "2.2",
+		OutputS3Settings: &contracts.S3ObjectSettings{
+			ServerSideEncryption: "aws:kms",
+			SSEKMSKeyId:          "alias/output",
+		},
+	}
+
+	ioConfig := docContent.GetIOConfiguration(DocumentParserInfo{S3Bucket: testS3Bucket})
+
+	assert.Equal(t, testS3Bucket, ioConfig.OutputS3BucketName)
+	assert.Equal(t, "aws:kms", ioConfig.OutputS3Settings.ServerSideEncryption)
+	assert.Equal(t, "alias/output", ioConfig.OutputS3Settings.SSEKMSKeyId)
+}
+
+func TestIsUrgent(t *testing.T) {
+	assert.True(t, (&DocContent{Priority: contracts.DocumentPriorityUrgent}).IsUrgent())
+	assert.False(t, (&DocContent{Priority: "low"}).IsUrgent())
+	assert.False(t, (&DocContent{}).IsUrgent())
+	assert.False(t, (&SessionDocContent{}).IsUrgent())
+}
+
+func TestGetSchedulingClass(t *testing.T) {
+	assert.Equal(t, contracts.SchedulingClassCPU, (&DocContent{SchedulingClass: "cpu"}).GetSchedulingClass())
+	assert.Equal(t, contracts.SchedulingClassIO, (&DocContent{SchedulingClass: "io"}).GetSchedulingClass())
+	assert.Equal(t, "", (&DocContent{SchedulingClass: "gpu"}).GetSchedulingClass())
+	assert.Equal(t, "", (&SessionDocContent{}).GetSchedulingClass())
+}
+
+func TestParseDocument_RebootSettings(t *testing.T) {
+	document := `{
+		"schemaVersion": "2.2",
+		"maxReboots": 3,
+		"mainSteps": [
+			{"action": "aws:runPowerShellScript", "name": "installUpdate", "rebootIfRequired": true, "inputs": {"runCommand": ["wusa.exe"]}},
+			{"action": "aws:runPowerShellScript", "name": "verify", "inputs": {"runCommand": ["Get-HotFix"]}}
+		]
+	}`
+	var docContent DocContent
+	assert.NoError(t, json.Unmarshal([]byte(document), &docContent))
+
+	pluginsInfo, err := docContent.ParseDocument(log.NewMockLog(), contracts.DocumentInfo{}, DocumentParserInfo{OrchestrationDir: testOrchDir}, nil)
+
+	assert.NoError(t, err)
+	assert.True(t, pluginsInfo[0].Configuration.RebootIfRequired)
+	assert.False(t, pluginsInfo[1].Configuration.RebootIfRequired)
+	assert.Equal(t, 3, pluginsInfo[0].Configuration.MaxReboots)
+	assert.Equal(t, 3, pluginsInfo[1].Configuration.MaxReboots)
+}
+
+func TestInitializeDocState_RecordsHashAndRedactedParameters(t *testing.T) {
+	var testDocContent DocContent
+	err := json.Unmarshal(loadFile(t, "../../runcommand/mds/testdata/validcommand12.json"), &testDocContent)
+	assert.Nil(t, err)
+	content, _ := json.Marshal(&testDocContent)
+	sum := sha256.Sum256(content)
+
+	params := map[string]interface{}{
+		"commands":      []interface{}{"echo secret"},
+		"password":      "{{ssm-secure:admin-password}}",
+		"region":        "us-east-1",
+		"ApprovalToken": "c2lnbmF0dXJl",
+	}
+	docState, err := InitializeDocState(log.NewMockLog(), contracts.SendCommand, &testDocContent, contracts.DocumentInfo{}, DocumentParserInfo{}, params)
+
+	assert.Nil(t, err)
+	assert.Equal(t, hex.EncodeToString(sum[:]), docState.DocumentInformation.DocumentHash)
+	assert.Equal(t, map[string]string{
+		"commands":      redactedParameterValue,
+		"password":      "{{ssm-secure:admin-password}}",
+		"region":        redactedParameterValue,
+		"ApprovalToken": redactedParameterValue,
+	}, docState.DocumentInformation.RedactedParameters)
+	assert.Equal(t, "c2lnbmF0dXJl", docState.DocumentInformation.ApprovalToken)
+}
@@ -0,0 +1,341 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package docparser contains methods for parsing and encoding any type of document,
+// i.e. association document, MDS/SSM messages, offline service documents, etc.
+package docparser
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/go-yaml/yaml"
+)
+
+const (
+	// includeSchemaVersion is the first schema version whose documents can include fragments
+	includeSchemaVersion = "2.3"
+	// includeKey is the key of the mapping replaced by the content of a fragment
+	includeKey = "$include"
+	// maxIncludeDepth limits how deep fragments can include other fragments
+	maxIncludeDepth = 8
+	// maxDocumentBytes limits the size of a document, of its fragments together and of its expanded content
+	maxDocumentBytes = 4 * 1024 * 1024
+	// maxAliasExpansions limits how many nodes YAML aliases copy, so a small document cannot expand without bounds
+	maxAliasExpansions = 10000
+)
+
+// yamlTokenRegex finds the YAML anchors and aliases of a line
+var yamlTokenRegex = regexp.MustCompile(`(?:^|[\s\[{,])([&*])([^\s,\[\]{}]+)`)
+
+// ResolveDocument reads a JSON or YAML document and includes its fragments.
+// From schema version 2.3, a mapping with the single key $include is replaced by the JSON or YAML fragment
+// at that path relative to baseDir, fragments in a list that are lists themselves are spliced into it.
+// Fragments cannot leave baseDir or include each other in a cycle, and YAML anchors, aliases and merge keys
+// are expanded within limits. Documents without includes are returned as they are, otherwise as JSON.
+func ResolveDocument(raw []byte, baseDir string) ([]byte, error) {
+	resolver := &documentResolver{baseDir: baseDir}
+	content, err := resolver.decode(raw)
+	if err != nil {
+		return nil, err
+	}
+	document, ok := content.(map[string]interface{})
+	if !ok || !isIncludeEnabled(document["schemaVersion"]) {
+		return raw, nil
+	}
+	if content, err = resolver.resolve(content, nil); err != nil {
+		return nil, err
+	}
+	resolved, err := json.Marshal(content)
+	if err != nil {
+		return nil, err
+	}
+	if len(resolved) > maxDocumentBytes {
+		return nil, fmt.Errorf("document is larger than %v bytes once expanded", maxDocumentBytes)
+	}
+	return resolved, nil
+}
+
+// LoadDocument reads a JSON or YAML document from a local file, with the fragments it includes
+func LoadDocument(path string) (docContent DocContent, err error) {
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return docContent, fmt.Errorf("failed to read document %v: %v", path, err)
+	}
+	if raw, err = ResolveDocument(raw, filepath.Dir(path)); err != nil {
+		return docContent, fmt.Errorf("failed to resolve document %v: %v", path, err)
+	}
+	if err = json.Unmarshal(raw, &docContent); err != nil {
+		if err = yaml.Unmarshal(raw, &docContent); err != nil {
+			if validationErr, ok := ValidateDocument(raw).(ValidationErrors); ok {
+				return docContent, validationErr
+			}
+			return docContent, fmt.Errorf("document %v is not valid JSON or YAML: %v", path, err)
+		}
+	}
+	return docContent, nil
+}
+
+// documentResolver tracks the fragments included while a document is resolved
+type documentResolver struct {
+	baseDir       string
+	includedBytes int
+}
+
+// decode parses JSON, or YAML with its aliases expanded, into maps with string keys
+func (resolver *documentResolver) decode(raw []byte) (interface{}, error) {
+	if len(raw) > maxDocumentBytes {
+		return nil, fmt.Errorf("document is larger than %v bytes", maxDocumentBytes)
+	}
+	var content interface{}
+	if err := json.Unmarshal(raw, &content); err == nil {
+		return content, nil
+	}
+	if err := checkAliasExpansion(raw); err != nil {
+		return nil, err
+	}
+	if err := yaml.Unmarshal(raw, &content); err != nil {
+		return nil, fmt.Errorf("document is not valid JSON or YAML: %v", err)
+	}
+	return normalizeYaml(content)
+}
+
+// resolve replaces the includes of the content, stack holds the fragments being included
+func (resolver *documentResolver) resolve(content interface{}, stack []string) (interface{}, error) {
+	switch content := content.(type) {
+	case map[string]interface{}:
+		if path, ok := includePath(content); ok {
+			return resolver.include(path, stack)
+		}
+		for key, value := range content {
+			resolved, err := resolver.resolve(value, stack)
+			if err != nil {
+				return nil, err
+			}
+			content[key] = resolved
+		}
+		return content, nil
+	case []interface{}:
+		out := make([]interface{}, 0, len(content))
+		for _, item := range content {
+			resolved, err := resolver.resolve(item, stack)
+			if err != nil {
+				return nil, err
+			}
+			if _, isInclude := includePath(item); isInclude {
+				if items, isList := resolved.([]interface{}); isList {
+					out = append(out, items...)
+					continue
+				}
+			}
+			out = append(out, resolved)
+		}
+		return out, nil
+	}
+	return content, nil
+}
+
+// include reads a fragment and resolves the fragments it includes
+func (resolver *documentResolver) include(path string, stack []string) (interface{}, error) {
+	fullPath, err := resolver.fragmentPath(path)
+	if err != nil {
+		return nil, err
+	}
+	for _, included := range stack {
+		if included == fullPath {
+			return nil, fmt.Errorf("fragment %v includes itself through %v", path, strings.Join(stack, " -> "))
+		}
+	}
+	if len(stack) >= maxIncludeDepth {
+		return nil, fmt.Errorf("fragment %v is included deeper than %v levels", path, maxIncludeDepth)
+	}
+	raw, err := ioutil.ReadFile(fullPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read fragment %v: %v", path, err)
+	}
+	resolver.includedBytes += len(raw)
+	if resolver.includedBytes > maxDocumentBytes {
+		return nil, fmt.Errorf("fragments are larger than %v bytes", maxDocumentBytes)
+	}
+	fragment, err := resolver.decode(raw)
+	if err != nil {
+		return nil, fmt.Errorf("fragment %v: %v", path, err)
+	}
+	return resolver.resolve(fragment, append(stack, fullPath))
+}
+
+// fragmentPath returns the path of a fragment, which must be a relative path within the base directory
+func (resolver *documentResolver) fragmentPath(path string) (string, error) {
+	if resolver.baseDir == "" {
+		return "", fmt.Errorf("fragment %v cannot be included, the document was not read from a directory", path)
+	}
+	if filepath.IsAbs(path) || filepath.VolumeName(path) != "" {
+		return "", fmt.Errorf("fragment %v must be a relative path", path)
+	}
+	baseDir, err := filepath.Abs(resolver.baseDir)
+	if err != nil {
+		return "", err
+	}
+	fullPath := filepath.Join(baseDir, filepath.FromSlash(path))
+	if fullPath != baseDir && !strings.HasPrefix(fullPath, baseDir+string(os.PathSeparator)) {
+		return "", fmt.Errorf("fragment %v is outside of the document directory", path)
+	}
+	return fullPath, nil
+}
+
+// includePath returns the path of a mapping that only has the include key
+func includePath(content interface{}) (string, bool) {
+	mapping, ok := content.(map[string]interface{})
+	if !ok || len(mapping) != 1 {
+		return "", false
+	}
+	path, ok := mapping[includeKey].(string)
+	return path, ok
+}
+
+// isIncludeEnabled returns true for the schema versions that support includes
+func isIncludeEnabled(schemaVersion interface{}) bool {
+	version, ok := schemaVersion.(string)
+	return ok && version == includeSchemaVersion
+}
+
+// normalizeYaml converts the maps decoded from YAML to maps with string keys, like the ones decoded from JSON
+func normalizeYaml(content interface{}) (interface{}, error) {
+	switch content := content.(type) {
+	case map[interface{}]interface{}:
+		out := make(map[string]interface{}, len(content))
+		for key, value := range content {
+			keyString, ok := key.(string)
+			if !ok {
+				return nil, fmt.Errorf("mapping key %v is not a string", key)
+			}
+			normalized, err := normalizeYaml(value)
+			if err != nil {
+				return nil, err
+			}
+			out[keyString] = normalized
+		}
+		return out, nil
+	case []interface{}:
+		out := make([]interface{}, len(content))
+		for i, item := range content {
+			normalized, err := normalizeYaml(item)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = normalized
+		}
+		return out, nil
+	}
+	return content, nil
+}
+
+// yamlAnchor is an anchored node and the aliases within it
+type yamlAnchor struct {
+	indent  int
+	aliases []string
+}
+
+// checkAliasExpansion estimates how many nodes the aliases of a YAML document copy before it is decoded.
+// An anchored node spans the rest of its line and the following lines that are indented more.
+func checkAliasExpansion(raw []byte) error {
+	anchors := map[string]*yamlAnchor{}
+	var open []string
+	var aliases []string
+
+	scanner := bufio.NewScanner(bytes.NewReader(raw))
+	scanner.Buffer(make([]byte, 64*1024), maxDocumentBytes)
+	for scanner.Scan() {
+		line := stripYamlComment(scanner.Text())
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		indent := len(line) - len(strings.TrimLeft(line, " "))
+		for len(open) > 0 && indent <= anchors[open[len(open)-1]].indent {
+			open = open[:len(open)-1]
+		}
+		for _, token := range yamlTokenRegex.FindAllStringSubmatch(line, -1) {
+			name := token[2]
+			if token[1] == "&" {
+				anchors[name] = &yamlAnchor{indent: indent}
+				open = append(open, name)
+				continue
+			}
+			aliases = append(aliases, name)
+			for _, anchor := range open {
+				anchors[anchor].aliases = append(anchors[anchor].aliases, name)
+			}
+		}
+	}
+	if len(aliases) == 0 {
+		return nil
+	}
+
+	// weights counts the nodes an alias of each anchor copies, including the aliases within it
+	weights := map[string]int{}
+	var weight func(name string, depth int) int
+	weight = func(name string, depth int) int {
+		if w, ok := weights[name]; ok {
+			return w
+		}
+		anchor, ok := anchors[name]
+		if !ok || depth > len(anchors) {
+			return 1
+		}
+		w := 1
+		for _, alias := range anchor.aliases {
+			w += weight(alias, depth+1)
+			if w > maxAliasExpansions {
+				break
+			}
+		}
+		weights[name] = w
+		return w
+	}
+	total := 0
+	for _, alias := range aliases {
+		total += weight(alias, 0)
+		if total > maxAliasExpansions {
+			return fmt.Errorf("YAML aliases expand to more than %v nodes", maxAliasExpansions)
+		}
+	}
+	return nil
+}
+
+// stripYamlComment removes the comment and the quoted strings of a line, anchors are never within them
+func stripYamlComment(line string) string {
+	var out strings.Builder
+	var quote rune
+	for i, c := range line {
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#' && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t'):
+			return out.String()
+		default:
+			out.WriteRune(c)
+		}
+	}
+	return out.String()
+}
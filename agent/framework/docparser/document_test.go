@@ -0,0 +1,165 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package docparser contains methods for parsing and encoding any type of document,
+// i.e. association document, MDS/SSM messages, offline service documents, etc.
+package docparser
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/stretchr/testify/assert"
+)
+
+const includeDocument = `
+schemaVersion: "2.3"
+description: Document with fragments
+parameters:
+  message:
+    type: String
+    default: hello
+defaults: &defaults
+  timeoutSeconds: 60
+  workingDirectory: /tmp
+mainSteps:
+  - $include: steps/prepare.yaml
+  - action: aws:runShellScript
+    name: main
+    inputs:
+      <<: *defaults
+      runCommand:
+        - $include: commands.json
+`
+
+func writeFragments(t *testing.T, files map[string]string) string {
+	dir, err := ioutil.TempDir("", "docparser")
+	assert.NoError(t, err)
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		assert.NoError(t, os.MkdirAll(filepath.Dir(path), 0700))
+		assert.NoError(t, ioutil.WriteFile(path, []byte(content), 0600))
+	}
+	return dir
+}
+
+func TestResolveDocumentIncludesFragments(t *testing.T) {
+	dir := writeFragments(t, map[string]string{
+		"document.yaml": includeDocument,
+		"steps/prepare.yaml": `
+- action: aws:runShellScript
+  name: prepare
+  inputs:
+    runCommand: ["mkdir -p /tmp/app"]
+- action: aws:runShellScript
+  name: check
+  inputs:
+    runCommand:
+      - $include: check.yaml
+`,
+		"check.yaml":    `test -d /tmp/app`,
+		"commands.json": `["echo {{ message }}", "echo done"]`,
+	})
+	defer os.RemoveAll(dir)
+	raw, _ := ioutil.ReadFile(filepath.Join(dir, "document.yaml"))
+
+	resolved, err := ResolveDocument(raw, dir)
+	assert.NoError(t, err)
+
+	var docContent DocContent
+	assert.NoError(t, json.Unmarshal(resolved, &docContent))
+	assert.Equal(t, 3, len(docContent.MainSteps))
+	assert.Equal(t, "prepare", docContent.MainSteps[0].Name)
+	assert.Equal(t, []interface{}{"test -d /tmp/app"}, docContent.MainSteps[1].Inputs.(map[string]interface{})["runCommand"])
+	inputs := docContent.MainSteps[2].Inputs.(map[string]interface{})
+	assert.Equal(t, float64(60), inputs["timeoutSeconds"])
+	assert.Equal(t, []interface{}{"echo {{ message }}", "echo done"}, inputs["runCommand"])
+
+	pluginsInfo, err := docContent.ParseDocument(log.NewMockLog(), contracts.DocumentInfo{}, DocumentParserInfo{OrchestrationDir: testOrchDir}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{"echo hello", "echo done"}, pluginsInfo[2].Configuration.Properties.(map[string]interface{})["runCommand"])
+}
+
+func TestResolveDocumentKeepsDocumentsWithoutIncludes(t *testing.T) {
+	raw := []byte("schemaVersion: \"2.2\"\nmainSteps:\n  - $include: steps.yaml\n")
+
+	resolved, err := ResolveDocument(raw, "")
+
+	assert.NoError(t, err)
+	assert.Equal(t, raw, resolved)
+}
+
+func TestResolveDocumentRefusesCycles(t *testing.T) {
+	dir := writeFragments(t, map[string]string{
+		"a.yaml": `{"$include": "b.yaml"}`,
+		"b.yaml": `{"$include": "a.yaml"}`,
+	})
+	defer os.RemoveAll(dir)
+
+	_, err := ResolveDocument([]byte(`{"schemaVersion": "2.3", "mainSteps": [{"$include": "a.yaml"}]}`), dir)
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "includes itself")
+}
+
+func TestResolveDocumentRefusesFragmentsOutsideOfItsDirectory(t *testing.T) {
+	dir := writeFragments(t, map[string]string{"document/steps.yaml": "[]", "secret.yaml": "[]"})
+	defer os.RemoveAll(dir)
+
+	for _, path := range []string{"../secret.yaml", filepath.Join(dir, "secret.yaml"), "missing.yaml"} {
+		document := fmt.Sprintf(`{"schemaVersion": "2.3", "mainSteps": [{"$include": %q}]}`, path)
+		_, err := ResolveDocument([]byte(document), filepath.Join(dir, "document"))
+		assert.Error(t, err, path)
+	}
+
+	_, err := ResolveDocument([]byte(`{"schemaVersion": "2.3", "mainSteps": [{"$include": "steps.yaml"}]}`), "")
+	assert.Error(t, err)
+}
+
+func TestResolveDocumentLimitsIncludeDepth(t *testing.T) {
+	files := map[string]string{}
+	for i := 0; i <= maxIncludeDepth; i++ {
+		files[fmt.Sprintf("f%v.yaml", i)] = fmt.Sprintf(`{"$include": "f%v.yaml"}`, i+1)
+	}
+	files[fmt.Sprintf("f%v.yaml", maxIncludeDepth+1)] = "[]"
+	dir := writeFragments(t, files)
+	defer os.RemoveAll(dir)
+
+	_, err := ResolveDocument([]byte(`{"schemaVersion": "2.3", "mainSteps": [{"$include": "f0.yaml"}]}`), dir)
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "deeper")
+}
+
+func TestCheckAliasExpansion(t *testing.T) {
+	lines := []string{`a: &a ["lol", "lol", "lol", "lol", "lol", "lol", "lol", "lol", "lol"]`}
+	for i := 'b'; i <= 'i'; i++ {
+		aliases := strings.Repeat(fmt.Sprintf("*%c, ", i-1), 9)
+		lines = append(lines, fmt.Sprintf("%c: &%c [%v]", i, i, strings.TrimSuffix(aliases, ", ")))
+	}
+	bomb := []byte(strings.Join(lines, "\n"))
+
+	assert.Error(t, checkAliasExpansion(bomb))
+	_, err := ResolveDocument(bomb, "")
+	assert.Error(t, err)
+
+	assert.NoError(t, checkAliasExpansion([]byte(includeDocument)))
+	assert.NoError(t, checkAliasExpansion([]byte("a: \"*not an alias\" # &comment\nb: value")))
+}
@@ -0,0 +1,139 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package instancemetadata resolves the references of documents to read-only information about the instance, like
+// {{instance:az}} or {{instance:type}}. The values come from the agent platform package, which queries the instance
+// metadata service with IMDSv2 session tokens, so documents do not need to call it themselves.
+package instancemetadata
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/platform"
+)
+
+// instanceReference matches the references to instance information, the key is the first group
+var instanceReference = regexp.MustCompile(`{{\s*instance:([a-zA-Z]*)\s*}}`)
+
+// instanceValues returns the value of every key documents can reference
+var instanceValues = map[string]func(log log.T) (string, error){
+	"id":        func(log.T) (string, error) { return platform.InstanceID() },
+	"az":        func(log.T) (string, error) { return platform.AvailabilityZone() },
+	"region":    func(log.T) (string, error) { return platform.Region() },
+	"type":      func(log.T) (string, error) { return platform.InstanceType() },
+	"privateIp": func(log.T) (string, error) { return platform.IP() },
+	"hostname":  platform.Hostname,
+}
+
+// TextContainsInstanceMetadata returns true when the text references instance information
+func TextContainsInstanceMetadata(text string) bool {
+	return instanceReference.MatchString(text)
+}
+
+// ResolveText replaces the references to instance information in the text, it fails on unknown keys
+func ResolveText(log log.T, text string) (string, error) {
+	resolved, err := Resolve(log, text)
+	if err != nil {
+		return text, err
+	}
+	return resolved.(string), nil
+}
+
+// Resolve replaces the references to instance information in the strings of the input, it fails on unknown keys and
+// on values that cannot be fetched
+func Resolve(log log.T, input interface{}) (interface{}, error) {
+	var keys []string
+	collectKeys(input, &keys)
+	if len(keys) == 0 {
+		return input, nil
+	}
+	values := make(map[string]string)
+	for _, key := range keys {
+		if _, fetched := values[key]; fetched {
+			continue
+		}
+		getValue, known := instanceValues[key]
+		if !known {
+			return input, fmt.Errorf("unknown instance information {{instance:%v}}", key)
+		}
+		value, err := getValue(log)
+		if err != nil {
+			return input, fmt.Errorf("failed to get instance information %v: %v", key, err)
+		}
+		values[key] = value
+	}
+	return replaceKeys(input, values), nil
+}
+
+// collectKeys appends the keys the input references
+func collectKeys(input interface{}, keys *[]string) {
+	switch value := input.(type) {
+	case string:
+		for _, match := range instanceReference.FindAllStringSubmatch(value, -1) {
+			*keys = append(*keys, match[1])
+		}
+	case []interface{}:
+		for _, item := range value {
+			collectKeys(item, keys)
+		}
+	case []string:
+		for _, item := range value {
+			collectKeys(item, keys)
+		}
+	case map[string]interface{}:
+		for _, item := range value {
+			collectKeys(item, keys)
+		}
+	case []map[string]interface{}:
+		for _, item := range value {
+			collectKeys(item, keys)
+		}
+	}
+}
+
+// replaceKeys returns the input with the references replaced by their values
+func replaceKeys(input interface{}, values map[string]string) interface{} {
+	switch value := input.(type) {
+	case string:
+		return instanceReference.ReplaceAllStringFunc(value, func(reference string) string {
+			return values[instanceReference.FindStringSubmatch(reference)[1]]
+		})
+	case []interface{}:
+		replaced := make([]interface{}, len(value))
+		for i, item := range value {
+			replaced[i] = replaceKeys(item, values)
+		}
+		return replaced
+	case []string:
+		replaced := make([]string, len(value))
+		for i, item := range value {
+			replaced[i] = replaceKeys(item, values).(string)
+		}
+		return replaced
+	case []map[string]interface{}:
+		replaced := make([]map[string]interface{}, len(value))
+		for i, item := range value {
+			replaced[i] = replaceKeys(item, values).(map[string]interface{})
+		}
+		return replaced
+	case map[string]interface{}:
+		replaced := make(map[string]interface{}, len(value))
+		for key, item := range value {
+			replaced[key] = replaceKeys(item, values)
+		}
+		return replaced
+	}
+	return input
+}
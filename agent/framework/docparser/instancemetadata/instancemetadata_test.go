@@ -0,0 +1,66 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package instancemetadata
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func stubInstanceValues(values map[string]func(log log.T) (string, error)) (restore func()) {
+	original := instanceValues
+	instanceValues = values
+	return func() { instanceValues = original }
+}
+
+func TestResolve(t *testing.T) {
+	calls := 0
+	defer stubInstanceValues(map[string]func(log log.T) (string, error){
+		"az":     func(log.T) (string, error) { calls++; return "us-east-1a", nil },
+		"type":   func(log.T) (string, error) { return "m5.large", nil },
+		"region": func(log.T) (string, error) { return "", errors.New("metadata unavailable") },
+	})()
+	logger := log.NewMockLog()
+
+	input := map[string]interface{}{
+		"runCommand":       []interface{}{"echo {{instance:az}} {{ instance:type }}", "echo {{instance:az}}"},
+		"workingDirectory": "/srv/{{tag:Team}}",
+	}
+	resolved, err := Resolve(logger, input)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"runCommand":       []interface{}{"echo us-east-1a m5.large", "echo us-east-1a"},
+		"workingDirectory": "/srv/{{tag:Team}}",
+	}, resolved)
+	assert.Equal(t, 1, calls)
+
+	_, err = Resolve(logger, []string{"{{instance:macAddress}}"})
+	assert.Error(t, err)
+
+	_, err = Resolve(logger, "{{instance:region}}")
+	assert.Error(t, err)
+
+	text, err := ResolveText(logger, "{{instance:type}}")
+	assert.NoError(t, err)
+	assert.Equal(t, "m5.large", text)
+}
+
+func TestTextContainsInstanceMetadata(t *testing.T) {
+	assert.True(t, TextContainsInstanceMetadata("{{ instance:hostname }}"))
+	assert.False(t, TextContainsInstanceMetadata("{{ instance }}"))
+	assert.False(t, TextContainsInstanceMetadata("{{tag:Environment}}"))
+}
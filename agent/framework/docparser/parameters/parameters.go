@@ -34,6 +34,10 @@ const paramNameRegex = "^[a-zA-Z0-9]+$"
 // Strings like "a {{ parameter1 }} within a string" are replaced with strings where the parameters
 // are replaced by a marshaled version of their values. In this case, the resulting object is always a string.
 //
+// Strings like "{{ upper parameter }}" or "{{ join (split parameter ",") ";" }}" call the functions of the
+// template library (upper, lower, trim, join, split, default, base64Encode and base64Decode) with parameters,
+// quoted strings and other calls in parentheses. Expressions using names that are not parameters are left as is.
+//
 // Note: this only works on composite types []interface{} and map[string]interface{} which are what json.Unmarshal
 // produces by default. If your object contains []string, for example, the object will be returned as is.
 //
@@ -48,6 +52,22 @@ func ReplaceParameters(input interface{}, parameters map[string]interface{}, log
 			}
 		}
 
+		// then expressions calling a function, like "{{ upper parameter }}", keep the type of their value
+		if node, ok := isSingleExpressionString(input); ok {
+			value, err := node.evaluate(parameters)
+			if err == nil {
+				return value
+			}
+			logger.Debugf("Expression %v is not replaced: %v", input, err)
+		}
+
+		// expressions within a string are replaced by their marshaled value
+		replaced, err := replaceExpressions(input, parameters)
+		if err != nil {
+			logger.Debugf("Expressions of %v are not all replaced: %v", input, err)
+		}
+		input = replaced
+
 		// look for multiple parameter strings
 		for parameterName, parameterValue := range parameters {
 			var parameterValueString string
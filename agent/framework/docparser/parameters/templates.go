@@ -0,0 +1,335 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package parameters provides utilities to parse ssm document parameters
+package parameters
+
+import (
+	"encoding/base64"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// templateFunction is a pure function documents call in expressions like {{ upper name }}
+type templateFunction struct {
+	arity int
+	call  func(args []interface{}) (interface{}, error)
+}
+
+// templateFunctions is the function library of parameter expressions
+var templateFunctions = map[string]templateFunction{
+	"upper": {1, func(args []interface{}) (interface{}, error) {
+		return strings.ToUpper(toTemplateString(args[0])), nil
+	}},
+	"lower": {1, func(args []interface{}) (interface{}, error) {
+		return strings.ToLower(toTemplateString(args[0])), nil
+	}},
+	"trim": {1, func(args []interface{}) (interface{}, error) {
+		return strings.TrimSpace(toTemplateString(args[0])), nil
+	}},
+	"join": {2, func(args []interface{}) (interface{}, error) {
+		list, ok := args[0].([]interface{})
+		if !ok {
+			return toTemplateString(args[0]), nil
+		}
+		items := make([]string, len(list))
+		for i, item := range list {
+			items[i] = toTemplateString(item)
+		}
+		return strings.Join(items, toTemplateString(args[1])), nil
+	}},
+	"split": {2, func(args []interface{}) (interface{}, error) {
+		items := strings.Split(toTemplateString(args[0]), toTemplateString(args[1]))
+		list := make([]interface{}, len(items))
+		for i, item := range items {
+			list[i] = item
+		}
+		return list, nil
+	}},
+	"default": {2, func(args []interface{}) (interface{}, error) {
+		if isEmptyTemplateValue(args[0]) {
+			return args[1], nil
+		}
+		return args[0], nil
+	}},
+	"base64Encode": {1, func(args []interface{}) (interface{}, error) {
+		return base64.StdEncoding.EncodeToString([]byte(toTemplateString(args[0]))), nil
+	}},
+	"base64Decode": {1, func(args []interface{}) (interface{}, error) {
+		decoded, err := base64.StdEncoding.DecodeString(toTemplateString(args[0]))
+		return string(decoded), err
+	}},
+}
+
+// expressionRegex matches the braces of an expression starting with a function name
+var expressionRegex = regexp.MustCompile(`{{\s*([a-zA-Z0-9]+)\s((?:[^{}"]|"(?:[^"\\]|\\.)*")*)}}`)
+
+// tokenRegex splits expressions into names, quoted strings and parentheses
+var tokenRegex = regexp.MustCompile(`\s*("(?:[^"\\]|\\.)*"|[a-zA-Z0-9]+|[()])`)
+
+// templateNode is a quoted string, a parameter name or a function call of an expression
+type templateNode struct {
+	literal   *string
+	reference string
+	function  string
+	args      []templateNode
+}
+
+// parseExpression parses the content of the braces of an expression, it returns false if it does not call a known function
+func parseExpression(expression string) (templateNode, bool) {
+	var tokens []string
+	rest := strings.TrimSpace(expression)
+	for len(rest) > 0 {
+		match := tokenRegex.FindStringSubmatchIndex(rest)
+		if match == nil || match[0] != 0 {
+			return templateNode{}, false
+		}
+		tokens = append(tokens, rest[match[2]:match[3]])
+		rest = strings.TrimSpace(rest[match[1]:])
+	}
+	node, next, ok := parseCall(tokens, 0)
+	if !ok || next != len(tokens) {
+		return templateNode{}, false
+	}
+	return node, true
+}
+
+// parseCall parses a function name followed by its arguments
+func parseCall(tokens []string, pos int) (templateNode, int, bool) {
+	if pos >= len(tokens) {
+		return templateNode{}, pos, false
+	}
+	function, known := templateFunctions[tokens[pos]]
+	if !known {
+		return templateNode{}, pos, false
+	}
+	node := templateNode{function: tokens[pos]}
+	pos++
+	for len(node.args) < function.arity {
+		if pos >= len(tokens) {
+			return templateNode{}, pos, false
+		}
+		token := tokens[pos]
+		switch {
+		case token == "(":
+			arg, next, ok := parseCall(tokens, pos+1)
+			if !ok || next >= len(tokens) || tokens[next] != ")" {
+				return templateNode{}, pos, false
+			}
+			node.args = append(node.args, arg)
+			pos = next + 1
+		case strings.HasPrefix(token, `"`):
+			value, err := strconv.Unquote(token)
+			if err != nil {
+				return templateNode{}, pos, false
+			}
+			node.args = append(node.args, templateNode{literal: &value})
+			pos++
+		case token == ")":
+			return templateNode{}, pos, false
+		default:
+			node.args = append(node.args, templateNode{reference: token})
+			pos++
+		}
+	}
+	return node, pos, true
+}
+
+// references adds the parameter names the node uses to names
+func (node templateNode) references(names map[string]struct{}) {
+	if node.reference != "" {
+		names[node.reference] = struct{}{}
+	}
+	for _, arg := range node.args {
+		arg.references(names)
+	}
+}
+
+// evaluate computes the value of the node, it fails on names that are not parameters
+func (node templateNode) evaluate(parameters map[string]interface{}) (interface{}, error) {
+	if node.literal != nil {
+		return *node.literal, nil
+	}
+	if node.reference != "" {
+		value, ok := parameters[node.reference]
+		if !ok {
+			return nil, fmt.Errorf("unknown parameter %v", node.reference)
+		}
+		return value, nil
+	}
+	args := make([]interface{}, len(node.args))
+	for i, arg := range node.args {
+		value, err := arg.evaluate(parameters)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = value
+	}
+	value, err := templateFunctions[node.function].call(args)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %v", node.function, err)
+	}
+	return value, nil
+}
+
+// isSingleExpressionString returns the expression if the input has the form "{{ function args }}" and nothing else
+func isSingleExpressionString(input string) (templateNode, bool) {
+	match := expressionRegex.FindStringSubmatchIndex(input)
+	if match == nil || match[0] != 0 || match[1] != len(input) {
+		return templateNode{}, false
+	}
+	return parseExpression(input[match[2]:match[5]])
+}
+
+// replaceExpressions replaces the expressions in the input by the string of their value.
+// Expressions that do not call a known function or use unknown names are left as they are.
+func replaceExpressions(input string, parameters map[string]interface{}) (string, error) {
+	var evalErr error
+	output := expressionRegex.ReplaceAllStringFunc(input, func(match string) string {
+		groups := expressionRegex.FindStringSubmatch(match)
+		node, ok := parseExpression(groups[1] + " " + groups[2])
+		if !ok {
+			return match
+		}
+		value, err := node.evaluate(parameters)
+		if err != nil {
+			evalErr = err
+			return match
+		}
+		valueString, err := convertToString(value)
+		if err != nil {
+			evalErr = err
+			return match
+		}
+		return valueString
+	})
+	return output, evalErr
+}
+
+// EvaluateVariables computes the document variables from the parameters and the other variables.
+// Variables are strings that may use parameters and expressions, they cannot replace a parameter
+// and cannot depend on each other in a cycle.
+func EvaluateVariables(variables map[string]string, parameters map[string]interface{}) (map[string]interface{}, error) {
+	values := make(map[string]interface{}, len(parameters)+len(variables))
+	for name, value := range parameters {
+		values[name] = value
+	}
+	pending := make([]string, 0, len(variables))
+	for name := range variables {
+		if !validName(name) {
+			return nil, fmt.Errorf("invalid variable name %v", name)
+		}
+		if _, exists := parameters[name]; exists {
+			return nil, fmt.Errorf("variable %v has the name of a parameter", name)
+		}
+		pending = append(pending, name)
+	}
+	sort.Strings(pending)
+
+	for len(pending) > 0 {
+		var blocked []string
+		for _, name := range pending {
+			if !referencesResolved(variables[name], values, variables) {
+				blocked = append(blocked, name)
+				continue
+			}
+			value, err := evaluateVariable(variables[name], values)
+			if err != nil {
+				return nil, fmt.Errorf("variable %v: %v", name, err)
+			}
+			values[name] = value
+		}
+		if len(blocked) == len(pending) {
+			return nil, fmt.Errorf("variables %v reference each other or unknown names", strings.Join(blocked, ", "))
+		}
+		pending = blocked
+	}
+
+	result := make(map[string]interface{}, len(variables))
+	for name := range variables {
+		result[name] = values[name]
+	}
+	return result, nil
+}
+
+// referencesResolved returns false while the variable uses another variable that is not computed yet
+func referencesResolved(variable string, values map[string]interface{}, variables map[string]string) bool {
+	names := map[string]struct{}{}
+	for _, groups := range expressionRegex.FindAllStringSubmatch(variable, -1) {
+		if node, ok := parseExpression(groups[1] + " " + groups[2]); ok {
+			node.references(names)
+		}
+	}
+	for name := range variables {
+		if ReplaceParameter(variable, name, "") != variable {
+			names[name] = struct{}{}
+		}
+	}
+	for name := range names {
+		if _, resolved := values[name]; !resolved {
+			if _, isVariable := variables[name]; isVariable {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// evaluateVariable replaces the parameters and expressions of one variable
+func evaluateVariable(variable string, values map[string]interface{}) (interface{}, error) {
+	if node, ok := isSingleExpressionString(variable); ok {
+		return node.evaluate(values)
+	}
+	for name, value := range values {
+		if isSingleParameterString(variable, name) {
+			return value, nil
+		}
+	}
+	output, err := replaceExpressions(variable, values)
+	if err != nil {
+		return nil, err
+	}
+	for name, value := range values {
+		valueString, err := convertToString(value)
+		if err != nil {
+			return nil, err
+		}
+		output = ReplaceParameter(output, name, valueString)
+	}
+	return output, nil
+}
+
+// toTemplateString returns strings as they are and other values as json
+func toTemplateString(value interface{}) string {
+	if value == nil {
+		return ""
+	}
+	valueString, _ := convertToString(value)
+	return valueString
+}
+
+// isEmptyTemplateValue returns true for missing values, empty strings and empty lists
+func isEmptyTemplateValue(value interface{}) bool {
+	switch value := value.(type) {
+	case nil:
+		return true
+	case string:
+		return value == ""
+	case []interface{}:
+		return len(value) == 0
+	}
+	return false
+}
@@ -0,0 +1,91 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// package parameters provides utilities to parse ssm document parameters
+package parameters
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReplaceParametersWithExpressions(t *testing.T) {
+	params := map[string]interface{}{
+		"env":      "Prod",
+		"empty":    "",
+		"packages": []interface{}{"git", "curl"},
+		"csv":      "a,b",
+		"encoded":  "aGVsbG8=",
+	}
+	testCases := []ReplaceParamTestCase{
+		{"{{ upper env }}", params, "PROD"},
+		{"{{lower env}}", params, "prod"},
+		{"{{ trim \" x \" }}", params, "x"},
+		{"{{ split csv \",\" }}", params, []interface{}{"a", "b"}},
+		{"install {{ join packages \" \" }}", params, "install git curl"},
+		{"{{ default empty \"dev\" }}-{{ env }}", params, "dev-Prod"},
+		{"{{ default env \"dev\" }}", params, "Prod"},
+		{"{{ base64Encode env }} {{ base64Decode encoded }}", params, "UHJvZA== hello"},
+		{"{{ upper (default empty env) }}", params, "PROD"},
+		{"{{ join (split csv \",\") \";\" }}", params, "a;b"},
+		{"{{ upper missing }}", params, "{{ upper missing }}"},
+		{"{{ unknown env }}", params, "{{ unknown env }}"},
+		{"{{ upper }}", params, "{{ upper }}"},
+		{"{{ ssm:upper }}", params, "{{ ssm:upper }}"},
+		{[]interface{}{"{{ upper env }}", "{{ env }}"}, params, []interface{}{"PROD", "Prod"}},
+	}
+
+	for _, testCase := range testCases {
+		result := ReplaceParameters(testCase.Input, testCase.Params, logger)
+		assert.Equal(t, testCase.Output, result, "input %v", testCase.Input)
+	}
+}
+
+func TestEvaluateVariables(t *testing.T) {
+	params := map[string]interface{}{
+		"env":   "prod",
+		"names": []interface{}{"web", "db"},
+	}
+	variables := map[string]string{
+		"stage":  "{{ upper env }}",
+		"prefix": "app-{{ stage }}",
+		"hosts":  "{{ join names \",\" }}",
+		"list":   "{{ names }}",
+	}
+
+	values, err := EvaluateVariables(variables, params)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "PROD", values["stage"])
+	assert.Equal(t, "app-PROD", values["prefix"])
+	assert.Equal(t, "web,db", values["hosts"])
+	assert.Equal(t, []interface{}{"web", "db"}, values["list"])
+	assert.NotContains(t, values, "env")
+}
+
+func TestEvaluateVariablesFailures(t *testing.T) {
+	params := map[string]interface{}{"env": "prod"}
+
+	_, err := EvaluateVariables(map[string]string{"env": "{{ upper env }}"}, params)
+	assert.Error(t, err)
+
+	_, err = EvaluateVariables(map[string]string{"bad-name": "x"}, params)
+	assert.Error(t, err)
+
+	_, err = EvaluateVariables(map[string]string{"a": "{{ b }}", "b": "{{ upper a }}"}, params)
+	assert.Error(t, err)
+
+	_, err = EvaluateVariables(map[string]string{"decoded": "{{ base64Decode \"%%\" }}"}, params)
+	assert.Error(t, err)
+}
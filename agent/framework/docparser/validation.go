@@ -0,0 +1,392 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package docparser contains methods for parsing and encoding any type of document,
+// i.e. association document, MDS/SSM messages, offline service documents, etc.
+package docparser
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+)
+
+// documentSchema is the JSON schema of command documents. It supports the keywords type, properties, required,
+// additionalProperties, items, enum, minimum, minItems and minLength, and uniqueProperty which requires the items
+// of an array to have different values for a property. The enum of schemaVersion is filled from the supported versions.
+const documentSchema = `{
+  "type": "object",
+  "required": ["schemaVersion"],
+  "properties": {
+    "schemaVersion": {"type": "string"},
+    "description": {"type": "string"},
+    "parameters": {
+      "type": "object",
+      "additionalProperties": {
+        "type": "object",
+        "required": ["type"],
+        "properties": {
+          "type": {"type": "string", "minLength": 1},
+          "description": {"type": "string"},
+          "allowedValues": {"type": "array"},
+          "allowedPattern": {"type": "string"},
+          "displayType": {"type": "string"},
+          "minItems": {"type": "integer", "minimum": 0},
+          "maxItems": {"type": "integer", "minimum": 0},
+          "minChars": {"type": "integer", "minimum": 0},
+          "maxChars": {"type": "integer", "minimum": 0}
+        }
+      }
+    },
+    "variables": {"type": "object", "additionalProperties": {"type": "string"}},
+    "runtimeConfig": {
+      "type": "object",
+      "additionalProperties": {"type": "object", "properties": {"description": {"type": "string"}}}
+    },
+    "mainSteps": {
+      "type": "array",
+      "minItems": 1,
+      "uniqueProperty": "name",
+      "items": {
+        "type": "object",
+        "required": ["action", "name"],
+        "properties": {
+          "action": {"type": "string", "minLength": 1},
+          "name": {"type": "string", "minLength": 1},
+          "inputs": {"type": "object"},
+          "settings": {"type": "object"},
+          "maxAttempts": {"type": "integer", "minimum": 0},
+          "timeoutSeconds": {"type": "integer", "minimum": 0},
+          "onFailure": {"type": "string"},
+          "rebootIfRequired": {"type": "boolean"},
+          "expectedOutputSha256": {"type": "string"},
+          "precondition": {"type": "object", "additionalProperties": {"type": "array", "items": {"type": "string"}}}
+        }
+      }
+    },
+    "executionContext": {
+      "type": "object",
+      "properties": {"seLinuxDomain": {"type": "string"}, "appArmorProfile": {"type": "string"}}
+    },
+    "outputS3Settings": {
+      "type": "object",
+      "properties": {
+        "serverSideEncryption": {"type": "string"},
+        "sseKmsKeyId": {"type": "string"},
+        "expectedBucketOwner": {"type": "string"},
+        "objectAcl": {"type": "string"}
+      }
+    },
+    "priority": {"type": "string"},
+    "schedulingClass": {"type": "string"},
+    "maxReboots": {"type": "integer", "minimum": 0}
+  }
+}`
+
+// commandDocumentSchema is the compiled documentSchema
+var commandDocumentSchema = compileDocumentSchema()
+
+// ValidationError is a violation of the document schema, Path locates the value like mainSteps[1].action
+type ValidationError struct {
+	Path   string
+	Reason string
+}
+
+// Error returns the path and the reason of the violation
+func (e ValidationError) Error() string {
+	if e.Path == "" {
+		return e.Reason
+	}
+	return fmt.Sprintf("%v: %v", e.Path, e.Reason)
+}
+
+// ValidationErrors are all the violations of the document schema, in document order
+type ValidationErrors []ValidationError
+
+// Error lists the violations one per line
+func (errs ValidationErrors) Error() string {
+	lines := make([]string, 0, len(errs)+1)
+	lines = append(lines, "Document is not valid:")
+	for _, err := range errs {
+		lines = append(lines, "  "+err.Error())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// jsonSchema is the subset of JSON schema used to validate documents
+type jsonSchema struct {
+	Type                 schemaTypes            `json:"type"`
+	Properties           map[string]*jsonSchema `json:"properties"`
+	Required             []string               `json:"required"`
+	AdditionalProperties *jsonSchema            `json:"additionalProperties"`
+	Items                *jsonSchema            `json:"items"`
+	Enum                 []interface{}          `json:"enum"`
+	Minimum              *float64               `json:"minimum"`
+	MinItems             int                    `json:"minItems"`
+	MinLength            int                    `json:"minLength"`
+	UniqueProperty       string                 `json:"uniqueProperty"`
+}
+
+// schemaTypes are the types a value can have, the schema holds a single type or a list of them
+type schemaTypes []string
+
+// UnmarshalJSON reads a single type or a list of types
+func (types *schemaTypes) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*types = schemaTypes{single}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	*types = list
+	return nil
+}
+
+// compileDocumentSchema parses documentSchema and allows the supported schema versions
+func compileDocumentSchema() *jsonSchema {
+	var schema jsonSchema
+	if err := json.Unmarshal([]byte(documentSchema), &schema); err != nil {
+		panic(fmt.Sprintf("invalid document schema: %v", err))
+	}
+	versions := make([]string, 0, len(appconfig.SupportedDocumentVersions))
+	for version := range appconfig.SupportedDocumentVersions {
+		versions = append(versions, version)
+	}
+	sort.Strings(versions)
+	for _, version := range versions {
+		schema.Properties["schemaVersion"].Enum = append(schema.Properties["schemaVersion"].Enum, version)
+	}
+	return &schema
+}
+
+// ValidateDocument validates a JSON or YAML command document against the document schema.
+// It returns ValidationErrors with the path and the reason of every violation, or the error decoding the document.
+func ValidateDocument(raw []byte) error {
+	content, err := (&documentResolver{}).decode(raw)
+	if err != nil {
+		return err
+	}
+	return validateContent(content)
+}
+
+// validateDocumentContent validates a parsed document against the document schema
+func validateDocumentContent(docContent DocContent) error {
+	raw, err := json.Marshal(docContent)
+	if err != nil {
+		return err
+	}
+	var content interface{}
+	if err = json.Unmarshal(raw, &content); err != nil {
+		return err
+	}
+	return validateContent(content)
+}
+
+// validateContent validates decoded document content and checks the sections its schema version requires
+func validateContent(content interface{}) error {
+	var errs ValidationErrors
+	commandDocumentSchema.validate(content, "", &errs)
+	if document, ok := content.(map[string]interface{}); ok {
+		version, _ := document["schemaVersion"].(string)
+		if _, supported := appconfig.SupportedDocumentVersions[version]; supported {
+			section := "mainSteps"
+			if strings.HasPrefix(version, "1.") {
+				section = "runtimeConfig"
+			}
+			if document[section] == nil {
+				errs = append(errs, ValidationError{Path: section, Reason: "is required for schema version " + version})
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// validate appends the violations of value and of its content, null values are treated like missing values
+func (schema *jsonSchema) validate(value interface{}, path string, errs *ValidationErrors) {
+	if value == nil {
+		return
+	}
+	value = normalizeNumber(value)
+	if valueType := jsonType(value); !schema.allowsType(valueType) {
+		*errs = append(*errs, ValidationError{Path: path, Reason: fmt.Sprintf("expected %v, got %v", strings.Join(schema.Type, " or "), valueType)})
+		return
+	}
+	if len(schema.Enum) > 0 && !schema.allowsValue(value) {
+		*errs = append(*errs, ValidationError{Path: path, Reason: fmt.Sprintf("must be one of %v, got %v", formatEnum(schema.Enum), formatValue(value))})
+	}
+
+	switch v := value.(type) {
+	case string:
+		if utf8.RuneCountInString(v) < schema.MinLength {
+			reason := fmt.Sprintf("must be at least %v characters long", schema.MinLength)
+			if schema.MinLength == 1 {
+				reason = "must not be empty"
+			}
+			*errs = append(*errs, ValidationError{Path: path, Reason: reason})
+		}
+	case float64:
+		if schema.Minimum != nil && v < *schema.Minimum {
+			*errs = append(*errs, ValidationError{Path: path, Reason: fmt.Sprintf("must be at least %v, got %v", *schema.Minimum, v)})
+		}
+	case map[string]interface{}:
+		schema.validateObject(v, path, errs)
+	case []interface{}:
+		schema.validateArray(v, path, errs)
+	}
+}
+
+// validateObject checks the required properties, then the properties in alphabetical order
+func (schema *jsonSchema) validateObject(object map[string]interface{}, path string, errs *ValidationErrors) {
+	for _, name := range schema.Required {
+		if object[name] == nil {
+			*errs = append(*errs, ValidationError{Path: propertyPath(path, name), Reason: "is required"})
+		}
+	}
+	names := make([]string, 0, len(object))
+	for name := range object {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if property, ok := schema.Properties[name]; ok {
+			property.validate(object[name], propertyPath(path, name), errs)
+		} else if schema.AdditionalProperties != nil {
+			schema.AdditionalProperties.validate(object[name], propertyPath(path, name), errs)
+		}
+	}
+}
+
+// validateArray checks the length, the items and the values of the unique property
+func (schema *jsonSchema) validateArray(array []interface{}, path string, errs *ValidationErrors) {
+	if len(array) < schema.MinItems {
+		*errs = append(*errs, ValidationError{Path: path, Reason: fmt.Sprintf("must have at least %v items", schema.MinItems)})
+	}
+	seen := make(map[interface{}]int)
+	for i, item := range array {
+		itemPath := fmt.Sprintf("%v[%v]", path, i)
+		if schema.Items != nil {
+			schema.Items.validate(item, itemPath, errs)
+		}
+		if schema.UniqueProperty == "" {
+			continue
+		}
+		object, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if key, ok := object[schema.UniqueProperty].(string); ok && key != "" {
+			if first, exists := seen[key]; exists {
+				*errs = append(*errs, ValidationError{
+					Path:   propertyPath(itemPath, schema.UniqueProperty),
+					Reason: fmt.Sprintf("%v is already used by %v[%v]", formatValue(key), path, first),
+				})
+			} else {
+				seen[key] = i
+			}
+		}
+	}
+}
+
+// allowsType tells whether the schema accepts values of the JSON type, integers are numbers too
+func (schema *jsonSchema) allowsType(valueType string) bool {
+	if len(schema.Type) == 0 {
+		return true
+	}
+	for _, allowed := range schema.Type {
+		if allowed == valueType || (allowed == "number" && valueType == "integer") {
+			return true
+		}
+	}
+	return false
+}
+
+// allowsValue tells whether the value is one of the enum values
+func (schema *jsonSchema) allowsValue(value interface{}) bool {
+	for _, allowed := range schema.Enum {
+		if reflect.DeepEqual(allowed, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// jsonType returns the JSON schema type of a decoded value
+func jsonType(value interface{}) string {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case float64:
+		if v == math.Trunc(v) {
+			return "integer"
+		}
+		return "number"
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
+
+// normalizeNumber converts the integers decoded from YAML to float64 like the numbers decoded from JSON
+func normalizeNumber(value interface{}) interface{} {
+	switch v := value.(type) {
+	case int:
+		return float64(v)
+	case int64:
+		return float64(v)
+	case uint64:
+		return float64(v)
+	}
+	return value
+}
+
+// propertyPath appends a property name to a path
+func propertyPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+// formatEnum lists the allowed values
+func formatEnum(values []interface{}) string {
+	formatted := make([]string, len(values))
+	for i, value := range values {
+		formatted[i] = formatValue(value)
+	}
+	return strings.Join(formatted, ", ")
+}
+
+// formatValue quotes strings so empty and blank values are visible
+func formatValue(value interface{}) string {
+	if s, ok := value.(string); ok {
+		return fmt.Sprintf("%q", s)
+	}
+	return fmt.Sprint(value)
+}
@@ -0,0 +1,115 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package docparser contains methods for parsing and encoding any type of document,
+// i.e. association document, MDS/SSM messages, offline service documents, etc.
+package docparser
+
+import (
+	"testing"
+
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/stretchr/testify/assert"
+)
+
+const invalidStepsDocument = `
+schemaVersion: "2.2"
+parameters:
+  message:
+    description: missing type
+variables:
+  upperMessage: 3
+mainSteps:
+  - action: aws:runShellScript
+    name: first
+    timeoutSeconds: -1
+  - name: second
+    inputs: [echo]
+  - action: aws:runShellScript
+    name: first
+`
+
+func TestValidateDocument_Valid(t *testing.T) {
+	document := `{
+		"schemaVersion": "2.2",
+		"parameters": {"message": {"type": "String", "default": "hello"}},
+		"mainSteps": [
+			{"action": "aws:runShellScript", "name": "first", "inputs": {"runCommand": ["echo {{ message }}"]}},
+			{"action": "aws:runShellScript", "name": "second", "precondition": {"StringEquals": ["platformType", "Linux"]}}
+		]
+	}`
+	assert.NoError(t, ValidateDocument([]byte(document)))
+}
+
+func TestValidateDocument_Violations(t *testing.T) {
+	err := ValidateDocument([]byte(invalidStepsDocument))
+
+	errs, ok := err.(ValidationErrors)
+	assert.True(t, ok)
+	assert.Equal(t, ValidationErrors{
+		{Path: "mainSteps[0].timeoutSeconds", Reason: "must be at least 0, got -1"},
+		{Path: "mainSteps[1].action", Reason: "is required"},
+		{Path: "mainSteps[1].inputs", Reason: "expected object, got array"},
+		{Path: "mainSteps[2].name", Reason: `"first" is already used by mainSteps[0]`},
+		{Path: "parameters.message.type", Reason: "is required"},
+		{Path: "variables.upperMessage", Reason: "expected string, got integer"},
+	}, errs)
+	assert.Contains(t, err.Error(), "Document is not valid:\n  mainSteps[0].timeoutSeconds: must be at least 0, got -1\n")
+}
+
+func TestValidateDocument_SchemaVersion(t *testing.T) {
+	err := ValidateDocument([]byte(`{"schemaVersion": "3.0", "mainSteps": []}`))
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), `schemaVersion: must be one of "1.0", "1.2", "2.0"`)
+	assert.Contains(t, err.Error(), "mainSteps: must have at least 1 items")
+
+	err = ValidateDocument([]byte(`{"description": "no version"}`))
+	assert.Equal(t, ValidationErrors{{Path: "schemaVersion", Reason: "is required"}}, err)
+}
+
+func TestValidateDocument_RequiredSection(t *testing.T) {
+	err := ValidateDocument([]byte(`{"schemaVersion": "2.2", "runtimeConfig": {}}`))
+	assert.Equal(t, ValidationErrors{{Path: "mainSteps", Reason: "is required for schema version 2.2"}}, err)
+
+	err = ValidateDocument([]byte(`{"schemaVersion": "1.2", "mainSteps": [{"action": "a", "name": "b"}]}`))
+	assert.Equal(t, ValidationErrors{{Path: "runtimeConfig", Reason: "is required for schema version 1.2"}}, err)
+}
+
+func TestValidateDocument_NotADocument(t *testing.T) {
+	err := ValidateDocument([]byte(`[1, 2]`))
+	assert.Equal(t, ValidationErrors{{Path: "", Reason: "expected object, got array"}}, err)
+	assert.Equal(t, "Document is not valid:\n  expected object, got array", err.Error())
+
+	err = ValidateDocument([]byte("a: [b"))
+	_, ok := err.(ValidationErrors)
+	assert.Error(t, err)
+	assert.False(t, ok)
+}
+
+func TestParseDocument_SchemaViolations(t *testing.T) {
+	docContent := DocContent{
+		SchemaVersion: "2.2",
+		MainSteps: []*contracts.InstancePluginConfig{
+			{Action: "aws:runShellScript", Name: "step"},
+			{Action: "", Name: "step"},
+		},
+	}
+
+	_, err := docContent.ParseDocument(log.NewMockLog(), contracts.DocumentInfo{}, DocumentParserInfo{OrchestrationDir: testOrchDir}, nil)
+
+	assert.Equal(t, ValidationErrors{
+		{Path: "mainSteps[1].action", Reason: "must not be empty"},
+		{Path: "mainSteps[1].name", Reason: `"step" is already used by mainSteps[0]`},
+	}, err)
+}
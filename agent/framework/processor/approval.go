@@ -0,0 +1,76 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package processor
+
+import (
+	"crypto"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/signature"
+)
+
+var readApproverKey = ioutil.ReadFile
+
+// verifyApproval checks the approval token of the document is a signature of its command ID by one of the approver keys
+func verifyApproval(approverKeys []string, docInfo contracts.DocumentInfo) error {
+	token := strings.TrimSpace(docInfo.ApprovalToken)
+	if token == "" {
+		return fmt.Errorf("the %v parameter is missing", appconfig.ApprovalTokenParameter)
+	}
+	if docInfo.CommandID == "" {
+		return fmt.Errorf("the document has no command ID to approve")
+	}
+	tokenSignature, err := base64.StdEncoding.DecodeString(token)
+	if err != nil {
+		return fmt.Errorf("the %v parameter is not base64 encoded: %v", appconfig.ApprovalTokenParameter, err)
+	}
+	if len(approverKeys) == 0 {
+		return fmt.Errorf("no approver keys are configured")
+	}
+
+	message := []byte(docInfo.CommandID)
+	var errs []string
+	for _, keyPath := range approverKeys {
+		key, err := loadApproverKey(keyPath)
+		if err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		if signature.Verify(key, message, tokenSignature) {
+			return nil
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("the signature does not match any approver key, %v", strings.Join(errs, ", "))
+	}
+	return fmt.Errorf("the signature does not match any approver key")
+}
+
+// loadApproverKey reads the PEM encoded public key or certificate of an approver
+func loadApproverKey(keyPath string) (crypto.PublicKey, error) {
+	content, err := readApproverKey(keyPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read approver key %v: %v", keyPath, err)
+	}
+	key, err := signature.ParsePublicKey(content)
+	if err != nil {
+		return nil, fmt.Errorf("approver key %v: %v", keyPath, err)
+	}
+	return key, nil
+}
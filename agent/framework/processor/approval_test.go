@@ -0,0 +1,98 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package processor
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"testing"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/stretchr/testify/assert"
+)
+
+func mockApproverKeys(t *testing.T, keys map[string]interface{}) func() {
+	encoded := make(map[string][]byte)
+	for keyPath, key := range keys {
+		der, err := x509.MarshalPKIXPublicKey(key)
+		assert.NoError(t, err)
+		encoded[keyPath] = pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+	}
+	original := readApproverKey
+	readApproverKey = func(keyPath string) ([]byte, error) {
+		if content, ok := encoded[keyPath]; ok {
+			return content, nil
+		}
+		return nil, fmt.Errorf("open %v: no such file or directory", keyPath)
+	}
+	return func() { readApproverKey = original }
+}
+
+func TestVerifyApproval(t *testing.T) {
+	edPublic, edPrivate, _ := ed25519.GenerateKey(rand.Reader)
+	ecPrivate, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	otherPublic, _, _ := ed25519.GenerateKey(rand.Reader)
+	defer mockApproverKeys(t, map[string]interface{}{
+		"/keys/ed25519.pem": edPublic,
+		"/keys/ecdsa.pem":   &ecPrivate.PublicKey,
+		"/keys/other.pem":   otherPublic,
+	})()
+
+	digest := sha256.Sum256([]byte("commandID"))
+	ecSignature, _ := ecdsa.SignASN1(rand.Reader, ecPrivate, digest[:])
+	edToken := base64.StdEncoding.EncodeToString(ed25519.Sign(edPrivate, []byte("commandID")))
+	ecToken := base64.StdEncoding.EncodeToString(ecSignature)
+
+	approved := func(keys []string, commandID, token string) error {
+		return verifyApproval(keys, contracts.DocumentInfo{CommandID: commandID, ApprovalToken: token})
+	}
+	assert.NoError(t, approved([]string{"/keys/other.pem", "/keys/ed25519.pem"}, "commandID", edToken))
+	assert.NoError(t, approved([]string{"/keys/missing.pem", "/keys/ecdsa.pem"}, "commandID", ecToken))
+	assert.Error(t, approved([]string{"/keys/ed25519.pem"}, "otherCommandID", edToken))
+	assert.Error(t, approved([]string{"/keys/other.pem"}, "commandID", edToken))
+	assert.Error(t, approved([]string{"/keys/ed25519.pem"}, "commandID", ""))
+	assert.Error(t, approved([]string{"/keys/ed25519.pem"}, "commandID", "not base64"))
+	assert.Error(t, approved(nil, "commandID", edToken))
+	assert.Contains(t, approved([]string{"/keys/missing.pem"}, "commandID", edToken).Error(), "/keys/missing.pem")
+}
+
+func TestCheckExecutionPolicy_ApprovalRequired(t *testing.T) {
+	public, private, _ := ed25519.GenerateKey(rand.Reader)
+	defer mockApproverKeys(t, map[string]interface{}{"/keys/approver.pem": public})()
+	policy := appconfig.ExecutionPolicyCfg{
+		ApprovalRequiredPlugins: []string{appconfig.PluginNameAwsRunPowerShellScript},
+		ApproverPublicKeys:      []string{"/keys/approver.pem"},
+	}
+
+	docState := policyDocState("Doc")
+	docState.DocumentInformation.CommandID = "commandID"
+	err := checkExecutionPolicy(policy, &docState)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), appconfig.ApprovalTokenParameter)
+
+	docState.DocumentInformation.ApprovalToken = base64.StdEncoding.EncodeToString(ed25519.Sign(private, []byte("commandID")))
+	assert.NoError(t, checkExecutionPolicy(policy, &docState))
+
+	policy.ApprovalRequiredPlugins = []string{"aws:configurePackage"}
+	docState.DocumentInformation.ApprovalToken = ""
+	assert.NoError(t, checkExecutionPolicy(policy, &docState))
+}
@@ -86,6 +86,8 @@ func run(context context.T,
 			}
 			resChan <- docResult
 			contracts.UpdateDocState(&docResult, state)
+			//persist every finished step, so a document interrupted by a restart resumes after it
+			docStore.Save(*state)
 		}
 	}(&docState)
 
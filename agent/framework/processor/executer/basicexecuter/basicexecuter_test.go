@@ -100,6 +100,10 @@ func testBasicExecuter(t *testing.T, testCase TestCase) {
 	resultState.InstancePluginsInformation[0].Result = *testCase.PluginResults["plugin1"]
 	dataStoreMock.On("Load").Return(state)
 	dataStoreMock.On("Save", resultState).Return()
+	//the state is also saved after each step finishes
+	stepState := resultState
+	stepState.DocumentInformation.DocumentStatus = contracts.ResultStatusInProgress
+	dataStoreMock.On("Save", stepState).Return()
 	pluginRunner = func(context context.T,
 		docState contracts.DocumentState,
 		resChan chan contracts.PluginResult,
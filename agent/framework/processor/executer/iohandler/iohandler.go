@@ -18,6 +18,7 @@ import (
 	"bytes"
 	"fmt"
 	"io"
+	"unicode/utf8"
 
 	"github.com/aws/amazon-ssm-agent/agent/agentlogstocloudwatch/cloudwatchlogspublisher"
 	"github.com/aws/amazon-ssm-agent/agent/contracts"
@@ -25,6 +26,7 @@ import (
 	"github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/iohandler/iomodule"
 	"github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/iohandler/multiwriter"
 	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/s3util"
 )
 
 const (
@@ -34,6 +36,9 @@ const (
 	truncateOut = "\n---Output truncated---"
 	// truncateError represents the string appended when error is truncated
 	truncateError = "\n---Error truncated----"
+	// deduplicatedOutputSampleSize is the size of the beginning of the output reported with its hash when the
+	// output has the hash the step expects
+	deduplicatedOutputSampleSize = 256
 )
 
 // PluginConfig is used for initializing plugins with default values
@@ -82,6 +87,8 @@ type IOHandler interface {
 	GetStatus() contracts.ResultStatus
 	GetStdout() string
 	GetStderr() string
+	GetStdoutStats() iomodule.StreamStats
+	GetStderrStats() iomodule.StreamStats
 	GetExitCode() int
 	GetStdoutWriter() multiwriter.DocumentIOMultiWriter
 	GetStderrWriter() multiwriter.DocumentIOMultiWriter
@@ -102,6 +109,11 @@ type DefaultIOHandler struct {
 	stdout   string
 	stderr   string
 	ioConfig contracts.IOConfiguration
+	// stdout and stderr only keep the ends of large output, the stats describe the complete streams
+	stdoutStats iomodule.StreamStats
+	stderrStats iomodule.StreamStats
+	// stdoutDeduplicated is set when stdout has the expected hash of the step and was not uploaded
+	stdoutDeduplicated bool
 	//refreshassociation and invoker write a different output rather than merging stdout and stderr
 	output interface{}
 
@@ -152,13 +164,18 @@ func (out *DefaultIOHandler) Init(log log.T, filePath ...string) {
 		OrchestrationDirectory: fullPath,
 		OutputS3BucketName:     out.ioConfig.OutputS3BucketName,
 		OutputS3KeyPrefix:      s3KeyPrefix,
+		OutputS3Settings:       s3util.ObjectSettings(out.ioConfig.OutputS3Settings),
 		LogGroupName:           out.ioConfig.CloudWatchConfig.LogGroupName,
 		LogStreamName:          stdOutLogStreamName,
+		ExpectedSha256:         out.ioConfig.ExpectedStdoutSha256,
+		Deduplicated:           &out.stdoutDeduplicated,
 	}
 
 	// Initialize console output module
 	stdoutConsole := iomodule.CommandOutput{
 		OutputString:           &out.stdout,
+		Stats:                  &out.stdoutStats,
+		CaptureBytes:           pluginConfig.MaxStdoutLength,
 		FileName:               pluginConfig.StdoutConsoleFileName,
 		OrchestrationDirectory: fullPath,
 	}
@@ -174,6 +191,7 @@ func (out *DefaultIOHandler) Init(log log.T, filePath ...string) {
 		OrchestrationDirectory: fullPath,
 		OutputS3BucketName:     out.ioConfig.OutputS3BucketName,
 		OutputS3KeyPrefix:      s3KeyPrefix,
+		OutputS3Settings:       s3util.ObjectSettings(out.ioConfig.OutputS3Settings),
 		LogGroupName:           out.ioConfig.CloudWatchConfig.LogGroupName,
 		LogStreamName:          stdErrLogStreamName,
 	}
@@ -181,6 +199,8 @@ func (out *DefaultIOHandler) Init(log log.T, filePath ...string) {
 	// Initialize console error module
 	stderrConsole := iomodule.CommandOutput{
 		OutputString:           &out.stderr,
+		Stats:                  &out.stderrStats,
+		CaptureBytes:           pluginConfig.MaxStdoutLength,
 		FileName:               pluginConfig.StderrConsoleFileName,
 		OrchestrationDirectory: fullPath,
 	}
@@ -223,6 +243,25 @@ func (out *DefaultIOHandler) Close(log log.T) {
 	if out.StderrWriter != nil {
 		out.StderrWriter.Close()
 	}
+
+	// the writers waited for the output modules, stdout is replaced once the file module compared its hash
+	if out.stdoutDeduplicated {
+		out.stdout = deduplicatedOutput(out.stdout, out.stdoutStats)
+	}
+}
+
+// deduplicatedOutput reports output that has the expected hash of the step as its hash, size and first bytes
+func deduplicatedOutput(stdout string, stats iomodule.StreamStats) string {
+	sample := stdout
+	if len(sample) > deduplicatedOutputSampleSize {
+		sample = sample[:deduplicatedOutputSampleSize]
+		// do not cut a multi-byte character
+		for len(sample) > 0 && !utf8.ValidString(sample) {
+			sample = sample[:len(sample)-1]
+		}
+	}
+	return fmt.Sprintf("Output has the expected sha256 %v (%v bytes) and was not uploaded. Sample:\n%v",
+		stats.Sha256, stats.TotalBytes, sample)
 }
 
 // String returns the output by concatenating stdout and stderr
@@ -259,6 +298,16 @@ func (out DefaultIOHandler) GetStderr() string {
 	return out.stderr
 }
 
+// GetStdoutStats returns the size and checksum of the complete stdout
+func (out DefaultIOHandler) GetStdoutStats() iomodule.StreamStats {
+	return out.stdoutStats
+}
+
+// GetStderrStats returns the size and checksum of the complete stderr
+func (out DefaultIOHandler) GetStderrStats() iomodule.StreamStats {
+	return out.stderrStats
+}
+
 // GetIOConfig returns the io configuration
 func (out DefaultIOHandler) GetIOConfig() contracts.IOConfiguration {
 	return out.ioConfig
@@ -303,6 +352,7 @@ func (out *DefaultIOHandler) SetOutput(output interface{}) {
 func (out *DefaultIOHandler) Merge(log log.T, mergeOutput *DefaultIOHandler) {
 
 	// Append Info
+	out.stdoutStats = mergeStats(out.stdout, out.stdoutStats, mergeOutput.GetStdout(), mergeOutput.GetStdoutStats())
 	var stdoutBuffer bytes.Buffer
 	if len(out.stdout) > 0 {
 		stdoutBuffer.WriteString(out.stdout + "\n")
@@ -311,6 +361,7 @@ func (out *DefaultIOHandler) Merge(log log.T, mergeOutput *DefaultIOHandler) {
 	out.stdout = stdoutBuffer.String()
 
 	// Append Error
+	out.stderrStats = mergeStats(out.stderr, out.stderrStats, mergeOutput.GetStderr(), mergeOutput.GetStderrStats())
 	var stderrBuffer bytes.Buffer
 	if len(out.stderr) > 0 {
 		stderrBuffer.WriteString(out.stderr + "\n")
@@ -324,6 +375,24 @@ func (out *DefaultIOHandler) Merge(log log.T, mergeOutput *DefaultIOHandler) {
 	out.Status = contracts.MergeResultStatus(out.Status, mergeOutput.GetStatus())
 }
 
+// mergeStats returns the stats of merged streams when one of them was captured partially, the checksum of the
+// merged complete streams is unknown then
+func mergeStats(output string, stats iomodule.StreamStats, mergeOutput string, mergeStats iomodule.StreamStats) iomodule.StreamStats {
+	if stats.TotalBytes <= len(output) && mergeStats.TotalBytes <= len(mergeOutput) {
+		return iomodule.StreamStats{}
+	}
+	total := len(mergeOutput)
+	if mergeStats.TotalBytes > total {
+		total = mergeStats.TotalBytes
+	}
+	if stats.TotalBytes > len(output) {
+		total += stats.TotalBytes + 1
+	} else if len(output) > 0 {
+		total += len(output) + 1
+	}
+	return iomodule.StreamStats{TotalBytes: total}
+}
+
 // MarkAsFailed Failed marks plugin as Failed
 func (out *DefaultIOHandler) MarkAsFailed(err error) {
 	// Update the error exit code
@@ -14,16 +14,23 @@
 package iohandler
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
 	"testing"
 
 	"sync"
 	"time"
 
 	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/iohandler/iomodule"
 	iomodulemock "github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/iohandler/iomodule/mock"
 	multiwritermock "github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/iohandler/multiwriter/mock"
 	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/platform"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 )
@@ -174,3 +181,55 @@ func TestAppendFormat(t *testing.T) {
 	assert.Contains(t, output.GetStdout(), testStringFormatted)
 	assert.Contains(t, output.GetStderr(), testStringFormatted)
 }
+
+func TestMergeKeepsTheSizeOfCapturedOutput(t *testing.T) {
+	output := DefaultIOHandler{stdout: "first", stderr: "error"}
+	captured := DefaultIOHandler{stdout: "head...tail", stdoutStats: iomodule.StreamStats{TotalBytes: 1000, Sha256: "5c6e2f"}}
+
+	output.Merge(log.NewMockLog(), &captured)
+
+	assert.Equal(t, "first\nhead...tail", output.GetStdout())
+	assert.Equal(t, iomodule.StreamStats{TotalBytes: len("first") + 1 + 1000}, output.GetStdoutStats())
+	assert.Equal(t, iomodule.StreamStats{}, output.GetStderrStats())
+}
+
+func TestStdoutWithTheExpectedHashIsDeduplicated(t *testing.T) {
+	// the file module creates a CloudWatch client, which must not query the instance metadata for the region
+	platform.SetRegion("us-east-1")
+	orchestrationDir, err := ioutil.TempDir("", "iohandler")
+	assert.NoError(t, err)
+	defer os.RemoveAll(orchestrationDir)
+
+	compliant := strings.Repeat("compliant\n", 100)
+	checksum := sha256.Sum256([]byte(compliant))
+	expected := hex.EncodeToString(checksum[:])
+
+	for _, test := range []struct {
+		stdout       string
+		deduplicated bool
+	}{
+		{compliant, true},
+		{"drifted\n", false},
+	} {
+		output := NewDefaultIOHandler(log.NewMockLog(), contracts.IOConfiguration{
+			OrchestrationDirectory: orchestrationDir,
+			ExpectedStdoutSha256:   expected,
+		})
+		output.Init(log.NewMockLog(), "aws:runShellScript", fmt.Sprint(test.deduplicated))
+		output.GetStdoutWriter().WriteString(test.stdout)
+		output.Close(log.NewMockLog())
+
+		if test.deduplicated {
+			assert.Equal(t, fmt.Sprintf("Output has the expected sha256 %v (1000 bytes) and was not uploaded. Sample:\n%v",
+				expected, compliant[:deduplicatedOutputSampleSize]), output.GetStdout())
+		} else {
+			assert.Equal(t, test.stdout, output.GetStdout())
+		}
+	}
+}
+
+func TestDeduplicatedOutputDoesNotCutCharacters(t *testing.T) {
+	stdout := strings.Repeat("a", deduplicatedOutputSampleSize-1) + "é"
+	report := deduplicatedOutput(stdout, iomodule.StreamStats{TotalBytes: len(stdout), Sha256: "5c6e2f"})
+	assert.True(t, strings.HasSuffix(report, "Sample:\n"+strings.Repeat("a", deduplicatedOutputSampleSize-1)))
+}
@@ -0,0 +1,96 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package iomodule
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+)
+
+const (
+	// copyBufferSize is the size of the chunks output is copied in
+	copyBufferSize = 32 * 1024
+
+	// captureGapMarker separates the head and the tail of a capture that dropped the middle of its stream
+	captureGapMarker = "\n---Output truncated---\n"
+)
+
+// StreamStats describes a complete output stream, of which only the ends may be kept in memory
+type StreamStats struct {
+	TotalBytes int
+	Sha256     string
+}
+
+// boundedCapture keeps the first and the last limit bytes written to it, the middle of larger streams is dropped.
+// It hashes everything written so the complete stream can still be described. A limit of zero keeps everything.
+type boundedCapture struct {
+	limit int
+	head  []byte
+	tail  []byte
+	total int
+	hash  hash.Hash
+}
+
+func newBoundedCapture(limit int) *boundedCapture {
+	return &boundedCapture{limit: limit, hash: sha256.New()}
+}
+
+// Write captures p, it never fails
+func (c *boundedCapture) Write(p []byte) (int, error) {
+	c.hash.Write(p)
+	c.total += len(p)
+
+	rest := p
+	if c.limit <= 0 || len(c.head) < c.limit {
+		n := len(rest)
+		if c.limit > 0 && n > c.limit-len(c.head) {
+			n = c.limit - len(c.head)
+		}
+		c.head = append(c.head, rest[:n]...)
+		rest = rest[n:]
+	}
+	if len(rest) == 0 {
+		return len(p), nil
+	}
+
+	c.tail = append(c.tail, rest...)
+	// the tail grows up to twice the limit before it is trimmed, so trimming copies are amortized
+	if len(c.tail) > 2*c.limit {
+		c.tail = append(c.tail[:0], c.tail[len(c.tail)-c.limit:]...)
+	}
+	return len(p), nil
+}
+
+// truncated returns true when the middle of the stream was dropped
+func (c *boundedCapture) truncated() bool {
+	return c.total > len(c.head)+len(c.tail)
+}
+
+// String returns the captured stream, with a marker where its middle was dropped
+func (c *boundedCapture) String() string {
+	if !c.truncated() {
+		return string(c.head) + string(c.tail)
+	}
+	tail := c.tail
+	if len(tail) > c.limit {
+		tail = tail[len(tail)-c.limit:]
+	}
+	return string(c.head) + captureGapMarker + string(tail)
+}
+
+// Stats returns the size and checksum of everything written to the capture
+func (c *boundedCapture) Stats() StreamStats {
+	return StreamStats{TotalBytes: c.total, Sha256: hex.EncodeToString(c.hash.Sum(nil))}
+}
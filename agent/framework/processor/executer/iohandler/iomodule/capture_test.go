@@ -0,0 +1,89 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package iomodule
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBoundedCaptureKeepsShortStreams(t *testing.T) {
+	capture := newBoundedCapture(10)
+	capture.Write([]byte("hello "))
+	capture.Write([]byte("world, hello"))
+
+	assert.False(t, capture.truncated())
+	assert.Equal(t, "hello world, hello", capture.String())
+}
+
+func TestBoundedCaptureKeepsTheEndsOfLongStreams(t *testing.T) {
+	capture := newBoundedCapture(4)
+	for _, chunk := range []string{"head", "-middle-", "more middle", "-", "tail"} {
+		capture.Write([]byte(chunk))
+	}
+
+	content := "head-middle-more middle-tail"
+	checksum := sha256.Sum256([]byte(content))
+	assert.True(t, capture.truncated())
+	assert.Equal(t, "head"+captureGapMarker+"tail", capture.String())
+	assert.Equal(t, StreamStats{TotalBytes: len(content), Sha256: hex.EncodeToString(checksum[:])}, capture.Stats())
+}
+
+func TestBoundedCaptureWithoutLimit(t *testing.T) {
+	capture := newBoundedCapture(0)
+	content := strings.Repeat("output ", 1000)
+	capture.Write([]byte(content))
+
+	assert.Equal(t, content, capture.String())
+}
+
+func TestCommandOutputCapturesTheEndsOfLargeOutput(t *testing.T) {
+	dir, err := ioutil.TempDir("", "commandoutput")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	var stdout string
+	var stats StreamStats
+	console := CommandOutput{OutputString: &stdout, Stats: &stats, CaptureBytes: 1024, FileName: "stdoutConsole", OrchestrationDirectory: dir}
+
+	r, w := io.Pipe()
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		console.Read(logger, r)
+	}()
+	line := strings.Repeat("x", 99) + "\n"
+	for i := 0; i < 10000; i++ {
+		w.Write([]byte(line))
+	}
+	w.Close()
+	wg.Wait()
+
+	assert.Equal(t, 10000*len(line), stats.TotalBytes)
+	assert.Equal(t, 2*1024+len(captureGapMarker), len(stdout))
+	// the console file has the complete output
+	info, err := os.Stat(filepath.Join(dir, "stdoutConsole"))
+	assert.Nil(t, err)
+	assert.Equal(t, int64(stats.TotalBytes), info.Size())
+}
@@ -14,12 +14,9 @@
 package iomodule
 
 import (
-	"bufio"
 	"io"
-
-	"path/filepath"
-
 	"os"
+	"path/filepath"
 
 	"github.com/aws/amazon-ssm-agent/agent/appconfig"
 	"github.com/aws/amazon-ssm-agent/agent/fileutil"
@@ -27,8 +24,12 @@ import (
 )
 
 // CommandOutput handles writing output to a string.
+// The complete output is written to the console file, the string keeps its first and last CaptureBytes
+// so that large output does not have to fit in memory. A CaptureBytes of zero keeps the complete output.
 type CommandOutput struct {
 	OutputString           *string
+	Stats                  *StreamStats
+	CaptureBytes           int
 	FileName               string
 	OrchestrationDirectory string
 }
@@ -50,31 +51,47 @@ func (c CommandOutput) Read(log log.T, reader *io.PipeReader) {
 
 	defer fileWriter.Close()
 
-	// Read byte by byte and write to file
-	scanner := bufio.NewScanner(reader)
-	scanner.Split(bufio.ScanBytes)
-	for scanner.Scan() {
-		if _, err = fileWriter.Write([]byte(scanner.Text())); err != nil {
-			log.Errorf("Failed to write the message to stdoutConsoleFile: %v", err)
-		}
+	// the output of a step resumed after a reboot continues the existing file
+	capture := newBoundedCapture(c.CaptureBytes)
+	if existing, err := os.Open(filePath); err == nil {
+		io.Copy(capture, existing)
+		existing.Close()
 	}
 
-	// Check if scanner exited because of an error
-	if err := scanner.Err(); err != nil {
+	if err = copyOutput(log, fileWriter, capture, reader); err != nil {
 		log.Error("Error with the scanner while reading the stream")
 	}
 
-	fi, err := fileWriter.Stat()
-	if err != nil {
-		log.Errorf("Failed to get file stat: %v", err)
-		return
+	// Write output to console
+	if capture.total > 0 {
+		*c.OutputString = capture.String()
+	}
+	if c.Stats != nil {
+		*c.Stats = capture.Stats()
 	}
+}
 
-	// Write output to console
-	if fi.Size() > 0 {
-		*c.OutputString, err = fileutil.ReadAllText(filePath)
+// copyOutput copies the stream in chunks to the file and to the capture, failed writes to the file are logged
+// without stopping the copy so the capture still receives the output
+func copyOutput(log log.T, file io.Writer, capture io.Writer, reader io.Reader) error {
+	buffer := make([]byte, copyBufferSize)
+	fileFailed := false
+	for {
+		n, err := reader.Read(buffer)
+		if n > 0 {
+			if _, writeErr := file.Write(buffer[:n]); writeErr != nil && !fileFailed {
+				log.Errorf("Failed to write the message to the output file: %v", writeErr)
+				fileFailed = true
+			}
+			if capture != nil {
+				capture.Write(buffer[:n])
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
 		if err != nil {
-			log.Errorf("Error reading %v at path %v", c.FileName, filePath)
+			return err
 		}
 	}
 }
@@ -14,7 +14,8 @@
 package iomodule
 
 import (
-	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
 	"io"
 	"os"
 	"path/filepath"
@@ -31,14 +32,19 @@ const (
 	maxCloudWatchUploadRetry = 5
 )
 
-// File handles writing to an output file and upload to s3 and cloudWatch
+// File handles writing to an output file and upload to s3, the output webhook and cloudWatch.
+// With an ExpectedSha256 the output is only uploaded once complete, and not at all when it has the expected hash,
+// which is then recorded in Deduplicated.
 type File struct {
 	FileName               string
 	OrchestrationDirectory string
 	OutputS3BucketName     string
 	OutputS3KeyPrefix      string
+	OutputS3Settings       s3util.ObjectSettings
 	LogGroupName           string
 	LogStreamName          string
+	ExpectedSha256         string
+	Deduplicated           *bool
 }
 
 // Read reads from the stream and writes to the output file, s3 and CloudWatchLogs.
@@ -64,23 +70,14 @@ func (file File) Read(log log.T, reader *io.PipeReader) {
 	defer fileWriter.Close()
 
 	cwl := cloudwatchlogspublisher.NewCloudWatchLogsService(log)
-	if file.LogGroupName != "" {
+	if file.LogGroupName != "" && file.ExpectedSha256 == "" {
 		log.Debugf("Received CloudWatch Configs: LogGroupName: %s\n, LogStreamName: %s\n", file.LogGroupName, file.LogStreamName)
 		//Start CWL logging on different go routine
 		go cwl.StreamData(log, file.LogGroupName, file.LogStreamName, filePath, false, false)
 	}
 
-	// Read byte by byte and write to file
-	scanner := bufio.NewScanner(reader)
-	scanner.Split(bufio.ScanBytes)
-	for scanner.Scan() {
-		if _, err = fileWriter.Write([]byte(scanner.Text())); err != nil {
-			log.Errorf("Failed to write the message to stdout: %v", err)
-		}
-	}
-
-	// Check if scanner exited because of an error
-	if err := scanner.Err(); err != nil {
+	// Copy the stream to the file in chunks, it is uploaded from the file so output never has to fit in memory
+	if err = copyOutput(log, fileWriter, nil, reader); err != nil {
 		log.Error("Error with the scanner while reading the stream")
 	}
 
@@ -90,17 +87,33 @@ func (file File) Read(log log.T, reader *io.PipeReader) {
 		return
 	}
 
-	// Upload output file to S3
-	if file.OutputS3BucketName != "" && fi.Size() > 0 {
-		s3Key := fileutil.BuildS3Path(file.OutputS3KeyPrefix, file.FileName)
-		if err := s3util.NewAmazonS3Util(log, file.OutputS3BucketName).S3Upload(log, file.OutputS3BucketName, s3Key, filePath); err != nil {
-			log.Errorf("Failed to upload the output to s3: %v", err)
+	if file.ExpectedSha256 != "" {
+		if fileSha256(filePath) == file.ExpectedSha256 {
+			log.Debugf("Output %v has the expected sha256, it is not uploaded", filePath)
+			if file.Deduplicated != nil {
+				*file.Deduplicated = true
+			}
+			return
+		}
+		if file.LogGroupName != "" {
+			// the complete file is streamed at once since it could not be streamed before its hash was known
+			cwl.StreamData(log, file.LogGroupName, file.LogStreamName, filePath, true, false)
+		}
+	}
+
+	// Upload output file to S3, or the output webhook when one is configured
+	if fi.Size() > 0 {
+		if uploader := newOutputUploader(log, file.OutputS3BucketName, file.OutputS3Settings); uploader != nil {
+			outputKey := fileutil.BuildS3Path(file.OutputS3KeyPrefix, file.FileName)
+			if err := uploader.Upload(log, outputKey, filePath); err != nil {
+				log.Errorf("Failed to upload the output: %v", err)
+			}
 		}
 	}
 
 	//Block main thread until CloudWatchLogs uploading is complete or until maxCloudWatchUploadRetry is reached
 	//TODO Add unit test to test maxRetry logic
-	if file.LogGroupName != "" {
+	if file.LogGroupName != "" && file.ExpectedSha256 == "" {
 		cwl.IsFileComplete = true
 		retry := 0
 		for !cwl.IsUploadComplete && retry < maxCloudWatchUploadRetry {
@@ -109,3 +122,17 @@ func (file File) Read(log log.T, reader *io.PipeReader) {
 		}
 	}
 }
+
+// fileSha256 returns the hex SHA256 of the file, the output of a step resumed after a reboot continues the file
+func fileSha256(filePath string) string {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	hash := sha256.New()
+	if _, err = io.Copy(hash, f); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
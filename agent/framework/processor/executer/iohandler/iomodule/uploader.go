@@ -0,0 +1,52 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package iomodule
+
+import (
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/s3util"
+)
+
+// OutputUploader uploads an output file to the output backend configured for the command
+type OutputUploader interface {
+	Upload(log log.T, key string, filePath string) error
+}
+
+// s3OutputUploader uploads the output to the bucket of the command with the object settings of the document
+type s3OutputUploader struct {
+	bucketName string
+	settings   s3util.ObjectSettings
+}
+
+// Upload uploads the output file to the object key in the bucket
+func (u s3OutputUploader) Upload(log log.T, key string, filePath string) error {
+	return s3util.NewAmazonS3UtilWithSettings(log, u.bucketName, u.settings).S3Upload(log, u.bucketName, key, filePath)
+}
+
+var getAppConfig = appconfig.Config
+
+// newOutputUploader returns the webhook uploader when an output webhook is configured, since it replaces S3 for
+// instances that cannot write to buckets, otherwise the S3 uploader of the bucket. It returns nil if output is not uploaded.
+var newOutputUploader = func(log log.T, bucketName string, settings s3util.ObjectSettings) OutputUploader {
+	if config, err := getAppConfig(false); err != nil {
+		log.Warnf("Failed to load appconfig, using S3 for output upload: %v", err)
+	} else if config.Output.WebhookUrl != "" {
+		return newWebhookOutputUploader(config.Output)
+	}
+	if bucketName == "" {
+		return nil
+	}
+	return s3OutputUploader{bucketName: bucketName, settings: settings}
+}
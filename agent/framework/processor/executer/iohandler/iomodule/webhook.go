@@ -0,0 +1,169 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package iomodule
+
+import (
+	"compress/gzip"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/platform"
+	"github.com/aws/amazon-ssm-agent/agent/proxyconfig"
+)
+
+// Headers sent with the output posted to the webhook.
+// The signature is the hex HMAC SHA256 of the timestamp, the key and the gzipped body separated by new lines.
+const (
+	webhookKeyHeader        = "X-Ssm-Output-Key"
+	webhookInstanceIdHeader = "X-Ssm-Instance-Id"
+	webhookTimestampHeader  = "X-Ssm-Timestamp"
+	webhookSignatureHeader  = "X-Ssm-Signature"
+
+	maxWebhookUploadAttempts = 4
+)
+
+var getInstanceID = platform.InstanceID
+
+// webhookOutputUploader posts gzipped output to a customer provided https webhook
+type webhookOutputUploader struct {
+	url        string
+	signingKey string
+	client     *http.Client
+}
+
+func newWebhookOutputUploader(config appconfig.OutputCfg) *webhookOutputUploader {
+	return &webhookOutputUploader{
+		url:        config.WebhookUrl,
+		signingKey: config.WebhookSigningKey,
+		client: &http.Client{
+			Transport: proxyconfig.NewTransport(""),
+			Timeout:   time.Duration(config.WebhookTimeoutSeconds) * time.Second,
+		},
+	}
+}
+
+// Upload posts the gzipped output file, retrying server errors and failed requests with exponential backoff.
+// The output is compressed to a file next to it so that large output does not have to fit in memory.
+func (u *webhookOutputUploader) Upload(log log.T, key string, filePath string) (err error) {
+	body, err := gzipFile(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to compress output %v: %v", filePath, err)
+	}
+	defer func() {
+		body.Close()
+		os.Remove(body.Name())
+	}()
+
+	log.Infof("Posting %v to output webhook %v", filePath, u.url)
+	for attempt := 1; attempt <= maxWebhookUploadAttempts; attempt++ {
+		var retry bool
+		if retry, err = u.post(key, body); err == nil {
+			log.Infof("Successfully posted output %v to webhook", key)
+			return nil
+		}
+		log.Errorf("Attempt %v: Failed posting %v to output webhook err:%v", attempt, filePath, err)
+		if !retry {
+			break
+		}
+		time.Sleep(time.Duration(math.Pow(2, float64(attempt))*100) * time.Millisecond)
+	}
+	return err
+}
+
+// post sends the output once and returns whether a failed request can be retried
+func (u *webhookOutputUploader) post(key string, body *os.File) (retry bool, err error) {
+	info, err := body.Stat()
+	if err != nil {
+		return false, err
+	}
+	// the section reader is not closed by the client, so the file can be sent again
+	request, err := http.NewRequest(http.MethodPost, u.url, io.NewSectionReader(body, 0, info.Size()))
+	if err != nil {
+		return false, err
+	}
+	request.ContentLength = info.Size()
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	request.Header.Set("Content-Type", "text/plain")
+	request.Header.Set("Content-Encoding", "gzip")
+	request.Header.Set(webhookKeyHeader, key)
+	request.Header.Set(webhookTimestampHeader, timestamp)
+	if instanceID, err := getInstanceID(); err == nil {
+		request.Header.Set(webhookInstanceIdHeader, instanceID)
+	}
+	if u.signingKey != "" {
+		signature, err := signWebhookPayload(u.signingKey, timestamp, key, io.NewSectionReader(body, 0, info.Size()))
+		if err != nil {
+			return false, err
+		}
+		request.Header.Set(webhookSignatureHeader, "sha256="+signature)
+	}
+
+	response, err := u.client.Do(request)
+	if err != nil {
+		return true, err
+	}
+	defer response.Body.Close()
+	io.Copy(ioutil.Discard, response.Body)
+
+	if response.StatusCode >= 200 && response.StatusCode < 300 {
+		return false, nil
+	}
+	return response.StatusCode >= 500 || response.StatusCode == http.StatusTooManyRequests,
+		fmt.Errorf("webhook returned status %v", response.Status)
+}
+
+// signWebhookPayload returns the hex HMAC SHA256 the webhook uses to verify the output was sent by the agent
+func signWebhookPayload(signingKey string, timestamp string, key string, body io.Reader) (string, error) {
+	mac := hmac.New(sha256.New, []byte(signingKey))
+	mac.Write([]byte(timestamp + "\n" + key + "\n"))
+	if _, err := io.Copy(mac, body); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+// gzipFile compresses the file to a temporary file in the same directory, which the caller removes
+func gzipFile(filePath string) (compressed *os.File, err error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	if compressed, err = ioutil.TempFile(filepath.Dir(filePath), filepath.Base(filePath)+".gz"); err != nil {
+		return nil, err
+	}
+	writer := gzip.NewWriter(compressed)
+	if _, err = io.Copy(writer, file); err == nil {
+		err = writer.Close()
+	}
+	if err != nil {
+		compressed.Close()
+		os.Remove(compressed.Name())
+		return nil, err
+	}
+	return compressed, nil
+}
@@ -0,0 +1,131 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package iomodule
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/platform"
+	"github.com/aws/amazon-ssm-agent/agent/s3util"
+	"github.com/stretchr/testify/assert"
+)
+
+func writeWebhookTestOutput(t *testing.T, content string) string {
+	dir, err := ioutil.TempDir("", "webhook")
+	assert.Nil(t, err)
+	filePath := filepath.Join(dir, "stdout")
+	assert.Nil(t, ioutil.WriteFile(filePath, []byte(content), appconfig.ReadWriteAccess))
+	return filePath
+}
+
+func TestWebhookOutputUploaderPostsSignedGzippedOutput(t *testing.T) {
+	getInstanceID = func() (string, error) { return "i-0123456789abcdef0", nil }
+	defer func() { getInstanceID = platform.InstanceID }()
+
+	var received string
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		assert.Nil(t, err)
+		assert.Equal(t, "gzip", r.Header.Get("Content-Encoding"))
+		assert.Equal(t, "prefix/stdout", r.Header.Get(webhookKeyHeader))
+		assert.Equal(t, "i-0123456789abcdef0", r.Header.Get(webhookInstanceIdHeader))
+		signature, err := signWebhookPayload("secret", r.Header.Get(webhookTimestampHeader), "prefix/stdout", bytes.NewReader(body))
+		assert.Nil(t, err)
+		assert.Equal(t, "sha256="+signature, r.Header.Get(webhookSignatureHeader))
+
+		reader, err := gzip.NewReader(bytes.NewReader(body))
+		assert.Nil(t, err)
+		content, err := ioutil.ReadAll(reader)
+		assert.Nil(t, err)
+		received = string(content)
+	}))
+	defer server.Close()
+
+	filePath := writeWebhookTestOutput(t, "command output")
+	defer os.RemoveAll(filepath.Dir(filePath))
+
+	uploader := &webhookOutputUploader{url: server.URL, signingKey: "secret", client: server.Client()}
+	assert.Nil(t, uploader.Upload(log.NewMockLog(), "prefix/stdout", filePath))
+	assert.Equal(t, "command output", received)
+}
+
+func TestWebhookOutputUploaderRetriesWithTheWholeBody(t *testing.T) {
+	var sizes []int
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		sizes = append(sizes, len(body))
+		if len(sizes) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	filePath := writeWebhookTestOutput(t, "command output")
+	defer os.RemoveAll(filepath.Dir(filePath))
+
+	uploader := &webhookOutputUploader{url: server.URL, client: server.Client()}
+	assert.Nil(t, uploader.Upload(log.NewMockLog(), "prefix/stdout", filePath))
+	assert.Len(t, sizes, 2)
+	assert.Equal(t, sizes[0], sizes[1])
+	assert.NotZero(t, sizes[1])
+
+	// the compressed copy of the output is removed
+	files, _ := ioutil.ReadDir(filepath.Dir(filePath))
+	assert.Len(t, files, 1)
+}
+
+func TestWebhookOutputUploaderDoesNotRetryClientErrors(t *testing.T) {
+	requests := 0
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	filePath := writeWebhookTestOutput(t, "command output")
+	defer os.RemoveAll(filepath.Dir(filePath))
+
+	uploader := &webhookOutputUploader{url: server.URL, client: server.Client()}
+	assert.NotNil(t, uploader.Upload(log.NewMockLog(), "prefix/stdout", filePath))
+	assert.Equal(t, 1, requests)
+}
+
+func TestNewOutputUploaderPrefersWebhook(t *testing.T) {
+	webhookUrl := ""
+	getAppConfig = func(bool) (appconfig.SsmagentConfig, error) {
+		config := appconfig.DefaultConfig()
+		config.Output.WebhookUrl = webhookUrl
+		return config, nil
+	}
+	defer func() { getAppConfig = appconfig.Config }()
+
+	settings := s3util.ObjectSettings{ServerSideEncryption: "aws:kms"}
+	assert.Nil(t, newOutputUploader(log.NewMockLog(), "", settings))
+	assert.Equal(t, s3OutputUploader{bucketName: "output-bucket", settings: settings},
+		newOutputUploader(log.NewMockLog(), "output-bucket", settings))
+
+	webhookUrl = "https://hooks.example.com/ssm"
+	uploader, ok := newOutputUploader(log.NewMockLog(), "output-bucket", settings).(*webhookOutputUploader)
+	assert.True(t, ok)
+	assert.Equal(t, "https://hooks.example.com/ssm", uploader.url)
+}
@@ -116,6 +116,18 @@ func (m *MockIOHandler) GetStderr() string {
 	return args.String(0)
 }
 
+// GetStdoutStats is a mocked method that just returns what mock tells it to.
+func (m *MockIOHandler) GetStdoutStats() iomodule.StreamStats {
+	args := m.Called()
+	return args.Get(0).(iomodule.StreamStats)
+}
+
+// GetStderrStats is a mocked method that just returns what mock tells it to.
+func (m *MockIOHandler) GetStderrStats() iomodule.StreamStats {
+	args := m.Called()
+	return args.Get(0).(iomodule.StreamStats)
+}
+
 // GetExitCode is a mocked method that just returns what mock tells it to.
 func (m *MockIOHandler) GetExitCode() int {
 	args := m.Called()
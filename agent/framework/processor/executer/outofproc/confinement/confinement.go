@@ -0,0 +1,104 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+
+// Package confinement launches document and session workers in a SELinux domain or AppArmor profile.
+// The transition is done by wrapping the worker command with runcon or aa-exec.
+package confinement
+
+import (
+	"fmt"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+)
+
+const (
+	runcon = "runcon"
+	aaExec = "aa-exec"
+
+	// argumentSeparator ends the options of the wrapper, the worker command follows it
+	argumentSeparator = "--"
+)
+
+var getAppConfig = appconfig.Config
+
+// Context is the SELinux domain or AppArmor profile a worker is confined to
+type Context struct {
+	SELinuxDomain   string
+	AppArmorProfile string
+}
+
+// TransitionError is returned when a worker cannot be started in its execution context,
+// the document must fail rather than run unconfined
+type TransitionError struct {
+	Context Context
+	Err     error
+}
+
+func (e *TransitionError) Error() string {
+	return fmt.Sprintf("failed to transition worker to execution context %v: %v", Describe(e.Context), e.Err)
+}
+
+// Resolve returns the execution context of a worker, the fields set by the document override the agent config
+func Resolve(documentContext Context) (context Context) {
+	config, err := getAppConfig(false)
+	if err == nil {
+		context.SELinuxDomain = config.ExecutionContext.SELinuxDomain
+		context.AppArmorProfile = config.ExecutionContext.AppArmorProfile
+		if config.ExecutionContext.DenyDocumentOverride {
+			return
+		}
+	}
+	if documentContext.SELinuxDomain != "" {
+		context.SELinuxDomain = documentContext.SELinuxDomain
+	}
+	if documentContext.AppArmorProfile != "" {
+		context.AppArmorProfile = documentContext.AppArmorProfile
+	}
+	return
+}
+
+// Wrap returns the command that starts the worker in the execution context,
+// the worker command is returned unchanged if no context is set
+func Wrap(context Context, name string, argv []string) (string, []string, error) {
+	if context.SELinuxDomain == "" && context.AppArmorProfile == "" {
+		return name, argv, nil
+	}
+	runconPath, aaExecPath, err := check(context)
+	if err != nil {
+		return "", nil, &TransitionError{Context: context, Err: err}
+	}
+
+	if context.SELinuxDomain != "" {
+		argv = append([]string{"-t", context.SELinuxDomain, argumentSeparator, name}, argv...)
+		name = runconPath
+	}
+	if context.AppArmorProfile != "" {
+		argv = append([]string{"-p", context.AppArmorProfile, argumentSeparator, name}, argv...)
+		name = aaExecPath
+	}
+	return name, argv, nil
+}
+
+// Describe returns the execution context as it is shown in logs and document output
+func Describe(context Context) string {
+	switch {
+	case context.SELinuxDomain != "" && context.AppArmorProfile != "":
+		return fmt.Sprintf("SELinux domain %v and AppArmor profile %v", context.SELinuxDomain, context.AppArmorProfile)
+	case context.SELinuxDomain != "":
+		return fmt.Sprintf("SELinux domain %v", context.SELinuxDomain)
+	case context.AppArmorProfile != "":
+		return fmt.Sprintf("AppArmor profile %v", context.AppArmorProfile)
+	}
+	return "unconfined"
+}
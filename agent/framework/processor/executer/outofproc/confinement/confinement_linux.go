@@ -0,0 +1,72 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+
+// +build linux
+
+package confinement
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+)
+
+var (
+	seLinuxEnforceFile   = "/sys/fs/selinux/enforce"
+	appArmorProfilesFile = "/sys/kernel/security/apparmor/profiles"
+)
+
+var lookPath = exec.LookPath
+
+// check verifies the security modules needed by the execution context are enabled and the AppArmor profile is loaded,
+// and returns the paths of the wrappers. An invalid SELinux domain is only detected by runcon when the worker is started.
+func check(context Context) (runconPath string, aaExecPath string, err error) {
+	if context.SELinuxDomain != "" {
+		if _, err = os.Stat(seLinuxEnforceFile); err != nil {
+			return "", "", fmt.Errorf("SELinux is not enabled")
+		}
+		if runconPath, err = lookPath(runcon); err != nil {
+			return "", "", fmt.Errorf("%v is not installed", runcon)
+		}
+	}
+	if context.AppArmorProfile != "" {
+		if err = checkAppArmorProfile(context.AppArmorProfile); err != nil {
+			return "", "", err
+		}
+		if aaExecPath, err = lookPath(aaExec); err != nil {
+			return "", "", fmt.Errorf("%v is not installed", aaExec)
+		}
+	}
+	return runconPath, aaExecPath, nil
+}
+
+// checkAppArmorProfile looks for the profile in the list of loaded profiles, each line is "<profile> (<mode>)"
+func checkAppArmorProfile(profile string) error {
+	file, err := os.Open(appArmorProfilesFile)
+	if err != nil {
+		return fmt.Errorf("AppArmor is not enabled")
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if strings.HasPrefix(scanner.Text(), profile+" (") {
+			return nil
+		}
+	}
+	return fmt.Errorf("AppArmor profile %v is not loaded", profile)
+}
@@ -0,0 +1,63 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+
+// +build linux
+
+package confinement
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWrap(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "confinement")
+	defer os.RemoveAll(dir)
+	seLinuxEnforceFile = filepath.Join(dir, "enforce")
+	appArmorProfilesFile = filepath.Join(dir, "profiles")
+	ioutil.WriteFile(seLinuxEnforceFile, []byte("1"), 0600)
+	ioutil.WriteFile(appArmorProfilesFile, []byte("/usr/sbin/ntpd (enforce)\nssm-worker (enforce)\n"), 0600)
+	lookPath = func(file string) (string, error) { return "/usr/bin/" + file, nil }
+
+	name, argv, err := Wrap(Context{SELinuxDomain: "ssm_worker_t"}, "/usr/bin/ssm-document-worker", []string{"doc", "i-123"})
+	assert.NoError(t, err)
+	assert.Equal(t, "/usr/bin/runcon", name)
+	assert.Equal(t, []string{"-t", "ssm_worker_t", "--", "/usr/bin/ssm-document-worker", "doc", "i-123"}, argv)
+
+	name, argv, err = Wrap(Context{AppArmorProfile: "ssm-worker"}, "/usr/bin/ssm-session-worker", []string{"session"})
+	assert.NoError(t, err)
+	assert.Equal(t, "/usr/bin/aa-exec", name)
+	assert.Equal(t, []string{"-p", "ssm-worker", "--", "/usr/bin/ssm-session-worker", "session"}, argv)
+}
+
+func TestWrapFailsWhenTransitionIsNotPossible(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "confinement")
+	defer os.RemoveAll(dir)
+	seLinuxEnforceFile = filepath.Join(dir, "enforce")
+	appArmorProfilesFile = filepath.Join(dir, "profiles")
+	ioutil.WriteFile(appArmorProfilesFile, []byte("ssm-worker-other (enforce)\n"), 0600)
+	lookPath = func(file string) (string, error) { return "/usr/bin/" + file, nil }
+
+	_, _, err := Wrap(Context{SELinuxDomain: "ssm_worker_t"}, "/usr/bin/ssm-document-worker", nil)
+	assert.IsType(t, &TransitionError{}, err)
+	assert.Contains(t, err.Error(), "SELinux is not enabled")
+
+	_, _, err = Wrap(Context{AppArmorProfile: "ssm-worker"}, "/usr/bin/ssm-document-worker", nil)
+	assert.IsType(t, &TransitionError{}, err)
+	assert.Contains(t, err.Error(), "AppArmor profile ssm-worker is not loaded")
+}
@@ -0,0 +1,27 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+
+// +build !linux
+
+package confinement
+
+import (
+	"fmt"
+
+)
+
+// check fails for any execution context, SELinux and AppArmor are only supported on Linux
+func check(context Context) (runconPath string, aaExecPath string, err error) {
+	return "", "", fmt.Errorf("SELinux and AppArmor are not supported on this platform")
+}
@@ -0,0 +1,57 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+
+package confinement
+
+import (
+	"testing"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/stretchr/testify/assert"
+)
+
+func setExecutionContextConfig(cfg appconfig.ExecutionContextCfg) {
+	getAppConfig = func(bool) (appconfig.SsmagentConfig, error) {
+		config := appconfig.DefaultConfig()
+		config.ExecutionContext = cfg
+		return config, nil
+	}
+}
+
+func TestResolve(t *testing.T) {
+	defer func() { getAppConfig = appconfig.Config }()
+	setExecutionContextConfig(appconfig.ExecutionContextCfg{SELinuxDomain: "ssm_worker_t", AppArmorProfile: "ssm-worker"})
+
+	assert.Equal(t, Context{SELinuxDomain: "ssm_worker_t", AppArmorProfile: "ssm-worker"},
+		Resolve(Context{}))
+	assert.Equal(t, Context{SELinuxDomain: "ssm_script_t", AppArmorProfile: "ssm-worker"},
+		Resolve(Context{SELinuxDomain: "ssm_script_t"}))
+
+	setExecutionContextConfig(appconfig.ExecutionContextCfg{AppArmorProfile: "ssm-worker", DenyDocumentOverride: true})
+	assert.Equal(t, Context{AppArmorProfile: "ssm-worker"},
+		Resolve(Context{SELinuxDomain: "unconfined_t", AppArmorProfile: "unconfined"}))
+}
+
+func TestWrapWithoutContext(t *testing.T) {
+	name, argv, err := Wrap(Context{}, "/usr/bin/ssm-document-worker", []string{"doc", "i-123"})
+	assert.NoError(t, err)
+	assert.Equal(t, "/usr/bin/ssm-document-worker", name)
+	assert.Equal(t, []string{"doc", "i-123"}, argv)
+}
+
+func TestDescribe(t *testing.T) {
+	assert.Equal(t, "unconfined", Describe(Context{}))
+	assert.Equal(t, "SELinux domain ssm_worker_t", Describe(Context{SELinuxDomain: "ssm_worker_t"}))
+	assert.Equal(t, "AppArmor profile ssm-worker", Describe(Context{AppArmorProfile: "ssm-worker"}))
+}
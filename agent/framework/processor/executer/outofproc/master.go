@@ -1,6 +1,7 @@
 package outofproc
 
 import (
+	"sync"
 	"time"
 
 	"fmt"
@@ -11,9 +12,12 @@ import (
 	"github.com/aws/amazon-ssm-agent/agent/framework/processor/executer"
 	"github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/basicexecuter"
 	"github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/outofproc/channel"
+	"github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/outofproc/confinement"
 	"github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/outofproc/messaging"
 	"github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/outofproc/proc"
+	"github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/outofproc/workerpool"
 	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/privilege"
 	"github.com/aws/amazon-ssm-agent/agent/task"
 )
 
@@ -52,6 +56,51 @@ var processCreator = func(name string, argv []string) (proc.OSProcess, error) {
 	return proc.StartProcess(name, argv)
 }
 
+var privilegedProcessCreator = func(name string, argv []string, context confinement.Context) (proc.OSProcess, error) {
+	return proc.StartPrivilegedProcess(name, argv, context)
+}
+
+var isUnprivileged = privilege.IsUnprivileged
+
+var (
+	workerPoolLock sync.Mutex
+	workerPool     *workerpool.Pool
+)
+
+// documentWorkerPool returns the pool of document workers, it is created by the first document when the pool is
+// enabled and nil otherwise
+var documentWorkerPool = func(ctx context.T, instanceID string) *workerpool.Pool {
+	workerPoolLock.Lock()
+	defer workerPoolLock.Unlock()
+	if workerPool != nil {
+		return workerPool
+	}
+	config := ctx.AppConfig().WorkerPool
+	if !config.Enabled {
+		return nil
+	}
+	log := ctx.Log()
+	start := func(channelName string) (proc.OSProcess, error) {
+		return processCreator(appconfig.DefaultDocumentWorker, proc.FormArgv(channelName, instanceID))
+	}
+	open := func(channelName string) (channel.Channel, error) {
+		ipc, err, _ := channelCreator(log, channel.ModeMaster, channelName)
+		return ipc, err
+	}
+	workerPool = workerpool.New(log, config, start, open)
+	return workerPool
+}
+
+// StopWorkerPool retires the pooled document workers
+func StopWorkerPool() {
+	workerPoolLock.Lock()
+	defer workerPoolLock.Unlock()
+	if workerPool != nil {
+		workerPool.Stop()
+		workerPool = nil
+	}
+}
+
 func NewOutOfProcExecuter(ctx context.T) *OutOfProcExecuter {
 	return &OutOfProcExecuter{
 		BasicExecuter: *basicexecuter.NewBasicExecuter(ctx),
@@ -80,7 +129,16 @@ func (e *OutOfProcExecuter) Run(
 	//save doc store immediately in case agent restarts.
 	docStore.Save(*e.docState)
 
-	if err != nil {
+	if transitionErr, ok := err.(*confinement.TransitionError); ok {
+		//a confined document must not fall back to run unconfined in process
+		log.Errorf("failed to start worker in %v: %v", confinement.Describe(transitionErr.Context), transitionErr.Err)
+		e.docState.DocumentInformation.DocumentStatus = contracts.ResultStatusFailed
+		docStore.Save(*e.docState)
+		resChan := make(chan contracts.DocumentResult, 1)
+		resChan <- e.generateUnexpectedFailResult(transitionErr.Error())
+		close(resChan)
+		return resChan
+	} else if err != nil {
 		log.Errorf("failed to prepare outofproc executer, falling back to InProc Executer")
 		return e.BasicExecuter.Run(cancelFlag, docStore)
 	} else {
@@ -97,7 +155,7 @@ func (e *OutOfProcExecuter) Run(
 				log.Info("Executer closed")
 				close(resChan)
 			}()
-			e.messaging(log, ipc, resChan, cancelFlag, stopTimer)
+			e.messaging(log, ipc, resChan, cancelFlag, stopTimer, store)
 		}(docStore)
 
 		return resChan
@@ -107,10 +165,10 @@ func (e *OutOfProcExecuter) Run(
 //Executer spins up an ipc transmission worker, it creates a Data processing backend and hands off the backend to the ipc worker
 //ipc worker and data backend act as 2 threads exchange raw json messages, and messaging protocol happened in data backend, data backend is self-contained and exit when command finishes accordingly
 //Executer however does hold a timer to the worker to forcefully termniate both of them
-func (e *OutOfProcExecuter) messaging(log log.T, ipc channel.Channel, resChan chan contracts.DocumentResult, cancelFlag task.CancelFlag, stopTimer chan bool) {
+func (e *OutOfProcExecuter) messaging(log log.T, ipc channel.Channel, resChan chan contracts.DocumentResult, cancelFlag task.CancelFlag, stopTimer chan bool, store executer.DocumentStore) {
 
 	//handoff reply functionalities to data backend.
-	backend := messaging.NewExecuterBackend(resChan, e.docState, cancelFlag)
+	backend := messaging.NewExecuterBackend(resChan, e.docState, cancelFlag, store.Save)
 	//handoff the data backend to messaging worker
 	if err := messaging.Messaging(log, ipc, backend, stopTimer); err != nil {
 		//the messaging worker encountered error, either ipc run into error or data backend throws error
@@ -178,11 +236,35 @@ func (e *OutOfProcExecuter) initialize(stopTimer chan bool) (ipc channel.Channel
 		} else {
 			workerName = appconfig.DefaultDocumentWorker
 		}
+		executionContext := confinement.Resolve(confinement.Context(e.docState.ExecutionContext))
+		create := func(name string, argv []string) (proc.OSProcess, error) {
+			name, argv, err := confinement.Wrap(executionContext, name, argv)
+			if err != nil {
+				return nil, err
+			}
+			return processCreator(name, argv)
+		}
+		if isUnprivileged() && privilege.DocumentRequiresRoot(e.pluginNames()) {
+			log.Debugf("starting %v as root through the privilege broker", workerName)
+			create = func(name string, argv []string) (proc.OSProcess, error) {
+				return privilegedProcessCreator(name, argv, executionContext)
+			}
+		}
+		if e.docState.DocumentType != contracts.StartSession &&
+			executionContext == (confinement.Context{}) &&
+			!(isUnprivileged() && privilege.DocumentRequiresRoot(e.pluginNames())) {
+			if e.runInPooledWorker(stopTimer, documentID, instanceID) {
+				return
+			}
+		}
 		var process proc.OSProcess
-		if process, err = processCreator(workerName, proc.FormArgv(documentID, instanceID)); err != nil {
+		if process, err = create(workerName, proc.FormArgv(documentID, instanceID)); err != nil {
 			log.Errorf("start process: %v error: %v", workerName, err)
 			//make sure close the channel
 			ipc.Destroy()
+			if _, ok := err.(*confinement.TransitionError); !ok && executionContext != (confinement.Context{}) {
+				err = &confinement.TransitionError{Context: executionContext, Err: err}
+			}
 			return
 		} else {
 			log.Debugf("successfully launched new process: %v", process.Pid())
@@ -199,6 +281,50 @@ func (e *OutOfProcExecuter) initialize(stopTimer chan bool) (ipc channel.Channel
 	return
 }
 
+// runInPooledWorker assigns the document to a worker of the pool, it returns false when the pool is disabled or has
+// no worker for the document, which then starts a new worker
+func (e *OutOfProcExecuter) runInPooledWorker(stopTimer chan bool, documentID, instanceID string) bool {
+	log := e.ctx.Log()
+	pool := documentWorkerPool(e.ctx, instanceID)
+	if pool == nil {
+		return false
+	}
+	worker, err := pool.Acquire()
+	if err != nil {
+		log.Warnf("failed to acquire a pooled worker, starting a new process: %v", err)
+		return false
+	}
+	done, err := worker.Assign(documentID)
+	if err != nil {
+		log.Warnf("failed to assign the document to a pooled worker, starting a new process: %v", err)
+		return false
+	}
+	process := worker.Process()
+	log.Debugf("assigned document to pooled worker process: %v", process.Pid())
+	e.docState.DocumentInformation.ProcInfo = contracts.OSProcInfo{
+		Pid:       process.Pid(),
+		StartTime: process.StartTime(),
+	}
+	go e.waitForPooledWorker(stopTimer, done)
+	return true
+}
+
+// waitForPooledWorker stops the messaging once the pooled worker completed the document or exited
+func (e *OutOfProcExecuter) waitForPooledWorker(stopTimer chan bool, done <-chan struct{}) {
+	<-done
+	e.ctx.Log().Debug("pooled worker completed the document, trying to stop messaging worker")
+	timeout(stopTimer, defaultZombieProcessTimeout, e.cancelFlag)
+}
+
+// pluginNames returns the names of the plugins of the document, which decide the privilege of its worker
+func (e *OutOfProcExecuter) pluginNames() []string {
+	var names []string
+	for _, plugin := range e.docState.InstancePluginsInformation {
+		names = append(names, plugin.Name)
+	}
+	return names
+}
+
 func (e *OutOfProcExecuter) WaitForProcess(stopTimer chan bool, process proc.OSProcess) {
 	log := e.ctx.Log()
 	//TODO revisit this feature, it has done sides of killing the document worker too fast -- the worker might busy doing s3 upload
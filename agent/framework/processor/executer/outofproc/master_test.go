@@ -9,15 +9,19 @@ import (
 	executermocks "github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/mock"
 	"github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/outofproc/channel"
 	channelmock "github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/outofproc/channel/mock"
+	"github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/outofproc/confinement"
 	procmock "github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/outofproc/proc/mock"
 
 	"errors"
 
 	"github.com/aws/amazon-ssm-agent/agent/appconfig"
 	"github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/outofproc/proc"
+	"github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/outofproc/workerpool"
 	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/privilege"
 	"github.com/aws/amazon-ssm-agent/agent/task"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 )
 
 type TestCase struct {
@@ -133,6 +137,40 @@ func TestInitializeNewProcess(t *testing.T) {
 	assert.Equal(t, testPid, exe.docState.DocumentInformation.ProcInfo.Pid)
 }
 
+func TestInitializeNewProcessThroughPrivilegeBroker(t *testing.T) {
+	testCase := CreateTestCase()
+	channelMock := new(channelmock.MockedChannel)
+	channelCreator = func(log log.T, mode channel.Mode, documentID string) (channel.Channel, error, bool) {
+		return channelMock, nil, false
+	}
+	processCreator = func(name string, argv []string) (proc.OSProcess, error) {
+		assert.Fail(t, "worker of a root plugin should be started by the privilege broker")
+		return nil, errors.New("unexpected worker start")
+	}
+	privilegedProcessCreator = func(name string, argv []string, context confinement.Context) (proc.OSProcess, error) {
+		assert.Equal(t, name, appconfig.DefaultDocumentWorker)
+		assert.Equal(t, argv, []string{testDocumentID, testInstanceID})
+		return testCase.processMock, nil
+	}
+	isUnprivileged = func() bool { return true }
+	defer func() { isUnprivileged = privilege.IsUnprivileged }()
+	exe := &OutOfProcExecuter{
+		ctx:        testCase.context,
+		docState:   &testCase.docState,
+		cancelFlag: task.NewChanneledCancelFlag(),
+	}
+	stopTimer := make(chan bool)
+
+	testCase.processMock.On("Wait").Return(nil)
+	testCase.processMock.On("Pid").Return(testPid)
+	testCase.processMock.On("StartTime").Return(testStartDateTime)
+	_, err := exe.initialize(stopTimer)
+	assert.NoError(t, err)
+	<-stopTimer
+	testCase.processMock.AssertExpectations(t)
+	assert.Equal(t, testPid, exe.docState.DocumentInformation.ProcInfo.Pid)
+}
+
 func TestInitializeNewProcessForSession(t *testing.T) {
 	testCase := createTestCaseForStartSession()
 	channelMock := new(channelmock.MockedChannel)
@@ -146,6 +184,12 @@ func TestInitializeNewProcessForSession(t *testing.T) {
 		assert.Equal(t, argv, []string{testDocumentID, testInstanceID})
 		return testCase.processMock, nil
 	}
+	defaultWorkerPool := documentWorkerPool
+	documentWorkerPool = func(ctx context.T, instanceID string) *workerpool.Pool {
+		assert.Fail(t, "a session should not run in a pooled worker")
+		return nil
+	}
+	defer func() { documentWorkerPool = defaultWorkerPool }()
 	exe := &OutOfProcExecuter{
 		ctx:        testCase.context,
 		docState:   &testCase.docState,
@@ -228,6 +272,32 @@ func TestCreateProcessFailed(t *testing.T) {
 	assert.Error(t, err2)
 	channelMock.AssertExpectations(t)
 }
+
+func TestRunFailsWhenWorkerCannotBeConfined(t *testing.T) {
+	testCase := CreateTestCase()
+	testCase.docState.ExecutionContext = contracts.ExecutionContext{SELinuxDomain: "ssm_worker_t"}
+	channelMock := new(channelmock.MockedChannel)
+	channelMock.On("Destroy").Return(nil)
+	channelCreator = func(log log.T, mode channel.Mode, documentID string) (channel.Channel, error, bool) {
+		return channelMock, nil, false
+	}
+	processCreator = func(name string, argv []string) (proc.OSProcess, error) {
+		return nil, errors.New("runcon: invalid context")
+	}
+	testCase.docStore.On("Load").Return(testCase.docState)
+	testCase.docStore.On("Save", mock.Anything).Return()
+	exe := NewOutOfProcExecuter(testCase.context)
+
+	resChan := exe.Run(task.NewChanneledCancelFlag(), testCase.docStore)
+	result, ok := <-resChan
+	assert.True(t, ok)
+	assert.Equal(t, contracts.ResultStatusFailed, result.Status)
+	assert.Contains(t, result.PluginResults["plugin1"].Output, "SELinux domain ssm_worker_t")
+	_, ok = <-resChan
+	assert.False(t, ok)
+	channelMock.AssertExpectations(t)
+}
+
 func TestInitializeProcessUnexpectedExited(t *testing.T) {
 	testCase := CreateTestCase()
 	channelMock := new(channelmock.MockedChannel)
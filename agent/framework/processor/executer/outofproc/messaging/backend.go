@@ -41,9 +41,11 @@ type ExecuterBackend struct {
 	cancelFlag task.CancelFlag
 	output     chan contracts.DocumentResult
 	stopChan   chan int
+	//saveState persists the shared state after each step update, it is optional
+	saveState func(contracts.DocumentState)
 }
 
-func NewExecuterBackend(output chan contracts.DocumentResult, docState *contracts.DocumentState, cancelFlag task.CancelFlag) *ExecuterBackend {
+func NewExecuterBackend(output chan contracts.DocumentResult, docState *contracts.DocumentState, cancelFlag task.CancelFlag, saveState func(contracts.DocumentState)) *ExecuterBackend {
 	stopChan := make(chan int, defaultBackendChannelSize)
 	inputChan := make(chan string, defaultBackendChannelSize)
 	p := ExecuterBackend{
@@ -52,6 +54,7 @@ func NewExecuterBackend(output chan contracts.DocumentResult, docState *contract
 		input:      inputChan,
 		cancelFlag: cancelFlag,
 		stopChan:   stopChan,
+		saveState:  saveState,
 	}
 	go p.start(*docState)
 	return &p
@@ -112,6 +115,10 @@ func (p *ExecuterBackend) formatDocResult(docResult *contracts.DocumentResult) {
 	docResult.DocumentVersion = p.docState.DocumentInformation.DocumentVersion
 	//update current document status
 	contracts.UpdateDocState(docResult, p.docState)
+	//persist every finished step, so a document interrupted by a restart resumes after it
+	if docResult.LastPlugin != "" && p.saveState != nil {
+		p.saveState(*p.docState)
+	}
 }
 
 func NewWorkerBackend(ctx context.T, runner PluginRunner) *WorkerBackend {
@@ -169,6 +169,30 @@ func TestExecuterBackend_ProcessV1(t *testing.T) {
 	cancel.AssertExpectations(t)
 }
 
+func TestExecuterBackend_ProcessSavesStepState(t *testing.T) {
+	testCase := CreateTestCase()
+	outputChan := make(chan contracts.DocumentResult, 10)
+	stopChan := make(chan int, 1)
+	var saved []contracts.DocumentState
+	backend := ExecuterBackend{
+		cancelFlag: task.NewMockDefault(),
+		output:     outputChan,
+		stopChan:   stopChan,
+		docState:   &testCase.docState,
+		saveState: func(state contracts.DocumentState) {
+			saved = append(saved, state)
+		},
+	}
+	assert.NoError(t, backend.Process(testPluginReplyRawJSON))
+	<-outputChan
+	assert.Equal(t, 1, len(saved))
+	assert.EqualValues(t, *testCase.results["plugin1"], saved[0].InstancePluginsInformation[0].Result)
+	//the document complete message is saved by the executer
+	assert.NoError(t, backend.Process(testDocumentCompleteRawJSON))
+	<-outputChan
+	assert.Equal(t, 1, len(saved))
+}
+
 //test the datagram mashalling v1
 func TestExecuterBackend_ProcessUnsupportedVersion(t *testing.T) {
 	testCase := CreateTestCase()
@@ -22,7 +22,9 @@ import (
 	"os/exec"
 
 	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/outofproc/confinement"
 	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/privilege"
 )
 
 //OSProcess is an abstracted interface of os.Process
@@ -76,6 +78,15 @@ func StartProcess(name string, argv []string) (OSProcess, error) {
 	return &p, err
 }
 
+//start a child process as root through the privilege broker, used when the agent worker runs unprivileged
+func StartPrivilegedProcess(name string, argv []string, context confinement.Context) (OSProcess, error) {
+	p, err := privilege.StartWorker(name, argv, context)
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
 //os.FindProcess() doesn't work on Linux: https://groups.google.com/forum/#!topic/golang-nuts/hqrp0UHBK9k
 //what we can only do is check whether it exists
 func IsProcessExists(log log.T, pid int, createTime time.Time) bool {
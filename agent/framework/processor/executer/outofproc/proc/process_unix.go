@@ -28,7 +28,8 @@ import (
 //verified on RHEL, Amazon Linux, Ubuntu, Centos, FreeBSD and Darwin
 //TODO optimize this, do not print all processes; what we need is the process belongs to a specific user and no tty attached
 var ps = func() ([]byte, error) {
-	return exec.Command("ps", "-e", "-o", "pid,lstart").CombinedOutput()
+	// -A lists all processes on linux, darwin and freebsd, -e shows the environment on freebsd
+	return exec.Command("ps", "-A", "-o", "pid,lstart").CombinedOutput()
 }
 
 func prepareProcess(command *exec.Cmd) {
@@ -10,11 +10,13 @@ import (
 	"github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/outofproc/channel"
 	"github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/outofproc/messaging"
 	"github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/outofproc/proc"
+	"github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/outofproc/workerpool"
 	"github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/plugin"
 	"github.com/aws/amazon-ssm-agent/agent/framework/runpluginutil"
 	"github.com/aws/amazon-ssm-agent/agent/log"
 	"github.com/aws/amazon-ssm-agent/agent/log/ssmlog"
 	"github.com/aws/amazon-ssm-agent/agent/platform"
+	"github.com/aws/amazon-ssm-agent/agent/sandbox"
 	"github.com/aws/amazon-ssm-agent/agent/task"
 	"github.com/aws/amazon-ssm-agent/agent/version"
 )
@@ -57,6 +59,11 @@ func initialize(args []string) (context.T, string, error) {
 }
 
 func main() {
+	// children of aws:runShellScript are started through the worker to apply their sandbox
+	if sandbox.IsExec(os.Args[1:]) {
+		sandbox.Main(os.Args[1:])
+	}
+
 	var err error
 	var logger log.T
 	args := os.Args
@@ -67,16 +74,38 @@ func main() {
 		logger.Close()
 		return
 	}
+	//initialize PluginRegistry
+	runpluginutil.SSMPluginRegistry = plugin.RegisteredWorkerPlugins(ctx)
+
+	if workerpool.IsPooledChannel(channelName) {
+		servePool(ctx, channelName)
+		//ensure logs are flushed
+		logger.Close()
+		return
+	}
+
+	if err = runDocument(ctx, channelName); err != nil {
+		//If ipc messaging broke, there's nothing worker process can do, exit immediately
+		logger.Close()
+		return
+	}
+	logger.Info("document worker closed")
+	//ensure logs are flushed
+	logger.Close()
+	//TODO figure out s3 aync problem
+	//TODO figure out why defer main doesnt work on windows
+}
+
+// runDocument runs the document over the channel named channelName until its messaging completed
+func runDocument(ctx context.T, channelName string) error {
+	logger := ctx.Log()
 	logger.Infof("document: %v worker started", channelName)
 	//create channel from the given handle identifier by master
 	ipc, err, _ := channel.CreateFileChannel(logger, channel.ModeWorker, channelName)
 	if err != nil {
 		logger.Errorf("failed to create channel: %v", err)
-		logger.Close()
-		return
+		return err
 	}
-	//initialize PluginRegistry
-	runpluginutil.SSMPluginRegistry = plugin.RegisteredWorkerPlugins(ctx)
 
 	//TODO add command timeout
 	stopTimer := make(chan bool)
@@ -84,16 +113,29 @@ func main() {
 	//TODO wait for sigterm or send fail message to the channel?
 	if err = messaging.Messaging(ctx.Log(), ipc, pipeline, stopTimer); err != nil {
 		logger.Errorf("messaging worker encountered error: %v", err)
-		//If ipc messaging broke, there's nothing worker process can do, exit immediately
-		logger.Close()
-		return
+		return err
 	}
-	logger.Info("document worker closed")
-	//ensure logs are flushed
-	logger.Close()
-	//TODO figure out s3 aync problem
-	//TODO figure out why defer main doesnt work on windows
+	return nil
+}
+
+// servePool runs the documents the worker pool assigns on the control channel named channelName
+func servePool(ctx context.T, channelName string) {
+	logger := ctx.Log()
+	logger.Infof("pooled worker %v started", channelName)
+	control, err, _ := channel.CreateFileChannel(logger, channel.ModeWorker, channelName)
 	if err != nil {
-		os.Exit(1)
+		logger.Errorf("failed to create control channel: %v", err)
+		return
 	}
+	registry := runpluginutil.SSMPluginRegistry
+	workerpool.Serve(logger, control, func(documentID string) error {
+		// each document starts from the registry the worker was initialized with
+		runpluginutil.SSMPluginRegistry = make(runpluginutil.PluginRegistry, len(registry))
+		for name, factory := range registry {
+			runpluginutil.SSMPluginRegistry[name] = factory
+		}
+		return runDocument(ctx.With("["+documentID+"]"), documentID)
+	})
+	control.Close()
+	logger.Info("pooled worker closed")
 }
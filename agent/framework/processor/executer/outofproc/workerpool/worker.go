@@ -0,0 +1,137 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package workerpool
+
+import (
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/outofproc/channel"
+	"github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/outofproc/messaging"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+)
+
+// RunFunc runs the document over the channel named documentID and returns once its messaging completed
+type RunFunc func(documentID string) error
+
+// processState is the state of the worker process a document may change, it is restored after each document so
+// that a document does not see what the previous ones left. Goroutines and file descriptors a document leaks are not
+// reclaimed, they last until the worker is replaced.
+type processState struct {
+	environment []string
+	workingDir  string
+	platform    platformState
+}
+
+// Serve runs the documents assigned on the control channel, one at a time, until the pool asks the worker to exit,
+// the control channel closes, a document fails or no message arrives within IdleTimeout
+func Serve(log log.T, control channel.Channel, run RunFunc) {
+	serve(log, control, run, IdleTimeout)
+}
+
+func serve(log log.T, control channel.Channel, run RunFunc, idleTimeout time.Duration) {
+	state := captureProcessState()
+	if !reply(log, control, MessageTypeReady) {
+		return
+	}
+
+	idle := time.NewTimer(idleTimeout)
+	defer idle.Stop()
+	for {
+		select {
+		case datagram, more := <-control.GetMessage():
+			if !more {
+				log.Info("control channel closed, pooled worker exiting")
+				return
+			}
+			t, content := messaging.ParseDatagram(datagram)
+			switch t {
+			case MessageTypePing:
+				if !reply(log, control, MessageTypePong) {
+					return
+				}
+			case MessageTypeAssign:
+				id, err := documentID(content)
+				if err == nil {
+					log.Infof("running document %v", id)
+					err = run(id)
+				}
+				if err != nil {
+					// the worker may be left in any state, a new worker replaces it
+					log.Errorf("pooled worker failed to run the document, exiting: %v", err)
+					return
+				}
+				if err = state.restore(); err != nil {
+					log.Errorf("pooled worker failed to restore its state, exiting: %v", err)
+					return
+				}
+				if !reply(log, control, MessageTypeReady) {
+					return
+				}
+			case MessageTypeExit:
+				log.Info("pooled worker requested to exit")
+				return
+			default:
+				log.Warnf("unsupported control message %v", t)
+			}
+			if !idle.Stop() {
+				<-idle.C
+			}
+			idle.Reset(idleTimeout)
+		case <-idle.C:
+			log.Infof("no message from the pool within %v, pooled worker exiting", idleTimeout)
+			return
+		}
+	}
+}
+
+// reply sends a control message, it returns false when the control channel is broken
+func reply(log log.T, control channel.Channel, t messaging.MessageType) bool {
+	datagram, _ := messaging.CreateDatagram(t, "")
+	if err := control.Send(datagram); err != nil {
+		log.Errorf("failed to send %v to the pool: %v", t, err)
+		return false
+	}
+	return true
+}
+
+// captureProcessState returns the current state of the process
+func captureProcessState() processState {
+	workingDir, _ := os.Getwd()
+	return processState{
+		environment: os.Environ(),
+		workingDir:  workingDir,
+		platform:    capturePlatformState(),
+	}
+}
+
+// restore sets the environment, working directory, file mode creation mask and resource limits of the process
+// back to the captured state
+func (s processState) restore() error {
+	os.Clearenv()
+	for _, variable := range s.environment {
+		if i := strings.Index(variable, "="); i > 0 {
+			if err := os.Setenv(variable[:i], variable[i+1:]); err != nil {
+				return err
+			}
+		}
+	}
+	if s.workingDir != "" {
+		if err := os.Chdir(s.workingDir); err != nil {
+			return err
+		}
+	}
+	return s.platform.restore()
+}
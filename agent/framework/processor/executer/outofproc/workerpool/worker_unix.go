@@ -0,0 +1,68 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// +build darwin freebsd linux netbsd openbsd
+
+package workerpool
+
+import (
+	"fmt"
+	"syscall"
+)
+
+// resourceLimits are the resource limits a document may lower for the worker process
+var resourceLimits = []int{
+	syscall.RLIMIT_AS,
+	syscall.RLIMIT_CORE,
+	syscall.RLIMIT_CPU,
+	syscall.RLIMIT_DATA,
+	syscall.RLIMIT_FSIZE,
+	syscall.RLIMIT_NOFILE,
+	syscall.RLIMIT_STACK,
+}
+
+// platformState is the file mode creation mask and the resource limits of the process
+type platformState struct {
+	umask  int
+	limits map[int]syscall.Rlimit
+}
+
+// capturePlatformState returns the current file mode creation mask and resource limits of the process
+func capturePlatformState() platformState {
+	umask := syscall.Umask(0)
+	syscall.Umask(umask)
+	limits := make(map[int]syscall.Rlimit, len(resourceLimits))
+	for _, resource := range resourceLimits {
+		var limit syscall.Rlimit
+		if err := syscall.Getrlimit(resource, &limit); err == nil {
+			limits[resource] = limit
+		}
+	}
+	return platformState{umask: umask, limits: limits}
+}
+
+// restore sets the file mode creation mask and the resource limits back, raising a hard limit a document lowered
+// fails without root privileges and the worker is then replaced
+func (s platformState) restore() error {
+	syscall.Umask(s.umask)
+	for resource, limit := range s.limits {
+		var current syscall.Rlimit
+		if err := syscall.Getrlimit(resource, &current); err == nil && current == limit {
+			continue
+		}
+		if err := syscall.Setrlimit(resource, &limit); err != nil {
+			return fmt.Errorf("failed to restore resource limit %v: %v", resource, err)
+		}
+	}
+	return nil
+}
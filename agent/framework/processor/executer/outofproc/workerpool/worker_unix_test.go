@@ -0,0 +1,41 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// +build darwin freebsd linux netbsd openbsd
+
+package workerpool
+
+import (
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServeSecondDocumentDoesNotSeeFirstDocumentUmaskAndLimits(t *testing.T) {
+	original := capturePlatformState()
+	var second platformState
+	runTwoDocuments(t, func(documentID string) error {
+		if documentID == "document1" {
+			syscall.Umask(0)
+			limit := original.limits[syscall.RLIMIT_CORE]
+			limit.Cur = 0
+			return syscall.Setrlimit(syscall.RLIMIT_CORE, &limit)
+		}
+		second = capturePlatformState()
+		return nil
+	})
+
+	assert.Equal(t, original, second)
+	assert.Equal(t, original, capturePlatformState())
+}
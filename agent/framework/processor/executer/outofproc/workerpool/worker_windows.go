@@ -0,0 +1,29 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// +build windows
+
+package workerpool
+
+// platformState is empty on Windows, which has neither a file mode creation mask nor resource limits
+type platformState struct{}
+
+// capturePlatformState returns the empty state of the process
+func capturePlatformState() platformState {
+	return platformState{}
+}
+
+// restore does nothing on Windows
+func (s platformState) restore() error {
+	return nil
+}
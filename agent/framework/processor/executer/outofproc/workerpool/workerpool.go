@@ -0,0 +1,399 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package workerpool keeps document workers started ahead of the documents, so that a document does not wait for
+// its worker process to start. The pool talks to each pooled worker over a control channel: it assigns the worker a
+// document, the worker runs it over the channel of the document as a new worker would and reports it is ready for
+// the next one. Idle workers are health checked and a worker is replaced after its configured number of documents.
+package workerpool
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/outofproc/channel"
+	"github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/outofproc/messaging"
+	"github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/outofproc/proc"
+	"github.com/aws/amazon-ssm-agent/agent/jsonutil"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/schedule"
+)
+
+const (
+	// MessageTypeReady is sent by a worker once it started and after each document it ran
+	MessageTypeReady messaging.MessageType = "ready"
+	// MessageTypeAssign hands a document id to a worker
+	MessageTypeAssign messaging.MessageType = "assign"
+	// MessageTypePing asks an idle worker to answer with MessageTypePong
+	MessageTypePing messaging.MessageType = "ping"
+	// MessageTypePong answers MessageTypePing
+	MessageTypePong messaging.MessageType = "pong"
+	// MessageTypeExit asks a worker to exit
+	MessageTypeExit messaging.MessageType = "exit"
+
+	// IdleTimeout is the time a pooled worker waits for a message of the pool before exiting. The pool health checks
+	// its idle workers more often, so only the workers left by a stopped agent exit this way.
+	IdleTimeout = 15 * time.Minute
+
+	// channelPrefix starts the names of the control channels of the pooled workers
+	channelPrefix = "workerpool-"
+
+	startTimeout = 10 * time.Second
+	pingTimeout  = 2 * time.Second
+	exitTimeout  = 10 * time.Second
+)
+
+// ErrStopped is returned by Acquire once the pool is stopped
+var ErrStopped = errors.New("worker pool is stopped")
+
+// IsPooledChannel returns true when channelName is the control channel of a pooled worker
+func IsPooledChannel(channelName string) bool {
+	return strings.HasPrefix(channelName, channelPrefix)
+}
+
+// StartFunc starts the worker process that serves the control channel channelName
+type StartFunc func(channelName string) (proc.OSProcess, error)
+
+// OpenFunc creates the master end of the control channel channelName
+type OpenFunc func(channelName string) (channel.Channel, error)
+
+// Pool keeps Size idle document workers
+type Pool struct {
+	log    log.T
+	config appconfig.WorkerPoolCfg
+	start  StartFunc
+	open   OpenFunc
+
+	lock      sync.Mutex
+	idle      []*Worker
+	starting  int
+	sequence  int
+	stopped   bool
+	healthJob *schedule.Job
+}
+
+// Worker is a pooled document worker process
+type Worker struct {
+	pool    *Pool
+	name    string
+	process proc.OSProcess
+	control channel.Channel
+
+	// documents is the number of documents the worker was assigned, done is closed once the assigned document
+	// completed, both guarded by the pool lock
+	documents int
+	done      chan struct{}
+
+	ready  chan struct{}
+	pong   chan struct{}
+	exited chan struct{}
+}
+
+// New creates a pool with the configuration and starts its workers
+func New(log log.T, config appconfig.WorkerPoolCfg, start StartFunc, open OpenFunc) *Pool {
+	p := &Pool{
+		log:    log,
+		config: config,
+		start:  start,
+		open:   open,
+	}
+	p.healthJob = schedule.Every(time.Duration(config.HealthCheckIntervalSeconds)*time.Second, p.checkHealth)
+	go p.fill()
+	return p
+}
+
+// Acquire returns a healthy idle worker, or starts a worker when none is idle
+func (p *Pool) Acquire() (*Worker, error) {
+	for {
+		p.lock.Lock()
+		if p.stopped {
+			p.lock.Unlock()
+			return nil, ErrStopped
+		}
+		if len(p.idle) == 0 {
+			p.lock.Unlock()
+			break
+		}
+		// the most recently used worker is the warmest
+		w := p.idle[len(p.idle)-1]
+		p.idle = p.idle[:len(p.idle)-1]
+		p.lock.Unlock()
+
+		if w.healthy() {
+			go p.fill()
+			return w, nil
+		}
+		p.log.Warnf("pooled worker %v failed its health check, replacing it", w.name)
+		w.retire()
+	}
+
+	w, err := p.startWorker()
+	go p.fill()
+	return w, err
+}
+
+// Stop retires the idle workers, the busy workers are retired once they completed their documents
+func (p *Pool) Stop() {
+	p.lock.Lock()
+	p.stopped = true
+	idle := p.idle
+	p.idle = nil
+	p.lock.Unlock()
+
+	p.healthJob.Stop()
+	for _, w := range idle {
+		w.retire()
+	}
+}
+
+// Assign hands the document to the worker, the returned channel is closed once the worker completed the document
+// or exited
+func (w *Worker) Assign(documentID string) (<-chan struct{}, error) {
+	datagram, err := messaging.CreateDatagram(MessageTypeAssign, documentID)
+	if err != nil {
+		return nil, err
+	}
+
+	w.pool.lock.Lock()
+	w.documents++
+	w.done = make(chan struct{})
+	done := w.done
+	w.pool.lock.Unlock()
+
+	if err = w.control.Send(datagram); err != nil {
+		w.retire()
+		return nil, err
+	}
+	return done, nil
+}
+
+// Process returns the worker process
+func (w *Worker) Process() proc.OSProcess {
+	return w.process
+}
+
+// fill starts workers until Size workers are idle or starting
+func (p *Pool) fill() {
+	for {
+		p.lock.Lock()
+		if p.stopped || len(p.idle)+p.starting >= p.config.Size {
+			p.lock.Unlock()
+			return
+		}
+		p.starting++
+		p.lock.Unlock()
+
+		w, err := p.startWorker()
+
+		p.lock.Lock()
+		p.starting--
+		p.lock.Unlock()
+		if err != nil {
+			p.log.Warnf("failed to start a pooled worker: %v", err)
+			return
+		}
+		p.release(w)
+	}
+}
+
+// startWorker starts a worker process and waits for it to be ready
+func (p *Pool) startWorker() (*Worker, error) {
+	p.lock.Lock()
+	p.sequence++
+	name := fmt.Sprintf("%v%v-%v", channelPrefix, os.Getpid(), p.sequence)
+	p.lock.Unlock()
+
+	control, err := p.open(name)
+	if err != nil {
+		return nil, err
+	}
+	process, err := p.start(name)
+	if err != nil {
+		control.Destroy()
+		return nil, err
+	}
+	w := &Worker{
+		pool:    p,
+		name:    name,
+		process: process,
+		control: control,
+		ready:   make(chan struct{}, 1),
+		pong:    make(chan struct{}, 1),
+		exited:  make(chan struct{}),
+	}
+	go w.listen()
+	go w.wait()
+
+	select {
+	case <-w.ready:
+		p.log.Debugf("pooled worker %v started with pid %v", name, process.Pid())
+		return w, nil
+	case <-w.exited:
+		return nil, fmt.Errorf("pooled worker %v exited before it was ready", name)
+	case <-time.After(startTimeout):
+		w.kill()
+		return nil, fmt.Errorf("pooled worker %v was not ready within %v", name, startTimeout)
+	}
+}
+
+// release returns the worker to the idle workers, or retires it once it ran its documents or the pool stopped
+func (p *Pool) release(w *Worker) {
+	p.lock.Lock()
+	if !p.stopped && w.documents < p.config.MaxDocumentsPerWorker {
+		p.idle = append(p.idle, w)
+		p.lock.Unlock()
+		return
+	}
+	p.lock.Unlock()
+	p.log.Debugf("retiring pooled worker %v after %v documents", w.name, w.documents)
+	w.retire()
+	go p.fill()
+}
+
+// remove drops the worker from the idle workers
+func (p *Pool) remove(w *Worker) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	for i, idle := range p.idle {
+		if idle == w {
+			p.idle = append(p.idle[:i], p.idle[i+1:]...)
+			return
+		}
+	}
+}
+
+// checkHealth pings the idle workers, the workers that do not answer are replaced
+func (p *Pool) checkHealth() {
+	p.lock.Lock()
+	idle := p.idle
+	p.idle = nil
+	p.lock.Unlock()
+
+	for _, w := range idle {
+		if w.healthy() {
+			p.release(w)
+			continue
+		}
+		p.log.Warnf("pooled worker %v failed its health check, replacing it", w.name)
+		w.retire()
+	}
+	p.fill()
+}
+
+// listen dispatches the messages of the worker
+func (w *Worker) listen() {
+	for datagram := range w.control.GetMessage() {
+		t, _ := messaging.ParseDatagram(datagram)
+		switch t {
+		case MessageTypeReady:
+			if !w.complete() {
+				w.ready <- struct{}{}
+			}
+		case MessageTypePong:
+			select {
+			case w.pong <- struct{}{}:
+			default:
+			}
+		default:
+			w.pool.log.Warnf("pooled worker %v sent an unsupported message %v", w.name, t)
+		}
+	}
+}
+
+// complete closes the channel of the assigned document and releases the worker, it returns false when no document
+// was assigned
+func (w *Worker) complete() bool {
+	w.pool.lock.Lock()
+	done := w.done
+	w.done = nil
+	w.pool.lock.Unlock()
+	if done == nil {
+		return false
+	}
+	close(done)
+	w.pool.release(w)
+	return true
+}
+
+// wait removes the worker from the pool once its process exited
+func (w *Worker) wait() {
+	if err := w.process.Wait(); err != nil {
+		w.pool.log.Debugf("pooled worker %v exited: %v", w.name, err)
+	}
+	close(w.exited)
+	w.pool.remove(w)
+
+	w.pool.lock.Lock()
+	done := w.done
+	w.done = nil
+	w.pool.lock.Unlock()
+	if done != nil {
+		close(done)
+	}
+	w.control.Destroy()
+}
+
+// healthy returns true when the worker answers a ping
+func (w *Worker) healthy() bool {
+	select {
+	case <-w.pong:
+	default:
+	}
+	datagram, _ := messaging.CreateDatagram(MessageTypePing, "")
+	if err := w.control.Send(datagram); err != nil {
+		return false
+	}
+	select {
+	case <-w.pong:
+		return true
+	case <-w.exited:
+		return false
+	case <-time.After(pingTimeout):
+		return false
+	}
+}
+
+// retire asks the worker to exit and kills it when it does not
+func (w *Worker) retire() {
+	datagram, _ := messaging.CreateDatagram(MessageTypeExit, "")
+	if err := w.control.Send(datagram); err != nil {
+		w.kill()
+		return
+	}
+	go func() {
+		select {
+		case <-w.exited:
+		case <-time.After(exitTimeout):
+			w.kill()
+		}
+	}()
+}
+
+// kill kills the worker process
+func (w *Worker) kill() {
+	if err := w.process.Kill(); err != nil {
+		w.pool.log.Debugf("failed to kill pooled worker %v: %v", w.name, err)
+	}
+}
+
+// documentID reads the document id of an assign message
+func documentID(content string) (string, error) {
+	var id string
+	err := jsonutil.Unmarshal(content, &id)
+	return id, err
+}
@@ -0,0 +1,317 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package workerpool
+
+import (
+	"errors"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/outofproc/channel"
+	"github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/outofproc/messaging"
+	"github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/outofproc/proc"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/stretchr/testify/assert"
+)
+
+// memoryChannel is one end of an in-memory channel pair
+type memoryChannel struct {
+	lock     sync.Mutex
+	closed   bool
+	messages chan string
+	peer     *memoryChannel
+}
+
+func newChannelPair() (master *memoryChannel, worker *memoryChannel) {
+	master = &memoryChannel{messages: make(chan string, 10)}
+	worker = &memoryChannel{messages: make(chan string, 10)}
+	master.peer, worker.peer = worker, master
+	return
+}
+
+func (c *memoryChannel) Send(message string) error {
+	c.peer.lock.Lock()
+	defer c.peer.lock.Unlock()
+	if c.peer.closed {
+		return errors.New("channel closed")
+	}
+	c.peer.messages <- message
+	return nil
+}
+
+func (c *memoryChannel) GetMessage() <-chan string {
+	return c.messages
+}
+
+func (c *memoryChannel) Close() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if !c.closed {
+		c.closed = true
+		close(c.messages)
+	}
+}
+
+func (c *memoryChannel) Destroy() {
+	c.Close()
+	c.peer.Close()
+}
+
+// fakeProcess exits once killed or once its worker returned
+type fakeProcess struct {
+	pid    int
+	once   sync.Once
+	exited chan struct{}
+}
+
+func (p *fakeProcess) Pid() int             { return p.pid }
+func (p *fakeProcess) StartTime() time.Time { return time.Time{} }
+func (p *fakeProcess) Kill() error          { p.exit(); return nil }
+func (p *fakeProcess) Wait() error          { <-p.exited; return nil }
+func (p *fakeProcess) exit()                { p.once.Do(func() { close(p.exited) }) }
+
+// fakeWorkers starts the pooled workers of a test in goroutines
+type fakeWorkers struct {
+	lock      sync.Mutex
+	channels  map[string]*memoryChannel
+	processes []*fakeProcess
+	documents []string
+	serve     func(log log.T, control channel.Channel, run RunFunc)
+}
+
+func newFakeWorkers() *fakeWorkers {
+	return &fakeWorkers{
+		channels: make(map[string]*memoryChannel),
+		serve:    Serve,
+	}
+}
+
+func (f *fakeWorkers) open(channelName string) (channel.Channel, error) {
+	master, worker := newChannelPair()
+	f.lock.Lock()
+	f.channels[channelName] = worker
+	f.lock.Unlock()
+	return master, nil
+}
+
+func (f *fakeWorkers) start(channelName string) (proc.OSProcess, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	process := &fakeProcess{pid: len(f.processes) + 1, exited: make(chan struct{})}
+	f.processes = append(f.processes, process)
+	control := f.channels[channelName]
+	serve := f.serve
+	go func() {
+		serve(log.NewMockLog(), control, f.run)
+		process.exit()
+	}()
+	return process, nil
+}
+
+func (f *fakeWorkers) run(documentID string) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.documents = append(f.documents, documentID)
+	return nil
+}
+
+func (f *fakeWorkers) ran() []string {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return append([]string{}, f.documents...)
+}
+
+func (f *fakeWorkers) process(i int) *fakeProcess {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return f.processes[i]
+}
+
+func poolConfig(size, maxDocuments int) appconfig.WorkerPoolCfg {
+	return appconfig.WorkerPoolCfg{
+		Enabled:                    true,
+		Size:                       size,
+		MaxDocumentsPerWorker:      maxDocuments,
+		HealthCheckIntervalSeconds: appconfig.DefaultWorkerPoolHealthCheckIntervalSeconds,
+	}
+}
+
+func waitClosed(t *testing.T, c <-chan struct{}) {
+	select {
+	case <-c:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out")
+	}
+}
+
+func TestAcquireRunsDocumentsOnTheSameWorker(t *testing.T) {
+	workers := newFakeWorkers()
+	pool := New(log.NewMockLog(), poolConfig(1, 10), workers.start, workers.open)
+	defer pool.Stop()
+
+	first, err := pool.Acquire()
+	assert.NoError(t, err)
+	done, err := first.Assign("document1")
+	assert.NoError(t, err)
+	waitClosed(t, done)
+
+	second, err := pool.Acquire()
+	assert.NoError(t, err)
+	done, err = second.Assign("document2")
+	assert.NoError(t, err)
+	waitClosed(t, done)
+
+	assert.Equal(t, first.Process().Pid(), second.Process().Pid())
+	assert.Equal(t, []string{"document1", "document2"}, workers.ran())
+}
+
+func TestWorkerRetiredAfterMaxDocuments(t *testing.T) {
+	workers := newFakeWorkers()
+	pool := New(log.NewMockLog(), poolConfig(1, 1), workers.start, workers.open)
+	defer pool.Stop()
+
+	first, err := pool.Acquire()
+	assert.NoError(t, err)
+	done, err := first.Assign("document1")
+	assert.NoError(t, err)
+	waitClosed(t, done)
+	waitClosed(t, workers.process(0).exited)
+
+	second, err := pool.Acquire()
+	assert.NoError(t, err)
+	assert.NotEqual(t, first.Process().Pid(), second.Process().Pid())
+}
+
+func TestAcquireReplacesUnhealthyWorker(t *testing.T) {
+	workers := newFakeWorkers()
+	// the first worker gets ready but never answers a ping
+	workers.serve = func(log log.T, control channel.Channel, run RunFunc) {
+		reply(log, control, MessageTypeReady)
+		for datagram := range control.GetMessage() {
+			if t, _ := messaging.ParseDatagram(datagram); t == MessageTypeExit {
+				return
+			}
+		}
+	}
+	pool := New(log.NewMockLog(), poolConfig(1, 10), workers.start, workers.open)
+	defer pool.Stop()
+
+	// wait for the pool to fill before the workers behave
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		pool.lock.Lock()
+		idle := len(pool.idle)
+		pool.lock.Unlock()
+		if idle == 1 || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	workers.lock.Lock()
+	workers.serve = Serve
+	workers.lock.Unlock()
+
+	w, err := pool.Acquire()
+	assert.NoError(t, err)
+	assert.NotEqual(t, workers.process(0).Pid(), w.Process().Pid())
+	waitClosed(t, workers.process(0).exited)
+}
+
+func TestStopRetiresIdleWorkers(t *testing.T) {
+	workers := newFakeWorkers()
+	pool := New(log.NewMockLog(), poolConfig(1, 10), workers.start, workers.open)
+
+	w, err := pool.Acquire()
+	assert.NoError(t, err)
+	pool.release(w)
+	pool.Stop()
+
+	waitClosed(t, w.exited)
+	_, err = pool.Acquire()
+	assert.Equal(t, ErrStopped, err)
+}
+
+func TestServeExitsOnIdleTimeout(t *testing.T) {
+	master, worker := newChannelPair()
+	returned := make(chan struct{})
+	go func() {
+		serve(log.NewMockLog(), worker, func(string) error { return nil }, 50*time.Millisecond)
+		close(returned)
+	}()
+
+	readyType, _ := messaging.ParseDatagram(<-master.GetMessage())
+	assert.Equal(t, MessageTypeReady, readyType)
+	waitClosed(t, returned)
+}
+
+func TestServeRestoresProcessState(t *testing.T) {
+	workingDir, _ := os.Getwd()
+	master, worker := newChannelPair()
+	run := func(documentID string) error {
+		os.Setenv("WORKERPOOL_TEST_VARIABLE", documentID)
+		return os.Chdir(os.TempDir())
+	}
+	go serve(log.NewMockLog(), worker, run, time.Minute)
+	<-master.GetMessage()
+
+	assign, _ := messaging.CreateDatagram(MessageTypeAssign, "document1")
+	master.Send(assign)
+	<-master.GetMessage()
+	reply(log.NewMockLog(), master, MessageTypeExit)
+
+	_, found := os.LookupEnv("WORKERPOOL_TEST_VARIABLE")
+	assert.False(t, found)
+	currentDir, _ := os.Getwd()
+	assert.Equal(t, workingDir, currentDir)
+}
+
+// runTwoDocuments serves document1 and document2 with run and returns once both completed
+func runTwoDocuments(t *testing.T, run RunFunc) {
+	master, worker := newChannelPair()
+	returned := make(chan struct{})
+	go func() {
+		serve(log.NewMockLog(), worker, run, time.Minute)
+		close(returned)
+	}()
+	<-master.GetMessage()
+	for _, documentID := range []string{"document1", "document2"} {
+		assign, _ := messaging.CreateDatagram(MessageTypeAssign, documentID)
+		master.Send(assign)
+		readyType, _ := messaging.ParseDatagram(<-master.GetMessage())
+		assert.Equal(t, MessageTypeReady, readyType)
+	}
+	reply(log.NewMockLog(), master, MessageTypeExit)
+	waitClosed(t, returned)
+}
+
+func TestServeSecondDocumentDoesNotSeeFirstDocumentState(t *testing.T) {
+	workingDir, _ := os.Getwd()
+	var found bool
+	var secondDir string
+	runTwoDocuments(t, func(documentID string) error {
+		if documentID == "document1" {
+			os.Setenv("WORKERPOOL_TEST_VARIABLE", documentID)
+			return os.Chdir(os.TempDir())
+		}
+		_, found = os.LookupEnv("WORKERPOOL_TEST_VARIABLE")
+		secondDir, _ = os.Getwd()
+		return nil
+	})
+
+	assert.False(t, found)
+	assert.Equal(t, workingDir, secondDir)
+}
@@ -22,12 +22,18 @@ import (
 	"github.com/aws/amazon-ssm-agent/agent/appconfig"
 	"github.com/aws/amazon-ssm-agent/agent/context"
 	"github.com/aws/amazon-ssm-agent/agent/framework/runpluginutil"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurecloudwatchagent"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/configurecontainers"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configuredaemon"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/dockercontainer"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/downloadcontent"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/externalplugin"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/inventory"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/lrpminvoker"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/managepackages"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/manageusers"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/putcomplianceitems"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/refreshassociation"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/rundocument"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/runscript"
@@ -42,22 +48,29 @@ import (
 // This allows us to differentiate between the case where a document asks for a plugin that exists but isn't supported on this platform
 // and the case where a plugin name isn't known at all to this version of the agent (and the user should probably upgrade their agent)
 var allPlugins = map[string]struct{}{
-	appconfig.PluginNameAwsAgentUpdate:         {},
-	appconfig.PluginNameAwsApplications:        {},
-	appconfig.PluginNameAwsConfigureDaemon:     {},
-	appconfig.PluginNameAwsConfigurePackage:    {},
-	appconfig.PluginNameAwsPowerShellModule:    {},
-	appconfig.PluginNameAwsRunPowerShellScript: {},
-	appconfig.PluginNameAwsRunShellScript:      {},
-	appconfig.PluginNameAwsSoftwareInventory:   {},
-	appconfig.PluginNameCloudWatch:             {},
-	appconfig.PluginNameConfigureDocker:        {},
-	appconfig.PluginNameDockerContainer:        {},
-	appconfig.PluginNameDomainJoin:             {},
-	appconfig.PluginEC2ConfigUpdate:            {},
-	appconfig.PluginNameRefreshAssociation:     {},
-	appconfig.PluginDownloadContent:            {},
-	appconfig.PluginRunDocument:                {},
+	appconfig.PluginNameAwsAgentUpdate:              {},
+	appconfig.PluginNameAwsApplications:             {},
+	appconfig.PluginNameAwsConfigureCloudWatchAgent: {},
+	appconfig.PluginNameAwsConfigureDaemon:          {},
+	appconfig.PluginNameAwsConfigurePackage:         {},
+	appconfig.PluginNameAwsConfigureWindowsFeatures: {},
+	appconfig.PluginNameAwsManagePackages:           {},
+	appconfig.PluginNameAwsManageRegistry:           {},
+	appconfig.PluginNameAwsManageUsers:              {},
+	appconfig.PluginNameAwsPowerShellModule:         {},
+	appconfig.PluginNameAwsPutComplianceItems:       {},
+	appconfig.PluginNameAwsRunPowerShellScript:      {},
+	appconfig.PluginNameAwsRunShellScript:           {},
+	appconfig.PluginNameAwsScanUpdates:              {},
+	appconfig.PluginNameAwsSoftwareInventory:        {},
+	appconfig.PluginNameCloudWatch:                  {},
+	appconfig.PluginNameConfigureDocker:             {},
+	appconfig.PluginNameDockerContainer:             {},
+	appconfig.PluginNameDomainJoin:                  {},
+	appconfig.PluginEC2ConfigUpdate:                 {},
+	appconfig.PluginNameRefreshAssociation:          {},
+	appconfig.PluginDownloadContent:                 {},
+	appconfig.PluginRunDocument:                     {},
 }
 
 var once sync.Once
@@ -114,6 +127,20 @@ func (f ConfigurePackageFactory) Create(context context.T) (runpluginutil.T, err
 	return configurepackage.NewPlugin()
 }
 
+type ManagePackagesFactory struct {
+}
+
+func (f ManagePackagesFactory) Create(context context.T) (runpluginutil.T, error) {
+	return managepackages.NewPlugin()
+}
+
+type ManageUsersFactory struct {
+}
+
+func (f ManageUsersFactory) Create(context context.T) (runpluginutil.T, error) {
+	return manageusers.NewPlugin()
+}
+
 type RefreshAssociationFactory struct {
 }
 
@@ -128,6 +155,27 @@ func (d DownloadContentFactory) Create(context context.T) (runpluginutil.T, erro
 	return downloadcontent.NewPlugin()
 }
 
+type ConfigureDaemonFactory struct {
+}
+
+func (f ConfigureDaemonFactory) Create(context context.T) (runpluginutil.T, error) {
+	return configuredaemon.NewPlugin()
+}
+
+type ConfigureCloudWatchAgentFactory struct {
+}
+
+func (f ConfigureCloudWatchAgentFactory) Create(context context.T) (runpluginutil.T, error) {
+	return configurecloudwatchagent.NewPlugin()
+}
+
+type PutComplianceItemsFactory struct {
+}
+
+func (f PutComplianceItemsFactory) Create(context context.T) (runpluginutil.T, error) {
+	return putcomplianceitems.NewPlugin()
+}
+
 type RunDocumentFactory struct {
 }
 
@@ -143,6 +191,14 @@ func (f SessionPluginFactory) Create(context context.T) (runpluginutil.T, error)
 	return sessionplugin.NewPlugin(f.newPluginFunc)
 }
 
+type ExternalPluginFactory struct {
+	config appconfig.ExternalPluginCfg
+}
+
+func (f ExternalPluginFactory) Create(context context.T) (runpluginutil.T, error) {
+	return externalplugin.NewPlugin(f.config)
+}
+
 // RegisteredWorkerPlugins returns all registered core modules.
 func RegisteredWorkerPlugins(context context.T) runpluginutil.PluginRegistry {
 
@@ -168,7 +224,8 @@ func RegisteredSessionWorkerPlugins() runpluginutil.PluginRegistry {
 }
 
 // loadWorkers loads all worker plugins that are invokers for interacting with long running plugins and
-// then all standard worker plugins (if there are any conflicting names, the standard worker plugin wins)
+// then all standard worker plugins (if there are any conflicting names, the standard worker plugin wins),
+// the external plugins of the agent configuration are loaded last and never replace another plugin
 func loadWorkers(context context.T) {
 	plugins := runpluginutil.PluginRegistry{}
 
@@ -186,6 +243,16 @@ func loadWorkers(context context.T) {
 		context.Log().Infof("Successfully loaded platform dependent plugin %v", key)
 	}
 
+	for key, value := range loadExternalPlugins(context) {
+		if _, exists := plugins[key]; exists {
+			context.Log().Warnf("External plugin %v is not loaded, a plugin of the agent has the same name", key)
+			continue
+		}
+		plugins[key] = value
+		runpluginutil.RegisterExternalPlugin(key)
+		context.Log().Infof("Successfully loaded external plugin %v", key)
+	}
+
 	registeredPlugins = &plugins
 }
 
@@ -236,6 +303,14 @@ func loadPlatformIndependentPlugins(context context.T) runpluginutil.PluginRegis
 	configurePackagePluginName := configurepackage.Name()
 	workerPlugins[configurePackagePluginName] = ConfigurePackageFactory{}
 
+	// registering aws:managePackages
+	managePackagesPluginName := managepackages.Name()
+	workerPlugins[managePackagesPluginName] = ManagePackagesFactory{}
+
+	// registering aws:manageUsers
+	manageUsersPluginName := manageusers.Name()
+	workerPlugins[manageUsersPluginName] = ManageUsersFactory{}
+
 	//registering aws:downloadContent
 	downloadContentPluginName := downloadcontent.Name()
 	workerPlugins[downloadContentPluginName] = DownloadContentFactory{}
@@ -244,5 +319,32 @@ func loadPlatformIndependentPlugins(context context.T) runpluginutil.PluginRegis
 	runDocumentPluginName := rundocument.Name()
 	workerPlugins[runDocumentPluginName] = RunDocumentFactory{}
 
+	//registering aws:configureDaemon
+	configureDaemonPluginName := configuredaemon.Name()
+	workerPlugins[configureDaemonPluginName] = ConfigureDaemonFactory{}
+
+	//registering aws:configureCloudWatchAgent
+	configureCloudWatchAgentPluginName := configurecloudwatchagent.Name()
+	workerPlugins[configureCloudWatchAgentPluginName] = ConfigureCloudWatchAgentFactory{}
+
+	//registering aws:putComplianceItems
+	putComplianceItemsPluginName := putcomplianceitems.Name()
+	workerPlugins[putComplianceItemsPluginName] = PutComplianceItemsFactory{}
+
+	return workerPlugins
+}
+
+// loadExternalPlugins registers the plugins shipped as separate executables in the agent configuration
+func loadExternalPlugins(context context.T) runpluginutil.PluginRegistry {
+	var workerPlugins = runpluginutil.PluginRegistry{}
+
+	for _, config := range context.AppConfig().ExternalPlugins {
+		if err := externalplugin.ValidateConfig(config); err != nil {
+			context.Log().Errorf("External plugin %v is not loaded: %v", config.Name, err)
+			continue
+		}
+		workerPlugins[config.Name] = ExternalPluginFactory{config}
+	}
+
 	return workerPlugins
 }
@@ -21,6 +21,7 @@ import (
 	"github.com/aws/amazon-ssm-agent/agent/framework/runpluginutil"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/domainjoin"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/runscript"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/scanupdates"
 )
 
 type RunShellScriptFactory struct {
@@ -37,12 +38,20 @@ func (f DomainJoinFactory) Create(context context.T) (runpluginutil.T, error) {
 	return domainjoin.NewPlugin()
 }
 
+type ScanUpdatesFactory struct {
+}
+
+func (f ScanUpdatesFactory) Create(context context.T) (runpluginutil.T, error) {
+	return scanupdates.NewPlugin()
+}
+
 // loadPlatformDependentPlugins registers platform dependent plugins
 func loadPlatformDependentPlugins(context context.T) runpluginutil.PluginRegistry {
 	var workerPlugins = runpluginutil.PluginRegistry{}
 
 	workerPlugins[appconfig.PluginNameAwsRunShellScript] = RunShellScriptFactory{}
 	workerPlugins[appconfig.PluginNameDomainJoin] = DomainJoinFactory{}
+	workerPlugins[appconfig.PluginNameAwsScanUpdates] = ScanUpdatesFactory{}
 
 	return workerPlugins
 }
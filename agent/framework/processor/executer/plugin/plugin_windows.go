@@ -22,7 +22,9 @@ import (
 	"github.com/aws/amazon-ssm-agent/agent/context"
 	"github.com/aws/amazon-ssm-agent/agent/framework/runpluginutil"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/application"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurewindowsfeatures"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/domainjoin"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/manageregistry"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/psmodule"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/updateec2config"
 )
@@ -48,6 +50,20 @@ func (f DomainJoinFactory) Create(context context.T) (runpluginutil.T, error) {
 	return domainjoin.NewPlugin()
 }
 
+type ConfigureWindowsFeaturesFactory struct {
+}
+
+func (f ConfigureWindowsFeaturesFactory) Create(context context.T) (runpluginutil.T, error) {
+	return configurewindowsfeatures.NewPlugin()
+}
+
+type ManageRegistryFactory struct {
+}
+
+func (f ManageRegistryFactory) Create(context context.T) (runpluginutil.T, error) {
+	return manageregistry.NewPlugin()
+}
+
 type UpdateEc2ConfigFactory struct {
 }
 
@@ -71,18 +87,17 @@ func loadPlatformDependentPlugins(context context.T) runpluginutil.PluginRegistr
 	domainJoinPluginName := domainjoin.Name()
 	workerPlugins[domainJoinPluginName] = DomainJoinFactory{}
 
+	// registering aws:configureWindowsFeatures plugin
+	configureWindowsFeaturesPluginName := configurewindowsfeatures.Name()
+	workerPlugins[configureWindowsFeaturesPluginName] = ConfigureWindowsFeaturesFactory{}
+
+	// registering aws:manageRegistry plugin
+	manageRegistryPluginName := manageregistry.Name()
+	workerPlugins[manageRegistryPluginName] = ManageRegistryFactory{}
+
 	// registering aws:updateAgent plugin.
 	updateEC2AgentPluginName := updateec2config.Name()
 	workerPlugins[updateEC2AgentPluginName] = UpdateEc2ConfigFactory{}
 
-	//// registering aws:configureDaemon
-	//configureDaemonPluginName := configuredaemon.Name()
-	//configureDaemonPlugin, err := configuredaemon.NewPlugin(pluginutil.DefaultPluginConfig())
-	//if err != nil {
-	//	log.Errorf("failed to create plugin %s %v", configureDaemonPluginName, err)
-	//} else {
-	//	workerPlugins[configureDaemonPluginName] = configureDaemonPlugin
-	//}
-
 	return workerPlugins
 }
@@ -33,3 +33,13 @@ func (m *MockedProcessor) Cancel(docState contracts.DocumentState) {
 	m.Called(docState)
 	return
 }
+
+func (m *MockedProcessor) CancelAll() {
+	m.Called()
+	return
+}
+
+func (m *MockedProcessor) SetCommandWorkerLimit(commandWorkerLimit int) {
+	m.Called(commandWorkerLimit)
+	return
+}
@@ -0,0 +1,111 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package processor
+
+import (
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+)
+
+// documentArnResourcePrefix starts the resource of the ARN of a document shared from another account
+const documentArnResourcePrefix = "document/"
+
+// documentSource returns the account a document is shared from, empty for documents referenced by name, and its name
+func documentSource(documentName string) (account string, name string) {
+	// arn:partition:ssm:region:account:document/name
+	fields := strings.SplitN(documentName, ":", 6)
+	if len(fields) != 6 || fields[0] != "arn" || !strings.HasPrefix(fields[5], documentArnResourcePrefix) {
+		return "", documentName
+	}
+	return fields[4], strings.TrimPrefix(fields[5], documentArnResourcePrefix)
+}
+
+// matchesAny returns whether the value matches one of the path.Match patterns
+func matchesAny(patterns []string, value string) bool {
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(pattern, value); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// containsAccount returns whether the accounts list the account or the wildcard
+func containsAccount(accounts []string, account string) bool {
+	for _, candidate := range accounts {
+		if candidate == account || candidate == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// checkExecutionPolicy returns why the execution policy rejects the document, or nil when the document may run
+func checkExecutionPolicy(policy appconfig.ExecutionPolicyCfg, docState *contracts.DocumentState) error {
+	account, name := documentSource(docState.DocumentInformation.DocumentName)
+	if account != "" {
+		if containsAccount(policy.DeniedSourceAccounts, account) {
+			return fmt.Errorf("documents shared from account %v are denied", account)
+		}
+		if len(policy.AllowedSourceAccounts) > 0 && !containsAccount(policy.AllowedSourceAccounts, account) {
+			return fmt.Errorf("documents shared from account %v are not allowed", account)
+		}
+	}
+
+	if matchesAny(policy.DeniedDocuments, name) {
+		return fmt.Errorf("document %v is denied", name)
+	}
+	if len(policy.AllowedDocuments) > 0 && !matchesAny(policy.AllowedDocuments, name) {
+		return fmt.Errorf("document %v is not allowed", name)
+	}
+
+	for _, plugin := range docState.InstancePluginsInformation {
+		if matchesAny(policy.DeniedPlugins, plugin.Name) {
+			return fmt.Errorf("step %v runs plugin %v, which is denied", plugin.Id, plugin.Name)
+		}
+		if len(policy.AllowedPlugins) > 0 && !matchesAny(policy.AllowedPlugins, plugin.Name) {
+			return fmt.Errorf("step %v runs plugin %v, which is not allowed", plugin.Id, plugin.Name)
+		}
+		if matchesAny(policy.ApprovalRequiredPlugins, plugin.Name) {
+			if err := verifyApproval(policy.ApproverPublicKeys, docState.DocumentInformation); err != nil {
+				return fmt.Errorf("step %v runs plugin %v, which requires an approval: %v", plugin.Id, plugin.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// rejectDocument marks every step of the document rejected by the execution policy, and returns the final document result
+func rejectDocument(log log.T, docState *contracts.DocumentState, reason error) contracts.DocumentResult {
+	now := time.Now()
+	results := make(map[string]*contracts.PluginResult)
+	for i := range docState.InstancePluginsInformation {
+		plugin := &docState.InstancePluginsInformation[i]
+		plugin.Result.PluginID = plugin.Id
+		plugin.Result.PluginName = plugin.Name
+		plugin.Result.StartDateTime = now
+		plugin.Result.EndDateTime = now
+		plugin.Result.Status = contracts.ResultStatusRejectedByPolicy
+		plugin.Result.Error = fmt.Sprintf("document rejected by the execution policy of the agent: %v", reason)
+		result := plugin.Result
+		results[plugin.Id] = &result
+	}
+	return finalDocumentResult(log, docState, results)
+}
@@ -0,0 +1,80 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package processor
+
+import (
+	"testing"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func policyDocState(documentName string) contracts.DocumentState {
+	docState := interruptedDocState("", "")
+	docState.DocumentInformation.DocumentName = documentName
+	docState.InstancePluginsInformation[1].Name = appconfig.PluginNameAwsRunPowerShellScript
+	return docState
+}
+
+func TestDocumentSource(t *testing.T) {
+	account, name := documentSource("arn:aws:ssm:us-east-1:123456789012:document/Shared-Doc")
+	assert.Equal(t, "123456789012", account)
+	assert.Equal(t, "Shared-Doc", name)
+
+	account, name = documentSource("AWS-RunShellScript")
+	assert.Equal(t, "", account)
+	assert.Equal(t, "AWS-RunShellScript", name)
+}
+
+func TestCheckExecutionPolicy(t *testing.T) {
+	shared := "arn:aws:ssm:us-east-1:123456789012:document/Shared-Doc"
+	testCases := []struct {
+		name     string
+		policy   appconfig.ExecutionPolicyCfg
+		document string
+		rejected bool
+	}{
+		{"empty policy", appconfig.ExecutionPolicyCfg{}, shared, false},
+		{"allowed plugins", appconfig.ExecutionPolicyCfg{AllowedPlugins: []string{"aws:runShell*"}}, "Doc", true},
+		{"all plugins allowed", appconfig.ExecutionPolicyCfg{AllowedPlugins: []string{"aws:runShell*", "aws:runPowerShellScript"}}, "Doc", false},
+		{"denied plugin", appconfig.ExecutionPolicyCfg{DeniedPlugins: []string{"aws:runShellScript"}}, "Doc", true},
+		{"allowed document", appconfig.ExecutionPolicyCfg{AllowedDocuments: []string{"AWS-*"}}, "AWS-RunShellScript", false},
+		{"document not allowed", appconfig.ExecutionPolicyCfg{AllowedDocuments: []string{"AWS-*"}}, shared, true},
+		{"denied document", appconfig.ExecutionPolicyCfg{DeniedDocuments: []string{"Shared-*"}}, shared, true},
+		{"allowed account", appconfig.ExecutionPolicyCfg{AllowedSourceAccounts: []string{"123456789012"}}, shared, false},
+		{"account not allowed", appconfig.ExecutionPolicyCfg{AllowedSourceAccounts: []string{"210987654321"}}, shared, true},
+		{"all shared documents denied", appconfig.ExecutionPolicyCfg{DeniedSourceAccounts: []string{"*"}}, shared, true},
+		{"own documents not shared", appconfig.ExecutionPolicyCfg{DeniedSourceAccounts: []string{"*"}}, "Doc", false},
+	}
+	for _, tc := range testCases {
+		docState := policyDocState(tc.document)
+		err := checkExecutionPolicy(tc.policy, &docState)
+		assert.Equal(t, tc.rejected, err != nil, tc.name)
+	}
+}
+
+func TestRejectDocument(t *testing.T) {
+	docState := policyDocState("Doc")
+	err := checkExecutionPolicy(appconfig.ExecutionPolicyCfg{DeniedPlugins: []string{"aws:runPowerShellScript"}}, &docState)
+	result := rejectDocument(log.NewMockLog(), &docState, err)
+
+	assert.Equal(t, contracts.ResultStatusFailed, result.Status)
+	assert.Equal(t, 2, result.NPlugins)
+	assert.Equal(t, contracts.ResultStatusRejectedByPolicy, result.PluginResults["plugin0"].Status)
+	assert.Equal(t, contracts.ResultStatusRejectedByPolicy, result.PluginResults["plugin1"].Status)
+	assert.Contains(t, result.PluginResults["plugin1"].Error, "aws:runPowerShellScript")
+	assert.Equal(t, contracts.ResultStatusFailed, docState.DocumentInformation.DocumentStatus)
+}
@@ -16,24 +16,32 @@ package processor
 
 import (
 	"fmt"
-	"io/ioutil"
-	"os"
 	"runtime/debug"
+	"strings"
 	"sync"
 	"time"
 
 	"path/filepath"
 
 	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/association/compliance/model"
+	"github.com/aws/amazon-ssm-agent/agent/association/compliance/reporter"
+	"github.com/aws/amazon-ssm-agent/agent/attestation"
 	"github.com/aws/amazon-ssm-agent/agent/context"
 	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/events"
 	"github.com/aws/amazon-ssm-agent/agent/fileutil"
 	"github.com/aws/amazon-ssm-agent/agent/framework/docmanager"
 	"github.com/aws/amazon-ssm-agent/agent/framework/processor/executer"
+	"github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/basicexecuter"
 	"github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/outofproc"
+	"github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/outofproc/proc"
+	"github.com/aws/amazon-ssm-agent/agent/jsonutil"
+	"github.com/aws/amazon-ssm-agent/agent/log"
 	"github.com/aws/amazon-ssm-agent/agent/longrunning/manager"
 	"github.com/aws/amazon-ssm-agent/agent/platform"
 	"github.com/aws/amazon-ssm-agent/agent/rebooter"
+	"github.com/aws/amazon-ssm-agent/agent/shutdown"
 	"github.com/aws/amazon-ssm-agent/agent/task"
 	"github.com/aws/amazon-ssm-agent/agent/times"
 )
@@ -46,8 +54,32 @@ const (
 	hardStopTimeout = time.Second * 4
 
 	maxDocumentTimeOutHour = time.Hour * 48
+
+	// maxUrgentSteps is the number of steps up to which a document with urgent priority is started ahead of
+	// other documents, larger documents are bulk work whatever their priority
+	maxUrgentSteps = 3
 )
 
+// resumablePlugins converge the instance to a declared state, so a step of theirs interrupted by a restart can run again.
+// Steps of other plugins, such as scripts, might have partially applied their changes and are failed instead.
+var resumablePlugins = map[string]bool{
+	appconfig.PluginNameAwsConfigurePackage:         true,
+	appconfig.PluginNameAwsConfigureWindowsFeatures: true,
+	appconfig.PluginNameAwsManagePackages:           true,
+	appconfig.PluginNameAwsManageUsers:              true,
+	appconfig.PluginNameAwsManageRegistry:           true,
+	appconfig.PluginNameAwsAgentUpdate:              true,
+	appconfig.PluginDownloadContent:                 true,
+	appconfig.PluginNameAwsSoftwareInventory:        true,
+	appconfig.PluginNameCloudWatch:                  true,
+	appconfig.PluginNameConfigureDocker:             true,
+	appconfig.PluginNameRefreshAssociation:          true,
+}
+
+var processExists = proc.IsProcessExists
+
+var shutdownInProgress = shutdown.InProgress
+
 type Processor interface {
 	//Start activate the Processor and pick up the left over document in the last run, it returns a channel to caller to gather DocumentResult
 	Start() (chan contracts.DocumentResult, error)
@@ -59,8 +91,10 @@ type Processor interface {
 	Submit(docState contracts.DocumentState)
 	//cancel process the cancel document, with no return value since the command is already tracked in a different thread
 	Cancel(docState contracts.DocumentState)
-	//TODO do we need to implement CancelAll?
-	//CancelAll()
+	//CancelAll cancels all the running and queued documents
+	CancelAll()
+	//SetCommandWorkerLimit changes the number of documents the Processor runs in parallel
+	SetCommandWorkerLimit(commandWorkerLimit int)
 }
 
 type EngineProcessor struct {
@@ -82,12 +116,28 @@ func NewEngineProcessor(ctx context.T, commandWorkerLimit int, cancelWorkerLimit
 	// so we can define the number of workers per each
 	cancelWaitDuration := 10000 * time.Millisecond
 	clock := times.DefaultClock
-	sendCommandTaskPool := task.NewPool(log, commandWorkerLimit, cancelWaitDuration, clock)
-	cancelCommandTaskPool := task.NewPool(log, cancelWorkerLimit, cancelWaitDuration, clock)
-	resChan := make(chan contracts.DocumentResult)
+	mdsConfig := ctx.AppConfig().Mds
+	poolOptions := task.PoolOptions{
+		QueuedJobsPerWorker: mdsConfig.QueuedJobsPerWorker,
+		ClassLimits: map[task.Class]int{
+			task.ClassCPU: mdsConfig.CpuBoundWorkersLimit,
+			task.ClassIO:  mdsConfig.IoBoundWorkersLimit,
+		},
+	}
 	executerCreator := func(ctx context.T) executer.Executer {
 		return outofproc.NewOutOfProcExecuter(ctx)
 	}
+	// in low memory mode documents share the worker process, sessions keep their own session worker
+	if lowMemory := ctx.AppConfig().LowMemory; lowMemory.Enabled && !supportsSessions(supportedDocs) {
+		log.Infof("Low memory mode, documents run in the worker process while it uses less than %v MB", lowMemory.MemoryLimitMB)
+		poolOptions.MemoryLimitBytes = uint64(lowMemory.MemoryLimitMB) * 1024 * 1024
+		executerCreator = func(ctx context.T) executer.Executer {
+			return basicexecuter.NewBasicExecuter(ctx)
+		}
+	}
+	sendCommandTaskPool := task.NewPoolWithOptions(log, commandWorkerLimit, cancelWaitDuration, clock, poolOptions)
+	cancelCommandTaskPool := task.NewPool(log, cancelWorkerLimit, cancelWaitDuration, clock)
+	resChan := make(chan contracts.DocumentResult)
 	documentMgr := docmanager.NewDocumentFileMgr(appconfig.DefaultDataStorePath, appconfig.DefaultDocumentRootDirName, appconfig.DefaultLocationOfState)
 	return &EngineProcessor{
 		context:           ctx.With("[EngineProcessor]"),
@@ -100,6 +150,16 @@ func NewEngineProcessor(ctx context.T, commandWorkerLimit int, cancelWorkerLimit
 	}
 }
 
+// supportsSessions returns true if the processor runs session documents
+func supportsSessions(supportedDocs []contracts.DocumentType) bool {
+	for _, docType := range supportedDocs {
+		if docType == contracts.StartSession {
+			return true
+		}
+	}
+	return false
+}
+
 func (p *EngineProcessor) Start() (resChan chan contracts.DocumentResult, err error) {
 	context := p.context
 	if context == nil {
@@ -127,6 +187,8 @@ func (p *EngineProcessor) InitialProcessing(skipDocumentIfExpired bool) (err err
 	}
 
 	log.Info("Initial processing")
+	//delete the temporary directories the documents left behind when the agent stopped before they completed
+	docmanager.DeleteStaleTmpDirectories(log, instanceID, p.documentMgr)
 	//prioritize the ongoing document first
 	p.processInProgressDocuments(instanceID, skipDocumentIfExpired)
 	//deal with the pending jobs that haven't picked up by worker yet
@@ -137,6 +199,21 @@ func (p *EngineProcessor) InitialProcessing(skipDocumentIfExpired bool) (err err
 //Submit() is the public interface for sending run document request to processor
 func (p *EngineProcessor) Submit(docState contracts.DocumentState) {
 	log := p.context.Log()
+	//no new work is started while the agent shuts down, documents stay pending and run after the restart
+	if shutdownInProgress() {
+		if docState.DocumentType == contracts.StartSession {
+			log.Infof("Agent is shutting down, rejecting session %v", docState.DocumentInformation.MessageID)
+			return
+		}
+		log.Infof("Agent is shutting down, document %v will run after the restart", docState.DocumentInformation.MessageID)
+		p.documentMgr.PersistDocumentState(log, docState.DocumentInformation.DocumentID, docState.DocumentInformation.InstanceID, appconfig.DefaultLocationOfPending, docState)
+		return
+	}
+	//a command redelivered after an agent restart must not run twice
+	if !docState.IsAssociation() && p.documentMgr.IsDocumentProcessed(log, docState.DocumentInformation.InstanceID, docState.DocumentInformation.MessageID) {
+		log.Infof("Document %v was already processed, skipping", docState.DocumentInformation.MessageID)
+		return
+	}
 	//queue up the pending document
 	p.documentMgr.PersistDocumentState(log, docState.DocumentInformation.DocumentID, docState.DocumentInformation.InstanceID, appconfig.DefaultLocationOfPending, docState)
 	err := p.submit(&docState)
@@ -159,7 +236,7 @@ func (p *EngineProcessor) submit(docState *contracts.DocumentState) error {
 	} else {
 		jobID = docState.DocumentInformation.MessageID
 	}
-	return p.sendCommandPool.Submit(log, jobID, func(cancelFlag task.CancelFlag) {
+	return p.sendCommandPool.SubmitWithOptions(log, jobID, func(cancelFlag task.CancelFlag) {
 		processCommand(
 			p.context,
 			p.executerCreator,
@@ -167,10 +244,22 @@ func (p *EngineProcessor) submit(docState *contracts.DocumentState) error {
 			p.resChan,
 			docState,
 			p.documentMgr)
-	})
+	}, task.JobOptions{Priority: documentPriority(docState), Class: task.Class(docState.SchedulingClass)})
 
 }
 
+// documentPriority returns the priority a document is started with, sessions and small urgent documents
+// are started ahead of other queued documents
+func documentPriority(docState *contracts.DocumentState) task.Priority {
+	if docState.DocumentType == contracts.StartSession {
+		return task.PriorityHigh
+	}
+	if docState.Urgent && len(docState.InstancePluginsInformation) <= maxUrgentSteps {
+		return task.PriorityHigh
+	}
+	return task.PriorityNormal
+}
+
 func (p *EngineProcessor) Cancel(docState contracts.DocumentState) {
 	log := p.context.Log()
 	//TODO this is a hack, in future jobID should be managed by Processing engine itself, instead of inferring from job's internal field
@@ -191,6 +280,19 @@ func (p *EngineProcessor) Cancel(docState contracts.DocumentState) {
 	}
 }
 
+//CancelAll cancels every document of the send command pool, the session plugins end their sessions as they do when the
+//client terminates them, so the clients learn that their sessions end before the agent exits
+func (p *EngineProcessor) CancelAll() {
+	p.context.Log().Info("Canceling all the running documents")
+	p.sendCommandPool.CancelAll()
+}
+
+//SetCommandWorkerLimit resizes the send command pool, documents already running are not interrupted
+func (p *EngineProcessor) SetCommandWorkerLimit(commandWorkerLimit int) {
+	p.context.Log().Infof("Changing command worker limit to %v", commandWorkerLimit)
+	p.sendCommandPool.Resize(commandWorkerLimit)
+}
+
 //Stop set the cancel flags of all the running jobs, which are to be captured by the command worker and shutdown gracefully
 func (p *EngineProcessor) Stop(stopType contracts.StopType) {
 	var waitTimeout time.Duration
@@ -200,6 +302,10 @@ func (p *EngineProcessor) Stop(stopType contracts.StopType) {
 	} else {
 		waitTimeout = hardStopTimeout
 	}
+	//the jobs still running at the shutdown deadline are detached, their documents resume after the restart
+	if remaining := shutdown.Remaining(); shutdownInProgress() && remaining < waitTimeout {
+		waitTimeout = remaining
+	}
 
 	var wg sync.WaitGroup
 
@@ -223,31 +329,22 @@ func (p *EngineProcessor) Stop(stopType contracts.StopType) {
 	close(p.resChan)
 }
 
-//TODO remove the direct file dependency once we encapsulate docmanager package
+// processPendingDocuments submits the documents that were queued but not started before the agent stopped
 func (p *EngineProcessor) processPendingDocuments(instanceID string) {
 	log := p.context.Log()
-	files := []os.FileInfo{}
-	var err error
-
-	//process older documents from PENDING folder
-	pendingDocsLocation := docmanager.DocumentStateDir(instanceID, appconfig.DefaultLocationOfPending)
-
-	if isDirectoryEmpty, _ := fileutil.IsDirEmpty(pendingDocsLocation); isDirectoryEmpty {
-		log.Debugf("No pending documents to process from %v", pendingDocsLocation)
-		return
-	}
 
-	//get all pending messages
-	if files, err = fileutil.ReadDir(pendingDocsLocation); err != nil {
-		log.Errorf("skipping reading pending documents from %v. unexpected error encountered - %v", pendingDocsLocation, err)
+	//process older documents from PENDING location
+	names := p.documentMgr.ListDocumentStates(log, instanceID, appconfig.DefaultLocationOfPending)
+	if len(names) == 0 {
+		log.Debugf("No pending documents to process")
 		return
 	}
 
 	//iterate through all pending messages
-	for _, f := range files {
-		log.Infof("Found pending document - %v", f.Name())
+	for _, name := range names {
+		log.Infof("Found pending document - %v", name)
 		//inspect document state
-		docState := p.documentMgr.GetDocumentState(log, f.Name(), instanceID, appconfig.DefaultLocationOfPending)
+		docState := p.documentMgr.GetDocumentState(log, name, instanceID, appconfig.DefaultLocationOfPending)
 
 		if p.isSupportedDocumentType(docState.DocumentType) {
 			log.Infof("Processing pending document %v", docState.DocumentInformation.DocumentID)
@@ -261,32 +358,24 @@ func (p *EngineProcessor) processPendingDocuments(instanceID string) {
 func (p *EngineProcessor) processInProgressDocuments(instanceID string, skipDocumentIfExpired bool) {
 	log := p.context.Log()
 	config := p.context.AppConfig()
-	var err error
-
-	pendingDocsLocation := docmanager.DocumentStateDir(instanceID, appconfig.DefaultLocationOfCurrent)
-
-	if isDirectoryEmpty, _ := fileutil.IsDirEmpty(pendingDocsLocation); isDirectoryEmpty {
-		log.Debugf("No in-progress document to process from %v", pendingDocsLocation)
-		return
 
-	}
-
-	files := []os.FileInfo{}
-	if files, err = ioutil.ReadDir(pendingDocsLocation); err != nil {
-		log.Errorf("skipping reading inprogress document from %v. unexpected error encountered - %v", pendingDocsLocation, err)
+	names := p.documentMgr.ListDocumentStates(log, instanceID, appconfig.DefaultLocationOfCurrent)
+	if len(names) == 0 {
+		log.Debugf("No in-progress document to process")
 		return
 	}
 
 	//iterate through all InProgress docs
-	for _, f := range files {
-		log.Infof("Found in-progress document - %v", f.Name())
+	for _, name := range names {
+		log.Infof("Found in-progress document - %v", name)
 
 		//inspect document state
-		docState := p.documentMgr.GetDocumentState(log, f.Name(), instanceID, appconfig.DefaultLocationOfCurrent)
+		docState := p.documentMgr.GetDocumentState(log, name, instanceID, appconfig.DefaultLocationOfCurrent)
 
 		retryLimit := config.Mds.CommandRetryLimit
 		if docState.DocumentInformation.RunCount >= retryLimit {
-			p.documentMgr.MoveDocumentState(log, f.Name(), instanceID, appconfig.DefaultLocationOfCurrent, appconfig.DefaultLocationOfCorrupt)
+			p.documentMgr.MoveDocumentState(log, name, instanceID, appconfig.DefaultLocationOfCurrent, appconfig.DefaultLocationOfCorrupt)
+			docmanager.DeleteTmpDirectory(log, instanceID, name)
 			continue
 		}
 
@@ -304,18 +393,119 @@ func (p *EngineProcessor) processInProgressDocuments(instanceID string, skipDocu
 				// Do not resume in-progress document is create date is 48 hours ago.
 				if createDate.Add(maxDocumentTimeOutHour).Before(time.Now().UTC()) {
 					log.Infof("Document %v expired %v, skipping", docState.DocumentInformation.DocumentID, docState.DocumentInformation.CreatedDate)
-					p.documentMgr.MoveDocumentState(log, f.Name(), instanceID, appconfig.DefaultLocationOfCurrent, appconfig.DefaultLocationOfCorrupt)
+					p.documentMgr.MoveDocumentState(log, name, instanceID, appconfig.DefaultLocationOfCurrent, appconfig.DefaultLocationOfCorrupt)
+					continue
+				}
+			}
+
+			if step, interrupted := interruptedStep(log, &docState); interrupted {
+				pluginName := docState.InstancePluginsInformation[step].Name
+				if !resumablePlugins[pluginName] {
+					log.Warnf("Document %v was interrupted by a restart during step %v which cannot be resumed, reporting it as failed", docState.DocumentInformation.DocumentID, pluginName)
+					if err := p.submitInterrupted(&docState, step); err != nil {
+						log.Errorf("failed to report interrupted document %v : %v", docState.DocumentInformation.DocumentID, err)
+						p.documentMgr.MoveDocumentState(log, name, instanceID, appconfig.DefaultLocationOfCurrent, appconfig.DefaultLocationOfCorrupt)
+					}
 					continue
 				}
+				log.Infof("Document %v was interrupted by a restart during step %v, resuming it", docState.DocumentInformation.DocumentID, pluginName)
 			}
 
 			//Submit the work to Job Pool so that we don't block for processing of new messages
 			if err := p.submit(&docState); err != nil {
 				log.Errorf("failed to submit in progress document %v : %v", docState.DocumentInformation.DocumentID, err)
-				p.documentMgr.MoveDocumentState(log, f.Name(), instanceID, appconfig.DefaultLocationOfCurrent, appconfig.DefaultLocationOfCorrupt)
+				p.documentMgr.MoveDocumentState(log, name, instanceID, appconfig.DefaultLocationOfCurrent, appconfig.DefaultLocationOfCorrupt)
+			}
+		}
+	}
+}
+
+// submitInterrupted queues the final result of a document whose step was interrupted by a restart
+func (p *EngineProcessor) submitInterrupted(docState *contracts.DocumentState, step int) error {
+	log := p.context.Log()
+	result := failInterruptedStep(log, docState, step)
+	var jobID string
+	if docState.IsAssociation() {
+		jobID = docState.DocumentInformation.AssociationID
+	} else {
+		jobID = docState.DocumentInformation.MessageID
+	}
+	return p.sendCommandPool.Submit(log, jobID, func(cancelFlag task.CancelFlag) {
+		documentID := docState.DocumentInformation.DocumentID
+		instanceID := docState.DocumentInformation.InstanceID
+		p.documentMgr.PersistDocumentState(log, documentID, instanceID, appconfig.DefaultLocationOfCurrent, *docState)
+		log.Infof("sending document: %v interrupted response", documentID)
+		p.resChan <- result
+		p.documentMgr.RemoveDocumentState(log, documentID, instanceID, appconfig.DefaultLocationOfCurrent)
+	})
+}
+
+// interruptedStep returns the index of the step that was running when the agent or host restarted.
+// Documents whose worker is still alive are reattached, and documents that requested a reboot resume as usual.
+func interruptedStep(log log.T, docState *contracts.DocumentState) (step int, interrupted bool) {
+	if docState.DocumentType == contracts.StartSession {
+		return 0, false
+	}
+	procInfo := docState.DocumentInformation.ProcInfo
+	if procInfo.Pid != 0 && processExists(log, procInfo.Pid, procInfo.StartTime) {
+		return 0, false
+	}
+	step = -1
+	for i, plugin := range docState.InstancePluginsInformation {
+		switch plugin.Result.Status {
+		case contracts.ResultStatusSuccessAndReboot, contracts.ResultStatusPassedAndReboot:
+			return 0, false
+		case "", contracts.ResultStatusNotStarted, contracts.ResultStatusInProgress:
+			if step < 0 {
+				step = i
 			}
 		}
 	}
+	return step, step >= 0
+}
+
+// failInterruptedStep marks the interrupted step as failed and the steps after it as skipped, and returns the final document result
+func failInterruptedStep(log log.T, docState *contracts.DocumentState, step int) contracts.DocumentResult {
+	now := time.Now()
+	interruptedName := docState.InstancePluginsInformation[step].Name
+	results := make(map[string]*contracts.PluginResult)
+	for i := range docState.InstancePluginsInformation {
+		plugin := &docState.InstancePluginsInformation[i]
+		if i >= step {
+			plugin.Result.PluginID = plugin.Id
+			plugin.Result.PluginName = plugin.Name
+			if plugin.Result.StartDateTime.IsZero() {
+				plugin.Result.StartDateTime = now
+			}
+			plugin.Result.EndDateTime = now
+			if i == step {
+				plugin.Result.Status = contracts.ResultStatusInterruptedByRestart
+				plugin.Result.Error = fmt.Sprintf("step %v was interrupted by an agent or host restart and cannot be resumed", plugin.Name)
+			} else {
+				plugin.Result.Status = contracts.ResultStatusSkipped
+				plugin.Result.Output = fmt.Sprintf("step skipped because step %v was interrupted by a restart", interruptedName)
+			}
+		}
+		result := plugin.Result
+		results[plugin.Id] = &result
+	}
+	return finalDocumentResult(log, docState, results)
+}
+
+// finalDocumentResult aggregates the final results of the steps of a document that did not run to completion
+func finalDocumentResult(log log.T, docState *contracts.DocumentState, results map[string]*contracts.PluginResult) contracts.DocumentResult {
+	status, _, _ := contracts.DocumentResultAggregator(log, "", results)
+	docState.DocumentInformation.DocumentStatus = status
+	return contracts.DocumentResult{
+		Status:          status,
+		PluginResults:   results,
+		LastPlugin:      "",
+		MessageID:       docState.DocumentInformation.MessageID,
+		AssociationID:   docState.DocumentInformation.AssociationID,
+		NPlugins:        len(docState.InstancePluginsInformation),
+		DocumentName:    docState.DocumentInformation.DocumentName,
+		DocumentVersion: docState.DocumentInformation.DocumentVersion,
+	}
 }
 
 func (p *EngineProcessor) isSupportedDocumentType(documentType contracts.DocumentType) bool {
@@ -335,6 +525,19 @@ func processCommand(context context.T, executerCreator ExecuterCreator, cancelFl
 		docState.DocumentInformation.InstanceID,
 		appconfig.DefaultLocationOfPending,
 		appconfig.DefaultLocationOfCurrent)
+	//record the start once the state is in the current folder, documents interrupted from here on are resumed from that folder
+	if !docState.IsAssociation() {
+		docMgr.MarkDocumentProcessed(log, docState.DocumentInformation.InstanceID, docState.DocumentInformation.MessageID)
+	}
+	if err := checkExecutionPolicy(context.AppConfig().ExecutionPolicy, docState); err != nil {
+		log.Warnf("document %v rejected by the execution policy: %v", docState.DocumentInformation.DocumentID, err)
+		result := rejectDocument(log, docState, err)
+		docMgr.PersistDocumentState(log, docState.DocumentInformation.DocumentID, docState.DocumentInformation.InstanceID, appconfig.DefaultLocationOfCurrent, *docState)
+		resChan <- result
+		docMgr.RemoveDocumentState(log, docState.DocumentInformation.DocumentID, docState.DocumentInformation.InstanceID, appconfig.DefaultLocationOfCurrent)
+		return
+	}
+	publishDocumentEvent(log, docState, nil)
 	log.Debug("Running executer...")
 	documentID := docState.DocumentInformation.DocumentID
 	instanceID := docState.DocumentInformation.InstanceID
@@ -362,8 +565,14 @@ func processCommand(context context.T, executerCreator ExecuterCreator, cancelFl
 				log.Infof("sending reply for plugin update: %v", res.LastPlugin)
 			}
 
+			if res.LastPlugin == "" {
+				handleLongRunningPluginSteps(context, docState, &res, cancelFlag)
+				reportCustomCompliance(context, docState, &res)
+			} else {
+				publishStepEvent(log, docState, &res)
+			}
 			final = &res
-			handleCloudwatchPlugin(context, res.PluginResults, documentID)
+			handleCloudwatchPlugin(context, res.PluginResults, documentID, cancelFlag)
 			//hand off the message to Service
 			resChan <- res
 
@@ -377,10 +586,14 @@ func processCommand(context context.T, executerCreator ExecuterCreator, cancelFl
 		return
 	} else if final.Status == contracts.ResultStatusSuccessAndReboot {
 		log.Infof("document %v requested reboot, need to resume", messageID)
-		rebooter.RequestPendingReboot(context.Log())
+		rebooter.RequestReboot(context.Log(), rebootRequest(docState, final))
 		return
 	}
 
+	publishDocumentEvent(log, docState, final)
+	attestation.Emit(context, docState, *final)
+	docmanager.DeleteTmpDirectory(log, instanceID, documentID)
+
 	//persist : commands execution in completed folder (terminal state folder)
 	log.Infof("execution of %v is over. Removing interimState from current folder", messageID)
 
@@ -421,9 +634,158 @@ func processCancelCommand(context context.T, sendCommandPool task.Pool, docState
 
 }
 
+// reportCompliance queues custom compliance items for PutComplianceItems
+var reportCompliance = reporter.Report
+
+// publishEvent publishes the lifecycle events of the documents
+var publishEvent = events.Publish
+
+// compliancePluginSteps are the steps whose output embeds a custom compliance report
+var compliancePluginSteps = map[string]bool{
+	appconfig.PluginNameAwsPutComplianceItems: true,
+	appconfig.PluginNameAwsScanUpdates:        true,
+}
+
+// longRunningPluginSteps are the steps the worker validates and hands off to lrpm once the document completes
+var longRunningPluginSteps = map[string]func(log.T, *contracts.PluginResult, task.CancelFlag){
+	appconfig.PluginNameAwsConfigureDaemon:          manager.ConfigureDaemon,
+	appconfig.PluginNameAwsConfigureCloudWatchAgent: manager.ConfigureCloudWatchAgent,
+}
+
+// handleLongRunningPluginSteps hands the long running plugin steps of a completed document off to lrpm, in the order of the steps
+func handleLongRunningPluginSteps(context context.T, docState *contracts.DocumentState, res *contracts.DocumentResult, cancelFlag task.CancelFlag) {
+	log := context.Log()
+	handled := false
+	for _, pluginState := range docState.InstancePluginsInformation {
+		pluginRes, found := res.PluginResults[pluginState.Id]
+		if !found {
+			continue
+		}
+		if handOff, isLongRunning := longRunningPluginSteps[pluginRes.PluginName]; isLongRunning {
+			log.Infof("Found %v to hand off to lrpm", pluginRes.PluginName)
+			handOff(log, pluginRes, cancelFlag)
+			handled = true
+		}
+	}
+	if handled {
+		res.Status, _, _ = contracts.DocumentResultAggregator(log, "", res.PluginResults)
+	}
+}
+
+// reportCustomCompliance queues the items the compliance steps of a completed document reported,
+// the items are linked to the association or command of the document
+func reportCustomCompliance(context context.T, docState *contracts.DocumentState, res *contracts.DocumentResult) {
+	log := context.Log()
+	for _, pluginState := range docState.InstancePluginsInformation {
+		pluginRes, found := res.PluginResults[pluginState.Id]
+		if !found || !compliancePluginSteps[pluginRes.PluginName] || pluginRes.Status != contracts.ResultStatusSuccess {
+			continue
+		}
+		var report model.CustomComplianceReport
+		if err := jsonutil.Remarshal(pluginRes.Output, &report); err != nil {
+			log.Errorf("failed to read the compliance items of %v: %v", pluginState.Id, err)
+			continue
+		}
+		if report.ComplianceType == "" {
+			// the step did not report to compliance
+			continue
+		}
+		if docState.DocumentType == contracts.Association {
+			report.ExecutionType = model.ExecutionTypeAssociation
+			report.ExecutionId = docState.DocumentInformation.AssociationID
+		} else {
+			report.ExecutionType = model.ExecutionTypeCommand
+			report.ExecutionId = docState.DocumentInformation.CommandID
+		}
+		report.ExecutionTime = pluginRes.EndDateTime
+		if _, err := reportCompliance(log, report); err != nil {
+			log.Errorf("failed to report the compliance items of %v: %v", pluginState.Id, err)
+		}
+	}
+}
+
+// publishDocumentEvent publishes the start of the command, the association or the session of the document, or its end
+// with the status of the final result
+func publishDocumentEvent(log log.T, docState *contracts.DocumentState, final *contracts.DocumentResult) {
+	detail := documentEventDetail(docState)
+	if final != nil {
+		detail["Status"] = string(final.Status)
+	}
+
+	var eventType string
+	switch docState.DocumentType {
+	case contracts.Association:
+		eventType = events.AssociationStarted
+		if final != nil {
+			eventType = events.AssociationFinished
+		}
+	case contracts.StartSession:
+		eventType = events.SessionOpened
+		if final != nil {
+			eventType = events.SessionClosed
+		}
+	default:
+		eventType = events.CommandStarted
+		if final != nil {
+			eventType = events.CommandFinished
+		}
+	}
+	publishEvent(log, eventType, detail)
+}
+
+// publishStepEvent publishes the end of the step of the result with its status and its timing
+func publishStepEvent(log log.T, docState *contracts.DocumentState, res *contracts.DocumentResult) {
+	pluginRes, found := res.PluginResults[res.LastPlugin]
+	if !found {
+		return
+	}
+	detail := documentEventDetail(docState)
+	detail["StepName"] = res.LastPlugin
+	detail["PluginName"] = pluginRes.PluginName
+	detail["Status"] = string(pluginRes.Status)
+	detail["StartDateTime"] = times.ToIso8601UTC(pluginRes.StartDateTime)
+	detail["EndDateTime"] = times.ToIso8601UTC(pluginRes.EndDateTime)
+	publishEvent(log, events.StepFinished, detail)
+}
+
+// documentEventDetail returns the detail that identifies the document of an event and its command, association or session
+func documentEventDetail(docState *contracts.DocumentState) map[string]string {
+	info := docState.DocumentInformation
+	detail := map[string]string{"DocumentName": info.DocumentName}
+	if info.DocumentVersion != "" {
+		detail["DocumentVersion"] = info.DocumentVersion
+	}
+	if info.RunAsUser != "" {
+		detail["RunAsUser"] = info.RunAsUser
+	}
+	switch docState.DocumentType {
+	case contracts.Association:
+		detail["AssociationId"] = info.AssociationID
+	case contracts.StartSession:
+		detail["SessionId"] = info.DocumentID
+	default:
+		detail["CommandId"] = info.CommandID
+	}
+	return detail
+}
+
+// rebootRequest returns the reboot request of a document with the steps that require the reboot as reason
+func rebootRequest(docState *contracts.DocumentState, res *contracts.DocumentResult) rebooter.RebootRequest {
+	var steps []string
+	for _, pluginState := range docState.InstancePluginsInformation {
+		if pluginRes, found := res.PluginResults[pluginState.Id]; found && pluginRes.Status.IsReboot() {
+			steps = append(steps, pluginState.Id)
+		}
+	}
+	return rebooter.RebootRequest{
+		Source: docState.DocumentInformation.DocumentID,
+		Reason: fmt.Sprintf("document %v requires a reboot after %v", docState.DocumentInformation.DocumentName, strings.Join(steps, ", ")),
+	}
+}
+
 //TODO remove this once CloudWatch plugin is reworked
 //temporary solution on plugins with shared responsibility with agent
-func handleCloudwatchPlugin(context context.T, pluginResults map[string]*contracts.PluginResult, documentID string) {
+func handleCloudwatchPlugin(context context.T, pluginResults map[string]*contracts.PluginResult, documentID string, cancelFlag task.CancelFlag) {
 	log := context.Log()
 	instanceID, _ := platform.InstanceID()
 	//TODO once association service switches to use RC and CW goes away, remove this block
@@ -436,7 +798,7 @@ func handleCloudwatchPlugin(context context.T, pluginResults map[string]*contrac
 				appconfig.DefaultDocumentRootDirName,
 				context.AppConfig().Agent.OrchestrationRootDir)
 			orchestrationDir := fileutil.BuildPath(orchestrationRootDir, documentID)
-			manager.Invoke(log, ID, pluginRes, orchestrationDir)
+			manager.Invoke(log, ID, pluginRes, orchestrationDir, cancelFlag)
 		}
 	}
 
@@ -18,13 +18,19 @@ import (
 	"testing"
 
 	"fmt"
+	"time"
 
 	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/association/compliance/model"
+	"github.com/aws/amazon-ssm-agent/agent/association/compliance/reporter"
 	"github.com/aws/amazon-ssm-agent/agent/context"
 	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/events"
 	"github.com/aws/amazon-ssm-agent/agent/framework/processor/executer"
+	"github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/basicexecuter"
 	executermocks "github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/mock"
 	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/shutdown"
 	"github.com/aws/amazon-ssm-agent/agent/task"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
@@ -38,7 +44,7 @@ func TestEngineProcessor_Submit(t *testing.T) {
 	creator := func(ctx context.T) executer.Executer {
 		return executerMock
 	}
-	sendCommandPoolMock.On("Submit", ctx.Log(), "messageID", mock.Anything).Return(nil)
+	sendCommandPoolMock.On("SubmitWithOptions", ctx.Log(), "messageID", mock.Anything, task.JobOptions{Priority: task.PriorityNormal}).Return(nil)
 	docMock := new(DocumentMgrMock)
 	processor := EngineProcessor{
 		executerCreator: creator,
@@ -48,11 +54,67 @@ func TestEngineProcessor_Submit(t *testing.T) {
 	}
 	docState := contracts.DocumentState{}
 	docState.DocumentInformation.MessageID = "messageID"
+	docMock.On("IsDocumentProcessed", mock.Anything, mock.Anything, "messageID").Return(false)
 	docMock.On("PersistDocumentState", mock.Anything, mock.Anything, mock.Anything, appconfig.DefaultLocationOfPending, docState)
 	processor.Submit(docState)
 	sendCommandPoolMock.AssertExpectations(t)
 }
 
+func TestEngineProcessor_SubmitSkipsProcessedDocument(t *testing.T) {
+	sendCommandPoolMock := new(task.MockedPool)
+	ctx := context.NewMockDefault()
+	docMock := new(DocumentMgrMock)
+	processor := EngineProcessor{
+		sendCommandPool: sendCommandPoolMock,
+		context:         ctx,
+		documentMgr:     docMock,
+	}
+	docState := contracts.DocumentState{}
+	docState.DocumentInformation.MessageID = "messageID"
+	docState.DocumentInformation.InstanceID = "instanceID"
+	docMock.On("IsDocumentProcessed", mock.Anything, "instanceID", "messageID").Return(true)
+	processor.Submit(docState)
+	docMock.AssertExpectations(t)
+	docMock.AssertNotCalled(t, "PersistDocumentState", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	sendCommandPoolMock.AssertNotCalled(t, "Submit", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestEngineProcessor_SubmitDuringShutdown(t *testing.T) {
+	shutdownInProgress = func() bool { return true }
+	defer func() { shutdownInProgress = shutdown.InProgress }()
+
+	sendCommandPoolMock := new(task.MockedPool)
+	ctx := context.NewMockDefault()
+	docMock := new(DocumentMgrMock)
+	processor := EngineProcessor{
+		sendCommandPool: sendCommandPoolMock,
+		context:         ctx,
+		documentMgr:     docMock,
+	}
+	docState := contracts.DocumentState{DocumentType: contracts.SendCommand}
+	docState.DocumentInformation.MessageID = "messageID"
+	docMock.On("PersistDocumentState", mock.Anything, mock.Anything, mock.Anything, appconfig.DefaultLocationOfPending, docState)
+	processor.Submit(docState)
+
+	session := contracts.DocumentState{DocumentType: contracts.StartSession}
+	session.DocumentInformation.MessageID = "sessionID"
+	processor.Submit(session)
+
+	docMock.AssertNumberOfCalls(t, "PersistDocumentState", 1)
+	sendCommandPoolMock.AssertNotCalled(t, "SubmitWithOptions", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestEngineProcessor_CancelAll(t *testing.T) {
+	sendCommandPoolMock := new(task.MockedPool)
+	sendCommandPoolMock.On("CancelAll").Return()
+	processor := EngineProcessor{
+		sendCommandPool: sendCommandPoolMock,
+		context:         context.NewMockDefault(),
+	}
+	processor.CancelAll()
+	sendCommandPoolMock.AssertExpectations(t)
+}
+
 func TestEngineProcessor_Cancel(t *testing.T) {
 	cancelCommandPoolMock := new(task.MockedPool)
 	ctx := context.NewMockDefault()
@@ -131,6 +193,7 @@ func TestProcessCommand(t *testing.T) {
 	}()
 	docMock := new(DocumentMgrMock)
 	docMock.On("MoveDocumentState", mock.Anything, "documentID", "instanceID", appconfig.DefaultLocationOfPending, appconfig.DefaultLocationOfCurrent)
+	docMock.On("MarkDocumentProcessed", mock.Anything, "instanceID", "messageID")
 	docMock.On("RemoveDocumentState", mock.Anything, "documentID", "instanceID", appconfig.DefaultLocationOfCurrent)
 	processCommand(ctx, creator, cancelFlag, resChan, &docState, docMock)
 	executerMock.AssertExpectations(t)
@@ -165,6 +228,7 @@ func TestProcessCommand_Shutdown(t *testing.T) {
 	}()
 	docMock := new(DocumentMgrMock)
 	docMock.On("MoveDocumentState", mock.Anything, "documentID", "instanceID", appconfig.DefaultLocationOfPending, appconfig.DefaultLocationOfCurrent)
+	docMock.On("MarkDocumentProcessed", mock.Anything, "instanceID", "messageID")
 	processCommand(ctx, creator, cancelFlag, resChan, &docState, docMock)
 	executerMock.AssertExpectations(t)
 	docMock.AssertExpectations(t)
@@ -191,6 +255,106 @@ func TestProcessCancelCommand_Success(t *testing.T) {
 
 }
 
+func interruptedDocState(statuses ...contracts.ResultStatus) contracts.DocumentState {
+	docState := contracts.DocumentState{DocumentType: contracts.SendCommand}
+	docState.DocumentInformation.MessageID = "messageID"
+	docState.DocumentInformation.InstanceID = "instanceID"
+	docState.DocumentInformation.DocumentID = "documentID"
+	for i, status := range statuses {
+		plugin := contracts.PluginState{Id: fmt.Sprintf("plugin%d", i), Name: appconfig.PluginNameAwsRunShellScript}
+		plugin.Result.Status = status
+		docState.InstancePluginsInformation = append(docState.InstancePluginsInformation, plugin)
+	}
+	return docState
+}
+
+func mockProcessExists(exists bool) func() {
+	original := processExists
+	processExists = func(log log.T, pid int, createTime time.Time) bool { return exists }
+	return func() { processExists = original }
+}
+
+func TestInterruptedStep(t *testing.T) {
+	defer mockProcessExists(false)()
+	logger := log.NewMockLog()
+
+	docState := interruptedDocState(contracts.ResultStatusSuccess, "", "")
+	step, interrupted := interruptedStep(logger, &docState)
+	assert.True(t, interrupted)
+	assert.Equal(t, 1, step)
+
+	docState = interruptedDocState(contracts.ResultStatusFailed, contracts.ResultStatusSkipped, contracts.ResultStatusNotStarted)
+	step, interrupted = interruptedStep(logger, &docState)
+	assert.True(t, interrupted)
+	assert.Equal(t, 2, step)
+
+	// a requested reboot resumes as usual
+	docState = interruptedDocState(contracts.ResultStatusSuccessAndReboot, "")
+	_, interrupted = interruptedStep(logger, &docState)
+	assert.False(t, interrupted)
+
+	// every step finished, only the final result is missing
+	docState = interruptedDocState(contracts.ResultStatusSuccess)
+	_, interrupted = interruptedStep(logger, &docState)
+	assert.False(t, interrupted)
+
+	docState = interruptedDocState("")
+	docState.DocumentType = contracts.StartSession
+	_, interrupted = interruptedStep(logger, &docState)
+	assert.False(t, interrupted)
+}
+
+func TestInterruptedStep_WorkerAlive(t *testing.T) {
+	defer mockProcessExists(true)()
+	docState := interruptedDocState("")
+	docState.DocumentInformation.ProcInfo.Pid = 1234
+	_, interrupted := interruptedStep(log.NewMockLog(), &docState)
+	assert.False(t, interrupted)
+}
+
+func TestFailInterruptedStep(t *testing.T) {
+	docState := interruptedDocState(contracts.ResultStatusSuccess, "", "")
+	result := failInterruptedStep(log.NewMockLog(), &docState, 1)
+
+	assert.Equal(t, contracts.ResultStatusFailed, result.Status)
+	assert.Equal(t, "", result.LastPlugin)
+	assert.Equal(t, "messageID", result.MessageID)
+	assert.Equal(t, 3, result.NPlugins)
+	assert.Equal(t, contracts.ResultStatusSuccess, result.PluginResults["plugin0"].Status)
+	assert.Equal(t, contracts.ResultStatusInterruptedByRestart, result.PluginResults["plugin1"].Status)
+	assert.Contains(t, result.PluginResults["plugin1"].Error, "interrupted")
+	assert.Equal(t, contracts.ResultStatusSkipped, result.PluginResults["plugin2"].Status)
+	assert.Equal(t, contracts.ResultStatusInterruptedByRestart, docState.InstancePluginsInformation[1].Result.Status)
+	assert.Equal(t, contracts.ResultStatusFailed, docState.DocumentInformation.DocumentStatus)
+}
+
+func TestEngineProcessor_SubmitInterrupted(t *testing.T) {
+	sendCommandPoolMock := new(task.MockedPool)
+	ctx := context.NewMockDefault()
+	docMock := new(DocumentMgrMock)
+	resChan := make(chan contracts.DocumentResult)
+	processor := EngineProcessor{
+		sendCommandPool: sendCommandPoolMock,
+		context:         ctx,
+		documentMgr:     docMock,
+		resChan:         resChan,
+	}
+	var job task.Job
+	sendCommandPoolMock.On("Submit", ctx.Log(), "messageID", mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+		job = args.Get(2).(task.Job)
+	})
+	docMock.On("PersistDocumentState", mock.Anything, "documentID", "instanceID", appconfig.DefaultLocationOfCurrent, mock.Anything)
+	docMock.On("RemoveDocumentState", mock.Anything, "documentID", "instanceID", appconfig.DefaultLocationOfCurrent)
+
+	docState := interruptedDocState("")
+	assert.NoError(t, processor.submitInterrupted(&docState, 0))
+	go job(task.NewChanneledCancelFlag())
+	result := <-resChan
+	assert.Equal(t, contracts.ResultStatusFailed, result.Status)
+	assert.Equal(t, contracts.ResultStatusInterruptedByRestart, result.PluginResults["plugin0"].Status)
+	sendCommandPoolMock.AssertExpectations(t)
+}
+
 type DocumentMgrMock struct {
 	mock.Mock
 }
@@ -214,3 +378,200 @@ func (m *DocumentMgrMock) RemoveDocumentState(log log.T, documentID, instanceID,
 	m.Called(log, documentID, instanceID, location)
 	return
 }
+
+func (m *DocumentMgrMock) ListDocumentStates(log log.T, instanceID, locationFolder string) []string {
+	args := m.Called(log, instanceID, locationFolder)
+	return args.Get(0).([]string)
+}
+
+func (m *DocumentMgrMock) IsDocumentProcessed(log log.T, instanceID, messageID string) bool {
+	args := m.Called(log, instanceID, messageID)
+	return args.Bool(0)
+}
+
+func (m *DocumentMgrMock) MarkDocumentProcessed(log log.T, instanceID, messageID string) {
+	m.Called(log, instanceID, messageID)
+	return
+}
+
+func TestNewEngineProcessor_LowMemory(t *testing.T) {
+	config := appconfig.SsmagentConfig{}
+	config.LowMemory.Enabled = true
+	config.LowMemory.MemoryLimitMB = 64
+	ctx := new(context.Mock)
+	ctx.On("Log").Return(log.NewMockLog())
+	ctx.On("AppConfig").Return(config)
+	ctx.On("With", mock.AnythingOfType("string")).Return(ctx)
+
+	commands := NewEngineProcessor(ctx, 1, 1, []contracts.DocumentType{contracts.SendCommand, contracts.CancelCommand})
+	defer commands.sendCommandPool.Shutdown()
+	defer commands.cancelCommandPool.Shutdown()
+	_, inProc := commands.executerCreator(ctx).(*basicexecuter.BasicExecuter)
+	assert.True(t, inProc)
+
+	sessions := NewEngineProcessor(ctx, 1, 1, []contracts.DocumentType{contracts.StartSession, contracts.TerminateSession})
+	defer sessions.sendCommandPool.Shutdown()
+	defer sessions.cancelCommandPool.Shutdown()
+	_, inProc = sessions.executerCreator(ctx).(*basicexecuter.BasicExecuter)
+	assert.False(t, inProc)
+}
+
+func TestDocumentPriority(t *testing.T) {
+	smallUrgent := contracts.DocumentState{Urgent: true, InstancePluginsInformation: make([]contracts.PluginState, maxUrgentSteps)}
+	largeUrgent := contracts.DocumentState{Urgent: true, InstancePluginsInformation: make([]contracts.PluginState, maxUrgentSteps+1)}
+	session := contracts.DocumentState{DocumentType: contracts.StartSession}
+	bulk := contracts.DocumentState{DocumentType: contracts.SendCommand, InstancePluginsInformation: make([]contracts.PluginState, 1)}
+
+	assert.Equal(t, task.PriorityHigh, documentPriority(&smallUrgent))
+	assert.Equal(t, task.PriorityNormal, documentPriority(&largeUrgent))
+	assert.Equal(t, task.PriorityHigh, documentPriority(&session))
+	assert.Equal(t, task.PriorityNormal, documentPriority(&bulk))
+}
+
+func TestRebootRequest(t *testing.T) {
+	docState := &contracts.DocumentState{
+		DocumentInformation: contracts.DocumentInfo{DocumentID: "commandID", DocumentName: "AWS-InstallWindowsUpdates"},
+		InstancePluginsInformation: []contracts.PluginState{
+			{Id: "download"}, {Id: "install"}, {Id: "configure"},
+		},
+	}
+	res := &contracts.DocumentResult{
+		PluginResults: map[string]*contracts.PluginResult{
+			"download":  {Status: contracts.ResultStatusSuccess},
+			"install":   {Status: contracts.ResultStatusSuccessAndReboot},
+			"configure": {Status: contracts.ResultStatusPassedAndReboot},
+		},
+	}
+
+	request := rebootRequest(docState, res)
+	assert.Equal(t, "commandID", request.Source)
+	assert.Equal(t, "document AWS-InstallWindowsUpdates requires a reboot after install, configure", request.Reason)
+}
+
+func TestReportCustomCompliance(t *testing.T) {
+	var reports []model.CustomComplianceReport
+	reportCompliance = func(log log.T, report model.CustomComplianceReport) (model.CustomComplianceSummary, error) {
+		reports = append(reports, report)
+		return model.SummarizeCustomCompliance(report), nil
+	}
+	defer func() { reportCompliance = reporter.Report }()
+
+	endTime := time.Date(2020, time.June, 6, 10, 0, 0, 0, time.UTC)
+	items := []model.CustomComplianceItem{{Id: "1.1.1", Severity: "HIGH", Status: model.NON_COMPLIANT}}
+	docState := &contracts.DocumentState{
+		DocumentType:        contracts.Association,
+		DocumentInformation: contracts.DocumentInfo{AssociationID: "associationID", DocumentName: "Custom-CISBenchmark"},
+		InstancePluginsInformation: []contracts.PluginState{
+			{Id: "scan"}, {Id: "report"}, {Id: "invalid"}, {Id: "updates"},
+		},
+	}
+	res := &contracts.DocumentResult{
+		PluginResults: map[string]*contracts.PluginResult{
+			"scan": {PluginName: appconfig.PluginNameAwsRunShellScript, Status: contracts.ResultStatusSuccess},
+			"report": {
+				PluginName:  appconfig.PluginNameAwsPutComplianceItems,
+				Status:      contracts.ResultStatusSuccess,
+				EndDateTime: endTime,
+				Output:      map[string]interface{}{"ComplianceType": "Custom:CIS", "Items": items, "Summary": map[string]interface{}{}},
+			},
+			"invalid": {PluginName: appconfig.PluginNameAwsPutComplianceItems, Status: contracts.ResultStatusFailed},
+			"updates": {
+				PluginName: appconfig.PluginNameAwsScanUpdates,
+				Status:     contracts.ResultStatusSuccess,
+				Output:     map[string]interface{}{"Manager": "apt", "Updates": []interface{}{}},
+			},
+		},
+	}
+
+	reportCustomCompliance(context.NewMockDefault(), docState, res)
+	assert.Equal(t, []model.CustomComplianceReport{{
+		ComplianceType: "Custom:CIS",
+		ExecutionType:  model.ExecutionTypeAssociation,
+		ExecutionId:    "associationID",
+		ExecutionTime:  endTime,
+		Items:          items,
+	}}, reports)
+
+	reports = nil
+	docState.DocumentType = contracts.SendCommand
+	docState.DocumentInformation.CommandID = "commandID"
+	reportCustomCompliance(context.NewMockDefault(), docState, res)
+	assert.Len(t, reports, 1)
+	assert.Equal(t, model.ExecutionTypeCommand, reports[0].ExecutionType)
+	assert.Equal(t, "commandID", reports[0].ExecutionId)
+}
+
+func TestPublishDocumentEvent(t *testing.T) {
+	var eventTypes []string
+	var details []map[string]string
+	publishEvent = func(log log.T, eventType string, detail map[string]string) {
+		eventTypes = append(eventTypes, eventType)
+		details = append(details, detail)
+	}
+	defer func() { publishEvent = events.Publish }()
+
+	docState := &contracts.DocumentState{
+		DocumentType: contracts.StartSession,
+		DocumentInformation: contracts.DocumentInfo{
+			DocumentID:   "session-id",
+			DocumentName: "SSM-SessionManagerRunShell",
+			RunAsUser:    "dba",
+		},
+	}
+	publishDocumentEvent(log.NewMockLog(), docState, nil)
+	publishDocumentEvent(log.NewMockLog(), docState, &contracts.DocumentResult{Status: contracts.ResultStatusSuccess})
+	docState.DocumentType = contracts.SendCommand
+	docState.DocumentInformation = contracts.DocumentInfo{CommandID: "command-id", DocumentName: "AWS-RunShellScript", DocumentVersion: "1"}
+	publishDocumentEvent(log.NewMockLog(), docState, &contracts.DocumentResult{Status: contracts.ResultStatusFailed})
+
+	assert.Equal(t, []string{events.SessionOpened, events.SessionClosed, events.CommandFinished}, eventTypes)
+	assert.Equal(t, map[string]string{"DocumentName": "SSM-SessionManagerRunShell", "RunAsUser": "dba", "SessionId": "session-id"}, details[0])
+	assert.Equal(t, string(contracts.ResultStatusSuccess), details[1]["Status"])
+	assert.Equal(t, map[string]string{
+		"DocumentName":    "AWS-RunShellScript",
+		"DocumentVersion": "1",
+		"CommandId":       "command-id",
+		"Status":          string(contracts.ResultStatusFailed),
+	}, details[2])
+}
+
+func TestPublishStepEvent(t *testing.T) {
+	var eventTypes []string
+	var details []map[string]string
+	publishEvent = func(log log.T, eventType string, detail map[string]string) {
+		eventTypes = append(eventTypes, eventType)
+		details = append(details, detail)
+	}
+	defer func() { publishEvent = events.Publish }()
+
+	startTime := time.Date(2020, time.June, 6, 10, 0, 0, 0, time.UTC)
+	docState := &contracts.DocumentState{
+		DocumentType:        contracts.Association,
+		DocumentInformation: contracts.DocumentInfo{AssociationID: "association-id", DocumentName: "Custom-Patch"},
+	}
+	res := &contracts.DocumentResult{
+		LastPlugin: "install",
+		PluginResults: map[string]*contracts.PluginResult{
+			"install": {
+				PluginName:    appconfig.PluginNameAwsRunShellScript,
+				Status:        contracts.ResultStatusFailed,
+				StartDateTime: startTime,
+				EndDateTime:   startTime.Add(time.Minute),
+			},
+		},
+	}
+	publishStepEvent(log.NewMockLog(), docState, res)
+	res.LastPlugin = "missing"
+	publishStepEvent(log.NewMockLog(), docState, res)
+
+	assert.Equal(t, []string{events.StepFinished}, eventTypes)
+	assert.Equal(t, map[string]string{
+		"DocumentName":  "Custom-Patch",
+		"AssociationId": "association-id",
+		"StepName":      "install",
+		"PluginName":    appconfig.PluginNameAwsRunShellScript,
+		"Status":        string(contracts.ResultStatusFailed),
+		"StartDateTime": "2020-06-06T10:00:00.000Z",
+		"EndDateTime":   "2020-06-06T10:01:00.000Z",
+	}, details[0])
+}
@@ -0,0 +1,117 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+package runpluginutil
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/version"
+)
+
+// maxCrashReports is how many crash reports are kept, the oldest are removed first
+const maxCrashReports = 20
+
+var crashReportDir = filepath.Join(log.LogDir(), appconfig.CrashReportsDirName)
+
+// crashReport describes the panic of a plugin for support. It does not carry the configuration of the step,
+// which can hold secrets.
+type crashReport struct {
+	Time          time.Time
+	AgentVersion  string
+	PluginName    string
+	PluginVersion string
+	StepName      string `json:",omitempty"`
+	MessageID     string `json:",omitempty"`
+	GoVersion     string
+	Platform      string
+	Panic         string
+	Stack         string
+}
+
+// newCrashReport describes the panic of a plugin, the plugins built into the agent have the version of the agent
+func newCrashReport(pluginName, stepName, messageID string, panicValue interface{}, stack []byte) crashReport {
+	return crashReport{
+		Time:          time.Now().UTC(),
+		AgentVersion:  version.Version,
+		PluginName:    pluginName,
+		PluginVersion: version.Version,
+		StepName:      stepName,
+		MessageID:     messageID,
+		GoVersion:     runtime.Version(),
+		Platform:      runtime.GOOS + "/" + runtime.GOARCH,
+		Panic:         fmt.Sprint(panicValue),
+		Stack:         string(stack),
+	}
+}
+
+// message is the error of the failed step, path is the crash report file when it was written
+func (report crashReport) message(path string) string {
+	message := fmt.Sprintf("Plugin %v crashed with message %v! Agent version %v, plugin version %v.\n%v",
+		report.PluginName, report.Panic, report.AgentVersion, report.PluginVersion, report.Stack)
+	if path != "" {
+		message += "\nCrash report: " + path
+	}
+	return message
+}
+
+// handleCrash logs the panic of a plugin and writes its crash report, it returns the error of the failed step
+func handleCrash(log log.T, report crashReport) string {
+	path, err := writeCrashReport(report)
+	if err != nil {
+		log.Warnf("failed to write the crash report of plugin %v: %v", report.PluginName, err)
+	}
+	message := report.message(path)
+	log.Error(message)
+	return message
+}
+
+// writeCrashReport writes a crash report to the crash report folder and removes the oldest reports beyond maxCrashReports
+func writeCrashReport(report crashReport) (path string, err error) {
+	content, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	if err = os.MkdirAll(crashReportDir, appconfig.ReadWriteExecuteAccess); err != nil {
+		return "", err
+	}
+	// the time leads the name so the reports sort by age
+	name := fmt.Sprintf("%v_%v.json", report.Time.Format("20060102T150405.000000000Z"), strings.Replace(report.PluginName, ":", "", -1))
+	path = filepath.Join(crashReportDir, name)
+	if err = ioutil.WriteFile(path, content, appconfig.ReadWriteAccess); err != nil {
+		return "", err
+	}
+	pruneCrashReports()
+	return path, nil
+}
+
+// pruneCrashReports removes the oldest crash reports beyond maxCrashReports
+func pruneCrashReports() {
+	reports, err := filepath.Glob(filepath.Join(crashReportDir, "*.json"))
+	if err != nil || len(reports) <= maxCrashReports {
+		return
+	}
+	sort.Strings(reports)
+	for _, report := range reports[:len(reports)-maxCrashReports] {
+		os.Remove(report)
+	}
+}
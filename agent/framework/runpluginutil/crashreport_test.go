@@ -0,0 +1,91 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+package runpluginutil
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/context"
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/task"
+	"github.com/aws/amazon-ssm-agent/agent/version"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestRunPluginPanicFailsStep(t *testing.T) {
+	dir, err := ioutil.TempDir("", "crashreports")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	defer func(original string) { crashReportDir = original }(crashReportDir)
+	crashReportDir = filepath.Join(dir, "crashreports")
+
+	ctx := context.NewMockDefault()
+	plugin := new(PluginMock)
+	plugin.On("Execute", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Run(func(mock.Arguments) {
+		panic("nil map")
+	})
+	factory := new(PluginFactoryMock)
+	factory.On("Create", mock.Anything).Return(plugin, nil)
+	config := contracts.Configuration{PluginID: testPlugin1, PluginName: testPlugin1, MessageId: "message"}
+
+	res := runPlugin(ctx, factory, testPlugin1, config, task.NewChanneledCancelFlag(), contracts.IOConfiguration{OrchestrationDirectory: dir})
+	assert.Equal(t, contracts.ResultStatusFailed, res.Status)
+	assert.Equal(t, 1, res.Code)
+	assert.True(t, strings.Contains(res.StandardError, "Plugin plugin1 crashed with message nil map! Agent version "+version.Version))
+	assert.True(t, strings.Contains(res.StandardError, "crashreport_test.go"), "the step error should carry the stack")
+
+	reports, err := filepath.Glob(filepath.Join(crashReportDir, "*_plugin1.json"))
+	assert.NoError(t, err)
+	if assert.Len(t, reports, 1) {
+		assert.True(t, strings.Contains(res.StandardError, "Crash report: "+reports[0]))
+		content, err := ioutil.ReadFile(reports[0])
+		assert.NoError(t, err)
+		var report crashReport
+		assert.NoError(t, json.Unmarshal(content, &report))
+		assert.Equal(t, testPlugin1, report.PluginName)
+		assert.Equal(t, version.Version, report.PluginVersion)
+		assert.Equal(t, "message", report.MessageID)
+		assert.Equal(t, "nil map", report.Panic)
+		assert.NotEmpty(t, report.Stack)
+	}
+}
+
+func TestWriteCrashReportKeepsRecentReports(t *testing.T) {
+	dir, err := ioutil.TempDir("", "crashreports")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	defer func(original string) { crashReportDir = original }(crashReportDir)
+	crashReportDir = dir
+
+	start := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	var paths []string
+	for i := 0; i < maxCrashReports+2; i++ {
+		report := newCrashReport("aws:runShellScript", "", "", "boom", nil)
+		report.Time = start.Add(time.Duration(i) * time.Second)
+		path, err := writeCrashReport(report)
+		assert.NoError(t, err)
+		paths = append(paths, path)
+	}
+	assert.Equal(t, "20200102T030405.000000000Z_awsrunShellScript.json", filepath.Base(paths[0]))
+
+	reports, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	assert.NoError(t, err)
+	assert.Equal(t, paths[2:], reports)
+}
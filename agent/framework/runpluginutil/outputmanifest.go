@@ -0,0 +1,80 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package runpluginutil
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/fileutil"
+	"github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/iohandler"
+	"github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/iohandler/iomodule"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/pluginutil"
+)
+
+// truncateStream truncates a stream of the plugin output to fit in the reply with the configured strategy
+func truncateStream(output string, strategy string) string {
+	pluginConfig := iohandler.DefaultOutputConfig()
+	return pluginutil.TruncateString(output, pluginConfig.MaxStdoutLength, pluginConfig.OutputTruncatedSuffix, strategy)
+}
+
+// newOutputManifest describes where the full standard output and error of a step were written when they do not fit in
+// the reply, it returns nil when neither stream is truncated.
+// The locations follow the paths used by iohandler.Init for the step. The stats describe the complete streams when the
+// io handler only kept their ends in memory.
+func newOutputManifest(config appconfig.SsmagentConfig, ioConfig contracts.IOConfiguration, pluginName, stepName, stdout, stderr string, stdoutStats, stderrStats iomodule.StreamStats) *contracts.OutputManifest {
+	pluginConfig := iohandler.DefaultOutputConfig()
+	strategy := config.Output.TruncationStrategy
+	manifest := &contracts.OutputManifest{TruncationStrategy: strategy}
+
+	newStreamManifest := func(content string, stats iomodule.StreamStats, fileName string) *contracts.OutputStreamManifest {
+		truncated := truncateStream(content, strategy)
+		if truncated == content {
+			return nil
+		}
+		stream := &contracts.OutputStreamManifest{
+			TotalBytes:    stats.TotalBytes,
+			ReturnedBytes: len(truncated),
+			Sha256:        stats.Sha256,
+			LocalPath:     fileutil.BuildPath(ioConfig.OrchestrationDirectory, pluginName, stepName, fileName),
+		}
+		if stats.TotalBytes <= len(content) {
+			checksum := sha256.Sum256([]byte(content))
+			stream.TotalBytes = len(content)
+			stream.Sha256 = hex.EncodeToString(checksum[:])
+		}
+
+		outputKey := fileutil.BuildS3Path(fileutil.BuildS3Path(ioConfig.OutputS3KeyPrefix, pluginName, stepName), fileName)
+		if config.Output.WebhookUrl != "" {
+			stream.WebhookKey = outputKey
+		} else if ioConfig.OutputS3BucketName != "" {
+			stream.S3Uri = fmt.Sprintf("s3://%v/%v", ioConfig.OutputS3BucketName, outputKey)
+		}
+		if ioConfig.CloudWatchConfig.LogGroupName != "" {
+			stream.CloudWatchLogGroup = ioConfig.CloudWatchConfig.LogGroupName
+			stream.CloudWatchLogStream = fmt.Sprintf("%s/%s", ioConfig.CloudWatchConfig.LogStreamPrefix, fileName)
+		}
+		return stream
+	}
+
+	manifest.StandardOutput = newStreamManifest(stdout, stdoutStats, pluginConfig.StdoutFileName)
+	manifest.StandardError = newStreamManifest(stderr, stderrStats, pluginConfig.StderrFileName)
+	if manifest.StandardOutput == nil && manifest.StandardError == nil {
+		return nil
+	}
+	return manifest
+}
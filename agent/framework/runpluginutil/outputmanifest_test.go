@@ -0,0 +1,90 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package runpluginutil
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/iohandler"
+	"github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/iohandler/iomodule"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewOutputManifest_NotTruncated(t *testing.T) {
+	config := appconfig.DefaultConfig()
+	assert.Nil(t, newOutputManifest(config, contracts.IOConfiguration{}, "aws:runShellScript", "step", "output", "error", iomodule.StreamStats{}, iomodule.StreamStats{}))
+}
+
+func TestNewOutputManifest_S3AndCloudWatch(t *testing.T) {
+	config := appconfig.DefaultConfig()
+	config.Output.TruncationStrategy = appconfig.OutputTruncationHeadTail
+	ioConfig := contracts.IOConfiguration{
+		OrchestrationDirectory: filepath.Join("orchestration", "command"),
+		OutputS3BucketName:     "bucket",
+		OutputS3KeyPrefix:      "prefix/command",
+	}
+	ioConfig.CloudWatchConfig.LogGroupName = "group"
+	ioConfig.CloudWatchConfig.LogStreamPrefix = "command/aws-runShellScript"
+	stdout := strings.Repeat("o", iohandler.DefaultOutputConfig().MaxStdoutLength+100)
+
+	manifest := newOutputManifest(config, ioConfig, "aws:runShellScript", "step", stdout, "error", iomodule.StreamStats{}, iomodule.StreamStats{})
+
+	assert.NotNil(t, manifest)
+	assert.Equal(t, appconfig.OutputTruncationHeadTail, manifest.TruncationStrategy)
+	assert.Nil(t, manifest.StandardError)
+	checksum := sha256.Sum256([]byte(stdout))
+	assert.Equal(t, contracts.OutputStreamManifest{
+		TotalBytes:          len(stdout),
+		ReturnedBytes:       iohandler.DefaultOutputConfig().MaxStdoutLength,
+		Sha256:              hex.EncodeToString(checksum[:]),
+		LocalPath:           filepath.Join("orchestration", "command", "awsrunShellScript", "step", "stdout"),
+		S3Uri:               "s3://bucket/prefix/command/awsrunShellScript/step/stdout",
+		CloudWatchLogGroup:  "group",
+		CloudWatchLogStream: "command/aws-runShellScript/stdout",
+	}, *manifest.StandardOutput)
+	assert.Equal(t, len(truncateStream(stdout, manifest.TruncationStrategy)), manifest.StandardOutput.ReturnedBytes)
+}
+
+func TestNewOutputManifest_Webhook(t *testing.T) {
+	config := appconfig.DefaultConfig()
+	config.Output.WebhookUrl = "https://hooks.example.com/ssm/output"
+	ioConfig := contracts.IOConfiguration{OutputS3BucketName: "bucket", OutputS3KeyPrefix: "prefix"}
+	stderr := strings.Repeat("e", iohandler.DefaultOutputConfig().MaxStdoutLength)
+
+	manifest := newOutputManifest(config, ioConfig, "aws:runShellScript", "step", "", stderr, iomodule.StreamStats{}, iomodule.StreamStats{})
+
+	assert.Nil(t, manifest.StandardOutput)
+	assert.Equal(t, "prefix/awsrunShellScript/step/stderr", manifest.StandardError.WebhookKey)
+	assert.Equal(t, "", manifest.StandardError.S3Uri)
+}
+
+func TestNewOutputManifest_CapturedStream(t *testing.T) {
+	config := appconfig.DefaultConfig()
+	ioConfig := contracts.IOConfiguration{OrchestrationDirectory: "orchestration"}
+	// the io handler only kept the ends of a 1 GB stream
+	stdout := strings.Repeat("o", 2*iohandler.DefaultOutputConfig().MaxStdoutLength)
+	stats := iomodule.StreamStats{TotalBytes: 1 << 30, Sha256: "5c6e2f"}
+
+	manifest := newOutputManifest(config, ioConfig, "aws:runShellScript", "step", stdout, "", stats, iomodule.StreamStats{})
+
+	assert.Equal(t, 1<<30, manifest.StandardOutput.TotalBytes)
+	assert.Equal(t, "5c6e2f", manifest.StandardOutput.Sha256)
+	assert.Equal(t, iohandler.DefaultOutputConfig().MaxStdoutLength, manifest.StandardOutput.ReturnedBytes)
+}
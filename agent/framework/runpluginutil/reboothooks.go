@@ -0,0 +1,108 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package runpluginutil run plugin utility functions without referencing the actually plugin impl packages
+package runpluginutil
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/aws/amazon-ssm-agent/agent/context"
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/executers"
+	"github.com/aws/amazon-ssm-agent/agent/fileutil"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/pluginutil"
+	"github.com/aws/amazon-ssm-agent/agent/task"
+)
+
+const (
+	preRebootHook  = "preReboot"
+	postRebootHook = "postReboot"
+
+	// rebootHookTimeoutSeconds bounds how long a reboot hook runs
+	rebootHookTimeoutSeconds = 600
+)
+
+var rebootHookExecuter executers.T = executers.ShellCommandExecuter{}
+
+// preRebootHooks returns the commands of the step to run before the reboot it requires
+func preRebootHooks(config contracts.Configuration) []string {
+	if config.RebootHooks == nil {
+		return nil
+	}
+	return config.RebootHooks.PreReboot
+}
+
+// postRebootHooks returns the commands of the step to run after the reboot it required
+func postRebootHooks(config contracts.Configuration) []string {
+	if config.RebootHooks == nil {
+		return nil
+	}
+	return config.RebootHooks.PostReboot
+}
+
+// runRebootHook runs the commands of a reboot hook of the step in the scratch directory of the document and returns
+// their output, the hook fails when the commands exit with a non zero code.
+func runRebootHook(context context.T, hook string, commands []string, config contracts.Configuration, cancelFlag task.CancelFlag) (output string, err error) {
+	if len(commands) == 0 {
+		return "", nil
+	}
+	log := context.Log()
+	log.Infof("Running %v hook of step %v", hook, config.PluginID)
+
+	hookDir := fileutil.BuildPath(config.OrchestrationDirectory, hook)
+	if err = fileutil.MakeDirsWithExecuteAccess(hookDir); err != nil {
+		return "", fmt.Errorf("failed to create %v hook directory %v: %v", hook, hookDir, err)
+	}
+	scriptPath := filepath.Join(hookDir, "_"+hook+rebootHookScriptExtension)
+	if err = pluginutil.CreateScriptFile(log, scriptPath, commands, rebootHookByteOrderMark); err != nil {
+		return "", fmt.Errorf("failed to create %v hook script: %v", hook, err)
+	}
+
+	workingDir := config.DefaultWorkingDirectory
+	env := map[string]string{}
+	if scratchDir := pluginutil.ScratchDirectory(config.OrchestrationDirectory); scratchDir != "" {
+		if err = fileutil.MakeDirs(scratchDir); err != nil {
+			return "", fmt.Errorf("failed to create the scratch directory of the document %v: %v", scratchDir, err)
+		}
+		workingDir = scratchDir
+		env[pluginutil.ScratchDirEnvVar] = scratchDir
+	}
+
+	var stdout, stderr bytes.Buffer
+	args := append(append([]string{}, pluginutil.GetShellArguments()...), scriptPath)
+	exitCode, err := rebootHookExecuter.NewExecute(log, workingDir, &stdout, &stderr, cancelFlag, rebootHookTimeoutSeconds,
+		pluginutil.GetShellCommand(), args, env)
+	output = strings.TrimSpace(stdout.String() + stderr.String())
+	if err == nil && exitCode != 0 {
+		err = fmt.Errorf("exit code %v", exitCode)
+	}
+	if err != nil {
+		return output, fmt.Errorf("%v hook of step %v failed: %v %v", hook, config.PluginID, err, stderr.String())
+	}
+	return output, nil
+}
+
+// appendHookOutput appends the output of a reboot hook to the standard output of the step
+func appendHookOutput(res *contracts.PluginResult, hook string, output string) {
+	if output == "" {
+		return
+	}
+	if res.StandardOutput != "" && !strings.HasSuffix(res.StandardOutput, "\n") {
+		res.StandardOutput += "\n"
+	}
+	res.StandardOutput += fmt.Sprintf("%v hook:\n%v\n", hook, output)
+}
@@ -0,0 +1,122 @@
+// +build darwin freebsd linux netbsd openbsd
+
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package runpluginutil
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/context"
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/task"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// rebootHookPlugins returns a document whose first step requires a reboot with the given hooks
+func rebootHookPlugins(t *testing.T, hooks *contracts.RebootHooks) (plugins []contracts.PluginState, cleanup func()) {
+	dir, err := ioutil.TempDir("", "reboothooks")
+	assert.NoError(t, err)
+	plugins = []contracts.PluginState{
+		{Name: testPlugin1, Id: testPlugin1, Configuration: contracts.Configuration{
+			PluginID:               testPlugin1,
+			PluginName:             testPlugin1,
+			OrchestrationDirectory: filepath.Join(dir, testPlugin1),
+			RebootIfRequired:       true,
+			RebootHooks:            hooks,
+		}},
+		{Name: testPlugin2, Id: testPlugin2, Configuration: contracts.Configuration{
+			PluginID:               testPlugin2,
+			PluginName:             testPlugin2,
+			OrchestrationDirectory: filepath.Join(dir, testPlugin2),
+		}},
+	}
+	return plugins, func() { os.RemoveAll(dir) }
+}
+
+func runDocument(ctx context.T, plugins []contracts.PluginState, registry PluginRegistry) map[string]*contracts.PluginResult {
+	ch := make(chan contracts.PluginResult, len(plugins))
+	outputs := RunPlugins(ctx, plugins, contracts.IOConfiguration{}, registry, ch, task.NewChanneledCancelFlag())
+	close(ch)
+	drainResults(ch)
+	return outputs
+}
+
+func TestRunPluginsRebootHooksKeepDataAcrossReboot(t *testing.T) {
+	setIsSupportedMock()
+	defer restoreIsSupported()
+	ctx := context.NewMockDefault()
+	registry, pluginInstances := setupRebootPlugins(ctx,
+		map[string]contracts.ResultStatus{testPlugin1: contracts.ResultStatusSuccessAndReboot, testPlugin2: contracts.ResultStatusSuccess},
+		map[string]int{testPlugin1: appconfig.RebootExitCode})
+	plugins, cleanup := rebootHookPlugins(t, &contracts.RebootHooks{
+		PreReboot:  []string{`echo "kernel-5.4" > "$SSM_DOCUMENT_SCRATCH/checkpoint"`, "echo checkpointed"},
+		PostReboot: []string{`grep -q kernel-5.4 checkpoint`, "echo validated"},
+	})
+	defer cleanup()
+
+	outputs := runDocument(ctx, plugins, registry)
+	assert.Equal(t, contracts.ResultStatusPassedAndReboot, outputs[testPlugin1].Status, outputs[testPlugin1].Error)
+	assert.Equal(t, 1, outputs[testPlugin1].RebootCount)
+	assert.Contains(t, outputs[testPlugin1].StandardOutput, "checkpointed")
+
+	plugins[0].Result = *outputs[testPlugin1]
+	outputs = runDocument(ctx, plugins, registry)
+	assert.Equal(t, contracts.ResultStatusSuccess, outputs[testPlugin1].Status, outputs[testPlugin1].Error)
+	assert.Contains(t, outputs[testPlugin1].StandardOutput, "validated")
+	assert.Equal(t, contracts.ResultStatusSuccess, outputs[testPlugin2].Status)
+	pluginInstances[testPlugin2].AssertNumberOfCalls(t, "Execute", 1)
+}
+
+func TestRunPluginsFailedPreRebootHookFailsStep(t *testing.T) {
+	setIsSupportedMock()
+	defer restoreIsSupported()
+	ctx := context.NewMockDefault()
+	registry, _ := setupRebootPlugins(ctx,
+		map[string]contracts.ResultStatus{testPlugin1: contracts.ResultStatusSuccessAndReboot, testPlugin2: contracts.ResultStatusSuccess},
+		map[string]int{testPlugin1: appconfig.RebootExitCode})
+	plugins, cleanup := rebootHookPlugins(t, &contracts.RebootHooks{PreReboot: []string{"exit 4"}})
+	defer cleanup()
+
+	outputs := runDocument(ctx, plugins, registry)
+	assert.Equal(t, contracts.ResultStatusFailed, outputs[testPlugin1].Status)
+	assert.Equal(t, 0, outputs[testPlugin1].RebootCount)
+	assert.Contains(t, outputs[testPlugin1].Error, "preReboot hook of step")
+	assert.Equal(t, contracts.ResultStatusSuccess, outputs[testPlugin2].Status, "the document continues without reboot")
+}
+
+func TestRunPluginsFailedPostRebootHookSkipsDocument(t *testing.T) {
+	setIsSupportedMock()
+	defer restoreIsSupported()
+	ctx := context.NewMockDefault()
+	registry, pluginInstances := setupRebootPlugins(ctx,
+		map[string]contracts.ResultStatus{testPlugin1: contracts.ResultStatusSuccessAndReboot, testPlugin2: contracts.ResultStatusSuccess},
+		map[string]int{testPlugin1: appconfig.RebootExitCode})
+	plugins, cleanup := rebootHookPlugins(t, &contracts.RebootHooks{PostReboot: []string{"test -f missing-checkpoint"}})
+	defer cleanup()
+
+	outputs := runDocument(ctx, plugins, registry)
+	plugins[0].Result = *outputs[testPlugin1]
+	outputs = runDocument(ctx, plugins, registry)
+
+	assert.Equal(t, contracts.ResultStatusFailed, outputs[testPlugin1].Status)
+	assert.Contains(t, outputs[testPlugin1].Error, "postReboot hook of step")
+	assert.Equal(t, contracts.ResultStatusSkipped, outputs[testPlugin2].Status)
+	pluginInstances[testPlugin2].AssertNotCalled(t, "Execute", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
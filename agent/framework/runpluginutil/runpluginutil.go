@@ -15,8 +15,11 @@
 package runpluginutil
 
 import (
+	"errors"
 	"fmt"
+	"runtime/debug"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/aws/amazon-ssm-agent/agent/appconfig"
@@ -56,22 +59,25 @@ var SSMPluginRegistry PluginRegistry
 // This allows us to differentiate between the case where a document asks for a plugin that exists but isn't supported on this platform
 // and the case where a plugin name isn't known at all to this version of the agent (and the user should probably upgrade their agent)
 var allPlugins = map[string]struct{}{
-	appconfig.PluginNameAwsAgentUpdate:         {},
-	appconfig.PluginNameAwsApplications:        {},
-	appconfig.PluginNameAwsConfigureDaemon:     {},
-	appconfig.PluginNameAwsConfigurePackage:    {},
-	appconfig.PluginNameAwsPowerShellModule:    {},
-	appconfig.PluginNameAwsRunPowerShellScript: {},
-	appconfig.PluginNameAwsRunShellScript:      {},
-	appconfig.PluginNameAwsSoftwareInventory:   {},
-	appconfig.PluginNameCloudWatch:             {},
-	appconfig.PluginNameConfigureDocker:        {},
-	appconfig.PluginNameDockerContainer:        {},
-	appconfig.PluginNameDomainJoin:             {},
-	appconfig.PluginEC2ConfigUpdate:            {},
-	appconfig.PluginNameRefreshAssociation:     {},
-	appconfig.PluginDownloadContent:            {},
-	appconfig.PluginRunDocument:                {},
+	appconfig.PluginNameAwsAgentUpdate:              {},
+	appconfig.PluginNameAwsApplications:             {},
+	appconfig.PluginNameAwsConfigureDaemon:          {},
+	appconfig.PluginNameAwsConfigureCloudWatchAgent: {},
+	appconfig.PluginNameAwsConfigurePackage:         {},
+	appconfig.PluginNameAwsPowerShellModule:         {},
+	appconfig.PluginNameAwsPutComplianceItems:       {},
+	appconfig.PluginNameAwsRunPowerShellScript:      {},
+	appconfig.PluginNameAwsRunShellScript:           {},
+	appconfig.PluginNameAwsScanUpdates:              {},
+	appconfig.PluginNameAwsSoftwareInventory:        {},
+	appconfig.PluginNameCloudWatch:                  {},
+	appconfig.PluginNameConfigureDocker:             {},
+	appconfig.PluginNameDockerContainer:             {},
+	appconfig.PluginNameDomainJoin:                  {},
+	appconfig.PluginEC2ConfigUpdate:                 {},
+	appconfig.PluginNameRefreshAssociation:          {},
+	appconfig.PluginDownloadContent:                 {},
+	appconfig.PluginRunDocument:                     {},
 }
 
 // allSessionPlugins is the list of all known session plugins.
@@ -81,8 +87,29 @@ var allSessionPlugins = map[string]struct{}{
 	appconfig.PluginNamePort:                {},
 }
 
+// externalPlugins is the list of plugins shipped as separate executables and registered from the agent configuration.
+var externalPlugins = map[string]struct{}{}
+
+var externalPluginsLock sync.RWMutex
+
+// RegisterExternalPlugin makes a plugin configured as a separate executable known on every platform.
+func RegisterExternalPlugin(pluginName string) {
+	externalPluginsLock.Lock()
+	defer externalPluginsLock.Unlock()
+	externalPlugins[pluginName] = struct{}{}
+}
+
+// isExternalPlugin returns true if the plugin was registered from the agent configuration
+func isExternalPlugin(pluginName string) bool {
+	externalPluginsLock.RLock()
+	defer externalPluginsLock.RUnlock()
+	_, known := externalPlugins[pluginName]
+	return known
+}
+
 // Assign method to global variables to allow unittest to override
 var isSupportedPlugin = IsPluginSupportedForCurrentPlatform
+var isRebootPending = rebootPending
 
 // TODO remove executionID and creation date
 // RunPlugins executes a set of plugins. The plugin configurations are given in a map with pluginId as key.
@@ -102,6 +129,15 @@ func RunPlugins(
 	//Contains the logStreamPrefix without the pluginID
 	logStreamPrefix := ioConfig.CloudWatchConfig.LogStreamPrefix
 
+	// a failed post reboot hook skips the rest of the document
+	var postRebootErr error
+
+	// the reboots of the steps before a reboot are kept in the document state
+	reboots := 0
+	for _, pluginState := range plugins {
+		reboots += pluginState.Result.RebootCount
+	}
+
 	for _, pluginState := range plugins {
 		pluginID := pluginState.Id     // the identifier of the plugin
 		pluginName := pluginState.Name // the name of the plugin
@@ -127,6 +163,19 @@ func RunPlugins(
 			context.Log().Debugf("plugin - %v just experienced reboot, reset to InProgress...",
 				pluginName)
 			pluginOutput.Status = contracts.ResultStatusInProgress
+			if postRebootErr = runPostRebootHook(context, pluginState.Configuration, cancelFlag, &pluginOutput); postRebootErr != nil {
+				resChan <- pluginOutput
+				continue
+			}
+
+		case contracts.ResultStatusPassedAndReboot:
+			context.Log().Debugf("plugin - %v completed before the reboot it required, resuming with the next plugin...",
+				pluginName)
+			pluginOutput.Status = contracts.ResultStatusSuccess
+			if postRebootErr = runPostRebootHook(context, pluginState.Configuration, cancelFlag, &pluginOutput); postRebootErr != nil {
+				resChan <- pluginOutput
+			}
+			continue
 
 		default:
 			context.Log().Debugf("plugin - %v already executed, skipping...",
@@ -134,6 +183,14 @@ func RunPlugins(
 			continue
 		}
 
+		if postRebootErr != nil {
+			pluginOutput.Status = contracts.ResultStatusSkipped
+			pluginOutput.Output = fmt.Sprintf("Step was skipped since %v", postRebootErr)
+			pluginOutput.EndDateTime = time.Now()
+			resChan <- pluginOutput
+			continue
+		}
+
 		context.Log().Debugf("Executing plugin - %v", pluginName)
 
 		// populate plugin start time and status
@@ -176,7 +233,26 @@ func RunPlugins(
 		switch operation {
 		case executeStep:
 			context.Log().Infof("Running plugin %s", pluginName)
+			wasRebootPending := configuration.RebootIfRequired && isRebootPending(context.Log())
 			r = runPlugin(context, pluginFactory, pluginName, configuration, cancelFlag, ioConfig)
+			r.Status = rebootRequiredStatus(context.Log(), configuration, r, wasRebootPending)
+			if r.Status.IsReboot() {
+				if reboots >= maxReboots(configuration) {
+					r.Status = contracts.ResultStatusFailed
+					r.Error = fmt.Sprintf("step %v requires a reboot, but the document already rebooted the instance %v times, its maximum",
+						pluginID, reboots)
+					context.Log().Error(r.Error)
+				} else if output, err := runRebootHook(context, preRebootHook, preRebootHooks(configuration), configuration, cancelFlag); err != nil {
+					appendHookOutput(&r, preRebootHook, output)
+					r.Status = contracts.ResultStatusFailed
+					r.Error = err.Error()
+					context.Log().Error(r.Error)
+				} else {
+					appendHookOutput(&r, preRebootHook, output)
+					reboots++
+					pluginOutputs[pluginID].RebootCount++
+				}
+			}
 			pluginOutputs[pluginID].Code = r.Code
 			pluginOutputs[pluginID].Status = r.Status
 			pluginOutputs[pluginID].Error = r.Error
@@ -184,6 +260,7 @@ func RunPlugins(
 			pluginOutputs[pluginID].StandardOutput = r.StandardOutput
 			pluginOutputs[pluginID].StandardError = r.StandardError
 			pluginOutputs[pluginID].StepName = r.StepName
+			pluginOutputs[pluginID].OutputManifest = r.OutputManifest
 
 		case skipStep:
 			context.Log().Info(logMessage)
@@ -208,14 +285,14 @@ func RunPlugins(
 
 		// truncate the result and send it back to buffer channel.
 		result := *pluginOutputs[pluginID]
-		pluginConfig := iohandler.DefaultOutputConfig()
-		result.StandardOutput = pluginutil.StringPrefix(result.StandardOutput, pluginConfig.MaxStdoutLength, pluginConfig.OutputTruncatedSuffix)
-		result.StandardError = pluginutil.StringPrefix(result.StandardError, pluginConfig.MaxStdoutLength, pluginConfig.OutputTruncatedSuffix)
+		truncationStrategy := context.AppConfig().Output.TruncationStrategy
+		result.StandardOutput = truncateStream(result.StandardOutput, truncationStrategy)
+		result.StandardError = truncateStream(result.StandardError, truncationStrategy)
 		// send to buffer channel, guaranteed to not block since buffer size is plugin number
 		resChan <- result
 
 		//TODO handle cancelFlag here
-		if pluginHandlerFound && r.Status == contracts.ResultStatusSuccessAndReboot {
+		if pluginHandlerFound && r.Status.IsReboot() {
 			// do not execute the the next plugin
 			break
 		}
@@ -224,6 +301,52 @@ func RunPlugins(
 	return
 }
 
+// runPostRebootHook runs the post reboot hook of a step that required a reboot, a failing hook fails the step
+func runPostRebootHook(context context.T, config contracts.Configuration, cancelFlag task.CancelFlag, res *contracts.PluginResult) error {
+	output, err := runRebootHook(context, postRebootHook, postRebootHooks(config), config, cancelFlag)
+	appendHookOutput(res, postRebootHook, output)
+	if err != nil {
+		context.Log().Error(err)
+		res.Status = contracts.ResultStatusFailed
+		res.Error = err.Error()
+		res.EndDateTime = time.Now()
+	}
+	return err
+}
+
+// rebootRequiredStatus returns the status of a step that reboots if required. The step completes and the document resumes
+// with the next step after the reboot when it returns a reboot required exit code, when it requests a reboot, or when a
+// reboot became pending while it ran, like after an installer that needs a restart.
+func rebootRequiredStatus(log log.T, config contracts.Configuration, res contracts.PluginResult, wasRebootPending bool) contracts.ResultStatus {
+	if !config.RebootIfRequired {
+		return res.Status
+	}
+	for _, code := range appconfig.RebootRequiredExitCodes {
+		if res.Code == code {
+			log.Infof("step %v exited with code %v, a reboot is required", config.PluginID, code)
+			return contracts.ResultStatusPassedAndReboot
+		}
+	}
+	switch res.Status {
+	case contracts.ResultStatusSuccessAndReboot:
+		return contracts.ResultStatusPassedAndReboot
+	case contracts.ResultStatusSuccess:
+		if !wasRebootPending && isRebootPending(log) {
+			log.Infof("a reboot became pending while step %v ran", config.PluginID)
+			return contracts.ResultStatusPassedAndReboot
+		}
+	}
+	return res.Status
+}
+
+// maxReboots returns how many times the document can reboot the instance
+func maxReboots(config contracts.Configuration) int {
+	if config.MaxReboots > 0 {
+		return config.MaxReboots
+	}
+	return appconfig.DefaultDocumentMaxReboots
+}
+
 func runPlugin(
 	context context.T,
 	factory PluginFactory,
@@ -238,13 +361,12 @@ func runPlugin(
 	var stepName string
 
 	defer func() {
-		// recover in case the plugin panics
-		// this should handle some kind of seg fault errors.
+		// recover in case creating the plugin or collecting its output panics,
+		// the panics of the plugin executions are recovered by executePlugin
 		if err := recover(); err != nil {
 			res.Status = contracts.ResultStatusFailed
 			res.Code = 1
-			res.Error = fmt.Errorf("Plugin crashed with message %v!", err).Error()
-			log.Error(res.Error)
+			res.Error = handleCrash(log, newCrashReport(pluginName, stepName, config.MessageId, err, debug.Stack()))
 		}
 	}()
 
@@ -263,6 +385,7 @@ func runPlugin(
 	res.StartDateTime = time.Now()
 	defer func() { res.EndDateTime = time.Now() }()
 
+	ioConfig.ExpectedStdoutSha256 = config.ExpectedOutputSha256
 	output := iohandler.NewDefaultIOHandler(log, ioConfig)
 	//check if properties is a list. If true, then unroll
 	switch config.Properties.(type) {
@@ -309,11 +432,14 @@ func runPlugin(
 	res.Output = output.GetOutput()
 	res.StandardOutput = output.GetStdout()
 	res.StandardError = output.GetStderr()
+	res.OutputManifest = newOutputManifest(context.AppConfig(), ioConfig, pluginName, stepName, res.StandardOutput, res.StandardError,
+		output.GetStdoutStats(), output.GetStderrStats())
 
 	return
 }
 
-// executePlugin executes the plugin that's passed in and initializes the necessary writers
+// executePlugin executes the plugin that's passed in and initializes the necessary writers.
+// A panic of the plugin fails the step with the stack of the panic, the output written until then is kept.
 func executePlugin(context context.T,
 	plugin T,
 	pluginName string,
@@ -325,6 +451,11 @@ func executePlugin(context context.T,
 
 	// Create the output object and execute the plugin
 	defer output.Close(log)
+	defer func() {
+		if err := recover(); err != nil {
+			output.MarkAsFailed(errors.New(handleCrash(log, newCrashReport(pluginName, stepName, config.MessageId, err, debug.Stack()))))
+		}
+	}()
 	output.Init(log, pluginName, stepName)
 	plugin.Execute(context, config, cancelFlag, output)
 }
@@ -483,7 +614,7 @@ func evaluatePreconditions(
 						unrecognizedPreconditionList = append(unrecognizedPreconditionList, fmt.Sprintf("\"%s\": [%v, %v]", key, value[0].InitialArgumentValue, value[1].InitialArgumentValue))
 					}
 				} else if strings.Compare(value[0].InitialArgumentValue, value[0].ResolvedArgumentValue) == 0 && strings.Compare(value[1].InitialArgumentValue, value[1].ResolvedArgumentValue) == 0 {
-					unrecognizedPreconditionList = append(unrecognizedPreconditionList, fmt.Sprintf("\"%s\": at least one of operator's arguments must contain a valid document parameter", key))
+					unrecognizedPreconditionList = append(unrecognizedPreconditionList, fmt.Sprintf("\"%s\": at least one of operator's arguments must contain a valid document parameter or instance tag", key))
 				} else {
 					if strings.Compare(value[0].ResolvedArgumentValue, value[1].ResolvedArgumentValue) != 0 {
 						// if arbitrary StringEquals precondition is not satisfied, mark step for skip
@@ -19,8 +19,10 @@ import (
 	"testing"
 	"time"
 
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
 	"github.com/aws/amazon-ssm-agent/agent/context"
 	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/iohandler"
 	"github.com/aws/amazon-ssm-agent/agent/log"
 	"github.com/aws/amazon-ssm-agent/agent/task"
 	"github.com/stretchr/testify/assert"
@@ -1829,7 +1831,7 @@ func TestRunPluginsWithUnrecognizedPreconditionNoDocumentParameters(t *testing.T
 		}
 
 		pluginError := fmt.Sprintf(
-			"Unrecognized precondition(s): '\"StringEquals\": at least one of operator's arguments must contain a valid document parameter', please update agent to latest version. Step name: %s",
+			"Unrecognized precondition(s): '\"StringEquals\": at least one of operator's arguments must contain a valid document parameter or instance tag', please update agent to latest version. Step name: %s",
 			name)
 
 		pluginResults[name] = &contracts.PluginResult{
@@ -1939,7 +1941,7 @@ func TestRunPluginsWithUnrecognizedPreconditionUnrecognizedParameter(t *testing.
 		}
 
 		pluginError := fmt.Sprintf(
-			"Unrecognized precondition(s): '\"StringEquals\": at least one of operator's arguments must contain a valid document parameter', please update agent to latest version. Step name: %s",
+			"Unrecognized precondition(s): '\"StringEquals\": at least one of operator's arguments must contain a valid document parameter or instance tag', please update agent to latest version. Step name: %s",
 			name)
 
 		pluginResults[name] = &contracts.PluginResult{
@@ -3173,3 +3175,132 @@ func TestGetStepNameError(t *testing.T) {
 	_, err := getStepName(inputPluginName, config)
 	assert.Nil(t, err)
 }
+
+// setupRebootPlugins registers plugins whose execution ends with the given status and exit code
+func setupRebootPlugins(ctx *context.Mock, statuses map[string]contracts.ResultStatus, codes map[string]int) (PluginRegistry, map[string]*PluginMock) {
+	pluginRegistry := PluginRegistry{}
+	pluginInstances := make(map[string]*PluginMock)
+	for name, status := range statuses {
+		status, code := status, codes[name]
+		pluginInstances[name] = new(PluginMock)
+		pluginInstances[name].On("Execute", ctx, mock.Anything, mock.Anything, mock.Anything).Return().Run(func(args mock.Arguments) {
+			output := args.Get(3).(iohandler.IOHandler)
+			output.SetStatus(status)
+			output.SetExitCode(code)
+		})
+		pluginFactory := new(PluginFactoryMock)
+		pluginFactory.On("Create", mock.Anything).Return(pluginInstances[name], nil)
+		pluginRegistry[name] = pluginFactory
+	}
+	return pluginRegistry, pluginInstances
+}
+
+func drainResults(ch chan contracts.PluginResult) {
+	for range ch {
+	}
+}
+
+func TestRunPluginsRebootIfRequiredResumesWithNextStep(t *testing.T) {
+	setIsSupportedMock()
+	defer restoreIsSupported()
+	ctx := context.NewMockDefault()
+	pluginRegistry, pluginInstances := setupRebootPlugins(ctx,
+		map[string]contracts.ResultStatus{testPlugin1: contracts.ResultStatusSuccessAndReboot, testPlugin2: contracts.ResultStatusSuccess},
+		map[string]int{testPlugin1: appconfig.RebootExitCode})
+	plugins := []contracts.PluginState{
+		{Name: testPlugin1, Id: testPlugin1, Configuration: contracts.Configuration{PluginID: testPlugin1, PluginName: testPlugin1, RebootIfRequired: true}},
+		{Name: testPlugin2, Id: testPlugin2, Configuration: contracts.Configuration{PluginID: testPlugin2, PluginName: testPlugin2}},
+	}
+
+	ch := make(chan contracts.PluginResult, len(plugins))
+	outputs := RunPlugins(ctx, plugins, contracts.IOConfiguration{}, pluginRegistry, ch, task.NewChanneledCancelFlag())
+	close(ch)
+	drainResults(ch)
+
+	// the step completed and the next step waits for the reboot
+	assert.Equal(t, 1, len(outputs))
+	assert.Equal(t, contracts.ResultStatusPassedAndReboot, outputs[testPlugin1].Status)
+	assert.Equal(t, 1, outputs[testPlugin1].RebootCount)
+	pluginInstances[testPlugin2].AssertNotCalled(t, "Execute", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+
+	// after the reboot only the next step runs
+	plugins[0].Result = *outputs[testPlugin1]
+	ch = make(chan contracts.PluginResult, len(plugins))
+	outputs = RunPlugins(ctx, plugins, contracts.IOConfiguration{}, pluginRegistry, ch, task.NewChanneledCancelFlag())
+	close(ch)
+	drainResults(ch)
+
+	assert.Equal(t, contracts.ResultStatusSuccess, outputs[testPlugin1].Status)
+	assert.Equal(t, contracts.ResultStatusSuccess, outputs[testPlugin2].Status)
+	pluginInstances[testPlugin1].AssertNumberOfCalls(t, "Execute", 1)
+	pluginInstances[testPlugin2].AssertNumberOfCalls(t, "Execute", 1)
+}
+
+func TestRunPluginsRebootWithoutRebootIfRequiredRerunsStep(t *testing.T) {
+	setIsSupportedMock()
+	defer restoreIsSupported()
+	ctx := context.NewMockDefault()
+	pluginRegistry, _ := setupRebootPlugins(ctx,
+		map[string]contracts.ResultStatus{testPlugin1: contracts.ResultStatusSuccessAndReboot},
+		map[string]int{testPlugin1: appconfig.RebootExitCode})
+	plugins := []contracts.PluginState{
+		{Name: testPlugin1, Id: testPlugin1, Configuration: contracts.Configuration{PluginID: testPlugin1, PluginName: testPlugin1}},
+	}
+
+	ch := make(chan contracts.PluginResult, len(plugins))
+	outputs := RunPlugins(ctx, plugins, contracts.IOConfiguration{}, pluginRegistry, ch, task.NewChanneledCancelFlag())
+	close(ch)
+	drainResults(ch)
+
+	assert.Equal(t, contracts.ResultStatusSuccessAndReboot, outputs[testPlugin1].Status)
+	assert.Equal(t, 1, outputs[testPlugin1].RebootCount)
+}
+
+func TestRunPluginsFailsStepAfterMaxReboots(t *testing.T) {
+	setIsSupportedMock()
+	defer restoreIsSupported()
+	ctx := context.NewMockDefault()
+	pluginRegistry, _ := setupRebootPlugins(ctx,
+		map[string]contracts.ResultStatus{testPlugin1: contracts.ResultStatusSuccessAndReboot},
+		map[string]int{testPlugin1: appconfig.RebootExitCode})
+	plugins := []contracts.PluginState{
+		{
+			Name:          testPlugin1,
+			Id:            testPlugin1,
+			Configuration: contracts.Configuration{PluginID: testPlugin1, PluginName: testPlugin1, MaxReboots: 2},
+			Result:        contracts.PluginResult{Status: contracts.ResultStatusSuccessAndReboot, RebootCount: 2},
+		},
+	}
+
+	ch := make(chan contracts.PluginResult, len(plugins))
+	outputs := RunPlugins(ctx, plugins, contracts.IOConfiguration{}, pluginRegistry, ch, task.NewChanneledCancelFlag())
+	close(ch)
+	drainResults(ch)
+
+	assert.Equal(t, contracts.ResultStatusFailed, outputs[testPlugin1].Status)
+	assert.Equal(t, 2, outputs[testPlugin1].RebootCount)
+	assert.Contains(t, outputs[testPlugin1].Error, "already rebooted the instance 2 times")
+}
+
+func TestRebootRequiredStatus(t *testing.T) {
+	pending := false
+	origIsRebootPending := isRebootPending
+	isRebootPending = func(log log.T) bool { return pending }
+	defer func() { isRebootPending = origIsRebootPending }()
+
+	logger := log.NewMockLog()
+	optIn := contracts.Configuration{PluginID: testPlugin1, RebootIfRequired: true}
+	success := contracts.PluginResult{Status: contracts.ResultStatusSuccess}
+	rebootCode := contracts.PluginResult{Status: contracts.ResultStatusSuccessAndReboot, Code: appconfig.RebootExitCode}
+
+	assert.Equal(t, contracts.ResultStatusSuccessAndReboot, rebootRequiredStatus(logger, contracts.Configuration{}, rebootCode, false))
+	assert.Equal(t, contracts.ResultStatusPassedAndReboot, rebootRequiredStatus(logger, optIn, rebootCode, false))
+	assert.Equal(t, contracts.ResultStatusSuccess, rebootRequiredStatus(logger, optIn, success, false))
+
+	// a reboot that became pending while the step ran is required by the step
+	pending = true
+	assert.Equal(t, contracts.ResultStatusPassedAndReboot, rebootRequiredStatus(logger, optIn, success, false))
+	assert.Equal(t, contracts.ResultStatusSuccess, rebootRequiredStatus(logger, optIn, success, true))
+	assert.Equal(t, contracts.ResultStatusFailed, rebootRequiredStatus(logger, optIn, contracts.PluginResult{Status: contracts.ResultStatusFailed, Code: 1}, false))
+	assert.Equal(t, contracts.ResultStatusSuccess, rebootRequiredStatus(logger, contracts.Configuration{}, success, false))
+}
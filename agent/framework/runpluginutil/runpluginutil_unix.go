@@ -20,9 +20,17 @@ import (
 	"fmt"
 
 	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/fileutil"
 	"github.com/aws/amazon-ssm-agent/agent/platform"
 )
 
+const rebootHookScriptExtension = ".sh"
+
+const rebootHookByteOrderMark = fileutil.ByteOrderMarkSkip
+
+// rebootRequiredMarkers are the files package managers create when an update requires a reboot
+var rebootRequiredMarkers = []string{"/var/run/reboot-required"}
+
 // IsPluginSupportedForCurrentPlatform always returns true for plugins that exist for linux because currently there
 // are no plugins that are supported on only one distribution or version of linux.
 func IsPluginSupportedForCurrentPlatform(log log.T, pluginName string) (isKnown bool, isSupported bool, message string) {
@@ -32,6 +40,21 @@ func IsPluginSupportedForCurrentPlatform(log log.T, pluginName string) (isKnown
 	if _, known := allSessionPlugins[pluginName]; known == true {
 		return known, true, fmt.Sprintf("%s v%s", platformName, platformVersion)
 	}
+	if isExternalPlugin(pluginName) {
+		return true, true, fmt.Sprintf("%s v%s", platformName, platformVersion)
+	}
+
 	_, known := allPlugins[pluginName]
 	return known, true, fmt.Sprintf("%s v%s", platformName, platformVersion)
 }
+
+// rebootPending returns true when a package manager marked a reboot as required
+func rebootPending(log log.T) bool {
+	for _, path := range rebootRequiredMarkers {
+		if fileutil.Exists(path) {
+			log.Debugf("reboot required marker %v exists", path)
+			return true
+		}
+	}
+	return false
+}
@@ -25,10 +25,22 @@ import (
 	"strings"
 
 	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/fileutil"
 	"github.com/aws/amazon-ssm-agent/agent/log"
 	"github.com/aws/amazon-ssm-agent/agent/platform"
+	"golang.org/x/sys/windows/registry"
 )
 
+const rebootHookScriptExtension = ".ps1"
+
+const rebootHookByteOrderMark = fileutil.ByteOrderMarkEmit
+
+// rebootPendingKeys are the registry keys Windows servicing and Windows Update create while a reboot is pending
+var rebootPendingKeys = []string{
+	`SOFTWARE\Microsoft\Windows\CurrentVersion\Component Based Servicing\RebootPending`,
+	`SOFTWARE\Microsoft\Windows\CurrentVersion\WindowsUpdate\Auto Update\RebootRequired`,
+}
+
 // IsPluginSupportedForCurrentPlatform returns true if current platform supports the plugin with given name.
 func IsPluginSupportedForCurrentPlatform(log log.T, pluginName string) (isKnown bool, isSupported bool, message string) {
 	platformName, _ := platform.PlatformName(log)
@@ -38,6 +50,10 @@ func IsPluginSupportedForCurrentPlatform(log log.T, pluginName string) (isKnown
 		return known, isSupportedSessionPlugin(log, pluginName), fmt.Sprintf("%s v%s", platformName, platformVersion)
 	}
 
+	if isExternalPlugin(pluginName) {
+		return true, true, fmt.Sprintf("%s v%s", platformName, platformVersion)
+	}
+
 	_, known := allPlugins[pluginName]
 	if isPlatformNanoServer, err := platform.IsPlatformNanoServer(log); err == nil && isPlatformNanoServer {
 		//if the current OS is Nano server, SSM Agent doesn't support the following plugins.
@@ -81,3 +97,16 @@ func isSupportedSessionPlugin(log log.T, pluginName string) (isSupported bool) {
 
 	return true
 }
+
+// rebootPending returns true when Windows marked a reboot as pending
+func rebootPending(log log.T) bool {
+	for _, path := range rebootPendingKeys {
+		key, err := registry.OpenKey(registry.LOCAL_MACHINE, path, registry.QUERY_VALUE)
+		if err == nil {
+			key.Close()
+			log.Debugf("reboot pending key %v exists", path)
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,76 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package health
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+)
+
+// BootstrapDocumentHealth describes the execution of the bootstrap document the worker runs on its first start.
+type BootstrapDocumentHealth struct {
+	Path      string
+	Status    contracts.ResultStatus
+	StartTime time.Time
+	EndTime   time.Time
+	Error     string `json:",omitempty"`
+}
+
+var bootstrapDocument *BootstrapDocumentHealth
+var bootstrapDocumentLock sync.RWMutex
+
+// ReportBootstrapDocument records the result of the bootstrap document.
+func ReportBootstrapDocument(result BootstrapDocumentHealth) {
+	bootstrapDocumentLock.Lock()
+	defer bootstrapDocumentLock.Unlock()
+
+	bootstrapDocument = &result
+}
+
+// BootstrapDocument returns the result of the bootstrap document, found is false when none is configured.
+func BootstrapDocument() (result BootstrapDocumentHealth, found bool) {
+	bootstrapDocumentLock.RLock()
+	defer bootstrapDocumentLock.RUnlock()
+
+	if bootstrapDocument == nil {
+		return
+	}
+	return *bootstrapDocument, true
+}
+
+// String returns a summary of the bootstrap document result for the logs.
+func (result BootstrapDocumentHealth) String() string {
+	summary := fmt.Sprintf("path: %s, status: %s, finished at %s", result.Path, result.Status, result.EndTime.Format(time.RFC3339))
+	if result.Error != "" {
+		summary += fmt.Sprintf(", error: %s", result.Error)
+	}
+	return summary
+}
+
+// reportBootstrapDocument logs the result of the bootstrap document, a document that did not succeed is logged as a warning.
+func reportBootstrapDocument(log log.T) {
+	result, found := BootstrapDocument()
+	if !found {
+		return
+	}
+	if result.Status == contracts.ResultStatusSuccess {
+		log.Infof("bootstrap document health: %s", result)
+	} else {
+		log.Warnf("bootstrap document health: %s", result)
+	}
+}
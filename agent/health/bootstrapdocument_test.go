@@ -0,0 +1,42 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+
+package health
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBootstrapDocumentHealth(t *testing.T) {
+	defer func() { bootstrapDocument = nil }()
+
+	_, found := BootstrapDocument()
+	assert.False(t, found)
+
+	ReportBootstrapDocument(BootstrapDocumentHealth{
+		Path:    "/etc/amazon/ssm/bootstrap.yaml",
+		Status:  contracts.ResultStatusFailed,
+		EndTime: time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC),
+		Error:   "step installCA failed",
+	})
+
+	result, found := BootstrapDocument()
+	assert.True(t, found)
+	assert.Equal(t, contracts.ResultStatusFailed, result.Status)
+	assert.Equal(t, "path: /etc/amazon/ssm/bootstrap.yaml, status: Failed, finished at 2020-01-02T03:04:05Z, error: step installCA failed", result.String())
+}
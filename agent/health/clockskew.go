@@ -0,0 +1,38 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package health
+
+import (
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/network/clockskew"
+)
+
+var clockSkewStatus = clockskew.Status
+
+// reportClockSkew logs the difference between the local clock and the service clock, a skew above the configured
+// threshold is logged as a warning since the services reject requests signed more than 5 minutes off.
+func reportClockSkew(log log.T) {
+	skew, measured, exceeded := clockSkewStatus()
+	if measured.IsZero() {
+		return
+	}
+	if exceeded {
+		log.Warnf("clock skew health: local clock differs from the service clock by %v, measured at %s",
+			skew.Round(time.Second), measured.Format(time.RFC3339))
+	} else {
+		log.Debugf("clock skew health: %v, measured at %s", skew.Round(time.Second), measured.Format(time.RFC3339))
+	}
+}
@@ -0,0 +1,38 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package health
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/network/clockskew"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestReportClockSkew(t *testing.T) {
+	defer func() { clockSkewStatus = clockskew.Status }()
+	measured := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	clockSkewStatus = func() (time.Duration, time.Time, bool) { return 0, time.Time{}, false }
+	logger := log.NewMockLog()
+	reportClockSkew(logger)
+	logger.AssertNotCalled(t, "Warnf", mock.Anything, mock.Anything)
+
+	clockSkewStatus = func() (time.Duration, time.Time, bool) { return -10 * time.Minute, measured, true }
+	reportClockSkew(logger)
+	logger.AssertCalled(t, "Warnf", "clock skew health: local clock differs from the service clock by %v, measured at %s",
+		[]interface{}{-10 * time.Minute, "2020-01-02T03:04:05Z"})
+}
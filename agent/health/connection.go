@@ -0,0 +1,125 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package health
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/log"
+)
+
+// ConnectionHealth describes a long lived connection of the agent to a service and how often it had to reconnect.
+type ConnectionHealth struct {
+	Connected           bool
+	Endpoint            string
+	Reconnects          int
+	FailedAttempts      int
+	ConsecutiveFailures int
+	LastError           string
+	LastErrorTime       time.Time
+	LastConnectedTime   time.Time
+}
+
+var connections = map[string]ConnectionHealth{}
+var connectionsLock sync.RWMutex
+
+// ReportConnected records that the connection is established with the endpoint.
+func ReportConnected(name string, endpoint string) {
+	connectionsLock.Lock()
+	defer connectionsLock.Unlock()
+
+	connection, known := connections[name]
+	if known {
+		connection.Reconnects++
+	}
+	connection.Connected = true
+	connection.Endpoint = endpoint
+	connection.ConsecutiveFailures = 0
+	connection.LastConnectedTime = time.Now()
+	connections[name] = connection
+}
+
+// ReportDisconnected records that the connection broke with the given error.
+func ReportDisconnected(name string, err error) {
+	connectionsLock.Lock()
+	defer connectionsLock.Unlock()
+
+	connection := connections[name]
+	connection.Connected = false
+	if err != nil {
+		connection.LastError = err.Error()
+		connection.LastErrorTime = time.Now()
+	}
+	connections[name] = connection
+}
+
+// ReportConnectionFailure records a failed attempt to connect to the endpoint.
+func ReportConnectionFailure(name string, endpoint string, err error) {
+	connectionsLock.Lock()
+	defer connectionsLock.Unlock()
+
+	connection := connections[name]
+	connection.Connected = false
+	connection.Endpoint = endpoint
+	connection.FailedAttempts++
+	connection.ConsecutiveFailures++
+	if err != nil {
+		connection.LastError = err.Error()
+		connection.LastErrorTime = time.Now()
+	}
+	connections[name] = connection
+}
+
+// Connection returns the health of the connection with the given name.
+func Connection(name string) (connection ConnectionHealth, found bool) {
+	connectionsLock.RLock()
+	defer connectionsLock.RUnlock()
+
+	connection, found = connections[name]
+	return
+}
+
+// String returns a summary of the connection health for the logs.
+func (connection ConnectionHealth) String() string {
+	summary := fmt.Sprintf("connected: %v, endpoint: %s, reconnects: %d, failed attempts: %d, consecutive failures: %d",
+		connection.Connected, connection.Endpoint, connection.Reconnects, connection.FailedAttempts, connection.ConsecutiveFailures)
+	if connection.LastError != "" {
+		summary += fmt.Sprintf(", last error at %s: %s", connection.LastErrorTime.Format(time.RFC3339), connection.LastError)
+	}
+	return summary
+}
+
+// reportConnections logs the health of all connections, connections that are down are logged as warnings.
+func reportConnections(log log.T) {
+	connectionsLock.RLock()
+	defer connectionsLock.RUnlock()
+
+	names := make([]string, 0, len(connections))
+	for name := range connections {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		connection := connections[name]
+		if connection.Connected {
+			log.Infof("%s health: %s", name, connection)
+		} else {
+			log.Warnf("%s health: %s", name, connection)
+		}
+	}
+}
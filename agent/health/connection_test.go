@@ -0,0 +1,53 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package health
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConnectionHealth(t *testing.T) {
+	name := "test-connection"
+
+	ReportConnected(name, "endpoint-a")
+	ReportDisconnected(name, errors.New("connection reset"))
+	ReportConnectionFailure(name, "endpoint-a", errors.New("bad handshake"))
+	ReportConnectionFailure(name, "endpoint-b", errors.New("no route to host"))
+
+	connection, found := Connection(name)
+	assert.True(t, found)
+	assert.False(t, connection.Connected)
+	assert.Equal(t, "endpoint-b", connection.Endpoint)
+	assert.Equal(t, 0, connection.Reconnects)
+	assert.Equal(t, 2, connection.FailedAttempts)
+	assert.Equal(t, 2, connection.ConsecutiveFailures)
+	assert.Equal(t, "no route to host", connection.LastError)
+
+	ReportConnected(name, "endpoint-b")
+
+	connection, _ = Connection(name)
+	assert.True(t, connection.Connected)
+	assert.Equal(t, 1, connection.Reconnects)
+	assert.Equal(t, 2, connection.FailedAttempts)
+	assert.Equal(t, 0, connection.ConsecutiveFailures)
+	assert.Contains(t, connection.String(), "last error at")
+}
+
+func TestConnectionHealth_Unknown(t *testing.T) {
+	_, found := Connection("unknown-connection")
+	assert.False(t, found)
+}
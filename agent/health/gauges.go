@@ -0,0 +1,143 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package health
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/framework/docmanager"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/platform"
+	"github.com/aws/amazon-ssm-agent/agent/sdkutil"
+	"github.com/aws/amazon-ssm-agent/agent/version"
+)
+
+const (
+	// healthInventoryTypeName is the custom inventory type of the operational gauges
+	healthInventoryTypeName = "Custom:AmazonSSMAgentHealth"
+
+	// healthInventorySchemaVersion is the schema version of the custom inventory item
+	healthInventorySchemaVersion = "1.0"
+
+	// healthInventoryFileName is the file of the item in the custom inventory folder
+	healthInventoryFileName = "AmazonSSMAgentHealth.json"
+)
+
+var (
+	documentStates = docmanager.DocumentStates
+	lastAwsError   = sdkutil.LastAwsError
+	getInstanceID  = platform.InstanceID
+
+	// customInventoryDirectory is the default folder of the custom inventory gatherer
+	customInventoryDirectory = func(instanceID string) string {
+		return filepath.Join(appconfig.DefaultDataStorePath, instanceID, appconfig.InventoryRootDirName, appconfig.CustomInventoryRootDirName)
+	}
+)
+
+// operationalGauges are the lightweight gauges that tell a stuck agent apart from an idle one
+type operationalGauges struct {
+	ActiveDocuments  int
+	PendingDocuments int
+	ActiveSessions   int
+	LastErrorCode    string
+	LastErrorTime    time.Time
+}
+
+// collectGauges counts the documents and sessions in progress and the documents waiting to run
+func collectGauges(log log.T, instanceID string) (gauges operationalGauges) {
+	for _, state := range documentStates(log, instanceID, appconfig.DefaultLocationOfCurrent) {
+		if state.DocumentType == contracts.StartSession {
+			gauges.ActiveSessions++
+		} else {
+			gauges.ActiveDocuments++
+		}
+	}
+	gauges.PendingDocuments = len(documentStates(log, instanceID, appconfig.DefaultLocationOfPending))
+	gauges.LastErrorCode, gauges.LastErrorTime = lastAwsError()
+	return gauges
+}
+
+// reportGauges logs the operational gauges, and writes them for the custom inventory gatherer when enabled so fleet
+// inventory shows them without connecting to the instance. UpdateInstanceInformation has no field to carry them.
+func reportGauges(log log.T, config appconfig.SsmagentConfig) {
+	instanceID, err := getInstanceID()
+	if err != nil {
+		return
+	}
+	gauges := collectGauges(log, instanceID)
+	log.Infof("operational health: %v documents in progress, %v pending, %v sessions, last error %q at %v",
+		gauges.ActiveDocuments, gauges.PendingDocuments, gauges.ActiveSessions, gauges.LastErrorCode, formatGaugeTime(gauges.LastErrorTime))
+
+	if !config.Ssm.HealthInventoryEnabled {
+		return
+	}
+	if err = writeHealthInventory(instanceID, gauges); err != nil {
+		log.Warnf("failed to write the health custom inventory: %v", err)
+	}
+}
+
+// writeHealthInventory writes the gauges as a custom inventory item, whose attribute values must be strings
+func writeHealthInventory(instanceID string, gauges operationalGauges) error {
+	content, err := json.Marshal(map[string]interface{}{
+		"SchemaVersion": healthInventorySchemaVersion,
+		"TypeName":      healthInventoryTypeName,
+		"Content": map[string]string{
+			"AgentVersion":     version.Version,
+			"ActiveDocuments":  strconv.Itoa(gauges.ActiveDocuments),
+			"PendingDocuments": strconv.Itoa(gauges.PendingDocuments),
+			"ActiveSessions":   strconv.Itoa(gauges.ActiveSessions),
+			"LastErrorCode":    gauges.LastErrorCode,
+			"LastErrorTime":    formatGaugeTime(gauges.LastErrorTime),
+			"ReportTime":       formatGaugeTime(time.Now()),
+		},
+	})
+	if err != nil {
+		return err
+	}
+	dir := customInventoryDirectory(instanceID)
+	if err = os.MkdirAll(dir, appconfig.ReadWriteExecuteAccess); err != nil {
+		return err
+	}
+	// the item is renamed into place so the gatherer never reads a partial file
+	tmpFile, err := ioutil.TempFile(dir, ".health")
+	if err != nil {
+		return err
+	}
+	_, err = tmpFile.Write(content)
+	if closeErr := tmpFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmpFile.Name(), filepath.Join(dir, healthInventoryFileName))
+	}
+	if err != nil {
+		os.Remove(tmpFile.Name())
+	}
+	return err
+}
+
+// formatGaugeTime formats a time of the gauges in UTC, the zero time is empty
+func formatGaugeTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
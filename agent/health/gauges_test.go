@@ -0,0 +1,102 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+package health
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/framework/docmanager"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/platform"
+	"github.com/aws/amazon-ssm-agent/agent/sdkutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func stubGauges(t *testing.T) (dir string, restore func()) {
+	dir, err := ioutil.TempDir("", "health")
+	assert.NoError(t, err)
+	errorTime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	defaultInventoryDirectory := customInventoryDirectory
+
+	documentStates = func(log log.T, instanceID, locationFolder string) []contracts.DocumentState {
+		if locationFolder == appconfig.DefaultLocationOfPending {
+			return []contracts.DocumentState{{DocumentType: contracts.SendCommand}}
+		}
+		return []contracts.DocumentState{
+			{DocumentType: contracts.SendCommand},
+			{DocumentType: contracts.Association},
+			{DocumentType: contracts.StartSession},
+		}
+	}
+	lastAwsError = func() (string, time.Time) { return "ThrottlingException", errorTime }
+	getInstanceID = func() (string, error) { return "i-1234567890", nil }
+	customInventoryDirectory = func(instanceID string) string { return filepath.Join(dir, instanceID) }
+
+	return dir, func() {
+		documentStates = docmanager.DocumentStates
+		lastAwsError = sdkutil.LastAwsError
+		getInstanceID = platform.InstanceID
+		customInventoryDirectory = defaultInventoryDirectory
+		os.RemoveAll(dir)
+	}
+}
+
+func TestCollectGauges(t *testing.T) {
+	_, restore := stubGauges(t)
+	defer restore()
+
+	gauges := collectGauges(log.NewMockLog(), "i-1234567890")
+	assert.Equal(t, 2, gauges.ActiveDocuments)
+	assert.Equal(t, 1, gauges.PendingDocuments)
+	assert.Equal(t, 1, gauges.ActiveSessions)
+	assert.Equal(t, "ThrottlingException", gauges.LastErrorCode)
+	assert.Equal(t, "2020-01-02T03:04:05Z", formatGaugeTime(gauges.LastErrorTime))
+}
+
+func TestReportGauges_HealthInventory(t *testing.T) {
+	dir, restore := stubGauges(t)
+	defer restore()
+	itemPath := filepath.Join(dir, "i-1234567890", healthInventoryFileName)
+
+	var config appconfig.SsmagentConfig
+	reportGauges(log.NewMockLog(), config)
+	_, err := os.Stat(itemPath)
+	assert.True(t, os.IsNotExist(err), "the item should not be written when disabled")
+
+	config.Ssm.HealthInventoryEnabled = true
+	reportGauges(log.NewMockLog(), config)
+	content, err := ioutil.ReadFile(itemPath)
+	assert.NoError(t, err)
+
+	var item struct {
+		SchemaVersion string
+		TypeName      string
+		Content       map[string]string
+	}
+	assert.NoError(t, json.Unmarshal(content, &item))
+	assert.Equal(t, healthInventoryTypeName, item.TypeName)
+	assert.Equal(t, healthInventorySchemaVersion, item.SchemaVersion)
+	assert.Equal(t, "2", item.Content["ActiveDocuments"])
+	assert.Equal(t, "1", item.Content["PendingDocuments"])
+	assert.Equal(t, "1", item.Content["ActiveSessions"])
+	assert.Equal(t, "ThrottlingException", item.Content["LastErrorCode"])
+	assert.Equal(t, "2020-01-02T03:04:05Z", item.Content["LastErrorTime"])
+	assert.NotEmpty(t, item.Content["ReportTime"])
+}
@@ -15,15 +15,19 @@
 package health
 
 import (
+	"encoding/json"
 	"math/rand"
 	"time"
 
 	"github.com/aws/amazon-ssm-agent/agent/context"
 	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/outbox"
+	"github.com/aws/amazon-ssm-agent/agent/platform"
+	"github.com/aws/amazon-ssm-agent/agent/schedule"
 	"github.com/aws/amazon-ssm-agent/agent/sdkutil"
 	"github.com/aws/amazon-ssm-agent/agent/ssm"
 	"github.com/aws/amazon-ssm-agent/agent/version"
-	"github.com/carlescere/scheduler"
 )
 
 type IHealthCheck interface {
@@ -37,7 +41,7 @@ type IHealthCheck interface {
 type HealthCheck struct {
 	context               context.T
 	healthCheckStopPolicy *sdkutil.StopPolicy
-	healthJob             *scheduler.Job
+	healthJob             *schedule.Job
 	service               ssm.Service
 }
 
@@ -49,6 +53,14 @@ const (
 
 var healthModule *HealthCheck
 
+var detectInstanceChange = platform.DetectInstanceChange
+
+// healthPing is the agent health reported to SSM
+type healthPing struct {
+	AgentVersion string
+	AgentStatus  string
+}
+
 // AgentState enumerates active and passive agentMode
 type AgentState int32
 
@@ -79,11 +91,8 @@ func NewHealthCheck(context context.T, svc ssm.Service) *HealthCheck {
 
 // schedules recurrent updateHealth calls
 func (h *HealthCheck) scheduleUpdateHealth() {
-	var err error
-	if h.healthJob, err = scheduler.Every(h.scheduleInMinutes()).Minutes().Run(h.updateHealth); err != nil {
-		h.context.Log().Errorf("unable to schedule health update. %v", err)
-	}
-	return
+	h.healthJob = schedule.Every(time.Duration(h.scheduleInMinutes())*time.Minute, h.updateHealth)
+	h.healthJob.RunNow()
 }
 
 // updates SSM with the instance health information
@@ -92,11 +101,22 @@ func (h *HealthCheck) updateHealth() {
 	log.Infof("%s reporting agent health.", name)
 
 	var err error
+	// the cached instance information is stale when the volume of the agent moved to another instance
+	detectInstanceChange(log)
+
 	//TODO when will status become inactive?
 	// If both ssm config and command is inactive => agent is inactive.
-	if _, err = h.service.UpdateInstanceInformation(log, version.Version, "Active", AgentName); err != nil {
+	ping := healthPing{AgentVersion: version.Version, AgentStatus: "Active"}
+	if _, err = outbox.Send(log, outbox.KindHealth, ping, func() (err error) {
+		_, err = h.service.UpdateInstanceInformation(log, ping.AgentVersion, ping.AgentStatus, AgentName)
+		return err
+	}); err != nil {
 		sdkutil.HandleAwsError(log, err, h.healthCheckStopPolicy)
 	}
+	reportConnections(log)
+	reportBootstrapDocument(log)
+	reportClockSkew(log)
+	reportGauges(log, h.context.AppConfig())
 
 	if !h.healthCheckStopPolicy.IsHealthy() {
 		h.service = ssm.NewService()
@@ -106,6 +126,16 @@ func (h *HealthCheck) updateHealth() {
 	return
 }
 
+// sendQueuedPing reports the agent health queued while SSM was unreachable
+func (h *HealthCheck) sendQueuedPing(log log.T, payload []byte) (err error) {
+	var ping healthPing
+	if err = json.Unmarshal(payload, &ping); err != nil {
+		return
+	}
+	_, err = h.service.UpdateInstanceInformation(log, ping.AgentVersion, ping.AgentStatus, AgentName)
+	return
+}
+
 // scheduleInMinutes Run Schedule In Minutes
 func (h *HealthCheck) scheduleInMinutes() int {
 	updateHealthFrequencyMins := 5
@@ -143,26 +173,26 @@ func (h *HealthCheck) ModuleExecute(context context.T) (err error) {
 
 	randomSeconds := rand.Intn(scheduleInMinutes * 60)
 
+	// Results queued while the service was unreachable are flushed along with the health pings
+	outbox.RegisterSender(outbox.KindHealth, h.sendQueuedPing)
+	outbox.Start(context.Log())
+
 	// First call updateHealth once
 	go h.updateHealth()
 
 	// Wait randomSeconds and schedule recurrent updateHealth calls
-	next := time.Duration(randomSeconds) * time.Second
-	go func(h *HealthCheck) {
-		select {
-		case <-time.After(next):
-			go h.scheduleUpdateHealth()
-		}
-	}(h)
+	time.AfterFunc(time.Duration(randomSeconds)*time.Second, h.scheduleUpdateHealth)
 
 	return
 }
 
 // ModuleRequestStop handles the termination of the health check module job
 func (h *HealthCheck) ModuleRequestStop(stopType contracts.StopType) (err error) {
+	outbox.Stop()
+	outbox.RegisterSender(outbox.KindHealth, nil)
 	if h.healthJob != nil {
 		h.context.Log().Info("stopping update instance health job.")
-		h.healthJob.Quit <- true
+		h.healthJob.Stop()
 	}
 	return nil
 }
@@ -23,10 +23,10 @@ import (
 	"github.com/aws/amazon-ssm-agent/agent/context"
 	"github.com/aws/amazon-ssm-agent/agent/contracts"
 	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/schedule"
 	"github.com/aws/amazon-ssm-agent/agent/sdkutil"
 	ssmMock "github.com/aws/amazon-ssm-agent/agent/ssm/mocks"
 	"github.com/aws/amazon-ssm-agent/agent/version"
-	"github.com/carlescere/scheduler"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/suite"
@@ -40,7 +40,7 @@ type HealthCheckTestSuite struct {
 	logMock     *log.Mock
 	contextMock *context.Mock
 	serviceMock *ssmMock.Service
-	healthJob   *scheduler.Job
+	healthJob   *schedule.Job
 	stopPolicy  *sdkutil.StopPolicy
 	healthCheck IHealthCheck
 }
@@ -51,11 +51,11 @@ func (suite *HealthCheckTestSuite) SetupTest() {
 	contextMock := context.NewMockDefault()
 
 	serviceMock := new(ssmMock.Service)
-	healthJob := &scheduler.Job{
-		Quit: make(chan bool),
-	}
+	healthJob := schedule.Every(time.Hour, func() {})
 
 	stopPolicy := sdkutil.NewStopPolicy("hibernation", 10)
+	detectInstanceChange = func(log.T) bool { return false }
+	getInstanceID = func() (string, error) { return "", errors.New("no instance id") }
 
 	suite.logMock = logMock
 	suite.contextMock = contextMock
@@ -69,6 +69,10 @@ func (suite *HealthCheckTestSuite) SetupTest() {
 	}
 }
 
+func (suite *HealthCheckTestSuite) TearDownTest() {
+	suite.healthJob.Stop()
+}
+
 // Testing the module name
 func (suite *HealthCheckTestSuite) TestModuleName() {
 	rst := suite.healthCheck.ModuleName()
@@ -101,18 +105,9 @@ func (suite *HealthCheckTestSuite) TestModuleRequestStopWithHealthJob() {
 		service:               suite.serviceMock,
 		healthCheckStopPolicy: suite.stopPolicy,
 	}
-	// Start a new wg to avoid go panic.
-	wg := new(sync.WaitGroup)
-	go func(wgc *sync.WaitGroup) {
-		wgc.Add(1)
-		defer wgc.Done()
-		suite.healthCheck.ModuleRequestStop(contracts.StopTypeSoftStop)
-	}(wg)
-	// Check the value is sent to healthJobMock channel. And the value is true.
-	val := <-suite.healthJob.Quit
-	close(suite.healthJob.Quit)
-	wg.Wait()
-	assert.Equal(suite.T(), val, true, "ModuleRequestStop should return true")
+	assert.False(suite.T(), suite.healthJob.Stopped())
+	suite.healthCheck.ModuleRequestStop(contracts.StopTypeSoftStop)
+	assert.True(suite.T(), suite.healthJob.Stopped(), "ModuleRequestStop should stop the health job")
 }
 
 // Testing the ModuleRequestStop method which doesn't have healthjob defination
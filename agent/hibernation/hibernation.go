@@ -13,7 +13,8 @@
 
 // Package hibernation is responsible for the agent in hibernate mode.
 // It depends on health pings in an exponential backoff to check if the agent needs
-// to move to active mode.
+// to move to active mode, and probes the health at a fast rate after a wake trigger
+// such as the instance metadata service or a network interface coming back.
 package hibernation
 
 import (
@@ -22,7 +23,7 @@ import (
 	"github.com/aws/amazon-ssm-agent/agent/context"
 	"github.com/aws/amazon-ssm-agent/agent/health"
 	"github.com/aws/amazon-ssm-agent/agent/log"
-	"github.com/carlescere/scheduler"
+	"github.com/aws/amazon-ssm-agent/agent/schedule"
 	"github.com/cihub/seelog"
 )
 
@@ -34,13 +35,19 @@ type IHibernate interface {
 type Hibernate struct {
 	currentMode  health.AgentState
 	healthModule health.IHealthCheck
-	hibernateJob *scheduler.Job
+	hibernateJob *schedule.Job
 
 	currentPingInterval int
 	maxInterval         int
 	scheduleBackOff     func(m *Hibernate)
 	schedulePing        func(m *Hibernate)
 
+	wakeTriggers      []*wakeTrigger
+	wakeCheckInterval time.Duration
+	fastProbeInterval time.Duration
+	fastProbing       int32
+	watchWake         func(m *Hibernate, stop chan bool)
+
 	seelogger seelog.LoggerInterface
 	isLogged  bool
 }
@@ -72,6 +79,10 @@ func NewHibernateMode(healthModule health.IHealthCheck, context context.T) *Hibe
 		maxInterval:         maxBackOffInterval,
 		scheduleBackOff:     scheduleBackOffStrategy,
 		schedulePing:        scheduleEmptyHealthPing,
+		wakeTriggers:        defaultWakeTriggers(),
+		wakeCheckInterval:   wakeCheckInterval,
+		fastProbeInterval:   fastProbeInterval,
+		watchWake:           watchWakeTriggers,
 	}
 }
 
@@ -79,8 +90,21 @@ func NewHibernateMode(healthModule health.IHealthCheck, context context.T) *Hibe
 func (m *Hibernate) ExecuteHibernation() health.AgentState {
 	next := time.Duration(initialPingRate) * time.Second
 	m.seelogger.Info("Agent is in hibernate mode. Reducing logging. Logging will be reduced to one log per backoff period")
-	// Wait backoff time and then schedule health pings
-	<-time.After(next)
+
+	// Wake triggers probe the health at a fast rate, instead of waiting for the next backoff period
+	stopWake := make(chan bool)
+	defer close(stopWake)
+	go m.watchWake(m, stopWake)
+
+	// Wait backoff time and then schedule health pings, unless a wake trigger found the agent active
+	select {
+	case status := <-modeChan:
+		if status == health.Active {
+			m.seelogger.Flush()
+			return status
+		}
+	case <-time.After(next):
+	}
 	m.scheduleBackOff(m)
 
 loop:
@@ -113,16 +137,13 @@ func (m *Hibernate) healthCheck() {
 
 func (m *Hibernate) stopEmptyPing() {
 	if m.hibernateJob != nil {
-		m.hibernateJob.Quit <- true
+		m.hibernateJob.Stop()
 	}
 }
 
 func scheduleEmptyHealthPing(m *Hibernate) {
-	var err error
-	if m.hibernateJob, err = scheduler.Every(m.currentPingInterval).Seconds().Run(m.healthCheck); err != nil {
-		m.seelogger.Errorf("Unable to schedule health update. %v", err)
-	}
-	return
+	m.hibernateJob = schedule.Every(time.Duration(m.currentPingInterval)*time.Second, m.healthCheck)
+	m.hibernateJob.RunNow()
 }
 
 func scheduleBackOffStrategy(m *Hibernate) {
@@ -31,6 +31,7 @@ func TestHibernation_ExecuteHibernation_AgentTurnsActive(t *testing.T) {
 	healthMock := health.NewHealthCheck(ctx, ssm.NewService())
 
 	hibernate := NewHibernateMode(healthMock, ctx)
+	hibernate.watchWake = fakeWatch
 	hibernate.scheduleBackOff = fakeScheduler
 	for i := 0; i < 4; i++ {
 		modeChan <- health.Passive
@@ -48,6 +49,7 @@ func TestHibernation_scheduleBackOffStrategy(t *testing.T) {
 	healthMock := health.NewHealthCheck(ctx, ssm.NewService())
 
 	hibernate := NewHibernateMode(healthMock, ctx)
+	hibernate.watchWake = fakeWatch
 	hibernate.schedulePing = fakeScheduler
 	hibernate.currentPingInterval = 1 //second
 	hibernate.maxInterval = 4         //second
@@ -70,3 +72,7 @@ func TestHibernation_scheduleBackOffStrategy(t *testing.T) {
 func fakeScheduler(*Hibernate) {
 	//Do nothing
 }
+
+func fakeWatch(*Hibernate, chan bool) {
+	//Do nothing
+}
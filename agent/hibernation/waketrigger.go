@@ -0,0 +1,186 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package hibernation
+
+import (
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/health"
+	"github.com/aws/amazon-ssm-agent/agent/platform"
+	"github.com/aws/amazon-ssm-agent/agent/schedule"
+)
+
+const (
+	wakeCheckInterval  = 10 * time.Second
+	fastProbeInterval  = 5 * time.Second
+	fastProbeDuration  = 2 * time.Minute
+	wakeProbeTimeout   = 2 * time.Second
+	ntpPacketSize      = 48
+	ntpClientVersion3  = 0x1B
+	imdsTokenTTLHeader = "60"
+)
+
+// ntpServers are queried in order, the Amazon Time Sync Service is only reachable on EC2
+var ntpServers = []string{"169.254.169.123:123", "time.aws.com:123"}
+
+// wakeTrigger watches a condition that often comes back together with the connection to SSM,
+// such as the instance metadata service or a network interface after the host resumes
+type wakeTrigger struct {
+	name string
+	// probe returns the current state and whether it is healthy
+	probe   func() (state string, healthy bool)
+	last    string
+	checked bool
+}
+
+// fired returns true when the trigger became healthy or its healthy state changed since the last check
+func (t *wakeTrigger) fired() bool {
+	state, healthy := t.probe()
+	if !healthy {
+		state = ""
+	}
+	fired := t.checked && healthy && state != t.last
+	t.last = state
+	t.checked = true
+	return fired
+}
+
+// defaultWakeTriggers returns the triggers watched while the agent hibernates
+func defaultWakeTriggers() []*wakeTrigger {
+	return []*wakeTrigger{
+		{name: "instance metadata available", probe: probeMetadataService},
+		{name: "network interface up", probe: probeNetworkInterfaces},
+		{name: "NTP reachable", probe: probeNtp},
+	}
+}
+
+// watchWakeTriggers checks the wake triggers until stop is closed, and probes the health of the
+// agent at a fast rate after one of them fires
+func watchWakeTriggers(m *Hibernate, stop chan bool) {
+	job := schedule.Every(m.wakeCheckInterval, func() {
+		woke := false
+		for _, trigger := range m.wakeTriggers {
+			if trigger.fired() {
+				m.seelogger.Infof("Wake trigger %v fired, probing health every %v for %v.", trigger.name, m.fastProbeInterval, fastProbeDuration)
+				woke = true
+			}
+		}
+		if woke {
+			go m.fastProbe(stop)
+		}
+	})
+	<-stop
+	job.Stop()
+}
+
+// fastProbe checks the health of the agent every fastProbeInterval for fastProbeDuration,
+// a probe already running is not restarted
+func (m *Hibernate) fastProbe(stop chan bool) {
+	if !atomic.CompareAndSwapInt32(&m.fastProbing, 0, 1) {
+		return
+	}
+	defer atomic.StoreInt32(&m.fastProbing, 0)
+
+	active := make(chan bool, 1)
+	job := schedule.Every(m.fastProbeInterval, func() {
+		if status, _ := m.healthModule.GetAgentState(); status == health.Active {
+			select {
+			case active <- true:
+			default:
+			}
+		}
+	})
+	defer job.Stop()
+	job.RunNow()
+
+	select {
+	case <-stop:
+	case <-time.After(fastProbeDuration):
+	case <-active:
+		modeChan <- health.Active
+	}
+}
+
+// probeMetadataService is healthy when the instance metadata service answers a token request
+func probeMetadataService() (string, bool) {
+	request, err := http.NewRequest(http.MethodPut, platform.EC2MetadataServiceEndpoint()+platform.EC2MetadataTokenURL, nil)
+	if err != nil {
+		return "", false
+	}
+	request.Header.Set(platform.EC2MetadataTokenExpireHeader, imdsTokenTTLHeader)
+	client := http.Client{Timeout: wakeProbeTimeout}
+	response, err := client.Do(request)
+	if err != nil {
+		return "", false
+	}
+	response.Body.Close()
+	return "available", true
+}
+
+// probeNetworkInterfaces is healthy when an interface other than loopback is up with an address,
+// its state is the list of addresses so that a new address also fires the trigger
+func probeNetworkInterfaces() (string, bool) {
+	interfaces, err := net.Interfaces()
+	if err != nil {
+		return "", false
+	}
+	var addresses []string
+	for _, inter := range interfaces {
+		if inter.Flags&net.FlagUp == 0 || inter.Flags&net.FlagLoopback != 0 {
+			continue
+		}
+		addrs, err := inter.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, addr := range addrs {
+			addresses = append(addresses, inter.Name+"="+addr.String())
+		}
+	}
+	sort.Strings(addresses)
+	return strings.Join(addresses, ","), len(addresses) > 0
+}
+
+// probeNtp is healthy when one of the NTP servers answers a client request
+func probeNtp() (string, bool) {
+	for _, server := range ntpServers {
+		if queryNtp(server) {
+			return "reachable", true
+		}
+	}
+	return "", false
+}
+
+func queryNtp(server string) bool {
+	conn, err := net.DialTimeout("udp", server, wakeProbeTimeout)
+	if err != nil {
+		return false
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(wakeProbeTimeout))
+
+	request := make([]byte, ntpPacketSize)
+	request[0] = ntpClientVersion3
+	if _, err = conn.Write(request); err != nil {
+		return false
+	}
+	response := make([]byte, ntpPacketSize)
+	read, err := conn.Read(response)
+	return err == nil && read == ntpPacketSize
+}
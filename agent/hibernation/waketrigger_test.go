@@ -0,0 +1,76 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package hibernation
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/context"
+	"github.com/aws/amazon-ssm-agent/agent/health"
+	healthMocks "github.com/aws/amazon-ssm-agent/agent/health/mocks"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWakeTriggerFired(t *testing.T) {
+	state, healthy := "", false
+	trigger := &wakeTrigger{name: "test", probe: func() (string, bool) { return state, healthy }}
+
+	// the first check only records the state
+	state, healthy = "eth0=10.0.0.1/24", true
+	assert.False(t, trigger.fired())
+	assert.False(t, trigger.fired())
+
+	state, healthy = "", false
+	assert.False(t, trigger.fired())
+	state, healthy = "eth0=10.0.0.1/24", true
+	assert.True(t, trigger.fired())
+
+	// a new address fires again
+	state = "eth0=10.0.0.1/24,eth1=10.0.1.1/24"
+	assert.True(t, trigger.fired())
+	assert.False(t, trigger.fired())
+}
+
+func TestWakeTriggerStartsFastProbe(t *testing.T) {
+	healthMock := new(healthMocks.IHealthCheck)
+	healthMock.On("GetAgentState").Return(health.Passive, errors.New("unreachable")).Once()
+	healthMock.On("GetAgentState").Return(health.Active, nil)
+
+	hibernate := NewHibernateMode(healthMock, context.NewMockDefault())
+	hibernate.scheduleBackOff = fakeScheduler
+	hibernate.wakeCheckInterval = 10 * time.Millisecond
+	hibernate.fastProbeInterval = 10 * time.Millisecond
+	up := false
+	hibernate.wakeTriggers = []*wakeTrigger{
+		{name: "test", probe: func() (string, bool) { return "up", up }},
+	}
+
+	result := make(chan health.AgentState)
+	go func() {
+		result <- hibernate.ExecuteHibernation()
+	}()
+	time.Sleep(50 * time.Millisecond)
+	healthMock.AssertNotCalled(t, "GetAgentState")
+
+	up = true
+	select {
+	case status := <-result:
+		assert.Equal(t, health.Active, status)
+	case <-time.After(5 * time.Second):
+		assert.Fail(t, "agent did not wake up after the trigger fired")
+	}
+	healthMock.AssertNumberOfCalls(t, "GetAgentState", 2)
+}
@@ -0,0 +1,102 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package hostaccess runs the children of aws:runShellScript on the host when the agent runs in a container that
+// manages its node, like a pod of a Kubernetes DaemonSet. Children either enter the namespaces of the host init
+// process with nsenter or change root to the host filesystem mounted in the container.
+package hostaccess
+
+import (
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/platform"
+)
+
+const (
+	nsenterCommand = "nsenter"
+	chrootCommand  = "chroot"
+
+	// hostInitPid is the pid of the host init process, visible to containers sharing the host PID namespace
+	hostInitPid = "1"
+
+	// chrootScript changes to the working directory given as $0 before it runs the child
+	chrootShell  = "/bin/sh"
+	chrootScript = `cd "$0" && exec "$@"`
+)
+
+var (
+	getAppConfig  = appconfig.Config
+	isInContainer = platform.IsRunningInContainer
+	dataStorePath = appconfig.DefaultDataStorePath
+)
+
+// Resolve returns the host config with the way children reach the host, HostAccessNsenter or HostAccessChroot.
+// It returns false when children run where the agent runs.
+func Resolve() (config appconfig.HostCfg, enabled bool) {
+	if runtime.GOOS != "linux" {
+		return config, false
+	}
+	agentConfig, err := getAppConfig(false)
+	if err != nil {
+		return config, false
+	}
+	config = agentConfig.Host
+	switch config.HostAccess {
+	case appconfig.HostAccessNsenter, appconfig.HostAccessChroot:
+		return config, true
+	case appconfig.HostAccessAuto:
+		if !agentConfig.Agent.ContainerMode && isInContainer() {
+			config.HostAccess = appconfig.HostAccessNsenter
+			return config, true
+		}
+	}
+	return config, false
+}
+
+// Wrap returns the command that runs the child in workingDir on the host, paths of the agent data store
+// are replaced by their path on the host
+func Wrap(config appconfig.HostCfg, workingDir string, name string, argv []string) (string, []string) {
+	hostArgv := make([]string, 0, len(argv)+1)
+	hostArgv = append(hostArgv, hostPath(config, name))
+	for _, arg := range argv {
+		hostArgv = append(hostArgv, hostPath(config, arg))
+	}
+	hostWorkingDir := hostPath(config, workingDir)
+
+	if config.HostAccess == appconfig.HostAccessChroot {
+		if hostWorkingDir == "" {
+			hostWorkingDir = "/"
+		}
+		return chrootCommand, append([]string{config.HostRoot, chrootShell, "-c", chrootScript, hostWorkingDir}, hostArgv...)
+	}
+	nsenterArgs := []string{"--target", hostInitPid, "--mount", "--uts", "--ipc", "--net", "--pid"}
+	if hostWorkingDir != "" {
+		nsenterArgs = append(nsenterArgs, "--wd="+hostWorkingDir)
+	}
+	return nsenterCommand, append(append(nsenterArgs, "--"), hostArgv...)
+}
+
+// hostPath returns the path on the host of a path of the agent data store, other values are returned unchanged
+func hostPath(config appconfig.HostCfg, path string) string {
+	if !filepath.IsAbs(path) {
+		return path
+	}
+	rel, err := filepath.Rel(dataStorePath, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, "../") {
+		return path
+	}
+	return filepath.Join(config.StateHostPath, rel)
+}
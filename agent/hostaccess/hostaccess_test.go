@@ -0,0 +1,101 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package hostaccess
+
+import (
+	"runtime"
+	"testing"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/stretchr/testify/assert"
+)
+
+// mockConfig sets the host config of the agent config and whether the agent runs in a container
+func mockConfig(hostCfg appconfig.HostCfg, containerMode bool, inContainer bool) func() {
+	config := appconfig.DefaultConfig()
+	config.Host = hostCfg
+	config.Agent.ContainerMode = containerMode
+	origConfig, origInContainer := getAppConfig, isInContainer
+	getAppConfig = func(bool) (appconfig.SsmagentConfig, error) { return config, nil }
+	isInContainer = func() bool { return inContainer }
+	return func() { getAppConfig, isInContainer = origConfig, origInContainer }
+}
+
+func TestResolve(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("scripts only run on the host on Linux")
+	}
+	testCases := []struct {
+		name          string
+		hostAccess    string
+		containerMode bool
+		inContainer   bool
+		expected      string
+		enabled       bool
+	}{
+		{"none", appconfig.HostAccessNone, false, true, appconfig.HostAccessNone, false},
+		{"nsenter", appconfig.HostAccessNsenter, false, false, appconfig.HostAccessNsenter, true},
+		{"chroot", appconfig.HostAccessChroot, false, true, appconfig.HostAccessChroot, true},
+		{"auto in container", appconfig.HostAccessAuto, false, true, appconfig.HostAccessNsenter, true},
+		{"auto on host", appconfig.HostAccessAuto, false, false, appconfig.HostAccessAuto, false},
+		{"auto in task container", appconfig.HostAccessAuto, true, true, appconfig.HostAccessAuto, false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			defer mockConfig(appconfig.HostCfg{HostAccess: tc.hostAccess}, tc.containerMode, tc.inContainer)()
+			config, enabled := Resolve()
+			assert.Equal(t, tc.enabled, enabled)
+			assert.Equal(t, tc.expected, config.HostAccess)
+		})
+	}
+}
+
+func TestWrap(t *testing.T) {
+	origDataStore := dataStorePath
+	dataStorePath = "/var/lib/amazon/ssm/"
+	defer func() { dataStorePath = origDataStore }()
+	config := appconfig.HostCfg{HostRoot: "/host", StateHostPath: "/var/lib/ssm-node/"}
+	script := "/var/lib/amazon/ssm/i-123/document/orchestration/cmd/0.awsrunShellScript/_script.sh"
+	workingDir := "/var/lib/amazon/ssm/i-123/document/orchestration/cmd/downloads"
+
+	config.HostAccess = appconfig.HostAccessNsenter
+	name, argv := Wrap(config, workingDir, "sh", []string{"-c", script})
+	assert.Equal(t, "nsenter", name)
+	assert.Equal(t, []string{
+		"--target", "1", "--mount", "--uts", "--ipc", "--net", "--pid",
+		"--wd=/var/lib/ssm-node/i-123/document/orchestration/cmd/downloads", "--",
+		"sh", "-c", "/var/lib/ssm-node/i-123/document/orchestration/cmd/0.awsrunShellScript/_script.sh",
+	}, argv)
+
+	config.HostAccess = appconfig.HostAccessChroot
+	name, argv = Wrap(config, "/opt/app", "sh", []string{"-c", script})
+	assert.Equal(t, "chroot", name)
+	assert.Equal(t, []string{
+		"/host", "/bin/sh", "-c", `cd "$0" && exec "$@"`, "/opt/app",
+		"sh", "-c", "/var/lib/ssm-node/i-123/document/orchestration/cmd/0.awsrunShellScript/_script.sh",
+	}, argv)
+}
+
+func TestHostPath(t *testing.T) {
+	origDataStore := dataStorePath
+	dataStorePath = "/var/lib/amazon/ssm/"
+	defer func() { dataStorePath = origDataStore }()
+	config := appconfig.HostCfg{StateHostPath: "/var/lib/ssm-node"}
+
+	assert.Equal(t, "/var/lib/ssm-node/session", hostPath(config, "/var/lib/amazon/ssm/session"))
+	assert.Equal(t, "/var/lib/ssm-node", hostPath(config, "/var/lib/amazon/ssm"))
+	assert.Equal(t, "/var/lib/amazon/ssm-other", hostPath(config, "/var/lib/amazon/ssm-other"))
+	assert.Equal(t, "/usr/bin", hostPath(config, "/usr/bin"))
+	assert.Equal(t, "-c", hostPath(config, "-c"))
+}
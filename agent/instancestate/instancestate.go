@@ -0,0 +1,348 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package instancestate clears the instance specific state of the agent before an image is created from the
+// instance, and restores it from a snapshot
+package instancestate
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/managedInstances/auth"
+	"github.com/aws/amazon-ssm-agent/agent/managedInstances/registration"
+)
+
+const (
+	// vaultFolderName is the folder of the data store holding the registration and the fingerprint
+	vaultFolderName = "Vault"
+	// ClearedMarkerName is the file created in the data store when the state is cleared, the agent removes it when it next starts
+	ClearedMarkerName = "instance-state-cleared"
+	// snapshotManifestName is the file of a snapshot that lists where every saved path belongs
+	snapshotManifestName = "manifest.json"
+	// stagingSuffix is appended to the state moved aside before it is removed
+	stagingSuffix = ".clearing"
+	// restoringSuffix is appended to the state copied from a snapshot before it is moved in place
+	restoringSuffix = ".restoring"
+)
+
+var (
+	dataStorePath = appconfig.DefaultDataStorePath
+	logDir        = log.DefaultLogDir
+
+	// instanceFiles are the files and folders of the data store bound to the registration and the machine,
+	// clearing the bootstrap document result runs the document again on the first start of a clone
+	instanceFiles = []string{vaultFolderName, "registration", "reregistration", appconfig.BootstrapDocumentFolderName}
+	// instanceDirRegex matches the folders of the data store named after an instance, holding its documents and caches
+	instanceDirRegex = regexp.MustCompile(`^(i|mi)-[0-9a-f]+$`)
+
+	rename     = os.Rename
+	privateKey = registration.PrivateKey
+	deleteKey  = auth.DeleteKey
+)
+
+// snapshotEntry is a path saved in a snapshot under Name
+type snapshotEntry struct {
+	Path string `json:"path"`
+	Name string `json:"name"`
+}
+
+// Paths returns the instance specific state of the agent on this machine: the registration, the fingerprint,
+// the folders of the instances the agent ran as and, with includeLogs, the logs.
+func Paths(includeLogs bool) (paths []string, err error) {
+	for _, name := range instanceFiles {
+		if path := filepath.Join(dataStorePath, name); exists(path) {
+			paths = append(paths, path)
+		}
+	}
+	entries, err := readDir(dataStorePath)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		if entry.IsDir() && instanceDirRegex.MatchString(entry.Name()) {
+			paths = append(paths, filepath.Join(dataStorePath, entry.Name()))
+		}
+	}
+	if !includeLogs {
+		return paths, nil
+	}
+	if entries, err = readDir(logDir); err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		paths = append(paths, filepath.Join(logDir, entry.Name()))
+	}
+	return paths, nil
+}
+
+// Clear removes the instance specific state so instances launched from an image of this machine start like new ones.
+// The state is first moved aside next to where it is, and moved back when any of it cannot be moved, so it is never
+// partly cleared. With a snapshot directory the state is copied there first and can be restored with Restore.
+func Clear(log log.T, snapshotDir string, includeLogs bool) (cleared []string, err error) {
+	paths, err := Paths(includeLogs)
+	if err != nil {
+		return nil, err
+	}
+	if snapshotDir != "" {
+		if err = snapshot(paths, snapshotDir); err != nil {
+			return nil, err
+		}
+	}
+
+	// the private key can be held outside the data store, it is removed with the registration unless it is saved
+	key := ""
+	if snapshotDir == "" && exists(filepath.Join(dataStorePath, vaultFolderName)) {
+		key = privateKey()
+	}
+
+	staged, err := stage(paths)
+	if err != nil {
+		return nil, err
+	}
+	var removeErrs []string
+	for _, path := range staged {
+		if err = os.RemoveAll(path); err != nil {
+			removeErrs = append(removeErrs, err.Error())
+		}
+	}
+	if key != "" {
+		if err = deleteKey(key); err != nil {
+			log.Warnf("Failed to remove the private key of the registration: %v", err)
+		}
+	}
+	if err = ioutil.WriteFile(filepath.Join(dataStorePath, ClearedMarkerName), nil, appconfig.ReadWriteAccess); err != nil {
+		removeErrs = append(removeErrs, err.Error())
+	}
+	if len(removeErrs) > 0 {
+		return paths, fmt.Errorf("the instance state was moved aside but not entirely removed: %v", strings.Join(removeErrs, "; "))
+	}
+	log.Infof("Cleared the instance state: %v", strings.Join(paths, ", "))
+	return paths, nil
+}
+
+// Restore puts back the state saved in a snapshot by Clear, replacing the current state at the same paths.
+// The saved state is copied next to its path before the current state is replaced, which is rolled back on failure.
+func Restore(log log.T, snapshotDir string) (restored []string, err error) {
+	data, err := ioutil.ReadFile(filepath.Join(snapshotDir, snapshotManifestName))
+	if err != nil {
+		return nil, fmt.Errorf("%v is not a snapshot of the instance state: %v", snapshotDir, err)
+	}
+	var entries []snapshotEntry
+	if err = json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("invalid snapshot manifest: %v", err)
+	}
+
+	prepared := make([]string, 0, len(entries))
+	defer func() {
+		for _, path := range prepared {
+			os.RemoveAll(path)
+		}
+	}()
+	var current []string
+	for _, entry := range entries {
+		if !isStatePath(entry.Path) || filepath.Base(entry.Name) != entry.Name {
+			return nil, fmt.Errorf("snapshot entry %v does not belong to the instance state", entry.Path)
+		}
+		tmp := entry.Path + restoringSuffix
+		os.RemoveAll(tmp)
+		prepared = append(prepared, tmp)
+		if err = copyTree(filepath.Join(snapshotDir, entry.Name), tmp); err != nil {
+			return nil, fmt.Errorf("failed to copy %v from the snapshot: %v", entry.Path, err)
+		}
+		if exists(entry.Path) {
+			current = append(current, entry.Path)
+		}
+	}
+
+	staged, err := stage(current)
+	if err != nil {
+		return nil, err
+	}
+	for i, entry := range entries {
+		if err = rename(prepared[i], entry.Path); err != nil {
+			for _, done := range entries[:i] {
+				os.RemoveAll(done.Path)
+			}
+			unstage(current, len(current))
+			return nil, fmt.Errorf("failed to restore %v: %v", entry.Path, err)
+		}
+		restored = append(restored, entry.Path)
+	}
+	prepared = nil
+	for _, path := range staged {
+		os.RemoveAll(path)
+	}
+	os.Remove(filepath.Join(dataStorePath, ClearedMarkerName))
+	log.Infof("Restored the instance state: %v", strings.Join(restored, ", "))
+	return restored, nil
+}
+
+// ReinitializeAfterClear runs when the agent starts for the first time after the state was cleared, like on the first
+// boot of an instance launched from an image, and removes the folders the image builder left for other instances.
+func ReinitializeAfterClear(log log.T, instanceID string) {
+	marker := filepath.Join(dataStorePath, ClearedMarkerName)
+	if !exists(marker) {
+		return
+	}
+	log.Infof("First start since the instance state was cleared, removing the state of other instances")
+	entries, err := readDir(dataStorePath)
+	if err != nil {
+		log.Warnf("Failed to list the data store: %v", err)
+		return
+	}
+	for _, entry := range entries {
+		if entry.IsDir() && instanceDirRegex.MatchString(entry.Name()) && entry.Name() != instanceID {
+			if err = os.RemoveAll(filepath.Join(dataStorePath, entry.Name())); err != nil {
+				log.Warnf("Failed to remove the state of instance %v: %v", entry.Name(), err)
+			}
+		}
+	}
+	if err = os.Remove(marker); err != nil {
+		log.Warnf("Failed to remove %v: %v", marker, err)
+	}
+}
+
+// snapshot copies the paths into an empty snapshot directory outside the instance state, with a manifest listing them
+func snapshot(paths []string, snapshotDir string) error {
+	snapshotDir, err := filepath.Abs(snapshotDir)
+	if err != nil {
+		return err
+	}
+	for _, root := range []string{dataStorePath, logDir} {
+		if isWithin(snapshotDir, root) {
+			return fmt.Errorf("snapshot directory %v cannot be inside %v", snapshotDir, root)
+		}
+	}
+	if entries, err := readDir(snapshotDir); err != nil {
+		return err
+	} else if len(entries) > 0 {
+		return fmt.Errorf("snapshot directory %v is not empty", snapshotDir)
+	}
+	if err = os.MkdirAll(snapshotDir, appconfig.ReadWriteExecuteAccess); err != nil {
+		return err
+	}
+
+	entries := make([]snapshotEntry, 0, len(paths))
+	for i, path := range paths {
+		entry := snapshotEntry{Path: path, Name: fmt.Sprintf("%03d-%v", i, filepath.Base(path))}
+		if err = copyTree(path, filepath.Join(snapshotDir, entry.Name)); err != nil {
+			return fmt.Errorf("failed to save %v in the snapshot: %v", path, err)
+		}
+		entries = append(entries, entry)
+	}
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(snapshotDir, snapshotManifestName), data, appconfig.ReadWriteAccess)
+}
+
+// stage moves every path aside in the same folder, and moves them back when one cannot be moved
+func stage(paths []string) (staged []string, err error) {
+	for i, path := range paths {
+		os.RemoveAll(path + stagingSuffix)
+		if err = rename(path, path+stagingSuffix); err != nil {
+			unstage(paths, i)
+			return nil, fmt.Errorf("failed to move %v aside, the instance state was left as it was: %v", path, err)
+		}
+		staged = append(staged, path+stagingSuffix)
+	}
+	return staged, nil
+}
+
+// unstage moves the first count paths moved aside by stage back in place
+func unstage(paths []string, count int) {
+	for i := count - 1; i >= 0; i-- {
+		rename(paths[i]+stagingSuffix, paths[i])
+	}
+}
+
+// copyTree copies a file or a folder with its permissions, symbolic links are copied as links
+func copyTree(src, dst string) error {
+	return filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		switch {
+		case info.IsDir():
+			return os.MkdirAll(target, info.Mode().Perm())
+		case info.Mode()&os.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(link, target)
+		default:
+			return copyFile(path, target, info.Mode().Perm())
+		}
+	})
+}
+
+// copyFile copies the content of a regular file
+func copyFile(src, dst string, mode os.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// isStatePath tells whether a path is in the data store or the log folder
+func isStatePath(path string) bool {
+	return filepath.IsAbs(path) && (isWithin(path, dataStorePath) || isWithin(path, logDir)) &&
+		path != filepath.Clean(dataStorePath) && path != filepath.Clean(logDir)
+}
+
+// isWithin tells whether path is root or inside it
+func isWithin(path, root string) bool {
+	rel, err := filepath.Rel(root, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// readDir lists a folder, a missing folder is empty
+func readDir(dir string) ([]os.FileInfo, error) {
+	entries, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	return entries, err
+}
+
+// exists tells whether a path exists, without following a final symbolic link
+func exists(path string) bool {
+	_, err := os.Lstat(path)
+	return err == nil
+}
@@ -0,0 +1,185 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package instancestate
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/stretchr/testify/assert"
+)
+
+// setupState creates a data store and a log folder with instance state and shared files in a temporary folder
+func setupState(t *testing.T) (root string, restore func()) {
+	root, err := ioutil.TempDir("", "instancestate")
+	assert.NoError(t, err)
+	origDataStorePath, origLogDir, origPrivateKey, origDeleteKey := dataStorePath, logDir, privateKey, deleteKey
+	dataStorePath = filepath.Join(root, "ssm")
+	logDir = filepath.Join(root, "log")
+	privateKey = func() string { return "" }
+	deleteKey = func(string) error { return nil }
+
+	files := map[string]string{
+		"ssm/Vault/Manifest":                            "manifest",
+		"ssm/Vault/Store/RegistrationKey":               "registration",
+		"ssm/registration":                              "activation",
+		"ssm/i-0123456789abcdef0/document/state/a.json": "{}",
+		"ssm/mi-0123456789abcdef0/inventory/b.json":     "{}",
+		"ssm/packages/package/manifest.json":            "{}",
+		"ssm/ipc/health":                                "",
+		"log/amazon-ssm-agent.log":                      "started",
+	}
+	for name, content := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		assert.NoError(t, os.MkdirAll(filepath.Dir(path), 0700))
+		assert.NoError(t, ioutil.WriteFile(path, []byte(content), 0600))
+	}
+	return root, func() {
+		dataStorePath, logDir, privateKey, deleteKey = origDataStorePath, origLogDir, origPrivateKey, origDeleteKey
+		os.RemoveAll(root)
+	}
+}
+
+func TestPaths(t *testing.T) {
+	root, restore := setupState(t)
+	defer restore()
+
+	paths, err := Paths(false)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		filepath.Join(root, "ssm", "Vault"),
+		filepath.Join(root, "ssm", "registration"),
+		filepath.Join(root, "ssm", "i-0123456789abcdef0"),
+		filepath.Join(root, "ssm", "mi-0123456789abcdef0"),
+	}, paths)
+
+	paths, err = Paths(true)
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(root, "log", "amazon-ssm-agent.log"), paths[len(paths)-1])
+}
+
+func TestClearAndRestore(t *testing.T) {
+	root, restore := setupState(t)
+	defer restore()
+	snapshotDir := filepath.Join(root, "snapshot")
+
+	cleared, err := Clear(log.NewMockLog(), snapshotDir, true)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 5, len(cleared))
+	for _, path := range cleared {
+		assert.False(t, exists(path), path)
+		assert.False(t, exists(path+stagingSuffix), path)
+	}
+	assert.True(t, exists(filepath.Join(root, "ssm", "packages", "package", "manifest.json")))
+	assert.True(t, exists(filepath.Join(root, "ssm", ClearedMarkerName)))
+
+	// a state created after the clear is replaced by the snapshot
+	assert.NoError(t, os.MkdirAll(filepath.Join(root, "ssm", "Vault"), 0700))
+	restored, err := Restore(log.NewMockLog(), snapshotDir)
+
+	assert.NoError(t, err)
+	assert.Equal(t, cleared, restored)
+	content, err := ioutil.ReadFile(filepath.Join(root, "ssm", "Vault", "Store", "RegistrationKey"))
+	assert.NoError(t, err)
+	assert.Equal(t, "registration", string(content))
+	info, err := os.Stat(filepath.Join(root, "ssm", "registration"))
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+	assert.False(t, exists(filepath.Join(root, "ssm", ClearedMarkerName)))
+	assert.False(t, exists(filepath.Join(root, "ssm", "Vault"+stagingSuffix)))
+}
+
+func TestClearDeletesPrivateKeyWithoutSnapshot(t *testing.T) {
+	_, restore := setupState(t)
+	defer restore()
+	deleted := ""
+	privateKey = func() string { return "tpm:handle" }
+	deleteKey = func(key string) error { deleted = key; return nil }
+
+	_, err := Clear(log.NewMockLog(), "", false)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "tpm:handle", deleted)
+}
+
+func TestClearRollsBackWhenStateCannotBeMoved(t *testing.T) {
+	root, restore := setupState(t)
+	defer restore()
+	origRename := rename
+	defer func() { rename = origRename }()
+	calls := 0
+	rename = func(src, dst string) error {
+		if calls++; calls == 3 {
+			return errors.New("device busy")
+		}
+		return os.Rename(src, dst)
+	}
+
+	_, err := Clear(log.NewMockLog(), "", false)
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "left as it was")
+	assert.True(t, exists(filepath.Join(root, "ssm", "Vault", "Store", "RegistrationKey")))
+	assert.True(t, exists(filepath.Join(root, "ssm", "registration")))
+	assert.False(t, exists(filepath.Join(root, "ssm", "registration"+stagingSuffix)))
+	assert.False(t, exists(filepath.Join(root, "ssm", ClearedMarkerName)))
+}
+
+func TestClearRejectsSnapshotInsideState(t *testing.T) {
+	root, restore := setupState(t)
+	defer restore()
+
+	_, err := Clear(log.NewMockLog(), filepath.Join(root, "ssm", "snapshot"), false)
+
+	assert.Error(t, err)
+	assert.True(t, exists(filepath.Join(root, "ssm", "Vault")))
+}
+
+func TestRestoreRejectsPathsOutsideState(t *testing.T) {
+	root, restore := setupState(t)
+	defer restore()
+	snapshotDir := filepath.Join(root, "snapshot")
+	assert.NoError(t, os.MkdirAll(snapshotDir, 0700))
+	manifest := `[{"path": "/etc/passwd", "name": "000-passwd"}]`
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(snapshotDir, snapshotManifestName), []byte(manifest), 0600))
+
+	_, err := Restore(log.NewMockLog(), snapshotDir)
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "does not belong to the instance state")
+}
+
+func TestReinitializeAfterClear(t *testing.T) {
+	root, restore := setupState(t)
+	defer restore()
+
+	// without the marker nothing is removed
+	ReinitializeAfterClear(log.NewMockLog(), "i-0fedcba9876543210")
+	assert.True(t, exists(filepath.Join(root, "ssm", "i-0123456789abcdef0")))
+
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(root, "ssm", ClearedMarkerName), nil, 0600))
+	assert.NoError(t, os.MkdirAll(filepath.Join(root, "ssm", "i-0fedcba9876543210"), 0700))
+	ReinitializeAfterClear(log.NewMockLog(), "i-0fedcba9876543210")
+
+	assert.False(t, exists(filepath.Join(root, "ssm", "i-0123456789abcdef0")))
+	assert.False(t, exists(filepath.Join(root, "ssm", "mi-0123456789abcdef0")))
+	assert.True(t, exists(filepath.Join(root, "ssm", "i-0fedcba9876543210")))
+	assert.True(t, exists(filepath.Join(root, "ssm", "Vault")))
+	assert.False(t, exists(filepath.Join(root, "ssm", ClearedMarkerName)))
+}
@@ -0,0 +1,246 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package instancetags provides the tags of the instance to documents, which reference them as {{tag:Name}}
+package instancetags
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/platform"
+	"github.com/aws/amazon-ssm-agent/agent/sdkutil"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ssm"
+)
+
+// tagReference matches the references to instance tags, the tag key is the first group
+var tagReference = regexp.MustCompile(`{{\s*tag:([^{}]*?)\s*}}`)
+
+var (
+	getAppConfig  = appconfig.Config
+	getInstanceID = platform.InstanceID
+	timeNow       = time.Now
+
+	fetchMetadataTags        = platform.MetadataTags
+	fetchEC2Tags             = describeTags
+	fetchManagedInstanceTags = listTagsForResource
+)
+
+// tagCache holds the tags of the instance and when they were fetched
+var tagCache struct {
+	lock    sync.Mutex
+	tags    map[string]string
+	fetched time.Time
+}
+
+// Tags returns the tags of the instance, fetched again once they are older than the refresh interval of the agent
+// config. The previous tags are kept when they cannot be fetched.
+func Tags(log log.T) (map[string]string, error) {
+	tagCache.lock.Lock()
+	defer tagCache.lock.Unlock()
+
+	refreshInterval := time.Duration(appconfig.DefaultInstanceTagsRefreshIntervalSeconds) * time.Second
+	if config, err := getAppConfig(false); err == nil {
+		refreshInterval = time.Duration(config.InstanceTags.RefreshIntervalSeconds) * time.Second
+	}
+	if tagCache.tags != nil && timeNow().Sub(tagCache.fetched) < refreshInterval {
+		return tagCache.tags, nil
+	}
+
+	tags, err := fetchTags(log)
+	if err != nil {
+		if tagCache.tags != nil {
+			log.Warnf("failed to refresh the instance tags, using the tags fetched at %v: %v", tagCache.fetched, err)
+			return tagCache.tags, nil
+		}
+		return nil, err
+	}
+	tagCache.tags = tags
+	tagCache.fetched = timeNow()
+	return tags, nil
+}
+
+// Invalidate drops the cached tags, they are fetched again on their next use
+func Invalidate() {
+	tagCache.lock.Lock()
+	defer tagCache.lock.Unlock()
+	tagCache.tags = nil
+}
+
+// fetchTags fetches the tags of a managed instance from ListTagsForResource, and the tags of an EC2 instance from its
+// metadata, or from DescribeTags when the instance does not allow access to its tags in the metadata
+func fetchTags(log log.T) (map[string]string, error) {
+	instanceID, err := getInstanceID()
+	if err != nil {
+		return nil, err
+	}
+	if strings.HasPrefix(instanceID, "mi-") {
+		return fetchManagedInstanceTags(instanceID)
+	}
+	tags, err := fetchMetadataTags()
+	if err == nil {
+		return tags, nil
+	}
+	log.Debugf("instance tags are not available in the instance metadata, calling DescribeTags: %v", err)
+	return fetchEC2Tags(instanceID)
+}
+
+// describeTags returns the tags of an EC2 instance
+func describeTags(instanceID string) (map[string]string, error) {
+	client := ec2.New(sdkutil.NewSession(sdkutil.AwsConfig(), ""))
+	input := &ec2.DescribeTagsInput{
+		Filters: []*ec2.Filter{
+			{Name: aws.String("resource-id"), Values: []*string{aws.String(instanceID)}},
+		},
+	}
+	tags := make(map[string]string)
+	err := client.DescribeTagsPages(input, func(page *ec2.DescribeTagsOutput, lastPage bool) bool {
+		for _, tag := range page.Tags {
+			tags[aws.StringValue(tag.Key)] = aws.StringValue(tag.Value)
+		}
+		return true
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe the tags of %v: %v", instanceID, err)
+	}
+	return tags, nil
+}
+
+// listTagsForResource returns the tags of a managed instance
+func listTagsForResource(instanceID string) (map[string]string, error) {
+	awsConfig := sdkutil.AwsConfig()
+	if config, err := getAppConfig(false); err == nil && config.Ssm.Endpoint != "" {
+		awsConfig.Endpoint = aws.String(config.Ssm.Endpoint)
+	}
+	client := ssm.New(sdkutil.NewSession(awsConfig, ""))
+	output, err := client.ListTagsForResource(&ssm.ListTagsForResourceInput{
+		ResourceType: aws.String(ssm.ResourceTypeForTaggingManagedInstance),
+		ResourceId:   aws.String(instanceID),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list the tags of %v: %v", instanceID, err)
+	}
+	tags := make(map[string]string)
+	for _, tag := range output.TagList {
+		tags[aws.StringValue(tag.Key)] = aws.StringValue(tag.Value)
+	}
+	return tags, nil
+}
+
+// TextContainsTags returns true when the text references an instance tag
+func TextContainsTags(text string) bool {
+	return tagReference.MatchString(text)
+}
+
+// ResolveText replaces the tag references of the text with the tag values, missing tags are replaced with an empty
+// string so that preconditions comparing them do not match
+func ResolveText(log log.T, text string) (string, error) {
+	if !TextContainsTags(text) {
+		return text, nil
+	}
+	tags, err := Tags(log)
+	if err != nil {
+		return text, err
+	}
+	return tagReference.ReplaceAllStringFunc(text, func(reference string) string {
+		return tags[tagReference.FindStringSubmatch(reference)[1]]
+	}), nil
+}
+
+// Resolve replaces the tag references in the strings of the input with the tag values, it fails when a referenced
+// tag is not set on the instance
+func Resolve(log log.T, input interface{}) (interface{}, error) {
+	var keys []string
+	collectTagKeys(input, &keys)
+	if len(keys) == 0 {
+		return input, nil
+	}
+	tags, err := Tags(log)
+	if err != nil {
+		return input, fmt.Errorf("failed to get the instance tags: %v", err)
+	}
+	for _, key := range keys {
+		if _, ok := tags[key]; !ok {
+			return input, fmt.Errorf("the instance has no tag %q", key)
+		}
+	}
+	return replaceTags(input, tags), nil
+}
+
+// collectTagKeys appends the keys of the tags the input references
+func collectTagKeys(input interface{}, keys *[]string) {
+	switch value := input.(type) {
+	case string:
+		for _, match := range tagReference.FindAllStringSubmatch(value, -1) {
+			*keys = append(*keys, match[1])
+		}
+	case []interface{}:
+		for _, item := range value {
+			collectTagKeys(item, keys)
+		}
+	case []string:
+		for _, item := range value {
+			collectTagKeys(item, keys)
+		}
+	case map[string]interface{}:
+		for _, item := range value {
+			collectTagKeys(item, keys)
+		}
+	case []map[string]interface{}:
+		for _, item := range value {
+			collectTagKeys(item, keys)
+		}
+	}
+}
+
+// replaceTags returns the input with the tag references replaced
+func replaceTags(input interface{}, tags map[string]string) interface{} {
+	switch value := input.(type) {
+	case string:
+		return tagReference.ReplaceAllStringFunc(value, func(reference string) string {
+			return tags[tagReference.FindStringSubmatch(reference)[1]]
+		})
+	case []interface{}:
+		replaced := make([]interface{}, len(value))
+		for i, item := range value {
+			replaced[i] = replaceTags(item, tags)
+		}
+		return replaced
+	case []string:
+		replaced := make([]string, len(value))
+		for i, item := range value {
+			replaced[i] = replaceTags(item, tags).(string)
+		}
+		return replaced
+	case []map[string]interface{}:
+		replaced := make([]map[string]interface{}, len(value))
+		for i, item := range value {
+			replaced[i] = replaceTags(item, tags).(map[string]interface{})
+		}
+		return replaced
+	case map[string]interface{}:
+		replaced := make(map[string]interface{}, len(value))
+		for key, item := range value {
+			replaced[key] = replaceTags(item, tags)
+		}
+		return replaced
+	}
+	return input
+}
@@ -0,0 +1,151 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package instancetags
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/platform"
+	"github.com/stretchr/testify/assert"
+)
+
+// tagsFixture stubs the tag sources and counts the fetches
+type tagsFixture struct {
+	now         time.Time
+	instanceID  string
+	metadata    map[string]string
+	metadataErr error
+	ec2         map[string]string
+	managed     map[string]string
+	fetches     int
+}
+
+func setupTags(t *testing.T, fixture *tagsFixture) {
+	Invalidate()
+	getAppConfig = func(bool) (appconfig.SsmagentConfig, error) {
+		return appconfig.SsmagentConfig{InstanceTags: appconfig.InstanceTagsCfg{RefreshIntervalSeconds: 60}}, nil
+	}
+	getInstanceID = func() (string, error) { return fixture.instanceID, nil }
+	timeNow = func() time.Time { return fixture.now }
+	fetchMetadataTags = func() (map[string]string, error) {
+		fixture.fetches++
+		return fixture.metadata, fixture.metadataErr
+	}
+	fetchEC2Tags = func(string) (map[string]string, error) {
+		if fixture.ec2 == nil {
+			return nil, errors.New("access denied")
+		}
+		return fixture.ec2, nil
+	}
+	fetchManagedInstanceTags = func(string) (map[string]string, error) {
+		fixture.fetches++
+		return fixture.managed, nil
+	}
+}
+
+func restoreTags() {
+	Invalidate()
+	getAppConfig = appconfig.Config
+	getInstanceID = platform.InstanceID
+	timeNow = time.Now
+	fetchMetadataTags = platform.MetadataTags
+	fetchEC2Tags = describeTags
+	fetchManagedInstanceTags = listTagsForResource
+}
+
+func TestTagsRefresh(t *testing.T) {
+	fixture := &tagsFixture{now: time.Now(), instanceID: "i-1234567890", metadata: map[string]string{"Environment": "prod"}}
+	setupTags(t, fixture)
+	defer restoreTags()
+	logger := log.NewMockLog()
+
+	tags, err := Tags(logger)
+	assert.NoError(t, err)
+	assert.Equal(t, "prod", tags["Environment"])
+
+	fixture.metadata = map[string]string{"Environment": "staging"}
+	fixture.now = fixture.now.Add(30 * time.Second)
+	tags, _ = Tags(logger)
+	assert.Equal(t, "prod", tags["Environment"])
+	assert.Equal(t, 1, fixture.fetches)
+
+	fixture.now = fixture.now.Add(time.Minute)
+	tags, _ = Tags(logger)
+	assert.Equal(t, "staging", tags["Environment"])
+
+	// the tags fetched last are kept when the refresh fails
+	fixture.metadataErr = errors.New("not found")
+	fixture.now = fixture.now.Add(time.Minute)
+	tags, err = Tags(logger)
+	assert.NoError(t, err)
+	assert.Equal(t, "staging", tags["Environment"])
+}
+
+func TestTagsSources(t *testing.T) {
+	fixture := &tagsFixture{instanceID: "i-1234567890", metadataErr: errors.New("not found"), ec2: map[string]string{"Team": "web"}}
+	setupTags(t, fixture)
+	defer restoreTags()
+
+	tags, err := Tags(log.NewMockLog())
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"Team": "web"}, tags)
+
+	Invalidate()
+	fixture.ec2 = nil
+	_, err = Tags(log.NewMockLog())
+	assert.Error(t, err)
+
+	Invalidate()
+	fixture.instanceID = "mi-1234567890"
+	fixture.managed = map[string]string{"Site": "dc1"}
+	tags, err = Tags(log.NewMockLog())
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"Site": "dc1"}, tags)
+}
+
+func TestResolve(t *testing.T) {
+	fixture := &tagsFixture{instanceID: "i-1234567890", metadata: map[string]string{"Environment": "prod", "Cost Center": "42"}}
+	setupTags(t, fixture)
+	defer restoreTags()
+	logger := log.NewMockLog()
+
+	input := map[string]interface{}{
+		"runCommand": []interface{}{"echo {{tag:Environment}}", "echo {{ tag:Cost Center }}"},
+		"timeout":    3600,
+	}
+	resolved, err := Resolve(logger, input)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"runCommand": []interface{}{"echo prod", "echo 42"},
+		"timeout":    3600,
+	}, resolved)
+
+	_, err = Resolve(logger, []string{"{{tag:Owner}}"})
+	assert.Error(t, err)
+
+	text, err := ResolveText(logger, "{{tag:Owner}}-{{tag:Environment}}")
+	assert.NoError(t, err)
+	assert.Equal(t, "-prod", text)
+
+	// inputs without tag references never fetch the tags
+	Invalidate()
+	resolved, err = Resolve(logger, "echo {{ssm:/app/name}}")
+	assert.NoError(t, err)
+	assert.Equal(t, "echo {{ssm:/app/name}}", resolved)
+	assert.Equal(t, 1, fixture.fetches)
+}
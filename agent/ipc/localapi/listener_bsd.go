@@ -0,0 +1,34 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// +build darwin freebsd
+
+package localapi
+
+import (
+	"net"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+)
+
+// listen relies on the permissions of the socket to check the peer, which only root and the allowed group can open
+func listen(log log.T, config appconfig.LocalApiCfg) (net.Listener, func(conn net.Conn) (Peer, error), error) {
+	listener, _, err := listenUnix(config)
+	if err != nil {
+		return nil, nil, err
+	}
+	// only root can open the socket when no group is allowed
+	privileged := config.AllowedGroup == ""
+	return listener, func(conn net.Conn) (Peer, error) { return Peer{Privileged: privileged}, nil }, nil
+}
@@ -0,0 +1,126 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// +build linux
+
+package localapi
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"os/user"
+	"strconv"
+	"syscall"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/systemd"
+)
+
+var systemdListeners = systemd.Listeners
+
+func listen(log log.T, config appconfig.LocalApiCfg) (net.Listener, func(conn net.Conn) (Peer, error), error) {
+	listener, allowedGid, err := listenSystemd(log, config)
+	if listener == nil && err == nil {
+		listener, allowedGid, err = listenUnix(config)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return listener, func(conn net.Conn) (Peer, error) {
+		return authorizePeer(log, conn, allowedGid)
+	}, nil
+}
+
+// listenSystemd serves the socket of amazon-ssm-agent.socket when the agent was started by socket activation,
+// systemd owns the socket file and its permissions then
+func listenSystemd(log log.T, config appconfig.LocalApiCfg) (net.Listener, int, error) {
+	listeners, err := systemdListeners()
+	if err != nil || len(listeners) == 0 {
+		return nil, -1, err
+	}
+	for _, extra := range listeners[1:] {
+		extra.Close()
+	}
+	allowedGid, err := allowedGroupID(config)
+	if err != nil {
+		listeners[0].Close()
+		return nil, -1, err
+	}
+	log.Infof("local api uses the socket passed by systemd")
+	return listeners[0], allowedGid, nil
+}
+
+// authorizePeer checks the credentials of the peer process, root, the user of the agent and members of the
+// allowed group are served. Only root is privileged, the other peers are identified by their account.
+func authorizePeer(log log.T, conn net.Conn, allowedGid int) (Peer, error) {
+	unixConn, ok := conn.(*net.UnixConn)
+	if !ok {
+		return Peer{}, fmt.Errorf("unexpected connection type %T", conn)
+	}
+	rawConn, err := unixConn.SyscallConn()
+	if err != nil {
+		return Peer{}, err
+	}
+	var cred *syscall.Ucred
+	var credErr error
+	if err = rawConn.Control(func(fd uintptr) {
+		cred, credErr = syscall.GetsockoptUcred(int(fd), syscall.SOL_SOCKET, syscall.SO_PEERCRED)
+	}); err != nil {
+		return Peer{}, err
+	}
+	if credErr != nil {
+		return Peer{}, credErr
+	}
+	if cred.Uid == 0 {
+		return Peer{Privileged: true}, nil
+	}
+	if int(cred.Uid) == os.Getuid() || isGroupMember(cred, allowedGid) {
+		return Peer{User: userName(cred.Uid)}, nil
+	}
+	log.Warnf("rejected local api request of uid %v, pid %v", cred.Uid, cred.Pid)
+	return Peer{}, fmt.Errorf("uid %v is not allowed to use the local api", cred.Uid)
+}
+
+// userName returns the name of the account of uid, empty when it cannot be looked up
+func userName(uid uint32) string {
+	account, err := user.LookupId(strconv.Itoa(int(uid)))
+	if err != nil {
+		return ""
+	}
+	return account.Username
+}
+
+func isGroupMember(cred *syscall.Ucred, allowedGid int) bool {
+	if allowedGid < 0 {
+		return false
+	}
+	if int(cred.Gid) == allowedGid {
+		return true
+	}
+	account, err := user.LookupId(strconv.Itoa(int(cred.Uid)))
+	if err != nil {
+		return false
+	}
+	groupIds, err := account.GroupIds()
+	if err != nil {
+		return false
+	}
+	for _, gid := range groupIds {
+		if gid == strconv.Itoa(allowedGid) {
+			return true
+		}
+	}
+	return false
+}
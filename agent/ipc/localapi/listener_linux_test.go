@@ -0,0 +1,72 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// +build linux
+
+package localapi
+
+import (
+	"net"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/systemd"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCallOverSocket(t *testing.T) {
+	defer mockDocuments(t, map[string]contracts.DocumentState{})()
+	originalAddress := Address
+	Address = filepath.Join(t.TempDir(), "localapi")
+	defer func() { Address = originalAddress }()
+
+	listener, authorize, err := listen(logMock, appconfig.LocalApiCfg{Enabled: true})
+	assert.NoError(t, err)
+	server := &Server{log: logMock, listener: listener, authorize: authorize}
+	go server.Serve()
+	defer listener.Close()
+
+	// the test runs as the user of the agent, which the peer check allows
+	resp, err := Call(VerbStatus)
+	assert.NoError(t, err)
+	assert.Equal(t, "i-1234567890", resp.Status.InstanceID)
+
+	_, err = Call("reboot")
+	assert.Error(t, err)
+}
+
+func TestCallOverSystemdSocket(t *testing.T) {
+	defer mockDocuments(t, map[string]contracts.DocumentState{})()
+	originalAddress := Address
+	Address = filepath.Join(t.TempDir(), "localapi")
+	defer func() { Address = originalAddress }()
+
+	// systemd created the socket before starting the agent
+	passed, err := net.Listen("unix", Address)
+	assert.NoError(t, err)
+	systemdListeners = func() ([]net.Listener, error) { return []net.Listener{passed}, nil }
+	defer func() { systemdListeners = systemd.Listeners }()
+
+	listener, authorize, err := listen(logMock, appconfig.LocalApiCfg{Enabled: true})
+	assert.NoError(t, err)
+	assert.Equal(t, passed, listener)
+	server := &Server{log: logMock, listener: listener, authorize: authorize}
+	go server.Serve()
+	defer listener.Close()
+
+	resp, err := Call(VerbStatus)
+	assert.NoError(t, err)
+	assert.Equal(t, "i-1234567890", resp.Status.InstanceID)
+}
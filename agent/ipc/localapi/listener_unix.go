@@ -0,0 +1,74 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// +build darwin freebsd linux
+
+package localapi
+
+import (
+	"net"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/common/message"
+)
+
+// Address is the unix socket the local api listens on
+var Address = message.DefaultCoreAgentChannel + "localapi"
+
+// allowedGroupID returns the id of the group allowed to use the local api, -1 when no group is configured
+func allowedGroupID(config appconfig.LocalApiCfg) (int, error) {
+	if config.AllowedGroup == "" {
+		return -1, nil
+	}
+	group, err := user.LookupGroup(config.AllowedGroup)
+	if err != nil {
+		return -1, err
+	}
+	return strconv.Atoi(group.Gid)
+}
+
+// listenUnix listens on the socket, which only root and the configured group can open
+func listenUnix(config appconfig.LocalApiCfg) (listener net.Listener, allowedGid int, err error) {
+	if allowedGid, err = allowedGroupID(config); err != nil {
+		return nil, -1, err
+	}
+
+	if err = os.MkdirAll(filepath.Dir(Address), appconfig.ReadWriteExecuteAccess); err != nil {
+		return nil, allowedGid, err
+	}
+	os.Remove(Address)
+	if listener, err = net.Listen("unix", Address); err != nil {
+		return nil, allowedGid, err
+	}
+	mode := os.FileMode(appconfig.ReadWriteAccess)
+	if allowedGid >= 0 {
+		mode = 0660
+		err = os.Chown(Address, -1, allowedGid)
+	}
+	if err == nil {
+		err = os.Chmod(Address, mode)
+	}
+	if err != nil {
+		listener.Close()
+		return nil, allowedGid, err
+	}
+	return listener, allowedGid, nil
+}
+
+func dial() (net.Conn, error) {
+	return net.Dial("unix", Address)
+}
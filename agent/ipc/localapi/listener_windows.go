@@ -0,0 +1,61 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// +build windows
+
+package localapi
+
+import (
+	"net"
+	"os/user"
+	"time"
+
+	"github.com/Microsoft/go-winio"
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+)
+
+// Address is the named pipe the local api listens on
+var Address = `\\.\pipe\amazon-ssm-agent-localapi`
+
+const (
+	// pipeSecurityDescriptor grants SYSTEM and the local administrators access to the pipe
+	pipeSecurityDescriptor = "D:P(A;;GA;;;SY)(A;;GA;;;BA)"
+	dialTimeout            = 5 * time.Second
+)
+
+// listen relies on the security descriptor of the pipe to check the peer, which only SYSTEM, administrators
+// and the allowed group can open
+func listen(log log.T, config appconfig.LocalApiCfg) (net.Listener, func(conn net.Conn) (Peer, error), error) {
+	securityDescriptor := pipeSecurityDescriptor
+	if config.AllowedGroup != "" {
+		group, err := user.LookupGroup(config.AllowedGroup)
+		if err != nil {
+			return nil, nil, err
+		}
+		// the group id is the SID of the group on Windows
+		securityDescriptor += "(A;;GRGW;;;" + group.Gid + ")"
+	}
+	listener, err := winio.ListenPipe(Address, &winio.PipeConfig{SecurityDescriptor: securityDescriptor})
+	if err != nil {
+		return nil, nil, err
+	}
+	// only SYSTEM and administrators can open the pipe when no group is allowed
+	privileged := config.AllowedGroup == ""
+	return listener, func(conn net.Conn) (Peer, error) { return Peer{Privileged: privileged}, nil }, nil
+}
+
+func dial() (net.Conn, error) {
+	timeout := dialTimeout
+	return winio.DialPipe(Address, &timeout)
+}
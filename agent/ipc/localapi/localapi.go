@@ -0,0 +1,489 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package localapi serves the status of the agent and administrative actions to tools on the instance,
+// over a unix socket or a named pipe that only privileged users can connect to.
+package localapi
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/association/compliance/model"
+	"github.com/aws/amazon-ssm-agent/agent/association/schedulemanager"
+	"github.com/aws/amazon-ssm-agent/agent/context"
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/framework/docmanager"
+	"github.com/aws/amazon-ssm-agent/agent/health"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/outbox"
+	"github.com/aws/amazon-ssm-agent/agent/platform"
+	"github.com/aws/amazon-ssm-agent/agent/version"
+	"github.com/aws/aws-sdk-go/aws"
+)
+
+const (
+	// VerbStatus returns the state of the agent and its connections
+	VerbStatus = "status"
+	// VerbDocuments returns the documents that are pending or running
+	VerbDocuments = "documents"
+	// VerbSessions returns the sessions that are running
+	VerbSessions = "sessions"
+	// VerbAssociations returns the last results of the associations
+	VerbAssociations = "associations"
+	// VerbFlushLogs writes the buffered log messages to the log files
+	VerbFlushLogs = "flush-logs"
+	// VerbRefreshAssociation lists the associations of the instance and runs those that are due
+	VerbRefreshAssociation = "refresh-association"
+	// VerbStartSession starts a loopback session, the connection then carries the session frames
+	VerbStartSession = "start-session"
+	// VerbAttachSession connects the session worker of a loopback session to its client
+	VerbAttachSession = "attach-session"
+	// VerbPutComplianceItems queues the items of a custom compliance type for PutComplianceItems
+	VerbPutComplianceItems = "put-compliance-items"
+
+	// StateStarting is the state of the agent until it reached the service or started hibernating
+	StateStarting = "Starting"
+	// StateHibernating is the state of the agent while it cannot reach the service
+	StateHibernating = "Hibernating"
+	// StateActive is the state of the agent once its core modules started
+	StateActive = "Active"
+
+	// requestTimeout limits how long a client may take to send its request
+	requestTimeout = 10 * time.Second
+)
+
+// Request is sent by a client, one request per connection
+type Request struct {
+	Verb      string
+	Session   *SessionRequest `json:",omitempty"`
+	SessionID string          `json:",omitempty"`
+	Token     string          `json:",omitempty"`
+	// Compliance is the report of a put-compliance-items request
+	Compliance *model.CustomComplianceReport `json:",omitempty"`
+	// Peer is the client as the server identified it, it is never read from the request
+	Peer Peer `json:"-"`
+}
+
+// Peer is the client of a request
+type Peer struct {
+	// Privileged is true for root, and on the platforms that cannot identify the peer when only administrators can connect
+	Privileged bool
+	// User is the account of an unprivileged peer, empty when it is not known
+	User string
+}
+
+// SessionRequest describes a loopback session, it carries what the service would send for a real session
+type SessionRequest struct {
+	SessionType     string
+	DocumentContent *contracts.SessionDocumentContent `json:",omitempty"`
+	Parameters      map[string]interface{}            `json:",omitempty"`
+	RunAsUser       string                            `json:",omitempty"`
+}
+
+// SessionFrame is one message of a loopback session stream, in either direction
+type SessionFrame struct {
+	PayloadType   uint32
+	Payload       []byte `json:",omitempty"`
+	SessionStatus string `json:",omitempty"`
+}
+
+// Response is returned for a request, Error is set when the request failed
+type Response struct {
+	Error        string              `json:",omitempty"`
+	Status       *AgentStatus        `json:",omitempty"`
+	Documents    []DocumentStatus    `json:",omitempty"`
+	Associations []AssociationStatus `json:",omitempty"`
+	Message      string              `json:",omitempty"`
+	// ComplianceSummary counts the items of a put-compliance-items request by status and severity
+	ComplianceSummary *model.CustomComplianceSummary `json:",omitempty"`
+}
+
+// AgentStatus is the state of the agent worker
+type AgentStatus struct {
+	State                 string
+	Version               string
+	InstanceID            string
+	Region                string
+	StartTime             time.Time
+	QueuedResults         int
+	Connections           map[string]health.ConnectionHealth
+	RunningDocuments      int
+	RunningSessions       int
+	ScheduledAssociations int
+	BootstrapDocument     *health.BootstrapDocumentHealth `json:",omitempty"`
+}
+
+// DocumentStatus is a document or session that is pending or running
+type DocumentStatus struct {
+	DocumentID   string
+	DocumentName string
+	DocumentType contracts.DocumentType
+	Status       contracts.ResultStatus
+	CreatedDate  string
+	Pending      bool
+}
+
+// AssociationStatus is the last result of an association
+type AssociationStatus struct {
+	AssociationID     string
+	Name              string
+	DocumentVersion   string
+	Status            string
+	LastExecutionDate *time.Time `json:",omitempty"`
+	NextScheduledDate *time.Time `json:",omitempty"`
+}
+
+// Action is an administrative verb registered by the module that implements it
+type Action func(log log.T) error
+
+// RequestAction is a verb that needs the content of the request to answer it
+type RequestAction func(log log.T, req Request) Response
+
+// StreamAction is a verb that keeps the connection open once it answered the request.
+// When the response has no error the stream function is called with the connection and owns it until it returns.
+type StreamAction func(log log.T, req Request) (resp Response, stream func(conn net.Conn))
+
+// connectionNames are the connections reported in the agent status
+var connectionNames = []string{"controlchannel"}
+
+var (
+	instanceID     = platform.InstanceID
+	region         = platform.Region
+	documentStates = docmanager.DocumentStates
+
+	startTime  = time.Now()
+	stateLock  sync.RWMutex
+	agentState = StateStarting
+
+	actionsLock    sync.RWMutex
+	actions        = map[string]Action{}
+	requestActions = map[string]RequestAction{}
+	streamActions  = map[string]StreamAction{}
+
+	serverLock   sync.Mutex
+	activeServer *Server
+)
+
+// Server answers the requests of the clients connected to the local api
+type Server struct {
+	log       log.T
+	listener  net.Listener
+	authorize func(conn net.Conn) (Peer, error)
+}
+
+// Start starts the local api when it is enabled in the agent configuration
+func Start(context context.T) {
+	config := context.AppConfig()
+	if !config.LocalApi.Enabled {
+		return
+	}
+	log := context.Log()
+
+	serverLock.Lock()
+	defer serverLock.Unlock()
+	if activeServer != nil {
+		return
+	}
+	listener, authorize, err := listen(log, config.LocalApi)
+	if err != nil {
+		log.Errorf("failed to start the local api: %v", err)
+		return
+	}
+	RegisterAction(VerbFlushLogs, flushLogs)
+	activeServer = &Server{log: log, listener: listener, authorize: authorize}
+	go activeServer.Serve()
+	log.Infof("local api listening on %v", Address)
+}
+
+// Stop closes the local api
+func Stop() {
+	serverLock.Lock()
+	defer serverLock.Unlock()
+	if activeServer != nil {
+		activeServer.listener.Close()
+		activeServer = nil
+	}
+}
+
+// SetAgentState sets the state reported in the agent status
+func SetAgentState(state string) {
+	stateLock.Lock()
+	defer stateLock.Unlock()
+	agentState = state
+}
+
+// RegisterAction sets the action of an administrative verb, a nil action removes it
+func RegisterAction(verb string, action Action) {
+	actionsLock.Lock()
+	defer actionsLock.Unlock()
+	if action == nil {
+		delete(actions, verb)
+		return
+	}
+	actions[verb] = action
+}
+
+// RegisterRequestAction sets the action of a verb that reads the request, a nil action removes it
+func RegisterRequestAction(verb string, action RequestAction) {
+	actionsLock.Lock()
+	defer actionsLock.Unlock()
+	if action == nil {
+		delete(requestActions, verb)
+		return
+	}
+	requestActions[verb] = action
+}
+
+// RegisterStreamAction sets the action of a streaming verb, a nil action removes it
+func RegisterStreamAction(verb string, action StreamAction) {
+	actionsLock.Lock()
+	defer actionsLock.Unlock()
+	if action == nil {
+		delete(streamActions, verb)
+		return
+	}
+	streamActions[verb] = action
+}
+
+// Serve handles connections until the server is stopped
+func (s *Server) Serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *Server) handle(conn net.Conn) {
+	defer conn.Close()
+
+	var resp Response
+	var req Request
+	var stream func(conn net.Conn)
+	var streaming bool
+	decoder := json.NewDecoder(conn)
+	conn.SetReadDeadline(time.Now().Add(requestTimeout))
+	peer, err := s.authorize(conn)
+	if err != nil {
+		resp.Error = err.Error()
+	} else if err = decoder.Decode(&req); err != nil {
+		resp.Error = fmt.Sprintf("invalid request: %v", err)
+	} else {
+		req.Peer = peer
+		if resp, stream, streaming = s.processStream(req); !streaming {
+			resp = s.process(req)
+		}
+	}
+	if err := json.NewEncoder(conn).Encode(resp); err != nil {
+		s.log.Debugf("failed to send local api response: %v", err)
+		return
+	}
+	if stream != nil && resp.Error == "" {
+		conn.SetReadDeadline(time.Time{})
+		stream(&streamConn{Conn: conn, reader: io.MultiReader(decoder.Buffered(), conn)})
+	}
+}
+
+func (s *Server) process(req Request) Response {
+	switch req.Verb {
+	case VerbStatus:
+		return Response{Status: agentStatus(s.log)}
+	case VerbDocuments:
+		return Response{Documents: activeDocuments(s.log, false)}
+	case VerbSessions:
+		return Response{Documents: activeDocuments(s.log, true)}
+	case VerbAssociations:
+		return Response{Associations: associationResults()}
+	}
+
+	actionsLock.RLock()
+	action, ok := actions[req.Verb]
+	requestAction, isRequestAction := requestActions[req.Verb]
+	actionsLock.RUnlock()
+	if isRequestAction {
+		s.log.Infof("local api request: %v", req.Verb)
+		return requestAction(s.log, req)
+	}
+	if !ok {
+		return Response{Error: fmt.Sprintf("unknown verb %q", req.Verb)}
+	}
+	s.log.Infof("local api request: %v", req.Verb)
+	if err := action(s.log); err != nil {
+		return Response{Error: err.Error()}
+	}
+	return Response{Message: fmt.Sprintf("%v done", req.Verb)}
+}
+
+// processStream answers the request of a streaming verb, it returns false when the verb is not a streaming verb
+func (s *Server) processStream(req Request) (resp Response, stream func(conn net.Conn), ok bool) {
+	actionsLock.RLock()
+	action, ok := streamActions[req.Verb]
+	actionsLock.RUnlock()
+	if !ok {
+		return
+	}
+	s.log.Infof("local api stream request: %v", req.Verb)
+	resp, stream = action(s.log, req)
+	return resp, stream, true
+}
+
+// flushLogs writes the buffered log messages to the log files
+func flushLogs(log log.T) error {
+	log.Flush()
+	return nil
+}
+
+func agentStatus(log log.T) *AgentStatus {
+	stateLock.RLock()
+	state := agentState
+	stateLock.RUnlock()
+
+	status := &AgentStatus{
+		State:         state,
+		Version:       version.Version,
+		StartTime:     startTime,
+		QueuedResults: outbox.Pending(""),
+		Connections:   map[string]health.ConnectionHealth{},
+	}
+	status.InstanceID, _ = instanceID()
+	status.Region, _ = region()
+	for _, name := range connectionNames {
+		if connection, ok := health.Connection(name); ok {
+			status.Connections[name] = connection
+		}
+	}
+	for _, doc := range activeDocuments(log, false) {
+		if !doc.Pending {
+			status.RunningDocuments++
+		}
+	}
+	status.RunningSessions = len(activeDocuments(log, true))
+	status.ScheduledAssociations = len(schedulemanager.Schedules())
+	if bootstrapDocument, ok := health.BootstrapDocument(); ok {
+		status.BootstrapDocument = &bootstrapDocument
+	}
+	return status
+}
+
+// activeDocuments reads the states of the pending and running documents, either the sessions or the other documents
+func activeDocuments(log log.T, sessions bool) []DocumentStatus {
+	id, err := instanceID()
+	if err != nil {
+		return nil
+	}
+	var documents []DocumentStatus
+	for _, location := range []string{appconfig.DefaultLocationOfPending, appconfig.DefaultLocationOfCurrent} {
+		for _, docState := range documentStates(log, id, location) {
+			if (docState.DocumentType == contracts.StartSession) != sessions {
+				continue
+			}
+			info := docState.DocumentInformation
+			documents = append(documents, DocumentStatus{
+				DocumentID:   info.DocumentID,
+				DocumentName: info.DocumentName,
+				DocumentType: docState.DocumentType,
+				Status:       info.DocumentStatus,
+				CreatedDate:  info.CreatedDate,
+				Pending:      location == appconfig.DefaultLocationOfPending,
+			})
+		}
+	}
+	return documents
+}
+
+// associationResults returns the associations of the last association poll with their last status
+func associationResults() []AssociationStatus {
+	var results []AssociationStatus
+	for _, assoc := range schedulemanager.Schedules() {
+		if assoc.Association == nil {
+			continue
+		}
+		results = append(results, AssociationStatus{
+			AssociationID:     aws.StringValue(assoc.Association.AssociationId),
+			Name:              aws.StringValue(assoc.Association.Name),
+			DocumentVersion:   aws.StringValue(assoc.Association.DocumentVersion),
+			Status:            aws.StringValue(assoc.Association.DetailedStatus),
+			LastExecutionDate: assoc.Association.LastExecutionDate,
+			NextScheduledDate: assoc.NextScheduledDate,
+		})
+	}
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].AssociationID < results[j].AssociationID
+	})
+	return results
+}
+
+// Call sends one request to the local api of the agent and returns its response
+func Call(verb string) (resp Response, err error) {
+	return Send(Request{Verb: verb})
+}
+
+// Send sends a request with content to the local api of the agent and returns its response
+func Send(req Request) (resp Response, err error) {
+	var conn net.Conn
+	if conn, err = dial(); err != nil {
+		return
+	}
+	defer conn.Close()
+
+	if err = json.NewEncoder(conn).Encode(req); err != nil {
+		return
+	}
+	if err = json.NewDecoder(conn).Decode(&resp); err != nil {
+		return
+	}
+	if resp.Error != "" {
+		err = errors.New(resp.Error)
+	}
+	return
+}
+
+// OpenStream sends the request of a streaming verb, once the agent accepted it the returned connection carries the stream
+func OpenStream(req Request) (stream net.Conn, resp Response, err error) {
+	var conn net.Conn
+	if conn, err = dial(); err != nil {
+		return
+	}
+	decoder := json.NewDecoder(conn)
+	if err = json.NewEncoder(conn).Encode(req); err == nil {
+		err = decoder.Decode(&resp)
+	}
+	if err == nil && resp.Error != "" {
+		err = errors.New(resp.Error)
+	}
+	if err != nil {
+		conn.Close()
+		return
+	}
+	return &streamConn{Conn: conn, reader: io.MultiReader(decoder.Buffered(), conn)}, resp, nil
+}
+
+// streamConn reads the bytes the json decoder of the request or response buffered before the rest of the connection
+type streamConn struct {
+	net.Conn
+	reader io.Reader
+}
+
+func (c *streamConn) Read(b []byte) (int, error) {
+	return c.reader.Read(b)
+}
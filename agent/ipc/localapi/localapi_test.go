@@ -0,0 +1,169 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package localapi
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/association/compliance/model"
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/stretchr/testify/assert"
+)
+
+var logMock = log.NewMockLog()
+
+func mockDocuments(t *testing.T, states map[string]contracts.DocumentState) func() {
+	locations := map[string][]contracts.DocumentState{}
+	for _, state := range states {
+		location := appconfig.DefaultLocationOfCurrent
+		if state.DocumentInformation.DocumentStatus == contracts.ResultStatusNotStarted {
+			location = appconfig.DefaultLocationOfPending
+		}
+		locations[location] = append(locations[location], state)
+	}
+
+	originalInstanceID, originalRegion, originalDocumentStates := instanceID, region, documentStates
+	instanceID = func() (string, error) { return "i-1234567890", nil }
+	region = func() (string, error) { return "us-east-1", nil }
+	documentStates = func(log log.T, instanceID, locationFolder string) []contracts.DocumentState {
+		return locations[locationFolder]
+	}
+	return func() {
+		instanceID, region, documentStates = originalInstanceID, originalRegion, originalDocumentStates
+	}
+}
+
+func documentState(id string, docType contracts.DocumentType, status contracts.ResultStatus) contracts.DocumentState {
+	return contracts.DocumentState{
+		DocumentType: docType,
+		DocumentInformation: contracts.DocumentInfo{
+			DocumentID:     id,
+			DocumentName:   "AWS-RunShellScript",
+			DocumentStatus: status,
+		},
+	}
+}
+
+func TestProcessStatusAndDocuments(t *testing.T) {
+	defer mockDocuments(t, map[string]contracts.DocumentState{
+		"command-1": documentState("command-1", contracts.SendCommand, contracts.ResultStatusInProgress),
+		"command-2": documentState("command-2", contracts.SendCommand, contracts.ResultStatusNotStarted),
+		"session-1": documentState("session-1", contracts.StartSession, contracts.ResultStatusInProgress),
+	})()
+	SetAgentState(StateActive)
+	defer SetAgentState(StateStarting)
+	server := &Server{log: logMock}
+
+	resp := server.process(Request{Verb: VerbStatus})
+	assert.Empty(t, resp.Error)
+	assert.Equal(t, StateActive, resp.Status.State)
+	assert.Equal(t, "i-1234567890", resp.Status.InstanceID)
+	assert.Equal(t, "us-east-1", resp.Status.Region)
+	assert.Equal(t, 1, resp.Status.RunningDocuments)
+	assert.Equal(t, 1, resp.Status.RunningSessions)
+
+	resp = server.process(Request{Verb: VerbDocuments})
+	assert.Equal(t, 2, len(resp.Documents))
+	for _, doc := range resp.Documents {
+		assert.Equal(t, doc.DocumentID == "command-2", doc.Pending)
+	}
+
+	resp = server.process(Request{Verb: VerbSessions})
+	assert.Equal(t, 1, len(resp.Documents))
+	assert.Equal(t, "session-1", resp.Documents[0].DocumentID)
+}
+
+func TestProcessActions(t *testing.T) {
+	server := &Server{log: logMock}
+
+	resp := server.process(Request{Verb: VerbRefreshAssociation})
+	assert.Contains(t, resp.Error, "unknown verb")
+
+	called := false
+	RegisterAction(VerbRefreshAssociation, func(log log.T) error {
+		called = true
+		return nil
+	})
+	defer RegisterAction(VerbRefreshAssociation, nil)
+	resp = server.process(Request{Verb: VerbRefreshAssociation})
+	assert.True(t, called)
+	assert.Empty(t, resp.Error)
+
+	RegisterAction(VerbFlushLogs, func(log log.T) error { return errors.New("flush failed") })
+	defer RegisterAction(VerbFlushLogs, nil)
+	resp = server.process(Request{Verb: VerbFlushLogs})
+	assert.Equal(t, "flush failed", resp.Error)
+}
+
+func TestProcessRequestActions(t *testing.T) {
+	server := &Server{log: logMock}
+
+	resp := server.process(Request{Verb: VerbPutComplianceItems})
+	assert.Contains(t, resp.Error, "unknown verb")
+
+	RegisterRequestAction(VerbPutComplianceItems, func(log log.T, req Request) Response {
+		summary := model.SummarizeCustomCompliance(*req.Compliance)
+		return Response{ComplianceSummary: &summary}
+	})
+	defer RegisterRequestAction(VerbPutComplianceItems, nil)
+	resp = server.process(Request{Verb: VerbPutComplianceItems, Compliance: &model.CustomComplianceReport{
+		ComplianceType: "Custom:CIS",
+		Items:          []model.CustomComplianceItem{{Id: "1.1.1", Severity: "HIGH", Status: model.NON_COMPLIANT}},
+	}})
+	assert.Empty(t, resp.Error)
+	assert.Equal(t, 1, resp.ComplianceSummary.NonCompliantSummary.HighCount)
+}
+
+func TestHandleStreamAction(t *testing.T) {
+	RegisterStreamAction("echo", func(log log.T, req Request) (Response, func(conn net.Conn)) {
+		if req.SessionID == "" {
+			return Response{Error: "session id is missing"}, nil
+		}
+		return Response{Message: req.SessionID}, func(conn net.Conn) {
+			io.Copy(conn, conn)
+		}
+	})
+	defer RegisterStreamAction("echo", nil)
+	server := &Server{log: logMock, authorize: func(conn net.Conn) (Peer, error) { return Peer{Privileged: true}, nil }}
+
+	client, serverConn := net.Pipe()
+	go server.handle(serverConn)
+	assert.NoError(t, json.NewEncoder(client).Encode(Request{Verb: "echo", SessionID: "session-1"}))
+	decoder := json.NewDecoder(client)
+	var resp Response
+	assert.NoError(t, decoder.Decode(&resp))
+	assert.Equal(t, "session-1", resp.Message)
+
+	// the pipe is synchronous, the echo of the stream is read while the frame is written
+	go json.NewEncoder(client).Encode(SessionFrame{PayloadType: 1, Payload: []byte("hello")})
+	var frame SessionFrame
+	assert.NoError(t, decoder.Decode(&frame))
+	assert.Equal(t, "hello", string(frame.Payload))
+	client.Close()
+
+	client, serverConn = net.Pipe()
+	go server.handle(serverConn)
+	assert.NoError(t, json.NewEncoder(client).Encode(Request{Verb: "echo"}))
+	resp = Response{}
+	assert.NoError(t, json.NewDecoder(client).Decode(&resp))
+	assert.Equal(t, "session id is missing", resp.Error)
+	_, err := client.Read(make([]byte, 1))
+	assert.Equal(t, io.EOF, err, "a refused stream closes the connection")
+}
@@ -17,13 +17,15 @@
 package log
 
 import (
+	"html"
 	"path/filepath"
 
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
 	"github.com/cihub/seelog"
 )
 
 func DefaultConfig() []byte {
-	return LoadLog(DefaultLogDir, LogFile, seelog.InfoStr)
+	return LoadLog(LogDir(), LogFile, seelog.InfoStr)
 }
 
 func LoadLog(defaultLogDir string, logFile string, debugStatus string) []byte {
@@ -32,6 +34,8 @@ func LoadLog(defaultLogDir string, logFile string, debugStatus string) []byte {
 	logFilePath = filepath.Join(defaultLogDir, logFile)
 	errorFilePath = filepath.Join(defaultLogDir, ErrorFile)
 
+	logCfg := getLogConfig()
+
 	logConfig := `
 <seelog type="adaptive" mininterval="2000000" maxinterval="100000000" critmsgcount="500" minlevel="` + debugStatus + `">
     <exceptions>
@@ -41,18 +45,30 @@ func LoadLog(defaultLogDir string, logFile string, debugStatus string) []byte {
         <console formatid="fmtinfo"/>
 
         `
-	logConfig += `<rollingfile type="size" filename="` + logFilePath + `" maxsize="30000000" maxrolls="5"/>`
-	logConfig += `
+	if hasSink(logCfg.Sinks, appconfig.LogSinkFile) {
+		logConfig += `<rollingfile type="size" filename="` + logFilePath + `" maxsize="30000000" maxrolls="5"/>`
+		logConfig += `
 		<filter levels="error,critical" formatid="fmterror">
 		`
-	logConfig += `<rollingfile type="size" filename="` + errorFilePath + `" maxsize="10000000" maxrolls="5"/>`
-	logConfig += `
+		logConfig += `<rollingfile type="size" filename="` + errorFilePath + `" maxsize="10000000" maxrolls="5"/>`
+		logConfig += `
         </filter>
+        `
+	}
+	for _, sink := range logCfg.Sinks {
+		// sinks not available on this platform are skipped so the logger still initializes
+		if receiverName, ok := sinkReceivers[sink]; ok {
+			logConfig += `<custom name="` + receiverName + `" formatid="fmtsink" data-identifier="` + html.EscapeString(logCfg.SyslogIdentifier) + `"/>
+        `
+		}
+	}
+	logConfig += `
     </outputs>
     <formats>
         <format id="fmterror" format="%Date %Time %LEVEL [%FuncShort @ %File.%Line] %Msg%n"/>
         <format id="fmtdebug" format="%Date %Time %LEVEL [%FuncShort @ %File.%Line] %Msg%n"/>
         <format id="fmtinfo" format="%Date %Time %LEVEL %Msg%n"/>
+        <format id="fmtsink" format="%Msg"/>
     </formats>
 </seelog>
 `
@@ -0,0 +1,84 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package log
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/cihub/seelog"
+	"github.com/stretchr/testify/assert"
+)
+
+func stubLogConfig(logCfg appconfig.LogCfg) func() {
+	original := getLogConfig
+	getLogConfig = func() appconfig.LogCfg {
+		return logCfg
+	}
+	return func() { getLogConfig = original }
+}
+
+func TestLoadLog_FileSinkOnly(t *testing.T) {
+	defer stubLogConfig(appconfig.LogCfg{Sinks: []string{appconfig.LogSinkFile}})()
+
+	config := string(LoadLog("logs", LogFile, seelog.InfoStr))
+
+	assert.Contains(t, config, "rollingfile")
+	assert.NotContains(t, config, "<custom")
+	_, err := seelog.LoggerFromConfigAsBytes([]byte(config))
+	assert.NoError(t, err)
+}
+
+func TestLoadLog_SinkWithoutFile(t *testing.T) {
+	defer stubLogConfig(appconfig.LogCfg{Sinks: []string{appconfig.LogSinkSyslog}, SyslogIdentifier: "ssm"})()
+
+	config := string(LoadLog("logs", LogFile, seelog.InfoStr))
+
+	assert.NotContains(t, config, "rollingfile")
+	if receiverName, ok := sinkReceivers[appconfig.LogSinkSyslog]; ok {
+		assert.Contains(t, config, `<custom name="`+receiverName+`" formatid="fmtsink" data-identifier="ssm"/>`)
+	}
+	_, err := seelog.LoggerFromConfigAsBytes([]byte(config))
+	assert.NoError(t, err)
+}
+
+func TestDefaultConfig_UnprivilegedLogsToWorkerFolder(t *testing.T) {
+	defer stubLogConfig(appconfig.LogCfg{Sinks: []string{appconfig.LogSinkFile}})()
+	defer os.Unsetenv(UnprivilegedEnvVar)
+
+	assert.Equal(t, DefaultLogDir, LogDir())
+	assert.Contains(t, string(DefaultConfig()), `filename="`+filepath.Join(DefaultLogDir, LogFile)+`"`)
+
+	os.Setenv(UnprivilegedEnvVar, "true")
+	workerLogDir := filepath.Join(DefaultLogDir, WorkerLogDirName)
+	assert.Equal(t, workerLogDir, LogDir())
+	assert.Contains(t, string(DefaultConfig()), `filename="`+filepath.Join(workerLogDir, LogFile)+`"`)
+	assert.Contains(t, string(DefaultConfig()), `filename="`+filepath.Join(workerLogDir, ErrorFile)+`"`)
+}
+
+func TestLoadLog_EscapesSyslogIdentifier(t *testing.T) {
+	receiverName, ok := sinkReceivers[appconfig.LogSinkSyslog]
+	if !ok {
+		t.Skip("syslog sink is not available on this platform")
+	}
+	defer stubLogConfig(appconfig.LogCfg{Sinks: []string{appconfig.LogSinkSyslog}, SyslogIdentifier: `ssm"/><x a="&`})()
+
+	config := string(LoadLog("logs", LogFile, seelog.InfoStr))
+
+	assert.Contains(t, config, `<custom name="`+receiverName+`" formatid="fmtsink" data-identifier="ssm&#34;/&gt;&lt;x a=&#34;&amp;"/>`)
+	_, err := seelog.LoggerFromConfigAsBytes([]byte(config))
+	assert.NoError(t, err)
+}
@@ -24,6 +24,9 @@ const (
 	// Message types for the event log chunks created
 	AgentTelemetryMessage    = "agent_telemetry"     // AgentTelemetryMessage represents message type for number Legacy Agent/Agent Reboot
 	AgentUpdateResultMessage = "agent_update_result" // AgentUpdateResultMessage represents message type for number Agent update result
+	MessageRejectedMessage   = "message_rejected"    // MessageRejectedMessage represents events of run command messages rejected by strict validation, they are not sent to MGS
+	StepScriptMessage        = "step_script"         // StepScriptMessage represents events of the hash of the scripts run by steps, they are not sent to MGS
+	ClockSkewMessage         = "clock_skew"          // ClockSkewMessage represents events of the local clock drifting from the service clock, they are not sent to MGS
 
 	BytePatternLen = 9 // BytePatternLen represents length of last read byte section in footer of audit file. Considered the audit file max file size to be 999.99MB
 
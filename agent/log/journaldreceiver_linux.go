@@ -0,0 +1,137 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// +build linux
+
+package log
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/cihub/seelog"
+)
+
+// journaldSocketPath is the native protocol socket of systemd-journald
+var journaldSocketPath = "/run/systemd/journal/socket"
+
+func init() {
+	registerSinkReceiver(appconfig.LogSinkJournald, JournaldReceiverName, &JournaldReceiver{})
+}
+
+// JournaldReceiver implements seelog.CustomReceiver and sends log messages to systemd-journald
+// using the native journal protocol, so the caller location is kept as structured fields.
+type JournaldReceiver struct {
+	identifier string
+	conn       *net.UnixConn
+	mutex      sync.Mutex
+}
+
+// journalField is a single KEY=value pair of a journal entry
+type journalField struct {
+	key   string
+	value string
+}
+
+// ReceiveMessage sends the message with its level and caller context to journald
+func (receiver *JournaldReceiver) ReceiveMessage(message string, level seelog.LogLevel, context seelog.LogContextInterface) (err error) {
+	fields := []journalField{
+		{"MESSAGE", message},
+		{"PRIORITY", strconv.Itoa(journaldPriority(level))},
+		{"SYSLOG_IDENTIFIER", receiver.identifier},
+	}
+	if context != nil && context.IsValid() {
+		fields = append(fields,
+			journalField{"CODE_FILE", context.FullPath()},
+			journalField{"CODE_LINE", strconv.Itoa(context.Line())},
+			journalField{"CODE_FUNC", context.Func()})
+	}
+
+	receiver.mutex.Lock()
+	defer receiver.mutex.Unlock()
+
+	// journald may be restarted while the agent is running, so connect lazily
+	if receiver.conn == nil {
+		socketAddr := &net.UnixAddr{Name: journaldSocketPath, Net: "unixgram"}
+		if receiver.conn, err = net.DialUnix("unixgram", nil, socketAddr); err != nil {
+			return err
+		}
+	}
+	if _, err = receiver.conn.Write(encodeJournalFields(fields)); err != nil {
+		receiver.conn.Close()
+		receiver.conn = nil
+	}
+	return err
+}
+
+// AfterParse reads the syslog identifier from the xml args
+func (receiver *JournaldReceiver) AfterParse(initArgs seelog.CustomReceiverInitArgs) error {
+	receiver.identifier = initArgs.XmlCustomAttrs["identifier"]
+	if receiver.identifier == "" {
+		receiver.identifier = appconfig.DefaultAgentName
+	}
+	return nil
+}
+
+// Flush is a no-op since every message is sent as its own datagram
+func (receiver *JournaldReceiver) Flush() {
+}
+
+// Close closes the journald socket
+func (receiver *JournaldReceiver) Close() error {
+	receiver.mutex.Lock()
+	defer receiver.mutex.Unlock()
+	if receiver.conn == nil {
+		return nil
+	}
+	err := receiver.conn.Close()
+	receiver.conn = nil
+	return err
+}
+
+// journaldPriority maps seelog levels to syslog priorities used by journald
+func journaldPriority(level seelog.LogLevel) int {
+	switch level {
+	case seelog.TraceLvl, seelog.DebugLvl:
+		return 7
+	case seelog.WarnLvl:
+		return 4
+	case seelog.ErrorLvl:
+		return 3
+	case seelog.CriticalLvl:
+		return 2
+	default:
+		return 6
+	}
+}
+
+// encodeJournalFields serializes the fields in the journald native protocol format.
+// Values containing new lines are written as binary safe length prefixed values.
+func encodeJournalFields(fields []journalField) []byte {
+	var buffer bytes.Buffer
+	for _, field := range fields {
+		if !strings.Contains(field.value, "\n") {
+			buffer.WriteString(field.key + "=" + field.value + "\n")
+			continue
+		}
+		buffer.WriteString(field.key + "\n")
+		binary.Write(&buffer, binary.LittleEndian, uint64(len(field.value)))
+		buffer.WriteString(field.value + "\n")
+	}
+	return buffer.Bytes()
+}
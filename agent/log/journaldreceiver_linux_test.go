@@ -0,0 +1,73 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// +build linux
+
+package log
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cihub/seelog"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEncodeJournalFields(t *testing.T) {
+	encoded := encodeJournalFields([]journalField{
+		{"MESSAGE", "hello"},
+		{"PRIORITY", "6"},
+	})
+	assert.Equal(t, "MESSAGE=hello\nPRIORITY=6\n", string(encoded))
+}
+
+func TestEncodeJournalFields_MultiLine(t *testing.T) {
+	encoded := encodeJournalFields([]journalField{{"MESSAGE", "a\nb"}})
+	expected := append([]byte("MESSAGE\n"), 3, 0, 0, 0, 0, 0, 0, 0)
+	expected = append(expected, []byte("a\nb\n")...)
+	assert.Equal(t, expected, encoded)
+}
+
+func TestJournaldPriority(t *testing.T) {
+	assert.Equal(t, 7, journaldPriority(seelog.DebugLvl))
+	assert.Equal(t, 6, journaldPriority(seelog.InfoLvl))
+	assert.Equal(t, 4, journaldPriority(seelog.WarnLvl))
+	assert.Equal(t, 3, journaldPriority(seelog.ErrorLvl))
+	assert.Equal(t, 2, journaldPriority(seelog.CriticalLvl))
+}
+
+func TestJournaldReceiver_ReceiveMessage(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "journald")
+	defer os.RemoveAll(dir)
+
+	originalPath := journaldSocketPath
+	journaldSocketPath = filepath.Join(dir, "socket")
+	defer func() { journaldSocketPath = originalPath }()
+
+	server, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: journaldSocketPath, Net: "unixgram"})
+	assert.NoError(t, err)
+	defer server.Close()
+
+	receiver := &JournaldReceiver{}
+	receiver.AfterParse(seelog.CustomReceiverInitArgs{XmlCustomAttrs: map[string]string{"identifier": "ssm-test"}})
+	assert.NoError(t, receiver.ReceiveMessage("hello", seelog.ErrorLvl, nil))
+	defer receiver.Close()
+
+	buffer := make([]byte, 1024)
+	n, err := server.Read(buffer)
+	assert.NoError(t, err)
+	assert.Equal(t, "MESSAGE=hello\nPRIORITY=3\nSYSLOG_IDENTIFIER=ssm-test\n", string(buffer[:n]))
+}
@@ -16,6 +16,8 @@ package log
 
 import (
 	"fmt"
+	"os"
+	"path/filepath"
 	"sync"
 
 	"github.com/cihub/seelog"
@@ -25,6 +27,13 @@ const (
 	LogFile      = "amazon-ssm-agent.log"
 	ErrorFile    = "errors.log"
 	EventLogFile = "amazon-ssm-agent-audit"
+
+	// UnprivilegedEnvVar is set in the environment of the agent processes started without root privileges
+	UnprivilegedEnvVar = "AWS_SSM_AGENT_UNPRIVILEGED"
+
+	// WorkerLogDirName is the folder of the log directory the agent processes started without root privileges log to,
+	// the log directory itself stays owned by root
+	WorkerLogDirName = "worker"
 )
 
 var loadedLogger T
@@ -75,6 +84,19 @@ func (f ContextFormatFilter) Filterf(format string, params ...interface{}) (newF
 	return
 }
 
+// LogDir returns the log directory of this process
+func LogDir() string {
+	if isUnprivileged() {
+		return filepath.Join(DefaultLogDir, WorkerLogDirName)
+	}
+	return DefaultLogDir
+}
+
+// isUnprivileged returns true if this process was started without root privileges
+func isUnprivileged() bool {
+	return os.Getenv(UnprivilegedEnvVar) == "true"
+}
+
 func GetLogConfigBytes() []byte {
 	return getLogConfigBytes()
 }
@@ -29,6 +29,11 @@ const (
 	DefaultLogDir = "/var/log/amazon/ssm"
 )
 
+// SeelogConfigFilePath returns the seelog location of this process
+func SeelogConfigFilePath() string {
+	return DefaultSeelogConfigFilePath
+}
+
 // getLogConfigBytes reads and returns the seelog configs from the config file path if present
 // otherwise returns the seelog default configurations
 // Linux uses seelog.xml file as configuration by default.
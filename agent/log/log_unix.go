@@ -27,14 +27,26 @@ const (
 	DefaultSeelogConfigFilePath = "/etc/amazon/ssm/seelog.xml"
 
 	DefaultLogDir = "/var/log/amazon/ssm"
+
+	// WorkerSeelogConfigFilePath specifies the seelog location of the agent processes started without root privileges,
+	// which cannot write the logs of the default configuration
+	WorkerSeelogConfigFilePath = "/etc/amazon/ssm/seelog-worker.xml"
 )
 
+// SeelogConfigFilePath returns the seelog location of this process
+func SeelogConfigFilePath() string {
+	if isUnprivileged() {
+		return WorkerSeelogConfigFilePath
+	}
+	return DefaultSeelogConfigFilePath
+}
+
 // getLogConfigBytes reads and returns the seelog configs from the config file path if present
 // otherwise returns the seelog default configurations
 // Linux uses seelog.xml file as configuration by default.
 func getLogConfigBytes() (logConfigBytes []byte) {
 	var err error
-	if logConfigBytes, err = ioutil.ReadFile(DefaultSeelogConfigFilePath); err != nil {
+	if logConfigBytes, err = ioutil.ReadFile(SeelogConfigFilePath()); err != nil {
 		fmt.Println("Error occurred fetching the seelog config file path: ", err)
 		logConfigBytes = DefaultConfig()
 	}
@@ -29,6 +29,11 @@ var DefaultLogDir = filepath.Join(appconfig.SSMDataPath, "Logs")
 // See Seelog documentation to customize the logger
 var DefaultSeelogConfigFilePath = filepath.Join(appconfig.DefaultProgramFolder, appconfig.SeelogConfigFileName)
 
+// SeelogConfigFilePath returns the seelog location of this process
+func SeelogConfigFilePath() string {
+	return DefaultSeelogConfigFilePath
+}
+
 // getLogConfigBytes reads and returns the seelog configs from the config file path if present
 // otherwise returns the seelog default configurations
 // Windows uses default log configuration if there is no seelog.xml override provided.
@@ -0,0 +1,188 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package log is used to initialize the logger. This package should be imported once, usually from main, then call GetLogger.
+package log
+
+import (
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/schedule"
+)
+
+const (
+	// rotatedLogCheckInterval is how often the log directory is checked for new rolls
+	rotatedLogCheckInterval = 5 * time.Minute
+
+	// compressedLogTimeFormat is used to name compressed rolls so they never collide with seelog roll numbers
+	compressedLogTimeFormat = "20060102T150405.000000000"
+
+	compressedLogExtension = ".gz"
+)
+
+var (
+	// seelog size rolls are named <file>.log.<n>
+	rolledLogPattern = regexp.MustCompile(`\.log\.\d+$`)
+
+	// compressed rolls are named <file>.log.<timestamp>.gz
+	compressedLogPattern = regexp.MustCompile(`\.log\.[0-9T.]+\.gz$`)
+)
+
+// rotatedLogManager compresses rotated log files and keeps the log directory under its size budget
+type rotatedLogManager struct {
+	log             T
+	logDir          string
+	compress        bool
+	maxDirSizeBytes int64
+}
+
+// StartRotatedLogManager starts a background routine that compresses rotated logs
+// and evicts the oldest of them whenever the log directory exceeds its size budget.
+// It does nothing when neither compression nor a size budget is configured.
+func StartRotatedLogManager(log T, logDir string) {
+	logCfg := getLogConfig()
+	if !logCfg.CompressRotatedLogs && logCfg.MaxDirectorySizeMB <= 0 {
+		return
+	}
+	manager := &rotatedLogManager{
+		log:             log,
+		logDir:          logDir,
+		compress:        logCfg.CompressRotatedLogs,
+		maxDirSizeBytes: int64(logCfg.MaxDirectorySizeMB) * 1024 * 1024,
+	}
+	job := schedule.Every(rotatedLogCheckInterval, func() {
+		defer func() {
+			if msg := recover(); msg != nil {
+				log.Errorf("Rotated log manager panicked: %v", msg)
+			}
+		}()
+		manager.enforce()
+	})
+	job.RunNow()
+}
+
+// enforce compresses pending rolls and then evicts rotated files, oldest first, until the directory fits the budget
+func (m *rotatedLogManager) enforce() {
+	if m.compress {
+		m.compressRolledLogs()
+	}
+	if m.maxDirSizeBytes > 0 {
+		m.evictRotatedLogs()
+	}
+}
+
+// compressRolledLogs gzips every uncompressed roll in the log directory
+func (m *rotatedLogManager) compressRolledLogs() {
+	files, err := ioutil.ReadDir(m.logDir)
+	if err != nil {
+		m.log.Debugf("Failed to read log directory %v: %v", m.logDir, err)
+		return
+	}
+	for _, file := range files {
+		if file.IsDir() || !rolledLogPattern.MatchString(file.Name()) {
+			continue
+		}
+		if err := compressRolledLog(m.logDir, file); err != nil {
+			m.log.Warnf("Failed to compress rotated log %v: %v", file.Name(), err)
+		}
+	}
+}
+
+// compressRolledLog writes <name>.<timestamp>.gz next to the roll and removes the roll once it is complete
+func compressRolledLog(logDir string, file os.FileInfo) (err error) {
+	rollPath := filepath.Join(logDir, file.Name())
+	baseName := rolledLogPattern.ReplaceAllString(file.Name(), ".log")
+	compressedPath := filepath.Join(logDir, baseName+"."+file.ModTime().UTC().Format(compressedLogTimeFormat)+compressedLogExtension)
+	tempPath := compressedPath + ".tmp"
+
+	source, err := os.Open(rollPath)
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+
+	target, err := os.OpenFile(tempPath, appconfig.FileFlagsCreateOrTruncate, appconfig.ReadWriteAccess)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tempPath)
+
+	writer := gzip.NewWriter(target)
+	writer.Name = file.Name()
+	writer.ModTime = file.ModTime()
+	if _, err = io.Copy(writer, source); err == nil {
+		err = writer.Close()
+	}
+	if closeErr := target.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	// keep the roll time so eviction order is unchanged by compression
+	os.Chtimes(tempPath, file.ModTime(), file.ModTime())
+	if err = os.Rename(tempPath, compressedPath); err != nil {
+		return err
+	}
+	return os.Remove(rollPath)
+}
+
+// evictRotatedLogs deletes rotated logs, oldest first, while the log directory is larger than the budget.
+// Active log files are never deleted.
+func (m *rotatedLogManager) evictRotatedLogs() {
+	type rotatedLog struct {
+		path string
+		info os.FileInfo
+	}
+	var totalSize int64
+	var rotated []rotatedLog
+	filepath.Walk(m.logDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return nil
+		}
+		totalSize += info.Size()
+		if filepath.Dir(path) == filepath.Clean(m.logDir) && isRotatedLog(info.Name()) {
+			rotated = append(rotated, rotatedLog{path: path, info: info})
+		}
+		return nil
+	})
+
+	sort.Slice(rotated, func(i, j int) bool {
+		return rotated[i].info.ModTime().Before(rotated[j].info.ModTime())
+	})
+	for _, file := range rotated {
+		if totalSize <= m.maxDirSizeBytes {
+			return
+		}
+		if err := os.Remove(file.path); err != nil {
+			m.log.Warnf("Failed to evict rotated log %v: %v", file.info.Name(), err)
+			continue
+		}
+		m.log.Debugf("Evicted rotated log %v to keep log directory under %v bytes", file.info.Name(), m.maxDirSizeBytes)
+		totalSize -= file.info.Size()
+	}
+}
+
+// isRotatedLog returns true for seelog rolls and their compressed copies
+func isRotatedLog(fileName string) bool {
+	return rolledLogPattern.MatchString(fileName) || compressedLogPattern.MatchString(fileName)
+}
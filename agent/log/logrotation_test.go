@@ -0,0 +1,91 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package log
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeLogFile(t *testing.T, dir string, name string, size int, modTime time.Time) {
+	path := filepath.Join(dir, name)
+	assert.NoError(t, ioutil.WriteFile(path, bytes.Repeat([]byte("a"), size), 0600))
+	assert.NoError(t, os.Chtimes(path, modTime, modTime))
+}
+
+func listLogFiles(dir string) []string {
+	var names []string
+	files, _ := ioutil.ReadDir(dir)
+	for _, file := range files {
+		names = append(names, file.Name())
+	}
+	return names
+}
+
+func TestRotatedLogManager_CompressRolledLogs(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "logrotation")
+	defer os.RemoveAll(dir)
+	rollTime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	writeLogFile(t, dir, LogFile, 10, rollTime)
+	writeLogFile(t, dir, LogFile+".1", 100, rollTime)
+
+	manager := &rotatedLogManager{log: NewMockLog(), logDir: dir, compress: true}
+	manager.enforce()
+
+	compressedName := LogFile + ".20200102T030405.000000000.gz"
+	assert.Equal(t, []string{LogFile, compressedName}, listLogFiles(dir))
+
+	compressed, err := os.Open(filepath.Join(dir, compressedName))
+	assert.NoError(t, err)
+	defer compressed.Close()
+	reader, err := gzip.NewReader(compressed)
+	assert.NoError(t, err)
+	content, err := ioutil.ReadAll(reader)
+	assert.NoError(t, err)
+	assert.Equal(t, 100, len(content))
+}
+
+func TestRotatedLogManager_EvictOldestFirst(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "logrotation")
+	defer os.RemoveAll(dir)
+	now := time.Now()
+	writeLogFile(t, dir, LogFile, 400, now)
+	writeLogFile(t, dir, LogFile+".1", 300, now.Add(-3*time.Hour))
+	writeLogFile(t, dir, LogFile+".20200102T030405.000000000.gz", 300, now.Add(-4*time.Hour))
+	writeLogFile(t, dir, ErrorFile+".2", 300, now.Add(-2*time.Hour))
+
+	manager := &rotatedLogManager{log: NewMockLog(), logDir: dir, maxDirSizeBytes: 900}
+	manager.enforce()
+
+	assert.Equal(t, []string{LogFile, ErrorFile + ".2"}, listLogFiles(dir))
+}
+
+func TestRotatedLogManager_ActiveLogsAreKept(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "logrotation")
+	defer os.RemoveAll(dir)
+	writeLogFile(t, dir, LogFile, 2000, time.Now().Add(-time.Hour))
+	writeLogFile(t, dir, ErrorFile, 2000, time.Now().Add(-time.Hour))
+
+	manager := &rotatedLogManager{log: NewMockLog(), logDir: dir, maxDirSizeBytes: 1000}
+	manager.enforce()
+
+	assert.Equal(t, []string{LogFile, ErrorFile}, listLogFiles(dir))
+}
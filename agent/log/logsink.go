@@ -0,0 +1,56 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package log is used to initialize the logger. This package should be imported once, usually from main, then call GetLogger.
+package log
+
+import (
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/cihub/seelog"
+)
+
+const (
+	// SyslogReceiverName is the seelog custom receiver name of the syslog sink
+	SyslogReceiverName = "syslog_receiver"
+
+	// JournaldReceiverName is the seelog custom receiver name of the journald sink
+	JournaldReceiverName = "journald_receiver"
+)
+
+// sinkReceivers maps the log sinks supported on this platform to their seelog custom receiver names
+var sinkReceivers = map[string]string{}
+
+// getLogConfig returns the logger configuration from the agent app config
+var getLogConfig = func() appconfig.LogCfg {
+	config, err := appconfig.Config(false)
+	if err != nil {
+		return appconfig.DefaultConfig().Log
+	}
+	return config.Log
+}
+
+// registerSinkReceiver registers a seelog custom receiver for the given log sink
+func registerSinkReceiver(sink string, receiverName string, receiver seelog.CustomReceiver) {
+	seelog.RegisterReceiver(receiverName, receiver)
+	sinkReceivers[sink] = receiverName
+}
+
+// hasSink returns true if the sink is part of the configured sinks
+func hasSink(sinks []string, sink string) bool {
+	for _, configured := range sinks {
+		if configured == sink {
+			return true
+		}
+	}
+	return false
+}
@@ -16,9 +16,10 @@ package ssmlog
 
 import (
 	"fmt"
-
+	"reflect"
 	"sync"
 
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
 	"github.com/aws/amazon-ssm-agent/agent/log"
 	"github.com/cihub/seelog"
 )
@@ -63,7 +64,7 @@ func withContext(logger seelog.LoggerInterface, context ...string) (contextLogge
 	contextLogger = &log.Wrapper{Format: formatFilter,
 		M:           pkgMutex,
 		Delegate:    loggerInstance,
-		EventLogger: log.GetEventLog(log.DefaultLogDir, log.EventLogFile),
+		EventLogger: log.GetEventLog(log.LogDir(), log.EventLogFile),
 	}
 	setStackDepth(logger)
 	return contextLogger
@@ -125,9 +126,16 @@ func startWatcher(logger log.T) {
 		}
 	}()
 	fileWatcher := &FileWatcher{}
-	fileWatcher.Init(logger, log.DefaultSeelogConfigFilePath, replaceLogger)
+	fileWatcher.Init(logger, log.SeelogConfigFilePath(), replaceLogger)
 	// Start the file watcher
 	fileWatcher.Start()
+
+	// log sinks come from the app config, so the logger is also replaced when they are reloaded
+	appconfig.RegisterConfigChangeHandler("ssmlog", func(oldConfig, newConfig appconfig.SsmagentConfig) {
+		if !reflect.DeepEqual(oldConfig.Log, newConfig.Log) {
+			replaceLogger()
+		}
+	})
 }
 
 // ReplaceLogger replaces the current logger with a new logger initialized from the current configurations file
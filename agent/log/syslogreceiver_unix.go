@@ -0,0 +1,86 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// +build darwin freebsd linux netbsd openbsd
+
+package log
+
+import (
+	"log/syslog"
+	"sync"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/cihub/seelog"
+)
+
+func init() {
+	registerSinkReceiver(appconfig.LogSinkSyslog, SyslogReceiverName, &SyslogReceiver{})
+}
+
+// SyslogReceiver implements seelog.CustomReceiver and forwards log messages to the local syslog daemon
+type SyslogReceiver struct {
+	identifier string
+	writer     *syslog.Writer
+	mutex      sync.Mutex
+}
+
+// ReceiveMessage writes the message to syslog with the severity matching the seelog level
+func (receiver *SyslogReceiver) ReceiveMessage(message string, level seelog.LogLevel, context seelog.LogContextInterface) (err error) {
+	receiver.mutex.Lock()
+	defer receiver.mutex.Unlock()
+
+	// The syslog daemon may not be up yet when the agent starts, so connect lazily
+	if receiver.writer == nil {
+		if receiver.writer, err = syslog.New(syslog.LOG_DAEMON|syslog.LOG_INFO, receiver.identifier); err != nil {
+			return err
+		}
+	}
+
+	switch level {
+	case seelog.TraceLvl, seelog.DebugLvl:
+		return receiver.writer.Debug(message)
+	case seelog.WarnLvl:
+		return receiver.writer.Warning(message)
+	case seelog.ErrorLvl:
+		return receiver.writer.Err(message)
+	case seelog.CriticalLvl:
+		return receiver.writer.Crit(message)
+	default:
+		return receiver.writer.Info(message)
+	}
+}
+
+// AfterParse reads the syslog identifier from the xml args
+func (receiver *SyslogReceiver) AfterParse(initArgs seelog.CustomReceiverInitArgs) error {
+	receiver.identifier = initArgs.XmlCustomAttrs["identifier"]
+	if receiver.identifier == "" {
+		receiver.identifier = appconfig.DefaultAgentName
+	}
+	return nil
+}
+
+// Flush is a no-op since syslog writes are not buffered
+func (receiver *SyslogReceiver) Flush() {
+}
+
+// Close closes the connection to the syslog daemon
+func (receiver *SyslogReceiver) Close() error {
+	receiver.mutex.Lock()
+	defer receiver.mutex.Unlock()
+	if receiver.writer == nil {
+		return nil
+	}
+	err := receiver.writer.Close()
+	receiver.writer = nil
+	return err
+}
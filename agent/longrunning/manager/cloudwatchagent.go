@@ -0,0 +1,116 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package manager encapsulates everything related to long running plugin manager that starts, stops & configures long running plugins
+package manager
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/iohandler"
+	"github.com/aws/amazon-ssm-agent/agent/jsonutil"
+	logger "github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/longrunning/plugin/cloudwatchagent"
+	"github.com/aws/amazon-ssm-agent/agent/task"
+)
+
+var installCloudWatchAgent = cloudwatchagent.Install
+
+// cloudWatchAgent is implemented by the long running plugin of the unified CloudWatch agent
+type cloudWatchAgent interface {
+	Restart(log logger.T) error
+	Status(log logger.T) cloudwatchagent.AgentStatus
+}
+
+// ConfigureCloudWatchAgent applies the action of an aws:configureCloudWatchAgent step, handed off by the worker, to the
+// unified CloudWatch agent of lrpm
+func ConfigureCloudWatchAgent(log logger.T, res *contracts.PluginResult, cancelFlag task.CancelFlag) {
+	var input cloudwatchagent.ConfigureCloudWatchAgentPluginInput
+	if !handedOffInput(log, "CloudWatch agent is", res, cancelFlag, &input) {
+		return
+	}
+	lrpm, err := GetInstance()
+	if err != nil {
+		CreateResult(fmt.Sprintf("Unable to configure CloudWatch agent: %v", err), contracts.ResultStatusFailed, res)
+		return
+	}
+
+	var msg string
+	switch input.Action {
+	case cloudwatchagent.ActionInstall:
+		msg, err = installCloudWatchAgent(log)
+	case cloudwatchagent.ActionConfigure:
+		msg, err = lrpm.configureCloudWatchAgent(log, input.ParameterName, cancelFlag)
+	case cloudwatchagent.ActionRestart:
+		msg, err = lrpm.restartCloudWatchAgent(log, input.ParameterName, cancelFlag)
+	case cloudwatchagent.ActionStop:
+		if err = lrpm.StopPlugin(appconfig.PluginNameCloudWatchAgent, cancelFlag); err == nil {
+			msg = "CloudWatch agent stopped"
+		}
+	case cloudwatchagent.ActionStatus:
+		msg, err = lrpm.cloudWatchAgentStatus(log)
+	default:
+		err = fmt.Errorf("Unsupported action %v", input.Action)
+	}
+	if err != nil {
+		log.Errorf("Unable to configure CloudWatch agent: %v", err)
+		CreateResult(err.Error(), contracts.ResultStatusFailed, res)
+		return
+	}
+	CreateResult(msg, contracts.ResultStatusSuccess, res)
+}
+
+// configureCloudWatchAgent starts the agent with the configuration of the parameter, lrpm keeps it running and in sync
+// with the parameter, also after the ssm agent restarts
+func (m *Manager) configureCloudWatchAgent(log logger.T, parameterName string, cancelFlag task.CancelFlag) (msg string, err error) {
+	out := iohandler.NewDefaultIOHandler(log, contracts.IOConfiguration{})
+	if err = m.StartPlugin(appconfig.PluginNameCloudWatchAgent, parameterName, "", cancelFlag, out); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("CloudWatch agent runs with the configuration of %v", parameterName), nil
+}
+
+// restartCloudWatchAgent applies the configuration of the parameter again, the parameter the agent was configured
+// with is used when none is given
+func (m *Manager) restartCloudWatchAgent(log logger.T, parameterName string, cancelFlag task.CancelFlag) (msg string, err error) {
+	if parameterName != "" {
+		return m.configureCloudWatchAgent(log, parameterName, cancelFlag)
+	}
+	lock.RLock()
+	_, configured := m.runningPlugins[appconfig.PluginNameCloudWatchAgent]
+	p := m.registeredPlugins[appconfig.PluginNameCloudWatchAgent]
+	lock.RUnlock()
+	agent, isAgent := p.Handler.(cloudWatchAgent)
+	if !configured || !isAgent {
+		return "", errors.New("CloudWatch agent is not configured with a parameter")
+	}
+	if err = agent.Restart(log); err != nil {
+		return "", err
+	}
+	return "CloudWatch agent restarted", nil
+}
+
+// cloudWatchAgentStatus returns the state of the agent and whether its configuration drifted as json
+func (m *Manager) cloudWatchAgentStatus(log logger.T) (msg string, err error) {
+	lock.RLock()
+	p := m.registeredPlugins[appconfig.PluginNameCloudWatchAgent]
+	lock.RUnlock()
+	agent, isAgent := p.Handler.(cloudWatchAgent)
+	if !isAgent {
+		return "", errors.New("CloudWatch agent is not registered")
+	}
+	return jsonutil.Marshal(agent.Status(log))
+}
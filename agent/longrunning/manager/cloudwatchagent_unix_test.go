@@ -0,0 +1,132 @@
+// +build darwin freebsd linux netbsd openbsd
+
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package manager
+
+import (
+	"testing"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/context"
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/iohandler"
+	logger "github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/longrunning/plugin"
+	"github.com/aws/amazon-ssm-agent/agent/longrunning/plugin/cloudwatchagent"
+	"github.com/aws/amazon-ssm-agent/agent/task"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeCloudWatchAgent is the long running plugin of the CloudWatch agent without the agent
+type fakeCloudWatchAgent struct {
+	parameterName string
+	running       bool
+	restarts      int
+}
+
+func (a *fakeCloudWatchAgent) IsRunning(context context.T) bool {
+	return a.running
+}
+
+func (a *fakeCloudWatchAgent) Start(context context.T, configuration string, orchestrationDir string, cancelFlag task.CancelFlag, out iohandler.IOHandler) error {
+	a.parameterName = configuration
+	a.running = true
+	return nil
+}
+
+func (a *fakeCloudWatchAgent) Stop(context context.T, cancelFlag task.CancelFlag) error {
+	a.running = false
+	return nil
+}
+
+func (a *fakeCloudWatchAgent) Restart(log logger.T) error {
+	a.restarts++
+	a.running = true
+	return nil
+}
+
+func (a *fakeCloudWatchAgent) Status(log logger.T) cloudwatchagent.AgentStatus {
+	return cloudwatchagent.AgentStatus{State: "running", ParameterName: a.parameterName, Config: cloudwatchagent.ConfigInSync}
+}
+
+func configureCloudWatchAgent(action, parameterName string, cancelFlag task.CancelFlag) *contracts.PluginResult {
+	res := &contracts.PluginResult{
+		PluginName: appconfig.PluginNameAwsConfigureCloudWatchAgent,
+		Status:     contracts.ResultStatusSuccess,
+		Output:     cloudwatchagent.ConfigureCloudWatchAgentPluginInput{Action: action, ParameterName: parameterName},
+	}
+	ConfigureCloudWatchAgent(loggerMock, res, cancelFlag)
+	return res
+}
+
+func TestConfigureCloudWatchAgent(t *testing.T) {
+	_, store, cleanup := setupDaemonManager(t)
+	defer cleanup()
+	agent := &fakeCloudWatchAgent{}
+	singletonInstance.registeredPlugins[appconfig.PluginNameCloudWatchAgent] = plugin.Plugin{
+		Info:    plugin.PluginInfo{Name: appconfig.PluginNameCloudWatchAgent},
+		Handler: agent,
+	}
+
+	res := configureCloudWatchAgent(cloudwatchagent.ActionRestart, "", task.NewChanneledCancelFlag())
+	assert.Equal(t, contracts.ResultStatusFailed, res.Status, "an agent without parameter can't be restarted")
+
+	res = configureCloudWatchAgent(cloudwatchagent.ActionConfigure, "AmazonCloudWatch-linux", task.NewChanneledCancelFlag())
+	assert.Equal(t, contracts.ResultStatusSuccess, res.Status, res.StandardError)
+	assert.Equal(t, "AmazonCloudWatch-linux", agent.parameterName)
+	assert.Equal(t, "AmazonCloudWatch-linux", store.data[appconfig.PluginNameCloudWatchAgent].Configuration,
+		"the agent is configured again when the ssm agent restarts")
+
+	res = configureCloudWatchAgent(cloudwatchagent.ActionRestart, "", task.NewChanneledCancelFlag())
+	assert.Equal(t, contracts.ResultStatusSuccess, res.Status, res.StandardError)
+	assert.Equal(t, 1, agent.restarts)
+
+	res = configureCloudWatchAgent(cloudwatchagent.ActionStatus, "", task.NewChanneledCancelFlag())
+	assert.Equal(t, contracts.ResultStatusSuccess, res.Status)
+	assert.Contains(t, res.StandardOutput, cloudwatchagent.ConfigInSync)
+
+	res = configureCloudWatchAgent(cloudwatchagent.ActionStop, "", task.NewChanneledCancelFlag())
+	assert.Equal(t, contracts.ResultStatusSuccess, res.Status)
+	assert.False(t, agent.running)
+	assert.NotContains(t, store.data, appconfig.PluginNameCloudWatchAgent)
+}
+
+func TestConfigureCloudWatchAgentInstall(t *testing.T) {
+	_, _, cleanup := setupDaemonManager(t)
+	defer cleanup()
+	installed := false
+	installCloudWatchAgent = func(log logger.T) (string, error) {
+		installed = true
+		return "CloudWatch agent installed", nil
+	}
+	defer func() { installCloudWatchAgent = cloudwatchagent.Install }()
+
+	res := configureCloudWatchAgent(cloudwatchagent.ActionInstall, "", task.NewChanneledCancelFlag())
+	assert.Equal(t, contracts.ResultStatusSuccess, res.Status)
+	assert.True(t, installed)
+}
+
+func TestConfigureCloudWatchAgentCancelled(t *testing.T) {
+	_, _, cleanup := setupDaemonManager(t)
+	defer cleanup()
+	agent := &fakeCloudWatchAgent{}
+	singletonInstance.registeredPlugins[appconfig.PluginNameCloudWatchAgent] = plugin.Plugin{Handler: agent}
+	cancelFlag := task.NewChanneledCancelFlag()
+	cancelFlag.Set(task.Canceled)
+
+	res := configureCloudWatchAgent(cloudwatchagent.ActionConfigure, "AmazonCloudWatch-linux", cancelFlag)
+	assert.Equal(t, contracts.ResultStatusCancelled, res.Status)
+	assert.False(t, agent.running)
+}
@@ -34,9 +34,9 @@ import (
 	managerContracts "github.com/aws/amazon-ssm-agent/agent/longrunning/plugin"
 	"github.com/aws/amazon-ssm-agent/agent/longrunning/plugin/cloudwatch"
 	"github.com/aws/amazon-ssm-agent/agent/platform"
+	"github.com/aws/amazon-ssm-agent/agent/schedule"
 	"github.com/aws/amazon-ssm-agent/agent/task"
 	"github.com/aws/amazon-ssm-agent/agent/times"
-	"github.com/carlescere/scheduler"
 )
 
 const (
@@ -88,7 +88,7 @@ type Manager struct {
 	registeredPlugins map[string]managerContracts.Plugin
 
 	//manages lifecycle of all long running plugins
-	managingLifeCycleJob *scheduler.Job
+	managingLifeCycleJob *schedule.Job
 
 	//manages file system related functions
 	fileSysUtil longrunning.FileSysUtil
@@ -239,9 +239,8 @@ func (m *Manager) ModuleExecute(context context.T) (err error) {
 	}
 
 	//schedule periodic health check of all long running plugins
-	if m.managingLifeCycleJob, err = scheduler.Every(PollFrequencyMinutes).Minutes().Run(m.ensurePluginsAreRunning); err != nil {
-		context.Log().Errorf("unable to schedule long running plugins manager. %v", err)
-	}
+	m.managingLifeCycleJob = schedule.Every(PollFrequencyMinutes*time.Minute, m.ensurePluginsAreRunning)
+	m.managingLifeCycleJob.RunNow()
 
 	return
 }
@@ -0,0 +1,147 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package manager encapsulates everything related to long running plugin manager that starts, stops & configures long running plugins
+package manager
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/fileutil"
+	"github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/iohandler"
+	"github.com/aws/amazon-ssm-agent/agent/jsonutil"
+	logger "github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/longrunning/plugin"
+	"github.com/aws/amazon-ssm-agent/agent/longrunning/plugin/rundaemon"
+	"github.com/aws/amazon-ssm-agent/agent/task"
+)
+
+// daemonRoot is the directory of the registrations of ssm daemons, lrpm registers them again when the agent restarts
+var daemonRoot = appconfig.DaemonRoot
+
+// daemonStatusReporter is implemented by the long running plugins of ssm daemons
+type daemonStatusReporter interface {
+	Status() rundaemon.DaemonStatus
+}
+
+// ConfigureDaemon applies the action of an aws:configureDaemon step, handed off by the worker, to the ssm daemons of lrpm
+func ConfigureDaemon(log logger.T, res *contracts.PluginResult, cancelFlag task.CancelFlag) {
+	var input rundaemon.ConfigureDaemonPluginInput
+	if !handedOffInput(log, "ssm daemons are", res, cancelFlag, &input) {
+		return
+	}
+	lrpm, err := GetInstance()
+	if err != nil {
+		CreateResult(fmt.Sprintf("Unable to configure ssm daemon %v: %v", input.Name, err), contracts.ResultStatusFailed, res)
+		return
+	}
+
+	var msg string
+	switch input.Action {
+	case rundaemon.ActionStart:
+		msg, err = lrpm.startDaemon(log, input, cancelFlag)
+	case rundaemon.ActionStop:
+		msg, err = lrpm.stopDaemon(input.Name, cancelFlag)
+	case rundaemon.ActionRemove:
+		msg, err = lrpm.removeDaemon(input.Name, cancelFlag)
+	case rundaemon.ActionStatus:
+		msg, err = lrpm.daemonStatus(input.Name)
+	default:
+		err = fmt.Errorf("Unsupported action %v", input.Action)
+	}
+	if err != nil {
+		log.Errorf("Unable to configure ssm daemon %v: %v", input.Name, err)
+		CreateResult(err.Error(), contracts.ResultStatusFailed, res)
+		return
+	}
+	CreateResult(msg, contracts.ResultStatusSuccess, res)
+}
+
+// startDaemon registers the daemon and starts it, a daemon that runs already is restarted with the new configuration
+func (m *Manager) startDaemon(log logger.T, input rundaemon.ConfigureDaemonPluginInput, cancelFlag task.CancelFlag) (msg string, err error) {
+	var registration string
+	if registration, err = jsonutil.Marshal(input); err != nil {
+		return "", fmt.Errorf("Failed to register ssm daemon %v: %v", input.Name, err)
+	}
+	if err = fileutil.MakeDirs(daemonRoot); err != nil {
+		return "", fmt.Errorf("Unable to create ssm daemon folder %v: %v", daemonRoot, err)
+	}
+	if err = fileutil.WriteAllText(daemonFilePath(input.Name), registration); err != nil {
+		return "", fmt.Errorf("Failed to register ssm daemon %v: %v", input.Name, err)
+	}
+
+	m.StopPlugin(input.Name, cancelFlag)
+	m.registerPlugin(input.Name, plugin.NewDaemonPlugin(input))
+	out := iohandler.NewDefaultIOHandler(log, contracts.IOConfiguration{})
+	if err = m.StartPlugin(input.Name, input.Command, "", cancelFlag, out); err != nil {
+		return "", fmt.Errorf("Failed to start ssm daemon %v: %v", input.Name, err)
+	}
+	return fmt.Sprintf("Daemon %v started", input.Name), nil
+}
+
+// stopDaemon stops the daemon, it stays registered but is not started when the agent restarts
+func (m *Manager) stopDaemon(name string, cancelFlag task.CancelFlag) (msg string, err error) {
+	if !fileutil.Exists(daemonFilePath(name)) {
+		return "", fmt.Errorf("No ssm daemon %v exists", name)
+	}
+	if err = m.StopPlugin(name, cancelFlag); err != nil {
+		return "", fmt.Errorf("Failed to stop ssm daemon %v: %v", name, err)
+	}
+	return fmt.Sprintf("Daemon %v stopped", name), nil
+}
+
+// removeDaemon stops the daemon and removes its registration
+func (m *Manager) removeDaemon(name string, cancelFlag task.CancelFlag) (msg string, err error) {
+	if !fileutil.Exists(daemonFilePath(name)) {
+		return fmt.Sprintf("Daemon %v is not installed", name), nil
+	}
+	if err = m.StopPlugin(name, cancelFlag); err != nil {
+		return "", fmt.Errorf("Failed to stop ssm daemon %v: %v", name, err)
+	}
+	if err = fileutil.DeleteFile(daemonFilePath(name)); err != nil {
+		return "", fmt.Errorf("Failed to remove ssm daemon %v: %v", name, err)
+	}
+	m.unregisterPlugin(name)
+	return fmt.Sprintf("Daemon %v removed", name), nil
+}
+
+// daemonStatus returns the supervision state of the daemon as json
+func (m *Manager) daemonStatus(name string) (msg string, err error) {
+	lock.RLock()
+	p, registered := m.registeredPlugins[name]
+	lock.RUnlock()
+	reporter, isDaemon := p.Handler.(daemonStatusReporter)
+	if !registered || !isDaemon {
+		return "", fmt.Errorf("No ssm daemon %v exists", name)
+	}
+	return jsonutil.Marshal(reporter.Status())
+}
+
+func (m *Manager) registerPlugin(name string, p plugin.Plugin) {
+	lock.Lock()
+	defer lock.Unlock()
+	m.registeredPlugins[name] = p
+}
+
+func (m *Manager) unregisterPlugin(name string) {
+	lock.Lock()
+	defer lock.Unlock()
+	delete(m.registeredPlugins, name)
+}
+
+func daemonFilePath(name string) string {
+	return filepath.Join(daemonRoot, name+".json")
+}
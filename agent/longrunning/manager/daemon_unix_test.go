@@ -0,0 +1,154 @@
+// +build darwin freebsd linux netbsd openbsd
+
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package manager
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/context"
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/fileutil"
+	"github.com/aws/amazon-ssm-agent/agent/longrunning/datastore"
+	"github.com/aws/amazon-ssm-agent/agent/longrunning/plugin"
+	"github.com/aws/amazon-ssm-agent/agent/longrunning/plugin/rundaemon"
+	"github.com/aws/amazon-ssm-agent/agent/task"
+	"github.com/stretchr/testify/assert"
+)
+
+type memoryDataStore struct {
+	data map[string]plugin.PluginInfo
+}
+
+func (d *memoryDataStore) Write(data map[string]plugin.PluginInfo) error {
+	d.data = make(map[string]plugin.PluginInfo)
+	for name, info := range data {
+		d.data[name] = info
+	}
+	return nil
+}
+
+func (d *memoryDataStore) Read() (map[string]plugin.PluginInfo, error) {
+	return d.data, nil
+}
+
+func setupDaemonManager(t *testing.T) (dir string, store *memoryDataStore, cleanup func()) {
+	dir, err := ioutil.TempDir("", "daemons")
+	assert.NoError(t, err)
+	store = &memoryDataStore{}
+	daemonRoot = dir
+	dataStore = store
+	singletonInstance = &Manager{
+		context:           context.NewMockDefault(),
+		runningPlugins:    map[string]plugin.PluginInfo{},
+		registeredPlugins: map[string]plugin.Plugin{},
+	}
+	return dir, store, func() {
+		daemonRoot = appconfig.DaemonRoot
+		dataStore = ds{dsImpl: datastore.FsStore{}}
+		singletonInstance = nil
+		os.RemoveAll(dir)
+	}
+}
+
+func configureDaemon(input rundaemon.ConfigureDaemonPluginInput, action string) *contracts.PluginResult {
+	input.Action = action
+	res := &contracts.PluginResult{
+		PluginName: appconfig.PluginNameAwsConfigureDaemon,
+		Status:     contracts.ResultStatusSuccess,
+		Output:     input,
+	}
+	ConfigureDaemon(loggerMock, res, task.NewChanneledCancelFlag())
+	return res
+}
+
+func TestConfigureDaemonLifecycle(t *testing.T) {
+	dir, store, cleanup := setupDaemonManager(t)
+	defer cleanup()
+	input := rundaemon.ConfigureDaemonPluginInput{
+		Name:            "testdaemon",
+		PackageLocation: dir,
+		Command:         "sleep 30",
+		LogDirectory:    filepath.Join(dir, "logs"),
+	}
+
+	res := configureDaemon(input, rundaemon.ActionStart)
+	assert.Equal(t, contracts.ResultStatusSuccess, res.Status, res.StandardError)
+	assert.Equal(t, "Daemon testdaemon started", res.StandardOutput)
+	assert.True(t, fileutil.Exists(filepath.Join(dir, "testdaemon.json")))
+	assert.Contains(t, store.data, "testdaemon", "a started daemon is started again when the agent restarts")
+
+	res = configureDaemon(input, rundaemon.ActionStatus)
+	assert.Equal(t, contracts.ResultStatusSuccess, res.Status)
+	assert.Contains(t, res.StandardOutput, rundaemon.StateRunning)
+
+	res = configureDaemon(input, rundaemon.ActionStop)
+	assert.Equal(t, contracts.ResultStatusSuccess, res.Status)
+	assert.NotContains(t, store.data, "testdaemon")
+
+	res = configureDaemon(input, rundaemon.ActionStatus)
+	assert.Contains(t, res.StandardOutput, rundaemon.StateStopped)
+
+	res = configureDaemon(input, rundaemon.ActionRemove)
+	assert.Equal(t, contracts.ResultStatusSuccess, res.Status)
+	assert.False(t, fileutil.Exists(filepath.Join(dir, "testdaemon.json")))
+
+	res = configureDaemon(input, rundaemon.ActionStatus)
+	assert.Equal(t, contracts.ResultStatusFailed, res.Status)
+}
+
+func TestConfigureDaemonStopUnknownDaemon(t *testing.T) {
+	dir, _, cleanup := setupDaemonManager(t)
+	defer cleanup()
+
+	res := configureDaemon(rundaemon.ConfigureDaemonPluginInput{Name: "unknown", PackageLocation: dir}, rundaemon.ActionStop)
+
+	assert.Equal(t, contracts.ResultStatusFailed, res.Status)
+	assert.Equal(t, "No ssm daemon unknown exists", res.StandardError)
+}
+
+func TestConfigureDaemonSkipsFailedStep(t *testing.T) {
+	_, _, cleanup := setupDaemonManager(t)
+	defer cleanup()
+	res := &contracts.PluginResult{
+		Status:        contracts.ResultStatusFailed,
+		StandardError: "configureDaemon input invalid",
+	}
+
+	ConfigureDaemon(loggerMock, res, task.NewChanneledCancelFlag())
+
+	assert.Equal(t, contracts.ResultStatusFailed, res.Status)
+	assert.Equal(t, "configureDaemon input invalid", res.StandardError)
+}
+
+func TestConfigureDaemonCancelled(t *testing.T) {
+	dir, _, cleanup := setupDaemonManager(t)
+	defer cleanup()
+	cancelFlag := task.NewChanneledCancelFlag()
+	cancelFlag.Set(task.Canceled)
+	res := &contracts.PluginResult{
+		Status: contracts.ResultStatusSuccess,
+		Output: rundaemon.ConfigureDaemonPluginInput{Name: "testdaemon", Action: rundaemon.ActionStart, PackageLocation: dir, Command: "sleep 30"},
+	}
+
+	ConfigureDaemon(loggerMock, res, cancelFlag)
+
+	assert.Equal(t, contracts.ResultStatusCancelled, res.Status)
+	assert.False(t, fileutil.Exists(filepath.Join(dir, "testdaemon.json")))
+}
@@ -28,7 +28,35 @@ func CreateResult(msg string, status contracts.ResultStatus, res *contracts.Plug
 	return
 }
 
-func Invoke(log logger.T, pluginID string, res *contracts.PluginResult, orchestrationDir string) {
+// handedOffInput reads the input of a step the worker handed off to lrpm, it returns false when the step failed or was
+// cancelled and there is nothing to apply.
+func handedOffInput(log logger.T, subject string, res *contracts.PluginResult, cancelFlag task.CancelFlag, input interface{}) bool {
+	if res.Status != contracts.ResultStatusSuccess {
+		// the step failed or was cancelled in the worker
+		return false
+	}
+	if cancelFlag.Canceled() || cancelFlag.ShutDown() {
+		log.Infof("Command was cancelled, %v left unchanged", subject)
+		res.Status = contracts.ResultStatusCancelled
+		res.Code = 1
+		return false
+	}
+	if err := jsonutil.Remarshal(res.Output, input); err != nil {
+		CreateResult(fmt.Sprintf("Invalid configuration of %v: %v", subject, err), contracts.ResultStatusFailed, res)
+		return false
+	}
+	return true
+}
+
+// Invoke hands the result of the lrpm invoker off to the long running plugin, the plugin is started or stopped with the
+// cancel flag of the command and left unchanged when the command was cancelled.
+func Invoke(log logger.T, pluginID string, res *contracts.PluginResult, orchestrationDir string, cancelFlag task.CancelFlag) {
+	if res.Status == contracts.ResultStatusCancelled || cancelFlag.Canceled() || cancelFlag.ShutDown() {
+		log.Infof("Command was cancelled, %s is left unchanged", lrpName)
+		res.Status = contracts.ResultStatusCancelled
+		res.Code = 1
+		return
+	}
 	var lrpm T
 	var err error
 	var startType = res.StandardOutput
@@ -45,7 +73,6 @@ func Invoke(log logger.T, pluginID string, res *contracts.PluginResult, orchestr
 
 		return
 	}
-	cancelFlag := task.NewChanneledCancelFlag()
 	//NOTE: All long running plugins have json node similar to aws:cloudWatch as mentioned in SSM document - AWS-ConfigureCloudWatch
 
 	//check if plugin is enabled or not - which would be stored in settings
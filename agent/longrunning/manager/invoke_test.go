@@ -0,0 +1,49 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+package manager
+
+import (
+	"testing"
+
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/task"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInvokeCancelledCommand(t *testing.T) {
+	cancelFlag := task.NewChanneledCancelFlag()
+	cancelFlag.Set(task.Canceled)
+	res := &contracts.PluginResult{
+		Status:         contracts.ResultStatusSuccess,
+		StandardOutput: "Enabled",
+	}
+
+	Invoke(log.NewMockLog(), "aws:cloudWatch", res, "orchestration", cancelFlag)
+
+	assert.Equal(t, contracts.ResultStatusCancelled, res.Status)
+	assert.Equal(t, 1, res.Code)
+	assert.Equal(t, "Enabled", res.StandardOutput, "the result is not handed off to the long running plugin")
+}
+
+func TestInvokeCancelledInvoker(t *testing.T) {
+	res := &contracts.PluginResult{
+		Status: contracts.ResultStatusCancelled,
+		Code:   1,
+	}
+
+	Invoke(log.NewMockLog(), "aws:cloudWatch", res, "orchestration", task.NewChanneledCancelFlag())
+
+	assert.Equal(t, contracts.ResultStatusCancelled, res.Status)
+	assert.Empty(t, res.StandardError)
+}
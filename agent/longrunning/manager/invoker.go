@@ -19,6 +19,7 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
 	"github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/iohandler"
 	"github.com/aws/amazon-ssm-agent/agent/longrunning/plugin"
 	"github.com/aws/amazon-ssm-agent/agent/longrunning/plugin/cloudwatch"
@@ -59,8 +60,10 @@ func (m *Manager) StopPlugin(name string, cancelFlag task.CancelFlag) (err error
 		}
 
 		// Update the config file to "IsEnabled": "false"
-		if err = cloudwatch.Instance().Disable(); err != nil {
-			log.Errorf("Failed to update config file - because of %s", err)
+		if name == appconfig.PluginNameCloudWatch {
+			if err = cloudwatch.Instance().Disable(); err != nil {
+				log.Errorf("Failed to update config file - because of %s", err)
+			}
 		}
 
 		return
@@ -109,6 +112,10 @@ func (m *Manager) StartPlugin(name, configuration string, orchestrationDir strin
 		log.Errorf(err.Error())
 	}
 
+	if name != appconfig.PluginNameCloudWatch {
+		return
+	}
+
 	// Update the config file with new configuration
 	var engineConfigurationParser cloudwatch.EngineConfigurationParser
 	json.Unmarshal([]byte(p.Info.Configuration), &engineConfigurationParser)
@@ -33,6 +33,11 @@ var (
 	lock sync.RWMutex
 )
 
+// configDrifter is implemented by long running plugins that detect when their running configuration drifted from its source
+type configDrifter interface {
+	HasDrifted(context context.T) bool
+}
+
 // ensurePluginsAreRunning ensures all running plugins are actually running.
 func (m *Manager) ensurePluginsAreRunning() {
 
@@ -44,30 +49,39 @@ func (m *Manager) ensurePluginsAreRunning() {
 	if len(m.runningPlugins) > 0 {
 		for n := range m.runningPlugins {
 			p, isRegistered := m.registeredPlugins[n]
-			if isRegistered && !p.Handler.IsRunning(m.context) {
-				log.Infof("Starting %s since it wasn't running before")
-				//todo: we arent using task pools anymore -> change the following implementation
-				m.startPlugin.Submit(m.context.Log(), n, func(cancelFlag task.CancelFlag) {
-					instanceID, _ := platform.InstanceID()
-					orchestrationRootDir := filepath.Join(
-						appconfig.DefaultDataStorePath,
-						instanceID,
-						appconfig.DefaultDocumentRootDirName,
-						m.context.AppConfig().Agent.OrchestrationRootDir)
-					orchestrationDir := fileutil.BuildPath(orchestrationRootDir)
-
-					ioConfig := contracts.IOConfiguration{
-						OrchestrationDirectory: orchestrationDir,
-						OutputS3BucketName:     "",
-						OutputS3KeyPrefix:      "",
-					}
-					out := iohandler.NewDefaultIOHandler(log, ioConfig)
-					defer out.Close(log)
-					out.Init(log, p.Info.Name)
-					p.Handler.Start(m.context, p.Info.Configuration, "", cancelFlag, out)
-					out.Close(log)
-				})
+			if !isRegistered {
+				continue
 			}
+			if p.Handler.IsRunning(m.context) {
+				drifter, detectsDrift := p.Handler.(configDrifter)
+				if !detectsDrift || !drifter.HasDrifted(m.context) {
+					continue
+				}
+				log.Infof("Restarting %s since its configuration drifted", n)
+			} else {
+				log.Infof("Starting %s since it wasn't running before", n)
+			}
+			//todo: we arent using task pools anymore -> change the following implementation
+			m.startPlugin.Submit(m.context.Log(), n, func(cancelFlag task.CancelFlag) {
+				instanceID, _ := platform.InstanceID()
+				orchestrationRootDir := filepath.Join(
+					appconfig.DefaultDataStorePath,
+					instanceID,
+					appconfig.DefaultDocumentRootDirName,
+					m.context.AppConfig().Agent.OrchestrationRootDir)
+				orchestrationDir := fileutil.BuildPath(orchestrationRootDir)
+
+				ioConfig := contracts.IOConfiguration{
+					OrchestrationDirectory: orchestrationDir,
+					OutputS3BucketName:     "",
+					OutputS3KeyPrefix:      "",
+				}
+				out := iohandler.NewDefaultIOHandler(log, ioConfig)
+				defer out.Close(log)
+				out.Init(log, p.Info.Name)
+				p.Handler.Start(m.context, p.Info.Configuration, "", cancelFlag, out)
+				out.Close(log)
+			})
 		}
 	} else {
 		log.Infof("There are no long running plugins currently getting executed - skipping their healthcheck")
@@ -77,7 +91,7 @@ func (m *Manager) ensurePluginsAreRunning() {
 // stopLifeCycleManagementJob stops periodic health checks of long running plugins
 func (m *Manager) stopLifeCycleManagementJob() {
 	if m.managingLifeCycleJob != nil {
-		m.managingLifeCycleJob.Quit <- true
+		m.managingLifeCycleJob.Stop()
 	}
 }
 
@@ -0,0 +1,291 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package cloudwatchagent implements the long running plugin that installs, configures and supervises the unified
+// CloudWatch agent with its configuration stored in Parameter Store
+package cloudwatchagent
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/context"
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/fileutil"
+	"github.com/aws/amazon-ssm-agent/agent/fileutil/artifact"
+	"github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/iohandler"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/platform"
+	ssmsvc "github.com/aws/amazon-ssm-agent/agent/ssm"
+	"github.com/aws/amazon-ssm-agent/agent/task"
+)
+
+const (
+	// ActionInstall downloads and installs the latest unified CloudWatch agent
+	ActionInstall = "Install"
+	// ActionConfigure applies the configuration of a parameter and keeps the agent running with it
+	ActionConfigure = "Configure"
+	// ActionRestart applies the configuration of the parameter again and restarts the agent
+	ActionRestart = "Restart"
+	// ActionStop stops the agent, it is not started again when the ssm agent restarts
+	ActionStop = "Stop"
+	// ActionStatus reports the state of the agent and whether its configuration drifted from the parameter
+	ActionStatus = "Status"
+
+	// ConfigInSync is the configuration state of an agent that runs the configuration of its parameter
+	ConfigInSync = "InSync"
+	// ConfigDrifted is the configuration state of an agent whose parameter or local configuration changed
+	ConfigDrifted = "Drifted"
+	// ConfigUnknown is the configuration state of an agent whose parameter can't be read
+	ConfigUnknown = "Unknown"
+
+	agentStateRunning = "running"
+)
+
+var (
+	// configPath is the local copy of the configuration the agent runs with
+	configPath = filepath.Join(appconfig.DefaultDataStorePath, "cloudwatchagent", "amazon-cloudwatch-agent.json")
+
+	getParameter      = parameterValue
+	runCtl            = ctl
+	installPackage    = install
+	download          = artifact.Download
+	isManagedInstance = platform.IsManagedInstance
+)
+
+// ConfigureCloudWatchAgentPluginInput represents an action on the unified CloudWatch agent.
+type ConfigureCloudWatchAgentPluginInput struct {
+	contracts.PluginInput
+	Action string `json:"action"`
+	// ParameterName is the Parameter Store parameter with the json configuration of the agent
+	ParameterName string `json:"parameterName"`
+}
+
+// AgentStatus is the state of the unified CloudWatch agent reported by the Status action
+type AgentStatus struct {
+	State         string `json:"state"`
+	Version       string `json:"version,omitempty"`
+	StartTime     string `json:"startTime,omitempty"`
+	ParameterName string `json:"parameterName,omitempty"`
+	Config        string `json:"config"`
+}
+
+// ctlStatus is the status printed by the control script of the agent
+type ctlStatus struct {
+	Status       string `json:"status"`
+	StartTime    string `json:"starttime"`
+	ConfigStatus string `json:"configstatus"`
+	Version      string `json:"version"`
+}
+
+var validParameterName = regexp.MustCompile(`^/?[a-zA-Z0-9_.\-]+(/[a-zA-Z0-9_.\-]+)*$`)
+
+// ValidateInput validates the input given to configure the unified CloudWatch agent
+func ValidateInput(input ConfigureCloudWatchAgentPluginInput) error {
+	switch input.Action {
+	case ActionInstall, ActionRestart, ActionStop, ActionStatus:
+	case ActionConfigure:
+		if input.ParameterName == "" {
+			return errors.New("parameter name of the agent configuration is missing")
+		}
+	default:
+		return fmt.Errorf("unsupported action %v", input.Action)
+	}
+	if input.ParameterName != "" && !validParameterName.MatchString(input.ParameterName) {
+		return fmt.Errorf("invalid parameter name %v", input.ParameterName)
+	}
+	return nil
+}
+
+// Plugin is the long running plugin of the unified CloudWatch agent
+type Plugin struct {
+	lock sync.Mutex
+	// parameterName is the parameter of the configuration the agent runs with
+	parameterName string
+}
+
+// NewPlugin returns the long running plugin of the unified CloudWatch agent
+func NewPlugin() *Plugin {
+	return &Plugin{}
+}
+
+// IsRunning returns true if the agent runs
+func (p *Plugin) IsRunning(context context.T) bool {
+	status, err := agentStatus()
+	return err == nil && status.Status == agentStateRunning
+}
+
+// Start applies the configuration of the parameter given as configuration, an agent that runs in sync with it is left running
+func (p *Plugin) Start(context context.T, configuration string, orchestrationDir string, cancelFlag task.CancelFlag, out iohandler.IOHandler) error {
+	log := context.Log()
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if configuration != "" {
+		p.parameterName = configuration
+	}
+	if p.IsRunning(context) {
+		if drifted, err := p.configDrifted(log); err == nil && !drifted {
+			log.Infof("CloudWatch agent runs with the configuration of %v", p.parameterName)
+			return nil
+		}
+	}
+	return p.apply(log)
+}
+
+// Restart applies the configuration of the parameter again and restarts the agent
+func (p *Plugin) Restart(log log.T) error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return p.apply(log)
+}
+
+// Stop stops the agent
+func (p *Plugin) Stop(context context.T, cancelFlag task.CancelFlag) error {
+	context.Log().Info("Stopping CloudWatch agent")
+	if output, err := runCtl("-a", "stop"); err != nil {
+		return fmt.Errorf("failed to stop CloudWatch agent: %v %v", err, output)
+	}
+	return nil
+}
+
+// HasDrifted returns true if the parameter or the local configuration of the agent changed since it was applied
+func (p *Plugin) HasDrifted(context context.T) bool {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	drifted, err := p.configDrifted(context.Log())
+	if err != nil {
+		context.Log().Warnf("Unable to detect drift of the CloudWatch agent configuration: %v", err)
+		return false
+	}
+	return drifted
+}
+
+// Status returns the state of the agent and of its configuration
+func (p *Plugin) Status(log log.T) AgentStatus {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	status := AgentStatus{ParameterName: p.parameterName, Config: ConfigUnknown}
+	if agent, err := agentStatus(); err != nil {
+		status.State = "not installed"
+	} else {
+		status.State = agent.Status
+		status.Version = agent.Version
+		status.StartTime = agent.StartTime
+	}
+	if drifted, err := p.configDrifted(log); err != nil {
+		log.Warnf("Unable to detect drift of the CloudWatch agent configuration: %v", err)
+	} else if drifted {
+		status.Config = ConfigDrifted
+	} else {
+		status.Config = ConfigInSync
+	}
+	return status
+}
+
+// apply copies the configuration of the parameter locally and restarts the agent with it
+func (p *Plugin) apply(log log.T) error {
+	if p.parameterName == "" {
+		return errors.New("CloudWatch agent is not configured with a parameter")
+	}
+	config, err := getParameter(log, p.parameterName)
+	if err != nil {
+		return fmt.Errorf("unable to get the CloudWatch agent configuration %v: %v", p.parameterName, err)
+	}
+	if !json.Valid([]byte(config)) {
+		return fmt.Errorf("parameter %v is not a json CloudWatch agent configuration", p.parameterName)
+	}
+	if err = fileutil.MakeDirs(filepath.Dir(configPath)); err != nil {
+		return err
+	}
+	if err = ioutil.WriteFile(configPath, []byte(config), 0600); err != nil {
+		return err
+	}
+	mode := "ec2"
+	if managed, _ := isManagedInstance(); managed {
+		mode = "onPremise"
+	}
+	log.Infof("Applying CloudWatch agent configuration %v", p.parameterName)
+	if output, err := runCtl("-a", "fetch-config", "-m", mode, "-c", "file:"+configPath, "-s"); err != nil {
+		return fmt.Errorf("failed to apply CloudWatch agent configuration: %v %v", err, output)
+	}
+	return nil
+}
+
+// configDrifted compares the configuration of the parameter with the local configuration the agent runs with
+func (p *Plugin) configDrifted(log log.T) (bool, error) {
+	if p.parameterName == "" {
+		return false, errors.New("CloudWatch agent is not configured with a parameter")
+	}
+	config, err := getParameter(log, p.parameterName)
+	if err != nil {
+		return false, err
+	}
+	local, err := ioutil.ReadFile(configPath)
+	if err != nil {
+		return true, nil
+	}
+	return sha256.Sum256([]byte(config)) != sha256.Sum256(local), nil
+}
+
+// Install downloads the latest unified CloudWatch agent and installs it
+func Install(log log.T) (string, error) {
+	url, err := packageURL()
+	if err != nil {
+		return "", err
+	}
+	log.Infof("Downloading CloudWatch agent from %v", url)
+	output, err := download(log, artifact.DownloadInput{
+		SourceURL:            url,
+		DestinationDirectory: filepath.Join(appconfig.DownloadRoot, "cloudwatchagent"),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to download CloudWatch agent: %v", err)
+	}
+	if result, err := installPackage(output.LocalFilePath); err != nil {
+		return "", fmt.Errorf("failed to install CloudWatch agent: %v %v", err, result)
+	}
+	return "CloudWatch agent installed", nil
+}
+
+func agentStatus() (status ctlStatus, err error) {
+	var output string
+	if output, err = runCtl("-a", "status"); err != nil {
+		return
+	}
+	// the control script may print lines before the json status
+	if start := strings.Index(output, "{"); start >= 0 {
+		output = output[start:]
+	}
+	err = json.Unmarshal([]byte(output), &status)
+	return
+}
+
+// parameterValue returns the decrypted value of a parameter
+func parameterValue(log log.T, name string) (string, error) {
+	response, err := ssmsvc.NewService().GetDecryptedParameters(log, []string{name})
+	if err != nil {
+		return "", err
+	}
+	if len(response.Parameters) == 0 || response.Parameters[0].Value == nil {
+		return "", fmt.Errorf("parameter %v not found", name)
+	}
+	return *response.Parameters[0].Value, nil
+}
@@ -0,0 +1,174 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package cloudwatchagent
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aws/amazon-ssm-agent/agent/context"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/platform"
+	"github.com/aws/amazon-ssm-agent/agent/task"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeAgent replaces the control script and Parameter Store in the tests
+type fakeAgent struct {
+	parameter string
+	running   bool
+	calls     []string
+}
+
+func setupFakeAgent(t *testing.T, parameter string) (agent *fakeAgent, cleanup func()) {
+	dir, err := ioutil.TempDir("", "cloudwatchagent")
+	assert.NoError(t, err)
+	agent = &fakeAgent{parameter: parameter}
+	defaultConfigPath := configPath
+	configPath = filepath.Join(dir, "amazon-cloudwatch-agent.json")
+	isManagedInstance = func() (bool, error) { return false, nil }
+	getParameter = func(log log.T, name string) (string, error) {
+		if agent.parameter == "" {
+			return "", errors.New("parameter not found")
+		}
+		return agent.parameter, nil
+	}
+	runCtl = func(args ...string) (string, error) {
+		agent.calls = append(agent.calls, strings.Join(args, " "))
+		switch args[1] {
+		case "status":
+			if agent.running {
+				return `{"status": "running", "starttime": "2020-06-01T10:00:00", "configstatus": "configured", "version": "1.247345"}`, nil
+			}
+			return `{"status": "stopped", "starttime": "", "configstatus": "configured", "version": "1.247345"}`, nil
+		case "fetch-config":
+			agent.running = true
+		case "stop":
+			agent.running = false
+		}
+		return "", nil
+	}
+	return agent, func() {
+		configPath = defaultConfigPath
+		isManagedInstance = platform.IsManagedInstance
+		getParameter = parameterValue
+		runCtl = ctl
+		os.RemoveAll(dir)
+	}
+}
+
+func TestValidateInput(t *testing.T) {
+	valid := []ConfigureCloudWatchAgentPluginInput{
+		{Action: ActionInstall},
+		{Action: ActionConfigure, ParameterName: "AmazonCloudWatch-linux"},
+		{Action: ActionConfigure, ParameterName: "/cloudwatch/agent.config"},
+		{Action: ActionRestart},
+		{Action: ActionStop},
+		{Action: ActionStatus},
+	}
+	for _, input := range valid {
+		assert.NoError(t, ValidateInput(input), "%v", input)
+	}
+	invalid := []ConfigureCloudWatchAgentPluginInput{
+		{Action: "Uninstall"},
+		{Action: ActionConfigure},
+		{Action: ActionConfigure, ParameterName: "config; rm -rf /"},
+		{Action: ActionRestart, ParameterName: "cloudwatch//config"},
+	}
+	for _, input := range invalid {
+		assert.Error(t, ValidateInput(input), "%v", input)
+	}
+}
+
+func TestStartAppliesConfiguration(t *testing.T) {
+	agent, cleanup := setupFakeAgent(t, `{"metrics": {}}`)
+	defer cleanup()
+	p := NewPlugin()
+
+	err := p.Start(context.NewMockDefault(), "AmazonCloudWatch-linux", "", task.NewChanneledCancelFlag(), nil)
+	assert.NoError(t, err)
+	assert.Contains(t, agent.calls, "-a fetch-config -m ec2 -c file:"+configPath+" -s")
+	config, err := ioutil.ReadFile(configPath)
+	assert.NoError(t, err)
+	assert.Equal(t, `{"metrics": {}}`, string(config))
+	assert.True(t, p.IsRunning(context.NewMockDefault()))
+}
+
+func TestStartLeavesAgentInSyncRunning(t *testing.T) {
+	agent, cleanup := setupFakeAgent(t, `{"metrics": {}}`)
+	defer cleanup()
+	p := NewPlugin()
+	assert.NoError(t, p.Start(context.NewMockDefault(), "AmazonCloudWatch-linux", "", task.NewChanneledCancelFlag(), nil))
+	agent.calls = nil
+
+	assert.NoError(t, p.Start(context.NewMockDefault(), "", "", task.NewChanneledCancelFlag(), nil))
+	for _, call := range agent.calls {
+		assert.NotContains(t, call, "fetch-config")
+	}
+}
+
+func TestStartRejectsInvalidConfiguration(t *testing.T) {
+	agent, cleanup := setupFakeAgent(t, "metrics")
+	defer cleanup()
+
+	err := NewPlugin().Start(context.NewMockDefault(), "AmazonCloudWatch-linux", "", task.NewChanneledCancelFlag(), nil)
+	assert.Error(t, err)
+	assert.False(t, agent.running)
+}
+
+func TestDriftDetection(t *testing.T) {
+	agent, cleanup := setupFakeAgent(t, `{"metrics": {}}`)
+	defer cleanup()
+	p := NewPlugin()
+	assert.NoError(t, p.Start(context.NewMockDefault(), "AmazonCloudWatch-linux", "", task.NewChanneledCancelFlag(), nil))
+	assert.False(t, p.HasDrifted(context.NewMockDefault()))
+	assert.Equal(t, ConfigInSync, p.Status(log.NewMockLog()).Config)
+
+	agent.parameter = `{"logs": {}}`
+	assert.True(t, p.HasDrifted(context.NewMockDefault()))
+	status := p.Status(log.NewMockLog())
+	assert.Equal(t, ConfigDrifted, status.Config)
+	assert.Equal(t, "running", status.State)
+	assert.Equal(t, "1.247345", status.Version)
+	assert.Equal(t, "AmazonCloudWatch-linux", status.ParameterName)
+
+	// a drifted agent is configured again when lrpm starts it
+	assert.NoError(t, p.Start(context.NewMockDefault(), "", "", task.NewChanneledCancelFlag(), nil))
+	assert.False(t, p.HasDrifted(context.NewMockDefault()))
+
+	assert.NoError(t, ioutil.WriteFile(configPath, []byte(`{}`), 0600))
+	assert.True(t, p.HasDrifted(context.NewMockDefault()), "a local change of the configuration is a drift")
+
+	agent.parameter = ""
+	assert.False(t, p.HasDrifted(context.NewMockDefault()), "an unreadable parameter is not a drift")
+	assert.Equal(t, ConfigUnknown, p.Status(log.NewMockLog()).Config)
+}
+
+func TestStopAndRestart(t *testing.T) {
+	agent, cleanup := setupFakeAgent(t, `{"metrics": {}}`)
+	defer cleanup()
+	p := NewPlugin()
+	assert.Error(t, p.Restart(log.NewMockLog()), "an agent without parameter can't be restarted")
+
+	assert.NoError(t, p.Start(context.NewMockDefault(), "AmazonCloudWatch-linux", "", task.NewChanneledCancelFlag(), nil))
+	assert.NoError(t, p.Stop(context.NewMockDefault(), task.NewChanneledCancelFlag()))
+	assert.False(t, agent.running)
+
+	assert.NoError(t, p.Restart(log.NewMockLog()))
+	assert.True(t, agent.running)
+}
@@ -0,0 +1,57 @@
+// +build darwin freebsd linux netbsd openbsd
+
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package cloudwatchagent
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"runtime"
+)
+
+const ctlPath = "/opt/aws/amazon-cloudwatch-agent/bin/amazon-cloudwatch-agent-ctl"
+
+// ctl runs the control script of the agent
+func ctl(args ...string) (string, error) {
+	output, err := exec.Command(ctlPath, args...).CombinedOutput()
+	return string(output), err
+}
+
+// packageURL returns the url of the latest agent package for the package manager of the instance
+func packageURL() (string, error) {
+	if runtime.GOARCH != "amd64" && runtime.GOARCH != "arm64" {
+		return "", fmt.Errorf("CloudWatch agent is not available for %v", runtime.GOARCH)
+	}
+	if _, err := exec.LookPath("dpkg"); err == nil {
+		return fmt.Sprintf("https://s3.amazonaws.com/amazoncloudwatch-agent/debian/%v/latest/amazon-cloudwatch-agent.deb", runtime.GOARCH), nil
+	}
+	if _, err := exec.LookPath("rpm"); err == nil {
+		return fmt.Sprintf("https://s3.amazonaws.com/amazoncloudwatch-agent/redhat/%v/latest/amazon-cloudwatch-agent.rpm", runtime.GOARCH), nil
+	}
+	return "", errors.New("CloudWatch agent needs dpkg or rpm to be installed")
+}
+
+// install installs the downloaded agent package
+func install(packagePath string) (string, error) {
+	var cmd *exec.Cmd
+	if _, err := exec.LookPath("dpkg"); err == nil {
+		cmd = exec.Command("dpkg", "-i", "-E", packagePath)
+	} else {
+		cmd = exec.Command("rpm", "-U", "--replacepkgs", packagePath)
+	}
+	output, err := cmd.CombinedOutput()
+	return string(output), err
+}
@@ -0,0 +1,49 @@
+// +build windows
+
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package cloudwatchagent
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+)
+
+var ctlPath = filepath.Join(os.Getenv("ProgramFiles"), "Amazon", "AmazonCloudWatchAgent", "amazon-cloudwatch-agent-ctl.ps1")
+
+// ctl runs the control script of the agent
+func ctl(args ...string) (string, error) {
+	cmdArgs := append([]string{"-ExecutionPolicy", "Bypass", "-File", ctlPath}, args...)
+	output, err := exec.Command(appconfig.PowerShellPluginCommandName, cmdArgs...).CombinedOutput()
+	return string(output), err
+}
+
+// packageURL returns the url of the latest agent installer
+func packageURL() (string, error) {
+	if runtime.GOARCH != "amd64" {
+		return "", fmt.Errorf("CloudWatch agent is not available for %v", runtime.GOARCH)
+	}
+	return "https://s3.amazonaws.com/amazoncloudwatch-agent/windows/amd64/latest/amazon-cloudwatch-agent.msi", nil
+}
+
+// install installs the downloaded agent installer
+func install(packagePath string) (string, error) {
+	output, err := exec.Command("msiexec", "/i", packagePath, "/qn", "/norestart").CombinedOutput()
+	return string(output), err
+}
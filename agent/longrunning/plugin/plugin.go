@@ -23,6 +23,7 @@ import (
 	"github.com/aws/amazon-ssm-agent/agent/fileutil"
 	"github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/iohandler"
 	"github.com/aws/amazon-ssm-agent/agent/jsonutil"
+	"github.com/aws/amazon-ssm-agent/agent/longrunning/plugin/cloudwatchagent"
 	"github.com/aws/amazon-ssm-agent/agent/longrunning/plugin/rundaemon"
 	"github.com/aws/amazon-ssm-agent/agent/task"
 )
@@ -65,6 +66,18 @@ type LongRunningPluginInput struct {
 	Properties string
 }
 
+// NewDaemonPlugin returns the long running plugin of an ssm daemon
+func NewDaemonPlugin(input rundaemon.ConfigureDaemonPluginInput) Plugin {
+	return Plugin{
+		Info: PluginInfo{
+			Name:          input.Name,
+			Configuration: input.Command,
+			State:         PluginState{IsEnabled: true},
+		},
+		Handler: rundaemon.NewPlugin(input),
+	}
+}
+
 // RegisteredPlugins loads all long running plugins in memory
 func RegisteredPlugins(context context.T) map[string]Plugin {
 	longrunningplugins := make(map[string]Plugin)
@@ -94,6 +107,14 @@ func loadPlatformIndependentPlugins(context context.T) map[string]Plugin {
 	//long running plugins that can be started/stopped/configured by long running plugin manager
 	longrunningplugins := make(map[string]Plugin)
 
+	longrunningplugins[appconfig.PluginNameCloudWatchAgent] = Plugin{
+		Info: PluginInfo{
+			Name:  appconfig.PluginNameCloudWatchAgent,
+			State: PluginState{IsEnabled: true},
+		},
+		Handler: cloudwatchagent.NewPlugin(),
+	}
+
 	for key, value := range loadDaemonPlugins(context) {
 		context.Log().Debugf("Adding long-running plugin for %v", key)
 		longrunningplugins[key] = value
@@ -129,18 +150,7 @@ func loadDaemonPlugins(context context.T) map[string]Plugin {
 				log.Errorf("ssm daemon file %v is invalid: %v", daemonFilePath, err.Error())
 			} else {
 				log.Infof("Registering long-running plugin for ssm daemon %v", input.Name)
-				plugin := Plugin{
-					Info: PluginInfo{
-						Name:          input.Name,
-						Configuration: input.Command,
-						State:         PluginState{IsEnabled: true},
-					},
-					Handler: &rundaemon.Plugin{
-						ExeLocation: input.PackageLocation,
-						Name:        input.Name,
-						CommandLine: input.Command,
-					},
-				}
+				plugin := NewDaemonPlugin(input)
 				if _, exists := daemonPlugins[input.Name]; exists {
 					log.Errorf("duplicate registrations exist for ssm daemon %v", input.Name)
 					continue
@@ -16,12 +16,44 @@ package rundaemon
 
 import (
 	"errors"
+	"fmt"
+	"path/filepath"
 	"regexp"
+	"time"
 
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
 	"github.com/aws/amazon-ssm-agent/agent/contracts"
 	"github.com/aws/amazon-ssm-agent/agent/fileutil"
 )
 
+const (
+	// ActionStart registers the daemon, survives agent restarts, and starts it under supervision
+	ActionStart = "Start"
+	// ActionStop stops the daemon and keeps its registration
+	ActionStop = "Stop"
+	// ActionRemove stops the daemon and removes its registration
+	ActionRemove = "Remove"
+	// ActionStatus reports the supervision state of the daemon
+	ActionStatus = "Status"
+
+	// RestartAlways restarts the daemon whenever it exits
+	RestartAlways = "Always"
+	// RestartOnFailure restarts the daemon when it exits with a non zero code or fails its health probe
+	RestartOnFailure = "OnFailure"
+	// RestartNever leaves the daemon stopped once it exits
+	RestartNever = "Never"
+
+	// StateRunning is the state of a daemon whose process is running
+	StateRunning = "Running"
+	// StateStopped is the state of a daemon that was stopped or exited without being restarted
+	StateStopped = "Stopped"
+	// StateFailed is the state of a daemon that exited too often to be restarted
+	StateFailed = "Failed"
+
+	// DefaultHealthProbeInterval is how often the health probe of a daemon runs unless configured
+	DefaultHealthProbeInterval = 60 * time.Second
+)
+
 // ConfigureDaemonPluginInput represents an action to run a package as a daemon.
 type ConfigureDaemonPluginInput struct {
 	contracts.PluginInput
@@ -29,6 +61,31 @@ type ConfigureDaemonPluginInput struct {
 	Action          string `json:"action"`
 	PackageLocation string `json:"packagelocation"`
 	Command         string `json:"command"`
+	// RestartPolicy is Always (default), OnFailure or Never, Windows restarts daemons with the OnFailure policy like Always
+	RestartPolicy string `json:"restartPolicy"`
+	// HealthProbe is a command run in the package location, the daemon is restarted when it exits with a non zero code.
+	// Health probes are supported on Linux and macOS.
+	HealthProbe                string `json:"healthProbe"`
+	HealthProbeIntervalSeconds int    `json:"healthProbeIntervalSeconds"`
+	// LogDirectory receives the standard output and error of the daemon, by default the logs folder of the daemon root
+	LogDirectory string `json:"logDirectory"`
+}
+
+// Supervision describes how a started daemon is kept running
+type Supervision struct {
+	RestartPolicy       string
+	HealthProbe         string
+	HealthProbeInterval time.Duration
+	LogDirectory        string
+}
+
+// DaemonStatus is the supervision state of a daemon reported by the Status action
+type DaemonStatus struct {
+	Name         string `json:"name"`
+	State        string `json:"state"`
+	Pid          int    `json:"pid,omitempty"`
+	Restarts     int    `json:"restarts"`
+	LastExitCode int    `json:"lastExitCode"`
 }
 
 // ValidateDaemonInput validates the input given to configure daemon
@@ -47,8 +104,58 @@ func ValidateDaemonInput(input ConfigureDaemonPluginInput) error {
 	if !fileutil.Exists(input.PackageLocation) {
 		return errors.New("daemon location does not exist")
 	}
-	if input.Action == "Start" && input.Command == "" {
+	if input.Action == ActionStart && input.Command == "" {
 		return errors.New("daemon launch command is missing")
 	}
+	switch input.RestartPolicy {
+	case "", RestartAlways, RestartOnFailure, RestartNever:
+	default:
+		return fmt.Errorf("invalid restart policy %v, must be %v, %v or %v", input.RestartPolicy, RestartAlways, RestartOnFailure, RestartNever)
+	}
+	if input.HealthProbeIntervalSeconds < 0 {
+		return errors.New("health probe interval must not be negative")
+	}
 	return nil
 }
+
+// SupervisionOf returns the supervision of a daemon with the defaults applied to its input
+func SupervisionOf(input ConfigureDaemonPluginInput) Supervision {
+	supervision := Supervision{
+		RestartPolicy:       input.RestartPolicy,
+		HealthProbe:         input.HealthProbe,
+		HealthProbeInterval: time.Duration(input.HealthProbeIntervalSeconds) * time.Second,
+		LogDirectory:        input.LogDirectory,
+	}
+	if supervision.RestartPolicy == "" {
+		supervision.RestartPolicy = RestartAlways
+	}
+	if supervision.HealthProbeInterval == 0 {
+		supervision.HealthProbeInterval = DefaultHealthProbeInterval
+	}
+	if supervision.LogDirectory == "" {
+		supervision.LogDirectory = filepath.Join(appconfig.DaemonRoot, "logs")
+	}
+	return supervision
+}
+
+// NewPlugin returns the long running plugin that supervises the daemon of the given input
+func NewPlugin(input ConfigureDaemonPluginInput) *Plugin {
+	return &Plugin{
+		ExeLocation: input.PackageLocation,
+		Name:        input.Name,
+		CommandLine: input.Command,
+		Supervision: SupervisionOf(input),
+	}
+}
+
+// shouldRestart returns true if the policy restarts a daemon that exited with the given code
+func shouldRestart(policy string, exitCode int) bool {
+	switch policy {
+	case RestartNever:
+		return false
+	case RestartOnFailure:
+		return exitCode != 0
+	default:
+		return true
+	}
+}
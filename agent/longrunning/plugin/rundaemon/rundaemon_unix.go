@@ -17,11 +17,37 @@
 package rundaemon
 
 import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+
 	"github.com/aws/amazon-ssm-agent/agent/context"
+	"github.com/aws/amazon-ssm-agent/agent/fileutil"
 	"github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/iohandler"
+	"github.com/aws/amazon-ssm-agent/agent/log"
 	"github.com/aws/amazon-ssm-agent/agent/task"
 )
 
+var (
+	// minRestartDelay is the delay before the first restart of a daemon, it doubles with every restart in a row
+	minRestartDelay = time.Second
+	// maxRestartDelay caps the delay between restarts of a daemon
+	maxRestartDelay = time.Minute
+	// maxRestartCount is the number of restarts in a row after which a daemon is left failed
+	maxRestartCount = 10
+	// stableRunTime is how long a daemon runs before its restarts in a row are reset
+	stableRunTime = 10 * time.Minute
+	// stopGracePeriod is how long a daemon has to exit after SIGTERM before it is killed
+	stopGracePeriod = 5 * time.Second
+
+	runHealthProbe = healthProbe
+	timeNow        = time.Now
+)
+
 // Plugin is the type for the configureDaemon plugin.
 type Plugin struct {
 	iohandler.PluginConfig
@@ -29,27 +55,265 @@ type Plugin struct {
 	ExeLocation string
 	// Name is the name of the daemon
 	Name string
-	// CommandLine is the command line to launch the daemon (On Windows, ame of executable or a powershell script)
+	// CommandLine is the command line to launch the daemon, it runs in sh
 	CommandLine string
+	// Supervision is how the daemon is kept running
+	Supervision Supervision
+
+	lock sync.Mutex
+	// stop is closed to stop the supervisor of the daemon, it is nil until the daemon is started
+	stop chan struct{}
+	// done is closed when the supervisor of the daemon returns
+	done   chan struct{}
+	status DaemonStatus
 }
 
-// IsRunning checks if the daemon is alive
+// IsRunning returns false until the daemon is started and after it failed too often,
+// the health check of the long running plugin manager then starts it again
 func (p *Plugin) IsRunning(context context.T) bool {
-	log := context.Log()
-	log.Infof("IsRunning check for daemon %v", p.Name)
-	return false // TODO:DAEMON check to see if process is alive (false for now to force regular restarts and see the logs
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return p.stop != nil && p.status.State != StateFailed
+}
+
+// Status returns the supervision state of the daemon
+func (p *Plugin) Status() DaemonStatus {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	status := p.status
+	status.Name = p.Name
+	if status.State == "" {
+		status.State = StateStopped
+	}
+	return status
 }
 
-// Start starts the daemon
+// Start starts the daemon and supervises it in the background
 func (p *Plugin) Start(context context.T, configuration string, orchestrationDir string, cancelFlag task.CancelFlag, out iohandler.IOHandler) error {
 	log := context.Log()
-	log.Infof("Starting %v Command: %v Config: %v", p.Name, p.CommandLine, configuration)
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.stop != nil {
+		select {
+		case <-p.done:
+		default:
+			log.Infof("Daemon %v is already running", p.Name)
+			return nil
+		}
+	}
+	if configuration != "" {
+		p.CommandLine = configuration
+	}
+	log.Infof("Starting %v Command: %v", p.Name, p.CommandLine)
+	cmd, exited, err := p.launch()
+	if err != nil {
+		p.status.State = StateFailed
+		return err
+	}
+	p.stop = make(chan struct{})
+	p.done = make(chan struct{})
+	p.status = DaemonStatus{State: StateRunning, Pid: cmd.Process.Pid}
+	go p.supervise(log, cmd, exited, p.stop, p.done)
 	return nil
 }
 
-// Stop stops the daemon
+// Stop stops the daemon and waits for it to exit
 func (p *Plugin) Stop(context context.T, cancelFlag task.CancelFlag) error {
 	log := context.Log()
+	p.lock.Lock()
+	stop, done := p.stop, p.done
+	p.stop, p.done = nil, nil
+	p.lock.Unlock()
+	if stop == nil {
+		return nil
+	}
 	log.Infof("Stopping %v", p.Name)
+	close(stop)
+	<-done
 	return nil
 }
+
+// launch starts the process of the daemon, with its output appended to the log files of the daemon
+func (p *Plugin) launch() (*exec.Cmd, <-chan int, error) {
+	if err := fileutil.MakeDirs(p.Supervision.LogDirectory); err != nil {
+		return nil, nil, fmt.Errorf("unable to create log directory of daemon %v: %v", p.Name, err)
+	}
+	stdout, err := openLog(filepath.Join(p.Supervision.LogDirectory, p.Name+".stdout.log"))
+	if err != nil {
+		return nil, nil, err
+	}
+	stderr, err := openLog(filepath.Join(p.Supervision.LogDirectory, p.Name+".stderr.log"))
+	if err != nil {
+		stdout.Close()
+		return nil, nil, err
+	}
+	cmd := exec.Command("sh", "-c", p.CommandLine)
+	cmd.Dir = p.ExeLocation
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	// the daemon runs in its own process group, so stopping it also stops its children
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	if err = cmd.Start(); err != nil {
+		stdout.Close()
+		stderr.Close()
+		return nil, nil, fmt.Errorf("unable to start daemon %v: %v", p.Name, err)
+	}
+	exited := make(chan int, 1)
+	go func() {
+		exited <- exitCode(cmd.Wait())
+		stdout.Close()
+		stderr.Close()
+	}()
+	return cmd, exited, nil
+}
+
+// supervise restarts the daemon as its restart policy requires until it is stopped
+func (p *Plugin) supervise(log log.T, cmd *exec.Cmd, exited <-chan int, stop, done chan struct{}) {
+	defer close(done)
+	restarts := 0
+	for {
+		started := timeNow()
+		code, stopped := p.wait(log, cmd, exited, stop)
+		p.exited(code)
+		if stopped {
+			log.Infof("Daemon %v stopped", p.Name)
+			return
+		}
+		if timeNow().Sub(started) >= stableRunTime {
+			restarts = 0
+		}
+		if !shouldRestart(p.Supervision.RestartPolicy, code) {
+			log.Infof("Daemon %v exited with code %v, restart policy %v leaves it stopped", p.Name, code, p.Supervision.RestartPolicy)
+			return
+		}
+		for {
+			restarts++
+			if restarts > maxRestartCount {
+				log.Errorf("Daemon %v exited %v times in a row, it is not restarted anymore", p.Name, maxRestartCount)
+				p.failed()
+				return
+			}
+			delay := restartDelay(restarts)
+			log.Infof("Daemon %v exited with code %v, restarting it in %v", p.Name, code, delay)
+			select {
+			case <-stop:
+				log.Infof("Daemon %v stopped", p.Name)
+				return
+			case <-time.After(delay):
+			}
+			var err error
+			if cmd, exited, err = p.launch(); err == nil {
+				break
+			}
+			log.Error(err)
+		}
+		p.restarted(cmd.Process.Pid)
+	}
+}
+
+// wait returns the exit code of the daemon, it terminates the daemon when it is stopped or fails its health probe
+func (p *Plugin) wait(log log.T, cmd *exec.Cmd, exited <-chan int, stop chan struct{}) (code int, stopped bool) {
+	var probe <-chan time.Time
+	if p.Supervision.HealthProbe != "" {
+		ticker := time.NewTicker(p.Supervision.HealthProbeInterval)
+		defer ticker.Stop()
+		probe = ticker.C
+	}
+	for {
+		select {
+		case code = <-exited:
+			return code, false
+		case <-stop:
+			return terminate(log, cmd, exited), true
+		case <-probe:
+			if err := runHealthProbe(p.ExeLocation, p.Supervision.HealthProbe, p.Supervision.HealthProbeInterval); err != nil {
+				log.Warnf("Health probe of daemon %v failed: %v", p.Name, err)
+				return terminate(log, cmd, exited), false
+			}
+		}
+	}
+}
+
+func (p *Plugin) exited(code int) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.status.State = StateStopped
+	p.status.Pid = 0
+	p.status.LastExitCode = code
+}
+
+func (p *Plugin) restarted(pid int) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.status.State = StateRunning
+	p.status.Pid = pid
+	p.status.Restarts++
+}
+
+func (p *Plugin) failed() {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.status.State = StateFailed
+}
+
+// terminate stops the process group of the daemon and returns its exit code
+func terminate(log log.T, cmd *exec.Cmd, exited <-chan int) int {
+	pid := cmd.Process.Pid
+	syscall.Kill(-pid, syscall.SIGTERM)
+	select {
+	case code := <-exited:
+		return code
+	case <-time.After(stopGracePeriod):
+		log.Warnf("Daemon process %v did not exit within %v, killing it", pid, stopGracePeriod)
+		syscall.Kill(-pid, syscall.SIGKILL)
+		return <-exited
+	}
+}
+
+// healthProbe runs the health probe command of a daemon, the probe fails when it exits with a non zero code or times out
+func healthProbe(dir, probe string, timeout time.Duration) error {
+	cmd := exec.Command("sh", "-c", probe)
+	cmd.Dir = dir
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	done := make(chan error, 1)
+	go func() { done <- cmd.Wait() }()
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(timeout):
+		cmd.Process.Kill()
+		<-done
+		return fmt.Errorf("health probe timed out after %v", timeout)
+	}
+}
+
+// restartDelay returns the delay before the given restart in a row
+func restartDelay(restarts int) time.Duration {
+	delay := minRestartDelay
+	for i := 1; i < restarts && delay < maxRestartDelay; i++ {
+		delay *= 2
+	}
+	if delay > maxRestartDelay {
+		delay = maxRestartDelay
+	}
+	return delay
+}
+
+func openLog(path string) (*os.File, error) {
+	return os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+}
+
+// exitCode returns the exit code of a process from the error of its Wait, -1 when it was killed by a signal
+func exitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		if status, ok := exitErr.Sys().(syscall.WaitStatus); ok {
+			return status.ExitStatus()
+		}
+	}
+	return -1
+}
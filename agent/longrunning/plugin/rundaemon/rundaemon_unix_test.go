@@ -0,0 +1,178 @@
+// +build darwin freebsd linux netbsd openbsd
+
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package rundaemon
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/context"
+	"github.com/aws/amazon-ssm-agent/agent/task"
+	"github.com/stretchr/testify/assert"
+)
+
+func setupDaemon(t *testing.T, command string, supervision Supervision) (*Plugin, func()) {
+	dir, err := ioutil.TempDir("", "rundaemon")
+	assert.NoError(t, err)
+	minRestartDelay = 10 * time.Millisecond
+	maxRestartCount = 2
+	stopGracePeriod = time.Second
+	if supervision.RestartPolicy == "" {
+		supervision.RestartPolicy = RestartAlways
+	}
+	if supervision.HealthProbeInterval == 0 {
+		supervision.HealthProbeInterval = DefaultHealthProbeInterval
+	}
+	supervision.LogDirectory = filepath.Join(dir, "logs")
+	p := &Plugin{
+		ExeLocation: dir,
+		Name:        "testdaemon",
+		CommandLine: command,
+		Supervision: supervision,
+	}
+	return p, func() {
+		p.Stop(context.NewMockDefault(), task.NewChanneledCancelFlag())
+		minRestartDelay = time.Second
+		maxRestartCount = 10
+		stopGracePeriod = 5 * time.Second
+		os.RemoveAll(dir)
+	}
+}
+
+func start(t *testing.T, p *Plugin) {
+	assert.NoError(t, p.Start(context.NewMockDefault(), p.CommandLine, "", task.NewChanneledCancelFlag(), nil))
+}
+
+// waitForState waits until the daemon has the given state
+func waitForState(t *testing.T, p *Plugin, state string) DaemonStatus {
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if status := p.Status(); status.State == state {
+			return status
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.Fail(t, "daemon did not reach state "+state, "status %v", p.Status())
+	return p.Status()
+}
+
+func TestStartStop(t *testing.T) {
+	p, cleanup := setupDaemon(t, "sleep 30", Supervision{})
+	defer cleanup()
+	assert.False(t, p.IsRunning(context.NewMockDefault()))
+
+	start(t, p)
+	status := waitForState(t, p, StateRunning)
+	assert.True(t, p.IsRunning(context.NewMockDefault()))
+	assert.NotZero(t, status.Pid)
+
+	assert.NoError(t, p.Stop(context.NewMockDefault(), task.NewChanneledCancelFlag()))
+	assert.Equal(t, StateStopped, p.Status().State)
+	assert.Error(t, syscall.Kill(status.Pid, 0), "the process of the daemon is stopped")
+}
+
+func TestStartTwiceKeepsDaemon(t *testing.T) {
+	p, cleanup := setupDaemon(t, "sleep 30", Supervision{})
+	defer cleanup()
+
+	start(t, p)
+	pid := waitForState(t, p, StateRunning).Pid
+	start(t, p)
+
+	assert.Equal(t, pid, p.Status().Pid)
+}
+
+func TestRestartGivesUpAfterFailuresInARow(t *testing.T) {
+	p, cleanup := setupDaemon(t, "exit 3", Supervision{RestartPolicy: RestartOnFailure})
+	defer cleanup()
+
+	start(t, p)
+	status := waitForState(t, p, StateFailed)
+
+	assert.Equal(t, 2, status.Restarts)
+	assert.Equal(t, 3, status.LastExitCode)
+	assert.False(t, p.IsRunning(context.NewMockDefault()), "the lrpm health check starts a failed daemon again")
+}
+
+func TestRestartPolicyLeavesExitedDaemonStopped(t *testing.T) {
+	for _, test := range []struct {
+		policy  string
+		command string
+	}{
+		{RestartNever, "exit 3"},
+		{RestartOnFailure, "exit 0"},
+	} {
+		p, cleanup := setupDaemon(t, test.command, Supervision{RestartPolicy: test.policy})
+		start(t, p)
+		<-p.done
+
+		status := p.Status()
+		assert.Equal(t, StateStopped, status.State, test.policy)
+		assert.Equal(t, 0, status.Restarts, test.policy)
+		assert.True(t, p.IsRunning(context.NewMockDefault()), "the lrpm health check leaves the daemon stopped")
+		cleanup()
+	}
+}
+
+func TestHealthProbeRestartsDaemon(t *testing.T) {
+	p, cleanup := setupDaemon(t, "sleep 30", Supervision{HealthProbe: "test -f healthy", HealthProbeInterval: 50 * time.Millisecond})
+	defer cleanup()
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(p.ExeLocation, "healthy"), nil, 0600))
+
+	start(t, p)
+	pid := waitForState(t, p, StateRunning).Pid
+	time.Sleep(200 * time.Millisecond)
+	assert.Equal(t, pid, p.Status().Pid, "a healthy daemon is not restarted")
+
+	assert.NoError(t, os.Remove(filepath.Join(p.ExeLocation, "healthy")))
+	deadline := time.Now().Add(5 * time.Second)
+	for p.Status().Restarts == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.Equal(t, 1, p.Status().Restarts)
+	assert.Error(t, syscall.Kill(pid, 0), "the unhealthy process is stopped")
+}
+
+func TestDaemonOutputIsRoutedToLogs(t *testing.T) {
+	p, cleanup := setupDaemon(t, "echo out; echo err >&2; sleep 30", Supervision{})
+	defer cleanup()
+
+	start(t, p)
+	waitForState(t, p, StateRunning)
+	stdout := filepath.Join(p.Supervision.LogDirectory, "testdaemon.stdout.log")
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if content, _ := ioutil.ReadFile(stdout); len(content) > 0 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	content, _ := ioutil.ReadFile(stdout)
+	assert.Equal(t, "out\n", string(content))
+	content, _ = ioutil.ReadFile(filepath.Join(p.Supervision.LogDirectory, "testdaemon.stderr.log"))
+	assert.Equal(t, "err\n", string(content))
+}
+
+func TestRestartDelay(t *testing.T) {
+	assert.Equal(t, time.Second, restartDelay(1))
+	assert.Equal(t, 4*time.Second, restartDelay(3))
+	assert.Equal(t, time.Minute, restartDelay(10))
+}
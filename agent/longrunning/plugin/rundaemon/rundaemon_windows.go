@@ -20,12 +20,14 @@ package rundaemon
 import (
 	"os"
 	"os/exec"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/aws/amazon-ssm-agent/agent/context"
+	"github.com/aws/amazon-ssm-agent/agent/fileutil"
 	"github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/iohandler"
 	"github.com/aws/amazon-ssm-agent/agent/longrunning/jobobject"
 	"github.com/aws/amazon-ssm-agent/agent/task"
@@ -63,6 +65,8 @@ type Plugin struct {
 	Name string
 	// CommandLine is command line to launch the daemon (On Windows, ame of executable or a powershell script)
 	CommandLine string
+	// Supervision is how the daemon is kept running, Windows honors the Never restart policy and the log directory
+	Supervision Supervision
 	Process     *os.Process
 	//ProcessStateLock lock is used to Protect access to daemon state updates
 	ProcessStateLock sync.Mutex
@@ -105,6 +109,18 @@ func (p *Plugin) IsRunning(context context.T) bool {
 	return false
 }
 
+// Status returns the supervision state of the daemon
+func (p *Plugin) Status() DaemonStatus {
+	p.ProcessStateLock.Lock()
+	defer p.ProcessStateLock.Unlock()
+	status := DaemonStatus{Name: p.Name, State: StateStopped}
+	if p.CurrentDaemonState == CurrentRunning && p.Process != nil {
+		status.State = StateRunning
+		status.Pid = p.Process.Pid
+	}
+	return status
+}
+
 // This function sets the flag to indicate that daemon stop has been requested via the StopPlugin call.
 func (p *Plugin) stopRequested() bool {
 	p.ProcessStateLock.Lock()
@@ -154,6 +170,18 @@ func StartDaemonHelper(p *Plugin, context context.T, configuration string) (err
 
 	daemonInvoke := exec.Command(commandArguments[0], commandArguments[1:]...)
 	daemonInvoke.Dir = p.ExeLocation
+	if p.Supervision.LogDirectory != "" {
+		var stdout, stderr *os.File
+		if stdout, stderr, err = openDaemonLogs(p); err != nil {
+			log.Errorf("Error opening Daemon logs: %s", err.Error())
+			return err
+		}
+		// the daemon holds its own handles of the log files
+		defer stdout.Close()
+		defer stderr.Close()
+		daemonInvoke.Stdout = stdout
+		daemonInvoke.Stderr = stderr
+	}
 	err = DaemonCmdExecutor(daemonInvoke)
 
 	if err != nil {
@@ -203,6 +231,10 @@ func StartDaemon(p *Plugin, context context.T, configuration string) (err error)
 			log.Infof("Daemon requested to be stopped: %v", configuration)
 			return
 		}
+		if p.Process != nil && p.Supervision.RestartPolicy == RestartNever {
+			log.Infof("Daemon %v exited, restart policy %v leaves it stopped", p.Name, RestartNever)
+			return
+		}
 		// Invoke the helper function to start daermo
 		err = StartDaemonHelperExecutor(p, context, configuration)
 		if err == nil {
@@ -248,6 +280,21 @@ func (p *Plugin) Start(context context.T, configuration string, orchestrationDir
 	return nil
 }
 
+// openDaemonLogs opens the files that receive the output of the daemon
+func openDaemonLogs(p *Plugin) (stdout *os.File, stderr *os.File, err error) {
+	if err = fileutil.MakeDirs(p.Supervision.LogDirectory); err != nil {
+		return
+	}
+	flags := os.O_CREATE | os.O_WRONLY | os.O_APPEND
+	if stdout, err = os.OpenFile(filepath.Join(p.Supervision.LogDirectory, p.Name+".stdout.log"), flags, 0600); err != nil {
+		return
+	}
+	if stderr, err = os.OpenFile(filepath.Join(p.Supervision.LogDirectory, p.Name+".stderr.log"), flags, 0600); err != nil {
+		stdout.Close()
+	}
+	return
+}
+
 func StopDaemon(p *Plugin, context context.T) {
 	log := context.Log()
 	p.ProcessStateLock.Lock()
@@ -0,0 +1,134 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// +build windows
+
+package auth
+
+import (
+	"encoding/base64"
+	"fmt"
+	"syscall"
+	"unsafe"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+)
+
+// The DPAPI backend saves the private key encrypted with the machine key of the Data Protection API,
+// so the registration info can only be decrypted on this machine.
+
+const (
+	cryptProtectUIForbidden  = 0x1
+	cryptProtectLocalMachine = 0x4
+)
+
+var (
+	modCrypt32             = syscall.NewLazyDLL("crypt32.dll")
+	modKernel32            = syscall.NewLazyDLL("kernel32.dll")
+	procCryptProtectData   = modCrypt32.NewProc("CryptProtectData")
+	procCryptUnprotectData = modCrypt32.NewProc("CryptUnprotectData")
+	procLocalFree          = modKernel32.NewProc("LocalFree")
+)
+
+// dataBlob is the DATA_BLOB structure used by the DPAPI functions
+type dataBlob struct {
+	cbData uint32
+	pbData *byte
+}
+
+func init() {
+	keyStorages[appconfig.PrivateKeyStorageOs] = dpapiKeyStorage{}
+}
+
+type dpapiKeyStorage struct{}
+
+func (dpapiKeyStorage) isAvailable() bool {
+	return procCryptProtectData.Find() == nil && procCryptUnprotectData.Find() == nil
+}
+
+func (dpapiKeyStorage) createKey() (publicKey string, keyReference string, err error) {
+	var rsaKey RsaKey
+	if rsaKey, err = CreateKeypair(); err != nil {
+		return
+	}
+	var encodedKey string
+	if encodedKey, err = rsaKey.EncodePrivateKey(); err != nil {
+		return
+	}
+	var protected []byte
+	if protected, err = cryptProtect([]byte(encodedKey)); err != nil {
+		return
+	}
+	keyReference = base64.StdEncoding.EncodeToString(protected)
+	publicKey, err = rsaKey.EncodePublicKey()
+	return
+}
+
+func (dpapiKeyStorage) loadKey(keyReference string) (SigningKey, error) {
+	protected, err := base64.StdEncoding.DecodeString(keyReference)
+	if err != nil {
+		return nil, err
+	}
+	encodedKey, err := cryptUnprotect(protected)
+	if err != nil {
+		return nil, err
+	}
+	rsaKey, err := DecodePrivateKey(string(encodedKey))
+	if err != nil {
+		return nil, err
+	}
+	return &rsaKey, nil
+}
+
+func (dpapiKeyStorage) deleteKey(keyReference string) error { return nil }
+
+func newDataBlob(data []byte) *dataBlob {
+	if len(data) == 0 {
+		return &dataBlob{}
+	}
+	return &dataBlob{cbData: uint32(len(data)), pbData: &data[0]}
+}
+
+// bytes copies the output of a DPAPI call and frees the buffer allocated by Windows
+func (b *dataBlob) bytes() []byte {
+	defer procLocalFree.Call(uintptr(unsafe.Pointer(b.pbData)))
+	output := make([]byte, b.cbData)
+	copy(output, (*[1 << 30]byte)(unsafe.Pointer(b.pbData))[:b.cbData:b.cbData])
+	return output
+}
+
+func cryptProtect(data []byte) ([]byte, error) {
+	var output dataBlob
+	ret, _, err := procCryptProtectData.Call(
+		uintptr(unsafe.Pointer(newDataBlob(data))),
+		0, 0, 0, 0,
+		uintptr(cryptProtectUIForbidden|cryptProtectLocalMachine),
+		uintptr(unsafe.Pointer(&output)))
+	if ret == 0 {
+		return nil, fmt.Errorf("CryptProtectData failed: %v", err)
+	}
+	return output.bytes(), nil
+}
+
+func cryptUnprotect(data []byte) ([]byte, error) {
+	var output dataBlob
+	ret, _, err := procCryptUnprotectData.Call(
+		uintptr(unsafe.Pointer(newDataBlob(data))),
+		0, 0, 0, 0,
+		uintptr(cryptProtectUIForbidden),
+		uintptr(unsafe.Pointer(&output)))
+	if ret == 0 {
+		return nil, fmt.Errorf("CryptUnprotectData failed: %v", err)
+	}
+	return output.bytes(), nil
+}
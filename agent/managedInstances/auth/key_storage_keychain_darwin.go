@@ -0,0 +1,99 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// +build darwin
+
+package auth
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+)
+
+// The keychain backend saves the private key as a generic password in the system keychain,
+// the registration info only holds the account name of the keychain item.
+
+const (
+	keychainPath    = "/Library/Keychains/System.keychain"
+	keychainService = "amazon-ssm-agent"
+	securityTool    = "/usr/bin/security"
+)
+
+func init() {
+	keyStorages[appconfig.PrivateKeyStorageOs] = keychainKeyStorage{}
+}
+
+type keychainKeyStorage struct{}
+
+func (keychainKeyStorage) isAvailable() bool {
+	_, err := exec.LookPath(securityTool)
+	return err == nil
+}
+
+func (keychainKeyStorage) createKey() (publicKey string, keyReference string, err error) {
+	var rsaKey RsaKey
+	if rsaKey, err = CreateKeypair(); err != nil {
+		return
+	}
+	var encodedKey string
+	if encodedKey, err = rsaKey.EncodePrivateKey(); err != nil {
+		return
+	}
+
+	accountBytes := make([]byte, 16)
+	if _, err = rand.Read(accountBytes); err != nil {
+		return
+	}
+	keyReference = "managed-instance-" + hex.EncodeToString(accountBytes)
+
+	// the key is passed on stdin in interactive mode so it never shows up in the process list
+	command := exec.Command(securityTool, "-i")
+	command.Stdin = strings.NewReader(fmt.Sprintf("add-generic-password -a %v -s %v -w %v -U %v\n",
+		keyReference, keychainService, encodedKey, keychainPath))
+	var output bytes.Buffer
+	command.Stdout = &output
+	command.Stderr = &output
+	if err = command.Run(); err != nil || strings.Contains(output.String(), "error") {
+		return "", "", fmt.Errorf("failed to add key to keychain: %v %v", err, strings.TrimSpace(output.String()))
+	}
+
+	publicKey, err = rsaKey.EncodePublicKey()
+	return
+}
+
+func (keychainKeyStorage) loadKey(keyReference string) (SigningKey, error) {
+	output, err := exec.Command(securityTool, "find-generic-password",
+		"-a", keyReference, "-s", keychainService, "-w", keychainPath).Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key from keychain: %v", err)
+	}
+	rsaKey, err := DecodePrivateKey(strings.TrimSpace(string(output)))
+	if err != nil {
+		return nil, err
+	}
+	return &rsaKey, nil
+}
+
+func (keychainKeyStorage) deleteKey(keyReference string) error {
+	if output, err := exec.Command(securityTool, "delete-generic-password",
+		"-a", keyReference, "-s", keychainService, keychainPath).CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to delete key from keychain: %v %v", err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
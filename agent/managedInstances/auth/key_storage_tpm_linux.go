@@ -0,0 +1,188 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// +build linux
+
+package auth
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+)
+
+// The TPM backend drives the tpm2-tools command line utilities. The key is created under an ECC primary key
+// derived from the owner hierarchy seed, and only the TPM wrapped key blobs are saved in the registration info,
+// so the private key never leaves the TPM and does not use a persistent handle.
+
+const (
+	tpmResourceManagerDevice = "/dev/tpmrm0"
+
+	// tpmKeyAttributes restricts the key to signing and keeps it inside the TPM
+	tpmKeyAttributes = "fixedtpm|fixedparent|sensitivedataorigin|userwithauth|sign"
+
+	// tpmBlobSeparator separates the base64 encoded public and private blobs of a key reference
+	tpmBlobSeparator = "."
+)
+
+var tpmDevice = tpmResourceManagerDevice
+
+// runTpmCommand runs a tpm2-tools command and returns its combined output on failure
+var runTpmCommand = func(name string, args ...string) error {
+	if output, err := exec.Command(name, args...).CombinedOutput(); err != nil {
+		return fmt.Errorf("%v failed: %v %v", name, err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+var lookPath = exec.LookPath
+
+func init() {
+	keyStorages[appconfig.PrivateKeyStorageTpm] = tpmKeyStorage{}
+}
+
+type tpmKeyStorage struct{}
+
+// tpmKey signs with a key loaded into the TPM for each signature
+type tpmKey struct {
+	publicBlob  []byte
+	privateBlob []byte
+}
+
+func (tpmKeyStorage) isAvailable() bool {
+	if _, err := os.Stat(tpmDevice); err != nil {
+		return false
+	}
+	for _, tool := range []string{"tpm2_createprimary", "tpm2_create", "tpm2_load", "tpm2_readpublic", "tpm2_sign"} {
+		if _, err := lookPath(tool); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+func (tpmKeyStorage) createKey() (publicKey string, keyReference string, err error) {
+	workDir, err := ioutil.TempDir("", "ssm-tpm")
+	if err != nil {
+		return
+	}
+	defer os.RemoveAll(workDir)
+
+	primaryContext := filepath.Join(workDir, "primary.ctx")
+	publicBlob := filepath.Join(workDir, "key.pub")
+	privateBlob := filepath.Join(workDir, "key.priv")
+	keyContext := filepath.Join(workDir, "key.ctx")
+	publicKeyDer := filepath.Join(workDir, "key.der")
+
+	if err = createTpmPrimary(primaryContext); err != nil {
+		return
+	}
+	if err = runTpmCommand("tpm2_create", "-C", primaryContext, "-G", "rsa2048", "-a", tpmKeyAttributes,
+		"-u", publicBlob, "-r", privateBlob); err != nil {
+		return
+	}
+	if err = runTpmCommand("tpm2_load", "-C", primaryContext, "-u", publicBlob, "-r", privateBlob, "-c", keyContext); err != nil {
+		return
+	}
+	if err = runTpmCommand("tpm2_readpublic", "-c", keyContext, "-f", "der", "-o", publicKeyDer); err != nil {
+		return
+	}
+
+	var publicKeyBytes, publicBlobBytes, privateBlobBytes []byte
+	if publicKeyBytes, err = ioutil.ReadFile(publicKeyDer); err != nil {
+		return
+	}
+	if publicBlobBytes, err = ioutil.ReadFile(publicBlob); err != nil {
+		return
+	}
+	if privateBlobBytes, err = ioutil.ReadFile(privateBlob); err != nil {
+		return
+	}
+
+	publicKey = base64.StdEncoding.EncodeToString(publicKeyBytes)
+	keyReference = base64.StdEncoding.EncodeToString(publicBlobBytes) + tpmBlobSeparator + base64.StdEncoding.EncodeToString(privateBlobBytes)
+	return
+}
+
+func (tpmKeyStorage) loadKey(keyReference string) (SigningKey, error) {
+	blobs := strings.Split(keyReference, tpmBlobSeparator)
+	if len(blobs) != 2 {
+		return nil, fmt.Errorf("invalid TPM key reference")
+	}
+	publicBlob, err := base64.StdEncoding.DecodeString(blobs[0])
+	if err != nil {
+		return nil, err
+	}
+	privateBlob, err := base64.StdEncoding.DecodeString(blobs[1])
+	if err != nil {
+		return nil, err
+	}
+	return &tpmKey{publicBlob: publicBlob, privateBlob: privateBlob}, nil
+}
+
+// deleteKey has nothing to remove, the key only exists as blobs wrapped by the TPM
+func (tpmKeyStorage) deleteKey(keyReference string) error { return nil }
+
+// Sign creates an RSASSA-PSS SHA256 signature of the message, matching RsaKey.Sign
+func (key *tpmKey) Sign(message string) (signature string, err error) {
+	workDir, err := ioutil.TempDir("", "ssm-tpm")
+	if err != nil {
+		return
+	}
+	defer os.RemoveAll(workDir)
+
+	primaryContext := filepath.Join(workDir, "primary.ctx")
+	publicBlob := filepath.Join(workDir, "key.pub")
+	privateBlob := filepath.Join(workDir, "key.priv")
+	keyContext := filepath.Join(workDir, "key.ctx")
+	messageFile := filepath.Join(workDir, "message")
+	signatureFile := filepath.Join(workDir, "signature")
+
+	if err = ioutil.WriteFile(publicBlob, key.publicBlob, appconfig.ReadWriteAccess); err != nil {
+		return
+	}
+	if err = ioutil.WriteFile(privateBlob, key.privateBlob, appconfig.ReadWriteAccess); err != nil {
+		return
+	}
+	if err = ioutil.WriteFile(messageFile, []byte(message), appconfig.ReadWriteAccess); err != nil {
+		return
+	}
+
+	if err = createTpmPrimary(primaryContext); err != nil {
+		return
+	}
+	if err = runTpmCommand("tpm2_load", "-C", primaryContext, "-u", publicBlob, "-r", privateBlob, "-c", keyContext); err != nil {
+		return
+	}
+	if err = runTpmCommand("tpm2_sign", "-c", keyContext, "-g", "sha256", "-s", "rsapss", "-f", "plain",
+		"-o", signatureFile, messageFile); err != nil {
+		return
+	}
+
+	signatureBytes, err := ioutil.ReadFile(signatureFile)
+	if err != nil {
+		return
+	}
+	return base64.StdEncoding.EncodeToString(signatureBytes), nil
+}
+
+// createTpmPrimary recreates the storage primary key, which is the same for every call since it is derived from the hierarchy seed
+func createTpmPrimary(primaryContext string) error {
+	return runTpmCommand("tpm2_createprimary", "-C", "o", "-G", "ecc", "-g", "sha256", "-c", primaryContext)
+}
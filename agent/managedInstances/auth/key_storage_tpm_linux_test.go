@@ -0,0 +1,80 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// +build linux
+
+package auth
+
+import (
+	"encoding/base64"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeTpmCommand records the tpm2-tools invocations and writes the files they would produce
+func fakeTpmCommand(commands *[]string) func(name string, args ...string) error {
+	return func(name string, args ...string) error {
+		*commands = append(*commands, name)
+		for i := 0; i < len(args)-1; i++ {
+			switch args[i] {
+			case "-u":
+				if name == "tpm2_create" {
+					ioutil.WriteFile(args[i+1], []byte("public blob"), 0600)
+				}
+			case "-r":
+				if name == "tpm2_create" {
+					ioutil.WriteFile(args[i+1], []byte("private blob"), 0600)
+				}
+			case "-o":
+				if name == "tpm2_readpublic" {
+					ioutil.WriteFile(args[i+1], []byte("public key"), 0600)
+				} else {
+					ioutil.WriteFile(args[i+1], []byte("signature"), 0600)
+				}
+			}
+		}
+		return nil
+	}
+}
+
+func TestTpmKeyStorage(t *testing.T) {
+	var commands []string
+	runTpmCommand = fakeTpmCommand(&commands)
+
+	publicKey, keyReference, err := tpmKeyStorage{}.createKey()
+	assert.NoError(t, err)
+	assert.Equal(t, base64.StdEncoding.EncodeToString([]byte("public key")), publicKey)
+	assert.Equal(t, base64.StdEncoding.EncodeToString([]byte("public blob"))+"."+
+		base64.StdEncoding.EncodeToString([]byte("private blob")), keyReference)
+	assert.Equal(t, []string{"tpm2_createprimary", "tpm2_create", "tpm2_load", "tpm2_readpublic"}, commands)
+
+	commands = nil
+	signingKey, err := tpmKeyStorage{}.loadKey(keyReference)
+	assert.NoError(t, err)
+	signature, err := signingKey.Sign("message")
+	assert.NoError(t, err)
+	assert.Equal(t, base64.StdEncoding.EncodeToString([]byte("signature")), signature)
+	assert.Equal(t, []string{"tpm2_createprimary", "tpm2_load", "tpm2_sign"}, commands)
+
+	_, err = tpmKeyStorage{}.loadKey("invalid")
+	assert.Error(t, err)
+}
+
+func TestTpmKeyStorageAvailability(t *testing.T) {
+	tpmDevice = "/nonexistent/tpmrm0"
+	defer func() { tpmDevice = tpmResourceManagerDevice }()
+
+	assert.False(t, tpmKeyStorage{}.isAvailable())
+}
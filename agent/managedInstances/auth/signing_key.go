@@ -0,0 +1,151 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package auth
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+)
+
+// The private key saved in the registration info is either the base64 DER encoded key for file storage,
+// or "<storage>:<reference>" for keys held by a hardware or operating system backed key storage.
+const keyReferenceSeparator = ":"
+
+// SigningKey signs the requests sent to the managed instance auth service
+type SigningKey interface {
+	Sign(message string) (signature string, err error)
+}
+
+// keyStorage creates and loads managed instance keys in one storage backend
+type keyStorage interface {
+	// isAvailable returns true if the backend can be used on this machine
+	isAvailable() bool
+
+	// createKey creates an RSA key and returns its base64 DER encoded public key and the reference used to load it
+	createKey() (publicKey string, keyReference string, err error)
+
+	// loadKey returns the signing key for a reference returned by createKey
+	loadKey(keyReference string) (SigningKey, error)
+
+	// deleteKey removes a key that is no longer used from the backend
+	deleteKey(keyReference string) error
+}
+
+// keyStorages holds the backends supported on this platform, backends other than file are registered by platform files
+var keyStorages = map[string]keyStorage{
+	appconfig.PrivateKeyStorageFile: fileKeyStorage{},
+}
+
+// preferredKeyStorages is the order PrivateKeyStorageAuto tries backends in
+var preferredKeyStorages = []string{
+	appconfig.PrivateKeyStorageTpm,
+	appconfig.PrivateKeyStorageOs,
+	appconfig.PrivateKeyStorageFile,
+}
+
+// ResolveKeyStorage returns the backend new keys are created in for the configured storage.
+// Auto picks the first available of tpm, os and file, a configured backend that is not available falls back to file.
+func ResolveKeyStorage(configured string) string {
+	if configured == appconfig.PrivateKeyStorageAuto {
+		for _, storage := range preferredKeyStorages {
+			if backend, ok := keyStorages[storage]; ok && backend.isAvailable() {
+				return storage
+			}
+		}
+	}
+	if backend, ok := keyStorages[configured]; ok && backend.isAvailable() {
+		return configured
+	}
+	return appconfig.PrivateKeyStorageFile
+}
+
+// CreateKey creates a managed instance key in the given storage backend and returns the
+// base64 DER encoded public key and the private key value to save in the registration info
+func CreateKey(storage string) (publicKey string, privateKey string, err error) {
+	backend, ok := keyStorages[storage]
+	if !ok {
+		return "", "", fmt.Errorf("private key storage %v is not supported on this platform", storage)
+	}
+	var keyReference string
+	if publicKey, keyReference, err = backend.createKey(); err != nil {
+		return "", "", fmt.Errorf("failed to create key in %v storage: %v", storage, err)
+	}
+	if storage == appconfig.PrivateKeyStorageFile {
+		return publicKey, keyReference, nil
+	}
+	return publicKey, storage + keyReferenceSeparator + keyReference, nil
+}
+
+// LoadSigningKey returns the signing key for a private key value saved in the registration info
+func LoadSigningKey(privateKey string) (SigningKey, error) {
+	storage, keyReference := splitPrivateKey(privateKey)
+	backend, ok := keyStorages[storage]
+	if !ok {
+		return nil, fmt.Errorf("private key storage %v is not supported on this platform", storage)
+	}
+	return backend.loadKey(keyReference)
+}
+
+// DeleteKey removes a private key that was replaced from its storage backend
+func DeleteKey(privateKey string) error {
+	storage, keyReference := splitPrivateKey(privateKey)
+	if backend, ok := keyStorages[storage]; ok {
+		return backend.deleteKey(keyReference)
+	}
+	return nil
+}
+
+// KeyStorageOf returns the storage backend holding a private key saved in the registration info
+func KeyStorageOf(privateKey string) string {
+	storage, _ := splitPrivateKey(privateKey)
+	return storage
+}
+
+// splitPrivateKey splits a private key value into its storage backend and reference.
+// The base64 alphabet does not contain the separator so file keys are never mistaken for references.
+func splitPrivateKey(privateKey string) (storage string, keyReference string) {
+	if parts := strings.SplitN(privateKey, keyReferenceSeparator, 2); len(parts) == 2 {
+		return parts[0], parts[1]
+	}
+	return appconfig.PrivateKeyStorageFile, privateKey
+}
+
+// fileKeyStorage keeps the private key in the registration info, this is the original behavior of the agent
+type fileKeyStorage struct{}
+
+func (fileKeyStorage) isAvailable() bool { return true }
+
+func (fileKeyStorage) createKey() (publicKey string, keyReference string, err error) {
+	var rsaKey RsaKey
+	if rsaKey, err = CreateKeypair(); err != nil {
+		return
+	}
+	if keyReference, err = rsaKey.EncodePrivateKey(); err != nil {
+		return
+	}
+	publicKey, err = rsaKey.EncodePublicKey()
+	return
+}
+
+func (fileKeyStorage) loadKey(keyReference string) (SigningKey, error) {
+	rsaKey, err := DecodePrivateKey(keyReference)
+	if err != nil {
+		return nil, err
+	}
+	return &rsaKey, nil
+}
+
+func (fileKeyStorage) deleteKey(keyReference string) error { return nil }
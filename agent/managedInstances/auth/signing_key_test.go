@@ -0,0 +1,98 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package auth
+
+import (
+	"testing"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/stretchr/testify/assert"
+)
+
+const testKeyStorage = "test"
+
+type keyStorageStub struct {
+	available bool
+	deleted   []string
+}
+
+func (k *keyStorageStub) isAvailable() bool { return k.available }
+
+func (k *keyStorageStub) createKey() (string, string, error) { return "publicKey", "keyReference", nil }
+
+func (k *keyStorageStub) loadKey(keyReference string) (SigningKey, error) {
+	rsaKey, err := CreateKeypair()
+	return &rsaKey, err
+}
+
+func (k *keyStorageStub) deleteKey(keyReference string) error {
+	k.deleted = append(k.deleted, keyReference)
+	return nil
+}
+
+func TestFileKeyStorageSignVerify(t *testing.T) {
+	publicKey, privateKey, err := CreateKey(appconfig.PrivateKeyStorageFile)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, publicKey)
+	assert.Equal(t, appconfig.PrivateKeyStorageFile, KeyStorageOf(privateKey))
+
+	signingKey, err := LoadSigningKey(privateKey)
+	assert.NoError(t, err)
+	signature, err := signingKey.Sign("message")
+	assert.NoError(t, err)
+
+	rsaKey, err := DecodePrivateKey(privateKey)
+	assert.NoError(t, err)
+	assert.NoError(t, rsaKey.VerifySignature("message", signature))
+}
+
+func TestCreateKeyReference(t *testing.T) {
+	stub := &keyStorageStub{available: true}
+	keyStorages[testKeyStorage] = stub
+	defer delete(keyStorages, testKeyStorage)
+
+	publicKey, privateKey, err := CreateKey(testKeyStorage)
+	assert.NoError(t, err)
+	assert.Equal(t, "publicKey", publicKey)
+	assert.Equal(t, "test:keyReference", privateKey)
+	assert.Equal(t, testKeyStorage, KeyStorageOf(privateKey))
+
+	_, err = LoadSigningKey(privateKey)
+	assert.NoError(t, err)
+	assert.NoError(t, DeleteKey(privateKey))
+	assert.Equal(t, []string{"keyReference"}, stub.deleted)
+
+	_, err = LoadSigningKey("unknown:keyReference")
+	assert.Error(t, err)
+	_, _, err = CreateKey("unknown")
+	assert.Error(t, err)
+}
+
+func TestResolveKeyStorage(t *testing.T) {
+	savedStorages, savedPreferred := keyStorages, preferredKeyStorages
+	defer func() { keyStorages, preferredKeyStorages = savedStorages, savedPreferred }()
+
+	keyStorages = map[string]keyStorage{
+		appconfig.PrivateKeyStorageFile: fileKeyStorage{},
+		appconfig.PrivateKeyStorageTpm:  &keyStorageStub{available: false},
+		appconfig.PrivateKeyStorageOs:   &keyStorageStub{available: true},
+	}
+	assert.Equal(t, appconfig.PrivateKeyStorageOs, ResolveKeyStorage(appconfig.PrivateKeyStorageAuto))
+	assert.Equal(t, appconfig.PrivateKeyStorageOs, ResolveKeyStorage(appconfig.PrivateKeyStorageOs))
+	assert.Equal(t, appconfig.PrivateKeyStorageFile, ResolveKeyStorage(appconfig.PrivateKeyStorageTpm))
+	assert.Equal(t, appconfig.PrivateKeyStorageFile, ResolveKeyStorage(appconfig.PrivateKeyStorageFile))
+
+	delete(keyStorages, appconfig.PrivateKeyStorageOs)
+	assert.Equal(t, appconfig.PrivateKeyStorageFile, ResolveKeyStorage(appconfig.PrivateKeyStorageAuto))
+}
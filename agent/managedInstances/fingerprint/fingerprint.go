@@ -17,6 +17,8 @@
 package fingerprint
 
 import (
+	"bytes"
+	"context"
 	"crypto/md5"
 	"encoding/base64"
 	"encoding/json"
@@ -25,9 +27,11 @@ import (
 	"net"
 	"os"
 	"os/exec"
+	"strings"
 	"time"
 	"unicode/utf8"
 
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
 	"github.com/aws/amazon-ssm-agent/agent/log"
 	"github.com/aws/amazon-ssm-agent/agent/log/ssmlog"
 	"github.com/twinj/uuid"
@@ -43,10 +47,14 @@ const (
 	minimumMatchPercent = 40
 	vaultKey            = "InstanceFingerprint"
 	ipAddressID         = "ipaddress-info"
+	identityHookID      = "identity-hook"
+	identityHookTimeout = 30 * time.Second
 )
 
 var (
-	fingerprint string
+	fingerprint     string
+	getAppConfig    = appconfig.Config
+	runIdentityHook = identityFromHook
 )
 
 func InstanceFingerprint() (string, error) {
@@ -84,6 +92,43 @@ func SetSimilarityThreshold(value int) (err error) {
 	return nil
 }
 
+// RotateFingerprint replaces the saved fingerprint with a new one for the current hardware,
+// so a cloned machine stops sharing the fingerprint of the machine it was cloned from
+func RotateFingerprint() (string, error) {
+	lock.Lock()
+	defer lock.Unlock()
+
+	hardwareHash, err := machineIdentity()
+	if err != nil {
+		return "", fmt.Errorf("Error while fetching hardware hashes from instance: %v", err)
+	} else if !isValidHardwareHash(hardwareHash) {
+		return "", fmt.Errorf("Hardware hash generated contains invalid characters. %s", hardwareHash)
+	}
+
+	savedHwInfo, err := fetch()
+	if err != nil {
+		return "", err
+	}
+	threshold := minimumMatchPercent
+	if savedHwInfo.SimilarityThreshold > 0 {
+		threshold = savedHwInfo.SimilarityThreshold
+	}
+
+	uuid.SwitchFormat(uuid.CleanHyphen)
+	updatedHwInfo := hwInfo{
+		Fingerprint:         uuid.NewV4().String(),
+		HardwareHash:        hardwareHash,
+		SimilarityThreshold: threshold,
+	}
+	if err = save(updatedHwInfo); err != nil {
+		return "", fmt.Errorf("Error while saving fingerprint data to vault: %v", err)
+	}
+
+	fingerprint = updatedHwInfo.Fingerprint
+	loaded = true
+	return fingerprint, nil
+}
+
 // generateFingerprint generates new fingerprint and saves it in the vault
 func generateFingerprint() (string, error) {
 	var hardwareHash map[string]string
@@ -99,7 +144,7 @@ func generateFingerprint() (string, error) {
 	// retry getting the new hash and compare with the saved hash for 3 times
 	for attempt := 1; attempt <= 3; attempt++ {
 		// fetch current hardware hash values
-		hardwareHash, hwHashErr = currentHwHash()
+		hardwareHash, hwHashErr = machineIdentity()
 
 		if hwHashErr != nil || !isValidHardwareHash(hardwareHash) {
 			// sleep 5 seconds until the next retry
@@ -117,6 +162,11 @@ func generateFingerprint() (string, error) {
 			threshold = savedHwInfo.SimilarityThreshold
 		}
 
+		// the threshold in the agent config takes precedence over the saved one
+		if config, err := getAppConfig(false); err == nil && config.Fingerprint.SimilarityThreshold > 0 {
+			threshold = config.Fingerprint.SimilarityThreshold
+		}
+
 		// first time generation, breakout retry
 		if !hasFingerprint(savedHwInfo) {
 			log.Debugf("No initial fingerprint detected, skipping retry...")
@@ -154,6 +204,11 @@ func generateFingerprint() (string, error) {
 	} else if !isSimilarHardwareHash(log, savedHwInfo.HardwareHash, hardwareHash, threshold) {
 		log.Info("Calculated hardware difference, regenerating fingerprint...")
 		result = uuid.NewV4().String()
+	} else if hasIdentity(hardwareHash) && !hasIdentity(savedHwInfo.HardwareHash) {
+		// the fingerprint was saved before the identity hook was configured and matched on the hardware,
+		// it is saved again with the identity the next comparisons use
+		log.Info("Saving the fingerprint with the identity of the identity hook...")
+		result = savedHwInfo.Fingerprint
 	} else {
 		result = savedHwInfo.Fingerprint
 		return result, nil
@@ -229,6 +284,25 @@ func isSimilarHardwareHash(logger log.T, savedHwHash map[string]string, currentH
 		return false
 	}
 
+	// the identity returned by the identity hook replaces the hardware comparison,
+	// the hardware is compared when the saved hash has no identity yet
+	if hasIdentity(currentHwHash) && hasIdentity(savedHwHash) {
+		return currentHwHash[identityHookID] == savedHwHash[identityHookID]
+	}
+	if hasIdentity(currentHwHash) {
+		hardwareHash := make(map[string]string, len(currentHwHash))
+		for key, value := range currentHwHash {
+			if key != identityHookID {
+				hardwareHash[key] = value
+			}
+		}
+		currentHwHash = hardwareHash
+		if len(currentHwHash) == 0 {
+			logger.Debugf("current hash has no hardware to compare with the saved hash")
+			return false
+		}
+	}
+
 	// check whether hardwareId (uuid/machineid) has changed
 	// this usually happens during provisioning
 	if currentHwHash[hardwareID] != savedHwHash[hardwareID] {
@@ -263,6 +337,51 @@ func isSimilarHardwareHash(logger log.T, savedHwHash map[string]string, currentH
 	return true
 }
 
+// hasIdentity returns true when the hash carries the identity returned by the identity hook
+func hasIdentity(hwHash map[string]string) bool {
+	_, ok := hwHash[identityHookID]
+	return ok
+}
+
+// machineIdentity returns the hardware hash of the instance, with the hash of the identity hook output when an
+// identity hook is configured. The hardware is kept so a fingerprint saved before the hook was configured still matches.
+func machineIdentity() (map[string]string, error) {
+	config, err := getAppConfig(false)
+	if err != nil || config.Fingerprint.IdentityHook == "" {
+		return currentHwHash()
+	}
+
+	identity, err := runIdentityHook(config.Fingerprint.IdentityHook)
+	if err != nil {
+		return nil, fmt.Errorf("identity hook failed: %v", err)
+	}
+	if identity == "" {
+		return nil, fmt.Errorf("identity hook returned an empty identity")
+	}
+	hwHash, err := currentHwHash()
+	if err != nil {
+		hwHash = map[string]string{}
+	}
+	sum := md5.Sum([]byte(identity))
+	hwHash[identityHookID] = base64.StdEncoding.EncodeToString(sum[:])
+	return hwHash, nil
+}
+
+// identityFromHook runs the identity hook and returns its trimmed standard output
+func identityFromHook(hook string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), identityHookTimeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	command := exec.CommandContext(ctx, hook)
+	command.Stdout = &stdout
+	command.Stderr = &stderr
+	if err := command.Run(); err != nil {
+		return "", fmt.Errorf("%v %v", err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
+
 func hostnameInfo() (value string, err error) {
 	return os.Hostname()
 }
@@ -18,9 +18,11 @@ import (
 	"fmt"
 	"testing"
 
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
 	"github.com/aws/amazon-ssm-agent/agent/log"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 )
 
 const (
@@ -87,6 +89,15 @@ func TestIsSimilarHardwareHash(t *testing.T) {
 	somethingElseChanged := deepCopy(origin)
 	somethingElseChanged["somethingElse"] = "somethingElseValueChanged"
 
+	identity := map[string]string{identityHookID: "identityValue"}
+	identityChanged := map[string]string{identityHookID: "identityValueChanged"}
+
+	withIdentity := deepCopy(origin)
+	withIdentity[identityHookID] = "identityValue"
+
+	hwChangedWithIdentity := deepCopy(hwChanged)
+	hwChangedWithIdentity[identityHookID] = "identityValue"
+
 	testData := []isSimilarHashTestData{
 		{origin, empty, 0, false},
 		{empty, origin, 0, false},
@@ -97,6 +108,12 @@ func TestIsSimilarHardwareHash(t *testing.T) {
 		{origin, ipAndElseChanged, 33, true},  // 1 out of 3 items matched > 33%
 		{origin, ipAndElseChanged, 34, false}, // 1 out of 3 items matched < 34%
 		{origin, somethingElseChanged, 100, true},
+		{identity, identity, 100, true},
+		{identity, identityChanged, 0, false},
+		{origin, identity, 0, false},
+		{origin, withIdentity, 100, true},         // the hardware is compared when the saved hash has no identity
+		{origin, hwChangedWithIdentity, 0, false}, // and must still match
+		{withIdentity, hwChangedWithIdentity, 0, true},
 	}
 
 	for _, test := range testData {
@@ -262,6 +279,136 @@ func TestGenerateFingerprint_DoesNotSave_WhenHardwareHashesMatch(t *testing.T) {
 	generateFingerprint()
 }
 
+func TestGenerateFingerprint_UsesConfiguredThreshold(t *testing.T) {
+	// Arrange
+	savedHwHash := map[string]string{
+		hardwareID:  "original",
+		ipAddressID: "ipAddress",
+		"disk":      "disk",
+	}
+	currentHwHash = func() (map[string]string, error) {
+		return map[string]string{
+			hardwareID:  "original",
+			ipAddressID: "ipAddressChanged",
+			"disk":      "disk",
+		}, nil
+	}
+	savedHwData, _ := json.Marshal(&hwInfo{
+		HardwareHash:        savedHwHash,
+		Fingerprint:         sampleFingerprint,
+		SimilarityThreshold: minimumMatchPercent,
+	})
+	vault = vaultStub{rKey: vaultKey, data: savedHwData}
+
+	config := appconfig.DefaultConfig()
+	config.Fingerprint.SimilarityThreshold = 100
+	getAppConfig = func(bool) (appconfig.SsmagentConfig, error) { return config, nil }
+	defer func() { getAppConfig = appconfig.Config }()
+
+	// Act
+	fingerprint, err := generateFingerprint()
+
+	// Assert
+	assert.NoError(t, err)
+	assert.NotEqual(t, sampleFingerprint, fingerprint)
+}
+
+func TestMachineIdentity_UsesIdentityHook(t *testing.T) {
+	// Arrange
+	config := appconfig.DefaultConfig()
+	config.Fingerprint.IdentityHook = "/usr/local/bin/vdi-identity"
+	getAppConfig = func(bool) (appconfig.SsmagentConfig, error) { return config, nil }
+	runIdentityHook = func(hook string) (string, error) { return "desktop-42", nil }
+	defer func() {
+		getAppConfig = appconfig.Config
+		runIdentityHook = identityFromHook
+	}()
+
+	currentHwHash = func() (map[string]string, error) {
+		return getHwHash("original"), nil
+	}
+
+	// Act
+	identity, err := machineIdentity()
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, "original", identity[hardwareID])
+	assert.NotEmpty(t, identity[identityHookID])
+
+	runIdentityHook = func(hook string) (string, error) { return "", nil }
+	_, err = machineIdentity()
+	assert.Error(t, err)
+}
+
+func TestGenerateFingerprint_SavesIdentity_WhenSavedBeforeIdentityHook(t *testing.T) {
+	// Arrange
+	currentHwHash = func() (map[string]string, error) {
+		return getHwHash("original"), nil
+	}
+	savedHwData, _ := json.Marshal(&hwInfo{
+		HardwareHash:        getHwHash("original"),
+		Fingerprint:         sampleFingerprint,
+		SimilarityThreshold: minimumMatchPercent,
+	})
+	vaultMock := &fpFsVaultMock{}
+	vaultMock.On("Retrieve", vaultKey).Return(savedHwData, nil)
+	vaultMock.On("Store", vaultKey, mock.MatchedBy(func(data []byte) bool {
+		var info hwInfo
+		return json.Unmarshal(data, &info) == nil &&
+			info.Fingerprint == sampleFingerprint &&
+			info.HardwareHash[identityHookID] != ""
+	})).Return(nil).Once()
+	vault = vaultMock
+
+	config := appconfig.DefaultConfig()
+	config.Fingerprint.IdentityHook = "/usr/local/bin/vdi-identity"
+	getAppConfig = func(bool) (appconfig.SsmagentConfig, error) { return config, nil }
+	runIdentityHook = func(hook string) (string, error) { return "desktop-42", nil }
+	defer func() {
+		getAppConfig = appconfig.Config
+		runIdentityHook = identityFromHook
+	}()
+
+	// Act
+	fingerprint, err := generateFingerprint()
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, sampleFingerprint, fingerprint)
+	vaultMock.AssertExpectations(t)
+}
+
+func TestRotateFingerprint(t *testing.T) {
+	// Arrange
+	currentHwHash = func() (map[string]string, error) {
+		return getHwHash("original"), nil
+	}
+	savedHwData, _ := json.Marshal(&hwInfo{
+		HardwareHash:        getHwHash("original"),
+		Fingerprint:         sampleFingerprint,
+		SimilarityThreshold: 60,
+	})
+	vaultMock := &fpFsVaultMock{}
+	vaultMock.On("Retrieve", vaultKey).Return(savedHwData, nil)
+	vaultMock.On("Store", vaultKey, mock.Anything).Return(nil)
+	vault = vaultMock
+
+	// Act
+	rotated, err := RotateFingerprint()
+
+	// Assert
+	assert.NoError(t, err)
+	assert.NotEqual(t, sampleFingerprint, rotated)
+	var savedHwInfo hwInfo
+	json.Unmarshal(vaultMock.Calls[1].Arguments.Get(1).([]byte), &savedHwInfo)
+	assert.Equal(t, rotated, savedHwInfo.Fingerprint)
+	assert.Equal(t, 60, savedHwInfo.SimilarityThreshold)
+	current, _ := InstanceFingerprint()
+	assert.Equal(t, rotated, current)
+	setLoaded(false)
+}
+
 func TestSave_SavesNewFingerprint(t *testing.T) {
 	// Arrange
 	sampleHwHash := getHwHash("backup")
@@ -0,0 +1,53 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// package registration provides managed instance information
+package registration
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+const ActivationVaultKey = "ActivationKey"
+
+// ActivationInfo holds the activation used to register the managed instance
+type ActivationInfo struct {
+	ActivationCode string `json:"ActivationCode"`
+	ActivationId   string `json:"ActivationId"`
+	Region         string `json:"Region"`
+}
+
+// StoreActivation saves the activation in the vault so the instance can register again after it is deregistered
+func StoreActivation(activation ActivationInfo) (err error) {
+	var data []byte
+	if data, err = json.Marshal(activation); err != nil {
+		return fmt.Errorf("Failed to marshal activation info. %v", err)
+	}
+	if err = vault.Store(ActivationVaultKey, data); err != nil {
+		return fmt.Errorf("Failed to store activation info in vault. %v", err)
+	}
+	return nil
+}
+
+// StoredActivation returns the activation saved by StoreActivation
+func StoredActivation() (activation ActivationInfo, err error) {
+	var data []byte
+	if data, err = vault.Retrieve(ActivationVaultKey); err != nil {
+		return activation, fmt.Errorf("Failed to load activation info from vault. %v", err)
+	}
+	if err = json.Unmarshal(data, &activation); err != nil {
+		return activation, fmt.Errorf("Failed to unmarshal activation info. %v", err)
+	}
+	return activation, nil
+}
@@ -19,6 +19,7 @@ import (
 	"fmt"
 	"sync"
 
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
 	"github.com/aws/amazon-ssm-agent/agent/log/ssmlog"
 	"github.com/aws/amazon-ssm-agent/agent/managedInstances/auth"
 	"github.com/aws/amazon-ssm-agent/agent/managedInstances/fingerprint"
@@ -36,6 +37,7 @@ type instanceInfo struct {
 var (
 	lock             sync.RWMutex
 	loadedServerInfo instanceInfo
+	getAppConfig     = appconfig.Config
 )
 
 const (
@@ -95,11 +97,21 @@ func HasManagedInstancesCredentials() (bool, error) {
 }
 
 // UpdatePrivateKey saves the private key into the registration persistence store
+// and removes the replaced key from its key storage
 func UpdatePrivateKey(privateKey, privateKeyType string) (err error) {
 	info := getInstanceInfo()
+	previousPrivateKey := info.PrivateKey
 	info.PrivateKey = privateKey
 	info.PrivateKeyType = privateKeyType
-	return updateServerInfo(info)
+	if err = updateServerInfo(info); err != nil {
+		return
+	}
+	if previousPrivateKey != "" && previousPrivateKey != privateKey {
+		if deleteErr := auth.DeleteKey(previousPrivateKey); deleteErr != nil {
+			ssmlog.SSMLogger(false).Warnf("Failed to remove the replaced private key: %v", deleteErr)
+		}
+	}
+	return
 }
 
 // UpdateServerInfo saves the instance info into the registration persistence store
@@ -113,27 +125,30 @@ func UpdateServerInfo(instanceID, region, privateKey, privateKeyType string) (er
 	return updateServerInfo(info)
 }
 
-// GenerateKeyPair generate a new keypair
+// GenerateKeyPair generate a new keypair in the configured private key storage
 func GenerateKeyPair() (publicKey, privateKey, keyType string, err error) {
-	var keyPair auth.RsaKey
-
-	keyPair, err = auth.CreateKeypair()
+	publicKey, privateKey, err = auth.CreateKey(configuredKeyStorage())
 	if err != nil {
 		return
 	}
 
-	privateKey, err = keyPair.EncodePrivateKey()
-	if err != nil {
-		return
-	}
+	keyType = auth.KeyType
+	return
+}
+
+// ShouldMigratePrivateKey returns true when the private key is not held by the configured private key storage
+func ShouldMigratePrivateKey() bool {
+	privateKey := PrivateKey()
+	return privateKey != "" && auth.KeyStorageOf(privateKey) != configuredKeyStorage()
+}
 
-	publicKey, err = keyPair.EncodePublicKey()
+// configuredKeyStorage returns the storage backend new private keys are created in
+func configuredKeyStorage() string {
+	config, err := getAppConfig(false)
 	if err != nil {
-		return
+		return appconfig.PrivateKeyStorageFile
 	}
-
-	keyType = auth.KeyType
-	return
+	return auth.ResolveKeyStorage(config.Registration.PrivateKeyStorage)
 }
 
 func updateServerInfo(info instanceInfo) (err error) {
@@ -0,0 +1,275 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package reregistration registers a managed instance again after its registration was removed from SSM.
+package reregistration
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/fileutil"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/managedInstances/registration"
+	"github.com/aws/amazon-ssm-agent/agent/platform"
+	"github.com/aws/amazon-ssm-agent/agent/ssm/anonauth"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+)
+
+// Reregistration states reported in the state file
+const (
+	// StatusDeregistered means the registration was removed and automatic reregistration is disabled
+	StatusDeregistered = "Deregistered"
+
+	// StatusReregistering means a reregistration attempt failed and will be retried
+	StatusReregistering = "Reregistering"
+
+	// StatusReregistered means the instance was registered again
+	StatusReregistered = "Reregistered"
+
+	// StatusFailed means every reregistration attempt failed, the instance has to be registered manually
+	StatusFailed = "Failed"
+)
+
+const (
+	activationHookTimeout = 60 * time.Second
+	minRetryBackoff       = 1 * time.Minute
+	maxRetryBackoff       = 30 * time.Minute
+)
+
+// deregisteredErrorCodes are the errors returned by the managed instance auth service once the instance is deregistered
+var deregisteredErrorCodes = []string{"InvalidInstanceId", "InvalidManagedInstanceId"}
+
+// State is the reregistration state saved in the state file
+type State struct {
+	Status          string
+	InstanceID      string
+	Attempts        int
+	LastAttemptTime time.Time
+	LastError       string
+}
+
+var (
+	lock              sync.Mutex
+	stateFile         = filepath.Join(appconfig.DefaultDataStorePath, "reregistration")
+	registrationFile  = filepath.Join(appconfig.DefaultDataStorePath, "registration")
+	getAppConfig      = appconfig.Config
+	newAnonService    = anonauth.NewAnonymousService
+	runActivationHook = activationFromHook
+	timeNow           = time.Now
+)
+
+// IsDeregisteredError returns true if the error means the managed instance registration was removed from SSM
+func IsDeregisteredError(err error) bool {
+	if aErr, ok := err.(awserr.Error); ok {
+		for _, code := range deregisteredErrorCodes {
+			if aErr.Code() == code {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Reregister registers the deregistered managed instance again, with the activation saved during registration
+// or the one returned by the configured activation hook. Each call makes at most one attempt, attempts are
+// spaced with an exponential backoff and stop after the configured maximum number of attempts.
+// It returns the new managed instance id and private key.
+func Reregister(log log.T) (instanceID string, privateKey string, err error) {
+	lock.Lock()
+	defer lock.Unlock()
+
+	config, err := getAppConfig(false)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to load agent config: %v", err)
+	}
+
+	deregisteredID := managedInstance.InstanceID()
+	state := loadState(log)
+	if state.InstanceID != deregisteredID {
+		// the instance was registered again since the last attempts
+		state = State{InstanceID: deregisteredID}
+	}
+
+	if !config.Registration.AutoReregister {
+		if state.Status != StatusDeregistered {
+			log.Errorf("Managed instance %v was deregistered. Register the instance with a new activation, "+
+				"or enable Registration.AutoReregister in the agent config", deregisteredID)
+			state.Status = StatusDeregistered
+			saveState(log, state)
+		}
+		return "", "", fmt.Errorf("managed instance %v was deregistered and automatic reregistration is disabled", deregisteredID)
+	}
+
+	if state.Status == StatusFailed {
+		return "", "", fmt.Errorf("reregistration of managed instance %v failed %v times, last error: %v",
+			deregisteredID, state.Attempts, state.LastError)
+	}
+	if state.Attempts > 0 {
+		if nextAttempt := state.LastAttemptTime.Add(retryBackoff(state.Attempts)); timeNow().Before(nextAttempt) {
+			return "", "", fmt.Errorf("managed instance %v is deregistered, next reregistration attempt at %v",
+				deregisteredID, nextAttempt.Format(time.RFC3339))
+		}
+	}
+
+	log.Infof("Managed instance %v was deregistered, reregistering (attempt %v of %v)",
+		deregisteredID, state.Attempts+1, config.Registration.MaxReregistrationAttempts)
+	state.Attempts++
+	state.LastAttemptTime = timeNow()
+
+	if instanceID, privateKey, err = register(config); err != nil {
+		state.LastError = err.Error()
+		state.Status = StatusReregistering
+		if state.Attempts >= config.Registration.MaxReregistrationAttempts {
+			state.Status = StatusFailed
+			log.Errorf("Reregistration failed %v times, giving up. Register the instance manually. Last error: %v", state.Attempts, err)
+		} else {
+			log.Warnf("Reregistration attempt failed: %v", err)
+		}
+		saveState(log, state)
+		return "", "", fmt.Errorf("reregistration failed: %v", err)
+	}
+
+	log.Infof("Successfully reregistered the instance with AWS SSM using Managed instance-id: %v", instanceID)
+	saveState(log, State{
+		Status:          StatusReregistered,
+		InstanceID:      instanceID,
+		LastAttemptTime: state.LastAttemptTime,
+	})
+	return instanceID, privateKey, nil
+}
+
+// register performs the managed instance registration with the stored activation or the activation hook
+func register(config appconfig.SsmagentConfig) (instanceID string, privateKey string, err error) {
+	var activation registration.ActivationInfo
+	if config.Registration.ActivationHook != "" {
+		if activation, err = runActivationHook(config.Registration.ActivationHook); err != nil {
+			return "", "", fmt.Errorf("activation hook failed: %v", err)
+		}
+	} else if activation, err = managedInstance.StoredActivation(); err != nil {
+		return "", "", err
+	}
+	if activation.Region == "" {
+		activation.Region = managedInstance.Region()
+	}
+	if activation.ActivationCode == "" || activation.ActivationId == "" || activation.Region == "" {
+		return "", "", fmt.Errorf("activation code, id and region are required")
+	}
+
+	publicKey, privateKey, keyType, err := managedInstance.GenerateKeyPair()
+	if err != nil {
+		return "", "", fmt.Errorf("error generating signing keys. %v", err)
+	}
+	fingerprint, err := managedInstance.Fingerprint()
+	if err != nil {
+		return "", "", fmt.Errorf("error generating instance fingerprint. %v", err)
+	}
+
+	service := newAnonService(activation.Region)
+	if instanceID, err = service.RegisterManagedInstance(
+		activation.ActivationCode,
+		activation.ActivationId,
+		publicKey,
+		keyType,
+		fingerprint,
+	); err != nil {
+		return "", "", fmt.Errorf("error registering the instance with AWS SSM. %v", err)
+	}
+
+	if err = managedInstance.UpdateServerInfo(instanceID, activation.Region, privateKey, keyType); err != nil {
+		return "", "", fmt.Errorf("error persisting the instance registration information. %v", err)
+	}
+	if err = platform.SetInstanceID(instanceID); err != nil {
+		return "", "", err
+	}
+
+	// keep the registration file read by external tooling in sync with the new registration
+	regData, _ := json.Marshal(map[string]string{
+		"ManagedInstanceID": instanceID,
+		"Region":            activation.Region,
+	})
+	if err = fileutil.WriteAllText(registrationFile, string(regData)); err != nil {
+		return "", "", fmt.Errorf("Failed to write registration info to file. %v", err)
+	}
+	return instanceID, privateKey, nil
+}
+
+// activationFromHook runs the activation hook, which prints the activation as json to its standard output
+func activationFromHook(hook string) (activation registration.ActivationInfo, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), activationHookTimeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	command := exec.CommandContext(ctx, hook)
+	command.Stdout = &stdout
+	command.Stderr = &stderr
+	if err = command.Run(); err != nil {
+		return activation, fmt.Errorf("%v %v", err, strings.TrimSpace(stderr.String()))
+	}
+	if err = json.Unmarshal(stdout.Bytes(), &activation); err != nil {
+		return activation, fmt.Errorf("invalid activation output: %v", err)
+	}
+	return activation, nil
+}
+
+// retryBackoff returns the time to wait after the given number of failed attempts
+func retryBackoff(attempts int) time.Duration {
+	backoff := minRetryBackoff
+	for i := 1; i < attempts && backoff < maxRetryBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > maxRetryBackoff {
+		return maxRetryBackoff
+	}
+	return backoff
+}
+
+// CurrentState returns the reregistration state saved in the state file
+func CurrentState(log log.T) State {
+	lock.Lock()
+	defer lock.Unlock()
+	return loadState(log)
+}
+
+func loadState(log log.T) (state State) {
+	if !fileutil.Exists(stateFile) {
+		return
+	}
+	data, err := fileutil.ReadAllText(stateFile)
+	if err == nil {
+		err = json.Unmarshal([]byte(data), &state)
+	}
+	if err != nil {
+		log.Warnf("Failed to read reregistration state: %v", err)
+		return State{}
+	}
+	return
+}
+
+func saveState(log log.T, state State) {
+	data, err := json.Marshal(state)
+	if err == nil {
+		err = fileutil.WriteAllText(stateFile, string(data))
+	}
+	if err != nil {
+		log.Warnf("Failed to save reregistration state: %v", err)
+	}
+}
@@ -0,0 +1,58 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package reregistration registers a managed instance again after its registration was removed from SSM.
+// dependencies
+package reregistration
+
+import (
+	"github.com/aws/amazon-ssm-agent/agent/managedInstances/registration"
+)
+
+// dependency for managed instance registration
+var managedInstance instanceRegistration = instanceInfo{}
+
+type instanceRegistration interface {
+	InstanceID() string
+	Region() string
+	Fingerprint() (string, error)
+	GenerateKeyPair() (string, string, string, error)
+	UpdateServerInfo(string, string, string, string) error
+	StoredActivation() (registration.ActivationInfo, error)
+}
+
+type instanceInfo struct{}
+
+// InstanceID returns the managed instance ID
+func (instanceInfo) InstanceID() string { return registration.InstanceID() }
+
+// Region returns the managed instance region
+func (instanceInfo) Region() string { return registration.Region() }
+
+// Fingerprint returns the managed instance fingerprint
+func (instanceInfo) Fingerprint() (string, error) { return registration.Fingerprint() }
+
+// GenerateKeyPair generate a new keypair
+func (instanceInfo) GenerateKeyPair() (publicKey, privateKey, keyType string, err error) {
+	return registration.GenerateKeyPair()
+}
+
+// UpdateServerInfo saves the instance info into the registration persistence store
+func (instanceInfo) UpdateServerInfo(instanceID, region, privateKey, privateKeyType string) error {
+	return registration.UpdateServerInfo(instanceID, region, privateKey, privateKeyType)
+}
+
+// StoredActivation returns the activation saved during registration
+func (instanceInfo) StoredActivation() (registration.ActivationInfo, error) {
+	return registration.StoredActivation()
+}
@@ -0,0 +1,210 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package reregistration
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/managedInstances/registration"
+	"github.com/aws/amazon-ssm-agent/agent/ssm/anonauth"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+const (
+	deregisteredID = "mi-e6c6f145e6c6f145"
+	newID          = "mi-0123456789abcdef0"
+)
+
+type ReregistrationTestSuite struct {
+	suite.Suite
+	log      log.T
+	tempDir  string
+	config   appconfig.SsmagentConfig
+	instance *instanceStub
+	service  *anonServiceStub
+	now      time.Time
+}
+
+func (suite *ReregistrationTestSuite) SetupTest() {
+	suite.log = log.NewMockLog()
+	suite.tempDir, _ = ioutil.TempDir("", "reregistration")
+	stateFile = filepath.Join(suite.tempDir, "reregistration")
+	registrationFile = filepath.Join(suite.tempDir, "registration")
+
+	suite.config = appconfig.DefaultConfig()
+	suite.config.Registration.AutoReregister = true
+	suite.config.Registration.MaxReregistrationAttempts = 2
+	getAppConfig = func(bool) (appconfig.SsmagentConfig, error) { return suite.config, nil }
+
+	suite.instance = &instanceStub{
+		instanceID: deregisteredID,
+		region:     "us-east-1",
+		activation: registration.ActivationInfo{ActivationCode: "code", ActivationId: "id"},
+	}
+	managedInstance = suite.instance
+
+	suite.service = &anonServiceStub{instanceID: newID}
+	newAnonService = func(region string) anonauth.AnonymousService {
+		suite.service.region = region
+		return suite.service
+	}
+
+	suite.now = time.Now()
+	timeNow = func() time.Time { return suite.now }
+}
+
+func (suite *ReregistrationTestSuite) TearDownTest() {
+	os.RemoveAll(suite.tempDir)
+	runActivationHook = activationFromHook
+}
+
+func (suite *ReregistrationTestSuite) TestReregisterWithStoredActivation() {
+	instanceID, privateKey, err := Reregister(suite.log)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), newID, instanceID)
+	assert.Equal(suite.T(), "privateKey", privateKey)
+	assert.Equal(suite.T(), "us-east-1", suite.service.region)
+	assert.Equal(suite.T(), "code", suite.service.activationCode)
+	assert.Equal(suite.T(), newID, suite.instance.instanceID)
+
+	state := CurrentState(suite.log)
+	assert.Equal(suite.T(), StatusReregistered, state.Status)
+	assert.Equal(suite.T(), newID, state.InstanceID)
+
+	data, _ := ioutil.ReadFile(registrationFile)
+	assert.Contains(suite.T(), string(data), newID)
+}
+
+func (suite *ReregistrationTestSuite) TestReregisterWithActivationHook() {
+	suite.config.Registration.ActivationHook = "/usr/local/bin/get-activation"
+	runActivationHook = func(hook string) (registration.ActivationInfo, error) {
+		assert.Equal(suite.T(), "/usr/local/bin/get-activation", hook)
+		return registration.ActivationInfo{ActivationCode: "hookCode", ActivationId: "hookId", Region: "eu-west-1"}, nil
+	}
+
+	instanceID, _, err := Reregister(suite.log)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), newID, instanceID)
+	assert.Equal(suite.T(), "eu-west-1", suite.service.region)
+	assert.Equal(suite.T(), "hookCode", suite.service.activationCode)
+}
+
+func (suite *ReregistrationTestSuite) TestReregisterDisabled() {
+	suite.config.Registration.AutoReregister = false
+
+	_, _, err := Reregister(suite.log)
+	assert.Error(suite.T(), err)
+	assert.Equal(suite.T(), 0, suite.service.calls)
+	assert.Equal(suite.T(), StatusDeregistered, CurrentState(suite.log).Status)
+}
+
+func (suite *ReregistrationTestSuite) TestReregisterBoundedRetries() {
+	suite.service.err = fmt.Errorf("activation expired")
+
+	_, _, err := Reregister(suite.log)
+	assert.Error(suite.T(), err)
+	state := CurrentState(suite.log)
+	assert.Equal(suite.T(), StatusReregistering, state.Status)
+	assert.Equal(suite.T(), 1, state.Attempts)
+
+	// the next attempt waits for the backoff
+	_, _, err = Reregister(suite.log)
+	assert.Error(suite.T(), err)
+	assert.Equal(suite.T(), 1, suite.service.calls)
+
+	suite.now = suite.now.Add(minRetryBackoff)
+	_, _, err = Reregister(suite.log)
+	assert.Error(suite.T(), err)
+	assert.Equal(suite.T(), 2, suite.service.calls)
+	assert.Equal(suite.T(), StatusFailed, CurrentState(suite.log).Status)
+
+	// no more attempts after the maximum is reached
+	suite.now = suite.now.Add(maxRetryBackoff)
+	_, _, err = Reregister(suite.log)
+	assert.Error(suite.T(), err)
+	assert.Equal(suite.T(), 2, suite.service.calls)
+
+	// a manual registration resets the state
+	suite.instance.instanceID = "mi-11111111111111111"
+	suite.service.err = nil
+	_, _, err = Reregister(suite.log)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 3, suite.service.calls)
+}
+
+func (suite *ReregistrationTestSuite) TestIsDeregisteredError() {
+	assert.True(suite.T(), IsDeregisteredError(awserr.New("InvalidInstanceId", "instance is not registered", nil)))
+	assert.False(suite.T(), IsDeregisteredError(awserr.New("ThrottlingException", "rate exceeded", nil)))
+	assert.False(suite.T(), IsDeregisteredError(fmt.Errorf("InvalidInstanceId")))
+}
+
+func (suite *ReregistrationTestSuite) TestRetryBackoff() {
+	assert.Equal(suite.T(), minRetryBackoff, retryBackoff(1))
+	assert.Equal(suite.T(), 4*minRetryBackoff, retryBackoff(3))
+	assert.Equal(suite.T(), maxRetryBackoff, retryBackoff(20))
+}
+
+func TestReregistrationTestSuite(t *testing.T) {
+	suite.Run(t, new(ReregistrationTestSuite))
+}
+
+// stubs
+
+type instanceStub struct {
+	instanceID string
+	region     string
+	activation registration.ActivationInfo
+}
+
+func (i *instanceStub) InstanceID() string { return i.instanceID }
+
+func (i *instanceStub) Region() string { return i.region }
+
+func (i *instanceStub) Fingerprint() (string, error) { return "fingerprint", nil }
+
+func (i *instanceStub) GenerateKeyPair() (string, string, string, error) {
+	return "publicKey", "privateKey", "Rsa", nil
+}
+
+func (i *instanceStub) UpdateServerInfo(instanceID, region, privateKey, privateKeyType string) error {
+	i.instanceID, i.region = instanceID, region
+	return nil
+}
+
+func (i *instanceStub) StoredActivation() (registration.ActivationInfo, error) {
+	return i.activation, nil
+}
+
+type anonServiceStub struct {
+	instanceID     string
+	region         string
+	activationCode string
+	calls          int
+	err            error
+}
+
+func (a *anonServiceStub) RegisterManagedInstance(activationCode, activationID, publicKey, publicKeyType, fingerprint string) (string, error) {
+	a.calls++
+	a.activationCode = activationCode
+	return a.instanceID, a.err
+}
@@ -29,6 +29,7 @@ type instanceRegistration interface {
 	Fingerprint() (string, error)
 	GenerateKeyPair() (string, string, string, error)
 	UpdatePrivateKey(string, string) error
+	ShouldMigratePrivateKey() bool
 }
 
 type instanceInfo struct{}
@@ -54,3 +55,8 @@ func (instanceInfo) GenerateKeyPair() (publicKey, privateKey, keyType string, er
 func (instanceInfo) UpdatePrivateKey(privateKey, privateKeyType string) (err error) {
 	return registration.UpdatePrivateKey(privateKey, privateKeyType)
 }
+
+// ShouldMigratePrivateKey returns true when the private key is not held by the configured key storage
+func (instanceInfo) ShouldMigratePrivateKey() bool {
+	return registration.ShouldMigratePrivateKey()
+}
@@ -21,6 +21,10 @@ import (
 	"testing"
 	"time"
 
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/managedInstances/reregistration"
+	"github.com/aws/amazon-ssm-agent/agent/ssm/rsaauth"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/service/ssm"
 	"github.com/stretchr/testify/assert"
 )
@@ -78,6 +82,88 @@ func TestRetrieve_ShouldUpdateKeyPair(t *testing.T) {
 	assert.True(t, client.updateCalled)
 }
 
+func TestRetrieve_ShouldMigratePrivateKey(t *testing.T) {
+	logger = log.NewMockLog()
+	updateKeyPair := false
+	tokenExpirationDate := time.Now().Add(1 * time.Hour)
+	managedInstance = registrationStub{
+		publicKey:  "publicKey",
+		privateKey: "tpm:privateKey",
+		keyType:    "Rsa",
+		migrate:    true,
+	}
+	client := &RsaSignedServiceStub{
+		roleResponse: ssm.RequestManagedInstanceRoleTokenOutput{
+			AccessKeyId:         &accessKeyID,
+			SecretAccessKey:     &secretAccessKey,
+			SessionToken:        &sessionToken,
+			UpdateKeyPair:       &updateKeyPair,
+			TokenExpirationDate: &tokenExpirationDate,
+		},
+	}
+	var newClientKey string
+	newRsaService = func(serverId string, region string, encodedPrivateKey string) rsaauth.RsaSignedService {
+		newClientKey = encodedPrivateKey
+		return client
+	}
+	defer func() { newRsaService = rsaauth.NewRsaService }()
+
+	testProvider := managedInstancesRoleProvider{
+		Client: client,
+	}
+	_, err := testProvider.Retrieve()
+	assert.NoError(t, err)
+	assert.True(t, client.updateCalled)
+	assert.Equal(t, "tpm:privateKey", newClientKey)
+}
+
+func TestRetrieve_ShouldReregisterWhenDeregistered(t *testing.T) {
+	logger = log.NewMockLog()
+	updateKeyPair := false
+	tokenExpirationDate := time.Now().Add(1 * time.Hour)
+	managedInstance = registrationStub{region: "us-east-1"}
+	newClient := &RsaSignedServiceStub{
+		roleResponse: ssm.RequestManagedInstanceRoleTokenOutput{
+			AccessKeyId:         &accessKeyID,
+			SecretAccessKey:     &secretAccessKey,
+			SessionToken:        &sessionToken,
+			UpdateKeyPair:       &updateKeyPair,
+			TokenExpirationDate: &tokenExpirationDate,
+		},
+	}
+	var newInstanceID string
+	newRsaService = func(serverId string, region string, encodedPrivateKey string) rsaauth.RsaSignedService {
+		newInstanceID = serverId
+		return newClient
+	}
+	reregister = func(log.T) (string, string, error) { return "mi-0123456789abcdef0", "privateKey", nil }
+	defer func() {
+		newRsaService = rsaauth.NewRsaService
+		reregister = reregistration.Reregister
+	}()
+
+	testProvider := managedInstancesRoleProvider{
+		Client: &RsaSignedServiceStub{
+			err: awserr.New("InvalidInstanceId", "instance is not registered", nil),
+		},
+	}
+	cred, err := testProvider.Retrieve()
+	assert.NoError(t, err)
+	assert.Equal(t, accessKeyID, cred.AccessKeyID)
+	assert.Equal(t, "mi-0123456789abcdef0", newInstanceID)
+
+	// the error is returned when reregistration fails
+	reregister = func(log.T) (string, string, error) { return "", "", fmt.Errorf("reregistration is disabled") }
+	testProvider = managedInstancesRoleProvider{
+		Client: &RsaSignedServiceStub{
+			err: awserr.New("InvalidInstanceId", "instance is not registered", nil),
+		},
+	}
+	_, err = testProvider.Retrieve()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "reregistration is disabled")
+}
+
 func TestRetrieve_ShouldFailOnError(t *testing.T) {
 	// Fail on machine fingerprint error
 	machineFingerprintError := fmt.Errorf("machineFingerprintError")
@@ -129,6 +215,7 @@ type registrationStub struct {
 	publicKey        string
 	privateKey       string
 	keyType          string
+	migrate          bool
 	err              error
 }
 
@@ -151,3 +238,5 @@ func (r registrationStub) GenerateKeyPair() (publicKey, privateKey, keyType stri
 func (r registrationStub) UpdatePrivateKey(privateKey, privateKeyType string) (err error) {
 	return r.err
 }
+
+func (r registrationStub) ShouldMigratePrivateKey() bool { return r.migrate }
@@ -22,6 +22,7 @@ import (
 	"github.com/aws/amazon-ssm-agent/agent/appconfig"
 	"github.com/aws/amazon-ssm-agent/agent/log"
 	"github.com/aws/amazon-ssm-agent/agent/log/ssmlog"
+	"github.com/aws/amazon-ssm-agent/agent/managedInstances/reregistration"
 	"github.com/aws/amazon-ssm-agent/agent/managedInstances/sharedCredentials"
 	"github.com/aws/amazon-ssm-agent/agent/ssm/rsaauth"
 	"github.com/aws/aws-sdk-go/aws/credentials"
@@ -60,13 +61,27 @@ type managedInstancesRoleProvider struct {
 
 var (
 	emptyCredential      = credentials.Value{ProviderName: ProviderName}
+	newRsaService        = rsaauth.NewRsaService
+	reregister           = reregistration.Reregister
 	credentialsSingleton *credentials.Credentials
 	lock                 sync.RWMutex
 	logger               log.T
 	shareCreds           bool
 	shareProfile         string
+
+	// remoteProvider retrieves the credentials for a process that cannot use the private key of the instance
+	remoteProvider credentials.Provider
 )
 
+// SetRemoteProvider makes the managed instance credentials come from provider instead of the SSM Auth service,
+// the unprivileged agent worker gets them from the privilege broker
+func SetRemoteProvider(provider credentials.Provider) {
+	lock.Lock()
+	defer lock.Unlock()
+	remoteProvider = provider
+	credentialsSingleton = nil
+}
+
 // ManagedInstanceCredentialsInstance returns a singleton instance of
 // Crednetials which provides credentials of a managed instance.
 func ManagedInstanceCredentialsInstance() *credentials.Credentials {
@@ -88,11 +103,14 @@ func ManagedInstanceCredentialsInstance() *credentials.Credentials {
 // newManagedInstanceCredentials returns a pointer to a new Credentials object wrapping
 // the managedInstancesRoleProvider.
 func newManagedInstanceCredentials() *credentials.Credentials {
+	if remoteProvider != nil {
+		return credentials.NewCredentials(remoteProvider)
+	}
 	instanceID := managedInstance.InstanceID()
 	region := managedInstance.Region()
 	privateKey := managedInstance.PrivateKey()
 	p := &managedInstancesRoleProvider{
-		Client:       rsaauth.NewRsaService(instanceID, region, privateKey),
+		Client:       newRsaService(instanceID, region, privateKey),
 		ExpiryWindow: EarlyExpiryTimeWindow,
 	}
 
@@ -109,30 +127,29 @@ func (m *managedInstancesRoleProvider) Retrieve() (credentials.Value, error) {
 	}
 
 	roleCreds, err := m.Client.RequestManagedInstanceRoleToken(fingerprint)
+	if err != nil && reregistration.IsDeregisteredError(err) {
+		// the registration was removed from SSM, retrying with the same instance id fails forever
+		var instanceID, privateKey string
+		if instanceID, privateKey, err = reregister(logger); err == nil {
+			m.Client = newRsaService(instanceID, managedInstance.Region(), privateKey)
+			roleCreds, err = m.Client.RequestManagedInstanceRoleToken(fingerprint)
+		}
+	}
 	if err != nil {
 		return emptyCredential, fmt.Errorf("error occurred in RequestManagedInstanceRoleToken: %v", err)
 	}
 
 	// check if SSM has requested the agent to update the instance keypair
 	if *roleCreds.UpdateKeyPair {
-		publicKey, privateKey, keyType, err := managedInstance.GenerateKeyPair()
-		if err != nil {
-			return emptyCredential, fmt.Errorf("error generating keys: %v", err)
+		if err = m.updateKeyPair(); err != nil {
+			return emptyCredential, err
 		}
-
-		// call ssm UpdateManagedInstancePublicKey
-		_, err = m.Client.UpdateManagedInstancePublicKey(publicKey, keyType)
-		if err != nil {
-			// TODO: Perform smart retry
-			// In case of client error, try some Onprem API call with new private key
-			// if call succeeds, then update the Private key, else retry UpdateManagedInstancePublicKey
-			return emptyCredential, fmt.Errorf("error updating public key: %v", err)
-		}
-
-		// persist the new key
-		err = managedInstance.UpdatePrivateKey(privateKey, keyType)
-		if err != nil {
-			return emptyCredential, fmt.Errorf("error persisting private key: %v", err)
+	} else if managedInstance.ShouldMigratePrivateKey() {
+		// move the key to the configured private key storage, the current key keeps working if this fails
+		if err = m.updateKeyPair(); err != nil {
+			logger.Warnf("Failed to migrate private key to the configured key storage: %v", err)
+		} else {
+			logger.Info("Migrated private key to the configured key storage")
 		}
 	}
 
@@ -160,3 +177,29 @@ func (m *managedInstancesRoleProvider) Retrieve() (credentials.Value, error) {
 		ProviderName:    ProviderName,
 	}, nil
 }
+
+// updateKeyPair replaces the instance keypair and makes the client sign with the new key
+func (m *managedInstancesRoleProvider) updateKeyPair() error {
+	publicKey, privateKey, keyType, err := managedInstance.GenerateKeyPair()
+	if err != nil {
+		return fmt.Errorf("error generating keys: %v", err)
+	}
+
+	// call ssm UpdateManagedInstancePublicKey
+	_, err = m.Client.UpdateManagedInstancePublicKey(publicKey, keyType)
+	if err != nil {
+		// TODO: Perform smart retry
+		// In case of client error, try some Onprem API call with new private key
+		// if call succeeds, then update the Private key, else retry UpdateManagedInstancePublicKey
+		return fmt.Errorf("error updating public key: %v", err)
+	}
+
+	// persist the new key
+	err = managedInstance.UpdatePrivateKey(privateKey, keyType)
+	if err != nil {
+		return fmt.Errorf("error persisting private key: %v", err)
+	}
+
+	m.Client = newRsaService(managedInstance.InstanceID(), managedInstance.Region(), privateKey)
+	return nil
+}
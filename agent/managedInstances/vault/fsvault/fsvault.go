@@ -20,8 +20,15 @@ import (
 	"sync"
 
 	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/managedInstances/vault"
 )
 
+// RemoteVault serves the vault to a process that cannot access the vault folder
+type RemoteVault interface {
+	vault.Vault
+	IsManifestExists() bool
+}
+
 var (
 	lock             sync.RWMutex
 	manifest         map[string]string = make(map[string]string)
@@ -29,14 +36,28 @@ var (
 	vaultFolderPath  string            = filepath.Join(appconfig.DefaultDataStorePath, "Vault")
 	manifestFilePath string            = filepath.Join(vaultFolderPath, "Manifest")
 	storeFolderPath  string            = filepath.Join(vaultFolderPath, "Store")
+
+	// remote replaces the vault folder when it is set
+	remote RemoteVault
 )
 
+// SetRemote makes the vault functions use remote instead of the vault folder,
+// the unprivileged agent worker reaches the vault through the privilege broker
+func SetRemote(remoteVault RemoteVault) {
+	lock.Lock()
+	defer lock.Unlock()
+	remote = remoteVault
+}
+
 // Store data.
 func Store(key string, data []byte) (err error) {
 
 	lock.Lock()
 	defer lock.Unlock()
 
+	if remote != nil {
+		return remote.Store(key, data)
+	}
 	if err = ensureInitialized(); err != nil {
 		return
 	}
@@ -57,6 +78,12 @@ func Store(key string, data []byte) (err error) {
 }
 
 func IsManifestExists() bool {
+	lock.RLock()
+	remoteVault := remote
+	lock.RUnlock()
+	if remoteVault != nil {
+		return remoteVault.IsManifestExists()
+	}
 	return fs.Exists(manifestFilePath) || len(manifest) != 0
 }
 
@@ -66,6 +93,9 @@ func Retrieve(key string) (data []byte, err error) {
 	lock.Lock()
 	defer lock.Unlock()
 
+	if remote != nil {
+		return remote.Retrieve(key)
+	}
 	if err = ensureInitialized(); err != nil {
 		return
 	}
@@ -93,6 +123,9 @@ func Remove(key string) (err error) {
 	lock.Lock()
 	defer lock.Unlock()
 
+	if remote != nil {
+		return remote.Remove(key)
+	}
 	if err = ensureInitialized(); err != nil {
 		return
 	}
@@ -0,0 +1,158 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package clockskew measures the difference between the local clock and the clock of the services from the Date
+// header of their responses, and signs requests with the service time so that a drifted clock does not make the
+// service reject them. Signature V4 requests are rejected once the clocks differ by more than 5 minutes.
+package clockskew
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/log/ssmlog"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	v4 "github.com/aws/aws-sdk-go/aws/signer/v4"
+)
+
+// minimumSkew is the skew below which the clocks are considered in sync, the Date header only has a precision of
+// one second and the response takes some time to arrive
+const minimumSkew = 5 * time.Second
+
+// skewErrorCodes are the error codes of requests rejected because their signing time is too far from the service time
+var skewErrorCodes = map[string]bool{
+	"RequestTimeTooSkewed":      true,
+	"RequestExpired":            true,
+	"RequestInTheFuture":        true,
+	"InvalidSignatureException": true,
+	"SignatureDoesNotMatch":     true,
+	"AuthFailure":               true,
+}
+
+var (
+	lock     sync.RWMutex
+	offset   time.Duration
+	measured time.Time
+	exceeded bool
+
+	getAppConfig = appconfig.Config
+	getLogger    = func() log.T { return ssmlog.SSMLogger(true) }
+	timeNow      = time.Now
+)
+
+// Offset returns the service time minus the local time, as measured from the last response
+func Offset() time.Duration {
+	lock.RLock()
+	defer lock.RUnlock()
+	return offset
+}
+
+// Status returns the last measured skew, when it was measured and whether it is above the configured threshold
+func Status() (skew time.Duration, lastMeasured time.Time, exceeds bool) {
+	lock.RLock()
+	defer lock.RUnlock()
+	return offset, measured, exceeded
+}
+
+// Now returns the time requests are signed with, the local time corrected by the measured skew
+func Now() time.Time {
+	if config, err := getAppConfig(false); err == nil && config.ClockSkew.DisableCorrection {
+		return timeNow()
+	}
+	return timeNow().Add(Offset())
+}
+
+// Record updates the skew from the time the service sent in a response that just arrived
+func Record(serviceTime time.Time) {
+	if serviceTime.IsZero() {
+		return
+	}
+	config, err := getAppConfig(false)
+	if err != nil {
+		return
+	}
+	skew := serviceTime.Sub(timeNow())
+	if abs(skew) < minimumSkew {
+		skew = 0
+	}
+	threshold := time.Duration(config.ClockSkew.ThresholdSeconds) * time.Second
+
+	lock.Lock()
+	defer lock.Unlock()
+	offset, measured = skew, timeNow()
+	if exceeds := abs(skew) > threshold; exceeds != exceeded {
+		exceeded = exceeds
+		logger := getLogger()
+		if !exceeds {
+			logger.Infof("Local clock is in sync with the service clock again")
+			return
+		}
+		logger.Warnf("Local clock differs from the service clock by %v, more than %v, fix the time synchronization of the instance",
+			skew.Round(time.Second), threshold)
+		if config.ClockSkew.AuditEvent {
+			logger.WriteEvent(log.ClockSkewMessage, "", fmt.Sprintf("skew:%v", skew.Round(time.Second)))
+		}
+	}
+}
+
+// RecordResponse updates the skew from the Date header of the response
+func RecordResponse(response *http.Response) {
+	if response == nil {
+		return
+	}
+	if serviceTime, err := http.ParseTime(response.Header.Get("Date")); err == nil {
+		Record(serviceTime)
+	}
+}
+
+// IsSkewError returns true if the service rejected the request because of its signing time
+func IsSkewError(err error) bool {
+	if aErr, ok := err.(awserr.Error); ok {
+		return skewErrorCodes[aErr.Code()]
+	}
+	return false
+}
+
+// AddHandlers makes an sdk client measure the skew from its responses and sign its requests with the service time.
+// A request rejected because of its signing time is retried once the skew is known.
+func AddHandlers(handlers *request.Handlers) {
+	handlers.Sign.Swap(v4.SignRequestHandler.Name, request.NamedHandler{
+		Name: v4.SignRequestHandler.Name,
+		Fn: func(r *request.Request) {
+			v4.SignSDKRequestWithCurrentTime(r, Now)
+		},
+	})
+	handlers.Send.PushBack(func(r *request.Request) {
+		RecordResponse(r.HTTPResponse)
+	})
+	handlers.Retry.PushBack(func(r *request.Request) {
+		if IsSkewError(r.Error) && Offset() != 0 {
+			if config, err := getAppConfig(false); err == nil && !config.ClockSkew.DisableCorrection {
+				r.Retryable = aws.Bool(true)
+			}
+		}
+	})
+}
+
+func abs(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
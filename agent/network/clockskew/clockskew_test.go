@@ -0,0 +1,117 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package clockskew
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	v4 "github.com/aws/aws-sdk-go/aws/signer/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func setup(config appconfig.SsmagentConfig) (*log.Mock, time.Time) {
+	logger := log.NewMockLog()
+	getAppConfig = func(bool) (appconfig.SsmagentConfig, error) { return config, nil }
+	getLogger = func() log.T { return logger }
+	offset, measured, exceeded = 0, time.Time{}, false
+
+	now := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	timeNow = func() time.Time { return now }
+	return logger, now
+}
+
+func TestRecord(t *testing.T) {
+	logger, now := setup(appconfig.DefaultConfig())
+
+	Record(now.Add(2 * time.Second))
+	assert.Equal(t, time.Duration(0), Offset())
+	assert.Equal(t, now, Now())
+
+	Record(now.Add(-10 * time.Minute))
+	assert.Equal(t, -10*time.Minute, Offset())
+	assert.Equal(t, now.Add(-10*time.Minute), Now())
+	skew, measuredAt, exceeds := Status()
+	assert.Equal(t, -10*time.Minute, skew)
+	assert.Equal(t, now, measuredAt)
+	assert.True(t, exceeds)
+	logger.AssertNumberOfCalls(t, "Warnf", 1)
+	logger.AssertNotCalled(t, "WriteEvent", mock.Anything, mock.Anything, mock.Anything)
+
+	Record(now.Add(30 * time.Second))
+	_, _, exceeds = Status()
+	assert.False(t, exceeds)
+	assert.Equal(t, now.Add(30*time.Second), Now())
+}
+
+func TestRecordAuditEvent(t *testing.T) {
+	config := appconfig.DefaultConfig()
+	config.ClockSkew.AuditEvent = true
+	config.ClockSkew.DisableCorrection = true
+	logger, now := setup(config)
+
+	Record(now.Add(6 * time.Minute))
+	logger.AssertCalled(t, "WriteEvent", log.ClockSkewMessage, "", "skew:6m0s")
+	assert.Equal(t, now, Now())
+}
+
+func TestRecordResponse(t *testing.T) {
+	_, now := setup(appconfig.DefaultConfig())
+
+	RecordResponse(nil)
+	RecordResponse(&http.Response{Header: http.Header{"Date": []string{"not a date"}}})
+	assert.Equal(t, time.Duration(0), Offset())
+
+	RecordResponse(&http.Response{Header: http.Header{"Date": []string{now.Add(time.Hour).Format(http.TimeFormat)}}})
+	assert.Equal(t, time.Hour, Offset())
+}
+
+func TestAddHandlers(t *testing.T) {
+	_, now := setup(appconfig.DefaultConfig())
+
+	var handlers request.Handlers
+	handlers.Sign.PushBackNamed(v4.SignRequestHandler)
+	AddHandlers(&handlers)
+	assert.Equal(t, 1, handlers.Sign.Len())
+
+	r := &request.Request{
+		HTTPRequest:  &http.Request{URL: &url.URL{Host: "ec2messages.us-east-1.amazonaws.com"}},
+		HTTPResponse: &http.Response{Header: http.Header{"Date": []string{now.Add(-time.Hour).Format(http.TimeFormat)}}},
+	}
+	handlers.Send.Run(r)
+	assert.Equal(t, -time.Hour, Offset())
+
+	r.Error = awserr.New("InvalidSignatureException", "Signature expired", nil)
+	handlers.Retry.Run(r)
+	assert.True(t, aws.BoolValue(r.Retryable))
+
+	r.Retryable = nil
+	r.Error = awserr.New("AccessDeniedException", "access denied", nil)
+	handlers.Retry.Run(r)
+	assert.Nil(t, r.Retryable)
+}
+
+func TestIsSkewError(t *testing.T) {
+	assert.True(t, IsSkewError(awserr.New("RequestTimeTooSkewed", "skewed", nil)))
+	assert.False(t, IsSkewError(awserr.New("ThrottlingException", "slow down", nil)))
+	assert.False(t, IsSkewError(nil))
+}
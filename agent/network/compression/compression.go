@@ -0,0 +1,145 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package compression sends the large request bodies of the agent gzip compressed to the services that accept them.
+//
+// A service that rejects a compressed request, because it cannot decode the body, is remembered for the lifetime of
+// the process. The rejected request is retried with its original body and later requests are sent uncompressed.
+package compression
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/log/ssmlog"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+)
+
+const contentEncodingGzip = "gzip"
+
+// decodingErrorCodes are the error codes of services that could not decode a compressed body
+var decodingErrorCodes = map[string]bool{
+	"SerializationException": true,
+	"UnsupportedMediaType":   true,
+	"InvalidContentEncoding": true,
+}
+
+var (
+	lock         sync.Mutex
+	unsupported  = make(map[string]bool)
+	getAppConfig = appconfig.Config
+	getLogger    = func() log.T { return ssmlog.SSMLogger(true) }
+)
+
+// IsSupported returns false once the service rejected a compressed request
+func IsSupported(service string) bool {
+	lock.Lock()
+	defer lock.Unlock()
+	return !unsupported[service]
+}
+
+// AddHandlers makes an sdk client of the service compress the bodies of the operations when compression is enabled.
+// It must be called on the handlers of the client, so the body is compressed after the client built it.
+func AddHandlers(handlers *request.Handlers, service string, operations ...string) {
+	compressed := make(map[string]bool)
+	for _, operation := range operations {
+		compressed[operation] = true
+	}
+	handlers.Build.PushBack(func(r *request.Request) {
+		if r.Operation != nil && compressed[r.Operation.Name] {
+			compress(r, service)
+		}
+	})
+	handlers.Retry.PushBack(func(r *request.Request) {
+		if isCompressed(r) && isDecodingError(r) {
+			decompress(r, service)
+		}
+	})
+}
+
+// compress replaces the body of the request with its gzip compression when the body is above the threshold
+func compress(r *request.Request, service string) {
+	config, err := getAppConfig(false)
+	if err != nil || !config.Compression.Enabled || r.Body == nil || !IsSupported(service) {
+		return
+	}
+	if length, err := aws.SeekerLen(r.Body); err != nil || length < int64(config.Compression.ThresholdBytes) {
+		return
+	}
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		r.Body.Seek(0, io.SeekStart)
+		return
+	}
+
+	var buffer bytes.Buffer
+	writer := gzip.NewWriter(&buffer)
+	if _, err = writer.Write(body); err == nil {
+		err = writer.Close()
+	}
+	if err != nil || buffer.Len() >= len(body) {
+		r.Body.Seek(0, io.SeekStart)
+		return
+	}
+	r.SetBufferBody(buffer.Bytes())
+	r.HTTPRequest.Header.Set("Content-Encoding", contentEncodingGzip)
+}
+
+// decompress restores the original body of a compressed request the service rejected and retries it
+func decompress(r *request.Request, service string) {
+	lock.Lock()
+	unsupported[service] = true
+	lock.Unlock()
+	getLogger().Warnf("%v rejected a compressed request, sending its requests uncompressed", service)
+
+	if _, err := r.Body.Seek(0, io.SeekStart); err != nil {
+		return
+	}
+	reader, err := gzip.NewReader(r.Body)
+	if err != nil {
+		return
+	}
+	body, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return
+	}
+	r.SetBufferBody(body)
+	r.HTTPRequest.Header.Del("Content-Encoding")
+	// the length of the compressed body was set when the request was signed, signing the retry sets it again
+	r.HTTPRequest.Header.Del("Content-Length")
+	r.Retryable = aws.Bool(true)
+}
+
+// isCompressed returns true when the body of the request was compressed
+func isCompressed(r *request.Request) bool {
+	return r.HTTPRequest != nil && r.HTTPRequest.Header.Get("Content-Encoding") == contentEncodingGzip
+}
+
+// isDecodingError returns true when the service failed the request because it could not decode the body
+func isDecodingError(r *request.Request) bool {
+	if r.HTTPResponse != nil && r.HTTPResponse.StatusCode == http.StatusUnsupportedMediaType {
+		return true
+	}
+	if aErr, ok := r.Error.(awserr.Error); ok {
+		return decodingErrorCodes[aErr.Code()]
+	}
+	return false
+}
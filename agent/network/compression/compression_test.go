@@ -0,0 +1,157 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package compression
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/ssmmds"
+	"github.com/stretchr/testify/assert"
+)
+
+// receivedRequest is a request received by the test server
+type receivedRequest struct {
+	operation       string
+	contentEncoding string
+	body            string
+}
+
+// testServer answers the requests of an ssmmds client, it rejects compressed bodies when rejectCompressed is set
+type testServer struct {
+	*httptest.Server
+	lock     sync.Mutex
+	requests []receivedRequest
+}
+
+func newTestServer(rejectCompressed bool) *testServer {
+	server := &testServer{}
+	server.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received := receivedRequest{
+			operation:       r.Header.Get("X-Amz-Target"),
+			contentEncoding: r.Header.Get("Content-Encoding"),
+		}
+		var body []byte
+		if received.contentEncoding == contentEncodingGzip {
+			if reader, err := gzip.NewReader(r.Body); err == nil {
+				body, _ = ioutil.ReadAll(reader)
+			}
+		} else {
+			body, _ = ioutil.ReadAll(r.Body)
+		}
+		received.body = string(body)
+		server.lock.Lock()
+		server.requests = append(server.requests, received)
+		server.lock.Unlock()
+
+		if rejectCompressed && received.contentEncoding != "" {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"__type":"SerializationException","message":"cannot parse body"}`))
+			return
+		}
+		w.Write([]byte(`{}`))
+	}))
+	return server
+}
+
+func (s *testServer) received() []receivedRequest {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return append([]receivedRequest{}, s.requests...)
+}
+
+func setup(enabled bool, server *testServer) *ssmmds.SSMMDS {
+	config := appconfig.DefaultConfig()
+	config.Compression.Enabled = enabled
+	config.Compression.ThresholdBytes = 1024
+	getAppConfig = func(bool) (appconfig.SsmagentConfig, error) { return config, nil }
+	getLogger = func() log.T { return log.NewMockLog() }
+	unsupported = make(map[string]bool)
+
+	client := ssmmds.New(session.New(&aws.Config{
+		Region:      aws.String("us-east-1"),
+		Endpoint:    aws.String(server.URL),
+		Credentials: credentials.NewStaticCredentials("id", "secret", ""),
+	}))
+	AddHandlers(&client.Handlers, "ec2messages", "SendReply")
+	return client
+}
+
+func sendReply(client *ssmmds.SSMMDS, payload string) error {
+	_, err := client.SendReply(&ssmmds.SendReplyInput{
+		MessageId: aws.String("aws.ssm.message-0001"),
+		Payload:   aws.String(payload),
+		ReplyId:   aws.String("reply-0001-0001-0001"),
+	})
+	return err
+}
+
+func TestCompressesLargeBodies(t *testing.T) {
+	server := newTestServer(false)
+	defer server.Close()
+	client := setup(true, server)
+	payload := strings.Repeat("output ", 1000)
+
+	assert.NoError(t, sendReply(client, payload))
+	assert.NoError(t, sendReply(client, "small"))
+	_, err := client.AcknowledgeMessage(&ssmmds.AcknowledgeMessageInput{MessageId: aws.String(payload)})
+	assert.NoError(t, err)
+
+	requests := server.received()
+	assert.Len(t, requests, 3)
+	assert.Equal(t, contentEncodingGzip, requests[0].contentEncoding)
+	assert.Contains(t, requests[0].body, payload)
+	// small bodies and other operations are sent uncompressed
+	assert.Equal(t, "", requests[1].contentEncoding)
+	assert.Equal(t, "", requests[2].contentEncoding)
+}
+
+func TestDisabledCompression(t *testing.T) {
+	server := newTestServer(false)
+	defer server.Close()
+	client := setup(false, server)
+
+	assert.NoError(t, sendReply(client, strings.Repeat("output ", 1000)))
+
+	assert.Equal(t, "", server.received()[0].contentEncoding)
+}
+
+func TestFallsBackWhenServiceRejectsCompressedBodies(t *testing.T) {
+	server := newTestServer(true)
+	defer server.Close()
+	client := setup(true, server)
+	payload := strings.Repeat("output ", 1000)
+
+	assert.NoError(t, sendReply(client, payload))
+	assert.False(t, IsSupported("ec2messages"))
+	assert.NoError(t, sendReply(client, payload))
+
+	requests := server.received()
+	assert.Len(t, requests, 3)
+	assert.Equal(t, contentEncodingGzip, requests[0].contentEncoding)
+	// the rejected request is retried with its original body
+	assert.Equal(t, "", requests[1].contentEncoding)
+	assert.Equal(t, requests[0].body, requests[1].body)
+	assert.Equal(t, "", requests[2].contentEncoding)
+}
@@ -0,0 +1,284 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package network
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+)
+
+var interfaceByName = net.InterfaceByName
+
+var lookupIPAddr = net.DefaultResolver.LookupIPAddr
+
+// Dialer makes the outbound connections of the agent from the configured source address.
+// The address of a configured interface is looked up for every connection, so it follows address changes of the interface.
+// Timeout bounds the connection attempts to all the addresses of the host together.
+type Dialer struct {
+	Timeout   time.Duration
+	KeepAlive time.Duration
+}
+
+// Dial connects to the address on the named network
+func (d Dialer) Dial(network, address string) (net.Conn, error) {
+	return d.DialContext(context.Background(), network, address)
+}
+
+// DialContext connects to the address on the named network using the provided context.
+// It uses the connection pre-warmed for the address if there is one.
+func (d Dialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	if conn := takeWarmConn(network, address); conn != nil {
+		return conn, nil
+	}
+	return d.dial(ctx, network, address)
+}
+
+// dial makes a new connection to the address
+func (d Dialer) dial(ctx context.Context, network, address string) (net.Conn, error) {
+	dialer := &net.Dialer{KeepAlive: d.KeepAlive}
+	if !isLoopbackDestination(network, address) {
+		if localAddr := sourceAddr(); localAddr != nil {
+			dialer.LocalAddr = localAddr
+		}
+	}
+
+	// the timeout bounds all the attempts together
+	if d.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, d.Timeout)
+		defer cancel()
+	}
+
+	host, port, err := net.SplitHostPort(address)
+	if err != nil || !isTCP(network) || net.ParseIP(host) != nil {
+		return dialer.DialContext(ctx, network, address)
+	}
+	addrs, err := resolve(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	addrs = interleaveFamilies(filterFamily(network, dialer.LocalAddr, addrs))
+	if len(addrs) == 0 {
+		return nil, &net.AddrError{Err: "no suitable address found", Addr: host}
+	}
+	conn, err := dialAttempts(ctx, dialer, network, addrs, port, connectionAttemptDelay())
+	if err != nil {
+		// the host may have moved, the next connection resolves its name again
+		forget(host)
+	}
+	return conn, err
+}
+
+// dialResult is the outcome of a connection attempt
+type dialResult struct {
+	conn net.Conn
+	err  error
+}
+
+// dialAttempts connects to the first address that answers, as described in RFC 8305. The next attempt starts after
+// attemptDelay or as soon as the previous attempt failed, attempts in flight are not canceled until one succeeds.
+func dialAttempts(ctx context.Context, dialer *net.Dialer, network string, addrs []net.IPAddr, port string, attemptDelay time.Duration) (net.Conn, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make(chan dialResult, len(addrs))
+	next, pending := 0, 0
+	startAttempt := func() {
+		address := net.JoinHostPort(addrs[next].String(), port)
+		next++
+		pending++
+		go func() {
+			conn, err := dialer.DialContext(ctx, network, address)
+			results <- dialResult{conn: conn, err: err}
+		}()
+	}
+
+	startAttempt()
+	timer := time.NewTimer(attemptDelay)
+	defer timer.Stop()
+
+	var firstErr error
+	for pending > 0 {
+		select {
+		case result := <-results:
+			pending--
+			if result.err == nil {
+				go closeLateConns(results, pending)
+				return result.conn, nil
+			}
+			if firstErr == nil {
+				firstErr = result.err
+			}
+			if next < len(addrs) {
+				startAttempt()
+				if !timer.Stop() {
+					select {
+					case <-timer.C:
+					default:
+					}
+				}
+				timer.Reset(attemptDelay)
+			}
+		case <-timer.C:
+			if next < len(addrs) {
+				startAttempt()
+				timer.Reset(attemptDelay)
+			}
+		}
+	}
+	return nil, firstErr
+}
+
+// closeLateConns closes the connections of the attempts that succeed after another attempt won
+func closeLateConns(results <-chan dialResult, pending int) {
+	for ; pending > 0; pending-- {
+		if result := <-results; result.conn != nil {
+			result.conn.Close()
+		}
+	}
+}
+
+// interleaveFamilies orders the addresses by alternating their families, starting with the family of the first one.
+// The resolver already sorts the addresses by preference, which is kept within each family.
+func interleaveFamilies(addrs []net.IPAddr) []net.IPAddr {
+	if len(addrs) == 0 {
+		return addrs
+	}
+	var primary, secondary []net.IPAddr
+	firstIsIPv4 := addrs[0].IP.To4() != nil
+	for _, addr := range addrs {
+		if (addr.IP.To4() != nil) == firstIsIPv4 {
+			primary = append(primary, addr)
+		} else {
+			secondary = append(secondary, addr)
+		}
+	}
+
+	interleaved := make([]net.IPAddr, 0, len(addrs))
+	for i := 0; i < len(primary) || i < len(secondary); i++ {
+		if i < len(primary) {
+			interleaved = append(interleaved, primary[i])
+		}
+		if i < len(secondary) {
+			interleaved = append(interleaved, secondary[i])
+		}
+	}
+	return interleaved
+}
+
+// filterFamily keeps the addresses of the family required by the network or by the local address
+func filterFamily(network string, localAddr net.Addr, addrs []net.IPAddr) []net.IPAddr {
+	wantIPv4, wantIPv6 := network != "tcp6", network != "tcp4"
+	if tcpAddr, ok := localAddr.(*net.TCPAddr); ok && tcpAddr != nil {
+		localIsIPv4 := tcpAddr.IP.To4() != nil
+		wantIPv4, wantIPv6 = wantIPv4 && localIsIPv4, wantIPv6 && !localIsIPv4
+	}
+
+	var filtered []net.IPAddr
+	for _, addr := range addrs {
+		if isIPv4 := addr.IP.To4() != nil; (isIPv4 && wantIPv4) || (!isIPv4 && wantIPv6) {
+			filtered = append(filtered, addr)
+		}
+	}
+	return filtered
+}
+
+// connectionAttemptDelay returns the configured delay between connection attempts
+func connectionAttemptDelay() time.Duration {
+	config, err := getAppConfig(false)
+	if err != nil || config.Network.ConnectionAttemptDelayMilliseconds <= 0 {
+		return appconfig.DefaultNetworkConnectionAttemptDelayMilliseconds * time.Millisecond
+	}
+	return time.Duration(config.Network.ConnectionAttemptDelayMilliseconds) * time.Millisecond
+}
+
+func isTCP(network string) bool {
+	switch network {
+	case "tcp", "tcp4", "tcp6":
+		return true
+	}
+	return false
+}
+
+// sourceAddr returns the configured source address, or nil to let the system choose.
+// Settings that cannot be applied are logged and skipped so the agent keeps its connectivity.
+func sourceAddr() *net.TCPAddr {
+	config, err := getAppConfig(false)
+	if err != nil {
+		return nil
+	}
+	if config.Network.SourceAddress != "" {
+		return &net.TCPAddr{IP: net.ParseIP(config.Network.SourceAddress)}
+	}
+	if config.Network.SourceInterface != "" {
+		ip, err := interfaceAddress(config.Network.SourceInterface)
+		if err != nil {
+			getLogger().Warnf("Not binding the connection to interface %v: %v", config.Network.SourceInterface, err)
+			return nil
+		}
+		return &net.TCPAddr{IP: ip}
+	}
+	return nil
+}
+
+// interfaceAddress returns the first IPv4 address of the interface, or else its first global IPv6 address
+func interfaceAddress(name string) (net.IP, error) {
+	iface, err := interfaceByName(name)
+	if err != nil {
+		return nil, err
+	}
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return nil, err
+	}
+
+	var ipv6 net.IP
+	for _, addr := range addrs {
+		ipNet, ok := addr.(*net.IPNet)
+		if !ok {
+			continue
+		}
+		if ipNet.IP.To4() != nil {
+			return ipNet.IP, nil
+		}
+		if ipv6 == nil && ipNet.IP.IsGlobalUnicast() {
+			ipv6 = ipNet.IP
+		}
+	}
+	if ipv6 != nil {
+		return ipv6, nil
+	}
+	return nil, fmt.Errorf("interface %v has no usable address", name)
+}
+
+// isLoopbackDestination returns true for unix sockets and for destinations on the instance itself, which cannot be
+// reached from the address of another interface
+func isLoopbackDestination(network, address string) bool {
+	if !isTCP(network) {
+		return true
+	}
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return false
+	}
+	if host == "" || host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && (ip.IsLoopback() || ip.IsUnspecified())
+}
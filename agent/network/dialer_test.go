@@ -0,0 +1,164 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package network
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/log/ssmlog"
+	"github.com/stretchr/testify/assert"
+)
+
+func setupNetworkConfig(networkCfg appconfig.NetworkCfg) func() {
+	getAppConfig = func(bool) (appconfig.SsmagentConfig, error) {
+		config := appconfig.DefaultConfig()
+		config.Network = networkCfg
+		return config, nil
+	}
+	getLogger = func() log.T { return log.NewMockLog() }
+	return func() {
+		getAppConfig = appconfig.Config
+		getLogger = func() log.T { return ssmlog.SSMLogger(true) }
+	}
+}
+
+func TestSourceAddrDefault(t *testing.T) {
+	defer setupNetworkConfig(appconfig.NetworkCfg{})()
+
+	assert.Nil(t, sourceAddr())
+}
+
+func TestSourceAddrFromAddress(t *testing.T) {
+	defer setupNetworkConfig(appconfig.NetworkCfg{SourceAddress: "10.0.1.15", SourceInterface: "eth1"})()
+
+	assert.Equal(t, "10.0.1.15", sourceAddr().IP.String())
+}
+
+func TestSourceAddrFromInterface(t *testing.T) {
+	interfaces, err := net.Interfaces()
+	assert.Nil(t, err)
+	var loopback string
+	for _, iface := range interfaces {
+		if iface.Flags&net.FlagLoopback != 0 {
+			loopback = iface.Name
+			break
+		}
+	}
+	if loopback == "" {
+		t.Skip("no loopback interface")
+	}
+	defer setupNetworkConfig(appconfig.NetworkCfg{SourceInterface: loopback})()
+
+	assert.True(t, sourceAddr().IP.IsLoopback())
+}
+
+func TestSourceAddrUnknownInterface(t *testing.T) {
+	defer setupNetworkConfig(appconfig.NetworkCfg{SourceInterface: "no-such-interface0"})()
+
+	assert.Nil(t, sourceAddr())
+}
+
+func TestIsLoopbackDestination(t *testing.T) {
+	assert.True(t, isLoopbackDestination("unix", "/var/run/app.sock"))
+	assert.True(t, isLoopbackDestination("tcp", "localhost:8080"))
+	assert.True(t, isLoopbackDestination("tcp", "127.0.0.1:3306"))
+	assert.True(t, isLoopbackDestination("tcp6", "[::1]:22"))
+	assert.True(t, isLoopbackDestination("tcp", ":22"))
+	assert.False(t, isLoopbackDestination("tcp", "ssm.us-east-1.amazonaws.com:443"))
+	assert.False(t, isLoopbackDestination("tcp", "10.0.2.30:5432"))
+}
+
+func TestDialerDoesNotBindLoopbackDestinations(t *testing.T) {
+	// an address the instance does not own would fail the connection if it was used
+	defer setupNetworkConfig(appconfig.NetworkCfg{SourceAddress: "192.0.2.1"})()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	defer listener.Close()
+
+	conn, err := Dialer{}.Dial("tcp", listener.Addr().String())
+	assert.Nil(t, err)
+	conn.Close()
+}
+
+func TestInterleaveFamilies(t *testing.T) {
+	addrs := []net.IPAddr{
+		{IP: net.ParseIP("2001:db8::1")},
+		{IP: net.ParseIP("2001:db8::2")},
+		{IP: net.ParseIP("2001:db8::3")},
+		{IP: net.ParseIP("192.0.2.1")},
+	}
+
+	interleaved := interleaveFamilies(addrs)
+
+	var ordered []string
+	for _, addr := range interleaved {
+		ordered = append(ordered, addr.String())
+	}
+	assert.Equal(t, []string{"2001:db8::1", "192.0.2.1", "2001:db8::2", "2001:db8::3"}, ordered)
+}
+
+func TestFilterFamily(t *testing.T) {
+	addrs := []net.IPAddr{{IP: net.ParseIP("2001:db8::1")}, {IP: net.ParseIP("192.0.2.1")}}
+
+	assert.Len(t, filterFamily("tcp", nil, addrs), 2)
+	assert.Equal(t, "192.0.2.1", filterFamily("tcp4", nil, addrs)[0].String())
+	assert.Equal(t, "2001:db8::1", filterFamily("tcp6", nil, addrs)[0].String())
+	assert.Equal(t, "192.0.2.1", filterFamily("tcp", &net.TCPAddr{IP: net.ParseIP("10.0.1.15")}, addrs)[0].String())
+	assert.Empty(t, filterFamily("tcp6", &net.TCPAddr{IP: net.ParseIP("10.0.1.15")}, addrs))
+}
+
+func TestDialerStartsNextAttemptWithoutWaitingForTimeout(t *testing.T) {
+	defer setupNetworkConfig(appconfig.NetworkCfg{ConnectionAttemptDelayMilliseconds: 50})()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	defer listener.Close()
+	_, port, _ := net.SplitHostPort(listener.Addr().String())
+
+	// the first address does not answer
+	lookupIPAddr = func(ctx context.Context, host string) ([]net.IPAddr, error) {
+		return []net.IPAddr{{IP: net.ParseIP("192.0.2.1")}, {IP: net.ParseIP("127.0.0.1")}}, nil
+	}
+	defer func() { lookupIPAddr = net.DefaultResolver.LookupIPAddr }()
+
+	start := time.Now()
+	conn, err := Dialer{Timeout: 20 * time.Second}.Dial("tcp", net.JoinHostPort("mgs.example.test", port))
+	assert.Nil(t, err)
+	conn.Close()
+	assert.True(t, time.Since(start) < 5*time.Second)
+}
+
+func TestDialerReturnsFirstErrorWhenAllAttemptsFail(t *testing.T) {
+	defer setupNetworkConfig(appconfig.NetworkCfg{ConnectionAttemptDelayMilliseconds: 10})()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	address := listener.Addr().String()
+	listener.Close()
+	_, port, _ := net.SplitHostPort(address)
+
+	lookupIPAddr = func(ctx context.Context, host string) ([]net.IPAddr, error) {
+		return []net.IPAddr{{IP: net.ParseIP("127.0.0.1")}, {IP: net.ParseIP("127.0.0.1")}}, nil
+	}
+	defer func() { lookupIPAddr = net.DefaultResolver.LookupIPAddr }()
+
+	_, err = Dialer{Timeout: 5 * time.Second}.Dial("tcp", net.JoinHostPort("mgs.example.test", port))
+	assert.NotNil(t, err)
+}
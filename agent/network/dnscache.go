@@ -0,0 +1,74 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package network
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+)
+
+// dnsCacheEntry is the addresses of a host name and when they expire
+type dnsCacheEntry struct {
+	addrs   []net.IPAddr
+	expires time.Time
+}
+
+var (
+	dnsCacheLock sync.Mutex
+	dnsCache     = make(map[string]dnsCacheEntry)
+	timeNow      = time.Now
+)
+
+// resolve returns the addresses of the host name. The addresses are kept for the configured DNS cache period, so the
+// connections the agent opens to its service endpoints do not all resolve the same names.
+func resolve(ctx context.Context, host string) ([]net.IPAddr, error) {
+	ttl := dnsCacheTTL()
+	if ttl <= 0 {
+		return lookupIPAddr(ctx, host)
+	}
+
+	dnsCacheLock.Lock()
+	entry, found := dnsCache[host]
+	dnsCacheLock.Unlock()
+	if found && timeNow().Before(entry.expires) {
+		return entry.addrs, nil
+	}
+
+	addrs, err := lookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	dnsCacheLock.Lock()
+	dnsCache[host] = dnsCacheEntry{addrs: addrs, expires: timeNow().Add(ttl)}
+	dnsCacheLock.Unlock()
+	return addrs, nil
+}
+
+// forget drops the cached addresses of the host name, after none of them could be connected to
+func forget(host string) {
+	dnsCacheLock.Lock()
+	defer dnsCacheLock.Unlock()
+	delete(dnsCache, host)
+}
+
+// dnsCacheTTL returns the configured DNS cache period, zero disables the cache
+func dnsCacheTTL() time.Duration {
+	config, err := getAppConfig(false)
+	if err != nil {
+		return 0
+	}
+	return time.Duration(config.Network.DnsCacheSeconds) * time.Second
+}
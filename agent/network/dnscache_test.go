@@ -0,0 +1,83 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package network
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/stretchr/testify/assert"
+)
+
+// countLookups makes the lookups return addr and counts them
+func countLookups(addr string) (*int, func()) {
+	lookups := 0
+	lookupIPAddr = func(ctx context.Context, host string) ([]net.IPAddr, error) {
+		lookups++
+		return []net.IPAddr{{IP: net.ParseIP(addr)}}, nil
+	}
+	dnsCache = make(map[string]dnsCacheEntry)
+	return &lookups, func() {
+		lookupIPAddr = net.DefaultResolver.LookupIPAddr
+		timeNow = time.Now
+	}
+}
+
+func TestResolveCachesAddresses(t *testing.T) {
+	defer setupNetworkConfig(appconfig.NetworkCfg{DnsCacheSeconds: 30})()
+	lookups, reset := countLookups("192.0.2.10")
+	defer reset()
+	now := time.Now()
+	timeNow = func() time.Time { return now }
+
+	addrs, err := resolve(context.Background(), "ssm.example.test")
+	assert.Nil(t, err)
+	assert.Equal(t, "192.0.2.10", addrs[0].IP.String())
+	resolve(context.Background(), "ssm.example.test")
+	assert.Equal(t, 1, *lookups)
+
+	now = now.Add(31 * time.Second)
+	resolve(context.Background(), "ssm.example.test")
+	assert.Equal(t, 2, *lookups)
+}
+
+func TestResolveWithoutCache(t *testing.T) {
+	defer setupNetworkConfig(appconfig.NetworkCfg{DnsCacheSeconds: 0})()
+	lookups, reset := countLookups("192.0.2.10")
+	defer reset()
+
+	resolve(context.Background(), "ssm.example.test")
+	resolve(context.Background(), "ssm.example.test")
+	assert.Equal(t, 2, *lookups)
+}
+
+func TestDialerForgetsAddressesOfUnreachableHost(t *testing.T) {
+	defer setupNetworkConfig(appconfig.NetworkCfg{DnsCacheSeconds: 30, ConnectionAttemptDelayMilliseconds: 10})()
+	lookups, reset := countLookups("127.0.0.1")
+	defer reset()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	_, port, _ := net.SplitHostPort(listener.Addr().String())
+	listener.Close()
+
+	_, err = Dialer{Timeout: 5 * time.Second}.Dial("tcp", net.JoinHostPort("ssm.example.test", port))
+	assert.NotNil(t, err)
+	_, err = Dialer{Timeout: 5 * time.Second}.Dial("tcp", net.JoinHostPort("ssm.example.test", port))
+	assert.NotNil(t, err)
+	assert.Equal(t, 2, *lookups)
+}
@@ -0,0 +1,222 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package failover switches control plane services to secondary regions while the primary region is unreachable.
+//
+// Each service tracks the target its requests are sent to. When requests to the active target keep failing
+// with connection or server errors for the configured period, the service moves to the next secondary region.
+// After the failback period the primary region is tried again, a failure returns to the secondary region.
+package failover
+
+import (
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/log/ssmlog"
+	"github.com/aws/amazon-ssm-agent/agent/platform"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+)
+
+// Target is the region, and optionally the endpoint, requests to a service are sent to
+type Target struct {
+	Region   string
+	Endpoint string
+	Primary  bool
+}
+
+// serviceState tracks the active target of a service, index 0 is the primary region
+// and index i is the secondary region i-1 of the failover config
+type serviceState struct {
+	primaryRegion string
+	active        int
+	previous      int
+	failingBack   bool
+	firstFailure  time.Time
+	switched      time.Time
+}
+
+var (
+	lock         sync.Mutex
+	states       = make(map[string]*serviceState)
+	getAppConfig = appconfig.Config
+	getLogger    = func() log.T { return ssmlog.SSMLogger(true) }
+	timeNow      = time.Now
+)
+
+// Current returns the target requests to the service should be sent to
+func Current(service string, primaryRegion string) Target {
+	config, err := getAppConfig(false)
+	if err != nil || len(config.Failover.Secondaries) == 0 {
+		return Target{Region: primaryRegion, Primary: true}
+	}
+
+	lock.Lock()
+	defer lock.Unlock()
+	state := stateOf(service, config)
+	state.primaryRegion = primaryRegion
+
+	failbackPeriod := time.Duration(config.Failover.FailbackSeconds) * time.Second
+	if state.active != 0 && !state.failingBack && timeNow().Sub(state.switched) >= failbackPeriod {
+		getLogger().Infof("Trying %v in primary region %v again", service, primaryRegion)
+		state.previous, state.active, state.failingBack = state.active, 0, true
+		state.switched, state.firstFailure = timeNow(), time.Time{}
+	}
+	return targetOf(config, state, service)
+}
+
+// Report records whether the target in the region was reachable for a request sent to the service
+func Report(service string, region string, unreachable bool) {
+	config, err := getAppConfig(false)
+	if err != nil || len(config.Failover.Secondaries) == 0 {
+		return
+	}
+
+	lock.Lock()
+	defer lock.Unlock()
+	state := stateOf(service, config)
+	if region != targetOf(config, state, service).Region {
+		// result of a request sent before the last switch
+		return
+	}
+
+	logger := getLogger()
+	if !unreachable {
+		if state.failingBack {
+			logger.Infof("Primary region %v of %v is reachable again", region, service)
+			state.failingBack = false
+		}
+		state.firstFailure = time.Time{}
+		return
+	}
+
+	if state.failingBack {
+		previous := targetOf(config, &serviceState{primaryRegion: state.primaryRegion, active: state.previous}, service)
+		logger.Warnf("Primary region %v of %v is still unreachable, switching back to %v", region, service, previous.Region)
+		state.active, state.failingBack = state.previous, false
+		state.switched, state.firstFailure = timeNow(), time.Time{}
+		return
+	}
+
+	if state.firstFailure.IsZero() {
+		state.firstFailure = timeNow()
+		return
+	}
+	unreachablePeriod := time.Duration(config.Failover.UnreachableSeconds) * time.Second
+	if timeNow().Sub(state.firstFailure) >= unreachablePeriod {
+		state.active = (state.active + 1) % (len(config.Failover.Secondaries) + 1)
+		state.switched, state.firstFailure = timeNow(), time.Time{}
+		logger.Warnf("%v has been unreachable in %v for %v, switching to region %v",
+			service, region, unreachablePeriod, targetOf(config, state, service).Region)
+	}
+}
+
+// EndpointOverride returns the endpoint configured for the service in a secondary region, or empty if there is none
+func EndpointOverride(service string, region string) string {
+	config, err := getAppConfig(false)
+	if err != nil {
+		return ""
+	}
+	for _, secondary := range config.Failover.Secondaries {
+		if secondary.Region == region {
+			return endpointHost(secondary.Endpoints[service])
+		}
+	}
+	return ""
+}
+
+// AddHandlers makes the requests of an sdk client follow the failover target of the service
+func AddHandlers(handlers *request.Handlers, service string) {
+	handlers.Build.PushBack(func(r *request.Request) {
+		target := Current(service, aws.StringValue(r.Config.Region))
+		if target.Primary {
+			return
+		}
+		r.ClientInfo.SigningRegion = target.Region
+		r.HTTPRequest.URL.Host = targetHost(target, service)
+	})
+	handlers.Complete.PushBack(func(r *request.Request) {
+		region := r.ClientInfo.SigningRegion
+		if region == "" {
+			region = aws.StringValue(r.Config.Region)
+		}
+		Report(service, region, IsUnreachable(r.Error))
+	})
+}
+
+// IsUnreachable returns true if the error means the service could not be reached or failed on the server side.
+// Timeouts of the http client are expected for long polling requests and are not counted.
+func IsUnreachable(err error) bool {
+	if err == nil {
+		return false
+	}
+	if reqErr, ok := err.(awserr.RequestFailure); ok {
+		return reqErr.StatusCode() >= 500
+	}
+	if aErr, ok := err.(awserr.Error); ok {
+		if aErr.Code() != "RequestError" {
+			return false
+		}
+		if aErr.OrigErr() != nil {
+			err = aErr.OrigErr()
+		}
+	}
+	return !strings.Contains(err.Error(), "Client.Timeout")
+}
+
+func stateOf(service string, config appconfig.SsmagentConfig) *serviceState {
+	state, ok := states[service]
+	if !ok {
+		state = &serviceState{}
+		states[service] = state
+	}
+	if state.active > len(config.Failover.Secondaries) || state.previous > len(config.Failover.Secondaries) {
+		// the config was reloaded with fewer secondaries
+		*state = serviceState{primaryRegion: state.primaryRegion}
+	}
+	return state
+}
+
+func targetOf(config appconfig.SsmagentConfig, state *serviceState, service string) Target {
+	if state.active == 0 {
+		return Target{Region: state.primaryRegion, Primary: true}
+	}
+	secondary := config.Failover.Secondaries[state.active-1]
+	return Target{Region: secondary.Region, Endpoint: endpointHost(secondary.Endpoints[service])}
+}
+
+// targetHost returns the host of the service in a secondary region
+func targetHost(target Target, service string) string {
+	if target.Endpoint != "" {
+		return target.Endpoint
+	}
+	if endpoint := platform.GetDefaultEndPoint(target.Region, service); endpoint != "" {
+		return endpoint
+	}
+	return service + "." + target.Region + ".amazonaws.com"
+}
+
+// endpointHost accepts endpoints configured with or without scheme
+func endpointHost(endpoint string) string {
+	if strings.Contains(endpoint, "://") {
+		if u, err := url.Parse(endpoint); err == nil {
+			return u.Host
+		}
+	}
+	return endpoint
+}
@@ -0,0 +1,149 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package failover
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	testService   = "ssm"
+	primaryRegion = "us-east-1"
+)
+
+func setup(t *testing.T) *time.Time {
+	config := appconfig.DefaultConfig()
+	config.Failover.Secondaries = []appconfig.FailoverTargetCfg{
+		{Region: "us-west-2"},
+		{Region: "eu-west-1", Endpoints: map[string]string{testService: "https://ssm.example.com"}},
+	}
+	getAppConfig = func(bool) (appconfig.SsmagentConfig, error) { return config, nil }
+	getLogger = func() log.T { return log.NewMockLog() }
+	states = make(map[string]*serviceState)
+
+	now := time.Now()
+	timeNow = func() time.Time { return now }
+	return &now
+}
+
+func TestFailoverAfterUnreachablePeriod(t *testing.T) {
+	now := setup(t)
+
+	assert.Equal(t, Target{Region: primaryRegion, Primary: true}, Current(testService, primaryRegion))
+	Report(testService, primaryRegion, true)
+	*now = now.Add(time.Minute)
+	Report(testService, primaryRegion, true)
+	assert.True(t, Current(testService, primaryRegion).Primary)
+
+	// a successful request resets the unreachable period
+	Report(testService, primaryRegion, false)
+	*now = now.Add(5 * time.Minute)
+	Report(testService, primaryRegion, true)
+	assert.True(t, Current(testService, primaryRegion).Primary)
+
+	*now = now.Add(5 * time.Minute)
+	Report(testService, primaryRegion, true)
+	assert.Equal(t, Target{Region: "us-west-2"}, Current(testService, primaryRegion))
+
+	// late results for the primary region are ignored
+	Report(testService, primaryRegion, false)
+	assert.Equal(t, "us-west-2", Current(testService, primaryRegion).Region)
+
+	Report(testService, "us-west-2", true)
+	*now = now.Add(5 * time.Minute)
+	Report(testService, "us-west-2", true)
+	assert.Equal(t, Target{Region: "eu-west-1", Endpoint: "ssm.example.com"}, Current(testService, primaryRegion))
+
+	// other services keep their own target
+	assert.True(t, Current("ec2messages", primaryRegion).Primary)
+}
+
+func TestFailback(t *testing.T) {
+	now := setup(t)
+	Current(testService, primaryRegion)
+	Report(testService, primaryRegion, true)
+	*now = now.Add(5 * time.Minute)
+	Report(testService, primaryRegion, true)
+	assert.Equal(t, "us-west-2", Current(testService, primaryRegion).Region)
+
+	// the primary region is tried again after the failback period, a failure switches back
+	*now = now.Add(15 * time.Minute)
+	assert.True(t, Current(testService, primaryRegion).Primary)
+	Report(testService, primaryRegion, true)
+	assert.Equal(t, "us-west-2", Current(testService, primaryRegion).Region)
+
+	*now = now.Add(15 * time.Minute)
+	assert.True(t, Current(testService, primaryRegion).Primary)
+	Report(testService, primaryRegion, false)
+	*now = now.Add(15 * time.Minute)
+	assert.True(t, Current(testService, primaryRegion).Primary)
+}
+
+func TestNoSecondaries(t *testing.T) {
+	setup(t)
+	getAppConfig = func(bool) (appconfig.SsmagentConfig, error) { return appconfig.DefaultConfig(), nil }
+
+	Report(testService, primaryRegion, true)
+	assert.Equal(t, Target{Region: primaryRegion, Primary: true}, Current(testService, primaryRegion))
+	assert.Empty(t, EndpointOverride(testService, "eu-west-1"))
+}
+
+func TestAddHandlers(t *testing.T) {
+	now := setup(t)
+	Current(testService, primaryRegion)
+	Report(testService, primaryRegion, true)
+	*now = now.Add(5 * time.Minute)
+	Report(testService, primaryRegion, true)
+
+	var handlers request.Handlers
+	AddHandlers(&handlers, testService)
+	r := &request.Request{
+		Config:      aws.Config{Region: aws.String(primaryRegion)},
+		HTTPRequest: &http.Request{URL: &url.URL{Host: "ssm.us-east-1.amazonaws.com"}},
+	}
+	handlers.Build.Run(r)
+	assert.Equal(t, "us-west-2", r.ClientInfo.SigningRegion)
+	assert.Equal(t, "ssm.us-west-2.amazonaws.com", r.HTTPRequest.URL.Host)
+
+	r.Error = awserr.New("RequestError", "send request failed", fmt.Errorf("dial tcp: connection refused"))
+	handlers.Complete.Run(r)
+	assert.False(t, states[testService].firstFailure.IsZero())
+}
+
+func TestIsUnreachable(t *testing.T) {
+	assert.False(t, IsUnreachable(nil))
+	assert.True(t, IsUnreachable(fmt.Errorf("dial tcp: lookup ssm.us-east-1.amazonaws.com: no such host")))
+	assert.False(t, IsUnreachable(fmt.Errorf("net/http: request canceled (Client.Timeout exceeded while awaiting headers)")))
+	assert.True(t, IsUnreachable(awserr.NewRequestFailure(awserr.New("InternalServerError", "", nil), 503, "")))
+	assert.False(t, IsUnreachable(awserr.NewRequestFailure(awserr.New("AccessDeniedException", "", nil), 400, "")))
+	assert.False(t, IsUnreachable(awserr.New("ThrottlingException", "", nil)))
+}
+
+func TestEndpointOverride(t *testing.T) {
+	setup(t)
+	assert.Equal(t, "ssm.example.com", EndpointOverride(testService, "eu-west-1"))
+	assert.Empty(t, EndpointOverride(testService, "us-west-2"))
+	assert.Empty(t, EndpointOverride("ssmmessages", "eu-west-1"))
+}
@@ -0,0 +1,101 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package network
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	// prewarmMaxIdle is how long a pre-warmed connection waits for its dial,
+	// servers close the connections that do not start their TLS handshake soon enough
+	prewarmMaxIdle = 10 * time.Second
+
+	// prewarmTimeout bounds the connection attempts of a pre-warm
+	prewarmTimeout = 30 * time.Second
+)
+
+// warmConn is a connection opened ahead of its dial
+type warmConn struct {
+	conn   net.Conn
+	dialed time.Time
+}
+
+var warmConns = struct {
+	sync.Mutex
+	byAddress map[string]warmConn
+	dialing   map[string]bool
+}{byAddress: make(map[string]warmConn), dialing: make(map[string]bool)}
+
+// Prewarm opens a TCP connection to the address ahead of time, the next dial of the address within prewarmMaxIdle uses it
+// instead of connecting. It is meant for the connections the agent is about to make, like the data channel of a session
+// being started, and does nothing if a connection to the address is already warm or being opened.
+func Prewarm(address string) {
+	warmConns.Lock()
+	if warm, ok := warmConns.byAddress[address]; (ok && time.Since(warm.dialed) < prewarmMaxIdle) || warmConns.dialing[address] {
+		warmConns.Unlock()
+		return
+	}
+	warmConns.dialing[address] = true
+	warmConns.Unlock()
+
+	conn, err := Dialer{Timeout: prewarmTimeout}.dial(context.Background(), "tcp", address)
+
+	warmConns.Lock()
+	defer warmConns.Unlock()
+	delete(warmConns.dialing, address)
+	if err != nil {
+		getLogger().Debugf("Failed to pre-warm a connection to %v: %v", address, err)
+		return
+	}
+	if previous, ok := warmConns.byAddress[address]; ok {
+		previous.conn.Close()
+	}
+	warm := warmConn{conn: conn, dialed: time.Now()}
+	warmConns.byAddress[address] = warm
+	time.AfterFunc(prewarmMaxIdle, func() { expireWarmConn(address, warm) })
+}
+
+// takeWarmConn returns the pre-warmed connection to the address and removes it from the pool,
+// or nil if there is none
+func takeWarmConn(network, address string) net.Conn {
+	if network != "tcp" {
+		return nil
+	}
+	warmConns.Lock()
+	defer warmConns.Unlock()
+	warm, ok := warmConns.byAddress[address]
+	if !ok {
+		return nil
+	}
+	delete(warmConns.byAddress, address)
+	if time.Since(warm.dialed) >= prewarmMaxIdle {
+		warm.conn.Close()
+		return nil
+	}
+	return warm.conn
+}
+
+// expireWarmConn closes the pre-warmed connection if it was not used
+func expireWarmConn(address string, warm warmConn) {
+	warmConns.Lock()
+	defer warmConns.Unlock()
+	if current, ok := warmConns.byAddress[address]; ok && current.conn == warm.conn {
+		delete(warmConns.byAddress, address)
+		warm.conn.Close()
+	}
+}
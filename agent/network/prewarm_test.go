@@ -0,0 +1,69 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package network
+
+import (
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrewarmedConnectionIsUsedByTheNextDial(t *testing.T) {
+	defer setupNetworkConfig(appconfig.NetworkCfg{})()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	defer listener.Close()
+	var accepted int32
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			atomic.AddInt32(&accepted, 1)
+			defer conn.Close()
+		}
+	}()
+
+	address := listener.Addr().String()
+	Prewarm(address)
+	// a second pre-warm keeps the warm connection
+	Prewarm(address)
+
+	conn, err := Dialer{}.Dial("tcp", address)
+	assert.Nil(t, err)
+	defer conn.Close()
+
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&accepted))
+	assert.Nil(t, takeWarmConn("tcp", address))
+}
+
+func TestExpiredWarmConnectionIsNotUsed(t *testing.T) {
+	client, server := net.Pipe()
+	defer server.Close()
+
+	address := "mgs.example.test:443"
+	warmConns.Lock()
+	warmConns.byAddress[address] = warmConn{conn: client, dialed: time.Now().Add(-prewarmMaxIdle)}
+	warmConns.Unlock()
+
+	assert.Nil(t, takeWarmConn("tcp", address))
+	assert.Nil(t, takeWarmConn("tcp", address))
+}
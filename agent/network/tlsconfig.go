@@ -0,0 +1,93 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package network contains the settings shared by the agent's connections to service endpoints.
+package network
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/log/ssmlog"
+)
+
+var getAppConfig = appconfig.Config
+
+var getLogger = func() log.T { return ssmlog.SSMLogger(true) }
+
+// GetDefaultTLSConfig returns the tls config used by the sdk clients, the session websocket and s3 transfers.
+// It trusts the system roots plus the configured CA bundle, and applies the configured minimum version and cipher suites.
+// Settings that cannot be applied are logged and skipped so the agent keeps its default TLS behavior.
+func GetDefaultTLSConfig() *tls.Config {
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	config, err := getAppConfig(false)
+	if err != nil {
+		return tlsConfig
+	}
+	if config.Tls.MinVersion == appconfig.TlsVersion13 {
+		tlsConfig.MinVersion = tls.VersionTLS13
+	}
+
+	if config.Tls.CaBundlePath != "" {
+		if rootCAs, err := loadCaBundle(config.Tls.CaBundlePath); err == nil {
+			tlsConfig.RootCAs = rootCAs
+		} else {
+			getLogger().Errorf("Failed to load CA bundle %v, using the system roots: %v", config.Tls.CaBundlePath, err)
+		}
+	}
+
+	if len(config.Tls.CipherSuites) > 0 {
+		tlsConfig.CipherSuites = getCipherSuites(config.Tls.CipherSuites)
+	}
+	return tlsConfig
+}
+
+// loadCaBundle returns the system cert pool with the PEM certificates of the bundle added
+func loadCaBundle(path string) (*x509.CertPool, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	rootCAs, err := x509.SystemCertPool()
+	if err != nil || rootCAs == nil {
+		rootCAs = x509.NewCertPool()
+	}
+	if !rootCAs.AppendCertsFromPEM(content) {
+		return nil, fmt.Errorf("no PEM certificates found in %v", path)
+	}
+	return rootCAs, nil
+}
+
+// getCipherSuites returns the ids of the named secure cipher suites.
+// Cipher suites only apply up to TLS 1.2, TLS 1.3 suites are not configurable.
+func getCipherSuites(names []string) []uint16 {
+	ids := make(map[string]uint16)
+	for _, suite := range tls.CipherSuites() {
+		ids[suite.Name] = suite.ID
+	}
+
+	var cipherSuites []uint16
+	for _, name := range names {
+		if id, ok := ids[name]; ok {
+			cipherSuites = append(cipherSuites, id)
+		} else {
+			getLogger().Warnf("Ignoring unknown or insecure cipher suite %v", name)
+		}
+	}
+	return cipherSuites
+}
@@ -0,0 +1,90 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package network
+
+import (
+	"crypto/tls"
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/log/ssmlog"
+	"github.com/stretchr/testify/assert"
+)
+
+func setupTlsConfig(tlsCfg appconfig.TlsCfg) func() {
+	getAppConfig = func(bool) (appconfig.SsmagentConfig, error) {
+		config := appconfig.DefaultConfig()
+		config.Tls = tlsCfg
+		return config, nil
+	}
+	getLogger = func() log.T { return log.NewMockLog() }
+	return func() {
+		getAppConfig = appconfig.Config
+		getLogger = func() log.T { return ssmlog.SSMLogger(true) }
+	}
+}
+
+func TestGetDefaultTLSConfigDefaults(t *testing.T) {
+	defer setupTlsConfig(appconfig.DefaultConfig().Tls)()
+
+	tlsConfig := GetDefaultTLSConfig()
+	assert.Equal(t, uint16(tls.VersionTLS12), tlsConfig.MinVersion)
+	assert.Nil(t, tlsConfig.RootCAs)
+	assert.Nil(t, tlsConfig.CipherSuites)
+}
+
+func TestGetDefaultTLSConfigSettings(t *testing.T) {
+	defer setupTlsConfig(appconfig.TlsCfg{
+		MinVersion:   appconfig.TlsVersion13,
+		CipherSuites: []string{"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256", "TLS_RSA_WITH_RC4_128_SHA", "bogus"},
+	})()
+
+	tlsConfig := GetDefaultTLSConfig()
+	assert.Equal(t, uint16(tls.VersionTLS13), tlsConfig.MinVersion)
+	assert.Equal(t, []uint16{tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256}, tlsConfig.CipherSuites)
+}
+
+func TestGetDefaultTLSConfigCaBundle(t *testing.T) {
+	server := httptest.NewTLSServer(nil)
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "network")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	bundlePath := filepath.Join(dir, "ca-bundle.pem")
+	bundle := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	assert.NoError(t, ioutil.WriteFile(bundlePath, bundle, 0600))
+
+	defer setupTlsConfig(appconfig.TlsCfg{CaBundlePath: bundlePath})()
+	tlsConfig := GetDefaultTLSConfig()
+	assert.NotNil(t, tlsConfig.RootCAs)
+
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: tlsConfig}}
+	resp, err := client.Get(server.URL)
+	assert.NoError(t, err)
+	resp.Body.Close()
+}
+
+func TestGetDefaultTLSConfigMissingCaBundle(t *testing.T) {
+	defer setupTlsConfig(appconfig.TlsCfg{CaBundlePath: "/nonexistent/ca-bundle.pem"})()
+
+	assert.Nil(t, GetDefaultTLSConfig().RootCAs)
+}
@@ -0,0 +1,319 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package outbox stores results the agent could not send because the service was unreachable, and sends
+// them in the order they were produced once the service can be reached again.
+package outbox
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/fileutil"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/network/failover"
+	"github.com/aws/amazon-ssm-agent/agent/schedule"
+	"github.com/aws/amazon-ssm-agent/agent/statecrypto"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+)
+
+const (
+	// KindCommandReply is the kind of queued MDS SendReply requests
+	KindCommandReply = "commandReply"
+	// KindAssociationStatus is the kind of queued association execution results
+	KindAssociationStatus = "associationStatus"
+	// KindInventory is the kind of queued PutInventory requests
+	KindInventory = "inventory"
+	// KindHealth is the kind of queued health pings
+	KindHealth = "health"
+)
+
+// Sender sends a queued entry to the service, the payload is the JSON the entry was queued with
+type Sender func(log log.T, payload []byte) error
+
+// coalescedKinds only need their latest entry, queuing one replaces the older entries of the same kind
+var coalescedKinds = map[string]bool{
+	KindHealth:    true,
+	KindInventory: true,
+}
+
+var (
+	getAppConfig = appconfig.Config
+	timeNow      = time.Now
+
+	// queueDir holds one file per entry, named so that sorting the names orders the entries by age
+	queueDir = filepath.Join(appconfig.DefaultDataStorePath, "outbox")
+
+	queueLock sync.Mutex
+	flushLock sync.Mutex
+	sequence  int64
+
+	sendersLock sync.RWMutex
+	senders     = map[string]Sender{}
+
+	flushJobLock sync.Mutex
+	flushJob     *schedule.Job
+)
+
+// entry is a queued payload, its file name is <created unix nanos>-<sequence>.<kind>.json
+type entry struct {
+	name    string
+	kind    string
+	created time.Time
+	size    int64
+}
+
+// Dir returns the folder of the queued entries
+func Dir() string {
+	return queueDir
+}
+
+// Enabled returns true when results are queued while the service is unreachable
+func Enabled() bool {
+	config, err := getAppConfig(false)
+	return err == nil && config.Offline.Enabled
+}
+
+// RegisterSender sets the sender of queued entries of the kind, a nil sender keeps the entries queued
+func RegisterSender(kind string, sender Sender) {
+	sendersLock.Lock()
+	defer sendersLock.Unlock()
+	if sender == nil {
+		delete(senders, kind)
+		return
+	}
+	senders[kind] = sender
+}
+
+// Send calls send, or queues the payload when the service is unreachable or older entries of the kind are
+// still queued, so that the service receives them in order. It returns true when the payload was queued.
+func Send(log log.T, kind string, payload interface{}, send func() error) (queued bool, err error) {
+	if !Enabled() {
+		return false, send()
+	}
+	if Pending(kind) == 0 {
+		if err = send(); err == nil || !isUnreachable(err) {
+			return false, err
+		}
+		log.Warnf("Service is unreachable, queuing %v to send it later: %v", kind, err)
+	}
+	if err = Enqueue(log, kind, payload); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Enqueue stores the payload, dropping expired entries and the oldest entries above the size limit
+func Enqueue(log log.T, kind string, payload interface{}) error {
+	content, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal queued %v: %w", kind, err)
+	}
+	if content, err = statecrypto.Seal(log, content); err != nil {
+		return fmt.Errorf("failed to encrypt queued %v: %w", kind, err)
+	}
+
+	queueLock.Lock()
+	defer queueLock.Unlock()
+
+	if err = fileutil.MakeDirs(queueDir); err != nil {
+		return err
+	}
+	if coalescedKinds[kind] {
+		for _, e := range entries(kind) {
+			os.Remove(filepath.Join(queueDir, e.name))
+		}
+	}
+
+	sequence++
+	name := fmt.Sprintf("%019d-%06d.%v.json", timeNow().UnixNano(), sequence%1000000, kind)
+	tempFile, err := ioutil.TempFile(queueDir, ".tmp")
+	if err != nil {
+		return err
+	}
+	tempFile.Close()
+	defer os.Remove(tempFile.Name())
+	if err = ioutil.WriteFile(tempFile.Name(), content, appconfig.ReadWriteAccess); err != nil {
+		return err
+	}
+	if err = os.Rename(tempFile.Name(), filepath.Join(queueDir, name)); err != nil {
+		return err
+	}
+
+	prune(log)
+	return nil
+}
+
+// Pending returns the number of queued entries of the kind, or of all kinds when kind is empty
+func Pending(kind string) int {
+	queueLock.Lock()
+	defer queueLock.Unlock()
+	return len(entries(kind))
+}
+
+// Flush sends the queued entries from the oldest, and stops at the first entry that fails because the service
+// is still unreachable. Entries the service rejects are dropped, since sending them again would fail the same way.
+// Entries without a registered sender stay queued. It returns the number of entries sent.
+func Flush(log log.T) (sent int) {
+	flushLock.Lock()
+	defer flushLock.Unlock()
+
+	queueLock.Lock()
+	prune(log)
+	queued := entries("")
+	queueLock.Unlock()
+
+	for _, e := range queued {
+		sendersLock.RLock()
+		sender := senders[e.kind]
+		sendersLock.RUnlock()
+		if sender == nil {
+			continue
+		}
+
+		path := filepath.Join(queueDir, e.name)
+		payload, err := ioutil.ReadFile(path)
+		if err != nil {
+			// a coalesced entry was replaced while flushing
+			continue
+		}
+		if payload, err = statecrypto.Open(log, payload); err != nil {
+			log.Errorf("Dropping queued %v %v that cannot be decrypted: %v", e.kind, e.name, err)
+			os.Remove(path)
+			continue
+		}
+		if err = sender(log, payload); err != nil {
+			if isUnreachable(err) {
+				log.Debugf("Service is still unreachable, keeping the queued entries: %v", err)
+				return sent
+			}
+			log.Errorf("Dropping queued %v %v rejected by the service: %v", e.kind, e.name, err)
+		} else {
+			sent++
+		}
+		os.Remove(path)
+	}
+	if sent > 0 {
+		log.Infof("Sent %v queued entries to the service", sent)
+	}
+	return sent
+}
+
+// Start flushes the queue on the configured interval until Stop is called
+func Start(log log.T) {
+	if !Enabled() {
+		return
+	}
+	config, _ := getAppConfig(false)
+	interval := time.Duration(config.Offline.FlushIntervalSeconds) * time.Second
+
+	flushJobLock.Lock()
+	defer flushJobLock.Unlock()
+	if flushJob != nil {
+		return
+	}
+	flushJob = schedule.Every(interval, func() {
+		Flush(log)
+	})
+}
+
+// Stop ends the flushes started by Start
+func Stop() {
+	flushJobLock.Lock()
+	defer flushJobLock.Unlock()
+	if flushJob != nil {
+		flushJob.Stop()
+		flushJob = nil
+	}
+}
+
+// prune drops expired entries and the oldest entries above the size limit, the caller holds queueLock
+func prune(log log.T) {
+	config, err := getAppConfig(false)
+	if err != nil {
+		return
+	}
+	maxAge := time.Duration(config.Offline.MaxEntryAgeHours) * time.Hour
+	maxSize := int64(config.Offline.MaxQueueSizeMB) * 1024 * 1024
+
+	queued := entries("")
+	var size int64
+	for _, e := range queued {
+		size += e.size
+	}
+	now := timeNow()
+	for _, e := range queued {
+		if now.Sub(e.created) > maxAge {
+			log.Warnf("Dropping queued %v %v older than %v", e.kind, e.name, maxAge)
+		} else if size > maxSize {
+			log.Warnf("Dropping queued %v %v, the queue is above %v MB", e.kind, e.name, config.Offline.MaxQueueSizeMB)
+		} else {
+			continue
+		}
+		os.Remove(filepath.Join(queueDir, e.name))
+		size -= e.size
+	}
+}
+
+// entries lists the queued entries of the kind from the oldest, all kinds when kind is empty
+func entries(kind string) []entry {
+	files, err := ioutil.ReadDir(queueDir)
+	if err != nil {
+		return nil
+	}
+	var queued []entry
+	for _, file := range files {
+		e, ok := parseEntry(file)
+		if ok && (kind == "" || e.kind == kind) {
+			queued = append(queued, e)
+		}
+	}
+	sort.Slice(queued, func(i, j int) bool {
+		return queued[i].name < queued[j].name
+	})
+	return queued
+}
+
+func parseEntry(file os.FileInfo) (e entry, ok bool) {
+	if !file.Mode().IsRegular() || !strings.HasSuffix(file.Name(), ".json") {
+		return e, false
+	}
+	parts := strings.SplitN(strings.TrimSuffix(file.Name(), ".json"), ".", 2)
+	if len(parts) != 2 {
+		return e, false
+	}
+	nanos, err := strconv.ParseInt(strings.SplitN(parts[0], "-", 2)[0], 10, 64)
+	if err != nil {
+		return e, false
+	}
+	return entry{name: file.Name(), kind: parts[1], created: time.Unix(0, nanos), size: file.Size()}, true
+}
+
+// isUnreachable looks through wrapped errors for the service error the failover uses to detect outages
+func isUnreachable(err error) bool {
+	var aErr awserr.Error
+	if errors.As(err, &aErr) {
+		return failover.IsUnreachable(aErr)
+	}
+	return failover.IsUnreachable(err)
+}
@@ -0,0 +1,158 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package outbox
+
+import (
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/stretchr/testify/assert"
+)
+
+var logMock = log.NewMockLog()
+
+var (
+	errUnreachable = awserr.New("RequestError", "send request failed", errors.New("dial tcp: connection refused"))
+	errRejected    = awserr.NewRequestFailure(awserr.New("ValidationException", "invalid input", nil), 400, "request")
+)
+
+func mockQueue(t *testing.T, enabled bool, maxSizeMB int) func() {
+	config := appconfig.DefaultConfig()
+	config.Offline.Enabled = enabled
+	config.Offline.MaxQueueSizeMB = maxSizeMB
+	originalConfig, originalDir, originalNow := getAppConfig, queueDir, timeNow
+	getAppConfig = func(bool) (appconfig.SsmagentConfig, error) { return config, nil }
+	queueDir = t.TempDir()
+	return func() {
+		getAppConfig, queueDir, timeNow = originalConfig, originalDir, originalNow
+		senders = map[string]Sender{}
+	}
+}
+
+func TestSendWhenDisabled(t *testing.T) {
+	defer mockQueue(t, false, 1)()
+
+	queued, err := Send(logMock, KindCommandReply, "reply", func() error { return errUnreachable })
+	assert.False(t, queued)
+	assert.Equal(t, errUnreachable, err)
+	assert.Equal(t, 0, Pending(""))
+}
+
+func TestSendQueuesWhileUnreachable(t *testing.T) {
+	defer mockQueue(t, true, 1)()
+
+	calls := 0
+	queued, err := Send(logMock, KindCommandReply, "first", func() error { calls++; return errUnreachable })
+	assert.True(t, queued)
+	assert.NoError(t, err)
+
+	// later replies wait behind the queued one without calling the service
+	queued, err = Send(logMock, KindCommandReply, "second", func() error { calls++; return nil })
+	assert.True(t, queued)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, calls)
+	assert.Equal(t, 2, Pending(KindCommandReply))
+
+	// rejected requests are returned to the caller instead of being queued
+	queued, err = Send(logMock, KindAssociationStatus, "status", func() error { return errRejected })
+	assert.False(t, queued)
+	assert.Equal(t, errRejected, err)
+	assert.Equal(t, 0, Pending(KindAssociationStatus))
+}
+
+func TestFlushInOrder(t *testing.T) {
+	defer mockQueue(t, true, 1)()
+
+	assert.NoError(t, Enqueue(logMock, KindCommandReply, "first"))
+	assert.NoError(t, Enqueue(logMock, KindAssociationStatus, "second"))
+	assert.NoError(t, Enqueue(logMock, KindCommandReply, "third"))
+	assert.NoError(t, Enqueue(logMock, KindInventory, "unregistered"))
+
+	var sent []string
+	reachable := false
+	sender := func(log log.T, payload []byte) error {
+		if !reachable {
+			return errUnreachable
+		}
+		sent = append(sent, string(payload))
+		return nil
+	}
+	RegisterSender(KindCommandReply, sender)
+	RegisterSender(KindAssociationStatus, sender)
+
+	assert.Equal(t, 0, Flush(logMock))
+	assert.Equal(t, 4, Pending(""))
+
+	reachable = true
+	assert.Equal(t, 3, Flush(logMock))
+	assert.Equal(t, []string{`"first"`, `"second"`, `"third"`}, sent)
+	assert.Equal(t, 1, Pending(KindInventory))
+}
+
+func TestFlushDropsRejected(t *testing.T) {
+	defer mockQueue(t, true, 1)()
+
+	assert.NoError(t, Enqueue(logMock, KindCommandReply, "rejected"))
+	assert.NoError(t, Enqueue(logMock, KindCommandReply, "accepted"))
+	RegisterSender(KindCommandReply, func(log log.T, payload []byte) error {
+		if string(payload) == `"rejected"` {
+			return errRejected
+		}
+		return nil
+	})
+
+	assert.Equal(t, 1, Flush(logMock))
+	assert.Equal(t, 0, Pending(""))
+}
+
+func TestEnqueueCoalesces(t *testing.T) {
+	defer mockQueue(t, true, 1)()
+
+	assert.NoError(t, Enqueue(logMock, KindHealth, "older"))
+	assert.NoError(t, Enqueue(logMock, KindHealth, "newer"))
+	assert.NoError(t, Enqueue(logMock, KindCommandReply, "reply"))
+	assert.NoError(t, Enqueue(logMock, KindCommandReply, "reply"))
+
+	assert.Equal(t, 1, Pending(KindHealth))
+	assert.Equal(t, 2, Pending(KindCommandReply))
+	content, err := ioutil.ReadFile(filepath.Join(queueDir, entries(KindHealth)[0].name))
+	assert.NoError(t, err)
+	assert.Equal(t, `"newer"`, string(content))
+}
+
+func TestEnqueueDropsExpiredAndOversized(t *testing.T) {
+	defer mockQueue(t, true, 1)()
+
+	now := time.Now()
+	timeNow = func() time.Time { return now.Add(-100 * time.Hour) }
+	assert.NoError(t, Enqueue(logMock, KindCommandReply, "expired"))
+	timeNow = func() time.Time { return now }
+	assert.NoError(t, Enqueue(logMock, KindCommandReply, "recent"))
+	assert.Equal(t, 1, Pending(""))
+
+	large := make([]byte, 700*1024)
+	assert.NoError(t, Enqueue(logMock, KindAssociationStatus, large))
+	assert.NoError(t, Enqueue(logMock, KindAssociationStatus, large))
+
+	// the oldest entries are dropped until the queue fits in 1 MB
+	queued := entries("")
+	assert.Equal(t, 1, len(queued))
+	assert.Equal(t, KindAssociationStatus, queued[0].kind)
+}
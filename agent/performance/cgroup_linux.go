@@ -0,0 +1,162 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// +build linux
+
+package performance
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// unlimitedMemory is the smallest memory limit of cgroup v1 that means no limit, the kernel reports the largest page
+// aligned value
+const unlimitedMemory = int64(1) << 62
+
+var (
+	procCgroupFile = "/proc/self/cgroup"
+	cgroupRoot     = "/sys/fs/cgroup"
+)
+
+// cgroupLimits returns the memory limit in bytes and the CPU quota in CPUs of the cgroup of the process, the
+// smallest limits of its ancestors apply. Limits are zero when unlimited or unknown.
+func cgroupLimits() (memoryLimit int64, cpuQuota float64) {
+	content, err := ioutil.ReadFile(procCgroupFile)
+	if err != nil {
+		return 0, 0
+	}
+	for _, line := range strings.Split(strings.TrimSpace(string(content)), "\n") {
+		// hierarchy-ID:controller-list:cgroup-path
+		fields := strings.SplitN(line, ":", 3)
+		if len(fields) != 3 {
+			continue
+		}
+		path := fields[2]
+		if fields[0] == "0" && fields[1] == "" {
+			// cgroup v2 unified hierarchy
+			forEachAncestor(cgroupRoot, path, func(dir string) {
+				memoryLimit = smallerLimit(memoryLimit, readMemoryMax(dir))
+				cpuQuota = smallerQuota(cpuQuota, readCPUMax(dir))
+			})
+			continue
+		}
+		for _, controller := range strings.Split(fields[1], ",") {
+			switch controller {
+			case "memory":
+				forEachAncestor(filepath.Join(cgroupRoot, "memory"), path, func(dir string) {
+					memoryLimit = smallerLimit(memoryLimit, readMemoryLimitInBytes(dir))
+				})
+			case "cpu":
+				forEachAncestor(filepath.Join(cgroupRoot, "cpu"), path, func(dir string) {
+					cpuQuota = smallerQuota(cpuQuota, readCFSQuota(dir))
+				})
+			}
+		}
+	}
+	return memoryLimit, cpuQuota
+}
+
+// forEachAncestor calls fn with the directory of the cgroup path under root and the directories of its ancestors
+func forEachAncestor(root string, path string, fn func(dir string)) {
+	dir := filepath.Join(root, path)
+	for {
+		fn(dir)
+		if len(dir) <= len(root) {
+			return
+		}
+		dir = filepath.Dir(dir)
+	}
+}
+
+// readMemoryMax returns the cgroup v2 memory limit of dir
+func readMemoryMax(dir string) int64 {
+	fields := readFields(filepath.Join(dir, "memory.max"))
+	if len(fields) != 1 {
+		return 0
+	}
+	limit, _ := strconv.ParseInt(fields[0], 10, 64)
+	return limit
+}
+
+// readCPUMax returns the cgroup v2 CPU quota of dir
+func readCPUMax(dir string) float64 {
+	fields := readFields(filepath.Join(dir, "cpu.max"))
+	if len(fields) != 2 {
+		return 0
+	}
+	return quota(fields[0], fields[1])
+}
+
+// readMemoryLimitInBytes returns the cgroup v1 memory limit of dir
+func readMemoryLimitInBytes(dir string) int64 {
+	fields := readFields(filepath.Join(dir, "memory.limit_in_bytes"))
+	if len(fields) != 1 {
+		return 0
+	}
+	limit, _ := strconv.ParseInt(fields[0], 10, 64)
+	if limit >= unlimitedMemory {
+		return 0
+	}
+	return limit
+}
+
+// readCFSQuota returns the cgroup v1 CPU quota of dir
+func readCFSQuota(dir string) float64 {
+	quotaFields := readFields(filepath.Join(dir, "cpu.cfs_quota_us"))
+	periodFields := readFields(filepath.Join(dir, "cpu.cfs_period_us"))
+	if len(quotaFields) != 1 || len(periodFields) != 1 {
+		return 0
+	}
+	return quota(quotaFields[0], periodFields[0])
+}
+
+// quota returns the CPUs of a quota of microseconds per period, zero when the quota is unlimited
+func quota(quotaValue string, periodValue string) float64 {
+	runtime, err := strconv.ParseInt(quotaValue, 10, 64)
+	if err != nil || runtime <= 0 {
+		return 0
+	}
+	period, err := strconv.ParseInt(periodValue, 10, 64)
+	if err != nil || period <= 0 {
+		return 0
+	}
+	return float64(runtime) / float64(period)
+}
+
+// readFields returns the whitespace separated fields of a cgroup interface file, nil when it cannot be read
+func readFields(path string) []string {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	return strings.Fields(string(content))
+}
+
+// smallerLimit returns the smaller of two memory limits, zero is no limit
+func smallerLimit(current int64, limit int64) int64 {
+	if limit > 0 && (current == 0 || limit < current) {
+		return limit
+	}
+	return current
+}
+
+// smallerQuota returns the smaller of two CPU quotas, zero is no quota
+func smallerQuota(current float64, quota float64) float64 {
+	if quota > 0 && (current == 0 || quota < current) {
+		return quota
+	}
+	return current
+}
@@ -0,0 +1,87 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// +build linux
+
+package performance
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeCgroups points the cgroup detection at a temporary directory
+func fakeCgroups(t *testing.T, procCgroup string, files map[string]string) func() {
+	dir, err := ioutil.TempDir("", "cgroup")
+	assert.NoError(t, err)
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		assert.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		assert.NoError(t, ioutil.WriteFile(path, []byte(content), 0644))
+	}
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "cgroup"), []byte(procCgroup), 0644))
+
+	defaultProcCgroupFile, defaultCgroupRoot := procCgroupFile, cgroupRoot
+	procCgroupFile, cgroupRoot = filepath.Join(dir, "cgroup"), filepath.Join(dir, "fs")
+	return func() {
+		procCgroupFile, cgroupRoot = defaultProcCgroupFile, defaultCgroupRoot
+		os.RemoveAll(dir)
+	}
+}
+
+func TestCgroupLimitsV2(t *testing.T) {
+	defer fakeCgroups(t, "0::/system.slice/amazon-ssm-agent.service\n", map[string]string{
+		"fs/memory.max":                                          "max\n",
+		"fs/system.slice/memory.max":                             "2147483648\n",
+		"fs/system.slice/cpu.max":                                "max 100000\n",
+		"fs/system.slice/amazon-ssm-agent.service/memory.max":    "4294967296\n",
+		"fs/system.slice/amazon-ssm-agent.service/cpu.max":       "150000 100000\n",
+		"fs/system.slice/amazon-ssm-agent.service/memory.events": "low 0\n",
+	})()
+
+	memoryLimit, cpuQuota := cgroupLimits()
+
+	// the smaller limit of the parent applies
+	assert.Equal(t, int64(2147483648), memoryLimit)
+	assert.Equal(t, 1.5, cpuQuota)
+}
+
+func TestCgroupLimitsV1(t *testing.T) {
+	defer fakeCgroups(t, "5:memory:/docker/abc\n4:cpu,cpuacct:/docker/abc\n1:name=systemd:/docker/abc\n", map[string]string{
+		"fs/memory/memory.limit_in_bytes":            "9223372036854771712\n",
+		"fs/memory/docker/abc/memory.limit_in_bytes": "536870912\n",
+		"fs/cpu/docker/abc/cpu.cfs_quota_us":         "200000\n",
+		"fs/cpu/docker/abc/cpu.cfs_period_us":        "100000\n",
+	})()
+
+	memoryLimit, cpuQuota := cgroupLimits()
+
+	assert.Equal(t, int64(536870912), memoryLimit)
+	assert.Equal(t, 2.0, cpuQuota)
+}
+
+func TestCgroupLimitsUnlimited(t *testing.T) {
+	defer fakeCgroups(t, "0::/\n", map[string]string{
+		"fs/memory.max": "max\n",
+		"fs/cpu.max":    "max 100000\n",
+	})()
+
+	memoryLimit, cpuQuota := cgroupLimits()
+
+	assert.Equal(t, int64(0), memoryLimit)
+	assert.Equal(t, 0.0, cpuQuota)
+}
@@ -0,0 +1,72 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package performance tunes the Go runtime of the agent processes for the configured performance profile.
+package performance
+
+import (
+	"math"
+	"os"
+	"runtime"
+	"runtime/debug"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+)
+
+// profile is the runtime tuning of a performance profile
+type profile struct {
+	// gcPercent is the GOGC of the profile
+	gcPercent int
+	// memoryLimitPercent is the share of the memory limit of the cgroup the runtime tries to stay below
+	memoryLimitPercent int64
+}
+
+var profiles = map[string]profile{
+	appconfig.PerformanceProfileLatency:   {gcPercent: 200, memoryLimitPercent: 90},
+	appconfig.PerformanceProfileFootprint: {gcPercent: 50, memoryLimitPercent: 70},
+}
+
+var (
+	detectLimits   = cgroupLimits
+	getenv         = os.Getenv
+	numCPU         = runtime.NumCPU
+	setMaxProcs    = runtime.GOMAXPROCS
+	setGCPercent   = debug.SetGCPercent
+	setMemoryLimit = debug.SetMemoryLimit
+)
+
+// Apply tunes the runtime of the process for the profile of config
+func Apply(log log.T, config appconfig.PerformanceCfg) {
+	p, found := profiles[config.Profile]
+	if !found {
+		return
+	}
+	memoryLimit, cpuQuota := detectLimits()
+
+	if getenv("GOMAXPROCS") == "" && cpuQuota > 0 {
+		if procs := int(math.Ceil(cpuQuota)); procs < numCPU() {
+			setMaxProcs(procs)
+			log.Infof("%v performance profile, GOMAXPROCS set to the CPU quota %v", config.Profile, procs)
+		}
+	}
+	if getenv("GOGC") == "" {
+		setGCPercent(p.gcPercent)
+		log.Infof("%v performance profile, GOGC set to %v", config.Profile, p.gcPercent)
+	}
+	if getenv("GOMEMLIMIT") == "" && memoryLimit > 0 {
+		limit := memoryLimit / 100 * p.memoryLimitPercent
+		setMemoryLimit(limit)
+		log.Infof("%v performance profile, GOMEMLIMIT set to %v bytes of the %v bytes cgroup limit", config.Profile, limit, memoryLimit)
+	}
+}
@@ -0,0 +1,88 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package performance
+
+import (
+	"testing"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/stretchr/testify/assert"
+)
+
+// runtimeSettings records the runtime settings applied by a test
+type runtimeSettings struct {
+	maxProcs    int
+	gcPercent   int
+	memoryLimit int64
+}
+
+func fakeRuntime(environment map[string]string, memoryLimit int64, cpuQuota float64) *runtimeSettings {
+	settings := &runtimeSettings{}
+	detectLimits = func() (int64, float64) { return memoryLimit, cpuQuota }
+	getenv = func(key string) string { return environment[key] }
+	numCPU = func() int { return 8 }
+	setMaxProcs = func(n int) int { settings.maxProcs = n; return 8 }
+	setGCPercent = func(percent int) int { settings.gcPercent = percent; return 100 }
+	setMemoryLimit = func(limit int64) int64 { settings.memoryLimit = limit; return 0 }
+	return settings
+}
+
+func TestApplyLatencyProfile(t *testing.T) {
+	settings := fakeRuntime(nil, 1000*1024*1024, 1.5)
+
+	Apply(log.NewMockLog(), appconfig.PerformanceCfg{Profile: appconfig.PerformanceProfileLatency})
+
+	assert.Equal(t, 2, settings.maxProcs)
+	assert.Equal(t, 200, settings.gcPercent)
+	assert.Equal(t, int64(900*1024*1024), settings.memoryLimit)
+}
+
+func TestApplyFootprintProfile(t *testing.T) {
+	settings := fakeRuntime(nil, 1000*1024*1024, 16)
+
+	Apply(log.NewMockLog(), appconfig.PerformanceCfg{Profile: appconfig.PerformanceProfileFootprint})
+
+	// a quota above the CPUs of the host does not change GOMAXPROCS
+	assert.Equal(t, 0, settings.maxProcs)
+	assert.Equal(t, 50, settings.gcPercent)
+	assert.Equal(t, int64(700*1024*1024), settings.memoryLimit)
+}
+
+func TestApplyWithoutCgroupLimits(t *testing.T) {
+	settings := fakeRuntime(nil, 0, 0)
+
+	Apply(log.NewMockLog(), appconfig.PerformanceCfg{Profile: appconfig.PerformanceProfileLatency})
+
+	assert.Equal(t, 0, settings.maxProcs)
+	assert.Equal(t, 200, settings.gcPercent)
+	assert.Equal(t, int64(0), settings.memoryLimit)
+}
+
+func TestApplyKeepsEnvironmentSettings(t *testing.T) {
+	environment := map[string]string{"GOGC": "off", "GOMEMLIMIT": "512MiB", "GOMAXPROCS": "4"}
+	settings := fakeRuntime(environment, 1000*1024*1024, 1)
+
+	Apply(log.NewMockLog(), appconfig.PerformanceCfg{Profile: appconfig.PerformanceProfileFootprint})
+
+	assert.Equal(t, runtimeSettings{}, *settings)
+}
+
+func TestApplyDefaultProfile(t *testing.T) {
+	settings := fakeRuntime(nil, 1000*1024*1024, 1)
+
+	Apply(log.NewMockLog(), appconfig.PerformanceCfg{Profile: appconfig.PerformanceProfileDefault})
+
+	assert.Equal(t, runtimeSettings{}, *settings)
+}
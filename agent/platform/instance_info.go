@@ -20,12 +20,19 @@ import (
 	"sync"
 
 	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/log"
 	"github.com/aws/amazon-ssm-agent/agent/platform/containers"
 )
 
 var cachedRegion, cachedAvailabilityZone, cachedInstanceType, cachedInstanceID, cachedTargetID string
 var lock sync.RWMutex
 
+// regionOverridden is set when the region was given explicitly, it is kept when the instance information is invalidated
+var regionOverridden bool
+
+var cachedIdentityDocument *InstanceIdentityDocument
+var identityLock sync.Mutex
+
 const errorMessage = "Failed to fetch %s. Data from vault is empty. %v"
 
 var getConfig = appconfig.Config
@@ -123,9 +130,22 @@ func SetRegion(region string) error {
 		return fmt.Errorf("invalid region")
 	}
 	cachedRegion = region
+	regionOverridden = true
 	return nil
 }
 
+// AccountID returns the account of the EC2 instance from its identity document, managed instances have no identity document
+func AccountID() (string, error) {
+	if managedInstance.InstanceID() != "" {
+		return "", fmt.Errorf("the account of managed instances is not known")
+	}
+	document, err := identityDocument()
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch account ID: %v", err)
+	}
+	return document.AccountID, nil
+}
+
 // AvailabilityZone returns the instance availability zone
 func AvailabilityZone() (string, error) {
 	var err error
@@ -163,9 +183,97 @@ func IsManagedInstance() (bool, error) {
 	return false, nil
 }
 
+// MetadataTags returns the instance tags from the metadata service, it fails unless the instance allows access to its
+// tags in the instance metadata
+func MetadataTags() (map[string]string, error) {
+	keys, err := metadata.GetMetadata("tags/instance")
+	if err != nil {
+		return nil, err
+	}
+	tags := make(map[string]string)
+	for _, key := range strings.Split(keys, "\n") {
+		if key = strings.TrimSpace(key); key == "" {
+			continue
+		}
+		if tags[key], err = metadata.GetMetadata("tags/instance/" + key); err != nil {
+			return nil, err
+		}
+	}
+	return tags, nil
+}
+
+// DetectInstanceChange compares the cached instance id with the instance id of the metadata service, and
+// invalidates the cached instance information when they differ, as it happens when the root volume of the
+// agent is attached to another instance. It returns true when the instance information was invalidated.
+func DetectInstanceChange(log log.T) bool {
+	lock.RLock()
+	cached := cachedInstanceID
+	lock.RUnlock()
+
+	if cached == "" || managedInstance.InstanceID() != "" {
+		return false
+	}
+	if config, _ := getConfig(false); config.Agent.ContainerMode {
+		return false
+	}
+
+	current, err := metadata.GetMetadata("instance-id")
+	if err != nil || current == "" || current == cached {
+		return false
+	}
+
+	log.Warnf("Instance id changed from %v to %v, invalidating the cached instance information", cached, current)
+	InvalidateInstanceInfo()
+	return true
+}
+
+// InvalidateInstanceInfo drops the cached instance information, it is fetched again on its next use.
+// An explicitly set region is kept.
+func InvalidateInstanceInfo() {
+	lock.Lock()
+	cachedInstanceID = ""
+	cachedInstanceType = ""
+	cachedAvailabilityZone = ""
+	if !regionOverridden {
+		cachedRegion = ""
+	}
+	lock.Unlock()
+
+	identityLock.Lock()
+	cachedIdentityDocument = nil
+	identityLock.Unlock()
+}
+
+// verifiedIdentity returns true when the instance information must come from the verified identity document
+func verifiedIdentity() bool {
+	config, err := getConfig(false)
+	return err == nil && config.Imds.VerifyIdentityDocument && !config.Agent.ContainerMode
+}
+
+// identityDocument returns the verified instance identity document, it is cached until the instance information
+// is invalidated
+func identityDocument() (*InstanceIdentityDocument, error) {
+	identityLock.Lock()
+	defer identityLock.Unlock()
+	if cachedIdentityDocument != nil {
+		return cachedIdentityDocument, nil
+	}
+
+	document, err := dynamicData.IdentityDocument()
+	if err != nil {
+		return nil, err
+	}
+	if document == nil || document.InstanceID == "" {
+		return nil, fmt.Errorf("instance identity document is empty")
+	}
+	cachedIdentityDocument = document
+	return cachedIdentityDocument, nil
+}
+
 // fetchInstanceID fetches the instance id with the following preference order.
 // 1. managed instance registration
-// 2. EC2 Instance Metadata
+// 2. verified EC2 Instance Identity Document, when identity document verification is enabled
+// 3. EC2 Instance Metadata
 func fetchInstanceID() (string, error) {
 	var err error
 	var instanceID string
@@ -184,6 +292,15 @@ func fetchInstanceID() (string, error) {
 		return instanceID, nil
 	}
 
+	// trying to get instance id from the verified identity document, without falling back to unverified data
+	if verifiedIdentity() {
+		document, err := identityDocument()
+		if err != nil {
+			return "", fmt.Errorf(errorMessage, "instance ID", err)
+		}
+		return document.InstanceID, nil
+	}
+
 	// trying to get instance id from ec2 metadata
 	if instanceID, err = metadata.GetMetadata("instance-id"); instanceID != "" && err == nil {
 		return instanceID, nil
@@ -214,6 +331,15 @@ func fetchInstanceType() (string, error) {
 		return instanceType, nil
 	}
 
+	// trying to get instance type from the verified identity document
+	if verifiedIdentity() {
+		document, err := identityDocument()
+		if err != nil {
+			return "", fmt.Errorf(errorMessage, "instance Type", err)
+		}
+		return document.InstanceType, nil
+	}
+
 	// trying to get instance id from ec2 metadata
 	if instanceType, err = metadata.GetMetadata("instance-type"); instanceType != "" && err == nil {
 		return instanceType, nil
@@ -242,6 +368,15 @@ func fetchRegion() (string, error) {
 		return region, nil
 	}
 
+	// trying to get region from the verified identity document
+	if verifiedIdentity() {
+		document, err := identityDocument()
+		if err != nil {
+			return "", fmt.Errorf(errorMessage, "region", err)
+		}
+		return document.Region, nil
+	}
+
 	// trying to get region from metadata
 	if region, err = metadata.Region(); region != "" && err == nil {
 		return region, nil
@@ -269,6 +404,15 @@ func fetchAvailabilityZone() (string, error) {
 		return availabilityZone, nil
 	}
 
+	// trying to get availability zone from the verified identity document
+	if verifiedIdentity() {
+		document, err := identityDocument()
+		if err != nil {
+			return "", fmt.Errorf(errorMessage, "availability zone", err)
+		}
+		return document.AvailabilityZone, nil
+	}
+
 	// trying to get instance id from ec2 metadata
 	if availabilityZone, err = metadata.GetMetadata("placement/availability-zone"); availabilityZone != "" && err == nil {
 		return availabilityZone, nil
@@ -15,12 +15,15 @@
 package platform
 
 import (
+	"net/url"
 	"runtime"
 
 	"github.com/aws/amazon-ssm-agent/agent/managedInstances/registration"
 	"github.com/aws/amazon-ssm-agent/agent/platform/containers"
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/aws/ec2metadata"
+	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/aws/session"
 )
 
@@ -50,9 +53,12 @@ func (instanceInfo) InstanceType() string { return registration.InstanceType() }
 // AvailabilityZone returns the managed instance availabilityZone
 func (instanceInfo) AvailabilityZone() string { return registration.AvailabilityZone() }
 
+// tokenOperationName is the operation of the sdk metadata client requesting the IMDSv2 session token
+const tokenOperationName = "GetToken"
+
 // dependency for metadata
 var metadata metadataClient = instanceMetadata{
-	Client: ec2metadata.New(session.New(aws.NewConfig().WithMaxRetries(10).WithEC2MetadataDisableTimeoutOverride(false))),
+	Client: WithConfiguredMetadataEndpoint(ec2metadata.New(session.New(aws.NewConfig().WithMaxRetries(10).WithEC2MetadataDisableTimeoutOverride(false)))),
 }
 
 type metadataClient interface {
@@ -67,11 +73,35 @@ func init() {
 	// Macs don't have instance metadata
 	if runtime.GOOS == "darwin" {
 		metadata = instanceMetadata{
-			Client: ec2metadata.New(session.New(aws.NewConfig().WithMaxRetries(0).WithEC2MetadataDisableTimeoutOverride(true))),
+			Client: WithConfiguredMetadataEndpoint(ec2metadata.New(session.New(aws.NewConfig().WithMaxRetries(0).WithEC2MetadataDisableTimeoutOverride(true)))),
 		}
 	}
 }
 
+// WithConfiguredMetadataEndpoint makes an sdk metadata client, including its IMDSv2 token requests,
+// use the metadata endpoint of the configured endpoint mode, and fail the requests without a session token
+// when IMDSv2 is required.
+// The endpoint is chosen per request so the client can be created before the agent config is loaded.
+func WithConfiguredMetadataEndpoint(client *ec2metadata.EC2Metadata) *ec2metadata.EC2Metadata {
+	client.Handlers.Build.PushBack(func(r *request.Request) {
+		if serviceURL, err := url.Parse(ec2MetadataServiceURL()); err == nil {
+			r.HTTPRequest.URL.Host = serviceURL.Host
+		}
+	})
+	client.Handlers.Sign.PushBack(requireTokenHandler)
+	return client
+}
+
+// requireTokenHandler runs after the token handler of the sdk client, which falls back to IMDSv1 when the token
+// request fails or times out
+func requireTokenHandler(r *request.Request) {
+	if !requireV2() || r.Operation.Name == tokenOperationName || r.HTTPRequest.Header.Get(EC2MetadataTokenHeader) != "" {
+		return
+	}
+	r.Error = awserr.New("IMDSv2Required",
+		"no IMDSv2 session token, the metadata service only answers IMDSv1 requests or the token response exceeded the hop limit of the instance", nil)
+}
+
 type instanceMetadata struct {
 	Client *ec2metadata.EC2Metadata
 }
@@ -100,12 +130,18 @@ func (d instanceDynamicData) ServiceDomain() (string, error) {
 type dynamicDataClient interface {
 	Region() (string, error)
 	ServiceDomain() (string, error)
+	IdentityDocument() (*InstanceIdentityDocument, error)
 }
 
 type instanceDynamicData struct {
 	Client *EC2MetadataClient
 }
 
+// IdentityDocument returns the instance identity document from dynamic data
+func (d instanceDynamicData) IdentityDocument() (*InstanceIdentityDocument, error) {
+	return d.Client.InstanceIdentityDocument()
+}
+
 // Region returns the region from dynamic data
 func (d instanceDynamicData) Region() (string, error) {
 	var instanceIdentityDocument *InstanceIdentityDocument
@@ -8,6 +8,7 @@ import (
 	"testing"
 
 	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/log"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -58,21 +59,28 @@ func (r registrationStub) AvailabilityZone() string { return r.availabilityZone
 
 // dynamicData stub
 type dynamicDataStub struct {
-	region  string
-	domain  string
-	err     error
-	message string
+	region   string
+	domain   string
+	document *InstanceIdentityDocument
+	err      error
+	message  string
 }
 
 func (d dynamicDataStub) Region() (string, error) { return d.region, d.err }
 
 func (d dynamicDataStub) ServiceDomain() (string, error) { return d.domain, d.err }
 
+func (d dynamicDataStub) IdentityDocument() (*InstanceIdentityDocument, error) {
+	return d.document, d.err
+}
+
 // getConfig mock
+func defaultConfigStub(reload bool) (appconfig.SsmagentConfig, error) {
+	return appconfig.DefaultConfig(), nil
+}
+
 func init() {
-	getConfig = func(reload bool) (appconfig.SsmagentConfig, error) {
-		return appconfig.DefaultConfig(), nil
-	}
+	getConfig = defaultConfigStub
 }
 
 // Examples
@@ -258,6 +266,18 @@ func TestFetchInstanceTypeForEc2Instance(t *testing.T) {
 	assert.Equal(t, nil, actualError)
 }
 
+func TestMetadataTags(t *testing.T) {
+	// the stub answers every path with the same string, the only tag key is also its value
+	metadata = &metadataStub{instanceID: "Environment"}
+	tags, err := MetadataTags()
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"Environment": "Environment"}, tags)
+
+	metadata = invalidMetadata
+	_, err = MetadataTags()
+	assert.Error(t, err)
+}
+
 func TestFetchAvailabilityZoneForEc2Instance(t *testing.T) {
 	// this tests that the metadata AZ is returned, because there
 	// is no valid managedInstance data
@@ -283,3 +303,87 @@ func TestFetchTargetID(t *testing.T) {
 		assert.Nil(t, err)
 	}
 }
+
+func verifiedIdentityConfig(reload bool) (appconfig.SsmagentConfig, error) {
+	config := appconfig.DefaultConfig()
+	config.Imds.VerifyIdentityDocument = true
+	return config, nil
+}
+
+func TestFetchInstanceInfoFromVerifiedIdentityDocument(t *testing.T) {
+	getConfig = verifiedIdentityConfig
+	defer func() { getConfig = defaultConfigStub }()
+	InvalidateInstanceInfo()
+	defer InvalidateInstanceInfo()
+
+	metadata = &metadataStub{instanceID: "i-unverified", region: "eu-west-1"}
+	managedInstance = invalidRegistration
+	dynamicData = &dynamicDataStub{document: &InstanceIdentityDocument{
+		InstanceID: sampleInstanceID, Region: sampleDynamicDataRegion, InstanceType: "t3.micro", AvailabilityZone: "us-west-2a"}}
+
+	instanceID, err := fetchInstanceID()
+	assert.Nil(t, err)
+	assert.Equal(t, sampleInstanceID, instanceID)
+	region, err := fetchRegion()
+	assert.Nil(t, err)
+	assert.Equal(t, sampleDynamicDataRegion, region)
+	instanceType, err := fetchInstanceType()
+	assert.Nil(t, err)
+	assert.Equal(t, "t3.micro", instanceType)
+	availabilityZone, err := fetchAvailabilityZone()
+	assert.Nil(t, err)
+	assert.Equal(t, "us-west-2a", availabilityZone)
+}
+
+func TestFetchInstanceIDFailsClosedWhenIdentityDocumentIsNotVerified(t *testing.T) {
+	getConfig = verifiedIdentityConfig
+	defer func() { getConfig = defaultConfigStub }()
+	InvalidateInstanceInfo()
+	defer InvalidateInstanceInfo()
+
+	metadata = validMetadata
+	managedInstance = invalidRegistration
+	dynamicData = invalidDynamicData
+
+	instanceID, err := fetchInstanceID()
+	assert.NotNil(t, err)
+	assert.Equal(t, "", instanceID)
+}
+
+func TestAccountID(t *testing.T) {
+	InvalidateInstanceInfo()
+	defer InvalidateInstanceInfo()
+
+	managedInstance = invalidRegistration
+	dynamicData = &dynamicDataStub{document: &InstanceIdentityDocument{InstanceID: sampleInstanceID, AccountID: "123456789012"}}
+	accountID, err := AccountID()
+	assert.Nil(t, err)
+	assert.Equal(t, "123456789012", accountID)
+
+	InvalidateInstanceInfo()
+	managedInstance = registrationStub{instanceID: "mi-1234567890abcdef0"}
+	accountID, err = AccountID()
+	assert.NotNil(t, err)
+	assert.Equal(t, "", accountID)
+}
+
+func TestDetectInstanceChange(t *testing.T) {
+	InvalidateInstanceInfo()
+	defer InvalidateInstanceInfo()
+	managedInstance = invalidRegistration
+	SetInstanceID(sampleInstanceID)
+	SetInstanceType("t3.micro")
+
+	metadata = &metadataStub{instanceID: sampleInstanceID}
+	assert.False(t, DetectInstanceChange(log.NewMockLog()))
+	assert.Equal(t, sampleInstanceID, cachedInstanceID)
+
+	metadata = &metadataStub{instanceID: "i-0123456789abcdef0"}
+	assert.True(t, DetectInstanceChange(log.NewMockLog()))
+	assert.Equal(t, "", cachedInstanceID)
+	assert.Equal(t, "", cachedInstanceType)
+
+	instanceID, err := InstanceID()
+	assert.Nil(t, err)
+	assert.Equal(t, "i-0123456789abcdef0", instanceID)
+}
@@ -14,16 +14,28 @@
 package platform
 
 import (
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
 	"encoding/json"
+	"encoding/pem"
 	"fmt"
 	"io/ioutil"
+	"net"
 	"net/http"
+	"strings"
 	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
 )
 
 const (
 	// EC2MetadataServiceURL is url for instance metadata.
 	EC2MetadataServiceURL = "http://169.254.169.254"
+	// EC2MetadataServiceURLIPv6 is url for instance metadata on the IPv6 link local address of Nitro instances.
+	EC2MetadataServiceURLIPv6 = "http://[fd00:ec2::254]"
 	// SecurityCredentialsResource provides iam credentials
 	SecurityCredentialsResource = "/latest/meta-data/iam/security-credentials/"
 	// InstanceIdentityDocumentResource provides instance information like instance id, region, availability
@@ -115,13 +127,24 @@ func NewEC2MetadataClient() *EC2MetadataClient {
 	return &EC2MetadataClient{client: httpClient}
 }
 
-// InstanceIdentityDocument returns the instance document details querying the metadata
+// InstanceIdentityDocument returns the instance document details querying the metadata,
+// its signature is verified when the agent is configured to
 func (c EC2MetadataClient) InstanceIdentityDocument() (*InstanceIdentityDocument, error) {
 	rawIidResp, err := c.ReadResource(InstanceIdentityDocumentResource)
 	if err != nil {
 		return nil, err
 	}
 
+	if config, err := getConfig(false); err == nil && config.Imds.VerifyIdentityDocument {
+		signature, err := c.ReadResource(InstanceIdentityDocumentSignatureResource)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the instance identity document signature, %v", err)
+		}
+		if err = verifyIdentityDocument(rawIidResp, signature, config.Imds.IdentityCertificatePath); err != nil {
+			return nil, err
+		}
+	}
+
 	var iid InstanceIdentityDocument
 
 	err = json.Unmarshal(rawIidResp, &iid)
@@ -132,8 +155,60 @@ func (c EC2MetadataClient) InstanceIdentityDocument() (*InstanceIdentityDocument
 	return &iid, nil
 }
 
+// verifyIdentityDocument checks the base64 encoded SHA256 RSA signature of the document
+// with the public key of the PEM certificate stored in certificatePath
+func verifyIdentityDocument(document []byte, signature []byte, certificatePath string) error {
+	if certificatePath == "" {
+		return fmt.Errorf("no certificate to verify the instance identity document with, IdentityCertificatePath is not configured")
+	}
+	certificatePEM, err := ioutil.ReadFile(certificatePath)
+	if err != nil {
+		return fmt.Errorf("failed to read the instance identity certificate, %v", err)
+	}
+	block, _ := pem.Decode(certificatePEM)
+	if block == nil {
+		return fmt.Errorf("instance identity certificate %v is not PEM encoded", certificatePath)
+	}
+	certificate, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return fmt.Errorf("failed to parse the instance identity certificate, %v", err)
+	}
+	publicKey, ok := certificate.PublicKey.(*rsa.PublicKey)
+	if !ok {
+		return fmt.Errorf("instance identity certificate %v does not have an RSA public key", certificatePath)
+	}
+	decodedSignature, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(string(signature)), ""))
+	if err != nil {
+		return fmt.Errorf("failed to decode the instance identity document signature, %v", err)
+	}
+	digest := sha256.Sum256(document)
+	if err = rsa.VerifyPKCS1v15(publicKey, crypto.SHA256, digest[:], decodedSignature); err != nil {
+		return fmt.Errorf("instance identity document signature is not valid, %v", err)
+	}
+	return nil
+}
+
+// requireV2 returns true when the agent must not fall back to IMDSv1
+func requireV2() bool {
+	config, err := getConfig(false)
+	return err == nil && config.Imds.RequireV2
+}
+
 func (c EC2MetadataClient) resourceServiceURL(path string) string {
-	return EC2MetadataServiceURL + path
+	return ec2MetadataServiceURL() + path
+}
+
+// EC2MetadataServiceEndpoint returns the instance metadata url for the configured endpoint mode
+func EC2MetadataServiceEndpoint() string {
+	return ec2MetadataServiceURL()
+}
+
+// ec2MetadataServiceURL returns the instance metadata url for the configured endpoint mode
+func ec2MetadataServiceURL() string {
+	if config, err := getConfig(false); err == nil && config.Agent.Ec2MetadataEndpointMode == appconfig.Ec2MetadataEndpointModeIPv6 {
+		return EC2MetadataServiceURLIPv6
+	}
+	return EC2MetadataServiceURL
 }
 
 // ReadResource reads from the url path
@@ -143,6 +218,10 @@ func (c EC2MetadataClient) ReadResource(path string) ([]byte, error) {
 	var resp []byte
 	var err error
 
+	if requireV2() {
+		return c.readResourceFromMetaDataV2(endpoint)
+	}
+
 	if resp, err = c.readResourceFromMetaDataV1(endpoint); err == nil {
 		return resp, err
 	}
@@ -233,9 +312,12 @@ func (c *EC2MetadataClient) refreshToken() (err error) {
 
 	if err != nil {
 		// failed to get the new token from metadata service
-		return err
+		return tokenError(err)
 	}
 	defer rsp.Body.Close()
+	if rsp.StatusCode != EC2MetadataSuccessStatus {
+		return fmt.Errorf("IMDSv2 token request failed, response %v", rsp.Status)
+	}
 	token, err := ioutil.ReadAll(rsp.Body)
 
 	if err != nil {
@@ -245,3 +327,12 @@ func (c *EC2MetadataClient) refreshToken() (err error) {
 	metadata_token = string(token)
 	return nil
 }
+
+// tokenError explains token requests that time out, the metadata service drops the token responses that would need
+// more hops than the hop limit of the instance allows, which is the case of agents in containers with the default limit
+func tokenError(err error) error {
+	if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+		return fmt.Errorf("IMDSv2 token request timed out, if the agent runs in a container the hop limit of the instance metadata options must be at least 2, %v", err)
+	}
+	return err
+}
@@ -15,13 +15,27 @@ package platform
 
 import (
 	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
 	"encoding/json"
+	"encoding/pem"
 	"errors"
 	"io/ioutil"
+	"math/big"
 	"net/http"
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
+	"github.com/aws/aws-sdk-go/aws/request"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -192,6 +206,74 @@ func TestReadResourceFromMetaDataV2(t *testing.T) {
 	assert.Equal(t, metadata_token, testActiveToken)
 }
 
+// writeIdentityCertificate writes a self signed certificate of a new key to a temporary file
+func writeIdentityCertificate(t *testing.T) (key *rsa.PrivateKey, certificatePath string, cleanup func()) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.Nil(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "identity"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	assert.Nil(t, err)
+
+	dir, err := ioutil.TempDir("", "identity")
+	assert.Nil(t, err)
+	certificatePath = filepath.Join(dir, "identity.pem")
+	assert.Nil(t, ioutil.WriteFile(certificatePath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+	return key, certificatePath, func() { os.RemoveAll(dir) }
+}
+
+func TestVerifyIdentityDocument(t *testing.T) {
+	key, certificatePath, cleanup := writeIdentityCertificate(t)
+	defer cleanup()
+
+	document := ignoreError(json.Marshal(expectediid)).([]byte)
+	digest := sha256.Sum256(document)
+	rawSignature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	assert.Nil(t, err)
+	encoded := base64.StdEncoding.EncodeToString(rawSignature)
+	// the metadata service wraps the signature on several lines
+	signature := []byte(encoded[:64] + "\n" + encoded[64:] + "\n")
+
+	assert.Nil(t, verifyIdentityDocument(document, signature, certificatePath))
+
+	tampered := bytes.Replace(document, []byte(expectediid.InstanceID), []byte("i-00000000"), 1)
+	assert.NotNil(t, verifyIdentityDocument(tampered, signature, certificatePath))
+	assert.NotNil(t, verifyIdentityDocument(document, []byte("not a signature"), certificatePath))
+	assert.NotNil(t, verifyIdentityDocument(document, signature, ""))
+	assert.NotNil(t, verifyIdentityDocument(document, signature, certificatePath+".missing"))
+}
+
+func TestRequireTokenHandler(t *testing.T) {
+	getConfig = func(reload bool) (appconfig.SsmagentConfig, error) {
+		config := appconfig.DefaultConfig()
+		config.Imds.RequireV2 = true
+		return config, nil
+	}
+	defer func() { getConfig = appconfig.Config }()
+
+	newRequest := func(operation string) *request.Request {
+		httpRequest, _ := http.NewRequest("GET", EC2MetadataServiceURL+"/latest/meta-data/instance-id", nil)
+		return &request.Request{Operation: &request.Operation{Name: operation}, HTTPRequest: httpRequest}
+	}
+
+	r := newRequest("GetMetadata")
+	requireTokenHandler(r)
+	assert.NotNil(t, r.Error)
+
+	r = newRequest("GetMetadata")
+	r.HTTPRequest.Header.Set("x-aws-ec2-metadata-token", testActiveToken)
+	requireTokenHandler(r)
+	assert.Nil(t, r.Error)
+
+	r = newRequest(tokenOperationName)
+	requireTokenHandler(r)
+	assert.Nil(t, r.Error)
+}
+
 // TestPendingTime tests the parsing/formatting of the pending time field.
 func TestPendingTime(t *testing.T) {
 	mst := time.FixedZone("MST", -7*3600) // seven hours west of UTC
@@ -16,7 +16,9 @@ package platform
 
 import (
 	"fmt"
+	"io/ioutil"
 	"net"
+	"os"
 	"sort"
 	"strings"
 	"unicode/utf8"
@@ -58,6 +60,14 @@ var awsRegionServiceDomainMap = map[string]string{
 
 var getPlatformNameFn = getPlatformName
 
+var (
+	// containerMarkerFiles are created by container runtimes in the root of the containers they start
+	containerMarkerFiles = []string{"/.dockerenv", "/run/.containerenv"}
+	// initCgroupFile lists the control groups of the init process, they are named after the runtime in containers
+	initCgroupFile   = "/proc/1/cgroup"
+	containerCgroups = []string{"docker", "kubepods", "containerd", "libpod", "lxc"}
+)
+
 // PlatformName gets the OS specific platform name.
 func PlatformName(log log.T) (name string, err error) {
 	name, err = getPlatformNameFn(log)
@@ -116,6 +126,17 @@ func GetDefaultEndPoint(region string, service string) string {
 		}
 	}
 
+	config, err := getConfig(false)
+	if err == nil && config.Agent.UseFipsEndpoint {
+		if fipsEndpoint := getFipsServiceEndpoint(region, service, endpoint, config.Agent.UseDualStackEndpoint); fipsEndpoint != "" {
+			return fipsEndpoint
+		}
+		log.Warnf("FIPS endpoints are not available in region %v, using the standard %v endpoint", region, service)
+	}
+	if err == nil && config.Agent.UseDualStackEndpoint {
+		return getDualStackServiceEndpoint(region, service, endpoint)
+	}
+
 	if endpoint == "" || endpoint == "amazonaws.com" {
 		return ""
 	} else {
@@ -127,6 +148,42 @@ func getServiceEndpoint(region string, service string, endpoint string) string {
 	return service + "." + region + "." + endpoint
 }
 
+// getDualStackServiceEndpoint returns the endpoint of a service that resolves to both IPv4 and IPv6 addresses.
+// S3 uses its dualstack sub domain, other services use the api domain of the partition.
+func getDualStackServiceEndpoint(region string, service string, endpoint string) string {
+	if endpoint == "" {
+		endpoint = "amazonaws.com"
+	}
+	if service == "s3" {
+		return getServiceEndpoint(region, "s3.dualstack", endpoint)
+	}
+	switch endpoint {
+	case "amazonaws.com":
+		return getServiceEndpoint(region, service, "api.aws")
+	case "amazonaws.com.cn":
+		return getServiceEndpoint(region, service, "api.amazonwebservices.com.cn")
+	}
+	return getServiceEndpoint(region, service, endpoint)
+}
+
+// getFipsServiceEndpoint returns the endpoint of a service that uses FIPS validated cryptography,
+// or an empty string in the partitions without FIPS endpoints.
+func getFipsServiceEndpoint(region string, service string, endpoint string, dualStack bool) string {
+	if endpoint == "" {
+		endpoint = "amazonaws.com"
+	}
+	if endpoint != "amazonaws.com" {
+		return ""
+	}
+	if !dualStack {
+		return getServiceEndpoint(region, service+"-fips", endpoint)
+	}
+	if service == "s3" {
+		return getServiceEndpoint(region, "s3-fips.dualstack", endpoint)
+	}
+	return getServiceEndpoint(region, service+"-fips", "api.aws")
+}
+
 // IP of the network interface
 func IP() (ip string, err error) {
 
@@ -203,3 +260,41 @@ func (b byIndex) Swap(i, j int)      { b[i], b[j] = b[j], b[i] }
 func IsPlatformNanoServer(log log.T) (bool, error) {
 	return isPlatformNanoServer(log)
 }
+
+// Capabilities describes the management components available on a Windows instance,
+// Nano Server and Server Core installations ship without some of them
+type Capabilities struct {
+	NanoServer bool
+	ServerCore bool
+	Wmic       bool
+	PowerShell bool
+}
+
+var getCapabilitiesFn = getCapabilities
+
+// GetCapabilities returns the management components available on the instance
+func GetCapabilities(log log.T) Capabilities {
+	return getCapabilitiesFn(log)
+}
+
+// IsRunningInContainer returns true if the agent runs in a container, like a pod of a Kubernetes DaemonSet
+func IsRunningInContainer() bool {
+	if os.Getenv("KUBERNETES_SERVICE_HOST") != "" {
+		return true
+	}
+	for _, marker := range containerMarkerFiles {
+		if _, err := os.Stat(marker); err == nil {
+			return true
+		}
+	}
+	cgroups, err := ioutil.ReadFile(initCgroupFile)
+	if err != nil {
+		return false
+	}
+	for _, runtime := range containerCgroups {
+		if strings.Contains(string(cgroups), runtime) {
+			return true
+		}
+	}
+	return false
+}
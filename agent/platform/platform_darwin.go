@@ -91,3 +91,7 @@ func fullyQualifiedDomainName(log log.T) string {
 func isPlatformNanoServer(log log.T) (bool, error) {
 	return false, nil
 }
+
+func getCapabilities(log log.T) Capabilities {
+	return Capabilities{}
+}
@@ -16,8 +16,12 @@ package platform
 
 import (
 	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
 
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
 	logger "github.com/aws/amazon-ssm-agent/agent/log"
 	"github.com/stretchr/testify/assert"
 )
@@ -61,3 +65,94 @@ func TestPlatformWithErr(t *testing.T) {
 	assert.Equal(t, "Microsoft \xa9 sample R2 Server", name)
 	assert.NotNil(t, err)
 }
+
+func TestGetDualStackServiceEndpoint(t *testing.T) {
+	tests := []struct {
+		region   string
+		service  string
+		endpoint string
+		output   string
+	}{
+		{"us-east-1", "ssm", "", "ssm.us-east-1.api.aws"},
+		{"us-east-1", "ec2messages", "amazonaws.com", "ec2messages.us-east-1.api.aws"},
+		{"us-east-1", "s3", "", "s3.dualstack.us-east-1.amazonaws.com"},
+		{"cn-north-1", "ssmmessages", "amazonaws.com.cn", "ssmmessages.cn-north-1.api.amazonwebservices.com.cn"},
+		{"cn-north-1", "s3", "amazonaws.com.cn", "s3.dualstack.cn-north-1.amazonaws.com.cn"},
+		{"us-iso-east-1", "ssm", "c2s.ic.gov", "ssm.us-iso-east-1.c2s.ic.gov"},
+	}
+	for _, test := range tests {
+		assert.Equal(t, test.output, getDualStackServiceEndpoint(test.region, test.service, test.endpoint))
+	}
+}
+
+func TestGetFipsServiceEndpoint(t *testing.T) {
+	tests := []struct {
+		region    string
+		service   string
+		endpoint  string
+		dualStack bool
+		output    string
+	}{
+		{"us-east-1", "ssm", "", false, "ssm-fips.us-east-1.amazonaws.com"},
+		{"us-gov-west-1", "ssmmessages", "amazonaws.com", false, "ssmmessages-fips.us-gov-west-1.amazonaws.com"},
+		{"us-east-2", "kms", "amazonaws.com", true, "kms-fips.us-east-2.api.aws"},
+		{"us-west-2", "s3", "", true, "s3-fips.dualstack.us-west-2.amazonaws.com"},
+		{"cn-north-1", "ssm", "amazonaws.com.cn", false, ""},
+		{"us-iso-east-1", "ssm", "c2s.ic.gov", false, ""},
+	}
+	for _, test := range tests {
+		assert.Equal(t, test.output, getFipsServiceEndpoint(test.region, test.service, test.endpoint, test.dualStack))
+	}
+}
+
+func TestGetDefaultEndPointWithFips(t *testing.T) {
+	defer func() { getConfig = appconfig.Config }()
+
+	config := appconfig.DefaultConfig()
+	config.Agent.UseFipsEndpoint = true
+	getConfig = func(reload bool) (appconfig.SsmagentConfig, error) { return config, nil }
+	assert.Equal(t, "ec2messages-fips.us-gov-east-1.amazonaws.com", GetDefaultEndPoint("us-gov-east-1", "ec2messages"))
+	assert.Equal(t, "ssm.cn-north-1.amazonaws.com.cn", GetDefaultEndPoint("cn-north-1", "ssm"))
+}
+
+func TestEC2MetadataServiceURL(t *testing.T) {
+	defer func() { getConfig = appconfig.Config }()
+
+	config := appconfig.DefaultConfig()
+	getConfig = func(reload bool) (appconfig.SsmagentConfig, error) { return config, nil }
+	assert.Equal(t, EC2MetadataServiceURL, ec2MetadataServiceURL())
+
+	config.Agent.Ec2MetadataEndpointMode = appconfig.Ec2MetadataEndpointModeIPv6
+	assert.Equal(t, EC2MetadataServiceURLIPv6, ec2MetadataServiceURL())
+}
+
+func TestIsRunningInContainer(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "container")
+	defer os.RemoveAll(dir)
+	origMarkers, origCgroup := containerMarkerFiles, initCgroupFile
+	defer func() { containerMarkerFiles, initCgroupFile = origMarkers, origCgroup }()
+	origHost, hostSet := os.LookupEnv("KUBERNETES_SERVICE_HOST")
+	os.Unsetenv("KUBERNETES_SERVICE_HOST")
+	defer func() {
+		if hostSet {
+			os.Setenv("KUBERNETES_SERVICE_HOST", origHost)
+		}
+	}()
+
+	containerMarkerFiles = []string{filepath.Join(dir, ".dockerenv")}
+	initCgroupFile = filepath.Join(dir, "cgroup")
+	ioutil.WriteFile(initCgroupFile, []byte("0::/init.scope\n"), 0600)
+	assert.False(t, IsRunningInContainer())
+
+	ioutil.WriteFile(initCgroupFile, []byte("12:memory:/kubepods/besteffort/pod1234/abcd\n"), 0600)
+	assert.True(t, IsRunningInContainer())
+
+	os.Remove(initCgroupFile)
+	ioutil.WriteFile(containerMarkerFiles[0], nil, 0600)
+	assert.True(t, IsRunningInContainer())
+
+	os.Remove(containerMarkerFiles[0])
+	os.Setenv("KUBERNETES_SERVICE_HOST", "10.100.0.1")
+	assert.True(t, IsRunningInContainer())
+	os.Unsetenv("KUBERNETES_SERVICE_HOST")
+}
@@ -225,8 +225,14 @@ func fullyQualifiedDomainName(log log.T) string {
 		return ""
 	}
 
+	// hostname on freebsd does not support the long option
+	fqdnFlag := "--fqdn"
+	if runtime.GOOS == "freebsd" {
+		fqdnFlag = "-f"
+	}
+
 	var contentBytes []byte
-	if contentBytes, err = exec.Command(hostNameCommand, "--fqdn").Output(); err == nil {
+	if contentBytes, err = exec.Command(hostNameCommand, fqdnFlag).Output(); err == nil {
 		fqdn = string(contentBytes)
 		//trim whitespaces - since by default above command appends '\n' at the end.
 		//e.g: 'ip-172-31-7-113.ec2.internal\n'
@@ -245,3 +251,7 @@ func fullyQualifiedDomainName(log log.T) string {
 func isPlatformNanoServer(log log.T) (bool, error) {
 	return false, nil
 }
+
+func getCapabilities(log log.T) Capabilities {
+	return Capabilities{}
+}
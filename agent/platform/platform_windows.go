@@ -26,6 +26,7 @@ import (
 
 	"github.com/aws/amazon-ssm-agent/agent/appconfig"
 	"github.com/aws/amazon-ssm-agent/agent/log"
+	"golang.org/x/sys/windows/registry"
 )
 
 const caption = "Caption"
@@ -41,11 +42,24 @@ const (
 	ProductStandardNanoServer = "144"
 )
 
-// IsPlatformNanoServer returns true if SKU is 143 or 144
+// Installation types reported in the registry by the Windows Server installation options
+const (
+	installationTypeNanoServer = "Nano Server"
+	installationTypeServerCore = "Server Core"
+)
+
+const currentVersionKey = `SOFTWARE\Microsoft\Windows NT\CurrentVersion`
+
+// IsPlatformNanoServer returns true if the installation type is Nano Server or SKU is 143 or 144
 func isPlatformNanoServer(log log.T) (bool, error) {
 	var sku string
 	var err error
 
+	// Nano Server has no wmic, the installation type tells it apart without running any command
+	if installationType() == installationTypeNanoServer {
+		return true, nil
+	}
+
 	// Get platform sku information
 	if sku, err = getPlatformSku(log); err != nil {
 		log.Infof("Failed to fetch sku - %v", err)
@@ -82,6 +96,11 @@ func getPlatformDetails(property string, log log.T) (value string, err error) {
 
 	cmdName := "wmic"
 	cmdArgs := []string{"OS", "get", property, "/format:list"}
+	if !hasWmic() {
+		// wmic is not available on Nano Server, query the same class through CIM
+		cmdName = appconfig.PowerShellPluginCommandName
+		cmdArgs = []string{"-NoProfile", "-Command", fmt.Sprintf("'%v=' + (Get-CimInstance Win32_OperatingSystem).%v", property, property)}
+	}
 	var cmdOut []byte
 	if cmdOut, err = exec.Command(cmdName, cmdArgs...).Output(); err != nil {
 		log.Debugf("There was an error running %v %v, err:%v", cmdName, cmdArgs, err)
@@ -120,7 +139,12 @@ func fullyQualifiedDomainName(log log.T) string {
 
 // getWMICComputerSystemValue return the value part of the wmic computersystem command for the specified attribute
 func getWMICComputerSystemValue(attribute string) string {
-	if contentBytes, err := exec.Command(wmicCommand, "computersystem", "get", attribute, "/value").Output(); err == nil {
+	cmd := exec.Command(wmicCommand, "computersystem", "get", attribute, "/value")
+	if !hasWmic() {
+		cmd = exec.Command(appconfig.PowerShellPluginCommandName, "-NoProfile", "-Command",
+			fmt.Sprintf("'%v=' + (Get-CimInstance Win32_ComputerSystem).%v", attribute, attribute))
+	}
+	if contentBytes, err := cmd.Output(); err == nil {
 		contents := string(contentBytes)
 		data := strings.Split(contents, "=")
 		if len(data) > 1 {
@@ -129,3 +153,38 @@ func getWMICComputerSystemValue(attribute string) string {
 	}
 	return ""
 }
+
+func hasWmic() bool {
+	_, err := os.Stat(wmicCommand)
+	return err == nil
+}
+
+// installationType returns the Windows installation option, like Server, Server Core or Nano Server
+func installationType() string {
+	key, err := registry.OpenKey(registry.LOCAL_MACHINE, currentVersionKey, registry.QUERY_VALUE)
+	if err != nil {
+		return ""
+	}
+	defer key.Close()
+	value, _, err := key.GetStringValue("InstallationType")
+	if err != nil {
+		return ""
+	}
+	return value
+}
+
+// getCapabilities checks which management components are present on the instance
+func getCapabilities(log log.T) Capabilities {
+	capabilities := Capabilities{Wmic: hasWmic()}
+	switch installationType() {
+	case installationTypeNanoServer:
+		capabilities.NanoServer = true
+	case installationTypeServerCore:
+		capabilities.ServerCore = true
+	}
+	if _, err := os.Stat(appconfig.PowerShellPluginCommandName); err == nil {
+		capabilities.PowerShell = true
+	}
+	log.Debugf("Platform capabilities %+v", capabilities)
+	return capabilities
+}
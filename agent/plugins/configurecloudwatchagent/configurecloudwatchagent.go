@@ -0,0 +1,73 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package configurecloudwatchagent implements the ConfigureCloudWatchAgent plugin.
+package configurecloudwatchagent
+
+import (
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/context"
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/iohandler"
+	"github.com/aws/amazon-ssm-agent/agent/jsonutil"
+	"github.com/aws/amazon-ssm-agent/agent/longrunning/plugin/cloudwatchagent"
+	"github.com/aws/amazon-ssm-agent/agent/task"
+)
+
+// Plugin is the type for the configureCloudWatchAgent plugin.
+// The unified CloudWatch agent is supervised by the long running plugin manager of the agent, the plugin validates
+// the input of the step and hands it off to the manager like configureDaemon.
+type Plugin struct {
+}
+
+// NewPlugin returns configureCloudWatchAgent
+func NewPlugin() (*Plugin, error) {
+	return &Plugin{}, nil
+}
+
+func (p *Plugin) Execute(context context.T, config contracts.Configuration, cancelFlag task.CancelFlag, output iohandler.IOHandler) {
+	log := context.Log()
+	log.Infof("%v started with configuration %v", Name(), config)
+	if cancelFlag.ShutDown() {
+		output.MarkAsShutdown()
+	} else if cancelFlag.Canceled() {
+		output.MarkAsCancelled()
+	} else {
+		runConfigureCloudWatchAgent(config.Properties, output)
+	}
+	return
+}
+
+func runConfigureCloudWatchAgent(rawPluginInput interface{}, output iohandler.IOHandler) {
+	var input cloudwatchagent.ConfigureCloudWatchAgentPluginInput
+	if err := jsonutil.Remarshal(rawPluginInput, &input); err != nil {
+		output.MarkAsFailed(err)
+		return
+	}
+
+	if err := cloudwatchagent.ValidateInput(input); err != nil {
+		output.AppendErrorf("\nconfigureCloudWatchAgent input invalid: %v", err.Error())
+		output.SetStatus(contracts.ResultStatusFailed)
+		return
+	}
+
+	// the long running plugin manager applies the action once the step completes
+	output.SetOutput(input)
+	output.SetStatus(contracts.ResultStatusSuccess)
+	return
+}
+
+// Name returns the plugin name
+func Name() string {
+	return appconfig.PluginNameAwsConfigureCloudWatchAgent
+}
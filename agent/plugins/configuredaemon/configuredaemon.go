@@ -16,33 +16,24 @@
 package configuredaemon
 
 import (
-	"fmt"
-	"path/filepath"
-
 	"github.com/aws/amazon-ssm-agent/agent/appconfig"
 	"github.com/aws/amazon-ssm-agent/agent/context"
 	"github.com/aws/amazon-ssm-agent/agent/contracts"
-	"github.com/aws/amazon-ssm-agent/agent/fileutil"
 	"github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/iohandler"
 	"github.com/aws/amazon-ssm-agent/agent/jsonutil"
-	"github.com/aws/amazon-ssm-agent/agent/longrunning/manager"
-	managerContracts "github.com/aws/amazon-ssm-agent/agent/longrunning/plugin"
 	"github.com/aws/amazon-ssm-agent/agent/longrunning/plugin/rundaemon"
 	"github.com/aws/amazon-ssm-agent/agent/task"
 )
 
-// Plugin is the type for the lrpm invoker plugin.
+// Plugin is the type for the configureDaemon plugin.
+// The daemons are supervised by the long running plugin manager of the agent, the plugin validates the
+// input of the step and hands it off to the manager like the lrpm invoker.
 type Plugin struct {
-	lrpm manager.T
 }
 
-// NewPlugin returns lrpminvoker
+// NewPlugin returns configureDaemon
 func NewPlugin() (*Plugin, error) {
-	var plugin Plugin
-	var err error
-	//getting the reference of LRPM - long running plugin manager - which manages all long running plugins
-	plugin.lrpm, err = manager.GetInstance()
-	return &plugin, err
+	return &Plugin{}, nil
 }
 
 func (p *Plugin) Execute(context context.T, config contracts.Configuration, cancelFlag task.CancelFlag, output iohandler.IOHandler) {
@@ -53,25 +44,19 @@ func (p *Plugin) Execute(context context.T, config contracts.Configuration, canc
 	} else if cancelFlag.Canceled() {
 		output.MarkAsCancelled()
 	} else {
-		runConfigureDaemon(p, context, config.Properties, config.OrchestrationDirectory, config.DefaultWorkingDirectory, cancelFlag, output)
+		runConfigureDaemon(config.Properties, config.DefaultWorkingDirectory, output)
 	}
 	return
 }
 
 func runConfigureDaemon(
-	p *Plugin,
-	context context.T,
 	rawPluginInput interface{},
-	orchestrationDir string,
 	daemonWorkingDir string,
-	cancelFlag task.CancelFlag,
 	output iohandler.IOHandler) {
 
-	// TODO:DAEMON: we're using the command line in a lot of places, we probably only need it in the rundaemon plugin or in the call to startplugin
 	var input rundaemon.ConfigureDaemonPluginInput
-	var err error
-	if err = jsonutil.Remarshal(rawPluginInput, &input); err != nil {
-		output.SetStatus(contracts.ResultStatusFailed)
+	if err := jsonutil.Remarshal(rawPluginInput, &input); err != nil {
+		output.MarkAsFailed(err)
 		return
 	}
 
@@ -79,93 +64,24 @@ func runConfigureDaemon(
 		input.PackageLocation = daemonWorkingDir
 	}
 
-	if err = rundaemon.ValidateDaemonInput(input); err != nil {
+	if err := rundaemon.ValidateDaemonInput(input); err != nil {
 		output.AppendErrorf("\nconfigureDaemon input invalid: %v", err.Error())
 		output.SetStatus(contracts.ResultStatusFailed)
 		return
 	}
 
-	daemonFilePath := filepath.Join(appconfig.DaemonRoot, fmt.Sprintf("%v.json", input.Name))
-	// make sure directory for ssm daemons exists
-	if err := fileutil.MakeDirs(appconfig.DaemonRoot); err != nil {
-		output.AppendErrorf("\nUnable to create ssm daemon folder %v: %v", appconfig.DaemonRoot, err.Error())
-		output.SetStatus(contracts.ResultStatusFailed)
-		return
-	}
-
 	switch input.Action {
-	case "Start":
-		// in longrunning/plugin/plugin.go (which should have the RegisteredPlugins method
-		// and call a platform specific helper) as part of exploration of the appconfig.PackageRoot
-		// directory tree looking for start.json (or whatever we call the daemon action)
-		plugin := managerContracts.Plugin{
-			Info: managerContracts.PluginInfo{
-				Name:          input.Name,
-				Configuration: input.Command,
-				State:         managerContracts.PluginState{IsEnabled: true},
-			},
-			Handler: &rundaemon.Plugin{
-				ExeLocation: input.PackageLocation,
-				Name:        input.Name,
-				CommandLine: input.Command,
-			},
-		}
-
-		// TODO:MF: make deps file to support mocking filesystem dependency
-		// Save daemon configuration file
-		var errDaemonDoc error
-		var ssmDaemonDoc string
-		if ssmDaemonDoc, errDaemonDoc = jsonutil.Marshal(input); errDaemonDoc == nil {
-			if fileutil.Exists(daemonFilePath) {
-				errDaemonDoc = fileutil.DeleteFile(daemonFilePath)
-			}
-			if errDaemonDoc == nil {
-				errDaemonDoc = fileutil.WriteAllText(daemonFilePath, ssmDaemonDoc)
-			}
-		}
-		if errDaemonDoc != nil {
-			output.AppendErrorf("\nFailed to register ssm daemon %v: %v", input.Name, errDaemonDoc.Error())
-			output.SetStatus(contracts.ResultStatusFailed)
-			return
-		}
-
-		p.lrpm.EnsurePluginRegistered(input.Name, plugin)
-		p.lrpm.StopPlugin(input.Name, cancelFlag)
-		if errStart := p.lrpm.StartPlugin(input.Name, input.Command, orchestrationDir, cancelFlag, output); errStart != nil {
-			output.AppendErrorf("\nFailed to start ssm daemon %v: %v", input.Name, err.Error())
-			output.SetStatus(contracts.ResultStatusFailed)
-			return
-		}
-	case "Stop":
-		if !fileutil.Exists(daemonFilePath) {
-			output.AppendErrorf("\nNo ssm daemon %v exists", input.Name)
-			output.SetStatus(contracts.ResultStatusFailed)
-			return
-		}
-		err := p.lrpm.StopPlugin(input.Name, cancelFlag)
-		if err != nil {
-			output.AppendErrorf("\nFailed to stop ssm daemon %v: %v", input.Name, err.Error())
-			output.SetStatus(contracts.ResultStatusFailed)
-			return
-		}
-		output.AppendInfof("\nDaemon %v stopped", input.Name)
-	case "Remove":
-		if fileutil.Exists(daemonFilePath) {
-			p.lrpm.StopPlugin(input.Name, cancelFlag)
-			fileutil.DeleteFile(daemonFilePath)
-			output.AppendInfof("\nDaemon %v removed", input.Name)
-		} else {
-			output.AppendInfof("\nDaemon %v is not installed", input.Name)
-		}
+	case rundaemon.ActionStart, rundaemon.ActionStop, rundaemon.ActionRemove, rundaemon.ActionStatus:
 	default:
 		output.AppendErrorf("\nUnsupported action %v", input.Action)
 		output.SetStatus(contracts.ResultStatusFailed)
 		return
 	}
 
+	// the long running plugin manager applies the action once the step completes
+	output.SetOutput(input)
 	output.SetStatus(contracts.ResultStatusSuccess)
 	return
-
 }
 
 // Name returns the plugin name
@@ -28,6 +28,7 @@ import (
 const (
 	PackageArchiveBirdwatcher = "birdwatcher"
 	PackageArchiveDocument    = "document"
+	PackageArchiveMirror      = "mirror"
 )
 
 type File struct {
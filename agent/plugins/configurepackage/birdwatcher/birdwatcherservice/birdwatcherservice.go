@@ -15,18 +15,26 @@
 package birdwatcherservice
 
 import (
+	"crypto/sha1"
 	"errors"
 	"fmt"
+	"io"
+	"os"
+	"path/filepath"
 	"reflect"
 	"strings"
 	"time"
 
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/fileutil"
 	"github.com/aws/amazon-ssm-agent/agent/fileutil/artifact"
+	"github.com/aws/amazon-ssm-agent/agent/fileutil/artifactcache"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/birdwatcher"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/birdwatcher/archive"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/birdwatcher/birdwatcherarchive"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/birdwatcher/documentarchive"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/birdwatcher/facade"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/birdwatcher/mirrorarchive"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/envdetect"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/packageservice"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/trace"
@@ -53,22 +61,30 @@ type PackageService struct {
 	collector      envdetect.Collector
 	timeProvider   NanoTime
 	packageArchive archive.IPackageArchive
+	artifactCache  artifactcache.Cache
 }
 
-func NewBirdwatcherArchive(facadeClient facade.BirdwatcherFacade, manifestCache packageservice.ManifestCache, context map[string]string) packageservice.PackageService {
+func NewBirdwatcherArchive(facadeClient facade.BirdwatcherFacade, manifestCache packageservice.ManifestCache, artifactCache artifactcache.Cache, context map[string]string) packageservice.PackageService {
 	pkgArchive := birdwatcherarchive.New(facadeClient, context)
 	pkgArchive.SetManifestCache(manifestCache)
-	return New(pkgArchive, facadeClient, manifestCache, packageservice.PackageServiceName_birdwatcher)
+	return New(pkgArchive, facadeClient, manifestCache, artifactCache, packageservice.PackageServiceName_birdwatcher)
 }
 
-func NewDocumentArchive(facadeClient facade.BirdwatcherFacade, manifestCache packageservice.ManifestCache) packageservice.PackageService {
+func NewDocumentArchive(facadeClient facade.BirdwatcherFacade, manifestCache packageservice.ManifestCache, artifactCache artifactcache.Cache) packageservice.PackageService {
 	pkgArchive := documentarchive.New(facadeClient)
 	pkgArchive.SetManifestCache(manifestCache)
-	return New(pkgArchive, facadeClient, manifestCache, packageservice.PackageServiceName_document)
+	return New(pkgArchive, facadeClient, manifestCache, artifactCache, packageservice.PackageServiceName_document)
+}
+
+// NewMirrorArchive returns a package service resolving packages from a local package mirror whose manifests are signed by one of the public keys
+func NewMirrorArchive(facadeClient facade.BirdwatcherFacade, manifestCache packageservice.ManifestCache, artifactCache artifactcache.Cache, mirrorURL string, publicKeys []string) packageservice.PackageService {
+	pkgArchive := mirrorarchive.New(mirrorURL, publicKeys)
+	pkgArchive.SetManifestCache(manifestCache)
+	return New(pkgArchive, facadeClient, manifestCache, artifactCache, packageservice.PackageServiceName_mirror)
 }
 
 // New constructor for PackageService
-func New(pkgArchive archive.IPackageArchive, facadeClient facade.BirdwatcherFacade, manifestCache packageservice.ManifestCache, name string) packageservice.PackageService {
+func New(pkgArchive archive.IPackageArchive, facadeClient facade.BirdwatcherFacade, manifestCache packageservice.ManifestCache, artifactCache artifactcache.Cache, name string) packageservice.PackageService {
 
 	return &PackageService{
 		pkgSvcName:     name,
@@ -77,6 +93,7 @@ func New(pkgArchive archive.IPackageArchive, facadeClient facade.BirdwatcherFaca
 		collector:      &envdetect.CollectorImp{},
 		timeProvider:   &TimeImpl{},
 		packageArchive: pkgArchive,
+		artifactCache:  artifactCache,
 	}
 }
 
@@ -97,6 +114,36 @@ func (ds *PackageService) DownloadManifest(tracer trace.Tracer, packageName stri
 	return ds.packageArchive.GetResourceArn(packageName, version), manifest.Version, isSameAsCache, nil
 }
 
+// ListVersions returns the version names of a package document, packages that are not documents have no version list
+func (ds *PackageService) ListVersions(tracer trace.Tracer, packageName string) ([]string, error) {
+	if ds.pkgSvcName != packageservice.PackageServiceName_document {
+		return nil, fmt.Errorf("listing versions is not supported for %v", packageName)
+	}
+	trace := tracer.BeginSection("list package versions")
+	defer trace.End()
+
+	var versions []string
+	input := &ssm.ListDocumentVersionsInput{Name: &packageName}
+	for {
+		output, err := ds.facadeClient.ListDocumentVersions(input)
+		if err != nil {
+			trace.WithError(err)
+			return nil, fmt.Errorf("failed to list versions of %v: %v", packageName, err)
+		}
+		for _, version := range output.DocumentVersions {
+			if version.VersionName != nil && *version.VersionName != "" {
+				versions = append(versions, *version.VersionName)
+			}
+		}
+		if output.NextToken == nil || *output.NextToken == "" {
+			break
+		}
+		input.NextToken = output.NextToken
+	}
+	trace.AppendDebugf("found versions %v", versions)
+	return versions, nil
+}
+
 // DownloadArtifact downloads the platform matching artifact specified in the manifest
 func (ds *PackageService) DownloadArtifact(tracer trace.Tracer, packageName string, version string) (string, error) {
 	trace := tracer.BeginSection("download artifact")
@@ -111,6 +158,11 @@ func (ds *PackageService) DownloadArtifact(tracer trace.Tracer, packageName stri
 
 // ReportResult sents back the result of the install/upgrade/uninstall run back to Birdwatcher
 func (ds *PackageService) ReportResult(tracer trace.Tracer, result packageservice.PackageResult) error {
+	if ds.pkgSvcName == packageservice.PackageServiceName_mirror {
+		// packages are resolved from a mirror when the package service cannot be reached
+		return nil
+	}
+
 	log := tracer.CurrentTrace().Logger
 	env, _ := ds.collector.CollectData(log)
 
@@ -254,6 +306,15 @@ func downloadFile(ds *PackageService, tracer trace.Tracer, file *archive.File, p
 	}
 
 	log := tracer.CurrentTrace().Logger
+	checksum := file.Info.Checksums["sha256"]
+	if ds.artifactCache != nil && checksum != "" {
+		// use the path artifact.Download would have downloaded the file to
+		localFilePath := filepath.Join(appconfig.DownloadRoot, fmt.Sprintf("%x", sha1.Sum([]byte(sourceUrl))))
+		if ds.artifactCache.Get(log, checksum, localFilePath) {
+			return localFilePath, nil
+		}
+	}
+
 	downloadOutput, downloadErr := birdwatcher.Networkdep.Download(log, downloadInput)
 	if downloadErr != nil || downloadOutput.LocalFilePath == "" {
 		errMessage := fmt.Sprintf("failed to download installation package reliably, %v", downloadInput.SourceURL)
@@ -279,9 +340,47 @@ func downloadFile(ds *PackageService, tracer trace.Tracer, file *archive.File, p
 		return downloadFile(ds, tracer, file, packageName, version, true)
 	}
 
+	// artifact.Download verifies a local source in place, copy it so removing the download leaves the source alone
+	if downloadOutput.LocalFilePath == sourceUrl {
+		localFilePath := filepath.Join(appconfig.DownloadRoot, fmt.Sprintf("%x", sha1.Sum([]byte(sourceUrl))))
+		if err = copyFile(sourceUrl, localFilePath); err != nil {
+			return "", fmt.Errorf("failed to copy %v: %v", sourceUrl, err)
+		}
+		downloadOutput.LocalFilePath = localFilePath
+	}
+
+	// artifact.Download verified the sha256 checksum of the file
+	if ds.artifactCache != nil && checksum != "" && downloadOutput.IsHashMatched {
+		if err = ds.artifactCache.Put(log, checksum, downloadOutput.LocalFilePath); err != nil {
+			log.Warnf("Failed to add %v to the artifact cache: %v", downloadOutput.LocalFilePath, err)
+		}
+	}
+
 	return downloadOutput.LocalFilePath, nil
 }
 
+// copyFile copies a file to the destination, creating the destination directory if needed
+func copyFile(source string, destination string) error {
+	sourceFile, err := os.Open(source)
+	if err != nil {
+		return err
+	}
+	defer sourceFile.Close()
+
+	if err = fileutil.MakeDirs(filepath.Dir(destination)); err != nil {
+		return err
+	}
+	destinationFile, err := os.Create(destination)
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(destinationFile, sourceFile); err != nil {
+		destinationFile.Close()
+		return err
+	}
+	return destinationFile.Close()
+}
+
 // ExtractPackageInfo returns the correct PackageInfo for the current instances platform/version/arch
 func (ds *PackageService) extractPackageInfo(tracer trace.Tracer, manifest *birdwatcher.Manifest) (*birdwatcher.PackageInfo, error) {
 	log := tracer.CurrentTrace().Logger
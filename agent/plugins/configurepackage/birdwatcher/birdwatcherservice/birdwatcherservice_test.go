@@ -32,6 +32,7 @@ import (
 	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/packageservice"
 	cache_mock "github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/packageservice/mock"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/trace"
+	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/ssm"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
@@ -1240,3 +1241,43 @@ func TestDownloadArtifact(t *testing.T) {
 		})
 	}
 }
+
+func TestListVersions(t *testing.T) {
+	tracer := trace.NewTracer(log.NewMockLog())
+	facadeClient := facade.FacadeStub{
+		ListDocumentVersionsOutput: &ssm.ListDocumentVersionsOutput{
+			DocumentVersions: []*ssm.DocumentVersionInfo{
+				{VersionName: aws.String("1.0")},
+				{DocumentVersion: aws.String("2")},
+				{VersionName: aws.String("1.1")},
+			},
+		},
+	}
+	ds := &PackageService{facadeClient: &facadeClient, pkgSvcName: packageservice.PackageServiceName_document}
+
+	versions, err := ds.ListVersions(tracer, "packagename")
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"1.0", "1.1"}, versions)
+	assert.Equal(t, "packagename", *facadeClient.ListDocumentVersionsInput.Name)
+
+	facadeClient.ListDocumentVersionsError = errors.New("testerror")
+	_, err = ds.ListVersions(tracer, "packagename")
+	assert.Error(t, err)
+
+	ds.pkgSvcName = packageservice.PackageServiceName_birdwatcher
+	_, err = ds.ListVersions(tracer, "packagename")
+	assert.Error(t, err)
+}
+
+func TestReportResultMirror(t *testing.T) {
+	tracer := trace.NewTracer(log.NewMockLog())
+	facadeClient := facade.FacadeStub{PutConfigurePackageResultError: errors.New("testerror")}
+	ds := NewMirrorArchive(&facadeClient, packageservice.ManifestCacheMemNew(), nil, "file:///mirror", nil)
+
+	err := ds.ReportResult(tracer, packageservice.PackageResult{PackageName: "packagename", Operation: "Install"})
+
+	assert.NoError(t, err)
+	assert.Equal(t, packageservice.PackageServiceName_mirror, ds.PackageServiceName())
+	assert.Nil(t, facadeClient.PutConfigurePackageResultInput)
+}
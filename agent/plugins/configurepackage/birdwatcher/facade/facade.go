@@ -19,12 +19,11 @@ import (
 	"github.com/aws/amazon-ssm-agent/agent/appconfig"
 	"github.com/aws/amazon-ssm-agent/agent/platform"
 	retry "github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/birdwatcher/facade/retryer"
+	"github.com/aws/amazon-ssm-agent/agent/proxyconfig"
 	"github.com/aws/amazon-ssm-agent/agent/sdkutil"
-	"github.com/aws/amazon-ssm-agent/agent/version"
 
 	"github.com/aws/aws-sdk-go/aws/client"
 	"github.com/aws/aws-sdk-go/aws/request"
-	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/ssm"
 )
 
@@ -58,16 +57,7 @@ func NewBirdwatcherFacade() BirdwatcherFacade {
 			cfg.Region = &appCfg.Agent.Region
 		}
 	}
-	facadeClientSession := session.New(cfg)
-
-	// Define a request handler with current agentName and version
-	SSMAgentVersionUserAgentHandler := request.NamedHandler{
-		Name: "ssm.SSMAgentVersionUserAgentHandler",
-		Fn:   request.MakeAddToUserAgentHandler(appconfig.DefaultConfig().Agent.Name, version.Version),
-	}
-
-	// Add the handler to each request to the BirdwatcherStationService
-	facadeClientSession.Handlers.Build.PushBackNamed(SSMAgentVersionUserAgentHandler)
+	facadeClientSession := sdkutil.NewSession(cfg, proxyconfig.ServiceSsm)
 
 	return ssm.New(facadeClientSession)
 }
@@ -0,0 +1,231 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package mirrorarchive contains the struct that is called when the package information is stored in a local package mirror
+package mirrorarchive
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/aws/amazon-ssm-agent/agent/fileutil/artifact"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/birdwatcher"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/birdwatcher/archive"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/packageservice"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/trace"
+)
+
+// A mirror holds one directory per package version, which contains the manifest, its detached signature
+// and the files referenced by the manifest:
+//
+//	<mirror>/<package name>/<version>/manifest.json
+//	<mirror>/<package name>/<version>/manifest.json.sig
+//	<mirror>/<package name>/<version>/<file>
+//
+// The latest version is published as a copy of its manifest and signature in <mirror>/<package name>/latest.
+const (
+	manifestFileName       = "manifest.json"
+	signatureFileExtension = ".sig"
+)
+
+type PackageArchive struct {
+	mirrorURL   string
+	publicKeys  []string
+	archiveType string
+	cache       packageservice.ManifestCache
+	packageArns map[string]string
+}
+
+// New is a constructor for PackageArchive struct.
+// The mirror is either a file:// url or local directory, or an https:// url,
+// and its manifests must be signed by one of the PEM encoded public keys at the given paths.
+func New(mirrorURL string, publicKeys []string) archive.IPackageArchive {
+	return &PackageArchive{
+		mirrorURL:   mirrorURL,
+		publicKeys:  publicKeys,
+		archiveType: archive.PackageArchiveMirror,
+		packageArns: make(map[string]string),
+	}
+}
+
+// Name of archive type
+func (ma *PackageArchive) Name() string {
+	return ma.archiveType
+}
+
+// SetManifestCache sets the manifest cache
+func (ma *PackageArchive) SetManifestCache(manifestCache packageservice.ManifestCache) {
+	ma.cache = manifestCache
+}
+
+// SetResource sets the package arn found in the manifest, packages without one are identified by their name
+func (ma *PackageArchive) SetResource(packageName string, version string, manifest *birdwatcher.Manifest) {
+	packageArn := manifest.PackageArn
+	if packageArn == "" {
+		packageArn = packageName
+	}
+	ma.packageArns[archive.FormKey(packageName, version)] = packageArn
+}
+
+// GetResourceArn returns the packageArn of a manifest downloaded from the mirror
+func (ma *PackageArchive) GetResourceArn(packageName string, version string) string {
+	return ma.packageArns[archive.FormKey(packageName, version)]
+}
+
+// GetResourceVersion returns the version
+func (ma *PackageArchive) GetResourceVersion(packageName string, packageVersion string) (name string, version string) {
+	version = packageVersion
+	if packageservice.IsLatest(packageVersion) {
+		version = packageservice.Latest
+	}
+
+	return packageName, version
+}
+
+// GetFileDownloadLocation returns the location of the file next to the manifest of the version in the mirror,
+// the download location in the manifest is ignored so that nothing is downloaded from outside the mirror
+func (ma *PackageArchive) GetFileDownloadLocation(file *archive.File, packageName string, version string) (string, error) {
+	if file == nil {
+		return "", fmt.Errorf("file is empty")
+	}
+	return ma.location(packageName, version, file.Name)
+}
+
+// DownloadArchiveInfo downloads the manifest from the mirror and verifies its signature
+func (ma *PackageArchive) DownloadArchiveInfo(tracer trace.Tracer, packageName string, version string) (string, error) {
+	trace := tracer.BeginSection("Downloading mirror archive info")
+	defer trace.End()
+
+	manifestLocation, err := ma.location(packageName, version, manifestFileName)
+	if err != nil {
+		return "", err
+	}
+	trace.AppendDebugf("Downloading manifest from %v", manifestLocation)
+
+	manifest, err := read(tracer, manifestLocation)
+	if err != nil {
+		return "", fmt.Errorf("failed to retrieve manifest: %v", err)
+	}
+	signature, err := read(tracer, manifestLocation+signatureFileExtension)
+	if err != nil {
+		return "", fmt.Errorf("failed to retrieve manifest signature: %v", err)
+	}
+
+	keys, err := loadPublicKeys(ma.publicKeys)
+	if err != nil {
+		return "", err
+	}
+	if err = verifySignature(keys, manifest, signature); err != nil {
+		return "", fmt.Errorf("manifest %v: %v", manifestLocation, err)
+	}
+
+	if err = validateManifest(manifest, version); err != nil {
+		return "", fmt.Errorf("manifest %v: %v", manifestLocation, err)
+	}
+
+	return string(manifest), nil
+}
+
+// ReadManifestFromCache to read the manifest from cache
+func (ma *PackageArchive) ReadManifestFromCache(packageArn string, version string) (*birdwatcher.Manifest, error) {
+	data, err := ma.cache.ReadManifest(packageArn, version)
+	if err != nil {
+		return nil, err
+	}
+
+	return archive.ParseManifest(&data)
+}
+
+// WriteManifestToCache stores the manifest in cache
+func (ma *PackageArchive) WriteManifestToCache(packageArn string, version string, manifest []byte) error {
+	return ma.cache.WriteManifest(packageArn, version, manifest)
+}
+
+// DeleteCachedManifest Deletes manifest from cache
+func (ma *PackageArchive) DeleteCachedManifest(packageArn string, version string) error {
+	return ma.cache.DeleteManifest(packageArn, version)
+}
+
+// location returns the path or url of a file of a package version in the mirror
+func (ma *PackageArchive) location(packageName string, version string, fileName string) (string, error) {
+	for _, element := range []string{packageName, version, fileName} {
+		if element == "" || element == "." || element == ".." || strings.ContainsAny(element, `/\`) {
+			return "", fmt.Errorf("%q cannot be used as a path in the package mirror", element)
+		}
+	}
+
+	mirrorURL, err := url.Parse(ma.mirrorURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid package mirror url: %v", err)
+	}
+	switch mirrorURL.Scheme {
+	case "https":
+		return strings.TrimSuffix(mirrorURL.String(), "/") + "/" +
+			url.PathEscape(packageName) + "/" + url.PathEscape(version) + "/" + url.PathEscape(fileName), nil
+	case "file":
+		return filepath.Join(localPath(mirrorURL.Path), packageName, version, fileName), nil
+	case "":
+		return filepath.Join(ma.mirrorURL, packageName, version, fileName), nil
+	default:
+		return "", fmt.Errorf("package mirror url must use the file or https scheme")
+	}
+}
+
+// localPath converts the path of a file url to a local path, file:///C:/mirror is C:\mirror on windows
+func localPath(path string) string {
+	if runtime.GOOS == "windows" && len(path) > 2 && path[0] == '/' && path[2] == ':' {
+		path = path[1:]
+	}
+	return filepath.FromSlash(path)
+}
+
+// read returns the content of a local file or downloads it from the mirror
+func read(tracer trace.Tracer, location string) ([]byte, error) {
+	if !strings.HasPrefix(location, "https://") {
+		return ioutil.ReadFile(location)
+	}
+	output, err := birdwatcher.Networkdep.Download(tracer.CurrentTrace().Logger, artifact.DownloadInput{SourceURL: location})
+	if err != nil {
+		return nil, err
+	}
+	if output.LocalFilePath == "" {
+		return nil, fmt.Errorf("failed to download %v", location)
+	}
+	return ioutil.ReadFile(output.LocalFilePath)
+}
+
+// validateManifest ensures the manifest is the one of the requested version and that the files
+// it references can be verified, as they are only trusted through the checksums of the signed manifest
+func validateManifest(data []byte, version string) error {
+	manifest, err := archive.ParseManifest(&data)
+	if err != nil {
+		return err
+	}
+	if manifest.Version == "" {
+		return fmt.Errorf("version is missing")
+	}
+	if !packageservice.IsLatest(version) && manifest.Version != version {
+		return fmt.Errorf("version %v does not match the requested version %v", manifest.Version, version)
+	}
+	for name, file := range manifest.Files {
+		if file == nil || file.Checksums["sha256"] == "" {
+			return fmt.Errorf("file %v has no sha256 checksum", name)
+		}
+	}
+	return nil
+}
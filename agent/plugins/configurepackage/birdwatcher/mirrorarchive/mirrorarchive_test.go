@@ -0,0 +1,190 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package mirrorarchive
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/birdwatcher"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/birdwatcher/archive"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/trace"
+	"github.com/stretchr/testify/assert"
+)
+
+const testManifest = `{"schemaVersion":"2.0","version":"1.0.0","packages":{"_any":{"_any":{"_any":{"file":"package.zip"}}}},"files":{"package.zip":{"checksums":{"sha256":"abcd"}}}}`
+
+// writePublicKey writes the PEM encoded public key to the directory and returns its path
+func writePublicKey(t *testing.T, dir string, name string, key crypto.PublicKey) string {
+	der, err := x509.MarshalPKIXPublicKey(key)
+	assert.NoError(t, err)
+	path := filepath.Join(dir, name)
+	assert.NoError(t, ioutil.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0600))
+	return path
+}
+
+// writeVersion publishes a manifest and its signature in the mirror
+func writeVersion(t *testing.T, mirror string, packageName string, version string, manifest string, signature []byte) {
+	dir := filepath.Join(mirror, packageName, version)
+	assert.NoError(t, os.MkdirAll(dir, 0700))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, manifestFileName), []byte(manifest), 0600))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, manifestFileName+signatureFileExtension), signature, 0600))
+}
+
+func TestArchiveName(t *testing.T) {
+	assert.Equal(t, archive.PackageArchiveMirror, New("file:///mirror", nil).Name())
+}
+
+func TestGetResourceArn(t *testing.T) {
+	testArchive := New("file:///mirror", nil)
+
+	testArchive.SetResource("package", "latest", &birdwatcher.Manifest{Version: "1.0.0"})
+	testArchive.SetResource("package", "1.0.0", &birdwatcher.Manifest{Version: "1.0.0", PackageArn: "arn"})
+
+	assert.Equal(t, "package", testArchive.GetResourceArn("package", "latest"))
+	assert.Equal(t, "arn", testArchive.GetResourceArn("package", "1.0.0"))
+	assert.Equal(t, "", testArchive.GetResourceArn("other", "1.0.0"))
+}
+
+func TestGetFileDownloadLocation(t *testing.T) {
+	file := &archive.File{Name: "package.zip", Info: birdwatcher.FileInfo{DownloadLocation: "https://example.com/package.zip"}}
+
+	location, err := New("https://mirror.example.com/packages/", nil).GetFileDownloadLocation(file, "package", "1.0.0")
+	assert.NoError(t, err)
+	assert.Equal(t, "https://mirror.example.com/packages/package/1.0.0/package.zip", location)
+
+	mirror := filepath.Join(os.TempDir(), "mirror")
+	location, err = New(mirror, nil).GetFileDownloadLocation(file, "package", "1.0.0")
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(mirror, "package", "1.0.0", "package.zip"), location)
+
+	_, err = New("http://mirror.example.com", nil).GetFileDownloadLocation(file, "package", "1.0.0")
+	assert.Error(t, err)
+
+	_, err = New(mirror, nil).GetFileDownloadLocation(&archive.File{Name: "../package.zip"}, "package", "1.0.0")
+	assert.Error(t, err)
+
+	_, err = New(mirror, nil).GetFileDownloadLocation(file, "..", "1.0.0")
+	assert.Error(t, err)
+}
+
+func TestDownloadArchiveInfo(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mirror")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	tracer := trace.NewTracer(log.NewMockLog())
+
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+	otherKey, _, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+	keyPath := writePublicKey(t, dir, "key.pem", publicKey)
+	otherKeyPath := writePublicKey(t, dir, "other.pem", otherKey)
+	signature := ed25519.Sign(privateKey, []byte(testManifest))
+	writeVersion(t, dir, "package", "1.0.0", testManifest, signature)
+	writeVersion(t, dir, "package", "latest", testManifest, []byte(base64.StdEncoding.EncodeToString(signature)))
+	writeVersion(t, dir, "package", "2.0.0", testManifest, signature)
+	writeVersion(t, dir, "tampered", "1.0.0", testManifest+" ", signature)
+	unverifiable := `{"version":"1.0.0","files":{"package.zip":{"downloadLocation":"package.zip"}}}`
+	writeVersion(t, dir, "unverifiable", "1.0.0", unverifiable, ed25519.Sign(privateKey, []byte(unverifiable)))
+
+	manifest, err := New("file://"+filepath.ToSlash(dir), []string{keyPath}).DownloadArchiveInfo(tracer, "package", "1.0.0")
+	assert.NoError(t, err)
+	assert.Equal(t, testManifest, manifest)
+
+	// base64 encoded signature
+	manifest, err = New(dir, []string{otherKeyPath, keyPath}).DownloadArchiveInfo(tracer, "package", "latest")
+	assert.NoError(t, err)
+	assert.Equal(t, testManifest, manifest)
+
+	_, err = New(dir, []string{otherKeyPath}).DownloadArchiveInfo(tracer, "package", "1.0.0")
+	assert.Error(t, err)
+
+	_, err = New(dir, nil).DownloadArchiveInfo(tracer, "package", "1.0.0")
+	assert.Error(t, err)
+
+	_, err = New(dir, []string{keyPath}).DownloadArchiveInfo(tracer, "tampered", "1.0.0")
+	assert.Error(t, err)
+
+	// a signed manifest of another version cannot be served for the requested version
+	_, err = New(dir, []string{keyPath}).DownloadArchiveInfo(tracer, "package", "2.0.0")
+	assert.Error(t, err)
+
+	_, err = New(dir, []string{keyPath}).DownloadArchiveInfo(tracer, "unverifiable", "1.0.0")
+	assert.Error(t, err)
+
+	_, err = New(dir, []string{keyPath}).DownloadArchiveInfo(tracer, "missing", "1.0.0")
+	assert.Error(t, err)
+}
+
+func TestVerifySignature(t *testing.T) {
+	data := []byte(testManifest)
+	digest := sha256.Sum256(data)
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	pkcs1Signature, err := rsa.SignPKCS1v15(rand.Reader, rsaKey, crypto.SHA256, digest[:])
+	assert.NoError(t, err)
+	pssSignature, err := rsa.SignPSS(rand.Reader, rsaKey, crypto.SHA256, digest[:], nil)
+	assert.NoError(t, err)
+
+	ecdsaKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	ecdsaSignature, err := ecdsa.SignASN1(rand.Reader, ecdsaKey, digest[:])
+	assert.NoError(t, err)
+
+	keys := []crypto.PublicKey{&ecdsaKey.PublicKey, &rsaKey.PublicKey}
+	assert.NoError(t, verifySignature(keys, data, pkcs1Signature))
+	assert.NoError(t, verifySignature(keys, data, pssSignature))
+	assert.NoError(t, verifySignature(keys, data, ecdsaSignature))
+	assert.Error(t, verifySignature(keys, []byte("other"), pkcs1Signature))
+	assert.Error(t, verifySignature(keys[:1], data, pkcs1Signature))
+}
+
+func TestLoadPublicKeys(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mirror")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	pkcs1Path := filepath.Join(dir, "rsa.pem")
+	assert.NoError(t, ioutil.WriteFile(pkcs1Path, pem.EncodeToMemory(&pem.Block{Type: "RSA PUBLIC KEY", Bytes: x509.MarshalPKCS1PublicKey(&rsaKey.PublicKey)}), 0600))
+	invalidPath := filepath.Join(dir, "invalid.pem")
+	assert.NoError(t, ioutil.WriteFile(invalidPath, []byte("not a key"), 0600))
+
+	keys, err := loadPublicKeys([]string{pkcs1Path})
+	assert.NoError(t, err)
+	assert.Equal(t, []crypto.PublicKey{&rsaKey.PublicKey}, keys)
+
+	_, err = loadPublicKeys([]string{invalidPath})
+	assert.Error(t, err)
+
+	_, err = loadPublicKeys([]string{filepath.Join(dir, "missing.pem")})
+	assert.Error(t, err)
+}
@@ -0,0 +1,57 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package mirrorarchive
+
+import (
+	"crypto"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	keySignature "github.com/aws/amazon-ssm-agent/agent/signature"
+)
+
+// loadPublicKeys reads the PEM encoded RSA, ECDSA and Ed25519 public keys and certificates the manifests can be signed with
+func loadPublicKeys(paths []string) (keys []crypto.PublicKey, err error) {
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no public keys are configured to verify the package mirror")
+	}
+	for _, path := range paths {
+		var data []byte
+		if data, err = ioutil.ReadFile(path); err != nil {
+			return nil, fmt.Errorf("failed to read package mirror public key: %v", err)
+		}
+		var fileKeys []crypto.PublicKey
+		if fileKeys, err = keySignature.ParsePublicKeys(data); err != nil {
+			return nil, fmt.Errorf("failed to parse package mirror public key %v: %v", path, err)
+		}
+		keys = append(keys, fileKeys...)
+	}
+	return keys, nil
+}
+
+// verifySignature returns nil if the raw or base64 encoded signature of the data was made by one of the keys.
+func verifySignature(keys []crypto.PublicKey, data []byte, signature []byte) error {
+	if decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(signature))); err == nil {
+		signature = decoded
+	}
+	for _, key := range keys {
+		if keySignature.Verify(key, data, signature) {
+			return nil
+		}
+	}
+	return fmt.Errorf("signature does not match any of the configured public keys")
+}
@@ -26,6 +26,7 @@ import (
 	"github.com/aws/amazon-ssm-agent/agent/appconfig"
 	"github.com/aws/amazon-ssm-agent/agent/context"
 	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/fileutil/artifactcache"
 	"github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/iohandler"
 	"github.com/aws/amazon-ssm-agent/agent/jsonutil"
 	"github.com/aws/amazon-ssm-agent/agent/platform"
@@ -37,6 +38,7 @@ import (
 	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/ssms3"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/trace"
 	"github.com/aws/amazon-ssm-agent/agent/task"
+	"github.com/aws/amazon-ssm-agent/agent/versionutil"
 
 	"github.com/aws/aws-sdk-go/service/ssm"
 )
@@ -52,6 +54,10 @@ const (
 )
 
 const resourceNotFoundException = "ResourceNotFoundException"
+
+// releaseVersionPattern matches the dotted numeric versions that can be ordered to detect a downgrade,
+// document version names that do not match are never treated as a downgrade
+const releaseVersionPattern = "^v?[0-9]+(\\.[0-9]+)*([-+][0-9A-Za-z.-]+)?$"
 const birdwatcherVersionPattern = "^[A-Za-z0-9.]+$"
 const documentArnPattern = "^arn:[a-z0-9][-.a-z0-9]{0,62}:[a-z0-9][-.a-z0-9]{0,62}:([a-z0-9][-.a-z0-9]{0,62})?:([a-z0-9][-.a-z0-9]{0,62})?:document\\/[a-zA-Z0-9/:.\\-_]{1,128}$"
 
@@ -76,6 +82,13 @@ type ConfigurePackagePluginInput struct {
 	AdditionalArguments string `json:"additionalArguments"`
 	Source              string `json:"source"`
 	Repository          string `json:"repository"`
+	// DisableArtifactCache opts the document out of reusing packages from the artifact cache shared by documents
+	DisableArtifactCache bool `json:"disableArtifactCache"`
+	// AllowDowngrade allows installing a version older than the installed version by uninstalling it first
+	AllowDowngrade bool `json:"allowDowngrade"`
+
+	// versionConstraint is set when Version is a range such as ">=1.2 <2.0" instead of a single version
+	versionConstraint *versionutil.Constraint
 }
 
 // NewPlugin returns a new instance of the plugin.
@@ -132,6 +145,18 @@ func prepareConfigurePackage(
 		installedVersion, installState = getVersionToInstall(tracer, repository, packageArn)
 		trace.AppendDebugf("installed: %v in state %v, to install: %v", installedVersion, installState, version).End()
 
+		if isDowngrade(installedVersion, installState, version) {
+			trace = tracer.BeginSection("check downgrade")
+			if !input.AllowDowngrade {
+				trace.WithError(fmt.Errorf("version %v is older than the installed version %v, set allowDowngrade to true to downgrade the package", version, installedVersion)).End()
+				output.MarkAsFailed(nil, nil)
+				return
+			}
+			// a downgrade always uninstalls the newer version before installing the older one
+			isUpdateInPlace = false
+			trace.AppendInfof("downgrading from version %v to %v", installedVersion, version).End()
+		}
+
 		// ensure manifest file and package
 		var err error
 		trace = tracer.BeginSection("ensure package is locally available")
@@ -167,8 +192,14 @@ func prepareConfigurePackage(
 		installedVersion, installState = getVersionToUninstall(tracer, repository, packageArn)
 
 		// if the input.Version is not specified, or is "latest", uninstall the installedVersion
+		// if it is a range, uninstall the installedVersion when it is in the range
 		if input.Version == "" || packageservice.IsLatest(input.Version) {
 			version = installedVersion
+		} else if input.versionConstraint != nil {
+			version = input.Version
+			if input.versionConstraint.Matches(installedVersion) {
+				version = installedVersion
+			}
 		}
 
 		//return success if the version is already uninstalled
@@ -279,6 +310,18 @@ func getVersionToInstall(
 	return installedVersion, currentState
 }
 
+// isDowngrade returns true if the version to install is older than a version that is installed
+func isDowngrade(installedVersion string, installState localpackages.InstallState, version string) bool {
+	if installedVersion == "" || installState != localpackages.Installed {
+		return false
+	}
+	versionPattern := regexp.MustCompile(releaseVersionPattern)
+	if !versionPattern.MatchString(installedVersion) || !versionPattern.MatchString(version) {
+		return false
+	}
+	return versionutil.Compare(version, installedVersion, false) < 0
+}
+
 // getVersionToUninstall decides which version to uninstall
 func getVersionToUninstall(
 	tracer trace.Tracer,
@@ -368,6 +411,14 @@ func validateInput(input *ConfigurePackagePluginInput) (valid bool, err error) {
 		input.AdditionalArguments = argumentString
 	}
 
+	if versionutil.IsConstraint(input.Version) {
+		constraint, err := versionutil.ParseConstraint(input.Version)
+		if err != nil {
+			return false, err
+		}
+		input.versionConstraint = &constraint
+	}
+
 	return true, nil
 }
 
@@ -460,22 +511,30 @@ func selectService(tracer trace.Tracer, input *ConfigurePackagePluginInput, loca
 	response := &ssm.GetManifestOutput{}
 	var err error
 
+	if appCfg != nil && appCfg.Birdwatcher.MirrorUrl != "" {
+		// a package mirror replaces the package service, for instances that cannot reach it
+		*isDocumentArchive = false
+		tracer.CurrentTrace().AppendInfof("Package mirror %v is configured", appCfg.Birdwatcher.MirrorUrl)
+		return birdwatcherservice.NewMirrorArchive(birdwatcherFacade, localrepo, newArtifactCache(input), appCfg.Birdwatcher.MirrorUrl, appCfg.Birdwatcher.MirrorPublicKeys), nil
+	}
+
 	if (appCfg != nil && appCfg.Birdwatcher.ForceEnable) || !ssms3.UseSSMS3Service(tracer, serviceEndpoint, region) {
 		// This indicates that it would be the birdwatcher service.
 		// Before creating an object of type birdwatcher here, check if the name is of document arn. If it is, return with a Document type service
 		if regexp.MustCompile(documentArnPattern).MatchString(input.Name) {
 			*isDocumentArchive = true
 			// return a new object of type document
-			return birdwatcherservice.NewDocumentArchive(birdwatcherFacade, localrepo), nil
+			return birdwatcherservice.NewDocumentArchive(birdwatcherFacade, localrepo, newArtifactCache(input)), nil
 		}
 		if input.Version != "" {
+			// Version ranges are only supported for documents.
 			// Birdwatcher version pattern and document version name pattern is different. If the pattern doesn't match Birdwatcher,
 			// we assume document and continue, since birdwatcher will error out with ValidationException.
 			// This could also happen if there is a typo in the birdwatcher version, but we assume Document and continue.
-			if !regexp.MustCompile(birdwatcherVersionPattern).MatchString(input.Version) {
+			if input.versionConstraint != nil || !regexp.MustCompile(birdwatcherVersionPattern).MatchString(input.Version) {
 				*isDocumentArchive = true
 				// return a new object of type document
-				return birdwatcherservice.NewDocumentArchive(birdwatcherFacade, localrepo), nil
+				return birdwatcherservice.NewDocumentArchive(birdwatcherFacade, localrepo, newArtifactCache(input)), nil
 			}
 		}
 
@@ -497,7 +556,7 @@ func selectService(tracer trace.Tracer, input *ConfigurePackagePluginInput, loca
 			if strings.Contains(err.Error(), resourceNotFoundException) {
 				*isDocumentArchive = true
 				// return a new object of type document
-				return birdwatcherservice.NewDocumentArchive(birdwatcherFacade, localrepo), nil
+				return birdwatcherservice.NewDocumentArchive(birdwatcherFacade, localrepo, newArtifactCache(input)), nil
 			} else {
 				tracer.CurrentTrace().AppendErrorf("Error returned for GetManifest - %v.", err.Error())
 				return nil, err
@@ -511,13 +570,48 @@ func selectService(tracer trace.Tracer, input *ConfigurePackagePluginInput, loca
 		birdWatcherArchiveContext["packageName"] = input.Name
 		birdWatcherArchiveContext["packageVersion"] = input.Version
 		birdWatcherArchiveContext["manifest"] = *response.Manifest
-		return birdwatcherservice.NewBirdwatcherArchive(birdwatcherFacade, localrepo, birdWatcherArchiveContext), nil
+		return birdwatcherservice.NewBirdwatcherArchive(birdwatcherFacade, localrepo, newArtifactCache(input), birdWatcherArchiveContext), nil
 	}
 
 	tracer.CurrentTrace().AppendInfof("S3 repository is marked active")
 	return ssms3.New(serviceEndpoint, region), nil
 }
 
+// resolveVersionConstraint replaces a version range in the input with the version to act upon.
+// Install picks the highest published version in the range, uninstall acts upon the installed version
+// and is resolved once the installed version is known.
+func resolveVersionConstraint(tracer trace.Tracer, packageService packageservice.PackageService, input *ConfigurePackagePluginInput) error {
+	if input.versionConstraint == nil || input.Action != InstallAction {
+		return nil
+	}
+	trace := tracer.BeginSection(fmt.Sprintf("resolve version constraint %v", input.Version))
+	defer trace.End()
+
+	lister, ok := packageService.(packageservice.VersionLister)
+	if !ok {
+		return fmt.Errorf("version constraints are not supported by the %v package service", packageService.PackageServiceName())
+	}
+	versions, err := lister.ListVersions(tracer, input.Name)
+	if err != nil {
+		return err
+	}
+	version, found := input.versionConstraint.Highest(versions)
+	if !found {
+		return fmt.Errorf("no version of %v matches %v", input.Name, input.Version)
+	}
+	trace.AppendInfof("resolved version %v", version)
+	input.Version = version
+	return nil
+}
+
+// newArtifactCache returns the cache packages are reused from, unless the document opted out of it
+func newArtifactCache(input *ConfigurePackagePluginInput) artifactcache.Cache {
+	if input.DisableArtifactCache {
+		return artifactcache.Disabled
+	}
+	return artifactcache.New()
+}
+
 // Execute runs the plugin operation and returns output
 func (p *Plugin) Execute(context context.T, config contracts.Configuration, cancelFlag task.CancelFlag, output iohandler.IOHandler) {
 	p.execute(context, config, cancelFlag, output)
@@ -551,11 +645,18 @@ func (p *Plugin) execute(context context.T, config contracts.Configuration, canc
 		if err != nil {
 			tracer.CurrentTrace().WithError(err).End()
 			out.MarkAsFailed(nil, nil)
+		} else if err = resolveVersionConstraint(tracer, packageService, input); err != nil {
+			tracer.CurrentTrace().WithError(err).End()
+			out.MarkAsFailed(nil, nil)
 		}
 		if out.GetStatus() != contracts.ResultStatusFailed {
 			//Return failure if the manifest cannot be accessed
 			//Return failure if the package version is installed, but the manifest is no longer available
 			packageName, packageVersion := packageService.GetPackageArnAndVersion(input.Name, input.Version)
+			if input.versionConstraint != nil {
+				// uninstall of a range acts upon the installed version, the default version manifest identifies the package
+				packageVersion = ""
+			}
 
 			//always download the manifest before acting upon the request
 			trace := tracer.BeginSection("download manifest")
@@ -586,6 +687,9 @@ func (p *Plugin) execute(context context.T, config contracts.Configuration, canc
 					manifestVersion,
 					isSameAsCache,
 					&out)
+				if installedVersion != "" && installState != localpackages.None {
+					tracer.CurrentTrace().AppendInfof("previously installed version: %v", installedVersion)
+				}
 				log.Debugf("HasInst %v, HasUninst %v, IsInplaceUpdate %v, InstallState %v, PackageName %v, InstalledVersion %v", inst != nil, uninst != nil, isUpdateInPlace, installState, packageArn, installedVersion)
 
 				//if the status is already decided as failed or succeeded, do not execute anything
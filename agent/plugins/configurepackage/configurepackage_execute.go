@@ -102,6 +102,9 @@ func executeInstall(
 		validatetrace := tracer.BeginSection(fmt.Sprintf("validate %s/%s - rollback: %t", inst.PackageName(), inst.Version(), isRollback))
 		result = inst.Validate(tracer, context)
 		validatetrace.WithExitcode(int64(result.GetExitCode()))
+		if !result.GetStatus().IsSuccess() {
+			validatetrace.AppendErrorf("Validation of %v %v failed; validate status %v", inst.PackageName(), inst.Version(), result.GetStatus())
+		}
 	}
 	if result.GetStatus().IsReboot() {
 		tracer.BeginSection(fmt.Sprintf("Rebooting to finish installation of %v %v - rollback: %t", inst.PackageName(), inst.Version(), isRollback))
@@ -128,6 +131,7 @@ func executeInstall(
 			return
 		}
 		// Execute rollback
+		installtrace.AppendInfof("Rolling back to %v %v", uninst.PackageName(), uninst.Version())
 		executeUninstall(tracer, context, repository, uninst, inst, isUpdateInPlace, true, output)
 		return
 	}
@@ -18,10 +18,12 @@ import (
 	"testing"
 
 	"github.com/aws/amazon-ssm-agent/agent/context"
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
 	"github.com/aws/amazon-ssm-agent/agent/log"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/localpackages"
 	repository_mock "github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/localpackages/mock"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/trace"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 )
 
@@ -162,6 +164,32 @@ func TestRollback(t *testing.T) {
 	repoMock.AssertExpectations(t)
 }
 
+func TestRollback_FailedValidate(t *testing.T) {
+	uninstallerMock := uninstallerSuccessWithRollbackMock("SsmTest", "0.0.1")
+	installerMock := installerInvalidWithRollbackMock("SsmTest", "0.0.2")
+	repoMock := &repository_mock.MockedRepository{}
+	repoMock.On("SetInstallState", mock.Anything, "SsmTest", "0.0.1", localpackages.Upgrading).Return(nil)
+	repoMock.On("SetInstallState", mock.Anything, "SsmTest", "0.0.2", localpackages.Installing).Return(nil)
+	repoMock.On("SetInstallState", mock.Anything, "SsmTest", "0.0.2", localpackages.RollbackUninstall).Return(nil)
+	repoMock.On("SetInstallState", mock.Anything, "SsmTest", "0.0.1", localpackages.RollbackInstall).Return(nil)
+	repoMock.On("SetInstallState", mock.Anything, "SsmTest", "0.0.1", localpackages.Installed).Return(nil)
+	repoMock.On("RemovePackage", mock.Anything, "SsmTest", "0.0.2").Return(nil)
+	tracer := trace.NewTracer(log.NewMockLog())
+	tracer.BeginSection("test segment root")
+	output := &trace.PluginOutputTrace{Tracer: tracer}
+
+	executeConfigurePackage(tracer, contextMock, repoMock, installerMock, uninstallerMock, false, localpackages.Installed, output)
+
+	installerMock.AssertExpectations(t)
+	uninstallerMock.AssertExpectations(t)
+	repoMock.AssertExpectations(t)
+	assert.Equal(t, contracts.ResultStatusFailed, output.GetStatus())
+	// both the failed install and the rollback are recorded
+	assert.Contains(t, tracer.ToPluginOutput().GetStderr(), "Validation of SsmTest 0.0.2 failed")
+	assert.Contains(t, tracer.ToPluginOutput().GetStdout(), "Rolling back to SsmTest 0.0.1")
+	assert.Contains(t, tracer.ToPluginOutput().GetStdout(), "successfully rolled back to SsmTest 0.0.1")
+}
+
 func TestRollbackFailed(t *testing.T) {
 	uninstallerMock := uninstallerSuccessWithFailedRollbackMock("SsmTest", "0.0.1")
 	installerMock := installerFailedWithRollbackMock("SsmTest", "0.0.2")
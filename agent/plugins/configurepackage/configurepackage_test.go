@@ -32,6 +32,7 @@ import (
 	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/envdetect/ec2infradetect"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/localpackages"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/packageservice"
+	serviceMock "github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/packageservice/mock"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/trace"
 
 	"github.com/aws/aws-sdk-go/service/ssm"
@@ -353,6 +354,72 @@ func TestPrepareUninstall(t *testing.T) {
 	repoMock.AssertExpectations(t)
 }
 
+func TestPrepareUninstallVersionConstraint(t *testing.T) {
+	// file stubs are needed for ensurePackage because it handles the unzip
+	stubs := setSuccessStubs()
+	defer stubs.Clear()
+
+	pluginInformation := createStubPluginInputUninstall(">=0.0.1 <0.1")
+	valid, err := validateInput(pluginInformation)
+	assert.True(t, valid)
+	assert.NoError(t, err)
+	installerMock := installerNotCalledMock()
+	repoMock := repoUninstallMock(pluginInformation, installerMock)
+	serviceMock := serviceSuccessMock()
+	tracer := trace.NewTracer(log.NewMockLog())
+	output := &trace.PluginOutputTrace{Tracer: tracer}
+
+	inst, uninst, _, installState, installedVersion := prepareConfigurePackage(
+		tracer,
+		buildConfigSimple(pluginInformation),
+		repoMock,
+		serviceMock,
+		pluginInformation,
+		"packageArn",
+		"0.0.3",
+		false,
+		output)
+
+	assert.Nil(t, inst)
+	assert.NotNil(t, uninst)
+	assert.Equal(t, localpackages.Installed, installState)
+	assert.Equal(t, "0.0.1", installedVersion)
+	assert.Equal(t, 0, output.GetExitCode())
+
+	installerMock.AssertExpectations(t)
+	repoMock.AssertExpectations(t)
+}
+
+func TestPrepareUninstallVersionConstraintNotInstalled(t *testing.T) {
+	pluginInformation := createStubPluginInputUninstall("^1.0")
+	valid, err := validateInput(pluginInformation)
+	assert.True(t, valid)
+	assert.NoError(t, err)
+	installerMock := installerNotCalledMock()
+	repoMock := repoUninstallMockWrongVersion(pluginInformation, installerMock)
+	serviceMock := serviceSuccessMock()
+	tracer := trace.NewTracer(log.NewMockLog())
+	output := &trace.PluginOutputTrace{Tracer: tracer}
+
+	inst, uninst, _, installState, _ := prepareConfigurePackage(
+		tracer,
+		buildConfigSimple(pluginInformation),
+		repoMock,
+		serviceMock,
+		pluginInformation,
+		"packageArn",
+		"0.0.1",
+		false,
+		output)
+
+	assert.Nil(t, inst)
+	assert.Nil(t, uninst)
+	assert.Equal(t, localpackages.None, installState)
+	assert.Equal(t, contracts.ResultStatusSuccess, output.GetStatus())
+
+	repoMock.AssertExpectations(t)
+}
+
 func TestPrepareUninstallCurrent(t *testing.T) {
 	// file stubs are needed for ensurePackage because it handles the unzip
 	stubs := setSuccessStubs()
@@ -555,6 +622,82 @@ func TestPrepareLegacyUpgrade(t *testing.T) {
 	repoMock.AssertExpectations(t)
 }
 
+func TestPrepareDowngrade(t *testing.T) {
+	// file stubs are needed for ensurePackage because it handles the unzip
+	stubs := setSuccessStubs()
+	defer stubs.Clear()
+
+	pluginInformation := createStubPluginInputUpdate()
+	pluginInformation.Version = "0.0.1"
+	pluginInformation.AllowDowngrade = true
+	installerMock := installerNotCalledMock()
+	repoMock := repoDowngradeMock(installerMock)
+	serviceMock := serviceUpdateMock()
+	tracer := trace.NewTracer(log.NewMockLog())
+	output := &trace.PluginOutputTrace{Tracer: tracer}
+
+	inst, uninst, isUpdateInPlace, installState, installedVersion := prepareConfigurePackage(
+		tracer,
+		buildConfigSimple(pluginInformation),
+		repoMock,
+		serviceMock,
+		pluginInformation,
+		"packageArn",
+		"0.0.1",
+		false,
+		output)
+
+	assert.NotNil(t, inst)
+	assert.NotNil(t, uninst)
+	// a downgrade uninstalls the installed version even if an in-place update was requested
+	assert.False(t, isUpdateInPlace)
+	assert.Equal(t, localpackages.Installed, installState)
+	assert.Equal(t, "0.0.2", installedVersion)
+	assert.Equal(t, 0, output.GetExitCode())
+	assert.Empty(t, tracer.ToPluginOutput().GetStderr())
+
+	installerMock.AssertExpectations(t)
+	repoMock.AssertExpectations(t)
+}
+
+func TestPrepareDowngrade_NotAllowed(t *testing.T) {
+	pluginInformation := createStubPluginInputUpgrade()
+	pluginInformation.Version = "0.0.1"
+	repoMock := repoDowngradeRefusedMock()
+	serviceMock := serviceSuccessMock()
+	tracer := trace.NewTracer(log.NewMockLog())
+	output := &trace.PluginOutputTrace{Tracer: tracer}
+
+	inst, uninst, _, _, _ := prepareConfigurePackage(
+		tracer,
+		buildConfigSimple(pluginInformation),
+		repoMock,
+		serviceMock,
+		pluginInformation,
+		"packageArn",
+		"0.0.1",
+		false,
+		output)
+
+	assert.Nil(t, inst)
+	assert.Nil(t, uninst)
+	assert.Equal(t, contracts.ResultStatusFailed, output.GetStatus())
+	assert.Contains(t, tracer.ToPluginOutput().GetStderr(), "allowDowngrade")
+
+	repoMock.AssertExpectations(t)
+}
+
+func TestIsDowngrade(t *testing.T) {
+	assert.True(t, isDowngrade("1.10.0", localpackages.Installed, "1.9.2"))
+	assert.True(t, isDowngrade("v2.0", localpackages.Installed, "1.0"))
+	assert.False(t, isDowngrade("1.9.2", localpackages.Installed, "1.10.0"))
+	assert.False(t, isDowngrade("1.0", localpackages.Installed, "1.0"))
+	assert.False(t, isDowngrade("1.10.0", localpackages.Failed, "1.9.2"))
+	assert.False(t, isDowngrade("", localpackages.None, "1.0"))
+	// document version names that are not release versions cannot be ordered
+	assert.False(t, isDowngrade("Release-May", localpackages.Installed, "Release-April"))
+}
+
 func TestPrepareUpdateWithAdditionalArguments(t *testing.T) {
 	// file stubs are needed for ensurePackage because it handles the unzip
 	stubs := setSuccessStubs()
@@ -1103,6 +1246,94 @@ func TestValidateInput_VersionValid(t *testing.T) {
 	}
 }
 
+func TestValidateInput_VersionConstraint(t *testing.T) {
+	input := ConfigurePackagePluginInput{}
+	input.Name = "PVDriver"
+	input.Action = "Install"
+
+	for _, version := range []string{">=1.2.0 <2.0.0", "~1.4", "^2.1.0", "1.x", "1.0 || >= 3.0"} {
+		input.Version = version
+		input.versionConstraint = nil
+
+		result, err := validateInput(&input)
+
+		assert.True(t, result)
+		assert.NoError(t, err)
+		assert.NotNil(t, input.versionConstraint, version)
+	}
+
+	input.Version = ">=1.0 <"
+	result, err := validateInput(&input)
+	assert.False(t, result)
+	assert.Error(t, err)
+}
+
+func TestValidateInput_VersionNotConstraint(t *testing.T) {
+	input := ConfigurePackagePluginInput{}
+	input.Name = "PVDriver"
+	input.Action = "Install"
+	input.Version = "1.2.3"
+
+	result, err := validateInput(&input)
+
+	assert.True(t, result)
+	assert.NoError(t, err)
+	assert.Nil(t, input.versionConstraint)
+}
+
+// versionListerMock is a package service that lists the published versions of a package
+type versionListerMock struct {
+	*serviceMock.Mock
+	versions []string
+}
+
+func (m versionListerMock) ListVersions(tracer trace.Tracer, packageName string) ([]string, error) {
+	return m.versions, nil
+}
+
+func TestResolveVersionConstraint(t *testing.T) {
+	input := createStubPluginInputInstall()
+	input.Version = ">=1.0 <2.0"
+	valid, _ := validateInput(input)
+	assert.True(t, valid)
+	service := versionListerMock{Mock: &serviceMock.Mock{}, versions: []string{"0.9", "1.2", "1.10", "2.0", "Beta"}}
+	tracer := trace.NewTracer(log.NewMockLog())
+
+	err := resolveVersionConstraint(tracer, service, input)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "1.10", input.Version)
+}
+
+func TestResolveVersionConstraint_NoMatch(t *testing.T) {
+	input := createStubPluginInputInstall()
+	input.Version = "^3.0"
+	valid, _ := validateInput(input)
+	assert.True(t, valid)
+	service := versionListerMock{Mock: &serviceMock.Mock{}, versions: []string{"1.2", "2.0"}}
+	tracer := trace.NewTracer(log.NewMockLog())
+
+	err := resolveVersionConstraint(tracer, service, input)
+
+	assert.Error(t, err)
+	assert.Equal(t, "^3.0", input.Version)
+}
+
+func TestResolveVersionConstraint_NotSupported(t *testing.T) {
+	input := createStubPluginInputInstall()
+	input.Version = "1.x"
+	valid, _ := validateInput(input)
+	assert.True(t, valid)
+	service := &serviceMock.Mock{}
+	service.On("PackageServiceName").Return(packageservice.PackageServiceName_birdwatcher)
+	tracer := trace.NewTracer(log.NewMockLog())
+
+	err := resolveVersionConstraint(tracer, service, input)
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "not supported")
+}
+
 func TestValidateInput_EmptyVersionWithInstall(t *testing.T) {
 	input := ConfigurePackagePluginInput{}
 
@@ -1303,6 +1534,28 @@ func TestSelectService(t *testing.T) {
 	}
 }
 
+func TestSelectService_Mirror(t *testing.T) {
+	tracer := trace.NewTracer(log.NewMockLog())
+	defer tracer.BeginSection("test").End()
+	isDocumentArchive := true
+	bwfacade := facadeMock.BirdwatcherFacade{}
+	appConfig := appconfig.SsmagentConfig{
+		Birdwatcher: appconfig.BirdwatcherCfg{
+			MirrorUrl:        "file:///mirror",
+			MirrorPublicKeys: []string{"/keys/mirror.pem"},
+		},
+	}
+	input := &ConfigurePackagePluginInput{Name: "package", Version: "1.0.0"}
+
+	result, err := selectService(tracer, input, localpackages.NewRepository(), &appConfig, &bwfacade, &isDocumentArchive)
+
+	assert.NoError(t, err)
+	assert.Equal(t, packageservice.PackageServiceName_mirror, result.PackageServiceName())
+	assert.False(t, isDocumentArchive)
+	// the package service is not called when a mirror is configured
+	bwfacade.AssertNotCalled(t, "GetManifest", mock.Anything)
+}
+
 // Integration tests
 func loadFile(t *testing.T, fileName string) (result []byte) {
 	result, err := ioutil.ReadFile(fileName)
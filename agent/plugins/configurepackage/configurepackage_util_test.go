@@ -100,6 +100,24 @@ func repoUninstallMockWrongVersion(pluginInformation *ConfigurePackagePluginInpu
 	return &mockRepo
 }
 
+func repoDowngradeMock(installerMock installer.Installer) *repoMock.MockedRepository {
+	mockRepo := repoMock.MockedRepository{}
+	mockRepo.On("GetInstalledVersion", mock.Anything, mock.Anything).Return("0.0.2")
+	mockRepo.On("GetInstallState", mock.Anything, mock.Anything).Return(localpackages.Installed, "")
+	mockRepo.On("ValidatePackage", mock.Anything, mock.Anything, "0.0.1").Return(nil)
+	mockRepo.On("ValidatePackage", mock.Anything, mock.Anything, "0.0.2").Return(nil)
+	mockRepo.On("GetInstaller", mock.Anything, mock.Anything, mock.Anything, "0.0.1", "").Return(installerMock)
+	mockRepo.On("GetInstaller", mock.Anything, mock.Anything, mock.Anything, "0.0.2", "").Return(installerMock)
+	return &mockRepo
+}
+
+func repoDowngradeRefusedMock() *repoMock.MockedRepository {
+	mockRepo := repoMock.MockedRepository{}
+	mockRepo.On("GetInstalledVersion", mock.Anything, mock.Anything).Return("0.0.2")
+	mockRepo.On("GetInstallState", mock.Anything, mock.Anything).Return(localpackages.Installed, "")
+	return &mockRepo
+}
+
 func repoUpdateMock(pluginInformation *ConfigurePackagePluginInput, installerMock installer.Installer) *repoMock.MockedRepository {
 	mockRepo := repoMock.MockedRepository{}
 	mockRepo.On("GetInstalledVersion", mock.Anything, mock.Anything).Return("0.0.1")
@@ -293,6 +311,16 @@ func installerFailedWithRollbackMock(packageName string, version string) *instal
 	return &mockInst
 }
 
+func installerInvalidWithRollbackMock(packageName string, version string) *installerMock.Mock {
+	mockInst := installerMock.Mock{}
+	mockInst.On("Install", mock.Anything).Return(pluginOutputWithStatus(contracts.ResultStatusSuccess)).Once()
+	mockInst.On("Validate", mock.Anything).Return(pluginOutputWithStatus(contracts.ResultStatusFailed)).Once()
+	mockInst.On("Uninstall", mock.Anything).Return(pluginOutputWithStatus(contracts.ResultStatusSuccess)).Once()
+	mockInst.On("PackageName").Return(packageName)
+	mockInst.On("Version").Return(version)
+	return &mockInst
+}
+
 func uninstallerSuccessWithRollbackMock(packageName string, version string) *installerMock.Mock {
 	mockInst := installerMock.Mock{}
 	mockInst.On("Uninstall", mock.Anything).Return(pluginOutputWithStatus(contracts.ResultStatusSuccess)).Once()
@@ -19,6 +19,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"path/filepath"
+	"regexp"
 	"strings"
 	"time"
 
@@ -35,6 +36,17 @@ import (
 	"github.com/aws/amazon-ssm-agent/agent/plugins/inventory/model"
 )
 
+// hookPattern matches the script names install hooks can be defined with
+var hookPattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// reservedActions are the actions run by the installer itself, which cannot be used as install hooks
+var reservedActions = map[string]bool{
+	ssminstaller.ACTION_INSTALL:   true,
+	ssminstaller.ACTION_UPDATE:    true,
+	ssminstaller.ACTION_UNINSTALL: true,
+	ssminstaller.ACTION_VALIDATE:  true,
+}
+
 // DownloadDelegate is a function that downloads a package to a directory provided by the repository
 type DownloadDelegate func(tracer trace.Tracer, targetDirectory string) error
 
@@ -137,6 +149,8 @@ type PackageManifest struct {
 	AppPublisher    string `json:"apppublisher"`    // optional inventory attribute
 	AppReferenceURL string `json:"appreferenceurl"` // optional inventory attribute
 	AppType         string `json:"apptype"`         // optional inventory attribute
+	PreInstall      string `json:"preinstall"`      // optional action run before install
+	PostInstall     string `json:"postinstall"`     // optional action validating the install, a failure rolls back
 }
 
 type localRepository struct {
@@ -171,12 +185,22 @@ func (repo *localRepository) GetInstaller(tracer trace.Tracer,
 
 	// Give each version an independent orchestration directory to support install and uninstall for two versions during rollback
 	configuration.OrchestrationDirectory = filepath.Join(configuration.OrchestrationDirectory, normalizeDirectory(version))
+
+	// the package has been validated, a manifest that cannot be read has no hooks
+	var hooks ssminstaller.Hooks
+	if manifestPath := repo.getManifestPath(tracer, packageArn, version, "manifest"); repo.filesysdep.Exists(manifestPath) {
+		if manifest, err := parsePackageManifest(tracer, repo.filesysdep, manifestPath, packageArn, version); err == nil {
+			hooks = ssminstaller.Hooks{PreInstall: manifest.PreInstall, PostInstall: manifest.PostInstall}
+		}
+	}
+
 	return ssminstaller.New(packageArn,
 		version,
 		additionalArguments,
 		repo.getPackageVersionPath(tracer, packageArn, version),
 		configuration,
-		&envdetect.CollectorImp{})
+		&envdetect.CollectorImp{},
+		hooks)
 }
 
 // GetInstalledVersion returns the version of the last successfully installed package
@@ -593,6 +617,11 @@ func validatePackageManifest(parsedManifest *PackageManifest, packageArn string,
 			return fmt.Errorf("manifest version (%v) does not match expected package version (%v)", manifestVersion, version)
 		}
 	}
+	for _, hook := range []string{parsedManifest.PreInstall, parsedManifest.PostInstall} {
+		if hook != "" && (!hookPattern.MatchString(hook) || reservedActions[strings.ToLower(hook)]) {
+			return fmt.Errorf("invalid install hook %v, hooks are script names other than install, update, uninstall and validate", hook)
+		}
+	}
 
 	return nil
 }
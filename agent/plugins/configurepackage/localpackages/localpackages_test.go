@@ -330,6 +330,27 @@ func TestValidatePackageManifest(t *testing.T) {
 			"version",
 			false,
 		},
+		{
+			"install hooks",
+			&PackageManifest{Name: "arn", Version: "version", PreInstall: "pre-install", PostInstall: "health_check"},
+			"arn",
+			"version",
+			false,
+		},
+		{
+			"install hook with path",
+			&PackageManifest{Name: "arn", Version: "version", PreInstall: "../preinstall"},
+			"arn",
+			"version",
+			true,
+		},
+		{
+			"reserved install hook",
+			&PackageManifest{Name: "arn", Version: "version", PostInstall: "Uninstall"},
+			"arn",
+			"version",
+			true,
+		},
 	}
 
 	for _, testdata := range data {
@@ -49,10 +49,17 @@ type PackageService interface {
 	ReportResult(tracer trace.Tracer, result PackageResult) error
 }
 
+// VersionLister is implemented by package services that can list the versions of a package,
+// which is needed to resolve version constraints
+type VersionLister interface {
+	ListVersions(tracer trace.Tracer, packageName string) ([]string, error)
+}
+
 const (
 	PackageServiceName_ssms3       = "ssms3"
 	PackageServiceName_birdwatcher = "birdwatcherUsingBirdwatcherArchive"
 	PackageServiceName_document    = "birdwatcherUsingDocumentArchive"
+	PackageServiceName_mirror      = "birdwatcherUsingMirrorArchive"
 )
 
 // ByTiming implements sort.Interface for []*packageservice.Trace based on the
@@ -74,13 +74,14 @@ func (m *execDepImp) ExecuteDocument(context context.T, pluginInput []contracts.
 		},
 		InstancePluginsInformation: pluginInput,
 	}
-	//specify the subdocument's bookkeeping location
+	//specify the subdocument's bookkeeping location, its states are kept in memory since the state store of the
+	//instance belongs to the agent process
 	instanceID, err := instance.InstanceID()
 	if err != nil {
 		log.Error("failed to load instance id")
 		return
 	}
-	docStore := executer.NewDocumentFileStore(context, documentID, instanceID, appconfig.DefaultLocationOfCurrent, &docState, docmanager.NewDocumentFileMgr(appconfig.DefaultDataStorePath, appconfig.DefaultDocumentRootDirName, appconfig.DefaultLocationOfState))
+	docStore := executer.NewDocumentFileStore(context, documentID, instanceID, appconfig.DefaultLocationOfCurrent, &docState, docmanager.NewMemoryDocumentMgr())
 	cancelFlag := task.NewChanneledCancelFlag()
 	resChan := exe.Run(cancelFlag, &docStore)
 
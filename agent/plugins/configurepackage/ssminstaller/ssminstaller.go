@@ -40,6 +40,15 @@ type Installer struct {
 	packagePath         string
 	config              contracts.Configuration // TODO:MF: See if we can use a smaller struct that has just the things we need
 	envdetectCollector  envdetect.Collector
+	hooks               Hooks
+}
+
+// Hooks are the optional actions the package manifest runs around the install, named after their sh or ps1 script
+type Hooks struct {
+	// PreInstall runs before install and update, a failure stops the install
+	PreInstall string
+	// PostInstall runs after validate, a failure rolls back to the previously installed version
+	PostInstall string
 }
 
 type ActionType uint8
@@ -64,7 +73,8 @@ func New(packageName string,
 	additionalArguments string,
 	packagePath string,
 	configuration contracts.Configuration,
-	envdetectCollector envdetect.Collector) *Installer {
+	envdetectCollector envdetect.Collector,
+	hooks Hooks) *Installer {
 	return &Installer{
 		filesysdep:          &fileSysDepImp{},
 		execdep:             &execDepImp{},
@@ -74,14 +84,21 @@ func New(packageName string,
 		packagePath:         packagePath,
 		config:              configuration,
 		envdetectCollector:  envdetectCollector,
+		hooks:               hooks,
 	}
 }
 
 func (inst *Installer) Install(tracer trace.Tracer, context context.T) contracts.PluginOutputter {
+	if output := inst.executeHook(tracer, context, inst.hooks.PreInstall); output.GetStatus() != contracts.ResultStatusSuccess {
+		return output
+	}
 	return inst.executeAction(tracer, context, ACTION_INSTALL)
 }
 
 func (inst *Installer) Update(tracer trace.Tracer, context context.T) contracts.PluginOutputter {
+	if output := inst.executeHook(tracer, context, inst.hooks.PreInstall); output.GetStatus() != contracts.ResultStatusSuccess {
+		return output
+	}
 	return inst.executeAction(tracer, context, ACTION_UPDATE)
 }
 
@@ -90,7 +107,11 @@ func (inst *Installer) Uninstall(tracer trace.Tracer, context context.T) contrac
 }
 
 func (inst *Installer) Validate(tracer trace.Tracer, context context.T) contracts.PluginOutputter {
-	return inst.executeAction(tracer, context, ACTION_VALIDATE)
+	output := inst.executeAction(tracer, context, ACTION_VALIDATE)
+	if inst.hooks.PostInstall == "" || output.GetStatus() != contracts.ResultStatusSuccess {
+		return output
+	}
+	return inst.executeHook(tracer, context, inst.hooks.PostInstall)
 }
 
 func (inst *Installer) Version() string {
@@ -130,6 +151,24 @@ func (inst *Installer) executeAction(tracer trace.Tracer, context context.T, act
 	return output
 }
 
+// executeHook executes an action defined in the package manifest, unlike the install actions its script must exist
+func (inst *Installer) executeHook(tracer trace.Tracer, context context.T, actionName string) contracts.PluginOutputter {
+	if actionName == "" {
+		output := &trace.PluginOutputTrace{Tracer: tracer}
+		output.SetStatus(contracts.ResultStatusSuccess)
+		return output
+	}
+	if exists, _, _ := inst.resolveAction(tracer, actionName); !exists {
+		hooktrace := tracer.BeginSection(fmt.Sprintf("execute action: %s", actionName))
+		err := fmt.Errorf("%v action defined in the package manifest has no sh or ps1 script", actionName)
+		hooktrace.WithError(err).End()
+		output := &trace.PluginOutputTrace{Tracer: tracer}
+		output.MarkAsFailed(nil, nil)
+		return output
+	}
+	return inst.executeAction(tracer, context, actionName)
+}
+
 // getActionPath is a helper function that builds the path to an action document file
 func (inst *Installer) getActionPath(actionName string, extension string) string {
 	return filepath.Join(inst.packagePath, fmt.Sprintf("%v.%v", actionName, extension))
@@ -311,6 +311,90 @@ func TestValidate_NoAction(t *testing.T) {
 	assert.Equal(t, contracts.ResultStatusSuccess, output.GetStatus())
 }
 
+func TestInstall_PreInstallFailed(t *testing.T) {
+	// Setup mocks with expectations, the hook is resolved before it is read
+	mockFileSys := MockedFileSys{}
+	actionPathNoExt := path.Join(testPackagePath, "preinstall")
+	mockReadAction(t, &mockFileSys, actionPathNoExt, []byte("echo sh"), []byte{}, false)
+	mockReadAction(t, &mockFileSys, actionPathNoExt, []byte("echo sh"), []byte{}, false)
+
+	mockExec := MockedExec{}
+	mockExec.On("ExecuteDocument", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(map[string]*contracts.PluginResult{"Foo": {Status: contracts.ResultStatusFailed, StandardError: "precondition error"}}).Once()
+
+	mockEnvdetectCollector := &envdetect.CollectorMock{}
+	mockEnvdetectCollector.On("CollectData", mock.Anything).Return(&environmentStub, nil).Once()
+
+	tracer := trace.NewTracer(log.NewMockLog())
+
+	// Instantiate installer with mock
+	inst := Installer{filesysdep: &mockFileSys,
+		execdep:            &mockExec,
+		packagePath:        testPackagePath,
+		envdetectCollector: mockEnvdetectCollector,
+		hooks:              Hooks{PreInstall: "preinstall"}}
+
+	// Call and validate mock expectations and return value, install is not executed
+	output := inst.Install(tracer, contextMock)
+	mockFileSys.AssertExpectations(t)
+	mockExec.AssertExpectations(t)
+	assert.Equal(t, contracts.ResultStatusFailed, output.GetStatus())
+	assert.Contains(t, output.GetStderr(), "precondition error")
+}
+
+func TestValidate_PostInstallSuccess(t *testing.T) {
+	// Setup mocks with expectations
+	mockFileSys := MockedFileSys{}
+	mockReadAction(t, &mockFileSys, path.Join(testPackagePath, "validate"), []byte{}, []byte{}, false)
+	actionPathNoExt := path.Join(testPackagePath, "healthcheck")
+	mockReadAction(t, &mockFileSys, actionPathNoExt, []byte{}, []byte("echo ps1"), false)
+	mockReadAction(t, &mockFileSys, actionPathNoExt, []byte{}, []byte("echo ps1"), false)
+
+	mockExec := MockedExec{}
+	mockExec.On("ExecuteDocument", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(map[string]*contracts.PluginResult{"Foo": {Status: contracts.ResultStatusSuccess}}).Once()
+
+	mockEnvdetectCollector := &envdetect.CollectorMock{}
+	mockEnvdetectCollector.On("CollectData", mock.Anything).Return(&environmentStub, nil).Once()
+
+	tracer := trace.NewTracer(log.NewMockLog())
+
+	// Instantiate installer with mock
+	inst := Installer{filesysdep: &mockFileSys,
+		execdep:            &mockExec,
+		packagePath:        testPackagePath,
+		envdetectCollector: mockEnvdetectCollector,
+		hooks:              Hooks{PostInstall: "healthcheck"}}
+
+	// Call and validate mock expectations and return value
+	output := inst.Validate(tracer, contextMock)
+	mockFileSys.AssertExpectations(t)
+	mockExec.AssertExpectations(t)
+	assert.Empty(t, output.GetStderr())
+	assert.Equal(t, contracts.ResultStatusSuccess, output.GetStatus())
+}
+
+func TestValidate_PostInstallMissing(t *testing.T) {
+	// Setup mocks with expectations
+	mockFileSys := MockedFileSys{}
+	mockReadAction(t, &mockFileSys, path.Join(testPackagePath, "validate"), []byte{}, []byte{}, false)
+	mockReadAction(t, &mockFileSys, path.Join(testPackagePath, "healthcheck"), []byte{}, []byte{}, false)
+	mockExec := MockedExec{}
+
+	tracer := trace.NewTracer(log.NewMockLog())
+
+	// Instantiate installer with mock
+	inst := Installer{filesysdep: &mockFileSys,
+		execdep:     &mockExec,
+		packagePath: testPackagePath,
+		hooks:       Hooks{PostInstall: "healthcheck"}}
+
+	// Call and validate mock expectations and return value
+	output := inst.Validate(tracer, contextMock)
+	mockFileSys.AssertExpectations(t)
+	mockExec.AssertExpectations(t)
+	assert.Equal(t, contracts.ResultStatusFailed, output.GetStatus())
+	assert.Contains(t, tracer.ToPluginOutput().GetStderr(), "healthcheck action defined in the package manifest has no sh or ps1 script")
+}
+
 func TestUninstall_Success(t *testing.T) {
 	// Setup mocks with expectations
 	mockFileSys := MockedFileSys{}
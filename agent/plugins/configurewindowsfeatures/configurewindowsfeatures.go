@@ -0,0 +1,295 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package configurewindowsfeatures implements the aws:configureWindowsFeatures plugin, which installs and removes
+// Windows Server roles and features.
+package configurewindowsfeatures
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strings"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/context"
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/iohandler"
+	"github.com/aws/amazon-ssm-agent/agent/jsonutil"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/task"
+)
+
+// Desired states of a feature
+const (
+	StatePresent = "present"
+	StateAbsent  = "absent"
+)
+
+// Changes reported for each feature
+const (
+	ChangeInstalled = "installed"
+	ChangeRemoved   = "removed"
+	ChangeUnchanged = "unchanged"
+	ChangeFailed    = "failed"
+)
+
+// Values of the RestartNeeded property returned by the ServerManager cmdlets, Maybe does not request a reboot
+const (
+	restartNeededYes = "Yes"
+	restartNeededNo  = "No"
+)
+
+// exitCodeNoChangeNeeded is the ExitCode of the ServerManager cmdlets when the feature is already in the desired state
+const exitCodeNoChangeNeeded = "NoChangeNeeded"
+
+var featureNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]*$`)
+
+// runPowerShell runs a script and returns its standard output
+var runPowerShell = func(script string) (string, error) {
+	command := exec.Command(appconfig.PowerShellPluginCommandName, "-InputFormat", "None", "-NoProfile", "-NonInteractive", "-Command", script)
+	var stdout, stderr bytes.Buffer
+	command.Stdout = &stdout
+	command.Stderr = &stderr
+	if err := command.Run(); err != nil {
+		return stdout.String(), fmt.Errorf("%v %v", err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.String(), nil
+}
+
+// Plugin is the type for the aws:configureWindowsFeatures plugin.
+type Plugin struct {
+}
+
+// ConfigureWindowsFeaturesPluginInput represents the parameters of the aws:configureWindowsFeatures plugin.
+type ConfigureWindowsFeaturesPluginInput struct {
+	contracts.PluginInput
+	Features []FeatureInput `json:"features"`
+	// Source is the side-by-side store or WIM image used when the feature files were removed from the image
+	Source string `json:"source"`
+	// NoReboot reports the restart required by a change instead of restarting the instance
+	NoReboot bool `json:"noReboot"`
+}
+
+// FeatureInput is the desired state of one role or feature
+type FeatureInput struct {
+	Name                   string `json:"name"`
+	State                  string `json:"state"`
+	IncludeAllSubFeatures  bool   `json:"includeAllSubFeatures"`
+	IncludeManagementTools bool   `json:"includeManagementTools"`
+}
+
+// FeatureResult is the change made to one role or feature
+type FeatureResult struct {
+	Name          string           `json:"name"`
+	State         string           `json:"state"`
+	Change        string           `json:"change"`
+	RestartNeeded string           `json:"restartNeeded,omitempty"`
+	Features      []ChangedFeature `json:"features,omitempty"`
+	Error         string           `json:"error,omitempty"`
+}
+
+// ChangedFeature is a feature or sub-feature changed by the cmdlet
+type ChangedFeature struct {
+	Name          string `json:"name"`
+	DisplayName   string `json:"displayName"`
+	Success       bool   `json:"success"`
+	RestartNeeded bool   `json:"restartNeeded"`
+}
+
+// cmdletResult is the part of the ServerManager FeatureOperationResult printed by the script
+type cmdletResult struct {
+	Success       bool
+	ExitCode      string
+	RestartNeeded string
+	Features      []ChangedFeature
+}
+
+// NewPlugin returns a new instance of the plugin.
+func NewPlugin() (*Plugin, error) {
+	var plugin Plugin
+	return &plugin, nil
+}
+
+// Name returns the name of the plugin
+func Name() string {
+	return appconfig.PluginNameAwsConfigureWindowsFeatures
+}
+
+// Execute installs or removes each feature of the plugin input and requests a reboot when a change needs one.
+func (p *Plugin) Execute(context context.T, config contracts.Configuration, cancelFlag task.CancelFlag, output iohandler.IOHandler) {
+	log := context.Log()
+	log.Infof("%v started with configuration %v", Name(), config)
+
+	if cancelFlag.ShutDown() {
+		output.MarkAsShutdown()
+	} else if cancelFlag.Canceled() {
+		output.MarkAsCancelled()
+	} else if input, err := parseAndValidateInput(config.Properties); err != nil {
+		output.MarkAsFailed(err)
+	} else {
+		runConfigureFeatures(log, input, cancelFlag, output)
+	}
+}
+
+// runConfigureFeatures applies the features in order, a feature that fails does not stop the following ones
+func runConfigureFeatures(log log.T, input *ConfigureWindowsFeaturesPluginInput, cancelFlag task.CancelFlag, output iohandler.IOHandler) {
+	var results []FeatureResult
+	failed := 0
+	restartNeeded := false
+	for _, feature := range input.Features {
+		if cancelFlag.Canceled() {
+			output.MarkAsCancelled()
+			return
+		}
+		result := applyFeature(log, feature, input.Source)
+		results = append(results, result)
+		switch {
+		case result.Change == ChangeFailed:
+			failed++
+			output.AppendErrorf("%v: %v", result.Name, result.Error)
+		case result.RestartNeeded != "" && result.RestartNeeded != restartNeededNo:
+			output.AppendInfof("%v: %v, restart needed: %v", result.Name, result.Change, result.RestartNeeded)
+		default:
+			output.AppendInfof("%v: %v", result.Name, result.Change)
+		}
+		if result.RestartNeeded == restartNeededYes {
+			restartNeeded = true
+		}
+	}
+	output.SetOutput(results)
+
+	if failed > 0 {
+		output.MarkAsFailed(fmt.Errorf("failed to configure %v of %v features", failed, len(input.Features)))
+		return
+	}
+	if restartNeeded {
+		if input.NoReboot {
+			output.AppendInfo("A restart is needed to complete the feature changes")
+		} else {
+			output.MarkAsSuccessWithReboot()
+			return
+		}
+	}
+	output.MarkAsSucceeded()
+}
+
+// applyFeature runs Install-WindowsFeature or Uninstall-WindowsFeature for one feature
+func applyFeature(log log.T, feature FeatureInput, source string) FeatureResult {
+	result := FeatureResult{Name: feature.Name, State: feature.State}
+	script := buildScript(feature, source)
+	log.Debugf("Running %v", script)
+
+	stdout, err := runPowerShell(script)
+	if err != nil {
+		result.Change = ChangeFailed
+		result.Error = err.Error()
+		return result
+	}
+	var cmdlet cmdletResult
+	if err = json.Unmarshal([]byte(strings.TrimSpace(stdout)), &cmdlet); err != nil {
+		result.Change = ChangeFailed
+		result.Error = fmt.Sprintf("failed to parse the cmdlet result %v: %v", strings.TrimSpace(stdout), err)
+		return result
+	}
+
+	result.RestartNeeded = cmdlet.RestartNeeded
+	result.Features = cmdlet.Features
+	switch {
+	case !cmdlet.Success:
+		result.Change = ChangeFailed
+		result.Error = fmt.Sprintf("the cmdlet failed with exit code %v", cmdlet.ExitCode)
+	case cmdlet.ExitCode == exitCodeNoChangeNeeded:
+		result.Change = ChangeUnchanged
+	case feature.State == StateAbsent:
+		result.Change = ChangeRemoved
+	default:
+		result.Change = ChangeInstalled
+	}
+	return result
+}
+
+// buildScript returns the script changing the feature and printing the result as json,
+// enums are converted to strings so the json does not depend on the cmdlet version
+func buildScript(feature FeatureInput, source string) string {
+	var script strings.Builder
+	script.WriteString("$ErrorActionPreference = 'Stop'; $ProgressPreference = 'SilentlyContinue'; ")
+	if feature.State == StateAbsent {
+		script.WriteString("$result = Uninstall-WindowsFeature -Name '" + feature.Name + "'")
+		if feature.IncludeManagementTools {
+			script.WriteString(" -IncludeManagementTools")
+		}
+	} else {
+		script.WriteString("$result = Install-WindowsFeature -Name '" + feature.Name + "'")
+		if feature.IncludeAllSubFeatures {
+			script.WriteString(" -IncludeAllSubFeature")
+		}
+		if feature.IncludeManagementTools {
+			script.WriteString(" -IncludeManagementTools")
+		}
+		if source != "" {
+			script.WriteString(" -Source '" + strings.Replace(source, "'", "''", -1) + "'")
+		}
+	}
+	script.WriteString("; [pscustomobject]@{ " +
+		"Success = [bool]$result.Success; " +
+		"ExitCode = [string]$result.ExitCode; " +
+		"RestartNeeded = [string]$result.RestartNeeded; " +
+		"Features = @($result.FeatureResult | ForEach-Object { [pscustomobject]@{ " +
+		"Name = [string]$_.Name; DisplayName = [string]$_.DisplayName; " +
+		"Success = [bool]$_.Success; RestartNeeded = [bool]$_.RestartNeeded } }) " +
+		"} | ConvertTo-Json -Compress -Depth 4")
+	return script.String()
+}
+
+// parseAndValidateInput parses the plugin properties and fills in the defaults
+func parseAndValidateInput(rawPluginInput interface{}) (*ConfigureWindowsFeaturesPluginInput, error) {
+	var input ConfigureWindowsFeaturesPluginInput
+	if err := jsonutil.Remarshal(rawPluginInput, &input); err != nil {
+		return nil, fmt.Errorf("invalid format in plugin properties %v; \nerror %v", rawPluginInput, err)
+	}
+	if err := validateInput(&input); err != nil {
+		return nil, fmt.Errorf("invalid input: %v", err)
+	}
+	return &input, nil
+}
+
+// validateInput ensures the plugin input matches the defined schema
+func validateInput(input *ConfigureWindowsFeaturesPluginInput) error {
+	if len(input.Features) == 0 {
+		return errors.New("at least one feature must be specified")
+	}
+	for i := range input.Features {
+		feature := &input.Features[i]
+		// the name is quoted in the script so it is restricted to the characters used by feature names
+		if !featureNamePattern.MatchString(feature.Name) {
+			return fmt.Errorf("invalid feature name %v", feature.Name)
+		}
+		if feature.State == "" {
+			feature.State = StatePresent
+		}
+		switch feature.State {
+		case StatePresent:
+		case StateAbsent:
+			if feature.IncludeAllSubFeatures {
+				return fmt.Errorf("includeAllSubFeatures cannot be specified for %v which is absent, sub-features are always removed", feature.Name)
+			}
+		default:
+			return fmt.Errorf("unsupported state %v for feature %v", feature.State, feature.Name)
+		}
+	}
+	return nil
+}
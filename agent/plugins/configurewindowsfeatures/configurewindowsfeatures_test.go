@@ -0,0 +1,134 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package configurewindowsfeatures
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/iohandler"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/task"
+	"github.com/stretchr/testify/assert"
+)
+
+var logger = log.NewMockLog()
+
+// mockPowerShell returns the output of the first script containing each key and records the scripts it ran
+func mockPowerShell(outputs map[string]string) (*[]string, func()) {
+	runPowerShellOrig := runPowerShell
+	var scripts []string
+	runPowerShell = func(script string) (string, error) {
+		scripts = append(scripts, script)
+		for key, output := range outputs {
+			if strings.Contains(script, key) {
+				return output, nil
+			}
+		}
+		return "", errors.New("exit status 1 Install-WindowsFeature : ArgumentNotValid: The role, role service, or feature name is not valid")
+	}
+	return &scripts, func() { runPowerShell = runPowerShellOrig }
+}
+
+func TestRunConfigureFeatures(t *testing.T) {
+	scripts, restore := mockPowerShell(map[string]string{
+		"Install-WindowsFeature -Name 'Web-Server'": `{"Success":true,"ExitCode":"SuccessRestartRequired","RestartNeeded":"Yes",` +
+			`"Features":[{"Name":"Web-Server","DisplayName":"Web Server (IIS)","Success":true,"RestartNeeded":true}]}`,
+		"Uninstall-WindowsFeature -Name 'Telnet-Client'": `{"Success":true,"ExitCode":"NoChangeNeeded","RestartNeeded":"No","Features":[]}`,
+	})
+	defer restore()
+
+	input, err := parseAndValidateInput(map[string]interface{}{
+		"features": []interface{}{
+			map[string]interface{}{"name": "Web-Server", "includeAllSubFeatures": true, "includeManagementTools": true},
+			map[string]interface{}{"name": "Telnet-Client", "state": "absent"},
+		},
+		"source": "D:\\sources\\sxs",
+	})
+	assert.NoError(t, err)
+
+	output := iohandler.DefaultIOHandler{}
+	runConfigureFeatures(logger, input, task.NewChanneledCancelFlag(), &output)
+
+	assert.Equal(t, contracts.ResultStatusSuccessAndReboot, output.GetStatus())
+	assert.Contains(t, output.GetStdout(), "Web-Server: installed, restart needed: Yes")
+	assert.Contains(t, output.GetStdout(), "Telnet-Client: unchanged")
+	assert.Contains(t, (*scripts)[0], "-IncludeAllSubFeature -IncludeManagementTools -Source 'D:\\sources\\sxs'")
+	assert.NotContains(t, (*scripts)[1], "-Source")
+}
+
+func TestRunConfigureFeatures_NoReboot(t *testing.T) {
+	_, restore := mockPowerShell(map[string]string{
+		"Web-Server": `{"Success":true,"ExitCode":"SuccessRestartRequired","RestartNeeded":"Yes","Features":[]}`,
+	})
+	defer restore()
+
+	input := &ConfigureWindowsFeaturesPluginInput{Features: []FeatureInput{{Name: "Web-Server", State: StatePresent}}, NoReboot: true}
+	output := iohandler.DefaultIOHandler{}
+	runConfigureFeatures(logger, input, task.NewChanneledCancelFlag(), &output)
+
+	assert.Equal(t, contracts.ResultStatusSuccess, output.GetStatus())
+	assert.Contains(t, output.GetStdout(), "A restart is needed")
+}
+
+func TestRunConfigureFeatures_Failure(t *testing.T) {
+	_, restore := mockPowerShell(map[string]string{
+		"Web-Server": `{"Success":true,"ExitCode":"Success","RestartNeeded":"No","Features":[]}`,
+		"Hyper-V":    `{"Success":false,"ExitCode":"Failed","RestartNeeded":"No","Features":[]}`,
+	})
+	defer restore()
+
+	input := &ConfigureWindowsFeaturesPluginInput{Features: []FeatureInput{
+		{Name: "Web-Server", State: StatePresent},
+		{Name: "Hyper-V", State: StatePresent},
+		{Name: "Unknown", State: StatePresent},
+	}}
+	output := iohandler.DefaultIOHandler{}
+	runConfigureFeatures(logger, input, task.NewChanneledCancelFlag(), &output)
+
+	assert.Equal(t, contracts.ResultStatusFailed, output.GetStatus())
+	assert.Contains(t, output.GetStdout(), "Web-Server: installed")
+	assert.Contains(t, output.GetStderr(), "Hyper-V: the cmdlet failed with exit code Failed")
+	assert.Contains(t, output.GetStderr(), "Unknown: exit status 1")
+	assert.Contains(t, output.GetStderr(), "failed to configure 2 of 3 features")
+}
+
+func TestBuildScript(t *testing.T) {
+	script := buildScript(FeatureInput{Name: "Web-Server", State: StateAbsent, IncludeManagementTools: true}, "C:\\it's")
+	assert.Contains(t, script, "$result = Uninstall-WindowsFeature -Name 'Web-Server' -IncludeManagementTools;")
+	assert.NotContains(t, script, "-Source")
+
+	script = buildScript(FeatureInput{Name: "NET-Framework-Core", State: StatePresent}, "C:\\it's")
+	assert.Contains(t, script, "-Source 'C:\\it''s'")
+	assert.Contains(t, script, "ConvertTo-Json")
+}
+
+func TestValidateInput(t *testing.T) {
+	input := ConfigureWindowsFeaturesPluginInput{Features: []FeatureInput{{Name: "RSAT-AD-Tools"}}}
+	assert.NoError(t, validateInput(&input))
+	assert.Equal(t, StatePresent, input.Features[0].State)
+
+	invalid := []FeatureInput{
+		{Name: ""},
+		{Name: "Web-Server'; Restart-Computer; '"},
+		{Name: "Web-Server", State: "installed"},
+		{Name: "Web-Server", State: StateAbsent, IncludeAllSubFeatures: true},
+	}
+	for _, feature := range invalid {
+		assert.Error(t, validateInput(&ConfigureWindowsFeaturesPluginInput{Features: []FeatureInput{feature}}), feature.Name)
+	}
+	assert.Error(t, validateInput(&ConfigureWindowsFeaturesPluginInput{}))
+}
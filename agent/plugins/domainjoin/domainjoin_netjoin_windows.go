@@ -0,0 +1,377 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+//go:build windows
+// +build windows
+
+package domainjoin
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"syscall"
+	"unsafe"
+
+	"github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/iohandler"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/platform"
+	"github.com/aws/amazon-ssm-agent/agent/ssm/ssmparameterresolver"
+)
+
+// Actions of the domain join plugin
+const (
+	actionJoin   = "join"
+	actionUnjoin = "unjoin"
+	actionRejoin = "rejoin"
+)
+
+// Placeholders of the computer name template
+const (
+	computerNameInstanceId = "{instanceId}"
+	computerNameRegion     = "{region}"
+	computerNameRandom     = "{random}"
+)
+
+// maxComputerNameLength is the length limit of NetBIOS computer names
+const maxComputerNameLength = 15
+
+// Options of NetJoinDomain and NetUnjoinDomain
+const (
+	netSetupJoinDomain         = 0x1
+	netSetupAcctCreate         = 0x2
+	netSetupAcctDelete         = 0x4
+	netSetupDomainJoinIfJoined = 0x20
+	netSetupJoinWithNewName    = 0x400
+	netSetupProvisionOnline    = 0x40000000
+)
+
+// errorInvalidParameter is returned when an argument cannot be passed to the system call
+const errorInvalidParameter uintptr = 87
+
+// computerNamePhysicalDnsHostname is the COMPUTER_NAME_FORMAT changed by SetComputerNameEx,
+// the NetBIOS name is derived from it on the next restart
+const computerNamePhysicalDnsHostname = 5
+
+var (
+	modNetapi32                 = syscall.NewLazyDLL("netapi32.dll")
+	modKernel32                 = syscall.NewLazyDLL("kernel32.dll")
+	procNetJoinDomain           = modNetapi32.NewProc("NetJoinDomain")
+	procNetUnjoinDomain         = modNetapi32.NewProc("NetUnjoinDomain")
+	procNetRequestOfflineDomain = modNetapi32.NewProc("NetRequestOfflineDomainJoin")
+	procSetComputerNameEx       = modKernel32.NewProc("SetComputerNameExW")
+)
+
+var validComputerName = regexp.MustCompile(`^[A-Za-z0-9-]+$`)
+
+// netJoinErrors describes the error codes returned by the domain join functions, with the usual cause of each one
+var netJoinErrors = map[uintptr]string{
+	5:    "access denied, the account is not allowed to join computers to the domain or to create computer objects in the OU",
+	52:   "a computer with the same name already exists on the network",
+	53:   "the network path was not found, check the DNS servers and the connectivity to the domain controllers",
+	86:   "the password is not correct",
+	87:   "invalid parameter, check the OU distinguished name and the computer name",
+	1212: "the domain name is not valid",
+	1219: "the domain controller already has a connection from this computer with different credentials",
+	1326: "logon failure, unknown user name or bad password",
+	1355: "the domain does not exist or could not be contacted",
+	1789: "the trust relationship between this computer and the domain failed",
+	1909: "the account is locked out",
+	2224: "the computer account already exists and the account is not allowed to reuse it",
+	2351: "the computer name is not valid",
+	2453: "no domain controller could be found for the domain",
+	2691: "the computer is already joined to a domain, use the rejoin action to move it",
+	2692: "the computer is not joined to a domain",
+	2693: "the computer is a domain controller and cannot be unjoined",
+	8240: "the OU does not exist",
+	8242: "the OU distinguished name is not valid",
+	8557: "the account exceeded the number of computers it is allowed to join to the domain",
+}
+
+// Makes the system calls variables, so that we can mock them for unit tests
+var netJoinDomain = callNetJoinDomain
+var netUnjoinDomain = callNetUnjoinDomain
+var netRequestOfflineDomainJoin = callNetRequestOfflineDomainJoin
+var setComputerName = callSetComputerName
+var getInstanceId = platform.InstanceID
+var resolveParameter = resolveParameterReference
+
+// usesNetJoin returns true if the input asks for a join that the agent makes itself instead of AWS.DomainJoin.exe
+func usesNetJoin(pluginInput DomainJoinPluginInput) bool {
+	return pluginInput.OfflineJoinBlob != "" ||
+		pluginInput.DomainUsername != "" ||
+		pluginInput.Action == actionUnjoin ||
+		pluginInput.Action == actionRejoin
+}
+
+// runNetJoin runs the offline join, credential join and unjoin actions with the NetJoin functions
+func runNetJoin(log log.T, pluginInput DomainJoinPluginInput, out iohandler.IOHandler) {
+	var err error
+	switch {
+	case pluginInput.Action == actionUnjoin:
+		err = unjoin(log, pluginInput, out)
+	case pluginInput.OfflineJoinBlob != "":
+		err = offlineJoin(log, pluginInput, out)
+	default:
+		err = credentialJoin(log, pluginInput, out)
+	}
+	if err != nil {
+		out.MarkAsFailed(err)
+		return
+	}
+	// the domain membership and the computer name only take effect after a restart
+	out.MarkAsSuccessWithReboot()
+}
+
+// offlineJoin loads the provisioning data created with djoin /provision into the running operating system
+func offlineJoin(log log.T, pluginInput DomainJoinPluginInput, out iohandler.IOHandler) error {
+	blob, err := resolveParameter(log, pluginInput.OfflineJoinBlob)
+	if err != nil {
+		return fmt.Errorf("failed to resolve the offline join blob: %v", err)
+	}
+	provisionData, err := decodeProvisionData(blob)
+	if err != nil {
+		return err
+	}
+	out.AppendInfo("Requesting offline domain join")
+	if code := netRequestOfflineDomainJoin(provisionData, os.Getenv("SystemRoot")); code != 0 {
+		return netJoinError("NetRequestOfflineDomainJoin", code)
+	}
+	return nil
+}
+
+// credentialJoin joins the domain with the credentials of the input, creating the computer object in the OU
+func credentialJoin(log log.T, pluginInput DomainJoinPluginInput, out iohandler.IOHandler) error {
+	if pluginInput.DirectoryName == "" {
+		return fmt.Errorf("directoryName is required")
+	}
+	username, password, err := resolveCredentials(log, pluginInput)
+	if err != nil {
+		return err
+	}
+
+	options := uint32(netSetupJoinDomain | netSetupAcctCreate)
+	if pluginInput.Action == actionRejoin {
+		options |= netSetupDomainJoinIfJoined
+	}
+	if pluginInput.ComputerName != "" {
+		var computerName string
+		if computerName, err = expandComputerName(pluginInput.ComputerName); err != nil {
+			return err
+		}
+		out.AppendInfof("Renaming computer to %v", computerName)
+		if err = setComputerName(computerName); err != nil {
+			return fmt.Errorf("failed to rename computer to %v: %v", computerName, err)
+		}
+		options |= netSetupJoinWithNewName
+	}
+
+	out.AppendInfof("Joining domain %v", pluginInput.DirectoryName)
+	if pluginInput.DirectoryOU != "" {
+		out.AppendInfof("Creating computer account in %v", pluginInput.DirectoryOU)
+	}
+	if code := netJoinDomain(pluginInput.DirectoryName, pluginInput.DirectoryOU, username, password, options); code != 0 {
+		return netJoinError("NetJoinDomain", code)
+	}
+	return nil
+}
+
+// unjoin removes the computer from its domain, the computer account is disabled when credentials are given
+func unjoin(log log.T, pluginInput DomainJoinPluginInput, out iohandler.IOHandler) error {
+	var username, password string
+	options := uint32(0)
+	if pluginInput.DomainUsername != "" {
+		var err error
+		if username, password, err = resolveCredentials(log, pluginInput); err != nil {
+			return err
+		}
+		options = netSetupAcctDelete
+	}
+	out.AppendInfo("Unjoining domain")
+	if code := netUnjoinDomain(username, password, options); code != 0 {
+		return netJoinError("NetUnjoinDomain", code)
+	}
+	return nil
+}
+
+// resolveCredentials resolves the username and password, the password must be a parameter reference
+// so it never appears in the document, Secrets Manager secrets are referenced through /aws/reference/secretsmanager
+func resolveCredentials(log log.T, pluginInput DomainJoinPluginInput) (username string, password string, err error) {
+	if pluginInput.DomainUsername == "" || pluginInput.DomainPassword == "" {
+		return "", "", fmt.Errorf("domainUsername and domainPassword are required")
+	}
+	if username, err = resolveParameter(log, pluginInput.DomainUsername); err != nil {
+		return "", "", fmt.Errorf("failed to resolve the domain username: %v", err)
+	}
+	if password, err = resolveParameter(log, pluginInput.DomainPassword); err != nil {
+		return "", "", fmt.Errorf("failed to resolve the domain password: %v", err)
+	}
+	return username, password, nil
+}
+
+// resolveParameterReference returns the value of a parameter store reference, values that are not references are returned as is
+func resolveParameterReference(log log.T, value string) (string, error) {
+	bridge := ssmparameterresolver.NewSsmParameterResolverBridge(ssmparameterresolver.NewService())
+	if !bridge.IsValidParameterStoreReference(value) {
+		return value, nil
+	}
+	return bridge.GetParameterFromSsmParameterStore(log, value)
+}
+
+// validateNetJoinInput ensures secrets are passed as parameter references and the action is known
+func validateNetJoinInput(pluginInput DomainJoinPluginInput) error {
+	switch pluginInput.Action {
+	case "", actionJoin, actionUnjoin, actionRejoin:
+	default:
+		return fmt.Errorf("unsupported action %v", pluginInput.Action)
+	}
+	if pluginInput.Action == actionRejoin && pluginInput.DomainUsername == "" {
+		return fmt.Errorf("domainUsername and domainPassword are required to rejoin")
+	}
+	if pluginInput.OfflineJoinBlob != "" && pluginInput.DomainUsername != "" {
+		return fmt.Errorf("offlineJoinBlob cannot be combined with domainUsername")
+	}
+	if pluginInput.OfflineJoinBlob != "" && !isParameterReference(pluginInput.OfflineJoinBlob) {
+		return fmt.Errorf("offlineJoinBlob must be a SecureString parameter reference")
+	}
+	if pluginInput.DomainPassword != "" && !isParameterReference(pluginInput.DomainPassword) {
+		return fmt.Errorf("domainPassword must be a SecureString parameter reference")
+	}
+	return nil
+}
+
+func isParameterReference(value string) bool {
+	return strings.HasPrefix(strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(value), "{{")), "ssm-secure:")
+}
+
+// expandComputerName replaces the placeholders of the computer name template.
+// The instance id is shortened to its last characters when the name would exceed the NetBIOS limit.
+func expandComputerName(template string) (string, error) {
+	name := template
+	if strings.Contains(name, computerNameRegion) {
+		region, err := getRegion()
+		if err != nil {
+			return "", fmt.Errorf("cannot get the instance region information")
+		}
+		name = strings.Replace(name, computerNameRegion, region, -1)
+	}
+	if strings.Contains(name, computerNameRandom) {
+		randomBytes := make([]byte, 2)
+		if _, err := rand.Read(randomBytes); err != nil {
+			return "", err
+		}
+		name = strings.Replace(name, computerNameRandom, hex.EncodeToString(randomBytes), -1)
+	}
+	if count := strings.Count(name, computerNameInstanceId); count > 0 {
+		instanceId, err := getInstanceId()
+		if err != nil {
+			return "", fmt.Errorf("cannot get the instance id: %v", err)
+		}
+		instanceId = strings.TrimPrefix(instanceId, "i-")
+		available := (maxComputerNameLength - (len(name) - count*len(computerNameInstanceId))) / count
+		if available <= 0 {
+			return "", fmt.Errorf("computer name template %v leaves no room for the instance id", template)
+		}
+		if len(instanceId) > available {
+			instanceId = instanceId[len(instanceId)-available:]
+		}
+		name = strings.Replace(name, computerNameInstanceId, instanceId, -1)
+	}
+
+	if len(name) > maxComputerNameLength {
+		return "", fmt.Errorf("computer name %v is longer than %v characters", name, maxComputerNameLength)
+	}
+	if !validComputerName.MatchString(name) || strings.Trim(name, "0123456789") == "" {
+		return "", fmt.Errorf("computer name %v is not valid, it can only contain letters, digits and hyphens and cannot be only digits", name)
+	}
+	return name, nil
+}
+
+// decodeProvisionData decodes the base64 provisioning data written by djoin /provision /savefile
+func decodeProvisionData(blob string) ([]byte, error) {
+	data, err := base64.StdEncoding.DecodeString(strings.TrimSpace(strings.Trim(blob, "\x00\ufeff")))
+	if err != nil || len(data) == 0 {
+		return nil, fmt.Errorf("offline join blob is not valid base64 provisioning data")
+	}
+	return data, nil
+}
+
+// netJoinError describes a failure code of the domain join functions
+func netJoinError(function string, code uintptr) error {
+	if description, ok := netJoinErrors[code]; ok {
+		return fmt.Errorf("%v failed with error %v: %v", function, code, description)
+	}
+	return fmt.Errorf("%v failed with error %v: %v", function, code, syscall.Errno(code).Error())
+}
+
+// utf16PtrOrNil converts an optional string argument, empty strings are passed as NULL
+func utf16PtrOrNil(value string) (uintptr, error) {
+	if value == "" {
+		return 0, nil
+	}
+	pointer, err := syscall.UTF16PtrFromString(value)
+	if err != nil {
+		return 0, err
+	}
+	return uintptr(unsafe.Pointer(pointer)), nil
+}
+
+func callNetJoinDomain(domain string, ou string, account string, password string, options uint32) uintptr {
+	domainPtr, err1 := utf16PtrOrNil(domain)
+	ouPtr, err2 := utf16PtrOrNil(ou)
+	accountPtr, err3 := utf16PtrOrNil(account)
+	passwordPtr, err4 := utf16PtrOrNil(password)
+	if err1 != nil || err2 != nil || err3 != nil || err4 != nil {
+		return errorInvalidParameter
+	}
+	ret, _, _ := procNetJoinDomain.Call(0, domainPtr, ouPtr, accountPtr, passwordPtr, uintptr(options))
+	return ret
+}
+
+func callNetUnjoinDomain(account string, password string, options uint32) uintptr {
+	accountPtr, err1 := utf16PtrOrNil(account)
+	passwordPtr, err2 := utf16PtrOrNil(password)
+	if err1 != nil || err2 != nil {
+		return errorInvalidParameter
+	}
+	ret, _, _ := procNetUnjoinDomain.Call(0, accountPtr, passwordPtr, uintptr(options))
+	return ret
+}
+
+func callNetRequestOfflineDomainJoin(provisionData []byte, windowsPath string) uintptr {
+	windowsPathPtr, err := utf16PtrOrNil(windowsPath)
+	if err != nil || len(provisionData) == 0 {
+		return errorInvalidParameter
+	}
+	ret, _, _ := procNetRequestOfflineDomain.Call(
+		uintptr(unsafe.Pointer(&provisionData[0])),
+		uintptr(len(provisionData)),
+		uintptr(netSetupProvisionOnline),
+		windowsPathPtr)
+	return ret
+}
+
+func callSetComputerName(name string) error {
+	namePtr, err := syscall.UTF16PtrFromString(name)
+	if err != nil {
+		return err
+	}
+	if ret, _, err := procSetComputerNameEx.Call(uintptr(computerNamePhysicalDnsHostname), uintptr(unsafe.Pointer(namePtr))); ret == 0 {
+		return err
+	}
+	return nil
+}
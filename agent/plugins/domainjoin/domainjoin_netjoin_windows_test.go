@@ -0,0 +1,205 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// +build windows
+
+package domainjoin
+
+import (
+	"encoding/base64"
+	"testing"
+
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/iohandler"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	testPasswordReference = "{{ssm-secure:/aws/reference/secretsmanager/domain-password}}"
+	testBlobReference     = "{{ssm-secure:djoin-blob}}"
+)
+
+// netJoinCall records the arguments of the mocked NetJoin functions
+type netJoinCall struct {
+	domain, ou, account, password string
+	options                       uint32
+	provisionData                 []byte
+	computerName                  string
+}
+
+func mockNetJoin(result uintptr) (*netJoinCall, func()) {
+	call := &netJoinCall{}
+	joinOrig, unjoinOrig, offlineOrig, renameOrig, resolveOrig, instanceOrig, regionOrig :=
+		netJoinDomain, netUnjoinDomain, netRequestOfflineDomainJoin, setComputerName, resolveParameter, getInstanceId, getRegion
+
+	netJoinDomain = func(domain string, ou string, account string, password string, options uint32) uintptr {
+		call.domain, call.ou, call.account, call.password, call.options = domain, ou, account, password, options
+		return result
+	}
+	netUnjoinDomain = func(account string, password string, options uint32) uintptr {
+		call.account, call.password, call.options = account, password, options
+		return result
+	}
+	netRequestOfflineDomainJoin = func(provisionData []byte, windowsPath string) uintptr {
+		call.provisionData = provisionData
+		return result
+	}
+	setComputerName = func(name string) error {
+		call.computerName = name
+		return nil
+	}
+	resolveParameter = func(log log.T, value string) (string, error) {
+		switch value {
+		case testPasswordReference:
+			return "Passw0rd", nil
+		case testBlobReference:
+			return base64.StdEncoding.EncodeToString([]byte("provision")), nil
+		}
+		return value, nil
+	}
+	getInstanceId = func() (string, error) { return "i-0123456789abcdef0", nil }
+	getRegion = func() (string, error) { return "us-east-1", nil }
+
+	return call, func() {
+		netJoinDomain, netUnjoinDomain, netRequestOfflineDomainJoin, setComputerName, resolveParameter, getInstanceId, getRegion =
+			joinOrig, unjoinOrig, offlineOrig, renameOrig, resolveOrig, instanceOrig, regionOrig
+	}
+}
+
+func TestCredentialJoin(t *testing.T) {
+	call, restore := mockNetJoin(0)
+	defer restore()
+
+	output := iohandler.DefaultIOHandler{}
+	runNetJoin(logger, DomainJoinPluginInput{
+		DirectoryName:  testDirectoryName,
+		DirectoryOU:    "OU=Servers,DC=corp,DC=test,DC=com",
+		ComputerName:   "web-{instanceId}",
+		DomainUsername: "CORP\\joiner",
+		DomainPassword: testPasswordReference,
+	}, &output)
+
+	assert.Equal(t, contracts.ResultStatusSuccessAndReboot, output.GetStatus())
+	assert.Equal(t, testDirectoryName, call.domain)
+	assert.Equal(t, "OU=Servers,DC=corp,DC=test,DC=com", call.ou)
+	assert.Equal(t, "CORP\\joiner", call.account)
+	assert.Equal(t, "Passw0rd", call.password)
+	assert.Equal(t, "web-6789abcdef0", call.computerName)
+	assert.Equal(t, uint32(netSetupJoinDomain|netSetupAcctCreate|netSetupJoinWithNewName), call.options)
+}
+
+func TestRejoin(t *testing.T) {
+	call, restore := mockNetJoin(0)
+	defer restore()
+
+	output := iohandler.DefaultIOHandler{}
+	runNetJoin(logger, DomainJoinPluginInput{
+		Action:         actionRejoin,
+		DirectoryName:  testDirectoryName,
+		DomainUsername: "joiner@corp.test.com",
+		DomainPassword: testPasswordReference,
+	}, &output)
+
+	assert.Equal(t, contracts.ResultStatusSuccessAndReboot, output.GetStatus())
+	assert.Equal(t, uint32(netSetupJoinDomain|netSetupAcctCreate|netSetupDomainJoinIfJoined), call.options)
+}
+
+func TestUnjoin(t *testing.T) {
+	call, restore := mockNetJoin(2692)
+	defer restore()
+
+	output := iohandler.DefaultIOHandler{}
+	runNetJoin(logger, DomainJoinPluginInput{Action: actionUnjoin}, &output)
+
+	assert.Equal(t, contracts.ResultStatusFailed, output.GetStatus())
+	assert.Contains(t, output.GetStderr(), "NetUnjoinDomain failed with error 2692: the computer is not joined to a domain")
+	assert.Equal(t, uint32(0), call.options)
+}
+
+func TestOfflineJoin(t *testing.T) {
+	call, restore := mockNetJoin(0)
+	defer restore()
+
+	output := iohandler.DefaultIOHandler{}
+	runNetJoin(logger, DomainJoinPluginInput{OfflineJoinBlob: testBlobReference}, &output)
+
+	assert.Equal(t, contracts.ResultStatusSuccessAndReboot, output.GetStatus())
+	assert.Equal(t, []byte("provision"), call.provisionData)
+}
+
+func TestNetJoinFailureDescribesError(t *testing.T) {
+	_, restore := mockNetJoin(8557)
+	defer restore()
+
+	output := iohandler.DefaultIOHandler{}
+	runNetJoin(logger, DomainJoinPluginInput{
+		DirectoryName:  testDirectoryName,
+		DomainUsername: "CORP\\joiner",
+		DomainPassword: testPasswordReference,
+	}, &output)
+
+	assert.Equal(t, contracts.ResultStatusFailed, output.GetStatus())
+	assert.Contains(t, output.GetStderr(), "exceeded the number of computers")
+}
+
+func TestExpandComputerName(t *testing.T) {
+	_, restore := mockNetJoin(0)
+	defer restore()
+
+	name, err := expandComputerName("web-{instanceId}")
+	assert.NoError(t, err)
+	assert.Equal(t, "web-6789abcdef0", name)
+
+	name, err = expandComputerName("{instanceId}")
+	assert.NoError(t, err)
+	assert.Equal(t, "23456789abcdef0", name)
+
+	name, err = expandComputerName("app-{random}")
+	assert.NoError(t, err)
+	assert.Len(t, name, len("app-")+4)
+
+	_, err = expandComputerName("{region}-server")
+	assert.Error(t, err)
+
+	_, err = expandComputerName("web_server")
+	assert.Error(t, err)
+
+	_, err = expandComputerName("12345")
+	assert.Error(t, err)
+
+	_, err = expandComputerName("a-very-long-prefix-{instanceId}")
+	assert.Error(t, err)
+}
+
+func TestValidateNetJoinInput(t *testing.T) {
+	assert.NoError(t, validateNetJoinInput(DomainJoinPluginInput{}))
+	assert.NoError(t, validateNetJoinInput(DomainJoinPluginInput{OfflineJoinBlob: testBlobReference}))
+	assert.NoError(t, validateNetJoinInput(DomainJoinPluginInput{DomainUsername: "joiner", DomainPassword: testPasswordReference}))
+
+	assert.Error(t, validateNetJoinInput(DomainJoinPluginInput{Action: "leave"}))
+	assert.Error(t, validateNetJoinInput(DomainJoinPluginInput{Action: actionRejoin}))
+	assert.Error(t, validateNetJoinInput(DomainJoinPluginInput{OfflineJoinBlob: "AAAA"}))
+	assert.Error(t, validateNetJoinInput(DomainJoinPluginInput{DomainUsername: "joiner", DomainPassword: "Passw0rd"}))
+	assert.Error(t, validateNetJoinInput(DomainJoinPluginInput{
+		OfflineJoinBlob: testBlobReference, DomainUsername: "joiner", DomainPassword: testPasswordReference}))
+}
+
+func TestDecodeProvisionData(t *testing.T) {
+	data, err := decodeProvisionData(base64.StdEncoding.EncodeToString([]byte("provision")) + "\r\n\x00")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("provision"), data)
+
+	_, err = decodeProvisionData("not base64")
+	assert.Error(t, err)
+}
@@ -76,6 +76,16 @@ type DomainJoinPluginInput struct {
 	DirectoryName  string
 	DirectoryOU    string
 	DnsIpAddresses []string
+	// Action is join, unjoin or rejoin, join is the default
+	Action string
+	// OfflineJoinBlob references a SecureString parameter holding the provisioning data created with djoin /provision
+	OfflineJoinBlob string
+	// ComputerName is the name template applied when joining with credentials
+	ComputerName string
+	// DomainUsername and DomainPassword are the credentials used to join with the NetJoin functions,
+	// the password must reference a SecureString parameter or a Secrets Manager secret
+	DomainUsername string
+	DomainPassword string
 }
 
 // NewPlugin returns a new instance of the plugin.
@@ -145,6 +155,15 @@ func (p *Plugin) runCommands(log log.T, pluginID string, pluginInput DomainJoinP
 		return
 	}
 
+	if err = validateNetJoinInput(pluginInput); err != nil {
+		out.MarkAsFailed(fmt.Errorf("Invalid domain join input: %v", err))
+		return
+	}
+	if usesNetJoin(pluginInput) {
+		runNetJoin(log, pluginInput, out)
+		return
+	}
+
 	// Construct Command line with executable file name and parameters
 	var command string
 	if command, err = makeArgs(log, pluginInput); err != nil {
@@ -18,11 +18,10 @@ package domainjoin
 
 import (
 	"errors"
+	"io"
 	"testing"
 
-	"github.com/aws/amazon-ssm-agent/agent/contracts"
 	"github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/iohandler"
-	iohandlermocks "github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/iohandler/mock"
 	"github.com/aws/amazon-ssm-agent/agent/jsonutil"
 	"github.com/aws/amazon-ssm-agent/agent/log"
 	"github.com/aws/amazon-ssm-agent/agent/task"
@@ -107,26 +106,25 @@ func testRunCommands(t *testing.T, testCase TestCase, rawInput bool) {
 	logger.Infof("test run commands %v", testCase)
 
 	if testCase.mark {
-		utilExe = func(log log.T, cmd string, workingDir string, outputRoot string, stdOut string, stdErr string, isAsync bool) (err error) {
-			return nil
+		utilExe = func(log log.T, cmd string, parameters []string, workingDir string, outputRoot string, stdOut io.Writer, stdErr io.Writer, isAsync bool) (string, error) {
+			return "", nil
 		}
 	} else {
 		errCase := errors.New("err here")
-		utilExe = func(log log.T, cmd string, workingDir string, outputRoot string, stdOut string, stdErr string, isAsync bool) (err error) {
-			return errCase
+		utilExe = func(log log.T, cmd string, parameters []string, workingDir string, outputRoot string, stdOut io.Writer, stdErr io.Writer, isAsync bool) (string, error) {
+			return "", errCase
 		}
 	}
 
 	makeDir = func(destinationDir string) (err error) {
 		return nil
 	}
-	makeArgs = func(log log.T, pluginInput DomainJoinPluginInput) (commandArguments string) {
-		return "cmd"
+	makeArgs = func(log log.T, pluginInput DomainJoinPluginInput) (commandArguments string, err error) {
+		return "cmd", nil
 	}
 
-	var res contracts.PluginOutput
 	mockCancelFlag := new(task.MockCancelFlag)
-	mockIOHandler := new(iohandlermocks.MockIOHandler)
+	output := iohandler.DefaultIOHandler{}
 	p := new(Plugin)
 
 	if rawInput {
@@ -135,10 +133,12 @@ func testRunCommands(t *testing.T, testCase TestCase, rawInput bool) {
 		err := jsonutil.Remarshal(testCase.Input, &rawPluginInput)
 		assert.Nil(t, err)
 
-		p.runCommandsRawInput(logger, rawPluginInput, orchestrationDirectory, mockCancelFlag, mockIOHandler, utilExe)
+		p.runCommandsRawInput(logger, "pluginID", rawPluginInput, orchestrationDirectory, mockCancelFlag, &output, utilExe)
 	} else {
-		p.runCommands(logger, testCase.Input, orchestrationDirectory, mockCancelFlag, mockIOHandler, utilExe)
+		p.runCommands(logger, "pluginID", testCase.Input, orchestrationDirectory, mockCancelFlag, &output, utilExe)
 	}
+	assert.Equal(t, testCase.Output.ExitCode, output.GetExitCode())
+	assert.Equal(t, testCase.Output.Status, output.GetStatus())
 }
 
 // TestMakeArguments tests the makeArguments methods, which build up the command for domainJoin.exe
@@ -150,7 +150,7 @@ func TestMakeArguments(t *testing.T) {
 
 	domainJoinInput := generateDomainJoinPluginInput(testDirectoryId, testDirectoryName, []string{"172.31.4.141", "172.31.21.240"})
 	commandRes, _ := makeArguments(logger, domainJoinInput)
-	expected := "./Ec2Config.DomainJoin.exe --directory-id d-0123456789 --directory-name corp.test.com --instance-region us-east-1 --dns-addresses 172.31.4.141 172.31.21.240"
+	expected := "./AWS.DomainJoin.exe --directory-id d-0123456789 --directory-name corp.test.com --instance-region us-east-1 --dns-addresses 172.31.4.141 172.31.21.240"
 
 	assert.Equal(t, expected, commandRes)
 }
@@ -23,9 +23,11 @@ import (
 	"strings"
 
 	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/attestation"
 	"github.com/aws/amazon-ssm-agent/agent/context"
 	"github.com/aws/amazon-ssm-agent/agent/contracts"
 	"github.com/aws/amazon-ssm-agent/agent/fileutil"
+	"github.com/aws/amazon-ssm-agent/agent/fileutil/artifactcache"
 	"github.com/aws/amazon-ssm-agent/agent/fileutil/filemanager"
 	"github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/iohandler"
 	"github.com/aws/amazon-ssm-agent/agent/jsonutil"
@@ -34,6 +36,7 @@ import (
 	"github.com/aws/amazon-ssm-agent/agent/plugins/downloadcontent/gitresource/github/privategithub"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/downloadcontent/gitresource/privategit"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/downloadcontent/httpresource"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/downloadcontent/registryresource"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/downloadcontent/remoteresource"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/downloadcontent/s3resource"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/downloadcontent/ssmdocresource"
@@ -47,6 +50,7 @@ const (
 	GitHub      = "GitHub"      //Github represents the source type "GitHub" from where the resource can be downloaded
 	S3          = "S3"          //S3 represents the source type "S3" from where the resource is being downloaded
 	SSMDocument = "SSMDocument" //SSMDocument represents the source type as SSM Document
+	Registry    = "Registry"    //Registry represents an artifact pulled from an OCI registry such as Amazon ECR
 
 	downloadsDir = "downloads" //Directory under the orchestration directory where the downloaded resource resides
 
@@ -60,10 +64,13 @@ var sourceTypes = map[string]bool{
 	GitHub:      true,
 	S3:          true,
 	SSMDocument: true,
+	Registry:    true,
 }
 
 var SetPermission = SetFilePermissions
 
+var newArtifactCache = artifactcache.New
+
 // NewPlugin returns a new instance of the plugin.
 func NewPlugin() (*Plugin, error) {
 	var plugin Plugin
@@ -83,6 +90,8 @@ type DownloadContentPlugin struct {
 	SourceType      string `json:"sourceType"`
 	SourceInfo      string `json:"sourceInfo"`
 	DestinationPath string `json:"destinationPath"`
+	// DisableArtifactCache opts the document out of reusing content from the artifact cache shared by documents
+	DisableArtifactCache bool `json:"disableArtifactCache"`
 	// TODO: 08/25/2017 meloniam@ Change the type of SourceInfo and documentParameters to map[string]interface{}
 	// TODO: https://amazon.awsapps.com/workdocs/index.html#/document/7d56a42ea5b040a7c33548d77dc98040f0fb380bbbfb2fd580c861225e2ee1c7
 }
@@ -105,6 +114,9 @@ func newRemoteResource(log log.T, SourceType string, SourceInfo string) (resourc
 	case Git:
 		ssmParameterResolverBridge := ssmparameterresolver.NewSsmParameterResolverBridge(ssmparameterresolver.NewService())
 		return privategit.NewGitResource(log, SourceInfo, ssmParameterResolverBridge)
+	case Registry:
+		ssmParameterResolverBridge := ssmparameterresolver.NewSsmParameterResolverBridge(ssmparameterresolver.NewService())
+		return registryresource.NewRegistryResource(log, SourceInfo, ssmParameterResolverBridge)
 	default:
 		return nil, fmt.Errorf("Invalid SourceType - %v", SourceType)
 	}
@@ -145,6 +157,14 @@ func (p *Plugin) runCopyContent(log log.T, input *DownloadContentPlugin, config
 		output.MarkAsFailed(err)
 		return
 	}
+	if cacheable, ok := remoteResource.(remoteresource.CacheableResource); ok {
+		if input.DisableArtifactCache {
+			cacheable.SetArtifactCache(artifactcache.Disabled)
+		} else {
+			cacheable.SetArtifactCache(newArtifactCache())
+		}
+	}
+
 	var destinationPath string
 
 	// If path is absolute, then download to the path,
@@ -176,6 +196,11 @@ func (p *Plugin) runCopyContent(log log.T, input *DownloadContentPlugin, config
 		output.MarkAsFailed(fmt.Errorf("Failed to set right permissions to the content. Error - %v", err))
 		return
 	}
+	attestation.RecordArtifacts(log,
+		strings.TrimSuffix(config.OrchestrationDirectory, config.PluginID),
+		config.PluginID,
+		fmt.Sprintf("%v:%v", input.SourceType, input.SourceInfo),
+		result.Files)
 
 	output.AppendInfof("Content downloaded to %v", destinationPath)
 	output.MarkAsSucceeded()
@@ -90,7 +90,9 @@ func (t TokenInfoImpl) GetOAuthClient(log log.T, tokenInfo string) (client *http
 	if tokenVal.Type != parameterstore.ParamTypeSecureString {
 		return nil, fmt.Errorf("token-parameter-name %v must be of secure string type, Current type - %v", tokenVal.Name, tokenVal.Type)
 	}
-	return t.gitoauthclient.GetGithubOauthClient(tokenVal.Value), nil
+	// the oauth client only accepts the token as a string
+	defer tokenVal.SecretValue.Release()
+	return t.gitoauthclient.GetGithubOauthClient(tokenVal.StringValue()), nil
 }
 
 func getSSMParameter(log log.T, paramService ssmparameterresolver.ISsmParameterService, parameterReferences []string,
@@ -0,0 +1,73 @@
+/*
+ * Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"). You may not
+ * use this file except in compliance with the License. A copy of the
+ * License is located at
+ *
+ * http://aws.amazon.com/apache2.0/
+ *
+ * or in the "license" file accompanying this file. This file is distributed
+ * on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied. See the License for the specific language governing
+ * permissions and limitations under the License.
+ */
+package handler
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/fileutil"
+	"github.com/aws/amazon-ssm-agent/agent/fileutil/artifact"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+)
+
+// etagCacheDir holds one entry for each url and download path downloaded with the ETag cache enabled
+var etagCacheDir = filepath.Join(appconfig.DefaultDataStorePath, "downloadcontent", "etag")
+
+// etagCacheEntry describes the last download of a url to a download path
+type etagCacheEntry struct {
+	ETag         string `json:"etag"`
+	LastModified string `json:"lastModified,omitempty"`
+	SHA256       string `json:"sha256"`
+}
+
+// etagCacheKey returns the name of the cache entry for a url and download path
+func etagCacheKey(url, downloadPath string) string {
+	hash := sha256.Sum256([]byte(url + "\n" + downloadPath))
+	return hex.EncodeToString(hash[:])
+}
+
+// loadETagCacheEntry returns the cache entry of the last download, which is only used
+// while the downloaded file still exists and has not been changed since
+func loadETagCacheEntry(log log.T, key, downloadPath string) (entry etagCacheEntry, ok bool) {
+	content, err := ioutil.ReadFile(filepath.Join(etagCacheDir, key))
+	if err != nil {
+		return entry, false
+	}
+	if err = json.Unmarshal(content, &entry); err != nil || entry.ETag == "" {
+		return entry, false
+	}
+	checksum, err := artifact.Sha256HashValue(log, downloadPath)
+	if err != nil || checksum == "" || checksum != entry.SHA256 {
+		return entry, false
+	}
+	return entry, true
+}
+
+// storeETagCacheEntry saves the cache entry of a completed download
+func storeETagCacheEntry(key string, entry etagCacheEntry) error {
+	content, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	if err = fileutil.MakeDirs(etagCacheDir); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(etagCacheDir, key), content, appconfig.ReadWriteAccess)
+}
@@ -17,11 +17,14 @@
 package handler
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
+	"regexp"
 	"strings"
 
 	"github.com/aws/amazon-ssm-agent/agent/fileutil/filemanager"
@@ -34,20 +37,43 @@ var ioCopy = io.Copy
 
 // Allowed auth method types
 const (
-	NONE  = "None"
-	BASIC = "Basic"
+	NONE   = "None"
+	BASIC  = "Basic"
+	BEARER = "Bearer"
 )
 
 var authMethods = map[types.TrimmedString]bool{
-	NONE:  true,
-	BASIC: true,
+	NONE:   true,
+	BASIC:  true,
+	BEARER: true,
 }
 
+// defaultMaxRedirects matches the number of redirects followed by the http client
+const defaultMaxRedirects = 10
+
+var (
+	// parameterReferencePattern finds the parameter store references in header values
+	parameterReferencePattern = regexp.MustCompile(`{{\s*(?:ssm|ssm-secure):[\w-./]+\s*}}`)
+	headerNamePattern         = regexp.MustCompile("^[A-Za-z0-9!#$%&'*+.^_`|~-]+$")
+	sha256Pattern             = regexp.MustCompile("^[a-fA-F0-9]{64}$")
+)
+
 // HTTPAuthConfig defines the attributes used to perform authentication over HTTP
 type HTTPAuthConfig struct {
 	AuthMethod types.TrimmedString
 	Username   types.TrimmedString
 	Password   types.TrimmedString
+	Token      types.TrimmedString
+}
+
+// HTTPOptions defines the request headers, redirect limit, checksum and caching of a download.
+// Header values can contain parameter store references, a nil MaxRedirects follows up to 10 redirects
+// and UseETagCache skips downloads the server reports as unchanged since the last download to the same path.
+type HTTPOptions struct {
+	Headers      map[string]string
+	MaxRedirects *int
+	SHA256       types.TrimmedString
+	UseETagCache bool
 }
 
 // IHTTPHandler defines methods to interact with HTTP resources
@@ -62,6 +88,7 @@ type httpHandler struct {
 	url                        url.URL
 	allowInsecureDownload      bool
 	authConfig                 HTTPAuthConfig
+	options                    HTTPOptions
 	ssmParameterResolverBridge ssmparameterresolver.ISsmParameterResolverBridge
 }
 
@@ -71,6 +98,7 @@ func NewHTTPHandler(
 	url url.URL,
 	allowInsecureDownload bool,
 	authConfig HTTPAuthConfig,
+	options HTTPOptions,
 	bridge ssmparameterresolver.ISsmParameterResolverBridge,
 ) IHTTPHandler {
 	return &httpHandler{
@@ -78,6 +106,7 @@ func NewHTTPHandler(
 		url:                        url,
 		allowInsecureDownload:      allowInsecureDownload,
 		authConfig:                 authConfig,
+		options:                    options,
 		ssmParameterResolverBridge: bridge,
 	}
 }
@@ -95,21 +124,55 @@ func (handler *httpHandler) Download(log log.T, fileSystem filemanager.FileSyste
 		return "", fmt.Errorf("Failed to prepare the request: %s", err.Error())
 	}
 
+	var cacheKey string
+	if handler.options.UseETagCache {
+		cacheKey = etagCacheKey(handler.url.String(), downloadPath)
+		if entry, ok := loadETagCacheEntry(log, cacheKey, downloadPath); ok {
+			request.Header.Set("If-None-Match", entry.ETag)
+			if entry.LastModified != "" {
+				request.Header.Set("If-Modified-Since", entry.LastModified)
+			}
+		}
+	}
+
+	response, err := handler.requestContent(request)
+	if err != nil {
+		return "", fmt.Errorf("Failed to download file: %s", err.Error())
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode == http.StatusNotModified {
+		log.Infof("%s has not changed since it was last downloaded", downloadPath)
+		return downloadPath, nil
+	}
+
 	out, err := fileSystem.CreateFile(downloadPath)
 	if err != nil {
 		return "", fmt.Errorf("Cannot create destinaton file: %s", err.Error())
 	}
 	defer out.Close()
 
-	contentReader, err := handler.requestContent(request)
+	hash := sha256.New()
+	_, err = ioCopy(io.MultiWriter(out, hash), response.Body)
 	if err != nil {
-		return "", fmt.Errorf("Failed to download file: %s", err.Error())
+		return "", fmt.Errorf("An error occurred during data transfer: %s", err.Error())
 	}
-	defer contentReader.Close()
+	checksum := hex.EncodeToString(hash.Sum(nil))
 
-	_, err = ioCopy(out, contentReader)
-	if err != nil {
-		return "", fmt.Errorf("An error occurred during data transfer: %s", err.Error())
+	if expected := handler.options.SHA256.Val(); expected != "" && !strings.EqualFold(expected, checksum) {
+		out.Close()
+		fileSystem.DeleteFile(downloadPath)
+		return "", fmt.Errorf("Checksum %s of the downloaded file does not match the expected checksum %s", checksum, expected)
+	}
+
+	if etag := response.Header.Get("ETag"); cacheKey != "" && etag != "" {
+		if err = storeETagCacheEntry(cacheKey, etagCacheEntry{
+			ETag:         etag,
+			LastModified: response.Header.Get("Last-Modified"),
+			SHA256:       checksum,
+		}); err != nil {
+			log.Warnf("Failed to cache the ETag of %s: %v", downloadPath, err)
+		}
 	}
 
 	return downloadPath, nil
@@ -123,7 +186,21 @@ func (handler *httpHandler) Validate() (bool, error) {
 
 	if handler.authConfig.AuthMethod != "" && !authMethods[handler.authConfig.AuthMethod] {
 		return false, fmt.Errorf("Invalid authentication method: %s. "+
-			"The following methods are accepted: None, Basic", handler.authConfig.AuthMethod)
+			"The following methods are accepted: None, Basic, Bearer", handler.authConfig.AuthMethod)
+	}
+
+	for name := range handler.options.Headers {
+		if !headerNamePattern.MatchString(name) {
+			return false, fmt.Errorf("Invalid header name: %s", name)
+		}
+	}
+
+	if handler.options.MaxRedirects != nil && *handler.options.MaxRedirects < 0 {
+		return false, errors.New("maxRedirects can not be negative")
+	}
+
+	if sha256 := handler.options.SHA256.Val(); sha256 != "" && !sha256Pattern.MatchString(sha256) {
+		return false, fmt.Errorf("Invalid SHA256 checksum: %s", sha256)
 	}
 
 	return true, nil
@@ -151,6 +228,17 @@ func (handler *httpHandler) authRequest(log log.T, req *http.Request) (err error
 
 		req.SetBasicAuth(username, password)
 		break
+	case BEARER:
+		var token = handler.authConfig.Token.Val()
+		if handler.ssmParameterResolverBridge.IsValidParameterStoreReference(token) {
+			token, err = handler.ssmParameterResolverBridge.GetParameterFromSsmParameterStore(log, token)
+			if err != nil {
+				return err
+			}
+		}
+
+		req.Header.Set("Authorization", "Bearer "+token)
+		break
 	default:
 		break
 	}
@@ -170,6 +258,13 @@ func (handler *httpHandler) prepareRequest(log log.T) (request *http.Request, er
 		return nil, err
 	}
 
+	for name, value := range handler.options.Headers {
+		if value, err = handler.resolveHeaderValue(log, value); err != nil {
+			return nil, err
+		}
+		request.Header.Set(name, value)
+	}
+
 	err = handler.authRequest(log, request)
 	if err != nil {
 		return nil, err
@@ -178,16 +273,47 @@ func (handler *httpHandler) prepareRequest(log log.T) (request *http.Request, er
 	return request, nil
 }
 
-// requestContent executes the given request and returns the response body
-func (handler *httpHandler) requestContent(request *http.Request) (io.ReadCloser, error) {
-	response, err := handler.client.Do(request)
+// resolveHeaderValue replaces the parameter store references in a header value with their values
+func (handler *httpHandler) resolveHeaderValue(log log.T, value string) (resolved string, err error) {
+	resolved = parameterReferencePattern.ReplaceAllStringFunc(value, func(reference string) string {
+		if err != nil {
+			return reference
+		}
+		var parameter string
+		parameter, err = handler.ssmParameterResolverBridge.GetParameterFromSsmParameterStore(log, reference)
+		return parameter
+	})
+	return resolved, err
+}
+
+// requestContent executes the given request, following redirects up to the redirect limit, and returns the response.
+// Redirects from https to http are only followed when insecure downloads are allowed.
+func (handler *httpHandler) requestContent(request *http.Request) (*http.Response, error) {
+	maxRedirects := defaultMaxRedirects
+	if handler.options.MaxRedirects != nil {
+		maxRedirects = *handler.options.MaxRedirects
+	}
+
+	client := handler.client
+	client.CheckRedirect = func(redirect *http.Request, via []*http.Request) error {
+		if len(via) > maxRedirects {
+			return fmt.Errorf("stopped after %d redirects", maxRedirects)
+		}
+		if strings.ToUpper(redirect.URL.Scheme) != "HTTPS" && !handler.allowInsecureDownload {
+			return errors.New("redirect to a non secure URL is not allowed")
+		}
+		return nil
+	}
+
+	response, err := client.Do(request)
 	if err != nil {
 		return nil, fmt.Errorf("Cannot execute request: %s", err.Error())
 	}
 
-	if response.StatusCode != http.StatusOK {
+	if response.StatusCode != http.StatusOK && response.StatusCode != http.StatusNotModified {
+		response.Body.Close()
 		return nil, fmt.Errorf("Status: %s", response.Status)
 	}
 
-	return response.Body, nil
+	return response, nil
 }
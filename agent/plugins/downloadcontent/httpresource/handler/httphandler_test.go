@@ -19,6 +19,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
@@ -26,6 +27,8 @@ import (
 	"path/filepath"
 	"testing"
 
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/fileutil/filemanager"
 	filemock "github.com/aws/amazon-ssm-agent/agent/fileutil/filemanager/mock"
 	"github.com/aws/amazon-ssm-agent/agent/log"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/downloadcontent/types"
@@ -65,6 +68,12 @@ func getHttpHandler(httpClient http.Client, url url.URL, allowInsecureDownload b
 	}
 }
 
+func getHttpHandlerWithOptions(options HTTPOptions) httpHandler {
+	handler := getHttpHandler(http.Client{}, getExampleURL("https", ""), false, "", "", "")
+	handler.options = options
+	return handler
+}
+
 func getString(obj interface{}) string {
 	return fmt.Sprintf("%v", obj)
 }
@@ -72,8 +81,15 @@ func getString(obj interface{}) string {
 var parameterStoreParameters = map[string]string{
 	"{{ssm-secure:username}}": "admin",
 	"{{ssm-secure:password}}": "pwd",
+	"{{ssm-secure:token}}":    "secret-token",
+	"{{ssm:tenant}}":          "tenant-1",
 }
 
+var negativeRedirects = -1
+
+// emptySHA256 is the checksum of an empty file
+const emptySHA256 = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
 func getParameterFromSsmParameterStoreStub(log log.T, reference string) (string, error) {
 	if value, exists := parameterStoreParameters[reference]; exists {
 		return value, nil
@@ -104,6 +120,7 @@ func TestNewHTTPHandler(t *testing.T) {
 		getExampleURL("http", ""),
 		false,
 		authConfig,
+		HTTPOptions{},
 		bridge,
 	))
 }
@@ -142,7 +159,31 @@ func TestHttpHandlerImpl_Validate(t *testing.T) {
 		{
 			getHttpHandler(http.Client{}, getExampleURL("http", ""), true, "Digest ", "", ""),
 			false,
-			errors.New("Invalid authentication method: Digest. The following methods are accepted: None, Basic"),
+			errors.New("Invalid authentication method: Digest. The following methods are accepted: None, Basic, Bearer"),
+		},
+		{
+			getHttpHandlerWithOptions(HTTPOptions{Headers: map[string]string{"X Api": "1"}}),
+			false,
+			errors.New("Invalid header name: X Api"),
+		},
+		{
+			getHttpHandlerWithOptions(HTTPOptions{MaxRedirects: &negativeRedirects}),
+			false,
+			errors.New("maxRedirects can not be negative"),
+		},
+		{
+			getHttpHandlerWithOptions(HTTPOptions{SHA256: types.NewTrimmedString("abc")}),
+			false,
+			errors.New("Invalid SHA256 checksum: abc"),
+		},
+		{
+			getHttpHandlerWithOptions(HTTPOptions{
+				Headers:      map[string]string{"X-Api-Key": "{{ssm-secure:key}}"},
+				MaxRedirects: new(int),
+				SHA256:       types.NewTrimmedString(emptySHA256),
+			}),
+			true,
+			nil,
 		},
 	}
 
@@ -338,15 +379,15 @@ func TestHttpHandlerImpl_requestContent(t *testing.T) {
 		handler.url.Path = test.urlPath
 		test.request.RequestURI = ""
 
-		responseBody, err := handler.requestContent(test.request)
+		response, err := handler.requestContent(test.request)
 
 		if test.err != nil {
 			assert.Error(t, err, getString(test))
 			assert.EqualError(t, err, test.err.Error(), getString(test))
-			assert.Nil(t, responseBody)
+			assert.Nil(t, response)
 		} else {
 			assert.NoError(t, err, getString(test))
-			assert.NotNil(t, responseBody)
+			assert.NotNil(t, response)
 		}
 	}
 
@@ -425,3 +466,196 @@ func TestHttpHandlerImpl_Download(t *testing.T) {
 	ioCopy = io.Copy
 	fileSystemMock.AssertExpectations(t)
 }
+
+func TestHttpHandlerImpl_authRequestBearer(t *testing.T) {
+	tests := []struct {
+		token         string
+		authorization string
+		err           error
+	}{
+		{
+			"plain-token",
+			"Bearer plain-token",
+			nil,
+		},
+		{
+			"{{ssm-secure:token}}",
+			"Bearer secret-token",
+			nil,
+		},
+		{
+			"{{ssm-secure:invalid-param}}",
+			"",
+			errors.New("parameter does not exist"),
+		},
+	}
+
+	for _, test := range tests {
+		handler := getHttpHandler(http.Client{}, getExampleURL("https", ""), false, "Bearer", "", "")
+		handler.authConfig.Token = types.NewTrimmedString(test.token)
+		request := httptest.NewRequest(http.MethodGet, handler.url.String(), nil)
+
+		err := handler.authRequest(logMock, request)
+
+		if test.err != nil {
+			assert.EqualError(t, err, test.err.Error(), getString(test))
+		} else {
+			assert.NoError(t, err, getString(test))
+			assert.Equal(t, test.authorization, request.Header.Get("Authorization"), getString(test))
+		}
+	}
+}
+
+func TestHttpHandlerImpl_prepareRequestHeaders(t *testing.T) {
+	handler := getHttpHandlerWithOptions(HTTPOptions{Headers: map[string]string{
+		"X-Tenant":  "{{ssm:tenant}}",
+		"X-Api-Key": "key {{ ssm-secure:token }} for {{ssm:tenant}}",
+		"Accept":    "application/octet-stream",
+	}})
+	handler.ssmParameterResolverBridge = bridgemock.GetSsmParamResolverBridge(map[string]string{
+		"{{ssm:tenant}}":         "tenant-1",
+		"{{ ssm-secure:token }}": "secret-token",
+	})
+
+	request, err := handler.prepareRequest(logMock)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "tenant-1", request.Header.Get("X-Tenant"))
+	assert.Equal(t, "key secret-token for tenant-1", request.Header.Get("X-Api-Key"))
+	assert.Equal(t, "application/octet-stream", request.Header.Get("Accept"))
+
+	handler.options.Headers = map[string]string{"X-Api-Key": "{{ssm-secure:invalid-param}}"}
+	request, err = handler.prepareRequest(logMock)
+
+	assert.EqualError(t, err, "parameter does not exist")
+	assert.Nil(t, request)
+}
+
+func TestHttpHandlerImpl_requestContentRedirects(t *testing.T) {
+	var testServer *httptest.Server
+	testServer = httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/twice":
+			http.Redirect(res, req, testServer.URL+"/once", http.StatusFound)
+		case "/once":
+			http.Redirect(res, req, testServer.URL+"/file", http.StatusFound)
+		default:
+			res.WriteHeader(http.StatusOK)
+		}
+	}))
+	defer testServer.Close()
+
+	one := 1
+	tests := []struct {
+		path         string
+		maxRedirects *int
+		success      bool
+	}{
+		{"/twice", nil, true},
+		{"/once", &one, true},
+		{"/twice", &one, false},
+		{"/once", new(int), false},
+	}
+
+	for _, test := range tests {
+		testURL, _ := url.Parse(testServer.URL + test.path)
+		handler := getHttpHandler(*testServer.Client(), *testURL, true, "", "", "")
+		handler.options.MaxRedirects = test.maxRedirects
+		request, _ := handler.prepareRequest(logMock)
+
+		response, err := handler.requestContent(request)
+
+		if test.success {
+			assert.NoError(t, err, getString(test))
+			assert.Equal(t, http.StatusOK, response.StatusCode, getString(test))
+		} else {
+			assert.Error(t, err, getString(test))
+		}
+	}
+}
+
+func TestHttpHandlerImpl_requestContentRedirectToInsecureURL(t *testing.T) {
+	insecureServer := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		res.WriteHeader(http.StatusOK)
+	}))
+	defer insecureServer.Close()
+	secureServer := httptest.NewTLSServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		http.Redirect(res, req, insecureServer.URL, http.StatusFound)
+	}))
+	defer secureServer.Close()
+
+	testURL, _ := url.Parse(secureServer.URL)
+	for _, allowInsecureDownload := range []bool{false, true} {
+		handler := getHttpHandler(*secureServer.Client(), *testURL, allowInsecureDownload, "", "", "")
+		request, _ := handler.prepareRequest(logMock)
+
+		_, err := handler.requestContent(request)
+
+		if allowInsecureDownload {
+			assert.NoError(t, err)
+		} else {
+			assert.Error(t, err)
+			assert.Contains(t, err.Error(), "redirect to a non secure URL is not allowed")
+		}
+	}
+}
+
+func TestHttpHandlerImpl_DownloadChecksum(t *testing.T) {
+	testServer := httptest.NewTLSServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		res.Write([]byte("content"))
+	}))
+	defer testServer.Close()
+	testURL, _ := url.Parse(testServer.URL)
+
+	downloadPath := filepath.Join(t.TempDir(), "testFile")
+	file, _ := os.Create(downloadPath)
+	fileSystemMock := filemock.FileSystemMock{}
+	fileSystemMock.On("CreateFile", downloadPath).Return(file, nil)
+	fileSystemMock.On("DeleteFile", downloadPath).Return(nil)
+
+	handler := getHttpHandler(*testServer.Client(), *testURL, false, "", "", "")
+	handler.options.SHA256 = types.NewTrimmedString(emptySHA256)
+
+	_, err := handler.Download(logMock, fileSystemMock, downloadPath)
+
+	assert.EqualError(t, err, "Checksum ed7002b439e9ac845f22357d822bac1444730fbdb6016d3ec9432297b9ec9f73 "+
+		"of the downloaded file does not match the expected checksum "+emptySHA256)
+	fileSystemMock.AssertExpectations(t)
+}
+
+func TestHttpHandlerImpl_DownloadETagCache(t *testing.T) {
+	etagCacheDir = t.TempDir()
+	defer func() { etagCacheDir = filepath.Join(appconfig.DefaultDataStorePath, "downloadcontent", "etag") }()
+
+	requests := 0
+	testServer := httptest.NewTLSServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		requests++
+		if req.Header.Get("If-None-Match") == `"v1"` {
+			res.WriteHeader(http.StatusNotModified)
+			return
+		}
+		res.Header().Set("ETag", `"v1"`)
+		res.Write([]byte("content"))
+	}))
+	defer testServer.Close()
+	testURL, _ := url.Parse(testServer.URL)
+
+	downloadPath := filepath.Join(t.TempDir(), "testFile")
+	handler := getHttpHandler(*testServer.Client(), *testURL, false, "", "", "")
+	handler.options.UseETagCache = true
+
+	for i := 0; i < 2; i++ {
+		downloadedFile, err := handler.Download(logMock, filemanager.FileSystemImpl{}, downloadPath)
+		assert.NoError(t, err)
+		assert.Equal(t, downloadPath, downloadedFile)
+	}
+	assert.Equal(t, 2, requests)
+	logMock.AssertCalled(t, "Infof", "%s has not changed since it was last downloaded", []interface{}{downloadPath})
+
+	// a changed file is downloaded again
+	ioutil.WriteFile(downloadPath, []byte("changed"), appconfig.ReadWriteAccess)
+	_, err := handler.Download(logMock, filemanager.FileSystemImpl{}, downloadPath)
+	assert.NoError(t, err)
+	content, _ := ioutil.ReadFile(downloadPath)
+	assert.Equal(t, "content", string(content))
+}
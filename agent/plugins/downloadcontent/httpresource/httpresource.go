@@ -17,6 +17,8 @@
 package httpresource
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"math/rand"
 	"net/http"
@@ -25,6 +27,7 @@ import (
 	"path/filepath"
 
 	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/fileutil/artifactcache"
 	"github.com/aws/amazon-ssm-agent/agent/fileutil/filemanager"
 	"github.com/aws/amazon-ssm-agent/agent/jsonutil"
 	"github.com/aws/amazon-ssm-agent/agent/log"
@@ -37,6 +40,14 @@ import (
 // HTTPResource represents an HTTP(s) resource
 type HTTPResource struct {
 	Handler handler.IHTTPHandler
+
+	// url and useETagCache name the downloaded file after the url, so unchanged files are not downloaded again
+	url          string
+	useETagCache bool
+
+	// sha256 is the expected checksum of the file, it allows reusing the file from the artifact cache
+	sha256        string
+	artifactCache artifactcache.Cache
 }
 
 // HTTPInfo defines the accepted SourceInfo attributes and their json definition
@@ -45,7 +56,12 @@ type HTTPInfo struct {
 	AuthMethod            types.TrimmedString `json:"authMethod"`
 	Username              types.TrimmedString `json:"username"`
 	Password              types.TrimmedString `json:"password"`
+	Token                 types.TrimmedString `json:"token"`
 	AllowInsecureDownload bool                `json:"allowInsecureDownload"`
+	Headers               map[string]string   `json:"headers"`
+	MaxRedirects          *int                `json:"maxRedirects"`
+	SHA256                types.TrimmedString `json:"sha256"`
+	UseETagCache          bool                `json:"useETagCache"`
 }
 
 // NewHTTPResource creates a new HTTP resource
@@ -68,13 +84,27 @@ func NewHTTPResource(log log.T, info string, bridge ssmparameterresolver.ISsmPar
 			AuthMethod: httpInfo.AuthMethod,
 			Username:   httpInfo.Username,
 			Password:   httpInfo.Password,
+			Token:      httpInfo.Token,
+		}, handler.HTTPOptions{
+			Headers:      httpInfo.Headers,
+			MaxRedirects: httpInfo.MaxRedirects,
+			SHA256:       httpInfo.SHA256,
+			UseETagCache: httpInfo.UseETagCache,
 		}, bridge),
+		url:          httpInfo.URL.Val(),
+		useETagCache: httpInfo.UseETagCache,
+		sha256:       httpInfo.SHA256.Val(),
 	}, nil
 }
 
 // DownloadRemoteResource downloads a HTTP resource into a specific download path
 func (resource *HTTPResource) DownloadRemoteResource(log log.T, fileSystem filemanager.FileSystem, downloadPath string) (err error, result *remoteresource.DownloadResult) {
-	downloadPath = resource.adjustDownloadPath(downloadPath, fmt.Sprintf("%d", rand.Int()), fileSystem)
+	fileSuffix := fmt.Sprintf("%d", rand.Int())
+	if resource.useETagCache {
+		urlHash := sha256.Sum256([]byte(resource.url))
+		fileSuffix = hex.EncodeToString(urlHash[:8])
+	}
+	downloadPath = resource.adjustDownloadPath(downloadPath, fileSuffix, fileSystem)
 
 	err = fileSystem.MakeDirs(filepath.Dir(downloadPath))
 	if err != nil {
@@ -83,16 +113,34 @@ func (resource *HTTPResource) DownloadRemoteResource(log log.T, fileSystem filem
 
 	log.Debug("Destination path to download - ", downloadPath)
 
+	if resource.artifactCache != nil && resource.sha256 != "" && resource.artifactCache.Get(log, resource.sha256, downloadPath) {
+		return nil, &remoteresource.DownloadResult{
+			Files: []string{downloadPath},
+		}
+	}
+
 	downloadedFilepath, err := resource.Handler.Download(log, fileSystem, downloadPath)
 	if err != nil {
 		return err, nil
 	}
 
+	// the handler verified the checksum of the downloaded file
+	if resource.artifactCache != nil && resource.sha256 != "" {
+		if err = resource.artifactCache.Put(log, resource.sha256, downloadedFilepath); err != nil {
+			log.Warnf("Failed to add %s to the artifact cache: %v", downloadedFilepath, err)
+		}
+	}
+
 	return nil, &remoteresource.DownloadResult{
 		Files: []string{downloadedFilepath},
 	}
 }
 
+// SetArtifactCache sets the cache used to reuse files with a known checksum
+func (resource *HTTPResource) SetArtifactCache(cache artifactcache.Cache) {
+	resource.artifactCache = cache
+}
+
 // ValidateLocationInfo validates attribute values of an HTTP resource
 func (resource *HTTPResource) ValidateLocationInfo() (isValid bool, err error) {
 	return resource.Handler.Validate()
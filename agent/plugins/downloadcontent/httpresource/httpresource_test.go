@@ -26,6 +26,7 @@ import (
 	"testing"
 
 	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	cachemock "github.com/aws/amazon-ssm-agent/agent/fileutil/artifactcache/mock"
 	filemock "github.com/aws/amazon-ssm-agent/agent/fileutil/filemanager/mock"
 	"github.com/aws/amazon-ssm-agent/agent/log"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/downloadcontent/httpresource/handler"
@@ -61,7 +62,8 @@ func getTestResource(url url.URL, authMethod, user, password string, allowInsecu
 			AuthMethod: types.NewTrimmedString(authMethod),
 			Username:   types.NewTrimmedString(user),
 			Password:   types.NewTrimmedString(password),
-		}, bridgemock.GetSsmParamResolverBridge(map[string]string{})),
+		}, handler.HTTPOptions{}, bridgemock.GetSsmParamResolverBridge(map[string]string{})),
+		url: url.String(),
 	}
 }
 
@@ -151,6 +153,27 @@ func TestHTTPResource_parseSourceInfo(t *testing.T) {
 			getHttpInfo("http://", "", "", "", false),
 			nil,
 		},
+		{
+			`{
+				"url": "https://",
+				"authMethod": "Bearer",
+				"token": " {{ssm-secure:token}} ",
+				"headers": {"X-Api-Version": "2"},
+				"maxRedirects": 0,
+				"sha256": " abc ",
+				"useETagCache": true
+			}`,
+			HTTPInfo{
+				URL:          types.NewTrimmedString("https://"),
+				AuthMethod:   types.NewTrimmedString("Bearer"),
+				Token:        types.NewTrimmedString("{{ssm-secure:token}}"),
+				Headers:      map[string]string{"X-Api-Version": "2"},
+				MaxRedirects: new(int),
+				SHA256:       types.NewTrimmedString("abc"),
+				UseETagCache: true,
+			},
+			nil,
+		},
 		{
 			`{
 				"url": "http://
@@ -266,3 +289,38 @@ func TestHTTPResource_DownloadRemoteResource(t *testing.T) {
 	fileSystemMock.AssertExpectations(t)
 	httpHandlerMock.AssertExpectations(t)
 }
+
+func TestHTTPResource_DownloadRemoteResourceArtifactCache(t *testing.T) {
+	destPath := filepath.Join(os.TempDir(), "testFile")
+	checksum := "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+	fileSystemMock := filemock.FileSystemMock{}
+	fileSystemMock.On("MakeDirs", filepath.Dir(destPath)).Return(nil)
+	fileSystemMock.On("Exists", destPath).Return(true)
+	fileSystemMock.On("IsDirectory", destPath).Return(false)
+
+	// cached files are not downloaded
+	cacheMock := cachemock.CacheMock{}
+	cacheMock.On("Get", logMock, checksum, destPath).Return(true).Once()
+	httpHandlerMock := httpMock.HTTPHandlerMock{}
+	resource := HTTPResource{Handler: &httpHandlerMock, sha256: checksum}
+	resource.SetArtifactCache(&cacheMock)
+
+	err, result := resource.DownloadRemoteResource(logMock, fileSystemMock, destPath)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{destPath}, result.Files)
+	httpHandlerMock.AssertNotCalled(t, "Download", mock.Anything, mock.Anything, mock.Anything)
+
+	// downloaded files are added to the cache
+	cacheMock.On("Get", logMock, checksum, destPath).Return(false).Once()
+	cacheMock.On("Put", logMock, checksum, destPath).Return(nil).Once()
+	httpHandlerMock.On("Download", logMock, fileSystemMock, destPath).Return(destPath, nil).Once()
+
+	err, result = resource.DownloadRemoteResource(logMock, fileSystemMock, destPath)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{destPath}, result.Files)
+	cacheMock.AssertExpectations(t)
+	httpHandlerMock.AssertExpectations(t)
+}
@@ -0,0 +1,341 @@
+/*
+ * Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"). You may not
+ * use this file except in compliance with the License. A copy of the
+ * License is located at
+ *
+ * http://aws.amazon.com/apache2.0/
+ *
+ * or in the "license" file accompanying this file. This file is distributed
+ * on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied. See the License for the specific language governing
+ * permissions and limitations under the License.
+ */
+
+package registryresource
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/sdkutil"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecr"
+)
+
+const (
+	ociManifestMediaType    = "application/vnd.oci.image.manifest.v1+json"
+	dockerManifestMediaType = "application/vnd.docker.distribution.manifest.v2+json"
+
+	// titleAnnotation names the file of a layer that is not a tar archive
+	titleAnnotation = "org.opencontainers.image.title"
+
+	contentDigestHeader = "Docker-Content-Digest"
+
+	// maxManifestSize bounds the manifest read into memory
+	maxManifestSize = 4 * 1024 * 1024
+)
+
+var (
+	// ecrRegistryPattern matches the host of an ECR registry and captures its account and region
+	ecrRegistryPattern = regexp.MustCompile(`^([0-9]{12})\.dkr\.ecr(?:-fips)?\.([a-z0-9-]+)\.amazonaws\.com(?:\.cn)?$`)
+	challengePattern   = regexp.MustCompile(`(\w+)="([^"]*)"`)
+	digestPattern      = regexp.MustCompile(`^sha256:[a-f0-9]{64}$`)
+)
+
+var getEcrAuthorizationToken = ecrAuthorizationToken
+
+// ecrAuthorizationToken returns the base64 encoded user:password token of an ECR registry
+func ecrAuthorizationToken(registryId string, region string) (string, error) {
+	config := sdkutil.AwsConfig()
+	config.Region = aws.String(region)
+	output, err := ecr.New(sdkutil.NewSession(config, "")).GetAuthorizationToken(&ecr.GetAuthorizationTokenInput{
+		RegistryIds: []*string{aws.String(registryId)},
+	})
+	if err != nil {
+		return "", err
+	}
+	if len(output.AuthorizationData) == 0 || output.AuthorizationData[0].AuthorizationToken == nil {
+		return "", errors.New("ECR returned no authorization token")
+	}
+	return *output.AuthorizationData[0].AuthorizationToken, nil
+}
+
+// reference identifies an artifact by tag or digest
+type reference struct {
+	registry   string
+	repository string
+	tag        string
+	digest     string
+}
+
+// String returns the reference in the registry/repository:tag or registry/repository@digest form
+func (ref reference) String() string {
+	if ref.digest != "" {
+		return fmt.Sprintf("%s/%s@%s", ref.registry, ref.repository, ref.digest)
+	}
+	return fmt.Sprintf("%s/%s:%s", ref.registry, ref.repository, ref.tag)
+}
+
+// parseReference parses registry/repository[:tag][@digest], the tag defaults to latest
+func parseReference(value string) (ref reference, err error) {
+	if at := strings.Index(value, "@"); at >= 0 {
+		ref.digest = value[at+1:]
+		if !digestPattern.MatchString(ref.digest) {
+			return ref, fmt.Errorf("Invalid digest %s, only sha256 digests are supported", ref.digest)
+		}
+		value = value[:at]
+	}
+
+	slash := strings.Index(value, "/")
+	if slash <= 0 || slash == len(value)-1 {
+		return ref, fmt.Errorf("Invalid reference %s, the registry and repository are required", value)
+	}
+	ref.registry, ref.repository = value[:slash], value[slash+1:]
+	if !strings.ContainsAny(ref.registry, ".:") && ref.registry != "localhost" {
+		return ref, fmt.Errorf("Invalid reference %s, the registry is required", value)
+	}
+
+	if colon := strings.LastIndex(ref.repository, ":"); colon >= 0 {
+		ref.repository, ref.tag = ref.repository[:colon], ref.repository[colon+1:]
+	}
+	if ref.tag == "" && ref.digest == "" {
+		ref.tag = "latest"
+	}
+	return ref, nil
+}
+
+func isEcrRegistry(registry string) bool {
+	return ecrRegistryPattern.MatchString(registry)
+}
+
+// descriptor describes the manifest layers
+type descriptor struct {
+	MediaType   string            `json:"mediaType"`
+	Digest      string            `json:"digest"`
+	Size        int64             `json:"size"`
+	Annotations map[string]string `json:"annotations"`
+}
+
+type manifest struct {
+	SchemaVersion int          `json:"schemaVersion"`
+	MediaType     string       `json:"mediaType"`
+	Layers        []descriptor `json:"layers"`
+}
+
+// registryClient sends requests to the distribution api of a registry
+type registryClient struct {
+	client    *http.Client
+	reference reference
+	username  string
+	password  string
+	token     string
+}
+
+// newRegistryClient returns a client with the credentials of the auth method
+func (resource *RegistryResource) newRegistryClient(log log.T) (registry *registryClient, err error) {
+	registry = &registryClient{client: resource.client, reference: resource.reference}
+
+	switch resource.info.AuthMethod {
+	case BASIC:
+		if registry.username, err = resource.resolveParameter(log, resource.info.Username.Val()); err != nil {
+			return nil, err
+		}
+		if registry.password, err = resource.resolveParameter(log, resource.info.Password.Val()); err != nil {
+			return nil, err
+		}
+	case ECR:
+		match := ecrRegistryPattern.FindStringSubmatch(resource.reference.registry)
+		if match == nil {
+			return nil, fmt.Errorf("Registry %s is not an Amazon ECR registry", resource.reference.registry)
+		}
+		token, err := getEcrAuthorizationToken(match[1], match[2])
+		if err != nil {
+			return nil, fmt.Errorf("Failed to get ECR authorization token: %v", err)
+		}
+		decoded, err := base64.StdEncoding.DecodeString(token)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid ECR authorization token: %v", err)
+		}
+		credentials := strings.SplitN(string(decoded), ":", 2)
+		if len(credentials) != 2 {
+			return nil, errors.New("Invalid ECR authorization token")
+		}
+		registry.username, registry.password = credentials[0], credentials[1]
+	}
+	return registry, nil
+}
+
+// resolveParameter returns the value of a Parameter Store reference, other values are returned as they are
+func (resource *RegistryResource) resolveParameter(log log.T, value string) (string, error) {
+	if resource.bridge.IsValidParameterStoreReference(value) {
+		return resource.bridge.GetParameterFromSsmParameterStore(log, value)
+	}
+	return value, nil
+}
+
+// get sends a GET request to the distribution api, authenticating with a bearer token when the registry asks for one
+func (registry *registryClient) get(log log.T, path string, accept ...string) (*http.Response, error) {
+	response, err := registry.send(path, accept)
+	if err != nil || response.StatusCode != http.StatusUnauthorized || registry.token != "" {
+		return response, err
+	}
+
+	challenge := response.Header.Get("WWW-Authenticate")
+	response.Body.Close()
+	if !strings.HasPrefix(strings.ToLower(challenge), "bearer ") {
+		return nil, fmt.Errorf("Registry %s rejected the credentials", registry.reference.registry)
+	}
+	log.Debugf("Requesting bearer token for %s", registry.reference.registry)
+	if registry.token, err = registry.requestToken(challenge); err != nil {
+		return nil, err
+	}
+	return registry.send(path, accept)
+}
+
+func (registry *registryClient) send(path string, accept []string) (*http.Response, error) {
+	request, err := http.NewRequest(http.MethodGet, "https://"+registry.reference.registry+"/v2/"+registry.reference.repository+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	for _, mediaType := range accept {
+		request.Header.Add("Accept", mediaType)
+	}
+	if registry.token != "" {
+		request.Header.Set("Authorization", "Bearer "+registry.token)
+	} else if registry.username != "" {
+		request.SetBasicAuth(registry.username, registry.password)
+	}
+	return registry.client.Do(request)
+}
+
+// requestToken gets a bearer token from the realm of a WWW-Authenticate challenge
+func (registry *registryClient) requestToken(challenge string) (string, error) {
+	params := map[string]string{}
+	for _, match := range challengePattern.FindAllStringSubmatch(challenge, -1) {
+		params[strings.ToLower(match[1])] = match[2]
+	}
+	realm, err := url.Parse(params["realm"])
+	if err != nil || realm.Scheme != "https" {
+		return "", fmt.Errorf("Invalid token realm %s", params["realm"])
+	}
+	query := realm.Query()
+	for _, key := range []string{"service", "scope"} {
+		if params[key] != "" {
+			query.Set(key, params[key])
+		}
+	}
+	realm.RawQuery = query.Encode()
+
+	request, err := http.NewRequest(http.MethodGet, realm.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	if registry.username != "" {
+		request.SetBasicAuth(registry.username, registry.password)
+	}
+	response, err := registry.client.Do(request)
+	if err != nil {
+		return "", err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("Token request to %s failed with status %s", realm.Host, response.Status)
+	}
+
+	var token struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err = json.NewDecoder(io.LimitReader(response.Body, maxManifestSize)).Decode(&token); err != nil {
+		return "", err
+	}
+	if token.Token != "" {
+		return token.Token, nil
+	}
+	return token.AccessToken, nil
+}
+
+// getManifest pulls the manifest and verifies it matches the digest of the reference, or the digest returned by the registry for tags
+func (registry *registryClient) getManifest(log log.T) (result manifest, digest string, err error) {
+	tagOrDigest := registry.reference.tag
+	if registry.reference.digest != "" {
+		tagOrDigest = registry.reference.digest
+	}
+	response, err := registry.get(log, "/manifests/"+tagOrDigest, ociManifestMediaType, dockerManifestMediaType)
+	if err != nil {
+		return result, "", err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return result, "", fmt.Errorf("Failed to pull manifest of %s: %s", registry.reference, response.Status)
+	}
+
+	content, err := ioutil.ReadAll(io.LimitReader(response.Body, maxManifestSize))
+	if err != nil {
+		return result, "", err
+	}
+	hash := sha256.Sum256(content)
+	digest = "sha256:" + hex.EncodeToString(hash[:])
+
+	expected := registry.reference.digest
+	if expected == "" {
+		expected = response.Header.Get(contentDigestHeader)
+	}
+	if expected != "" && expected != digest {
+		return result, "", fmt.Errorf("Manifest digest %s does not match the expected digest %s", digest, expected)
+	}
+
+	if err = json.Unmarshal(content, &result); err != nil {
+		return result, "", fmt.Errorf("Failed to parse manifest of %s: %v", registry.reference, err)
+	}
+	if result.MediaType != "" && result.MediaType != ociManifestMediaType && result.MediaType != dockerManifestMediaType {
+		return result, "", fmt.Errorf("Manifest media type %s is not supported, reference an image manifest", result.MediaType)
+	}
+	return result, digest, nil
+}
+
+// downloadBlob streams a layer to a temporary file in the download path and verifies its digest
+func (registry *registryClient) downloadBlob(log log.T, layer descriptor, downloadPath string) (blobFile string, err error) {
+	if !digestPattern.MatchString(layer.Digest) {
+		return "", fmt.Errorf("Invalid layer digest %s, only sha256 digests are supported", layer.Digest)
+	}
+	response, err := registry.get(log, "/blobs/"+layer.Digest)
+	if err != nil {
+		return "", err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("Failed to pull layer %s: %s", layer.Digest, response.Status)
+	}
+
+	file, err := ioutil.TempFile(downloadPath, ".layer")
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err = io.Copy(io.MultiWriter(file, hash), response.Body); err != nil {
+		os.Remove(file.Name())
+		return "", err
+	}
+	if digest := "sha256:" + hex.EncodeToString(hash.Sum(nil)); digest != layer.Digest {
+		os.Remove(file.Name())
+		return "", fmt.Errorf("Layer digest %s does not match the manifest digest %s", digest, layer.Digest)
+	}
+	return file.Name(), nil
+}
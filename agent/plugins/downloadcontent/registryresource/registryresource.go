@@ -0,0 +1,191 @@
+/*
+ * Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"). You may not
+ * use this file except in compliance with the License. A copy of the
+ * License is located at
+ *
+ * http://aws.amazon.com/apache2.0/
+ *
+ * or in the "license" file accompanying this file. This file is distributed
+ * on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied. See the License for the specific language governing
+ * permissions and limitations under the License.
+ */
+
+// Package registryresource provides methods to pull artifacts from OCI registries
+package registryresource
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/fileutil/artifactcache"
+	"github.com/aws/amazon-ssm-agent/agent/fileutil/filemanager"
+	"github.com/aws/amazon-ssm-agent/agent/jsonutil"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/downloadcontent/remoteresource"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/downloadcontent/types"
+	"github.com/aws/amazon-ssm-agent/agent/proxyconfig"
+	"github.com/aws/amazon-ssm-agent/agent/ssm/ssmparameterresolver"
+)
+
+// Authentication methods accepted for a registry
+const (
+	NONE  = "None"
+	BASIC = "Basic"
+	ECR   = "ECR"
+)
+
+var authMethods = map[types.TrimmedString]bool{
+	NONE:  true,
+	BASIC: true,
+	ECR:   true,
+}
+
+// RegistryResource represents an artifact stored in an OCI registry
+type RegistryResource struct {
+	info      RegistryInfo
+	reference reference
+	client    *http.Client
+	bridge    ssmparameterresolver.ISsmParameterResolverBridge
+	cache     artifactcache.Cache
+}
+
+// RegistryInfo defines the accepted SourceInfo attributes and their json definition.
+// The reference is registry/repository:tag or registry/repository@sha256:digest, the username and password
+// can be Parameter Store references and are used with the Basic auth method.
+type RegistryInfo struct {
+	Reference  types.TrimmedString `json:"reference"`
+	AuthMethod types.TrimmedString `json:"authMethod"`
+	Username   types.TrimmedString `json:"username"`
+	Password   types.TrimmedString `json:"password"`
+}
+
+// NewRegistryResource creates a new registry resource
+func NewRegistryResource(log log.T, info string, bridge ssmparameterresolver.ISsmParameterResolverBridge) (resource *RegistryResource, err error) {
+	var registryInfo RegistryInfo
+	if registryInfo, err = parseSourceInfo(info); err != nil {
+		return nil, err
+	}
+
+	parsedReference, err := parseReference(registryInfo.Reference.Val())
+	if err != nil {
+		return nil, err
+	}
+
+	return &RegistryResource{
+		info:      registryInfo,
+		reference: parsedReference,
+		client:    &http.Client{Transport: proxyconfig.NewTransport("")},
+		bridge:    bridge,
+	}, nil
+}
+
+// DownloadRemoteResource pulls the manifest of the artifact, verifies the digests of the manifest and its layers
+// and unpacks the layers into the download path
+func (resource *RegistryResource) DownloadRemoteResource(log log.T, fileSystem filemanager.FileSystem, downloadPath string) (err error, result *remoteresource.DownloadResult) {
+	if downloadPath == "" {
+		downloadPath = appconfig.DownloadRoot
+	}
+	if err = fileSystem.MakeDirs(downloadPath); err != nil {
+		return fmt.Errorf("Cannot create download path %s: %v", downloadPath, err.Error()), nil
+	}
+
+	registry, err := resource.newRegistryClient(log)
+	if err != nil {
+		return err, nil
+	}
+
+	log.Infof("Pulling manifest of %v", resource.reference)
+	manifest, digest, err := registry.getManifest(log)
+	if err != nil {
+		return err, nil
+	}
+	log.Infof("Pulled manifest %v with %v layers", digest, len(manifest.Layers))
+
+	result = &remoteresource.DownloadResult{}
+	for _, layer := range manifest.Layers {
+		var files []string
+		if files, err = resource.pullLayer(log, registry, layer, downloadPath); err != nil {
+			return err, nil
+		}
+		result.Files = append(result.Files, files...)
+	}
+	return nil, result
+}
+
+// SetArtifactCache sets the cache layers are reused from by their digest
+func (resource *RegistryResource) SetArtifactCache(cache artifactcache.Cache) {
+	resource.cache = cache
+}
+
+// ValidateLocationInfo validates attribute values of a registry resource
+func (resource *RegistryResource) ValidateLocationInfo() (isValid bool, err error) {
+	if resource.info.AuthMethod != "" && !authMethods[resource.info.AuthMethod] {
+		return false, fmt.Errorf("Invalid authentication method: %s. "+
+			"The following methods are accepted: None, Basic, ECR", resource.info.AuthMethod)
+	}
+	if resource.info.AuthMethod == ECR && !isEcrRegistry(resource.reference.registry) {
+		return false, fmt.Errorf("Registry %s is not an Amazon ECR registry", resource.reference.registry)
+	}
+	return true, nil
+}
+
+// pullLayer downloads a layer next to the download path, verifies its digest and unpacks it
+func (resource *RegistryResource) pullLayer(log log.T, registry *registryClient, layer descriptor, downloadPath string) (files []string, err error) {
+	blobFile, err := resource.fetchBlob(log, registry, layer, downloadPath)
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(blobFile)
+
+	if isTarLayer(layer.MediaType) {
+		log.Infof("Unpacking layer %v to %v", layer.Digest, downloadPath)
+		return untar(blobFile, downloadPath, strings.HasSuffix(layer.MediaType, "gzip"))
+	}
+
+	fileName := layer.Digest[strings.Index(layer.Digest, ":")+1:]
+	if title := filepath.Base(layer.Annotations[titleAnnotation]); title != "." && title != string(filepath.Separator) && title != "" {
+		fileName = title
+	}
+	filePath := filepath.Join(downloadPath, fileName)
+	log.Infof("Saving layer %v to %v", layer.Digest, filePath)
+	if err = os.Rename(blobFile, filePath); err != nil {
+		return nil, err
+	}
+	return []string{filePath}, nil
+}
+
+// fetchBlob copies a layer from the artifact cache, or downloads it and adds it to the cache
+func (resource *RegistryResource) fetchBlob(log log.T, registry *registryClient, layer descriptor, downloadPath string) (blobFile string, err error) {
+	if resource.cache == nil || !digestPattern.MatchString(layer.Digest) {
+		return registry.downloadBlob(log, layer, downloadPath)
+	}
+
+	checksum := strings.TrimPrefix(layer.Digest, "sha256:")
+	cachedFile := filepath.Join(downloadPath, ".layer"+checksum)
+	if resource.cache.Get(log, checksum, cachedFile) {
+		return cachedFile, nil
+	}
+
+	if blobFile, err = registry.downloadBlob(log, layer, downloadPath); err != nil {
+		return "", err
+	}
+	if err = resource.cache.Put(log, checksum, blobFile); err != nil {
+		log.Warnf("Failed to add layer %v to the artifact cache: %v", layer.Digest, err)
+	}
+	return blobFile, nil
+}
+
+// parseSourceInfo unmarshalls the provided SourceInfo input
+func parseSourceInfo(sourceInfo string) (registryInfo RegistryInfo, err error) {
+	if err = jsonutil.Unmarshal(sourceInfo, &registryInfo); err != nil {
+		return registryInfo, fmt.Errorf("SourceInfo could not be unmarshalled for source type Registry: %s", err.Error())
+	}
+	return registryInfo, nil
+}
@@ -0,0 +1,253 @@
+/*
+ * Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"). You may not
+ * use this file except in compliance with the License. A copy of the
+ * License is located at
+ *
+ * http://aws.amazon.com/apache2.0/
+ *
+ * or in the "license" file accompanying this file. This file is distributed
+ * on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied. See the License for the specific language governing
+ * permissions and limitations under the License.
+ */
+
+package registryresource
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aws/amazon-ssm-agent/agent/fileutil/filemanager"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/downloadcontent/types"
+	bridgemock "github.com/aws/amazon-ssm-agent/agent/ssm/ssmparameterresolver/mock"
+	"github.com/stretchr/testify/assert"
+)
+
+var logMock = log.NewMockLog()
+
+func sha256Digest(content []byte) string {
+	hash := sha256.Sum256(content)
+	return "sha256:" + hex.EncodeToString(hash[:])
+}
+
+func tarGz(t *testing.T, files map[string]string) []byte {
+	var buffer bytes.Buffer
+	gzipWriter := gzip.NewWriter(&buffer)
+	tarWriter := tar.NewWriter(gzipWriter)
+	for name, content := range files {
+		assert.Nil(t, tarWriter.WriteHeader(&tar.Header{Name: name, Mode: 0600, Size: int64(len(content)), Typeflag: tar.TypeReg}))
+		_, err := tarWriter.Write([]byte(content))
+		assert.Nil(t, err)
+	}
+	assert.Nil(t, tarWriter.Close())
+	assert.Nil(t, gzipWriter.Close())
+	return buffer.Bytes()
+}
+
+// newTestRegistry serves a manifest and its blobs, requiring a bearer token issued for the basic credentials
+func newTestRegistry(t *testing.T, manifestContent []byte, blobs map[string][]byte) *httptest.Server {
+	var server *httptest.Server
+	server = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/token" {
+			user, password, _ := r.BasicAuth()
+			assert.Equal(t, "reader", user)
+			assert.Equal(t, "secret", password)
+			assert.Equal(t, "repository:config/bundle:pull", r.URL.Query().Get("scope"))
+			w.Write([]byte(`{"token": "registry-token"}`))
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer registry-token" {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="`+server.URL+`/token",service="registry",scope="repository:config/bundle:pull"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch {
+		case strings.HasPrefix(r.URL.Path, "/v2/config/bundle/manifests/"):
+			w.Header().Set(contentDigestHeader, sha256Digest(manifestContent))
+			w.Write(manifestContent)
+		case strings.HasPrefix(r.URL.Path, "/v2/config/bundle/blobs/"):
+			blob, ok := blobs[strings.TrimPrefix(r.URL.Path, "/v2/config/bundle/blobs/")]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.Write(blob)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	return server
+}
+
+func newTestResource(t *testing.T, server *httptest.Server, ref string) *RegistryResource {
+	parsedReference, err := parseReference(strings.TrimPrefix(server.URL, "https://") + "/" + ref)
+	assert.Nil(t, err)
+	return &RegistryResource{
+		info: RegistryInfo{
+			AuthMethod: types.NewTrimmedString(BASIC),
+			Username:   types.NewTrimmedString("reader"),
+			Password:   types.NewTrimmedString("{{ssm-secure:registry-password}}"),
+		},
+		reference: parsedReference,
+		client:    server.Client(),
+		bridge:    bridgemock.GetSsmParamResolverBridge(map[string]string{"{{ssm-secure:registry-password}}": "secret"}),
+	}
+}
+
+func TestParseReference(t *testing.T) {
+	digest := sha256Digest([]byte("manifest"))
+	testCases := []struct {
+		value    string
+		expected reference
+		valid    bool
+	}{
+		{"registry.example.com/config/bundle:1.0", reference{registry: "registry.example.com", repository: "config/bundle", tag: "1.0"}, true},
+		{"localhost:5000/bundle", reference{registry: "localhost:5000", repository: "bundle", tag: "latest"}, true},
+		{"registry.example.com/bundle@" + digest, reference{registry: "registry.example.com", repository: "bundle", digest: digest}, true},
+		{"bundle:1.0", reference{}, false},
+		{"library/bundle:1.0", reference{}, false},
+		{"registry.example.com/bundle@md5:1234", reference{}, false},
+	}
+	for _, testCase := range testCases {
+		ref, err := parseReference(testCase.value)
+		if testCase.valid {
+			assert.Nil(t, err, testCase.value)
+			assert.Equal(t, testCase.expected, ref, testCase.value)
+		} else {
+			assert.NotNil(t, err, testCase.value)
+		}
+	}
+}
+
+func TestDownloadRemoteResourceUnpacksVerifiedLayers(t *testing.T) {
+	layer := tarGz(t, map[string]string{"conf/app.conf": "key=value"})
+	script := []byte("#!/bin/sh\necho configured\n")
+	manifestContent, _ := json.Marshal(manifest{
+		SchemaVersion: 2,
+		MediaType:     ociManifestMediaType,
+		Layers: []descriptor{
+			{MediaType: "application/vnd.oci.image.layer.v1.tar+gzip", Digest: sha256Digest(layer), Size: int64(len(layer))},
+			{MediaType: "application/x-sh", Digest: sha256Digest(script), Size: int64(len(script)),
+				Annotations: map[string]string{titleAnnotation: "configure.sh"}},
+		},
+	})
+	server := newTestRegistry(t, manifestContent, map[string][]byte{
+		sha256Digest(layer):  layer,
+		sha256Digest(script): script,
+	})
+	defer server.Close()
+
+	destination, err := ioutil.TempDir("", "registryresource")
+	assert.Nil(t, err)
+	defer os.RemoveAll(destination)
+
+	resource := newTestResource(t, server, "config/bundle:1.0")
+	err, result := resource.DownloadRemoteResource(logMock, filemanager.FileSystemImpl{}, destination)
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{filepath.Join(destination, "conf", "app.conf"), filepath.Join(destination, "configure.sh")}, result.Files)
+	content, _ := ioutil.ReadFile(filepath.Join(destination, "conf", "app.conf"))
+	assert.Equal(t, "key=value", string(content))
+	content, _ = ioutil.ReadFile(filepath.Join(destination, "configure.sh"))
+	assert.Equal(t, script, content)
+}
+
+func TestDownloadRemoteResourceRejectsManifestDigestMismatch(t *testing.T) {
+	manifestContent := []byte(`{"schemaVersion": 2, "layers": []}`)
+	server := newTestRegistry(t, manifestContent, nil)
+	defer server.Close()
+
+	destination, err := ioutil.TempDir("", "registryresource")
+	assert.Nil(t, err)
+	defer os.RemoveAll(destination)
+
+	resource := newTestResource(t, server, "config/bundle@"+sha256Digest([]byte("other manifest")))
+	err, result := resource.DownloadRemoteResource(logMock, filemanager.FileSystemImpl{}, destination)
+
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "does not match the expected digest")
+	assert.Nil(t, result)
+}
+
+func TestDownloadRemoteResourceRejectsLayerDigestMismatch(t *testing.T) {
+	layer := []byte("tampered")
+	manifestContent, _ := json.Marshal(manifest{
+		SchemaVersion: 2,
+		Layers:        []descriptor{{MediaType: "application/octet-stream", Digest: sha256Digest([]byte("original"))}},
+	})
+	server := newTestRegistry(t, manifestContent, map[string][]byte{sha256Digest([]byte("original")): layer})
+	defer server.Close()
+
+	destination, err := ioutil.TempDir("", "registryresource")
+	assert.Nil(t, err)
+	defer os.RemoveAll(destination)
+
+	resource := newTestResource(t, server, "config/bundle:1.0")
+	err, _ = resource.DownloadRemoteResource(logMock, filemanager.FileSystemImpl{}, destination)
+
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "does not match the manifest digest")
+	files, _ := ioutil.ReadDir(destination)
+	assert.Empty(t, files)
+}
+
+func TestNewRegistryClientUsesEcrAuthorizationToken(t *testing.T) {
+	getEcrAuthorizationToken = func(registryId string, region string) (string, error) {
+		assert.Equal(t, "123456789012", registryId)
+		assert.Equal(t, "eu-west-1", region)
+		return base64.StdEncoding.EncodeToString([]byte("AWS:ecr-password")), nil
+	}
+	defer func() { getEcrAuthorizationToken = ecrAuthorizationToken }()
+
+	parsedReference, err := parseReference("123456789012.dkr.ecr.eu-west-1.amazonaws.com/config/bundle:1.0")
+	assert.Nil(t, err)
+	resource := &RegistryResource{info: RegistryInfo{AuthMethod: types.NewTrimmedString(ECR)}, reference: parsedReference}
+
+	valid, err := resource.ValidateLocationInfo()
+	assert.True(t, valid)
+	assert.Nil(t, err)
+
+	registry, err := resource.newRegistryClient(logMock)
+	assert.Nil(t, err)
+	assert.Equal(t, "AWS", registry.username)
+	assert.Equal(t, "ecr-password", registry.password)
+}
+
+func TestValidateLocationInfoRejectsEcrAuthForOtherRegistries(t *testing.T) {
+	parsedReference, err := parseReference("registry.example.com/config/bundle:1.0")
+	assert.Nil(t, err)
+	resource := &RegistryResource{info: RegistryInfo{AuthMethod: types.NewTrimmedString(ECR)}, reference: parsedReference}
+
+	valid, err := resource.ValidateLocationInfo()
+	assert.False(t, valid)
+	assert.NotNil(t, err)
+}
+
+func TestUntarRejectsEntriesOutsideDestination(t *testing.T) {
+	destination, err := ioutil.TempDir("", "registryresource")
+	assert.Nil(t, err)
+	defer os.RemoveAll(destination)
+
+	layerFile := filepath.Join(destination, "layer")
+	assert.Nil(t, ioutil.WriteFile(layerFile, tarGz(t, map[string]string{"../escaped": "content"}), 0600))
+
+	_, err = untar(layerFile, filepath.Join(destination, "unpacked"), true)
+	assert.NotNil(t, err)
+	_, statErr := os.Stat(filepath.Join(destination, "escaped"))
+	assert.True(t, os.IsNotExist(statErr))
+}
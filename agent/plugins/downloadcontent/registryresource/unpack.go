@@ -0,0 +1,96 @@
+/*
+ * Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"). You may not
+ * use this file except in compliance with the License. A copy of the
+ * License is located at
+ *
+ * http://aws.amazon.com/apache2.0/
+ *
+ * or in the "license" file accompanying this file. This file is distributed
+ * on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied. See the License for the specific language governing
+ * permissions and limitations under the License.
+ */
+
+package registryresource
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+)
+
+// isTarLayer returns true for OCI and Docker layers, which are tar archives that are optionally gzipped
+func isTarLayer(mediaType string) bool {
+	return strings.Contains(mediaType, ".tar")
+}
+
+// untar unpacks the regular files and directories of a layer into the destination.
+// Links and entries outside the destination are rejected so an artifact can not write anywhere else.
+func untar(layerFile string, destination string, gzipped bool) (files []string, err error) {
+	file, err := os.Open(layerFile)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var reader io.Reader = file
+	if gzipped {
+		gzipReader, err := gzip.NewReader(file)
+		if err != nil {
+			return nil, err
+		}
+		defer gzipReader.Close()
+		reader = gzipReader
+	}
+
+	destination = filepath.Clean(destination)
+	tarReader := tar.NewReader(reader)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			return files, nil
+		} else if err != nil {
+			return nil, err
+		}
+
+		itemPath := filepath.Join(destination, filepath.FromSlash(header.Name))
+		if itemPath != destination && !strings.HasPrefix(itemPath, destination+string(os.PathSeparator)) {
+			return nil, fmt.Errorf("Layer entry %s is outside of the destination %s", header.Name, destination)
+		}
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err = os.MkdirAll(itemPath, appconfig.ReadWriteExecuteAccess); err != nil {
+				return nil, err
+			}
+		case tar.TypeReg:
+			if err = writeTarEntry(tarReader, itemPath); err != nil {
+				return nil, err
+			}
+			files = append(files, itemPath)
+		default:
+			return nil, fmt.Errorf("Layer entry %s has unsupported type %v", header.Name, string(header.Typeflag))
+		}
+	}
+}
+
+func writeTarEntry(reader io.Reader, itemPath string) error {
+	if err := os.MkdirAll(filepath.Dir(itemPath), appconfig.ReadWriteExecuteAccess); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(itemPath, appconfig.FileFlagsCreateOrTruncate, appconfig.ReadWriteAccess)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, err = io.Copy(file, reader)
+	return err
+}
@@ -16,6 +16,7 @@
 package remoteresource
 
 import (
+	"github.com/aws/amazon-ssm-agent/agent/fileutil/artifactcache"
 	"github.com/aws/amazon-ssm-agent/agent/fileutil/filemanager"
 	"github.com/aws/amazon-ssm-agent/agent/log"
 )
@@ -34,3 +35,9 @@ type RemoteResource interface {
 	DownloadRemoteResource(log log.T, filesys filemanager.FileSystem, destinationDir string) (err error, result *DownloadResult)
 	ValidateLocationInfo() (bool, error)
 }
+
+// CacheableResource is implemented by remote resources that know the checksum of their content before downloading it,
+// so the content can be reused from the artifact cache
+type CacheableResource interface {
+	SetArtifactCache(cache artifactcache.Cache)
+}
@@ -0,0 +1,387 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package externalplugin runs plugins shipped as separate executables and registered in the agent configuration.
+//
+// The agent starts the executable for every step and exchanges JSON messages with it, one per line,
+// over its standard input and output. The plugin first writes a hello message with the protocol version
+// and its capabilities, the agent then writes an execute message with the step, and the plugin writes
+// output messages followed by one result message before it exits. When the command is canceled the agent
+// writes a cancel message to plugins that declared they can be canceled, the process is killed when it
+// does not exit within the grace period, when it cannot be canceled or when the step times out.
+// Anything the plugin writes to its standard error is added to the error output of the step.
+package externalplugin
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/context"
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/fileutil"
+	"github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/iohandler"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/task"
+)
+
+// ProtocolVersion is the version of the messages the agent exchanges with external plugins
+const ProtocolVersion = 1
+
+// Types of the protocol messages
+const (
+	MessageTypeHello   = "hello"   // MessageTypeHello is the first message of the plugin
+	MessageTypeExecute = "execute" // MessageTypeExecute asks the plugin to run the step
+	MessageTypeCancel  = "cancel"  // MessageTypeCancel asks the plugin to stop the step
+	MessageTypeOutput  = "output"  // MessageTypeOutput adds lines to the output of the step
+	MessageTypeResult  = "result"  // MessageTypeResult is the last message of the plugin
+)
+
+// ProtocolVersionEnvVar tells the plugin which protocol version the agent speaks
+const ProtocolVersionEnvVar = "SSM_EXTERNAL_PLUGIN_PROTOCOL_VERSION"
+
+// reservedPrefix is the namespace of the plugins of the agent, external plugins cannot use it
+const reservedPrefix = "aws:"
+
+var (
+	// helloTimeout limits how long the agent waits for the hello message of the plugin
+	helloTimeout = 30 * time.Second
+	// cancelGracePeriod is how long a canceled plugin has to exit before it is killed
+	cancelGracePeriod = 10 * time.Second
+)
+
+// passthroughEnvironment is the agent environment plugins get when they do not inherit all of it
+var passthroughEnvironment = []string{"PATH", "LANG", "TMPDIR", "TEMP", "TMP", "SystemRoot", "ProgramData"}
+
+// Capabilities are declared by the plugin in its hello message
+type Capabilities struct {
+	// Cancel is set when the plugin stops its step on a cancel message
+	Cancel bool `json:"cancel"`
+	// Platforms lists the operating systems the plugin runs on, as named by GOOS, it runs everywhere when empty
+	Platforms []string `json:"platforms,omitempty"`
+}
+
+// ExecuteRequest is the step the plugin runs
+type ExecuteRequest struct {
+	PluginName              string      `json:"pluginName"`
+	PluginID                string      `json:"pluginId"`
+	MessageID               string      `json:"messageId"`
+	Properties              interface{} `json:"properties"`
+	OrchestrationDirectory  string      `json:"orchestrationDirectory"`
+	DefaultWorkingDirectory string      `json:"defaultWorkingDirectory"`
+}
+
+// Message is one line of the protocol, the fields that are set depend on its type
+type Message struct {
+	Type            string                 `json:"type"`
+	ProtocolVersion int                    `json:"protocolVersion,omitempty"`
+	Capabilities    *Capabilities          `json:"capabilities,omitempty"`
+	Execute         *ExecuteRequest        `json:"execute,omitempty"`
+	Stdout          string                 `json:"stdout,omitempty"`
+	Stderr          string                 `json:"stderr,omitempty"`
+	Status          contracts.ResultStatus `json:"status,omitempty"`
+	ExitCode        int                    `json:"exitCode,omitempty"`
+	Error           string                 `json:"error,omitempty"`
+}
+
+// Plugin runs the steps of one external plugin.
+type Plugin struct {
+	config appconfig.ExternalPluginCfg
+}
+
+// NewPlugin returns a new instance of the plugin.
+func NewPlugin(config appconfig.ExternalPluginCfg) (*Plugin, error) {
+	if err := ValidateConfig(config); err != nil {
+		return nil, err
+	}
+	return &Plugin{config: config}, nil
+}
+
+// ValidateConfig rejects external plugins that would replace a plugin of the agent or whose executable is unsafe to run
+func ValidateConfig(config appconfig.ExternalPluginCfg) error {
+	if config.Name == "" {
+		return errors.New("external plugin has no name")
+	}
+	if strings.HasPrefix(strings.ToLower(config.Name), reservedPrefix) {
+		return fmt.Errorf("external plugin %v uses the reserved prefix %v", config.Name, reservedPrefix)
+	}
+	if !filepath.IsAbs(config.Path) {
+		return fmt.Errorf("external plugin %v must have an absolute path", config.Name)
+	}
+	return checkExecutable(config.Path)
+}
+
+// Execute runs the executable of the plugin for the step.
+func (p *Plugin) Execute(context context.T, config contracts.Configuration, cancelFlag task.CancelFlag, output iohandler.IOHandler) {
+	log := context.Log()
+	log.Infof("%v started with external plugin %v", config.PluginName, p.config.Path)
+
+	if cancelFlag.ShutDown() {
+		output.MarkAsShutdown()
+	} else if cancelFlag.Canceled() {
+		output.MarkAsCancelled()
+	} else if err := checkExecutable(p.config.Path); err != nil {
+		output.MarkAsFailed(err)
+	} else {
+		p.run(log, config, cancelFlag, output)
+	}
+}
+
+// run starts the plugin process, waits for its hello message and relays its messages until it sends its result
+func (p *Plugin) run(log log.T, config contracts.Configuration, cancelFlag task.CancelFlag, output iohandler.IOHandler) {
+	if err := fileutil.MakeDirs(config.OrchestrationDirectory); err != nil {
+		output.MarkAsFailed(fmt.Errorf("failed to create orchestration directory %v: %v", config.OrchestrationDirectory, err))
+		return
+	}
+	command := exec.Command(p.config.Path, p.config.Args...)
+	command.Dir = config.OrchestrationDirectory
+	command.Env = buildEnvironment(p.config)
+	if err := prepareCommand(command, p.config.RunAsUser); err != nil {
+		output.MarkAsFailed(fmt.Errorf("failed to prepare external plugin %v: %v", p.config.Name, err))
+		return
+	}
+	var stderr bytes.Buffer
+	command.Stderr = &stderr
+	stdin, err := command.StdinPipe()
+	if err != nil {
+		output.MarkAsFailed(err)
+		return
+	}
+	stdout, err := command.StdoutPipe()
+	if err != nil {
+		output.MarkAsFailed(err)
+		return
+	}
+	if err = command.Start(); err != nil {
+		output.MarkAsFailed(fmt.Errorf("failed to start external plugin %v: %v", p.config.Name, err))
+		return
+	}
+
+	exited := make(chan struct{})
+	var exitOnce sync.Once
+	messages := make(chan Message)
+	go readMessages(log, stdout, messages)
+	go func() {
+		command.Wait()
+		exitOnce.Do(func() { close(exited) })
+	}()
+	defer func() {
+		stdin.Close()
+		// messages sent after the result or a timeout are dropped so the reader does not block
+		go func() {
+			for range messages {
+			}
+		}()
+		select {
+		case <-exited:
+		case <-time.After(cancelGracePeriod):
+			log.Warnf("external plugin %v did not exit after its result, killing it", p.config.Name)
+			killProcess(command.Process)
+			<-exited
+		}
+		if stderr.Len() > 0 {
+			output.AppendError(strings.TrimRight(stderr.String(), "\n"))
+		}
+	}()
+
+	capabilities, err := waitForHello(messages)
+	if err != nil {
+		killProcess(command.Process)
+		output.MarkAsFailed(fmt.Errorf("external plugin %v: %v", p.config.Name, err))
+		return
+	}
+	encoder := json.NewEncoder(stdin)
+	request := Message{
+		Type: MessageTypeExecute,
+		Execute: &ExecuteRequest{
+			PluginName:              config.PluginName,
+			PluginID:                config.PluginID,
+			MessageID:               config.MessageId,
+			Properties:              config.Properties,
+			OrchestrationDirectory:  config.OrchestrationDirectory,
+			DefaultWorkingDirectory: config.DefaultWorkingDirectory,
+		},
+	}
+	if err = encoder.Encode(request); err != nil {
+		killProcess(command.Process)
+		output.MarkAsFailed(fmt.Errorf("failed to send the step to external plugin %v: %v", p.config.Name, err))
+		return
+	}
+
+	canceled := make(chan task.State, 1)
+	go func() { canceled <- cancelFlag.Wait() }()
+	timeout := time.NewTimer(time.Duration(p.config.TimeoutSeconds) * time.Second)
+	defer timeout.Stop()
+	var killTimer <-chan time.Time
+
+	for {
+		select {
+		case message, ok := <-messages:
+			if !ok {
+				if cancelFlag.ShutDown() {
+					output.MarkAsShutdown()
+				} else if cancelFlag.Canceled() {
+					output.MarkAsCancelled()
+				} else {
+					output.MarkAsFailed(fmt.Errorf("external plugin %v exited without a result", p.config.Name))
+				}
+				return
+			}
+			if message.Type == MessageTypeOutput {
+				output.AppendInfo(strings.TrimRight(message.Stdout, "\n"))
+				output.AppendError(strings.TrimRight(message.Stderr, "\n"))
+			} else if message.Type == MessageTypeResult {
+				setResult(message, output)
+				return
+			} else {
+				log.Warnf("ignoring %v message of external plugin %v", message.Type, p.config.Name)
+			}
+
+		case state := <-canceled:
+			if state == task.ShutDown || !capabilities.Cancel {
+				killProcess(command.Process)
+				continue
+			}
+			log.Infof("canceling external plugin %v", p.config.Name)
+			if err = encoder.Encode(Message{Type: MessageTypeCancel}); err != nil {
+				killProcess(command.Process)
+				continue
+			}
+			killTimer = time.After(cancelGracePeriod)
+
+		case <-killTimer:
+			log.Warnf("external plugin %v did not stop after it was canceled, killing it", p.config.Name)
+			killProcess(command.Process)
+
+		case <-timeout.C:
+			killProcess(command.Process)
+			output.AppendErrorf("external plugin %v timed out after %v seconds", p.config.Name, p.config.TimeoutSeconds)
+			output.SetExitCode(appconfig.CommandStoppedPreemptivelyExitCode)
+			output.SetStatus(contracts.ResultStatusTimedOut)
+			return
+		}
+	}
+}
+
+// readMessages decodes the messages of the plugin until it closes its standard output
+func readMessages(log log.T, stdout io.Reader, messages chan<- Message) {
+	defer close(messages)
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var message Message
+		if err := json.Unmarshal(line, &message); err != nil {
+			log.Warnf("ignoring invalid message of external plugin: %v", err)
+			continue
+		}
+		messages <- message
+	}
+}
+
+// waitForHello returns the capabilities of the plugin once it confirmed it speaks the protocol of the agent
+func waitForHello(messages <-chan Message) (Capabilities, error) {
+	select {
+	case message, ok := <-messages:
+		if !ok {
+			return Capabilities{}, errors.New("exited before its hello message")
+		}
+		if message.Type != MessageTypeHello {
+			return Capabilities{}, fmt.Errorf("sent a %v message instead of its hello message", message.Type)
+		}
+		if message.ProtocolVersion != ProtocolVersion {
+			return Capabilities{}, fmt.Errorf("speaks protocol version %v, the agent speaks version %v", message.ProtocolVersion, ProtocolVersion)
+		}
+		var capabilities Capabilities
+		if message.Capabilities != nil {
+			capabilities = *message.Capabilities
+		}
+		if !supportsPlatform(capabilities.Platforms) {
+			return capabilities, fmt.Errorf("does not support %v", runtime.GOOS)
+		}
+		return capabilities, nil
+	case <-time.After(helloTimeout):
+		return Capabilities{}, fmt.Errorf("did not send its hello message within %v", helloTimeout)
+	}
+}
+
+// supportsPlatform returns true if the platforms declared by the plugin include the current one
+func supportsPlatform(platforms []string) bool {
+	if len(platforms) == 0 {
+		return true
+	}
+	for _, platform := range platforms {
+		if strings.EqualFold(platform, runtime.GOOS) {
+			return true
+		}
+	}
+	return false
+}
+
+// setResult sets the status of the step from the result message of the plugin, the exit code is kept for failures
+func setResult(message Message, output iohandler.IOHandler) {
+	output.SetExitCode(message.ExitCode)
+	switch message.Status {
+	case contracts.ResultStatusSuccess:
+		output.MarkAsSucceeded()
+	case contracts.ResultStatusSuccessAndReboot:
+		output.MarkAsSuccessWithReboot()
+	case contracts.ResultStatusCancelled:
+		output.MarkAsCancelled()
+	case contracts.ResultStatusFailed:
+		if message.Error == "" {
+			message.Error = "external plugin failed"
+		}
+		output.MarkAsFailed(errors.New(message.Error))
+	default:
+		output.MarkAsFailed(fmt.Errorf("external plugin returned unknown status %v", message.Status))
+	}
+}
+
+// buildEnvironment returns the environment of the plugin process, variables of the configuration are sorted
+func buildEnvironment(config appconfig.ExternalPluginCfg) []string {
+	var env []string
+	if config.InheritEnvironment {
+		env = os.Environ()
+	} else {
+		for _, name := range passthroughEnvironment {
+			if value, ok := os.LookupEnv(name); ok {
+				env = append(env, name+"="+value)
+			}
+		}
+	}
+	names := make([]string, 0, len(config.Environment))
+	for name := range config.Environment {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		env = append(env, name+"="+config.Environment[name])
+	}
+	return append(env, fmt.Sprintf("%v=%v", ProtocolVersionEnvVar, ProtocolVersion))
+}
@@ -0,0 +1,176 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package externalplugin runs plugins shipped as separate executables and registered in the agent configuration.
+package externalplugin
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/context"
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/iohandler"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/task"
+	"github.com/stretchr/testify/assert"
+)
+
+// helperPlugin returns the configuration of an external plugin played by the test binary
+func helperPlugin(t *testing.T, behavior string) appconfig.ExternalPluginCfg {
+	return appconfig.ExternalPluginCfg{
+		Name:           "custom:test",
+		Path:           os.Args[0],
+		Args:           []string{"-test.run=TestExternalPluginHelperProcess", "--", behavior},
+		Environment:    map[string]string{"GO_WANT_HELPER_PROCESS": "1"},
+		TimeoutSeconds: 30,
+	}
+}
+
+func runHelperPlugin(t *testing.T, behavior string, cancelFlag task.CancelFlag) *iohandler.DefaultIOHandler {
+	plugin, err := NewPlugin(helperPlugin(t, behavior))
+	assert.NoError(t, err)
+	dir, err := ioutil.TempDir("", "externalplugin")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	output := iohandler.NewDefaultIOHandler(log.NewMockLog(), contracts.IOConfiguration{})
+	config := contracts.Configuration{
+		PluginName:             "custom:test",
+		PluginID:               "step1",
+		MessageId:              "aws.ssm.1234.i-1234",
+		Properties:             map[string]interface{}{"greeting": "hello"},
+		OrchestrationDirectory: filepath.Join(dir, "step1"),
+	}
+	plugin.Execute(context.NewMockDefault(), config, cancelFlag, output)
+	return output
+}
+
+func TestExecuteSucceeds(t *testing.T) {
+	output := runHelperPlugin(t, "success", task.NewChanneledCancelFlag())
+
+	assert.Equal(t, contracts.ResultStatusSuccess, output.GetStatus())
+	assert.Equal(t, 0, output.GetExitCode())
+	assert.Contains(t, output.GetStdout(), "custom:test step1 hello")
+	assert.Contains(t, output.GetStderr(), "diagnostic")
+}
+
+func TestExecuteFailsWithExitCode(t *testing.T) {
+	output := runHelperPlugin(t, "fail", task.NewChanneledCancelFlag())
+
+	assert.Equal(t, contracts.ResultStatusFailed, output.GetStatus())
+	assert.Equal(t, 3, output.GetExitCode())
+	assert.Contains(t, output.GetStderr(), "terraform plan failed")
+}
+
+func TestExecuteFailsWithoutResult(t *testing.T) {
+	output := runHelperPlugin(t, "noresult", task.NewChanneledCancelFlag())
+
+	assert.Equal(t, contracts.ResultStatusFailed, output.GetStatus())
+	assert.Contains(t, output.GetStderr(), "exited without a result")
+}
+
+func TestExecuteRefusesOtherProtocolVersion(t *testing.T) {
+	output := runHelperPlugin(t, "version", task.NewChanneledCancelFlag())
+
+	assert.Equal(t, contracts.ResultStatusFailed, output.GetStatus())
+	assert.Contains(t, output.GetStderr(), "protocol version 2")
+}
+
+func TestExecuteCancels(t *testing.T) {
+	cancelFlag := task.NewChanneledCancelFlag()
+	go func() {
+		time.Sleep(500 * time.Millisecond)
+		cancelFlag.Set(task.Canceled)
+	}()
+	output := runHelperPlugin(t, "cancel", cancelFlag)
+
+	assert.Equal(t, contracts.ResultStatusCancelled, output.GetStatus())
+	assert.Contains(t, output.GetStdout(), "stopping")
+}
+
+func TestValidateConfig(t *testing.T) {
+	config := helperPlugin(t, "success")
+	assert.NoError(t, ValidateConfig(config))
+
+	config.Name = "aws:runShellScript"
+	assert.Error(t, ValidateConfig(config))
+
+	config = helperPlugin(t, "success")
+	config.Path = "plugins/terraform"
+	assert.Error(t, ValidateConfig(config))
+
+	config = helperPlugin(t, "success")
+	config.Path = filepath.Dir(os.Args[0])
+	assert.Error(t, ValidateConfig(config))
+}
+
+func TestBuildEnvironment(t *testing.T) {
+	env := buildEnvironment(appconfig.ExternalPluginCfg{Environment: map[string]string{"B": "2", "A": "1"}})
+
+	assert.Equal(t, []string{"A=1", "B=2", ProtocolVersionEnvVar + "=1"}, env[len(env)-3:])
+	assert.True(t, supportsPlatform(nil))
+	assert.True(t, supportsPlatform([]string{runtime.GOOS}))
+	assert.False(t, supportsPlatform([]string{"plan9"}))
+}
+
+// TestExternalPluginHelperProcess is not a real test, it plays the external plugin for the other tests
+func TestExternalPluginHelperProcess(t *testing.T) {
+	if os.Getenv("GO_WANT_HELPER_PROCESS") != "1" {
+		return
+	}
+	defer os.Exit(0)
+
+	behavior := os.Args[len(os.Args)-1]
+	encoder := json.NewEncoder(os.Stdout)
+	scanner := bufio.NewScanner(os.Stdin)
+
+	version := ProtocolVersion
+	if behavior == "version" {
+		version = 2
+	}
+	encoder.Encode(Message{Type: MessageTypeHello, ProtocolVersion: version, Capabilities: &Capabilities{Cancel: true}})
+	if !scanner.Scan() {
+		return
+	}
+	var request Message
+	json.Unmarshal(scanner.Bytes(), &request)
+
+	switch behavior {
+	case "success":
+		properties := request.Execute.Properties.(map[string]interface{})
+		fmt.Fprintln(os.Stderr, "diagnostic")
+		encoder.Encode(Message{Type: MessageTypeOutput, Stdout: fmt.Sprintf("%v %v %v", request.Execute.PluginName, request.Execute.PluginID, properties["greeting"])})
+		encoder.Encode(Message{Type: MessageTypeResult, Status: contracts.ResultStatusSuccess})
+	case "fail":
+		encoder.Encode(Message{Type: MessageTypeResult, Status: contracts.ResultStatusFailed, ExitCode: 3, Error: "terraform plan failed"})
+	case "cancel":
+		for scanner.Scan() {
+			var message Message
+			json.Unmarshal(scanner.Bytes(), &message)
+			if message.Type == MessageTypeCancel {
+				encoder.Encode(Message{Type: MessageTypeOutput, Stdout: "stopping"})
+				encoder.Encode(Message{Type: MessageTypeResult, Status: contracts.ResultStatusCancelled})
+				return
+			}
+		}
+	}
+}
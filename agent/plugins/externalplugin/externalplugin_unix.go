@@ -0,0 +1,82 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// +build darwin freebsd linux netbsd openbsd
+
+// Package externalplugin runs plugins shipped as separate executables and registered in the agent configuration.
+package externalplugin
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"os/user"
+	"strconv"
+	"syscall"
+)
+
+// prepareCommand starts the plugin in its own process group, as RunAsUser in its home directory when it is set
+func prepareCommand(command *exec.Cmd, runAsUser string) error {
+	command.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	if runAsUser == "" {
+		return nil
+	}
+	account, err := user.Lookup(runAsUser)
+	if err != nil {
+		return err
+	}
+	uid, err := strconv.ParseUint(account.Uid, 10, 32)
+	if err != nil {
+		return err
+	}
+	gid, err := strconv.ParseUint(account.Gid, 10, 32)
+	if err != nil {
+		return err
+	}
+	var groups []uint32
+	if groupIds, err := account.GroupIds(); err == nil {
+		for _, groupId := range groupIds {
+			if group, err := strconv.ParseUint(groupId, 10, 32); err == nil {
+				groups = append(groups, uint32(group))
+			}
+		}
+	}
+	command.SysProcAttr.Credential = &syscall.Credential{Uid: uint32(uid), Gid: uint32(gid), Groups: groups}
+	// the orchestration directory of the agent is not accessible to other users
+	command.Dir = account.HomeDir
+	command.Env = append(command.Env, "HOME="+account.HomeDir, "USER="+account.Username)
+	return nil
+}
+
+// checkExecutable refuses executables that users other than root and the agent user can modify
+func checkExecutable(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if !info.Mode().IsRegular() {
+		return fmt.Errorf("%v is not a regular file", path)
+	}
+	if info.Mode().Perm()&0022 != 0 {
+		return fmt.Errorf("%v is writable by group or others", path)
+	}
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok && stat.Uid != 0 && int(stat.Uid) != os.Geteuid() {
+		return fmt.Errorf("%v is not owned by root or the agent user", path)
+	}
+	return nil
+}
+
+// killProcess kills the plugin and the processes it started
+func killProcess(process *os.Process) error {
+	return syscall.Kill(-process.Pid, syscall.SIGKILL)
+}
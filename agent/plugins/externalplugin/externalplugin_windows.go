@@ -0,0 +1,49 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// +build windows
+
+// Package externalplugin runs plugins shipped as separate executables and registered in the agent configuration.
+package externalplugin
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+)
+
+// prepareCommand refuses RunAsUser, Windows plugins run as the agent account
+func prepareCommand(command *exec.Cmd, runAsUser string) error {
+	if runAsUser != "" {
+		return errors.New("RunAsUser is not supported for external plugins on Windows")
+	}
+	return nil
+}
+
+// checkExecutable refuses paths that are not files, the permissions are left to the install directory
+func checkExecutable(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if !info.Mode().IsRegular() {
+		return fmt.Errorf("%v is not a regular file", path)
+	}
+	return nil
+}
+
+// killProcess kills the plugin
+func killProcess(process *os.Process) error {
+	return process.Kill()
+}
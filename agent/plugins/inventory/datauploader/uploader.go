@@ -26,11 +26,13 @@ import (
 
 	"github.com/aws/amazon-ssm-agent/agent/appconfig"
 	"github.com/aws/amazon-ssm-agent/agent/context"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/network/compression"
+	"github.com/aws/amazon-ssm-agent/agent/outbox"
 	"github.com/aws/amazon-ssm-agent/agent/platform"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/inventory/model"
+	"github.com/aws/amazon-ssm-agent/agent/proxyconfig"
 	"github.com/aws/amazon-ssm-agent/agent/sdkutil"
-	"github.com/aws/aws-sdk-go/aws/request"
-	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/ssm"
 )
 
@@ -88,10 +90,12 @@ func NewInventoryUploader(context context.T) (*InventoryUploader, error) {
 			cfg.Region = &appCfg.Agent.Region
 		}
 	}
-	sess := session.New(cfg)
-	sess.Handlers.Build.PushBack(request.MakeAddToUserAgentHandler(appCfg.Agent.Name, appCfg.Agent.Version))
+	sess := sdkutil.NewSession(cfg, proxyconfig.ServiceSsm)
 
-	uploader.ssm = ssm.New(sess)
+	ssmService := ssm.New(sess)
+	compression.AddHandlers(&ssmService.Handlers, proxyconfig.ServiceSsm, "PutInventory")
+	uploader.ssm = ssmService
+	outbox.RegisterSender(outbox.KindInventory, uploader.queuedInventorySender(c))
 
 	if uploader.optimizer, err = NewOptimizerImpl(context); err != nil {
 		log.Errorf("Unable to load optimizer for inventory uploader because - %v", err.Error())
@@ -127,10 +131,16 @@ func (u *InventoryUploader) SendDataToSSM(context context.T, items []*ssm.Invent
 	time.Sleep(time.Duration(getRandomBackOffTime(context, instanceID)) * time.Second)
 	log.Debugf("Calling PutInventory API with parameters - %v", params)
 	if u.ssm != nil {
-		resp, err = u.ssm.PutInventory(params)
+		var queued bool
+		queued, err = outbox.Send(log, outbox.KindInventory, params, func() (err error) {
+			resp, err = u.ssm.PutInventory(params)
+			return err
+		})
 
 		if err != nil {
 			log.Errorf("the following error occured while calling PutInventory API: %v", err)
+		} else if queued {
+			log.Infof("Queued inventory data until SSM can be reached")
 		} else {
 			log.Debugf("PutInventory was called successfully with response - %v", resp)
 			u.updateContentHash(context, items)
@@ -140,6 +150,20 @@ func (u *InventoryUploader) SendDataToSSM(context context.T, items []*ssm.Invent
 	return
 }
 
+// queuedInventorySender returns the sender of inventory data queued while SSM was unreachable
+func (u *InventoryUploader) queuedInventorySender(context context.T) outbox.Sender {
+	return func(log log.T, payload []byte) (err error) {
+		var params ssm.PutInventoryInput
+		if err = json.Unmarshal(payload, &params); err != nil {
+			return
+		}
+		if _, err = u.ssm.PutInventory(&params); err == nil {
+			u.updateContentHash(context, params.Items)
+		}
+		return
+	}
+}
+
 // Get one random jitter time before calling PutInventory API to prevent huge number of request come to
 // the backend service in the same time.
 // Use current Time stamp + Hashcode of instance ID as random key
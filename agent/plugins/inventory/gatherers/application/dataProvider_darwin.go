@@ -0,0 +1,165 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// +build darwin
+
+// Package application contains application gatherer.
+package application
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/context"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/inventory/model"
+)
+
+const (
+	pkgutilCmd            = "pkgutil"
+	pkgutilArgsToListPkgs = "--pkgs"
+	pkgutilArgsToPkgInfo  = "--pkg-info"
+
+	homebrewPublisher       = "Homebrew"
+	installerPackageType    = "pkgutil"
+	homebrewFormulaType     = "homebrew-formula"
+	homebrewCaskType        = "homebrew-cask"
+	homebrewCellarDir       = "Cellar"
+	homebrewCaskroomDir     = "Caskroom"
+	homebrewMetadataDirName = ".metadata"
+)
+
+// homebrewPrefixes are the default prefixes of Homebrew on Intel and Apple silicon Macs.
+// Homebrew refuses to run as root, so the installed packages are read from its directories instead of brew list.
+var homebrewPrefixes = []string{"/usr/local", "/opt/homebrew"}
+
+// decoupling exec.Command for easy testability
+var cmdExecutor = executeCommand
+
+func executeCommand(command string, args ...string) ([]byte, error) {
+	return exec.Command(command, args...).CombinedOutput()
+}
+
+// collectPlatformDependentApplicationData collects the packages installed by the macOS installer, and the formulae and casks installed by Homebrew.
+func collectPlatformDependentApplicationData(context context.T) (appData []model.ApplicationData) {
+	log := context.Log()
+
+	var err error
+	if appData, err = getInstallerPackages(log); err != nil {
+		log.Errorf("Unable to list installer packages with pkgutil: %v", err)
+	}
+	for _, prefix := range homebrewPrefixes {
+		appData = append(appData, getHomebrewPackages(log, filepath.Join(prefix, homebrewCellarDir), homebrewFormulaType)...)
+		appData = append(appData, getHomebrewPackages(log, filepath.Join(prefix, homebrewCaskroomDir), homebrewCaskType)...)
+	}
+	return
+}
+
+// getInstallerPackages returns the packages in the receipts database of the macOS installer
+func getInstallerPackages(log log.T) (data []model.ApplicationData, err error) {
+	var output []byte
+	if output, err = cmdExecutor(pkgutilCmd, pkgutilArgsToListPkgs); err != nil {
+		return nil, fmt.Errorf("%v %v", err, strings.TrimSpace(string(output)))
+	}
+	for _, packageId := range strings.Fields(string(output)) {
+		info, err := cmdExecutor(pkgutilCmd, pkgutilArgsToPkgInfo, packageId)
+		if err != nil {
+			log.Debugf("Unable to get information of package %v: %v", packageId, err)
+			continue
+		}
+		data = append(data, parsePkgInfo(packageId, string(info)))
+	}
+	return
+}
+
+// parsePkgInfo parses the output of pkgutil --pkg-info like
+//  package-id: com.amazon.aws.ssm
+//  version: 3.0.0.0
+//  volume: /
+//  location: /
+//  install-time: 1600000000
+func parsePkgInfo(packageId string, info string) model.ApplicationData {
+	app := model.ApplicationData{
+		Name:            packageId,
+		PackageId:       packageId,
+		Publisher:       pkgPublisher(packageId),
+		ApplicationType: installerPackageType,
+		CompType:        componentType(packageId),
+	}
+	scanner := bufio.NewScanner(strings.NewReader(info))
+	for scanner.Scan() {
+		fields := strings.SplitN(scanner.Text(), ":", 2)
+		if len(fields) != 2 {
+			continue
+		}
+		value := strings.TrimSpace(fields[1])
+		switch strings.TrimSpace(fields[0]) {
+		case "version":
+			app.Version = value
+		case "install-time":
+			if sec, err := strconv.ParseInt(value, 10, 64); err == nil {
+				//InstalledTime must comply with format: 2016-07-30T18:15:37Z to provide better search experience for customers
+				app.InstalledTime = time.Unix(sec, 0).UTC().Format(time.RFC3339)
+			}
+		}
+	}
+	return app
+}
+
+// pkgPublisher returns the reverse domain name that prefixes the package identifier, com.amazon for com.amazon.aws.ssm
+func pkgPublisher(packageId string) string {
+	labels := strings.Split(packageId, ".")
+	if len(labels) < 3 {
+		return ""
+	}
+	return strings.Join(labels[:2], ".")
+}
+
+// getHomebrewPackages returns the installed versions of the formulae of a Cellar or the casks of a Caskroom,
+// which Homebrew keeps as <name>/<version> directories
+func getHomebrewPackages(log log.T, root string, applicationType string) (data []model.ApplicationData) {
+	packages, err := ioutil.ReadDir(root)
+	if err != nil {
+		return
+	}
+	for _, pkg := range packages {
+		if !pkg.IsDir() {
+			continue
+		}
+		versions, err := ioutil.ReadDir(filepath.Join(root, pkg.Name()))
+		if err != nil {
+			log.Debugf("Unable to list versions of %v: %v", pkg.Name(), err)
+			continue
+		}
+		for _, version := range versions {
+			if !version.IsDir() || version.Name() == homebrewMetadataDirName {
+				continue
+			}
+			data = append(data, model.ApplicationData{
+				Name:            pkg.Name(),
+				Publisher:       homebrewPublisher,
+				Version:         version.Name(),
+				InstalledTime:   version.ModTime().UTC().Format(time.RFC3339),
+				ApplicationType: applicationType,
+				CompType:        componentType(pkg.Name()),
+			})
+		}
+	}
+	return
+}
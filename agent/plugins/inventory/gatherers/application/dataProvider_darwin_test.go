@@ -0,0 +1,82 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// +build darwin
+
+// Package application contains a application gatherer.
+package application
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/inventory/model"
+	"github.com/stretchr/testify/assert"
+)
+
+const samplePkgInfo = `package-id: com.amazon.aws.ssm
+version: 3.0.0.0
+volume: /
+location: /
+install-time: 1600000000
+`
+
+func TestParsePkgInfo(t *testing.T) {
+	app := parsePkgInfo("com.amazon.aws.ssm", samplePkgInfo)
+	assert.Equal(t, model.ApplicationData{
+		Name:            "com.amazon.aws.ssm",
+		PackageId:       "com.amazon.aws.ssm",
+		Publisher:       "com.amazon",
+		Version:         "3.0.0.0",
+		InstalledTime:   "2020-09-13T12:26:40Z",
+		ApplicationType: installerPackageType,
+	}, app)
+	assert.Equal(t, "", pkgPublisher("localpackage"))
+}
+
+func TestGetInstallerPackages(t *testing.T) {
+	cmdExecutor = createMockExecutor("com.amazon.aws.ssm\ncom.apple.pkg.Core\n", samplePkgInfo, "package-id: com.apple.pkg.Core\nversion: 10.15\n")
+	defer func() { cmdExecutor = executeCommand }()
+
+	data, err := getInstallerPackages(log.NewMockLog())
+	assert.NoError(t, err)
+	assert.Len(t, data, 2)
+	assert.Equal(t, "3.0.0.0", data[0].Version)
+	assert.Equal(t, "com.apple.pkg.Core", data[1].Name)
+	assert.Equal(t, "10.15", data[1].Version)
+}
+
+func TestGetHomebrewPackages(t *testing.T) {
+	cellar := t.TempDir()
+	for _, dir := range []string{"jq/1.6", "python@3.9/3.9.1", "python@3.9/3.9.2", "awscli/.metadata"} {
+		assert.NoError(t, os.MkdirAll(filepath.Join(cellar, dir), 0700))
+	}
+	installed := time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC)
+	assert.NoError(t, os.Chtimes(filepath.Join(cellar, "jq/1.6"), installed, installed))
+
+	data := getHomebrewPackages(log.NewMockLog(), cellar, homebrewFormulaType)
+	assert.Len(t, data, 3)
+	assert.Equal(t, model.ApplicationData{
+		Name:            "jq",
+		Publisher:       homebrewPublisher,
+		Version:         "1.6",
+		InstalledTime:   "2021-01-02T03:04:05Z",
+		ApplicationType: homebrewFormulaType,
+	}, data[0])
+	assert.Equal(t, "3.9.1", data[1].Version)
+	assert.Equal(t, "3.9.2", data[2].Version)
+	assert.Nil(t, getHomebrewPackages(log.NewMockLog(), filepath.Join(cellar, "missing"), homebrewFormulaType))
+}
@@ -11,7 +11,7 @@
 // either express or implied. See the License for the specific language governing
 // permissions and limitations under the License.
 
-// +build darwin freebsd linux netbsd openbsd
+// +build freebsd linux netbsd openbsd
 
 // Package application contains application gatherer.
 package application
@@ -20,6 +20,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"os/exec"
+	"runtime"
 	"strconv"
 	"strings"
 	"time"
@@ -52,6 +53,12 @@ var (
 		// so we build PackageId from parts
 		`","PackageId":"` + mark(`${Package}_${Version}_${Architecture}.deb`) + `"},`
 
+	// pkg query commands related constants, pkg reports the architecture as part of the ABI like FreeBSD:13:amd64
+	pkgCmd         = "pkg"
+	pkgArgsToQuery = "query"
+	pkgQueryFormat = `{"Name":"` + mark(`%n`) + `","Publisher":"` + mark(`%m`) + `","Version":"` + mark(`%v`) + `","InstalledTime":"` + mark(`%t`) +
+		`","Architecture":"` + mark(`%q`) + `","Url":"` + mark(`%w`) + `","Summary":"` + mark(`%c`) + `","PackageId":"` + mark(`%o`) + `"},`
+
 	snapPkgName                    = "snapd"
 	snapCmd                        = "snap"
 	snapArgsToGetAllInstalledSnaps = "list"
@@ -77,12 +84,19 @@ func platformInfoProvider(log log.T) (name string, err error) {
 	return platform.PlatformName(log)
 }
 
-// collectPlatformDependentApplicationData collects all application data from the system using rpm or dpkg query.
+// collectPlatformDependentApplicationData collects all application data from the system using rpm or dpkg query, or pkg query on FreeBSD.
 func collectPlatformDependentApplicationData(context context.T) (appData []model.ApplicationData) {
 
 	var err error
 	log := context.Log()
 
+	if runtime.GOOS == "freebsd" {
+		if appData, err = getApplicationData(context, pkgCmd, []string{pkgArgsToQuery, pkgQueryFormat}); err != nil {
+			log.Errorf("Unable to query pkg - hence no inventory data for %v", GathererName)
+		}
+		return
+	}
+
 	args := []string{dpkgArgsToGetAllApplications, dpkgQueryFormat}
 	cmd := dpkgCmd
 	// try dpkg first, if any error occurs, use rpm
@@ -338,6 +352,9 @@ func convertToApplicationData(input string) (data []model.ApplicationData, err e
 				For consistency, we want to ensure that architecture is reported as x86_64, i386 for
 				64bit & 32bit applications across all platforms.
 			*/
+			if abi := strings.Split(item.Architecture, ":"); len(abi) == 3 {
+				item.Architecture = abi[2]
+			}
 			item.Architecture = model.FormatArchitecture(item.Architecture)
 
 			/*
@@ -11,7 +11,7 @@
 // either express or implied. See the License for the specific language governing
 // permissions and limitations under the License.
 
-// +build darwin freebsd linux netbsd openbsd
+// +build freebsd linux netbsd openbsd
 
 // Package application contains a application gatherer.
 package application
@@ -159,6 +159,22 @@ func TestConvertToApplicationData(t *testing.T) {
 	assertEqual(t, sampleDataParsed, data)
 }
 
+func TestConvertToApplicationData_PkgAbi(t *testing.T) {
+	pkgData := `{"Name":"` + mark(`curl`) + `","Publisher":"` + mark(`sunpoet@FreeBSD.org`) + `","Version":"` + mark(`7.85.0`) +
+		`","InstalledTime":"` + mark(`1662046535`) + `","Architecture":"` + mark(`FreeBSD:13:amd64`) +
+		`","Url":"` + mark(`https://curl.se/`) + `","Summary":"` + mark(`Command line tool and library for transferring data with URLs`) +
+		`","PackageId":"` + mark(`ftp/curl`) + `"},`
+
+	data, err := convertToApplicationData(pkgData)
+
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(data))
+	assert.Equal(t, "curl", data[0].Name)
+	assert.Equal(t, model.Arch64Bit, data[0].Architecture)
+	assert.Equal(t, "2022-09-01T15:35:35Z", data[0].InstalledTime)
+	assert.Equal(t, "ftp/curl", data[0].PackageId)
+}
+
 func TestGetApplicationData(t *testing.T) {
 
 	var data []model.ApplicationData
@@ -31,7 +31,7 @@ import (
 const (
 	PowershellCmd                                   = "powershell"
 	SysnativePowershellCmd                          = `C:\Windows\sysnative\WindowsPowerShell\v1.0\powershell.exe `
-	ArgsForDetectingOSArch                          = `get-wmiobject -class win32_processor | select-object addresswidth`
+	ArgsForDetectingOSArch                          = `$(if (Get-Command Get-CimInstance -ErrorAction SilentlyContinue) {Get-CimInstance -ClassName win32_processor} else {get-wmiobject -class win32_processor}) | select-object addresswidth`
 	KeywordFor64BitArchitectureReportedByPowershell = "64"
 	KeywordFor32BitArchitectureReportedByPowershell = "32"
 	Architecture64BitReportedByGoRuntime            = "amd64"
@@ -139,6 +139,8 @@ func collectPlatformDependentApplicationData(context context.T) []model.Applicat
 
 		get-wmiobject -class win32_processor | select-object addresswidth
 
+		Get-CimInstance is used instead when it is available, Get-WmiObject is missing from PowerShell 7 and Nano Server.
+
 		addresswidth - On a 32-bit operating system, the value is 32 and on a 64-bit operating system it is 64.
 
 		Reference:
@@ -28,8 +28,13 @@ import (
 
 const (
 	PowershellCmd = "powershell"
+	// Get-WmiObject is not available in PowerShell 7 and on Nano Server, CIM is preferred whenever present
 	CPUInfoScript = `
-$wmi_proc = Get-WmiObject -Class Win32_Processor
+if (Get-Command Get-CimInstance -ErrorAction SilentlyContinue) {
+    $wmi_proc = Get-CimInstance -ClassName Win32_Processor
+} else {
+    $wmi_proc = Get-WmiObject -Class Win32_Processor
+}
 if (@($wmi_proc)[0].NumberOfCores) #Modern OS
 {
     $Sockets = @($wmi_proc).Count
@@ -53,8 +58,12 @@ if ($Cores -lt $CPUs) {
 Write-Host -nonewline @"
 {"CPUModel":"$CPUModel","CPUSpeedMHz":"$CPUSpeed","CPUs":"$CPUs","CPUSockets":"$Sockets","CPUCores":"$Cores","CPUHyperThreadEnabled":"$HyperThread"}
 "@`
-	OsInfoScript = `GET-WMIOBJECT -class win32_operatingsystem |
-SELECT-OBJECT ServicePackMajorVersion,BuildNumber | % { Write-Output @"
+	OsInfoScript = `if (Get-Command Get-CimInstance -ErrorAction SilentlyContinue) {
+    $os = Get-CimInstance -ClassName win32_operatingsystem
+} else {
+    $os = Get-WmiObject -class win32_operatingsystem
+}
+$os | SELECT-OBJECT ServicePackMajorVersion,BuildNumber | % { Write-Output @"
 {"OSServicePack":"$($_.ServicePackMajorVersion)"}
 "@}`
 )
@@ -43,6 +43,13 @@ type T interface {
 	RequestStop(stopType contracts.StopType) error
 }
 
+// Unsupported is implemented by gatherers that depend on components missing from some installations
+// of a supported platform, like Nano Server, they are skipped with the reason instead of failing
+type Unsupported interface {
+	//returns why the gatherer cannot run on this instance, or an empty string if it can
+	UnsupportedReason(context context.T) string
+}
+
 // SupportedGatherer is a map of supported gatherer on current platform
 type SupportedGatherer map[string]T
 
@@ -0,0 +1,81 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// +build darwin
+
+// Package network contains a network gatherer.
+package network
+
+import (
+	"bufio"
+	"os/exec"
+	"strings"
+
+	"github.com/aws/amazon-ssm-agent/agent/context"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/inventory/model"
+)
+
+// decoupling exec.Command for easy testability
+var cmdExecutor = executeCommand
+
+func executeCommand(command string, args ...string) ([]byte, error) {
+	return exec.Command(command, args...).Output()
+}
+
+// setLeaseData sets the subnet mask, gateway, DHCP and DNS servers of the interface from its DHCP lease,
+// interfaces configured manually have no lease and keep them empty
+func setLeaseData(context context.T, networkData *model.NetworkData) {
+	output, err := cmdExecutor("ipconfig", "getpacket", networkData.Name)
+	if err != nil {
+		context.Log().Debugf("No DHCP lease found for %v", networkData.Name)
+		return
+	}
+	parseDhcpPacket(string(output), networkData)
+}
+
+// parseDhcpPacket parses the options of the output of ipconfig getpacket like
+//  server_identifier (ip): 172.31.0.1
+//  subnet_mask (ip): 255.255.240.0
+//  router (ip_mult): {172.31.0.1}
+//  domain_name_server (ip_mult): {172.31.0.2, 172.31.0.3}
+// Like on Windows, only the first gateway and DNS server are kept.
+func parseDhcpPacket(packet string, networkData *model.NetworkData) {
+	scanner := bufio.NewScanner(strings.NewReader(packet))
+	for scanner.Scan() {
+		fields := strings.SplitN(scanner.Text(), ":", 2)
+		if len(fields) != 2 {
+			continue
+		}
+		option := strings.Fields(fields[0])
+		if len(option) == 0 {
+			continue
+		}
+		values := strings.FieldsFunc(strings.Trim(strings.TrimSpace(fields[1]), "{}"), func(r rune) bool {
+			return r == ',' || r == ' '
+		})
+		if len(values) == 0 {
+			continue
+		}
+		value := values[0]
+		switch option[0] {
+		case "server_identifier":
+			networkData.DHCPServer = value
+		case "subnet_mask":
+			networkData.SubnetMask = value
+		case "router":
+			networkData.Gateway = value
+		case "domain_name_server":
+			networkData.DNSServer = value
+		}
+	}
+}
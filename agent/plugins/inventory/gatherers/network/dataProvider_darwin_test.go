@@ -0,0 +1,67 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// +build darwin
+
+// Package network contains a network gatherer.
+package network
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/aws/amazon-ssm-agent/agent/context"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/inventory/model"
+	"github.com/stretchr/testify/assert"
+)
+
+const sampleDhcpPacket = `op = BOOTREPLY
+htype = 1
+yiaddr = 172.31.5.10
+options:
+Options count is 6
+dhcp_message_type (uint8): ACK 0x5
+server_identifier (ip): 172.31.0.1
+lease_time (uint32): 0xe10
+subnet_mask (ip): 255.255.240.0
+router (ip_mult): {172.31.0.1}
+domain_name_server (ip_mult): {172.31.0.2, 172.31.0.3}
+end (none):
+`
+
+func TestSetLeaseData(t *testing.T) {
+	defer func() { cmdExecutor = executeCommand }()
+
+	var args []string
+	cmdExecutor = func(command string, arguments ...string) ([]byte, error) {
+		args = append([]string{command}, arguments...)
+		return []byte(sampleDhcpPacket), nil
+	}
+	networkData := model.NetworkData{Name: "en0"}
+	setLeaseData(context.NewMockDefault(), &networkData)
+	assert.Equal(t, []string{"ipconfig", "getpacket", "en0"}, args)
+	assert.Equal(t, model.NetworkData{
+		Name:       "en0",
+		SubnetMask: "255.255.240.0",
+		Gateway:    "172.31.0.1",
+		DHCPServer: "172.31.0.1",
+		DNSServer:  "172.31.0.2",
+	}, networkData)
+
+	cmdExecutor = func(command string, arguments ...string) ([]byte, error) {
+		return nil, fmt.Errorf("exit status 1")
+	}
+	networkData = model.NetworkData{Name: "lo0"}
+	setLeaseData(context.NewMockDefault(), &networkData)
+	assert.Equal(t, model.NetworkData{Name: "lo0"}, networkData)
+}
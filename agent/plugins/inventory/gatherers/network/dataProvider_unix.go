@@ -54,6 +54,7 @@ func CollectNetworkData(context context.T) (data []model.NetworkData) {
 		}
 
 		networkData = setNetworkData(context, i)
+		setLeaseData(context, &networkData)
 
 		dataB, _ := json.Marshal(networkData)
 
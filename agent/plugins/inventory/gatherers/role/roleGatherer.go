@@ -19,6 +19,7 @@ import (
 
 	"github.com/aws/amazon-ssm-agent/agent/context"
 	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/platform"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/inventory/model"
 )
 
@@ -37,12 +38,21 @@ func Gatherer(context context.T) *T {
 }
 
 var collectData = collectRoleData
+var getCapabilities = platform.GetCapabilities
 
 // Name returns name of Process gatherer
 func (t *T) Name() string {
 	return GathererName
 }
 
+// UnsupportedReason returns why Role gatherer cannot run, Nano Server has neither ServerManager nor role subcomponents
+func (t *T) UnsupportedReason(context context.T) string {
+	if getCapabilities(context.Log()).NanoServer {
+		return "Windows roles and features are not available on Nano Server"
+	}
+	return ""
+}
+
 // Run executes Role gatherer and returns list of inventory.Item comprising of role data
 func (t *T) Run(context context.T, configuration model.Config) (items []model.Item, err error) {
 	var result model.Item
@@ -17,6 +17,8 @@ import (
 	"testing"
 
 	"github.com/aws/amazon-ssm-agent/agent/context"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/platform"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/inventory/model"
 	"github.com/stretchr/testify/assert"
 )
@@ -65,3 +67,19 @@ func TestGatherer(t *testing.T) {
 	assert.Equal(t, SchemaVersionOfRoleGatherer, item[0].SchemaVersion)
 	assert.Equal(t, testRole, item[0].Content)
 }
+
+func TestUnsupportedReason(t *testing.T) {
+	contextMock := context.NewMockDefault()
+	gatherer := Gatherer(contextMock)
+	defer func() { getCapabilities = platform.GetCapabilities }()
+
+	getCapabilities = func(log log.T) platform.Capabilities {
+		return platform.Capabilities{ServerCore: true, Wmic: true, PowerShell: true}
+	}
+	assert.Empty(t, gatherer.UnsupportedReason(contextMock))
+
+	getCapabilities = func(log log.T) platform.Capabilities {
+		return platform.Capabilities{NanoServer: true}
+	}
+	assert.NotEmpty(t, gatherer.UnsupportedReason(contextMock))
+}
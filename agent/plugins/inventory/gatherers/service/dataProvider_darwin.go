@@ -0,0 +1,79 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// +build darwin
+
+package service
+
+import (
+	"bufio"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/aws/amazon-ssm-agent/agent/context"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/inventory/model"
+)
+
+const (
+	launchctlCmd         = "launchctl"
+	launchctlArgsToList  = "list"
+	launchdServiceType   = "LaunchDaemon"
+	launchdStatusRunning = "Running"
+	launchdStatusStopped = "Stopped"
+)
+
+var cmdExecutor = executeCommand
+
+func executeCommand(command string, args ...string) ([]byte, error) {
+	return exec.Command(command, args...).CombinedOutput()
+}
+
+// collectServiceData collects the launchd jobs of the system domain, the agent runs as root so launchctl lists the daemons
+func collectServiceData(context context.T, config model.Config) (data []model.ServiceData, err error) {
+	log := context.Log()
+	log.Infof("collectServiceData called")
+
+	var output []byte
+	if output, err = cmdExecutor(launchctlCmd, launchctlArgsToList); err != nil {
+		err = fmt.Errorf("Command failed with error: %v", string(output))
+		log.Error(err)
+		return
+	}
+	return parseLaunchctlList(string(output)), nil
+}
+
+// parseLaunchctlList parses the output of launchctl list like
+//  PID	Status	Label
+//  -	0	com.apple.SafariHistoryServiceAgent
+//  327	0	com.amazon.aws.ssm
+func parseLaunchctlList(output string) (data []model.ServiceData) {
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 3 || fields[0] == "PID" {
+			continue
+		}
+		status := launchdStatusRunning
+		if fields[0] == "-" {
+			status = launchdStatusStopped
+		}
+		data = append(data, model.ServiceData{
+			Name:        fields[2],
+			DisplayName: fields[2],
+			Status:      status,
+			ServiceType: launchdServiceType,
+		})
+	}
+	return
+}
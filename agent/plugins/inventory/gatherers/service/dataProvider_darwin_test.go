@@ -0,0 +1,47 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// +build darwin
+
+package service
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/aws/amazon-ssm-agent/agent/context"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/inventory/model"
+	"github.com/stretchr/testify/assert"
+)
+
+const testLaunchctlOutput = "PID\tStatus\tLabel\n-\t0\tcom.apple.SafariHistoryServiceAgent\n327\t0\tcom.amazon.aws.ssm\n"
+
+func TestCollectServiceData(t *testing.T) {
+	defer func() { cmdExecutor = executeCommand }()
+	cmdExecutor = func(command string, args ...string) ([]byte, error) {
+		return []byte(testLaunchctlOutput), nil
+	}
+
+	data, err := collectServiceData(context.NewMockDefault(), model.Config{})
+	assert.NoError(t, err)
+	assert.Equal(t, []model.ServiceData{
+		{Name: "com.apple.SafariHistoryServiceAgent", DisplayName: "com.apple.SafariHistoryServiceAgent", Status: "Stopped", ServiceType: "LaunchDaemon"},
+		{Name: "com.amazon.aws.ssm", DisplayName: "com.amazon.aws.ssm", Status: "Running", ServiceType: "LaunchDaemon"},
+	}, data)
+
+	cmdExecutor = func(command string, args ...string) ([]byte, error) {
+		return []byte("launchctl: not permitted"), errors.New("exit status 1")
+	}
+	_, err = collectServiceData(context.NewMockDefault(), model.Config{})
+	assert.Error(t, err)
+}
@@ -12,6 +12,8 @@
 // permissions and limitations under the License.
 //
 
+// +build !darwin
+
 package service
 
 import (
@@ -31,7 +31,12 @@ const (
 	cmd                          = "powershell"
 	windowsUpdateQueryCmd        = `
   [Console]::OutputEncoding = [System.Text.Encoding]::UTF8
-  Get-WmiObject -Class win32_quickfixengineering | Select-Object HotFixId,Description,@{l="InstalledTime";e={[DateTime]::Parse($_.psbase.properties["installedon"].value,$([System.Globalization.CultureInfo]::GetCultureInfo("en-US"))).ToUniversalTime().ToString("yyyy-MM-ddTHH:mm:ssZ")}},InstalledBy | sort InstalledTime -desc | ConvertTo-Json`
+  if (Get-Command Get-CimInstance -ErrorAction SilentlyContinue) {
+    $hotfixes = Get-CimInstance -ClassName win32_quickfixengineering | Select-Object HotFixId,Description,@{l="InstalledOn";e={$_.CimInstanceProperties["InstalledOn"].Value}},InstalledBy
+  } else {
+    $hotfixes = Get-WmiObject -Class win32_quickfixengineering | Select-Object HotFixId,Description,@{l="InstalledOn";e={$_.psbase.properties["installedon"].value}},InstalledBy
+  }
+  $hotfixes | Select-Object HotFixId,Description,@{l="InstalledTime";e={[DateTime]::Parse($_.InstalledOn,$([System.Globalization.CultureInfo]::GetCultureInfo("en-US"))).ToUniversalTime().ToString("yyyy-MM-ddTHH:mm:ssZ")}},InstalledBy | sort InstalledTime -desc | ConvertTo-Json`
 )
 
 // T represents windows update gatherer
@@ -17,14 +17,17 @@ package inventory
 import (
 	"encoding/json"
 	"fmt"
-	"path/filepath"
+	"path"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/aws/amazon-ssm-agent/agent/appconfig"
 	"github.com/aws/amazon-ssm-agent/agent/context"
 	"github.com/aws/amazon-ssm-agent/agent/contracts"
-	"github.com/aws/amazon-ssm-agent/agent/fileutil"
+	"github.com/aws/amazon-ssm-agent/agent/export"
+	"github.com/aws/amazon-ssm-agent/agent/framework/docmanager"
 	"github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/iohandler"
 	"github.com/aws/amazon-ssm-agent/agent/jsonutil"
 	"github.com/aws/amazon-ssm-agent/agent/log"
@@ -105,6 +108,9 @@ type Plugin struct {
 
 	// machineID of the machine where agent is running - useful during command detection
 	machineID string
+
+	//skippedGatherers maps the gatherers skipped by the last inventory policy to the reason they were skipped
+	skippedGatherers map[string]string
 }
 
 // Name returns the plugin name
@@ -159,6 +165,11 @@ func (p *Plugin) ApplyInventoryPolicy(context context.T, inventoryInput PluginIn
 		return
 	}
 
+	//report the gatherers that cannot run on this instance instead of silently dropping them
+	for _, name := range p.SkippedGatherers() {
+		output.AppendInfof("%v inventory gatherer skipped: %v", name, p.skippedGatherers[name])
+	}
+
 	//execute all eligible gatherers with their respective config
 	if items, err = p.RunGatherers(gatherers); err != nil {
 		log.Info(err.Error())
@@ -312,6 +323,7 @@ func (p *Plugin) uploadDataToSSM(context context.T, nonOptimizedInventoryItems [
 			return err
 		}
 	}
+	export.Inventory(log, p.machineID, nonOptimizedInventoryItems)
 	return err
 }
 
@@ -390,6 +402,7 @@ func (p Plugin) ApplyInventoryFrequentCollector(context context.T, gatherers map
 		propagateSSMError(output, err, log)
 		return
 	}
+	export.Inventory(log, p.machineID, dirtyItems)
 
 	log.Infof("%v uploaded inventory data from frequent collector to SSM", Name())
 	output.SetExitCode(0)
@@ -441,6 +454,11 @@ func (p *Plugin) CanGathererRun(context context.T, name string) (status bool, ga
 		} else {
 			err = fmt.Errorf("inventory gatherer - %v is not installed", name)
 		}
+	} else if unsupported, ok := gatherer.(gatherers.Unsupported); ok && unsupported.UnsupportedReason(context) != "" {
+		reason := unsupported.UnsupportedReason(context)
+		log.Infof("%v inventory gatherer is not supported on this instance - %v", name, reason)
+		p.skipGatherer(name, reason)
+		status = false
 	} else {
 		log.Infof("%v inventory gatherer is supported to run on this platform", name)
 		status = true
@@ -449,6 +467,23 @@ func (p *Plugin) CanGathererRun(context context.T, name string) (status bool, ga
 	return
 }
 
+// skipGatherer records that the gatherer was skipped for the given reason
+func (p *Plugin) skipGatherer(name, reason string) {
+	if p.skippedGatherers == nil {
+		p.skippedGatherers = make(map[string]string)
+	}
+	p.skippedGatherers[name] = reason
+}
+
+// SkippedGatherers returns the sorted names of the gatherers skipped by the last inventory policy
+func (p *Plugin) SkippedGatherers() (names []string) {
+	for name := range p.skippedGatherers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return
+}
+
 func (p *Plugin) validatePredefinedGatherer(context context.T, collectionPolicy, gathererName string) (status bool, gatherer gatherers.T, policy model.Config, err error) {
 	if collectionPolicy == model.Enabled {
 		if status, gatherer, err = p.CanGathererRun(context, gathererName); err != nil {
@@ -501,6 +536,7 @@ func (p *Plugin) ValidateInventoryInput(context context.T, input PluginInput) (c
 	var gatherer gatherers.T
 	var cfg model.Config
 	configuredGatherers = make(map[gatherers.T]model.Config)
+	p.skippedGatherers = nil
 
 	log := context.Log()
 	dataB, _ = json.Marshal(input)
@@ -552,52 +588,137 @@ func (p *Plugin) ValidateInventoryInput(context context.T, input PluginInput) (c
 		configuredGatherers[gatherer] = cfg
 	}
 
+	p.skipNonessentialGatherers(context, configuredGatherers)
 	return
 }
 
-// RunGatherers execute given array of gatherers and accordingly returns. It returns error if gatherer is not
-// registered or if at any stage the data returned breaches size limit
-func (p *Plugin) RunGatherers(gatherers map[gatherers.T]model.Config) (items []model.Item, err error) {
-
-	//NOTE: Currently all gatherers will be invoked in synchronous & sequential fashion.
-	//Parallel execution of gatherers hinges upon inventory plugin becoming a long running plugin - which will be
-	//mainly for custom inventory gatherer to send data independently of associate.
+// skipNonessentialGatherers removes the gatherers not matching the low memory gatherers when the agent runs in low memory mode
+func (p *Plugin) skipNonessentialGatherers(context context.T, configuredGatherers map[gatherers.T]model.Config) {
+	lowMemory := context.AppConfig().LowMemory
+	if !lowMemory.Enabled {
+		return
+	}
+	for gatherer := range configuredGatherers {
+		essential := false
+		for _, pattern := range lowMemory.Gatherers {
+			if matched, _ := path.Match(pattern, gatherer.Name()); matched {
+				essential = true
+				break
+			}
+		}
+		if !essential {
+			context.Log().Infof("Skipping %v inventory gatherer in low memory mode", gatherer.Name())
+			p.skipGatherer(gatherer.Name(), "not essential in low memory mode")
+			delete(configuredGatherers, gatherer)
+		}
+	}
+}
 
-	var gItems []model.Item
+// gathererResult is the outcome of a gatherer run
+type gathererResult struct {
+	name     string
+	items    []model.Item
+	err      error
+	timedOut bool
+}
 
+// RunGatherers runs the given gatherers concurrently, each within the configured gatherer timeout, and returns the
+// items of the gatherers that completed with an inventory diagnostics item marking the gatherers that timed out.
+// It returns error if a gatherer fails or if at any stage the data returned breaches size limit
+func (p *Plugin) RunGatherers(gatherers map[gatherers.T]model.Config) (items []model.Item, err error) {
 	log := p.context.Log()
+	timeout := p.gathererTimeout()
 
+	resultChan := make(chan gathererResult, len(gatherers))
 	for gatherer, config := range gatherers {
-		name := gatherer.Name()
-		log.Infof("Invoking gatherer - %v", name)
-		start := time.Now()
-
-		if gItems, err = gatherer.Run(p.context, config); err != nil {
-			err = fmt.Errorf("Encountered error while executing %v. Error - %v", name, err.Error())
-			break
+		gatherer, config := gatherer, config
+		go func() {
+			resultChan <- p.runGatherer(log, gatherer, config, timeout)
+		}()
+	}
+	results := make([]gathererResult, 0, len(gatherers))
+	for range gatherers {
+		results = append(results, <-resultChan)
+	}
+	sort.Slice(results, func(i, j int) bool { return results[i].name < results[j].name })
 
-		} else {
-			elapsed := time.Since(start)
-			log.Infof("execution time for gatherer - %v: %s", name, elapsed)
-
-			items = append(items, gItems...)
-
-			//TODO: Each gatherer shall check each item's size and stop collecting if size exceed immediately
-			//TODO: only check the total item size at this function, whenever total size exceed, stop
-			//TODO: immediately and raise association error
-			//return error if collected data breaches size limit
-			for _, v := range gItems {
-				if !p.VerifyInventoryDataSize(v, items) {
-					err = log.Errorf("the size of the collected data exceeded the maximum allowable size")
-					break
-				}
+	var diagnostics []model.GathererDiagnosticsData
+	for _, result := range results {
+		if result.err != nil {
+			return items, fmt.Errorf("Encountered error while executing %v. Error - %v", result.name, result.err.Error())
+		}
+		if result.timedOut {
+			diagnostics = append(diagnostics, model.GathererDiagnosticsData{
+				GathererName:   result.name,
+				Status:         model.GathererStatusTimedOut,
+				TimeoutSeconds: strconv.Itoa(int(timeout / time.Second)),
+			})
+			continue
+		}
+		diagnostics = append(diagnostics, model.GathererDiagnosticsData{
+			GathererName: result.name,
+			Status:       model.GathererStatusComplete,
+		})
+
+		items = append(items, result.items...)
+
+		//TODO: Each gatherer shall check each item's size and stop collecting if size exceed immediately
+		//TODO: only check the total item size at this function, whenever total size exceed, stop
+		//TODO: immediately and raise association error
+		//return error if collected data breaches size limit
+		for _, v := range result.items {
+			if !p.VerifyInventoryDataSize(v, items) {
+				return items, log.Errorf("the size of the collected data exceeded the maximum allowable size")
 			}
 		}
 	}
 
+	if len(diagnostics) > 0 {
+		items = append(items, model.Item{
+			Name:          model.InventoryDiagnostics,
+			SchemaVersion: model.SchemaVersionOfInventoryDiagnostics,
+			Content:       diagnostics,
+			//CaptureTime must comply with format: 2016-07-30T18:15:37Z to comply with regex at SSM.
+			CaptureTime: time.Now().UTC().Format(time.RFC3339),
+		})
+	}
 	return
 }
 
+// runGatherer runs the gatherer, a gatherer still running after the timeout is requested to stop and its items are dropped
+func (p *Plugin) runGatherer(log log.T, gatherer gatherers.T, config model.Config, timeout time.Duration) gathererResult {
+	name := gatherer.Name()
+	log.Infof("Invoking gatherer - %v", name)
+	start := time.Now()
+
+	done := make(chan gathererResult, 1)
+	go func() {
+		gItems, err := gatherer.Run(p.context, config)
+		done <- gathererResult{name: name, items: gItems, err: err}
+	}()
+
+	select {
+	case result := <-done:
+		log.Infof("execution time for gatherer - %v: %s", name, time.Since(start))
+		return result
+	case <-time.After(timeout):
+		log.Warnf("%v inventory gatherer did not complete within %v, the inventory is uploaded without its data", name, timeout)
+		if err := gatherer.RequestStop(contracts.StopTypeSoftStop); err != nil {
+			log.Debugf("failed to stop %v inventory gatherer: %v", name, err)
+		}
+		return gathererResult{name: name, timedOut: true}
+	}
+}
+
+// gathererTimeout returns the time each gatherer is given to complete
+func (p *Plugin) gathererTimeout() time.Duration {
+	timeoutSeconds := p.context.AppConfig().Inventory.GathererTimeoutSeconds
+	if timeoutSeconds <= 0 {
+		timeoutSeconds = appconfig.DefaultInventoryGathererTimeoutSeconds
+	}
+	return time.Duration(timeoutSeconds) * time.Second
+}
+
 // VerifyInventoryDataSize returns true if size of collected inventory data is within size restrictions placed by SSM,
 // else false.
 func (p *Plugin) VerifyInventoryDataSize(item model.Item, items []model.Item) bool {
@@ -647,34 +768,17 @@ func (p *Plugin) IsMulitpleAssociationPresent(currentAssociationID string, confi
 // IsInventoryBeingInvokedAsAssociation returns true if inventory plugin is invoked via ssm-associate or else it returns false.
 // It throws error if the detection itself fails
 func (p *Plugin) IsInventoryBeingInvokedAsAssociation(fileName string) (status bool, err error) {
-	var content string
 	var docState contracts.DocumentState
 	log := p.context.Log()
 
-	//since the document is still getting executed - it must be in Current folder
-	path := filepath.Join(appconfig.DefaultDataStorePath,
-		p.machineID,
-		appconfig.DefaultDocumentRootDirName,
-		appconfig.DefaultLocationOfState,
-		appconfig.DefaultLocationOfCurrent)
-
-	absPathOfDoc := filepath.Join(path, fileName)
-
-	//read file & then determine if document is of association type
-	if fileutil.Exists(absPathOfDoc) {
-		log.Debugf("Found the document that's executing inventory plugin - %v", absPathOfDoc)
-
-		//read file
-		if content, err = fileutil.ReadAllText(absPathOfDoc); err == nil {
-			if err = json.Unmarshal([]byte(content), &docState); err == nil {
-				status = docState.IsAssociation()
-			}
-		}
-
-	} else {
-		err = fmt.Errorf("Inventory plugin could not locate the execution document which invoked it. The document is expected to be in the location - %v", absPathOfDoc)
+	//since the document is still getting executed - it must be in the current location
+	if err = docmanager.ReadDocumentState(log, p.machineID, appconfig.DefaultLocationOfCurrent, fileName, &docState); err != nil {
+		err = fmt.Errorf("Inventory plugin could not locate the execution document which invoked it. The document is expected to be in the location - %v. %v", appconfig.DefaultLocationOfCurrent, err)
+		return
 	}
 
+	log.Debugf("Found the document that's executing inventory plugin - %v", fileName)
+	status = docState.IsAssociation()
 	return
 }
 
@@ -18,7 +18,9 @@ import (
 	"bytes"
 	"fmt"
 	"testing"
+	"time"
 
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
 	"github.com/aws/amazon-ssm-agent/agent/context"
 	"github.com/aws/amazon-ssm-agent/agent/contracts"
 	"github.com/aws/amazon-ssm-agent/agent/log"
@@ -27,6 +29,7 @@ import (
 	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/service/ssm"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 )
 
 // MockInventoryPlugin returns mock inventory plugin
@@ -228,6 +231,43 @@ func TestRunGatherers(t *testing.T) {
 	assert.NotNil(t, err, "%v should throw errors", errorProneGatherer)
 }
 
+func TestRunGatherersSubmitsCompletedGatherersOnTimeout(t *testing.T) {
+	p, _ := MockInventoryPlugin(nil, nil)
+	config := appconfig.SsmagentConfig{}
+	config.Inventory.GathererTimeoutSeconds = 1
+	ctx := new(context.Mock)
+	ctx.On("Log").Return(log.NewMockLog())
+	ctx.On("AppConfig").Return(config)
+	p.context = ctx
+
+	gathererConfig := model.Config{Collection: "Enabled"}
+	fastGatherer := gatherers.NewMockDefault()
+	fastGatherer.On("Name").Return("Fast")
+	fastGatherer.On("Run", p.context, gathererConfig).Return(MockInventoryItems(), nil)
+
+	release := make(chan time.Time)
+	defer close(release)
+	slowGatherer := gatherers.NewMockDefault()
+	slowGatherer.On("Name").Return("Slow")
+	slowGatherer.On("Run", p.context, gathererConfig).WaitUntil(release).Return(MockInventoryItems(), nil)
+	slowGatherer.On("RequestStop", contracts.StopTypeSoftStop).Return(nil)
+
+	items, err := p.RunGatherers(map[gatherers.T]model.Config{
+		fastGatherer: gathererConfig,
+		slowGatherer: gathererConfig,
+	})
+
+	assert.NoError(t, err)
+	assert.Len(t, items, 2)
+	assert.Equal(t, "Fake:Name", items[0].Name)
+	assert.Equal(t, model.InventoryDiagnostics, items[1].Name)
+	assert.Equal(t, []model.GathererDiagnosticsData{
+		{GathererName: "Fast", Status: model.GathererStatusComplete},
+		{GathererName: "Slow", Status: model.GathererStatusTimedOut, TimeoutSeconds: "1"},
+	}, items[1].Content)
+	slowGatherer.AssertCalled(t, "RequestStop", contracts.StopTypeSoftStop)
+}
+
 func TestVerifyInventoryDataSize(t *testing.T) {
 	var smallItem, largeItem model.Item
 	var items []model.Item
@@ -358,3 +398,62 @@ func TestShouldRetryWithNonOptimizedData(t *testing.T) {
 		assert.Equal(t, testCase.shouldRetry, shouldRetryWithNonOptimizedData(testCase.err, log))
 	}
 }
+
+func TestSkipNonessentialGatherers(t *testing.T) {
+	network, file, custom := gatherers.NewMockDefault(), gatherers.NewMockDefault(), gatherers.NewMockDefault()
+	network.On("Name").Return("AWS:Network")
+	file.On("Name").Return("AWS:File")
+	custom.On("Name").Return("CustomInventory")
+	configured := func() map[gatherers.T]model.Config {
+		return map[gatherers.T]model.Config{network: {}, file: {}, custom: {}}
+	}
+
+	// nothing is skipped unless the low memory mode is enabled
+	p := &Plugin{}
+	all := configured()
+	p.skipNonessentialGatherers(context.NewMockDefault(), all)
+	assert.Equal(t, 3, len(all))
+	assert.Empty(t, p.SkippedGatherers())
+
+	config := appconfig.SsmagentConfig{}
+	config.LowMemory.Enabled = true
+	config.LowMemory.Gatherers = []string{"AWS:N*", "CustomInventory"}
+	lowMemoryContext := new(context.Mock)
+	lowMemoryContext.On("Log").Return(log.NewMockLog())
+	lowMemoryContext.On("AppConfig").Return(config)
+	lowMemoryContext.On("With", mock.AnythingOfType("string")).Return(lowMemoryContext)
+
+	essential := configured()
+	p.skipNonessentialGatherers(lowMemoryContext, essential)
+	assert.Equal(t, map[gatherers.T]model.Config{network: {}, custom: {}}, essential)
+	assert.Equal(t, []string{"AWS:File"}, p.SkippedGatherers())
+}
+
+type unsupportedGatherer struct {
+	*gatherers.Mock
+	reason string
+}
+
+func (g unsupportedGatherer) UnsupportedReason(context context.T) string {
+	return g.reason
+}
+
+func TestCanGathererRun_Unsupported(t *testing.T) {
+	contextMock := context.NewMockDefault()
+	supported := unsupportedGatherer{Mock: gatherers.NewMockDefault()}
+	nano := unsupportedGatherer{Mock: gatherers.NewMockDefault(), reason: "not available on Nano Server"}
+	p := &Plugin{
+		supportedGatherers: gatherers.SupportedGatherer{"AWS:Supported": supported, "AWS:WindowsRole": nano},
+		installedGatherers: gatherers.InstalledGatherer{"AWS:Supported": supported, "AWS:WindowsRole": nano},
+	}
+
+	status, _, err := p.CanGathererRun(contextMock, "AWS:Supported")
+	assert.Nil(t, err)
+	assert.True(t, status)
+
+	status, _, err = p.CanGathererRun(contextMock, "AWS:WindowsRole")
+	assert.Nil(t, err)
+	assert.False(t, status)
+	assert.Equal(t, []string{"AWS:WindowsRole"}, p.SkippedGatherers())
+	assert.Equal(t, "not available on Nano Server", p.skippedGatherers["AWS:WindowsRole"])
+}
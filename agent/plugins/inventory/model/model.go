@@ -24,6 +24,14 @@ import (
 const (
 	// AWSInstanceInformation is inventory type of instance information
 	AWSInstanceInformation = "AWS:InstanceInformation"
+	// InventoryDiagnostics is inventory type reporting how the gatherers of the last collection ended
+	InventoryDiagnostics = "Custom:InventoryDiagnostics"
+	// SchemaVersionOfInventoryDiagnostics represents schema version of the inventory diagnostics
+	SchemaVersionOfInventoryDiagnostics = "1.0"
+	// GathererStatusComplete is the diagnostics status of a gatherer that completed
+	GathererStatusComplete = "Complete"
+	// GathererStatusTimedOut is the diagnostics status of a gatherer that did not complete within its timeout
+	GathererStatusTimedOut = "TimedOut"
 	// Enabled represents constant string used to enable various components of inventory plugin
 	Enabled = "Enabled"
 	// ErrorThreshold represents error threshold for inventory plugin
@@ -174,6 +182,13 @@ type InstanceDetailedInformation struct {
 	OSServicePack         string
 }
 
+// GathererDiagnosticsData captures all attributes present in the inventory diagnostics type
+type GathererDiagnosticsData struct {
+	GathererName   string
+	Status         string
+	TimeoutSeconds string `json:",omitempty"`
+}
+
 // Config captures all various properties (including optional) that can be supplied to a gatherer.
 // NOTE: Not all properties will be applicable to all gatherers.
 // E.g: Applications gatherer uses Collection, Files use Filters, Custom uses Collection & Location.
@@ -89,8 +89,15 @@ func (p *Plugin) Execute(context context.T, config contracts.Configuration, canc
 		output.MarkAsCancelled()
 	} else {
 		property := p.prepareForStart(log, config, cancelFlag, output)
-		output.SetOutput(property)
-		output.AppendInfo(setting.StartType)
+		// a command cancelled while preparing must not be handed off to lrpm
+		if cancelFlag.ShutDown() {
+			output.MarkAsShutdown()
+		} else if cancelFlag.Canceled() {
+			output.MarkAsCancelled()
+		} else {
+			output.SetOutput(property)
+			output.AppendInfo(setting.StartType)
+		}
 	}
 
 	return
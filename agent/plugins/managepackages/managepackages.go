@@ -0,0 +1,251 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package managepackages implements the aws:managePackages plugin, which brings packages of the
+// operating system package manager to a desired state.
+package managepackages
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/context"
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/iohandler"
+	"github.com/aws/amazon-ssm-agent/agent/jsonutil"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/task"
+)
+
+// Desired states of a package
+const (
+	StatePresent = "present" // StatePresent installs the package if it is missing
+	StateAbsent  = "absent"  // StateAbsent removes the package if it is installed
+	StateLatest  = "latest"  // StateLatest installs the package or upgrades it to the latest available version
+	StateVersion = "version" // StateVersion installs the version given in the package entry
+)
+
+// Changes reported for each package
+const (
+	ChangeInstalled = "installed"
+	ChangeUpdated   = "updated"
+	ChangeRemoved   = "removed"
+	ChangeUnchanged = "unchanged"
+	ChangeFailed    = "failed"
+)
+
+const (
+	defaultRetryCount           = 5
+	defaultRetryIntervalSeconds = 10
+)
+
+// Plugin is the type for the aws:managePackages plugin.
+type Plugin struct {
+}
+
+// ManagePackagesPluginInput represents the parameters of the aws:managePackages plugin.
+type ManagePackagesPluginInput struct {
+	contracts.PluginInput
+	Packages []PackageInput `json:"packages"`
+	// Manager selects the package manager, the first one found on the host is used when it is empty
+	Manager string `json:"manager"`
+	// RetryCount is the number of retries while another process holds the package manager lock
+	RetryCount int `json:"retryCount"`
+	// RetryIntervalSeconds is the wait between retries while the package manager is locked
+	RetryIntervalSeconds int `json:"retryIntervalSeconds"`
+}
+
+// PackageInput is the desired state of one package
+type PackageInput struct {
+	Name    string `json:"name"`
+	State   string `json:"state"`
+	Version string `json:"version"`
+}
+
+// PackageResult is the change made to one package
+type PackageResult struct {
+	Name    string `json:"name"`
+	State   string `json:"state"`
+	Change  string `json:"change"`
+	Version string `json:"version,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// NewPlugin returns a new instance of the plugin.
+func NewPlugin() (*Plugin, error) {
+	var plugin Plugin
+	return &plugin, nil
+}
+
+// Name returns the name of the plugin
+func Name() string {
+	return appconfig.PluginNameAwsManagePackages
+}
+
+// Execute brings each package of the plugin input to its desired state and reports the change made to each one.
+func (p *Plugin) Execute(context context.T, config contracts.Configuration, cancelFlag task.CancelFlag, output iohandler.IOHandler) {
+	log := context.Log()
+	log.Infof("%v started with configuration %v", Name(), config)
+
+	if cancelFlag.ShutDown() {
+		output.MarkAsShutdown()
+	} else if cancelFlag.Canceled() {
+		output.MarkAsCancelled()
+	} else if input, err := parseAndValidateInput(config.Properties); err != nil {
+		output.MarkAsFailed(err)
+	} else if manager, err := detectManager(input.Manager); err != nil {
+		output.MarkAsFailed(err)
+	} else {
+		runManagePackages(log, manager, input, cancelFlag, output)
+	}
+}
+
+// runManagePackages applies the packages in order, a package that fails does not stop the following ones
+func runManagePackages(log log.T, manager *packageManager, input *ManagePackagesPluginInput, cancelFlag task.CancelFlag, output iohandler.IOHandler) {
+	retry := retryPolicy{
+		retries:  input.RetryCount,
+		interval: time.Duration(input.RetryIntervalSeconds) * time.Second,
+	}
+	output.AppendInfof("Using package manager %v", manager.name)
+
+	var results []PackageResult
+	failed := 0
+	for _, pkg := range input.Packages {
+		if cancelFlag.Canceled() {
+			output.MarkAsCancelled()
+			return
+		}
+		result := applyPackage(log, manager, retry, pkg)
+		results = append(results, result)
+		if result.Change == ChangeFailed {
+			failed++
+			output.AppendErrorf("%v: %v", result.Name, result.Error)
+		} else if result.Version != "" {
+			output.AppendInfof("%v: %v %v", result.Name, result.Change, result.Version)
+		} else {
+			output.AppendInfof("%v: %v", result.Name, result.Change)
+		}
+	}
+	output.SetOutput(results)
+
+	if failed > 0 {
+		output.MarkAsFailed(fmt.Errorf("failed to apply %v of %v packages", failed, len(input.Packages)))
+		return
+	}
+	output.MarkAsSucceeded()
+}
+
+// applyPackage brings one package to its desired state
+func applyPackage(log log.T, manager *packageManager, retry retryPolicy, pkg PackageInput) PackageResult {
+	result := PackageResult{Name: pkg.Name, State: pkg.State}
+	installedVersion, installed := manager.installedVersion(pkg.Name)
+
+	var err error
+	switch {
+	case pkg.State == StateAbsent:
+		if !installed {
+			result.Change = ChangeUnchanged
+			return result
+		}
+		if err = manager.remove(log, retry, pkg.Name); err == nil {
+			result.Change = ChangeRemoved
+			return result
+		}
+	case pkg.State == StatePresent && installed,
+		pkg.State == StateVersion && installed && installedVersion == pkg.Version:
+		result.Change = ChangeUnchanged
+		result.Version = installedVersion
+		return result
+	case pkg.State == StateLatest && installed:
+		err = manager.upgrade(log, retry, pkg.Name)
+	default:
+		err = manager.install(log, retry, pkg.Name, pkg.Version)
+	}
+	if err != nil {
+		result.Change = ChangeFailed
+		result.Error = err.Error()
+		return result
+	}
+
+	newVersion, nowInstalled := manager.installedVersion(pkg.Name)
+	switch {
+	case !nowInstalled:
+		result.Change = ChangeFailed
+		result.Error = fmt.Sprintf("%v reported success but the package is not installed", manager.name)
+	case !installed:
+		result.Change = ChangeInstalled
+	case newVersion != installedVersion:
+		result.Change = ChangeUpdated
+	default:
+		result.Change = ChangeUnchanged
+	}
+	result.Version = newVersion
+	return result
+}
+
+// parseAndValidateInput parses the plugin properties and fills in the defaults
+func parseAndValidateInput(rawPluginInput interface{}) (*ManagePackagesPluginInput, error) {
+	var input ManagePackagesPluginInput
+	if err := jsonutil.Remarshal(rawPluginInput, &input); err != nil {
+		return nil, fmt.Errorf("invalid format in plugin properties %v; \nerror %v", rawPluginInput, err)
+	}
+	if err := validateInput(&input); err != nil {
+		return nil, fmt.Errorf("invalid input: %v", err)
+	}
+	if input.RetryCount == 0 {
+		input.RetryCount = defaultRetryCount
+	}
+	if input.RetryIntervalSeconds == 0 {
+		input.RetryIntervalSeconds = defaultRetryIntervalSeconds
+	}
+	return &input, nil
+}
+
+// validateInput ensures the plugin input matches the defined schema
+func validateInput(input *ManagePackagesPluginInput) error {
+	if len(input.Packages) == 0 {
+		return errors.New("at least one package must be specified")
+	}
+	if input.RetryCount < 0 || input.RetryIntervalSeconds < 0 {
+		return errors.New("retryCount and retryIntervalSeconds cannot be negative")
+	}
+	for i := range input.Packages {
+		pkg := &input.Packages[i]
+		if pkg.Name == "" {
+			return errors.New("package name must be specified")
+		}
+		// names and versions are passed as arguments of the package manager, they cannot look like options
+		if strings.HasPrefix(pkg.Name, "-") || strings.HasPrefix(pkg.Version, "-") {
+			return fmt.Errorf("invalid package %v", pkg.Name)
+		}
+		if pkg.State == "" {
+			pkg.State = StatePresent
+		}
+		switch pkg.State {
+		case StatePresent, StateAbsent, StateLatest:
+			if pkg.Version != "" {
+				return fmt.Errorf("version of %v can only be specified with state %v", pkg.Name, StateVersion)
+			}
+		case StateVersion:
+			if pkg.Version == "" {
+				return fmt.Errorf("version of %v must be specified with state %v", pkg.Name, StateVersion)
+			}
+		default:
+			return fmt.Errorf("unsupported state %v for package %v", pkg.State, pkg.Name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,207 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package managepackages
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/iohandler"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/task"
+	"github.com/stretchr/testify/assert"
+)
+
+var logger = log.NewMockLog()
+
+// fakeHost emulates the package database of a host managed with apt
+type fakeHost struct {
+	installed map[string]string
+	latest    map[string]string
+	locked    int
+	commands  []string
+}
+
+func (h *fakeHost) run(env []string, name string, args ...string) (string, error) {
+	h.commands = append(h.commands, name+" "+strings.Join(args, " "))
+	pkg := args[len(args)-1]
+	switch {
+	case name == "dpkg-query":
+		if version, ok := h.installed[pkg]; ok {
+			return "install ok installed " + version, nil
+		}
+		return "dpkg-query: no packages found matching " + pkg, errors.New("exit status 1")
+	case h.locked > 0:
+		h.locked--
+		return "E: Could not get lock /var/lib/dpkg/lock-frontend", errors.New("exit status 100")
+	case args[0] == "remove":
+		delete(h.installed, pkg)
+	case strings.Contains(pkg, "="):
+		parts := strings.Split(pkg, "=")
+		h.installed[parts[0]] = parts[1]
+	default:
+		if _, ok := h.latest[pkg]; !ok {
+			return "E: Unable to locate package " + pkg, errors.New("exit status 100")
+		}
+		h.installed[pkg] = h.latest[pkg]
+	}
+	return "", nil
+}
+
+// useFakeHost replaces the command runner with the fake host and returns a function restoring it
+func useFakeHost(host *fakeHost) func() {
+	runCommandOrig, sleepOrig := runCommand, sleep
+	runCommand = host.run
+	sleep = func(time.Duration) {}
+	return func() { runCommand, sleep = runCommandOrig, sleepOrig }
+}
+
+func TestApplyPackage(t *testing.T) {
+	retry := retryPolicy{retries: 2}
+	tests := []struct {
+		pkg     PackageInput
+		change  string
+		version string
+	}{
+		{PackageInput{Name: "curl", State: StatePresent}, ChangeUnchanged, "7.0"},
+		{PackageInput{Name: "jq", State: StatePresent}, ChangeInstalled, "1.6"},
+		{PackageInput{Name: "curl", State: StateLatest}, ChangeUpdated, "7.5"},
+		{PackageInput{Name: "git", State: StateLatest}, ChangeUnchanged, "2.30"},
+		{PackageInput{Name: "curl", State: StateVersion, Version: "6.0"}, ChangeUpdated, "6.0"},
+		{PackageInput{Name: "git", State: StateVersion, Version: "2.30"}, ChangeUnchanged, "2.30"},
+		{PackageInput{Name: "git", State: StateAbsent}, ChangeRemoved, ""},
+		{PackageInput{Name: "jq", State: StateAbsent}, ChangeUnchanged, ""},
+		{PackageInput{Name: "missing", State: StatePresent}, ChangeFailed, ""},
+	}
+	for _, test := range tests {
+		t.Run(test.pkg.Name+"_"+test.pkg.State, func(t *testing.T) {
+			host := &fakeHost{
+				installed: map[string]string{"curl": "7.0", "git": "2.30"},
+				latest:    map[string]string{"curl": "7.5", "git": "2.30", "jq": "1.6"},
+			}
+			defer useFakeHost(host)()
+
+			result := applyPackage(logger, managers[managerApt], retry, test.pkg)
+			assert.Equal(t, test.change, result.Change, result.Error)
+			assert.Equal(t, test.version, result.Version)
+		})
+	}
+}
+
+func TestApplyPackage_RetriesWhileLocked(t *testing.T) {
+	host := &fakeHost{installed: map[string]string{}, latest: map[string]string{"jq": "1.6"}, locked: 2}
+	defer useFakeHost(host)()
+
+	result := applyPackage(logger, managers[managerApt], retryPolicy{retries: 2}, PackageInput{Name: "jq", State: StatePresent})
+	assert.Equal(t, ChangeInstalled, result.Change)
+	assert.Equal(t, "1.6", host.installed["jq"])
+}
+
+func TestApplyPackage_LockRetriesExhausted(t *testing.T) {
+	host := &fakeHost{installed: map[string]string{}, latest: map[string]string{"jq": "1.6"}, locked: 3}
+	defer useFakeHost(host)()
+
+	result := applyPackage(logger, managers[managerApt], retryPolicy{retries: 2}, PackageInput{Name: "jq", State: StatePresent})
+	assert.Equal(t, ChangeFailed, result.Change)
+	assert.Contains(t, result.Error, "Could not get lock")
+	assert.NotContains(t, host.installed, "jq")
+}
+
+func TestRunManagePackages(t *testing.T) {
+	host := &fakeHost{installed: map[string]string{"git": "2.30"}, latest: map[string]string{"jq": "1.6"}}
+	defer useFakeHost(host)()
+	input := &ManagePackagesPluginInput{
+		Packages: []PackageInput{
+			{Name: "jq", State: StatePresent},
+			{Name: "missing", State: StatePresent},
+			{Name: "git", State: StateAbsent},
+		},
+	}
+
+	output := iohandler.DefaultIOHandler{}
+	runManagePackages(logger, managers[managerApt], input, task.NewChanneledCancelFlag(), &output)
+
+	assert.Equal(t, 1, output.GetExitCode())
+	assert.Contains(t, output.GetStdout(), "jq: installed 1.6")
+	assert.Contains(t, output.GetStdout(), "git: removed")
+	assert.Contains(t, output.GetStderr(), "missing: apt-get install -y missing failed")
+	assert.Contains(t, output.GetStderr(), "failed to apply 1 of 3 packages")
+}
+
+func TestDetectManager(t *testing.T) {
+	lookPathOrig := lookPath
+	defer func() { lookPath = lookPathOrig }()
+	available := map[string]bool{managers[platformManagers[1]].binary: true}
+	lookPath = func(file string) (string, error) {
+		if available[file] {
+			return file, nil
+		}
+		return "", fmt.Errorf("%v not found", file)
+	}
+
+	manager, err := detectManager("")
+	assert.NoError(t, err)
+	assert.Equal(t, platformManagers[1], manager.name)
+
+	_, err = detectManager(platformManagers[0])
+	assert.Error(t, err)
+
+	_, err = detectManager("portage")
+	assert.Error(t, err)
+}
+
+func TestParseQuery(t *testing.T) {
+	version, installed := parseRpmQuery("git", "2.30.1-1.amzn2\n")
+	assert.True(t, installed)
+	assert.Equal(t, "2.30.1-1.amzn2", version)
+
+	_, installed = parseRpmQuery("git", "package git is not installed\n")
+	assert.False(t, installed)
+
+	version, installed = managers[managerChoco].parseQuery("git", "Chocolatey v0.10.15\ngit|2.30.0\n")
+	assert.True(t, installed)
+	assert.Equal(t, "2.30.0", version)
+
+	version, installed = managers[managerWinget].parseQuery("Git.Git", "Name Id      Version\n-----\nGit  Git.Git 2.30.0\n")
+	assert.True(t, installed)
+	assert.Equal(t, "2.30.0", version)
+
+	_, installed = managers[managerApt].parseQuery("git", "deinstall ok config-files 2.30")
+	assert.False(t, installed)
+}
+
+func TestValidateInput(t *testing.T) {
+	input, err := parseAndValidateInput(map[string]interface{}{
+		"packages": []interface{}{map[string]interface{}{"name": "jq"}},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, StatePresent, input.Packages[0].State)
+	assert.Equal(t, defaultRetryCount, input.RetryCount)
+	assert.Equal(t, defaultRetryIntervalSeconds, input.RetryIntervalSeconds)
+
+	invalid := []PackageInput{
+		{State: StatePresent},
+		{Name: "jq", State: "installed"},
+		{Name: "jq", State: StateVersion},
+		{Name: "jq", State: StateLatest, Version: "1.6"},
+		{Name: "--force-yes"},
+	}
+	for _, pkg := range invalid {
+		assert.Error(t, validateInput(&ManagePackagesPluginInput{Packages: []PackageInput{pkg}}), pkg.Name)
+	}
+	assert.Error(t, validateInput(&ManagePackagesPluginInput{}))
+}
@@ -0,0 +1,300 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package managepackages
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/log"
+)
+
+// Names of the supported package managers, these are also the values accepted in the manager parameter
+const (
+	managerApt    = "apt"
+	managerYum    = "yum"
+	managerDnf    = "dnf"
+	managerZypper = "zypper"
+	managerChoco  = "choco"
+	managerWinget = "winget"
+)
+
+// runCommand runs a package manager command and returns its combined output
+var runCommand = func(env []string, name string, args ...string) (string, error) {
+	command := exec.Command(name, args...)
+	if len(env) > 0 {
+		command.Env = append(os.Environ(), env...)
+	}
+	output, err := command.CombinedOutput()
+	return string(output), err
+}
+
+var lookPath = exec.LookPath
+
+var sleep = time.Sleep
+
+// packageManager installs, upgrades and removes packages with the package manager of the host
+type packageManager struct {
+	name string
+	// binary is the command used to change packages, it is also used to detect the package manager
+	binary string
+	env    []string
+
+	// queryCommand returns the command printing the installed version of a package
+	queryCommand func(pkg string) []string
+	// parseQuery returns the installed version from the query output, or false if the package is not installed
+	parseQuery func(pkg string, output string) (version string, installed bool)
+
+	installArgs func(pkg string, version string) []string
+	upgradeArgs func(pkg string) []string
+	removeArgs  func(pkg string) []string
+
+	// lockMessages are printed by the package manager when another process holds its lock
+	lockMessages []string
+}
+
+// retryPolicy controls how often a command is retried while the package manager is locked
+type retryPolicy struct {
+	retries  int
+	interval time.Duration
+}
+
+// managers holds the package managers known to the plugin, keyed by name
+var managers = map[string]*packageManager{
+	managerApt: {
+		name:   managerApt,
+		binary: "apt-get",
+		env:    []string{"DEBIAN_FRONTEND=noninteractive"},
+		queryCommand: func(pkg string) []string {
+			return []string{"dpkg-query", "-W", "-f=${Status} ${Version}", pkg}
+		},
+		parseQuery: func(pkg string, output string) (string, bool) {
+			// the status is "install ok installed" followed by the version
+			fields := strings.Fields(output)
+			if len(fields) != 4 || fields[2] != "installed" {
+				return "", false
+			}
+			return fields[3], true
+		},
+		installArgs: func(pkg string, version string) []string {
+			if version != "" {
+				return []string{"install", "-y", "--allow-downgrades", pkg + "=" + version}
+			}
+			return []string{"install", "-y", pkg}
+		},
+		upgradeArgs: func(pkg string) []string { return []string{"install", "-y", "--only-upgrade", pkg} },
+		removeArgs:  func(pkg string) []string { return []string{"remove", "-y", pkg} },
+		lockMessages: []string{
+			"Could not get lock",
+			"Unable to acquire the dpkg frontend lock",
+			"Unable to lock the administration directory",
+		},
+	},
+	managerYum: {
+		name:         managerYum,
+		binary:       "yum",
+		queryCommand: rpmQueryCommand,
+		parseQuery:   parseRpmQuery,
+		installArgs: func(pkg string, version string) []string {
+			if version != "" {
+				return []string{"install", "-y", pkg + "-" + version}
+			}
+			return []string{"install", "-y", pkg}
+		},
+		upgradeArgs:  func(pkg string) []string { return []string{"update", "-y", pkg} },
+		removeArgs:   func(pkg string) []string { return []string{"remove", "-y", pkg} },
+		lockMessages: []string{"Another app is currently holding the yum lock", "Existing lock"},
+	},
+	managerDnf: {
+		name:         managerDnf,
+		binary:       "dnf",
+		queryCommand: rpmQueryCommand,
+		parseQuery:   parseRpmQuery,
+		installArgs: func(pkg string, version string) []string {
+			if version != "" {
+				return []string{"install", "-y", "--allowerasing", pkg + "-" + version}
+			}
+			return []string{"install", "-y", pkg}
+		},
+		upgradeArgs:  func(pkg string) []string { return []string{"upgrade", "-y", pkg} },
+		removeArgs:   func(pkg string) []string { return []string{"remove", "-y", pkg} },
+		lockMessages: []string{"Waiting for process with pid", "Failed to obtain the transaction lock"},
+	},
+	managerZypper: {
+		name:         managerZypper,
+		binary:       "zypper",
+		queryCommand: rpmQueryCommand,
+		parseQuery:   parseRpmQuery,
+		installArgs: func(pkg string, version string) []string {
+			if version != "" {
+				return []string{"--non-interactive", "install", "--oldpackage", pkg + "=" + version}
+			}
+			return []string{"--non-interactive", "install", pkg}
+		},
+		upgradeArgs:  func(pkg string) []string { return []string{"--non-interactive", "update", pkg} },
+		removeArgs:   func(pkg string) []string { return []string{"--non-interactive", "remove", pkg} },
+		lockMessages: []string{"System management is locked"},
+	},
+	managerChoco: {
+		name:   managerChoco,
+		binary: "choco",
+		queryCommand: func(pkg string) []string {
+			return []string{"choco", "list", "--local-only", "--exact", "--limit-output", pkg}
+		},
+		parseQuery: func(pkg string, output string) (string, bool) {
+			// each installed package is printed as name|version
+			for _, line := range strings.Split(output, "\n") {
+				parts := strings.Split(strings.TrimSpace(line), "|")
+				if len(parts) == 2 && strings.EqualFold(parts[0], pkg) {
+					return parts[1], true
+				}
+			}
+			return "", false
+		},
+		installArgs: func(pkg string, version string) []string {
+			if version != "" {
+				return []string{"install", pkg, "-y", "--no-progress", "--allow-downgrade", "--version", version}
+			}
+			return []string{"install", pkg, "-y", "--no-progress"}
+		},
+		upgradeArgs:  func(pkg string) []string { return []string{"upgrade", pkg, "-y", "--no-progress"} },
+		removeArgs:   func(pkg string) []string { return []string{"uninstall", pkg, "-y"} },
+		lockMessages: []string{"Unable to obtain lock file access"},
+	},
+	managerWinget: {
+		name:   managerWinget,
+		binary: "winget",
+		queryCommand: func(pkg string) []string {
+			return []string{"winget", "list", "--id", pkg, "--exact", "--accept-source-agreements"}
+		},
+		parseQuery: func(pkg string, output string) (string, bool) {
+			// the package is listed in a table as name, id and version columns
+			for _, line := range strings.Split(output, "\n") {
+				fields := strings.Fields(line)
+				for i := 0; i < len(fields)-1; i++ {
+					if strings.EqualFold(fields[i], pkg) {
+						return fields[i+1], true
+					}
+				}
+			}
+			return "", false
+		},
+		installArgs: func(pkg string, version string) []string {
+			args := []string{"install", "--id", pkg, "--exact", "--silent", "--accept-package-agreements", "--accept-source-agreements"}
+			if version != "" {
+				args = append(args, "--version", version)
+			}
+			return args
+		},
+		upgradeArgs: func(pkg string) []string {
+			return []string{"upgrade", "--id", pkg, "--exact", "--silent", "--accept-package-agreements", "--accept-source-agreements"}
+		},
+		removeArgs: func(pkg string) []string {
+			return []string{"uninstall", "--id", pkg, "--exact", "--silent"}
+		},
+		lockMessages: []string{"Another installation is already in progress"},
+	},
+}
+
+func rpmQueryCommand(pkg string) []string {
+	return []string{"rpm", "-q", "--qf", "%{VERSION}-%{RELEASE}\n", pkg}
+}
+
+func parseRpmQuery(pkg string, output string) (string, bool) {
+	version := strings.TrimSpace(strings.Split(strings.TrimSpace(output), "\n")[0])
+	if version == "" || strings.Contains(version, "not installed") {
+		return "", false
+	}
+	return version, true
+}
+
+// detectManager returns the package manager to use, either the requested one or the first available on the host
+func detectManager(requested string) (*packageManager, error) {
+	if requested != "" {
+		if !isSupportedManager(requested) {
+			return nil, fmt.Errorf("package manager %v is not supported on this platform", requested)
+		}
+		manager := managers[requested]
+		if _, err := lookPath(manager.binary); err != nil {
+			return nil, fmt.Errorf("package manager %v is not installed", requested)
+		}
+		return manager, nil
+	}
+	for _, name := range platformManagers {
+		if _, err := lookPath(managers[name].binary); err == nil {
+			return managers[name], nil
+		}
+	}
+	return nil, fmt.Errorf("no supported package manager found, looked for %v", strings.Join(platformManagers, ", "))
+}
+
+func isSupportedManager(name string) bool {
+	for _, supported := range platformManagers {
+		if supported == name {
+			return true
+		}
+	}
+	return false
+}
+
+// installedVersion returns the installed version of a package, or false if it is not installed
+func (m *packageManager) installedVersion(pkg string) (string, bool) {
+	command := m.queryCommand(pkg)
+	output, err := runCommand(nil, command[0], command[1:]...)
+	if err != nil {
+		// the query commands fail when the package is not installed
+		return "", false
+	}
+	return m.parseQuery(pkg, output)
+}
+
+func (m *packageManager) install(log log.T, retry retryPolicy, pkg string, version string) error {
+	return m.run(log, retry, m.installArgs(pkg, version))
+}
+
+func (m *packageManager) upgrade(log log.T, retry retryPolicy, pkg string) error {
+	return m.run(log, retry, m.upgradeArgs(pkg))
+}
+
+func (m *packageManager) remove(log log.T, retry retryPolicy, pkg string) error {
+	return m.run(log, retry, m.removeArgs(pkg))
+}
+
+// run runs a package manager command, retrying while another process holds the package manager lock
+func (m *packageManager) run(log log.T, retry retryPolicy, args []string) (err error) {
+	var output string
+	for attempt := 0; ; attempt++ {
+		if output, err = runCommand(m.env, m.binary, args...); err == nil {
+			return nil
+		}
+		if !m.isLocked(output) || attempt >= retry.retries {
+			break
+		}
+		log.Infof("%v is locked by another process, retrying in %v", m.name, retry.interval)
+		sleep(retry.interval)
+	}
+	return fmt.Errorf("%v %v failed: %v %v", m.binary, strings.Join(args, " "), err, strings.TrimSpace(output))
+}
+
+func (m *packageManager) isLocked(output string) bool {
+	for _, message := range m.lockMessages {
+		if strings.Contains(output, message) {
+			return true
+		}
+	}
+	return false
+}
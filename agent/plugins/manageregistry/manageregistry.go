@@ -0,0 +1,295 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package manageregistry implements the aws:manageRegistry plugin, which converges Windows registry keys and values
+// to a desired state and can save the overwritten values to restore them later.
+package manageregistry
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/context"
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/iohandler"
+	"github.com/aws/amazon-ssm-agent/agent/jsonutil"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/task"
+)
+
+// Desired states of keys and values
+const (
+	StatePresent = "present"
+	StateAbsent  = "absent"
+)
+
+var backupNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-][A-Za-z0-9_.-]*$`)
+
+// Plugin is the type for the aws:manageRegistry plugin.
+type Plugin struct {
+}
+
+// ManageRegistryPluginInput represents the parameters of the aws:manageRegistry plugin.
+type ManageRegistryPluginInput struct {
+	contracts.PluginInput
+	Keys []RegistryKeyInput `json:"keys"`
+	// BackupName saves the previous state of everything the plugin changes under this name
+	BackupName string `json:"backupName"`
+	// RestoreBackup restores the state saved under this name instead of applying keys
+	RestoreBackup string `json:"restoreBackup"`
+	// RollbackOnFailure undoes the changes already made when a change fails
+	RollbackOnFailure bool `json:"rollbackOnFailure"`
+}
+
+// RegistryKeyInput is the desired state of a registry key and its values
+type RegistryKeyInput struct {
+	Path   string               `json:"path"`
+	State  string               `json:"state"`
+	Values []RegistryValueInput `json:"values"`
+}
+
+// RegistryValueInput is the desired state of a registry value, an empty name is the default value of the key
+type RegistryValueInput struct {
+	Name  string      `json:"name"`
+	Type  string      `json:"type"`
+	Data  interface{} `json:"data"`
+	State string      `json:"state"`
+
+	value registryValue
+}
+
+// NewPlugin returns a new instance of the plugin.
+func NewPlugin() (*Plugin, error) {
+	var plugin Plugin
+	return &plugin, nil
+}
+
+// Name returns the name of the plugin
+func Name() string {
+	return appconfig.PluginNameAwsManageRegistry
+}
+
+// Execute applies the registry keys of the plugin input, or restores a backup.
+func (p *Plugin) Execute(context context.T, config contracts.Configuration, cancelFlag task.CancelFlag, output iohandler.IOHandler) {
+	log := context.Log()
+	log.Infof("%v started with configuration %v", Name(), config)
+
+	if cancelFlag.ShutDown() {
+		output.MarkAsShutdown()
+	} else if cancelFlag.Canceled() {
+		output.MarkAsCancelled()
+	} else if input, err := parseAndValidateInput(config.Properties); err != nil {
+		output.MarkAsFailed(err)
+	} else if store, err := newRegistryStore(); err != nil {
+		output.MarkAsFailed(err)
+	} else if input.RestoreBackup != "" {
+		runRestore(log, store, input.RestoreBackup, output)
+	} else {
+		runManageRegistry(log, store, input, output)
+	}
+}
+
+// runManageRegistry converges the keys and saves or rolls back the previous state
+func runManageRegistry(log log.T, store registryStore, input *ManageRegistryPluginInput, output iohandler.IOHandler) {
+	backup := &registryBackup{}
+	err := converge(store, input.Keys, backup, output)
+
+	if err != nil && input.RollbackOnFailure {
+		output.AppendErrorf("Failed to apply registry changes: %v", err)
+		if rollbackErr := restore(store, backup); rollbackErr != nil {
+			output.MarkAsFailed(fmt.Errorf("failed to roll back registry changes: %v", rollbackErr))
+			return
+		}
+		output.AppendInfof("Rolled back %v registry changes", len(backup.Entries))
+		output.MarkAsFailed(errors.New("registry changes were rolled back"))
+		return
+	}
+
+	// the backup is saved even when a change failed so the changes made before it can be restored
+	if input.BackupName != "" && len(backup.Entries) > 0 {
+		if saveErr := saveBackup(input.BackupName, backup); saveErr != nil {
+			output.MarkAsFailed(fmt.Errorf("failed to save registry backup %v: %v", input.BackupName, saveErr))
+			return
+		}
+		output.AppendInfof("Saved previous registry state to backup %v", input.BackupName)
+	}
+
+	if err != nil {
+		output.MarkAsFailed(err)
+		return
+	}
+	output.MarkAsSucceeded()
+}
+
+// runRestore restores a saved backup and removes it once restored
+func runRestore(log log.T, store registryStore, name string, output iohandler.IOHandler) {
+	backup, err := loadBackup(name)
+	if err != nil {
+		output.MarkAsFailed(fmt.Errorf("failed to load registry backup %v: %v", name, err))
+		return
+	}
+	if err = restore(store, backup); err != nil {
+		output.MarkAsFailed(fmt.Errorf("failed to restore registry backup %v: %v", name, err))
+		return
+	}
+	if err = deleteBackup(name); err != nil {
+		log.Warnf("failed to delete restored registry backup %v: %v", name, err)
+	}
+	output.AppendInfof("Restored %v registry changes from backup %v", len(backup.Entries), name)
+	output.MarkAsSucceeded()
+}
+
+// converge applies the keys in order and records the previous state of each change in the backup
+func converge(store registryStore, keys []RegistryKeyInput, backup *registryBackup, output iohandler.IOHandler) error {
+	for _, key := range keys {
+		exists, err := store.keyExists(key.Path)
+		if err != nil {
+			return fmt.Errorf("failed to open %v: %v", key.Path, err)
+		}
+
+		if key.State == StateAbsent {
+			if !exists {
+				output.AppendInfof("%v: unchanged", key.Path)
+				continue
+			}
+			snapshot, err := snapshotKey(store, key.Path)
+			if err != nil {
+				return fmt.Errorf("failed to read %v: %v", key.Path, err)
+			}
+			if err = deleteKeyTree(store, key.Path); err != nil {
+				return fmt.Errorf("failed to delete %v: %v", key.Path, err)
+			}
+			backup.add(backupEntry{Kind: entryKey, Path: key.Path, Existed: true, Snapshot: snapshot})
+			output.AppendInfof("%v: removed", key.Path)
+			continue
+		}
+
+		if !exists {
+			if err = store.createKey(key.Path); err != nil {
+				return fmt.Errorf("failed to create %v: %v", key.Path, err)
+			}
+			backup.add(backupEntry{Kind: entryKey, Path: key.Path})
+			output.AppendInfof("%v: created", key.Path)
+		}
+		for _, value := range key.Values {
+			if err = convergeValue(store, key.Path, value, backup, output); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// convergeValue sets or deletes one value of a key that exists
+func convergeValue(store registryStore, path string, value RegistryValueInput, backup *registryBackup, output iohandler.IOHandler) error {
+	displayName := path + "\\" + value.Name
+	if value.Name == "" {
+		displayName = path + "\\(Default)"
+	}
+	current, err := store.getValue(path, value.Name)
+	if err != nil {
+		return fmt.Errorf("failed to read %v: %v", displayName, err)
+	}
+
+	if value.State == StateAbsent {
+		if current == nil {
+			output.AppendInfof("%v: unchanged", displayName)
+			return nil
+		}
+		if err = store.deleteValue(path, value.Name); err != nil {
+			return fmt.Errorf("failed to delete %v: %v", displayName, err)
+		}
+		backup.add(backupEntry{Kind: entryValue, Path: path, Name: value.Name, Existed: true, Value: current})
+		output.AppendInfof("%v: removed", displayName)
+		return nil
+	}
+
+	if current != nil && current.equal(value.value) {
+		output.AppendInfof("%v: unchanged", displayName)
+		return nil
+	}
+	if err = store.setValue(path, value.Name, value.value); err != nil {
+		return fmt.Errorf("failed to set %v: %v", displayName, err)
+	}
+	backup.add(backupEntry{Kind: entryValue, Path: path, Name: value.Name, Existed: current != nil, Value: current})
+	output.AppendInfof("%v: set to %v", displayName, value.value.describe())
+	return nil
+}
+
+// parseAndValidateInput parses the plugin properties and converts the value data to typed values
+func parseAndValidateInput(rawPluginInput interface{}) (*ManageRegistryPluginInput, error) {
+	var input ManageRegistryPluginInput
+	if err := jsonutil.Remarshal(rawPluginInput, &input); err != nil {
+		return nil, fmt.Errorf("invalid format in plugin properties %v; \nerror %v", rawPluginInput, err)
+	}
+	if err := validateInput(&input); err != nil {
+		return nil, fmt.Errorf("invalid input: %v", err)
+	}
+	return &input, nil
+}
+
+// validateInput ensures the plugin input matches the defined schema
+func validateInput(input *ManageRegistryPluginInput) (err error) {
+	for _, name := range []string{input.BackupName, input.RestoreBackup} {
+		if name != "" && !backupNamePattern.MatchString(name) {
+			return fmt.Errorf("invalid backup name %v", name)
+		}
+	}
+	if input.RestoreBackup != "" {
+		if len(input.Keys) > 0 {
+			return errors.New("keys cannot be specified with restoreBackup")
+		}
+		return nil
+	}
+	if len(input.Keys) == 0 {
+		return errors.New("at least one key must be specified")
+	}
+
+	for i := range input.Keys {
+		key := &input.Keys[i]
+		if key.Path, err = parseKeyPath(key.Path); err != nil {
+			return err
+		}
+		if key.State == "" {
+			key.State = StatePresent
+		}
+		switch key.State {
+		case StatePresent:
+		case StateAbsent:
+			if len(key.Values) > 0 {
+				return fmt.Errorf("values cannot be specified for %v which is absent", key.Path)
+			}
+		default:
+			return fmt.Errorf("unsupported state %v for %v", key.State, key.Path)
+		}
+
+		for j := range key.Values {
+			value := &key.Values[j]
+			if value.State == "" {
+				value.State = StatePresent
+			}
+			switch value.State {
+			case StatePresent:
+				if value.value, err = parseValue(value.Type, value.Data); err != nil {
+					return fmt.Errorf("invalid value %v of %v: %v", value.Name, key.Path, err)
+				}
+			case StateAbsent:
+			default:
+				return fmt.Errorf("unsupported state %v for value %v of %v", value.State, value.Name, key.Path)
+			}
+		}
+	}
+	return nil
+}
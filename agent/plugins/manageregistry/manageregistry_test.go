@@ -0,0 +1,293 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package manageregistry
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/iohandler"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/stretchr/testify/assert"
+)
+
+var logger = log.NewMockLog()
+
+// memoryRegistry is a registry store kept in memory, keys are stored by their full path
+type memoryRegistry struct {
+	keys map[string]map[string]registryValue
+	// failSet makes setValue fail for the value with this name
+	failSet string
+}
+
+func newMemoryRegistry() *memoryRegistry {
+	return &memoryRegistry{keys: map[string]map[string]registryValue{}}
+}
+
+func (m *memoryRegistry) keyExists(path string) (bool, error) {
+	_, ok := m.keys[path]
+	return ok, nil
+}
+
+func (m *memoryRegistry) createKey(path string) error {
+	parts := strings.Split(path, "\\")
+	for i := 2; i <= len(parts); i++ {
+		if _, ok := m.keys[strings.Join(parts[:i], "\\")]; !ok {
+			m.keys[strings.Join(parts[:i], "\\")] = map[string]registryValue{}
+		}
+	}
+	return nil
+}
+
+func (m *memoryRegistry) deleteKey(path string) error {
+	if names, _ := m.subKeyNames(path); len(names) > 0 {
+		return errors.New("key has subkeys")
+	}
+	delete(m.keys, path)
+	return nil
+}
+
+func (m *memoryRegistry) subKeyNames(path string) ([]string, error) {
+	var names []string
+	for key := range m.keys {
+		if strings.HasPrefix(key, path+"\\") && !strings.Contains(key[len(path)+1:], "\\") {
+			names = append(names, key[len(path)+1:])
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func (m *memoryRegistry) valueNames(path string) ([]string, error) {
+	var names []string
+	for name := range m.keys[path] {
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+func (m *memoryRegistry) getValue(path string, name string) (*registryValue, error) {
+	if value, ok := m.keys[path][name]; ok {
+		return &value, nil
+	}
+	return nil, nil
+}
+
+func (m *memoryRegistry) setValue(path string, name string, value registryValue) error {
+	if name == m.failSet {
+		return errors.New("access denied")
+	}
+	m.keys[path][name] = value
+	return nil
+}
+
+func (m *memoryRegistry) deleteValue(path string, name string) error {
+	delete(m.keys[path], name)
+	return nil
+}
+
+func useTempBackupRoot(t *testing.T) func() {
+	backupRootOrig := backupRoot
+	dir, err := ioutil.TempDir("", "registrybackups")
+	assert.NoError(t, err)
+	backupRoot = dir
+	return func() {
+		backupRoot = backupRootOrig
+		os.RemoveAll(dir)
+	}
+}
+
+func testInput(t *testing.T, properties map[string]interface{}) *ManageRegistryPluginInput {
+	input, err := parseAndValidateInput(properties)
+	assert.NoError(t, err)
+	return input
+}
+
+func TestConverge(t *testing.T) {
+	store := newMemoryRegistry()
+	store.createKey("HKLM\\SOFTWARE\\Contoso\\Old\\Child")
+	store.keys["HKLM\\SOFTWARE\\Contoso"]["Level"] = registryValue{Type: TypeDWord, Integer: 1}
+	store.keys["HKLM\\SOFTWARE\\Contoso"]["Mode"] = registryValue{Type: TypeString, String: "fast"}
+	store.keys["HKLM\\SOFTWARE\\Contoso"]["Obsolete"] = registryValue{Type: TypeString, String: "x"}
+
+	input := testInput(t, map[string]interface{}{
+		"keys": []interface{}{
+			map[string]interface{}{
+				"path": "HKEY_LOCAL_MACHINE\\SOFTWARE\\Contoso",
+				"values": []interface{}{
+					map[string]interface{}{"name": "Level", "type": "REG_DWORD", "data": float64(5)},
+					map[string]interface{}{"name": "Mode", "type": "SZ", "data": "fast"},
+					map[string]interface{}{"name": "Servers", "type": "MULTI_SZ", "data": []interface{}{"a", "b"}},
+					map[string]interface{}{"name": "Obsolete", "state": "absent"},
+				},
+			},
+			map[string]interface{}{"path": "HKLM\\SOFTWARE\\Contoso\\Old", "state": "absent"},
+			map[string]interface{}{"path": "HKLM\\SOFTWARE\\Fabrikam", "values": []interface{}{
+				map[string]interface{}{"name": "Key", "type": "BINARY", "data": "01 ff"},
+			}},
+		},
+	})
+
+	output := iohandler.DefaultIOHandler{}
+	backup := &registryBackup{}
+	assert.NoError(t, converge(store, input.Keys, backup, &output))
+
+	contoso := store.keys["HKLM\\SOFTWARE\\Contoso"]
+	assert.Equal(t, uint64(5), contoso["Level"].Integer)
+	assert.Equal(t, []string{"a", "b"}, contoso["Servers"].Strings)
+	assert.NotContains(t, contoso, "Obsolete")
+	assert.NotContains(t, store.keys, "HKLM\\SOFTWARE\\Contoso\\Old")
+	assert.NotContains(t, store.keys, "HKLM\\SOFTWARE\\Contoso\\Old\\Child")
+	assert.Equal(t, []byte{0x01, 0xff}, store.keys["HKLM\\SOFTWARE\\Fabrikam"]["Key"].Binary)
+
+	stdout := output.GetStdout()
+	assert.Contains(t, stdout, "HKLM\\SOFTWARE\\Contoso\\Level: set to DWORD 5")
+	assert.Contains(t, stdout, "HKLM\\SOFTWARE\\Contoso\\Mode: unchanged")
+	assert.Contains(t, stdout, "HKLM\\SOFTWARE\\Contoso\\Obsolete: removed")
+	assert.Contains(t, stdout, "HKLM\\SOFTWARE\\Contoso\\Old: removed")
+	assert.Contains(t, stdout, "HKLM\\SOFTWARE\\Fabrikam: created")
+	// unchanged values are not recorded
+	assert.Len(t, backup.Entries, 6)
+
+	// converging again changes nothing
+	again := &registryBackup{}
+	assert.NoError(t, converge(store, input.Keys, again, &iohandler.DefaultIOHandler{}))
+	assert.Empty(t, again.Entries)
+
+	// restoring the backup brings back the original registry
+	assert.NoError(t, restore(store, backup))
+	assert.Equal(t, uint64(1), store.keys["HKLM\\SOFTWARE\\Contoso"]["Level"].Integer)
+	assert.Equal(t, "x", store.keys["HKLM\\SOFTWARE\\Contoso"]["Obsolete"].String)
+	assert.NotContains(t, store.keys["HKLM\\SOFTWARE\\Contoso"], "Servers")
+	assert.Contains(t, store.keys, "HKLM\\SOFTWARE\\Contoso\\Old\\Child")
+	assert.NotContains(t, store.keys, "HKLM\\SOFTWARE\\Fabrikam")
+}
+
+func TestRunManageRegistry_RollbackOnFailure(t *testing.T) {
+	store := newMemoryRegistry()
+	store.createKey("HKLM\\SOFTWARE\\Contoso")
+	store.keys["HKLM\\SOFTWARE\\Contoso"]["Level"] = registryValue{Type: TypeDWord, Integer: 1}
+	store.failSet = "Locked"
+
+	input := testInput(t, map[string]interface{}{
+		"rollbackOnFailure": true,
+		"keys": []interface{}{
+			map[string]interface{}{"path": "HKLM\\SOFTWARE\\Contoso", "values": []interface{}{
+				map[string]interface{}{"name": "Level", "type": "DWORD", "data": "0x10"},
+				map[string]interface{}{"name": "Locked", "type": "SZ", "data": "on"},
+			}},
+		},
+	})
+
+	output := iohandler.DefaultIOHandler{}
+	runManageRegistry(logger, store, input, &output)
+
+	assert.Equal(t, contracts.ResultStatusFailed, output.GetStatus())
+	assert.Contains(t, output.GetStderr(), "access denied")
+	assert.Contains(t, output.GetStdout(), "Rolled back 1 registry changes")
+	assert.Equal(t, uint64(1), store.keys["HKLM\\SOFTWARE\\Contoso"]["Level"].Integer)
+}
+
+func TestBackupAndRestore(t *testing.T) {
+	defer useTempBackupRoot(t)()
+	store := newMemoryRegistry()
+	store.createKey("HKLM\\SOFTWARE\\Contoso")
+	store.keys["HKLM\\SOFTWARE\\Contoso"]["Level"] = registryValue{Type: TypeDWord, Integer: 1}
+
+	apply := func(level float64) {
+		input := testInput(t, map[string]interface{}{
+			"backupName": "before-hardening",
+			"keys": []interface{}{
+				map[string]interface{}{"path": "HKLM\\SOFTWARE\\Contoso", "values": []interface{}{
+					map[string]interface{}{"name": "Level", "type": "DWORD", "data": level},
+				}},
+			},
+		})
+		output := iohandler.DefaultIOHandler{}
+		runManageRegistry(logger, store, input, &output)
+		assert.Equal(t, contracts.ResultStatusSuccess, output.GetStatus())
+	}
+	apply(5)
+	// a second run keeps the state saved by the first one
+	apply(7)
+
+	output := iohandler.DefaultIOHandler{}
+	runRestore(logger, store, "before-hardening", &output)
+	assert.Equal(t, contracts.ResultStatusSuccess, output.GetStatus())
+	assert.Equal(t, uint64(1), store.keys["HKLM\\SOFTWARE\\Contoso"]["Level"].Integer)
+
+	// the backup is removed once restored
+	output = iohandler.DefaultIOHandler{}
+	runRestore(logger, store, "before-hardening", &output)
+	assert.Equal(t, contracts.ResultStatusFailed, output.GetStatus())
+}
+
+func TestParseValue(t *testing.T) {
+	value, err := parseValue("dword", "4294967295")
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(4294967295), value.Integer)
+
+	value, err = parseValue("REG_QWORD", float64(1<<40))
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(1<<40), value.Integer)
+
+	value, err = parseValue("BINARY", []interface{}{float64(1), float64(255)})
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{1, 255}, value.Binary)
+
+	value, err = parseValue("MULTI_SZ", "single")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"single"}, value.Strings)
+
+	for _, invalid := range []struct {
+		valueType string
+		data      interface{}
+	}{
+		{"DWORD", float64(-1)},
+		{"DWORD", "4294967296"},
+		{"DWORD", float64(1.5)},
+		{"SZ", float64(1)},
+		{"BINARY", "xyz"},
+		{"MULTI_SZ", []interface{}{float64(1)}},
+		{"LINK", "x"},
+	} {
+		_, err = parseValue(invalid.valueType, invalid.data)
+		assert.Error(t, err, invalid.valueType)
+	}
+}
+
+func TestValidateInput(t *testing.T) {
+	input := ManageRegistryPluginInput{Keys: []RegistryKeyInput{{Path: "HKLM:\\SOFTWARE\\Contoso\\"}}}
+	assert.NoError(t, validateInput(&input))
+	assert.Equal(t, "HKLM\\SOFTWARE\\Contoso", input.Keys[0].Path)
+	assert.Equal(t, StatePresent, input.Keys[0].State)
+
+	invalid := []ManageRegistryPluginInput{
+		{},
+		{Keys: []RegistryKeyInput{{Path: "SOFTWARE\\Contoso"}}},
+		{Keys: []RegistryKeyInput{{Path: "HKLM"}}},
+		{Keys: []RegistryKeyInput{{Path: "HKLM\\SOFTWARE", State: "missing"}}},
+		{Keys: []RegistryKeyInput{{Path: "HKLM\\SOFTWARE\\Contoso", State: StateAbsent, Values: []RegistryValueInput{{Name: "a", State: StateAbsent}}}}},
+		{Keys: []RegistryKeyInput{{Path: "HKLM\\SOFTWARE\\Contoso"}}, RestoreBackup: "backup"},
+		{Keys: []RegistryKeyInput{{Path: "HKLM\\SOFTWARE\\Contoso"}}, BackupName: "../backup"},
+	}
+	for _, input := range invalid {
+		assert.Error(t, validateInput(&input))
+	}
+}
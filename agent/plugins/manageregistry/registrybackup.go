@@ -0,0 +1,218 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package manageregistry
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/fileutil"
+)
+
+// Kinds of backup entries
+const (
+	entryKey   = "key"
+	entryValue = "value"
+)
+
+// registryStore reads and writes the registry, paths start with the short name of their root key
+type registryStore interface {
+	keyExists(path string) (bool, error)
+	// createKey creates the key and its missing parents
+	createKey(path string) error
+	// deleteKey deletes a key that has no subkeys
+	deleteKey(path string) error
+	subKeyNames(path string) ([]string, error)
+	valueNames(path string) ([]string, error)
+	// getValue returns nil if the value does not exist
+	getValue(path string, name string) (*registryValue, error)
+	setValue(path string, name string, value registryValue) error
+	deleteValue(path string, name string) error
+}
+
+// keySnapshot is the content of a deleted key, so it can be recreated
+type keySnapshot struct {
+	Values  map[string]registryValue `json:"values"`
+	SubKeys map[string]*keySnapshot  `json:"subKeys"`
+}
+
+// backupEntry is the state of a key or value before the plugin changed it
+type backupEntry struct {
+	Kind     string         `json:"kind"`
+	Path     string         `json:"path"`
+	Name     string         `json:"name,omitempty"`
+	Existed  bool           `json:"existed"`
+	Value    *registryValue `json:"value,omitempty"`
+	Snapshot *keySnapshot   `json:"snapshot,omitempty"`
+}
+
+// registryBackup is the list of changes in the order they were made
+type registryBackup struct {
+	Entries []backupEntry `json:"entries"`
+}
+
+func (b *registryBackup) add(entry backupEntry) {
+	b.Entries = append(b.Entries, entry)
+}
+
+// restore undoes the changes of the backup in reverse order
+func restore(store registryStore, backup *registryBackup) error {
+	for i := len(backup.Entries) - 1; i >= 0; i-- {
+		entry := backup.Entries[i]
+		exists, err := store.keyExists(entry.Path)
+		if err != nil {
+			return err
+		}
+		switch {
+		case entry.Kind == entryKey && !entry.Existed:
+			if exists {
+				err = deleteKeyTree(store, entry.Path)
+			}
+		case entry.Kind == entryKey:
+			if exists {
+				if err = deleteKeyTree(store, entry.Path); err != nil {
+					return err
+				}
+			}
+			err = restoreSnapshot(store, entry.Path, entry.Snapshot)
+		case !entry.Existed:
+			if exists {
+				var current *registryValue
+				if current, err = store.getValue(entry.Path, entry.Name); err == nil && current != nil {
+					err = store.deleteValue(entry.Path, entry.Name)
+				}
+			}
+		default:
+			if !exists {
+				if err = store.createKey(entry.Path); err != nil {
+					return err
+				}
+			}
+			err = store.setValue(entry.Path, entry.Name, *entry.Value)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// snapshotKey reads a key with its values and subkeys
+func snapshotKey(store registryStore, path string) (*keySnapshot, error) {
+	snapshot := &keySnapshot{Values: map[string]registryValue{}, SubKeys: map[string]*keySnapshot{}}
+	names, err := store.valueNames(path)
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range names {
+		value, err := store.getValue(path, name)
+		if err != nil {
+			return nil, err
+		}
+		if value != nil {
+			snapshot.Values[name] = *value
+		}
+	}
+	subKeys, err := store.subKeyNames(path)
+	if err != nil {
+		return nil, err
+	}
+	for _, subKey := range subKeys {
+		if snapshot.SubKeys[subKey], err = snapshotKey(store, path+"\\"+subKey); err != nil {
+			return nil, err
+		}
+	}
+	return snapshot, nil
+}
+
+// restoreSnapshot recreates a key from its snapshot
+func restoreSnapshot(store registryStore, path string, snapshot *keySnapshot) error {
+	if err := store.createKey(path); err != nil {
+		return err
+	}
+	for name, value := range snapshot.Values {
+		if err := store.setValue(path, name, value); err != nil {
+			return err
+		}
+	}
+	for subKey, subSnapshot := range snapshot.SubKeys {
+		if err := restoreSnapshot(store, path+"\\"+subKey, subSnapshot); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deleteKeyTree deletes a key and all its subkeys
+func deleteKeyTree(store registryStore, path string) error {
+	subKeys, err := store.subKeyNames(path)
+	if err != nil {
+		return err
+	}
+	for _, subKey := range subKeys {
+		if err = deleteKeyTree(store, path+"\\"+subKey); err != nil {
+			return err
+		}
+	}
+	return store.deleteKey(path)
+}
+
+func backupPath(name string) string {
+	return filepath.Join(backupRoot, name+".json")
+}
+
+// saveBackup saves the backup, when a backup with the same name exists the earliest state of each key and value is kept
+func saveBackup(name string, backup *registryBackup) error {
+	merged := backup
+	if existing, err := loadBackup(name); err == nil {
+		recorded := map[string]bool{}
+		for _, entry := range existing.Entries {
+			recorded[entry.Kind+"|"+entry.Path+"|"+entry.Name] = true
+		}
+		for _, entry := range backup.Entries {
+			if !recorded[entry.Kind+"|"+entry.Path+"|"+entry.Name] {
+				existing.add(entry)
+			}
+		}
+		merged = existing
+	}
+
+	content, err := json.Marshal(merged)
+	if err != nil {
+		return err
+	}
+	if err = fileutil.MakeDirs(backupRoot); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(backupPath(name), content, appconfig.ReadWriteAccess)
+}
+
+func loadBackup(name string) (*registryBackup, error) {
+	content, err := ioutil.ReadFile(backupPath(name))
+	if err != nil {
+		return nil, err
+	}
+	var backup registryBackup
+	if err = json.Unmarshal(content, &backup); err != nil {
+		return nil, err
+	}
+	return &backup, nil
+}
+
+func deleteBackup(name string) error {
+	return os.Remove(backupPath(name))
+}
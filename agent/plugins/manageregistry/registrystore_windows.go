@@ -0,0 +1,180 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// +build windows
+
+package manageregistry
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"golang.org/x/sys/windows/registry"
+)
+
+// backupRoot is the directory where registry backups are saved
+var backupRoot = filepath.Join(appconfig.SSMDataPath, "RegistryBackups")
+
+var rootKeys = map[string]registry.Key{
+	"HKLM": registry.LOCAL_MACHINE,
+	"HKCU": registry.CURRENT_USER,
+	"HKU":  registry.USERS,
+	"HKCR": registry.CLASSES_ROOT,
+	"HKCC": registry.CURRENT_CONFIG,
+}
+
+// windowsRegistry accesses the 64 bit view of the registry
+type windowsRegistry struct{}
+
+func newRegistryStore() (registryStore, error) {
+	return windowsRegistry{}, nil
+}
+
+func splitPath(path string) (registry.Key, string) {
+	parts := strings.SplitN(path, "\\", 2)
+	return rootKeys[parts[0]], parts[1]
+}
+
+func openKey(path string, access uint32) (registry.Key, error) {
+	root, subKey := splitPath(path)
+	return registry.OpenKey(root, subKey, access|registry.WOW64_64KEY)
+}
+
+func (windowsRegistry) keyExists(path string) (bool, error) {
+	key, err := openKey(path, registry.QUERY_VALUE)
+	if err == registry.ErrNotExist {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	key.Close()
+	return true, nil
+}
+
+func (windowsRegistry) createKey(path string) error {
+	root, subKey := splitPath(path)
+	key, _, err := registry.CreateKey(root, subKey, registry.ALL_ACCESS|registry.WOW64_64KEY)
+	if err != nil {
+		return err
+	}
+	return key.Close()
+}
+
+func (windowsRegistry) deleteKey(path string) error {
+	parent, name := path[:strings.LastIndex(path, "\\")], path[strings.LastIndex(path, "\\")+1:]
+	if !strings.Contains(parent, "\\") {
+		root, _ := splitPath(path)
+		return registry.DeleteKey(root, name)
+	}
+	key, err := openKey(parent, registry.ALL_ACCESS)
+	if err != nil {
+		return err
+	}
+	defer key.Close()
+	return registry.DeleteKey(key, name)
+}
+
+func (windowsRegistry) subKeyNames(path string) ([]string, error) {
+	key, err := openKey(path, registry.ENUMERATE_SUB_KEYS)
+	if err != nil {
+		return nil, err
+	}
+	defer key.Close()
+	return key.ReadSubKeyNames(-1)
+}
+
+func (windowsRegistry) valueNames(path string) ([]string, error) {
+	key, err := openKey(path, registry.QUERY_VALUE)
+	if err != nil {
+		return nil, err
+	}
+	defer key.Close()
+	return key.ReadValueNames(-1)
+}
+
+func (windowsRegistry) getValue(path string, name string) (*registryValue, error) {
+	key, err := openKey(path, registry.QUERY_VALUE)
+	if err != nil {
+		return nil, err
+	}
+	defer key.Close()
+
+	_, valueType, err := key.GetValue(name, nil)
+	if err == registry.ErrNotExist {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var value registryValue
+	switch valueType {
+	case registry.SZ:
+		value.Type = TypeString
+		value.String, _, err = key.GetStringValue(name)
+	case registry.EXPAND_SZ:
+		value.Type = TypeExpandString
+		value.String, _, err = key.GetStringValue(name)
+	case registry.MULTI_SZ:
+		value.Type = TypeMultiString
+		value.Strings, _, err = key.GetStringsValue(name)
+	case registry.DWORD:
+		value.Type = TypeDWord
+		value.Integer, _, err = key.GetIntegerValue(name)
+	case registry.QWORD:
+		value.Type = TypeQWord
+		value.Integer, _, err = key.GetIntegerValue(name)
+	case registry.BINARY:
+		value.Type = TypeBinary
+		value.Binary, _, err = key.GetBinaryValue(name)
+	default:
+		return nil, fmt.Errorf("value type %v is not supported", valueType)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &value, nil
+}
+
+func (windowsRegistry) setValue(path string, name string, value registryValue) error {
+	key, err := openKey(path, registry.SET_VALUE)
+	if err != nil {
+		return err
+	}
+	defer key.Close()
+
+	switch value.Type {
+	case TypeString:
+		return key.SetStringValue(name, value.String)
+	case TypeExpandString:
+		return key.SetExpandStringValue(name, value.String)
+	case TypeMultiString:
+		return key.SetStringsValue(name, value.Strings)
+	case TypeDWord:
+		return key.SetDWordValue(name, uint32(value.Integer))
+	case TypeQWord:
+		return key.SetQWordValue(name, value.Integer)
+	default:
+		return key.SetBinaryValue(name, value.Binary)
+	}
+}
+
+func (windowsRegistry) deleteValue(path string, name string) error {
+	key, err := openKey(path, registry.SET_VALUE)
+	if err != nil {
+		return err
+	}
+	defer key.Close()
+	return key.DeleteValue(name)
+}
@@ -0,0 +1,193 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package manageregistry
+
+import (
+	"encoding/hex"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Registry value types, the REG_ prefix is optional in the plugin input
+const (
+	TypeString       = "SZ"
+	TypeExpandString = "EXPAND_SZ"
+	TypeMultiString  = "MULTI_SZ"
+	TypeDWord        = "DWORD"
+	TypeQWord        = "QWORD"
+	TypeBinary       = "BINARY"
+)
+
+// hives maps the accepted names of the root keys to their short names
+var hives = map[string]string{
+	"HKLM":                "HKLM",
+	"HKEY_LOCAL_MACHINE":  "HKLM",
+	"HKCU":                "HKCU",
+	"HKEY_CURRENT_USER":   "HKCU",
+	"HKU":                 "HKU",
+	"HKEY_USERS":          "HKU",
+	"HKCR":                "HKCR",
+	"HKEY_CLASSES_ROOT":   "HKCR",
+	"HKCC":                "HKCC",
+	"HKEY_CURRENT_CONFIG": "HKCC",
+}
+
+// registryValue is a typed registry value, only the field matching the type is used
+type registryValue struct {
+	Type    string   `json:"type"`
+	String  string   `json:"string,omitempty"`
+	Strings []string `json:"strings,omitempty"`
+	Integer uint64   `json:"integer,omitempty"`
+	Binary  []byte   `json:"binary,omitempty"`
+}
+
+// equal returns true if both values have the same type and data
+func (v registryValue) equal(other registryValue) bool {
+	if v.Type != other.Type {
+		return false
+	}
+	switch v.Type {
+	case TypeString, TypeExpandString:
+		return v.String == other.String
+	case TypeMultiString:
+		if len(v.Strings) != len(other.Strings) {
+			return false
+		}
+		for i := range v.Strings {
+			if v.Strings[i] != other.Strings[i] {
+				return false
+			}
+		}
+		return true
+	case TypeDWord, TypeQWord:
+		return v.Integer == other.Integer
+	default:
+		return hex.EncodeToString(v.Binary) == hex.EncodeToString(other.Binary)
+	}
+}
+
+// describe formats the value for the plugin output
+func (v registryValue) describe() string {
+	switch v.Type {
+	case TypeString, TypeExpandString:
+		return fmt.Sprintf("%v %q", v.Type, v.String)
+	case TypeMultiString:
+		return fmt.Sprintf("%v %q", v.Type, v.Strings)
+	case TypeDWord, TypeQWord:
+		return fmt.Sprintf("%v %v", v.Type, v.Integer)
+	default:
+		return fmt.Sprintf("%v %v", v.Type, hex.EncodeToString(v.Binary))
+	}
+}
+
+// parseKeyPath returns the path with its root key in short form, e.g. HKEY_LOCAL_MACHINE\SOFTWARE\Contoso becomes HKLM\SOFTWARE\Contoso
+func parseKeyPath(path string) (string, error) {
+	parts := strings.SplitN(strings.Trim(path, "\\"), "\\", 2)
+	hive, ok := hives[strings.ToUpper(strings.TrimSuffix(parts[0], ":"))]
+	if !ok {
+		return "", fmt.Errorf("registry path %v does not start with a root key such as HKLM", path)
+	}
+	if len(parts) == 1 || strings.Trim(parts[1], "\\") == "" {
+		return "", fmt.Errorf("registry path %v cannot be a root key", path)
+	}
+	return hive + "\\" + strings.Trim(parts[1], "\\"), nil
+}
+
+// parseValue converts the data of the plugin input to a value of the given type
+func parseValue(valueType string, data interface{}) (value registryValue, err error) {
+	value.Type = strings.TrimPrefix(strings.ToUpper(valueType), "REG_")
+	switch value.Type {
+	case TypeString, TypeExpandString:
+		text, ok := data.(string)
+		if !ok {
+			return value, fmt.Errorf("data of a %v value must be a string", value.Type)
+		}
+		value.String = text
+	case TypeMultiString:
+		switch typed := data.(type) {
+		case string:
+			value.Strings = []string{typed}
+		case []interface{}:
+			for _, item := range typed {
+				text, ok := item.(string)
+				if !ok {
+					return value, fmt.Errorf("data of a %v value must be a list of strings", value.Type)
+				}
+				value.Strings = append(value.Strings, text)
+			}
+		default:
+			return value, fmt.Errorf("data of a %v value must be a list of strings", value.Type)
+		}
+	case TypeDWord, TypeQWord:
+		bits := 64
+		if value.Type == TypeDWord {
+			bits = 32
+		}
+		if value.Integer, err = parseInteger(data, bits); err != nil {
+			return value, fmt.Errorf("data of a %v value %v", value.Type, err)
+		}
+	case TypeBinary:
+		if value.Binary, err = parseBinary(data); err != nil {
+			return value, err
+		}
+	default:
+		return value, fmt.Errorf("unsupported value type %v", valueType)
+	}
+	return value, nil
+}
+
+// parseInteger accepts json numbers and decimal or 0x prefixed hexadecimal strings
+func parseInteger(data interface{}, bits int) (uint64, error) {
+	switch typed := data.(type) {
+	case float64:
+		if typed < 0 || typed != math.Trunc(typed) || typed > math.Pow(2, float64(bits))-1 {
+			return 0, fmt.Errorf("must be an unsigned %v bit integer", bits)
+		}
+		return uint64(typed), nil
+	case string:
+		integer, err := strconv.ParseUint(strings.TrimSpace(typed), 0, bits)
+		if err != nil {
+			return 0, fmt.Errorf("must be an unsigned %v bit integer", bits)
+		}
+		return integer, nil
+	default:
+		return 0, fmt.Errorf("must be a number or a string")
+	}
+}
+
+// parseBinary accepts a hexadecimal string, optionally separated by spaces or commas, or a list of bytes
+func parseBinary(data interface{}) ([]byte, error) {
+	switch typed := data.(type) {
+	case string:
+		binary, err := hex.DecodeString(strings.NewReplacer(" ", "", ",", "").Replace(typed))
+		if err != nil {
+			return nil, fmt.Errorf("data of a %v value must be a hexadecimal string", TypeBinary)
+		}
+		return binary, nil
+	case []interface{}:
+		var binary []byte
+		for _, item := range typed {
+			number, ok := item.(float64)
+			if !ok || number < 0 || number > 255 || number != math.Trunc(number) {
+				return nil, fmt.Errorf("data of a %v value must be a list of bytes", TypeBinary)
+			}
+			binary = append(binary, byte(number))
+		}
+		return binary, nil
+	default:
+		return nil, fmt.Errorf("data of a %v value must be a hexadecimal string", TypeBinary)
+	}
+}
@@ -0,0 +1,411 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package manageusers implements the aws:manageUsers plugin, which converges local users, groups,
+// group membership and credentials to a desired state.
+package manageusers
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/context"
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/iohandler"
+	"github.com/aws/amazon-ssm-agent/agent/jsonutil"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/secret"
+	"github.com/aws/amazon-ssm-agent/agent/ssm/ssmparameterresolver"
+	"github.com/aws/amazon-ssm-agent/agent/task"
+)
+
+// Desired states of users and groups
+const (
+	StatePresent = "present"
+	StateAbsent  = "absent"
+)
+
+// When passwords are set
+const (
+	UpdatePasswordOnCreate = "onCreate" // UpdatePasswordOnCreate only sets the password of users created by the plugin
+	UpdatePasswordAlways   = "always"   // UpdatePasswordAlways sets the password on every run
+)
+
+// accountNamePattern restricts names to characters that are valid for local accounts on every platform
+var accountNamePattern = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9_.-]{0,31}$`)
+
+var resolveParameter = resolveParameterReference
+
+var newUserManager = newPlatformUserManager
+
+// userInfo is the current state of a local user
+type userInfo struct {
+	Name    string
+	Comment string
+	Shell   string
+	Home    string
+	// PrimaryGroup is never removed by exclusive group membership
+	PrimaryGroup string
+	Groups       []string
+}
+
+// userManager changes local users and groups with the tools of the platform
+type userManager interface {
+	// validate rejects settings the platform does not support
+	validate(user UserInput) error
+	// getUser returns nil if the user does not exist
+	getUser(name string) (*userInfo, error)
+	createUser(user UserInput, password *secret.SecretValue) error
+	updateUser(user UserInput) error
+	deleteUser(name string, removeHome bool) error
+	setPassword(name string, password *secret.SecretValue) error
+	groupExists(name string) (bool, error)
+	createGroup(group GroupInput) error
+	deleteGroup(name string) error
+	addToGroup(user string, group string) error
+	removeFromGroup(user string, group string) error
+	// setAuthorizedKeys replaces the SSH authorized keys of the user and returns false if they were already set
+	setAuthorizedKeys(user *userInfo, keys []string) (bool, error)
+}
+
+// Plugin is the type for the aws:manageUsers plugin.
+type Plugin struct {
+}
+
+// ManageUsersPluginInput represents the parameters of the aws:manageUsers plugin.
+type ManageUsersPluginInput struct {
+	contracts.PluginInput
+	Users  []UserInput  `json:"users"`
+	Groups []GroupInput `json:"groups"`
+}
+
+// UserInput is the desired state of a local user
+type UserInput struct {
+	Name    string `json:"name"`
+	State   string `json:"state"`
+	Comment string `json:"comment"`
+	Shell   string `json:"shell"`
+	Home    string `json:"home"`
+	// System creates a service account without a login home directory
+	System bool     `json:"system"`
+	Groups []string `json:"groups"`
+	// ExclusiveGroups removes the user from the groups that are not listed, except its primary group
+	ExclusiveGroups bool `json:"exclusiveGroups"`
+	// Password must reference a SecureString parameter, it is never written to the output or the logs
+	Password       string `json:"password"`
+	UpdatePassword string `json:"updatePassword"`
+	// AuthorizedKeys replaces the SSH authorized keys of the user when it is specified
+	AuthorizedKeys []string `json:"authorizedKeys"`
+	RemoveHome     bool     `json:"removeHome"`
+}
+
+// GroupInput is the desired state of a local group
+type GroupInput struct {
+	Name   string `json:"name"`
+	State  string `json:"state"`
+	System bool   `json:"system"`
+}
+
+// NewPlugin returns a new instance of the plugin.
+func NewPlugin() (*Plugin, error) {
+	var plugin Plugin
+	return &plugin, nil
+}
+
+// Name returns the name of the plugin
+func Name() string {
+	return appconfig.PluginNameAwsManageUsers
+}
+
+// Execute converges the groups and users of the plugin input.
+func (p *Plugin) Execute(context context.T, config contracts.Configuration, cancelFlag task.CancelFlag, output iohandler.IOHandler) {
+	log := context.Log()
+	// the configuration is not logged since it holds password references
+	log.Infof("%v started", Name())
+
+	if cancelFlag.ShutDown() {
+		output.MarkAsShutdown()
+	} else if cancelFlag.Canceled() {
+		output.MarkAsCancelled()
+	} else if manager, err := newUserManager(); err != nil {
+		output.MarkAsFailed(err)
+	} else if input, err := parseAndValidateInput(config.Properties, manager); err != nil {
+		output.MarkAsFailed(err)
+	} else {
+		runManageUsers(log, manager, input, output)
+	}
+}
+
+// runManageUsers creates groups first so users can join them, and removes groups last once users left them
+func runManageUsers(log log.T, manager userManager, input *ManageUsersPluginInput, output iohandler.IOHandler) {
+	failed := 0
+	report := func(name string, err error) {
+		if err != nil {
+			failed++
+			output.AppendErrorf("%v: %v", name, err)
+		}
+	}
+
+	for _, group := range input.Groups {
+		if group.State == StatePresent {
+			report("group "+group.Name, convergeGroup(manager, group, output))
+		}
+	}
+	for _, user := range input.Users {
+		report("user "+user.Name, convergeUser(log, manager, user, output))
+	}
+	for _, group := range input.Groups {
+		if group.State == StateAbsent {
+			report("group "+group.Name, convergeGroup(manager, group, output))
+		}
+	}
+
+	if failed > 0 {
+		output.MarkAsFailed(fmt.Errorf("failed to apply %v of %v users and groups", failed, len(input.Users)+len(input.Groups)))
+		return
+	}
+	output.MarkAsSucceeded()
+}
+
+func convergeGroup(manager userManager, group GroupInput, output iohandler.IOHandler) error {
+	exists, err := manager.groupExists(group.Name)
+	if err != nil {
+		return err
+	}
+	switch {
+	case group.State == StatePresent && !exists:
+		if err = manager.createGroup(group); err != nil {
+			return err
+		}
+		output.AppendInfof("group %v: created", group.Name)
+	case group.State == StateAbsent && exists:
+		if err = manager.deleteGroup(group.Name); err != nil {
+			return err
+		}
+		output.AppendInfof("group %v: removed", group.Name)
+	default:
+		output.AppendInfof("group %v: unchanged", group.Name)
+	}
+	return nil
+}
+
+func convergeUser(log log.T, manager userManager, user UserInput, output iohandler.IOHandler) error {
+	current, err := manager.getUser(user.Name)
+	if err != nil {
+		return err
+	}
+
+	if user.State == StateAbsent {
+		if current == nil {
+			output.AppendInfof("user %v: unchanged", user.Name)
+			return nil
+		}
+		if err = manager.deleteUser(user.Name, user.RemoveHome); err != nil {
+			return err
+		}
+		output.AppendInfof("user %v: removed", user.Name)
+		return nil
+	}
+
+	var changes []string
+	if current == nil {
+		password, err := userPassword(log, user)
+		if err != nil {
+			return err
+		}
+		err = manager.createUser(user, password)
+		password.Release()
+		if err != nil {
+			return err
+		}
+		if current, err = manager.getUser(user.Name); err != nil || current == nil {
+			return fmt.Errorf("user was created but cannot be read: %v", err)
+		}
+		changes = append(changes, "created")
+	} else {
+		if (user.Comment != "" && user.Comment != current.Comment) ||
+			(user.Shell != "" && user.Shell != current.Shell) ||
+			(user.Home != "" && user.Home != current.Home) {
+			if err = manager.updateUser(user); err != nil {
+				return err
+			}
+			changes = append(changes, "updated")
+		}
+		if user.Password != "" && user.UpdatePassword == UpdatePasswordAlways {
+			password, err := resolveParameter(log, user.Password)
+			if err != nil {
+				return fmt.Errorf("failed to resolve the password: %v", err)
+			}
+			err = manager.setPassword(user.Name, password)
+			password.Release()
+			if err != nil {
+				return err
+			}
+			changes = append(changes, "password set")
+		}
+	}
+
+	groupChanges, err := convergeMembership(manager, user, current)
+	if err != nil {
+		return err
+	}
+	changes = append(changes, groupChanges...)
+
+	if user.AuthorizedKeys != nil {
+		changed, err := manager.setAuthorizedKeys(current, user.AuthorizedKeys)
+		if err != nil {
+			return fmt.Errorf("failed to set authorized keys: %v", err)
+		}
+		if changed {
+			changes = append(changes, "authorized keys set")
+		}
+	}
+
+	if len(changes) == 0 {
+		output.AppendInfof("user %v: unchanged", user.Name)
+	} else {
+		output.AppendInfof("user %v: %v", user.Name, strings.Join(changes, ", "))
+	}
+	return nil
+}
+
+// convergeMembership adds the user to the listed groups and removes it from the others when membership is exclusive
+func convergeMembership(manager userManager, user UserInput, current *userInfo) (changes []string, err error) {
+	isMember := map[string]bool{}
+	for _, group := range current.Groups {
+		isMember[strings.ToLower(group)] = true
+	}
+	wanted := map[string]bool{}
+	for _, group := range user.Groups {
+		wanted[strings.ToLower(group)] = true
+		if !isMember[strings.ToLower(group)] {
+			if err = manager.addToGroup(user.Name, group); err != nil {
+				return changes, err
+			}
+			changes = append(changes, "added to "+group)
+		}
+	}
+	if !user.ExclusiveGroups {
+		return changes, nil
+	}
+	for _, group := range current.Groups {
+		if !wanted[strings.ToLower(group)] && !strings.EqualFold(group, current.PrimaryGroup) {
+			if err = manager.removeFromGroup(user.Name, group); err != nil {
+				return changes, err
+			}
+			changes = append(changes, "removed from "+group)
+		}
+	}
+	return changes, nil
+}
+
+// userPassword returns the password of a new user, users without a password get a random one they cannot log in with
+func userPassword(log log.T, user UserInput) (*secret.SecretValue, error) {
+	if user.Password != "" {
+		password, err := resolveParameter(log, user.Password)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve the password: %v", err)
+		}
+		return password, nil
+	}
+	random := make([]byte, 24)
+	if _, err := rand.Read(random); err != nil {
+		return nil, err
+	}
+	// the suffix satisfies the complexity rules of Windows password policies
+	password := make([]byte, base64.RawURLEncoding.EncodedLen(len(random)), base64.RawURLEncoding.EncodedLen(len(random))+4)
+	base64.RawURLEncoding.Encode(password, random)
+	secret.Zero(random)
+	return secret.New(append(password, "aA1!"...)), nil
+}
+
+// resolveParameterReference returns the value of a SecureString parameter reference
+func resolveParameterReference(log log.T, value string) (*secret.SecretValue, error) {
+	bridge := ssmparameterresolver.NewSsmParameterResolverBridge(ssmparameterresolver.NewService())
+	return bridge.GetSecretFromSsmParameterStore(log, value)
+}
+
+func isSecureReference(value string) bool {
+	return strings.HasPrefix(strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(value), "{{")), "ssm-secure:")
+}
+
+// parseAndValidateInput parses the plugin properties and fills in the defaults
+func parseAndValidateInput(rawPluginInput interface{}, manager userManager) (*ManageUsersPluginInput, error) {
+	var input ManageUsersPluginInput
+	if err := jsonutil.Remarshal(rawPluginInput, &input); err != nil {
+		// the properties are not included in the error since they hold password references
+		return nil, fmt.Errorf("invalid format in plugin properties; \nerror %v", err)
+	}
+	if err := validateInput(&input, manager); err != nil {
+		return nil, fmt.Errorf("invalid input: %v", err)
+	}
+	return &input, nil
+}
+
+// validateInput ensures the plugin input matches the defined schema
+func validateInput(input *ManageUsersPluginInput, manager userManager) error {
+	if len(input.Users) == 0 && len(input.Groups) == 0 {
+		return errors.New("at least one user or group must be specified")
+	}
+	for i := range input.Groups {
+		group := &input.Groups[i]
+		if !accountNamePattern.MatchString(group.Name) {
+			return fmt.Errorf("invalid group name %v", group.Name)
+		}
+		if group.State == "" {
+			group.State = StatePresent
+		}
+		if group.State != StatePresent && group.State != StateAbsent {
+			return fmt.Errorf("unsupported state %v for group %v", group.State, group.Name)
+		}
+	}
+	for i := range input.Users {
+		user := &input.Users[i]
+		if !accountNamePattern.MatchString(user.Name) {
+			return fmt.Errorf("invalid user name %v", user.Name)
+		}
+		if user.State == "" {
+			user.State = StatePresent
+		}
+		if user.UpdatePassword == "" {
+			user.UpdatePassword = UpdatePasswordOnCreate
+		}
+		switch {
+		case user.State != StatePresent && user.State != StateAbsent:
+			return fmt.Errorf("unsupported state %v for user %v", user.State, user.Name)
+		case user.UpdatePassword != UpdatePasswordOnCreate && user.UpdatePassword != UpdatePasswordAlways:
+			return fmt.Errorf("unsupported updatePassword %v for user %v", user.UpdatePassword, user.Name)
+		case user.Password != "" && !isSecureReference(user.Password):
+			return fmt.Errorf("password of user %v must be a SecureString parameter reference", user.Name)
+		}
+		for _, group := range user.Groups {
+			if !accountNamePattern.MatchString(group) {
+				return fmt.Errorf("invalid group name %v for user %v", group, user.Name)
+			}
+		}
+		for _, key := range user.AuthorizedKeys {
+			if strings.ContainsAny(key, "\r\n") || strings.TrimSpace(key) == "" {
+				return fmt.Errorf("authorized keys of user %v must be single non empty lines", user.Name)
+			}
+		}
+		if err := manager.validate(*user); err != nil {
+			return fmt.Errorf("user %v: %v", user.Name, err)
+		}
+	}
+	return nil
+}
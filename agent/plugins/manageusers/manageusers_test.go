@@ -0,0 +1,245 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package manageusers
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/iohandler"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/secret"
+	"github.com/stretchr/testify/assert"
+)
+
+var logger = log.NewMockLog()
+
+const testPasswordReference = "{{ssm-secure:/service/password}}"
+
+// fakeUserManager keeps users and groups in memory
+type fakeUserManager struct {
+	users     map[string]*userInfo
+	groups    map[string]bool
+	passwords map[string]string
+	keys      map[string][]string
+}
+
+func newFakeUserManager() *fakeUserManager {
+	return &fakeUserManager{
+		users:     map[string]*userInfo{},
+		groups:    map[string]bool{},
+		passwords: map[string]string{},
+		keys:      map[string][]string{},
+	}
+}
+
+func (f *fakeUserManager) validate(user UserInput) error {
+	if user.Shell == "unsupported" {
+		return errors.New("shell is not supported")
+	}
+	return nil
+}
+
+func (f *fakeUserManager) getUser(name string) (*userInfo, error) {
+	if user, ok := f.users[name]; ok {
+		copy := *user
+		copy.Groups = append([]string{}, user.Groups...)
+		return &copy, nil
+	}
+	return nil, nil
+}
+
+func (f *fakeUserManager) createUser(user UserInput, password *secret.SecretValue) error {
+	f.users[user.Name] = &userInfo{Name: user.Name, Comment: user.Comment, Shell: user.Shell, PrimaryGroup: user.Name, Groups: []string{user.Name}}
+	f.passwords[user.Name] = password.Reveal()
+	return nil
+}
+
+func (f *fakeUserManager) updateUser(user UserInput) error {
+	f.users[user.Name].Comment = user.Comment
+	return nil
+}
+
+func (f *fakeUserManager) deleteUser(name string, removeHome bool) error {
+	delete(f.users, name)
+	return nil
+}
+
+func (f *fakeUserManager) setPassword(name string, password *secret.SecretValue) error {
+	f.passwords[name] = password.Reveal()
+	return nil
+}
+
+func (f *fakeUserManager) groupExists(name string) (bool, error) {
+	return f.groups[name], nil
+}
+
+func (f *fakeUserManager) createGroup(group GroupInput) error {
+	f.groups[group.Name] = true
+	return nil
+}
+
+func (f *fakeUserManager) deleteGroup(name string) error {
+	delete(f.groups, name)
+	return nil
+}
+
+func (f *fakeUserManager) addToGroup(user string, group string) error {
+	if !f.groups[group] {
+		return errors.New("group " + group + " does not exist")
+	}
+	f.users[user].Groups = append(f.users[user].Groups, group)
+	return nil
+}
+
+func (f *fakeUserManager) removeFromGroup(user string, group string) error {
+	var groups []string
+	for _, member := range f.users[user].Groups {
+		if member != group {
+			groups = append(groups, member)
+		}
+	}
+	f.users[user].Groups = groups
+	return nil
+}
+
+func (f *fakeUserManager) setAuthorizedKeys(user *userInfo, keys []string) (bool, error) {
+	if len(f.keys[user.Name]) == len(keys) {
+		return false, nil
+	}
+	f.keys[user.Name] = keys
+	return true, nil
+}
+
+func mockResolveParameter() func() {
+	resolveParameterOrig := resolveParameter
+	resolveParameter = func(log log.T, value string) (*secret.SecretValue, error) {
+		if value == testPasswordReference {
+			return secret.FromString("S3cret!"), nil
+		}
+		return nil, errors.New("parameter not found")
+	}
+	return func() { resolveParameter = resolveParameterOrig }
+}
+
+func TestRunManageUsers(t *testing.T) {
+	defer mockResolveParameter()()
+	manager := newFakeUserManager()
+	manager.groups["wheel"] = true
+	manager.groups["legacy"] = true
+	manager.users["olduser"] = &userInfo{Name: "olduser"}
+
+	input, err := parseAndValidateInput(map[string]interface{}{
+		"groups": []interface{}{
+			map[string]interface{}{"name": "deploy", "system": true},
+			map[string]interface{}{"name": "legacy", "state": "absent"},
+		},
+		"users": []interface{}{
+			map[string]interface{}{
+				"name":           "svc-app",
+				"comment":        "application",
+				"system":         true,
+				"groups":         []interface{}{"deploy", "wheel"},
+				"password":       testPasswordReference,
+				"authorizedKeys": []interface{}{"ssh-ed25519 AAAA app@host"},
+			},
+			map[string]interface{}{"name": "olduser", "state": "absent", "removeHome": true},
+		},
+	}, manager)
+	assert.NoError(t, err)
+
+	output := iohandler.DefaultIOHandler{}
+	runManageUsers(logger, manager, input, &output)
+
+	assert.Equal(t, contracts.ResultStatusSuccess, output.GetStatus())
+	assert.Equal(t, []string{"svc-app", "deploy", "wheel"}, manager.users["svc-app"].Groups)
+	assert.Equal(t, "S3cret!", manager.passwords["svc-app"])
+	assert.NotContains(t, manager.users, "olduser")
+	assert.False(t, manager.groups["legacy"])
+	assert.True(t, manager.groups["deploy"])
+	assert.Contains(t, output.GetStdout(), "user svc-app: created, added to deploy, added to wheel, authorized keys set")
+	assert.Contains(t, output.GetStdout(), "group legacy: removed")
+	// the password is never written to the output
+	assert.NotContains(t, output.GetStdout()+output.GetStderr(), "S3cret!")
+
+	// a second run converges without changes
+	output = iohandler.DefaultIOHandler{}
+	runManageUsers(logger, manager, input, &output)
+	assert.Equal(t, contracts.ResultStatusSuccess, output.GetStatus())
+	assert.Contains(t, output.GetStdout(), "user svc-app: unchanged")
+	assert.Contains(t, output.GetStdout(), "group deploy: unchanged")
+}
+
+func TestConvergeUser_ExclusiveGroupsAndPassword(t *testing.T) {
+	defer mockResolveParameter()()
+	manager := newFakeUserManager()
+	manager.groups["web"] = true
+	manager.users["alice"] = &userInfo{Name: "alice", Comment: "old", PrimaryGroup: "alice", Groups: []string{"alice", "admins", "web"}}
+	manager.passwords["alice"] = "old"
+
+	user := UserInput{Name: "alice", State: StatePresent, Comment: "Alice", Groups: []string{"web"}, ExclusiveGroups: true,
+		Password: testPasswordReference, UpdatePassword: UpdatePasswordAlways}
+	output := iohandler.DefaultIOHandler{}
+	assert.NoError(t, convergeUser(logger, manager, user, &output))
+
+	assert.Equal(t, []string{"alice", "web"}, manager.users["alice"].Groups)
+	assert.Equal(t, "Alice", manager.users["alice"].Comment)
+	assert.Equal(t, "S3cret!", manager.passwords["alice"])
+	assert.Contains(t, output.GetStdout(), "user alice: updated, password set, removed from admins")
+}
+
+func TestConvergeUser_Failures(t *testing.T) {
+	defer mockResolveParameter()()
+	manager := newFakeUserManager()
+
+	output := iohandler.DefaultIOHandler{}
+	input := &ManageUsersPluginInput{Users: []UserInput{
+		{Name: "bob", State: StatePresent, Password: "{{ssm-secure:missing}}", UpdatePassword: UpdatePasswordOnCreate},
+		{Name: "carol", State: StatePresent, Groups: []string{"nogroup"}, UpdatePassword: UpdatePasswordOnCreate},
+	}}
+	runManageUsers(logger, manager, input, &output)
+
+	assert.Equal(t, contracts.ResultStatusFailed, output.GetStatus())
+	assert.NotContains(t, manager.users, "bob")
+	assert.Contains(t, output.GetStderr(), "user bob: failed to resolve the password")
+	assert.Contains(t, output.GetStderr(), "user carol: group nogroup does not exist")
+	assert.Contains(t, output.GetStderr(), "failed to apply 2 of 2 users and groups")
+	// users without a password get a random one
+	assert.NotEmpty(t, manager.passwords["carol"])
+}
+
+func TestValidateInput(t *testing.T) {
+	manager := newFakeUserManager()
+	input := ManageUsersPluginInput{Users: []UserInput{{Name: "svc"}}, Groups: []GroupInput{{Name: "team"}}}
+	assert.NoError(t, validateInput(&input, manager))
+	assert.Equal(t, StatePresent, input.Users[0].State)
+	assert.Equal(t, UpdatePasswordOnCreate, input.Users[0].UpdatePassword)
+	assert.Equal(t, StatePresent, input.Groups[0].State)
+
+	invalid := []ManageUsersPluginInput{
+		{},
+		{Users: []UserInput{{Name: "-rf"}}},
+		{Users: []UserInput{{Name: "svc", State: "locked"}}},
+		{Users: []UserInput{{Name: "svc", Password: "plaintext"}}},
+		{Users: []UserInput{{Name: "svc", UpdatePassword: "never"}}},
+		{Users: []UserInput{{Name: "svc", Groups: []string{"bad group"}}}},
+		{Users: []UserInput{{Name: "svc", AuthorizedKeys: []string{"key\nkey"}}}},
+		{Users: []UserInput{{Name: "svc", Shell: "unsupported"}}},
+		{Groups: []GroupInput{{Name: "team:x"}}},
+	}
+	for _, input := range invalid {
+		assert.Error(t, validateInput(&input, manager))
+	}
+}
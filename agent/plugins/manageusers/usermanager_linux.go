@@ -0,0 +1,232 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// +build linux
+
+package manageusers
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/aws/amazon-ssm-agent/agent/secret"
+)
+
+// runCommand runs a shadow-utils command, the input is passed on stdin so secrets never appear in the process list
+var runCommand = func(input []byte, name string, args ...string) (string, error) {
+	command := exec.Command(name, args...)
+	if len(input) > 0 {
+		command.Stdin = bytes.NewReader(input)
+	}
+	var stdout, stderr bytes.Buffer
+	command.Stdout = &stdout
+	command.Stderr = &stderr
+	if err := command.Run(); err != nil {
+		return stdout.String(), fmt.Errorf("%v failed: %v %v", name, err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.String(), nil
+}
+
+var chown = os.Chown
+
+// linuxUserManager uses the shadow-utils commands available on every distribution
+type linuxUserManager struct{}
+
+func newPlatformUserManager() (userManager, error) {
+	return linuxUserManager{}, nil
+}
+
+func (linuxUserManager) validate(user UserInput) error {
+	return nil
+}
+
+func (linuxUserManager) getUser(name string) (*userInfo, error) {
+	entry, ok, err := getent("passwd", name)
+	if err != nil || !ok {
+		return nil, err
+	}
+	// name:password:uid:gid:gecos:home:shell
+	fields := strings.Split(entry, ":")
+	if len(fields) != 7 {
+		return nil, fmt.Errorf("unexpected passwd entry for %v", name)
+	}
+	user := &userInfo{Name: name, Comment: fields[4], Home: fields[5], Shell: fields[6]}
+
+	if user.PrimaryGroup, err = runCommand(nil, "id", "-gn", name); err != nil {
+		return nil, err
+	}
+	user.PrimaryGroup = strings.TrimSpace(user.PrimaryGroup)
+	groups, err := runCommand(nil, "id", "-Gn", name)
+	if err != nil {
+		return nil, err
+	}
+	user.Groups = strings.Fields(groups)
+	return user, nil
+}
+
+func (linuxUserManager) createUser(user UserInput, password *secret.SecretValue) error {
+	args := []string{}
+	if user.System {
+		args = append(args, "--system")
+	} else {
+		args = append(args, "--create-home")
+	}
+	if user.Comment != "" {
+		args = append(args, "--comment", user.Comment)
+	}
+	if user.Shell != "" {
+		args = append(args, "--shell", user.Shell)
+	}
+	if user.Home != "" {
+		args = append(args, "--home-dir", user.Home)
+	}
+	if _, err := runCommand(nil, "useradd", append(args, user.Name)...); err != nil {
+		return err
+	}
+	if user.Password == "" {
+		// the account keeps the locked password set by useradd
+		return nil
+	}
+	return linuxUserManager{}.setPassword(user.Name, password)
+}
+
+func (linuxUserManager) updateUser(user UserInput) error {
+	args := []string{}
+	if user.Comment != "" {
+		args = append(args, "--comment", user.Comment)
+	}
+	if user.Shell != "" {
+		args = append(args, "--shell", user.Shell)
+	}
+	if user.Home != "" {
+		args = append(args, "--home", user.Home, "--move-home")
+	}
+	_, err := runCommand(nil, "usermod", append(args, user.Name)...)
+	return err
+}
+
+func (linuxUserManager) deleteUser(name string, removeHome bool) error {
+	args := []string{name}
+	if removeHome {
+		args = []string{"--remove", name}
+	}
+	_, err := runCommand(nil, "userdel", args...)
+	return err
+}
+
+func (linuxUserManager) setPassword(name string, password *secret.SecretValue) error {
+	// the input is built without converting the password to a string so that it can be zeroed
+	input := append(append([]byte(name+":"), password.Bytes()...), '\n')
+	defer secret.Zero(input)
+	_, err := runCommand(input, "chpasswd")
+	return err
+}
+
+func (linuxUserManager) groupExists(name string) (bool, error) {
+	_, ok, err := getent("group", name)
+	return ok, err
+}
+
+func (linuxUserManager) createGroup(group GroupInput) error {
+	args := []string{group.Name}
+	if group.System {
+		args = []string{"--system", group.Name}
+	}
+	_, err := runCommand(nil, "groupadd", args...)
+	return err
+}
+
+func (linuxUserManager) deleteGroup(name string) error {
+	_, err := runCommand(nil, "groupdel", name)
+	return err
+}
+
+func (linuxUserManager) addToGroup(user string, group string) error {
+	_, err := runCommand(nil, "usermod", "--append", "--groups", group, user)
+	return err
+}
+
+func (linuxUserManager) removeFromGroup(user string, group string) error {
+	_, err := runCommand(nil, "gpasswd", "--delete", user, group)
+	return err
+}
+
+// setAuthorizedKeys writes ~/.ssh/authorized_keys owned by the user with the permissions required by sshd
+func (linuxUserManager) setAuthorizedKeys(user *userInfo, keys []string) (bool, error) {
+	if user.Home == "" {
+		return false, fmt.Errorf("user has no home directory")
+	}
+	sshDir := filepath.Join(user.Home, ".ssh")
+	keysFile := filepath.Join(sshDir, "authorized_keys")
+	content := ""
+	if len(keys) > 0 {
+		content = strings.Join(keys, "\n") + "\n"
+	}
+	if existing, err := ioutil.ReadFile(keysFile); err == nil && string(existing) == content {
+		return false, nil
+	}
+
+	uid, gid, err := lookupIds(user.Name)
+	if err != nil {
+		return false, err
+	}
+	if err = os.MkdirAll(sshDir, 0700); err != nil {
+		return false, err
+	}
+	if err = chown(sshDir, uid, gid); err != nil {
+		return false, err
+	}
+	// the keys are written to a temporary file first so sshd never reads a partial file
+	tempFile := keysFile + ".tmp"
+	if err = ioutil.WriteFile(tempFile, []byte(content), 0600); err != nil {
+		return false, err
+	}
+	if err = chown(tempFile, uid, gid); err != nil {
+		os.Remove(tempFile)
+		return false, err
+	}
+	return true, os.Rename(tempFile, keysFile)
+}
+
+func lookupIds(name string) (uid int, gid int, err error) {
+	var output string
+	if output, err = runCommand(nil, "id", "-u", name); err != nil {
+		return
+	}
+	if uid, err = strconv.Atoi(strings.TrimSpace(output)); err != nil {
+		return
+	}
+	if output, err = runCommand(nil, "id", "-g", name); err != nil {
+		return
+	}
+	gid, err = strconv.Atoi(strings.TrimSpace(output))
+	return
+}
+
+// getent returns the entry of a name in a database, getent exits with 2 when the name is not found
+func getent(database string, name string) (entry string, found bool, err error) {
+	output, err := runCommand(nil, "getent", database, name)
+	if err != nil {
+		if strings.Contains(err.Error(), "exit status 2") {
+			return "", false, nil
+		}
+		return "", false, err
+	}
+	return strings.TrimSpace(output), true, nil
+}
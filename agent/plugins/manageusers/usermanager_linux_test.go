@@ -0,0 +1,99 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// +build linux
+
+package manageusers
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aws/amazon-ssm-agent/agent/secret"
+	"github.com/stretchr/testify/assert"
+)
+
+// mockCommands answers the commands run by the linux user manager and records them with their input
+func mockCommands(outputs map[string]string) (*[]string, func()) {
+	runCommandOrig, chownOrig := runCommand, chown
+	var commands []string
+	runCommand = func(input []byte, name string, args ...string) (string, error) {
+		command := strings.Join(append([]string{name}, args...), " ")
+		commands = append(commands, command+"|"+string(input))
+		if output, ok := outputs[command]; ok {
+			return output, nil
+		}
+		if name == "getent" {
+			return "", errors.New("getent failed: exit status 2")
+		}
+		return "", nil
+	}
+	chown = func(name string, uid int, gid int) error { return nil }
+	return &commands, func() { runCommand, chown = runCommandOrig, chownOrig }
+}
+
+func TestLinuxGetUser(t *testing.T) {
+	_, restore := mockCommands(map[string]string{
+		"getent passwd alice": "alice:x:1001:1001:Alice:/home/alice:/bin/bash\n",
+		"id -gn alice":        "alice\n",
+		"id -Gn alice":        "alice wheel docker\n",
+	})
+	defer restore()
+
+	user, err := linuxUserManager{}.getUser("alice")
+	assert.NoError(t, err)
+	assert.Equal(t, &userInfo{Name: "alice", Comment: "Alice", Home: "/home/alice", Shell: "/bin/bash",
+		PrimaryGroup: "alice", Groups: []string{"alice", "wheel", "docker"}}, user)
+
+	user, err = linuxUserManager{}.getUser("bob")
+	assert.NoError(t, err)
+	assert.Nil(t, user)
+}
+
+func TestLinuxCreateUser_PasswordOnStdin(t *testing.T) {
+	commands, restore := mockCommands(map[string]string{})
+	defer restore()
+
+	err := linuxUserManager{}.createUser(UserInput{Name: "svc", System: true, Shell: "/sbin/nologin", Password: testPasswordReference}, secret.FromString("S3cret!"))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"useradd --system --shell /sbin/nologin svc|", "chpasswd|svc:S3cret!\n"}, *commands)
+}
+
+func TestLinuxSetAuthorizedKeys(t *testing.T) {
+	home, err := ioutil.TempDir("", "home")
+	assert.NoError(t, err)
+	defer os.RemoveAll(home)
+	_, restore := mockCommands(map[string]string{"id -u svc": "1001\n", "id -g svc": "1001\n"})
+	defer restore()
+
+	user := &userInfo{Name: "svc", Home: home}
+	changed, err := linuxUserManager{}.setAuthorizedKeys(user, []string{"ssh-ed25519 AAAA one", "ssh-rsa BBBB two"})
+	assert.NoError(t, err)
+	assert.True(t, changed)
+
+	keysFile := filepath.Join(home, ".ssh", "authorized_keys")
+	content, err := ioutil.ReadFile(keysFile)
+	assert.NoError(t, err)
+	assert.Equal(t, "ssh-ed25519 AAAA one\nssh-rsa BBBB two\n", string(content))
+	info, err := os.Stat(keysFile)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	changed, err = linuxUserManager{}.setAuthorizedKeys(user, []string{"ssh-ed25519 AAAA one", "ssh-rsa BBBB two"})
+	assert.NoError(t, err)
+	assert.False(t, changed)
+}
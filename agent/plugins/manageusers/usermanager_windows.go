@@ -0,0 +1,335 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+//go:build windows
+// +build windows
+
+package manageusers
+
+import (
+	"errors"
+	"fmt"
+	"syscall"
+	"unicode"
+	"unicode/utf16"
+	"unicode/utf8"
+	"unsafe"
+
+	"github.com/aws/amazon-ssm-agent/agent/secret"
+	"golang.org/x/sys/windows"
+)
+
+const (
+	nerrSuccess            = 0
+	serverNameLocalMachine = 0
+	userPrivUser           = 1
+	ufScript               = 0x1
+	ufDontExpirePasswd     = 0x10000
+	maxPreferredLength     = 0xFFFFFFFF
+
+	levelForUserInfo1              = 1
+	levelForUserInfo1003           = 1003
+	levelForUserInfo1007           = 1007
+	levelForLocalGroupInfo0        = 0
+	levelForLocalGroupUsersInfo0   = 0
+	levelForLocalGroupMembersInfo3 = 3
+
+	errCodeForGroupNotFound        = 2220
+	errCodeForUserNotFound         = 2221
+	errCodeForNoSuchAlias          = 1376
+	errCodeForMemberNotInAlias     = 1377
+	errCodeForMemberAlreadyInAlias = 1378
+)
+
+type userInfo1 struct {
+	name        *uint16
+	password    *uint16
+	passwordAge uint32
+	priv        uint32
+	homeDir     *uint16
+	comment     *uint16
+	flags       uint32
+	scriptPath  *uint16
+}
+
+type userInfo1003 struct {
+	password *uint16
+}
+
+type userInfo1007 struct {
+	comment *uint16
+}
+
+type localGroupInfo0 struct {
+	name *uint16
+}
+
+type localGroupUsersInfo0 struct {
+	name *uint16
+}
+
+type localGroupMembersInfo3 struct {
+	domainAndName *uint16
+}
+
+var (
+	modNetapi32             = syscall.NewLazyDLL("netapi32.dll")
+	netUserAdd              = modNetapi32.NewProc("NetUserAdd")
+	netUserDel              = modNetapi32.NewProc("NetUserDel")
+	netUserGetInfo          = modNetapi32.NewProc("NetUserGetInfo")
+	netUserSetInfo          = modNetapi32.NewProc("NetUserSetInfo")
+	netUserGetLocalGroups   = modNetapi32.NewProc("NetUserGetLocalGroups")
+	netLocalGroupAdd        = modNetapi32.NewProc("NetLocalGroupAdd")
+	netLocalGroupDel        = modNetapi32.NewProc("NetLocalGroupDel")
+	netLocalGroupGetInfo    = modNetapi32.NewProc("NetLocalGroupGetInfo")
+	netLocalGroupAddMembers = modNetapi32.NewProc("NetLocalGroupAddMembers")
+	netLocalGroupDelMembers = modNetapi32.NewProc("NetLocalGroupDelMembers")
+	netApiBufferFree        = modNetapi32.NewProc("NetApiBufferFree")
+)
+
+// windowsUserManager uses the NetUser and NetLocalGroup functions of netapi32.dll
+type windowsUserManager struct{}
+
+func newPlatformUserManager() (userManager, error) {
+	return windowsUserManager{}, nil
+}
+
+func (windowsUserManager) validate(user UserInput) error {
+	switch {
+	case user.Shell != "" || user.Home != "":
+		return errors.New("shell and home are not supported on Windows")
+	case user.AuthorizedKeys != nil:
+		// the profile directory holding the keys is only created by the first logon of the user
+		return errors.New("authorizedKeys are not supported on Windows")
+	case user.RemoveHome:
+		return errors.New("removeHome is not supported on Windows")
+	}
+	return nil
+}
+
+func (windowsUserManager) getUser(name string) (*userInfo, error) {
+	namePtr, err := syscall.UTF16PtrFromString(name)
+	if err != nil {
+		return nil, err
+	}
+	var buffer *userInfo1
+	ret, _, _ := netUserGetInfo.Call(serverNameLocalMachine, uintptr(unsafe.Pointer(namePtr)), levelForUserInfo1, uintptr(unsafe.Pointer(&buffer)))
+	if ret == errCodeForUserNotFound {
+		return nil, nil
+	} else if ret != nerrSuccess {
+		return nil, fmt.Errorf("NetUserGetInfo failed with error %v", ret)
+	}
+	user := &userInfo{Name: name, Comment: utf16PtrToString(buffer.comment)}
+	netApiBufferFree.Call(uintptr(unsafe.Pointer(buffer)))
+
+	var groups unsafe.Pointer
+	var entriesRead, totalEntries uint32
+	ret, _, _ = netUserGetLocalGroups.Call(serverNameLocalMachine, uintptr(unsafe.Pointer(namePtr)), levelForLocalGroupUsersInfo0, 0,
+		uintptr(unsafe.Pointer(&groups)), maxPreferredLength, uintptr(unsafe.Pointer(&entriesRead)), uintptr(unsafe.Pointer(&totalEntries)))
+	if ret != nerrSuccess {
+		return nil, fmt.Errorf("NetUserGetLocalGroups failed with error %v", ret)
+	}
+	defer netApiBufferFree.Call(uintptr(groups))
+	for i := uint32(0); i < entriesRead; i++ {
+		entry := (*localGroupUsersInfo0)(unsafe.Pointer(uintptr(groups) + uintptr(i)*unsafe.Sizeof(localGroupUsersInfo0{})))
+		user.Groups = append(user.Groups, utf16PtrToString(entry.name))
+	}
+	return user, nil
+}
+
+func (windowsUserManager) createUser(user UserInput, password *secret.SecretValue) error {
+	namePtr, err := syscall.UTF16PtrFromString(user.Name)
+	if err != nil {
+		return err
+	}
+	passwordUTF16, err := utf16FromSecret(password)
+	if err != nil {
+		return err
+	}
+	defer zeroUTF16(passwordUTF16)
+	passwordPtr := &passwordUTF16[0]
+	commentPtr, err := syscall.UTF16PtrFromString(user.Comment)
+	if err != nil {
+		return err
+	}
+	info := userInfo1{name: namePtr, password: passwordPtr, priv: userPrivUser, comment: commentPtr, flags: ufScript}
+	if user.System {
+		info.flags |= ufDontExpirePasswd
+	}
+	var errParam uint32
+	if ret, _, _ := netUserAdd.Call(serverNameLocalMachine, levelForUserInfo1, uintptr(unsafe.Pointer(&info)), uintptr(unsafe.Pointer(&errParam))); ret != nerrSuccess {
+		return fmt.Errorf("NetUserAdd failed with error %v", ret)
+	}
+	return nil
+}
+
+func (windowsUserManager) updateUser(user UserInput) error {
+	commentPtr, err := syscall.UTF16PtrFromString(user.Comment)
+	if err != nil {
+		return err
+	}
+	return setUserInfo(user.Name, levelForUserInfo1007, unsafe.Pointer(&userInfo1007{comment: commentPtr}))
+}
+
+func (windowsUserManager) deleteUser(name string, removeHome bool) error {
+	namePtr, err := syscall.UTF16PtrFromString(name)
+	if err != nil {
+		return err
+	}
+	if ret, _, _ := netUserDel.Call(serverNameLocalMachine, uintptr(unsafe.Pointer(namePtr))); ret != nerrSuccess {
+		return fmt.Errorf("NetUserDel failed with error %v", ret)
+	}
+	return nil
+}
+
+func (windowsUserManager) setPassword(name string, password *secret.SecretValue) error {
+	passwordUTF16, err := utf16FromSecret(password)
+	if err != nil {
+		return err
+	}
+	defer zeroUTF16(passwordUTF16)
+	return setUserInfo(name, levelForUserInfo1003, unsafe.Pointer(&userInfo1003{password: &passwordUTF16[0]}))
+}
+
+// utf16FromSecret encodes the password for the Windows APIs without converting it to a string, like
+// syscall.UTF16PtrFromString it rejects passwords containing a NUL
+func utf16FromSecret(password *secret.SecretValue) ([]uint16, error) {
+	value := password.Bytes()
+	encoded := make([]uint16, 0, len(value)+1)
+	for len(value) > 0 {
+		r, size := utf8.DecodeRune(value)
+		if r == 0 {
+			zeroUTF16(encoded)
+			return nil, syscall.EINVAL
+		}
+		if r1, r2 := utf16.EncodeRune(r); r1 != unicode.ReplacementChar {
+			encoded = append(encoded, uint16(r1), uint16(r2))
+		} else {
+			encoded = append(encoded, uint16(r))
+		}
+		value = value[size:]
+	}
+	return append(encoded, 0), nil
+}
+
+func zeroUTF16(value []uint16) {
+	for i := range value {
+		value[i] = 0
+	}
+}
+
+func (windowsUserManager) groupExists(name string) (bool, error) {
+	namePtr, err := syscall.UTF16PtrFromString(name)
+	if err != nil {
+		return false, err
+	}
+	var buffer unsafe.Pointer
+	ret, _, _ := netLocalGroupGetInfo.Call(serverNameLocalMachine, uintptr(unsafe.Pointer(namePtr)), levelForLocalGroupInfo0, uintptr(unsafe.Pointer(&buffer)))
+	switch ret {
+	case nerrSuccess:
+		netApiBufferFree.Call(uintptr(buffer))
+		return true, nil
+	case errCodeForGroupNotFound, errCodeForNoSuchAlias:
+		return false, nil
+	default:
+		return false, fmt.Errorf("NetLocalGroupGetInfo failed with error %v", ret)
+	}
+}
+
+func (windowsUserManager) createGroup(group GroupInput) error {
+	namePtr, err := syscall.UTF16PtrFromString(group.Name)
+	if err != nil {
+		return err
+	}
+	var errParam uint32
+	info := localGroupInfo0{name: namePtr}
+	if ret, _, _ := netLocalGroupAdd.Call(serverNameLocalMachine, levelForLocalGroupInfo0, uintptr(unsafe.Pointer(&info)), uintptr(unsafe.Pointer(&errParam))); ret != nerrSuccess {
+		return fmt.Errorf("NetLocalGroupAdd failed with error %v", ret)
+	}
+	return nil
+}
+
+func (windowsUserManager) deleteGroup(name string) error {
+	namePtr, err := syscall.UTF16PtrFromString(name)
+	if err != nil {
+		return err
+	}
+	if ret, _, _ := netLocalGroupDel.Call(serverNameLocalMachine, uintptr(unsafe.Pointer(namePtr))); ret != nerrSuccess {
+		return fmt.Errorf("NetLocalGroupDel failed with error %v", ret)
+	}
+	return nil
+}
+
+func (windowsUserManager) addToGroup(user string, group string) error {
+	ret, err := changeMembership(netLocalGroupAddMembers, user, group)
+	if err != nil {
+		return err
+	}
+	if ret != nerrSuccess && ret != errCodeForMemberAlreadyInAlias {
+		return fmt.Errorf("NetLocalGroupAddMembers failed with error %v", ret)
+	}
+	return nil
+}
+
+func (windowsUserManager) removeFromGroup(user string, group string) error {
+	ret, err := changeMembership(netLocalGroupDelMembers, user, group)
+	if err != nil {
+		return err
+	}
+	if ret != nerrSuccess && ret != errCodeForMemberNotInAlias {
+		return fmt.Errorf("NetLocalGroupDelMembers failed with error %v", ret)
+	}
+	return nil
+}
+
+func (windowsUserManager) setAuthorizedKeys(user *userInfo, keys []string) (bool, error) {
+	return false, errors.New("authorizedKeys are not supported on Windows")
+}
+
+// utf16PtrToString converts a NUL terminated string returned by netapi32.dll
+func utf16PtrToString(pointer *uint16) string {
+	if pointer == nil {
+		return ""
+	}
+	var chars []uint16
+	for address := unsafe.Pointer(pointer); *(*uint16)(address) != 0; address = unsafe.Pointer(uintptr(address) + unsafe.Sizeof(*pointer)) {
+		chars = append(chars, *(*uint16)(address))
+	}
+	return windows.UTF16ToString(chars)
+}
+
+func setUserInfo(name string, level uintptr, info unsafe.Pointer) error {
+	namePtr, err := syscall.UTF16PtrFromString(name)
+	if err != nil {
+		return err
+	}
+	var errParam uint32
+	if ret, _, _ := netUserSetInfo.Call(serverNameLocalMachine, uintptr(unsafe.Pointer(namePtr)), level, uintptr(info), uintptr(unsafe.Pointer(&errParam))); ret != nerrSuccess {
+		return fmt.Errorf("NetUserSetInfo failed with error %v", ret)
+	}
+	return nil
+}
+
+func changeMembership(proc *syscall.LazyProc, user string, group string) (uintptr, error) {
+	groupPtr, err := syscall.UTF16PtrFromString(group)
+	if err != nil {
+		return 0, err
+	}
+	userPtr, err := syscall.UTF16PtrFromString(user)
+	if err != nil {
+		return 0, err
+	}
+	member := localGroupMembersInfo3{domainAndName: userPtr}
+	ret, _, _ := proc.Call(serverNameLocalMachine, uintptr(unsafe.Pointer(groupPtr)), levelForLocalGroupMembersInfo3, uintptr(unsafe.Pointer(&member)), 1)
+	return ret, nil
+}
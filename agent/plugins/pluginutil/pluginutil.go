@@ -17,6 +17,7 @@ package pluginutil
 import (
 	"io"
 	"io/ioutil"
+	"path/filepath"
 	"strconv"
 	"strings"
 
@@ -54,6 +55,25 @@ func StringPrefix(input string, maxLength int, truncatedSuffix string) string {
 	return truncatedSuffix[:maxLength]
 }
 
+// TruncateString truncates a string to the given limit with the truncation strategy, which keeps the head,
+// the tail or both ends of the string around the truncation marker. Unknown strategies keep the head.
+func TruncateString(input string, maxLength int, truncatedSuffix string, strategy string) string {
+	if len(input) < maxLength || maxLength <= len(truncatedSuffix) {
+		return StringPrefix(input, maxLength, truncatedSuffix)
+	}
+
+	available := maxLength - len(truncatedSuffix)
+	switch strategy {
+	case appconfig.OutputTruncationTail:
+		return truncatedSuffix + input[len(input)-available:]
+	case appconfig.OutputTruncationHeadTail:
+		head := available / 2
+		return input[:head] + truncatedSuffix + input[len(input)-(available-head):]
+	default:
+		return StringPrefix(input, maxLength, truncatedSuffix)
+	}
+}
+
 // ReadPrefix returns the beginning data from a given Reader, truncated to the given limit.
 func ReadPrefix(input io.Reader, maxLength int, truncatedSuffix string) (out string, err error) {
 	// read up to maxLength bytes from input
@@ -77,6 +97,19 @@ func ReadAll(input io.Reader, maxLength int, truncatedSuffix string) (out string
 	return string(data), nil
 }
 
+// ScratchDirEnvVar is the environment variable of the scripts and reboot hooks that holds the scratch directory of the document
+const ScratchDirEnvVar = "SSM_DOCUMENT_SCRATCH"
+
+// ScratchDirectory returns the scratch directory the steps of a document share to keep data across reboots, it is
+// removed with the orchestration directory of the document. orchestrationDirectory is the one of a step, steps
+// without an absolute orchestration directory have no scratch directory.
+func ScratchDirectory(orchestrationDirectory string) string {
+	if !filepath.IsAbs(orchestrationDirectory) {
+		return ""
+	}
+	return filepath.Join(filepath.Dir(orchestrationDirectory), ".scratch")
+}
+
 // CreateScriptFile creates a script containing the given commands.
 func CreateScriptFile(log log.T, scriptPath string, runCommand []string, byteOrderMark fileutil.ByteOrderMark) (err error) {
 	// write source commands to file
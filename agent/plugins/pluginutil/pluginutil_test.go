@@ -16,9 +16,12 @@ package pluginutil
 
 import (
 	"bytes"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
 	"github.com/aws/amazon-ssm-agent/agent/log"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
@@ -70,6 +73,19 @@ func testTruncatedString(t *testing.T, original string, truncated string, trunca
 	}
 }
 
+func TestTruncateString(t *testing.T) {
+	input := "0123456789abcdefghij"
+	suffix := "-z-"
+
+	assert.Equal(t, input, TruncateString(input, 21, suffix, appconfig.OutputTruncationTail))
+	assert.Equal(t, "0123456-z-", TruncateString(input, 10, suffix, appconfig.OutputTruncationHead))
+	assert.Equal(t, "-z-defghij", TruncateString(input, 10, suffix, appconfig.OutputTruncationTail))
+	assert.Equal(t, "012-z-ghij", TruncateString(input, 10, suffix, appconfig.OutputTruncationHeadTail))
+	assert.Equal(t, "0123456-z-", TruncateString(input, 10, suffix, "unknown"))
+	// suffix doesn't fit, expect a prefix of the suffix
+	assert.Equal(t, "-z", TruncateString(input, 2, suffix, appconfig.OutputTruncationTail))
+}
+
 func TestValidateExecutionTimeout(t *testing.T) {
 	logger := log.NewMockLog()
 	logger.On("Error", mock.Anything).Return(nil)
@@ -190,3 +206,10 @@ func TestCleanupJSONField(t *testing.T) {
 		assert.Equal(t, output, result)
 	}
 }
+
+func TestScratchDirectory(t *testing.T) {
+	documentDir := filepath.Join(os.TempDir(), "orchestration", "commandID")
+	assert.Equal(t, filepath.Join(documentDir, ".scratch"), ScratchDirectory(filepath.Join(documentDir, "installUpdates")))
+	assert.Equal(t, "", ScratchDirectory("installUpdates"))
+	assert.Equal(t, "", ScratchDirectory(""))
+}
@@ -0,0 +1,89 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package putcomplianceitems implements the aws:putComplianceItems plugin.
+package putcomplianceitems
+
+import (
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/association/compliance/model"
+	"github.com/aws/amazon-ssm-agent/agent/context"
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/iohandler"
+	"github.com/aws/amazon-ssm-agent/agent/jsonutil"
+	"github.com/aws/amazon-ssm-agent/agent/task"
+)
+
+// PutComplianceItemsPluginInput is the custom compliance type a step reports with its items
+type PutComplianceItemsPluginInput struct {
+	contracts.PluginInput
+	ComplianceType string                       `json:"complianceType"`
+	Items          []model.CustomComplianceItem `json:"items"`
+}
+
+// PutComplianceItemsPluginOutput is the validated report the agent submits once the document completes, with its summary
+type PutComplianceItemsPluginOutput struct {
+	model.CustomComplianceReport
+	Summary model.CustomComplianceSummary
+}
+
+// Plugin is the type for the putComplianceItems plugin.
+// The worker validates the items of the step, the agent links them to the command or association of the document
+// and batches them with the items other documents report.
+type Plugin struct {
+}
+
+// NewPlugin returns putComplianceItems
+func NewPlugin() (*Plugin, error) {
+	return &Plugin{}, nil
+}
+
+func (p *Plugin) Execute(context context.T, config contracts.Configuration, cancelFlag task.CancelFlag, output iohandler.IOHandler) {
+	log := context.Log()
+	log.Infof("%v started with configuration %v", Name(), config)
+	if cancelFlag.ShutDown() {
+		output.MarkAsShutdown()
+	} else if cancelFlag.Canceled() {
+		output.MarkAsCancelled()
+	} else {
+		runPutComplianceItems(config.Properties, output)
+	}
+	return
+}
+
+func runPutComplianceItems(rawPluginInput interface{}, output iohandler.IOHandler) {
+	var input PutComplianceItemsPluginInput
+	if err := jsonutil.Remarshal(rawPluginInput, &input); err != nil {
+		output.MarkAsFailed(err)
+		return
+	}
+
+	report := model.CustomComplianceReport{ComplianceType: input.ComplianceType, Items: input.Items}
+	if err := model.ValidateCustomComplianceReport(report); err != nil {
+		output.AppendErrorf("\nputComplianceItems input invalid: %v", err.Error())
+		output.SetStatus(contracts.ResultStatusFailed)
+		return
+	}
+
+	summary := model.SummarizeCustomCompliance(report)
+	output.AppendInfof("%v: %v compliant and %v non compliant items", report.ComplianceType, summary.CompliantCount, summary.NonCompliantCount)
+	// the agent queues the report for PutComplianceItems once the document completes
+	output.SetOutput(PutComplianceItemsPluginOutput{CustomComplianceReport: report, Summary: summary})
+	output.SetStatus(contracts.ResultStatusSuccess)
+	return
+}
+
+// Name returns the plugin name
+func Name() string {
+	return appconfig.PluginNameAwsPutComplianceItems
+}
@@ -36,7 +36,7 @@ type ExecDocument interface {
 		s3Bucket string, s3KeyPrefix string, messageID string, documentID string, defaultWorkingDirectory string,
 		params map[string]interface{}) (pluginsInfo []contracts.PluginState, err error)
 	ExecuteDocument(config contracts.Configuration, context context.T, pluginInput []contracts.PluginState, documentID string,
-		documentCreatedDate string) (chan contracts.DocumentResult, error)
+		documentCreatedDate string, cancelFlag task.CancelFlag) (chan contracts.DocumentResult, error)
 }
 
 type ExecDocumentImpl struct {
@@ -52,6 +52,9 @@ func (exec ExecDocumentImpl) ParseDocument(log log.T, documentRaw []byte, orches
 	if err := json.Unmarshal(documentRaw, &docContent); err != nil {
 		if err := yaml.Unmarshal(documentRaw, &docContent); err != nil {
 			log.Error("Unmarshaling remote resource document failed. Please make sure the document is in the correct JSON or YAML formal")
+			if validationErr, ok := docparser.ValidateDocument(documentRaw).(docparser.ValidationErrors); ok {
+				return pluginsInfo, validationErr
+			}
 			return pluginsInfo, err
 		}
 	}
@@ -70,9 +73,10 @@ func (exec ExecDocumentImpl) ParseDocument(log log.T, documentRaw []byte, orches
 	return
 }
 
-// ExecuteDocument is responsible to execute the sub-documents that are created or downloaded by the executeCommand plugin
+// ExecuteDocument is responsible to execute the sub-documents that are created or downloaded by the executeCommand plugin.
+// The sub-document shares the cancel flag of the plugin, so cancelling the command also cancels the steps of the sub-document.
 func (exec ExecDocumentImpl) ExecuteDocument(config contracts.Configuration, context context.T, pluginInput []contracts.PluginState, documentID string,
-	documentCreatedDate string) (resultChannels chan contracts.DocumentResult, err error) {
+	documentCreatedDate string, cancelFlag task.CancelFlag) (resultChannels chan contracts.DocumentResult, err error) {
 	log := context.Log()
 	log.Info("Running sub-document")
 
@@ -92,15 +96,15 @@ func (exec ExecDocumentImpl) ExecuteDocument(config contracts.Configuration, con
 		},
 		InstancePluginsInformation: pluginInput,
 	}
-	//specify the sub-document's bookkeeping location
+	//specify the sub-document's bookkeeping location, its states are kept in memory since the state store of the
+	//instance belongs to the agent process
 	instanceID, err := instance.InstanceID()
 	if err != nil {
 		log.Error("failed to load instance id")
 		return resultChannels, err
 	}
 	docStore := executer.NewDocumentFileStore(context, documentID, instanceID, appconfig.DefaultLocationOfCurrent,
-		&docState, docmanager.NewDocumentFileMgr(appconfig.DefaultDataStorePath, appconfig.DefaultDocumentRootDirName, appconfig.DefaultLocationOfState))
-	cancelFlag := task.NewChanneledCancelFlag()
+		&docState, docmanager.NewMemoryDocumentMgr())
 	resultChannels = exec.DocExecutor.Run(cancelFlag, &docStore)
 
 	return resultChannels, nil
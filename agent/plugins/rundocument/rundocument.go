@@ -106,12 +106,12 @@ func (p *Plugin) execute(context context.T, config contracts.Configuration, canc
 	} else if input, err := parseAndValidateInput(config.Properties); err != nil {
 		output.MarkAsFailed(err)
 	} else {
-		p.runDocument(context, input, config, output)
+		p.runDocument(context, input, config, cancelFlag, output)
 	}
 }
 
 // runCopyContent figures out the type of location, downloads the resource, saves it on disk and returns information required for it
-func (p *Plugin) runDocument(context context.T, input *RunDocumentPluginInput, config contracts.Configuration, output iohandler.IOHandler) {
+func (p *Plugin) runDocument(context context.T, input *RunDocumentPluginInput, config contracts.Configuration, cancelFlag task.CancelFlag, output iohandler.IOHandler) {
 
 	log := context.Log()
 	//Run aws:runDocument plugin
@@ -163,8 +163,9 @@ func (p *Plugin) runDocument(context context.T, input *RunDocumentPluginInput, c
 
 	var resultsChannel chan contracts.DocumentResult
 	var pluginOutput map[string]*contracts.PluginResult
-	if resultsChannel, err = p.execDoc.ExecuteDocument(config, context, pluginsInfo, config.BookKeepingFileName, times.ToIso8601UTC(time.Now())); err != nil {
+	if resultsChannel, err = p.execDoc.ExecuteDocument(config, context, pluginsInfo, config.BookKeepingFileName, times.ToIso8601UTC(time.Now()), cancelFlag); err != nil {
 		output.MarkAsFailed(fmt.Errorf("There was an error while running documents - %v", err.Error()))
+		return
 	}
 	for res := range resultsChannel {
 		if res.LastPlugin == "" {
@@ -189,6 +190,12 @@ func (p *Plugin) runDocument(context context.T, input *RunDocumentPluginInput, c
 		}
 		output.SetStatus(contracts.MergeResultStatus(output.GetStatus(), pluginOut.Status))
 	}
+	// the steps of the sub-document stop at the cancel flag of this plugin, report the cancellation like any other step
+	if cancelFlag.ShutDown() {
+		output.MarkAsShutdown()
+	} else if cancelFlag.Canceled() {
+		output.MarkAsCancelled()
+	}
 }
 
 func (p *Plugin) downloadDocumentFromSSM(log log.T, config contracts.Configuration, input *RunDocumentPluginInput) (string, error) {
@@ -250,6 +257,11 @@ func (p *Plugin) prepareDocumentForExecution(log log.T, pathToFile string, confi
 		log.Error("Could not read document from remote resource - ", err)
 		return nil, err
 	}
+	// fragments of the document are included from its directory, where aws:downloadContent stored them
+	if rawDocument, err = docparser.ResolveDocument(rawDocument, filepath.Dir(pathToFile)); err != nil {
+		log.Error("Could not resolve the document - ", err)
+		return nil, err
+	}
 	log.Infof("Sending the document received for parsing - %v", string(rawDocument))
 
 	return p.execDoc.ParseDocument(log, rawDocument, config.OrchestrationDirectory, config.OutputS3BucketName, config.OutputS3KeyPrefix, config.MessageId, config.PluginID, config.DefaultWorkingDirectory, parameters)
@@ -96,7 +96,7 @@ func TestExecDocumentImpl_ExecuteDocumentFailure(t *testing.T) {
 		DocExecutor: execMock,
 	}
 	conf := createStubConfiguration("orch", "bucket", "prefix", "1234-1234-1234", "directory")
-	_, err := exec.ExecuteDocument(conf, contextMock, pluginInput, documentId, "time")
+	_, err := exec.ExecuteDocument(conf, contextMock, pluginInput, documentId, "time", task.NewChanneledCancelFlag())
 
 	assert.NoError(t, err)
 }
@@ -125,7 +125,7 @@ func TestExecDocumentImpl_ExecuteDocumentSuccess(t *testing.T) {
 		DocExecutor: execMock,
 	}
 	conf := createStubConfiguration("orch", "bucket", "prefix", "1234-1234-1234", "directory")
-	_, err := exec.ExecuteDocument(conf, contextMock, pluginInput, documentId, "time")
+	_, err := exec.ExecuteDocument(conf, contextMock, pluginInput, documentId, "time", task.NewChanneledCancelFlag())
 
 	assert.NoError(t, err)
 }
@@ -151,11 +151,34 @@ func TestExecDocumentImpl_ExecuteDocumentWithMultiplePlugin(t *testing.T) {
 	exec := ExecDocumentImpl{
 		DocExecutor: execMock,
 	}
-	_, err := exec.ExecuteDocument(conf, contextMock, pluginInput, documentId, "time")
+	_, err := exec.ExecuteDocument(conf, contextMock, pluginInput, documentId, "time", task.NewChanneledCancelFlag())
 
 	assert.NoError(t, err)
 }
 
+func TestExecDocumentImpl_ExecuteDocumentSharesCancelFlag(t *testing.T) {
+
+	documentId := "documentId"
+	var pluginInput []contracts.PluginState
+	pluginInput = append(pluginInput, plugin)
+	execMock := executermocks.NewMockExecuter()
+	docResultChan := make(chan contracts.DocumentResult)
+	mockObj := new(InstanceMock)
+	mockObj.On("InstanceID", mock.Anything, mock.Anything).Return("instanceID", nil)
+
+	instance = mockObj
+	cancelFlag := task.NewChanneledCancelFlag()
+	execMock.On("Run", cancelFlag, mock.AnythingOfType("*executer.DocumentFileStore")).Return(docResultChan)
+	exec := ExecDocumentImpl{
+		DocExecutor: execMock,
+	}
+	conf := createStubConfiguration("orch", "bucket", "prefix", "1234-1234-1234", "directory")
+	_, err := exec.ExecuteDocument(conf, contextMock, pluginInput, documentId, "time", cancelFlag)
+
+	assert.NoError(t, err)
+	execMock.AssertExpectations(t)
+}
+
 func TestExecutePlugin_PrepareDocumentForExecution(t *testing.T) {
 
 	execMock := NewExecMock()
@@ -344,7 +367,7 @@ func TestPlugin_RunDocument(t *testing.T) {
 
 	fileMock.On("ReadFile", "/var/tmp/docLocation/docname.json").Return(content, nil)
 	execMock.On("ParseDocument", contextMock.Log(), []byte(content), conf.OrchestrationDirectory, conf.OutputS3BucketName, conf.OutputS3KeyPrefix, conf.MessageId, conf.PluginID, conf.DefaultWorkingDirectory, parameters).Return(plugins, nil)
-	execMock.On("ExecuteDocument", contextMock, plugins, conf.BookKeepingFileName, mock.Anything).Return(resChan, nil)
+	execMock.On("ExecuteDocument", contextMock, plugins, conf.BookKeepingFileName, mock.Anything, mock.Anything).Return(resChan, nil)
 	mockIOHandler.On("GetStatus").Return(contracts.ResultStatusSuccess)
 	mockIOHandler.On("SetStatus", contracts.ResultStatusSuccess).Return()
 
@@ -361,6 +384,64 @@ func TestPlugin_RunDocument(t *testing.T) {
 	mockIOHandler.AssertExpectations(t)
 }
 
+func TestPlugin_RunDocumentCancelled(t *testing.T) {
+
+	execMock := NewExecMock()
+	fileMock := filemock.FileSystemMock{}
+	mockIOHandler := new(iohandlermocks.MockIOHandler)
+
+	conf := createStubConfiguration("orch", "bucket", "prefix", "1234-1234-1234", "directory")
+
+	var input RunDocumentPluginInput
+	input.DocumentType = LocalPathType
+	input.DocumentPath = "/var/tmp/docLocation/docname.json"
+	conf.Properties = &input
+
+	cancelFlag := task.NewChanneledCancelFlag()
+	resChan := make(chan contracts.DocumentResult)
+	pluginRes := contracts.PluginResult{
+		PluginID:   "aws:runShellScript",
+		PluginName: "aws:runShellScript",
+		Status:     contracts.ResultStatusCancelled,
+		Code:       1,
+	}
+	pluginResults := make(map[string]*contracts.PluginResult)
+	pluginResults[pluginRes.PluginID] = &pluginRes
+
+	go func() {
+		// the command is cancelled while the sub-document runs
+		cancelFlag.Set(task.Canceled)
+		resChan <- contracts.DocumentResult{
+			LastPlugin:    "",
+			Status:        contracts.ResultStatusCancelled,
+			PluginResults: pluginResults,
+		}
+		close(resChan)
+	}()
+	parameters := make(map[string]interface{})
+	content := "content"
+
+	plugins := []contracts.PluginState{{}}
+
+	fileMock.On("ReadFile", "/var/tmp/docLocation/docname.json").Return(content, nil)
+	execMock.On("ParseDocument", contextMock.Log(), []byte(content), conf.OrchestrationDirectory, conf.OutputS3BucketName, conf.OutputS3KeyPrefix, conf.MessageId, conf.PluginID, conf.DefaultWorkingDirectory, parameters).Return(plugins, nil)
+	execMock.On("ExecuteDocument", contextMock, plugins, conf.BookKeepingFileName, mock.Anything, cancelFlag).Return(resChan, nil)
+	mockIOHandler.On("GetStatus").Return(contracts.ResultStatusSuccess)
+	mockIOHandler.On("SetStatus", contracts.ResultStatusCancelled).Return()
+	mockIOHandler.On("MarkAsCancelled").Return()
+
+	p := Plugin{
+		filesys: fileMock,
+		execDoc: execMock,
+	}
+
+	p.runDocument(contextMock, &input, conf, cancelFlag, mockIOHandler)
+
+	execMock.AssertExpectations(t)
+	fileMock.AssertExpectations(t)
+	mockIOHandler.AssertExpectations(t)
+}
+
 func TestPlugin_RunDocumentFromSSMDocument(t *testing.T) {
 
 	execMock := NewExecMock()
@@ -404,7 +485,7 @@ func TestPlugin_RunDocumentFromSSMDocument(t *testing.T) {
 	fileMock.On("WriteFile", "orch/downloads/RunShellScript.json", content).Return(nil)
 	fileMock.On("ReadFile", "orch/downloads/RunShellScript.json").Return(content, nil)
 	execMock.On("ParseDocument", contextMock.Log(), []byte(content), conf.OrchestrationDirectory, conf.OutputS3BucketName, conf.OutputS3KeyPrefix, conf.MessageId, conf.PluginID, conf.DefaultWorkingDirectory, parameters).Return(plugins, nil)
-	execMock.On("ExecuteDocument", contextMock, plugins, conf.BookKeepingFileName, mock.Anything).Return(resChan, nil)
+	execMock.On("ExecuteDocument", contextMock, plugins, conf.BookKeepingFileName, mock.Anything, mock.Anything).Return(resChan, nil)
 	mockIOHandler.On("GetStatus").Return(contracts.ResultStatusSuccess)
 	mockIOHandler.On("SetStatus", contracts.ResultStatusSuccess).Return()
 
@@ -419,7 +500,7 @@ func TestPlugin_RunDocumentFromSSMDocument(t *testing.T) {
 		execDoc: execMock,
 	}
 
-	p.runDocument(contextMock, &input, conf, mockIOHandler)
+	p.runDocument(contextMock, &input, conf, createMockCancelFlag(), mockIOHandler)
 
 	execMock.AssertExpectations(t)
 	fileMock.AssertExpectations(t)
@@ -462,7 +543,7 @@ func TestPlugin_RunDocumentFromAbsLocalPath(t *testing.T) {
 
 	fileMock.On("ReadFile", "/var/tmp/document/docName.json").Return(content, nil)
 	execMock.On("ParseDocument", contextMock.Log(), []byte(content), conf.OrchestrationDirectory, conf.OutputS3BucketName, conf.OutputS3KeyPrefix, conf.MessageId, conf.PluginID, conf.DefaultWorkingDirectory, parameters).Return(plugins, nil)
-	execMock.On("ExecuteDocument", contextMock, plugins, conf.BookKeepingFileName, mock.Anything).Return(resChan, nil)
+	execMock.On("ExecuteDocument", contextMock, plugins, conf.BookKeepingFileName, mock.Anything, mock.Anything).Return(resChan, nil)
 	mockIOHandler.On("GetStatus").Return(contracts.ResultStatusSuccess)
 	mockIOHandler.On("SetStatus", contracts.ResultStatusSuccess).Return()
 
@@ -476,7 +557,7 @@ func TestPlugin_RunDocumentFromAbsLocalPath(t *testing.T) {
 		execDoc: execMock,
 	}
 
-	p.runDocument(contextMock, &input, conf, mockIOHandler)
+	p.runDocument(contextMock, &input, conf, createMockCancelFlag(), mockIOHandler)
 
 	execMock.AssertExpectations(t)
 	fileMock.AssertExpectations(t)
@@ -0,0 +1,85 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package runscript
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os/exec"
+	"regexp"
+	"strings"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+)
+
+// runYara scans the file with the yara executable and returns the names of the matching rules
+var runYara = func(yaraPath string, rules []string, filePath string) ([]string, error) {
+	var stderr bytes.Buffer
+	cmd := exec.Command(yaraPath, append(append([]string{}, rules...), filePath)...)
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("%v %v", err, strings.TrimSpace(stderr.String()))
+	}
+	var matches []string
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		if fields := strings.Fields(scanner.Text()); len(fields) > 0 {
+			matches = append(matches, fields[0])
+		}
+	}
+	return matches, nil
+}
+
+// inspectScript inspects the script against the rules of the agent config. It returns the warn rules the script
+// matches, and an error when it matches a block rule or cannot be inspected.
+func inspectScript(config appconfig.ScriptInspectionCfg, scriptPath string) (warnings []string, err error) {
+	if len(config.Rules) == 0 && config.YaraPath == "" {
+		return nil, nil
+	}
+	content, err := ioutil.ReadFile(scriptPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read script file for inspection. %v", err)
+	}
+
+	for _, rule := range config.Rules {
+		pattern, err := regexp.Compile(rule.Pattern)
+		if err != nil || !pattern.Match(content) {
+			continue
+		}
+		if rule.Severity == appconfig.ScriptInspectionSeverityBlock {
+			return warnings, fmt.Errorf("script blocked by inspection rule %v", rule.Name)
+		}
+		warnings = append(warnings, rule.Name)
+	}
+
+	if config.YaraPath != "" {
+		matches, err := runYara(config.YaraPath, config.YaraRules, scriptPath)
+		if err != nil {
+			if config.YaraSeverity == appconfig.ScriptInspectionSeverityBlock {
+				return warnings, fmt.Errorf("failed to scan script with yara: %v", err)
+			}
+			warnings = append(warnings, fmt.Sprintf("yara scan failed: %v", err))
+		}
+		for _, match := range matches {
+			if config.YaraSeverity == appconfig.ScriptInspectionSeverityBlock {
+				return warnings, fmt.Errorf("script blocked by yara rule %v", match)
+			}
+			warnings = append(warnings, "yara:"+match)
+		}
+	}
+	return warnings, nil
+}
@@ -0,0 +1,93 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package runscript
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/fileutil"
+)
+
+const (
+	// parameterFilesDir is the directory of the orchestration directory of the step the parameter files are written to
+	parameterFilesDir = "parameters"
+
+	// parameterPathEnvVarFormat is the environment variable of the scripts that holds the path of a parameter file
+	parameterPathEnvVarFormat = "SSM_PARAM_%v_PATH"
+)
+
+// invalidEnvVarChars are the characters of parameter names that cannot appear in environment variable names
+var invalidEnvVarChars = regexp.MustCompile(`[^A-Za-z0-9_]`)
+
+// parameterPathEnvVar returns the environment variable that holds the path of the parameter file of the name
+func parameterPathEnvVar(name string) string {
+	return fmt.Sprintf(parameterPathEnvVarFormat, strings.ToUpper(invalidEnvVarChars.ReplaceAllString(name, "_")))
+}
+
+// writeParameterFiles writes the parameter files of the input, and the environment variables too large for the
+// command line of the platform, to files only the agent user can read. The scripts find their paths in the
+// SSM_PARAM_<name>_PATH environment variables. writeParameterFiles returns the directory of the files, which the
+// caller removes once the script ran, and the environment variables it moved to files.
+func writeParameterFiles(pluginInput *RunScriptPluginInput, orchestrationDir string) (dir string, moved []string, err error) {
+	files := make(map[string]string)
+	for name, value := range pluginInput.ParameterFiles {
+		files[name] = value
+	}
+	for name, value := range pluginInput.Environment {
+		if len(value) <= maxEnvironmentValueBytes {
+			continue
+		}
+		if _, found := files[name]; found {
+			return "", nil, fmt.Errorf("parameter %v is both a parameter file and an environment variable", name)
+		}
+		files[name] = value
+		moved = append(moved, name)
+	}
+	if len(files) == 0 {
+		return "", nil, nil
+	}
+	sort.Strings(moved)
+
+	dir = filepath.Join(orchestrationDir, parameterFilesDir)
+	if err = fileutil.MakeDirs(dir); err != nil {
+		return "", nil, err
+	}
+	if pluginInput.Environment == nil {
+		pluginInput.Environment = make(map[string]string)
+	}
+	pathEnvVars := make(map[string]string)
+	for name, value := range files {
+		envVar := parameterPathEnvVar(name)
+		if other, found := pathEnvVars[envVar]; found {
+			return dir, nil, fmt.Errorf("parameters %v and %v both use the environment variable %v", other, name, envVar)
+		}
+		pathEnvVars[envVar] = name
+
+		path := filepath.Join(dir, invalidEnvVarChars.ReplaceAllString(name, "_"))
+		if err = ioutil.WriteFile(path, []byte(value), appconfig.ReadWriteAccess); err != nil {
+			return dir, nil, err
+		}
+		pluginInput.Environment[envVar] = path
+	}
+	for _, name := range moved {
+		delete(pluginInput.Environment, name)
+	}
+	return dir, moved, nil
+}
@@ -0,0 +1,20 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// +build !windows
+
+package runscript
+
+// maxEnvironmentValueBytes is the size above which environment values are passed in parameter files, linux limits
+// every string of the environment and the arguments to 128 KiB and all of them together to a quarter of the stack
+const maxEnvironmentValueBytes = 32 * 1024
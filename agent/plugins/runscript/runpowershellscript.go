@@ -41,6 +41,7 @@ func NewRunPowerShellPlugin() (*runPowerShellPlugin, error) {
 			ShellArguments:  strings.Split(appconfig.PowerShellPluginCommandArgs, " "),
 			ByteOrderMark:   fileutil.ByteOrderMarkEmit,
 			CommandExecuter: executers.ShellCommandExecuter{},
+			AuditScript:     true,
 		},
 	}
 
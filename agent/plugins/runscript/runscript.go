@@ -15,19 +15,28 @@
 package runscript
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"io/ioutil"
+	"os"
 	"path/filepath"
 
 	"strings"
 
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
 	"github.com/aws/amazon-ssm-agent/agent/context"
 	"github.com/aws/amazon-ssm-agent/agent/contracts"
 	"github.com/aws/amazon-ssm-agent/agent/executers"
 	"github.com/aws/amazon-ssm-agent/agent/fileutil"
+	"github.com/aws/amazon-ssm-agent/agent/framework/docmanager"
 	"github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/iohandler"
+	"github.com/aws/amazon-ssm-agent/agent/hostaccess"
 	"github.com/aws/amazon-ssm-agent/agent/jsonutil"
 	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/platform"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/pluginutil"
+	"github.com/aws/amazon-ssm-agent/agent/sandbox"
 
 	messageContracts "github.com/aws/amazon-ssm-agent/agent/runcommand/contracts"
 	"github.com/aws/amazon-ssm-agent/agent/task"
@@ -35,8 +44,29 @@ import (
 
 const (
 	downloadsDir = "downloads" //Directory under the orchestration directory where the downloaded resource resides
+
+	// commandIDEnvVar is the environment variable of the scripts that holds the id of the command
+	commandIDEnvVar = "SSM_COMMAND_ID"
 )
 
+var getAppConfig = appconfig.Config
+
+// tmpDirEnvVars are the environment variables that name the temporary directory on Linux, macOS and Windows
+var tmpDirEnvVars = []string{"TMPDIR", "TMP", "TEMP"}
+
+// documentTmpDirectory returns the private temporary directory of the document, steps without an absolute
+// orchestration directory have none
+var documentTmpDirectory = func(orchestrationDirectory string, documentID string) string {
+	if !filepath.IsAbs(orchestrationDirectory) {
+		return ""
+	}
+	instanceID, err := platform.InstanceID()
+	if err != nil {
+		return ""
+	}
+	return docmanager.TmpDirectory(instanceID, documentID)
+}
+
 // Plugin is the type for the runscript plugin.
 type Plugin struct {
 	// ExecuteCommand is an object that can execute commands.
@@ -47,6 +77,12 @@ type Plugin struct {
 	ShellCommand   string
 	ShellArguments []string
 	ByteOrderMark  fileutil.ByteOrderMark
+	// Sandboxed runs the children in the seccomp and Landlock sandbox of the agent config or the step
+	Sandboxed bool
+	// RunsOnHost runs the children on the host when the agent runs in a container configured to manage its node
+	RunsOnHost bool
+	// AuditScript records the hash of the script in the audit log and checks that AMSI and script block logging can see it
+	AuditScript bool
 }
 
 // RunScriptPluginInput represents one set of commands executed by the RunScript plugin.
@@ -57,6 +93,10 @@ type RunScriptPluginInput struct {
 	ID               string
 	WorkingDirectory string
 	TimeoutSeconds   interface{}
+	Sandbox          *sandbox.Profile
+	// ParameterFiles are written to files the scripts find in the SSM_PARAM_<name>_PATH environment variables,
+	// for values too large for the command line
+	ParameterFiles map[string]string
 }
 
 // Execute runs multiple sets of commands and returns their outputs.
@@ -78,13 +118,13 @@ func (p *Plugin) Execute(context context.T, config contracts.Configuration, canc
 	} else if cancelFlag.Canceled() {
 		output.MarkAsCancelled()
 	} else {
-		p.runCommandsRawInput(log, config.PluginID, config.Properties, config.OrchestrationDirectory, config.DefaultWorkingDirectory, cancelFlag, output, runCommandID)
+		p.runCommandsRawInput(log, config.PluginID, config.Properties, config.OrchestrationDirectory, config.DefaultWorkingDirectory, cancelFlag, output, runCommandID, config.BookKeepingFileName)
 	}
 }
 
 // runCommandsRawInput executes one set of commands and returns their output.
 // The input is in the default json unmarshal format (e.g. map[string]interface{}).
-func (p *Plugin) runCommandsRawInput(log log.T, pluginID string, rawPluginInput interface{}, orchestrationDirectory string, defaultWorkingDirectory string, cancelFlag task.CancelFlag, output iohandler.IOHandler, runCommandID string) {
+func (p *Plugin) runCommandsRawInput(log log.T, pluginID string, rawPluginInput interface{}, orchestrationDirectory string, defaultWorkingDirectory string, cancelFlag task.CancelFlag, output iohandler.IOHandler, runCommandID string, documentID string) {
 	var pluginInput RunScriptPluginInput
 	err := jsonutil.Remarshal(rawPluginInput, &pluginInput)
 	if err != nil {
@@ -98,16 +138,68 @@ func (p *Plugin) runCommandsRawInput(log log.T, pluginID string, rawPluginInput
 			pluginInput.Environment = make(map[string]string)
 		}
 		// Check if "SSM_COMMAND_ID" exists already in the env. If so, log that it will be overwritten
-		if _, ok := pluginInput.Environment["SSM_COMMAND_ID"]; ok {
+		if _, ok := pluginInput.Environment[commandIDEnvVar]; ok {
 			log.Warnf("The environment variable 'SSM_COMMAND_ID' has been detected as pre-existing and will be overwritten with the CommandId of this execution.")
 		}
-		pluginInput.Environment["SSM_COMMAND_ID"] = runCommandID
+		pluginInput.Environment[commandIDEnvVar] = runCommandID
+	}
+	if scratchDir := pluginutil.ScratchDirectory(orchestrationDirectory); scratchDir != "" {
+		if err := fileutil.MakeDirs(scratchDir); err != nil {
+			log.Warnf("failed to create the scratch directory of the document %v: %v", scratchDir, err)
+		} else {
+			if pluginInput.Environment == nil {
+				pluginInput.Environment = make(map[string]string)
+			}
+			pluginInput.Environment[pluginutil.ScratchDirEnvVar] = scratchDir
+		}
 	}
-	p.runCommands(log, pluginID, pluginInput, orchestrationDirectory, defaultWorkingDirectory, cancelFlag, output)
+	tmpDir := documentTmpDirectory(orchestrationDirectory, documentID)
+	if tmpDir != "" {
+		if err := makeTmpDirectory(tmpDir); err != nil {
+			log.Warnf("failed to create the temporary directory of the document %v: %v", tmpDir, err)
+			tmpDir = ""
+		} else {
+			if pluginInput.Environment == nil {
+				pluginInput.Environment = make(map[string]string)
+			}
+			for _, name := range tmpDirEnvVars {
+				pluginInput.Environment[name] = tmpDir
+			}
+		}
+	}
+	p.runCommands(log, pluginID, pluginInput, orchestrationDirectory, defaultWorkingDirectory, tmpDir, cancelFlag, output)
+}
+
+// makeTmpDirectory creates the temporary directory of the document, which users other than the agent share like /tmp
+func makeTmpDirectory(tmpDir string) error {
+	if err := fileutil.MakeDirs(tmpDir); err != nil {
+		return err
+	}
+	return os.Chmod(tmpDir, os.ModeSticky|os.ModePerm)
+}
+
+// auditScript records the hash of the script in the audit log. Gaps in the AMSI and script block logging coverage
+// of the instance fail the step when the agent config requires it, otherwise they are logged.
+func auditScript(logger log.T, commandID string, pluginID string, scriptPath string) error {
+	if gaps := scriptLoggingGaps(); len(gaps) > 0 {
+		if config, err := getAppConfig(false); err == nil && config.PowerShell.RequireScriptBlockLogging {
+			return fmt.Errorf("script would not be visible to AMSI and script block logging: %v", strings.Join(gaps, ", "))
+		}
+		logger.Warnf("script %v is not fully visible to AMSI and script block logging: %v", scriptPath, strings.Join(gaps, ", "))
+	}
+
+	content, err := ioutil.ReadFile(scriptPath)
+	if err != nil {
+		return fmt.Errorf("failed to read script file. %v", err)
+	}
+	hash := sha256.Sum256(content)
+	logger.WriteEvent(log.StepScriptMessage, "", fmt.Sprintf("%s:%s:sha256=%s", commandID, pluginID, hex.EncodeToString(hash[:])))
+	return nil
 }
 
 // runCommands executes one set of commands and returns their output.
-func (p *Plugin) runCommands(log log.T, pluginID string, pluginInput RunScriptPluginInput, orchestrationDirectory string, defaultWorkingDirectory string, cancelFlag task.CancelFlag, output iohandler.IOHandler) {
+// tmpDir is the temporary directory of the document, it is mounted over /tmp when the agent config isolates the documents.
+func (p *Plugin) runCommands(log log.T, pluginID string, pluginInput RunScriptPluginInput, orchestrationDirectory string, defaultWorkingDirectory string, tmpDir string, cancelFlag task.CancelFlag, output iohandler.IOHandler) {
 	var err error
 	var workingDir string
 
@@ -141,6 +233,36 @@ func (p *Plugin) runCommands(log log.T, pluginID string, pluginInput RunScriptPl
 		output.MarkAsFailed(fmt.Errorf("failed to create script file. %v", err))
 		return
 	}
+	if config, err := getAppConfig(false); err == nil {
+		warnings, err := inspectScript(config.ScriptInspection, scriptPath)
+		for _, warning := range warnings {
+			log.Warnf("script %v matches inspection rule %v", scriptPath, warning)
+			output.AppendInfof("Warning: script matches inspection rule %v", warning)
+		}
+		if err != nil {
+			output.MarkAsFailed(err)
+			return
+		}
+	}
+	if p.AuditScript {
+		if err = auditScript(log, pluginInput.Environment[commandIDEnvVar], pluginID, scriptPath); err != nil {
+			output.MarkAsFailed(err)
+			return
+		}
+	}
+
+	parameterDir, moved, err := writeParameterFiles(&pluginInput, orchestrationDir)
+	if parameterDir != "" {
+		defer os.RemoveAll(parameterDir)
+	}
+	if err != nil {
+		output.MarkAsFailed(fmt.Errorf("failed to write parameter files. %v", err))
+		return
+	}
+	for _, name := range moved {
+		log.Infof("environment variable %v is too large for the command line, it is passed in a parameter file", name)
+		output.AppendInfof("Environment variable %v is too large for the command line, its value is in the file %v", name, parameterPathEnvVar(name))
+	}
 
 	// Set execution time
 	executionTimeout := pluginutil.ValidateExecutionTimeout(log, pluginInput.TimeoutSeconds)
@@ -148,6 +270,22 @@ func (p *Plugin) runCommands(log log.T, pluginID string, pluginInput RunScriptPl
 	// Construct Command Name and Arguments
 	commandName := p.ShellCommand
 	commandArguments := append(p.ShellArguments, scriptPath)
+	if p.RunsOnHost {
+		if hostConfig, enabled := hostaccess.Resolve(); enabled {
+			log.Debugf("Running commands on the host with %v", hostConfig.HostAccess)
+			commandName, commandArguments = hostaccess.Wrap(hostConfig, workingDir, commandName, commandArguments)
+		}
+	}
+	if p.Sandboxed {
+		profile, enabled := sandbox.Resolve(pluginInput.Sandbox)
+		profile.AllowWrite(workingDir, orchestrationDir)
+		if isolated := profile.Isolate(tmpDir, workingDir, orchestrationDir); enabled || isolated {
+			if commandName, commandArguments, err = sandbox.Wrap(profile, commandName, commandArguments); err != nil {
+				output.MarkAsFailed(err)
+				return
+			}
+		}
+	}
 
 	// Execute Command
 	exitCode, err := p.CommandExecuter.NewExecute(log, workingDir, output.GetStdoutWriter(), output.GetStderrWriter(), cancelFlag, executionTimeout, commandName, commandArguments, pluginInput.Environment)
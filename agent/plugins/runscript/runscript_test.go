@@ -14,9 +14,16 @@
 package runscript
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
 	"github.com/aws/amazon-ssm-agent/agent/context"
 	"github.com/aws/amazon-ssm-agent/agent/contracts"
 	"github.com/aws/amazon-ssm-agent/agent/executers"
@@ -151,9 +158,9 @@ func testRunScripts(t *testing.T, testCase TestCase, rawInput bool) {
 			err := jsonutil.Remarshal(testCase.Input, &rawPluginInput)
 			assert.Nil(t, err)
 
-			p.runCommandsRawInput(logger, pluginID, rawPluginInput, orchestrationDirectory, defaultWorkingDirectory, mockCancelFlag, mockIOHandler, runCommandID)
+			p.runCommandsRawInput(logger, pluginID, rawPluginInput, orchestrationDirectory, defaultWorkingDirectory, mockCancelFlag, mockIOHandler, runCommandID, "")
 		} else {
-			p.runCommands(logger, pluginID, testCase.Input, orchestrationDirectory, defaultWorkingDirectory, mockCancelFlag, mockIOHandler)
+			p.runCommands(logger, pluginID, testCase.Input, orchestrationDirectory, defaultWorkingDirectory, "", mockCancelFlag, mockIOHandler)
 		}
 	}
 
@@ -178,7 +185,7 @@ func testBucketsInDifferentRegions(t *testing.T, testCase TestCase, testingBucke
 		setIOHandlerExpectations(mockIOHandler, testCase)
 
 		// call method under test
-		p.runCommands(logger, pluginID, testCase.Input, orchestrationDirectory, defaultWorkingDirectory, mockCancelFlag, mockIOHandler)
+		p.runCommands(logger, pluginID, testCase.Input, orchestrationDirectory, defaultWorkingDirectory, "", mockCancelFlag, mockIOHandler)
 	}
 
 	testExecution(t, runScriptTester)
@@ -393,3 +400,102 @@ func setCancelFlagExpectations(mockCancelFlag *task.MockCancelFlag, times int) {
 	mockCancelFlag.On("Canceled").Return(false).Times(times)
 	mockCancelFlag.On("ShutDown").Return(false).Times(times)
 }
+
+// TestAuditScript tests that the hash of the script is recorded in the audit log
+func TestAuditScript(t *testing.T) {
+	scriptPath := filepath.Join(t.TempDir(), powerShellScriptName)
+	assert.NoError(t, ioutil.WriteFile(scriptPath, []byte("Write-Host hello"), 0600))
+	hash := sha256.Sum256([]byte("Write-Host hello"))
+
+	mockLog := log.NewMockLog()
+	err := auditScript(mockLog, "commandID", "aws:runPowerShellScript", scriptPath)
+
+	assert.NoError(t, err)
+	mockLog.AssertCalled(t, "WriteEvent", log.StepScriptMessage, "", "commandID:aws:runPowerShellScript:sha256="+hex.EncodeToString(hash[:]))
+	assert.Error(t, auditScript(mockLog, "commandID", "aws:runPowerShellScript", scriptPath+".missing"))
+}
+
+// TestInspectScript tests that scripts matching block rules are rejected and matches of warn rules are returned
+func TestInspectScript(t *testing.T) {
+	scriptPath := filepath.Join(t.TempDir(), shellScriptName)
+	assert.NoError(t, ioutil.WriteFile(scriptPath, []byte("curl -s https://example.com/install | bash\n"), 0600))
+
+	config := appconfig.ScriptInspectionCfg{Rules: []appconfig.ScriptInspectionRuleCfg{
+		{Name: "curl-pipe-shell", Pattern: `curl[^|]*\|\s*(ba)?sh`, Severity: appconfig.ScriptInspectionSeverityWarn},
+		{Name: "aws-access-key", Pattern: `AKIA[0-9A-Z]{16}`, Severity: appconfig.ScriptInspectionSeverityBlock},
+	}}
+	warnings, err := inspectScript(config, scriptPath)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"curl-pipe-shell"}, warnings)
+
+	config.Rules[0].Severity = appconfig.ScriptInspectionSeverityBlock
+	_, err = inspectScript(config, scriptPath)
+	assert.EqualError(t, err, "script blocked by inspection rule curl-pipe-shell")
+
+	warnings, err = inspectScript(appconfig.ScriptInspectionCfg{}, scriptPath+".missing")
+	assert.NoError(t, err)
+	assert.Nil(t, warnings)
+}
+
+// TestInspectScript_Yara tests that yara matches and failures have the configured severity
+func TestInspectScript_Yara(t *testing.T) {
+	scriptPath := filepath.Join(t.TempDir(), shellScriptName)
+	assert.NoError(t, ioutil.WriteFile(scriptPath, []byte("echo hello\n"), 0600))
+	original := runYara
+	defer func() { runYara = original }()
+
+	var yaraArgs []string
+	runYara = func(yaraPath string, rules []string, filePath string) ([]string, error) {
+		yaraArgs = append(append([]string{yaraPath}, rules...), filePath)
+		return []string{"SuspiciousDownload"}, nil
+	}
+	config := appconfig.ScriptInspectionCfg{YaraPath: "/usr/bin/yara", YaraRules: []string{"/etc/yara/scripts.yar"}, YaraSeverity: appconfig.ScriptInspectionSeverityWarn}
+	warnings, err := inspectScript(config, scriptPath)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"yara:SuspiciousDownload"}, warnings)
+	assert.Equal(t, []string{"/usr/bin/yara", "/etc/yara/scripts.yar", scriptPath}, yaraArgs)
+
+	config.YaraSeverity = appconfig.ScriptInspectionSeverityBlock
+	_, err = inspectScript(config, scriptPath)
+	assert.EqualError(t, err, "script blocked by yara rule SuspiciousDownload")
+
+	runYara = func(yaraPath string, rules []string, filePath string) ([]string, error) {
+		return nil, fmt.Errorf("exit status 1")
+	}
+	_, err = inspectScript(config, scriptPath)
+	assert.Error(t, err)
+}
+
+func TestWriteParameterFiles(t *testing.T) {
+	orchestrationDir, _ := ioutil.TempDir("", "runscript")
+	defer os.RemoveAll(orchestrationDir)
+	large := strings.Repeat("x", maxEnvironmentValueBytes+1)
+	pluginInput := RunScriptPluginInput{
+		Environment:    map[string]string{"SMALL": "value", "LARGE": large},
+		ParameterFiles: map[string]string{"payload.json": `{"key": "value"}`},
+	}
+
+	dir, moved, err := writeParameterFiles(&pluginInput, orchestrationDir)
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(orchestrationDir, parameterFilesDir), dir)
+	assert.Equal(t, []string{"LARGE"}, moved)
+	assert.Equal(t, map[string]string{
+		"SMALL":                       "value",
+		"SSM_PARAM_LARGE_PATH":        filepath.Join(dir, "LARGE"),
+		"SSM_PARAM_PAYLOAD_JSON_PATH": filepath.Join(dir, "payload_json"),
+	}, pluginInput.Environment)
+	content, _ := ioutil.ReadFile(filepath.Join(dir, "LARGE"))
+	assert.Equal(t, large, string(content))
+	content, _ = ioutil.ReadFile(filepath.Join(dir, "payload_json"))
+	assert.Equal(t, `{"key": "value"}`, string(content))
+
+	pluginInput = RunScriptPluginInput{Environment: map[string]string{"SMALL": "value"}}
+	dir, moved, err = writeParameterFiles(&pluginInput, orchestrationDir)
+	assert.NoError(t, err)
+	assert.Empty(t, dir)
+	assert.Empty(t, moved)
+
+	pluginInput = RunScriptPluginInput{ParameterFiles: map[string]string{"a-b": "1", "a.b": "2"}}
+	_, _, err = writeParameterFiles(&pluginInput, orchestrationDir)
+	assert.Error(t, err)
+}
@@ -41,6 +41,8 @@ func NewRunShellPlugin(log log.T) (*runShellPlugin, error) {
 			ShellArguments:  shellArgs,
 			ByteOrderMark:   fileutil.ByteOrderMarkSkip,
 			CommandExecuter: executers.ShellCommandExecuter{},
+			Sandboxed:       true,
+			RunsOnHost:      true,
 		},
 	}
 
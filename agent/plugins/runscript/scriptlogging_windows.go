@@ -0,0 +1,61 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// +build windows
+
+package runscript
+
+import (
+	"golang.org/x/sys/windows/registry"
+)
+
+const (
+	// scriptBlockLoggingPolicyKey is the group policy that enables PowerShell script block logging
+	scriptBlockLoggingPolicyKey   = `SOFTWARE\Policies\Microsoft\Windows\PowerShell\ScriptBlockLogging`
+	scriptBlockLoggingPolicyValue = "EnableScriptBlockLogging"
+
+	// amsiProvidersKey lists the antimalware products registered with AMSI
+	amsiProvidersKey = `SOFTWARE\Microsoft\AMSI\Providers`
+)
+
+// scriptLoggingGaps returns what keeps the scripts of PowerShell steps from being scanned by AMSI and recorded by
+// script block logging on the instance
+func scriptLoggingGaps() (gaps []string) {
+	if !scriptBlockLoggingEnabled() {
+		gaps = append(gaps, "the script block logging policy is not enabled")
+	}
+	if !amsiProviderRegistered() {
+		gaps = append(gaps, "no AMSI provider is registered")
+	}
+	return gaps
+}
+
+func scriptBlockLoggingEnabled() bool {
+	key, err := registry.OpenKey(registry.LOCAL_MACHINE, scriptBlockLoggingPolicyKey, registry.QUERY_VALUE)
+	if err != nil {
+		return false
+	}
+	defer key.Close()
+	value, _, err := key.GetIntegerValue(scriptBlockLoggingPolicyValue)
+	return err == nil && value == 1
+}
+
+func amsiProviderRegistered() bool {
+	key, err := registry.OpenKey(registry.LOCAL_MACHINE, amsiProvidersKey, registry.ENUMERATE_SUB_KEYS|registry.QUERY_VALUE)
+	if err != nil {
+		return false
+	}
+	defer key.Close()
+	info, err := key.Stat()
+	return err == nil && info.SubKeyCount > 0
+}
@@ -0,0 +1,379 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package scanupdates
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/service/ssm"
+)
+
+// Names of the supported package managers, these are also the values accepted in the manager parameter
+const (
+	managerApt    = "apt"
+	managerYum    = "yum"
+	managerDnf    = "dnf"
+	managerZypper = "zypper"
+)
+
+// managerOrder is the order the package managers are detected in
+var managerOrder = []string{managerApt, managerDnf, managerYum, managerZypper}
+
+// runCommand runs a package manager command and returns its combined output and exit code
+var runCommand = func(name string, args ...string) (output string, exitCode int, err error) {
+	command := exec.Command(name, args...)
+	command.Env = append(os.Environ(), "LC_ALL=C")
+	out, err := command.CombinedOutput()
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		return string(out), exitErr.ExitCode(), nil
+	}
+	return string(out), 0, err
+}
+
+var lookPath = exec.LookPath
+
+// scanner lists the available updates with a package manager
+type scanner struct {
+	name string
+	// binary is used to detect the package manager
+	binary string
+	// refreshArgs downloads the package lists of the repositories
+	refreshArgs []string
+	scan        func() ([]Update, error)
+}
+
+var scanners = map[string]*scanner{
+	managerApt: {
+		name:        managerApt,
+		binary:      "apt-get",
+		refreshArgs: []string{"update", "-q"},
+		scan:        scanApt,
+	},
+	managerYum: {
+		name:        managerYum,
+		binary:      "yum",
+		refreshArgs: []string{"-q", "makecache"},
+		scan:        func() ([]Update, error) { return scanRpm("yum") },
+	},
+	managerDnf: {
+		name:        managerDnf,
+		binary:      "dnf",
+		refreshArgs: []string{"-q", "makecache"},
+		scan:        func() ([]Update, error) { return scanRpm("dnf") },
+	},
+	managerZypper: {
+		name:        managerZypper,
+		binary:      "zypper",
+		refreshArgs: []string{"--non-interactive", "refresh"},
+		scan:        scanZypper,
+	},
+}
+
+// detectScanner returns the requested package manager, or the first one found on the host
+func detectScanner(name string) (*scanner, error) {
+	if name != "" {
+		s, known := scanners[name]
+		if !known {
+			return nil, fmt.Errorf("package manager %v is not supported, supported package managers are %v", name, strings.Join(managerOrder, ", "))
+		}
+		if _, err := lookPath(s.binary); err != nil {
+			return nil, fmt.Errorf("package manager %v is not installed", name)
+		}
+		return s, nil
+	}
+	for _, candidate := range managerOrder {
+		if _, err := lookPath(scanners[candidate].binary); err == nil {
+			return scanners[candidate], nil
+		}
+	}
+	return nil, fmt.Errorf("none of the supported package managers %v was found", strings.Join(managerOrder, ", "))
+}
+
+// aptInstPattern matches the packages apt-get would install in simulation mode, like
+// Inst libssl1.1 [1.1.1f-1ubuntu2.16] (1.1.1f-1ubuntu2.17 Ubuntu:20.04/focal-updates, Ubuntu:20.04/focal-security [amd64])
+var aptInstPattern = regexp.MustCompile(`^Inst (\S+) (?:\[([^\]]*)\] )?\((\S+) (.*?)(?: \[([^\]]+)\])?\)`)
+
+// scanApt simulates the upgrade, the updates coming from a security pocket are security updates.
+// Debian and Ubuntu do not publish a severity with the packages.
+func scanApt() ([]Update, error) {
+	output, exitCode, err := runCommand("apt-get", "--simulate", "-o", "Debug::NoLocking=1", "dist-upgrade")
+	if err != nil || exitCode != 0 {
+		return nil, commandError("apt-get", output, exitCode, err)
+	}
+	return parseAptSimulation(output), nil
+}
+
+func parseAptSimulation(output string) []Update {
+	var updates []Update
+	for _, line := range strings.Split(output, "\n") {
+		match := aptInstPattern.FindStringSubmatch(strings.TrimSpace(line))
+		if match == nil {
+			continue
+		}
+		update := Update{
+			Package:          match[1],
+			CurrentVersion:   match[2],
+			CandidateVersion: match[3],
+			Architecture:     match[5],
+			Repository:       match[4],
+			Classification:   ClassificationOther,
+		}
+		if strings.Contains(strings.ToLower(match[4]), "security") {
+			update.Classification = ClassificationSecurity
+			update.Severity = ssm.ComplianceSeverityUnspecified
+		}
+		updates = append(updates, update)
+	}
+	return updates
+}
+
+// scanRpm lists the updates with yum or dnf, the security advisories of the repositories give their severity
+func scanRpm(binary string) ([]Update, error) {
+	output, exitCode, err := runCommand(binary, "-q", "check-update")
+	// check-update exits with 100 when updates are available
+	if err != nil || (exitCode != 0 && exitCode != 100) {
+		return nil, commandError(binary, output, exitCode, err)
+	}
+	updates := parseCheckUpdate(output)
+	if len(updates) == 0 {
+		return updates, nil
+	}
+
+	installed, exitCode, err := runCommand("rpm", "-qa", "--qf", `%{NAME}.%{ARCH} %{EPOCH}:%{VERSION}-%{RELEASE}\n`)
+	if err != nil || exitCode != 0 {
+		return nil, commandError("rpm", installed, exitCode, err)
+	}
+	currentVersions := parseRpmQuery(installed)
+
+	// repositories without advisories fail the updateinfo command, their updates are not classified
+	advisories := map[string]advisory{}
+	if output, exitCode, err = runCommand(binary, "-q", "updateinfo", "list", "updates"); err == nil && exitCode == 0 {
+		advisories = parseUpdateInfo(output)
+	}
+	for i := range updates {
+		key := updates[i].Package + "." + updates[i].Architecture
+		updates[i].CurrentVersion = currentVersions[key]
+		if adv, found := advisories[key]; found && adv.security {
+			updates[i].Classification = ClassificationSecurity
+			updates[i].Severity = adv.severity
+			updates[i].Advisory = adv.id
+		}
+	}
+	return updates, nil
+}
+
+// parseCheckUpdate reads the name.arch, version and repository columns of check-update,
+// yum moves the columns after a long name to the next line
+func parseCheckUpdate(output string) []Update {
+	var updates []Update
+	var fields []string
+	for _, line := range strings.Split(output, "\n") {
+		if strings.HasPrefix(line, "Obsoleting Packages") || strings.HasPrefix(line, "Security:") {
+			break
+		}
+		fields = append(fields, strings.Fields(line)...)
+		if len(fields) < 3 {
+			continue
+		}
+		name, arch := splitArch(fields[0])
+		if len(fields) == 3 && arch != "" {
+			updates = append(updates, Update{
+				Package:          name,
+				Architecture:     arch,
+				CandidateVersion: fields[1],
+				Repository:       fields[2],
+				Classification:   ClassificationOther,
+			})
+		}
+		fields = nil
+	}
+	return updates
+}
+
+// parseRpmQuery returns the installed versions by name.arch, the epoch is only kept when the package has one
+func parseRpmQuery(output string) map[string]string {
+	versions := map[string]string{}
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 {
+			versions[fields[0]] = strings.TrimPrefix(fields[1], "(none):")
+		}
+	}
+	return versions
+}
+
+// advisory is the most severe advisory of a package
+type advisory struct {
+	id       string
+	security bool
+	severity string
+}
+
+// parseUpdateInfo reads the advisories of updateinfo list, like
+// RHSA-2020:1234 Important/Sec. openssl-1:1.0.2k-19.el7.x86_64
+func parseUpdateInfo(output string) map[string]advisory {
+	advisories := map[string]advisory{}
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 3 {
+			continue
+		}
+		kind := strings.ToLower(fields[1])
+		security := strings.HasSuffix(kind, "/sec.") || kind == "security"
+		if !security && kind != "bugfix" && kind != "enhancement" && kind != "newpackage" {
+			continue
+		}
+		name, arch := splitArch(fields[2])
+		if arch == "" {
+			continue
+		}
+		key := rpmName(name) + "." + arch
+		adv := advisory{id: fields[0], security: security}
+		if security {
+			adv.severity = normalizeSeverity(strings.TrimSuffix(kind, "/sec."))
+		}
+		if previous, found := advisories[key]; found && !moreSevere(adv, previous) {
+			continue
+		}
+		advisories[key] = adv
+	}
+	return advisories
+}
+
+// scanZypper lists the package updates and the needed security patches, SUSE publishes the severity with the patches
+func scanZypper() ([]Update, error) {
+	output, exitCode, err := runCommand("zypper", "--non-interactive", "--quiet", "list-updates")
+	if err != nil || exitCode != 0 {
+		return nil, commandError("zypper", output, exitCode, err)
+	}
+	var updates []Update
+	for _, row := range parseZypperTable(output) {
+		updates = append(updates, Update{
+			Package:          row["Name"],
+			Architecture:     row["Arch"],
+			CurrentVersion:   row["Current Version"],
+			CandidateVersion: row["Available Version"],
+			Repository:       row["Repository"],
+			Classification:   ClassificationOther,
+		})
+	}
+
+	if output, exitCode, err = runCommand("zypper", "--non-interactive", "--quiet", "list-patches", "--category", "security"); err != nil || exitCode != 0 {
+		return nil, commandError("zypper", output, exitCode, err)
+	}
+	for _, row := range parseZypperTable(output) {
+		updates = append(updates, Update{
+			Package:        row["Name"],
+			Repository:     row["Repository"],
+			Advisory:       row["Name"],
+			Classification: ClassificationSecurity,
+			Severity:       normalizeSeverity(row["Severity"]),
+		})
+	}
+	return updates, nil
+}
+
+// parseZypperTable returns the rows of a zypper table keyed by the column headers
+func parseZypperTable(output string) []map[string]string {
+	var headers []string
+	var rows []map[string]string
+	for _, line := range strings.Split(output, "\n") {
+		if !strings.Contains(line, "|") || strings.HasPrefix(strings.TrimSpace(line), "--") {
+			continue
+		}
+		columns := strings.Split(line, "|")
+		for i := range columns {
+			columns[i] = strings.TrimSpace(columns[i])
+		}
+		if headers == nil {
+			headers = columns
+			continue
+		}
+		if len(columns) != len(headers) {
+			continue
+		}
+		row := map[string]string{}
+		for i, header := range headers {
+			row[header] = columns[i]
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+// splitArch splits name.arch, the arch is empty when the last part is not an architecture
+func splitArch(nameArch string) (name, arch string) {
+	i := strings.LastIndex(nameArch, ".")
+	if i <= 0 {
+		return nameArch, ""
+	}
+	switch arch = nameArch[i+1:]; arch {
+	case "x86_64", "i386", "i486", "i586", "i686", "noarch", "aarch64", "armv7hl", "ppc64le", "s390x", "src":
+		return nameArch[:i], arch
+	}
+	return nameArch, ""
+}
+
+// rpmName removes the version and release of name-[epoch:]version-release
+func rpmName(nameVersionRelease string) string {
+	name := nameVersionRelease
+	for i := 0; i < 2; i++ {
+		if j := strings.LastIndex(name, "-"); j > 0 {
+			name = name[:j]
+		}
+	}
+	return name
+}
+
+// normalizeSeverity maps the severities of the advisories to compliance severities
+func normalizeSeverity(severity string) string {
+	switch strings.ToLower(severity) {
+	case "critical":
+		return ssm.ComplianceSeverityCritical
+	case "important", "high":
+		return ssm.ComplianceSeverityHigh
+	case "moderate", "medium":
+		return ssm.ComplianceSeverityMedium
+	case "low":
+		return ssm.ComplianceSeverityLow
+	}
+	return ssm.ComplianceSeverityUnspecified
+}
+
+var severityRank = map[string]int{
+	ssm.ComplianceSeverityCritical:    4,
+	ssm.ComplianceSeverityHigh:        3,
+	ssm.ComplianceSeverityMedium:      2,
+	ssm.ComplianceSeverityLow:         1,
+	ssm.ComplianceSeverityUnspecified: 0,
+}
+
+// moreSevere returns true if a security advisory outranks another advisory of the same package
+func moreSevere(a, b advisory) bool {
+	if a.security != b.security {
+		return a.security
+	}
+	return severityRank[a.severity] > severityRank[b.severity]
+}
+
+func commandError(binary, output string, exitCode int, err error) error {
+	if err != nil {
+		return fmt.Errorf("failed to run %v: %v", binary, err)
+	}
+	return fmt.Errorf("%v exited with %v: %v", binary, exitCode, strings.TrimSpace(output))
+}
@@ -0,0 +1,162 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package scanupdates
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const aptSimulation = `NOTE: This is only a simulation!
+Reading package lists...
+Calculating upgrade...
+The following packages will be upgraded:
+  libssl1.1 tzdata
+Inst libssl1.1 [1.1.1f-1ubuntu2.16] (1.1.1f-1ubuntu2.17 Ubuntu:20.04/focal-updates, Ubuntu:20.04/focal-security [amd64])
+Inst tzdata [2021a-0ubuntu0.20.04] (2021e-0ubuntu0.20.04 Ubuntu:20.04/focal-updates [all])
+Inst linux-headers-5.4.0-91 (5.4.0-91.102 Ubuntu:20.04/focal-updates [all])
+Conf libssl1.1 (1.1.1f-1ubuntu2.17 Ubuntu:20.04/focal-updates, Ubuntu:20.04/focal-security [amd64])
+`
+
+func TestParseAptSimulation(t *testing.T) {
+	updates := parseAptSimulation(aptSimulation)
+	assert.Equal(t, []Update{
+		{
+			Package:          "libssl1.1",
+			Architecture:     "amd64",
+			CurrentVersion:   "1.1.1f-1ubuntu2.16",
+			CandidateVersion: "1.1.1f-1ubuntu2.17",
+			Classification:   ClassificationSecurity,
+			Severity:         "UNSPECIFIED",
+			Repository:       "Ubuntu:20.04/focal-updates, Ubuntu:20.04/focal-security",
+		},
+		{
+			Package:          "tzdata",
+			Architecture:     "all",
+			CurrentVersion:   "2021a-0ubuntu0.20.04",
+			CandidateVersion: "2021e-0ubuntu0.20.04",
+			Classification:   ClassificationOther,
+			Repository:       "Ubuntu:20.04/focal-updates",
+		},
+		{
+			Package:          "linux-headers-5.4.0-91",
+			Architecture:     "all",
+			CandidateVersion: "5.4.0-91.102",
+			Classification:   ClassificationOther,
+			Repository:       "Ubuntu:20.04/focal-updates",
+		},
+	}, updates)
+}
+
+const checkUpdate = `
+openssl.x86_64                     1:1.0.2k-22.el7_9              updates
+openssl-libs.x86_64                1:1.0.2k-22.el7_9              updates
+python-perf-debuginfo-common-very-long-name.noarch
+                                   3.10.0-1160.el7                base
+tzdata.noarch                      2021e-1.el7                    updates
+Obsoleting Packages
+grub2.x86_64                       1:2.02-0.87.el7.centos         updates
+`
+
+const updateInfo = `FEDORA-EPEL-2021-1 bugfix        openssl-1:1.0.2k-22.el7_9.x86_64
+RHSA-2021:1234     Moderate/Sec.  openssl-1:1.0.2k-22.el7_9.x86_64
+RHSA-2021:2345     Important/Sec. openssl-libs-1:1.0.2k-22.el7_9.x86_64
+RHSA-2021:2222     Low/Sec.       openssl-libs-1:1.0.2k-21.el7_9.x86_64
+updateinfo list done
+`
+
+func TestScanRpm(t *testing.T) {
+	defer useFakeCommands(map[string]fakeCommand{
+		"yum -q check-update": {output: checkUpdate, exitCode: 100},
+		"rpm -qa --qf %{NAME}.%{ARCH} %{EPOCH}:%{VERSION}-%{RELEASE}\\n": {
+			output: "openssl.x86_64 1:1.0.2k-21.el7_9\nopenssl-libs.x86_64 1:1.0.2k-21.el7_9\ntzdata.noarch (none):2021a-1.el7\n",
+		},
+		"yum -q updateinfo list updates": {output: updateInfo},
+	})()
+
+	updates, err := scanRpm("yum")
+	assert.NoError(t, err)
+	assert.Equal(t, []Update{
+		{Package: "openssl", Architecture: "x86_64", CurrentVersion: "1:1.0.2k-21.el7_9", CandidateVersion: "1:1.0.2k-22.el7_9",
+			Classification: ClassificationSecurity, Severity: "MEDIUM", Advisory: "RHSA-2021:1234", Repository: "updates"},
+		{Package: "openssl-libs", Architecture: "x86_64", CurrentVersion: "1:1.0.2k-21.el7_9", CandidateVersion: "1:1.0.2k-22.el7_9",
+			Classification: ClassificationSecurity, Severity: "HIGH", Advisory: "RHSA-2021:2345", Repository: "updates"},
+		{Package: "python-perf-debuginfo-common-very-long-name", Architecture: "noarch", CandidateVersion: "3.10.0-1160.el7",
+			Classification: ClassificationOther, Repository: "base"},
+		{Package: "tzdata", Architecture: "noarch", CurrentVersion: "2021a-1.el7", CandidateVersion: "2021e-1.el7",
+			Classification: ClassificationOther, Repository: "updates"},
+	}, updates)
+}
+
+func TestScanRpmFails(t *testing.T) {
+	defer useFakeCommands(map[string]fakeCommand{
+		"dnf -q check-update": {output: "Error: Failed to download metadata for repo 'appstream'", exitCode: 1},
+	})()
+
+	_, err := scanRpm("dnf")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "Failed to download metadata")
+}
+
+const zypperUpdates = `S | Repository        | Name    | Current Version | Available Version | Arch
+--+-------------------+---------+-----------------+-------------------+-------
+v | SLES15-SP2-Updates | openssl | 1.1.1d-2.1      | 1.1.1d-2.20.1     | x86_64
+v | SLES15-SP2-Updates | vim     | 8.0.1568-5.3.1  | 8.0.1568-5.11.1   | x86_64
+`
+
+const zypperPatches = `Repository         | Name                                  | Category | Severity  | Interactive | Status | Summary
+-------------------+---------------------------------------+----------+-----------+-------------+--------+--------------------------------
+SLES15-SP2-Updates | SUSE-SLE-Module-Basesystem-15-SP2-123 | security | important | ---         | needed | Security update for openssl
+`
+
+func TestScanZypper(t *testing.T) {
+	defer useFakeCommands(map[string]fakeCommand{
+		"zypper --non-interactive --quiet list-updates":                     {output: zypperUpdates},
+		"zypper --non-interactive --quiet list-patches --category security": {output: zypperPatches},
+	})()
+
+	updates, err := scanZypper()
+	assert.NoError(t, err)
+	assert.Len(t, updates, 3)
+	assert.Equal(t, Update{Package: "openssl", Architecture: "x86_64", CurrentVersion: "1.1.1d-2.1", CandidateVersion: "1.1.1d-2.20.1",
+		Classification: ClassificationOther, Repository: "SLES15-SP2-Updates"}, updates[0])
+	assert.Equal(t, Update{Package: "SUSE-SLE-Module-Basesystem-15-SP2-123", Advisory: "SUSE-SLE-Module-Basesystem-15-SP2-123",
+		Classification: ClassificationSecurity, Severity: "HIGH", Repository: "SLES15-SP2-Updates"}, updates[2])
+}
+
+func TestDetectScanner(t *testing.T) {
+	lookPathOrig := lookPath
+	defer func() { lookPath = lookPathOrig }()
+	lookPath = func(file string) (string, error) {
+		if file == "zypper" || file == "yum" {
+			return "/usr/bin/" + file, nil
+		}
+		return "", errors.New("not found")
+	}
+
+	s, err := detectScanner("")
+	assert.NoError(t, err)
+	assert.Equal(t, managerYum, s.name)
+
+	s, err = detectScanner(managerZypper)
+	assert.NoError(t, err)
+	assert.Equal(t, managerZypper, s.name)
+
+	_, err = detectScanner(managerApt)
+	assert.Error(t, err)
+	_, err = detectScanner("pacman")
+	assert.Error(t, err)
+}
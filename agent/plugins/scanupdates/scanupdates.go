@@ -0,0 +1,298 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package scanupdates implements the aws:scanUpdates plugin, which reports the updates the package manager of the
+// host has available, for fleets that do not use the patch baselines of Patch Manager.
+package scanupdates
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/association/compliance/model"
+	"github.com/aws/amazon-ssm-agent/agent/context"
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/fileutil"
+	"github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/iohandler"
+	"github.com/aws/amazon-ssm-agent/agent/jsonutil"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/platform"
+	"github.com/aws/amazon-ssm-agent/agent/task"
+)
+
+// Classifications of an update
+const (
+	ClassificationSecurity = "Security"
+	ClassificationOther    = "Other"
+)
+
+// Destinations of the scan results
+const (
+	ReportAll        = "all"
+	ReportInventory  = "inventory"
+	ReportCompliance = "compliance"
+	ReportNone       = "none"
+)
+
+// Updates that make the instance non compliant
+const (
+	NonCompliantSecurity = "security"
+	NonCompliantAll      = "all"
+)
+
+const (
+	// DefaultTypeName is the inventory type and compliance type the updates are reported as
+	DefaultTypeName        = "Custom:PackageUpdates"
+	inventorySchemaVersion = "1.0"
+)
+
+var (
+	instanceID = platform.InstanceID
+	// dataStorePath is the root of the custom inventory folder of the instance
+	dataStorePath = appconfig.DefaultDataStorePath
+)
+
+// Plugin is the type for the aws:scanUpdates plugin.
+type Plugin struct {
+}
+
+// ScanUpdatesPluginInput represents the parameters of the aws:scanUpdates plugin.
+type ScanUpdatesPluginInput struct {
+	contracts.PluginInput
+	// Manager selects the package manager, the first one found on the host is used when it is empty
+	Manager string `json:"manager"`
+	// RefreshMetadata downloads the package lists of the repositories before the scan
+	RefreshMetadata bool `json:"refreshMetadata"`
+	// Report is where the updates are reported: all, inventory, compliance or none
+	Report string `json:"report"`
+	// TypeName is the custom inventory type and compliance type of the report
+	TypeName string `json:"typeName"`
+	// NonCompliant selects the updates that make the instance non compliant: security or all
+	NonCompliant string `json:"nonCompliant"`
+}
+
+// Update is an update available for a package, normalized across package managers
+type Update struct {
+	Package          string
+	Architecture     string `json:",omitempty"`
+	CurrentVersion   string `json:",omitempty"`
+	CandidateVersion string `json:",omitempty"`
+	Classification   string
+	// Severity is a compliance severity, it is only known for security updates of repositories publishing advisories
+	Severity   string `json:",omitempty"`
+	Advisory   string `json:",omitempty"`
+	Repository string `json:",omitempty"`
+}
+
+// ScanUpdatesPluginOutput is the result of the scan, the embedded report is queued for PutComplianceItems by the agent
+// once the document completes when the updates are reported to compliance
+type ScanUpdatesPluginOutput struct {
+	model.CustomComplianceReport
+	Manager         string
+	Updates         []Update
+	SecurityUpdates int
+	OtherUpdates    int
+}
+
+// NewPlugin returns a new instance of the plugin.
+func NewPlugin() (*Plugin, error) {
+	var plugin Plugin
+	return &plugin, nil
+}
+
+// Name returns the name of the plugin
+func Name() string {
+	return appconfig.PluginNameAwsScanUpdates
+}
+
+// Execute lists the available updates and reports them to inventory and compliance.
+func (p *Plugin) Execute(context context.T, config contracts.Configuration, cancelFlag task.CancelFlag, output iohandler.IOHandler) {
+	log := context.Log()
+	log.Infof("%v started with configuration %v", Name(), config)
+
+	if cancelFlag.ShutDown() {
+		output.MarkAsShutdown()
+	} else if cancelFlag.Canceled() {
+		output.MarkAsCancelled()
+	} else if input, err := parseAndValidateInput(config.Properties); err != nil {
+		output.MarkAsFailed(err)
+	} else if scanner, err := detectScanner(input.Manager); err != nil {
+		output.MarkAsFailed(err)
+	} else {
+		runScanUpdates(log, scanner, input, output)
+	}
+}
+
+// parseAndValidateInput reads the plugin input and applies the defaults
+func parseAndValidateInput(rawPluginInput interface{}) (*ScanUpdatesPluginInput, error) {
+	var input ScanUpdatesPluginInput
+	if err := jsonutil.Remarshal(rawPluginInput, &input); err != nil {
+		return nil, fmt.Errorf("invalid format in plugin properties %v; error %v", rawPluginInput, err)
+	}
+	if input.Report == "" {
+		input.Report = ReportAll
+	}
+	if input.TypeName == "" {
+		input.TypeName = DefaultTypeName
+	}
+	if input.NonCompliant == "" {
+		input.NonCompliant = NonCompliantSecurity
+	}
+	switch input.Report {
+	case ReportAll, ReportInventory, ReportCompliance, ReportNone:
+	default:
+		return nil, fmt.Errorf("report %q is not supported, it must be %v, %v, %v or %v", input.Report, ReportAll, ReportInventory, ReportCompliance, ReportNone)
+	}
+	if input.NonCompliant != NonCompliantSecurity && input.NonCompliant != NonCompliantAll {
+		return nil, fmt.Errorf("nonCompliant %q is not supported, it must be %v or %v", input.NonCompliant, NonCompliantSecurity, NonCompliantAll)
+	}
+	if err := model.ValidateCustomComplianceReport(model.CustomComplianceReport{ComplianceType: input.TypeName}); err != nil {
+		return nil, fmt.Errorf("typeName is invalid: %v", err)
+	}
+	return &input, nil
+}
+
+func runScanUpdates(log log.T, scanner *scanner, input *ScanUpdatesPluginInput, output iohandler.IOHandler) {
+	output.AppendInfof("Using package manager %v", scanner.name)
+	if input.RefreshMetadata {
+		if out, exitCode, err := runCommand(scanner.binary, scanner.refreshArgs...); err != nil || exitCode != 0 {
+			output.MarkAsFailed(commandError(scanner.binary, out, exitCode, err))
+			return
+		}
+	}
+	updates, err := scanner.scan()
+	if err != nil {
+		output.MarkAsFailed(err)
+		return
+	}
+	sort.Slice(updates, func(i, j int) bool {
+		if updates[i].Package != updates[j].Package {
+			return updates[i].Package < updates[j].Package
+		}
+		return updates[i].Architecture < updates[j].Architecture
+	})
+
+	result := ScanUpdatesPluginOutput{Manager: scanner.name, Updates: updates}
+	for _, update := range updates {
+		if update.Classification == ClassificationSecurity {
+			result.SecurityUpdates++
+		} else {
+			result.OtherUpdates++
+		}
+	}
+	output.AppendInfof("%v security updates and %v other updates are available", result.SecurityUpdates, result.OtherUpdates)
+
+	if input.Report == ReportAll || input.Report == ReportInventory {
+		if err = writeInventory(log, input.TypeName, scanner.name, updates); err != nil {
+			output.MarkAsFailed(err)
+			return
+		}
+		output.AppendInfof("Updates are reported to inventory as %v with the next inventory collection", input.TypeName)
+	}
+	if input.Report == ReportAll || input.Report == ReportCompliance {
+		result.CustomComplianceReport = complianceReport(input, scanner.name, updates)
+		summary := model.SummarizeCustomCompliance(result.CustomComplianceReport)
+		output.AppendInfof("%v: %v non compliant items", input.TypeName, summary.NonCompliantCount)
+	}
+	output.SetOutput(result)
+	output.SetStatus(contracts.ResultStatusSuccess)
+}
+
+// complianceReport lists the updates that make the instance non compliant
+func complianceReport(input *ScanUpdatesPluginInput, manager string, updates []Update) model.CustomComplianceReport {
+	report := model.CustomComplianceReport{ComplianceType: input.TypeName, Items: []model.CustomComplianceItem{}}
+	for _, update := range updates {
+		if input.NonCompliant == NonCompliantSecurity && update.Classification != ClassificationSecurity {
+			continue
+		}
+		severity := update.Severity
+		if severity == "" {
+			severity = model.UNSPECIFIED
+		}
+		report.Items = append(report.Items, model.CustomComplianceItem{
+			Id:       updateID(update),
+			Title:    strings.TrimSpace(fmt.Sprintf("%v %v", update.Package, update.CandidateVersion)),
+			Severity: severity,
+			Status:   model.NON_COMPLIANT,
+			Details:  updateAttributes(manager, update),
+		})
+	}
+	if len(report.Items) > model.MaxCustomComplianceItems {
+		report.Items = report.Items[:model.MaxCustomComplianceItems]
+	}
+	return report
+}
+
+// writeInventory writes the updates as a custom inventory type the inventory plugin collects
+func writeInventory(log log.T, typeName string, manager string, updates []Update) error {
+	id, err := instanceID()
+	if err != nil {
+		return fmt.Errorf("failed to get the instance id: %v", err)
+	}
+	content := make([]map[string]string, len(updates))
+	for i, update := range updates {
+		content[i] = updateAttributes(manager, update)
+		content[i]["Package"] = update.Package
+	}
+	item := struct {
+		TypeName      string
+		SchemaVersion string
+		Content       []map[string]string
+	}{typeName, inventorySchemaVersion, content}
+	text, err := jsonutil.Marshal(item)
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Join(dataStorePath, id, appconfig.InventoryRootDirName, appconfig.CustomInventoryRootDirName)
+	path := filepath.Join(dir, strings.TrimPrefix(typeName, model.CustomComplianceTypePrefix)+".json")
+	if err = fileutil.MakeDirs(dir); err == nil {
+		err = fileutil.WriteAllText(path, text)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write the inventory of the updates: %v", err)
+	}
+	log.Debugf("wrote %v updates to %v", len(updates), path)
+	return nil
+}
+
+func updateID(update Update) string {
+	if update.Architecture == "" {
+		return update.Package
+	}
+	return update.Package + "." + update.Architecture
+}
+
+// updateAttributes are the details of an update reported to inventory and compliance
+func updateAttributes(manager string, update Update) map[string]string {
+	attributes := map[string]string{
+		"Manager":          manager,
+		"Classification":   update.Classification,
+		"CandidateVersion": update.CandidateVersion,
+	}
+	for name, value := range map[string]string{
+		"Architecture":   update.Architecture,
+		"CurrentVersion": update.CurrentVersion,
+		"Severity":       update.Severity,
+		"Advisory":       update.Advisory,
+		"Repository":     update.Repository,
+	} {
+		if value != "" {
+			attributes[name] = value
+		}
+	}
+	return attributes
+}
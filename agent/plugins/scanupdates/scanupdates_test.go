@@ -0,0 +1,107 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package scanupdates
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/iohandler"
+	"github.com/aws/amazon-ssm-agent/agent/jsonutil"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/stretchr/testify/assert"
+)
+
+var logger = log.NewMockLog()
+
+// fakeCommand is the result of a package manager command
+type fakeCommand struct {
+	output   string
+	exitCode int
+}
+
+// useFakeCommands replaces the command runner with canned results keyed by command line and returns a function restoring it
+func useFakeCommands(commands map[string]fakeCommand) func() {
+	runCommandOrig := runCommand
+	runCommand = func(name string, args ...string) (string, int, error) {
+		result, found := commands[name+" "+strings.Join(args, " ")]
+		if !found {
+			return name + ": command not found", 127, nil
+		}
+		return result.output, result.exitCode, nil
+	}
+	return func() { runCommand = runCommandOrig }
+}
+
+func TestParseAndValidateInput(t *testing.T) {
+	input, err := parseAndValidateInput(map[string]interface{}{})
+	assert.NoError(t, err)
+	assert.Equal(t, ReportAll, input.Report)
+	assert.Equal(t, DefaultTypeName, input.TypeName)
+	assert.Equal(t, NonCompliantSecurity, input.NonCompliant)
+
+	for _, properties := range []map[string]interface{}{
+		{"report": "s3"},
+		{"nonCompliant": "critical"},
+		{"typeName": "PackageUpdates"},
+	} {
+		_, err = parseAndValidateInput(properties)
+		assert.Error(t, err, "%v", properties)
+	}
+}
+
+func TestRunScanUpdates(t *testing.T) {
+	defer useFakeCommands(map[string]fakeCommand{
+		"apt-get --simulate -o Debug::NoLocking=1 dist-upgrade": {output: aptSimulation},
+	})()
+	dir, err := ioutil.TempDir("", "scanupdates")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	instanceIDOrig, dataStorePathOrig := instanceID, dataStorePath
+	instanceID = func() (string, error) { return "i-1234567890", nil }
+	dataStorePath = dir
+	defer func() { instanceID, dataStorePath = instanceIDOrig, dataStorePathOrig }()
+
+	input, _ := parseAndValidateInput(map[string]interface{}{})
+	output := iohandler.DefaultIOHandler{}
+	runScanUpdates(logger, scanners[managerApt], input, &output)
+	assert.Equal(t, 0, output.GetExitCode(), output.GetStderr())
+	assert.Contains(t, output.GetStdout(), "1 security updates and 2 other updates are available")
+
+	result := output.GetOutput().(ScanUpdatesPluginOutput)
+	assert.Equal(t, managerApt, result.Manager)
+	assert.Len(t, result.Updates, 3)
+	assert.Equal(t, DefaultTypeName, result.ComplianceType)
+	assert.Len(t, result.Items, 1, "only the security updates are non compliant")
+	assert.Equal(t, "libssl1.1.amd64", result.Items[0].Id)
+	assert.Equal(t, "1.1.1f-1ubuntu2.16", result.Items[0].Details["CurrentVersion"])
+
+	var inventory struct {
+		TypeName string
+		Content  []map[string]string
+	}
+	assert.NoError(t, jsonutil.UnmarshalFile(filepath.Join(dir, "i-1234567890", "inventory", "custom", "PackageUpdates.json"), &inventory))
+	assert.Equal(t, DefaultTypeName, inventory.TypeName)
+	assert.Len(t, inventory.Content, 3)
+	assert.Equal(t, "libssl1.1", inventory.Content[0]["Package"])
+	assert.Equal(t, "Security", inventory.Content[0]["Classification"])
+
+	input.Report, input.NonCompliant = ReportCompliance, NonCompliantAll
+	output = iohandler.DefaultIOHandler{}
+	runScanUpdates(logger, scanners[managerApt], input, &output)
+	assert.Len(t, output.GetOutput().(ScanUpdatesPluginOutput).Items, 3)
+}
@@ -0,0 +1,495 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+
+// +build linux
+
+package privilege
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/outofproc/confinement"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/platform"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	operationStart = "start"
+	operationWait  = "wait"
+	operationKill  = "kill"
+
+	operationVaultRetrieve = "vault-retrieve"
+	operationVaultStore    = "vault-store"
+	operationVaultRemove   = "vault-remove"
+	operationVaultExists   = "vault-exists"
+	operationCredentials   = "credentials"
+
+	// exitedProcessRetention is how long the exit status of a worker is kept for a wait request
+	exitedProcessRetention = time.Hour
+)
+
+// request is sent by the unprivileged agent worker over the broker socket, one request per connection
+type request struct {
+	Operation        string
+	Name             string
+	Args             []string
+	Pid              int
+	ExecutionContext confinement.Context
+	Key              string `json:",omitempty"`
+	Data             []byte `json:",omitempty"`
+}
+
+// response is returned by the broker, a wait request only gets its response once the worker exited
+type response struct {
+	Pid         int
+	StartTime   time.Time
+	Error       string
+	Data        []byte             `json:",omitempty"`
+	Exists      bool               `json:",omitempty"`
+	Credentials *credentials.Value `json:",omitempty"`
+	Expiration  time.Time
+}
+
+// allowedWorkers returns the only executables the broker starts as root
+var allowedWorkers = func() []string {
+	return []string{appconfig.DefaultDocumentWorker, appconfig.DefaultSessionWorker}
+}
+
+// activeBroker is the broker started by the core agent, nil if the agent worker runs as root
+var activeBroker *Broker
+
+// Broker starts document and session workers as root on behalf of the unprivileged agent worker.
+// It also keeps the vault and the private key of the instance, the worker gets its credentials from the broker.
+type Broker struct {
+	log       log.T
+	listener  net.Listener
+	clientUid uint32
+	clientGid uint32
+	mutex     sync.Mutex
+	processes map[int]*brokerProcess
+}
+
+type brokerProcess struct {
+	done chan struct{}
+	err  error
+}
+
+// unprivilegedUser is the account the agent worker runs as
+type unprivilegedUser struct {
+	uid uint32
+	gid uint32
+}
+
+// StartBroker starts the privilege broker in the core agent if the agent worker is configured to run unprivileged.
+// If the broker cannot be started the agent worker keeps running as root.
+func StartBroker(log log.T) {
+	config, err := getAppConfig(false)
+	if err != nil || !config.Privilege.Unprivileged {
+		return
+	}
+
+	account, err := lookupUser(config.Privilege.User)
+	if err != nil {
+		log.Errorf("failed to find unprivileged user %v, agent worker runs as root: %v", config.Privilege.User, err)
+		return
+	}
+	broker, err := NewBroker(log, SocketPath, account)
+	if err != nil {
+		log.Errorf("failed to start privilege broker, agent worker runs as root: %v", err)
+		return
+	}
+	if err = prepareDirectories(account); err != nil {
+		log.Errorf("failed to give %v access to the agent directories, agent worker runs as root: %v", config.Privilege.User, err)
+		broker.Stop()
+		return
+	}
+	go broker.Serve()
+	activeBroker = broker
+	log.Infof("privilege broker started, agent worker runs as %v", config.Privilege.User)
+}
+
+// PrepareWorker makes the agent worker command run as the unprivileged user when the broker is running.
+// Setting the credential of a root process to a non-root user clears all its capabilities on exec.
+func PrepareWorker(command *exec.Cmd) {
+	if activeBroker == nil {
+		return
+	}
+	if command.SysProcAttr == nil {
+		command.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	command.SysProcAttr.Credential = &syscall.Credential{
+		Uid: activeBroker.clientUid,
+		Gid: activeBroker.clientGid,
+	}
+	command.SysProcAttr.AmbientCaps = nil
+	command.Env = append(command.Env, UnprivilegedEnvVar+"=true")
+}
+
+// NewBroker listens on the socket path, only connections of root and the unprivileged user are served
+func NewBroker(log log.T, socketPath string, account unprivilegedUser) (*Broker, error) {
+	if err := os.MkdirAll(filepath.Dir(socketPath), appconfig.ReadWriteExecuteAccess); err != nil {
+		return nil, err
+	}
+	os.Remove(socketPath)
+	listener, err := net.Listen("unix", socketPath)
+	if err != nil {
+		return nil, err
+	}
+	if err = os.Chown(socketPath, int(account.uid), int(account.gid)); err == nil {
+		err = os.Chmod(socketPath, appconfig.ReadWriteAccess)
+	}
+	if err != nil {
+		listener.Close()
+		return nil, err
+	}
+	return &Broker{
+		log:       log,
+		listener:  listener,
+		clientUid: account.uid,
+		clientGid: account.gid,
+		processes: make(map[int]*brokerProcess),
+	}, nil
+}
+
+// Serve handles connections until the broker is stopped
+func (b *Broker) Serve() {
+	for {
+		conn, err := b.listener.Accept()
+		if err != nil {
+			return
+		}
+		go b.handle(conn.(*net.UnixConn))
+	}
+}
+
+// Stop closes the broker socket, workers that were started keep running
+func (b *Broker) Stop() {
+	b.listener.Close()
+}
+
+func (b *Broker) handle(conn *net.UnixConn) {
+	defer conn.Close()
+
+	var resp response
+	var req request
+	if err := b.authorize(conn); err != nil {
+		resp.Error = err.Error()
+	} else if err = json.NewDecoder(conn).Decode(&req); err != nil {
+		resp.Error = fmt.Sprintf("invalid request: %v", err)
+	} else {
+		resp = b.process(req)
+	}
+	if err := json.NewEncoder(conn).Encode(resp); err != nil {
+		b.log.Debugf("failed to send privilege broker response: %v", err)
+	}
+}
+
+// authorize checks the credentials of the peer process
+func (b *Broker) authorize(conn *net.UnixConn) error {
+	rawConn, err := conn.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var cred *syscall.Ucred
+	var credErr error
+	if err = rawConn.Control(func(fd uintptr) {
+		cred, credErr = syscall.GetsockoptUcred(int(fd), syscall.SOL_SOCKET, syscall.SO_PEERCRED)
+	}); err != nil {
+		return err
+	}
+	if credErr != nil {
+		return credErr
+	}
+	if cred.Uid != 0 && cred.Uid != b.clientUid {
+		b.log.Warnf("rejected privilege broker request of uid %v, pid %v", cred.Uid, cred.Pid)
+		return fmt.Errorf("uid %v is not allowed to use the privilege broker", cred.Uid)
+	}
+	return nil
+}
+
+func (b *Broker) process(req request) response {
+	switch req.Operation {
+	case operationStart:
+		return b.start(req.Name, req.Args, req.ExecutionContext)
+	case operationWait:
+		return b.wait(req.Pid)
+	case operationKill:
+		return b.kill(req.Pid)
+	case operationVaultRetrieve:
+		return b.vaultRetrieve(req.Key)
+	case operationVaultStore:
+		return b.vaultStore(req.Key, req.Data)
+	case operationVaultRemove:
+		return b.vaultRemove(req.Key)
+	case operationVaultExists:
+		return response{Exists: vaultExists()}
+	case operationCredentials:
+		return b.credentials()
+	}
+	return response{Error: fmt.Sprintf("unknown operation %q", req.Operation)}
+}
+
+// start launches one of the allowed workers as root in its own process group and execution context
+func (b *Broker) start(name string, args []string, context confinement.Context) response {
+	allowed := false
+	for _, worker := range allowedWorkers() {
+		allowed = allowed || name == worker
+	}
+	if !allowed {
+		b.log.Warnf("rejected privilege broker request to start %v", name)
+		return response{Error: fmt.Sprintf("%v is not a worker the privilege broker starts", name)}
+	}
+
+	name, args, err := confinement.Wrap(context, name, args)
+	if err != nil {
+		return response{Error: err.Error()}
+	}
+	cmd := exec.Command(name, args...)
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	if err = cmd.Start(); err != nil {
+		return response{Error: err.Error()}
+	}
+	startTime := time.Now().UTC()
+	pid := cmd.Process.Pid
+	b.log.Infof("privilege broker started %v as root, pid %v", name, pid)
+
+	process := &brokerProcess{done: make(chan struct{})}
+	b.mutex.Lock()
+	b.processes[pid] = process
+	b.mutex.Unlock()
+
+	go func() {
+		process.err = cmd.Wait()
+		close(process.done)
+		time.AfterFunc(exitedProcessRetention, func() { b.remove(pid, process) })
+	}()
+	return response{Pid: pid, StartTime: startTime}
+}
+
+// wait blocks until a worker started by the broker exits
+func (b *Broker) wait(pid int) response {
+	process, err := b.find(pid)
+	if err != nil {
+		return response{Error: err.Error()}
+	}
+	<-process.done
+	b.remove(pid, process)
+	if process.err != nil {
+		return response{Pid: pid, Error: process.err.Error()}
+	}
+	return response{Pid: pid}
+}
+
+// kill terminates the process group of a worker started by the broker
+func (b *Broker) kill(pid int) response {
+	if _, err := b.find(pid); err != nil {
+		return response{Error: err.Error()}
+	}
+	if err := syscall.Kill(-pid, syscall.SIGKILL); err != nil {
+		return response{Pid: pid, Error: err.Error()}
+	}
+	return response{Pid: pid}
+}
+
+func (b *Broker) find(pid int) (*brokerProcess, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if process, ok := b.processes[pid]; ok {
+		return process, nil
+	}
+	return nil, fmt.Errorf("process %v was not started by the privilege broker", pid)
+}
+
+func (b *Broker) remove(pid int, process *brokerProcess) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if b.processes[pid] == process {
+		delete(b.processes, pid)
+	}
+}
+
+func lookupUser(name string) (account unprivilegedUser, err error) {
+	var u *user.User
+	if u, err = user.Lookup(name); err != nil {
+		return
+	}
+	var uid, gid uint64
+	if uid, err = strconv.ParseUint(u.Uid, 10, 32); err != nil {
+		return
+	}
+	if gid, err = strconv.ParseUint(u.Gid, 10, 32); err != nil {
+		return
+	}
+	if uid == 0 {
+		return account, fmt.Errorf("user %v is root", name)
+	}
+	return unprivilegedUser{uid: uint32(uid), gid: uint32(gid)}, nil
+}
+
+// rootOwnedPaths stay owned by root: the vault holds the private key and the fingerprint of the instance,
+// and root workers install the updates, packages and downloaded artifacts kept in the others
+var rootOwnedPaths = func() []string {
+	return []string{
+		filepath.Join(appconfig.DefaultDataStorePath, "Vault"),
+		appconfig.UpdaterArtifactsRoot,
+		appconfig.ManifestCacheDirectory,
+		appconfig.PackageRoot,
+		filepath.Join(appconfig.DefaultDataStorePath, "artifactcache"),
+		filepath.Join(appconfig.DefaultDataStorePath, "downloadcontent"),
+	}
+}
+
+// workerDirectories are the directories of the data store the agent worker writes to, they are created for it
+// since the data store directory itself stays owned by root
+var workerDirectories = func() []string {
+	dirs := []string{
+		filepath.Join(appconfig.DefaultDataStorePath, "outbox"),
+		filepath.Join(appconfig.DefaultDataStorePath, "reboot"),
+		filepath.Join(appconfig.DefaultDataStorePath, appconfig.ComplianceRootDirName),
+		filepath.Join(appconfig.DefaultDataStorePath, appconfig.BootstrapDocumentFolderName),
+	}
+	if instanceID, err := platform.InstanceID(); err == nil && instanceID != "" {
+		dirs = append(dirs, filepath.Join(appconfig.DefaultDataStorePath, instanceID))
+	}
+	return dirs
+}
+
+// prepareDirectories gives the unprivileged user ownership of the data the agent worker writes and of the worker log
+// folder. The data store and log directories and the root owned paths in the data store stay owned by root.
+var prepareDirectories = func(account unprivilegedUser) error {
+	for _, dir := range workerDirectories() {
+		if err := os.MkdirAll(dir, appconfig.ReadWriteExecuteAccess); err != nil {
+			return err
+		}
+	}
+	if err := giveDirectoryContent(appconfig.DefaultDataStorePath, rootOwnedPaths(), account); err != nil {
+		return err
+	}
+	// the core agent keeps writing its logs next to the worker log folder
+	if err := os.MkdirAll(filepath.Join(log.DefaultLogDir, log.WorkerLogDirName), appconfig.ReadWriteExecuteAccess); err != nil {
+		return err
+	}
+	return chownDirectoryContent(log.DefaultLogDir, account, func(name string) bool {
+		return name == log.WorkerLogDirName
+	})
+}
+
+// giveDirectoryContent gives the unprivileged user ownership of the entries of dir except rootOwned,
+// which are chowned to root with everything under them
+func giveDirectoryContent(dir string, rootOwned []string, account unprivilegedUser) error {
+	keep := make(map[string]bool, len(rootOwned))
+	for _, path := range rootOwned {
+		keep[filepath.Clean(path)] = true
+	}
+	return chownDirectoryContent(dir, account, func(name string) bool {
+		return !keep[filepath.Join(dir, name)]
+	})
+}
+
+// chownDirectoryContent creates dir owned by root and gives the unprivileged user ownership of its entries
+// for which userOwned is true, the others are chowned to root
+func chownDirectoryContent(dir string, account unprivilegedUser, userOwned func(name string) bool) error {
+	if err := os.MkdirAll(dir, appconfig.ReadWriteExecuteAccess); err != nil {
+		return err
+	}
+	dirFd, err := openDirectory(unix.AT_FDCWD, filepath.Clean(dir))
+	if err != nil {
+		return err
+	}
+	defer unix.Close(dirFd)
+	if err = unix.Fchown(dirFd, 0, 0); err != nil {
+		return err
+	}
+	names, err := readDirectoryNames(dirFd)
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		uid, gid := 0, 0
+		if userOwned(name) {
+			uid, gid = int(account.uid), int(account.gid)
+		}
+		if err = chownTree(dirFd, name, uid, gid); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// chownTree changes the owner of the entry name of the directory dirFd and of everything under it. Directories are
+// opened relative to their parent without following symbolic links, so a path swapped for a link while the tree is
+// walked cannot redirect the walk out of it.
+func chownTree(dirFd int, name string, uid, gid int) error {
+	fd, err := openDirectory(dirFd, name)
+	switch err {
+	case nil:
+	case unix.ENOTDIR, unix.ELOOP:
+		// files and symbolic links are chowned by name without being followed
+		if err = unix.Fchownat(dirFd, name, uid, gid, unix.AT_SYMLINK_NOFOLLOW); err == unix.ENOENT {
+			return nil
+		}
+		return err
+	case unix.ENOENT:
+		return nil
+	default:
+		return err
+	}
+	defer unix.Close(fd)
+	if err = unix.Fchown(fd, uid, gid); err != nil {
+		return err
+	}
+	names, err := readDirectoryNames(fd)
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		if err = chownTree(fd, name, uid, gid); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// openDirectory opens the directory name relative to dirFd, it fails with ELOOP or ENOTDIR if name is not a directory
+func openDirectory(dirFd int, name string) (int, error) {
+	return unix.Openat(dirFd, name, unix.O_RDONLY|unix.O_DIRECTORY|unix.O_NOFOLLOW|unix.O_CLOEXEC, 0)
+}
+
+// readDirectoryNames lists the entries of the directory fd
+func readDirectoryNames(fd int) (names []string, err error) {
+	buf := make([]byte, 8192)
+	for {
+		var n int
+		if n, err = unix.ReadDirent(fd, buf); err != nil {
+			return nil, err
+		}
+		if n <= 0 {
+			return names, nil
+		}
+		_, _, names = unix.ParseDirent(buf[:n], -1, names)
+	}
+}
@@ -0,0 +1,108 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+
+// +build linux
+
+package privilege
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/outofproc/confinement"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type BrokerTestSuite struct {
+	suite.Suite
+	dir    string
+	broker *Broker
+}
+
+func (suite *BrokerTestSuite) SetupTest() {
+	dir, err := ioutil.TempDir("", "privilege")
+	suite.Require().NoError(err)
+	suite.dir = dir
+	SocketPath = filepath.Join(dir, "privilege")
+	allowedWorkers = func() []string { return []string{"/bin/sleep", "/bin/false"} }
+
+	// the test process connects as the client user of the broker
+	suite.broker, err = NewBroker(log.NewMockLog(), SocketPath,
+		unprivilegedUser{uid: uint32(os.Getuid()), gid: uint32(os.Getgid())})
+	suite.Require().NoError(err)
+	go suite.broker.Serve()
+}
+
+func (suite *BrokerTestSuite) TearDownTest() {
+	suite.broker.Stop()
+	os.RemoveAll(suite.dir)
+}
+
+func (suite *BrokerTestSuite) TestStartAndWait() {
+	process, err := StartWorker("/bin/sleep", []string{"0"}, confinement.Context{})
+	suite.Require().NoError(err)
+	assert.NotZero(suite.T(), process.Pid())
+	assert.WithinDuration(suite.T(), time.Now(), process.StartTime(), time.Minute)
+	assert.NoError(suite.T(), process.Wait())
+
+	// the broker forgets a worker once it was waited for
+	assert.Error(suite.T(), process.Wait())
+}
+
+func (suite *BrokerTestSuite) TestWaitReturnsExitError() {
+	process, err := StartWorker("/bin/false", nil, confinement.Context{})
+	suite.Require().NoError(err)
+	assert.Error(suite.T(), process.Wait())
+}
+
+func (suite *BrokerTestSuite) TestKill() {
+	process, err := StartWorker("/bin/sleep", []string{"60"}, confinement.Context{})
+	suite.Require().NoError(err)
+	assert.NoError(suite.T(), process.Kill())
+	assert.Error(suite.T(), process.Wait())
+}
+
+func (suite *BrokerTestSuite) TestRejectsOtherExecutables() {
+	_, err := StartWorker("/bin/sh", []string{"-c", "id"}, confinement.Context{})
+	assert.Error(suite.T(), err)
+}
+
+func (suite *BrokerTestSuite) TestRejectsUnknownProcesses() {
+	process := &Process{pid: os.Getpid()}
+	assert.Error(suite.T(), process.Kill())
+	assert.Error(suite.T(), process.Wait())
+}
+
+func TestBrokerTestSuite(t *testing.T) {
+	suite.Run(t, new(BrokerTestSuite))
+}
+
+func TestPrepareWorker(t *testing.T) {
+	activeBroker = &Broker{clientUid: 1001, clientGid: 1002}
+	defer func() { activeBroker = nil }()
+
+	command := exec.Command("ssm-agent-worker")
+	command.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	PrepareWorker(command)
+	assert.True(t, command.SysProcAttr.Setpgid)
+	assert.Equal(t, &syscall.Credential{Uid: 1001, Gid: 1002}, command.SysProcAttr.Credential)
+	assert.Contains(t, command.Env, UnprivilegedEnvVar+"=true")
+}
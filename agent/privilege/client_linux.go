@@ -0,0 +1,83 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+
+// +build linux
+
+package privilege
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/outofproc/confinement"
+)
+
+// Process is a worker started as root by the privilege broker
+type Process struct {
+	pid       int
+	startTime time.Time
+}
+
+// StartWorker asks the privilege broker to start a document or session worker as root in the execution context
+func StartWorker(name string, argv []string, context confinement.Context) (*Process, error) {
+	resp, err := call(request{Operation: operationStart, Name: name, Args: argv, ExecutionContext: context})
+	if err != nil {
+		return nil, err
+	}
+	return &Process{pid: resp.Pid, startTime: resp.StartTime}, nil
+}
+
+// Pid returns the process id of the worker
+func (p *Process) Pid() int {
+	return p.pid
+}
+
+// StartTime returns the time the broker started the worker
+func (p *Process) StartTime() time.Time {
+	return p.startTime
+}
+
+// Kill kills the worker and its process group
+func (p *Process) Kill() error {
+	_, err := call(request{Operation: operationKill, Pid: p.pid})
+	return err
+}
+
+// Wait blocks until the worker exits
+func (p *Process) Wait() error {
+	_, err := call(request{Operation: operationWait, Pid: p.pid})
+	return err
+}
+
+// call sends one request to the privilege broker and returns its response
+func call(req request) (resp response, err error) {
+	var conn net.Conn
+	if conn, err = net.Dial("unix", SocketPath); err != nil {
+		return
+	}
+	defer conn.Close()
+
+	if err = json.NewEncoder(conn).Encode(req); err != nil {
+		return
+	}
+	if err = json.NewDecoder(conn).Decode(&resp); err != nil {
+		return
+	}
+	if resp.Error != "" {
+		err = errors.New(resp.Error)
+	}
+	return
+}
@@ -0,0 +1,86 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package privilege runs the agent worker without root privileges on Linux. The root core process keeps a small
+// broker listening on a local socket, which only starts document and session workers for plugins that need root
+// and serves the vault entries and the managed instance credentials of the worker.
+package privilege
+
+import (
+	"os"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/common/message"
+)
+
+// UnprivilegedEnvVar is set in the environment of an agent worker started without root privileges
+const UnprivilegedEnvVar = log.UnprivilegedEnvVar
+
+// SocketPath is the unix socket the privilege broker listens on
+var SocketPath = message.DefaultCoreAgentChannel + "privilege"
+
+var getAppConfig = appconfig.Config
+
+// pluginPrivileges declares the privilege each plugin needs, plugins missing here need root.
+// Only plugins that neither change the system nor switch users can run in a worker started by the unprivileged agent.
+var pluginPrivileges = map[string]string{
+	appconfig.PluginNameAwsRunShellScript:           appconfig.PrivilegeRoot,
+	appconfig.PluginNameAwsRunPowerShellScript:      appconfig.PrivilegeRoot,
+	appconfig.PluginNameAwsConfigurePackage:         appconfig.PrivilegeRoot,
+	appconfig.PluginNameAwsConfigureDaemon:          appconfig.PrivilegeRoot,
+	appconfig.PluginNameAwsConfigureCloudWatchAgent: appconfig.PrivilegeRoot,
+	appconfig.PluginNameAwsScanUpdates:              appconfig.PrivilegeRoot,
+	appconfig.PluginNameAwsAgentUpdate:              appconfig.PrivilegeRoot,
+	appconfig.PluginNameAwsSoftwareInventory:        appconfig.PrivilegeRoot,
+	appconfig.PluginNameDomainJoin:                  appconfig.PrivilegeRoot,
+	appconfig.PluginNameCloudWatch:                  appconfig.PrivilegeRoot,
+	appconfig.PluginNameDockerContainer:             appconfig.PrivilegeRoot,
+	appconfig.PluginNameConfigureDocker:             appconfig.PrivilegeRoot,
+	appconfig.PluginNameAwsPowerShellModule:         appconfig.PrivilegeRoot,
+	appconfig.PluginNameAwsApplications:             appconfig.PrivilegeRoot,
+	appconfig.PluginNameRefreshAssociation:          appconfig.PrivilegeUser,
+	appconfig.PluginNameStandardStream:              appconfig.PrivilegeRoot,
+	appconfig.PluginNameInteractiveCommands:         appconfig.PrivilegeRoot,
+	appconfig.PluginNamePort:                        appconfig.PrivilegeUser,
+	appconfig.PluginNameAwsPutComplianceItems:       appconfig.PrivilegeUser,
+}
+
+// IsUnprivileged returns true if this process was started by the core agent without root privileges
+func IsUnprivileged() bool {
+	return os.Getenv(UnprivilegedEnvVar) == "true"
+}
+
+// RequiresRoot returns true if the plugin needs a worker running as root.
+// The Privilege.PluginPrivileges config takes precedence over the declarations of the agent.
+func RequiresRoot(pluginName string) bool {
+	if config, err := getAppConfig(false); err == nil {
+		if privilege, ok := config.Privilege.PluginPrivileges[pluginName]; ok {
+			return privilege != appconfig.PrivilegeUser
+		}
+	}
+	if privilege, ok := pluginPrivileges[pluginName]; ok {
+		return privilege != appconfig.PrivilegeUser
+	}
+	return true
+}
+
+// DocumentRequiresRoot returns true if any of the plugins of a document needs a worker running as root
+func DocumentRequiresRoot(pluginNames []string) bool {
+	for _, pluginName := range pluginNames {
+		if RequiresRoot(pluginName) {
+			return true
+		}
+	}
+	return false
+}
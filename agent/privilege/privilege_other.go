@@ -0,0 +1,52 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+
+// +build !linux
+
+package privilege
+
+import (
+	"fmt"
+	"os/exec"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/outofproc/confinement"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+)
+
+// Process is a worker started as root by the privilege broker
+type Process struct{}
+
+// StartBroker does nothing, the agent worker only runs unprivileged on Linux
+func StartBroker(log log.T) {}
+
+// PrepareWorker does nothing, the agent worker only runs unprivileged on Linux
+func PrepareWorker(command *exec.Cmd) {}
+
+// StartWorker is not supported, the agent worker only runs unprivileged on Linux
+func StartWorker(name string, argv []string, context confinement.Context) (*Process, error) {
+	return nil, fmt.Errorf("privilege broker is not supported on this platform")
+}
+
+// Pid returns the process id of the worker
+func (p *Process) Pid() int { return 0 }
+
+// StartTime returns the time the broker started the worker
+func (p *Process) StartTime() time.Time { return time.Time{} }
+
+// Kill kills the worker and its process group
+func (p *Process) Kill() error { return nil }
+
+// Wait blocks until the worker exits
+func (p *Process) Wait() error { return nil }
@@ -0,0 +1,41 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+
+package privilege
+
+import (
+	"testing"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequiresRoot(t *testing.T) {
+	config := appconfig.DefaultConfig()
+	config.Privilege.PluginPrivileges = map[string]string{
+		appconfig.PluginNameAwsRunShellScript: appconfig.PrivilegeUser,
+		appconfig.PluginNamePort:              appconfig.PrivilegeRoot,
+	}
+	getAppConfig = func(bool) (appconfig.SsmagentConfig, error) { return config, nil }
+	defer func() { getAppConfig = appconfig.Config }()
+
+	assert.False(t, RequiresRoot(appconfig.PluginNameAwsRunShellScript))
+	assert.True(t, RequiresRoot(appconfig.PluginNamePort))
+	assert.True(t, RequiresRoot(appconfig.PluginNameAwsConfigurePackage))
+	assert.False(t, RequiresRoot(appconfig.PluginNameRefreshAssociation))
+	assert.True(t, RequiresRoot("aws:unknownPlugin"))
+
+	assert.False(t, DocumentRequiresRoot([]string{appconfig.PluginNameAwsRunShellScript, appconfig.PluginNameRefreshAssociation}))
+	assert.True(t, DocumentRequiresRoot([]string{appconfig.PluginNameAwsRunShellScript, appconfig.PluginNameDomainJoin}))
+}
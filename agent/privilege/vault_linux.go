@@ -0,0 +1,166 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// +build linux
+
+package privilege
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/managedInstances/registration"
+	"github.com/aws/amazon-ssm-agent/agent/managedInstances/rolecreds"
+	"github.com/aws/amazon-ssm-agent/agent/managedInstances/vault/fsvault"
+	"github.com/aws/amazon-ssm-agent/agent/statecrypto"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+)
+
+// redactedPrivateKey replaces the private key in the registration info sent to the agent worker,
+// it names no key storage so the worker cannot sign with it
+const redactedPrivateKey = "privilege-broker:redacted"
+
+var (
+	vaultRetrieve = fsvault.Retrieve
+	vaultStore    = fsvault.Store
+	vaultRemove   = fsvault.Remove
+	vaultExists   = fsvault.IsManifestExists
+
+	// managedInstanceCredentials returns the credentials of the managed instance and when they expire.
+	// The broker signs the requests, rotates the key pair and registers the instance again for the agent worker.
+	managedInstanceCredentials = func() (credentials.Value, time.Time, error) {
+		creds := rolecreds.ManagedInstanceCredentialsInstance()
+		value, err := creds.Get()
+		if err != nil {
+			return value, time.Time{}, err
+		}
+		expiration, err := creds.ExpiresAt()
+		return value, expiration, err
+	}
+)
+
+// workerReadableKeys are the vault entries the agent worker reads, workerWritableKeys the ones it writes.
+// The registration info is read without its private key, the fingerprint is only used by the broker.
+var (
+	workerReadableKeys = map[string]bool{registration.RegVaultKey: true, statecrypto.VaultKey: true}
+	workerWritableKeys = map[string]bool{statecrypto.VaultKey: true}
+)
+
+func init() {
+	if IsUnprivileged() {
+		fsvault.SetRemote(brokerVault{})
+		rolecreds.SetRemoteProvider(&brokerCredentialsProvider{})
+	}
+}
+
+// vaultRetrieve returns a vault entry the agent worker reads
+func (b *Broker) vaultRetrieve(key string) response {
+	if !workerReadableKeys[key] {
+		b.log.Warnf("rejected privilege broker request to read vault entry %v", key)
+		return response{Error: fmt.Sprintf("vault entry %v is not readable through the privilege broker", key)}
+	}
+	data, err := vaultRetrieve(key)
+	if err == nil && key == registration.RegVaultKey {
+		data, err = redactPrivateKey(data)
+	}
+	if err != nil {
+		return response{Error: err.Error()}
+	}
+	return response{Data: data}
+}
+
+// vaultStore writes a vault entry of the agent worker
+func (b *Broker) vaultStore(key string, data []byte) response {
+	if !workerWritableKeys[key] {
+		b.log.Warnf("rejected privilege broker request to write vault entry %v", key)
+		return response{Error: fmt.Sprintf("vault entry %v is not writable through the privilege broker", key)}
+	}
+	if err := vaultStore(key, data); err != nil {
+		return response{Error: err.Error()}
+	}
+	return response{}
+}
+
+// vaultRemove removes a vault entry of the agent worker
+func (b *Broker) vaultRemove(key string) response {
+	if !workerWritableKeys[key] {
+		b.log.Warnf("rejected privilege broker request to remove vault entry %v", key)
+		return response{Error: fmt.Sprintf("vault entry %v is not writable through the privilege broker", key)}
+	}
+	if err := vaultRemove(key); err != nil {
+		return response{Error: err.Error()}
+	}
+	return response{}
+}
+
+// credentials returns the managed instance credentials, the private key of the instance never leaves the broker
+func (b *Broker) credentials() response {
+	value, expiration, err := managedInstanceCredentials()
+	if err != nil {
+		return response{Error: err.Error()}
+	}
+	return response{Credentials: &value, Expiration: expiration}
+}
+
+// redactPrivateKey replaces the private key of the registration info
+func redactPrivateKey(data []byte) ([]byte, error) {
+	var info map[string]interface{}
+	if err := json.Unmarshal(data, &info); err != nil {
+		return nil, fmt.Errorf("failed to read the registration info: %v", err)
+	}
+	if privateKey, ok := info["privateKey"].(string); ok && privateKey != "" {
+		info["privateKey"] = redactedPrivateKey
+	}
+	return json.Marshal(info)
+}
+
+// brokerVault is the vault of the unprivileged agent worker, which cannot read the vault folder
+type brokerVault struct{}
+
+func (brokerVault) Retrieve(key string) ([]byte, error) {
+	resp, err := call(request{Operation: operationVaultRetrieve, Key: key})
+	return resp.Data, err
+}
+
+func (brokerVault) Store(key string, data []byte) error {
+	_, err := call(request{Operation: operationVaultStore, Key: key, Data: data})
+	return err
+}
+
+func (brokerVault) Remove(key string) error {
+	_, err := call(request{Operation: operationVaultRemove, Key: key})
+	return err
+}
+
+func (brokerVault) IsManifestExists() bool {
+	resp, err := call(request{Operation: operationVaultExists})
+	return err == nil && resp.Exists
+}
+
+// brokerCredentialsProvider gets the managed instance credentials of the unprivileged agent worker from the broker
+type brokerCredentialsProvider struct {
+	credentials.Expiry
+}
+
+func (p *brokerCredentialsProvider) Retrieve() (credentials.Value, error) {
+	resp, err := call(request{Operation: operationCredentials})
+	if err == nil && resp.Credentials == nil {
+		err = fmt.Errorf("privilege broker returned no credentials")
+	}
+	if err != nil {
+		return credentials.Value{ProviderName: rolecreds.ProviderName}, err
+	}
+	p.SetExpiration(resp.Expiration, 0)
+	return *resp.Credentials, nil
+}
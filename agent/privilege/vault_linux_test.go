@@ -0,0 +1,144 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// +build linux
+
+package privilege
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/managedInstances/registration"
+	"github.com/aws/amazon-ssm-agent/agent/managedInstances/vault/fsvault"
+	"github.com/aws/amazon-ssm-agent/agent/statecrypto"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/stretchr/testify/assert"
+)
+
+// stubVault replaces the vault of the broker with entries, it is restored on cleanup
+func (suite *BrokerTestSuite) stubVault(entries map[string][]byte) {
+	vaultRetrieve = func(key string) ([]byte, error) {
+		if data, ok := entries[key]; ok {
+			return data, nil
+		}
+		return nil, fmt.Errorf("%s does not exist.", key)
+	}
+	vaultStore = func(key string, data []byte) error {
+		entries[key] = data
+		return nil
+	}
+	vaultRemove = func(key string) error {
+		delete(entries, key)
+		return nil
+	}
+	vaultExists = func() bool { return len(entries) > 0 }
+	suite.T().Cleanup(func() {
+		vaultRetrieve = fsvault.Retrieve
+		vaultStore = fsvault.Store
+		vaultRemove = fsvault.Remove
+		vaultExists = fsvault.IsManifestExists
+	})
+}
+
+func (suite *BrokerTestSuite) TestVaultKeepsThePrivateKey() {
+	registrationInfo := `{"instanceID":"mi-1234567890","region":"us-east-1","privateKey":"secret","privateKeyType":"Rsa"}`
+	entries := map[string][]byte{
+		registration.RegVaultKey: []byte(registrationInfo),
+		"InstanceFingerprint":    []byte(`{"fingerprint":"abc"}`),
+	}
+	suite.stubVault(entries)
+	vault := brokerVault{}
+
+	assert.True(suite.T(), vault.IsManifestExists())
+	data, err := vault.Retrieve(registration.RegVaultKey)
+	suite.Require().NoError(err)
+	var info map[string]string
+	suite.Require().NoError(json.Unmarshal(data, &info))
+	assert.Equal(suite.T(), "mi-1234567890", info["instanceID"])
+	assert.Equal(suite.T(), redactedPrivateKey, info["privateKey"])
+
+	// the worker cannot read the fingerprint nor replace the registration
+	_, err = vault.Retrieve("InstanceFingerprint")
+	assert.Error(suite.T(), err)
+	assert.Error(suite.T(), vault.Store(registration.RegVaultKey, []byte(`{"privateKey":"planted"}`)))
+	assert.Equal(suite.T(), registrationInfo, string(entries[registration.RegVaultKey]))
+
+	assert.NoError(suite.T(), vault.Store(statecrypto.VaultKey, []byte("key")))
+	data, err = vault.Retrieve(statecrypto.VaultKey)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "key", string(data))
+	assert.NoError(suite.T(), vault.Remove(statecrypto.VaultKey))
+	assert.NotContains(suite.T(), entries, statecrypto.VaultKey)
+}
+
+func (suite *BrokerTestSuite) TestCredentials() {
+	expiration := time.Now().Add(time.Hour).UTC()
+	originalCredentials := managedInstanceCredentials
+	defer func() { managedInstanceCredentials = originalCredentials }()
+	managedInstanceCredentials = func() (credentials.Value, time.Time, error) {
+		return credentials.Value{AccessKeyID: "AKID", SecretAccessKey: "secret", SessionToken: "token"}, expiration, nil
+	}
+
+	provider := &brokerCredentialsProvider{}
+	value, err := provider.Retrieve()
+	suite.Require().NoError(err)
+	assert.Equal(suite.T(), "AKID", value.AccessKeyID)
+	assert.False(suite.T(), provider.IsExpired())
+	assert.True(suite.T(), expiration.Equal(provider.ExpiresAt()))
+
+	managedInstanceCredentials = func() (credentials.Value, time.Time, error) {
+		return credentials.Value{}, time.Time{}, fmt.Errorf("instance is not registered")
+	}
+	_, err = provider.Retrieve()
+	assert.Error(suite.T(), err)
+}
+
+func TestGiveDirectoryContentKeepsRootOwnedPaths(t *testing.T) {
+	if os.Getuid() != 0 {
+		t.Skip("changing the owner of files needs root")
+	}
+	dir := t.TempDir()
+	vaultDir := filepath.Join(dir, "Vault")
+	instanceDir := filepath.Join(dir, "i-1234567890")
+	for _, path := range []string{filepath.Join(vaultDir, "Store"), filepath.Join(instanceDir, "document")} {
+		assert.NoError(t, os.MkdirAll(path, 0700))
+	}
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(vaultDir, "Store", "RegistrationKey"), nil, 0600))
+	assert.NoError(t, os.Lchown(filepath.Join(vaultDir, "Store", "RegistrationKey"), 1001, 1001))
+	// a link in the data store must not hand its target to the unprivileged user
+	outside := filepath.Join(t.TempDir(), "outside")
+	assert.NoError(t, ioutil.WriteFile(outside, nil, 0600))
+	assert.NoError(t, os.Symlink(outside, filepath.Join(instanceDir, "link")))
+
+	assert.NoError(t, giveDirectoryContent(dir, []string{vaultDir + "/"}, unprivilegedUser{uid: 1001, gid: 1001}))
+
+	owner := func(path string) uint32 {
+		info, err := os.Lstat(path)
+		assert.NoError(t, err)
+		return info.Sys().(*syscall.Stat_t).Uid
+	}
+	assert.Equal(t, uint32(0), owner(dir))
+	assert.Equal(t, uint32(0), owner(vaultDir))
+	assert.Equal(t, uint32(0), owner(filepath.Join(vaultDir, "Store", "RegistrationKey")))
+	assert.Equal(t, uint32(1001), owner(instanceDir))
+	assert.Equal(t, uint32(1001), owner(filepath.Join(instanceDir, "document")))
+	assert.Equal(t, uint32(1001), owner(filepath.Join(instanceDir, "link")))
+	assert.Equal(t, uint32(0), owner(outside))
+}
@@ -0,0 +1,497 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package proxyconfig
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/log/ssmlog"
+)
+
+// The agent does not embed a JavaScript engine, so PAC files are evaluated by a small interpreter
+// that supports the subset used by most corporate PAC files: FindProxyForURL made of if/else
+// statements and returns, the operators ! && || == != and the functions listed in pacFunctions.
+
+const (
+	// pacRefreshInterval is how long a downloaded or parsed PAC file is used before it is loaded again
+	pacRefreshInterval = 30 * time.Minute
+
+	pacDownloadTimeout = 30 * time.Second
+)
+
+var lookupIP = net.LookupIP
+
+var pacCache struct {
+	sync.Mutex
+	location string
+	script   *pacScript
+	err      error
+	loadTime time.Time
+}
+
+// pacEnv holds the arguments FindProxyForURL is called with
+type pacEnv struct {
+	url  string
+	host string
+}
+
+type pacExpr func(env pacEnv) interface{}
+
+// pacStmt returns the result of the first return statement executed, if any
+type pacStmt func(env pacEnv) (result string, returned bool)
+
+// pacScript is a parsed FindProxyForURL function
+type pacScript struct {
+	body pacStmt
+}
+
+// pacFunctions are the PAC helper functions understood by the interpreter
+var pacFunctions = map[string]struct {
+	argCount int
+	call     func(args []string) interface{}
+}{
+	"isPlainHostName": {1, func(args []string) interface{} {
+		return !strings.Contains(args[0], ".")
+	}},
+	"dnsDomainIs": {2, func(args []string) interface{} {
+		return strings.HasSuffix(strings.ToLower(args[0]), strings.ToLower(args[1]))
+	}},
+	"localHostOrDomainIs": {2, func(args []string) interface{} {
+		host, hostDomain := strings.ToLower(args[0]), strings.ToLower(args[1])
+		return host == hostDomain || (!strings.Contains(host, ".") && strings.HasPrefix(hostDomain, host+"."))
+	}},
+	"shExpMatch": {2, func(args []string) interface{} {
+		return shExpMatch(args[0], args[1])
+	}},
+	"isResolvable": {1, func(args []string) interface{} {
+		return dnsResolve(args[0]) != ""
+	}},
+	"dnsResolve": {1, func(args []string) interface{} {
+		return dnsResolve(args[0])
+	}},
+	"isInNet": {3, func(args []string) interface{} {
+		ip := net.ParseIP(dnsResolve(args[0]))
+		pattern, mask := net.ParseIP(args[1]).To4(), net.ParseIP(args[2]).To4()
+		if ip == nil || pattern == nil || mask == nil {
+			return false
+		}
+		return ip.Mask(net.IPMask(mask)).Equal(pattern.Mask(net.IPMask(mask)))
+	}},
+}
+
+// loadPacScript returns the parsed PAC file at location, which is either a local path or an http(s) or file url.
+// Scripts and load failures are cached for pacRefreshInterval so requests are not slowed down by a broken PAC file.
+func loadPacScript(location string) (*pacScript, error) {
+	pacCache.Lock()
+	defer pacCache.Unlock()
+
+	if pacCache.location == location && time.Since(pacCache.loadTime) < pacRefreshInterval {
+		return pacCache.script, pacCache.err
+	}
+
+	pacCache.location = location
+	pacCache.loadTime = time.Now()
+	pacCache.script = nil
+	content, err := readPacFile(location)
+	if err == nil {
+		pacCache.script, err = parsePacScript(string(content))
+	}
+	pacCache.err = err
+	if err != nil {
+		ssmlog.SSMLogger(true).Warnf("Failed to load PAC file %v, falling back to environment proxy settings: %v", location, err)
+	}
+	return pacCache.script, pacCache.err
+}
+
+// readPacFile reads the PAC file from disk or downloads it without going through a proxy
+func readPacFile(location string) ([]byte, error) {
+	if strings.HasPrefix(location, "http://") || strings.HasPrefix(location, "https://") {
+		client := &http.Client{
+			Transport: &http.Transport{Proxy: nil},
+			Timeout:   pacDownloadTimeout,
+		}
+		resp, err := client.Get(location)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("unexpected status %v", resp.Status)
+		}
+		return ioutil.ReadAll(resp.Body)
+	}
+	return ioutil.ReadFile(strings.TrimPrefix(location, "file://"))
+}
+
+// findProxyForURL evaluates the script for a url and returns the PAC result string, for example "PROXY host:3128; DIRECT"
+func (s *pacScript) findProxyForURL(u *url.URL) string {
+	result, _ := s.body(pacEnv{url: u.String(), host: u.Hostname()})
+	return result
+}
+
+// parsePacResult returns the first usable proxy of a PAC result. DIRECT, an empty result
+// or a result with only unsupported proxy types means the request is sent without a proxy.
+func parsePacResult(result string) (*url.URL, error) {
+	for _, entry := range strings.Split(result, ";") {
+		fields := strings.Fields(entry)
+		if len(fields) == 0 {
+			continue
+		}
+		switch strings.ToUpper(fields[0]) {
+		case "DIRECT":
+			return nil, nil
+		case "PROXY", "HTTP":
+			if len(fields) > 1 {
+				return url.Parse("http://" + fields[1])
+			}
+		case "HTTPS":
+			if len(fields) > 1 {
+				return url.Parse("https://" + fields[1])
+			}
+		}
+	}
+	return nil, nil
+}
+
+func dnsResolve(host string) string {
+	if ip := net.ParseIP(host); ip != nil {
+		return ip.String()
+	}
+	ips, err := lookupIP(host)
+	if err != nil {
+		return ""
+	}
+	for _, ip := range ips {
+		if ip.To4() != nil {
+			return ip.String()
+		}
+	}
+	return ""
+}
+
+// shExpMatch matches a string against a shell expression where * matches any sequence and ? any single character
+func shExpMatch(str, pattern string) bool {
+	expr := regexp.QuoteMeta(pattern)
+	expr = strings.Replace(expr, `\*`, ".*", -1)
+	expr = strings.Replace(expr, `\?`, ".", -1)
+	matched, _ := regexp.MatchString("^"+expr+"$", str)
+	return matched
+}
+
+// parsePacScript parses the FindProxyForURL function of a PAC file
+func parsePacScript(content string) (*pacScript, error) {
+	tokens, err := tokenizePac(content)
+	if err != nil {
+		return nil, err
+	}
+	p := &pacParser{tokens: tokens}
+
+	for p.peek() != "" && p.peek() != "function" {
+		p.next()
+	}
+	if err = p.expect("function", "FindProxyForURL", "("); err != nil {
+		return nil, err
+	}
+	urlParam, hostParam := p.next(), ""
+	if err = p.expect(","); err != nil {
+		return nil, err
+	}
+	hostParam = p.next()
+	if err = p.expect(")"); err != nil {
+		return nil, err
+	}
+	p.params = map[string]func(env pacEnv) string{
+		urlParam:  func(env pacEnv) string { return env.url },
+		hostParam: func(env pacEnv) string { return env.host },
+	}
+
+	body, err := p.parseBlock()
+	if err != nil {
+		return nil, err
+	}
+	return &pacScript{body: body}, nil
+}
+
+var pacTokenPattern = regexp.MustCompile(`^(\s+|//[^\n]*|/\*(?s:.*?)\*/|"[^"]*"|'[^']*'|[A-Za-z_$][A-Za-z0-9_$.]*|===|!==|==|!=|&&|\|\||[(){};,!])`)
+
+// tokenizePac splits a PAC file into tokens, dropping whitespace and comments
+func tokenizePac(content string) ([]string, error) {
+	var tokens []string
+	for len(content) > 0 {
+		token := pacTokenPattern.FindString(content)
+		if token == "" {
+			return nil, fmt.Errorf("unsupported PAC syntax near %q", firstLine(content))
+		}
+		content = content[len(token):]
+		if trimmed := strings.TrimSpace(token); trimmed != "" && !strings.HasPrefix(trimmed, "//") && !strings.HasPrefix(trimmed, "/*") {
+			tokens = append(tokens, trimmed)
+		}
+	}
+	return tokens, nil
+}
+
+func firstLine(content string) string {
+	if i := strings.IndexByte(content, '\n'); i >= 0 {
+		return content[:i]
+	}
+	return content
+}
+
+type pacParser struct {
+	tokens []string
+	pos    int
+	params map[string]func(env pacEnv) string
+}
+
+func (p *pacParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+func (p *pacParser) next() string {
+	token := p.peek()
+	p.pos++
+	return token
+}
+
+func (p *pacParser) expect(tokens ...string) error {
+	for _, token := range tokens {
+		if next := p.next(); next != token {
+			return fmt.Errorf("expected %q in PAC file but found %q", token, next)
+		}
+	}
+	return nil
+}
+
+// parseBlock parses statements between braces
+func (p *pacParser) parseBlock() (pacStmt, error) {
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+	var stmts []pacStmt
+	for p.peek() != "}" {
+		if p.peek() == "" {
+			return nil, fmt.Errorf("unexpected end of PAC file")
+		}
+		stmt, err := p.parseStatement()
+		if err != nil {
+			return nil, err
+		}
+		stmts = append(stmts, stmt)
+	}
+	p.next()
+	return func(env pacEnv) (string, bool) {
+		for _, stmt := range stmts {
+			if result, returned := stmt(env); returned {
+				return result, true
+			}
+		}
+		return "", false
+	}, nil
+}
+
+// parseStatement parses a block, an if statement or a return statement
+func (p *pacParser) parseStatement() (pacStmt, error) {
+	switch p.peek() {
+	case "{":
+		return p.parseBlock()
+	case ";":
+		p.next()
+		return func(env pacEnv) (string, bool) { return "", false }, nil
+	case "return":
+		p.next()
+		value, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() == ";" {
+			p.next()
+		}
+		return func(env pacEnv) (string, bool) { return toPacString(value(env)), true }, nil
+	case "if":
+		p.next()
+		if err := p.expect("("); err != nil {
+			return nil, err
+		}
+		condition, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		if err = p.expect(")"); err != nil {
+			return nil, err
+		}
+		then, err := p.parseStatement()
+		if err != nil {
+			return nil, err
+		}
+		otherwise := func(env pacEnv) (string, bool) { return "", false }
+		if p.peek() == "else" {
+			p.next()
+			if otherwise, err = p.parseStatement(); err != nil {
+				return nil, err
+			}
+		}
+		return func(env pacEnv) (string, bool) {
+			if toPacBool(condition(env)) {
+				return then(env)
+			}
+			return otherwise(env)
+		}, nil
+	default:
+		return nil, fmt.Errorf("unsupported PAC statement %q", p.peek())
+	}
+}
+
+// parseExpr parses an expression using the precedence || then && then equality then unary
+func (p *pacParser) parseExpr() (pacExpr, error) {
+	left, err := p.parseAnd()
+	for err == nil && p.peek() == "||" {
+		p.next()
+		var right pacExpr
+		if right, err = p.parseAnd(); err == nil {
+			l, r := left, right
+			left = func(env pacEnv) interface{} { return toPacBool(l(env)) || toPacBool(r(env)) }
+		}
+	}
+	return left, err
+}
+
+func (p *pacParser) parseAnd() (pacExpr, error) {
+	left, err := p.parseEquality()
+	for err == nil && p.peek() == "&&" {
+		p.next()
+		var right pacExpr
+		if right, err = p.parseEquality(); err == nil {
+			l, r := left, right
+			left = func(env pacEnv) interface{} { return toPacBool(l(env)) && toPacBool(r(env)) }
+		}
+	}
+	return left, err
+}
+
+func (p *pacParser) parseEquality() (pacExpr, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	switch op := p.peek(); op {
+	case "==", "===", "!=", "!==":
+		p.next()
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		equal := op == "==" || op == "==="
+		return func(env pacEnv) interface{} { return (left(env) == right(env)) == equal }, nil
+	}
+	return left, nil
+}
+
+func (p *pacParser) parseUnary() (pacExpr, error) {
+	if p.peek() == "!" {
+		p.next()
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return func(env pacEnv) interface{} { return !toPacBool(operand(env)) }, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *pacParser) parsePrimary() (pacExpr, error) {
+	token := p.next()
+	switch {
+	case token == "(":
+		expr, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		return expr, p.expect(")")
+	case token == "true" || token == "false":
+		value := token == "true"
+		return func(env pacEnv) interface{} { return value }, nil
+	case strings.HasPrefix(token, `"`) || strings.HasPrefix(token, "'"):
+		value := token[1 : len(token)-1]
+		return func(env pacEnv) interface{} { return value }, nil
+	}
+
+	if param, ok := p.params[token]; ok {
+		return func(env pacEnv) interface{} { return param(env) }, nil
+	}
+	function, ok := pacFunctions[token]
+	if !ok {
+		return nil, fmt.Errorf("unsupported PAC expression %q", token)
+	}
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	var args []pacExpr
+	for p.peek() != ")" {
+		if len(args) > 0 {
+			if err := p.expect(","); err != nil {
+				return nil, err
+			}
+		}
+		arg, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+	}
+	p.next()
+	if len(args) != function.argCount {
+		return nil, fmt.Errorf("PAC function %v expects %v arguments but got %v", token, function.argCount, len(args))
+	}
+	return func(env pacEnv) interface{} {
+		values := make([]string, len(args))
+		for i, arg := range args {
+			values[i] = toPacString(arg(env))
+		}
+		return function.call(values)
+	}, nil
+}
+
+func toPacBool(value interface{}) bool {
+	switch v := value.(type) {
+	case bool:
+		return v
+	case string:
+		return v != ""
+	}
+	return false
+}
+
+func toPacString(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case bool:
+		if v {
+			return "true"
+		}
+		return "false"
+	}
+	return ""
+}
@@ -0,0 +1,111 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package proxyconfig
+
+import (
+	"net"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testPacFile = `
+// corporate proxy selection
+function FindProxyForURL(url, host) {
+	/* internal hosts are reached directly */
+	if (isPlainHostName(host) || dnsDomainIs(host, ".corp.example.com"))
+		return "DIRECT";
+
+	if (shExpMatch(host, "*.s3.*amazonaws.com") || host == 's3.amazonaws.com') {
+		return "PROXY s3proxy.example.com:3128; DIRECT";
+	} else if (isInNet(host, "10.0.0.0", "255.0.0.0")) {
+		return "DIRECT";
+	}
+
+	if (!shExpMatch(url, "https:*"))
+		return "SOCKS socks.example.com:1080";
+	return "HTTPS proxy.example.com:8443; PROXY fallback.example.com:8080";
+}
+`
+
+func TestPacScript(t *testing.T) {
+	lookupIP = func(host string) ([]net.IP, error) {
+		return []net.IP{net.ParseIP("10.1.2.3")}, nil
+	}
+	defer func() { lookupIP = net.LookupIP }()
+
+	script, err := parsePacScript(testPacFile)
+	assert.NoError(t, err)
+
+	tests := []struct {
+		url    string
+		result string
+	}{
+		{"https://intranet/", "DIRECT"},
+		{"https://git.corp.example.com/", "DIRECT"},
+		{"https://bucket.s3.us-east-1.amazonaws.com/key", "PROXY s3proxy.example.com:3128; DIRECT"},
+		{"https://s3.amazonaws.com/bucket", "PROXY s3proxy.example.com:3128; DIRECT"},
+		{"https://10.20.30.40/", "DIRECT"},
+		{"https://ssm.us-east-1.amazonaws.com/", "DIRECT"},
+	}
+	for _, test := range tests {
+		u, _ := url.Parse(test.url)
+		assert.Equal(t, test.result, script.findProxyForURL(u), test.url)
+	}
+
+	lookupIP = func(host string) ([]net.IP, error) {
+		return []net.IP{net.ParseIP("52.1.2.3")}, nil
+	}
+	u, _ := url.Parse("https://ssm.us-east-1.amazonaws.com/")
+	assert.Equal(t, "HTTPS proxy.example.com:8443; PROXY fallback.example.com:8080", script.findProxyForURL(u))
+	u, _ = url.Parse("http://ssm.us-east-1.amazonaws.com/")
+	assert.Equal(t, "SOCKS socks.example.com:1080", script.findProxyForURL(u))
+}
+
+func TestParsePacScriptUnsupported(t *testing.T) {
+	for _, content := range []string{
+		`function FindProxyForURL(url, host) { var proxy = "DIRECT"; return proxy; }`,
+		`function FindProxyForURL(url, host) { if (weekdayRange("MON", "FRI")) return "DIRECT"; }`,
+		`function FindProxyForURL(url, host) { return dnsDomainIs(host); }`,
+		`function FindProxyForURL(url, host) { return "DIRECT";`,
+		`function FindProxy(url, host) { return "DIRECT"; }`,
+	} {
+		_, err := parsePacScript(content)
+		assert.Error(t, err, content)
+	}
+}
+
+func TestParsePacResult(t *testing.T) {
+	tests := []struct {
+		result string
+		proxy  string
+	}{
+		{"", ""},
+		{"DIRECT", ""},
+		{"PROXY proxy:3128; DIRECT", "http://proxy:3128"},
+		{"HTTPS proxy:8443", "https://proxy:8443"},
+		{"SOCKS socks:1080; PROXY proxy:3128", "http://proxy:3128"},
+		{"SOCKS socks:1080", ""},
+	}
+	for _, test := range tests {
+		proxy, err := parsePacResult(test.result)
+		assert.NoError(t, err)
+		if test.proxy == "" {
+			assert.Nil(t, proxy, test.result)
+		} else {
+			assert.Equal(t, test.proxy, proxy.String())
+		}
+	}
+}
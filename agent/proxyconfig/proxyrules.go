@@ -0,0 +1,146 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package proxyconfig
+
+import (
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/network"
+)
+
+// Service names used to select per-service proxy rules in appconfig
+const (
+	ServiceSsm         = "ssm"
+	ServiceEc2Messages = "ec2messages"
+	ServiceSsmMessages = "ssmmessages"
+	ServiceS3          = "s3"
+)
+
+var getAppConfig = appconfig.Config
+
+var proxyFromEnvironment = http.ProxyFromEnvironment
+
+// ProxyFunc returns the function used by http transports and websocket dialers to choose
+// the proxy for requests sent to the given service.
+// A proxy rule configured for the service takes precedence over the PAC file,
+// which takes precedence over the http_proxy, https_proxy and no_proxy environment variables.
+func ProxyFunc(service string) func(*http.Request) (*url.URL, error) {
+	return func(req *http.Request) (*url.URL, error) {
+		return proxyForRequest(service, req)
+	}
+}
+
+// NewTransport returns an http transport with the same defaults as http.DefaultTransport
+// that selects its proxy with the rules of the given service, uses the agent tls config,
+// and connects from the configured source address.
+func NewTransport(service string) *http.Transport {
+	return &http.Transport{
+		Proxy: ProxyFunc(service),
+		DialContext: network.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}.DialContext,
+		MaxIdleConns:          100,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+		TLSClientConfig:       network.GetDefaultTLSConfig(),
+	}
+}
+
+// proxyForRequest returns the proxy for the request, or nil if the request should be sent directly
+func proxyForRequest(service string, req *http.Request) (*url.URL, error) {
+	config, err := getAppConfig(false)
+	if err != nil {
+		return proxyFromEnvironment(req)
+	}
+
+	if rule, ok := config.Proxy.Services[service]; ok {
+		if matchesNoProxy(req.URL, rule.NoProxy) {
+			return nil, nil
+		}
+		switch rule.Proxy {
+		case "":
+			// only a bypass list is configured, the proxy is still chosen by PAC or environment
+		case appconfig.ProxyDirect:
+			return nil, nil
+		default:
+			return parseProxy(rule.Proxy)
+		}
+	}
+
+	if config.Proxy.PacFile != "" {
+		if script, err := loadPacScript(config.Proxy.PacFile); err == nil {
+			return parsePacResult(script.findProxyForURL(req.URL))
+		}
+	}
+
+	return proxyFromEnvironment(req)
+}
+
+// matchesNoProxy returns true if the url host matches one of the bypass entries.
+// Entries are either "*", an IP network in CIDR notation, a host with an optional port,
+// or a domain that also matches its subdomains.
+func matchesNoProxy(u *url.URL, noProxy []string) bool {
+	host := strings.ToLower(u.Hostname())
+	port := u.Port()
+	if port == "" {
+		port = defaultPort(u.Scheme)
+	}
+	ip := net.ParseIP(host)
+
+	for _, entry := range noProxy {
+		if entry == "*" {
+			return true
+		}
+		if _, network, err := net.ParseCIDR(entry); err == nil {
+			if ip != nil && network.Contains(ip) {
+				return true
+			}
+			continue
+		}
+		if entryHost, entryPort, err := net.SplitHostPort(entry); err == nil {
+			if entryPort != port {
+				continue
+			}
+			entry = entryHost
+		}
+		if host == entry || strings.HasSuffix(host, "."+strings.TrimPrefix(entry, ".")) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseProxy parses a proxy address, defaulting to http when the scheme is missing
+func parseProxy(proxy string) (*url.URL, error) {
+	if !strings.Contains(proxy, "://") {
+		proxy = "http://" + proxy
+	}
+	return url.Parse(proxy)
+}
+
+func defaultPort(scheme string) string {
+	switch scheme {
+	case "https", "wss":
+		return "443"
+	default:
+		return "80"
+	}
+}
@@ -0,0 +1,92 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package proxyconfig
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/stretchr/testify/assert"
+)
+
+func setupProxyConfig(t *testing.T, proxy appconfig.ProxyCfg) func() {
+	getAppConfig = func(bool) (appconfig.SsmagentConfig, error) {
+		config := appconfig.DefaultConfig()
+		config.Proxy = proxy
+		return config, nil
+	}
+	proxyFromEnvironment = func(req *http.Request) (*url.URL, error) {
+		return url.Parse("http://envproxy:3128")
+	}
+	return func() {
+		getAppConfig = appconfig.Config
+		proxyFromEnvironment = http.ProxyFromEnvironment
+	}
+}
+
+func proxyFor(t *testing.T, service string, requestURL string) string {
+	req, err := http.NewRequest("GET", requestURL, nil)
+	assert.NoError(t, err)
+	proxy, err := ProxyFunc(service)(req)
+	assert.NoError(t, err)
+	if proxy == nil {
+		return ""
+	}
+	return proxy.String()
+}
+
+func TestProxyFuncServiceRules(t *testing.T) {
+	defer setupProxyConfig(t, appconfig.ProxyCfg{
+		Services: map[string]appconfig.ProxyRuleCfg{
+			ServiceS3:          {Proxy: "s3proxy:3128", NoProxy: []string{".internal.example.com"}},
+			ServiceSsmMessages: {Proxy: appconfig.ProxyDirect},
+			ServiceEc2Messages: {NoProxy: []string{"10.0.0.0/8", "ec2messages.example.com:8443"}},
+		},
+	})()
+
+	assert.Equal(t, "http://s3proxy:3128", proxyFor(t, ServiceS3, "https://bucket.s3.amazonaws.com/key"))
+	assert.Equal(t, "", proxyFor(t, ServiceS3, "https://s3.internal.example.com/key"))
+	assert.Equal(t, "", proxyFor(t, ServiceSsmMessages, "https://ssmmessages.us-east-1.amazonaws.com/"))
+	assert.Equal(t, "", proxyFor(t, ServiceEc2Messages, "https://10.1.2.3/"))
+	assert.Equal(t, "", proxyFor(t, ServiceEc2Messages, "https://ec2messages.example.com:8443/"))
+	assert.Equal(t, "http://envproxy:3128", proxyFor(t, ServiceEc2Messages, "https://ec2messages.example.com/"))
+	assert.Equal(t, "http://envproxy:3128", proxyFor(t, ServiceSsm, "https://ssm.us-east-1.amazonaws.com/"))
+}
+
+func TestProxyFuncPacFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "proxyconfig")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	pacFile := filepath.Join(dir, "proxy.pac")
+	ioutil.WriteFile(pacFile, []byte(`function FindProxyForURL(url, host) {
+		if (dnsDomainIs(host, "ssmmessages.us-east-1.amazonaws.com")) return "PROXY mgsproxy:8080";
+		return "DIRECT";
+	}`), 0600)
+
+	defer setupProxyConfig(t, appconfig.ProxyCfg{
+		PacFile: pacFile,
+		Services: map[string]appconfig.ProxyRuleCfg{
+			ServiceS3: {Proxy: "s3proxy:3128"},
+		},
+	})()
+
+	assert.Equal(t, "http://mgsproxy:8080", proxyFor(t, ServiceSsmMessages, "https://ssmmessages.us-east-1.amazonaws.com/"))
+	assert.Equal(t, "", proxyFor(t, ServiceSsm, "https://ssm.us-east-1.amazonaws.com/"))
+	assert.Equal(t, "http://s3proxy:3128", proxyFor(t, ServiceS3, "https://bucket.s3.amazonaws.com/key"))
+}
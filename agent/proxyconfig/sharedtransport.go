@@ -0,0 +1,62 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package proxyconfig
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"sync"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+)
+
+// serviceContextKey is the context key of the service a request of the shared transport is sent to
+type serviceContextKey struct{}
+
+var (
+	sharedTransportOnce sync.Once
+	sharedTransport     *http.Transport
+)
+
+// SharedTransport returns the transport the AWS service clients of the process share, so their requests reuse the
+// same connections instead of each client making its own. The proxy of a request is selected with the rules of the
+// service set in its context by WithService.
+func SharedTransport() *http.Transport {
+	sharedTransportOnce.Do(func() {
+		config, _ := getAppConfig(false)
+		sharedTransport = newSharedTransport(config.Network)
+	})
+	return sharedTransport
+}
+
+// WithService returns a copy of ctx for the requests sent to the service through the shared transport
+func WithService(ctx context.Context, service string) context.Context {
+	return context.WithValue(ctx, serviceContextKey{}, service)
+}
+
+// newSharedTransport returns a transport with the connection pool settings of the network config
+func newSharedTransport(config appconfig.NetworkCfg) *http.Transport {
+	transport := NewTransport("")
+	transport.Proxy = proxyForService
+	transport.MaxIdleConnsPerHost = config.MaxIdleConnectionsPerHost
+	transport.ForceAttemptHTTP2 = !config.DisableHttp2
+	return transport
+}
+
+// proxyForService selects the proxy of the request with the rules of the service in its context
+func proxyForService(req *http.Request) (*url.URL, error) {
+	service, _ := req.Context().Value(serviceContextKey{}).(string)
+	return proxyForRequest(service, req)
+}
@@ -0,0 +1,59 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package proxyconfig
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewSharedTransport(t *testing.T) {
+	transport := newSharedTransport(appconfig.NetworkCfg{MaxIdleConnectionsPerHost: 4})
+	assert.Equal(t, 4, transport.MaxIdleConnsPerHost)
+	assert.True(t, transport.ForceAttemptHTTP2)
+
+	transport = newSharedTransport(appconfig.NetworkCfg{MaxIdleConnectionsPerHost: 4, DisableHttp2: true})
+	assert.False(t, transport.ForceAttemptHTTP2)
+}
+
+func TestSharedTransportSelectsProxyOfService(t *testing.T) {
+	defer setupProxyConfig(t, appconfig.ProxyCfg{
+		Services: map[string]appconfig.ProxyRuleCfg{
+			ServiceS3: {Proxy: "s3proxy:3128"},
+		},
+	})()
+	transport := newSharedTransport(appconfig.NetworkCfg{MaxIdleConnectionsPerHost: 4})
+
+	proxyOf := func(service string) string {
+		req, err := http.NewRequest("GET", "https://bucket.s3.amazonaws.com/key", nil)
+		assert.NoError(t, err)
+		if service != "" {
+			req = req.WithContext(WithService(context.Background(), service))
+		}
+		proxy, err := transport.Proxy(req)
+		assert.NoError(t, err)
+		if proxy == nil {
+			return ""
+		}
+		return proxy.String()
+	}
+
+	assert.Equal(t, "http://s3proxy:3128", proxyOf(ServiceS3))
+	assert.Equal(t, "http://envproxy:3128", proxyOf(ServiceSsm))
+	assert.Equal(t, "http://envproxy:3128", proxyOf(""))
+}
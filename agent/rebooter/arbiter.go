@@ -0,0 +1,165 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package rebooter provides utilities used to reboot a machine.
+package rebooter
+
+import (
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/fileutil"
+	"github.com/aws/amazon-ssm-agent/agent/jsonutil"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+)
+
+// rebootSearchHorizon bounds the search of a reboot time allowed by the maintenance and blackout windows,
+// the windows repeat every week
+const rebootSearchHorizon = 8 * 24 * time.Hour
+
+var (
+	// recordPath is the record of the requests the last reboot satisfied
+	recordPath = filepath.Join(appconfig.DefaultDataStorePath, "reboot", "rebootrecord.json")
+
+	timeNow       = time.Now
+	afterFunc     = time.AfterFunc
+	requestReboot = RequestPendingReboot
+
+	arbiterOnce     sync.Once
+	arbiterInstance *arbiter
+)
+
+// RebootRequest is the request of a document to reboot the machine
+type RebootRequest struct {
+	// Source identifies the requester, like the command or association that requests the reboot
+	Source      string    `json:"source"`
+	Reason      string    `json:"reason"`
+	RequestedAt time.Time `json:"requestedAt"`
+}
+
+// RebootRecord lists the requests a single reboot satisfied
+type RebootRecord struct {
+	RebootedAt time.Time       `json:"rebootedAt"`
+	Requests   []RebootRequest `json:"requests"`
+}
+
+// arbiter coalesces the reboot requests of concurrent documents into a single reboot allowed by the reboot windows
+type arbiter struct {
+	lock      sync.Mutex
+	config    appconfig.RebootCfg
+	requests  []RebootRequest
+	scheduled bool
+	rebooting bool
+}
+
+// RequestReboot registers the reboot request of a document, a source is only registered once. The first request
+// schedules the reboot, it satisfies every request registered until the machine reboots. RequestReboot returns
+// false if the source already requested the reboot.
+func RequestReboot(log log.T, request RebootRequest) bool {
+	arbiterOnce.Do(func() {
+		config, _ := appconfig.Config(false)
+		arbiterInstance = &arbiter{config: config.Reboot}
+	})
+	return arbiterInstance.request(log, request)
+}
+
+func (a *arbiter) request(log log.T, request RebootRequest) bool {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	for _, registered := range a.requests {
+		if registered.Source == request.Source {
+			log.Infof("reboot was already requested by %v", request.Source)
+			return false
+		}
+	}
+	if request.RequestedAt.IsZero() {
+		request.RequestedAt = timeNow()
+	}
+	a.requests = append(a.requests, request)
+	log.Infof("%v requested a reboot: %v", request.Source, request.Reason)
+
+	if a.rebooting {
+		// the machine reboots already, the reboot satisfies the request too
+		a.writeRecord(log)
+		return true
+	}
+	if !a.scheduled {
+		now := timeNow()
+		rebootTime := a.nextRebootTime(log, now.Add(time.Duration(a.config.CoalesceSeconds)*time.Second))
+		log.Infof("reboot is scheduled at %v", rebootTime.Format(time.RFC3339))
+		afterFunc(rebootTime.Sub(now), func() { a.reboot(log) })
+		a.scheduled = true
+	}
+	return true
+}
+
+// reboot records the requests the reboot satisfies and requests the reboot of the machine
+func (a *arbiter) reboot(log log.T) {
+	a.lock.Lock()
+	a.rebooting = true
+	a.writeRecord(log)
+	sources := make([]string, len(a.requests))
+	for i, request := range a.requests {
+		sources[i] = request.Source
+	}
+	a.lock.Unlock()
+
+	log.Infof("rebooting to satisfy the reboot requests of %v", strings.Join(sources, ", "))
+	requestReboot(log)
+}
+
+func (a *arbiter) writeRecord(log log.T) {
+	record := RebootRecord{RebootedAt: timeNow(), Requests: a.requests}
+	content, err := jsonutil.Marshal(record)
+	if err == nil {
+		if err = fileutil.MakeDirs(filepath.Dir(recordPath)); err == nil {
+			err = fileutil.WriteAllText(recordPath, content)
+		}
+	}
+	if err != nil {
+		log.Warnf("failed to record the reboot requests: %v", err)
+	}
+}
+
+// nextRebootTime returns the first minute from earliest inside a maintenance window, when any are configured, and
+// outside every blackout window. The windows are ignored when they never allow a reboot.
+func (a *arbiter) nextRebootTime(log log.T, earliest time.Time) time.Time {
+	for t := earliest; t.Sub(earliest) < rebootSearchHorizon; t = t.Truncate(time.Minute).Add(time.Minute) {
+		if a.rebootAllowed(t) {
+			return t
+		}
+	}
+	log.Warnf("reboot windows never allow a reboot, rebooting at %v", earliest.Format(time.RFC3339))
+	return earliest
+}
+
+func (a *arbiter) rebootAllowed(t time.Time) bool {
+	for _, window := range a.config.BlackoutWindows {
+		if window.Contains(t) {
+			return false
+		}
+	}
+	if len(a.config.MaintenanceWindows) == 0 {
+		return true
+	}
+	for _, window := range a.config.MaintenanceWindows {
+		if window.Contains(t) {
+			return true
+		}
+	}
+	return false
+}
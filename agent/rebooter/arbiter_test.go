@@ -0,0 +1,128 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package rebooter
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/jsonutil"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/stretchr/testify/assert"
+)
+
+// saturday is a Saturday at the given local time
+func saturday(hour, minute int) time.Time {
+	return time.Date(2020, time.June, 6, hour, minute, 0, 0, time.Local)
+}
+
+// fakeScheduler replaces the clock and timers of the arbiter
+type fakeScheduler struct {
+	now      time.Time
+	delays   []time.Duration
+	fire     func()
+	requests int
+}
+
+func setupArbiter(t *testing.T, config appconfig.RebootCfg) (a *arbiter, scheduler *fakeScheduler, cleanup func()) {
+	dir, err := ioutil.TempDir("", "rebooter")
+	assert.NoError(t, err)
+	scheduler = &fakeScheduler{now: saturday(10, 0)}
+	defaultRecordPath := recordPath
+	recordPath = filepath.Join(dir, "rebootrecord.json")
+	timeNow = func() time.Time { return scheduler.now }
+	afterFunc = func(d time.Duration, f func()) *time.Timer {
+		scheduler.delays = append(scheduler.delays, d)
+		scheduler.fire = f
+		return nil
+	}
+	requestReboot = func(log log.T) bool {
+		scheduler.requests++
+		return true
+	}
+	return &arbiter{config: config}, scheduler, func() {
+		recordPath = defaultRecordPath
+		timeNow = time.Now
+		afterFunc = time.AfterFunc
+		requestReboot = RequestPendingReboot
+		os.RemoveAll(dir)
+	}
+}
+
+func readRecord(t *testing.T) (record RebootRecord) {
+	assert.NoError(t, jsonutil.UnmarshalFile(recordPath, &record))
+	return
+}
+
+func TestArbiterCoalescesRequests(t *testing.T) {
+	a, scheduler, cleanup := setupArbiter(t, appconfig.RebootCfg{CoalesceSeconds: 30})
+	defer cleanup()
+	logger := log.NewMockLog()
+
+	assert.True(t, a.request(logger, RebootRequest{Source: "command-1", Reason: "aws:applyPatches"}))
+	assert.True(t, a.request(logger, RebootRequest{Source: "association-1", Reason: "aws:domainJoin"}))
+	assert.False(t, a.request(logger, RebootRequest{Source: "command-1", Reason: "aws:applyPatches"}))
+	assert.Equal(t, []time.Duration{30 * time.Second}, scheduler.delays, "the requests share a single reboot")
+	assert.Equal(t, 0, scheduler.requests)
+
+	scheduler.fire()
+	assert.Equal(t, 1, scheduler.requests)
+	record := readRecord(t)
+	assert.Len(t, record.Requests, 2)
+	assert.Equal(t, "command-1", record.Requests[0].Source)
+	assert.Equal(t, "association-1", record.Requests[1].Source)
+
+	// a request while the machine reboots is satisfied by the same reboot
+	assert.True(t, a.request(logger, RebootRequest{Source: "command-2"}))
+	assert.Equal(t, 1, scheduler.requests)
+	assert.Len(t, scheduler.delays, 1)
+	assert.Len(t, readRecord(t).Requests, 3)
+}
+
+func TestArbiterWaitsForMaintenanceWindow(t *testing.T) {
+	a, scheduler, cleanup := setupArbiter(t, appconfig.RebootCfg{
+		MaintenanceWindows: []appconfig.PollWindowCfg{{Days: []string{"sat"}, Start: "22:00", End: "02:00"}},
+	})
+	defer cleanup()
+
+	a.request(log.NewMockLog(), RebootRequest{Source: "command-1"})
+	assert.Equal(t, []time.Duration{12 * time.Hour}, scheduler.delays)
+}
+
+func TestArbiterAvoidsBlackoutWindows(t *testing.T) {
+	a, scheduler, cleanup := setupArbiter(t, appconfig.RebootCfg{
+		CoalesceSeconds: 30,
+		BlackoutWindows: []appconfig.PollWindowCfg{{Start: "08:00", End: "18:00"}},
+	})
+	defer cleanup()
+
+	a.request(log.NewMockLog(), RebootRequest{Source: "command-1"})
+	assert.Equal(t, []time.Duration{8 * time.Hour}, scheduler.delays)
+}
+
+func TestArbiterIgnoresWindowsThatNeverAllowReboot(t *testing.T) {
+	a, scheduler, cleanup := setupArbiter(t, appconfig.RebootCfg{
+		CoalesceSeconds:    30,
+		MaintenanceWindows: []appconfig.PollWindowCfg{{Days: []string{"sun"}, Start: "02:00", End: "04:00"}},
+		BlackoutWindows:    []appconfig.PollWindowCfg{{Days: []string{"sun"}, Start: "00:00", End: "23:59"}},
+	})
+	defer cleanup()
+
+	a.request(log.NewMockLog(), RebootRequest{Source: "command-1"})
+	assert.Equal(t, []time.Duration{30 * time.Second}, scheduler.delays)
+}
@@ -47,6 +47,8 @@ func (r *SSMRebooter) RebootMachine(log log.T) {
 	}
 }
 
+// RequestPendingReboot requests the reboot of the machine right away, documents request reboots with RequestReboot
+// so that the reboots of concurrent documents are coalesced
 func RequestPendingReboot(log log.T) bool {
 	//non-blocking send
 	select {
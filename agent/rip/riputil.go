@@ -70,7 +70,8 @@ func GetMgsEndpoint(region string) (mgsEndpoint string) {
 		}
 	}
 
-	if mgsEndpoint, ok := awsMessageGatewayServiceEndpointMap[region]; ok {
+	// the FIPS endpoints are not in the map of standard endpoints
+	if mgsEndpoint, ok := awsMessageGatewayServiceEndpointMap[region]; ok && !useFipsEndpoint() {
 		return mgsEndpoint
 	}
 
@@ -81,6 +82,12 @@ func GetMgsEndpoint(region string) (mgsEndpoint string) {
 	return mgsEndpoint
 }
 
+// useFipsEndpoint returns true when the agent is configured to use FIPS endpoints
+func useFipsEndpoint() bool {
+	appConfig, err := appconfig.Config(false)
+	return err == nil && appConfig.Agent.UseFipsEndpoint
+}
+
 // GetDefaultServiceEndpoint returns the default endpoint for a service, it should not be empty.
 func GetDefaultServiceEndpoint(region string, service string) (endpoint string) {
 	defaultEndpoint := platform.GetDefaultEndPoint(region, service)
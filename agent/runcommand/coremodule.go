@@ -15,6 +15,7 @@
 package runcommand
 
 import (
+	"encoding/json"
 	"fmt"
 	"runtime/debug"
 	"strings"
@@ -26,10 +27,15 @@ import (
 	"github.com/aws/amazon-ssm-agent/agent/contracts"
 	"github.com/aws/amazon-ssm-agent/agent/fileutil"
 	"github.com/aws/amazon-ssm-agent/agent/framework/docmanager"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/outbox"
 	"github.com/aws/amazon-ssm-agent/agent/platform"
 	messageContracts "github.com/aws/amazon-ssm-agent/agent/runcommand/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/runcommand/delivery"
 	mdsService "github.com/aws/amazon-ssm-agent/agent/runcommand/mds"
 	"github.com/aws/amazon-ssm-agent/agent/sdkutil"
+	"github.com/aws/amazon-ssm-agent/agent/shutdown"
+	"github.com/aws/amazon-ssm-agent/agent/watchdog"
 	"github.com/aws/aws-sdk-go/service/ssmmds"
 	"github.com/carlescere/scheduler"
 )
@@ -64,23 +70,30 @@ func (s *RunCommandService) ModuleExecute(context context.T) (err error) {
 		}
 	}()
 	log.Info("Starting document processing engine...")
-	var resultChan chan contracts.DocumentResult
-	if resultChan, err = s.processor.Start(); err != nil {
-		log.Errorf("unable to start document processor: %v", err)
+	if err = s.startProcessor(); err != nil {
+		log.Errorf("%v", err)
 		return
 	}
 
-	go s.listenReply(resultChan)
-
-	if err = s.processor.InitialProcessing(true); err != nil {
-		log.Errorf("initial processing in EngineProcessor encountered error: %v", err)
-		return
+	if s.name == mdsName && context.AppConfig().Mds.CommandDelivery == appconfig.CommandDeliveryPush {
+		log.Info("Accepting run command messages over the control channel")
+		delivery.RegisterHandler(s.processChannelMessage, s.resumePolling)
+	}
+	if s.name == mdsName {
+		outbox.RegisterSender(outbox.KindCommandReply, s.sendQueuedReply)
 	}
 
 	log.Info("Starting message polling")
-	if s.messagePollJob, err = scheduler.Every(pollMessageFrequencyMinutes).Minutes().Run(s.messagePollLoop); err != nil {
+	var messagePollJob *scheduler.Job
+	if messagePollJob, err = scheduler.Every(pollMessageFrequencyMinutes).Minutes().Run(s.messagePollLoop); err != nil {
 		context.Log().Errorf("unable to schedule message poll job. %v", err)
 	}
+	s.restartLock.Lock()
+	s.messagePollJob = messagePollJob
+	s.restartLock.Unlock()
+	if s.name == mdsName {
+		watchdog.Register(s.name, pollMessageFrequencyMinutes*time.Minute, s.restartPolling)
+	}
 
 	log.Info("Starting send replies to MDS")
 	if s.sendReplyJob, err = scheduler.Every(sendReplyFrequencyMinutes).Minutes().Run(s.sendReplyLoop); err != nil {
@@ -95,10 +108,19 @@ func (s *RunCommandService) ModuleExecute(context context.T) (err error) {
 }
 
 func (s *RunCommandService) ModuleRequestStop(stopType contracts.StopType) (err error) {
-	//first stop sending failed replies to the service and the message poller
+	//first stop receiving messages over the control channel, sending failed replies to the service and the message poller
+	if s.name == mdsName {
+		watchdog.Unregister(s.name)
+		delivery.RegisterHandler(nil, nil)
+		outbox.RegisterSender(outbox.KindCommandReply, nil)
+	}
 	s.stop()
 	//second stop the message processor
-	s.processor.Stop(stopType)
+	s.getProcessor().Stop(stopType)
+	//on shutdown send the replies of the documents that completed while draining, documents still running resume after the restart
+	if shutdown.InProgress() {
+		s.waitForReplies()
+	}
 
 	//TODO move this out once we have association moved to a different core module
 	if s.assocProcessor != nil {
@@ -107,8 +129,46 @@ func (s *RunCommandService) ModuleRequestStop(stopType contracts.StopType) (err
 	return nil
 }
 
-func (s *RunCommandService) listenReply(resultChan chan contracts.DocumentResult) {
+// startProcessor starts the current document processor and the sending of its results,
+// then resumes the documents that did not complete before it started
+func (s *RunCommandService) startProcessor() (err error) {
+	processor := s.getProcessor()
+	var resultChan chan contracts.DocumentResult
+	if resultChan, err = processor.Start(); err != nil {
+		return fmt.Errorf("unable to start document processor: %v", err)
+	}
+
+	repliesDone := make(chan bool)
+	s.restartLock.Lock()
+	s.repliesDone = repliesDone
+	s.restartLock.Unlock()
+	go s.listenReply(resultChan, repliesDone)
+
+	if err = processor.InitialProcessing(true); err != nil {
+		return fmt.Errorf("initial processing in EngineProcessor encountered error: %v", err)
+	}
+	return nil
+}
+
+// waitForReplies waits until listenReply sent the results of the stopped processor, at most until the shutdown deadline
+func (s *RunCommandService) waitForReplies() {
+	s.restartLock.RLock()
+	repliesDone := s.repliesDone
+	s.restartLock.RUnlock()
+	if repliesDone == nil {
+		return
+	}
+	select {
+	case <-repliesDone:
+	case <-time.After(shutdown.Remaining()):
+		s.context.Log().Warn("Shutdown deadline passed before the command replies were sent")
+	}
+}
+
+// listenReply sends the results of a processor until it closes resultChan, then closes done
+func (s *RunCommandService) listenReply(resultChan chan contracts.DocumentResult, done chan bool) {
 	log := s.context.Log()
+	defer close(done)
 	//processor guarantees to close this channel upon stop
 	for res := range resultChan {
 		func() {
@@ -160,7 +220,13 @@ func (s *RunCommandService) handleSpecialPlugin(lastPluginID string, pluginRes m
 
 }
 
+// processMessage processes a message polled from MDS
 func (s *RunCommandService) processMessage(msg *ssmmds.Message) {
+	s.processChannelMessage(s.getService(), msg)
+}
+
+// processChannelMessage processes a message received on a channel, and acknowledges or fails it on the same channel
+func (s *RunCommandService) processChannelMessage(channel delivery.Channel, msg *ssmmds.Message) {
 	var (
 		docState *contracts.DocumentState
 		err      error
@@ -176,6 +242,16 @@ func (s *RunCommandService) processMessage(msg *ssmmds.Message) {
 		return
 	}
 
+	if validation := context.AppConfig().Mds.Validation; validation.Strict && s.messageGuard != nil {
+		isProcessed := func(messageID string) bool {
+			return isDocumentProcessed(log, s.config.InstanceID, messageID)
+		}
+		if reason, err := s.messageGuard.check(validation, msg, time.Now(), isProcessed); err != nil {
+			s.rejectMessage(log, channel, msg, reason, err)
+			return
+		}
+	}
+
 	if strings.HasPrefix(*msg.Topic, string(SendCommandTopicPrefix)) {
 		docState, err = loadDocStateFromSendCommand(context, msg, s.orchestrationRootDir)
 		if err != nil {
@@ -191,12 +267,12 @@ func (s *RunCommandService) processMessage(msg *ssmmds.Message) {
 
 	if err != nil {
 		log.Error("format of received message is invalid ", err)
-		if err = s.service.FailMessage(log, *msg.MessageId, mdsService.InternalHandlerException); err != nil {
+		if err = channel.FailMessage(log, *msg.MessageId, mdsService.InternalHandlerException); err != nil {
 			sdkutil.HandleAwsError(log, err, s.processorStopPolicy)
 		}
 		return
 	}
-	if err = s.service.AcknowledgeMessage(log, *msg.MessageId); err != nil {
+	if err = channel.AcknowledgeMessage(log, *msg.MessageId); err != nil {
 		sdkutil.HandleAwsError(log, err, s.processorStopPolicy)
 		return
 	}
@@ -211,9 +287,9 @@ func (s *RunCommandService) processMessage(msg *ssmmds.Message) {
 	log.Debugf("SendReply done. Received message - messageId - %v", *msg.MessageId)
 	switch docState.DocumentType {
 	case contracts.SendCommand, contracts.SendCommandOffline:
-		s.processor.Submit(*docState)
+		s.getProcessor().Submit(*docState)
 	case contracts.CancelCommand, contracts.CancelCommandOffline:
-		s.processor.Cancel(*docState)
+		s.getProcessor().Cancel(*docState)
 
 	default:
 		log.Error("unexpected document type ", docState.DocumentType)
@@ -226,7 +302,8 @@ func (s *RunCommandService) sendFailedReplies() {
 	log := s.context.Log()
 
 	log.Debug("Checking if there are document replies that failed to reach the service, and retry sending them")
-	replies := s.service.LoadFailedReplies(log)
+	service := s.getService()
+	replies := service.LoadFailedReplies(log)
 
 	if len(replies) != 0 {
 		log.Infof("Found document replies that need to be sent to the service")
@@ -234,22 +311,22 @@ func (s *RunCommandService) sendFailedReplies() {
 			log.Debug("Loading reply ", reply)
 			if isValidReplyRequest(reply) == false {
 				log.Debug("Reply is old, document execution must have timed out. Deleting the reply")
-				s.service.DeleteFailedReply(log, reply)
+				service.DeleteFailedReply(log, reply)
 				continue
 			}
-			sendReplyRequest, err := s.service.GetFailedReply(log, reply)
+			sendReplyRequest, err := service.GetFailedReply(log, reply)
 			if err != nil {
 				log.Error("Couldn't load the reply from disk ", err)
 				continue
 			}
 
 			log.Info("Sending reply ", reply)
-			if err = s.service.SendReplyWithInput(log, sendReplyRequest); err != nil {
+			if err = service.SendReplyWithInput(log, sendReplyRequest); err != nil {
 				sdkutil.HandleAwsError(log, err, s.processorStopPolicy)
 				break
 			} else {
 				log.Infof("Sending reply %v succeeded, deleting the reply file from disk", reply)
-				s.service.DeleteFailedReply(log, reply)
+				service.DeleteFailedReply(log, reply)
 			}
 		}
 	} else {
@@ -257,6 +334,15 @@ func (s *RunCommandService) sendFailedReplies() {
 	}
 }
 
+// sendQueuedReply sends a reply queued while MDS was unreachable
+func (s *RunCommandService) sendQueuedReply(log log.T, payload []byte) error {
+	var sendReplyRequest ssmmds.SendReplyInput
+	if err := json.Unmarshal(payload, &sendReplyRequest); err != nil {
+		return err
+	}
+	return s.getService().SendReplyWithInput(log, &sendReplyRequest)
+}
+
 // isValidReplyRequest checks if the sendReply request is older than 2 hours
 // If so it is considered as not valid anymore as the document must have timed out
 func isValidReplyRequest(filename string) bool {
@@ -0,0 +1,97 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package delivery hands run command messages received over the MGS control channel to the run command service,
+// so messages are processed the same way whether they are pushed by MGS or polled from MDS.
+package delivery
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	mdsService "github.com/aws/amazon-ssm-agent/agent/runcommand/mds"
+	"github.com/aws/aws-sdk-go/service/ssmmds"
+)
+
+// Channel is a pipeline run command messages are received on, the MDS service and the MGS control channel both implement it
+type Channel interface {
+	AcknowledgeMessage(log log.T, messageID string) error
+	FailMessage(log log.T, messageID string, failureType mdsService.FailureType) error
+}
+
+// Handler processes a run command message received on a channel
+type Handler func(channel Channel, msg *ssmmds.Message)
+
+// ErrNoHandler is returned when a message is delivered before the run command service registered its handler
+var ErrNoHandler = errors.New("no handler is registered for run command messages")
+
+// ErrPushUnavailable is returned when a message is delivered while push delivery is turned off
+var ErrPushUnavailable = errors.New("run command messages are not delivered over the control channel")
+
+var (
+	lock        sync.RWMutex
+	handler     Handler
+	onPushLost  func()
+	pushChannel Channel
+)
+
+// RegisterHandler registers the function pushed messages are processed with, and the function called when
+// push delivery stops so the run command service can resume polling. A nil handler unregisters it.
+func RegisterHandler(messageHandler Handler, pushLost func()) {
+	lock.Lock()
+	defer lock.Unlock()
+	handler = messageHandler
+	onPushLost = pushLost
+}
+
+// SetPushChannel records the channel run command messages are pushed on, nil when push delivery stops
+func SetPushChannel(log log.T, channel Channel) {
+	lock.Lock()
+	wasAvailable := pushChannel != nil
+	pushChannel = channel
+	pushLost := onPushLost
+	lock.Unlock()
+
+	if channel != nil && !wasAvailable {
+		log.Info("Run command messages are delivered over the control channel, polling is paused")
+	} else if channel == nil && wasAvailable {
+		log.Info("Run command messages are no longer delivered over the control channel, resuming polling")
+		if pushLost != nil {
+			pushLost()
+		}
+	}
+}
+
+// PushAvailable returns true if run command messages are pushed over the control channel and do not need to be polled
+func PushAvailable() bool {
+	lock.RLock()
+	defer lock.RUnlock()
+	return pushChannel != nil && handler != nil
+}
+
+// Deliver hands a message pushed over the control channel to the run command service
+func Deliver(msg *ssmmds.Message) error {
+	lock.RLock()
+	messageHandler, channel := handler, pushChannel
+	lock.RUnlock()
+
+	if messageHandler == nil {
+		return ErrNoHandler
+	}
+	if channel == nil {
+		return ErrPushUnavailable
+	}
+	messageHandler(channel, msg)
+	return nil
+}
@@ -0,0 +1,61 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package delivery
+
+import (
+	"testing"
+
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	mdsService "github.com/aws/amazon-ssm-agent/agent/runcommand/mds"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ssmmds"
+	"github.com/stretchr/testify/assert"
+)
+
+type channelStub struct{}
+
+func (channelStub) AcknowledgeMessage(log log.T, messageID string) error { return nil }
+
+func (channelStub) FailMessage(log log.T, messageID string, failureType mdsService.FailureType) error {
+	return nil
+}
+
+func TestDeliver(t *testing.T) {
+	logger := log.NewMockLog()
+	defer RegisterHandler(nil, nil)
+	defer SetPushChannel(logger, nil)
+
+	msg := &ssmmds.Message{MessageId: aws.String("aws.ssm.sendCommand.test")}
+	assert.Equal(t, ErrNoHandler, Deliver(msg))
+
+	var delivered []*ssmmds.Message
+	pushLost := 0
+	RegisterHandler(func(channel Channel, msg *ssmmds.Message) {
+		assert.Equal(t, channelStub{}, channel)
+		delivered = append(delivered, msg)
+	}, func() { pushLost++ })
+
+	assert.False(t, PushAvailable())
+	assert.Equal(t, ErrPushUnavailable, Deliver(msg))
+
+	SetPushChannel(logger, channelStub{})
+	assert.True(t, PushAvailable())
+	assert.NoError(t, Deliver(msg))
+	assert.Equal(t, []*ssmmds.Message{msg}, delivered)
+
+	SetPushChannel(logger, nil)
+	SetPushChannel(logger, nil)
+	assert.False(t, PushAvailable())
+	assert.Equal(t, 1, pushLost)
+}
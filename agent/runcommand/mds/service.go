@@ -16,7 +16,6 @@ package service
 
 import (
 	"fmt"
-	"net"
 	"net/http"
 	"os"
 	"path"
@@ -28,8 +27,15 @@ import (
 	"github.com/aws/amazon-ssm-agent/agent/fileutil"
 	"github.com/aws/amazon-ssm-agent/agent/jsonutil"
 	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/network"
+	"github.com/aws/amazon-ssm-agent/agent/network/clockskew"
+	"github.com/aws/amazon-ssm-agent/agent/network/compression"
+	"github.com/aws/amazon-ssm-agent/agent/network/failover"
+	"github.com/aws/amazon-ssm-agent/agent/outbox"
 	"github.com/aws/amazon-ssm-agent/agent/platform"
+	"github.com/aws/amazon-ssm-agent/agent/proxyconfig"
 	"github.com/aws/amazon-ssm-agent/agent/sdkutil"
+	"github.com/aws/amazon-ssm-agent/agent/statecrypto"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/credentials"
 	"github.com/aws/aws-sdk-go/aws/request"
@@ -108,20 +114,24 @@ func NewService(region string, endpoint string, creds *credentials.Credentials,
 
 	// capture Transport so we can use it to cancel requests
 	tr := &http.Transport{
-		Proxy: http.ProxyFromEnvironment,
-		Dial: (&net.Dialer{
+		Proxy: proxyconfig.ProxyFunc(proxyconfig.ServiceEc2Messages),
+		DialContext: network.Dialer{
 			Timeout:   connectionTimeout,
 			KeepAlive: 0,
-		}).Dial,
+		}.DialContext,
 		TLSHandshakeTimeout: 10 * time.Second,
+		TLSClientConfig:     network.GetDefaultTLSConfig(),
 	}
 	config.HTTPClient = &http.Client{Transport: tr, Timeout: connectionTimeout}
 
 	appConfig, _ := appconfig.Config(false)
 	sess := session.New(config)
 	sess.Handlers.Build.PushBack(request.MakeAddToUserAgentHandler(appConfig.Agent.Name, appConfig.Agent.Version))
+	failover.AddHandlers(&sess.Handlers, proxyconfig.ServiceEc2Messages)
 
 	msgSvc := ssmmds.New(sess)
+	clockskew.AddHandlers(&msgSvc.Handlers)
+	compression.AddHandlers(&msgSvc.Handlers, proxyconfig.ServiceEc2Messages, "SendReply")
 
 	//adding server based expected error messages
 	serverBasedErrorMessages = make([]string, 2)
@@ -236,7 +246,7 @@ func (mds *sdkService) SendReplyWithInput(log log.T, sendReply *ssmmds.SendReply
 	log.Debug("Calling SendReply with params", sendReply)
 	req, resp := mds.sdk.SendReplyRequest(sendReply)
 	if err = mds.sendRequest(req); err != nil {
-		err = fmt.Errorf("SendReply Error: %v", err)
+		err = fmt.Errorf("SendReply Error: %w", err)
 		log.Debug(err)
 	} else {
 		log.Info("SendReply Response", resp)
@@ -253,9 +263,14 @@ func (mds *sdkService) SendReply(log log.T, messageID string, payload string) (e
 		Payload:   aws.String(payload),   // Required
 		ReplyId:   aws.String(replyID),   // Required
 	}
-	if err = mds.SendReplyWithInput(log, &replyInput); err != nil {
+	var queued bool
+	if queued, err = outbox.Send(log, outbox.KindCommandReply, replyInput, func() error {
+		return mds.SendReplyWithInput(log, &replyInput)
+	}); err != nil {
 		log.Infof("Saving reply %v to local disk", replyID)
 		mds.PersistFailedReply(log, replyInput)
+	} else if queued {
+		log.Infof("Queued reply %v until the service can be reached", replyID)
 	}
 	return
 }
@@ -339,7 +354,12 @@ func (mds *sdkService) PersistFailedReply(log log.T, sendReply ssmmds.SendReplyI
 		absoluteFileName := getFailedReplyLocation(fileName)
 
 		log.Tracef("persisting reply %v in file %v", jsonutil.Indent(content), absoluteFileName)
-		if s, err := fileutil.WriteIntoFileWithPermissions(absoluteFileName, jsonutil.Indent(content), os.FileMode(int(appconfig.ReadWriteAccess))); s && err == nil {
+		sealed, err := statecrypto.Seal(log, []byte(jsonutil.Indent(content)))
+		if err != nil {
+			log.Errorf("encountered error %v while encrypting reply for %v", err, absoluteFileName)
+			return err
+		}
+		if s, err := fileutil.WriteIntoFileWithPermissions(absoluteFileName, string(sealed), os.FileMode(int(appconfig.ReadWriteAccess))); s && err == nil {
 			log.Debugf("successfully persisted reply in %v", absoluteFileName)
 		} else {
 			log.Debugf("persisting reply in %v failed with error %v", absoluteFileName, err)
@@ -353,7 +373,7 @@ func (mds *sdkService) GetFailedReply(log log.T, fileName string) (*ssmmds.SendR
 	absoluteFileName := getFailedReplyLocation(fileName)
 
 	var sendReply ssmmds.SendReplyInput
-	err := jsonutil.UnmarshalFile(absoluteFileName, &sendReply)
+	err := statecrypto.UnmarshalFile(log, absoluteFileName, &sendReply)
 	if err != nil {
 		log.Errorf("encountered error with message %v while reading reply input from file - %v", err, absoluteFileName)
 	} else {
@@ -0,0 +1,94 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package runcommand implements runcommand core processing module
+package runcommand
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/framework/docmanager"
+	logger "github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/runcommand/delivery"
+	mdsService "github.com/aws/amazon-ssm-agent/agent/runcommand/mds"
+	"github.com/aws/amazon-ssm-agent/agent/sdkutil"
+	"github.com/aws/amazon-ssm-agent/agent/times"
+	"github.com/aws/aws-sdk-go/service/ssmmds"
+)
+
+// rejectReason is recorded in the audit log for a message rejected by strict validation
+type rejectReason string
+
+const (
+	rejectedClockSkew rejectReason = "clock_skew"
+	rejectedReplay    rejectReason = "replay"
+)
+
+// isDocumentProcessed returns true if the document of the message was already executed on the instance
+var isDocumentProcessed = func(log logger.T, instanceID string, messageID string) bool {
+	documentMgr := docmanager.NewDocumentFileMgr(appconfig.DefaultDataStorePath, appconfig.DefaultDocumentRootDirName, appconfig.DefaultLocationOfState)
+	return documentMgr.IsDocumentProcessed(log, instanceID, messageID)
+}
+
+// messageGuard remembers the ids of the messages received inside the clock skew window, so a replayed message
+// is rejected even before its document is executed. Older messages are rejected by their created date.
+type messageGuard struct {
+	lock sync.Mutex
+	seen map[string]time.Time
+}
+
+// check returns an error and the reason if the message was created outside the clock skew window or was received before.
+// isProcessed reports messages whose documents were executed before the agent restarted.
+func (g *messageGuard) check(config appconfig.MdsValidationCfg, msg *ssmmds.Message, now time.Time, isProcessed func(messageID string) bool) (rejectReason, error) {
+	maxSkew := time.Duration(config.MaxClockSkewSeconds) * time.Second
+	createdDate := times.ParseIso8601UTC(*msg.CreatedDate)
+	if skew := now.Sub(createdDate); skew > maxSkew || skew < -maxSkew {
+		return rejectedClockSkew, fmt.Errorf("message created at %v is outside the allowed clock skew of %v", *msg.CreatedDate, maxSkew)
+	}
+
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	if g.seen == nil {
+		g.seen = make(map[string]time.Time)
+	}
+	for messageID, seenCreatedDate := range g.seen {
+		if now.Sub(seenCreatedDate) > maxSkew {
+			delete(g.seen, messageID)
+		}
+	}
+
+	if _, seen := g.seen[*msg.MessageId]; seen || isProcessed(*msg.MessageId) {
+		return rejectedReplay, fmt.Errorf("message %v was received before", *msg.MessageId)
+	}
+	g.seen[*msg.MessageId] = createdDate
+	return "", nil
+}
+
+// rejectMessage records a message rejected by strict validation in the audit log. A replayed message is acknowledged
+// so it is not delivered again, failing it would fail the command of the original message.
+func (s *RunCommandService) rejectMessage(log logger.T, channel delivery.Channel, msg *ssmmds.Message, reason rejectReason, err error) {
+	log.Errorf("message rejected by strict validation: %v", err)
+	log.WriteEvent(logger.MessageRejectedMessage, "", fmt.Sprintf("%s:%s", reason, *msg.MessageId))
+
+	if reason == rejectedReplay {
+		err = channel.AcknowledgeMessage(log, *msg.MessageId)
+	} else {
+		err = channel.FailMessage(log, *msg.MessageId, mdsService.InternalHandlerException)
+	}
+	if err != nil {
+		sdkutil.HandleAwsError(log, err, s.processorStopPolicy)
+	}
+}
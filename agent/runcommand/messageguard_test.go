@@ -0,0 +1,122 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package runcommand implements runcommand core processing module
+package runcommand
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/context"
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	mdsService "github.com/aws/amazon-ssm-agent/agent/runcommand/mds"
+	"github.com/aws/amazon-ssm-agent/agent/times"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ssmmds"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+var strictValidation = appconfig.MdsValidationCfg{Strict: true, MaxClockSkewSeconds: 300}
+
+func notProcessed(messageID string) bool {
+	return false
+}
+
+func TestMessageGuard_ClockSkew(t *testing.T) {
+	now := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+	guard := &messageGuard{}
+
+	for _, createdDate := range []time.Time{now.Add(-6 * time.Minute), now.Add(6 * time.Minute)} {
+		msg := &ssmmds.Message{MessageId: aws.String("message"), CreatedDate: aws.String(times.ToIso8601UTC(createdDate))}
+		reason, err := guard.check(strictValidation, msg, now, notProcessed)
+		assert.Error(t, err)
+		assert.Equal(t, rejectedClockSkew, reason)
+	}
+
+	msg := &ssmmds.Message{MessageId: aws.String("message"), CreatedDate: aws.String("invalid")}
+	reason, err := guard.check(strictValidation, msg, now, notProcessed)
+	assert.Error(t, err)
+	assert.Equal(t, rejectedClockSkew, reason)
+
+	msg.CreatedDate = aws.String(times.ToIso8601UTC(now.Add(-4 * time.Minute)))
+	_, err = guard.check(strictValidation, msg, now, notProcessed)
+	assert.NoError(t, err)
+}
+
+func TestMessageGuard_Replay(t *testing.T) {
+	now := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+	guard := &messageGuard{}
+	msg := &ssmmds.Message{MessageId: aws.String("message"), CreatedDate: aws.String(times.ToIso8601UTC(now))}
+
+	_, err := guard.check(strictValidation, msg, now, notProcessed)
+	assert.NoError(t, err)
+
+	reason, err := guard.check(strictValidation, msg, now.Add(time.Minute), notProcessed)
+	assert.Error(t, err)
+	assert.Equal(t, rejectedReplay, reason)
+
+	// the message is forgotten once its created date is outside the window, it is rejected by the clock skew check then
+	_, err = guard.check(strictValidation, &ssmmds.Message{MessageId: aws.String("other"), CreatedDate: aws.String(times.ToIso8601UTC(now.Add(6 * time.Minute)))}, now.Add(6*time.Minute), notProcessed)
+	assert.NoError(t, err)
+	assert.NotContains(t, guard.seen, "message")
+}
+
+func TestMessageGuard_ProcessedBeforeRestart(t *testing.T) {
+	now := time.Now()
+	guard := &messageGuard{}
+	msg := &ssmmds.Message{MessageId: aws.String("message"), CreatedDate: aws.String(times.ToIso8601UTC(now))}
+
+	reason, err := guard.check(strictValidation, msg, now, func(messageID string) bool { return messageID == "message" })
+
+	assert.Error(t, err)
+	assert.Equal(t, rejectedReplay, reason)
+}
+
+func TestProcessMessageStrictValidation(t *testing.T) {
+	defer func(original func(log.T, string, string) bool) { isDocumentProcessed = original }(isDocumentProcessed)
+	isDocumentProcessed = func(log.T, string, string) bool { return false }
+	loadDocStateFromSendCommand = func(context context.T, msg *ssmmds.Message, messagesOrchestrationRootDir string) (*contracts.DocumentState, error) {
+		return &contracts.DocumentState{DocumentType: contracts.SendCommand}, nil
+	}
+
+	svc, tc := prepareTestProcessMessage(testTopicSend)
+	contextMock := new(context.Mock)
+	contextMock.On("Log").Return(log.NewMockLog())
+	contextMock.On("With", mock.AnythingOfType("string")).Return(contextMock)
+	contextMock.On("AppConfig").Return(appconfig.SsmagentConfig{Mds: appconfig.MdsCfg{Validation: strictValidation}})
+	svc.context = contextMock
+	svc.messageGuard = &messageGuard{}
+	tc.Message.CreatedDate = aws.String(times.ToIso8601UTC(time.Now()))
+	tc.MdsMock.On("AcknowledgeMessage", mock.Anything, *tc.Message.MessageId).Return(nil)
+	tc.ProcessMock.On("Submit", mock.Anything).Return(nil).Once()
+
+	svc.processMessage(&tc.Message)
+	svc.processMessage(&tc.Message)
+
+	// the replay is acknowledged but not submitted again
+	tc.MdsMock.AssertNumberOfCalls(t, "AcknowledgeMessage", 2)
+	tc.ProcessMock.AssertNumberOfCalls(t, "Submit", 1)
+
+	tc.Message.MessageId = aws.String("stale-message")
+	tc.Message.CreatedDate = aws.String(testCreatedDate)
+	tc.MdsMock.On("FailMessage", mock.Anything, "stale-message", mdsService.InternalHandlerException).Return(nil)
+
+	svc.processMessage(&tc.Message)
+
+	tc.MdsMock.AssertCalled(t, "FailMessage", mock.Anything, "stale-message", mdsService.InternalHandlerException)
+	tc.ProcessMock.AssertNumberOfCalls(t, "Submit", 1)
+}
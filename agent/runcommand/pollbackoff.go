@@ -0,0 +1,58 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package runcommand implements runcommand core processing module
+package runcommand
+
+import (
+	"math"
+	"sync/atomic"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+)
+
+// pollBackoffBase is the wait after the first poll without messages, it doubles for every following quiet poll
+const pollBackoffBase = 5 * time.Second
+
+// pollBackoff tracks how many polls in a row returned no messages to compute the wait before the next poll
+type pollBackoff struct {
+	quietPolls int32
+}
+
+// next returns the wait before the next poll. The wait resets after a poll that received messages
+// and inside poll windows, otherwise it backs off exponentially up to the configured ceiling.
+func (b *pollBackoff) next(config appconfig.MdsPollingCfg, receivedMessages bool, now time.Time) time.Duration {
+	if receivedMessages || inPollWindow(config.Windows, now) {
+		atomic.StoreInt32(&b.quietPolls, 0)
+		return 0
+	}
+	quietPolls := atomic.AddInt32(&b.quietPolls, 1)
+
+	ceiling := time.Duration(config.MaxIntervalSeconds) * time.Second
+	wait := time.Duration(float64(pollBackoffBase) * math.Pow(2, float64(quietPolls-1)))
+	if wait > ceiling || wait <= 0 {
+		return ceiling
+	}
+	return wait
+}
+
+// inPollWindow returns true if the local time is inside one of the poll windows
+func inPollWindow(windows []appconfig.PollWindowCfg, now time.Time) bool {
+	for _, window := range windows {
+		if window.Contains(now) {
+			return true
+		}
+	}
+	return false
+}
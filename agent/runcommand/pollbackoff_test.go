@@ -0,0 +1,62 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package runcommand implements runcommand core processing module
+package runcommand
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPollBackoff(t *testing.T) {
+	config := appconfig.MdsPollingCfg{Adaptive: true, MaxIntervalSeconds: 30}
+	now := time.Date(2020, 6, 3, 12, 0, 0, 0, time.Local)
+	backoff := pollBackoff{}
+
+	assert.Equal(t, 5*time.Second, backoff.next(config, false, now))
+	assert.Equal(t, 10*time.Second, backoff.next(config, false, now))
+	assert.Equal(t, 20*time.Second, backoff.next(config, false, now))
+	assert.Equal(t, 30*time.Second, backoff.next(config, false, now))
+	assert.Equal(t, 30*time.Second, backoff.next(config, false, now))
+
+	// activity resets the backoff
+	assert.Equal(t, time.Duration(0), backoff.next(config, true, now))
+	assert.Equal(t, 5*time.Second, backoff.next(config, false, now))
+
+	// polls inside a window never wait
+	config.Windows = []appconfig.PollWindowCfg{{Start: "11:00", End: "13:00"}}
+	assert.Equal(t, time.Duration(0), backoff.next(config, false, now))
+	assert.Equal(t, 5*time.Second, backoff.next(config, false, now.Add(2*time.Hour)))
+}
+
+func TestInPollWindow(t *testing.T) {
+	// 2020-06-06 is a Saturday
+	saturday := func(hour, minute int) time.Time { return time.Date(2020, 6, 6, hour, minute, 0, 0, time.Local) }
+	weekend := []appconfig.PollWindowCfg{{Days: []string{"sat"}, Start: "22:00", End: "02:00"}}
+
+	assert.True(t, inPollWindow(weekend, saturday(23, 30)))
+	assert.False(t, inPollWindow(weekend, saturday(21, 59)))
+	// the window spanning midnight started on Saturday, so Sunday morning is inside and Saturday morning is not
+	assert.True(t, inPollWindow(weekend, saturday(25, 30)))
+	assert.False(t, inPollWindow(weekend, saturday(1, 30)))
+	assert.False(t, inPollWindow(weekend, saturday(26, 0)))
+
+	daily := []appconfig.PollWindowCfg{{Start: "09:00", End: "17:00"}}
+	assert.True(t, inPollWindow(daily, saturday(9, 0)))
+	assert.False(t, inPollWindow(daily, saturday(17, 0)))
+	assert.False(t, inPollWindow(nil, saturday(12, 0)))
+}
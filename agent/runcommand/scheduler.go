@@ -20,8 +20,11 @@ import (
 	"sync"
 	"time"
 
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
 	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/runcommand/delivery"
 	"github.com/aws/amazon-ssm-agent/agent/sdkutil"
+	"github.com/aws/amazon-ssm-agent/agent/watchdog"
 	"github.com/carlescere/scheduler"
 )
 
@@ -62,17 +65,33 @@ func (s *RunCommandService) messagePollLoop() {
 	// this is extra insurance to prevent any race condition
 	pollStartTime := time.Now()
 	updateLastPollTime(s.name, pollStartTime)
+	// a loop that returns is progress for the watchdog, a loop stuck polling or handing over messages is not
+	defer watchdog.Beat(s.name)
 
 	log := s.context.Log()
+	if s.name == mdsName && delivery.PushAvailable() {
+		// messages are pushed over the control channel, resumePolling starts the loop again when that stops
+		log.Debugf("Run command messages are delivered over the control channel, skipping poll")
+		return
+	}
 	if err := s.checkStopPolicy(log); err != nil {
 		return
 	}
 
-	s.pollOnce()
+	received := s.pollOnce()
 	if s.name == mdsName {
 		log.Debugf("%v's stoppolicy after polling is %v", s.name, s.processorStopPolicy)
 	}
 
+	pollingConfig := s.context.AppConfig().Mds.Polling
+	if s.name == mdsName && pollingConfig.Adaptive {
+		// back off while no messages arrive, and poll right away after activity or inside poll windows
+		if wait := s.pollBackoff.next(pollingConfig, received > 0, time.Now()); wait > 0 {
+			log.Debugf("No messages received, waiting %v before the next poll", wait)
+			time.Sleep(wait)
+		}
+	}
+
 	// Slow down a bit in case GetMessages returns
 	// without blocking, which may cause us to
 	// flood the service with requests.
@@ -84,7 +103,7 @@ func (s *RunCommandService) messagePollLoop() {
 	// to prevent any possible race condition due to the scheduler
 	if getLastPollTime(s.name) == pollStartTime {
 		// skip waiting for the next scheduler polling event and start polling immediately
-		scheduleNextRun(s.messagePollJob)
+		scheduleNextRun(s.getMessagePollJob())
 	}
 }
 
@@ -110,6 +129,19 @@ var scheduleNextRun = func(j *scheduler.Job) {
 	j.SkipWait <- true
 }
 
+// resumePolling starts polling immediately when messages stop being delivered over the control channel
+func (s *RunCommandService) resumePolling() {
+	messagePollJob := s.getMessagePollJob()
+	if messagePollJob == nil {
+		return
+	}
+	select {
+	case messagePollJob.SkipWait <- true:
+	default:
+		// a poll is already pending
+	}
+}
+
 func (s *RunCommandService) reset() {
 	log := s.context.Log()
 	log.Debugf("Resetting processor:%v", s.name)
@@ -120,34 +152,68 @@ func (s *RunCommandService) reset() {
 	// this is extra insurance to avoid service object getting corrupted - adding resiliency
 	config := s.context.AppConfig()
 	if s.name == mdsName {
+		s.restartLock.Lock()
 		s.service = newMdsService(config)
+		s.restartLock.Unlock()
 	}
 }
 
+// restartPolling is the restart of the watchdog when the poll loop stalls. The loop may hang on a request to MDS or on
+// handing a message to a wedged processor, so the service client, the processor and the poll job are all replaced.
+// The stalled ones are stopped before the new processor starts, their documents resume on it like after a restart.
+func (s *RunCommandService) restartPolling(log log.T) {
+	log.Warnf("Restarting message polling and document processing of %v", s.name)
+	s.restartLock.Lock()
+	stalledService, stalledProcessor, stalledPollJob := s.service, s.processor, s.messagePollJob
+	s.service = newMdsService(s.context.AppConfig())
+	s.processor = s.newProcessor(s.commandWorkerLimit)
+	s.messagePollJob = nil
+	s.restartLock.Unlock()
+
+	// the stalled ones are stopped outside the lock, the documents of the stalled processor send their replies while it stops
+	if stalledPollJob != nil {
+		stalledPollJob.Quit <- true
+	}
+	stalledService.Stop()
+	stalledProcessor.Stop(contracts.StopTypeHardStop)
+
+	if err := s.startProcessor(); err != nil {
+		log.Errorf("%v", err)
+	}
+
+	messagePollJob, err := scheduler.Every(pollMessageFrequencyMinutes).Minutes().Run(s.messagePollLoop)
+	if err != nil {
+		log.Errorf("unable to schedule message poll job. %v", err)
+	}
+	s.restartLock.Lock()
+	s.messagePollJob = messagePollJob
+	s.restartLock.Unlock()
+}
+
 // Stop stops the message poller.
 func (s *RunCommandService) stop() {
 	log := s.context.Log()
 	log.Debugf("Stopping processor:%v", s.name)
-	s.service.Stop()
+	s.getService().Stop()
 
-	if s.messagePollJob != nil {
-		s.messagePollJob.Quit <- true
+	if messagePollJob := s.getMessagePollJob(); messagePollJob != nil {
+		messagePollJob.Quit <- true
 	}
 	if s.sendReplyJob != nil {
 		s.sendReplyJob.Quit <- true
 	}
 }
 
-// pollOnce calls GetMessages once and processes the result.
-func (s *RunCommandService) pollOnce() {
+// pollOnce calls GetMessages once, processes the result and returns the number of messages received.
+func (s *RunCommandService) pollOnce() (received int) {
 	log := s.context.Log()
 	if s.name == mdsName {
 		log.Debugf("Polling for messages")
 	}
-	messages, err := s.service.GetMessages(log, s.config.InstanceID)
+	messages, err := s.getService().GetMessages(log, s.config.InstanceID)
 	if err != nil {
 		sdkutil.HandleAwsError(log, err, s.processorStopPolicy)
-		return
+		return 0
 	}
 	if len(messages.Messages) > 0 {
 		log.Debugf("Got %v messages", len(messages.Messages))
@@ -159,4 +225,5 @@ func (s *RunCommandService) pollOnce() {
 	if s.name == mdsName {
 		log.Debugf("Done poll once")
 	}
+	return len(messages.Messages)
 }
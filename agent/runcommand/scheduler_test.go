@@ -18,8 +18,14 @@ import (
 	"fmt"
 	"testing"
 
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
 	"github.com/aws/amazon-ssm-agent/agent/context"
 	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/framework/processor"
+	processormock "github.com/aws/amazon-ssm-agent/agent/framework/processor/mock"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/runcommand/delivery"
+	mdsService "github.com/aws/amazon-ssm-agent/agent/runcommand/mds"
 	runcommandmock "github.com/aws/amazon-ssm-agent/agent/runcommand/mock"
 	"github.com/aws/aws-sdk-go/service/ssmmds"
 	"github.com/stretchr/testify/assert"
@@ -175,3 +181,66 @@ func TestPollOnceWithGetMessagesReturnError(t *testing.T) {
 	tc.MdsMock.AssertExpectations(t)
 	assert.False(t, isMessageProcessed)
 }
+
+type pushChannelStub struct{}
+
+func (pushChannelStub) AcknowledgeMessage(log log.T, messageID string) error { return nil }
+
+func (pushChannelStub) FailMessage(log log.T, messageID string, failureType mdsService.FailureType) error {
+	return nil
+}
+
+// TestMessagePollLoopSkipsWhileMessagesArePushed tests that MDS is not polled while messages arrive over the control channel
+func TestMessagePollLoopSkipsWhileMessagesArePushed(t *testing.T) {
+	proc, tc := prepareTestPollOnce()
+	proc.name = mdsName
+
+	delivery.RegisterHandler(proc.processChannelMessage, proc.resumePolling)
+	defer delivery.RegisterHandler(nil, nil)
+	delivery.SetPushChannel(log.NewMockLog(), pushChannelStub{})
+	defer delivery.SetPushChannel(log.NewMockLog(), nil)
+
+	proc.messagePollLoop()
+
+	tc.MdsMock.AssertNotCalled(t, "GetMessages", mock.Anything, mock.Anything)
+}
+
+// TestRestartPollingReplacesTheServiceAndTheProcessor tests that the watchdog restart stops the stalled service client
+// and processor and starts new ones
+func TestRestartPollingReplacesTheServiceAndTheProcessor(t *testing.T) {
+	proc, tc := prepareTestPollOnce()
+	proc.name = mdsName
+	proc.commandWorkerLimit = 3
+	tc.MdsMock.On("Stop").Return()
+
+	stalledProcessor := new(processormock.MockedProcessor)
+	stalledProcessor.On("Stop", contracts.StopTypeHardStop).Return()
+	proc.processor = stalledProcessor
+
+	resultChan := make(chan contracts.DocumentResult)
+	newProcessor := new(processormock.MockedProcessor)
+	newProcessor.On("Start").Return(resultChan, nil)
+	newProcessor.On("InitialProcessing", true).Return(nil)
+	var commandWorkerLimit int
+	proc.newProcessor = func(limit int) processor.Processor {
+		commandWorkerLimit = limit
+		return newProcessor
+	}
+
+	newService := new(runcommandmock.MockedMDS)
+	newService.On("GetMessages", mock.Anything, mock.Anything).Return(&ssmmds.GetMessagesOutput{}, nil)
+	defer func(create func(appconfig.SsmagentConfig) mdsService.Service) { newMdsService = create }(newMdsService)
+	newMdsService = func(appconfig.SsmagentConfig) mdsService.Service { return newService }
+
+	proc.restartPolling(log.NewMockLog())
+	defer close(resultChan)
+	defer func() { proc.getMessagePollJob().Quit <- true }()
+
+	tc.MdsMock.AssertExpectations(t)
+	stalledProcessor.AssertExpectations(t)
+	newProcessor.AssertExpectations(t)
+	assert.Equal(t, 3, commandWorkerLimit)
+	assert.Equal(t, newService, proc.getService())
+	assert.Equal(t, newProcessor, proc.getProcessor())
+	assert.NotNil(t, proc.getMessagePollJob())
+}
@@ -17,6 +17,7 @@ package runcommand
 import (
 	"encoding/json"
 	"path/filepath"
+	"sync"
 	"time"
 
 	"github.com/aws/amazon-ssm-agent/agent/appconfig"
@@ -51,8 +52,6 @@ const (
 	// CancelCommandTopicPrefix is the topic prefix for a cancel command MDS message received from the offline service.
 	CancelCommandTopicPrefixOffline TopicPrefix = "aws.ssm.cancelCommand.offline."
 
-	CancelWorkersLimit = 3
-
 	// mdsname is the core module name for the MDS processor
 	mdsName = "MessagingDeliveryService"
 
@@ -97,6 +96,13 @@ type RunCommandService struct {
 	processorStopPolicy *sdkutil.StopPolicy
 	pollAssociations    bool
 	processor           processor.Processor
+	pollBackoff         pollBackoff
+	messageGuard        *messageGuard
+	repliesDone         chan bool
+	newProcessor        func(commandWorkerLimit int) processor.Processor
+	commandWorkerLimit  int
+	// restartLock guards the service client, the processor and the poll job that restartPolling replaces
+	restartLock sync.RWMutex
 }
 
 // NewOfflineProcessor initialize a new offline command document processor
@@ -119,7 +125,15 @@ func NewMDSService(context context.T) *RunCommandService {
 	mdsService := newMdsService(context.AppConfig())
 	config := context.AppConfig()
 
-	return NewService(messageContext, mdsName, mdsService, config.Mds.CommandWorkersLimit, CancelWorkersLimit, true, []contracts.DocumentType{contracts.SendCommand, contracts.CancelCommand})
+	runCommandService := NewService(messageContext, mdsName, mdsService, config.Mds.CommandWorkersLimit, config.Mds.CancelWorkersLimit, true, []contracts.DocumentType{contracts.SendCommand, contracts.CancelCommand})
+	if runCommandService != nil {
+		appconfig.RegisterConfigChangeHandler(mdsName, func(oldConfig, newConfig appconfig.SsmagentConfig) {
+			if oldConfig.Mds.CommandWorkersLimit != newConfig.Mds.CommandWorkersLimit {
+				runCommandService.setCommandWorkerLimit(newConfig.Mds.CommandWorkersLimit)
+			}
+		})
+	}
+	return runCommandService
 }
 
 // NewProcessor performs common initialization for Mds and Offline processors
@@ -152,37 +166,72 @@ func NewService(ctx context.T, serviceName string, service mdsService.Service, c
 	// create a stop policy where we will stop after 10 consecutive errors and if time period expires.
 	stopPolicy := newStopPolicy(serviceName)
 
-	// SendDocLevelResponse is used to send document level update
-	// Specify a new status of the document
-	sendDocLevelResponse := func(messageID string, resultStatus contracts.ResultStatus, documentTraceOutput string) {
-		payloadDoc := prepareReplyPayloadToUpdateDocumentStatus(agentInfo, resultStatus, documentTraceOutput)
-		processSendReply(log, messageID, service, payloadDoc, stopPolicy)
-	}
-
-	sendResponse := func(messageID string, res contracts.DocumentResult) {
-		pluginID := res.LastPlugin
-		processSendReply(log, messageID, service, FormatPayload(log, pluginID, agentInfo, res.PluginResults), stopPolicy)
-	}
-
 	var assocProc *associationProcessor.Processor
 	if pollAssoc {
 		assocProc = associationProcessor.NewAssociationProcessor(ctx)
 	}
 
-	processor := processor.NewEngineProcessor(ctx, commandWorkerLimit, cancelWorkerLimit, supportedDocs)
-	return &RunCommandService{
+	newProcessor := func(commandWorkerLimit int) processor.Processor {
+		return processor.NewEngineProcessor(ctx, commandWorkerLimit, cancelWorkerLimit, supportedDocs)
+	}
+	runCommandService := &RunCommandService{
 		context:              ctx,
 		name:                 serviceName,
 		config:               agentConfig,
 		service:              service,
-		sendDocLevelResponse: sendDocLevelResponse,
-		sendResponse:         sendResponse,
 		orchestrationRootDir: orchestrationRootDir,
 		processorStopPolicy:  stopPolicy,
 		assocProcessor:       assocProc,
 		pollAssociations:     pollAssoc,
-		processor:            processor,
+		processor:            newProcessor(commandWorkerLimit),
+		newProcessor:         newProcessor,
+		commandWorkerLimit:   commandWorkerLimit,
+		messageGuard:         &messageGuard{},
 	}
+
+	// SendDocLevelResponse is used to send document level update
+	// Specify a new status of the document
+	// the replies use the current service client, restartPolling may have replaced the one the service was created with
+	runCommandService.sendDocLevelResponse = func(messageID string, resultStatus contracts.ResultStatus, documentTraceOutput string) {
+		payloadDoc := prepareReplyPayloadToUpdateDocumentStatus(agentInfo, resultStatus, documentTraceOutput)
+		processSendReply(log, messageID, runCommandService.getService(), payloadDoc, stopPolicy)
+	}
+
+	runCommandService.sendResponse = func(messageID string, res contracts.DocumentResult) {
+		pluginID := res.LastPlugin
+		processSendReply(log, messageID, runCommandService.getService(), FormatPayload(log, pluginID, agentInfo, res.PluginResults), stopPolicy)
+	}
+	return runCommandService
+}
+
+// getService returns the current service client
+func (s *RunCommandService) getService() mdsService.Service {
+	s.restartLock.RLock()
+	defer s.restartLock.RUnlock()
+	return s.service
+}
+
+// getProcessor returns the current document processor
+func (s *RunCommandService) getProcessor() processor.Processor {
+	s.restartLock.RLock()
+	defer s.restartLock.RUnlock()
+	return s.processor
+}
+
+// setCommandWorkerLimit changes the number of documents the current processor and the processors
+// created by restartPolling run in parallel
+func (s *RunCommandService) setCommandWorkerLimit(commandWorkerLimit int) {
+	s.restartLock.Lock()
+	defer s.restartLock.Unlock()
+	s.commandWorkerLimit = commandWorkerLimit
+	s.processor.SetCommandWorkerLimit(commandWorkerLimit)
+}
+
+// getMessagePollJob returns the current message poll job
+func (s *RunCommandService) getMessagePollJob() *scheduler.Job {
+	s.restartLock.RLock()
+	defer s.restartLock.RUnlock()
+	return s.messagePollJob
 }
 
 // prepareReplyPayloadToUpdateDocumentStatus creates the payload object for SendReply based on document status change.
@@ -159,6 +159,16 @@ func parseSendCommandMessage(context context.T, msg *ssmmds.Message, messagesOrc
 	var parsedMessage messageContracts.SendCommandPayload
 	err := json.Unmarshal([]byte(*msg.Payload), &parsedMessage)
 	if err != nil {
+		// report where the document does not match the document schema when the document is the cause
+		var rawMessage struct {
+			DocumentContent json.RawMessage `json:"DocumentContent"`
+		}
+		if json.Unmarshal([]byte(*msg.Payload), &rawMessage) == nil && len(rawMessage.DocumentContent) > 0 {
+			if validationErr, ok := docparser.ValidateDocument(rawMessage.DocumentContent).(docparser.ValidationErrors); ok {
+				log.Errorf("Encountered error while parsing input - %v", validationErr)
+				return nil, validationErr
+			}
+		}
 		errorMsg := "Encountered error while parsing input - internal error"
 		log.Errorf(errorMsg)
 		return nil, fmt.Errorf("%v", errorMsg)
@@ -191,11 +201,18 @@ func parseSendCommandMessage(context context.T, msg *ssmmds.Message, messagesOrc
 	}
 
 	docContent := &docparser.DocContent{
-		SchemaVersion: parsedMessage.DocumentContent.SchemaVersion,
-		Description:   parsedMessage.DocumentContent.Description,
-		RuntimeConfig: parsedMessage.DocumentContent.RuntimeConfig,
-		MainSteps:     parsedMessage.DocumentContent.MainSteps,
-		Parameters:    parsedMessage.DocumentContent.Parameters}
+		SchemaVersion:    parsedMessage.DocumentContent.SchemaVersion,
+		Description:      parsedMessage.DocumentContent.Description,
+		RuntimeConfig:    parsedMessage.DocumentContent.RuntimeConfig,
+		MainSteps:        parsedMessage.DocumentContent.MainSteps,
+		Parameters:       parsedMessage.DocumentContent.Parameters,
+		Variables:        parsedMessage.DocumentContent.Variables,
+		ExecutionContext: parsedMessage.DocumentContent.ExecutionContext,
+		OutputS3Settings: parsedMessage.DocumentContent.OutputS3Settings,
+		Priority:         parsedMessage.DocumentContent.Priority,
+		SchedulingClass:  parsedMessage.DocumentContent.SchedulingClass,
+		MaxReboots:       parsedMessage.DocumentContent.MaxReboots,
+	}
 	//Data format persisted in Current Folder is defined by the struct - CommandState
 	docState, err := docparser.InitializeDocState(log, documentType, docContent, documentInfo, parserInfo, parsedMessage.Parameters)
 	if err != nil {
@@ -0,0 +1,135 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package s3util
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/proxyconfig"
+	"github.com/aws/amazon-ssm-agent/agent/sdkutil"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/endpoints"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+)
+
+const (
+	// bucketRegionCacheTTL is how long a detected bucket region is reused before it is looked up again
+	bucketRegionCacheTTL = time.Hour
+
+	// headBucketTimeout bounds the HeadBucket request used to detect the bucket region
+	headBucketTimeout = 30 * time.Second
+)
+
+type cachedBucketRegion struct {
+	region  string
+	expires time.Time
+}
+
+var (
+	bucketRegionCache     = map[string]cachedBucketRegion{}
+	bucketRegionCacheLock sync.Mutex
+
+	getAppConfig     = appconfig.Config
+	headBucketRegion = getBucketRegionFromHeadBucket
+)
+
+// GetBucketEndpoint returns the endpoint requests for the bucket are sent to.
+// An endpoint configured for the bucket, such as an S3 interface endpoint, takes precedence over the S3 endpoint.
+// It returns an empty string when neither is configured.
+func GetBucketEndpoint(bucketName string) string {
+	config, err := getAppConfig(false)
+	if err != nil {
+		return ""
+	}
+	if endpoint, ok := config.S3.BucketEndpoints[bucketName]; ok && endpoint != "" {
+		return endpoint
+	}
+	return config.S3.Endpoint
+}
+
+// hasBucketEndpoint returns true if an endpoint is configured for the bucket.
+// The bucket is addressed with path style on these endpoints since their host names already identify the endpoint.
+func hasBucketEndpoint(bucketName string) bool {
+	config, err := getAppConfig(false)
+	if err != nil {
+		return false
+	}
+	_, ok := config.S3.BucketEndpoints[bucketName]
+	return ok
+}
+
+// resolveS3Endpoint returns the host of the S3 endpoint the SDK endpoint resolver returns for the region
+func resolveS3Endpoint(region string) string {
+	if region == "" {
+		return ""
+	}
+	endpoint, err := endpoints.DefaultResolver().EndpointFor(s3.EndpointsID, region)
+	if err != nil {
+		return ""
+	}
+	endpointURL, err := url.Parse(endpoint.URL)
+	if err != nil {
+		return ""
+	}
+	return endpointURL.Host
+}
+
+// getCachedBucketRegion returns the region detected for the bucket if it has not expired
+func getCachedBucketRegion(bucketName string) (string, bool) {
+	bucketRegionCacheLock.Lock()
+	defer bucketRegionCacheLock.Unlock()
+	cached, ok := bucketRegionCache[bucketName]
+	if !ok || timeNow().After(cached.expires) {
+		return "", false
+	}
+	return cached.region, true
+}
+
+func cacheBucketRegion(bucketName string, region string) {
+	bucketRegionCacheLock.Lock()
+	defer bucketRegionCacheLock.Unlock()
+	bucketRegionCache[bucketName] = cachedBucketRegion{region: region, expires: timeNow().Add(bucketRegionCacheTTL)}
+}
+
+// getBucketRegionFromHeadBucket sends an anonymous HeadBucket request to the bucket endpoint, or the endpoint
+// the SDK resolves for the region hint, and returns the region from the x-amz-bucket-region response header.
+func getBucketRegionFromHeadBucket(bucketName string, endpoint string, regionHint string) (string, error) {
+	config := sdkutil.AwsConfig()
+	config.Region = aws.String(regionHint)
+	if endpoint != "" {
+		config.Endpoint = aws.String(endpoint)
+	}
+	config.HTTPClient = &http.Client{Transport: proxyconfig.NewTransport(proxyconfig.ServiceS3)}
+
+	ctx, cancel := context.WithTimeout(context.Background(), headBucketTimeout)
+	defer cancel()
+	return s3manager.GetBucketRegionWithClient(ctx, s3.New(session.New(config)), bucketName)
+}
+
+// PresignedURL returns a SigV4 presigned url to download an output object. The url is signed for the bucket region
+// and uses the same endpoint as the uploads, so it also works for buckets in other regions or behind interface endpoints.
+func (u *AmazonS3Util) PresignedURL(bucketName string, objectKey string, expiry time.Duration) (string, error) {
+	req, _ := u.client.GetObjectRequest(&s3.GetObjectInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String(objectKey),
+	})
+	return req.Presign(expiry)
+}
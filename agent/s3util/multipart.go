@@ -0,0 +1,89 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+
+package s3util
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+)
+
+// uploadPartSize returns the part size of a file upload, it is raised for files that would exceed the maximum number of parts.
+// The upload manager computes the same size so a resumed upload splits the file into the same parts.
+func uploadPartSize(fileSize int64, partSize int64) int64 {
+	if partSize < s3manager.MinUploadPartSize {
+		partSize = s3manager.MinUploadPartSize
+	}
+	if fileSize/partSize >= s3manager.MaxUploadParts {
+		partSize = fileSize/s3manager.MaxUploadParts + 1
+	}
+	return partSize
+}
+
+// resumeUpload uploads the parts missing from a multipart upload left behind by a failed attempt and completes it
+func (u *AmazonS3Util) resumeUpload(log log.T, file io.ReaderAt, fileSize int64, partSize int64, bucketName string, objectKey string, uploadID string) error {
+	uploaded := make(map[int64]*s3.Part)
+	if err := u.client.ListPartsPages(&s3.ListPartsInput{
+		Bucket:   aws.String(bucketName),
+		Key:      aws.String(objectKey),
+		UploadId: aws.String(uploadID),
+	}, func(page *s3.ListPartsOutput, lastPage bool) bool {
+		for _, part := range page.Parts {
+			uploaded[aws.Int64Value(part.PartNumber)] = part
+		}
+		return true
+	}); err != nil {
+		return fmt.Errorf("failed to list parts of upload %v: %v", uploadID, err)
+	}
+
+	var completed []*s3.CompletedPart
+	for partNumber, offset := int64(1), int64(0); offset < fileSize; partNumber, offset = partNumber+1, offset+partSize {
+		length := partSize
+		if fileSize-offset < length {
+			length = fileSize - offset
+		}
+		if part, ok := uploaded[partNumber]; ok && aws.Int64Value(part.Size) == length {
+			completed = append(completed, &s3.CompletedPart{ETag: part.ETag, PartNumber: aws.Int64(partNumber)})
+			continue
+		}
+
+		log.Debugf("Uploading part %v of upload %v", partNumber, uploadID)
+		output, err := u.client.UploadPart(&s3.UploadPartInput{
+			Bucket:        aws.String(bucketName),
+			Key:           aws.String(objectKey),
+			UploadId:      aws.String(uploadID),
+			PartNumber:    aws.Int64(partNumber),
+			Body:          io.NewSectionReader(file, offset, length),
+			ContentLength: aws.Int64(length),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to upload part %v of upload %v: %v", partNumber, uploadID, err)
+		}
+		completed = append(completed, &s3.CompletedPart{ETag: output.ETag, PartNumber: aws.Int64(partNumber)})
+	}
+	log.Infof("Resumed upload %v, %v of %v parts were already uploaded", uploadID, len(uploaded), len(completed))
+
+	_, err := u.client.CompleteMultipartUpload(&s3.CompleteMultipartUploadInput{
+		Bucket:          aws.String(bucketName),
+		Key:             aws.String(objectKey),
+		UploadId:        aws.String(uploadID),
+		MultipartUpload: &s3.CompletedMultipartUpload{Parts: completed},
+	})
+	return err
+}
@@ -67,6 +67,10 @@ func GetS3Endpoint(region string) (s3Endpoint string) {
 		return s3Endpoint
 	}
 
+	if s3Endpoint := resolveS3Endpoint(region); s3Endpoint != "" {
+		return s3Endpoint
+	}
+
 	if region, err := platform.Region(); err == nil {
 		if defaultEndpoint := platform.GetDefaultEndPoint(region, "s3"); defaultEndpoint != "" {
 			return defaultEndpoint
@@ -17,23 +17,31 @@ package s3util
 import (
 	"errors"
 	"fmt"
+	"io"
 	"math"
+	"net/http"
 	"os"
 	"time"
 
 	"github.com/aws/amazon-ssm-agent/agent/appconfig"
 	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/network/clockskew"
 	"github.com/aws/amazon-ssm-agent/agent/platform"
+	"github.com/aws/amazon-ssm-agent/agent/proxyconfig"
 	"github.com/aws/amazon-ssm-agent/agent/sdkutil"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
 	"github.com/aws/aws-sdk-go/service/s3/s3manager"
 )
 
 const (
 	s3ResponseRegionHeader = "x-amz-bucket-region"
+
+	// expectedBucketOwnerHeader makes S3 reject requests to a bucket owned by another account
+	expectedBucketOwnerHeader = "x-amz-expected-bucket-owner"
 )
 
 var getRegion = platform.Region
@@ -45,42 +53,106 @@ type IAmazonS3Util interface {
 
 type AmazonS3Util struct {
 	myUploader *s3manager.Uploader
+	client     s3iface.S3API
+	partSize   int64
+	settings   ObjectSettings
+}
+
+// ObjectSettings are the server side encryption, expected bucket owner and acl of the objects written to S3.
+// Empty fields use the S3 settings of appconfig.
+type ObjectSettings struct {
+	ServerSideEncryption string
+	SSEKMSKeyId          string
+	ExpectedBucketOwner  string
+	ObjectACL            string
+}
+
+// merge returns the settings with empty fields taken from the defaults
+func (settings ObjectSettings) merge(defaults ObjectSettings) ObjectSettings {
+	if settings.ServerSideEncryption == "" && settings.SSEKMSKeyId == "" {
+		settings.ServerSideEncryption, settings.SSEKMSKeyId = defaults.ServerSideEncryption, defaults.SSEKMSKeyId
+	} else if settings.ServerSideEncryption == "" {
+		settings.ServerSideEncryption = s3.ServerSideEncryptionAwsKms
+	}
+	if settings.ExpectedBucketOwner == "" {
+		settings.ExpectedBucketOwner = defaults.ExpectedBucketOwner
+	}
+	if settings.ObjectACL == "" {
+		settings.ObjectACL = defaults.ObjectACL
+	}
+	if settings.ObjectACL == "" {
+		settings.ObjectACL = appconfig.DefaultS3ObjectACL
+	}
+	return settings
 }
 
 func NewAmazonS3Util(log log.T, bucketName string) *AmazonS3Util {
+	return NewAmazonS3UtilWithSettings(log, bucketName, ObjectSettings{})
+}
+
+// NewAmazonS3UtilWithSettings returns an S3 util that writes objects with the settings of a document,
+// fields the document does not set use the S3 settings of appconfig
+func NewAmazonS3UtilWithSettings(log log.T, bucketName string, settings ObjectSettings) *AmazonS3Util {
 
 	httpProvider := HttpProviderImpl{}
 	bucketRegion := GetBucketRegion(log, bucketName, httpProvider)
 
 	config := sdkutil.AwsConfig()
 	var appConfig appconfig.SsmagentConfig
-	appConfig, errConfig := appconfig.Config(false)
+	appConfig, errConfig := getAppConfig(false)
 	if errConfig != nil {
 		log.Error("failed to read appconfig.")
-	} else {
-		if appConfig.S3.Endpoint != "" {
-			config.Endpoint = &appConfig.S3.Endpoint
-		} else {
-			if region, err := platform.Region(); err == nil {
-				if defaultEndpoint := platform.GetDefaultEndPoint(region, "s3"); defaultEndpoint != "" {
-					config.Endpoint = &defaultEndpoint
-				}
-			} else {
-				log.Errorf("error fetching the region, %v", err)
-			}
+	} else if endpoint := GetBucketEndpoint(bucketName); endpoint != "" {
+		config.Endpoint = &endpoint
+		if hasBucketEndpoint(bucketName) {
+			config.S3ForcePathStyle = aws.Bool(true)
 		}
+	} else if defaultEndpoint := platform.GetDefaultEndPoint(bucketRegion, "s3"); defaultEndpoint != "" {
+		// the endpoint has to be in the bucket region, otherwise the SDK endpoint resolver picks it from the region
+		config.Endpoint = &defaultEndpoint
 	}
 	config.Region = &bucketRegion
+	config.HTTPClient = &http.Client{Transport: newThrottledTransport(
+		proxyconfig.NewTransport(proxyconfig.ServiceS3),
+		int64(appConfig.S3.UploadBandwidthKBps)*1024)}
+
+	settings = settings.merge(ObjectSettings{
+		ServerSideEncryption: appConfig.S3.ServerSideEncryption,
+		SSEKMSKeyId:          appConfig.S3.SSEKMSKeyId,
+		ExpectedBucketOwner:  appConfig.S3.ExpectedBucketOwner,
+		ObjectACL:            appConfig.S3.ObjectACL,
+	})
 
 	sess := session.New(config)
 	sess.Handlers.Build.PushBack(request.MakeAddToUserAgentHandler(appConfig.Agent.Name, appConfig.Agent.Version))
+	if settings.ExpectedBucketOwner != "" {
+		sess.Handlers.Build.PushBack(expectedBucketOwnerHandler(settings.ExpectedBucketOwner))
+	}
 
+	partSizeMB := appConfig.S3.UploadPartSizeMB
+	if partSizeMB == 0 {
+		partSizeMB = appconfig.DefaultS3UploadPartSizeMB
+	}
+	client := s3.New(sess)
+	clockskew.AddHandlers(&client.Handlers)
 	return &AmazonS3Util{
-		myUploader: s3manager.NewUploader(sess),
+		myUploader: s3manager.NewUploaderWithClient(client),
+		client:     client,
+		partSize:   int64(partSizeMB) * 1024 * 1024,
+		settings:   settings,
+	}
+}
+
+// expectedBucketOwnerHandler adds the expected bucket owner to every request, so the agent never writes
+// to or reads from a bucket that changed hands
+func expectedBucketOwnerHandler(owner string) func(*request.Request) {
+	return func(r *request.Request) {
+		r.HTTPRequest.Header.Set(expectedBucketOwnerHeader, owner)
 	}
 }
 
 // S3Upload uploads a file to s3.
+// Files larger than the part size are uploaded in parts, a failed attempt resumes the upload with the parts that are missing.
 func (u *AmazonS3Util) S3Upload(log log.T, bucketName string, objectKey string, filePath string) (err error) {
 	file, err := os.Open(filePath)
 	if err != nil {
@@ -89,32 +161,74 @@ func (u *AmazonS3Util) S3Upload(log log.T, bucketName string, objectKey string,
 	}
 	defer file.Close()
 
+	fileInfo, err := file.Stat()
+	if err != nil {
+		log.Errorf("Failed to stat file %v", err)
+		return err
+	}
+	partSize := uploadPartSize(fileInfo.Size(), u.partSize)
+
 	log.Infof("Uploading %v to s3://%v/%v", filePath, bucketName, objectKey)
 	params := &s3manager.UploadInput{
 		Bucket:      aws.String(bucketName),
 		Key:         aws.String(objectKey),
 		Body:        file,
 		ContentType: aws.String("text/plain"),
-		ACL:         aws.String("bucket-owner-full-control"),
+		ACL:         aws.String(u.settings.ObjectACL),
+	}
+	if u.settings.ServerSideEncryption != "" {
+		params.ServerSideEncryption = aws.String(u.settings.ServerSideEncryption)
+	}
+	if u.settings.SSEKMSKeyId != "" {
+		params.SSEKMSKeyId = aws.String(u.settings.SSEKMSKeyId)
 	}
 
+	var uploadID string
 	for attempt := 1; attempt <= 4; attempt++ {
-		var result *s3manager.UploadOutput
-		if result, err = u.myUploader.Upload(params); err == nil {
-			log.Infof("Successfully uploaded file to ", result.Location)
-			break
-		} else {
-			log.Errorf("Attempt %s: Failed uploading %v to s3://%v/%v err:%v ", attempt, filePath, bucketName, objectKey, err)
-			time.Sleep(time.Duration(math.Pow(2, float64(attempt))*100) * time.Millisecond)
+		if uploadID == "" {
+			var result *s3manager.UploadOutput
+			if result, err = u.myUploader.Upload(params, func(uploader *s3manager.Uploader) {
+				uploader.PartSize = partSize
+				uploader.LeavePartsOnError = true
+			}); err == nil {
+				log.Infof("Successfully uploaded file to %v", result.Location)
+				return nil
+			}
+			if multiUploadErr, ok := err.(s3manager.MultiUploadFailure); ok {
+				uploadID = multiUploadErr.UploadID()
+			}
+		} else if err = u.resumeUpload(log, file, fileInfo.Size(), partSize, bucketName, objectKey, uploadID); err == nil {
+			log.Infof("Successfully resumed upload of %v to s3://%v/%v", filePath, bucketName, objectKey)
+			return nil
 		}
+
+		log.Errorf("Attempt %v: Failed uploading %v to s3://%v/%v err:%v ", attempt, filePath, bucketName, objectKey, err)
+		if _, seekErr := file.Seek(0, io.SeekStart); seekErr != nil {
+			return seekErr
+		}
+		time.Sleep(time.Duration(math.Pow(2, float64(attempt))*100) * time.Millisecond)
 	}
 
+	if uploadID != "" {
+		if _, abortErr := u.client.AbortMultipartUpload(&s3.AbortMultipartUploadInput{
+			Bucket:   aws.String(bucketName),
+			Key:      aws.String(objectKey),
+			UploadId: aws.String(uploadID),
+		}); abortErr != nil {
+			log.Warnf("Failed to abort multipart upload %v: %v", uploadID, abortErr)
+		}
+	}
 	return err
 }
 
 // This function returns the Amazon S3 Bucket region based on its name and the EC2 instance region.
-// It will return the same instance region if it failed to guess the bucket region.
+// The region is detected with a HeadBucket request to the bucket endpoint and cached, the S3 url headers are used
+// when HeadBucket fails. It will return the same instance region if it failed to guess the bucket region.
 func GetBucketRegion(log log.T, bucketName string, httpProvider HttpProvider) (region string) {
+	if bucketRegion, ok := getCachedBucketRegion(bucketName); ok {
+		return bucketRegion
+	}
+
 	instanceRegion, err := getRegion()
 	if err != nil {
 		log.Error("Cannot get the current instance region information")
@@ -122,12 +236,16 @@ func GetBucketRegion(log log.T, bucketName string, httpProvider HttpProvider) (r
 	}
 	log.Infof("Instance region is %v", instanceRegion)
 
-	bucketRegion := GetS3Header(log, bucketName, instanceRegion, httpProvider)
+	bucketRegion, err := headBucketRegion(bucketName, GetBucketEndpoint(bucketName), instanceRegion)
+	if err != nil || bucketRegion == "" {
+		log.Infof("Failed to get S3 bucket region with HeadBucket, error details: %v", err)
+		bucketRegion = GetS3Header(log, bucketName, instanceRegion, httpProvider)
+	}
 	if bucketRegion == "" {
 		return instanceRegion // Default
-	} else {
-		return bucketRegion
 	}
+	cacheBucketRegion(bucketName, bucketRegion)
+	return bucketRegion
 }
 
 //IsBucketEncrypted checks if the bucket is encrypted
@@ -16,6 +16,8 @@ package s3util
 
 import (
 	"net/http"
+
+	"github.com/aws/amazon-ssm-agent/agent/proxyconfig"
 )
 
 type HttpProvider interface {
@@ -26,5 +28,6 @@ type HttpProvider interface {
 type HttpProviderImpl struct{}
 
 func (HttpProviderImpl) Head(url string) (*http.Response, error) {
-	return http.Head(url)
+	client := &http.Client{Transport: proxyconfig.NewTransport(proxyconfig.ServiceS3)}
+	return client.Head(url)
 }
@@ -1,12 +1,25 @@
 package s3util
 
 import (
+	"bytes"
+	"io/ioutil"
 	"net/http"
+	"net/url"
+	"strings"
 	"testing"
+	"time"
 
 	"errors"
 
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
 	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/platform"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 )
@@ -172,3 +185,188 @@ func (m *MockedHttpProvider) Head(url string) (*http.Response, error) {
 	args := m.Called(url)
 	return args.Get(0).(*http.Response), args.Error(1)
 }
+
+// fakeS3Client keeps the parts of one multipart upload
+type fakeS3Client struct {
+	s3iface.S3API
+	parts     []*s3.Part
+	uploaded  []int64
+	completed []*s3.CompletedPart
+}
+
+func (c *fakeS3Client) ListPartsPages(input *s3.ListPartsInput, fn func(*s3.ListPartsOutput, bool) bool) error {
+	fn(&s3.ListPartsOutput{Parts: c.parts}, true)
+	return nil
+}
+
+func (c *fakeS3Client) UploadPart(input *s3.UploadPartInput) (*s3.UploadPartOutput, error) {
+	body, _ := ioutil.ReadAll(input.Body)
+	if int64(len(body)) != aws.Int64Value(input.ContentLength) {
+		return nil, errors.New("content length does not match body")
+	}
+	c.uploaded = append(c.uploaded, aws.Int64Value(input.PartNumber))
+	return &s3.UploadPartOutput{ETag: aws.String("new")}, nil
+}
+
+func (c *fakeS3Client) CompleteMultipartUpload(input *s3.CompleteMultipartUploadInput) (*s3.CompleteMultipartUploadOutput, error) {
+	c.completed = input.MultipartUpload.Parts
+	return &s3.CompleteMultipartUploadOutput{}, nil
+}
+
+func TestUploadPartSize(t *testing.T) {
+	assert.Equal(t, s3manager.MinUploadPartSize, uploadPartSize(100, 1024))
+	assert.Equal(t, int64(8*1024*1024), uploadPartSize(100*1024*1024, 8*1024*1024))
+	assert.Equal(t, int64(100*1024*1024*1024/s3manager.MaxUploadParts+1), uploadPartSize(100*1024*1024*1024, 8*1024*1024))
+}
+
+func TestResumeUploadSendsMissingParts(t *testing.T) {
+	partSize := s3manager.MinUploadPartSize
+	content := bytes.Repeat([]byte("a"), int(partSize*2+10))
+	client := &fakeS3Client{parts: []*s3.Part{
+		{PartNumber: aws.Int64(1), Size: aws.Int64(partSize), ETag: aws.String("first")},
+		// a part with the wrong size is uploaded again
+		{PartNumber: aws.Int64(2), Size: aws.Int64(10), ETag: aws.String("truncated")},
+	}}
+	util := &AmazonS3Util{client: client, partSize: partSize}
+
+	err := util.resumeUpload(log.NewMockLog(), bytes.NewReader(content), int64(len(content)), partSize, "bucket", "key", "upload")
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{2, 3}, client.uploaded)
+	assert.Equal(t, []*s3.CompletedPart{
+		{ETag: aws.String("first"), PartNumber: aws.Int64(1)},
+		{ETag: aws.String("new"), PartNumber: aws.Int64(2)},
+		{ETag: aws.String("new"), PartNumber: aws.Int64(3)},
+	}, client.completed)
+}
+
+func TestThrottledBodyLimitsBandwidth(t *testing.T) {
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	var slept time.Duration
+	timeNow = func() time.Time { return now }
+	sleep = func(d time.Duration) { slept += d; now = now.Add(d) }
+	defer func() { timeNow, sleep = time.Now, time.Sleep }()
+
+	limiter := &bandwidthLimiter{bytesPerSecond: 10 * 1024}
+	body := &throttledBody{ReadCloser: ioutil.NopCloser(strings.NewReader(strings.Repeat("a", 30*1024))), limiter: limiter}
+	data, err := ioutil.ReadAll(body)
+	assert.NoError(t, err)
+	assert.Len(t, data, 30*1024)
+	// the last chunk is reserved without waiting for it to be sent
+	assert.InDelta(t, float64(2900*time.Millisecond), float64(slept), float64(100*time.Millisecond))
+}
+
+func TestNewThrottledTransportWithoutLimit(t *testing.T) {
+	assert.Equal(t, http.DefaultTransport, newThrottledTransport(http.DefaultTransport, 0))
+	assert.IsType(t, &throttledTransport{}, newThrottledTransport(http.DefaultTransport, 1024))
+}
+
+func TestGetBucketEndpointPrefersBucketOverride(t *testing.T) {
+	getAppConfig = func(bool) (appconfig.SsmagentConfig, error) {
+		config := appconfig.DefaultConfig()
+		config.S3.Endpoint = "s3.example.com"
+		config.S3.BucketEndpoints = map[string]string{"vpce-bucket": "https://bucket.vpce-0123456789abcdef0.s3.us-west-2.vpce.amazonaws.com"}
+		return config, nil
+	}
+	defer func() { getAppConfig = appconfig.Config }()
+
+	assert.Equal(t, "https://bucket.vpce-0123456789abcdef0.s3.us-west-2.vpce.amazonaws.com", GetBucketEndpoint("vpce-bucket"))
+	assert.True(t, hasBucketEndpoint("vpce-bucket"))
+	assert.Equal(t, "s3.example.com", GetBucketEndpoint("other-bucket"))
+	assert.False(t, hasBucketEndpoint("other-bucket"))
+}
+
+func TestResolveS3Endpoint(t *testing.T) {
+	assert.Equal(t, "s3.eu-west-1.amazonaws.com", resolveS3Endpoint("eu-west-1"))
+	assert.Equal(t, "s3.cn-north-1.amazonaws.com.cn", resolveS3Endpoint("cn-north-1"))
+	assert.Equal(t, "", resolveS3Endpoint(""))
+}
+
+func TestGetBucketRegionCachesHeadBucketRegion(t *testing.T) {
+	getRegion = func() (string, error) { return "us-east-1", nil }
+	headBucketCalls := 0
+	headBucketRegion = func(bucketName string, endpoint string, regionHint string) (string, error) {
+		headBucketCalls++
+		assert.Equal(t, "us-east-1", regionHint)
+		return "eu-west-1", nil
+	}
+	defer func() {
+		getRegion, headBucketRegion = platform.Region, getBucketRegionFromHeadBucket
+		bucketRegionCache = map[string]cachedBucketRegion{}
+	}()
+
+	mockHttpProvider := MockedHttpProvider{}
+	assert.Equal(t, "eu-west-1", GetBucketRegion(log.NewMockLog(), "cached-bucket", &mockHttpProvider))
+	assert.Equal(t, "eu-west-1", GetBucketRegion(log.NewMockLog(), "cached-bucket", &mockHttpProvider))
+	assert.Equal(t, 1, headBucketCalls)
+	mockHttpProvider.AssertNotCalled(t, "Head", mock.Anything)
+}
+
+func TestGetBucketRegionExpiresCachedRegion(t *testing.T) {
+	now := time.Now()
+	timeNow = func() time.Time { return now }
+	cacheBucketRegion("expired-bucket", "eu-west-1")
+	defer func() {
+		timeNow = time.Now
+		bucketRegionCache = map[string]cachedBucketRegion{}
+	}()
+
+	region, ok := getCachedBucketRegion("expired-bucket")
+	assert.True(t, ok)
+	assert.Equal(t, "eu-west-1", region)
+
+	now = now.Add(bucketRegionCacheTTL + time.Second)
+	_, ok = getCachedBucketRegion("expired-bucket")
+	assert.False(t, ok)
+}
+
+func TestPresignedURLIsSignedForBucketRegion(t *testing.T) {
+	sess := session.New(&aws.Config{
+		Region:      aws.String("eu-west-1"),
+		Credentials: credentials.NewStaticCredentials("AKID", "SECRET", ""),
+	})
+	util := &AmazonS3Util{client: s3.New(sess)}
+
+	presignedURL, err := util.PresignedURL("output-bucket", "prefix/stdout", 15*time.Minute)
+	assert.Nil(t, err)
+
+	parsedURL, err := url.Parse(presignedURL)
+	assert.Nil(t, err)
+	assert.Equal(t, "output-bucket.s3.eu-west-1.amazonaws.com", parsedURL.Host)
+	assert.Equal(t, "/prefix/stdout", parsedURL.Path)
+	assert.Equal(t, "900", parsedURL.Query().Get("X-Amz-Expires"))
+	assert.Contains(t, parsedURL.Query().Get("X-Amz-Credential"), "/eu-west-1/s3/aws4_request")
+}
+
+func TestObjectSettingsMergeWithAppConfig(t *testing.T) {
+	defaults := ObjectSettings{
+		ServerSideEncryption: s3.ServerSideEncryptionAwsKms,
+		SSEKMSKeyId:          "alias/agent",
+		ExpectedBucketOwner:  "123456789012",
+	}
+
+	settings := ObjectSettings{}.merge(defaults)
+	assert.Equal(t, "alias/agent", settings.SSEKMSKeyId)
+	assert.Equal(t, "123456789012", settings.ExpectedBucketOwner)
+	assert.Equal(t, appconfig.DefaultS3ObjectACL, settings.ObjectACL)
+
+	settings = ObjectSettings{SSEKMSKeyId: "alias/document", ObjectACL: "private"}.merge(defaults)
+	assert.Equal(t, s3.ServerSideEncryptionAwsKms, settings.ServerSideEncryption)
+	assert.Equal(t, "alias/document", settings.SSEKMSKeyId)
+	assert.Equal(t, "private", settings.ObjectACL)
+
+	settings = ObjectSettings{ServerSideEncryption: s3.ServerSideEncryptionAes256}.merge(defaults)
+	assert.Equal(t, "", settings.SSEKMSKeyId)
+}
+
+func TestExpectedBucketOwnerHandlerSignsHeader(t *testing.T) {
+	sess := session.New(&aws.Config{
+		Region:      aws.String("us-east-1"),
+		Credentials: credentials.NewStaticCredentials("AKID", "SECRET", ""),
+	})
+	sess.Handlers.Build.PushBack(expectedBucketOwnerHandler("123456789012"))
+
+	req, _ := s3.New(sess).PutObjectRequest(&s3.PutObjectInput{Bucket: aws.String("bucket"), Key: aws.String("key")})
+	assert.Nil(t, req.Sign())
+	assert.Equal(t, "123456789012", req.HTTPRequest.Header.Get(expectedBucketOwnerHeader))
+	assert.Contains(t, req.HTTPRequest.Header.Get("Authorization"), expectedBucketOwnerHeader)
+}
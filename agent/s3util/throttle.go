@@ -0,0 +1,101 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+
+package s3util
+
+import (
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// minThrottleChunk is the smallest amount of data read at once from a throttled request body
+const minThrottleChunk = 1024
+
+var (
+	timeNow = time.Now
+	sleep   = time.Sleep
+)
+
+// throttledTransport limits the rate request bodies are sent at, it only applies while sending
+// so reading the body to sign the request is not throttled
+type throttledTransport struct {
+	base    http.RoundTripper
+	limiter *bandwidthLimiter
+}
+
+// newThrottledTransport returns the base transport if bytesPerSecond is zero
+func newThrottledTransport(base http.RoundTripper, bytesPerSecond int64) http.RoundTripper {
+	if bytesPerSecond <= 0 {
+		return base
+	}
+	return &throttledTransport{base: base, limiter: &bandwidthLimiter{bytesPerSecond: bytesPerSecond}}
+}
+
+func (t *throttledTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return t.base.RoundTrip(req)
+	}
+	throttled := req.WithContext(req.Context())
+	throttled.Body = &throttledBody{ReadCloser: req.Body, limiter: t.limiter}
+	return t.base.RoundTrip(throttled)
+}
+
+// bandwidthLimiter is shared by all uploads of a transport so concurrent parts together stay below the limit
+type bandwidthLimiter struct {
+	bytesPerSecond int64
+	mutex          sync.Mutex
+	next           time.Time
+}
+
+// chunk returns the amount of data read at once, a tenth of a second of bandwidth
+func (l *bandwidthLimiter) chunk() int {
+	if chunk := int(l.bytesPerSecond / 10); chunk > minThrottleChunk {
+		return chunk
+	}
+	return minThrottleChunk
+}
+
+// wait reserves bandwidth to send n bytes and blocks until they may be sent
+func (l *bandwidthLimiter) wait(n int) {
+	l.mutex.Lock()
+	now := timeNow()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	delay := l.next.Sub(now)
+	l.next = l.next.Add(time.Duration(int64(n) * int64(time.Second) / l.bytesPerSecond))
+	l.mutex.Unlock()
+
+	if delay > 0 {
+		sleep(delay)
+	}
+}
+
+type throttledBody struct {
+	io.ReadCloser
+	limiter *bandwidthLimiter
+}
+
+func (b *throttledBody) Read(p []byte) (int, error) {
+	if chunk := b.limiter.chunk(); len(p) > chunk {
+		p = p[:chunk]
+	}
+	n, err := b.ReadCloser.Read(p)
+	if n > 0 {
+		b.limiter.wait(n)
+	}
+	return n, err
+}
@@ -0,0 +1,90 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package sandbox
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/sys/unix"
+)
+
+// privateTmpDirs are the directories the private temporary directory of the document is mounted over
+var privateTmpDirs = []string{"/tmp", "/var/tmp"}
+
+// isolate moves the calling thread to a private mount namespace, mounts the private temporary directory over the
+// temporary directories and the paths of the document in the chroot. The mounts do not propagate to the host.
+func isolate(profile Profile) error {
+	if profile.PrivateTmp == "" && profile.Chroot == "" {
+		return nil
+	}
+	if err := unix.Unshare(unix.CLONE_NEWNS); err != nil {
+		if profile.Chroot == "" && (err == unix.EPERM || err == unix.EINVAL) {
+			return &unsupportedError{reason: fmt.Sprintf("failed to create a private mount namespace: %v", err)}
+		}
+		return fmt.Errorf("failed to create a private mount namespace: %v", err)
+	}
+	if err := unix.Mount("", "/", "", unix.MS_REC|unix.MS_PRIVATE, ""); err != nil {
+		return fmt.Errorf("failed to make the mounts private: %v", err)
+	}
+
+	root := "/"
+	if profile.Chroot != "" {
+		root = profile.Chroot
+		for _, path := range profile.ChrootPaths {
+			target := filepath.Join(root, path)
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return fmt.Errorf("failed to create %v in the chroot: %v", path, err)
+			}
+			if err := unix.Mount(path, target, "", unix.MS_BIND|unix.MS_REC, ""); err != nil {
+				return fmt.Errorf("failed to mount %v in the chroot: %v", path, err)
+			}
+		}
+	}
+	if profile.PrivateTmp != "" {
+		// the directory may be hidden by the first mount, the next ones are mounted from the first
+		source := profile.PrivateTmp
+		for _, dir := range privateTmpDirs {
+			target := filepath.Join(root, dir)
+			if _, err := os.Stat(target); err != nil {
+				continue
+			}
+			if err := unix.Mount(source, target, "", unix.MS_BIND, ""); err != nil {
+				return fmt.Errorf("failed to mount the private temporary directory over %v: %v", dir, err)
+			}
+			source = target
+		}
+	}
+	return nil
+}
+
+// changeRoot changes the root directory of the calling thread to the chroot of the profile, the working directory
+// is kept when the chroot has it
+func changeRoot(profile Profile) error {
+	if profile.Chroot == "" {
+		return nil
+	}
+	workingDir, err := os.Getwd()
+	if err != nil {
+		workingDir = "/"
+	}
+	if err = unix.Chroot(profile.Chroot); err != nil {
+		return fmt.Errorf("failed to change the root directory to %v: %v", profile.Chroot, err)
+	}
+	if err = unix.Chdir(workingDir); err != nil {
+		return unix.Chdir("/")
+	}
+	return nil
+}
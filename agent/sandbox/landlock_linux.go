@@ -0,0 +1,153 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package sandbox
+
+import (
+	"fmt"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// Landlock system calls have the same numbers on every architecture
+const (
+	sysLandlockCreateRuleset = 444
+	sysLandlockAddRule       = 445
+	sysLandlockRestrictSelf  = 446
+
+	landlockCreateRulesetVersion = 1 << 0
+	landlockRulePathBeneath      = 1
+)
+
+// Landlock filesystem access rights, Refer was added by ABI 2 and Truncate by ABI 3
+const (
+	accessFsExecute = 1 << iota
+	accessFsWriteFile
+	accessFsReadFile
+	accessFsReadDir
+	accessFsRemoveDir
+	accessFsRemoveFile
+	accessFsMakeChar
+	accessFsMakeDir
+	accessFsMakeReg
+	accessFsMakeSock
+	accessFsMakeFifo
+	accessFsMakeBlock
+	accessFsMakeSym
+	accessFsRefer
+	accessFsTruncate
+
+	// accessFsABI1 are the rights of the first Landlock ABI
+	accessFsABI1 = accessFsMakeSym<<1 - 1
+
+	// accessFsRead are the rights of read only paths
+	accessFsRead = accessFsExecute | accessFsReadFile | accessFsReadDir
+
+	// accessFsFile are the rights that apply to files, the other rights only apply to directories
+	accessFsFile = accessFsExecute | accessFsWriteFile | accessFsReadFile | accessFsTruncate
+)
+
+// rulesetAttr is struct landlock_ruleset_attr
+type rulesetAttr struct {
+	handledAccessFs uint64
+}
+
+// pathBeneathAttr is the packed struct landlock_path_beneath_attr, its fields have the same offsets without packing
+type pathBeneathAttr struct {
+	allowedAccess uint64
+	parentFd      int32
+}
+
+// landlockABI returns the Landlock ABI version of the kernel
+func landlockABI() (int, error) {
+	abi, _, errno := unix.Syscall(sysLandlockCreateRuleset, 0, 0, landlockCreateRulesetVersion)
+	switch errno {
+	case 0:
+		return int(abi), nil
+	case unix.ENOSYS:
+		return 0, &unsupportedError{reason: "the kernel does not support Landlock"}
+	case unix.EOPNOTSUPP:
+		return 0, &unsupportedError{reason: "Landlock is disabled in the kernel"}
+	}
+	return 0, fmt.Errorf("failed to get the Landlock ABI version: %v", errno)
+}
+
+// handledAccess returns the rights of the ABI, the rights that are not granted to any path are denied
+func handledAccess(abi int) uint64 {
+	handled := uint64(accessFsABI1)
+	if abi >= 2 {
+		handled |= accessFsRefer
+	}
+	if abi >= 3 {
+		handled |= accessFsTruncate
+	}
+	return handled
+}
+
+// restrictFilesystem denies the calling thread any filesystem access outside the paths
+func restrictFilesystem(readOnlyPaths []string, readWritePaths []string) error {
+	abi, err := landlockABI()
+	if err != nil {
+		return err
+	}
+	handled := handledAccess(abi)
+
+	attr := rulesetAttr{handledAccessFs: handled}
+	rulesetFd, _, errno := unix.Syscall(sysLandlockCreateRuleset, uintptr(unsafe.Pointer(&attr)), unsafe.Sizeof(attr), 0)
+	if errno != 0 {
+		return fmt.Errorf("failed to create Landlock ruleset: %v", errno)
+	}
+	defer unix.Close(int(rulesetFd))
+
+	for _, path := range readOnlyPaths {
+		if err = addPathRule(int(rulesetFd), path, accessFsRead&handled); err != nil {
+			return err
+		}
+	}
+	for _, path := range readWritePaths {
+		if err = addPathRule(int(rulesetFd), path, handled); err != nil {
+			return err
+		}
+	}
+
+	if _, _, errno = unix.Syscall(sysLandlockRestrictSelf, rulesetFd, 0, 0); errno != 0 {
+		return fmt.Errorf("failed to restrict filesystem access: %v", errno)
+	}
+	return nil
+}
+
+// addPathRule grants access beneath the path, paths that do not exist are skipped
+func addPathRule(rulesetFd int, path string, access uint64) error {
+	fd, err := unix.Open(path, unix.O_PATH|unix.O_CLOEXEC, 0)
+	if err == unix.ENOENT {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("failed to open %v: %v", path, err)
+	}
+	defer unix.Close(fd)
+
+	var stat unix.Stat_t
+	if err = unix.Fstat(fd, &stat); err != nil {
+		return fmt.Errorf("failed to stat %v: %v", path, err)
+	}
+	if stat.Mode&unix.S_IFMT != unix.S_IFDIR {
+		access &= accessFsFile
+	}
+
+	attr := pathBeneathAttr{allowedAccess: access, parentFd: int32(fd)}
+	if _, _, errno := unix.Syscall6(sysLandlockAddRule, uintptr(rulesetFd), landlockRulePathBeneath, uintptr(unsafe.Pointer(&attr)), 0, 0, 0); errno != 0 {
+		return fmt.Errorf("failed to add Landlock rule for %v: %v", path, errno)
+	}
+	return nil
+}
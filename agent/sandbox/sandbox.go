@@ -0,0 +1,163 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package sandbox runs the children of aws:runShellScript under a seccomp filter and Landlock filesystem rules,
+// with a private /tmp in their own mount namespace and in a chroot when the agent config isolates the documents.
+// Go cannot run code between fork and exec, so the child is started through the document worker, which applies
+// the sandbox to its own thread and then replaces itself with the child command. Kernels without seccomp
+// filters or Landlock run the child without them.
+package sandbox
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+)
+
+const (
+	// ExecArgument is the first argument of the document worker when it starts a sandboxed child
+	ExecArgument = "sandbox-exec"
+
+	// argumentSeparator ends the sandbox arguments, the child command follows it
+	argumentSeparator = "--"
+
+	// exitCodeFailed is the exit code of the child when the sandbox could not be applied, as the shell does
+	// for commands that cannot be executed
+	exitCodeFailed = 126
+)
+
+var (
+	getAppConfig = appconfig.Config
+
+	// helperPath is the executable that applies the sandbox before it starts the child
+	helperPath = func() string { return appconfig.DefaultDocumentWorker }
+)
+
+// Profile is the sandbox of a child. SeccompProfile is the path of a seccomp profile in the OCI format,
+// the child can only access ReadOnlyPaths and ReadWritePaths when either is set.
+// Disabled is only set by steps, to run their children without the sandbox of the agent config.
+// PrivateTmp is the directory mounted over /tmp and /var/tmp, and Chroot the root directory of the child, in which
+// ChrootPaths are mounted at the same location. They are only set from the isolation of the agent config.
+type Profile struct {
+	Disabled       bool     `json:",omitempty"`
+	SeccompProfile string   `json:",omitempty"`
+	ReadOnlyPaths  []string `json:",omitempty"`
+	ReadWritePaths []string `json:",omitempty"`
+	PrivateTmp     string   `json:",omitempty"`
+	Chroot         string   `json:",omitempty"`
+	ChrootPaths    []string `json:",omitempty"`
+}
+
+// RestrictsFilesystem returns whether Landlock rules are applied to the child
+func (p Profile) RestrictsFilesystem() bool {
+	return len(p.ReadOnlyPaths) > 0 || len(p.ReadWritePaths) > 0
+}
+
+// AllowWrite adds the paths the child needs to write to, such as its working directory, when the filesystem is restricted
+func (p *Profile) AllowWrite(paths ...string) {
+	if !p.RestrictsFilesystem() {
+		return
+	}
+	for _, path := range paths {
+		if path != "" {
+			p.ReadWritePaths = append(p.ReadWritePaths, path)
+		}
+	}
+}
+
+// Isolate adds the isolation of the agent config to the profile, tmpDir is the temporary directory of the document
+// and paths the ones the child needs in the chroot, such as its working directory. It returns false when the child
+// is not isolated.
+func (p *Profile) Isolate(tmpDir string, paths ...string) bool {
+	if !supported {
+		return false
+	}
+	config, err := getAppConfig(false)
+	if err != nil {
+		return false
+	}
+	if config.Isolation.PrivateTmp && tmpDir != "" {
+		p.PrivateTmp = tmpDir
+		p.AllowWrite(tmpDir)
+	}
+	if p.Chroot = config.Isolation.Chroot; p.Chroot != "" {
+		for _, path := range paths {
+			if path != "" {
+				p.ChrootPaths = append(p.ChrootPaths, path)
+			}
+		}
+	}
+	return p.PrivateTmp != "" || p.Chroot != ""
+}
+
+// Resolve returns the sandbox of a child, the fields set by the step override the agent config.
+// It returns an empty profile and false when the child runs without a sandbox.
+func Resolve(stepProfile *Profile) (profile Profile, enabled bool) {
+	if !supported {
+		return Profile{}, false
+	}
+	config, err := getAppConfig(false)
+	if err == nil {
+		enabled = config.ScriptSandbox.Enabled
+		profile = Profile{
+			SeccompProfile: config.ScriptSandbox.SeccompProfile,
+			ReadOnlyPaths:  config.ScriptSandbox.LandlockReadOnlyPaths,
+			ReadWritePaths: config.ScriptSandbox.LandlockReadWritePaths,
+		}
+	}
+	if stepProfile != nil && (err != nil || !config.ScriptSandbox.DenyDocumentOverride) {
+		if stepProfile.Disabled {
+			return Profile{}, false
+		}
+		enabled = true
+		if stepProfile.SeccompProfile != "" {
+			profile.SeccompProfile = stepProfile.SeccompProfile
+		}
+		if stepProfile.ReadOnlyPaths != nil {
+			profile.ReadOnlyPaths = stepProfile.ReadOnlyPaths
+		}
+		if stepProfile.ReadWritePaths != nil {
+			profile.ReadWritePaths = stepProfile.ReadWritePaths
+		}
+	}
+	if !enabled || (profile.SeccompProfile == "" && !profile.RestrictsFilesystem()) {
+		return Profile{}, false
+	}
+	return profile, true
+}
+
+// Wrap returns the command that starts the child in the sandbox
+func Wrap(profile Profile, name string, argv []string) (string, []string, error) {
+	encoded, err := json.Marshal(profile)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to encode sandbox profile: %v", err)
+	}
+	return helperPath(), append([]string{ExecArgument, string(encoded), argumentSeparator, name}, argv...), nil
+}
+
+// IsExec returns whether the document worker was started to apply a sandbox, args are its arguments without the program name
+func IsExec(args []string) bool {
+	return len(args) > 0 && args[0] == ExecArgument
+}
+
+// parseExecArgs returns the sandbox and the child command from the arguments of the document worker
+func parseExecArgs(args []string) (profile Profile, name string, argv []string, err error) {
+	if len(args) < 4 || args[0] != ExecArgument || args[2] != argumentSeparator {
+		return profile, "", nil, fmt.Errorf("expected %v <profile> %v <command> [arguments]", ExecArgument, argumentSeparator)
+	}
+	if err = json.Unmarshal([]byte(args[1]), &profile); err != nil {
+		return profile, "", nil, fmt.Errorf("invalid sandbox profile: %v", err)
+	}
+	return profile, args[3], args[4:], nil
+}
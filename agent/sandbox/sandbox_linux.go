@@ -0,0 +1,105 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package sandbox
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+
+	"golang.org/x/sys/unix"
+)
+
+// supported is whether children can be sandboxed on this platform
+const supported = true
+
+// unsupportedError is returned when the kernel cannot apply part of the sandbox, the child runs without it
+type unsupportedError struct {
+	reason string
+}
+
+func (e *unsupportedError) Error() string {
+	return e.reason
+}
+
+// Main applies the sandbox in the arguments of the document worker and replaces the worker with the child command.
+// It only returns by exiting the process, with exit code 126 if the sandbox or the command cannot be applied.
+func Main(args []string) {
+	// the seccomp filter, the Landlock rules, the mount namespace and the root directory apply to the calling thread,
+	// which is the one that execs the child
+	runtime.LockOSThread()
+
+	profile, name, argv, err := parseExecArgs(args)
+	if err == nil {
+		err = apply(profile, os.Stderr)
+	}
+	// the command is looked up once the root directory of the child is changed
+	var path string
+	if err == nil {
+		path, err = exec.LookPath(name)
+	}
+	if err == nil {
+		err = unix.Exec(path, append([]string{name}, argv...), os.Environ())
+	}
+	fmt.Fprintf(os.Stderr, "sandbox: %v\n", err)
+	os.Exit(exitCodeFailed)
+}
+
+// apply restricts the calling thread to the profile, parts the kernel does not support are reported to warnings.
+// The seccomp profile is loaded before the Landlock rules may deny reading it, and the filter is installed last
+// so that it cannot deny the Landlock system calls. The mounts precede the Landlock rules, which forbid them, and the
+// root directory is changed after the rules are added for the paths of the host.
+func apply(profile Profile, warnings io.Writer) (err error) {
+	var filter []unix.SockFilter
+	if profile.SeccompProfile != "" {
+		if filter, err = loadSeccompFilter(profile.SeccompProfile); err != nil {
+			return err
+		}
+	}
+
+	if err = isolate(profile); err != nil {
+		if _, ok := err.(*unsupportedError); !ok {
+			return err
+		}
+		fmt.Fprintf(warnings, "sandbox: running without a private /tmp, %v\n", err)
+	}
+
+	// without no_new_privs, unprivileged threads cannot install seccomp filters or Landlock rules
+	if err = unix.Prctl(unix.PR_SET_NO_NEW_PRIVS, 1, 0, 0, 0); err != nil {
+		return fmt.Errorf("failed to set no_new_privs: %v", err)
+	}
+
+	if profile.RestrictsFilesystem() {
+		if err = restrictFilesystem(profile.ReadOnlyPaths, profile.ReadWritePaths); err != nil {
+			if _, ok := err.(*unsupportedError); !ok {
+				return err
+			}
+			fmt.Fprintf(warnings, "sandbox: running without filesystem rules, %v\n", err)
+		}
+	}
+	if err = changeRoot(profile); err != nil {
+		return err
+	}
+	if filter != nil {
+		if err = installSeccompFilter(filter); err != nil {
+			if _, ok := err.(*unsupportedError); !ok {
+				return err
+			}
+			fmt.Fprintf(warnings, "sandbox: running without seccomp filter, %v\n", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,30 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// +build !linux
+
+package sandbox
+
+import (
+	"fmt"
+	"os"
+)
+
+// supported is whether children can be sandboxed on this platform
+const supported = false
+
+// Main exits the document worker, sandboxes are only applied on Linux
+func Main(args []string) {
+	fmt.Fprintln(os.Stderr, "sandbox: seccomp and Landlock are only supported on Linux")
+	os.Exit(exitCodeFailed)
+}
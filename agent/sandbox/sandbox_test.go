@@ -0,0 +1,138 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package sandbox
+
+import (
+	"testing"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/stretchr/testify/assert"
+)
+
+// mockConfig sets the script sandbox of the agent config
+func mockConfig(sandboxCfg appconfig.ScriptSandboxCfg) func() {
+	config := appconfig.DefaultConfig()
+	config.ScriptSandbox = sandboxCfg
+	original := getAppConfig
+	getAppConfig = func(bool) (appconfig.SsmagentConfig, error) { return config, nil }
+	return func() { getAppConfig = original }
+}
+
+func TestResolve(t *testing.T) {
+	if !supported {
+		t.Skip("sandboxes are only supported on Linux")
+	}
+	configured := appconfig.ScriptSandboxCfg{
+		Enabled:               true,
+		SeccompProfile:        "/etc/amazon/ssm/seccomp.json",
+		LandlockReadOnlyPaths: []string{"/usr"},
+	}
+
+	testCases := []struct {
+		name     string
+		config   appconfig.ScriptSandboxCfg
+		step     *Profile
+		expected Profile
+		enabled  bool
+	}{
+		{"disabled", appconfig.ScriptSandboxCfg{}, nil, Profile{}, false},
+		{"enabled without rules", appconfig.ScriptSandboxCfg{Enabled: true}, nil, Profile{}, false},
+		{"config", configured, nil, Profile{SeccompProfile: "/etc/amazon/ssm/seccomp.json", ReadOnlyPaths: []string{"/usr"}}, true},
+		{
+			"step overrides paths",
+			configured,
+			&Profile{ReadWritePaths: []string{"/data"}},
+			Profile{SeccompProfile: "/etc/amazon/ssm/seccomp.json", ReadOnlyPaths: []string{"/usr"}, ReadWritePaths: []string{"/data"}},
+			true,
+		},
+		{"step enables", appconfig.ScriptSandboxCfg{}, &Profile{SeccompProfile: "/tmp/profile.json"}, Profile{SeccompProfile: "/tmp/profile.json"}, true},
+		{"step disables", configured, &Profile{Disabled: true}, Profile{}, false},
+		{
+			"override denied",
+			appconfig.ScriptSandboxCfg{Enabled: true, SeccompProfile: "/etc/amazon/ssm/seccomp.json", DenyDocumentOverride: true},
+			&Profile{Disabled: true},
+			Profile{SeccompProfile: "/etc/amazon/ssm/seccomp.json"},
+			true,
+		},
+	}
+	for _, testCase := range testCases {
+		restore := mockConfig(testCase.config)
+		profile, enabled := Resolve(testCase.step)
+		restore()
+		assert.Equal(t, testCase.enabled, enabled, testCase.name)
+		if enabled {
+			assert.Equal(t, testCase.expected, profile, testCase.name)
+		}
+	}
+}
+
+func TestAllowWrite(t *testing.T) {
+	profile := Profile{SeccompProfile: "/tmp/profile.json"}
+	profile.AllowWrite("/var/lib/amazon/ssm")
+	assert.Nil(t, profile.ReadWritePaths)
+
+	profile.ReadOnlyPaths = []string{"/usr"}
+	profile.AllowWrite("/var/lib/amazon/ssm", "")
+	assert.Equal(t, []string{"/var/lib/amazon/ssm"}, profile.ReadWritePaths)
+}
+
+func TestIsolate(t *testing.T) {
+	if !supported {
+		t.Skip("sandboxes are only supported on Linux")
+	}
+	config := appconfig.DefaultConfig()
+	original := getAppConfig
+	getAppConfig = func(bool) (appconfig.SsmagentConfig, error) { return config, nil }
+	defer func() { getAppConfig = original }()
+
+	profile := Profile{}
+	assert.False(t, profile.Isolate("/var/lib/amazon/ssm/tmp/command-1", "/home/ssm-user"))
+	assert.Equal(t, Profile{}, profile)
+
+	config.Isolation = appconfig.DocumentIsolationCfg{PrivateTmp: true}
+	assert.False(t, profile.Isolate(""))
+	assert.True(t, profile.Isolate("/var/lib/amazon/ssm/tmp/command-1", "/home/ssm-user"))
+	assert.Equal(t, Profile{PrivateTmp: "/var/lib/amazon/ssm/tmp/command-1"}, profile)
+
+	config.Isolation = appconfig.DocumentIsolationCfg{PrivateTmp: true, Chroot: "/srv/jail"}
+	profile = Profile{ReadOnlyPaths: []string{"/srv/jail"}}
+	assert.True(t, profile.Isolate("/var/lib/amazon/ssm/tmp/command-1", "/home/ssm-user", ""))
+	assert.Equal(t, Profile{
+		ReadOnlyPaths:  []string{"/srv/jail"},
+		ReadWritePaths: []string{"/var/lib/amazon/ssm/tmp/command-1"},
+		PrivateTmp:     "/var/lib/amazon/ssm/tmp/command-1",
+		Chroot:         "/srv/jail",
+		ChrootPaths:    []string{"/home/ssm-user"},
+	}, profile)
+}
+
+func TestWrapAndParseExecArgs(t *testing.T) {
+	profile := Profile{SeccompProfile: "/tmp/profile.json", ReadWritePaths: []string{"/tmp"}}
+	name, argv, err := Wrap(profile, "sh", []string{"-c", "/tmp/_script.sh"})
+	assert.NoError(t, err)
+	assert.Equal(t, appconfig.DefaultDocumentWorker, name)
+	assert.True(t, IsExec(argv))
+
+	parsed, childName, childArgv, err := parseExecArgs(argv)
+	assert.NoError(t, err)
+	assert.Equal(t, profile, parsed)
+	assert.Equal(t, "sh", childName)
+	assert.Equal(t, []string{"-c", "/tmp/_script.sh"}, childArgv)
+
+	assert.False(t, IsExec([]string{"documentID", "instanceID"}))
+	_, _, _, err = parseExecArgs([]string{ExecArgument, "{}", "sh"})
+	assert.Error(t, err)
+	_, _, _, err = parseExecArgs([]string{ExecArgument, "{", argumentSeparator, "sh"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,178 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package sandbox
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// seccomp filter return values
+const (
+	seccompRetKillProcess = 0x80000000
+	seccompRetKillThread  = 0x00000000
+	seccompRetTrap        = 0x00030000
+	seccompRetErrno       = 0x00050000
+	seccompRetLog         = 0x7ffc0000
+	seccompRetAllow       = 0x7fff0000
+	seccompRetData        = 0x0000ffff
+
+	seccompSetModeFilter = 1
+
+	// offsets of the fields of struct seccomp_data
+	seccompDataNrOffset   = 0
+	seccompDataArchOffset = 4
+
+	// x32SyscallBit is set in the numbers of the x32 system calls, which share the audit architecture of x86-64
+	x32SyscallBit = 0x40000000
+
+	// bpfMaxInstructions is the largest filter the kernel accepts
+	bpfMaxInstructions = 4096
+)
+
+// seccompProfile is the subset of the OCI seccomp profile the sandbox supports, rules cannot have argument conditions
+type seccompProfile struct {
+	DefaultAction   string
+	DefaultErrnoRet *uint
+	Syscalls        []seccompRule
+}
+
+// seccompRule is the action of a list of system calls
+type seccompRule struct {
+	Names    []string
+	Action   string
+	ErrnoRet *uint
+	Args     []json.RawMessage
+}
+
+// loadSeccompFilter reads the seccomp profile at path and compiles it
+func loadSeccompFilter(path string) ([]unix.SockFilter, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read seccomp profile: %v", err)
+	}
+	var profile seccompProfile
+	if err = json.Unmarshal(content, &profile); err != nil {
+		return nil, fmt.Errorf("invalid seccomp profile %v: %v", path, err)
+	}
+	filter, err := compileSeccompProfile(profile)
+	if err != nil {
+		return nil, fmt.Errorf("invalid seccomp profile %v: %v", path, err)
+	}
+	return filter, nil
+}
+
+// seccompAction returns the filter return value of an OCI action
+func seccompAction(action string, errnoRet *uint) (uint32, error) {
+	switch action {
+	case "SCMP_ACT_ALLOW":
+		return seccompRetAllow, nil
+	case "SCMP_ACT_ERRNO":
+		errno := uint32(unix.EPERM)
+		if errnoRet != nil {
+			errno = uint32(*errnoRet) & seccompRetData
+		}
+		return seccompRetErrno | errno, nil
+	case "SCMP_ACT_KILL", "SCMP_ACT_KILL_THREAD":
+		return seccompRetKillThread, nil
+	case "SCMP_ACT_KILL_PROCESS":
+		return seccompRetKillProcess, nil
+	case "SCMP_ACT_TRAP":
+		return seccompRetTrap, nil
+	case "SCMP_ACT_LOG":
+		return seccompRetLog, nil
+	}
+	return 0, fmt.Errorf("unsupported action %q", action)
+}
+
+// compileSeccompProfile returns the BPF program of the profile. Every system call has one action, the first rule
+// that names it wins. System calls of other architectures, and the x32 ones, kill the process.
+func compileSeccompProfile(profile seccompProfile) ([]unix.SockFilter, error) {
+	defaultAction, err := seccompAction(profile.DefaultAction, profile.DefaultErrnoRet)
+	if err != nil {
+		return nil, err
+	}
+
+	filter := []unix.SockFilter{
+		bpfStatement(unix.BPF_LD|unix.BPF_W|unix.BPF_ABS, seccompDataArchOffset),
+		bpfJump(unix.BPF_JMP|unix.BPF_JEQ|unix.BPF_K, auditArch, 1, 0),
+		bpfStatement(unix.BPF_RET|unix.BPF_K, seccompRetKillProcess),
+		bpfStatement(unix.BPF_LD|unix.BPF_W|unix.BPF_ABS, seccompDataNrOffset),
+		bpfJump(unix.BPF_JMP|unix.BPF_JGE|unix.BPF_K, x32SyscallBit, 0, 1),
+		bpfStatement(unix.BPF_RET|unix.BPF_K, seccompRetKillProcess),
+	}
+	seen := make(map[uint32]bool)
+	for _, rule := range profile.Syscalls {
+		if len(rule.Args) > 0 {
+			return nil, fmt.Errorf("argument conditions of %v are not supported", rule.Names)
+		}
+		action, err := seccompAction(rule.Action, rule.ErrnoRet)
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range rule.Names {
+			number, ok := syscallNumbers[name]
+			if !ok {
+				return nil, fmt.Errorf("unsupported system call %q", name)
+			}
+			if seen[number] {
+				continue
+			}
+			seen[number] = true
+			if action == defaultAction {
+				continue
+			}
+			filter = append(filter,
+				bpfJump(unix.BPF_JMP|unix.BPF_JEQ|unix.BPF_K, number, 0, 1),
+				bpfStatement(unix.BPF_RET|unix.BPF_K, action))
+		}
+	}
+	filter = append(filter, bpfStatement(unix.BPF_RET|unix.BPF_K, defaultAction))
+	if len(filter) > bpfMaxInstructions {
+		return nil, fmt.Errorf("the profile needs %v instructions, at most %v are supported", len(filter), bpfMaxInstructions)
+	}
+	return filter, nil
+}
+
+func bpfStatement(code uint16, k uint32) unix.SockFilter {
+	return unix.SockFilter{Code: code, K: k}
+}
+
+func bpfJump(code uint16, k uint32, jumpTrue uint8, jumpFalse uint8) unix.SockFilter {
+	return unix.SockFilter{Code: code, Jt: jumpTrue, Jf: jumpFalse, K: k}
+}
+
+// installSeccompFilter installs the filter on the calling thread, no_new_privs must be set
+func installSeccompFilter(filter []unix.SockFilter) error {
+	if auditArch == 0 {
+		return &unsupportedError{reason: "seccomp filters are not supported on this architecture"}
+	}
+	program := unix.SockFprog{Len: uint16(len(filter)), Filter: &filter[0]}
+	_, _, errno := unix.Syscall(sysSeccomp, seccompSetModeFilter, 0, uintptr(unsafe.Pointer(&program)))
+	if errno == unix.ENOSYS {
+		// kernels before 3.17 only install filters with prctl
+		_, _, errno = unix.Syscall6(unix.SYS_PRCTL, unix.PR_SET_SECCOMP, unix.SECCOMP_MODE_FILTER, uintptr(unsafe.Pointer(&program)), 0, 0, 0)
+	}
+	switch errno {
+	case 0:
+		return nil
+	case unix.EINVAL:
+		return &unsupportedError{reason: "the kernel does not support seccomp filters"}
+	}
+	return fmt.Errorf("failed to install seccomp filter: %v", errno)
+}
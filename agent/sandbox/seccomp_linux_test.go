@@ -0,0 +1,86 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package sandbox
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/sys/unix"
+)
+
+func TestCompileSeccompProfile(t *testing.T) {
+	errnoRet := uint(38)
+	filter, err := compileSeccompProfile(seccompProfile{
+		DefaultAction: "SCMP_ACT_ALLOW",
+		Syscalls: []seccompRule{
+			{Names: []string{"mount", "umount2"}, Action: "SCMP_ACT_ERRNO", ErrnoRet: &errnoRet},
+			{Names: []string{"mount", "ptrace"}, Action: "SCMP_ACT_KILL_PROCESS"},
+			{Names: []string{"socket"}, Action: "SCMP_ACT_ALLOW"},
+		},
+	})
+
+	assert.NoError(t, err)
+	// arch and x32 checks, one jump and return per denied system call, the default action
+	assert.Equal(t, 6+3*2+1, len(filter))
+	assert.Equal(t, auditArch, filter[1].K)
+	assert.Equal(t, syscallNumbers["mount"], filter[6].K)
+	assert.Equal(t, uint32(seccompRetErrno|38), filter[7].K)
+	assert.Equal(t, syscallNumbers["umount2"], filter[8].K)
+	assert.Equal(t, syscallNumbers["ptrace"], filter[10].K)
+	assert.Equal(t, uint32(seccompRetKillProcess), filter[11].K)
+	assert.Equal(t, unix.SockFilter{Code: unix.BPF_RET | unix.BPF_K, K: seccompRetAllow}, filter[12])
+}
+
+func TestCompileSeccompProfile_Invalid(t *testing.T) {
+	_, err := compileSeccompProfile(seccompProfile{DefaultAction: "SCMP_ACT_TRACE"})
+	assert.Error(t, err)
+
+	_, err = compileSeccompProfile(seccompProfile{
+		DefaultAction: "SCMP_ACT_ALLOW",
+		Syscalls:      []seccompRule{{Names: []string{"not_a_syscall"}, Action: "SCMP_ACT_ERRNO"}},
+	})
+	assert.Error(t, err)
+
+	_, err = compileSeccompProfile(seccompProfile{
+		DefaultAction: "SCMP_ACT_ALLOW",
+		Syscalls:      []seccompRule{{Names: []string{"clone"}, Action: "SCMP_ACT_ERRNO", Args: []json.RawMessage{[]byte(`{"index":0}`)}}},
+	})
+	assert.Error(t, err)
+}
+
+func TestLoadSeccompFilter(t *testing.T) {
+	file, err := ioutil.TempFile("", "seccomp")
+	assert.NoError(t, err)
+	defer os.Remove(file.Name())
+	file.WriteString(`{"defaultAction": "SCMP_ACT_ALLOW", "syscalls": [{"names": ["reboot"], "action": "SCMP_ACT_ERRNO"}]}`)
+	file.Close()
+
+	filter, err := loadSeccompFilter(file.Name())
+	assert.NoError(t, err)
+	assert.Equal(t, syscallNumbers["reboot"], filter[6].K)
+	assert.Equal(t, uint32(seccompRetErrno|uint32(unix.EPERM)), filter[7].K)
+
+	_, err = loadSeccompFilter(file.Name() + ".missing")
+	assert.Error(t, err)
+}
+
+func TestHandledAccess(t *testing.T) {
+	assert.Equal(t, uint64(1<<13-1), handledAccess(1))
+	assert.Equal(t, uint64(1<<14-1), handledAccess(2))
+	assert.Equal(t, uint64(1<<15-1), handledAccess(3))
+}
@@ -0,0 +1,25 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// +build linux,!386,!amd64,!arm,!arm64
+
+package sandbox
+
+// sysSeccomp is not used, seccomp filters are not installed on this architecture
+const sysSeccomp = 0
+
+// auditArch is not known on this architecture, the seccomp filter is skipped
+var auditArch uint32
+
+// syscallNumbers is empty, seccomp profiles cannot name system calls on this architecture
+var syscallNumbers = map[string]uint32{}
@@ -0,0 +1,214 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package schedule runs the recurring jobs of the agent from a single timer, so that an idle agent only wakes up
+// when a job is due. Jobs that are due close together share a wake up, and the first run of a job is delayed by a
+// random jitter so that instances started together do not call the service at the same time.
+package schedule
+
+import (
+	"container/heap"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// jitterFraction is the part of the interval the first run of a job is delayed by, at most maxJitter
+	jitterFraction = 10
+	maxJitter      = 30 * time.Second
+
+	// slackFraction is the part of the interval a run may be advanced by to share the wake up of another job,
+	// at most maxSlack
+	slackFraction = 10
+	maxSlack      = 30 * time.Second
+)
+
+// Job is a function run on an interval by a Scheduler
+type Job struct {
+	fn        func()
+	interval  time.Duration
+	next      time.Time
+	index     int // position of the job in the queue, -1 when the job is not queued
+	stopped   bool
+	running   int32
+	scheduler *Scheduler
+}
+
+// Scheduler runs jobs from one timer, which is only armed for the next due job
+type Scheduler struct {
+	lock      sync.Mutex
+	queue     jobQueue
+	timer     *time.Timer
+	maxJitter time.Duration
+	wakeups   int
+}
+
+var defaultScheduler = New()
+
+// New creates a scheduler
+func New() *Scheduler {
+	return &Scheduler{maxJitter: maxJitter}
+}
+
+// Every runs fn every interval from the shared scheduler of the agent, the first run happens after the interval
+func Every(interval time.Duration, fn func()) *Job {
+	return defaultScheduler.Every(interval, fn)
+}
+
+// Every runs fn every interval, the first run happens after the interval and a random jitter.
+// A job with a non positive interval only runs when RunNow is called.
+func (s *Scheduler) Every(interval time.Duration, fn func()) *Job {
+	job := &Job{fn: fn, interval: interval, index: -1, scheduler: s}
+	if interval <= 0 {
+		return job
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	job.next = time.Now().Add(interval + s.jitter(interval))
+	heap.Push(&s.queue, job)
+	s.arm()
+	return job
+}
+
+// RunNow runs the job immediately and schedules its next run an interval from now, it does nothing once the job is stopped
+func (j *Job) RunNow() {
+	s := j.scheduler
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if j.stopped {
+		return
+	}
+	j.run()
+	if j.index >= 0 {
+		j.next = time.Now().Add(j.interval)
+		heap.Fix(&s.queue, j.index)
+		s.arm()
+	}
+}
+
+// Stop removes the job from the scheduler, a run in progress completes
+func (j *Job) Stop() {
+	s := j.scheduler
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	j.stopped = true
+	if j.index >= 0 {
+		heap.Remove(&s.queue, j.index)
+		s.arm()
+	}
+}
+
+// Stopped returns true once Stop was called, the job does not run anymore
+func (j *Job) Stopped() bool {
+	s := j.scheduler
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return j.stopped
+}
+
+// run starts the job function unless its previous run is still in progress, the caller holds the scheduler lock
+func (j *Job) run() {
+	if !atomic.CompareAndSwapInt32(&j.running, 0, 1) {
+		return
+	}
+	go func() {
+		defer atomic.StoreInt32(&j.running, 0)
+		j.fn()
+	}()
+}
+
+// slack is how early the job may run to share a wake up
+func (j *Job) slack() time.Duration {
+	if slack := j.interval / slackFraction; slack < maxSlack {
+		return slack
+	}
+	return maxSlack
+}
+
+// jitter returns a random delay for the first run of a job
+func (s *Scheduler) jitter(interval time.Duration) time.Duration {
+	limit := interval / jitterFraction
+	if limit > s.maxJitter {
+		limit = s.maxJitter
+	}
+	if limit <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(limit)))
+}
+
+// arm sets the timer to the next due job, the caller holds the scheduler lock
+func (s *Scheduler) arm() {
+	if len(s.queue) == 0 {
+		if s.timer != nil {
+			s.timer.Stop()
+		}
+		return
+	}
+	wait := time.Until(s.queue[0].next)
+	if s.timer == nil {
+		s.timer = time.AfterFunc(wait, s.wake)
+	} else {
+		s.timer.Reset(wait)
+	}
+}
+
+// wake runs every job that is due or within its slack, and arms the timer for the next one
+func (s *Scheduler) wake() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.wakeups++
+
+	now := time.Now()
+	for len(s.queue) > 0 {
+		job := s.queue[0]
+		if job.next.Sub(now) > job.slack() {
+			break
+		}
+		job.run()
+		// later runs keep the interval from now, so jobs that shared this wake up keep sharing the next ones
+		job.next = now.Add(job.interval)
+		heap.Fix(&s.queue, 0)
+	}
+	s.arm()
+}
+
+// jobQueue is a heap of jobs ordered by their next run
+type jobQueue []*Job
+
+func (q jobQueue) Len() int           { return len(q) }
+func (q jobQueue) Less(i, j int) bool { return q[i].next.Before(q[j].next) }
+
+func (q jobQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].index = i
+	q[j].index = j
+}
+
+func (q *jobQueue) Push(x interface{}) {
+	job := x.(*Job)
+	job.index = len(*q)
+	*q = append(*q, job)
+}
+
+func (q *jobQueue) Pop() interface{} {
+	old := *q
+	job := old[len(old)-1]
+	old[len(old)-1] = nil
+	job.index = -1
+	*q = old[:len(old)-1]
+	return job
+}
@@ -0,0 +1,113 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package schedule
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestScheduler() *Scheduler {
+	s := New()
+	s.maxJitter = 0
+	return s
+}
+
+func (s *Scheduler) wakeupCount() int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.wakeups
+}
+
+func TestEveryRunsOnTheInterval(t *testing.T) {
+	s := newTestScheduler()
+	var runs int32
+	job := s.Every(20*time.Millisecond, func() { atomic.AddInt32(&runs, 1) })
+	defer job.Stop()
+
+	time.Sleep(110 * time.Millisecond)
+	assert.True(t, atomic.LoadInt32(&runs) >= 3)
+	assert.True(t, atomic.LoadInt32(&runs) <= 6)
+}
+
+func TestJobsDueTogetherShareAWakeup(t *testing.T) {
+	s := newTestScheduler()
+	var first, second int32
+	// the second job is due within the slack of the first one
+	one := s.Every(100*time.Millisecond, func() { atomic.AddInt32(&first, 1) })
+	two := s.Every(105*time.Millisecond, func() { atomic.AddInt32(&second, 1) })
+	defer one.Stop()
+	defer two.Stop()
+
+	time.Sleep(150 * time.Millisecond)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&first))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&second))
+	assert.Equal(t, 1, s.wakeupCount())
+}
+
+func TestStoppedJobDoesNotRun(t *testing.T) {
+	s := newTestScheduler()
+	var runs int32
+	job := s.Every(10*time.Millisecond, func() { atomic.AddInt32(&runs, 1) })
+	assert.False(t, job.Stopped())
+	job.Stop()
+	assert.True(t, job.Stopped())
+	job.RunNow()
+
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, int32(0), atomic.LoadInt32(&runs))
+	assert.Equal(t, 0, s.wakeupCount())
+	assert.Empty(t, s.queue)
+}
+
+func TestRunNowRunsImmediately(t *testing.T) {
+	s := newTestScheduler()
+	runs := make(chan bool, 10)
+	job := s.Every(time.Hour, func() { runs <- true })
+	defer job.Stop()
+
+	job.RunNow()
+	select {
+	case <-runs:
+	case <-time.After(time.Second):
+		assert.Fail(t, "job did not run")
+	}
+}
+
+func TestRunDoesNotOverlap(t *testing.T) {
+	s := newTestScheduler()
+	var runs int32
+	release := make(chan bool)
+	job := s.Every(10*time.Millisecond, func() {
+		atomic.AddInt32(&runs, 1)
+		<-release
+	})
+
+	time.Sleep(60 * time.Millisecond)
+	job.Stop()
+	close(release)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&runs))
+}
+
+func TestJitterIsBounded(t *testing.T) {
+	s := New()
+	for i := 0; i < 100; i++ {
+		assert.True(t, s.jitter(time.Second) < 100*time.Millisecond)
+		assert.True(t, s.jitter(time.Hour) < maxJitter)
+	}
+	assert.Equal(t, time.Duration(0), s.jitter(5*time.Nanosecond))
+}
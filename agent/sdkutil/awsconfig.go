@@ -15,17 +15,21 @@
 package sdkutil
 
 import (
+	"net/http"
 	"time"
 
 	"github.com/aws/amazon-ssm-agent/agent/appconfig"
 	"github.com/aws/amazon-ssm-agent/agent/managedInstances/registration"
 	"github.com/aws/amazon-ssm-agent/agent/managedInstances/rolecreds"
 	"github.com/aws/amazon-ssm-agent/agent/platform"
+	"github.com/aws/amazon-ssm-agent/agent/proxyconfig"
 	"github.com/aws/amazon-ssm-agent/agent/sdkutil/retryer"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/credentials/ec2rolecreds"
 	"github.com/aws/aws-sdk-go/aws/defaults"
+	"github.com/aws/aws-sdk-go/aws/endpoints"
 )
 
 // AwsConfig returns the default aws.Config object while the appropriate
@@ -33,9 +37,12 @@ import (
 // values they want for service specific overrides.
 func AwsConfig() (awsConfig *aws.Config) {
 	// create default config
+	// clients share the connections of the shared transport, which selects the proxy with the rules of the service
+	// of their session and uses the agent tls config
 	awsConfig = &aws.Config{
 		Retryer:    newRetryer(),
 		SleepDelay: sleepDelay,
+		HTTPClient: &http.Client{Transport: proxyconfig.SharedTransport()},
 	}
 
 	// update region from platform
@@ -46,12 +53,24 @@ func AwsConfig() (awsConfig *aws.Config) {
 
 	//load Task IAM credentials if applicable
 	config, _ := appconfig.Config(false)
+	if config.Agent.UseDualStackEndpoint {
+		awsConfig.UseDualStack = aws.Bool(true)
+	}
+	if config.Agent.UseFipsEndpoint {
+		awsConfig.EndpointResolver = endpoints.ResolverFunc(fipsEndpointFor)
+	}
 	if config.Agent.ContainerMode {
 		cfg := defaults.Config()
 		handlers := defaults.Handlers()
 		awsConfig.Credentials = defaults.CredChain(cfg, handlers)
 	}
 
+	// load credentials from credential_process or web identity if configured
+	if federatedCreds := federatedCredentials(config.Profile, region); federatedCreds != nil {
+		awsConfig.Credentials = federatedCreds
+		return
+	}
+
 	// load managed credentials if applicable
 	if isManaged, err := registration.HasManagedInstancesCredentials(); isManaged && err == nil {
 		awsConfig.Credentials =
@@ -65,6 +84,30 @@ func AwsConfig() (awsConfig *aws.Config) {
 	return
 }
 
+// fipsServices are the services the agent calls that have FIPS endpoints
+var fipsServices = map[string]bool{
+	"ec2messages": true,
+	"kms":         true,
+	"logs":        true,
+	"s3":          true,
+	"ssm":         true,
+	"ssmmessages": true,
+	"sts":         true,
+}
+
+// fipsEndpointFor resolves the endpoint of a service like the SDK does, but with the FIPS endpoint of the services that have one.
+// The SDK version used by the agent cannot select FIPS endpoints by itself.
+func fipsEndpointFor(service, region string, opts ...func(*endpoints.Options)) (endpoints.ResolvedEndpoint, error) {
+	resolved, err := endpoints.DefaultResolver().EndpointFor(service, region, opts...)
+	if err != nil || !fipsServices[service] {
+		return resolved, err
+	}
+	if endpoint := platform.GetDefaultEndPoint(region, service); endpoint != "" {
+		resolved.URL = "https://" + endpoint
+	}
+	return resolved, nil
+}
+
 // This will return the same remote credential provider as the SDK
 // We are creating this explicitly and passing it to the SDK
 // because we do not care for the shared credentials / ENV credentials in the
@@ -73,6 +116,9 @@ func defaultRemoteCredentials() *credentials.Credentials {
 	cfg := defaults.Config()
 	handlers := defaults.Handlers()
 	remotecreds := defaults.RemoteCredProvider(*cfg, handlers)
+	if ec2RoleProvider, ok := remotecreds.(*ec2rolecreds.EC2RoleProvider); ok {
+		platform.WithConfiguredMetadataEndpoint(ec2RoleProvider.Client)
+	}
 
 	return credentials.NewCredentials(remotecreds)
 }
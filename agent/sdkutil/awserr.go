@@ -17,11 +17,42 @@ package sdkutil
 import (
 	"runtime"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/aws/amazon-ssm-agent/agent/log"
 	"github.com/aws/aws-sdk-go/aws/awserr"
 )
 
+// unknownErrorCode is the code recorded for errors that are not AWS errors
+const unknownErrorCode = "Unknown"
+
+// lastAwsError is the last error HandleAwsError logged, the health ping reports it
+var lastAwsError struct {
+	lock sync.Mutex
+	code string
+	time time.Time
+}
+
+// LastAwsError returns the code of the last error logged by HandleAwsError and when it was logged
+func LastAwsError() (code string, at time.Time) {
+	lastAwsError.lock.Lock()
+	defer lastAwsError.lock.Unlock()
+	return lastAwsError.code, lastAwsError.time
+}
+
+// recordAwsError records the code of an error logged by HandleAwsError
+func recordAwsError(err error) {
+	code := GetAwsErrorCode(err)
+	if code == "" {
+		code = unknownErrorCode
+	}
+	lastAwsError.lock.Lock()
+	defer lastAwsError.lock.Unlock()
+	lastAwsError.code = code
+	lastAwsError.time = time.Now()
+}
+
 // HandleAwsError logs an AWS error.
 func HandleAwsError(log log.T, err error, stopPolicy *StopPolicy) {
 	if err != nil {
@@ -46,6 +77,7 @@ func HandleAwsError(log log.T, err error, stopPolicy *StopPolicy) {
 		}
 
 		log.Errorf("error when calling AWS APIs. error details - %v", err)
+		recordAwsError(err)
 		if stopPolicy != nil {
 			log.Infof("increasing error count by 1")
 			stopPolicy.AddErrorCount(1)
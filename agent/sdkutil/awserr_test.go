@@ -16,8 +16,10 @@ package sdkutil
 import (
 	"errors"
 	"testing"
+	"time"
 
 	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -49,3 +51,17 @@ func TestHandleAwsErrorCount(t *testing.T) {
 	}
 
 }
+
+func TestLastAwsError(t *testing.T) {
+	log := log.NewMockLog()
+	before := time.Now()
+
+	HandleAwsError(log, awserr.New("ThrottlingException", "Rate exceeded", nil), nil)
+	code, at := LastAwsError()
+	assert.Equal(t, "ThrottlingException", code)
+	assert.False(t, at.Before(before))
+
+	HandleAwsError(log, errSample, nil)
+	code, _ = LastAwsError()
+	assert.Equal(t, unknownErrorCode, code)
+}
@@ -0,0 +1,87 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package sdkutil provides utilities used to call awssdk.
+package sdkutil
+
+import (
+	"sync"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/credentials/processcreds"
+	"github.com/aws/aws-sdk-go/aws/credentials/stscreds"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/aws/aws-sdk-go/service/sts/stsiface"
+)
+
+// federatedCredsExpiryWindow refreshes federated credentials before they actually expire
+const federatedCredsExpiryWindow = 5 * time.Minute
+
+var (
+	federatedCredsLock    sync.Mutex
+	federatedCredsProfile appconfig.CredentialProfile
+	federatedCredsCache   *credentials.Credentials
+)
+
+// federatedCredentials returns the credentials for the credential_process or web identity
+// configured in the profile, or nil if neither is configured.
+// Credentials are shared by all clients so that they are refreshed only once when they expire.
+func federatedCredentials(profile appconfig.CredentialProfile, region string) *credentials.Credentials {
+	federatedCredsLock.Lock()
+	defer federatedCredsLock.Unlock()
+
+	if federatedCredsCache != nil && federatedCredsProfile == profile {
+		return federatedCredsCache
+	}
+
+	federatedCredsCache = newFederatedCredentials(profile, region)
+	federatedCredsProfile = profile
+	return federatedCredsCache
+}
+
+// newFederatedCredentials creates the credentials for the profile, credential_process takes precedence over web identity
+func newFederatedCredentials(profile appconfig.CredentialProfile, region string) *credentials.Credentials {
+	if profile.CredentialProcess != "" {
+		return processcreds.NewCredentials(profile.CredentialProcess, func(p *processcreds.ProcessProvider) {
+			p.Timeout = time.Duration(profile.CredentialProcessTimeoutSeconds) * time.Second
+			p.ExpiryWindow = federatedCredsExpiryWindow
+		})
+	}
+
+	if profile.WebIdentityTokenFile != "" && profile.WebIdentityRoleArn != "" {
+		// AssumeRoleWithWebIdentity is not signed, the token file is the only secret needed
+		stsConfig := &aws.Config{
+			Credentials: credentials.AnonymousCredentials,
+			Retryer:     newRetryer(),
+		}
+		if region != "" {
+			stsConfig.Region = aws.String(region)
+		}
+		provider := stscreds.NewWebIdentityRoleProvider(
+			newStsClient(stsConfig),
+			profile.WebIdentityRoleArn,
+			profile.WebIdentityRoleSessionName,
+			profile.WebIdentityTokenFile)
+		provider.ExpiryWindow = federatedCredsExpiryWindow
+		return credentials.NewCredentials(provider)
+	}
+
+	return nil
+}
+
+var newStsClient = func(config *aws.Config) stsiface.STSAPI {
+	return sts.New(NewSession(config, ""))
+}
@@ -0,0 +1,65 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package sdkutil provides utilies used to call awssdk.
+package sdkutil
+
+import (
+	"runtime"
+	"testing"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFederatedCredentials_NotConfigured(t *testing.T) {
+	assert.Nil(t, newFederatedCredentials(appconfig.CredentialProfile{ShareCreds: true}, "us-east-1"))
+
+	// web identity needs both the token file and the role
+	assert.Nil(t, newFederatedCredentials(appconfig.CredentialProfile{WebIdentityTokenFile: "/var/run/token"}, "us-east-1"))
+}
+
+func TestFederatedCredentials_WebIdentity(t *testing.T) {
+	profile := appconfig.CredentialProfile{
+		WebIdentityTokenFile: "/var/run/token",
+		WebIdentityRoleArn:   "arn:aws:iam::123456789012:role/ssm",
+	}
+	assert.NotNil(t, newFederatedCredentials(profile, "us-east-1"))
+}
+
+func TestFederatedCredentials_CredentialProcess(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("credential process test uses a posix shell")
+	}
+	profile := appconfig.CredentialProfile{
+		CredentialProcess:               `echo '{"Version": 1, "AccessKeyId": "akid", "SecretAccessKey": "secret", "SessionToken": "token"}'`,
+		CredentialProcessTimeoutSeconds: 10,
+	}
+
+	creds := newFederatedCredentials(profile, "us-east-1")
+	value, err := creds.Get()
+
+	assert.NoError(t, err)
+	assert.Equal(t, "akid", value.AccessKeyID)
+	assert.Equal(t, "secret", value.SecretAccessKey)
+	assert.Equal(t, "token", value.SessionToken)
+}
+
+func TestFederatedCredentials_Cached(t *testing.T) {
+	profile := appconfig.CredentialProfile{CredentialProcess: "credential-helper"}
+	first := federatedCredentials(profile, "us-east-1")
+	assert.True(t, first == federatedCredentials(profile, "us-east-1"))
+
+	profile.CredentialProcess = "other-credential-helper"
+	assert.False(t, first == federatedCredentials(profile, "us-east-1"))
+}
@@ -0,0 +1,36 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package sdksession creates the aws sdk sessions of the agent, it does not depend on the credential providers
+// of the agent so the clients they use can share it
+package sdksession
+
+import (
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/proxyconfig"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/aws/session"
+)
+
+// New returns a session for the clients of the service. Its requests carry the agent user agent and, when
+// config uses the shared transport of the agent, are sent with the proxy rules of the service.
+func New(config *aws.Config, service string) *session.Session {
+	appConfig, _ := appconfig.Config(false)
+	sess := session.New(config)
+	sess.Handlers.Build.PushBack(request.MakeAddToUserAgentHandler(appConfig.Agent.Name, appConfig.Agent.Version))
+	sess.Handlers.Build.PushBack(func(r *request.Request) {
+		r.SetContext(proxyconfig.WithService(r.Context(), service))
+	})
+	return sess
+}
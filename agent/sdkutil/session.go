@@ -0,0 +1,26 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package sdkutil
+
+import (
+	"github.com/aws/amazon-ssm-agent/agent/sdkutil/sdksession"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+)
+
+// NewSession returns a session for the clients of the service. Its requests carry the agent user agent and, when
+// config uses the shared transport of AwsConfig, are sent with the proxy rules of the service.
+func NewSession(config *aws.Config, service string) *session.Session {
+	return sdksession.New(config, service)
+}
@@ -0,0 +1,37 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// +build darwin freebsd linux
+
+package secret
+
+import (
+	"golang.org/x/sys/unix"
+)
+
+// allocate maps anonymous pages so that locking and unlocking them does not affect other allocations
+func allocate(size int) []byte {
+	memory, err := unix.Mmap(-1, 0, size, unix.PROT_READ|unix.PROT_WRITE, unix.MAP_ANON|unix.MAP_PRIVATE)
+	if err != nil {
+		return make([]byte, size)
+	}
+	// locking fails above the memlock limit of the process, the secret is still zeroed on release
+	unix.Mlock(memory)
+	return memory
+}
+
+// free unmaps the pages, buffers allocated by make when the mapping failed are left to the garbage collector
+func free(memory []byte) {
+	unix.Munlock(memory)
+	unix.Munmap(memory)
+}
@@ -0,0 +1,34 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// +build windows
+
+package secret
+
+import (
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// allocate locks the buffer in the working set so that it is not written to the page file
+func allocate(size int) []byte {
+	memory := make([]byte, size)
+	// locking fails above the working set limit of the process, the secret is still zeroed on release
+	windows.VirtualLock(uintptr(unsafe.Pointer(&memory[0])), uintptr(len(memory)))
+	return memory
+}
+
+func free(memory []byte) {
+	windows.VirtualUnlock(uintptr(unsafe.Pointer(&memory[0])), uintptr(len(memory)))
+}
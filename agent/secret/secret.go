@@ -0,0 +1,114 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package secret keeps decrypted values such as SecureString parameters and data keys out of the garbage collected
+// heap. The values are held in locked memory that is zeroed when they are released, and never formatted or logged.
+package secret
+
+import (
+	"fmt"
+	"io"
+	"runtime"
+	"sync"
+)
+
+// Masked replaces a secret wherever it would be formatted, logged or marshalled
+const Masked = "****"
+
+// SecretValue holds a secret in a locked buffer until Release
+type SecretValue struct {
+	lock   sync.Mutex
+	memory []byte
+	value  []byte
+}
+
+// New copies the value into a locked buffer and zeroes the value
+func New(value []byte) *SecretValue {
+	s := &SecretValue{}
+	if len(value) > 0 {
+		s.memory = allocate(len(value))
+		s.value = s.memory[:len(value)]
+		copy(s.value, value)
+		Zero(value)
+	}
+	// values that are never released are still zeroed once they are collected
+	runtime.SetFinalizer(s, (*SecretValue).Release)
+	return s
+}
+
+// FromString copies the value into a locked buffer, strings are immutable so the caller's copy cannot be zeroed
+func FromString(value string) *SecretValue {
+	return New([]byte(value))
+}
+
+// Bytes returns the secret, the slice is zeroed by Release and must not be kept after it
+func (s *SecretValue) Bytes() []byte {
+	if s == nil {
+		return nil
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.value
+}
+
+// Reveal returns the secret as a string for the APIs that only accept strings, the string cannot be zeroed
+func (s *SecretValue) Reveal() string {
+	return string(s.Bytes())
+}
+
+// Len returns the length of the secret
+func (s *SecretValue) Len() int {
+	return len(s.Bytes())
+}
+
+// Release zeroes the secret and frees its buffer
+func (s *SecretValue) Release() {
+	if s == nil {
+		return
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.memory != nil {
+		Zero(s.memory)
+		free(s.memory)
+	}
+	s.memory, s.value = nil, nil
+	runtime.SetFinalizer(s, nil)
+}
+
+// String masks the secret
+func (s *SecretValue) String() string {
+	return Masked
+}
+
+// GoString masks the secret when formatted with %#v
+func (s *SecretValue) GoString() string {
+	return Masked
+}
+
+// Format masks the secret for every verb
+func (s *SecretValue) Format(f fmt.State, verb rune) {
+	io.WriteString(f, Masked)
+}
+
+// MarshalJSON masks the secret when it is marshalled, for instance to log a structure holding it
+func (s *SecretValue) MarshalJSON() ([]byte, error) {
+	return []byte(`"` + Masked + `"`), nil
+}
+
+// Zero overwrites the bytes with zeroes
+func Zero(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
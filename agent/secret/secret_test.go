@@ -0,0 +1,77 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package secret
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewZeroesSource(t *testing.T) {
+	source := []byte("S3cret!")
+	s := New(source)
+	defer s.Release()
+
+	assert.Equal(t, make([]byte, 7), source)
+	assert.Equal(t, "S3cret!", s.Reveal())
+	assert.Equal(t, 7, s.Len())
+}
+
+func TestRelease(t *testing.T) {
+	s := FromString("S3cret!")
+	s.Release()
+
+	assert.Equal(t, 0, s.Len())
+	assert.Nil(t, s.Bytes())
+	// releasing twice is a no-op
+	s.Release()
+}
+
+func TestZero(t *testing.T) {
+	value := []byte("S3cret!")
+	Zero(value)
+	assert.Equal(t, make([]byte, 7), value)
+}
+
+func TestFormattingIsMasked(t *testing.T) {
+	s := FromString("S3cret!")
+	defer s.Release()
+
+	for _, format := range []string{"%v", "%+v", "%#v", "%s", "%q", "%x"} {
+		assert.Equal(t, Masked, fmt.Sprintf(format, s), format)
+	}
+	assert.Equal(t, Masked, s.String())
+
+	holder := struct {
+		Name     string
+		Password *SecretValue
+	}{Name: "alice", Password: s}
+	assert.NotContains(t, fmt.Sprintf("%+v", holder), "S3cret!")
+	content, err := json.Marshal(holder)
+	assert.NoError(t, err)
+	assert.Equal(t, `{"Name":"alice","Password":"****"}`, string(content))
+}
+
+func TestNilAndEmpty(t *testing.T) {
+	var s *SecretValue
+	assert.Equal(t, "", s.Reveal())
+	s.Release()
+
+	empty := New(nil)
+	assert.Equal(t, 0, empty.Len())
+	empty.Release()
+}
@@ -26,6 +26,7 @@ import (
 
 	"github.com/aws/amazon-ssm-agent/agent/context"
 	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/network/clockskew"
 	"github.com/aws/amazon-ssm-agent/agent/session/communicator/websocketutil"
 	mgsconfig "github.com/aws/amazon-ssm-agent/agent/session/config"
 	v4 "github.com/aws/aws-sdk-go/aws/signer/v4"
@@ -66,6 +67,8 @@ type WebSocketChannel struct {
 	Region       string
 	IsOpen       bool
 	writeLock    *sync.Mutex
+	// OnPong is called for every pong the service answers the pings with, while the channel reads its messages
+	OnPong func()
 }
 
 // Initialize a WebSocketChannel object.
@@ -130,7 +133,7 @@ func (webSocketChannel *WebSocketChannel) getV4SignatureHeader(log log.T, Url st
 	request, err := http.NewRequest("GET", Url, nil)
 
 	if webSocketChannel.Signer != nil {
-		_, err = webSocketChannel.Signer.Sign(request, nil, mgsconfig.ServiceName, webSocketChannel.Region, time.Now())
+		_, err = webSocketChannel.Signer.Sign(request, nil, mgsconfig.ServiceName, webSocketChannel.Region, clockskew.Now())
 	}
 	return request.Header, err
 }
@@ -163,6 +166,12 @@ func (webSocketChannel *WebSocketChannel) Open(log log.T) error {
 
 	webSocketChannel.Connection = ws
 	webSocketChannel.IsOpen = true
+	if onPong := webSocketChannel.OnPong; onPong != nil {
+		ws.SetPongHandler(func(string) error {
+			onPong()
+			return nil
+		})
+	}
 	webSocketChannel.StartPings(log, mgsconfig.WebSocketPingInterval)
 
 	// spin up a different routine to listen to the incoming traffic
@@ -16,9 +16,15 @@ package websocketutil
 
 import (
 	"errors"
+	"fmt"
+	"net"
 	"net/http"
+	"net/url"
 
 	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/network"
+	"github.com/aws/amazon-ssm-agent/agent/network/clockskew"
+	"github.com/aws/amazon-ssm-agent/agent/proxyconfig"
 	"github.com/gorilla/websocket"
 )
 
@@ -34,6 +40,17 @@ type WebsocketUtil struct {
 	log    log.T
 }
 
+// HandshakeError is returned when the service rejects the websocket handshake.
+type HandshakeError struct {
+	StatusCode int
+	Err        error
+}
+
+// Error returns the error message of the HandshakeError.
+func (e *HandshakeError) Error() string {
+	return fmt.Sprintf("%s, status code: %d", e.Err, e.StatusCode)
+}
+
 // NewWebsocketUtil is the factory function for websocketutil.
 func NewWebsocketUtil(logger log.T, dialerInput *websocket.Dialer) *WebsocketUtil {
 
@@ -41,8 +58,12 @@ func NewWebsocketUtil(logger log.T, dialerInput *websocket.Dialer) *WebsocketUti
 
 	if dialerInput == nil {
 		websocketUtil = &WebsocketUtil{
-			dialer: websocket.DefaultDialer,
-			log:    logger,
+			dialer: &websocket.Dialer{
+				Proxy:           proxyconfig.ProxyFunc(proxyconfig.ServiceSsmMessages),
+				TLSClientConfig: network.GetDefaultTLSConfig(),
+				NetDial:         network.Dialer{}.Dial,
+			},
+			log: logger,
 		}
 	} else {
 		websocketUtil = &WebsocketUtil{
@@ -60,9 +81,11 @@ func (u *WebsocketUtil) OpenConnection(url string, requestHeader http.Header) (*
 	u.log.Infof("Opening websocket connection to: %s", url)
 
 	conn, resp, err := u.dialer.Dial(url, requestHeader)
+	clockskew.RecordResponse(resp)
 	if err != nil {
 		if resp != nil {
 			u.log.Warnf("Failed to dial websocket, status: %s, err: %s", resp.Status, err)
+			return nil, &HandshakeError{StatusCode: resp.StatusCode, Err: err}
 		} else {
 			u.log.Warnf("Failed to dial websocket: %s", err)
 		}
@@ -92,3 +115,42 @@ func (u *WebsocketUtil) CloseConnection(ws *websocket.Conn) error {
 
 	return nil
 }
+
+// Prewarm connects ahead of time to the host of the websocket url, or to the proxy chosen for it, so that the next
+// OpenConnection to the host does not wait for the name resolution and the TCP handshake.
+func Prewarm(log log.T, websocketUrl string) {
+	u, err := url.Parse(websocketUrl)
+	if err != nil {
+		log.Debugf("Not pre-warming a connection to %v: %v", websocketUrl, err)
+		return
+	}
+	// the dialer chooses the proxy for the http scheme of the websocket url
+	switch u.Scheme {
+	case "ws":
+		u.Scheme = "http"
+	case "wss":
+		u.Scheme = "https"
+	}
+
+	address := hostPort(u)
+	proxyURL, err := proxyconfig.ProxyFunc(proxyconfig.ServiceSsmMessages)(&http.Request{URL: u, Host: u.Host})
+	if err != nil {
+		log.Debugf("Not pre-warming a connection to %v: %v", websocketUrl, err)
+		return
+	}
+	if proxyURL != nil {
+		address = hostPort(proxyURL)
+	}
+	go network.Prewarm(address)
+}
+
+// hostPort returns the address the dialer connects to for the url
+func hostPort(u *url.URL) string {
+	if u.Port() != "" {
+		return u.Host
+	}
+	if u.Scheme == "https" {
+		return net.JoinHostPort(u.Hostname(), "443")
+	}
+	return net.JoinHostPort(u.Hostname(), "80")
+}
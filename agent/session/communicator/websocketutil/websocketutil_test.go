@@ -16,10 +16,13 @@ package websocketutil
 
 import (
 	"fmt"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/aws/amazon-ssm-agent/agent/log"
 	"github.com/gorilla/websocket"
@@ -67,6 +70,42 @@ func TestWebsocketUtilOpenConnectionInvalidUrl(t *testing.T) {
 	assert.Nil(t, conn, "Open connection failed.")
 }
 
+func TestWebsocketUtilOpenConnectionUsesPrewarmedConnection(t *testing.T) {
+	var newConns int32
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(handlerToBeTested))
+	srv.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			atomic.AddInt32(&newConns, 1)
+		}
+	}
+	srv.Start()
+	defer srv.Close()
+	u, _ := url.Parse(srv.URL)
+	u.Scheme = "ws"
+	var log = log.NewMockLog()
+
+	Prewarm(log, u.String())
+	for i := 0; i < 50 && atomic.LoadInt32(&newConns) == 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	var ws = NewWebsocketUtil(log, nil)
+	conn, err := ws.OpenConnection(u.String(), http.Header{})
+	assert.Nil(t, err)
+	assert.NotNil(t, conn)
+	ws.CloseConnection(conn)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&newConns))
+}
+
+func TestHostPort(t *testing.T) {
+	u, _ := url.Parse("https://ssmmessages.us-east-1.amazonaws.com/v1/control-channel")
+	assert.Equal(t, "ssmmessages.us-east-1.amazonaws.com:443", hostPort(u))
+	u, _ = url.Parse("http://proxy.local")
+	assert.Equal(t, "proxy.local:80", hostPort(u))
+	u, _ = url.Parse("http://[fd00::1]:3128")
+	assert.Equal(t, "[fd00::1]:3128", hostPort(u))
+}
+
 func TestSendMessage(t *testing.T) {
 	srv := httptest.NewServer(http.HandlerFunc(handlerToBeTested))
 	u, _ := url.Parse(srv.URL)
@@ -17,6 +17,7 @@ package config
 import (
 	"time"
 
+	"github.com/aws/amazon-ssm-agent/agent/network/failover"
 	"github.com/aws/amazon-ssm-agent/agent/rip"
 )
 
@@ -80,5 +81,8 @@ const (
 )
 
 var GetMgsEndpointFromRip = func(region string) string {
+	if endpoint := failover.EndpointOverride(ServiceName, region); endpoint != "" {
+		return endpoint
+	}
 	return rip.GetMgsEndpoint(region)
 }
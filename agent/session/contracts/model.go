@@ -44,8 +44,17 @@ const (
 	PausePublicationMessage string = "pause_publication"
 	// StartPublicationMessage message type for start sending data packages.
 	StartPublicationMessage string = "start_publication"
+	// AgentJobMessage represents message type for a run command message delivered over the control channel
+	AgentJobMessage string = "agent_job"
+	// AgentJobAcknowledgeMessage represents message type for acknowledging or failing a delivered run command message
+	AgentJobAcknowledgeMessage string = "agent_job_ack"
+	// AgentJobDeliveryMessage represents message type for the service turning run command delivery on or off
+	AgentJobDeliveryMessage string = "agent_job_delivery"
 )
 
+// AgentJobCapability is the capability the agent requests when opening the control channel to receive run command messages
+const AgentJobCapability = "agent_job"
+
 type ShellProperties struct {
 	Windows ShellConfig `json:"windows" yaml:"windows"`
 	Linux   ShellConfig `json:"linux" yaml:"linux"`
@@ -185,6 +194,27 @@ func (channelClose *ChannelClosed) Serialize(log logger.T) (result []byte, err e
 	return
 }
 
+// AgentJobPayload parallels the structure of an MDS message, so run command messages are parsed the same way
+// whether they are delivered over the control channel or polled from MDS.
+type AgentJobPayload struct {
+	MessageId   string `json:"MessageId"`
+	Topic       string `json:"Topic"`
+	Payload     string `json:"Payload"`
+	Destination string `json:"Destination"`
+	CreatedDate string `json:"CreatedDate"`
+}
+
+// AgentJobAcknowledgeContent acknowledges a run command message, FailureType is set when the agent failed the message.
+type AgentJobAcknowledgeContent struct {
+	MessageId   string `json:"MessageId"`
+	FailureType string `json:"FailureType,omitempty"`
+}
+
+// AgentJobDeliveryContent tells the agent whether the service delivers run command messages over the control channel.
+type AgentJobDeliveryContent struct {
+	Enabled bool `json:"Enabled"`
+}
+
 // AgentTaskCompletePayload is sent by the agent to inform the task is complete and what the overall result was.
 type AgentTaskCompletePayload struct {
 	SchemaVersion    int    `json:"SchemaVersion"`
@@ -16,26 +16,33 @@ package controlchannel
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
-	"math/rand"
 	"path/filepath"
 	"strings"
+	"sync/atomic"
+	"time"
 
 	"github.com/aws/amazon-ssm-agent/agent/appconfig"
 	"github.com/aws/amazon-ssm-agent/agent/context"
 	"github.com/aws/amazon-ssm-agent/agent/contracts"
 	"github.com/aws/amazon-ssm-agent/agent/framework/processor"
+	"github.com/aws/amazon-ssm-agent/agent/health"
 	"github.com/aws/amazon-ssm-agent/agent/log"
 	"github.com/aws/amazon-ssm-agent/agent/platform"
+	"github.com/aws/amazon-ssm-agent/agent/runcommand/delivery"
+	mdsService "github.com/aws/amazon-ssm-agent/agent/runcommand/mds"
 	"github.com/aws/amazon-ssm-agent/agent/session/communicator"
+	"github.com/aws/amazon-ssm-agent/agent/session/communicator/websocketutil"
 	mgsConfig "github.com/aws/amazon-ssm-agent/agent/session/config"
 	mgsContracts "github.com/aws/amazon-ssm-agent/agent/session/contracts"
-	"github.com/aws/amazon-ssm-agent/agent/session/retry"
 	"github.com/aws/amazon-ssm-agent/agent/session/service"
 	telemetry "github.com/aws/amazon-ssm-agent/agent/session/telemetry"
 	"github.com/aws/amazon-ssm-agent/agent/times"
 	"github.com/aws/amazon-ssm-agent/agent/version"
+	"github.com/aws/amazon-ssm-agent/agent/watchdog"
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ssmmds"
 	"github.com/gorilla/websocket"
 	"github.com/twinj/uuid"
 )
@@ -57,6 +64,12 @@ type ControlChannel struct {
 	Service           service.Service
 	AuditLogScheduler telemetry.IAuditLogTelemetry
 	channelType       string
+	// receiveCommands is true when run command messages are requested over the control channel
+	receiveCommands bool
+	// endpoint is the message gateway service endpoint of the current connection
+	endpoint     string
+	reconnecting int32
+	closed       int32
 }
 
 // Initialize populates controlchannel object and opens controlchannel to communicate with mgs.
@@ -73,7 +86,9 @@ func (controlChannel *ControlChannel) Initialize(context context.T,
 	controlChannel.ChannelId = instanceId
 	controlChannel.channelType = mgsConfig.RoleSubscribe
 	controlChannel.Processor = processor
-	controlChannel.wsChannel = &communicator.WebSocketChannel{}
+	controlChannel.receiveCommands = context.AppConfig().Mds.CommandDelivery == appconfig.CommandDeliveryPush
+	// the pongs are only read while incoming messages are handled, they are the progress of the channel for the watchdog
+	controlChannel.wsChannel = &communicator.WebSocketChannel{OnPong: func() { watchdog.Beat(connectionName) }}
 	controlChannel.AuditLogScheduler = telemetry.GetAuditLogTelemetryInstance(context, controlChannel.wsChannel)
 	log.Debugf("Initialized controlchannel for instance: %s", instanceId)
 }
@@ -99,52 +114,42 @@ func (controlChannel *ControlChannel) SetWebSocket(context context.T,
 	orchestrationRootDir := filepath.Join(appconfig.DefaultDataStorePath, instanceId, appconfig.DefaultSessionRootDirName, config.Agent.OrchestrationRootDir)
 
 	onMessageHandler := func(input []byte) {
-		controlChannelIncomingMessageHandler(context, processor, input, orchestrationRootDir, instanceId)
-	}
-	onErrorHandler := func(err error) {
-		callable := func() (channel interface{}, err error) {
-			uuid.SwitchFormat(uuid.CleanHyphen)
-			requestId := uuid.NewV4().String()
-			tokenValue, err := getControlChannelToken(log, mgsService, controlChannel.ChannelId, requestId)
-			if err != nil {
-				return controlChannel, err
-			}
-			controlChannel.wsChannel.SetChannelToken(tokenValue)
-			if err := controlChannel.Reconnect(log); err != nil {
-				return controlChannel, err
-			}
-			return controlChannel, nil
-		}
-		retryer := retry.ExponentialRetryer{
-			CallableFunc:        callable,
-			GeometricRatio:      mgsConfig.RetryGeometricRatio,
-			JitterRatio:         mgsConfig.RetryJitterRatio,
-			InitialDelayInMilli: rand.Intn(mgsConfig.ControlChannelRetryInitialDelayMillis) + mgsConfig.ControlChannelRetryInitialDelayMillis,
-			MaxDelayInMilli:     mgsConfig.ControlChannelRetryMaxIntervalMillis,
-			MaxAttempts:         mgsConfig.ControlChannelNumMaxRetries,
-		}
-		retryer.Init()
-		if _, err := retryer.Call(); err != nil {
-			// should never happen
-			log.Errorf("failed to reconnect to the controlchannel with error: %v", err)
-		}
-	}
-
-	if err := controlChannel.wsChannel.Initialize(context,
-		controlChannel.ChannelId,
-		mgsConfig.ControlChannel,
-		mgsConfig.RoleSubscribe,
-		tokenValue,
-		mgsService.GetRegion(),
-		mgsService.GetV4Signer(),
-		onMessageHandler,
-		onErrorHandler); err != nil {
+		controlChannelIncomingMessageHandler(context, controlChannel, processor, input, orchestrationRootDir, instanceId)
+	}
+	var onErrorHandler func(err error)
+	initialize := func(token string) error {
+		region := mgsService.GetRegion()
+		controlChannel.endpoint = mgsConfig.GetMgsEndpointFromRip(region)
+		return controlChannel.wsChannel.Initialize(context,
+			controlChannel.ChannelId,
+			mgsConfig.ControlChannel,
+			mgsConfig.RoleSubscribe,
+			token,
+			region,
+			mgsService.GetV4Signer(),
+			onMessageHandler,
+			onErrorHandler)
+	}
+	onErrorHandler = func(err error) {
+		controlChannel.reconnect(log, mgsService, initialize, err)
+	}
+
+	atomic.StoreInt32(&controlChannel.closed, 0)
+	if err := initialize(tokenValue); err != nil {
 		log.Errorf("failed to initialize websocket channel for controlchannel, error: %s", err)
 		return err
 	}
+	controlChannel.watch(mgsService, initialize)
 	return nil
 }
 
+// watch registers the controlchannel with the watchdog, which reconnects it when it stalls.
+func (controlChannel *ControlChannel) watch(mgsService service.Service, initialize func(token string) error) {
+	watchdog.Register(connectionName, mgsConfig.WebSocketPingInterval, func(log log.T) {
+		controlChannel.reconnect(log, mgsService, initialize, errStalled)
+	})
+}
+
 // SendMessage sends a message to the service through controlchannel.
 func (controlChannel *ControlChannel) SendMessage(log log.T, input []byte, inputType int) error {
 	return controlChannel.wsChannel.SendMessage(log, input, inputType)
@@ -154,12 +159,15 @@ func (controlChannel *ControlChannel) SendMessage(log log.T, input []byte, input
 func (controlChannel *ControlChannel) Reconnect(log log.T) error {
 	log.Debugf("Reconnecting controlchannel %s", controlChannel.ChannelId)
 
+	// run command messages are polled until the service turns delivery on for the new connection
+	delivery.SetPushChannel(log, nil)
+
 	if err := controlChannel.wsChannel.Close(log); err != nil {
 		log.Warnf("closing controlchannel failed with error: %s", err)
 	}
 
 	if err := controlChannel.Open(log); err != nil {
-		return fmt.Errorf("failed to reconnect controlchannel with error: %s", err)
+		return fmt.Errorf("failed to reconnect controlchannel with error: %w", err)
 	}
 
 	log.Debugf("Successfully reconnected with controlchannel with type %s", controlChannel.channelType)
@@ -169,6 +177,10 @@ func (controlChannel *ControlChannel) Reconnect(log log.T) error {
 // Close closes controlchannel - its web socket connection.
 func (controlChannel *ControlChannel) Close(log log.T) error {
 	log.Infof("Closing controlchannel with channel Id %s", controlChannel.ChannelId)
+	atomic.StoreInt32(&controlChannel.closed, 1)
+	watchdog.Unregister(connectionName)
+	health.ReportDisconnected(connectionName, nil)
+	delivery.SetPushChannel(log, nil)
 	if controlChannel.wsChannel != nil {
 		return controlChannel.wsChannel.Close(log)
 	}
@@ -181,7 +193,7 @@ func (controlChannel *ControlChannel) Close(log log.T) error {
 // Open opens a websocket connection and sends the token for service to acknowledge the connection.
 func (controlChannel *ControlChannel) Open(log log.T) error {
 	if err := controlChannel.wsChannel.Open(log); err != nil {
-		return fmt.Errorf("failed to connect controlchannel with error: %s", err)
+		return fmt.Errorf("failed to connect controlchannel with error: %w", err)
 	}
 
 	uuid.SwitchFormat(uuid.CleanHyphen)
@@ -196,6 +208,9 @@ func (controlChannel *ControlChannel) Open(log log.T) error {
 		AgentVersion:         aws.String(version.Version),
 		PlatformType:         aws.String(instancePlatformType),
 	}
+	if controlChannel.receiveCommands {
+		openControlChannelInput.AgentCapabilities = []*string{aws.String(mgsContracts.AgentJobCapability)}
+	}
 
 	jsonValue, err := json.Marshal(openControlChannelInput)
 	if err != nil {
@@ -203,13 +218,49 @@ func (controlChannel *ControlChannel) Open(log log.T) error {
 	}
 
 	if err = controlChannel.SendMessage(log, jsonValue, websocket.TextMessage); err == nil {
+		health.ReportConnected(connectionName, controlChannel.endpoint)
 		controlChannel.AuditLogScheduler.SendAuditMessage()
 	}
 	return err
 }
 
+// AcknowledgeMessage acknowledges a run command message delivered over the control channel.
+func (controlChannel *ControlChannel) AcknowledgeMessage(log log.T, messageID string) error {
+	return controlChannel.sendAgentJobAcknowledge(log, mgsContracts.AgentJobAcknowledgeContent{MessageId: messageID})
+}
+
+// FailMessage tells the service a run command message delivered over the control channel could not be processed.
+func (controlChannel *ControlChannel) FailMessage(log log.T, messageID string, failureType mdsService.FailureType) error {
+	return controlChannel.sendAgentJobAcknowledge(log, mgsContracts.AgentJobAcknowledgeContent{MessageId: messageID, FailureType: string(failureType)})
+}
+
+// sendAgentJobAcknowledge sends an agent_job_ack message for a run command message.
+func (controlChannel *ControlChannel) sendAgentJobAcknowledge(log log.T, content mgsContracts.AgentJobAcknowledgeContent) error {
+	payload, err := json.Marshal(content)
+	if err != nil {
+		return fmt.Errorf("cannot marshal agent job acknowledge content: %v", err)
+	}
+
+	uuid.SwitchFormat(uuid.CleanHyphen)
+	agentMessage := &mgsContracts.AgentMessage{
+		MessageType:    mgsContracts.AgentJobAcknowledgeMessage,
+		SchemaVersion:  1,
+		CreatedDate:    uint64(time.Now().UnixNano() / 1000000),
+		SequenceNumber: 0,
+		Flags:          0,
+		MessageId:      uuid.NewV4(),
+		Payload:        payload,
+	}
+	message, err := agentMessage.Serialize(log)
+	if err != nil {
+		return err
+	}
+	return controlChannel.SendMessage(log, message, websocket.BinaryMessage)
+}
+
 // controlChannelIncomingMessageHandler handles the incoming messages coming to the agent.
 func controlChannelIncomingMessageHandler(context context.T,
+	controlChannel *ControlChannel,
 	processor processor.Processor,
 	rawMessage []byte,
 	orchestrationRootDir string,
@@ -229,16 +280,30 @@ func controlChannelIncomingMessageHandler(context context.T,
 	}
 
 	if agentMessage.MessageType == mgsContracts.InteractiveShellMessage {
+		controlChannel.prewarmDataChannel(context)
 		uuid.SwitchFormat(uuid.CleanHyphen)
 		clientId := uuid.NewV4().String()
 		return sendStartSessionMessageToProcessor(processor, context, agentMessage, orchestrationRootDir, instanceId, clientId)
 	} else if agentMessage.MessageType == mgsContracts.ChannelClosedMessage {
 		return sendTerminateSessionMessageToProcessor(processor, context, instanceId, *agentMessage)
+	} else if agentMessage.MessageType == mgsContracts.AgentJobDeliveryMessage {
+		return updateAgentJobDelivery(context, controlChannel, *agentMessage)
+	} else if agentMessage.MessageType == mgsContracts.AgentJobMessage {
+		return deliverAgentJobMessage(context, *agentMessage)
 	}
 
 	return fmt.Errorf("invalid message type: %s", agentMessage.MessageType)
 }
 
+// prewarmDataChannel connects to the message gateway service while the session is started,
+// the session opens its data channel to the endpoint of the control channel
+func (controlChannel *ControlChannel) prewarmDataChannel(context context.T) {
+	if controlChannel == nil || controlChannel.endpoint == "" || !context.AppConfig().Network.PrewarmConnections {
+		return
+	}
+	websocketutil.Prewarm(context.Log(), mgsConfig.WebSocketPrefix+controlChannel.endpoint)
+}
+
 // sendStartSessionMessageToProcessor sends a StartSession message to the processor.
 func sendStartSessionMessageToProcessor(
 	processor processor.Processor,
@@ -307,6 +372,48 @@ func sendTerminateSessionMessageToProcessor(
 	return nil
 }
 
+// updateAgentJobDelivery turns receiving run command messages over the control channel on or off.
+// Delivery is only turned on when the agent requested it, otherwise the message is ignored and MDS is polled.
+func updateAgentJobDelivery(context context.T, controlChannel *ControlChannel, agentMessage mgsContracts.AgentMessage) error {
+	log := context.Log()
+
+	content := &mgsContracts.AgentJobDeliveryContent{}
+	if err := json.Unmarshal(agentMessage.Payload, content); err != nil {
+		return fmt.Errorf("cannot parse agent job delivery message %s: %v", agentMessage.MessageId, err)
+	}
+
+	if content.Enabled && controlChannel != nil && controlChannel.receiveCommands {
+		delivery.SetPushChannel(log, controlChannel)
+	} else {
+		delivery.SetPushChannel(log, nil)
+	}
+	return nil
+}
+
+// deliverAgentJobMessage hands a run command message to the run command service.
+// Messages that cannot be delivered are not acknowledged, so the service delivers them again through MDS.
+func deliverAgentJobMessage(context context.T, agentMessage mgsContracts.AgentMessage) error {
+	log := context.Log()
+
+	payload := &mgsContracts.AgentJobPayload{}
+	if err := json.Unmarshal(agentMessage.Payload, payload); err != nil {
+		return fmt.Errorf("cannot parse agent job message %s: %v", agentMessage.MessageId, err)
+	}
+	log.Debugf("Processing agent job message %s", payload.MessageId)
+
+	msg := &ssmmds.Message{
+		MessageId:   aws.String(payload.MessageId),
+		Topic:       aws.String(payload.Topic),
+		Payload:     aws.String(payload.Payload),
+		Destination: aws.String(payload.Destination),
+		CreatedDate: aws.String(payload.CreatedDate),
+	}
+	if err := delivery.Deliver(msg); err != nil {
+		return fmt.Errorf("cannot deliver agent job message %s: %v", payload.MessageId, err)
+	}
+	return nil
+}
+
 // getControlChannelToken calls CreateControlChannel to get the token for this instance
 func getControlChannelToken(log log.T,
 	mgsService service.Service,
@@ -319,8 +426,11 @@ func getControlChannelToken(log log.T,
 	}
 
 	createControlChannelOutput, err := mgsService.CreateControlChannel(log, createControlChannelInput, instanceId)
-	if err != nil || createControlChannelOutput == nil {
-		return "", fmt.Errorf("CreateControlChannel failed with error: %s", err)
+	if err != nil {
+		return "", fmt.Errorf("CreateControlChannel failed with error: %w", err)
+	}
+	if createControlChannelOutput == nil {
+		return "", errors.New("CreateControlChannel failed with an empty response")
 	}
 
 	log.Debug("Successfully get controlchannel token")
@@ -23,6 +23,8 @@ import (
 	"github.com/aws/amazon-ssm-agent/agent/context"
 	processorMock "github.com/aws/amazon-ssm-agent/agent/framework/processor/mock"
 	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/runcommand/delivery"
+	mdsService "github.com/aws/amazon-ssm-agent/agent/runcommand/mds"
 	communicatorMocks "github.com/aws/amazon-ssm-agent/agent/session/communicator/mocks"
 	mgsConfig "github.com/aws/amazon-ssm-agent/agent/session/config"
 	mgsContracts "github.com/aws/amazon-ssm-agent/agent/session/contracts"
@@ -30,6 +32,7 @@ import (
 	serviceMock "github.com/aws/amazon-ssm-agent/agent/session/service/mocks"
 	eventlogMock "github.com/aws/amazon-ssm-agent/agent/session/telemetry/mocks"
 	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/service/ssmmds"
 	v4 "github.com/aws/aws-sdk-go/aws/signer/v4"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
@@ -169,7 +172,7 @@ func TestControlChannelIncomingMessageHandlerForStartSessionMessage(t *testing.T
 	serializedBytes, _ := agentMessage.Serialize(log.NewMockLog())
 	mockProcessor.On("Submit", mock.Anything).Return(nil)
 
-	err := controlChannelIncomingMessageHandler(mockContext, mockProcessor, serializedBytes, "", "")
+	err := controlChannelIncomingMessageHandler(mockContext, nil, mockProcessor, serializedBytes, "", "")
 
 	assert.Nil(t, err)
 	mockProcessor.AssertExpectations(t)
@@ -194,12 +197,86 @@ func TestControlChannelIncomingMessageHandlerForTerminateSessionMessage(t *testi
 	serializedBytes, _ := agentMessage.Serialize(log.NewMockLog())
 	mockProcessor.On("Cancel", mock.Anything).Return(nil)
 
-	err := controlChannelIncomingMessageHandler(mockContext, mockProcessor, serializedBytes, "", "")
+	err := controlChannelIncomingMessageHandler(mockContext, nil, mockProcessor, serializedBytes, "", "")
 
 	assert.Nil(t, err)
 	mockProcessor.AssertExpectations(t)
 }
 
+func TestControlChannelIncomingMessageHandlerForAgentJobMessage(t *testing.T) {
+	controlChannel := getControlChannel()
+	controlChannel.receiveCommands = true
+	wsChannel := &communicatorMocks.IWebSocketChannel{}
+	wsChannel.On("SendMessage", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	controlChannel.wsChannel = wsChannel
+
+	var delivered []*ssmmds.Message
+	delivery.RegisterHandler(func(channel delivery.Channel, msg *ssmmds.Message) {
+		assert.NoError(t, channel.AcknowledgeMessage(mockLog, *msg.MessageId))
+		delivered = append(delivered, msg)
+	}, nil)
+	defer delivery.RegisterHandler(nil, nil)
+	defer delivery.SetPushChannel(mockLog, nil)
+
+	u, _ := uuid.Parse(messageId)
+	jobPayload, _ := json.Marshal(mgsContracts.AgentJobPayload{
+		MessageId:   "aws.ssm.sendCommand.11111111-2222-3333-4444-555555555555.i-1234",
+		Topic:       "aws.ssm.sendCommand.test",
+		Payload:     "{}",
+		Destination: instanceId,
+		CreatedDate: "2020-06-01T00:00:00.000Z",
+	})
+	jobMessage := &mgsContracts.AgentMessage{
+		MessageType:   mgsContracts.AgentJobMessage,
+		SchemaVersion: schemaVersion,
+		CreatedDate:   createdDate,
+		MessageId:     u,
+		Payload:       jobPayload,
+	}
+	serializedJob, _ := jobMessage.Serialize(log.NewMockLog())
+
+	// messages are not accepted before the service turns delivery on
+	assert.Error(t, controlChannelIncomingMessageHandler(mockContext, controlChannel, mockProcessor, serializedJob, "", ""))
+
+	deliveryPayload, _ := json.Marshal(mgsContracts.AgentJobDeliveryContent{Enabled: true})
+	deliveryMessage := &mgsContracts.AgentMessage{
+		MessageType:   mgsContracts.AgentJobDeliveryMessage,
+		SchemaVersion: schemaVersion,
+		CreatedDate:   createdDate,
+		MessageId:     u,
+		Payload:       deliveryPayload,
+	}
+	serializedDelivery, _ := deliveryMessage.Serialize(log.NewMockLog())
+	assert.NoError(t, controlChannelIncomingMessageHandler(mockContext, controlChannel, mockProcessor, serializedDelivery, "", ""))
+	assert.True(t, delivery.PushAvailable())
+
+	assert.NoError(t, controlChannelIncomingMessageHandler(mockContext, controlChannel, mockProcessor, serializedJob, "", ""))
+	assert.Len(t, delivered, 1)
+	assert.Equal(t, "aws.ssm.sendCommand.test", *delivered[0].Topic)
+	assert.Equal(t, instanceId, *delivered[0].Destination)
+	wsChannel.AssertNumberOfCalls(t, "SendMessage", 1)
+}
+
+func TestAgentJobAcknowledge(t *testing.T) {
+	controlChannel := getControlChannel()
+	wsChannel := &communicatorMocks.IWebSocketChannel{}
+	var sent [][]byte
+	wsChannel.On("SendMessage", mock.Anything, mock.Anything, mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+		sent = append(sent, args.Get(1).([]byte))
+	})
+	controlChannel.wsChannel = wsChannel
+
+	assert.NoError(t, controlChannel.FailMessage(mockLog, "message-id", mdsService.InternalHandlerException))
+	assert.Len(t, sent, 1)
+
+	agentMessage := &mgsContracts.AgentMessage{}
+	assert.NoError(t, agentMessage.Deserialize(mockLog, sent[0]))
+	assert.Equal(t, mgsContracts.AgentJobAcknowledgeMessage, agentMessage.MessageType)
+	content := mgsContracts.AgentJobAcknowledgeContent{}
+	assert.NoError(t, json.Unmarshal(agentMessage.Payload, &content))
+	assert.Equal(t, mgsContracts.AgentJobAcknowledgeContent{MessageId: "message-id", FailureType: "InternalHandlerException"}, content)
+}
+
 func TestInitializeForContainer(t *testing.T) {
 	controlChannel := getControlChannel()
 	mockInstanceId := "9781c2480-edd4cdb9a93f6-3cb662a5d3"
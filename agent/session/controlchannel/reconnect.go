@@ -0,0 +1,128 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package controlchannel
+
+import (
+	"errors"
+	"math/rand"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/health"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/network/failover"
+	"github.com/aws/amazon-ssm-agent/agent/session/communicator/websocketutil"
+	mgsConfig "github.com/aws/amazon-ssm-agent/agent/session/config"
+	"github.com/aws/amazon-ssm-agent/agent/session/retry"
+	"github.com/aws/amazon-ssm-agent/agent/session/service"
+	"github.com/aws/amazon-ssm-agent/agent/watchdog"
+	"github.com/twinj/uuid"
+)
+
+// connectionName is the name of the controlchannel connection in the health reports and the watchdog.
+const connectionName = "controlchannel"
+
+var reconnectSleep = time.Sleep
+
+// errStalled is the cause of the reconnect when the watchdog found the controlchannel stalled.
+var errStalled = errors.New("controlchannel made no progress")
+
+// reconnect reconnects the controlchannel after its connection broke.
+// Attempts are retried with jittered exponential backoff until one succeeds or the controlchannel is closed.
+// Every attempt builds the websocket url again, so an endpoint of a failover region is used once the current one is unreachable.
+func (controlChannel *ControlChannel) reconnect(log log.T, mgsService service.Service, initialize func(token string) error, cause error) {
+	if !atomic.CompareAndSwapInt32(&controlChannel.reconnecting, 0, 1) {
+		log.Debugf("Controlchannel %s is already reconnecting", controlChannel.ChannelId)
+		return
+	}
+	defer atomic.StoreInt32(&controlChannel.reconnecting, 0)
+
+	log.Warnf("Controlchannel connection broke, reconnecting: %v", cause)
+	health.ReportDisconnected(connectionName, cause)
+
+	retryer := retry.ExponentialRetryer{
+		GeometricRatio:      mgsConfig.RetryGeometricRatio,
+		JitterRatio:         mgsConfig.RetryJitterRatio,
+		InitialDelayInMilli: rand.Intn(mgsConfig.ControlChannelRetryInitialDelayMillis) + mgsConfig.ControlChannelRetryInitialDelayMillis,
+		MaxDelayInMilli:     mgsConfig.ControlChannelRetryMaxIntervalMillis,
+	}
+	retryer.Init()
+
+	for attempt := 0; atomic.LoadInt32(&controlChannel.closed) == 0; {
+		// reconnect attempts are progress, the watchdog does not restart a channel that waits to reconnect
+		watchdog.Beat(connectionName)
+		err := controlChannel.reconnectOnce(log, mgsService, initialize)
+		if err == nil {
+			return
+		}
+		log.Warnf("Failed to reconnect controlchannel to %s: %v", controlChannel.endpoint, err)
+		health.ReportConnectionFailure(connectionName, controlChannel.endpoint, err)
+
+		if isForbidden(err) {
+			// the signing credentials expired or were rotated, the next attempt gets new ones together with a new token
+			log.Info("Controlchannel was rejected with 403, refreshing credentials")
+			if signer := mgsService.GetV4Signer(); signer != nil && signer.Credentials != nil {
+				signer.Credentials.Expire()
+			}
+		}
+
+		sleep, exceedMaxDelay := retryer.NextSleepTime(attempt)
+		if !exceedMaxDelay {
+			attempt++
+		}
+		reconnectSleep(sleep)
+	}
+	log.Infof("Controlchannel %s is closed, stopped reconnecting", controlChannel.ChannelId)
+}
+
+// reconnectOnce gets a new token and opens a new connection in the current region of the message gateway service.
+func (controlChannel *ControlChannel) reconnectOnce(log log.T, mgsService service.Service, initialize func(token string) error) error {
+	uuid.SwitchFormat(uuid.CleanHyphen)
+	requestId := uuid.NewV4().String()
+	tokenValue, err := getControlChannelToken(log, mgsService, controlChannel.ChannelId, requestId)
+	if err != nil {
+		return err
+	}
+
+	region := mgsService.GetRegion()
+	if err = initialize(tokenValue); err != nil {
+		return err
+	}
+	err = controlChannel.Reconnect(log)
+	failover.Report(mgsConfig.ServiceName, region, isUnreachable(err))
+	return err
+}
+
+// isForbidden returns true when the service rejected the credentials or the token of the controlchannel.
+func isForbidden(err error) bool {
+	var handshakeErr *websocketutil.HandshakeError
+	if errors.As(err, &handshakeErr) {
+		return handshakeErr.StatusCode == http.StatusForbidden
+	}
+	var requestErr *service.RequestError
+	if errors.As(err, &requestErr) {
+		return requestErr.StatusCode == http.StatusForbidden
+	}
+	return false
+}
+
+// isUnreachable returns true when a websocket connection failed because the endpoint could not be reached.
+func isUnreachable(err error) bool {
+	var handshakeErr *websocketutil.HandshakeError
+	if errors.As(err, &handshakeErr) {
+		return handshakeErr.StatusCode >= http.StatusInternalServerError
+	}
+	return failover.IsUnreachable(err)
+}
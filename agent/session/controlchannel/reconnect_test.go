@@ -0,0 +1,127 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package controlchannel
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/health"
+	communicatorMocks "github.com/aws/amazon-ssm-agent/agent/session/communicator/mocks"
+	"github.com/aws/amazon-ssm-agent/agent/session/communicator/websocketutil"
+	"github.com/aws/amazon-ssm-agent/agent/session/service"
+	serviceMock "github.com/aws/amazon-ssm-agent/agent/session/service/mocks"
+	eventlogMock "github.com/aws/amazon-ssm-agent/agent/session/telemetry/mocks"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	v4 "github.com/aws/aws-sdk-go/aws/signer/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func mockReconnectSleep(sleeps *[]time.Duration) func() {
+	original := reconnectSleep
+	reconnectSleep = func(d time.Duration) {
+		*sleeps = append(*sleeps, d)
+	}
+	return func() { reconnectSleep = original }
+}
+
+func TestReconnect_RetriesUntilConnected(t *testing.T) {
+	var sleeps []time.Duration
+	defer mockReconnectSleep(&sleeps)()
+
+	wsChannel := &communicatorMocks.IWebSocketChannel{}
+	mgsService := &serviceMock.Service{}
+	eventLog := &eventlogMock.IAuditLogTelemetry{}
+	credentialsProvider := &credentials.StaticProvider{Value: credentials.Value{AccessKeyID: "AKID", SecretAccessKey: "SECRET"}}
+	reconnectSigner := &v4.Signer{Credentials: credentials.NewCredentials(credentialsProvider)}
+	reconnectSigner.Credentials.Get()
+
+	controlChannel := &ControlChannel{wsChannel: wsChannel, ChannelId: instanceId, AuditLogScheduler: eventLog}
+	mgsService.On("CreateControlChannel", mock.Anything, mock.Anything, instanceId).Return(nil, &service.RequestError{StatusCode: http.StatusInternalServerError}).Once()
+	mgsService.On("CreateControlChannel", mock.Anything, mock.Anything, instanceId).Return(&service.CreateControlChannelOutput{TokenValue: &token}, nil)
+	mgsService.On("GetRegion").Return(region)
+	mgsService.On("GetV4Signer").Return(reconnectSigner)
+	wsChannel.On("Close", mock.Anything).Return(nil)
+	wsChannel.On("Open", mock.Anything).Return(&websocketutil.HandshakeError{StatusCode: http.StatusForbidden, Err: errors.New("bad handshake")}).Once()
+	wsChannel.On("Open", mock.Anything).Return(nil)
+	wsChannel.On("GetChannelToken").Return(token)
+	wsChannel.On("SendMessage", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	eventLog.On("SendAuditMessage")
+
+	var tokens []string
+	initialize := func(token string) error {
+		tokens = append(tokens, token)
+		controlChannel.endpoint = "ssmmessages.us-east-1.amazonaws.com"
+		return nil
+	}
+	controlChannel.reconnect(mockLog, mgsService, initialize, errors.New("connection reset"))
+
+	assert.Equal(t, []string{token, token}, tokens)
+	assert.Equal(t, 2, len(sleeps))
+	assert.True(t, reconnectSigner.Credentials.IsExpired(), "credentials are refreshed after a 403")
+	connection, found := health.Connection(connectionName)
+	assert.True(t, found)
+	assert.True(t, connection.Connected)
+	assert.Equal(t, 0, connection.ConsecutiveFailures)
+	assert.Contains(t, connection.LastError, "status code: 403")
+	wsChannel.AssertExpectations(t)
+	mgsService.AssertExpectations(t)
+}
+
+func TestReconnect_StopsWhenClosed(t *testing.T) {
+	var sleeps []time.Duration
+	defer mockReconnectSleep(&sleeps)()
+
+	mgsService := &serviceMock.Service{}
+	mgsService.On("CreateControlChannel", mock.Anything, mock.Anything, instanceId).Return(nil, errors.New("no route to host"))
+	mgsService.On("GetV4Signer").Return(nil)
+	controlChannel := &ControlChannel{ChannelId: instanceId}
+	reconnectSleep = func(d time.Duration) {
+		sleeps = append(sleeps, d)
+		controlChannel.closed = 1
+	}
+
+	controlChannel.reconnect(mockLog, mgsService, func(string) error { return nil }, errors.New("connection reset"))
+
+	assert.Equal(t, 1, len(sleeps))
+	mgsService.AssertNumberOfCalls(t, "CreateControlChannel", 1)
+}
+
+func TestReconnect_SkipsWhenAlreadyReconnecting(t *testing.T) {
+	mgsService := &serviceMock.Service{}
+	controlChannel := &ControlChannel{ChannelId: instanceId, reconnecting: 1}
+
+	controlChannel.reconnect(mockLog, mgsService, func(string) error { return nil }, errors.New("connection reset"))
+
+	mgsService.AssertNotCalled(t, "CreateControlChannel", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestIsForbidden(t *testing.T) {
+	forbiddenHandshake := &websocketutil.HandshakeError{StatusCode: http.StatusForbidden, Err: errors.New("bad handshake")}
+	assert.True(t, isForbidden(fmt.Errorf("failed to reconnect controlchannel with error: %w", forbiddenHandshake)))
+	assert.True(t, isForbidden(fmt.Errorf("CreateControlChannel failed with error: %w", &service.RequestError{StatusCode: http.StatusForbidden})))
+	assert.False(t, isForbidden(&websocketutil.HandshakeError{StatusCode: http.StatusBadGateway, Err: errors.New("bad handshake")}))
+	assert.False(t, isForbidden(errors.New("403")))
+}
+
+func TestIsUnreachable(t *testing.T) {
+	assert.True(t, isUnreachable(&websocketutil.HandshakeError{StatusCode: http.StatusBadGateway, Err: errors.New("bad handshake")}))
+	assert.False(t, isUnreachable(&websocketutil.HandshakeError{StatusCode: http.StatusForbidden, Err: errors.New("bad handshake")}))
+	assert.True(t, isUnreachable(errors.New("dial tcp: no route to host")))
+	assert.False(t, isUnreachable(nil))
+}
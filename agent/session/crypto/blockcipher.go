@@ -22,10 +22,61 @@ import (
 	"io"
 
 	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/secret"
+	"github.com/aws/aws-sdk-go/aws"
 )
 
 const nonceSize = 12
 
+const (
+	// encryptionContextSessionIdKey and encryptionContextTargetIdKey are always in the encryption context of the data key
+	encryptionContextSessionIdKey = "aws:ssm:SessionId"
+	encryptionContextTargetIdKey  = "aws:ssm:TargetId"
+	// maxGrantTokens is the number of grant tokens KMS accepts in a request
+	maxGrantTokens = 10
+)
+
+// KMSParameters are the key of a session and the caller controlled parameters of its KMS requests.
+// The encryption context is added to the session and target ids, so key policies can require more conditions,
+// such as the account, and grant tokens give access to the key through grants that are not yet consistent.
+type KMSParameters struct {
+	KeyId             string
+	EncryptionContext map[string]string
+	GrantTokens       []string
+}
+
+// Validate checks the encryption context does not replace the session and target ids and the number of grant tokens
+func (parameters KMSParameters) Validate() error {
+	for key := range parameters.EncryptionContext {
+		if key == "" {
+			return fmt.Errorf("encryption context keys cannot be empty")
+		}
+		if key == encryptionContextSessionIdKey || key == encryptionContextTargetIdKey {
+			return fmt.Errorf("encryption context key %v is set by the agent", key)
+		}
+	}
+	if len(parameters.GrantTokens) > maxGrantTokens {
+		return fmt.Errorf("at most %v grant tokens are allowed, got %v", maxGrantTokens, len(parameters.GrantTokens))
+	}
+	for _, grantToken := range parameters.GrantTokens {
+		if grantToken == "" {
+			return fmt.Errorf("grant tokens cannot be empty")
+		}
+	}
+	return nil
+}
+
+// encryptionContext returns the encryption context of the data key of a session
+func (parameters KMSParameters) encryptionContext(sessionId string, instanceId string) map[string]*string {
+	encryptionContext := make(map[string]*string, len(parameters.EncryptionContext)+2)
+	for key, value := range parameters.EncryptionContext {
+		encryptionContext[key] = aws.String(value)
+	}
+	encryptionContext[encryptionContextSessionIdKey] = aws.String(sessionId)
+	encryptionContext[encryptionContextTargetIdKey] = aws.String(instanceId)
+	return encryptionContext
+}
+
 type IBlockCipher interface {
 	UpdateEncryptionKey(log log.T, cipherTextKey []byte, sessionId string, instanceId string) error
 	EncryptWithAESGCM(plainText []byte) (cipherText []byte, err error)
@@ -35,30 +86,31 @@ type IBlockCipher interface {
 }
 
 type BlockCipher struct {
-	kmsKeyId         string
+	kmsParameters    KMSParameters
 	kmsService       IKMSService
 	cipherTextKey    []byte
-	encryptionKey    []byte
-	decryptionKey    []byte
 	encryptionCipher cipher.AEAD
 	decryptionCipher cipher.AEAD
 }
 
 // NewBlockCipher creates a new block cipher
-func NewBlockCipher(log log.T, kmsKeyId string) (blockCipher *BlockCipher, err error) {
+func NewBlockCipher(log log.T, kmsParameters KMSParameters) (blockCipher *BlockCipher, err error) {
+	if err = kmsParameters.Validate(); err != nil {
+		return nil, fmt.Errorf("Invalid KMS parameters, %v", err)
+	}
 	var kmsService *KMSService
 	if kmsService, err = NewKMSService(log); err != nil {
 		return nil, fmt.Errorf("Unable to get new KMSService, %v", err)
 	}
-	return NewBlockCipherKMS(log, kmsKeyId, kmsService)
+	return NewBlockCipherKMS(log, kmsParameters, kmsService)
 }
 
 // NewBlockCipherKMS creates a new block cipher with a provided IKMService instance
-func NewBlockCipherKMS(log log.T, kmsKeyId string, kmsService IKMSService) (blockCipher *BlockCipher, err error) {
+func NewBlockCipherKMS(log log.T, kmsParameters KMSParameters, kmsService IKMSService) (blockCipher *BlockCipher, err error) {
 	// NewBlockCipher creates a new instance of BlockCipher
 	blockCipher = &BlockCipher{
-		kmsKeyId:   kmsKeyId,
-		kmsService: kmsService,
+		kmsParameters: kmsParameters,
+		kmsService:    kmsService,
 	}
 	return blockCipher, nil
 }
@@ -70,25 +122,24 @@ func (blockCipher *BlockCipher) UpdateEncryptionKey(log log.T, cipherTextBlob []
 		plainTextKey []byte
 		err          error
 	)
-	var encryptionContext = make(map[string]*string)
-	const encryptionContextSessionIdKey = "aws:ssm:SessionId"
-	encryptionContext[encryptionContextSessionIdKey] = &sessionId
-	const encryptionContextTargetIdKey = "aws:ssm:TargetId"
-	encryptionContext[encryptionContextTargetIdKey] = &instanceId
-
-	if plainTextKey, err = blockCipher.kmsService.Decrypt(cipherTextBlob, encryptionContext); err != nil {
+	encryptionContext := blockCipher.kmsParameters.encryptionContext(sessionId, instanceId)
+	if plainTextKey, err = blockCipher.kmsService.Decrypt(cipherTextBlob, encryptionContext, blockCipher.kmsParameters.GrantTokens); err != nil {
 		return fmt.Errorf("Unable to retrieve data key, %v", err)
 	}
+	// the data key is only needed to expand the ciphers, it is zeroed once they are created
+	dataKey := secret.New(plainTextKey)
+	defer dataKey.Release()
+	if dataKey.Len() != int(KMSKeySizeInBytes) {
+		return fmt.Errorf("Unexpected data key size %v", dataKey.Len())
+	}
 	// cryptoKeySizeInBytes is half of PlainTextKey size fetched from KMS. PlainTextKey is split in two two halves of cryptoKeySizeInBytes
 	// First half will be used by agent for encryption and second half by clients like cli/console for encryption
 	cryptoKeySizeInBytes := KMSKeySizeInBytes / 2
 	blockCipher.cipherTextKey = cipherTextBlob
-	blockCipher.encryptionKey = plainTextKey[:cryptoKeySizeInBytes]
-	blockCipher.decryptionKey = plainTextKey[cryptoKeySizeInBytes:]
-	if blockCipher.encryptionCipher, err = getAEAD(blockCipher.encryptionKey); err != nil {
+	if blockCipher.encryptionCipher, err = getAEAD(dataKey.Bytes()[:cryptoKeySizeInBytes]); err != nil {
 		return err
 	}
-	if blockCipher.decryptionCipher, err = getAEAD(blockCipher.decryptionKey); err != nil {
+	if blockCipher.decryptionCipher, err = getAEAD(dataKey.Bytes()[cryptoKeySizeInBytes:]); err != nil {
 		return err
 	}
 	return nil
@@ -149,5 +200,5 @@ func (blockCipher *BlockCipher) GetCipherTextKey() (cipherTextKey []byte) {
 
 // GetKMSKeyId returns kmsKeyId from BlockCipher
 func (blockCipher *BlockCipher) GetKMSKeyId() (kmsKey string) {
-	return blockCipher.kmsKeyId
+	return blockCipher.kmsParameters.KeyId
 }
@@ -60,15 +60,15 @@ func TestBlockCipherTestSuite(t *testing.T) {
 // Testing Encrypt and Decrypt functions
 func (suite *BlockCipherTestSuite) TestEncryptDecrypt() {
 	var encryptionContext = map[string]*string{"aws:ssm:SessionId": &suite.sessionId, "aws:ssm:TargetId": &suite.instanceId}
-	suite.mockKMSService.On("Decrypt", suite.cipherTextKey, encryptionContext).Return(suite.plainTextKey, nil)
+	suite.mockKMSService.On("Decrypt", suite.cipherTextKey, encryptionContext, []string(nil)).Return(suite.plainTextKey, nil)
 
-	blockCipher, err := NewBlockCipherKMS(suite.mockLog, suite.kmsKeyId, &suite.mockKMSService)
+	blockCipher, err := NewBlockCipherKMS(suite.mockLog, KMSParameters{KeyId: suite.kmsKeyId}, &suite.mockKMSService)
 	assert.Nil(suite.T(), err)
 	err = blockCipher.UpdateEncryptionKey(suite.mockLog, suite.cipherTextKey, suite.sessionId, suite.instanceId)
 	assert.Nil(suite.T(), err)
 
 	// Create another cipher with flipped encryption/decryption keys
-	suite.mockKMSService.On("Decrypt", suite.cipherTextKeyFlipped, encryptionContext).Return(suite.plainTextKeyFlipped, nil)
+	suite.mockKMSService.On("Decrypt", suite.cipherTextKeyFlipped, encryptionContext, []string(nil)).Return(suite.plainTextKeyFlipped, nil)
 	blockCipherReversed := BlockCipher(*blockCipher)
 	err = blockCipherReversed.UpdateEncryptionKey(suite.mockLog, suite.cipherTextKeyFlipped, suite.sessionId, suite.instanceId)
 
@@ -88,6 +88,36 @@ func (suite *BlockCipherTestSuite) TestGetCipherTextKey() {
 }
 
 func (suite *BlockCipherTestSuite) TestGetKMSKeyId() {
-	var blockCipher IBlockCipher = &BlockCipher{kmsKeyId: suite.kmsKeyId}
+	var blockCipher IBlockCipher = &BlockCipher{kmsParameters: KMSParameters{KeyId: suite.kmsKeyId}}
 	assert.Equal(suite.T(), suite.kmsKeyId, blockCipher.GetKMSKeyId())
 }
+
+// Testing the caller encryption context and grant tokens are sent to KMS with the session and target ids
+func (suite *BlockCipherTestSuite) TestUpdateEncryptionKeyWithCallerParameters() {
+	kmsParameters := KMSParameters{
+		KeyId:             suite.kmsKeyId,
+		EncryptionContext: map[string]string{"aws:ssm:AccountId": "123456789012"},
+		GrantTokens:       []string{"grant-token"},
+	}
+	account := "123456789012"
+	var encryptionContext = map[string]*string{
+		"aws:ssm:SessionId": &suite.sessionId,
+		"aws:ssm:TargetId":  &suite.instanceId,
+		"aws:ssm:AccountId": &account,
+	}
+	suite.mockKMSService.On("Decrypt", suite.cipherTextKey, encryptionContext, []string{"grant-token"}).Return(suite.plainTextKey, nil)
+
+	blockCipher, err := NewBlockCipherKMS(suite.mockLog, kmsParameters, &suite.mockKMSService)
+	assert.Nil(suite.T(), err)
+	err = blockCipher.UpdateEncryptionKey(suite.mockLog, suite.cipherTextKey, suite.sessionId, suite.instanceId)
+	assert.Nil(suite.T(), err)
+	suite.mockKMSService.AssertExpectations(suite.T())
+}
+
+func (suite *BlockCipherTestSuite) TestValidateKMSParameters() {
+	assert.Nil(suite.T(), KMSParameters{KeyId: suite.kmsKeyId}.Validate())
+	assert.Nil(suite.T(), KMSParameters{EncryptionContext: map[string]string{"team": "ops"}, GrantTokens: []string{"token"}}.Validate())
+	assert.NotNil(suite.T(), KMSParameters{EncryptionContext: map[string]string{"aws:ssm:SessionId": "other"}}.Validate())
+	assert.NotNil(suite.T(), KMSParameters{EncryptionContext: map[string]string{"": "value"}}.Validate())
+	assert.NotNil(suite.T(), KMSParameters{GrantTokens: make([]string, maxGrantTokens+1)}.Validate())
+}
@@ -32,7 +32,8 @@ import (
 const KMSKeySizeInBytes int64 = 64
 
 type IKMSService interface {
-	Decrypt(cipherTextBlob []byte, encryptionContext map[string]*string) (plainText []byte, err error)
+	Decrypt(cipherTextBlob []byte, encryptionContext map[string]*string, grantTokens []string) (plainText []byte, err error)
+	GenerateDataKey(kmsKeyId string, encryptionContext map[string]*string, grantTokens []string) (cipherTextBlob []byte, plainText []byte, err error)
 }
 
 type KMSService struct {
@@ -68,12 +69,32 @@ func NewKMSService(log log.T) (kmsService *KMSService, err error) {
 }
 
 // Decrypt will get the plaintext key from KMS service
-func (kmsService *KMSService) Decrypt(cipherTextBlob []byte, encryptionContext map[string]*string) (plainText []byte, err error) {
-	output, err := kmsService.client.Decrypt(&kms.DecryptInput{
+func (kmsService *KMSService) Decrypt(cipherTextBlob []byte, encryptionContext map[string]*string, grantTokens []string) (plainText []byte, err error) {
+	input := &kms.DecryptInput{
 		CiphertextBlob:    cipherTextBlob,
-		EncryptionContext: encryptionContext})
+		EncryptionContext: encryptionContext}
+	if len(grantTokens) > 0 {
+		input.GrantTokens = aws.StringSlice(grantTokens)
+	}
+	output, err := kmsService.client.Decrypt(input)
 	if err != nil {
 		return nil, fmt.Errorf("Error when decrypting data key %s", err)
 	}
 	return output.Plaintext, nil
 }
+
+// GenerateDataKey gets a new data key of KMSKeySizeInBytes from KMS service, encrypted and in plaintext
+func (kmsService *KMSService) GenerateDataKey(kmsKeyId string, encryptionContext map[string]*string, grantTokens []string) (cipherTextBlob []byte, plainText []byte, err error) {
+	input := &kms.GenerateDataKeyInput{
+		KeyId:             aws.String(kmsKeyId),
+		NumberOfBytes:     aws.Int64(KMSKeySizeInBytes),
+		EncryptionContext: encryptionContext}
+	if len(grantTokens) > 0 {
+		input.GrantTokens = aws.StringSlice(grantTokens)
+	}
+	output, err := kmsService.client.GenerateDataKey(input)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Error when generating data key %s", err)
+	}
+	return output.CiphertextBlob, output.Plaintext, nil
+}
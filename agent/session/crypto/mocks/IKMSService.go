@@ -20,13 +20,13 @@ type IKMSService struct {
 	mock.Mock
 }
 
-// Decrypt provides a mock function with given fields: cipherTextBlob, encryptionContext
-func (_m *IKMSService) Decrypt(cipherTextBlob []byte, encryptionContext map[string]*string) ([]byte, error) {
-	ret := _m.Called(cipherTextBlob, encryptionContext)
+// Decrypt provides a mock function with given fields: cipherTextBlob, encryptionContext, grantTokens
+func (_m *IKMSService) Decrypt(cipherTextBlob []byte, encryptionContext map[string]*string, grantTokens []string) ([]byte, error) {
+	ret := _m.Called(cipherTextBlob, encryptionContext, grantTokens)
 
 	var r0 []byte
-	if rf, ok := ret.Get(0).(func([]byte, map[string]*string) []byte); ok {
-		r0 = rf(cipherTextBlob, encryptionContext)
+	if rf, ok := ret.Get(0).(func([]byte, map[string]*string, []string) []byte); ok {
+		r0 = rf(cipherTextBlob, encryptionContext, grantTokens)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).([]byte)
@@ -34,11 +34,43 @@ func (_m *IKMSService) Decrypt(cipherTextBlob []byte, encryptionContext map[stri
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func([]byte, map[string]*string) error); ok {
-		r1 = rf(cipherTextBlob, encryptionContext)
+	if rf, ok := ret.Get(1).(func([]byte, map[string]*string, []string) error); ok {
+		r1 = rf(cipherTextBlob, encryptionContext, grantTokens)
 	} else {
 		r1 = ret.Error(1)
 	}
 
 	return r0, r1
 }
+
+// GenerateDataKey provides a mock function with given fields: kmsKeyId, encryptionContext, grantTokens
+func (_m *IKMSService) GenerateDataKey(kmsKeyId string, encryptionContext map[string]*string, grantTokens []string) ([]byte, []byte, error) {
+	ret := _m.Called(kmsKeyId, encryptionContext, grantTokens)
+
+	var r0 []byte
+	if rf, ok := ret.Get(0).(func(string, map[string]*string, []string) []byte); ok {
+		r0 = rf(kmsKeyId, encryptionContext, grantTokens)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]byte)
+		}
+	}
+
+	var r1 []byte
+	if rf, ok := ret.Get(1).(func(string, map[string]*string, []string) []byte); ok {
+		r1 = rf(kmsKeyId, encryptionContext, grantTokens)
+	} else {
+		if ret.Get(1) != nil {
+			r1 = ret.Get(1).([]byte)
+		}
+	}
+
+	var r2 error
+	if rf, ok := ret.Get(2).(func(string, map[string]*string, []string) error); ok {
+		r2 = rf(kmsKeyId, encryptionContext, grantTokens)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
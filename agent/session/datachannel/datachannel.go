@@ -26,6 +26,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
 	"github.com/aws/amazon-ssm-agent/agent/context"
 	"github.com/aws/amazon-ssm-agent/agent/log"
 	"github.com/aws/amazon-ssm-agent/agent/platform"
@@ -68,7 +69,7 @@ type IDataChannel interface {
 	AddDataToIncomingMessageBuffer(streamMessage StreamingMessage)
 	RemoveDataFromIncomingMessageBuffer(sequenceNumber int64)
 	SkipHandshake(log log.T)
-	PerformHandshake(log log.T, kmsKeyId string, encryptionEnabled bool, sessionTypeRequest mgsContracts.SessionTypeRequest) (err error)
+	PerformHandshake(log log.T, kmsParameters crypto.KMSParameters, encryptionEnabled bool, sessionTypeRequest mgsContracts.SessionTypeRequest) (err error)
 	GetClientVersion() string
 }
 
@@ -233,14 +234,25 @@ func (dataChannel *DataChannel) Initialize(context context.T,
 	dataChannel.Pause = false
 	dataChannel.ExpectedSequenceNumber = 0
 	dataChannel.StreamDataSequenceNumber = 0
+	outgoingCapacity, incomingCapacity := mgsConfig.OutgoingMessageBufferCapacity, mgsConfig.IncomingMessageBufferCapacity
+	if lowMemory := context.AppConfig().LowMemory; lowMemory.Enabled {
+		outgoingCapacity, incomingCapacity = lowMemory.SessionBufferCapacity, lowMemory.SessionBufferCapacity
+	} else if context.AppConfig().Performance.Profile == appconfig.PerformanceProfileFootprint {
+		if outgoingCapacity > appconfig.DefaultPerformanceFootprintSessionBufferCapacity {
+			outgoingCapacity = appconfig.DefaultPerformanceFootprintSessionBufferCapacity
+		}
+		if incomingCapacity > appconfig.DefaultPerformanceFootprintSessionBufferCapacity {
+			incomingCapacity = appconfig.DefaultPerformanceFootprintSessionBufferCapacity
+		}
+	}
 	dataChannel.OutgoingMessageBuffer = ListMessageBuffer{
 		list.New(),
-		mgsConfig.OutgoingMessageBufferCapacity,
+		outgoingCapacity,
 		&sync.Mutex{},
 	}
 	dataChannel.IncomingMessageBuffer = MapMessageBuffer{
 		make(map[int64]StreamingMessage),
-		mgsConfig.IncomingMessageBufferCapacity,
+		incomingCapacity,
 		&sync.Mutex{},
 	}
 	dataChannel.RoundTripTime = float64(mgsConfig.DefaultRoundTripTime)
@@ -893,18 +905,18 @@ func (dataChannel *DataChannel) finalizeKMSEncryption(log log.T, actionResult js
 	return nil
 }
 
-var newBlockCipher = func(log log.T, kmsKeyId string) (blockCipher crypto.IBlockCipher, err error) {
-	return crypto.NewBlockCipher(log, kmsKeyId)
+var newBlockCipher = func(log log.T, kmsParameters crypto.KMSParameters) (blockCipher crypto.IBlockCipher, err error) {
+	return crypto.NewBlockCipher(log, kmsParameters)
 }
 
 // PerformHandshake performs handshake to share version string and encryption information with clients like cli/console
 func (dataChannel *DataChannel) PerformHandshake(log log.T,
-	kmsKeyId string,
+	kmsParameters crypto.KMSParameters,
 	encryptionEnabled bool,
 	sessionTypeRequest mgsContracts.SessionTypeRequest) (err error) {
 
 	if encryptionEnabled {
-		if dataChannel.blockCipher, err = newBlockCipher(log, kmsKeyId); err != nil {
+		if dataChannel.blockCipher, err = newBlockCipher(log, kmsParameters); err != nil {
 			return fmt.Errorf("Initializing BlockCipher failed: %s", err)
 		}
 	}
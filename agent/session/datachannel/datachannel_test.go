@@ -97,6 +97,52 @@ func TestInitialize(t *testing.T) {
 	assert.Equal(t, mgsConfig.DefaultTransmissionTimeout, dataChannel.RetransmissionTimeout)
 }
 
+func TestInitialize_LowMemory(t *testing.T) {
+	config := appconfig.SsmagentConfig{}
+	config.LowMemory.Enabled = true
+	config.LowMemory.SessionBufferCapacity = 500
+	lowMemoryContext := new(context.Mock)
+	lowMemoryContext.On("Log").Return(mockLog)
+	lowMemoryContext.On("AppConfig").Return(config)
+
+	dataChannel := &DataChannel{}
+	dataChannel.Initialize(
+		lowMemoryContext,
+		mockService,
+		sessionId,
+		clientId,
+		instanceId,
+		mgsConfig.RolePublishSubscribe,
+		mockCancelFlag,
+		inputStreamMessageHandler)
+
+	assert.Equal(t, 500, dataChannel.OutgoingMessageBuffer.Capacity)
+	assert.Equal(t, 500, dataChannel.IncomingMessageBuffer.Capacity)
+	assert.Equal(t, mgsConfig.OutgoingMessageBufferCapacity, getDataChannel().OutgoingMessageBuffer.Capacity)
+}
+
+func TestInitialize_FootprintProfile(t *testing.T) {
+	config := appconfig.SsmagentConfig{}
+	config.Performance.Profile = appconfig.PerformanceProfileFootprint
+	footprintContext := new(context.Mock)
+	footprintContext.On("Log").Return(mockLog)
+	footprintContext.On("AppConfig").Return(config)
+
+	dataChannel := &DataChannel{}
+	dataChannel.Initialize(
+		footprintContext,
+		mockService,
+		sessionId,
+		clientId,
+		instanceId,
+		mgsConfig.RolePublishSubscribe,
+		mockCancelFlag,
+		inputStreamMessageHandler)
+
+	assert.Equal(t, appconfig.DefaultPerformanceFootprintSessionBufferCapacity, dataChannel.OutgoingMessageBuffer.Capacity)
+	assert.Equal(t, appconfig.DefaultPerformanceFootprintSessionBufferCapacity, dataChannel.IncomingMessageBuffer.Capacity)
+}
+
 func TestSetWebSocket(t *testing.T) {
 	dataChannel := getDataChannel()
 
@@ -596,11 +642,11 @@ func TestDataCHannelHandshakeInitiate(t *testing.T) {
 	dataChannel.handshake.encryptionConfirmedChan <- true
 
 	// This is necessary because PerformHandshake initializes the cipher
-	newBlockCipher = func(log log.T, kmsKeyId string) (blockCipher crypto.IBlockCipher, err error) {
+	newBlockCipher = func(log log.T, kmsParameters crypto.KMSParameters) (blockCipher crypto.IBlockCipher, err error) {
 		return mockCipher, nil
 	}
 
-	err := dataChannel.PerformHandshake(mockLog, kmskey, true, sessionTypeRequest)
+	err := dataChannel.PerformHandshake(mockLog, crypto.KMSParameters{KeyId: kmskey}, true, sessionTypeRequest)
 
 	assert.Nil(t, err)
 	assert.True(t, dataChannel.handshake.complete)
@@ -0,0 +1,202 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package datachannel implements data channel which is used to interactively run commands.
+package datachannel
+
+import (
+	"container/list"
+	"encoding/json"
+	"errors"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/context"
+	"github.com/aws/amazon-ssm-agent/agent/ipc/localapi"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	mgsContracts "github.com/aws/amazon-ssm-agent/agent/session/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/session/crypto"
+	"github.com/aws/amazon-ssm-agent/agent/session/service"
+	"github.com/aws/amazon-ssm-agent/agent/task"
+	"github.com/twinj/uuid"
+)
+
+// loopbackInputDelay is how long input is held back when the plugin did not send output yet.
+// The service client resends the input a plugin rejects before it started, the loopback client sends it once.
+var loopbackInputDelay = time.Second
+
+// LoopbackDataChannel is the data channel of a session started through the local api,
+// it exchanges session frames with the agent, which relays them to the ssm-cli client.
+type LoopbackDataChannel struct {
+	context                   context.T
+	conn                      io.ReadWriteCloser
+	sessionId                 string
+	inputStreamMessageHandler InputStreamMessageHandler
+	cancelFlag                task.CancelFlag
+	writeLock                 sync.Mutex
+	encoder                   *json.Encoder
+	ready                     chan struct{}
+	readyOnce                 sync.Once
+	sequenceNumber            int64
+}
+
+// NewLoopbackDataChannel returns a data channel for a loopback session connected to the agent with conn
+func NewLoopbackDataChannel(context context.T,
+	conn io.ReadWriteCloser,
+	sessionId string,
+	inputStreamMessageHandler InputStreamMessageHandler,
+	cancelFlag task.CancelFlag) *LoopbackDataChannel {
+
+	dataChannel := &LoopbackDataChannel{
+		context:                   context,
+		conn:                      conn,
+		sessionId:                 sessionId,
+		inputStreamMessageHandler: inputStreamMessageHandler,
+		cancelFlag:                cancelFlag,
+		encoder:                   json.NewEncoder(conn),
+		ready:                     make(chan struct{}),
+	}
+	go dataChannel.readPump(context.Log())
+	return dataChannel
+}
+
+// readPump passes the frames of the client to the plugin once it is ready, a closed client cancels the session
+func (dataChannel *LoopbackDataChannel) readPump(log log.T) {
+	decoder := json.NewDecoder(dataChannel.conn)
+	select {
+	case <-dataChannel.ready:
+	case <-time.After(loopbackInputDelay):
+	}
+	for {
+		var frame localapi.SessionFrame
+		if err := decoder.Decode(&frame); err != nil {
+			if err != io.EOF {
+				log.Debugf("loopback session %s stopped reading: %v", dataChannel.sessionId, err)
+			}
+			dataChannel.cancelFlag.Set(task.Canceled)
+			return
+		}
+		dataChannel.sequenceNumber++
+		message := mgsContracts.AgentMessage{
+			MessageType:    mgsContracts.InputStreamDataMessage,
+			SchemaVersion:  schemaVersion,
+			CreatedDate:    uint64(time.Now().UnixNano() / 1000000),
+			SequenceNumber: dataChannel.sequenceNumber,
+			MessageId:      uuid.NewV4(),
+			PayloadType:    frame.PayloadType,
+			PayloadLength:  uint32(len(frame.Payload)),
+			Payload:        frame.Payload,
+		}
+		if err := dataChannel.inputStreamMessageHandler(log, message); err != nil {
+			log.Errorf("failed to process loopback session input: %v", err)
+		}
+	}
+}
+
+// send writes one frame to the client
+func (dataChannel *LoopbackDataChannel) send(frame localapi.SessionFrame) error {
+	dataChannel.writeLock.Lock()
+	defer dataChannel.writeLock.Unlock()
+	return dataChannel.encoder.Encode(frame)
+}
+
+// SendStreamDataMessage sends the output of the plugin to the client
+func (dataChannel *LoopbackDataChannel) SendStreamDataMessage(log log.T, payloadType mgsContracts.PayloadType, inputData []byte) error {
+	if len(inputData) == 0 {
+		return nil
+	}
+	dataChannel.readyOnce.Do(func() { close(dataChannel.ready) })
+	return dataChannel.send(localapi.SessionFrame{PayloadType: uint32(payloadType), Payload: inputData})
+}
+
+// SendAgentSessionStateMessage tells the client about the state of the session
+func (dataChannel *LoopbackDataChannel) SendAgentSessionStateMessage(log log.T, sessionStatus mgsContracts.SessionStatus) error {
+	return dataChannel.send(localapi.SessionFrame{SessionStatus: string(sessionStatus)})
+}
+
+// PerformHandshake completes immediately, the client of a loopback session does not negotiate encryption
+func (dataChannel *LoopbackDataChannel) PerformHandshake(log log.T, kmsParameters crypto.KMSParameters, encryptionEnabled bool, sessionTypeRequest mgsContracts.SessionTypeRequest) error {
+	if encryptionEnabled {
+		return errors.New("KMS encryption is not supported in loopback sessions")
+	}
+	log.Infof("Skipping handshake of loopback session %s.", dataChannel.sessionId)
+	return nil
+}
+
+// SkipHandshake does nothing, a loopback session has no handshake
+func (dataChannel *LoopbackDataChannel) SkipHandshake(log log.T) {}
+
+// GetClientVersion returns no version, plugins use the protocol every client supports
+func (dataChannel *LoopbackDataChannel) GetClientVersion() string {
+	return ""
+}
+
+// Close closes the connection to the agent, which ends the session of the client
+func (dataChannel *LoopbackDataChannel) Close(log log.T) error {
+	log.Infof("Closing loopback data channel of session %s", dataChannel.sessionId)
+	return dataChannel.conn.Close()
+}
+
+// Open does nothing, the connection is open when the data channel is created
+func (dataChannel *LoopbackDataChannel) Open(log log.T) error {
+	return nil
+}
+
+// Reconnect is not supported, the session ends with its connection
+func (dataChannel *LoopbackDataChannel) Reconnect(log log.T) error {
+	return errors.New("loopback sessions cannot reconnect")
+}
+
+// Initialize does nothing, a loopback data channel is set up by NewLoopbackDataChannel
+func (dataChannel *LoopbackDataChannel) Initialize(context context.T, mgsService service.Service, sessionId string, clientId string, instanceId string, role string, cancelFlag task.CancelFlag, inputStreamMessageHandler InputStreamMessageHandler) {
+}
+
+// SetWebSocket does nothing, a loopback session has no web socket
+func (dataChannel *LoopbackDataChannel) SetWebSocket(context context.T, mgsService service.Service, sessionId string, clientId string, onMessageHandler func(input []byte)) error {
+	return nil
+}
+
+// SendMessage does nothing, a loopback session only carries stream data and session states
+func (dataChannel *LoopbackDataChannel) SendMessage(log log.T, input []byte, inputType int) error {
+	return nil
+}
+
+// ResendStreamDataMessageScheduler does nothing, frames are not lost on a local connection
+func (dataChannel *LoopbackDataChannel) ResendStreamDataMessageScheduler(log log.T) error {
+	return nil
+}
+
+// ProcessAcknowledgedMessage does nothing, frames are not acknowledged
+func (dataChannel *LoopbackDataChannel) ProcessAcknowledgedMessage(log log.T, acknowledgeMessageContent mgsContracts.AcknowledgeContent) {
+}
+
+// SendAcknowledgeMessage does nothing, frames are not acknowledged
+func (dataChannel *LoopbackDataChannel) SendAcknowledgeMessage(log log.T, agentMessage mgsContracts.AgentMessage) error {
+	return nil
+}
+
+// AddDataToOutgoingMessageBuffer does nothing, frames are not buffered
+func (dataChannel *LoopbackDataChannel) AddDataToOutgoingMessageBuffer(streamMessage StreamingMessage) {
+}
+
+// RemoveDataFromOutgoingMessageBuffer does nothing, frames are not buffered
+func (dataChannel *LoopbackDataChannel) RemoveDataFromOutgoingMessageBuffer(streamMessageElement *list.Element) {
+}
+
+// AddDataToIncomingMessageBuffer does nothing, frames are not buffered
+func (dataChannel *LoopbackDataChannel) AddDataToIncomingMessageBuffer(streamMessage StreamingMessage) {
+}
+
+// RemoveDataFromIncomingMessageBuffer does nothing, frames are not buffered
+func (dataChannel *LoopbackDataChannel) RemoveDataFromIncomingMessageBuffer(sequenceNumber int64) {}
@@ -0,0 +1,91 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package datachannel implements data channel which is used to interactively run commands.
+package datachannel
+
+import (
+	"encoding/json"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/ipc/localapi"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	mgsContracts "github.com/aws/amazon-ssm-agent/agent/session/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/session/crypto"
+	"github.com/aws/amazon-ssm-agent/agent/task"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoopbackDataChannelSendsOutputFrames(t *testing.T) {
+	agent, client := net.Pipe()
+	defer client.Close()
+	handler := func(log log.T, message mgsContracts.AgentMessage) error { return nil }
+	dataChannel := NewLoopbackDataChannel(mockContext, agent, sessionId, handler, task.NewChanneledCancelFlag())
+	defer dataChannel.Close(mockLog)
+
+	go dataChannel.SendStreamDataMessage(mockLog, mgsContracts.Output, []byte("output"))
+	var frame localapi.SessionFrame
+	assert.NoError(t, json.NewDecoder(client).Decode(&frame))
+	assert.Equal(t, uint32(mgsContracts.Output), frame.PayloadType)
+	assert.Equal(t, []byte("output"), frame.Payload)
+}
+
+func TestLoopbackDataChannelPassesInputToHandler(t *testing.T) {
+	agent, client := net.Pipe()
+	received := make(chan mgsContracts.AgentMessage, 1)
+	handler := func(log log.T, message mgsContracts.AgentMessage) error {
+		received <- message
+		return nil
+	}
+	cancelFlag := task.NewChanneledCancelFlag()
+	dataChannel := NewLoopbackDataChannel(mockContext, agent, sessionId, handler, cancelFlag)
+	defer dataChannel.Close(mockLog)
+
+	go func() {
+		dataChannel.SendStreamDataMessage(mockLog, mgsContracts.Output, []byte("prompt"))
+	}()
+	var frame localapi.SessionFrame
+	assert.NoError(t, json.NewDecoder(client).Decode(&frame))
+
+	assert.NoError(t, json.NewEncoder(client).Encode(localapi.SessionFrame{PayloadType: uint32(mgsContracts.Output), Payload: []byte("ls")}))
+	select {
+	case message := <-received:
+		assert.Equal(t, mgsContracts.InputStreamDataMessage, message.MessageType)
+		assert.Equal(t, []byte("ls"), message.Payload)
+		assert.Equal(t, int64(1), message.SequenceNumber)
+	case <-time.After(5 * time.Second):
+		assert.Fail(t, "input was not passed to the handler")
+	}
+
+	client.Close()
+	canceled := make(chan task.State, 1)
+	go func() { canceled <- cancelFlag.Wait() }()
+	select {
+	case <-canceled:
+	case <-time.After(5 * time.Second):
+	}
+	assert.True(t, cancelFlag.Canceled())
+}
+
+func TestLoopbackDataChannelRefusesEncryption(t *testing.T) {
+	agent, client := net.Pipe()
+	defer client.Close()
+	handler := func(log log.T, message mgsContracts.AgentMessage) error { return nil }
+	dataChannel := NewLoopbackDataChannel(mockContext, agent, sessionId, handler, task.NewChanneledCancelFlag())
+	defer dataChannel.Close(mockLog)
+
+	assert.Error(t, dataChannel.PerformHandshake(mockLog, crypto.KMSParameters{KeyId: kmskey}, true, mgsContracts.SessionTypeRequest{}))
+	assert.NoError(t, dataChannel.PerformHandshake(mockLog, crypto.KMSParameters{}, false, mgsContracts.SessionTypeRequest{}))
+}
@@ -20,6 +20,7 @@ import (
 	"github.com/aws/amazon-ssm-agent/agent/context"
 	"github.com/aws/amazon-ssm-agent/agent/log"
 	"github.com/aws/amazon-ssm-agent/agent/session/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/session/crypto"
 	"github.com/aws/amazon-ssm-agent/agent/session/datachannel"
 	"github.com/aws/amazon-ssm-agent/agent/session/service"
 	"github.com/aws/amazon-ssm-agent/agent/task"
@@ -60,13 +61,13 @@ func (_m *IDataChannel) Initialize(_a0 context.T, mgsService service.Service, se
 	_m.Called(_a0, mgsService, sessionId, clientId, instanceId, role, cancelFlag, inputStreamMessageHandler)
 }
 
-// PerformHandshake provides a mock function with given fields: _a0, kmsKeyId, encryptionEnabled, properties
-func (_m *IDataChannel) PerformHandshake(_a0 log.T, kmsKeyId string, encryptionEnabled bool, sessionTypeRequest contracts.SessionTypeRequest) error {
-	ret := _m.Called(_a0, kmsKeyId, encryptionEnabled, sessionTypeRequest)
+// PerformHandshake provides a mock function with given fields: _a0, kmsParameters, encryptionEnabled, properties
+func (_m *IDataChannel) PerformHandshake(_a0 log.T, kmsParameters crypto.KMSParameters, encryptionEnabled bool, sessionTypeRequest contracts.SessionTypeRequest) error {
+	ret := _m.Called(_a0, kmsParameters, encryptionEnabled, sessionTypeRequest)
 
 	var r0 error
-	if rf, ok := ret.Get(0).(func(log.T, string, bool, contracts.SessionTypeRequest) error); ok {
-		r0 = rf(_a0, kmsKeyId, encryptionEnabled, sessionTypeRequest)
+	if rf, ok := ret.Get(0).(func(log.T, crypto.KMSParameters, bool, contracts.SessionTypeRequest) error); ok {
+		r0 = rf(_a0, kmsParameters, encryptionEnabled, sessionTypeRequest)
 	} else {
 		r0 = ret.Error(0)
 	}
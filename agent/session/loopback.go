@@ -0,0 +1,239 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package session implements the core module to start web-socket connection with message gateway service.
+package session
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/ipc/localapi"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	mgsContracts "github.com/aws/amazon-ssm-agent/agent/session/contracts"
+	"github.com/twinj/uuid"
+)
+
+const (
+	// loopbackSessionPrefix starts the id of sessions started through the local api
+	loopbackSessionPrefix = "loopback-"
+	// loopbackDocumentName is the document name reported for loopback sessions
+	loopbackDocumentName = "ssm-cli-loopback"
+)
+
+// loopbackAttachTimeout limits how long a loopback client waits for the session worker to attach
+var loopbackAttachTimeout = time.Minute
+
+// loopbackSession pairs the client of a loopback session with the session worker that runs it
+type loopbackSession struct {
+	token  string
+	worker chan net.Conn
+	done   chan struct{}
+}
+
+var (
+	loopbackLock     sync.Mutex
+	loopbackSessions = map[string]*loopbackSession{}
+)
+
+// isLoopbackSession returns true for the sessions started through the local api, they have no service to reply to
+func isLoopbackSession(sessionId string) bool {
+	return strings.HasPrefix(sessionId, loopbackSessionPrefix)
+}
+
+// registerLoopbackSessions lets local api clients start sessions when the configuration allows it
+func (s *Session) registerLoopbackSessions() {
+	if !s.context.AppConfig().LocalApi.LoopbackSessions {
+		return
+	}
+	localapi.RegisterStreamAction(localapi.VerbStartSession, s.startLoopbackSession)
+	localapi.RegisterStreamAction(localapi.VerbAttachSession, s.attachLoopbackSession)
+}
+
+// unregisterLoopbackSessions stops accepting loopback sessions
+func (s *Session) unregisterLoopbackSessions() {
+	localapi.RegisterStreamAction(localapi.VerbStartSession, nil)
+	localapi.RegisterStreamAction(localapi.VerbAttachSession, nil)
+}
+
+// startLoopbackSession submits the session to the processor like a start session message of the service,
+// the connection of the client is then relayed to the session worker
+func (s *Session) startLoopbackSession(log log.T, req localapi.Request) (localapi.Response, func(conn net.Conn)) {
+	if req.Session == nil {
+		return localapi.Response{Error: "session is missing from the request"}, nil
+	}
+	docState, err := s.buildLoopbackDocState(*req.Session, req.Peer)
+	if err != nil {
+		return localapi.Response{Error: err.Error()}, nil
+	}
+
+	sessionId := docState.DocumentInformation.DocumentID
+	session := &loopbackSession{
+		token:  uuid.NewV4().String(),
+		worker: make(chan net.Conn, 1),
+		done:   make(chan struct{}),
+	}
+	for i := range docState.InstancePluginsInformation {
+		docState.InstancePluginsInformation[i].Configuration.LoopbackToken = session.token
+	}
+
+	return localapi.Response{Message: sessionId}, func(client net.Conn) {
+		loopbackLock.Lock()
+		loopbackSessions[sessionId] = session
+		loopbackLock.Unlock()
+		defer func() {
+			loopbackLock.Lock()
+			delete(loopbackSessions, sessionId)
+			loopbackLock.Unlock()
+			close(session.done)
+		}()
+
+		log.Infof("starting loopback session %s", sessionId)
+		s.processor.Submit(*docState)
+		select {
+		case worker := <-session.worker:
+			relayLoopbackSession(client, worker)
+			log.Infof("loopback session %s ended", sessionId)
+		case <-time.After(loopbackAttachTimeout):
+			log.Errorf("session worker of loopback session %s did not attach", sessionId)
+		}
+	}
+}
+
+// attachLoopbackSession hands the connection of the session worker to the client of the session
+func (s *Session) attachLoopbackSession(log log.T, req localapi.Request) (localapi.Response, func(conn net.Conn)) {
+	loopbackLock.Lock()
+	session, ok := loopbackSessions[req.SessionID]
+	loopbackLock.Unlock()
+	if !ok || subtle.ConstantTimeCompare([]byte(session.token), []byte(req.Token)) != 1 {
+		return localapi.Response{Error: fmt.Sprintf("no loopback session %s to attach to", req.SessionID)}, nil
+	}
+	return localapi.Response{Message: req.SessionID}, func(worker net.Conn) {
+		select {
+		case session.worker <- worker:
+			<-session.done
+		default:
+			log.Warnf("loopback session %s already has a session worker", req.SessionID)
+		}
+	}
+}
+
+// relayLoopbackSession copies the frames between the client and the session worker until either closes its connection
+func relayLoopbackSession(client net.Conn, worker net.Conn) {
+	clientDone := make(chan struct{})
+	go func() {
+		io.Copy(worker, client)
+		close(clientDone)
+	}()
+	io.Copy(client, worker)
+	// the session worker closes its data channel when the plugin completes, which ends the client
+	client.Close()
+	worker.Close()
+	<-clientDone
+}
+
+// buildLoopbackDocState builds the start session message the service would send for the request and parses it
+func (s *Session) buildLoopbackDocState(req localapi.SessionRequest, peer localapi.Peer) (*contracts.DocumentState, error) {
+	docContent, runAsUser, err := loopbackSessionContent(req, peer)
+	if err != nil {
+		return nil, err
+	}
+
+	sessionId := loopbackSessionPrefix + uuid.NewV4().String()
+	taskPayload, err := json.Marshal(mgsContracts.AgentTaskPayload{
+		DocumentName:    loopbackDocumentName,
+		DocumentContent: docContent,
+		SessionId:       sessionId,
+		Parameters:      req.Parameters,
+		RunAsUser:       runAsUser,
+	})
+	if err != nil {
+		return nil, err
+	}
+	payload, err := json.Marshal(mgsContracts.MGSPayload{Payload: string(taskPayload), TaskId: sessionId})
+	if err != nil {
+		return nil, err
+	}
+	agentMessage := mgsContracts.AgentMessage{
+		MessageType: mgsContracts.InteractiveShellMessage,
+		CreatedDate: uint64(time.Now().Unix()),
+		MessageId:   uuid.NewV4(),
+		Payload:     payload,
+	}
+
+	instanceId := s.agentConfig.InstanceID
+	orchestrationRootDir := filepath.Join(
+		appconfig.DefaultDataStorePath,
+		instanceId,
+		appconfig.DefaultSessionRootDirName,
+		s.context.AppConfig().Agent.OrchestrationRootDir)
+	return agentMessage.ParseAgentMessage(s.context, orchestrationRootDir, instanceId, uuid.NewV4().String())
+}
+
+// loopbackSessionContent returns the session document and the RunAs user of the session the peer requested.
+// Root chooses both, the other peers get the default document of the session type run as their own account.
+func loopbackSessionContent(req localapi.SessionRequest, peer localapi.Peer) (docContent contracts.SessionDocumentContent, runAsUser string, err error) {
+	if peer.Privileged {
+		if req.DocumentContent != nil {
+			docContent = *req.DocumentContent
+		} else {
+			docContent = defaultLoopbackDocument(req.SessionType)
+		}
+		runAsUser = req.RunAsUser
+	} else {
+		if peer.User == "" {
+			return docContent, "", errors.New("loopback sessions are only allowed for root and identified users")
+		}
+		docContent = defaultLoopbackDocument(req.SessionType)
+		docContent.Inputs.RunAsEnabled = true
+		runAsUser = peer.User
+	}
+	switch docContent.SessionType {
+	case appconfig.PluginNameStandardStream, appconfig.PluginNameInteractiveCommands, appconfig.PluginNamePort:
+	default:
+		return docContent, "", fmt.Errorf("unsupported session type %q", docContent.SessionType)
+	}
+	return docContent, runAsUser, nil
+}
+
+// defaultLoopbackDocument returns the session document used when the client did not send one,
+// the port document takes the port number from the session parameters
+func defaultLoopbackDocument(sessionType string) contracts.SessionDocumentContent {
+	if sessionType == "" {
+		sessionType = appconfig.PluginNameStandardStream
+	}
+	docContent := contracts.SessionDocumentContent{
+		SchemaVersion: "1.0",
+		Description:   "Loopback session started by ssm-cli",
+		SessionType:   sessionType,
+	}
+	if sessionType == appconfig.PluginNamePort {
+		docContent.Parameters = map[string]*contracts.Parameter{
+			"portNumber": {ParamType: "String"},
+		}
+		docContent.Properties = map[string]interface{}{
+			"portNumber": "{{ portNumber }}",
+		}
+	}
+	return docContent
+}
@@ -0,0 +1,104 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package session implements the core module to start web-socket connection with message gateway service.
+package session
+
+import (
+	"io/ioutil"
+	"net"
+	"testing"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/ipc/localapi"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAttachLoopbackSessionChecksToken(t *testing.T) {
+	sessionId := loopbackSessionPrefix + "attach"
+	loopbackLock.Lock()
+	loopbackSessions[sessionId] = &loopbackSession{token: "secret", worker: make(chan net.Conn, 1), done: make(chan struct{})}
+	loopbackLock.Unlock()
+	defer func() {
+		loopbackLock.Lock()
+		delete(loopbackSessions, sessionId)
+		loopbackLock.Unlock()
+	}()
+
+	s := &Session{}
+	resp, stream := s.attachLoopbackSession(log.NewMockLog(), localapi.Request{SessionID: sessionId, Token: "guess"})
+	assert.NotEmpty(t, resp.Error)
+	assert.Nil(t, stream)
+
+	resp, stream = s.attachLoopbackSession(log.NewMockLog(), localapi.Request{SessionID: loopbackSessionPrefix + "unknown", Token: "secret"})
+	assert.NotEmpty(t, resp.Error)
+	assert.Nil(t, stream)
+
+	resp, stream = s.attachLoopbackSession(log.NewMockLog(), localapi.Request{SessionID: sessionId, Token: "secret"})
+	assert.Empty(t, resp.Error)
+	assert.NotNil(t, stream)
+}
+
+func TestRelayLoopbackSession(t *testing.T) {
+	client, clientAgent := net.Pipe()
+	worker, workerAgent := net.Pipe()
+	go relayLoopbackSession(clientAgent, workerAgent)
+
+	go func() {
+		worker.Write([]byte("output"))
+		worker.Close()
+	}()
+	output, err := ioutil.ReadAll(client)
+	assert.NoError(t, err)
+	assert.Equal(t, "output", string(output))
+}
+
+func TestDefaultLoopbackDocument(t *testing.T) {
+	docContent := defaultLoopbackDocument("")
+	assert.Equal(t, appconfig.PluginNameStandardStream, docContent.SessionType)
+	assert.Empty(t, docContent.Parameters)
+
+	docContent = defaultLoopbackDocument(appconfig.PluginNamePort)
+	assert.Equal(t, appconfig.PluginNamePort, docContent.SessionType)
+	assert.Contains(t, docContent.Parameters, "portNumber")
+	assert.Equal(t, "{{ portNumber }}", docContent.Properties.(map[string]interface{})["portNumber"])
+	assert.NotContains(t, docContent.Parameters, "host")
+	assert.True(t, isLoopbackSession(loopbackSessionPrefix+"1234"))
+	assert.False(t, isLoopbackSession("user-1234"))
+}
+
+func TestLoopbackSessionContent(t *testing.T) {
+	custom := &contracts.SessionDocumentContent{SchemaVersion: "1.0", SessionType: appconfig.PluginNameStandardStream}
+	custom.Inputs.RunAsEnabled = false
+	req := localapi.SessionRequest{DocumentContent: custom, RunAsUser: "root"}
+
+	docContent, runAsUser, err := loopbackSessionContent(req, localapi.Peer{Privileged: true})
+	assert.NoError(t, err)
+	assert.Equal(t, *custom, docContent)
+	assert.Equal(t, "root", runAsUser)
+
+	// an unprivileged peer cannot choose the document nor the user the session runs as
+	docContent, runAsUser, err = loopbackSessionContent(req, localapi.Peer{User: "alice"})
+	assert.NoError(t, err)
+	assert.Equal(t, "Loopback session started by ssm-cli", docContent.Description)
+	assert.True(t, docContent.Inputs.RunAsEnabled)
+	assert.Equal(t, "alice", runAsUser)
+
+	_, _, err = loopbackSessionContent(req, localapi.Peer{})
+	assert.Error(t, err)
+
+	_, _, err = loopbackSessionContent(localapi.SessionRequest{SessionType: "AWS-RunShellScript"}, localapi.Peer{Privileged: true})
+	assert.Error(t, err)
+}
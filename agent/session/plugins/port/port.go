@@ -17,7 +17,9 @@ package port
 import (
 	"errors"
 	"fmt"
+	"net"
 	"os"
+	"path/filepath"
 
 	"github.com/aws/amazon-ssm-agent/agent/appconfig"
 	"github.com/aws/amazon-ssm-agent/agent/context"
@@ -36,9 +38,11 @@ import (
 const muxSupportedClientVersion = "1.1.70"
 
 // PortParameters contains inputs required to execute port plugin.
+// UnixSocket is the absolute path of a local unix domain socket to forward to instead of a port.
 type PortParameters struct {
 	PortNumber string `json:"portNumber" yaml:"portNumber"`
 	Type       string `json:"type"`
+	UnixSocket string `json:"unixSocket" yaml:"unixSocket"`
 }
 
 // Plugin is the type for the port plugin.
@@ -62,11 +66,11 @@ var GetSession = func(portParameters PortParameters, cancelled chan struct{}, cl
 	if portParameters.Type == mgsConfig.LocalPortForwarding &&
 		versionutil.Compare(clientVersion, muxSupportedClientVersion, true) >= 0 {
 
-		if session, err = NewMuxPortSession(cancelled, portParameters.PortNumber, sessionId); err == nil {
+		if session, err = NewMuxPortSession(cancelled, portParameters.PortNumber, portParameters.UnixSocket, sessionId); err == nil {
 			return session, nil
 		}
 	} else {
-		if session, err = NewBasicPortSession(cancelled, portParameters.PortNumber, portParameters.Type); err == nil {
+		if session, err = NewBasicPortSession(cancelled, portParameters.PortNumber, portParameters.UnixSocket, portParameters.Type); err == nil {
 			return session, nil
 		}
 	}
@@ -221,10 +225,28 @@ func (p *PortPlugin) initializeParameters(log log.T, config agentContracts.Confi
 		return errors.New(fmt.Sprintf("Unable to remarshal session properties. %v", err))
 	}
 
-	if portParameters.PortNumber == "" {
+	if portParameters.UnixSocket != "" {
+		if !filepath.IsAbs(portParameters.UnixSocket) {
+			return errors.New(fmt.Sprintf("Unix socket %v in session properties is not an absolute path.", portParameters.UnixSocket))
+		}
+	} else if portParameters.PortNumber == "" {
 		return errors.New(fmt.Sprintf("Port number is empty in session properties. %v", config.Properties))
 	}
 	p.session, err = GetSession(portParameters, p.cancelled, p.dataChannel.GetClientVersion(), config.SessionId)
 
 	return
 }
+
+// serverAddress joins the local destination host and port for dialing, IPv6 literals are bracketed.
+// An empty host dials the local system.
+func serverAddress(host string, portNumber string) string {
+	return net.JoinHostPort(host, portNumber)
+}
+
+// serverDestination returns the network and address dialed for the destination, the unix socket when it is set
+func serverDestination(host string, portNumber string, unixSocket string) (network string, address string) {
+	if unixSocket != "" {
+		return "unix", unixSocket
+	}
+	return "tcp", serverAddress(host, portNumber)
+}
@@ -25,13 +25,19 @@ import (
 
 	"github.com/aws/amazon-ssm-agent/agent/appconfig"
 	"github.com/aws/amazon-ssm-agent/agent/log"
+	agentNetwork "github.com/aws/amazon-ssm-agent/agent/network"
 	mgsConfig "github.com/aws/amazon-ssm-agent/agent/session/config"
 	mgsContracts "github.com/aws/amazon-ssm-agent/agent/session/contracts"
 	"github.com/aws/amazon-ssm-agent/agent/session/datachannel"
 )
 
+const (
+	localHost     = "localhost"
+	localHostIPv6 = "::1"
+)
+
 var DialCall = func(network string, address string) (net.Conn, error) {
-	return net.Dial(network, address)
+	return agentNetwork.Dialer{}.Dial(network, address)
 }
 
 // BasicPortSession is the type for the port session.
@@ -40,6 +46,7 @@ type BasicPortSession struct {
 	portSession        IPortSession
 	conn               net.Conn
 	serverPortNumber   string
+	serverUnixSocket   string
 	portType           string
 	reconnectToPort    bool
 	reconnectToPortErr chan error
@@ -47,9 +54,10 @@ type BasicPortSession struct {
 }
 
 // NewBasicPortSession returns a new instance of the BasicPortSession.
-func NewBasicPortSession(cancelled chan struct{}, portNumber string, portType string) (IPortSession, error) {
+func NewBasicPortSession(cancelled chan struct{}, portNumber string, unixSocket string, portType string) (IPortSession, error) {
 	var plugin = BasicPortSession{
 		serverPortNumber:   portNumber,
+		serverUnixSocket:   unixSocket,
 		portType:           portType,
 		reconnectToPortErr: make(chan error),
 		cancelled:          cancelled,
@@ -138,7 +146,13 @@ func (p *BasicPortSession) WritePump(log log.T, dataChannel datachannel.IDataCha
 
 // InitializeSession dials a connection to port
 func (p *BasicPortSession) InitializeSession(log log.T) (err error) {
-	if p.conn, err = DialCall("tcp", "localhost:"+p.serverPortNumber); err != nil {
+	network, address := serverDestination(localHost, p.serverPortNumber, p.serverUnixSocket)
+	if p.conn, err = DialCall(network, address); err != nil && network == "tcp" {
+		// localhost may only name the IPv4 loopback on hosts where the server listens on the IPv6 one
+		log.Debugf("Unable to connect to %s, retrying on the IPv6 loopback: %v", address, err)
+		p.conn, err = DialCall(serverDestination(localHostIPv6, p.serverPortNumber, p.serverUnixSocket))
+	}
+	if err != nil {
 		return errors.New(fmt.Sprintf("Unable to connect to specified port: %v", err))
 	}
 	return nil
@@ -131,6 +131,27 @@ func (suite *BasicPortTestSuite) TestHandleStreamMessageWithReconnectToPortSetTo
 	assert.Equal(suite.T(), false, suite.session.reconnectToPort)
 }
 
+func (suite *BasicPortTestSuite) TestInitializeSessionRetriesOnIPv6Loopback() {
+	defer func(dial func(string, string) (net.Conn, error)) { DialCall = dial }(DialCall)
+
+	out, in := net.Pipe()
+	defer in.Close()
+	defer out.Close()
+	var addresses []string
+	DialCall = func(network string, address string) (net.Conn, error) {
+		addresses = append(addresses, address)
+		if address == "[::1]:22" {
+			return out, nil
+		}
+		return nil, errors.New("connection refused")
+	}
+
+	suite.session.serverPortNumber = "22"
+	assert.Nil(suite.T(), suite.session.InitializeSession(suite.mockLog))
+	assert.Equal(suite.T(), []string{"localhost:22", "[::1]:22"}, addresses)
+	assert.Equal(suite.T(), out, suite.session.conn)
+}
+
 // Testing handleTCPReadError
 func (suite *BasicPortTestSuite) TestHandleTCPReadNonEOFError() {
 	returnCode := suite.session.handleTCPReadError(suite.mockLog, errors.New("some error!!!"))
@@ -56,6 +56,7 @@ type MuxPortSession struct {
 	portSession      IPortSession
 	cancelled        chan struct{}
 	serverPortNumber string
+	serverUnixSocket string
 	sessionId        string
 	socketFile       string
 	muxServer        *MuxServer
@@ -73,8 +74,8 @@ func (s *MuxServer) close() {
 }
 
 // NewMuxPortSession returns a new instance of the MuxPortSession.
-func NewMuxPortSession(cancelled chan struct{}, portNumber string, sessionId string) (IPortSession, error) {
-	var plugin = MuxPortSession{cancelled: cancelled, serverPortNumber: portNumber, sessionId: sessionId}
+func NewMuxPortSession(cancelled chan struct{}, portNumber string, unixSocket string, sessionId string) (IPortSession, error) {
+	var plugin = MuxPortSession{cancelled: cancelled, serverPortNumber: portNumber, serverUnixSocket: unixSocket, sessionId: sessionId}
 	return &plugin, nil
 }
 
@@ -223,7 +224,7 @@ func (p *MuxPortSession) transferDataToMgs(log log.T, ctx context.Context, dataC
 // handleServerConnections sets up smux stream and handles communication between smux stream and destination server.
 func (p *MuxPortSession) handleServerConnections(log log.T, ctx context.Context, dataChannel datachannel.IDataChannel) error {
 	// net.Dial assumes local system when host in addr is empty
-	localAddr := fmt.Sprintf(":%s", p.serverPortNumber)
+	network, localAddr := serverDestination("", p.serverPortNumber, p.serverUnixSocket)
 	for {
 		select {
 		case <-ctx.Done():
@@ -237,8 +238,8 @@ func (p *MuxPortSession) handleServerConnections(log log.T, ctx context.Context,
 
 			log.Debugf("Started a new mux stream %d\n", stream.ID())
 
-			if conn, err := net.Dial("tcp", localAddr); err == nil {
-				log.Tracef("Established connection to port %s", p.serverPortNumber)
+			if conn, err := DialCall(network, localAddr); err == nil {
+				log.Tracef("Established connection to %s", localAddr)
 				go func() {
 					handleDataTransfer(stream, conn)
 				}()
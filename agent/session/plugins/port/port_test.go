@@ -16,6 +16,8 @@ package port
 
 import (
 	"errors"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/aws/amazon-ssm-agent/agent/appconfig"
@@ -258,3 +260,37 @@ func getAgentMessage(payloadType uint32, payload []byte) mgsContracts.AgentMessa
 	}
 	return agentMessage
 }
+
+func TestInitializeParametersWithUnixSocket(t *testing.T) {
+	mockDataChannel := &dataChannelMock.IDataChannel{}
+	mockDataChannel.On("GetClientVersion").Return(clientVersion)
+	portPlugin := &PortPlugin{
+		dataChannel: mockDataChannel,
+		cancelled:   make(chan struct{}),
+	}
+
+	socket := filepath.Join(os.TempDir(), "docker.sock")
+	config := contracts.Configuration{Properties: map[string]interface{}{"unixSocket": "docker.sock"}, SessionId: "sessionId"}
+	assert.Error(t, portPlugin.initializeParameters(mockLog, config))
+
+	session, _ := NewBasicPortSession(portPlugin.cancelled, "", socket, "")
+	assert.Equal(t, socket, session.(*BasicPortSession).serverUnixSocket)
+	session, _ = NewMuxPortSession(portPlugin.cancelled, "", socket, "sessionId")
+	assert.Equal(t, socket, session.(*MuxPortSession).serverUnixSocket)
+}
+
+func TestServerDestination(t *testing.T) {
+	network, address := serverDestination("localhost", "22", "")
+	assert.Equal(t, "tcp", network)
+	assert.Equal(t, "localhost:22", address)
+
+	network, address = serverDestination("", "", "/var/run/docker.sock")
+	assert.Equal(t, "unix", network)
+	assert.Equal(t, "/var/run/docker.sock", address)
+}
+
+func TestServerAddress(t *testing.T) {
+	assert.Equal(t, ":22", serverAddress("", "22"))
+	assert.Equal(t, "localhost:22", serverAddress(localHost, "22"))
+	assert.Equal(t, "[::1]:22", serverAddress(localHostIPv6, "22"))
+}
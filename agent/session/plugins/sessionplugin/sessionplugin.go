@@ -22,9 +22,11 @@ import (
 	"github.com/aws/amazon-ssm-agent/agent/context"
 	"github.com/aws/amazon-ssm-agent/agent/contracts"
 	"github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/iohandler"
+	"github.com/aws/amazon-ssm-agent/agent/ipc/localapi"
 	"github.com/aws/amazon-ssm-agent/agent/log"
 	mgsConfig "github.com/aws/amazon-ssm-agent/agent/session/config"
 	mgsContracts "github.com/aws/amazon-ssm-agent/agent/session/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/session/crypto"
 	"github.com/aws/amazon-ssm-agent/agent/session/datachannel"
 	"github.com/aws/amazon-ssm-agent/agent/session/retry"
 	"github.com/aws/amazon-ssm-agent/agent/task"
@@ -58,9 +60,19 @@ func (p *SessionPlugin) Execute(context context.T,
 	output iohandler.IOHandler) {
 
 	log := context.Log()
-	kmsKeyId := config.KmsKeyId
+	kmsParameters := crypto.KMSParameters{
+		KeyId:             config.KmsKeyId,
+		EncryptionContext: config.KmsEncryptionContext,
+		GrantTokens:       config.KmsGrantTokens,
+	}
 
-	dataChannel, err := getDataChannelForSessionPlugin(context, config.SessionId, config.ClientId, cancelFlag, p.sessionPlugin.InputStreamMessageHandler)
+	var dataChannel datachannel.IDataChannel
+	var err error
+	if config.LoopbackToken != "" {
+		dataChannel, err = getLoopbackDataChannel(context, config.SessionId, config.LoopbackToken, cancelFlag, p.sessionPlugin.InputStreamMessageHandler)
+	} else {
+		dataChannel, err = getDataChannelForSessionPlugin(context, config.SessionId, config.ClientId, cancelFlag, p.sessionPlugin.InputStreamMessageHandler)
+	}
 	if err != nil {
 		errorString := fmt.Errorf("Setting up data channel with id %s failed: %s", config.SessionId, err)
 		output.MarkAsFailed(errorString)
@@ -73,13 +85,13 @@ func (p *SessionPlugin) Execute(context context.T,
 		log.Errorf("Unable to send AgentSessionState message with session status %s. %s", mgsContracts.Connected, err)
 	}
 
-	encryptionEnabled := p.isEncryptionEnabled(kmsKeyId, config.PluginName)
+	encryptionEnabled := p.isEncryptionEnabled(kmsParameters.KeyId, config.PluginName)
 	sessionTypeRequest := mgsContracts.SessionTypeRequest{
 		SessionType: config.PluginName,
 		Properties:  p.sessionPlugin.GetPluginParameters(config.Properties),
 	}
 	if p.sessionPlugin.RequireHandshake() || encryptionEnabled {
-		if err = dataChannel.PerformHandshake(log, kmsKeyId, encryptionEnabled, sessionTypeRequest); err != nil {
+		if err = dataChannel.PerformHandshake(log, kmsParameters, encryptionEnabled, sessionTypeRequest); err != nil {
 			errorString := fmt.Errorf("Encountered error while initiating handshake. %s", err)
 			output.MarkAsFailed(errorString)
 			log.Error(errorString)
@@ -121,3 +133,12 @@ var getDataChannelForSessionPlugin = func(context context.T, sessionId string, c
 	dataChannel := channel.(*datachannel.DataChannel)
 	return dataChannel, nil
 }
+
+// getLoopbackDataChannel attaches to the agent that relays the session frames of a loopback session client
+var getLoopbackDataChannel = func(context context.T, sessionId string, token string, cancelFlag task.CancelFlag, inputStreamMessageHandler datachannel.InputStreamMessageHandler) (datachannel.IDataChannel, error) {
+	conn, _, err := localapi.OpenStream(localapi.Request{Verb: localapi.VerbAttachSession, SessionID: sessionId, Token: token})
+	if err != nil {
+		return nil, err
+	}
+	return datachannel.NewLoopbackDataChannel(context, conn, sessionId, inputStreamMessageHandler, cancelFlag), nil
+}
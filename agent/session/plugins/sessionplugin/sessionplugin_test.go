@@ -24,6 +24,7 @@ import (
 	iohandlerMock "github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/iohandler/mock"
 	"github.com/aws/amazon-ssm-agent/agent/log"
 	mgsContracts "github.com/aws/amazon-ssm-agent/agent/session/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/session/crypto"
 	"github.com/aws/amazon-ssm-agent/agent/session/datachannel"
 	dataChannelMock "github.com/aws/amazon-ssm-agent/agent/session/datachannel/mocks"
 	sessionPluginMock "github.com/aws/amazon-ssm-agent/agent/session/plugins/sessionplugin/mocks"
@@ -98,7 +99,7 @@ func (suite *SessionPluginTestSuite) TestExecuteHandshakeEncryptionDisabled() {
 	suite.mockSessionPlugin.On("GetPluginParameters", config.Properties).Return(sessionProperties)
 
 	sessionTypeRequest := mgsContracts.SessionTypeRequest{SessionType: appconfig.PluginNamePort, Properties: sessionProperties}
-	suite.mockDataChannel.On("PerformHandshake", suite.mockContext.Log(), "", false, sessionTypeRequest).Return(nil)
+	suite.mockDataChannel.On("PerformHandshake", suite.mockContext.Log(), crypto.KMSParameters{}, false, sessionTypeRequest).Return(nil)
 	suite.sessionPlugin.Execute(suite.mockContext,
 		config,
 		suite.mockCancelFlag,
@@ -124,7 +125,7 @@ func (suite *SessionPluginTestSuite) TestExecuteHandshakeEncryptionEnabledPortPl
 	suite.mockSessionPlugin.On("GetPluginParameters", config.Properties).Return(sessionProperties)
 
 	sessionTypeRequest := mgsContracts.SessionTypeRequest{SessionType: appconfig.PluginNamePort, Properties: sessionProperties}
-	suite.mockDataChannel.On("PerformHandshake", suite.mockContext.Log(), kmsKey, false, sessionTypeRequest).Return(nil)
+	suite.mockDataChannel.On("PerformHandshake", suite.mockContext.Log(), crypto.KMSParameters{KeyId: kmsKey}, false, sessionTypeRequest).Return(nil)
 	suite.sessionPlugin.Execute(suite.mockContext,
 		config,
 		suite.mockCancelFlag,
@@ -149,7 +150,41 @@ func (suite *SessionPluginTestSuite) TestExecuteEncryptionHandshakeSuccess() {
 	suite.mockSessionPlugin.On("GetPluginParameters", config.Properties).Return(nil)
 
 	sessionTypeRequest := mgsContracts.SessionTypeRequest{SessionType: appconfig.PluginNameStandardStream}
-	suite.mockDataChannel.On("PerformHandshake", suite.mockContext.Log(), kmsKey, true, sessionTypeRequest).Return(nil)
+	suite.mockDataChannel.On("PerformHandshake", suite.mockContext.Log(), crypto.KMSParameters{KeyId: kmsKey}, true, sessionTypeRequest).Return(nil)
+	suite.sessionPlugin.Execute(suite.mockContext,
+		config,
+		suite.mockCancelFlag,
+		suite.mockIohandler)
+
+	suite.mockDataChannel.AssertExpectations(suite.T())
+	suite.mockSessionPlugin.AssertExpectations(suite.T())
+}
+
+func (suite *SessionPluginTestSuite) TestExecuteEncryptionHandshakeWithKMSParameters() {
+	config := contracts.Configuration{
+		KmsKeyId:             "some-key",
+		KmsEncryptionContext: map[string]string{"aws:ssm:AccountId": "123456789012"},
+		KmsGrantTokens:       []string{"grant-token"},
+		PluginName:           appconfig.PluginNameStandardStream,
+	}
+
+	getDataChannelForSessionPlugin =
+		func(context context.T, sessionId string, clientId string, cancelFlag task.CancelFlag, inputStreamMessageHandler datachannel.InputStreamMessageHandler) (datachannel.IDataChannel, error) {
+			return suite.mockDataChannel, nil
+		}
+	suite.mockDataChannel.On("SendAgentSessionStateMessage", suite.mockContext.Log(), mgsContracts.Connected).Return(nil)
+	suite.mockDataChannel.On("Close", suite.mockContext.Log()).Return(nil)
+	suite.mockSessionPlugin.On("Execute", suite.mockContext, mock.Anything, suite.mockCancelFlag, suite.mockIohandler, suite.mockDataChannel).Return()
+	suite.mockSessionPlugin.On("RequireHandshake").Return(false)
+	suite.mockSessionPlugin.On("GetPluginParameters", config.Properties).Return(nil)
+
+	kmsParameters := crypto.KMSParameters{
+		KeyId:             "some-key",
+		EncryptionContext: map[string]string{"aws:ssm:AccountId": "123456789012"},
+		GrantTokens:       []string{"grant-token"},
+	}
+	sessionTypeRequest := mgsContracts.SessionTypeRequest{SessionType: appconfig.PluginNameStandardStream}
+	suite.mockDataChannel.On("PerformHandshake", suite.mockContext.Log(), kmsParameters, true, sessionTypeRequest).Return(nil)
 	suite.sessionPlugin.Execute(suite.mockContext,
 		config,
 		suite.mockCancelFlag,
@@ -174,7 +209,7 @@ func (suite *SessionPluginTestSuite) TestExecuteEncryptionHandshakeFailed() {
 
 	sessionTypeRequest := mgsContracts.SessionTypeRequest{SessionType: appconfig.PluginNameStandardStream}
 	error := errors.New("handshake failure")
-	suite.mockDataChannel.On("PerformHandshake", suite.mockContext.Log(), kmsKey, true, sessionTypeRequest).Return(error)
+	suite.mockDataChannel.On("PerformHandshake", suite.mockContext.Log(), crypto.KMSParameters{KeyId: kmsKey}, true, sessionTypeRequest).Return(error)
 	suite.mockIohandler.On("MarkAsFailed", mock.Anything).Return()
 	suite.sessionPlugin.Execute(suite.mockContext,
 		config,
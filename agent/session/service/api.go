@@ -54,6 +54,9 @@ type OpenControlChannelInput struct {
 
 	// PlatformType is a required field
 	PlatformType *string `json:"PlatformType" min:"1" type:"string" required:"true"`
+
+	// AgentCapabilities lists the optional message types the agent accepts on the control channel
+	AgentCapabilities []*string `json:"AgentCapabilities,omitempty" type:"list"`
 }
 
 type CreateDataChannelInput struct {
@@ -21,7 +21,6 @@ import (
 	"errors"
 	"fmt"
 	"io/ioutil"
-	"net"
 	"net/http"
 	"net/url"
 	"path"
@@ -31,7 +30,11 @@ import (
 	"github.com/aws/amazon-ssm-agent/agent/log"
 	"github.com/aws/amazon-ssm-agent/agent/managedInstances/registration"
 	"github.com/aws/amazon-ssm-agent/agent/managedInstances/rolecreds"
+	"github.com/aws/amazon-ssm-agent/agent/network"
+	"github.com/aws/amazon-ssm-agent/agent/network/clockskew"
+	"github.com/aws/amazon-ssm-agent/agent/network/failover"
 	"github.com/aws/amazon-ssm-agent/agent/platform"
+	"github.com/aws/amazon-ssm-agent/agent/proxyconfig"
 	"github.com/aws/amazon-ssm-agent/agent/sdkutil"
 	mgsconfig "github.com/aws/amazon-ssm-agent/agent/session/config"
 	"github.com/aws/aws-sdk-go/aws"
@@ -61,6 +64,17 @@ type MessageGatewayService struct {
 	signer *v4.Signer
 }
 
+// RequestError is returned when the message gateway service answers a request with an unexpected status code.
+type RequestError struct {
+	StatusCode int
+	Body       string
+}
+
+// Error returns the error message of the RequestError.
+func (e *RequestError) Error() string {
+	return fmt.Sprintf("unexpected response from the service %s", e.Body)
+}
+
 // NewService creates a new service instance.
 func NewService(log log.T, mgsConfig appconfig.MgsConfig, connectionTimeout time.Duration) Service {
 
@@ -101,12 +115,13 @@ func NewService(log log.T, mgsConfig appconfig.MgsConfig, connectionTimeout time
 
 	// capture Transport so we can use it to cancel requests
 	tr := &http.Transport{
-		Proxy: http.ProxyFromEnvironment,
-		Dial: (&net.Dialer{
+		Proxy: proxyconfig.ProxyFunc(proxyconfig.ServiceSsmMessages),
+		DialContext: network.Dialer{
 			Timeout:   connectionTimeout,
 			KeepAlive: 0,
-		}).Dial,
+		}.DialContext,
 		TLSHandshakeTimeout: 10 * time.Second,
+		TLSClientConfig:     network.GetDefaultTLSConfig(),
 	}
 
 	return &MessageGatewayService{
@@ -124,7 +139,7 @@ var makeRestcall = func(request []byte, methodType string, url string, region st
 	}
 
 	httpRequest.Header.Set("Content-Type", "application/json")
-	_, err = signer.Sign(httpRequest, bytes.NewReader(request), mgsconfig.ServiceName, region, time.Now())
+	_, err = signer.Sign(httpRequest, bytes.NewReader(request), mgsconfig.ServiceName, region, clockskew.Now())
 	if err != nil {
 		return nil, fmt.Errorf("failed to sign the request: %s", err)
 	}
@@ -134,6 +149,8 @@ var makeRestcall = func(request []byte, methodType string, url string, region st
 	}
 
 	resp, err := client.Do(httpRequest)
+	clockskew.RecordResponse(resp)
+	failover.Report(mgsconfig.ServiceName, region, failover.IsUnreachable(err) || (err == nil && resp.StatusCode >= 500))
 	if err != nil {
 		return nil, fmt.Errorf("failed to make http client call: %s", err)
 	}
@@ -146,7 +163,7 @@ var makeRestcall = func(request []byte, methodType string, url string, region st
 	if resp.StatusCode == httpStatusCodeCreated {
 		return body, nil
 	} else {
-		return nil, fmt.Errorf("unexpected response from the service %s", body)
+		return nil, &RequestError{StatusCode: resp.StatusCode, Body: string(body)}
 	}
 }
 
@@ -189,15 +206,16 @@ func (mgsService *MessageGatewayService) GetV4Signer() *v4.Signer {
 	return mgsService.signer
 }
 
-// GetRegion gets the region.
+// GetRegion gets the region, which is a secondary region while the primary region is unreachable.
 func (mgsService *MessageGatewayService) GetRegion() string {
-	return mgsService.region
+	return failover.Current(mgsconfig.ServiceName, mgsService.region).Region
 }
 
 // CreateControlChannel calls the CreateControlChannel MGS API
 func (mgsService *MessageGatewayService) CreateControlChannel(log log.T, createControlChannelInput *CreateControlChannelInput, channelId string) (createControlChannelOutput *CreateControlChannelOutput, err error) {
 
-	url, err := getMGSBaseUrl(log, mgsconfig.ControlChannel, channelId, mgsService.region)
+	region := mgsService.GetRegion()
+	url, err := getMGSBaseUrl(log, mgsconfig.ControlChannel, channelId, region)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get the mgs base url with error: %s", err)
 	}
@@ -211,9 +229,9 @@ func (mgsService *MessageGatewayService) CreateControlChannel(log log.T, createC
 		return nil, errors.New("unable to marshal the createControlChannelInput")
 	}
 
-	resp, err := makeRestcall(jsonValue, "POST", url, mgsService.region, mgsService.signer)
+	resp, err := makeRestcall(jsonValue, "POST", url, region, mgsService.signer)
 	if err != nil {
-		return nil, fmt.Errorf("createControlChannel request failed: %s", err)
+		return nil, fmt.Errorf("createControlChannel request failed: %w", err)
 	}
 
 	var output CreateControlChannelOutput
@@ -229,7 +247,8 @@ func (mgsService *MessageGatewayService) CreateControlChannel(log log.T, createC
 // CreateDataChannel calls the CreateDataChannel MGS API
 func (mgsService *MessageGatewayService) CreateDataChannel(log log.T, createDataChannelInput *CreateDataChannelInput, sessionId string) (createDataChannelOutput *CreateDataChannelOutput, err error) {
 
-	url, err := getMGSBaseUrl(log, mgsconfig.DataChannel, sessionId, mgsService.region)
+	region := mgsService.GetRegion()
+	url, err := getMGSBaseUrl(log, mgsconfig.DataChannel, sessionId, region)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get the mgs base url with error: %s", err)
 	}
@@ -243,7 +262,7 @@ func (mgsService *MessageGatewayService) CreateDataChannel(log log.T, createData
 		return nil, errors.New("unable to marshal the createDataChannelInput")
 	}
 
-	resp, err := makeRestcall(jsonValue, "POST", url, mgsService.region, mgsService.signer)
+	resp, err := makeRestcall(jsonValue, "POST", url, region, mgsService.signer)
 	if err != nil {
 		return nil, fmt.Errorf("createDataChannel request failed: %s", err)
 	}
@@ -35,6 +35,7 @@ import (
 	"github.com/aws/amazon-ssm-agent/agent/session/controlchannel"
 	"github.com/aws/amazon-ssm-agent/agent/session/retry"
 	"github.com/aws/amazon-ssm-agent/agent/session/service"
+	"github.com/aws/amazon-ssm-agent/agent/shutdown"
 	"github.com/gorilla/websocket"
 	"github.com/twinj/uuid"
 )
@@ -48,6 +49,7 @@ type Session struct {
 	service        service.Service
 	controlChannel controlchannel.IControlChannel
 	processor      processor.Processor
+	repliesDone    chan bool
 }
 
 // NewSession gets session core module that manages the web-socket connection between Agent and message gateway service.
@@ -116,6 +118,12 @@ func NewSession(context context.T) *Session {
 
 	controlChannel := &controlchannel.ControlChannel{}
 
+	appconfig.RegisterConfigChangeHandler(mgsConfig.SessionServiceName, func(oldConfig, newConfig appconfig.SsmagentConfig) {
+		if oldConfig.Mgs.SessionWorkersLimit != newConfig.Mgs.SessionWorkersLimit {
+			processor.SetCommandWorkerLimit(newConfig.Mgs.SessionWorkersLimit)
+		}
+	})
+
 	return &Session{
 		context:        sessionContext,
 		agentConfig:    agentConfig,
@@ -185,7 +193,11 @@ func (s *Session) ModuleExecute(context context.T) (err error) {
 		return err
 	}
 
+	s.repliesDone = make(chan bool)
 	go s.listenReply(resultChan, instanceId)
+	s.registerLoopbackSessions()
+	// on shutdown the sessions end as if their clients terminated them, which tells the clients and uploads the session logs
+	shutdown.Register(shutdown.PhaseNotify, s.name, s.cancelSessions)
 
 	log.Info("SSM Agent is trying to setup control channel for Session Manager module.")
 	s.controlChannel, err = setupControlChannel(s.context, s.service, s.processor, instanceId)
@@ -215,20 +227,57 @@ func (s *Session) ModuleRequestStop(stopType contracts.StopType) (err error) {
 		}
 	}()
 
+	shutdown.Unregister(s.name)
+	s.unregisterLoopbackSessions()
+	if shutdown.InProgress() {
+		// the canceled sessions report their completion over the control channel, close it once they are all reported
+		s.processor.Stop(stopType)
+		s.waitForReplies()
+		s.closeControlChannel()
+		return nil
+	}
+
+	s.closeControlChannel()
+	s.processor.Stop(stopType)
+
+	return nil
+}
+
+// cancelSessions cancels the running sessions when the agent shuts down
+func (s *Session) cancelSessions(log log.T) {
+	log.Info("Agent is shutting down, ending the running sessions")
+	s.processor.CancelAll()
+}
+
+// closeControlChannel closes the control channel if it was set up
+func (s *Session) closeControlChannel() {
+	log := s.context.Log()
 	if s.controlChannel != nil {
-		if err = s.controlChannel.Close(log); err != nil {
+		if err := s.controlChannel.Close(log); err != nil {
 			log.Errorf("stopping controlchannel with error, %s", err)
 		}
 	}
-	s.processor.Stop(stopType)
+}
 
-	return nil
+// waitForReplies waits until listenReply sent the results of the stopped processor, at most until the shutdown deadline
+func (s *Session) waitForReplies() {
+	if s.repliesDone == nil {
+		return
+	}
+	select {
+	case <-s.repliesDone:
+	case <-time.After(shutdown.Remaining()):
+		s.context.Log().Warn("Shutdown deadline passed before the session results were sent")
+	}
 }
 
 // listenReply listens document result of session execution.
 func (s *Session) listenReply(resultChan chan contracts.DocumentResult, instanceId string) {
 	log := s.context.Log()
 	log.Info("listening reply.")
+	if s.repliesDone != nil {
+		defer close(s.repliesDone)
+	}
 
 	//processor guarantees to close this channel upon stop
 	for res := range resultChan {
@@ -244,6 +293,10 @@ func (s *Session) listenReply(resultChan chan contracts.DocumentResult, instance
 				s.context.AppConfig().Agent.OrchestrationRootDir,
 				s.context.AppConfig().Ssm.SessionLogsRetentionDurationHours)
 		}
+		if isLoopbackSession(res.MessageID) {
+			// the client of a loopback session is connected to the local api, not to the service
+			continue
+		}
 		if s.context.AppConfig().Agent.ContainerMode {
 			instanceId, _ = platform.TargetID()
 		}
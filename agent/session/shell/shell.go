@@ -196,6 +196,10 @@ func (p *ShellPlugin) execute(context context.T,
 	select {
 	case <-cancelled:
 		log.Debug("Session cancelled. Attempting to stop pty.")
+		// The agent also cancels the sessions when it shuts down, tell the client the session ends before the pty goes away
+		if err = p.dataChannel.SendAgentSessionStateMessage(log, mgsContracts.Terminating); err != nil {
+			log.Warnf("Unable to send AgentSessionState message with session status %s. %v", mgsContracts.Terminating, err)
+		}
 		if err := Stop(log); err != nil {
 			log.Errorf("Error occurred while closing pty: %v", err)
 		}
@@ -99,11 +99,15 @@ func StartPty(
 
 			sessionUser = config.RunAsUser
 		} else {
-			// Start as ssm-user
-			// Create ssm-user before starting a session.
-			u.CreateLocalAdminUser(log)
+			// Start as the session user
+			// Create the session user before starting a session.
+			if _, err = u.CreateLocalAdminUser(log); err == utility.ErrSessionUserDisabled {
+				return nil, nil, err
+			}
 
-			sessionUser = appconfig.DefaultRunAsUserName
+			sessionUser = utility.SessionUserName()
+			u.EnableLocalUser(log)
+			utility.RecordSessionUserActivity(log, sessionUser)
 		}
 
 		// Get the uid and gid of the runas user.
@@ -90,35 +90,44 @@ func StartPty(
 	appConfig, _ := appconfig.Config(false)
 
 	if !shellProps.Windows.RunAsElevated && !isSessionLogger && !appConfig.Agent.ContainerMode {
-		// Reset password for default ssm user
+		sessionUser := utility.SessionUserName()
+		if utility.SessionUserConfig().Disabled {
+			return nil, nil, utility.ErrSessionUserDisabled
+		}
+
+		// Reset password for the session user
 		var newPassword string
 		newPassword, err = u.GeneratePasswordForDefaultUser()
 		if err != nil {
 			return nil, nil, err
 		}
 		var userExists bool
-		if userExists, err = u.ChangePassword(appconfig.DefaultRunAsUserName, newPassword); err != nil {
-			log.Errorf("Failed to generate new password for %s: %v", appconfig.DefaultRunAsUserName, err)
+		if userExists, err = u.ChangePassword(sessionUser, newPassword); err != nil {
+			log.Errorf("Failed to generate new password for %s: %v", sessionUser, err)
 			return
 		}
 
-		// create ssm-user before starting a new session
+		// create the session user before starting a new session
 		if !userExists {
 			if newPassword, err = u.CreateLocalAdminUser(log); err != nil {
-				return nil, nil, fmt.Errorf("Failed to create user %s: %v", appconfig.DefaultRunAsUserName, err)
+				return nil, nil, fmt.Errorf("Failed to create user %s: %v", sessionUser, err)
 			}
 		} else {
 			// enable user
 			if err = u.EnableLocalUser(log); err != nil {
-				return nil, nil, fmt.Errorf("Failed to enable user %s: %v", appconfig.DefaultRunAsUserName, err)
+				return nil, nil, fmt.Errorf("Failed to enable user %s: %v", sessionUser, err)
 			}
 		}
+		if err = u.EnsureSessionUserPrivileges(log); err != nil {
+			return nil, nil, err
+		}
+		utility.RecordSessionUserActivity(log, sessionUser)
 
 		var wg sync.WaitGroup
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			err = startPtyAsUser(log, appconfig.DefaultRunAsUserName, newPassword, finalCmd)
+			err = startPtyAsUser(log, sessionUser, newPassword, finalCmd)
 		}()
 		wg.Wait()
 	} else {
@@ -139,7 +148,7 @@ func Stop(log log.T) (err error) {
 		return fmt.Errorf("Stop winpty failed: %s", err)
 	}
 
-	log.Debugf("Disabling ssm-user")
+	log.Debugf("Disabling %s", utility.SessionUserName())
 	u.DisableLocalUser(log)
 	return nil
 }
@@ -158,7 +167,7 @@ func startPtyAsUser(log log.T, user string, pass string, shellCmd string) (err e
 	runtime.LockOSThread()
 	defer runtime.UnlockOSThread()
 
-	log.Debugf("Impersonating %s", appconfig.DefaultRunAsUserName)
+	log.Debugf("Impersonating %s", user)
 	if err = impersonate(log, user, pass); err != nil {
 		log.Error(err)
 		return
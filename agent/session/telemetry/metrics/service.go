@@ -24,7 +24,6 @@ import (
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/client"
 	"github.com/aws/aws-sdk-go/aws/request"
-	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/cloudwatch"
 )
 
@@ -163,7 +162,6 @@ func (c *CloudWatchService) createCloudWatchClient() *cloudwatch.CloudWatch {
 		NumMaxRetries: maxRetries,
 	})
 
-	appConfig := c.context.AppConfig()
 	if region, err := platform.Region(); err == nil {
 		if defaultEndpoint := platform.GetDefaultEndPoint(region, "logs"); defaultEndpoint != "" {
 			config.Endpoint = &defaultEndpoint
@@ -172,8 +170,7 @@ func (c *CloudWatchService) createCloudWatchClient() *cloudwatch.CloudWatch {
 		log.Errorf("error fetching the region, %v", err)
 	}
 
-	sess := session.New(config)
-	sess.Handlers.Build.PushBack(request.MakeAddToUserAgentHandler(appConfig.Agent.Name, appConfig.Agent.Version))
+	sess := sdkutil.NewSession(config, "")
 
 	return cloudwatch.New(sess)
 }
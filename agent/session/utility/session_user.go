@@ -0,0 +1,119 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package utility
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+)
+
+// ErrSessionUserDisabled is returned when a session needs the session user and its provisioning is turned off
+var ErrSessionUserDisabled = errors.New("the session user is disabled in the agent configuration, enable RunAs support to start sessions")
+
+var getAppConfig = appconfig.Config
+
+var timeNow = time.Now
+
+// sessionUserActivityDir holds the time a session last started as each session user
+var sessionUserActivityDir = filepath.Join(appconfig.DefaultDataStorePath, "sessionuser")
+
+// inactivityCheckInterval is how often the agent checks if the session user became inactive
+var inactivityCheckInterval = time.Hour
+
+// disableSessionUser and sessionUserExists are replaced in tests
+var disableSessionUser = func(u *SessionUtil, log log.T) error { return u.DisableLocalUser(log) }
+var sessionUserExists = func(u *SessionUtil, name string) (bool, error) { return u.userExists(name) }
+
+// SessionUserConfig returns the configuration of the account sessions are started as when RunAs is not enabled
+func SessionUserConfig() appconfig.SessionUserCfg {
+	config, err := getAppConfig(false)
+	if err != nil {
+		return appconfig.DefaultConfig().SessionUser
+	}
+	return config.SessionUser
+}
+
+// SessionUserName returns the name of the account sessions are started as when RunAs is not enabled
+func SessionUserName() string {
+	return SessionUserConfig().Name
+}
+
+// RecordSessionUserActivity saves the time a session started as the session user
+func RecordSessionUserActivity(log log.T, name string) {
+	if err := os.MkdirAll(sessionUserActivityDir, appconfig.ReadWriteExecuteAccess); err != nil {
+		log.Warnf("Failed to record activity of %s: %v", name, err)
+		return
+	}
+	lastUsed := timeNow().UTC().Format(time.RFC3339)
+	if err := ioutil.WriteFile(filepath.Join(sessionUserActivityDir, name), []byte(lastUsed), appconfig.ReadWriteAccess); err != nil {
+		log.Warnf("Failed to record activity of %s: %v", name, err)
+	}
+}
+
+// lastSessionUserActivity returns the time a session last started as the session user, if it was recorded
+func lastSessionUserActivity(name string) (lastUsed time.Time, recorded bool) {
+	content, err := ioutil.ReadFile(filepath.Join(sessionUserActivityDir, name))
+	if err != nil {
+		return
+	}
+	if lastUsed, err = time.Parse(time.RFC3339, strings.TrimSpace(string(content))); err != nil {
+		return
+	}
+	return lastUsed, true
+}
+
+// DisableInactiveSessionUser disables the session user when no session started as it for the configured number of days.
+// The next session enables the account again.
+func (u *SessionUtil) DisableInactiveSessionUser(log log.T) {
+	config := SessionUserConfig()
+	if config.Disabled || config.InactiveDays == 0 {
+		return
+	}
+
+	if exists, err := sessionUserExists(u, config.Name); err != nil || !exists {
+		return
+	}
+
+	lastUsed, recorded := lastSessionUserActivity(config.Name)
+	if !recorded {
+		// accounts created before inactivity was configured are counted from the first check
+		RecordSessionUserActivity(log, config.Name)
+		return
+	}
+	if timeNow().Sub(lastUsed) < time.Duration(config.InactiveDays)*24*time.Hour {
+		return
+	}
+
+	log.Infof("No session started as %s for %d days, disabling it", config.Name, config.InactiveDays)
+	if err := disableSessionUser(u, log); err != nil {
+		log.Warnf("Failed to disable inactive %s: %v", config.Name, err)
+		return
+	}
+	os.Remove(filepath.Join(sessionUserActivityDir, config.Name))
+}
+
+// MonitorSessionUserInactivity periodically disables the session user once it becomes inactive
+func (u *SessionUtil) MonitorSessionUserInactivity(log log.T) {
+	for {
+		u.DisableInactiveSessionUser(log)
+		time.Sleep(inactivityCheckInterval)
+	}
+}
@@ -0,0 +1,99 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package utility
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/stretchr/testify/assert"
+)
+
+// mockSessionUser replaces the configuration, clock and account functions used for the session user
+func mockSessionUser(t *testing.T, config appconfig.SessionUserCfg, now *time.Time, disabled *int) func() {
+	getAppConfigOrig, timeNowOrig, dirOrig := getAppConfig, timeNow, sessionUserActivityDir
+	disableOrig, existsOrig := disableSessionUser, sessionUserExists
+
+	dir, err := ioutil.TempDir("", "sessionuser")
+	assert.NoError(t, err)
+	sessionUserActivityDir = dir
+	getAppConfig = func(reload bool) (appconfig.SsmagentConfig, error) {
+		appConfig := appconfig.DefaultConfig()
+		appConfig.SessionUser = config
+		return appConfig, nil
+	}
+	timeNow = func() time.Time { return *now }
+	disableSessionUser = func(u *SessionUtil, log log.T) error {
+		*disabled++
+		return nil
+	}
+	sessionUserExists = func(u *SessionUtil, name string) (bool, error) { return name == config.Name, nil }
+
+	return func() {
+		os.RemoveAll(dir)
+		getAppConfig, timeNow, sessionUserActivityDir = getAppConfigOrig, timeNowOrig, dirOrig
+		disableSessionUser, sessionUserExists = disableOrig, existsOrig
+	}
+}
+
+func TestDisableInactiveSessionUser(t *testing.T) {
+	logger := log.NewMockLog()
+	now := time.Date(2020, 6, 1, 0, 0, 0, 0, time.UTC)
+	disabled := 0
+	defer mockSessionUser(t, appconfig.SessionUserCfg{Name: "session-admin", InactiveDays: 30}, &now, &disabled)()
+	u := &SessionUtil{}
+
+	// the first check starts counting for an account that was never used
+	u.DisableInactiveSessionUser(logger)
+	lastUsed, recorded := lastSessionUserActivity("session-admin")
+	assert.True(t, recorded)
+	assert.Equal(t, now, lastUsed)
+
+	now = now.Add(29 * 24 * time.Hour)
+	RecordSessionUserActivity(logger, "session-admin")
+	now = now.Add(29 * 24 * time.Hour)
+	u.DisableInactiveSessionUser(logger)
+	assert.Equal(t, 0, disabled)
+
+	now = now.Add(24 * time.Hour)
+	u.DisableInactiveSessionUser(logger)
+	assert.Equal(t, 1, disabled)
+	_, recorded = lastSessionUserActivity("session-admin")
+	assert.False(t, recorded)
+}
+
+func TestDisableInactiveSessionUser_NotConfigured(t *testing.T) {
+	logger := log.NewMockLog()
+	now := time.Date(2020, 6, 1, 0, 0, 0, 0, time.UTC)
+	disabled := 0
+	defer mockSessionUser(t, appconfig.SessionUserCfg{Name: "ssm-user"}, &now, &disabled)()
+
+	RecordSessionUserActivity(logger, "ssm-user")
+	now = now.Add(365 * 24 * time.Hour)
+	(&SessionUtil{}).DisableInactiveSessionUser(logger)
+	assert.Equal(t, 0, disabled)
+}
+
+func TestSessionUserConfig(t *testing.T) {
+	now := time.Now()
+	disabled := 0
+	defer mockSessionUser(t, appconfig.SessionUserCfg{Name: "session-admin", Disabled: true}, &now, &disabled)()
+
+	assert.Equal(t, "session-admin", SessionUserName())
+	assert.True(t, SessionUserConfig().Disabled)
+}
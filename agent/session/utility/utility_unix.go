@@ -21,6 +21,8 @@ import (
 	"net"
 	"os"
 	"os/exec"
+	"strconv"
+	"strings"
 
 	"github.com/aws/amazon-ssm-agent/agent/appconfig"
 	"github.com/aws/amazon-ssm-agent/agent/context"
@@ -41,51 +43,91 @@ func (u *SessionUtil) ResetPasswordIfDefaultUserExists(context context.T) (err e
 
 // DoesUserExist checks if given user already exists
 func (u *SessionUtil) DoesUserExist(username string) (bool, error) {
-	shellCmdArgs := append(ShellPluginCommandArgs, fmt.Sprintf("id %s", username))
-	cmd := exec.Command(ShellPluginCommandName, shellCmdArgs...)
+	cmd := exec.Command("id", username)
 	if err := cmd.Run(); err != nil {
 		if exitErr, ok := err.(*exec.ExitError); ok {
 			// The program has exited with an exit code != 0
-			return false, fmt.Errorf("encountered an error while checking for %s: %v", appconfig.DefaultRunAsUserName, exitErr.Error())
+			return false, fmt.Errorf("encountered an error while checking for %s: %v", username, exitErr.Error())
 		}
 		return false, nil
 	}
 	return true, nil
 }
 
-// createLocalAdminUser creates a local OS user on the instance with admin permissions. The password will alway be empty
+// userExists returns true if the user exists, id fails the same way for missing users and other errors
+func (u *SessionUtil) userExists(username string) (bool, error) {
+	userExists, _ := u.DoesUserExist(username)
+	return userExists, nil
+}
+
+// createLocalAdminUser creates the session user on the instance, with the sudoers rule from the agent configuration
+// when it is an administrator. The password will alway be empty
 func (u *SessionUtil) CreateLocalAdminUser(log log.T) (newPassword string, err error) {
+	config := SessionUserConfig()
+	if config.Disabled {
+		return "", ErrSessionUserDisabled
+	}
 
-	userExists, _ := u.DoesUserExist(appconfig.DefaultRunAsUserName)
+	userExists, _ := u.DoesUserExist(config.Name)
 
 	if userExists {
-		log.Infof("%s already exists.", appconfig.DefaultRunAsUserName)
+		log.Infof("%s already exists.", config.Name)
 	} else {
-		if err = u.createLocalUser(log); err != nil {
+		if err = u.createLocalUser(log, config); err != nil {
 			return
 		}
 		// only create sudoers file when user does not exist
-		err = u.createSudoersFileIfNotPresent(log)
+		if config.Administrator {
+			err = u.createSudoersFileIfNotPresent(log, config)
+		}
 	}
 
+	if !config.Administrator {
+		err = u.removeSudoersFile(log)
+	}
 	return
 }
 
 // createLocalUser creates an OS local user.
-func (u *SessionUtil) createLocalUser(log log.T) error {
+func (u *SessionUtil) createLocalUser(log log.T, config appconfig.SessionUserCfg) error {
+	args := []string{"-m"}
+	if config.HomeSkeleton != "" {
+		args = append(args, "-k", config.HomeSkeleton)
+	}
+	if config.Uid > 0 {
+		args = append(args, "-u", strconv.Itoa(config.Uid))
+	}
+	if config.Gid > 0 {
+		if err := u.createPrimaryGroup(log, config); err != nil {
+			return err
+		}
+		args = append(args, "-g", strconv.Itoa(config.Gid))
+	}
+	args = append(args, config.Name)
 
-	commandArgs := append(ShellPluginCommandArgs, fmt.Sprintf("useradd -m %s", appconfig.DefaultRunAsUserName))
-	cmd := exec.Command(ShellPluginCommandName, commandArgs...)
-	if err := cmd.Run(); err != nil {
-		log.Errorf("Failed to create %s: %v", appconfig.DefaultRunAsUserName, err)
+	if output, err := exec.Command("useradd", args...).CombinedOutput(); err != nil {
+		log.Errorf("Failed to create %s: %v %s", config.Name, err, strings.TrimSpace(string(output)))
+		return err
+	}
+	log.Infof("Successfully created %s", config.Name)
+	return nil
+}
+
+// createPrimaryGroup creates a group named after the session user with the configured gid, unless a group with that gid exists
+func (u *SessionUtil) createPrimaryGroup(log log.T, config appconfig.SessionUserCfg) error {
+	gid := strconv.Itoa(config.Gid)
+	if err := exec.Command("getent", "group", gid).Run(); err == nil {
+		return nil
+	}
+	if output, err := exec.Command("groupadd", "-g", gid, config.Name).CombinedOutput(); err != nil {
+		log.Errorf("Failed to create group %s with gid %s: %v %s", config.Name, gid, err, strings.TrimSpace(string(output)))
 		return err
 	}
-	log.Infof("Successfully created %s", appconfig.DefaultRunAsUserName)
 	return nil
 }
 
 // createSudoersFileIfNotPresent will create the sudoers file if not present.
-func (u *SessionUtil) createSudoersFileIfNotPresent(log log.T) error {
+func (u *SessionUtil) createSudoersFileIfNotPresent(log log.T, config appconfig.SessionUserCfg) error {
 
 	// Return if the file exists
 	if _, err := os.Stat(sudoersFile); err == nil {
@@ -94,21 +136,34 @@ func (u *SessionUtil) createSudoersFileIfNotPresent(log log.T) error {
 		return err
 	}
 
-	// Create a sudoers file for ssm-user
+	// Create a sudoers file for the session user
 	file, err := os.Create(sudoersFile)
 	if err != nil {
-		log.Errorf("Failed to add %s to sudoers file: %v", appconfig.DefaultRunAsUserName, err)
+		log.Errorf("Failed to add %s to sudoers file: %v", config.Name, err)
 		return err
 	}
 	defer file.Close()
 
-	file.WriteString(fmt.Sprintf("# User rules for %s\n", appconfig.DefaultRunAsUserName))
-	file.WriteString(fmt.Sprintf("%s ALL=(ALL) NOPASSWD:ALL\n", appconfig.DefaultRunAsUserName))
+	file.WriteString(fmt.Sprintf("# User rules for %s\n", config.Name))
+	file.WriteString(fmt.Sprintf("%s %s\n", config.Name, config.SudoRule))
 	log.Infof("Successfully created file %s", sudoersFile)
 	u.changeModeOfSudoersFile(log)
 	return nil
 }
 
+// removeSudoersFile removes the sudoers file of the agent so a session user that is not an administrator has no sudo rule
+func (u *SessionUtil) removeSudoersFile(log log.T) error {
+	if err := os.Remove(sudoersFile); err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		log.Errorf("Failed to remove %s: %v", sudoersFile, err)
+		return err
+	}
+	log.Infof("Removed %s since the session user is not an administrator", sudoersFile)
+	return nil
+}
+
 // changeModeOfSudoersFile will change the sudoersFile mode to 0440 (read only).
 // This file is created with mode 0666 using os.Create() so needs to be updated to read only with chmod.
 func (u *SessionUtil) changeModeOfSudoersFile(log log.T) error {
@@ -121,8 +176,28 @@ func (u *SessionUtil) changeModeOfSudoersFile(log log.T) error {
 	return nil
 }
 
+// EnableLocalUser removes the expiry set on the session user when it was disabled for inactivity.
+// No password is required for unix platform local user, so the account is only ever disabled for inactivity.
+func (u *SessionUtil) EnableLocalUser(log log.T) (err error) {
+	config := SessionUserConfig()
+	if config.InactiveDays == 0 {
+		return nil
+	}
+	if output, err := exec.Command("chage", "-E", "-1", config.Name).CombinedOutput(); err != nil {
+		log.Errorf("error occurred enabling %s: %v %s", config.Name, err, strings.TrimSpace(string(output)))
+		return err
+	}
+	return nil
+}
+
+// DisableLocalUser expires the session user, sessions enable it again before they start
 func (u *SessionUtil) DisableLocalUser(log log.T) (err error) {
-	// Do nothing here as no password is required for unix platform local user, so that no need to disable user.
+	config := SessionUserConfig()
+	if output, err := exec.Command("chage", "-E", "0", config.Name).CombinedOutput(); err != nil {
+		log.Errorf("error occurred disabling %s: %v %s", config.Name, err, strings.TrimSpace(string(output)))
+		return err
+	}
+	log.Infof("Successfully disabled %s", config.Name)
 	return nil
 }
 
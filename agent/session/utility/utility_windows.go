@@ -22,7 +22,6 @@ import (
 	"syscall"
 	"unsafe"
 
-	"github.com/aws/amazon-ssm-agent/agent/appconfig"
 	"github.com/aws/amazon-ssm-agent/agent/context"
 	"github.com/aws/amazon-ssm-agent/agent/log"
 	"golang.org/x/sys/windows"
@@ -63,6 +62,8 @@ const (
 	errCodeForUserAlreadyExists = 2224
 	// Windows error code for account name already a member of the group
 	errCodeForUserAlreadyGroupMember = 1378
+	// Windows error code for account name not a member of the group
+	errCodeForUserNotGroupMember = 1377
 )
 
 type USER_INFO_1003 struct {
@@ -99,6 +100,7 @@ var (
 	netUserAdd              = modNetapi32.NewProc("NetUserAdd")
 	netApiBufferFree        = modNetapi32.NewProc("NetApiBufferFree")
 	netLocalGroupAddMembers = modNetapi32.NewProc("NetLocalGroupAddMembers")
+	netLocalGroupDelMembers = modNetapi32.NewProc("NetLocalGroupDelMembers")
 )
 
 // AddNewUser adds new user using NetUserAdd function of netapi32.dll on local machine
@@ -231,6 +233,43 @@ func (u *SessionUtil) AddUserToLocalAdministratorsGroup(username string) (adminG
 	return
 }
 
+// RemoveUserFromLocalAdministratorsGroup removes user from local built in administrators group using NetLocalGroupDelMembers function of netapi32.dll
+func (u *SessionUtil) RemoveUserFromLocalAdministratorsGroup(username string) (adminGroupName string, err error) {
+	var (
+		uPointer *uint16
+		gPointer *uint16
+	)
+
+	if adminGroupName, err = u.getBuiltInAdministratorsGroupName(); err != nil {
+		return
+	}
+
+	if uPointer, err = syscall.UTF16PtrFromString(username); err != nil {
+		return "", fmt.Errorf("Unable to encode username to UTF16")
+	}
+
+	if gPointer, err = syscall.UTF16PtrFromString(adminGroupName); err != nil {
+		return "", fmt.Errorf("Unable to encode adminGroupName to UTF16")
+	}
+
+	localGroupMembers := []LOCALGROUP_MEMBERS_INFO_3{{Lgrmi3_domainandname: uPointer}}
+
+	ret, _, _ := netLocalGroupDelMembers.Call(
+		uintptr(serverNameLocalMachine),
+		uintptr(unsafe.Pointer(gPointer)),
+		uintptr(uint32(levelForLocalGroupMembersInfo3)),
+		uintptr(unsafe.Pointer(&localGroupMembers[0])),
+		uintptr(uint32(len(localGroupMembers))),
+	)
+
+	// return error if API call failed and user is still a group member
+	if ret != nerrSuccess && ret != errCodeForUserNotGroupMember {
+		err = fmt.Errorf("NetLocalGroupDelMembers call failed. Error Code: %d", ret)
+	}
+
+	return
+}
+
 // getBuiltInAdministratorsGroupName fetches builtin local administrators group name
 func (u *SessionUtil) getBuiltInAdministratorsGroupName() (adminGroupName string, err error) {
 	var sid *windows.SID
@@ -291,20 +330,21 @@ func (u *SessionUtil) ChangePassword(username string, password string) (userExis
 
 // ResetPasswordIfDefaultUserExists resets default RunAs user password if user exists (only for agent starts)
 func (u *SessionUtil) ResetPasswordIfDefaultUserExists(context context.T) (err error) {
+	name := SessionUserName()
 	var userExists bool
-	if userExists, err = u.doesUserExist(appconfig.DefaultRunAsUserName); err != nil {
-		return fmt.Errorf("Error occured while checking if %s user exists, %v", appconfig.DefaultRunAsUserName, err)
+	if userExists, err = u.doesUserExist(name); err != nil {
+		return fmt.Errorf("Error occured while checking if %s user exists, %v", name, err)
 	}
 
 	if userExists {
 		log := context.Log()
-		log.Infof("%s already exists. Resetting password.", appconfig.DefaultRunAsUserName)
+		log.Infof("%s already exists. Resetting password.", name)
 		newPassword, err := u.GeneratePasswordForDefaultUser()
 		if err != nil {
 			return err
 		}
-		if _, err = u.ChangePassword(appconfig.DefaultRunAsUserName, newPassword); err != nil {
-			return fmt.Errorf("Error occured while changing password for %s, %v", appconfig.DefaultRunAsUserName, err)
+		if _, err = u.ChangePassword(name, newPassword); err != nil {
+			return fmt.Errorf("Error occured while changing password for %s, %v", name, err)
 		}
 	}
 
@@ -347,8 +387,18 @@ func (u *SessionUtil) doesUserExist(username string) (bool, error) {
 	return userExists, err
 }
 
-// createLocalAdminUser creates a local OS user on the instance with admin permissions.
+// userExists returns true if the user exists
+func (u *SessionUtil) userExists(username string) (bool, error) {
+	return u.doesUserExist(username)
+}
+
+// createLocalAdminUser creates the session user on the instance, in the local administrators group when it is an administrator.
 func (u *SessionUtil) CreateLocalAdminUser(log log.T) (newPassword string, err error) {
+	config := SessionUserConfig()
+	if config.Disabled {
+		return "", ErrSessionUserDisabled
+	}
+
 	if u.IsInstanceADomainController(log) {
 		return "", fmt.Errorf("Instance is running active directory domain controller service. Disable the service to continue to use session manager.")
 	}
@@ -358,42 +408,64 @@ func (u *SessionUtil) CreateLocalAdminUser(log log.T) (newPassword string, err e
 	}
 
 	var userExists bool
-	if userExists, err = u.AddNewUser(appconfig.DefaultRunAsUserName, newPassword); err != nil {
-		return "", fmt.Errorf("Failed to create %s: %v", appconfig.DefaultRunAsUserName, err)
+	if userExists, err = u.AddNewUser(config.Name, newPassword); err != nil {
+		return "", fmt.Errorf("Failed to create %s: %v", config.Name, err)
 	}
 
 	if userExists {
-		log.Infof("%s already exists.", appconfig.DefaultRunAsUserName)
+		log.Infof("%s already exists.", config.Name)
+		return
+	}
+	log.Infof("Successfully created %s", config.Name)
+
+	if !config.Administrator {
 		return
 	}
-	log.Infof("Successfully created %s", appconfig.DefaultRunAsUserName)
 
 	var adminGroupName string
-	if adminGroupName, err = u.AddUserToLocalAdministratorsGroup(appconfig.DefaultRunAsUserName); err != nil {
-		return newPassword, fmt.Errorf("Failed to add %s to local admin group: %v", appconfig.DefaultRunAsUserName, err)
+	if adminGroupName, err = u.AddUserToLocalAdministratorsGroup(config.Name); err != nil {
+		return newPassword, fmt.Errorf("Failed to add %s to local admin group: %v", config.Name, err)
 	}
-	log.Infof("Added %s to %s group", appconfig.DefaultRunAsUserName, adminGroupName)
+	log.Infof("Added %s to %s group", config.Name, adminGroupName)
 
 	return
 }
 
+// EnsureSessionUserPrivileges removes the session user from the local administrators group when it is not an administrator,
+// so an account created before the configuration changed loses its privileges
+func (u *SessionUtil) EnsureSessionUserPrivileges(log log.T) (err error) {
+	config := SessionUserConfig()
+	if config.Administrator {
+		return nil
+	}
+
+	var adminGroupName string
+	if adminGroupName, err = u.RemoveUserFromLocalAdministratorsGroup(config.Name); err != nil {
+		return fmt.Errorf("Failed to remove %s from local admin group: %v", config.Name, err)
+	}
+	log.Debugf("%s is not a member of %s group", config.Name, adminGroupName)
+	return nil
+}
+
 func (u *SessionUtil) EnableLocalUser(log log.T) (err error) {
-	if err = u.userDelFlags(log, appconfig.DefaultRunAsUserName, USER_UF_ACCOUNTDISABLE); err != nil {
-		log.Errorf("error occurred disabling %s: %v", appconfig.DefaultRunAsUserName, err)
+	name := SessionUserName()
+	if err = u.userDelFlags(log, name, USER_UF_ACCOUNTDISABLE); err != nil {
+		log.Errorf("error occurred enabling %s: %v", name, err)
 		return err
 	}
 
-	log.Infof("Successfully enabled %s", appconfig.DefaultRunAsUserName)
+	log.Infof("Successfully enabled %s", name)
 	return nil
 }
 
 func (u *SessionUtil) DisableLocalUser(log log.T) (err error) {
-	if err = u.userAddFlags(log, appconfig.DefaultRunAsUserName, USER_UF_ACCOUNTDISABLE); err != nil {
-		log.Errorf("error occurred disabling %s: %v", appconfig.DefaultRunAsUserName, err)
+	name := SessionUserName()
+	if err = u.userAddFlags(log, name, USER_UF_ACCOUNTDISABLE); err != nil {
+		log.Errorf("error occurred disabling %s: %v", name, err)
 		return err
 	}
 
-	log.Infof("Successfully disabled %s", appconfig.DefaultRunAsUserName)
+	log.Infof("Successfully disabled %s", name)
 	return nil
 }
 
@@ -0,0 +1,158 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package shutdown coordinates the graceful shutdown of the agent worker. Modules register hooks for the phases
+// of the shutdown, Run executes them in phase order and gives up once the deadline has passed.
+package shutdown
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/log"
+)
+
+// Phase orders the hooks run during the shutdown
+type Phase int
+
+const (
+	// PhaseNotify tells the clients of the running sessions that their sessions end
+	PhaseNotify Phase = iota
+	// PhaseDrain stops the core modules, which wait for their running documents and send their replies
+	PhaseDrain
+	// PhaseFlush sends the results still queued, the results that cannot be sent stay queued for the restart
+	PhaseFlush
+)
+
+var phases = []Phase{PhaseNotify, PhaseDrain, PhaseFlush}
+
+// Hook runs during its phase, the shutdown moves on to the next phase once all the hooks of a phase returned
+type Hook func(log log.T)
+
+var (
+	timeNow = time.Now
+
+	lock       sync.RWMutex
+	hooks      = map[Phase]map[string]Hook{}
+	inProgress bool
+	deadline   time.Time
+)
+
+// Register sets the hook run during the phase under name, registering a name again replaces its hook
+func Register(phase Phase, name string, hook Hook) {
+	lock.Lock()
+	defer lock.Unlock()
+	if hooks[phase] == nil {
+		hooks[phase] = map[string]Hook{}
+	}
+	hooks[phase][name] = hook
+}
+
+// Unregister removes the hooks registered under name
+func Unregister(name string) {
+	lock.Lock()
+	defer lock.Unlock()
+	for _, phaseHooks := range hooks {
+		delete(phaseHooks, name)
+	}
+}
+
+// InProgress returns true once the shutdown started, new documents and sessions are no longer accepted
+func InProgress() bool {
+	lock.RLock()
+	defer lock.RUnlock()
+	return inProgress
+}
+
+// Remaining returns the time left until the deadline of the shutdown, zero when no shutdown is in progress
+func Remaining() time.Duration {
+	lock.RLock()
+	defer lock.RUnlock()
+	if !inProgress {
+		return 0
+	}
+	if remaining := deadline.Sub(timeNow()); remaining > 0 {
+		return remaining
+	}
+	return 0
+}
+
+// Run starts the shutdown and runs the hooks of each phase concurrently, one phase after the other.
+// It returns false when the deadline passed before all the hooks returned, the hooks still running are abandoned.
+func Run(log log.T, timeout time.Duration) (completed bool) {
+	lock.Lock()
+	inProgress = true
+	deadline = timeNow().Add(timeout)
+	lock.Unlock()
+
+	log.Infof("Shutting down, waiting at most %v for the running documents and sessions", timeout)
+	for _, phase := range phases {
+		if !runPhase(log, phase) {
+			return false
+		}
+	}
+	return true
+}
+
+// runPhase runs the hooks registered for the phase and waits for them until the deadline
+func runPhase(log log.T, phase Phase) bool {
+	lock.RLock()
+	names := make([]string, 0, len(hooks[phase]))
+	phaseHooks := make(map[string]Hook, len(hooks[phase]))
+	for name, hook := range hooks[phase] {
+		names = append(names, name)
+		phaseHooks[name] = hook
+	}
+	lock.RUnlock()
+	sort.Strings(names)
+
+	var doneLock sync.Mutex
+	done := map[string]bool{}
+	var wg sync.WaitGroup
+	for _, name := range names {
+		wg.Add(1)
+		go func(name string, hook Hook) {
+			defer wg.Done()
+			defer func() {
+				if msg := recover(); msg != nil {
+					log.Errorf("Shutdown hook %v panicked: %v", name, msg)
+				}
+			}()
+			hook(log)
+			doneLock.Lock()
+			done[name] = true
+			doneLock.Unlock()
+		}(name, phaseHooks[name])
+	}
+
+	finished := make(chan bool)
+	go func() {
+		wg.Wait()
+		close(finished)
+	}()
+
+	select {
+	case <-finished:
+		return true
+	case <-time.After(Remaining()):
+		doneLock.Lock()
+		defer doneLock.Unlock()
+		for _, name := range names {
+			if !done[name] {
+				log.Warnf("Shutdown deadline passed before %v finished", name)
+			}
+		}
+		return false
+	}
+}
@@ -0,0 +1,83 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package shutdown
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func resetShutdown() {
+	lock.Lock()
+	defer lock.Unlock()
+	hooks = map[Phase]map[string]Hook{}
+	inProgress = false
+	deadline = time.Time{}
+}
+
+func TestRunPhasesInOrder(t *testing.T) {
+	resetShutdown()
+	defer resetShutdown()
+
+	var orderLock sync.Mutex
+	var order []string
+	record := func(name string) Hook {
+		return func(log log.T) {
+			orderLock.Lock()
+			defer orderLock.Unlock()
+			order = append(order, name)
+		}
+	}
+	Register(PhaseFlush, "flush", record("flush"))
+	Register(PhaseDrain, "drain", record("drain"))
+	Register(PhaseNotify, "notify", record("notify"))
+	Register(PhaseNotify, "removed", record("removed"))
+	Unregister("removed")
+
+	assert.False(t, InProgress())
+	assert.Equal(t, time.Duration(0), Remaining())
+
+	assert.True(t, Run(log.NewMockLog(), time.Minute))
+	assert.Equal(t, []string{"notify", "drain", "flush"}, order)
+	assert.True(t, InProgress())
+	assert.True(t, Remaining() > 0)
+}
+
+func TestRunStopsAtTheDeadline(t *testing.T) {
+	resetShutdown()
+	defer resetShutdown()
+
+	release := make(chan bool)
+	defer close(release)
+	flushed := false
+	Register(PhaseDrain, "stuck", func(log log.T) { <-release })
+	Register(PhaseFlush, "flush", func(log log.T) { flushed = true })
+
+	assert.False(t, Run(log.NewMockLog(), 50*time.Millisecond))
+	assert.False(t, flushed)
+	assert.Equal(t, time.Duration(0), Remaining())
+}
+
+func TestRunRecoversFromPanickingHook(t *testing.T) {
+	resetShutdown()
+	defer resetShutdown()
+
+	Register(PhaseNotify, "panic", func(log log.T) { panic("failed") })
+
+	assert.True(t, Run(log.NewMockLog(), time.Minute))
+}
@@ -0,0 +1,87 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package signature verifies the signatures made with the public keys an administrator configures,
+// such as the keys of command approvers, pinned documents and package mirrors.
+package signature
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+)
+
+// ParsePublicKey parses the first public key of a PEM encoded key or certificate file
+func ParsePublicKey(content []byte) (crypto.PublicKey, error) {
+	keys, err := ParsePublicKeys(content)
+	if err != nil {
+		return nil, err
+	}
+	return keys[0], nil
+}
+
+// ParsePublicKeys parses the PKIX and PKCS #1 public keys and the certificates of a PEM encoded file.
+// Ed25519, ECDSA and RSA keys are accepted, the blocks of other types are skipped.
+func ParsePublicKeys(content []byte) (keys []crypto.PublicKey, err error) {
+	for block, rest := pem.Decode(content); block != nil; block, rest = pem.Decode(rest) {
+		var key crypto.PublicKey
+		switch block.Type {
+		case "PUBLIC KEY":
+			key, err = x509.ParsePKIXPublicKey(block.Bytes)
+		case "RSA PUBLIC KEY":
+			key, err = x509.ParsePKCS1PublicKey(block.Bytes)
+		case "CERTIFICATE":
+			var certificate *x509.Certificate
+			if certificate, err = x509.ParseCertificate(block.Bytes); err == nil {
+				key = certificate.PublicKey
+			}
+		default:
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse the key: %v", err)
+		}
+		switch key.(type) {
+		case ed25519.PublicKey, *ecdsa.PublicKey, *rsa.PublicKey:
+			keys = append(keys, key)
+		default:
+			return nil, fmt.Errorf("the key is not an Ed25519, ECDSA or RSA key")
+		}
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no PEM encoded public key found")
+	}
+	return keys, nil
+}
+
+// Verify returns true if the signature of the message was made by the key. Ed25519 keys sign the message,
+// ECDSA keys with ASN.1 signatures and RSA keys with PKCS #1 v1.5 or PSS signatures sign its sha256.
+func Verify(key crypto.PublicKey, message []byte, signature []byte) bool {
+	switch publicKey := key.(type) {
+	case ed25519.PublicKey:
+		return ed25519.Verify(publicKey, message, signature)
+	case *ecdsa.PublicKey:
+		digest := sha256.Sum256(message)
+		return ecdsa.VerifyASN1(publicKey, digest[:], signature)
+	case *rsa.PublicKey:
+		digest := sha256.Sum256(message)
+		return rsa.VerifyPKCS1v15(publicKey, crypto.SHA256, digest[:], signature) == nil ||
+			rsa.VerifyPSS(publicKey, crypto.SHA256, digest[:], signature, nil) == nil
+	}
+	return false
+}
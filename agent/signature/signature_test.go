@@ -0,0 +1,104 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package signature
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func encodePublicKey(t *testing.T, key crypto.PublicKey) []byte {
+	der, err := x509.MarshalPKIXPublicKey(key)
+	assert.NoError(t, err)
+	return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+}
+
+func TestParsePublicKey(t *testing.T) {
+	edPublic, _, _ := ed25519.GenerateKey(rand.Reader)
+	ecPrivate, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	rsaPrivate, _ := rsa.GenerateKey(rand.Reader, 2048)
+
+	key, err := ParsePublicKey(encodePublicKey(t, edPublic))
+	assert.NoError(t, err)
+	assert.Equal(t, edPublic, key)
+
+	key, err = ParsePublicKey(encodePublicKey(t, &ecPrivate.PublicKey))
+	assert.NoError(t, err)
+	assert.Equal(t, &ecPrivate.PublicKey, key)
+
+	key, err = ParsePublicKey(encodePublicKey(t, &rsaPrivate.PublicKey))
+	assert.NoError(t, err)
+	assert.Equal(t, &rsaPrivate.PublicKey, key)
+
+	_, err = ParsePublicKey([]byte("not a key"))
+	assert.Error(t, err)
+	_, err = ParsePublicKey(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: []byte("invalid")}))
+	assert.Error(t, err)
+}
+
+func TestParsePublicKeys(t *testing.T) {
+	edPublic, edPrivate, _ := ed25519.GenerateKey(rand.Reader)
+	rsaPrivate, _ := rsa.GenerateKey(rand.Reader, 2048)
+	pkcs1 := pem.EncodeToMemory(&pem.Block{Type: "RSA PUBLIC KEY", Bytes: x509.MarshalPKCS1PublicKey(&rsaPrivate.PublicKey)})
+	template := &x509.Certificate{SerialNumber: big.NewInt(1), NotBefore: time.Now(), NotAfter: time.Now().Add(time.Hour)}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, edPublic, edPrivate)
+	assert.NoError(t, err)
+	certificate := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	other := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: []byte("skipped")})
+
+	content := append(append(append([]byte{}, other...), pkcs1...), certificate...)
+	keys, err := ParsePublicKeys(content)
+	assert.NoError(t, err)
+	assert.Equal(t, []crypto.PublicKey{&rsaPrivate.PublicKey, edPublic}, keys)
+
+	_, err = ParsePublicKeys(other)
+	assert.Error(t, err)
+	_, err = ParsePublicKeys(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: []byte("invalid")}))
+	assert.Error(t, err)
+}
+
+func TestVerify(t *testing.T) {
+	message := []byte("message")
+	digest := sha256.Sum256(message)
+
+	edPublic, edPrivate, _ := ed25519.GenerateKey(rand.Reader)
+	assert.True(t, Verify(edPublic, message, ed25519.Sign(edPrivate, message)))
+	assert.False(t, Verify(edPublic, []byte("other"), ed25519.Sign(edPrivate, message)))
+
+	ecPrivate, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	ecSignature, _ := ecdsa.SignASN1(rand.Reader, ecPrivate, digest[:])
+	assert.True(t, Verify(&ecPrivate.PublicKey, message, ecSignature))
+	assert.False(t, Verify(edPublic, message, ecSignature))
+
+	rsaPrivate, _ := rsa.GenerateKey(rand.Reader, 2048)
+	pkcs1Signature, _ := rsa.SignPKCS1v15(rand.Reader, rsaPrivate, crypto.SHA256, digest[:])
+	pssSignature, _ := rsa.SignPSS(rand.Reader, rsaPrivate, crypto.SHA256, digest[:], nil)
+	assert.True(t, Verify(&rsaPrivate.PublicKey, message, pkcs1Signature))
+	assert.True(t, Verify(&rsaPrivate.PublicKey, message, pssSignature))
+	assert.False(t, Verify(&rsaPrivate.PublicKey, []byte("other"), pkcs1Signature))
+
+	assert.False(t, Verify(nil, message, pkcs1Signature))
+}
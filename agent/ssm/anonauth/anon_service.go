@@ -19,11 +19,11 @@ import (
 
 	"github.com/aws/amazon-ssm-agent/agent/appconfig"
 	"github.com/aws/amazon-ssm-agent/agent/platform"
+	"github.com/aws/amazon-ssm-agent/agent/proxyconfig"
+	"github.com/aws/amazon-ssm-agent/agent/sdkutil/sdksession"
 	"github.com/aws/amazon-ssm-agent/agent/ssm/util"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/credentials"
-	"github.com/aws/aws-sdk-go/aws/request"
-	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/ssm"
 )
 
@@ -69,8 +69,7 @@ func NewAnonymousService(region string) AnonymousService {
 	}
 
 	// Create a session to share service client config and handlers with
-	ssmSess := session.New(awsConfig)
-	ssmSess.Handlers.Build.PushBack(request.MakeAddToUserAgentHandler(appConfig.Agent.Name, appConfig.Agent.Version))
+	ssmSess := sdksession.New(awsConfig, proxyconfig.ServiceSsm)
 
 	ssmService := ssm.New(ssmSess)
 	return &sdkService{sdk: ssmService}
@@ -0,0 +1,285 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package ssm
+
+import (
+	"crypto"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/platform"
+	"github.com/aws/amazon-ssm-agent/agent/sdkutil"
+	"github.com/aws/amazon-ssm-agent/agent/signature"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ssm"
+)
+
+// documentCacheDirName is the directory under the document root directory that holds the cached documents
+const documentCacheDirName = "cache"
+
+// documentVersionPattern matches document version numbers, the content of a version never changes
+var documentVersionPattern = regexp.MustCompile(`^[1-9][0-9]*$`)
+
+var (
+	getAppConfig = appconfig.Config
+
+	readPublicKey = ioutil.ReadFile
+
+	documentCacheDirectory = defaultDocumentCacheDirectory
+)
+
+// defaultDocumentCacheDirectory returns the directory of the cached documents of the instance
+func defaultDocumentCacheDirectory() (string, error) {
+	instanceID, err := platform.InstanceID()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(appconfig.DefaultDataStorePath, instanceID, appconfig.DefaultDocumentRootDirName, documentCacheDirName), nil
+}
+
+// cachedDocument is a GetDocument response in the document cache. Hash is the hash DescribeDocument returned for
+// the version, ContentSha256 detects cache files that changed since they were written.
+type cachedDocument struct {
+	Hash          string
+	ContentSha256 string
+	Document      *ssm.GetDocumentOutput
+}
+
+//GetDocument calls the GetDocument SSM API to retrieve document with given document name, or returns the cached
+//document when the agent config enables the document cache. Documents matching a pin of the agent config are verified.
+func (svc *sdkService) GetDocument(log log.T, docName string, docVersion string) (response *ssm.GetDocumentOutput, err error) {
+	config, err := getAppConfig(false)
+	if err != nil {
+		return svc.getDocument(log, docName, docVersion)
+	}
+	documentsConfig := config.ServiceDocuments
+
+	var cacheDir, version, hash string
+	if documentsConfig.CacheEnabled {
+		if cacheDir, err = documentCacheDirectory(); err != nil {
+			log.Debugf("document cache is not available: %v", err)
+		} else if version, hash = svc.resolveDocumentVersion(log, docName, docVersion); version != "" {
+			if response = readCachedDocument(log, cacheDir, docName, version, hash); response != nil {
+				log.Debugf("Document %v version %v served from the document cache", docName, version)
+			}
+		}
+	}
+
+	if response == nil {
+		if response, err = svc.getDocument(log, docName, docVersion); err != nil {
+			return nil, err
+		}
+	}
+	if err = verifyDocument(documentsConfig.Pinned, docName, response); err != nil {
+		log.Errorf("document %v failed verification: %v", docName, err)
+		return nil, err
+	}
+
+	if cacheDir != "" {
+		if aws.StringValue(response.DocumentVersion) != version {
+			// the hash was returned for another version than the one GetDocument returned
+			hash = ""
+		}
+		if err := writeCachedDocument(cacheDir, docName, hash, response, documentsConfig.CacheMaxEntries); err != nil {
+			log.Warnf("failed to cache document %v: %v", docName, err)
+		}
+	}
+	return response, nil
+}
+
+// getDocument calls the GetDocument SSM API
+func (svc *sdkService) getDocument(log log.T, docName string, docVersion string) (response *ssm.GetDocumentOutput, err error) {
+	params := ssm.GetDocumentInput{
+		Name: aws.String(docName),
+	}
+
+	if docVersion != "" {
+		params.DocumentVersion = aws.String(docVersion)
+	}
+
+	response, err = svc.sdk.GetDocument(&params)
+	if err != nil {
+		sdkutil.HandleAwsError(log, err, ssmStopPolicy)
+		return
+	}
+	log.Debug("GetDocument Response", response)
+	return
+}
+
+// resolveDocumentVersion returns the version number of the requested version, and its hash when the version is
+// not a number. DescribeDocument resolves the default and latest versions without returning the content.
+func (svc *sdkService) resolveDocumentVersion(log log.T, docName string, docVersion string) (version string, hash string) {
+	if documentVersionPattern.MatchString(docVersion) {
+		return docVersion, ""
+	}
+	params := ssm.DescribeDocumentInput{
+		Name: aws.String(docName),
+	}
+	if docVersion != "" {
+		params.DocumentVersion = aws.String(docVersion)
+	}
+	response, err := svc.sdk.DescribeDocument(&params)
+	if err != nil || response.Document == nil {
+		log.Debugf("failed to describe document %v, it is fetched without the document cache: %v", docName, err)
+		return "", ""
+	}
+	return aws.StringValue(response.Document.DocumentVersion), aws.StringValue(response.Document.Hash)
+}
+
+// documentCacheFile returns the cache file of a version of a document, names are hashed as they can be ARNs
+func documentCacheFile(cacheDir string, docName string, version string) string {
+	nameHash := sha256.Sum256([]byte(docName))
+	return filepath.Join(cacheDir, fmt.Sprintf("%v_%v.json", hex.EncodeToString(nameHash[:]), version))
+}
+
+// readCachedDocument returns the cached version of the document, nil when it is not cached, was modified, or its
+// hash differs from the hash the service returned for the version
+func readCachedDocument(log log.T, cacheDir string, docName string, version string, hash string) *ssm.GetDocumentOutput {
+	cacheFile := documentCacheFile(cacheDir, docName, version)
+	content, err := ioutil.ReadFile(cacheFile)
+	if err != nil {
+		return nil
+	}
+	var cached cachedDocument
+	if err = json.Unmarshal(content, &cached); err != nil || cached.Document == nil {
+		log.Warnf("ignoring malformed document cache file %v", cacheFile)
+		return nil
+	}
+	if contentHash := sha256.Sum256([]byte(aws.StringValue(cached.Document.Content))); hex.EncodeToString(contentHash[:]) != cached.ContentSha256 {
+		log.Warnf("ignoring document cache file %v, its content was modified", cacheFile)
+		return nil
+	}
+	if hash != "" && hash != cached.Hash {
+		return nil
+	}
+	// the modification time orders the cache files from the least recently used
+	now := time.Now()
+	os.Chtimes(cacheFile, now, now)
+	return cached.Document
+}
+
+// writeCachedDocument adds the document to the cache and removes the least recently used versions past maxEntries.
+// The file is renamed into place so that concurrent document workers never read a partial file.
+func writeCachedDocument(cacheDir string, docName string, hash string, document *ssm.GetDocumentOutput, maxEntries int) error {
+	version := aws.StringValue(document.DocumentVersion)
+	if !documentVersionPattern.MatchString(version) {
+		return fmt.Errorf("unexpected document version %q", version)
+	}
+	contentHash := sha256.Sum256([]byte(aws.StringValue(document.Content)))
+	content, err := json.Marshal(cachedDocument{Hash: hash, ContentSha256: hex.EncodeToString(contentHash[:]), Document: document})
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(cacheDir, appconfig.ReadWriteExecuteAccess); err != nil {
+		return err
+	}
+	tmpFile, err := ioutil.TempFile(cacheDir, ".document")
+	if err != nil {
+		return err
+	}
+	_, err = tmpFile.Write(content)
+	if closeErr := tmpFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmpFile.Name(), documentCacheFile(cacheDir, docName, version))
+	}
+	if err != nil {
+		os.Remove(tmpFile.Name())
+		return err
+	}
+	pruneDocumentCache(cacheDir, maxEntries)
+	return nil
+}
+
+// pruneDocumentCache removes the least recently used cache files past maxEntries
+func pruneDocumentCache(cacheDir string, maxEntries int) {
+	files, err := ioutil.ReadDir(cacheDir)
+	if err != nil {
+		return
+	}
+	var cached []os.FileInfo
+	for _, file := range files {
+		if !file.IsDir() && filepath.Ext(file.Name()) == ".json" {
+			cached = append(cached, file)
+		}
+	}
+	if len(cached) <= maxEntries {
+		return
+	}
+	sort.Slice(cached, func(i, j int) bool { return cached[i].ModTime().Before(cached[j].ModTime()) })
+	for _, file := range cached[:len(cached)-maxEntries] {
+		os.Remove(filepath.Join(cacheDir, file.Name()))
+	}
+}
+
+// verifyDocument checks the document against the first pin matching its name. Its content must have one of the pinned
+// hashes, or its version name must be a signature of the content by one of the pinned keys.
+func verifyDocument(pins []appconfig.PinnedDocumentCfg, docName string, document *ssm.GetDocumentOutput) error {
+	for _, pin := range pins {
+		if matched, _ := path.Match(pin.Name, docName); !matched {
+			continue
+		}
+		content := []byte(aws.StringValue(document.Content))
+		contentHash := sha256.Sum256(content)
+		for _, hash := range pin.Sha256 {
+			if hash == hex.EncodeToString(contentHash[:]) {
+				return nil
+			}
+		}
+		if len(pin.PublicKeys) == 0 {
+			return fmt.Errorf("the content does not match the hashes pinned for %v", pin.Name)
+		}
+		versionSignature, err := base64.RawURLEncoding.DecodeString(aws.StringValue(document.VersionName))
+		if err != nil || len(versionSignature) == 0 {
+			return fmt.Errorf("the version name of the document is not a signature, as pinned for %v", pin.Name)
+		}
+		for _, keyPath := range pin.PublicKeys {
+			key, err := loadDocumentPublicKey(keyPath)
+			if err != nil {
+				return err
+			}
+			if signature.Verify(key, content, versionSignature) {
+				return nil
+			}
+		}
+		return fmt.Errorf("the signature does not match the keys pinned for %v", pin.Name)
+	}
+	return nil
+}
+
+// loadDocumentPublicKey reads a PEM encoded Ed25519, ECDSA or RSA public key or certificate
+func loadDocumentPublicKey(keyPath string) (crypto.PublicKey, error) {
+	content, err := readPublicKey(keyPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read document public key %v: %v", keyPath, err)
+	}
+	key, err := signature.ParsePublicKey(content)
+	if err != nil {
+		return nil, fmt.Errorf("document public key %v: %v", keyPath, err)
+	}
+	return key, nil
+}
@@ -0,0 +1,191 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package ssm
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ssm"
+	"github.com/aws/aws-sdk-go/service/ssm/ssmiface"
+	"github.com/stretchr/testify/assert"
+)
+
+// documentSSM serves a single version of a document and counts the GetDocument calls
+type documentSSM struct {
+	ssmiface.SSMAPI
+	content     string
+	version     string
+	versionName string
+	hash        string
+	gets        int
+}
+
+func (s *documentSSM) GetDocument(input *ssm.GetDocumentInput) (*ssm.GetDocumentOutput, error) {
+	s.gets++
+	return &ssm.GetDocumentOutput{
+		Name:            input.Name,
+		Content:         aws.String(s.content),
+		DocumentVersion: aws.String(s.version),
+		VersionName:     aws.String(s.versionName),
+	}, nil
+}
+
+func (s *documentSSM) DescribeDocument(input *ssm.DescribeDocumentInput) (*ssm.DescribeDocumentOutput, error) {
+	return &ssm.DescribeDocumentOutput{
+		Document: &ssm.DocumentDescription{
+			Name:            input.Name,
+			DocumentVersion: aws.String(s.version),
+			Hash:            aws.String(s.hash),
+		},
+	}, nil
+}
+
+func setDocumentConfig(t *testing.T, documentsConfig appconfig.ServiceDocumentsCfg) (cacheDir string) {
+	cacheDir, err := ioutil.TempDir("", "document_cache")
+	assert.NoError(t, err)
+	getAppConfig = func(bool) (appconfig.SsmagentConfig, error) {
+		return appconfig.SsmagentConfig{ServiceDocuments: documentsConfig}, nil
+	}
+	documentCacheDirectory = func() (string, error) { return cacheDir, nil }
+	return cacheDir
+}
+
+func restoreDocumentConfig(cacheDir string) {
+	getAppConfig = appconfig.Config
+	documentCacheDirectory = defaultDocumentCacheDirectory
+	readPublicKey = ioutil.ReadFile
+	os.RemoveAll(cacheDir)
+}
+
+func TestGetDocumentCache(t *testing.T) {
+	cacheDir := setDocumentConfig(t, appconfig.ServiceDocumentsCfg{CacheEnabled: true, CacheMaxEntries: 2})
+	defer restoreDocumentConfig(cacheDir)
+	fake := &documentSSM{content: "{}", version: "3", hash: "h3"}
+	svc := &sdkService{sdk: fake}
+	logger := log.NewMockLog()
+
+	for i := 0; i < 2; i++ {
+		response, err := svc.GetDocument(logger, "Shared", "")
+		assert.NoError(t, err)
+		assert.Equal(t, "{}", aws.StringValue(response.Content))
+	}
+	assert.Equal(t, 1, fake.gets)
+
+	response, err := svc.GetDocument(logger, "Shared", "3")
+	assert.NoError(t, err)
+	assert.Equal(t, "3", aws.StringValue(response.DocumentVersion))
+	assert.Equal(t, 1, fake.gets)
+
+	// the default version changed its hash, the cached version is stale
+	fake.hash, fake.content = "h3b", `{"a":1}`
+	response, err = svc.GetDocument(logger, "Shared", "$DEFAULT")
+	assert.NoError(t, err)
+	assert.Equal(t, `{"a":1}`, aws.StringValue(response.Content))
+	assert.Equal(t, 2, fake.gets)
+
+	// modified cache files are ignored
+	assert.NoError(t, ioutil.WriteFile(documentCacheFile(cacheDir, "Shared", "3"), []byte(`{"Hash":"h3b","ContentSha256":"00","Document":{"Content":"x"}}`), 0600))
+	response, err = svc.GetDocument(logger, "Shared", "3")
+	assert.NoError(t, err)
+	assert.Equal(t, `{"a":1}`, aws.StringValue(response.Content))
+	assert.Equal(t, 3, fake.gets)
+
+	for _, version := range []string{"4", "5"} {
+		fake.version = version
+		_, err = svc.GetDocument(logger, "Shared", version)
+		assert.NoError(t, err)
+	}
+	files, _ := ioutil.ReadDir(cacheDir)
+	assert.Len(t, files, 2)
+}
+
+func TestGetDocumentCacheDisabled(t *testing.T) {
+	cacheDir := setDocumentConfig(t, appconfig.ServiceDocumentsCfg{CacheMaxEntries: 2})
+	defer restoreDocumentConfig(cacheDir)
+	fake := &documentSSM{content: "{}", version: "1"}
+	svc := &sdkService{sdk: fake}
+
+	for i := 0; i < 2; i++ {
+		_, err := svc.GetDocument(log.NewMockLog(), "Shared", "1")
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, 2, fake.gets)
+	files, _ := ioutil.ReadDir(cacheDir)
+	assert.Empty(t, files)
+}
+
+func TestGetDocumentPinnedHash(t *testing.T) {
+	contentHash := sha256.Sum256([]byte("{}"))
+	cacheDir := setDocumentConfig(t, appconfig.ServiceDocumentsCfg{Pinned: []appconfig.PinnedDocumentCfg{
+		{Name: "Shared*", Sha256: []string{hex.EncodeToString(contentHash[:])}},
+	}})
+	defer restoreDocumentConfig(cacheDir)
+	fake := &documentSSM{content: "{}", version: "1"}
+	svc := &sdkService{sdk: fake}
+
+	_, err := svc.GetDocument(log.NewMockLog(), "SharedDoc", "1")
+	assert.NoError(t, err)
+
+	fake.content = `{"changed":true}`
+	_, err = svc.GetDocument(log.NewMockLog(), "SharedDoc", "1")
+	assert.Error(t, err)
+
+	// documents without a matching pin are not verified
+	_, err = svc.GetDocument(log.NewMockLog(), "Other", "1")
+	assert.NoError(t, err)
+}
+
+func TestGetDocumentPinnedSignature(t *testing.T) {
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+	der, err := x509.MarshalPKIXPublicKey(publicKey)
+	assert.NoError(t, err)
+	cacheDir := setDocumentConfig(t, appconfig.ServiceDocumentsCfg{Pinned: []appconfig.PinnedDocumentCfg{
+		{Name: "Shared", PublicKeys: []string{"/keys/shared.pem"}},
+	}})
+	defer restoreDocumentConfig(cacheDir)
+	readPublicKey = func(string) ([]byte, error) {
+		return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), nil
+	}
+	content := `{"schemaVersion":"2.2"}`
+	fake := &documentSSM{
+		content:     content,
+		version:     "1",
+		versionName: base64.RawURLEncoding.EncodeToString(ed25519.Sign(privateKey, []byte(content))),
+	}
+	svc := &sdkService{sdk: fake}
+
+	_, err = svc.GetDocument(log.NewMockLog(), "Shared", "1")
+	assert.NoError(t, err)
+
+	fake.content = `{"schemaVersion":"0.3"}`
+	_, err = svc.GetDocument(log.NewMockLog(), "Shared", "1")
+	assert.Error(t, err)
+
+	fake.versionName = "release"
+	_, err = svc.GetDocument(log.NewMockLog(), "Shared", "1")
+	assert.Error(t, err)
+}
@@ -134,11 +134,11 @@ func (v4 *signer) buildRsa() {
 
 // Sign the stringToSign using the private key
 func (v4 *signer) buildRsaSignature() (err error) {
-	var rsaKey auth.RsaKey
-	rsaKey, err = auth.DecodePrivateKey(v4.CredValues.SecretAccessKey)
+	var signingKey auth.SigningKey
+	signingKey, err = auth.LoadSigningKey(v4.CredValues.SecretAccessKey)
 	if err != nil {
 		return
 	}
-	v4.signature, err = rsaKey.Sign(v4.stringToSign)
+	v4.signature, err = signingKey.Sign(v4.stringToSign)
 	return
 }
@@ -14,7 +14,6 @@
 package ssm
 
 import (
-	"crypto/tls"
 	"fmt"
 	"net/http"
 	"runtime"
@@ -22,11 +21,12 @@ import (
 
 	"github.com/aws/amazon-ssm-agent/agent/appconfig"
 	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/network/clockskew"
+	"github.com/aws/amazon-ssm-agent/agent/network/failover"
 	"github.com/aws/amazon-ssm-agent/agent/platform"
+	"github.com/aws/amazon-ssm-agent/agent/proxyconfig"
 	"github.com/aws/amazon-ssm-agent/agent/sdkutil"
 	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/request"
-	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/ssm"
 	"github.com/aws/aws-sdk-go/service/ssm/ssmiface"
 )
@@ -108,16 +108,16 @@ func NewService() Service {
 		// TODO: test hook, can be removed before release
 		// this is to skip ssl verification for the beta self signed certs
 		if appConfig.Ssm.InsecureSkipVerify {
-			tr := &http.Transport{
-				TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
-			}
+			tr := proxyconfig.NewTransport(proxyconfig.ServiceSsm)
+			tr.TLSClientConfig.InsecureSkipVerify = true
 			awsConfig.HTTPClient = &http.Client{Transport: tr}
 		}
 	}
-	sess := session.New(awsConfig)
-	sess.Handlers.Build.PushBack(request.MakeAddToUserAgentHandler(appConfig.Agent.Name, appConfig.Agent.Version))
+	sess := sdkutil.NewSession(awsConfig, proxyconfig.ServiceSsm)
+	failover.AddHandlers(&sess.Handlers, proxyconfig.ServiceSsm)
 
 	ssmService := ssm.New(sess)
+	clockskew.AddHandlers(&ssmService.Handlers)
 	return NewSSMService(ssmService)
 }
 
@@ -363,25 +363,6 @@ func (svc *sdkService) CreateDocument(log log.T, docName string, docContent stri
 	return
 }
 
-//GetDocument calls the GetDocument SSM API to retrieve document with given document name
-func (svc *sdkService) GetDocument(log log.T, docName string, docVersion string) (response *ssm.GetDocumentOutput, err error) {
-	params := ssm.GetDocumentInput{
-		Name: aws.String(docName),
-	}
-
-	if docVersion != "" {
-		params.DocumentVersion = aws.String(docVersion)
-	}
-
-	response, err = svc.sdk.GetDocument(&params)
-	if err != nil {
-		sdkutil.HandleAwsError(log, err, ssmStopPolicy)
-		return
-	}
-	log.Debug("GetDocument Response", response)
-	return
-}
-
 //DescribeAssociation calls the DescribeAssociation SSM API to retrieve parameters information
 func (svc *sdkService) DescribeAssociation(log log.T, instanceID string, docName string) (response *ssm.DescribeAssociationOutput, err error) {
 	params := ssm.DescribeAssociationInput{
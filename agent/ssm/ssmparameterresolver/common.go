@@ -14,7 +14,11 @@
 // Package ssmparameterresolver provides helper methods to detect, validate and extract parameter store parameter references.
 package ssmparameterresolver
 
-import "regexp"
+import (
+	"regexp"
+
+	"github.com/aws/amazon-ssm-agent/agent/secret"
+)
 
 const (
 	ssmNonSecurePrefix = "ssm:"
@@ -31,10 +35,20 @@ var ssmParameterPlaceholderRegEx = regexp.MustCompile("{{\\s*(" + ssmNonSecurePr
 var secureSsmParameterPlaceholderRegEx = regexp.MustCompile("{{\\s*(" + ssmSecurePrefix + "[\\w-/]+)\\s*}}")
 
 // SsmParameterInfo structure represents a resolved SSM Parameter.
+// The value of SecureString parameters is held in SecretValue instead of Value.
 type SsmParameterInfo struct {
-	Name  string
-	Type  string
-	Value string
+	Name        string
+	Type        string
+	Value       string
+	SecretValue *secret.SecretValue
+}
+
+// StringValue returns the value of the parameter, revealing the value of SecureString parameters
+func (info SsmParameterInfo) StringValue() string {
+	if info.SecretValue != nil {
+		return info.SecretValue.Reveal()
+	}
+	return info.Value
 }
 
 // ResolveOptions structure represents a set of options for the parameter resolution.
@@ -20,6 +20,7 @@ import (
 	"strings"
 
 	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/secret"
 	"github.com/aws/amazon-ssm-agent/agent/ssm/ssmparameterresolver"
 	"github.com/stretchr/testify/mock"
 )
@@ -38,12 +39,23 @@ func (mock *SsmParameterResolverBridgeMock) GetParameterFromSsmParameterStore(lo
 	return args.String(0), args.Error(1)
 }
 
+func (mock *SsmParameterResolverBridgeMock) GetSecretFromSsmParameterStore(log log.T, parameter string) (*secret.SecretValue, error) {
+	args := mock.Called(log, parameter)
+	// a new secret value is returned for every call since the callers release it
+	if value, ok := args.Get(0).(string); ok {
+		return secret.FromString(value), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
 func GetSsmParamResolverBridge(parameterStoreParameters map[string]string) ssmparameterresolver.ISsmParameterResolverBridge {
 	bridgeMock := &SsmParameterResolverBridgeMock{}
 	for k, v := range parameterStoreParameters {
 		bridgeMock.On("GetParameterFromSsmParameterStore", mock.Anything, k).Return(v, nil)
+		bridgeMock.On("GetSecretFromSsmParameterStore", mock.Anything, k).Return(v, nil)
 	}
 	bridgeMock.On("GetParameterFromSsmParameterStore", mock.Anything, mock.Anything).Return("", errors.New("parameter does not exist"))
+	bridgeMock.On("GetSecretFromSsmParameterStore", mock.Anything, mock.Anything).Return(nil, errors.New("parameter does not exist"))
 
 	bridgeMock.On("IsValidParameterStoreReference", mock.MatchedBy(func(reference string) bool {
 		return strings.HasPrefix(reference, "{{ssm-secure:")
@@ -106,7 +106,8 @@ func ResolveParametersInText(
 
 	for ref, param := range resolvedParametersMap {
 		var placeholder = regexp.MustCompile("{{\\s*" + ref + "\\s*}}")
-		input = placeholder.ReplaceAllString(input, param.Value)
+		input = placeholder.ReplaceAllString(input, param.StringValue())
+		param.SecretValue.Release()
 	}
 
 	return input, nil
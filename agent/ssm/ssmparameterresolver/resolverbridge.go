@@ -21,6 +21,7 @@ import (
 	"regexp"
 
 	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/secret"
 )
 
 // The format of a valid secure parameter store parameter reference
@@ -31,6 +32,7 @@ var ssmParamReferencePattern = regexp.MustCompile(fmt.Sprintf("{{\\s*((?:%s|%s)[
 type ISsmParameterResolverBridge interface {
 	IsValidParameterStoreReference(value string) bool
 	GetParameterFromSsmParameterStore(log log.T, parameter string) (string, error)
+	GetSecretFromSsmParameterStore(log log.T, parameter string) (*secret.SecretValue, error)
 }
 
 // ssmParameterResolverBridgeImpl moderates the communication to the ssm parameter store service
@@ -52,17 +54,32 @@ func (bridge *ssmParameterResolverBridgeImpl) IsValidParameterStoreReference(val
 
 // GetParameterFromSsmParameterStore returns the value of the given parameter store parameter
 func (bridge *ssmParameterResolverBridgeImpl) GetParameterFromSsmParameterStore(log log.T, parameter string) (string, error) {
-	reference, err := bridge.extractParameterStoreParameterReference(parameter)
+	value, err := bridge.GetSecretFromSsmParameterStore(log, parameter)
 	if err != nil {
 		return "", err
 	}
+	defer value.Release()
+
+	return value.Reveal(), nil
+}
+
+// GetSecretFromSsmParameterStore returns the value of the given parameter store parameter in a secret value,
+// the caller releases it once the value is no longer needed
+func (bridge *ssmParameterResolverBridgeImpl) GetSecretFromSsmParameterStore(log log.T, parameter string) (*secret.SecretValue, error) {
+	reference, err := bridge.extractParameterStoreParameterReference(parameter)
+	if err != nil {
+		return nil, err
+	}
 
 	parameterInfo, err := bridge.resolveSsmParameterStoreReference(log, reference)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	return parameterInfo.Value, nil
+	if parameterInfo.SecretValue != nil {
+		return parameterInfo.SecretValue, nil
+	}
+	return secret.FromString(parameterInfo.Value), nil
 }
 
 // extractParameterStoreParameterReference extract the actual parameter name from the reference structure
@@ -19,6 +19,7 @@ import (
 	"strings"
 
 	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/secret"
 	"github.com/aws/amazon-ssm-agent/agent/ssm"
 )
 
@@ -68,11 +69,18 @@ func (s *SsmParameterService) getParameters(
 	resolvedParametersMap := map[string]SsmParameterInfo{}
 	for i := 0; i < len(parametersOutput.Parameters); i++ {
 		param := parametersOutput.Parameters[i]
-		resolvedParametersMap[ref2NameMapper[*param.Name]] = SsmParameterInfo{
-			Name:  *param.Name,
-			Type:  *param.Type,
-			Value: *param.Value,
+		info := SsmParameterInfo{
+			Name: *param.Name,
+			Type: *param.Type,
 		}
+		if info.Type == secureStringType {
+			info.SecretValue = secret.FromString(*param.Value)
+			// drop the reference of the response to the decrypted value
+			param.Value = nil
+		} else {
+			info.Value = *param.Value
+		}
+		resolvedParametersMap[ref2NameMapper[*param.Name]] = info
 	}
 
 	return resolvedParametersMap, nil
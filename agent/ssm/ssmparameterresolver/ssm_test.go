@@ -16,12 +16,17 @@ package ssmparameterresolver
 
 import (
 	"errors"
+	"fmt"
 	"reflect"
 	"strconv"
 	"testing"
 
 	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/ssm"
+	"github.com/aws/aws-sdk-go/aws"
+	ssmsdk "github.com/aws/aws-sdk-go/service/ssm"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 )
 
 type serviceMockedObjectWithRecords struct {
@@ -118,3 +123,26 @@ func TestGetParametersFromSsmParameterStoreWithUnresolvedIgnoreNoPaging(t *testi
 	_, err := getParametersFromSsmParameterStore(&serviceObject, log, parametersList)
 	assert.NotNil(t, err)
 }
+
+func TestGetParametersKeepsSecureStringsInSecretValues(t *testing.T) {
+	sdk := ssm.NewMockDefault()
+	sdk.On("GetDecryptedParameters", mock.Anything, []string{"plain", "secure"}).Return(&ssmsdk.GetParametersOutput{
+		Parameters: []*ssmsdk.Parameter{
+			{Name: aws.String("plain"), Type: aws.String(stringType), Value: aws.String("plainValue")},
+			{Name: aws.String("secure"), Type: aws.String(secureStringType), Value: aws.String("secureValue")},
+		},
+	}, nil)
+	service := &SsmParameterService{sdk: sdk}
+
+	parameters, err := service.getParameters(log.NewMockLog(), []string{"ssm:plain", "ssm-secure:secure"})
+	assert.NoError(t, err)
+	assert.Equal(t, "plainValue", parameters["ssm:plain"].Value)
+	assert.Nil(t, parameters["ssm:plain"].SecretValue)
+
+	secure := parameters["ssm-secure:secure"]
+	assert.Equal(t, "", secure.Value)
+	assert.Equal(t, "secureValue", secure.StringValue())
+	assert.NotContains(t, fmt.Sprintf("%+v", secure), "secureValue")
+	secure.SecretValue.Release()
+	assert.Equal(t, "", secure.StringValue())
+}
@@ -15,12 +15,12 @@
 package util
 
 import (
-	"crypto/tls"
 	"net/http"
 	"time"
 
 	"github.com/aws/amazon-ssm-agent/agent/appconfig"
 	"github.com/aws/amazon-ssm-agent/agent/platform"
+	"github.com/aws/amazon-ssm-agent/agent/proxyconfig"
 	"github.com/aws/amazon-ssm-agent/agent/sdkutil/retryer"
 	"github.com/aws/aws-sdk-go/aws"
 )
@@ -49,14 +49,13 @@ func AwsConfig() *aws.Config {
 	if appConfig.Agent.Region != "" {
 		awsConfig.Region = &appConfig.Agent.Region
 	}
+	tr := proxyconfig.NewTransport(proxyconfig.ServiceSsm)
 	// TODO: test hook, can be removed before release
 	// this is to skip ssl verification for the beta self signed certs
 	if appConfig.Ssm.InsecureSkipVerify {
-		tr := &http.Transport{
-			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
-		}
-		awsConfig.HTTPClient = &http.Client{Transport: tr}
+		tr.TLSClientConfig.InsecureSkipVerify = true
 	}
+	awsConfig.HTTPClient = &http.Client{Transport: tr}
 
 	return awsConfig
 
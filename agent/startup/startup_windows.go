@@ -160,7 +160,7 @@ func (p *Processor) IsAllowed() bool {
 
 	// check if metadata is rechable which indicates the instance is in EC2.
 	// maximum retry is 10 to ensure the failure/error is not caused by arbitrary reason.
-	ec2MetadataService := ec2metadata.New(session.New(aws.NewConfig().WithMaxRetries(10)))
+	ec2MetadataService := platform.WithConfiguredMetadataEndpoint(ec2metadata.New(session.New(aws.NewConfig().WithMaxRetries(10))))
 	if metadata, err := ec2MetadataService.GetMetadata(""); err != nil || metadata == "" {
 		// This is as designed to check if instance is in EC2, so it is not an error
 		return false
@@ -0,0 +1,444 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package statecrypto encrypts the document state and the replies the agent persists, which contain the resolved
+// parameters of the documents, with a data key kept in the agent vault. Plaintext files written before the
+// encryption was enabled are still read, and Migrate rewrites them in the configured form.
+package statecrypto
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/managedInstances/vault/fsvault"
+	"github.com/aws/amazon-ssm-agent/agent/session/crypto"
+	"github.com/aws/aws-sdk-go/aws"
+)
+
+const (
+	// VaultKey is the vault entry holding the data key
+	VaultKey = "StateEncryptionKey"
+
+	// dataKeySize is the size of the AES-256 data key
+	dataKeySize = 32
+
+	// sealedVersion is the version of the envelope of sealed files
+	sealedVersion = 1
+
+	// encryptionContextPurposeKey and encryptionContextPurpose bind the data key wrapped by KMS to its use
+	encryptionContextPurposeKey = "Purpose"
+	encryptionContextPurpose    = "SSMAgentStateEncryption"
+)
+
+// sealedPrefix starts every sealed file, the plaintext files are JSON objects of other types
+var sealedPrefix = []byte(`{"SealedStateVersion":`)
+
+// sealedState is the envelope of a sealed file, Ciphertext is the AES-GCM nonce followed by the encrypted content
+type sealedState struct {
+	SealedStateVersion int
+	Ciphertext         []byte
+}
+
+// keyRecord is the data key stored in the vault, Key is wrapped by KmsKeyId when Mode is kms. Previous is the key
+// the record replaced, it is kept until Migrate rewrote every file sealed with it.
+type keyRecord struct {
+	Mode     string
+	KmsKeyId string `json:",omitempty"`
+	Key      []byte
+	Previous *keyRecord `json:",omitempty"`
+}
+
+// keys are the unwrapped data keys of the vault record
+type keys struct {
+	record   *keyRecord
+	current  []byte
+	previous []byte
+}
+
+var (
+	getAppConfig  = appconfig.Config
+	retrieveKey   = fsvault.Retrieve
+	storeKey      = fsvault.Store
+	removeKey     = fsvault.Remove
+	newKMSService = func(log log.T) (crypto.IKMSService, error) { return crypto.NewKMSService(log) }
+
+	// keyLock guards cachedKeys, the keys are unwrapped once per process
+	keyLock    sync.Mutex
+	cachedKeys *keys
+)
+
+// IsSealed returns true when the content was written by Seal
+func IsSealed(content []byte) bool {
+	return bytes.HasPrefix(bytes.TrimSpace(content), sealedPrefix)
+}
+
+// Seal encrypts the content with the data key, creating the key when there is none yet.
+// The content is returned as is when the state encryption is disabled.
+func Seal(log log.T, content []byte) ([]byte, error) {
+	mode, kmsKeyId := configuredMode()
+	if mode == appconfig.StateEncryptionNone {
+		return content, nil
+	}
+
+	keyLock.Lock()
+	defer keyLock.Unlock()
+	k, err := loadKeys(log)
+	if err != nil {
+		return nil, err
+	}
+	if k.record == nil {
+		if k, err = rotateKey(log, k, mode, kmsKeyId); err != nil {
+			return nil, err
+		}
+	}
+	return seal(k.current, content)
+}
+
+// Open decrypts content written by Seal, plaintext content is returned as is
+func Open(log log.T, content []byte) ([]byte, error) {
+	if !IsSealed(content) {
+		return content, nil
+	}
+
+	keyLock.Lock()
+	defer keyLock.Unlock()
+	k, err := loadKeys(log)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, _, err := open(k, content)
+	return plaintext, err
+}
+
+// UnmarshalFile reads a file written in plaintext or by Seal into dest
+func UnmarshalFile(log log.T, filePath string, dest interface{}) error {
+	content, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return err
+	}
+	if content, err = Open(log, content); err != nil {
+		return fmt.Errorf("failed to decrypt %v: %v", filePath, err)
+	}
+	return json.Unmarshal(content, dest)
+}
+
+// Store is a key value store of sealed or plaintext values
+type Store interface {
+	// Rewrite replaces every value by the result of migrate
+	Rewrite(migrate func(value []byte) ([]byte, error)) error
+}
+
+// Migrate rewrites the files of the folders in the configured form, sealed with the data key of the configured
+// mode or in plaintext when the encryption is disabled. A data key of another mode or KMS key is replaced first,
+// and dropped once no file sealed with it is left.
+func Migrate(log log.T, dirs ...string) error {
+	return MigrateStores(log, nil, dirs...)
+}
+
+// MigrateStores rewrites the values of the stores and the files of the folders in the configured form,
+// the data keys are replaced and dropped as in Migrate
+func MigrateStores(log log.T, stores []Store, dirs ...string) error {
+	mode, kmsKeyId := configuredMode()
+
+	keyLock.Lock()
+	defer keyLock.Unlock()
+	k, err := loadKeys(log)
+	if err != nil {
+		return err
+	}
+	if mode != appconfig.StateEncryptionNone && (k.record == nil || k.record.Mode != mode || k.record.KmsKeyId != kmsKeyId) {
+		log.Infof("Creating the %v state encryption key", mode)
+		if k, err = rotateKey(log, k, mode, kmsKeyId); err != nil {
+			return err
+		}
+	}
+
+	var failed []string
+	for i, store := range stores {
+		err = store.Rewrite(func(value []byte) ([]byte, error) {
+			return migrateContent(k, mode, value)
+		})
+		if err != nil {
+			log.Warnf("Failed to migrate the encryption of the state store: %v", err)
+			failed = append(failed, fmt.Sprintf("store %v", i))
+		}
+	}
+	for _, dir := range dirs {
+		files, err := ioutil.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, file := range files {
+			if !file.Mode().IsRegular() {
+				continue
+			}
+			filePath := filepath.Join(dir, file.Name())
+			if err = migrateFile(k, mode, filePath); err != nil {
+				log.Warnf("Failed to migrate the encryption of %v: %v", filePath, err)
+				failed = append(failed, filePath)
+			}
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("failed to migrate the encryption of %v files or stores", len(failed))
+	}
+
+	// every file is in the configured form, the keys no file is sealed with are dropped
+	if mode == appconfig.StateEncryptionNone && k.record != nil {
+		log.Info("Removing the state encryption key")
+		if err = removeKey(VaultKey); err != nil {
+			return err
+		}
+		cachedKeys = &keys{}
+	} else if k.record != nil && k.record.Previous != nil {
+		record := *k.record
+		record.Previous = nil
+		if err = saveRecord(&record); err != nil {
+			return err
+		}
+		cachedKeys = &keys{record: &record, current: k.current}
+	}
+	return nil
+}
+
+// migrateFile seals the file with the current key or writes it in plaintext when mode is none
+func migrateFile(k *keys, mode string, filePath string) error {
+	content, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return err
+	}
+	migrated, err := migrateContent(k, mode, content)
+	if err != nil || bytes.Equal(migrated, content) {
+		return err
+	}
+	return replaceFile(filePath, migrated)
+}
+
+// migrateContent returns the content sealed with the current key, or in plaintext when mode is none.
+// Content already in that form is returned as is.
+func migrateContent(k *keys, mode string, content []byte) ([]byte, error) {
+	plaintext, sealedWithCurrent, err := open(k, content)
+	if err != nil {
+		return nil, err
+	}
+	if mode == appconfig.StateEncryptionNone {
+		return plaintext, nil
+	}
+	if sealedWithCurrent {
+		return content, nil
+	}
+	return seal(k.current, plaintext)
+}
+
+// replaceFile writes the content next to the file and renames it over the file, so that it is never left partial
+func replaceFile(filePath string, content []byte) error {
+	tempFile, err := ioutil.TempFile(filepath.Dir(filePath), ".tmp")
+	if err != nil {
+		return err
+	}
+	tempFile.Close()
+	defer os.Remove(tempFile.Name())
+	if err = ioutil.WriteFile(tempFile.Name(), content, appconfig.ReadWriteAccess); err != nil {
+		return err
+	}
+	return os.Rename(tempFile.Name(), filePath)
+}
+
+// configuredMode returns the state encryption mode and KMS key of the agent configuration
+func configuredMode() (mode string, kmsKeyId string) {
+	config, err := getAppConfig(false)
+	if err != nil || config.StateEncryption.Mode == "" {
+		return appconfig.StateEncryptionNone, ""
+	}
+	return config.StateEncryption.Mode, config.StateEncryption.KmsKeyId
+}
+
+// loadKeys returns the cached keys or unwraps the keys of the vault record, the caller holds keyLock
+func loadKeys(log log.T) (*keys, error) {
+	if cachedKeys != nil {
+		return cachedKeys, nil
+	}
+	k := &keys{}
+	data, err := retrieveKey(VaultKey)
+	if err != nil {
+		// no state was sealed yet
+		cachedKeys = k
+		return k, nil
+	}
+	var record keyRecord
+	if err = json.Unmarshal(data, &record); err != nil {
+		return nil, fmt.Errorf("failed to parse the state encryption key: %v", err)
+	}
+	if k.current, err = unwrapKey(log, &record); err != nil {
+		return nil, err
+	}
+	if record.Previous != nil {
+		if k.previous, err = unwrapKey(log, record.Previous); err != nil {
+			log.Warnf("Failed to unwrap the previous state encryption key: %v", err)
+		}
+	}
+	k.record = &record
+	cachedKeys = k
+	return k, nil
+}
+
+// rotateKey generates a data key for the mode and stores it, keeping the current key as the previous key
+func rotateKey(log log.T, k *keys, mode string, kmsKeyId string) (*keys, error) {
+	record, plaintext, err := generateKey(log, mode, kmsKeyId)
+	if err != nil {
+		return nil, err
+	}
+	rotated := &keys{record: record, current: plaintext}
+	if k.record != nil {
+		previous := *k.record
+		previous.Previous = nil
+		record.Previous = &previous
+		rotated.previous = k.current
+	}
+	if err = saveRecord(record); err != nil {
+		return nil, err
+	}
+	cachedKeys = rotated
+	return rotated, nil
+}
+
+// generateKey creates a random data key, or a data key wrapped by the KMS key in mode kms
+func generateKey(log log.T, mode string, kmsKeyId string) (*keyRecord, []byte, error) {
+	record := &keyRecord{Mode: mode}
+	if mode != appconfig.StateEncryptionKms {
+		plaintext := make([]byte, dataKeySize)
+		if _, err := rand.Read(plaintext); err != nil {
+			return nil, nil, err
+		}
+		record.Key = plaintext
+		return record, plaintext, nil
+	}
+
+	kmsService, err := newKMSService(log)
+	if err != nil {
+		return nil, nil, err
+	}
+	cipherTextBlob, plaintext, err := kmsService.GenerateDataKey(kmsKeyId, encryptionContext(), nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(plaintext) < dataKeySize {
+		return nil, nil, fmt.Errorf("KMS returned a data key of %v bytes", len(plaintext))
+	}
+	record.KmsKeyId = kmsKeyId
+	record.Key = cipherTextBlob
+	return record, plaintext[:dataKeySize], nil
+}
+
+// unwrapKey returns the plaintext data key of the record
+func unwrapKey(log log.T, record *keyRecord) ([]byte, error) {
+	if record.Mode != appconfig.StateEncryptionKms {
+		return record.Key, nil
+	}
+	kmsService, err := newKMSService(log)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := kmsService.Decrypt(record.Key, encryptionContext(), nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(plaintext) < dataKeySize {
+		return nil, fmt.Errorf("KMS returned a data key of %v bytes", len(plaintext))
+	}
+	return plaintext[:dataKeySize], nil
+}
+
+func saveRecord(record *keyRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	return storeKey(VaultKey, data)
+}
+
+func encryptionContext() map[string]*string {
+	return map[string]*string{encryptionContextPurposeKey: aws.String(encryptionContextPurpose)}
+}
+
+// seal encrypts the content with AES-GCM into the envelope of sealed files
+func seal(key []byte, content []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err = rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return json.Marshal(sealedState{
+		SealedStateVersion: sealedVersion,
+		Ciphertext:         gcm.Seal(nonce, nonce, content, nil),
+	})
+}
+
+// open returns the plaintext of the content and whether it was sealed with the current key
+func open(k *keys, content []byte) (plaintext []byte, sealedWithCurrent bool, err error) {
+	if !IsSealed(content) {
+		return content, false, nil
+	}
+	var sealed sealedState
+	if err = json.Unmarshal(content, &sealed); err != nil {
+		return nil, false, err
+	}
+	if sealed.SealedStateVersion != sealedVersion {
+		return nil, false, fmt.Errorf("unsupported sealed state version %v", sealed.SealedStateVersion)
+	}
+	if k.current == nil {
+		return nil, false, errors.New("the state encryption key is missing")
+	}
+	if plaintext, err = openWith(k.current, sealed.Ciphertext); err == nil {
+		return plaintext, true, nil
+	}
+	if k.previous != nil {
+		if plaintext, err = openWith(k.previous, sealed.Ciphertext); err == nil {
+			return plaintext, false, nil
+		}
+	}
+	return nil, false, err
+}
+
+func openWith(key []byte, ciphertext []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(ciphertext) < gcm.NonceSize() {
+		return nil, errors.New("sealed state is too short")
+	}
+	return gcm.Open(nil, ciphertext[:gcm.NonceSize()], ciphertext[gcm.NonceSize():], nil)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,232 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package statecrypto
+
+import (
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/session/crypto"
+	"github.com/aws/amazon-ssm-agent/agent/session/crypto/mocks"
+	"github.com/stretchr/testify/assert"
+)
+
+var logMock = log.NewMockLog()
+
+const state = `{"DocumentInformation":{"DocumentID":"1"},"Parameters":{"password":"secret"}}`
+
+// mockVault keeps the vault in memory and configures the state encryption mode
+func mockVault(mode string, kmsKeyId string) (vault map[string][]byte, setMode func(string, string), restore func()) {
+	vault = map[string][]byte{}
+	config := appconfig.DefaultConfig()
+	setMode = func(mode string, kmsKeyId string) {
+		config.StateEncryption = appconfig.StateEncryptionCfg{Mode: mode, KmsKeyId: kmsKeyId}
+		cachedKeys = nil
+	}
+	setMode(mode, kmsKeyId)
+
+	originalConfig, originalRetrieve, originalStore, originalRemove := getAppConfig, retrieveKey, storeKey, removeKey
+	getAppConfig = func(bool) (appconfig.SsmagentConfig, error) { return config, nil }
+	retrieveKey = func(key string) ([]byte, error) {
+		if data, ok := vault[key]; ok {
+			return data, nil
+		}
+		return nil, errors.New("does not exist")
+	}
+	storeKey = func(key string, data []byte) error {
+		vault[key] = data
+		return nil
+	}
+	removeKey = func(key string) error {
+		delete(vault, key)
+		return nil
+	}
+	return vault, setMode, func() {
+		getAppConfig, retrieveKey, storeKey, removeKey = originalConfig, originalRetrieve, originalStore, originalRemove
+		cachedKeys = nil
+	}
+}
+
+func mockKMS() (kmsService *mocks.IKMSService, restore func()) {
+	kmsService = &mocks.IKMSService{}
+	key := []byte("0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef")
+	kmsService.On("GenerateDataKey", "alias/state", encryptionContext(), []string(nil)).Return([]byte("wrapped"), key, nil)
+	kmsService.On("Decrypt", []byte("wrapped"), encryptionContext(), []string(nil)).Return(key, nil)
+	original := newKMSService
+	newKMSService = func(log.T) (crypto.IKMSService, error) { return kmsService, nil }
+	return kmsService, func() { newKMSService = original }
+}
+
+func TestSealDisabled(t *testing.T) {
+	vault, _, restore := mockVault(appconfig.StateEncryptionNone, "")
+	defer restore()
+
+	sealed, err := Seal(logMock, []byte(state))
+	assert.NoError(t, err)
+	assert.Equal(t, state, string(sealed))
+	assert.Empty(t, vault)
+}
+
+func TestSealAndOpenLocal(t *testing.T) {
+	vault, _, restore := mockVault(appconfig.StateEncryptionLocal, "")
+	defer restore()
+
+	sealed, err := Seal(logMock, []byte(state))
+	assert.NoError(t, err)
+	assert.True(t, IsSealed(sealed))
+	assert.NotContains(t, string(sealed), "secret")
+	assert.Contains(t, vault, VaultKey)
+
+	// another process unwraps the key from the vault
+	cachedKeys = nil
+	plaintext, err := Open(logMock, sealed)
+	assert.NoError(t, err)
+	assert.Equal(t, state, string(plaintext))
+}
+
+func TestOpenPlaintext(t *testing.T) {
+	_, _, restore := mockVault(appconfig.StateEncryptionLocal, "")
+	defer restore()
+
+	plaintext, err := Open(logMock, []byte(state))
+	assert.NoError(t, err)
+	assert.Equal(t, state, string(plaintext))
+}
+
+func TestOpenWithoutKey(t *testing.T) {
+	vault, _, restore := mockVault(appconfig.StateEncryptionLocal, "")
+	defer restore()
+
+	sealed, _ := Seal(logMock, []byte(state))
+	delete(vault, VaultKey)
+	cachedKeys = nil
+	_, err := Open(logMock, sealed)
+	assert.Error(t, err)
+}
+
+func TestSealAndOpenKms(t *testing.T) {
+	vault, _, restore := mockVault(appconfig.StateEncryptionKms, "alias/state")
+	defer restore()
+	kmsService, restoreKMS := mockKMS()
+	defer restoreKMS()
+
+	sealed, err := Seal(logMock, []byte(state))
+	assert.NoError(t, err)
+	assert.Contains(t, string(vault[VaultKey]), `"KmsKeyId":"alias/state"`)
+
+	cachedKeys = nil
+	plaintext, err := Open(logMock, sealed)
+	assert.NoError(t, err)
+	assert.Equal(t, state, string(plaintext))
+	kmsService.AssertNumberOfCalls(t, "GenerateDataKey", 1)
+	kmsService.AssertNumberOfCalls(t, "Decrypt", 1)
+}
+
+func TestMigrate(t *testing.T) {
+	vault, setMode, restore := mockVault(appconfig.StateEncryptionNone, "")
+	defer restore()
+	dir := t.TempDir()
+	file := filepath.Join(dir, "document")
+	assert.NoError(t, ioutil.WriteFile(file, []byte(state), appconfig.ReadWriteAccess))
+
+	// plaintext state is sealed once the encryption is enabled
+	setMode(appconfig.StateEncryptionLocal, "")
+	assert.NoError(t, Migrate(logMock, dir))
+	content, _ := ioutil.ReadFile(file)
+	assert.True(t, IsSealed(content))
+	localKey := string(vault[VaultKey])
+
+	// state sealed with the current key is left as is
+	assert.NoError(t, Migrate(logMock, dir))
+	unchanged, _ := ioutil.ReadFile(file)
+	assert.Equal(t, content, unchanged)
+
+	// a new key replaces the key of another mode, the previous key is dropped once the state was sealed again
+	setMode(appconfig.StateEncryptionKms, "alias/state")
+	_, restoreKMS := mockKMS()
+	defer restoreKMS()
+	assert.NoError(t, Migrate(logMock, dir))
+	resealed, _ := ioutil.ReadFile(file)
+	assert.True(t, IsSealed(resealed))
+	assert.NotEqual(t, content, resealed)
+	assert.NotEqual(t, localKey, string(vault[VaultKey]))
+	assert.NotContains(t, string(vault[VaultKey]), "Previous")
+
+	cachedKeys = nil
+	var docState map[string]interface{}
+	assert.NoError(t, UnmarshalFile(logMock, file, &docState))
+	assert.Contains(t, docState, "Parameters")
+
+	// the state is written in plaintext again and the key removed once the encryption is disabled
+	setMode(appconfig.StateEncryptionNone, "")
+	assert.NoError(t, Migrate(logMock, dir))
+	plaintext, _ := ioutil.ReadFile(file)
+	assert.Equal(t, state, string(plaintext))
+	assert.Empty(t, vault)
+}
+
+func TestMigrateKeepsPreviousKeyOnFailure(t *testing.T) {
+	vault, setMode, restore := mockVault(appconfig.StateEncryptionLocal, "")
+	defer restore()
+	dir := t.TempDir()
+	sealed, _ := Seal(logMock, []byte(state))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "document"), sealed, appconfig.ReadWriteAccess))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "unreadable"), []byte(`{"SealedStateVersion":1,"Ciphertext":"AAAA"}`), appconfig.ReadWriteAccess))
+
+	setMode(appconfig.StateEncryptionKms, "alias/state")
+	_, restoreKMS := mockKMS()
+	defer restoreKMS()
+	assert.Error(t, Migrate(logMock, dir))
+	assert.Contains(t, string(vault[VaultKey]), "Previous")
+
+	// the files the migration did not rewrite yet are still opened with the previous key
+	cachedKeys = nil
+	plaintext, err := Open(logMock, sealed)
+	assert.NoError(t, err)
+	assert.Equal(t, state, string(plaintext))
+}
+
+// memoryStore keeps the values of a state store in memory
+type memoryStore map[string][]byte
+
+func (s memoryStore) Rewrite(migrate func(value []byte) ([]byte, error)) error {
+	for key, value := range s {
+		migrated, err := migrate(value)
+		if err != nil {
+			return err
+		}
+		s[key] = migrated
+	}
+	return nil
+}
+
+func TestMigrateStores(t *testing.T) {
+	vault, setMode, restore := mockVault(appconfig.StateEncryptionNone, "")
+	defer restore()
+	store := memoryStore{"current/document": []byte(state)}
+
+	setMode(appconfig.StateEncryptionLocal, "")
+	assert.NoError(t, MigrateStores(logMock, []Store{store}))
+	assert.True(t, IsSealed(store["current/document"]))
+	assert.NotEmpty(t, vault)
+
+	setMode(appconfig.StateEncryptionNone, "")
+	assert.NoError(t, MigrateStores(logMock, []Store{store}))
+	assert.Equal(t, state, string(store["current/document"]))
+	assert.Empty(t, vault)
+}
@@ -0,0 +1,35 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package systemd implements the notification and socket activation protocols of systemd
+package systemd
+
+import "strings"
+
+// States sent to the service manager
+const (
+	// Ready tells systemd that the agent finished starting up
+	Ready = "READY=1"
+	// Stopping tells systemd that the agent is shutting down
+	Stopping = "STOPPING=1"
+	// Watchdog keeps the watchdog of the service from restarting the agent
+	Watchdog = "WATCHDOG=1"
+)
+
+// listenFdsStart is the first file descriptor passed by socket activation
+const listenFdsStart = 3
+
+// Status returns the state updating the status line shown by systemctl status
+func Status(status string) string {
+	return "STATUS=" + strings.Replace(status, "\n", " ", -1)
+}
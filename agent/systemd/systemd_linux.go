@@ -0,0 +1,132 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// +build linux
+
+package systemd
+
+import (
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// the environment is captured when the agent starts, UnsetEnvironment hides it from the processes the agent runs
+var (
+	notifySocket = os.Getenv("NOTIFY_SOCKET")
+	watchdogUsec = os.Getenv("WATCHDOG_USEC")
+	watchdogPid  = os.Getenv("WATCHDOG_PID")
+	listenPid    = os.Getenv("LISTEN_PID")
+	listenFds    = os.Getenv("LISTEN_FDS")
+)
+
+var (
+	files     []*os.File
+	filesOnce sync.Once
+)
+
+var environment = []string{"NOTIFY_SOCKET", "WATCHDOG_USEC", "WATCHDOG_PID", "LISTEN_PID", "LISTEN_FDS", "LISTEN_FDNAMES"}
+
+// Notify sends the states to systemd, it returns false when the agent does not run as a notify service
+func Notify(states ...string) (bool, error) {
+	if notifySocket == "" {
+		return false, nil
+	}
+	address := &net.UnixAddr{Name: notifySocket, Net: "unixgram"}
+	// a leading @ refers to the abstract namespace
+	if strings.HasPrefix(address.Name, "@") {
+		address.Name = "\x00" + address.Name[1:]
+	}
+	conn, err := net.DialUnix(address.Net, nil, address)
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+	if _, err = conn.Write([]byte(strings.Join(states, "\n"))); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// WatchdogInterval returns the interval the service manager expects watchdog pings from this process within,
+// it is zero when the watchdog is disabled
+func WatchdogInterval() time.Duration {
+	if watchdogUsec == "" {
+		return 0
+	}
+	if watchdogPid != "" && watchdogPid != strconv.Itoa(os.Getpid()) {
+		return 0
+	}
+	usec, err := strconv.ParseInt(watchdogUsec, 10, 64)
+	if err != nil || usec <= 0 {
+		return 0
+	}
+	return time.Duration(usec) * time.Microsecond
+}
+
+// ListenFiles returns the sockets systemd passed to this process, the core agent forwards them
+// to ssm-agent-worker, which serves them
+func ListenFiles() []*os.File {
+	if listenPid != strconv.Itoa(os.Getpid()) {
+		return nil
+	}
+	return listenFiles()
+}
+
+// Listeners returns the listeners of the sockets passed by systemd, either directly or through the core agent
+func Listeners() ([]net.Listener, error) {
+	if listenPid != strconv.Itoa(os.Getpid()) && listenPid != strconv.Itoa(os.Getppid()) {
+		return nil, nil
+	}
+	var listeners []net.Listener
+	for _, file := range listenFiles() {
+		listener, err := net.FileListener(file)
+		file.Close()
+		if err != nil {
+			for _, l := range listeners {
+				l.Close()
+			}
+			return nil, err
+		}
+		listeners = append(listeners, listener)
+	}
+	return listeners, nil
+}
+
+// listenFiles wraps the passed descriptors once, the files close their descriptor when they are collected
+func listenFiles() []*os.File {
+	filesOnce.Do(func() {
+		count, err := strconv.Atoi(listenFds)
+		if err != nil || count <= 0 {
+			return
+		}
+		for fd := listenFdsStart; fd < listenFdsStart+count; fd++ {
+			// keep the sockets from leaking into every process the agent starts, ExtraFiles passes them explicitly
+			syscall.CloseOnExec(fd)
+			files = append(files, os.NewFile(uintptr(fd), "LISTEN_FD_"+strconv.Itoa(fd)))
+		}
+	})
+	return files
+}
+
+// UnsetEnvironment removes the variables of the service manager, so that commands and plugins
+// started by the agent do not talk to systemd on its behalf
+func UnsetEnvironment() {
+	for _, name := range environment {
+		os.Unsetenv(name)
+	}
+}
@@ -0,0 +1,81 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// +build linux
+
+package systemd
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNotify(t *testing.T) {
+	defer func(original string) { notifySocket = original }(notifySocket)
+
+	notifySocket = ""
+	sent, err := Notify(Ready)
+	assert.NoError(t, err)
+	assert.False(t, sent)
+
+	notifySocket = filepath.Join(t.TempDir(), "notify")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: notifySocket, Net: "unixgram"})
+	assert.NoError(t, err)
+	defer conn.Close()
+
+	sent, err = Notify(Ready, Status("connected\nto the service"))
+	assert.NoError(t, err)
+	assert.True(t, sent)
+
+	buf := make([]byte, 256)
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, err := conn.Read(buf)
+	assert.NoError(t, err)
+	assert.Equal(t, "READY=1\nSTATUS=connected to the service", string(buf[:n]))
+}
+
+func TestWatchdogInterval(t *testing.T) {
+	defer func(usec, pid string) { watchdogUsec, watchdogPid = usec, pid }(watchdogUsec, watchdogPid)
+
+	watchdogUsec, watchdogPid = "", ""
+	assert.Equal(t, time.Duration(0), WatchdogInterval())
+
+	watchdogUsec = "30000000"
+	assert.Equal(t, 30*time.Second, WatchdogInterval())
+
+	watchdogPid = strconv.Itoa(os.Getpid())
+	assert.Equal(t, 30*time.Second, WatchdogInterval())
+
+	// the pings of ssm-agent-worker would not count, the watchdog belongs to the core agent
+	watchdogPid = strconv.Itoa(os.Getppid())
+	assert.Equal(t, time.Duration(0), WatchdogInterval())
+
+	watchdogPid, watchdogUsec = "", "invalid"
+	assert.Equal(t, time.Duration(0), WatchdogInterval())
+}
+
+func TestListenersOfOtherProcess(t *testing.T) {
+	defer func(pid, fds string) { listenPid, listenFds = pid, fds }(listenPid, listenFds)
+
+	listenPid, listenFds = "1", "1"
+	assert.Empty(t, ListenFiles())
+	listeners, err := Listeners()
+	assert.NoError(t, err)
+	assert.Empty(t, listeners)
+}
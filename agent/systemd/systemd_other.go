@@ -0,0 +1,45 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// +build !linux
+
+package systemd
+
+import (
+	"net"
+	"os"
+	"time"
+)
+
+// Notify does nothing, systemd only runs on linux
+func Notify(states ...string) (bool, error) {
+	return false, nil
+}
+
+// WatchdogInterval returns zero, systemd only runs on linux
+func WatchdogInterval() time.Duration {
+	return 0
+}
+
+// ListenFiles returns no sockets, systemd only runs on linux
+func ListenFiles() []*os.File {
+	return nil
+}
+
+// Listeners returns no listeners, systemd only runs on linux
+func Listeners() ([]net.Listener, error) {
+	return nil, nil
+}
+
+// UnsetEnvironment does nothing, systemd only runs on linux
+func UnsetEnvironment() {}
@@ -15,6 +15,7 @@ package task
 
 import (
 	"fmt"
+	"runtime"
 	"sync"
 	"time"
 
@@ -28,6 +29,10 @@ type Pool interface {
 	// Returns an error if a job with the same name already exists.
 	Submit(log log.T, jobID string, job Job) error
 
+	// SubmitWithOptions schedules a job like Submit, jobs with a higher priority are started first
+	// and jobs of a class wait while the limit of running jobs of their class is reached.
+	SubmitWithOptions(log log.T, jobID string, job Job, options JobOptions) error
+
 	// Cancel cancels the given job. Jobs that have not started yet will never be started.
 	// Jobs that are running will have their CancelFlag set to the Canceled state.
 	// It is the responsibility of the job to terminate within a reasonable time.
@@ -35,6 +40,9 @@ type Pool interface {
 	// Returns true if the job has been found and canceled, false if the job was not found.
 	Cancel(jobID string) bool
 
+	// CancelAll cancels all the jobs, like Cancel does for a single job.
+	CancelAll()
+
 	// Shutdown cancels all the jobs and shuts down the workers.
 	Shutdown()
 
@@ -45,12 +53,66 @@ type Pool interface {
 
 	// HasJob returns if jobStore has specified job
 	HasJob(jobID string) bool
+
+	// Resize changes the number of workers of the pool.
+	// Extra workers are started right away, surplus workers retire once they finish their current job.
+	Resize(maxParallel int)
 }
 
+// Priority orders the jobs waiting for a worker of the pool.
+type Priority int
+
+const (
+	// PriorityNormal is the priority of bulk work.
+	PriorityNormal Priority = iota
+	// PriorityHigh is the priority of short interactive work like cancellations, sessions and urgent documents.
+	PriorityHigh
+)
+
+// Class is the resource a job mostly uses, a pool can limit the jobs of a class running at the same time.
+type Class string
+
+const (
+	// ClassDefault jobs are only limited by the number of workers.
+	ClassDefault Class = ""
+	// ClassCPU jobs mostly use the CPU.
+	ClassCPU Class = "cpu"
+	// ClassIO jobs mostly wait for the disk or the network.
+	ClassIO Class = "io"
+)
+
+// JobOptions tell a pool how to schedule a job.
+type JobOptions struct {
+	Priority Priority
+	Class    Class
+}
+
+// PoolOptions tune how a pool queues and starts jobs.
+type PoolOptions struct {
+	// QueuedJobsPerWorker limits the normal priority jobs waiting for a worker, Submit blocks while the queue is full.
+	// High priority jobs are always queued. 0 means the queue is unlimited.
+	QueuedJobsPerWorker int
+	// ClassLimits limits the jobs of a class running at the same time, classes without a limit only wait for a worker.
+	ClassLimits map[Class]int
+	// MemoryLimitBytes defers starting jobs while the process uses more memory, until a running job finishes.
+	// A job is always started when none is running. 0 means memory is not limited.
+	MemoryLimitBytes uint64
+}
+
+// highPriorityStreak is the number of high priority jobs started in a row while normal priority jobs wait,
+// after which a normal priority job is started so bulk work is not starved.
+const highPriorityStreak = 4
+
 // pool implements a task pool where all jobs are managed by a root task
 type pool struct {
 	log            log.T
-	jobQueue       chan JobToken
+	highQueue      []JobToken
+	normalQueue    []JobToken
+	jobReady       *sync.Cond
+	queueSpace     *sync.Cond
+	highStarted    int
+	options        PoolOptions
+	classRunning   map[Class]int
 	nWorkers       int
 	doneWorker     chan struct{}
 	isShutdown     bool
@@ -58,6 +120,10 @@ type pool struct {
 	mut            sync.Mutex
 	jobStore       *JobStore
 	cancelDuration time.Duration
+	jobProcessor   func(JobToken)
+	pendingRetires int
+	nRunning       int
+	memoryInUse    func() uint64
 }
 
 // JobToken embeds a job and its associated info
@@ -66,22 +132,32 @@ type JobToken struct {
 	job        Job
 	cancelFlag *ChanneledCancelFlag
 	log        log.T
+	class      Class
 }
 
 // NewPool creates a new task pool and launches maxParallel workers.
 // The cancelWaitDuration parameter defines how long to wait for a job
 // to complete a cancellation request.
 func NewPool(log log.T, maxParallel int, cancelWaitDuration time.Duration, clock times.Clock) Pool {
+	return NewPoolWithOptions(log, maxParallel, cancelWaitDuration, clock, PoolOptions{})
+}
+
+// NewPoolWithOptions creates a new task pool like NewPool that queues and starts jobs according to the options.
+func NewPoolWithOptions(log log.T, maxParallel int, cancelWaitDuration time.Duration, clock times.Clock, options PoolOptions) Pool {
 	p := &pool{
 		log:            log,
-		jobQueue:       make(chan JobToken),
 		nWorkers:       maxParallel,
 		doneWorker:     make(chan struct{}),
 		clock:          clock,
 		cancelDuration: cancelWaitDuration,
+		options:        options,
+		classRunning:   make(map[Class]int),
+		memoryInUse:    processMemoryInUse,
 	}
 
 	p.jobStore = NewJobStore()
+	p.jobReady = sync.NewCond(&p.mut)
+	p.queueSpace = sync.NewCond(&p.mut)
 
 	// defines the job processing function.
 	processor := func(j JobToken) {
@@ -103,11 +179,13 @@ func (p *pool) Shutdown() {
 	p.mut.Lock()
 	defer p.mut.Unlock()
 	if !p.isShutdown {
-		// close the channel to makes all workers terminate once the pending
-		// jobs have been consumed (the pending jobs are in the Canceled state
-		// so they will simply be discarded)
-		close(p.jobQueue)
+		// wake up all workers so they terminate, the queued jobs were
+		// shut down above and are simply discarded
+		p.highQueue = nil
+		p.normalQueue = nil
 		p.isShutdown = true
+		p.jobReady.Broadcast()
+		p.queueSpace.Broadcast()
 	}
 }
 
@@ -119,7 +197,9 @@ func (p *pool) ShutdownAndWait(timeout time.Duration) (finished bool) {
 
 	timeoutTimer := p.clock.After(timeout)
 	exitTimer := p.clock.After(timeout + p.cancelDuration)
+	p.mut.Lock()
 	workersRunning := p.nWorkers
+	p.mut.Unlock()
 	for workersRunning > 0 {
 		select {
 		case <-p.doneWorker:
@@ -144,11 +224,17 @@ func (p *pool) ShutdownAndWait(timeout time.Duration) (finished bool) {
 
 // start starts the workers of this pool
 func (p *pool) start(jobProcessor func(JobToken)) {
-	for i := 0; i < p.nWorkers; i++ {
-		workerName := fmt.Sprintf("worker-%d", i)
+	p.jobProcessor = jobProcessor
+	p.startWorkers(p.nWorkers)
+}
+
+// startWorkers launches count workers
+func (p *pool) startWorkers(count int) {
+	for i := 0; i < count; i++ {
 		go func() {
-			defer p.workerDone()
-			worker(workerName, p.jobQueue, jobProcessor)
+			if retired := p.worker(); !retired {
+				p.workerDone()
+			}
 		}()
 	}
 }
@@ -158,28 +244,183 @@ func (p *pool) workerDone() {
 	p.doneWorker <- struct{}{}
 }
 
-// worker processes jobs from a channel.
-func worker(workerName string, queue chan JobToken, processor func(JobToken)) {
-	for token := range queue {
+// Resize changes the number of workers of this pool.
+func (p *pool) Resize(maxParallel int) {
+	p.mut.Lock()
+	defer p.mut.Unlock()
+	if p.isShutdown || maxParallel < 1 {
+		return
+	}
+
+	target := p.nWorkers - p.pendingRetires
+	if maxParallel < target {
+		p.pendingRetires += target - maxParallel
+		// idle workers retire right away
+		p.jobReady.Broadcast()
+	} else if maxParallel > target {
+		// cancel pending retirements first, then start any missing workers
+		growBy := maxParallel - target
+		cancelled := growBy
+		if cancelled > p.pendingRetires {
+			cancelled = p.pendingRetires
+		}
+		p.pendingRetires -= cancelled
+		if growBy > cancelled {
+			p.startWorkers(growBy - cancelled)
+			p.nWorkers += growBy - cancelled
+		}
+	}
+	// the queue limit grows and shrinks with the pool
+	p.queueSpace.Broadcast()
+	p.log.Debugf("Pool resized to %d workers", maxParallel)
+}
+
+// worker processes jobs until the pool is shut down or the worker is retired.
+// Returns true if the worker was retired.
+func (p *pool) worker() bool {
+	for {
+		token, retired, ok := p.nextJob()
+		if !ok {
+			return retired
+		}
 		if !token.cancelFlag.Canceled() {
-			processor(token)
+			p.jobProcessor(token)
+		}
+		p.jobDone(token)
+	}
+}
+
+// nextJob waits for the next job to start. High priority jobs are started first, unless normal priority jobs
+// waited for highPriorityStreak of them, and jobs of a class that reached its limit are passed over.
+// Returns false if the worker must exit, retired is true if it exits because the pool was shrunk.
+// Workers are not retired once the pool is shutting down so that every remaining worker signals workerDone.
+func (p *pool) nextJob() (token JobToken, retired bool, ok bool) {
+	p.mut.Lock()
+	defer p.mut.Unlock()
+	for {
+		if p.isShutdown {
+			return token, false, false
 		}
+		if p.pendingRetires > 0 {
+			p.pendingRetires--
+			p.nWorkers--
+			return token, true, false
+		}
+		if p.memoryExceeded() {
+			p.jobReady.Wait()
+			continue
+		}
+		normalWaiting := len(p.normalQueue) > 0
+		if normalWaiting && p.highStarted >= highPriorityStreak {
+			if token, ok = p.takeJob(&p.normalQueue); ok {
+				p.highStarted = 0
+				return token, false, true
+			}
+		}
+		if token, ok = p.takeJob(&p.highQueue); ok {
+			if normalWaiting {
+				p.highStarted++
+			}
+			return token, false, true
+		}
+		if token, ok = p.takeJob(&p.normalQueue); ok {
+			p.highStarted = 0
+			return token, false, true
+		}
+		p.jobReady.Wait()
 	}
 }
 
-// Submit adds a job to the execution queue of this pool.
+// takeJob removes and returns the first job of the queue whose class is below its limit.
+// The caller must hold the pool mutex.
+func (p *pool) takeJob(queue *[]JobToken) (token JobToken, ok bool) {
+	for i, candidate := range *queue {
+		if limit, limited := p.options.ClassLimits[candidate.class]; limited && p.classRunning[candidate.class] >= limit {
+			continue
+		}
+		*queue = append((*queue)[:i:i], (*queue)[i+1:]...)
+		p.classRunning[candidate.class]++
+		p.nRunning++
+		p.queueSpace.Signal()
+		return candidate, true
+	}
+	return token, false
+}
+
+// jobDone releases the class of a finished job so waiting jobs of the class can start.
+func (p *pool) jobDone(token JobToken) {
+	p.mut.Lock()
+	defer p.mut.Unlock()
+	p.classRunning[token.class]--
+	p.nRunning--
+	if _, limited := p.options.ClassLimits[token.class]; limited || p.options.MemoryLimitBytes > 0 {
+		p.jobReady.Broadcast()
+	}
+}
+
+// memoryExceeded returns true if jobs are queued but none may start before a running job frees memory.
+// The caller must hold the pool mutex.
+func (p *pool) memoryExceeded() bool {
+	if p.options.MemoryLimitBytes == 0 || p.nRunning == 0 || len(p.highQueue)+len(p.normalQueue) == 0 {
+		return false
+	}
+	inUse := p.memoryInUse()
+	if inUse <= p.options.MemoryLimitBytes {
+		return false
+	}
+	p.log.Debugf("Process uses %d bytes of memory, above the limit of %d bytes, waiting for a running job to finish", inUse, p.options.MemoryLimitBytes)
+	return true
+}
+
+// processMemoryInUse returns the memory the process holds from the operating system
+func processMemoryInUse() uint64 {
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+	return stats.Sys - stats.HeapReleased
+}
+
+// queueFull returns true if no more normal priority jobs can be queued. The caller must hold the pool mutex.
+func (p *pool) queueFull() bool {
+	if p.options.QueuedJobsPerWorker <= 0 {
+		return false
+	}
+	return len(p.normalQueue) >= p.options.QueuedJobsPerWorker*(p.nWorkers-p.pendingRetires)
+}
+
+// Submit adds a job with normal priority to the execution queue of this pool.
 func (p *pool) Submit(log log.T, jobID string, job Job) (err error) {
+	return p.SubmitWithOptions(log, jobID, job, JobOptions{})
+}
+
+// SubmitWithOptions adds a job to the execution queue of this pool.
+func (p *pool) SubmitWithOptions(log log.T, jobID string, job Job, options JobOptions) (err error) {
 	token := JobToken{
 		id:         jobID,
 		job:        job,
 		cancelFlag: NewChanneledCancelFlag(),
 		log:        log,
+		class:      options.Class,
 	}
 	err = p.jobStore.AddJob(jobID, &token)
 	if err != nil {
 		return
 	}
-	p.jobQueue <- token
+
+	p.mut.Lock()
+	defer p.mut.Unlock()
+	for options.Priority != PriorityHigh && !p.isShutdown && p.queueFull() {
+		p.queueSpace.Wait()
+	}
+	if p.isShutdown {
+		p.jobStore.DeleteJob(jobID)
+		return fmt.Errorf("pool is shut down, job %v was not submitted", jobID)
+	}
+	if options.Priority == PriorityHigh {
+		p.highQueue = append(p.highQueue, token)
+	} else {
+		p.normalQueue = append(p.normalQueue, token)
+	}
+	p.jobReady.Signal()
 	return
 }
 
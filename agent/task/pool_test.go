@@ -119,3 +119,193 @@ func exercisePool(t *testing.T, pool Pool, jobID string, shouldCancel bool) {
 	// see that job completes
 	assert.True(t, <-jobState)
 }
+
+func TestPoolResize(t *testing.T) {
+	clock := times.NewMockedClock()
+	waitTimeout := 100 * time.Millisecond
+	shutdownTimeout := 10000 * time.Millisecond
+	clock.On("After", waitTimeout).Return(clock.AfterChannel)
+	clock.On("After", shutdownTimeout).Return(clock.AfterChannel)
+	clock.On("After", shutdownTimeout+waitTimeout).Return(clock.AfterChannel)
+
+	p := NewPool(logger, 1, waitTimeout, clock)
+
+	// grow the pool and check two jobs can run at the same time
+	p.Resize(2)
+	started := make(chan bool)
+	release := make(chan bool)
+	for i := 0; i < 2; i++ {
+		assert.Nil(t, p.Submit(logger, fmt.Sprintf("job-%d", i), func(CancelFlag) {
+			started <- true
+			<-release
+		}))
+	}
+	assert.True(t, <-started)
+	assert.True(t, <-started)
+	close(release)
+
+	// shrink the pool, the retired worker must not be waited on during shutdown
+	p.Resize(1)
+	assert.Nil(t, p.Submit(logger, "job-2", func(CancelFlag) {}))
+	assert.Nil(t, p.Submit(logger, "job-3", func(CancelFlag) {}))
+	assert.True(t, p.ShutdownAndWait(shutdownTimeout))
+}
+
+func TestPoolPriority(t *testing.T) {
+	clock := times.NewMockedClock()
+	waitTimeout := 100 * time.Millisecond
+	shutdownTimeout := 10000 * time.Millisecond
+	clock.On("After", waitTimeout).Return(clock.AfterChannel)
+	clock.On("After", shutdownTimeout).Return(clock.AfterChannel)
+	clock.On("After", shutdownTimeout+waitTimeout).Return(clock.AfterChannel)
+
+	p := NewPool(logger, 1, waitTimeout, clock)
+
+	// keep the only worker busy while the other jobs are queued
+	started := make(chan bool)
+	release := make(chan bool)
+	assert.Nil(t, p.Submit(logger, "blocking", func(CancelFlag) {
+		started <- true
+		<-release
+	}))
+	assert.True(t, <-started)
+
+	var order []string
+	done := make(chan bool)
+	record := func(jobID string) Job {
+		return func(CancelFlag) {
+			order = append(order, jobID)
+			done <- true
+		}
+	}
+	assert.Nil(t, p.Submit(logger, "normal-0", record("normal-0")))
+	assert.Nil(t, p.Submit(logger, "normal-1", record("normal-1")))
+	for i := 0; i < highPriorityStreak+2; i++ {
+		jobID := fmt.Sprintf("high-%d", i)
+		assert.Nil(t, p.SubmitWithOptions(logger, jobID, record(jobID), JobOptions{Priority: PriorityHigh}))
+	}
+	close(release)
+	for i := 0; i < highPriorityStreak+4; i++ {
+		<-done
+	}
+
+	// high priority jobs start first, but a normal job is started after highPriorityStreak of them
+	assert.Equal(t, []string{"high-0", "high-1", "high-2", "high-3", "normal-0", "high-4", "high-5", "normal-1"}, order)
+	assert.True(t, p.ShutdownAndWait(shutdownTimeout))
+	assert.NotNil(t, p.Submit(logger, "after-shutdown", func(CancelFlag) {}))
+}
+
+func TestPoolClassLimit(t *testing.T) {
+	clock := times.NewMockedClock()
+	waitTimeout := 100 * time.Millisecond
+	shutdownTimeout := 10000 * time.Millisecond
+	clock.On("After", waitTimeout).Return(clock.AfterChannel)
+	clock.On("After", shutdownTimeout).Return(clock.AfterChannel)
+	clock.On("After", shutdownTimeout+waitTimeout).Return(clock.AfterChannel)
+
+	p := NewPoolWithOptions(logger, 3, waitTimeout, clock, PoolOptions{ClassLimits: map[Class]int{ClassCPU: 1}})
+
+	started := make(chan string, 3)
+	release := make(chan bool)
+	job := func(jobID string) Job {
+		return func(CancelFlag) {
+			started <- jobID
+			<-release
+		}
+	}
+	assert.Nil(t, p.SubmitWithOptions(logger, "cpu-0", job("cpu-0"), JobOptions{Class: ClassCPU}))
+	assert.Nil(t, p.SubmitWithOptions(logger, "cpu-1", job("cpu-1"), JobOptions{Class: ClassCPU}))
+	assert.Nil(t, p.SubmitWithOptions(logger, "io-0", job("io-0"), JobOptions{Class: ClassIO}))
+
+	// the second cpu job waits for the first one although a worker is free
+	assert.Equal(t, "cpu-0", <-started)
+	assert.Equal(t, "io-0", <-started)
+	select {
+	case jobID := <-started:
+		assert.Fail(t, "job started above its class limit", jobID)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	release <- true
+	assert.Equal(t, "cpu-1", <-started)
+	close(release)
+	assert.True(t, p.ShutdownAndWait(shutdownTimeout))
+}
+
+func TestPoolQueueLimit(t *testing.T) {
+	clock := times.NewMockedClock()
+	waitTimeout := 100 * time.Millisecond
+	shutdownTimeout := 10000 * time.Millisecond
+	clock.On("After", waitTimeout).Return(clock.AfterChannel)
+	clock.On("After", shutdownTimeout).Return(clock.AfterChannel)
+	clock.On("After", shutdownTimeout+waitTimeout).Return(clock.AfterChannel)
+
+	p := NewPoolWithOptions(logger, 1, waitTimeout, clock, PoolOptions{QueuedJobsPerWorker: 1})
+
+	started := make(chan bool)
+	release := make(chan bool)
+	assert.Nil(t, p.Submit(logger, "running", func(CancelFlag) {
+		started <- true
+		<-release
+	}))
+	assert.True(t, <-started)
+	assert.Nil(t, p.Submit(logger, "queued", func(CancelFlag) {}))
+
+	// the queue is full, the next normal job blocks while a high priority job is still queued
+	submitted := make(chan bool)
+	go func() {
+		assert.Nil(t, p.Submit(logger, "blocked", func(CancelFlag) {}))
+		submitted <- true
+	}()
+	assert.Nil(t, p.SubmitWithOptions(logger, "urgent", func(CancelFlag) {}, JobOptions{Priority: PriorityHigh}))
+	select {
+	case <-submitted:
+		assert.Fail(t, "job submitted to a full queue")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(release)
+	assert.True(t, <-submitted)
+	assert.True(t, p.ShutdownAndWait(shutdownTimeout))
+}
+
+func TestPoolMemoryLimit(t *testing.T) {
+	clock := times.NewMockedClock()
+	waitTimeout := 100 * time.Millisecond
+	shutdownTimeout := 10000 * time.Millisecond
+	clock.On("After", waitTimeout).Return(clock.AfterChannel)
+	clock.On("After", shutdownTimeout).Return(clock.AfterChannel)
+	clock.On("After", shutdownTimeout+waitTimeout).Return(clock.AfterChannel)
+
+	p := NewPoolWithOptions(logger, 3, waitTimeout, clock, PoolOptions{MemoryLimitBytes: 100}).(*pool)
+	var inUse uint64 = 200
+	p.mut.Lock()
+	p.memoryInUse = func() uint64 { return inUse }
+	p.mut.Unlock()
+
+	started := make(chan string, 3)
+	release := make(chan bool)
+	job := func(jobID string) Job {
+		return func(CancelFlag) {
+			started <- jobID
+			<-release
+		}
+	}
+	// the first job starts above the limit since no job is running to free memory
+	assert.Nil(t, p.Submit(logger, "first", job("first")))
+	assert.Equal(t, "first", <-started)
+	assert.Nil(t, p.Submit(logger, "second", job("second")))
+	select {
+	case jobID := <-started:
+		assert.Fail(t, "job started above the memory limit", jobID)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	p.mut.Lock()
+	inUse = 50
+	p.mut.Unlock()
+	release <- true
+	assert.Equal(t, "second", <-started)
+	close(release)
+	assert.True(t, p.ShutdownAndWait(shutdownTimeout))
+}
@@ -33,6 +33,11 @@ func (mockPool *MockedPool) Submit(log log.T, jobID string, job Job) error {
 	return mockPool.Called(log, jobID, job).Error(0)
 }
 
+// SubmitWithOptions mocks the method with the same name.
+func (mockPool *MockedPool) SubmitWithOptions(log log.T, jobID string, job Job, options JobOptions) error {
+	return mockPool.Called(log, jobID, job, options).Error(0)
+}
+
 // Cancel mocks the method with the same name.
 func (mockPool *MockedPool) Cancel(jobID string) bool {
 	return mockPool.Called(jobID).Bool(0)
@@ -43,6 +48,11 @@ func (mockPool *MockedPool) Shutdown() {
 	mockPool.Called()
 }
 
+// CancelAll mocks the method with the same name.
+func (mockPool *MockedPool) CancelAll() {
+	mockPool.Called()
+}
+
 // ShutdownAndWait mocks the method with the same name.
 func (mockPool *MockedPool) ShutdownAndWait(timeout time.Duration) (finished bool) {
 	args := mockPool.Called(timeout)
@@ -55,6 +65,11 @@ func (mockPool *MockedPool) HasJob(jobID string) bool {
 	return args.Bool(0)
 }
 
+// Resize mocks the method with the same name.
+func (mockPool *MockedPool) Resize(maxParallel int) {
+	mockPool.Called(maxParallel)
+}
+
 // MockCancelFlag mocks a cancel flag.
 type MockCancelFlag struct {
 	mock.Mock
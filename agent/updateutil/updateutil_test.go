@@ -753,6 +753,8 @@ func (p *testProcess) Start(*model.WorkerConfig) (*model.Process, error) { retur
 
 func (p *testProcess) Kill(pid int) error { return nil }
 
+func (p *testProcess) Wait(pid int) error { return nil }
+
 func (p *testProcess) Processes() ([]executor.OsProcess, error) {
 	var allProcess []executor.OsProcess
 	var process = executor.OsProcess{
@@ -18,6 +18,7 @@ package updateutil
 
 import (
 	"os/exec"
+	"runtime"
 	"syscall"
 )
 
@@ -86,10 +87,17 @@ func prepareProcess(command *exec.Cmd) {
 }
 
 func agentStatusOutput() ([]byte, error) {
+	if runtime.GOOS == "freebsd" {
+		return execCommand("service", "amazon-ssm-agent", "status").Output()
+	}
 	return execCommand("status", "amazon-ssm-agent").Output()
 }
 
 func agentExpectedStatus() string {
+	if runtime.GOOS == "freebsd" {
+		// rc.d status output uses the rc variable name of the service
+		return "amazon_ssm_agent is running"
+	}
 	return "amazon-ssm-agent start/running"
 }
 
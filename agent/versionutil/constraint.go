@@ -0,0 +1,175 @@
+package versionutil
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Constraint is a range of versions such as ">=1.2.0 <2.0.0", "~1.4", "^2.1.0" or "1.x".
+// Comparators separated by spaces or commas must all match, alternatives are separated by "||".
+type Constraint struct {
+	ranges [][]comparator
+}
+
+type comparator struct {
+	operator string
+	version  string
+}
+
+var comparatorPattern = regexp.MustCompile(`^(==|=|!=|>=|<=|>|<|~|\^)?v?([0-9A-Za-z*+._-]+)$`)
+
+// IsConstraint returns true if the version is a range rather than a single version
+func IsConstraint(version string) bool {
+	version = strings.TrimSpace(version)
+	if strings.ContainsAny(version, "<>=!~^*|, ") {
+		return true
+	}
+	for _, component := range strings.Split(version, ".") {
+		if isWildcard(component) {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseConstraint parses a version range
+func ParseConstraint(value string) (constraint Constraint, err error) {
+	for _, alternative := range strings.Split(value, "||") {
+		tokens := strings.Fields(strings.Replace(alternative, ",", " ", -1))
+		if len(tokens) == 0 {
+			return constraint, fmt.Errorf("invalid version constraint %q: empty range", value)
+		}
+
+		var comparators []comparator
+		for i := 0; i < len(tokens); i++ {
+			token := tokens[i]
+			// allow whitespace between an operator and its version
+			if strings.Trim(token, "=!<>~^") == "" && i+1 < len(tokens) {
+				i++
+				token += tokens[i]
+			}
+			var expanded []comparator
+			if expanded, err = parseComparator(token); err != nil {
+				return constraint, fmt.Errorf("invalid version constraint %q: %v", value, err)
+			}
+			comparators = append(comparators, expanded...)
+		}
+		constraint.ranges = append(constraint.ranges, comparators)
+	}
+	return constraint, nil
+}
+
+// Matches returns true if the version is in the range
+func (constraint Constraint) Matches(version string) bool {
+	for _, comparators := range constraint.ranges {
+		matches := true
+		for _, c := range comparators {
+			if !c.matches(version) {
+				matches = false
+				break
+			}
+		}
+		if matches {
+			return true
+		}
+	}
+	return false
+}
+
+// Highest returns the highest of the versions in the range
+func (constraint Constraint) Highest(versions []string) (highest string, found bool) {
+	for _, version := range versions {
+		if constraint.Matches(version) && (!found || Compare(version, highest, false) > 0) {
+			highest, found = version, true
+		}
+	}
+	return highest, found
+}
+
+func (c comparator) matches(version string) bool {
+	result := Compare(version, c.version, false)
+	switch c.operator {
+	case "!=":
+		return result != 0
+	case ">":
+		return result > 0
+	case ">=":
+		return result >= 0
+	case "<":
+		return result < 0
+	case "<=":
+		return result <= 0
+	default:
+		return result == 0
+	}
+}
+
+// parseComparator expands tilde, caret and wildcard comparators into lower and upper bounds
+func parseComparator(token string) ([]comparator, error) {
+	groups := comparatorPattern.FindStringSubmatch(token)
+	if groups == nil {
+		return nil, fmt.Errorf("%q is not a valid comparator", token)
+	}
+	operator, version := groups[1], groups[2]
+	if operator == "==" {
+		operator = "="
+	}
+
+	components := strings.Split(version, ".")
+	for i, component := range components {
+		if !isWildcard(component) {
+			continue
+		}
+		if operator != "" && operator != "=" {
+			return nil, fmt.Errorf("wildcard version %q can not be used with %v", version, operator)
+		}
+		if i == 0 {
+			// matches any version
+			return []comparator{}, nil
+		}
+		return bounds(components[:i], i-1)
+	}
+
+	switch operator {
+	case "~":
+		// allows patch updates, or minor updates when only the major version is given
+		if len(components) == 1 {
+			return bounds(components, 0)
+		}
+		return bounds(components, 1)
+	case "^":
+		// allows updates that do not change the first non zero component
+		significant := len(components) - 1
+		for i, component := range components {
+			if component != "0" {
+				significant = i
+				break
+			}
+		}
+		return bounds(components, significant)
+	case "":
+		operator = "="
+	}
+	return []comparator{{operator: operator, version: version}}, nil
+}
+
+// bounds returns the range from the version up to the next increment of the component at the index
+func bounds(components []string, index int) ([]comparator, error) {
+	upper := make([]string, index+1)
+	copy(upper, components[:index+1])
+	number, err := strconv.Atoi(strings.SplitN(upper[index], "-", 2)[0])
+	if err != nil {
+		return nil, fmt.Errorf("version component %q is not numeric", upper[index])
+	}
+	upper[index] = strconv.Itoa(number + 1)
+	return []comparator{
+		{operator: ">=", version: strings.Join(components, ".")},
+		{operator: "<", version: strings.Join(upper, ".")},
+	}, nil
+}
+
+func isWildcard(component string) bool {
+	return component == "x" || component == "X" || component == "*"
+}
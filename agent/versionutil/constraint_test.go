@@ -0,0 +1,67 @@
+package versionutil
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsConstraint(t *testing.T) {
+	for _, constraint := range []string{">=1.0", "~1.2", "^1.2.3", "1.x", "1.2.*", "*", "1.0 || 2.0", ">1.0, <2.0"} {
+		assert.True(t, IsConstraint(constraint), constraint)
+	}
+	for _, version := range []string{"", "latest", "1.2.3", "1.0.0-beta+exp", "10.0.14393.0"} {
+		assert.False(t, IsConstraint(version), version)
+	}
+}
+
+func TestConstraintMatches(t *testing.T) {
+	tests := []struct {
+		constraint string
+		matches    []string
+		excludes   []string
+	}{
+		{">=1.2.0 <2.0.0", []string{"1.2.0", "1.9.9", "1.10"}, []string{"1.1.9", "2.0.0", "2.1"}},
+		{">= 1.2, < 2", []string{"1.2", "1.5.3"}, []string{"1.1", "2.0.0"}},
+		{"~1.4", []string{"1.4.0", "1.4.9"}, []string{"1.3.9", "1.5.0"}},
+		{"~1.4.2", []string{"1.4.2", "1.4.10"}, []string{"1.4.1", "1.5.0"}},
+		{"~1", []string{"1.0.0", "1.9.0"}, []string{"0.9", "2.0.0"}},
+		{"^2.1.0", []string{"2.1.0", "2.9.9"}, []string{"2.0.9", "3.0.0"}},
+		{"^0.2.3", []string{"0.2.3", "0.2.9"}, []string{"0.2.2", "0.3.0"}},
+		{"^0.0.3", []string{"0.0.3"}, []string{"0.0.4", "0.1.0"}},
+		{"1.x", []string{"1.0.0", "1.99"}, []string{"0.9", "2.0"}},
+		{"1.2.*", []string{"1.2.0", "1.2.7"}, []string{"1.3.0"}},
+		{"*", []string{"0.0.1", "42.0"}, nil},
+		{"!=1.5.0", []string{"1.4.0", "1.6.0"}, []string{"1.5.0", "1.5"}},
+		{"=1.5.0", []string{"1.5.0", "1.5"}, []string{"1.5.1"}},
+		{"1.0 || >=3.0", []string{"1.0.0", "3.1"}, []string{"2.0"}},
+	}
+
+	for _, test := range tests {
+		constraint, err := ParseConstraint(test.constraint)
+		assert.NoError(t, err, test.constraint)
+		for _, version := range test.matches {
+			assert.True(t, constraint.Matches(version), "%v should match %v", test.constraint, version)
+		}
+		for _, version := range test.excludes {
+			assert.False(t, constraint.Matches(version), "%v should not match %v", test.constraint, version)
+		}
+	}
+}
+
+func TestParseConstraintErrors(t *testing.T) {
+	for _, constraint := range []string{">=", "1.0 ||", "=>1.0", ">1.x", "~a.b", "1.0 <> 2.0"} {
+		_, err := ParseConstraint(constraint)
+		assert.Error(t, err, constraint)
+	}
+}
+
+func TestConstraintHighest(t *testing.T) {
+	constraint, _ := ParseConstraint("^1.2")
+	highest, found := constraint.Highest([]string{"1.1.0", "1.10.0", "1.9.3", "2.0.0", "1.2.0"})
+	assert.True(t, found)
+	assert.Equal(t, "1.10.0", highest)
+
+	_, found = constraint.Highest([]string{"0.9", "2.0"})
+	assert.False(t, found)
+}
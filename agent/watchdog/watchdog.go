@@ -0,0 +1,188 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+// Package watchdog detects workers of the core modules that stopped making progress and restarts them
+package watchdog
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"runtime/pprof"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/context"
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/schedule"
+)
+
+const (
+	name = "Watchdog"
+
+	// checkInterval is how often the workers are checked for progress
+	checkInterval = time.Minute
+
+	// dumpFileName is the file in the log folder the goroutines are dumped to before a stalled worker is restarted,
+	// only the dump of the last stall is kept
+	dumpFileName = "watchdog-dump.log"
+)
+
+// worker is a loop of a core module whose progress is watched
+type worker struct {
+	period     time.Duration
+	restart    func(log.T)
+	lastBeat   time.Time
+	restarts   int
+	restarting bool
+}
+
+var (
+	lock    sync.Mutex
+	workers = make(map[string]*worker)
+
+	logDir = log.DefaultLogDir
+)
+
+// Register watches the progress of a worker. A healthy worker calls Beat at least once every period, restart is called
+// when it made no progress for the configured stall timeout beyond its period. Registering a name again replaces the worker.
+func Register(workerName string, period time.Duration, restart func(log.T)) {
+	lock.Lock()
+	defer lock.Unlock()
+	workers[workerName] = &worker{period: period, restart: restart, lastBeat: time.Now()}
+}
+
+// Unregister stops watching a worker, modules unregister their workers when they stop
+func Unregister(workerName string) {
+	lock.Lock()
+	defer lock.Unlock()
+	delete(workers, workerName)
+}
+
+// Beat records the progress of a worker, it does nothing for workers that are not registered
+func Beat(workerName string) {
+	lock.Lock()
+	defer lock.Unlock()
+	if w, ok := workers[workerName]; ok {
+		w.lastBeat = time.Now()
+	}
+}
+
+// Watchdog is the core module checking the registered workers for progress
+type Watchdog struct {
+	context context.T
+	job     *schedule.Job
+}
+
+// NewWatchdog creates a new watchdog core module
+func NewWatchdog(context context.T) *Watchdog {
+	return &Watchdog{context: context.With("[" + name + "]")}
+}
+
+// ModuleName returns the name of the module
+func (w *Watchdog) ModuleName() string {
+	return name
+}
+
+// ModuleExecute starts checking the workers for progress
+func (w *Watchdog) ModuleExecute(context context.T) (err error) {
+	config := w.context.AppConfig().Watchdog
+	if !config.Enabled {
+		w.context.Log().Info("Watchdog is disabled")
+		return nil
+	}
+	stallTimeout := time.Duration(config.StallTimeoutSeconds) * time.Second
+	w.job = schedule.Every(checkInterval, func() { check(w.context.Log(), stallTimeout) })
+	return nil
+}
+
+// ModuleRequestStop stops checking the workers
+func (w *Watchdog) ModuleRequestStop(stopType contracts.StopType) (err error) {
+	if w.job != nil {
+		w.job.Stop()
+	}
+	return nil
+}
+
+// check restarts the workers that made no progress for stallTimeout beyond their period
+func check(log log.T, stallTimeout time.Duration) {
+	now := time.Now()
+	lock.Lock()
+	var stalled []string
+	for workerName, w := range workers {
+		if !w.restarting && now.Sub(w.lastBeat) > w.period+stallTimeout {
+			stalled = append(stalled, workerName)
+		}
+	}
+	sort.Strings(stalled)
+	lock.Unlock()
+
+	for _, workerName := range stalled {
+		restart(log, workerName, now)
+	}
+}
+
+// restart dumps the goroutines of the agent and restarts a stalled worker. The worker gets a full period and stall
+// timeout to make progress again before it is restarted again.
+func restart(log log.T, workerName string, now time.Time) {
+	lock.Lock()
+	w, ok := workers[workerName]
+	if !ok {
+		lock.Unlock()
+		return
+	}
+	stalledFor := now.Sub(w.lastBeat)
+	w.lastBeat = now
+	w.restarts++
+	w.restarting = true
+	restarts := w.restarts
+	lock.Unlock()
+
+	log.Errorf("watchdog: %v made no progress for %v, restarting it (restart %v)", workerName, stalledFor.Round(time.Second), restarts)
+	if path, err := dump(workerName, stalledFor, restarts); err != nil {
+		log.Warnf("watchdog: failed to dump the goroutines: %v", err)
+	} else {
+		log.Errorf("watchdog: goroutines of the agent were dumped to %v", path)
+	}
+
+	// the restart may block on the stalled worker, it must not keep the other workers from being checked
+	go func() {
+		defer func() {
+			if msg := recover(); msg != nil {
+				log.Errorf("watchdog: restarting %v panicked: %v\n%s", workerName, msg, debug.Stack())
+			}
+			lock.Lock()
+			w.restarting = false
+			lock.Unlock()
+		}()
+		w.restart(log)
+	}()
+}
+
+// dump writes the stacks of all goroutines to the dump file in the log folder
+func dump(workerName string, stalledFor time.Duration, restarts int) (path string, err error) {
+	path = filepath.Join(logDir, dumpFileName)
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, appconfig.ReadWriteAccess)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	if _, err = fmt.Fprintf(file, "%v: %v made no progress for %v, restart %v\n\n",
+		time.Now().UTC().Format(time.RFC3339), workerName, stalledFor.Round(time.Second), restarts); err != nil {
+		return "", err
+	}
+	return path, pprof.Lookup("goroutine").WriteTo(file, 2)
+}
@@ -0,0 +1,91 @@
+// Copyright 2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+package watchdog
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func setLastBeat(workerName string, lastBeat time.Time) {
+	lock.Lock()
+	defer lock.Unlock()
+	workers[workerName].lastBeat = lastBeat
+}
+
+func TestBeat(t *testing.T) {
+	defer Unregister("worker")
+	Register("worker", time.Minute, func(log.T) {})
+	setLastBeat("worker", time.Time{})
+
+	Beat("worker")
+	Beat("unregistered")
+	lock.Lock()
+	defer lock.Unlock()
+	assert.WithinDuration(t, time.Now(), workers["worker"].lastBeat, time.Second)
+	assert.NotContains(t, workers, "unregistered")
+}
+
+func TestCheck_RestartsStalledWorker(t *testing.T) {
+	dir, err := ioutil.TempDir("", "watchdog")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	defer func(original string) { logDir = original }(logDir)
+	logDir = dir
+	defer Unregister("stalled")
+	defer Unregister("healthy")
+
+	restarted := make(chan string, 2)
+	Register("stalled", time.Minute, func(log.T) { restarted <- "stalled" })
+	Register("healthy", time.Minute, func(log.T) { restarted <- "healthy" })
+	setLastBeat("stalled", time.Now().Add(-time.Hour))
+
+	check(log.NewMockLog(), 10*time.Minute)
+	select {
+	case name := <-restarted:
+		assert.Equal(t, "stalled", name)
+	case <-time.After(5 * time.Second):
+		assert.Fail(t, "the stalled worker was not restarted")
+	}
+
+	content, err := ioutil.ReadFile(filepath.Join(dir, dumpFileName))
+	assert.NoError(t, err)
+	assert.True(t, strings.Contains(string(content), "stalled made no progress for 1h0m0s, restart 1"))
+	assert.True(t, strings.Contains(string(content), "goroutine"))
+
+	// the restarted worker gets its period and the stall timeout to make progress again
+	check(log.NewMockLog(), 10*time.Minute)
+	select {
+	case name := <-restarted:
+		assert.Fail(t, "restarted again", name)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestCheck_UnregisteredWorker(t *testing.T) {
+	restarted := false
+	Register("stopped", time.Minute, func(log.T) { restarted = true })
+	setLastBeat("stopped", time.Now().Add(-time.Hour))
+	Unregister("stopped")
+
+	check(log.NewMockLog(), time.Minute)
+	time.Sleep(10 * time.Millisecond)
+	assert.False(t, restarted)
+}
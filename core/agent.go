@@ -24,6 +24,8 @@ import (
 
 	"github.com/aws/amazon-ssm-agent/agent/appconfig"
 	logger "github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/performance"
+	"github.com/aws/amazon-ssm-agent/agent/privilege"
 	"github.com/aws/amazon-ssm-agent/core/app"
 	"github.com/aws/amazon-ssm-agent/core/app/bootstrap"
 	"github.com/aws/amazon-ssm-agent/core/ipc/messagebus"
@@ -49,6 +51,8 @@ var (
 
 func start(log logger.T, instanceIDPtr *string, regionPtr *string) (app.CoreAgent, logger.T, error) {
 	log.WriteEvent(logger.AgentTelemetryMessage, "", logger.AmazonAgentStartEvent)
+	logger.StartRotatedLogManager(log, logger.DefaultLogDir)
+	registerNode(log)
 
 	bs := bootstrap.NewBootstrap(log, filesystem.NewFileSystem())
 	context, err := bs.Init(instanceIDPtr, regionPtr)
@@ -57,11 +61,18 @@ func start(log logger.T, instanceIDPtr *string, regionPtr *string) (app.CoreAgen
 	}
 
 	context = context.With("[amazon-ssm-agent]")
+	performance.Apply(context.Log(), context.AppConfig().Performance)
+
+	// pick up log sink changes without restarting the core agent, the worker watches the config on its own
+	appconfig.StartConfigWatcher()
+
 	message := messagebus.NewMessageBus(context)
 	if err := message.Start(); err != nil {
 		return nil, log, fmt.Errorf("failed to start message bus, %s", err)
 	}
 
+	privilege.StartBroker(context.Log())
+
 	ssmAgentCore := app.NewSSMCoreAgent(context, message)
 	ssmAgentCore.Start()
 
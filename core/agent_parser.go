@@ -188,6 +188,35 @@ func registerManagedInstance() (managedInstanceID string, err error) {
 	return managedInstanceID, nil
 }
 
+// registerNode registers the node an agent in a container runs on with the hybrid activation of the host config,
+// unless the node is registered already. The registration is kept in the agent data store, mounted from the host,
+// so the node keeps its identity when the container is replaced.
+func registerNode(log logger.T) {
+	config, err := appconfig.Config(false)
+	if err != nil || config.Agent.ContainerMode || config.Host.ActivationId == "" || !platform.IsRunningInContainer() {
+		return
+	}
+	if registration.InstanceID() != "" {
+		log.Debugf("Node is registered as %v", registration.InstanceID())
+		return
+	}
+
+	code, err := ioutil.ReadFile(config.Host.ActivationCodeFile)
+	if err != nil {
+		log.Errorf("Failed to read the activation code of the node: %v", err)
+		return
+	}
+	activationCode, activationID, region = strings.TrimSpace(string(code)), config.Host.ActivationId, config.Host.ActivationRegion
+	platform.SetRegion(region)
+
+	managedInstanceID, err := registerManagedInstance()
+	if err != nil {
+		log.Errorf("Node registration failed due to %v", err)
+		return
+	}
+	log.Infof("Successfully registered the node with AWS SSM using Managed instance-id: %s", managedInstanceID)
+}
+
 // clearRegistration clears any existing registration data
 func clearRegistration(log logger.T) (exitCode int) {
 	err := registration.UpdateServerInfo("", "", "", "")
@@ -17,6 +17,8 @@ package app
 import (
 	"runtime"
 
+	"github.com/aws/amazon-ssm-agent/agent/schedule"
+	"github.com/aws/amazon-ssm-agent/agent/systemd"
 	"github.com/aws/amazon-ssm-agent/agent/version"
 	"github.com/aws/amazon-ssm-agent/core/app/context"
 	reboot "github.com/aws/amazon-ssm-agent/core/app/reboot/model"
@@ -32,11 +34,15 @@ type CoreAgent interface {
 
 // SSMCoreAgent encapsulates the core functionality of the agent
 type SSMCoreAgent struct {
-	context    context.ICoreAgentContext
-	container  longrunningprovider.IContainer
-	selfupdate selfupdate.ISelfUpdate
+	context     context.ICoreAgentContext
+	container   longrunningprovider.IContainer
+	selfupdate  selfupdate.ISelfUpdate
+	watchdogJob *schedule.Job
 }
 
+var notify = systemd.Notify
+var watchdogInterval = systemd.WatchdogInterval
+
 // NewSSMCoreAgent creates and returns and object of type CoreAgent interface
 func NewSSMCoreAgent(context context.ICoreAgentContext, messageBus messagebus.IMessageBus) CoreAgent {
 
@@ -57,15 +63,34 @@ func (agent *SSMCoreAgent) Start() {
 	agent.container.Start()
 	go agent.container.Monitor()
 	agent.selfupdate.Start()
+	if interval := watchdogInterval(); interval > 0 {
+		log.Infof("Pinging the systemd watchdog every %v", interval/2)
+		agent.watchdogJob = schedule.Every(interval/2, agent.pingWatchdog)
+	}
 	log.Flush()
 }
 
+// pingWatchdog keeps systemd from restarting the agent, it runs twice per watchdog interval
+func (agent *SSMCoreAgent) pingWatchdog() {
+	if _, err := notify(systemd.Watchdog); err != nil {
+		agent.context.Log().Warnf("Failed to ping the systemd watchdog, %v", err)
+	}
+}
+
 // Stop the core manager
 func (agent *SSMCoreAgent) Stop() {
 	log := agent.context.Log()
 	log.Info("Stopping Core Agent")
 	log.Flush()
 
+	// workers drain their in-flight documents, systemd must not treat the stop as a hang
+	if _, err := notify(systemd.Stopping, systemd.Status("Draining in-flight documents")); err != nil {
+		log.Warnf("Failed to notify systemd, %v", err)
+	}
+	if agent.watchdogJob != nil {
+		agent.watchdogJob.Stop()
+	}
+
 	agent.selfupdate.Stop()
 	agent.container.Stop(reboot.StopTypeHardStop)
 	log.Info("Bye.")
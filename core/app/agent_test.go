@@ -20,7 +20,9 @@ import (
 	"time"
 
 	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/systemd"
 	contextmocks "github.com/aws/amazon-ssm-agent/core/app/context/mocks"
+	reboot "github.com/aws/amazon-ssm-agent/core/app/reboot/model"
 	selfupdatemocks "github.com/aws/amazon-ssm-agent/core/app/selfupdate/mocks"
 	containermocks "github.com/aws/amazon-ssm-agent/core/workerprovider/longrunningprovider/mocks"
 	"github.com/stretchr/testify/suite"
@@ -81,3 +83,36 @@ func (suite *AgentTestSuite) TestAgentStart_WithStartWorkerError() {
 
 	suite.mockconatiner.AssertExpectations(suite.T())
 }
+
+func (suite *AgentTestSuite) TestAgentWatchdog() {
+	notifications := make(chan string, 10)
+	notify = func(states ...string) (bool, error) {
+		notifications <- states[0]
+		return true, nil
+	}
+	watchdogInterval = func() time.Duration { return 20 * time.Millisecond }
+	defer func() {
+		notify = systemd.Notify
+		watchdogInterval = systemd.WatchdogInterval
+	}()
+	suite.mockconatiner.On("Monitor").Return()
+	suite.mockconatiner.On("Start").Return([]error{})
+	suite.mockconatiner.On("Stop", reboot.StopTypeHardStop).Return()
+	suite.mockselfupdate.On("Start").Return()
+	suite.mockselfupdate.On("Stop").Return()
+
+	suite.coreAgent.Start()
+	suite.Equal(systemd.Watchdog, <-notifications)
+
+	suite.coreAgent.Stop()
+	stopping := false
+	for !stopping {
+		select {
+		case state := <-notifications:
+			stopping = state == systemd.Stopping
+		case <-time.After(time.Second):
+			suite.FailNow("systemd was not notified of the stop")
+		}
+	}
+	suite.mockconatiner.AssertExpectations(suite.T())
+}
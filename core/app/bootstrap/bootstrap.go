@@ -20,6 +20,7 @@ import (
 	"time"
 
 	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/instancestate"
 	"github.com/aws/amazon-ssm-agent/agent/log"
 	logger "github.com/aws/amazon-ssm-agent/agent/log"
 	"github.com/aws/amazon-ssm-agent/agent/platform"
@@ -81,6 +82,9 @@ func (bs *Bootstrap) Init(instanceIdPtr *string, regionPtr *string) (context.ICo
 	}
 	logger.Debug("Using instanceID:", instanceId)
 
+	// an instance launched from an image prepared with clear-instance-state starts without the state of the builder
+	instancestate.ReinitializeAfterClear(logger, instanceId)
+
 	config, err := appconfig.Config(true)
 	if err != nil {
 		return nil, logger.Errorf("app config could not be loaded - %v", err)
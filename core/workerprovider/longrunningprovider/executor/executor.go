@@ -18,11 +18,17 @@ import (
 	"fmt"
 	"os"
 	"os/exec"
+	"time"
 
 	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/privilege"
+	"github.com/aws/amazon-ssm-agent/agent/systemd"
 	"github.com/aws/amazon-ssm-agent/core/workerprovider/longrunningprovider/model"
 )
 
+// waitPollInterval is how often Wait checks whether a process which is not a child of the agent exited
+var waitPollInterval = time.Second
+
 // OsProcess represent the process information for the worker, such as pid and binary name
 type OsProcess struct {
 	Pid        int
@@ -35,6 +41,7 @@ type IExecutor interface {
 	Start(*model.WorkerConfig) (*model.Process, error)
 	Processes() ([]OsProcess, error)
 	Kill(pid int) error
+	Wait(pid int) error
 }
 
 // ProcessExecutor is specially added for testing purposes
@@ -61,6 +68,12 @@ func (exc *ProcessExecutor) Start(workerConfig *model.WorkerConfig) (*model.Proc
 	prepareProcess(command)
 	// configure environment variables
 	prepareEnvironment(command)
+	if workerConfig.Name == model.SSMAgentWorkerName {
+		// the agent worker runs as the unprivileged user when the privilege broker is running
+		privilege.PrepareWorker(command)
+		// the agent worker serves the sockets systemd passed to the core agent
+		command.ExtraFiles = systemd.ListenFiles()
+	}
 
 	if err := command.Start(); err != nil {
 		return &model.Process{
@@ -114,3 +127,31 @@ func prepareEnvironment(command *exec.Cmd) {
 func fmtEnvVariable(name string, val string) string {
 	return fmt.Sprintf("%s=%s", name, val)
 }
+
+// Wait blocks until the process exited, it reaps the process when it is a child of the agent
+func (exc *ProcessExecutor) Wait(pid int) error {
+	if osProcess, err := os.FindProcess(pid); err == nil {
+		if _, err = osProcess.Wait(); err == nil {
+			return nil
+		}
+	}
+
+	// the process was started by a previous execution of the agent, poll until it is gone
+	for {
+		processes, err := exc.Processes()
+		if err != nil {
+			return err
+		}
+		running := false
+		for _, process := range processes {
+			if process.Pid == pid {
+				running = true
+				break
+			}
+		}
+		if !running {
+			return nil
+		}
+		time.Sleep(waitPollInterval)
+	}
+}
@@ -83,7 +83,8 @@ func validateEnvironmentVariables(command *exec.Cmd) {
 //Unix man: http://www.skrenta.com/rt/man/ps.1.html , return the process table of the current user, in agent it'll be root
 //verified on RHEL, Amazon Linux, Ubuntu, Centos, FreeBSD and Darwin
 var listProcessPs = func() ([]byte, error) {
-	return exec.Command("ps", "-e", "-o", "pid,ppid,command").CombinedOutput()
+	// -A lists all processes on linux, darwin and freebsd, -e shows the environment on freebsd
+	return exec.Command("ps", "-A", "-o", "pid,ppid,command").CombinedOutput()
 }
 
 // Unix man: http://man7.org/linux/man-pages/man5/proc.5.html
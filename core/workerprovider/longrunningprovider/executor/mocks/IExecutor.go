@@ -74,3 +74,17 @@ func (_m *IExecutor) Start(_a0 *model.WorkerConfig) (*model.Process, error) {
 
 	return r0, r1
 }
+
+// Wait provides a mock function with given fields: pid
+func (_m *IExecutor) Wait(pid int) error {
+	ret := _m.Called(pid)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(int) error); ok {
+		r0 = rf(pid)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
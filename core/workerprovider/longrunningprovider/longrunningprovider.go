@@ -143,7 +143,8 @@ func (container *WorkerContainer) Stop(stopType reboot.StopType) {
 	container.stopWorkerMonitor <- true
 
 	request := message.CreateTerminateWorkerRequest()
-	if results, err := container.messageBus.SendSurveyMessage(request); err != nil {
+	results, err := container.messageBus.SendSurveyMessage(request)
+	if err != nil {
 		logger.Errorf("failed to broadcast core termination signal %s", err)
 	} else {
 		for _, result := range results {
@@ -152,7 +153,15 @@ func (container *WorkerContainer) Stop(stopType reboot.StopType) {
 	}
 
 	container.messageBus.Stop()
-	sleep(reboot.HardStopTimeout)
+	if err != nil {
+		sleep(reboot.HardStopTimeout)
+	} else {
+		// workers finish their in-flight documents before they exit, at most until their shutdown deadline
+		drainTimeout := reboot.HardStopTimeout + time.Duration(container.context.AppConfig().Shutdown.DeadlineSeconds)*time.Second
+		if !container.workerProvider.WaitForWorkersToExit(drainTimeout) {
+			logger.Warnf("Workers are still running %v after the termination signal", drainTimeout)
+		}
+	}
 
 	// If agent parent is 0, force terminate and clean up all worker processes
 	if getPpid() == 0 {
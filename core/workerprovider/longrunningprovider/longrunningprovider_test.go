@@ -142,6 +142,7 @@ func (suite *LongRunningProviderTestSuite) TestStopWorker_SuccessSendSurvey() {
 	}
 	suite.messageBus.On("SendSurveyMessage", mock.Anything).Return(response, nil).Once()
 	suite.messageBus.On("Stop").Return().Once()
+	suite.workerProvider.On("WaitForWorkersToExit", reboot.HardStopTimeout+30*time.Second).Return(true).Once()
 	suite.workerProvider.AssertNotCalled(suite.T(), "KillAllWorkerProcesses")
 
 	suite.container.Stop(reboot.StopTypeHardStop)
@@ -158,6 +159,7 @@ func (suite *LongRunningProviderTestSuite) TestStopWorker_ParentProcessZero() {
 
 	suite.messageBus.On("SendSurveyMessage", mock.Anything).Return([]*message.Message{}, nil).Once()
 	suite.messageBus.On("Stop").Return().Once()
+	suite.workerProvider.On("WaitForWorkersToExit", mock.Anything).Return(false).Once()
 	suite.workerProvider.On("KillAllWorkerProcesses").Return().Once()
 
 	suite.container.Stop(reboot.StopTypeHardStop)
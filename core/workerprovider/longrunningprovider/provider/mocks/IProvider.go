@@ -3,6 +3,8 @@
 package mocks
 
 import (
+	time "time"
+
 	message "github.com/aws/amazon-ssm-agent/common/message"
 	mock "github.com/stretchr/testify/mock"
 
@@ -28,3 +30,17 @@ func (_m *IProvider) Start(_a0 map[string]*model.WorkerConfig, _a1 []*message.Me
 func (_m *IProvider) KillAllWorkerProcesses() {
 	_m.Called()
 }
+
+// WaitForWorkersToExit provides a mock function with given fields: timeout
+func (_m *IProvider) WaitForWorkersToExit(timeout time.Duration) bool {
+	ret := _m.Called(timeout)
+
+	var r0 bool
+	if rf, ok := ret.Get(0).(func(time.Duration) bool); ok {
+		r0 = rf(timeout)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	return r0
+}
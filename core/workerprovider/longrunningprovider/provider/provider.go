@@ -19,6 +19,7 @@ import (
 	"runtime/debug"
 	"strconv"
 	"sync"
+	"time"
 
 	"github.com/aws/amazon-ssm-agent/common/message"
 	"github.com/aws/amazon-ssm-agent/core/app/context"
@@ -31,6 +32,7 @@ type IProvider interface {
 	Start(map[string]*model.WorkerConfig, []*message.Message)
 	Monitor(map[string]*model.WorkerConfig, []*message.Message)
 	KillAllWorkerProcesses()
+	WaitForWorkersToExit(timeout time.Duration) bool
 }
 
 // WorkerProvider owns workerPool, it auto discovers the worker config and the running processes
@@ -195,6 +197,36 @@ func (w *WorkerProvider) KillAllWorkerProcesses() {
 	}
 }
 
+// WaitForWorkersToExit waits until the worker processes exited, it returns false when a worker
+// is still running after the timeout
+func (w *WorkerProvider) WaitForWorkersToExit(timeout time.Duration) bool {
+	logger := w.context.Log()
+
+	var pids []int
+	for _, worker := range w.workerPool {
+		for pid := range worker.Processes {
+			pids = append(pids, pid)
+		}
+	}
+
+	exited := make(chan bool, 1)
+	go func() {
+		for _, pid := range pids {
+			if err := w.exec.Wait(pid); err != nil {
+				logger.Warnf("Failed to wait for worker process (pid:%v), %s", pid, err)
+			}
+		}
+		exited <- true
+	}()
+
+	select {
+	case <-exited:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
 func (w *WorkerProvider) terminateOrphanSsmAgentWorker() {
 	logger := w.context.Log()
 
@@ -17,6 +17,7 @@ package provider
 import (
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/aws/amazon-ssm-agent/agent/log"
 	"github.com/aws/amazon-ssm-agent/common/message"
@@ -187,3 +188,27 @@ func (suite *WorkerProviderTestSuite) TestKillAllWorkerProcesses_Failure() {
 	assert.Equal(suite.T(), worker.Processes[failurePid].Pid, failurePid)
 	assert.Equal(suite.T(), worker.Processes[failurePid].Status, model.Active)
 }
+
+func (suite *WorkerProviderTestSuite) TestWaitForWorkersToExit() {
+	suite.provider.workerPool[model.SSMAgentWorkerName] = &model.Worker{
+		Name:      model.SSMAgentWorkerName,
+		Config:    &model.WorkerConfig{},
+		Processes: map[int]*model.Process{10: {Pid: 10, Status: model.Active}},
+	}
+	suite.exec.On("Wait", 10).Return(nil).Once()
+
+	assert.True(suite.T(), suite.provider.WaitForWorkersToExit(time.Second))
+	suite.exec.AssertExpectations(suite.T())
+}
+
+func (suite *WorkerProviderTestSuite) TestWaitForWorkersToExit_Timeout() {
+	suite.provider.workerPool[model.SSMAgentWorkerName] = &model.Worker{
+		Name:      model.SSMAgentWorkerName,
+		Config:    &model.WorkerConfig{},
+		Processes: map[int]*model.Process{10: {Pid: 10, Status: model.Active}},
+	}
+	// the worker is still draining its documents
+	suite.exec.On("Wait", 10).After(time.Second).Return(nil).Once()
+
+	assert.False(suite.T(), suite.provider.WaitForWorkersToExit(10*time.Millisecond))
+}
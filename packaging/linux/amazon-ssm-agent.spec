@@ -30,6 +30,7 @@ This package provides Amazon SSM Agent for managing EC2 Instances using SSM APIs
 
 %config(noreplace) /etc/init/amazon-ssm-agent.conf
 %config(noreplace) /etc/systemd/system/amazon-ssm-agent.service
+%config(noreplace) /etc/systemd/system/amazon-ssm-agent.socket
 
 # The scriptlets in %pre and %post are run before and after a package is installed.
 # The scriptlets %preun and %postun are run before and after a package is uninstalled.